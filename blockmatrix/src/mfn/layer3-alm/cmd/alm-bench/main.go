@@ -0,0 +1,626 @@
+// ALM routing benchmarks - validates the improvement target over the HTTP baseline
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/bench"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+// Exit codes
+const (
+	exitTargetAchieved = 0
+	exitTargetMissed   = 1
+	exitUsage          = 2
+	exitFailed         = 3
+	exitRegressed      = 4
+	exitLeaked         = 5
+)
+
+// subcommand is one kind of benchmark. setup registers its flags and
+// returns what builds its target once they are parsed. Benchmarks of a
+// remote deployment set remote to the job their flags fill in, which lets
+// -agents spread them across benchmark agents.
+type subcommand struct {
+	summary string
+	setup   func(fs *flag.FlagSet, config *bench.Config) func(ctx context.Context) (bench.Target, func(), error)
+	remote  *bench.Job
+}
+
+// grpcJob is the deployment the grpc subcommand benchmarks
+var grpcJob = &bench.Job{Target: bench.DefaultGRPCTargetConfig()}
+
+var subcommands = map[string]subcommand{
+	"simulated": {
+		summary: "benchmark the harness against a simulated engine with fixed stage delays",
+		setup: func(fs *flag.FlagSet, config *bench.Config) func(context.Context) (bench.Target, func(), error) {
+			config.Nodes = 50
+			config.Requests = 50000
+			config.Concurrency = 100
+			config.Warmup = 5000
+			return func(context.Context) (bench.Target, func(), error) {
+				return bench.NewSimulatedEngine(), func() {}, nil
+			}
+		},
+	},
+	"integrated": {
+		summary: "benchmark the routing table, associative search and optimizer over a random topology",
+		setup: func(fs *flag.FlagSet, config *bench.Config) func(context.Context) (bench.Target, func(), error) {
+			connections := fs.Int("connections", 500, "random edges added to the ring topology")
+			level := fs.Int("optimization", int(routing.BalancedOptimization), "optimization level: 0 fast, 1 balanced, 2 deep")
+			topologyName := fs.String("topology", "", "topology profile to route over instead of a random ring: "+strings.Join(bench.TopologyNames(), ", "))
+			return func(context.Context) (bench.Target, func(), error) {
+				if *topologyName != "" {
+					topology, err := bench.LookupTopology(*topologyName)
+					if err != nil {
+						return nil, nil, err
+					}
+					// Lookups must stay within the profile's nodes
+					config.Nodes = topology.Nodes
+					config.Topology = topology.Name
+					target, err := bench.NewTopologyTarget(topology, routing.OptimizationLevel(*level))
+					if err != nil {
+						return nil, nil, err
+					}
+					return target, target.Close, nil
+				}
+				target, err := bench.NewIntegratedTarget(config.Nodes, *connections, routing.OptimizationLevel(*level))
+				if err != nil {
+					return nil, nil, err
+				}
+				return target, target.Close, nil
+			}
+		},
+	},
+	"baseline": {
+		summary: "measure the HTTP round trip to pass as -baseline to the other benchmarks",
+		setup: func(fs *flag.FlagSet, config *bench.Config) func(context.Context) (bench.Target, func(), error) {
+			baseline := bench.DefaultHTTPBaselineConfig()
+			fs.StringVar(&baseline.Protocol, "protocol", baseline.Protocol, "protocol measured: http1 or http2")
+			fs.StringVar(&baseline.Address, "address", "", "baseline server started with alm-bench baseline-server, to measure across the LAN; defaults to one on loopback")
+			return func(context.Context) (bench.Target, func(), error) {
+				// The baseline is what the others are measured against
+				config.BaselineLatency = 0
+				target, err := bench.NewHTTPBaseline(baseline)
+				if err != nil {
+					return nil, nil, err
+				}
+				return target, func() { target.Close() }, nil
+			}
+		},
+	},
+	"grpc": {
+		summary: "benchmark a running deployment through its gRPC API, optionally from -agents",
+		setup: func(fs *flag.FlagSet, config *bench.Config) func(context.Context) (bench.Target, func(), error) {
+			fs.StringVar(&grpcJob.Address, "address", "", "address of the deployment's gRPC API; lookups go between node IDs 1 to -nodes")
+			fs.StringVar(&grpcJob.Target.ServiceType, "service-type", grpcJob.Target.ServiceType, "service type of the route requests")
+			fs.IntVar(&grpcJob.Target.MaxHops, "max-hops", grpcJob.Target.MaxHops, "hop limit of the route requests")
+			return func(context.Context) (bench.Target, func(), error) {
+				target, err := bench.NewGRPCTarget(grpcJob.Address, grpcJob.Target)
+				if err != nil {
+					return nil, nil, err
+				}
+				return target, func() { target.Close() }, nil
+			}
+		},
+		remote: grpcJob,
+	},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage()
+		return exitUsage
+	}
+
+	name := args[0]
+	switch name {
+	case "agent":
+		return runAgent(args[1:])
+	case "baseline-server":
+		return runBaselineServer(args[1:])
+	case "history":
+		return runHistory(args[1:])
+	}
+	command, exists := subcommands[name]
+	if !exists {
+		fmt.Fprintf(os.Stderr, "alm-bench: unknown subcommand %q\n\n", name)
+		usage()
+		return exitUsage
+	}
+
+	config := bench.DefaultConfig()
+	config.Name = name
+
+	fs := flag.NewFlagSet("alm-bench "+name, flag.ContinueOnError)
+	setup := command.setup(fs, config)
+	fs.IntVar(&config.Requests, "requests", config.Requests, "measured lookups")
+	fs.IntVar(&config.Concurrency, "concurrency", config.Concurrency, "concurrent workers")
+	fs.IntVar(&config.Warmup, "warmup", config.Warmup, "lookups run before measuring")
+	fs.IntVar(&config.Nodes, "nodes", config.Nodes, "nodes lookups are made between")
+	fs.DurationVar(&config.BaselineLatency, "baseline", config.BaselineLatency, "baseline latency the improvement is computed against")
+	fs.Float64Var(&config.TargetImprovement, "target", config.TargetImprovement, "improvement factor over the baseline to achieve")
+	workload := fs.String("workload", "", "YAML workload spec; defaults to uniform closed-loop lookups")
+	replay := fs.String("replay", "", "trace recorded by a coordinator to replay instead of a workload")
+	faults := fs.String("faults", "", "YAML fault scenario to inject while measuring, reporting the impact and recovery of each fault")
+	replaySpeed := fs.Float64("replay-speed", 1, "multiple of the recorded pace to replay at; 0 replays closed-loop")
+	verbose := fs.Bool("verbose", false, "report request counts and latency extremes")
+	format := fs.String("format", "text", "result format: text, json or csv")
+	output := fs.String("output", "", "file to write results to instead of stdout; csv runs are appended")
+	histogramOutput := fs.String("histogram", "", "file to write the latency distribution to as CSV")
+	compare := fs.String("compare", "", "JSON result of a previous run to compare against")
+	maxRegression := percentFlag(0.05)
+	fs.Var(&maxRegression, "max-regression", "largest tolerated regression of any compared metric, such as 5%")
+	soakConfig := bench.DefaultSoakConfig()
+	fs.DurationVar(&soakConfig.Duration, "soak", 0, "repeat rounds for this long, such as 4h, watching memory and goroutines for leaks")
+	fs.DurationVar(&soakConfig.Interval, "soak-interval", soakConfig.Interval, "time between soak samples")
+	fs.DurationVar(&soakConfig.Settle, "soak-settle", soakConfig.Settle, "time excluded from leak detection while caches fill")
+	maxGrowth := percentFlag(soakConfig.MaxGrowth)
+	fs.Var(&maxGrowth, "max-growth", "largest tolerated growth per hour of any soak series, such as 5%")
+	soakSamples := fs.String("soak-samples", "", "file to write soak samples to as CSV")
+	store := fs.String("store", "", "result store file or directory to record the run in, for alm-bench history")
+	storeBackend := fs.String("store-backend", bench.StoreBackendFile, "result store backend: file (JSON lines) or badger (database directory)")
+	commit := fs.String("commit", "", "commit the run is recorded against; defaults to the current git commit")
+	pushConfig := bench.DefaultPushConfig()
+	fs.StringVar(&pushConfig.URL, "pushgateway", "", "Prometheus Pushgateway URL to push results to")
+	fs.StringVar(&pushConfig.Instance, "push-instance", "", "instance grouping label of pushed results")
+	profile := fs.String("profile", "", "comma-separated captures of the measurement window: cpu, heap, trace")
+	profileDir := fs.String("profile-dir", "", "directory captures are written to; defaults to that of -output")
+	live := fs.Bool("live", false, "show a live dashboard of the run on the terminal")
+	agents := fs.String("agents", "", "comma-separated benchmark agents to spread the run across")
+	if err := fs.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	if command.remote != nil && command.remote.Address == "" {
+		fmt.Fprintf(os.Stderr, "alm-bench %s: -address is required\n", name)
+		return exitUsage
+	}
+	if *profile != "" {
+		config.Profile = &bench.ProfileConfig{Dir: *profileDir}
+		if err := bench.ParseProfiles(*profile, config.Profile); err != nil {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: %v\n", name, err)
+			return exitUsage
+		}
+		if config.Profile.Dir == "" {
+			config.Profile.Dir = filepath.Dir(*output)
+		}
+	}
+	var agentList []string
+	if *agents != "" {
+		if command.remote == nil {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: only benchmarks of a remote deployment can use -agents\n", name)
+			return exitUsage
+		}
+		if soakConfig.Duration > 0 {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: -soak and -agents are exclusive\n", name)
+			return exitUsage
+		}
+		if config.Profile != nil {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: -profile and -agents are exclusive\n", name)
+			return exitUsage
+		}
+		if *live {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: -live and -agents are exclusive\n", name)
+			return exitUsage
+		}
+		if *faults != "" {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: -faults and -agents are exclusive\n", name)
+			return exitUsage
+		}
+		for _, agent := range strings.Split(*agents, ",") {
+			if agent = strings.TrimSpace(agent); agent != "" {
+				agentList = append(agentList, agent)
+			}
+		}
+	}
+	switch *format {
+	case "text", "json", "csv":
+	default:
+		fmt.Fprintf(os.Stderr, "alm-bench %s: unknown format %q\n", name, *format)
+		return exitUsage
+	}
+
+	if *workload != "" {
+		var err error
+		config.Workload, err = bench.LoadWorkload(*workload)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: %v\n", name, err)
+			return exitFailed
+		}
+	}
+	if *replay != "" {
+		if *workload != "" {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: -workload and -replay are exclusive\n", name)
+			return exitUsage
+		}
+		var err error
+		config.Replay, err = bench.LoadTrace(*replay, *replaySpeed)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: %v\n", name, err)
+			return exitFailed
+		}
+	}
+
+	if *faults != "" {
+		var err error
+		config.Faults, err = bench.LoadFaults(*faults)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: %v\n", name, err)
+			return exitFailed
+		}
+	}
+
+	var previous *bench.Result
+	if *compare != "" {
+		// Read before running so a missing baseline fails fast
+		var err error
+		previous, err = bench.ReadResult(*compare)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: %v\n", name, err)
+			return exitFailed
+		}
+	}
+
+	if *live {
+		if !isTerminal(os.Stderr) {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: -live needs a terminal\n", name)
+			return exitUsage
+		}
+		dashboard := bench.NewDashboard(os.Stderr, config)
+		config.Progress = dashboard.Update
+		defer dashboard.Close()
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var (
+		result            *bench.Result
+		soakResult        *bench.SoakResult
+		distributedResult *bench.DistributedResult
+		err               error
+	)
+	if len(agentList) > 0 {
+		job := command.remote
+		distributedResult, err = bench.RunDistributed(ctx, config, agentList, job.Address, job.Target)
+		if distributedResult != nil {
+			result = distributedResult.Result
+		}
+	} else {
+		target, closeTarget, setupErr := setup(ctx)
+		if setupErr != nil {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: %v\n", name, setupErr)
+			return exitFailed
+		}
+		defer closeTarget()
+
+		if soakConfig.Duration > 0 {
+			soakConfig.MaxGrowth = float64(maxGrowth)
+			soakResult, err = bench.Soak(ctx, config, soakConfig, target)
+			if soakResult != nil {
+				result = soakResult.Result
+			}
+		} else {
+			result, err = bench.Run(ctx, config, target)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "alm-bench %s: %v\n", name, err)
+		return exitFailed
+	}
+
+	if err := writeResult(*output, *format, result, *verbose); err != nil {
+		fmt.Fprintf(os.Stderr, "alm-bench %s: %v\n", name, err)
+		return exitFailed
+	}
+
+	if *histogramOutput != "" {
+		if err := writeHistogram(*histogramOutput, result); err != nil {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: %v\n", name, err)
+			return exitFailed
+		}
+	}
+
+	if *store != "" {
+		if err := storeResult(*storeBackend, *store, *commit, result); err != nil {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: %v\n", name, err)
+			return exitFailed
+		}
+	}
+
+	if pushConfig.URL != "" {
+		if err := bench.Push(ctx, pushConfig, result); err != nil {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: %v\n", name, err)
+			return exitFailed
+		}
+	}
+
+	// Summaries beside the result go to stdout only when the result is a
+	// text report there
+	summaries := os.Stderr
+	if *format == "text" && *output == "" {
+		summaries = os.Stdout
+	}
+
+	if distributedResult != nil {
+		if err := bench.ReportAgents(summaries, distributedResult); err != nil {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: %v\n", name, err)
+			return exitFailed
+		}
+	}
+
+	if soakResult != nil {
+		if err := bench.ReportSoak(summaries, soakResult); err != nil {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: %v\n", name, err)
+			return exitFailed
+		}
+		if *soakSamples != "" {
+			if err := writeSamples(*soakSamples, soakResult); err != nil {
+				fmt.Fprintf(os.Stderr, "alm-bench %s: %v\n", name, err)
+				return exitFailed
+			}
+		}
+	}
+
+	if previous != nil {
+		comparison, err := bench.Compare(previous, result, float64(maxRegression))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: %v\n", name, err)
+			return exitFailed
+		}
+		if err := bench.ReportComparison(summaries, comparison); err != nil {
+			fmt.Fprintf(os.Stderr, "alm-bench %s: %v\n", name, err)
+			return exitFailed
+		}
+		if comparison.Regressed() {
+			return exitRegressed
+		}
+	}
+
+	if soakResult != nil && len(soakResult.Leaks()) > 0 {
+		return exitLeaked
+	}
+	if config.BaselineLatency > 0 && !result.TargetAchieved {
+		return exitTargetMissed
+	}
+	return exitTargetAchieved
+}
+
+// runAgent serves benchmark jobs from coordinators until interrupted
+func runAgent(args []string) int {
+	hostname, _ := os.Hostname()
+
+	fs := flag.NewFlagSet("alm-bench agent", flag.ContinueOnError)
+	listen := fs.String("listen", ":7070", "address to accept jobs on")
+	agentName := fs.String("name", hostname, "name the agent reports its results under")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	server := &http.Server{Addr: *listen, Handler: bench.NewAgent(*agentName)}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	fmt.Fprintf(os.Stderr, "alm-bench agent %s: accepting jobs on %s\n", *agentName, *listen)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "alm-bench agent: %v\n", err)
+		return exitFailed
+	}
+	return 0
+}
+
+// runBaselineServer serves baseline lookups to baseline benchmarks on other
+// machines until interrupted
+func runBaselineServer(args []string) int {
+	fs := flag.NewFlagSet("alm-bench baseline-server", flag.ContinueOnError)
+	listen := fs.String("listen", ":7071", "address to serve baseline lookups on")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "alm-bench baseline-server: %v\n", err)
+		return exitFailed
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	server := bench.ServeBaseline(listener)
+	fmt.Fprintf(os.Stderr, "alm-bench baseline-server: serving HTTP/1.1 and HTTP/2 on %s\n", listener.Addr())
+	<-ctx.Done()
+	server.Close()
+	return 0
+}
+
+// runHistory prints the history of a metric from a result store
+func runHistory(args []string) int {
+	fs := flag.NewFlagSet("alm-bench history", flag.ContinueOnError)
+	path := fs.String("store", "", "result store file or directory written by -store")
+	backend := fs.String("store-backend", bench.StoreBackendFile, "result store backend: file or badger")
+	var query bench.Query
+	fs.StringVar(&query.Name, "name", "", "only runs of this subcommand")
+	fs.StringVar(&query.Workload, "workload", "", "only runs of this workload")
+	fs.StringVar(&query.Topology, "topology", "", "only runs over this topology profile")
+	since := fs.Duration("since", 0, "only runs started within this long, such as 720h")
+	fs.IntVar(&query.Limit, "limit", 0, "only the most recent runs")
+	metric := fs.String("metric", "p99_latency_seconds", "metric to show, named as in CSV output")
+	format := fs.String("format", "text", "history format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *path == "" {
+		fmt.Fprintf(os.Stderr, "alm-bench history: -store is required\n")
+		return exitUsage
+	}
+	if *since > 0 {
+		query.Since = time.Now().Add(-*since)
+	}
+
+	store, err := bench.OpenStore(*backend, *path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "alm-bench history: %v\n", err)
+		return exitFailed
+	}
+	defer store.Close()
+
+	records, err := store.Query(query)
+	if err == nil {
+		var points []*bench.HistoryPoint
+		if points, err = bench.History(records, *metric); err == nil {
+			if *format == "json" {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				err = encoder.Encode(points)
+			} else {
+				err = bench.ReportHistory(os.Stdout, *metric, points)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "alm-bench history: %v\n", err)
+		return exitFailed
+	}
+	return 0
+}
+
+// storeResult records result against commit in the backend store at path.
+// An empty commit is the current git commit, if the run is in a repository.
+func storeResult(backend, path, commit string, result *bench.Result) error {
+	if commit == "" {
+		if head, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output(); err == nil {
+			commit = strings.TrimSpace(string(head))
+		}
+	}
+
+	store, err := bench.OpenStore(backend, path)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return store.Add(&bench.Record{Commit: commit, Result: result})
+}
+
+// isTerminal reports whether file is a terminal
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// writeResult writes result in format to the file at path, or stdout if
+// path is empty. CSV results are appended, with a header only when the file
+// is new, so repeated runs build up a history.
+func writeResult(path, format string, result *bench.Result, verbose bool) error {
+	w := os.Stdout
+	header := true
+	if path != "" {
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if format == "csv" {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		file, err := os.OpenFile(path, flags, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open output file: %w", err)
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to open output file: %w", err)
+		}
+		w = file
+		header = info.Size() == 0
+	}
+
+	switch format {
+	case "json":
+		return bench.WriteJSON(w, result)
+	case "csv":
+		return bench.WriteCSV(w, []*bench.Result{result}, header)
+	default:
+		return bench.Report(w, result, verbose)
+	}
+}
+
+// writeHistogram writes the latency distribution of result to the file at path
+func writeHistogram(path string, result *bench.Result) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create histogram file: %w", err)
+	}
+	defer file.Close()
+
+	return bench.WriteHistogram(file, result)
+}
+
+// writeSamples writes the samples of a soak run to the file at path
+func writeSamples(path string, result *bench.SoakResult) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create soak samples file: %w", err)
+	}
+	defer file.Close()
+
+	return bench.WriteSamples(file, result)
+}
+
+// percentFlag is a percentage flag such as 5% or 2.5, held as a fraction
+type percentFlag float64
+
+func (p *percentFlag) String() string {
+	return strconv.FormatFloat(float64(*p)*100, 'g', -1, 64) + "%"
+}
+
+func (p *percentFlag) Set(value string) error {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil || percent < 0 {
+		return fmt.Errorf("invalid percentage %q", value)
+	}
+	*p = percentFlag(percent / 100)
+	return nil
+}
+
+func usage() {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "usage: alm-bench <%s> [flags]\n", strings.Join(names, "|"))
+	fmt.Fprintf(os.Stderr, "       alm-bench agent [-listen address] [-name name]\n")
+	fmt.Fprintf(os.Stderr, "       alm-bench baseline-server [-listen address]\n")
+	fmt.Fprintf(os.Stderr, "       alm-bench history -store file [flags]\n\n")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, subcommands[name].summary)
+	}
+	fmt.Fprintf(os.Stderr, "  %-16s %s\n", "agent", "run benchmark jobs sent by a coordinator using -agents")
+	fmt.Fprintf(os.Stderr, "  %-16s %s\n", "baseline-server", "serve the baseline benchmark's lookups from another machine")
+	fmt.Fprintf(os.Stderr, "  %-16s %s\n", "history", "show a metric per commit from runs recorded with -store")
+	fmt.Fprintf(os.Stderr, "\nRun alm-bench <subcommand> -h for its flags.\n")
+}
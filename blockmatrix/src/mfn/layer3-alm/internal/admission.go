@@ -0,0 +1,362 @@
+// Package internal implements priority-aware admission control for route lookups
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+// ErrOverloaded matches every AdmissionError with errors.Is
+var ErrOverloaded = errors.New("coordinator overloaded")
+
+// Reasons a request is shed
+const (
+	ShedQueueFull = "queue_full"
+	ShedEvicted   = "evicted"
+	ShedTimeout   = "timeout"
+)
+
+// admissionClasses is the number of QoS classes, BestEffort through
+// CriticalMission
+const admissionClasses = int(routing.CriticalMission) + 1
+
+// AdmissionConfig configures an AdmissionController
+type AdmissionConfig struct {
+	// Requests processed at the same time
+	MaxConcurrent int
+
+	// Requests waiting for a slot, across all classes
+	QueueSize int
+
+	// Longest a request waits before it is shed
+	QueueTimeout time.Duration
+}
+
+// AdmissionError is returned when a request is shed. It is the equivalent
+// of an HTTP 503 response.
+type AdmissionError struct {
+	Class  routing.QoSClass
+	Reason string
+	Waited time.Duration
+}
+
+// Error implements the error interface
+func (ae *AdmissionError) Error() string {
+	return fmt.Sprintf("%s request shed (%s) after %v", ae.Class, ae.Reason, ae.Waited)
+}
+
+// StatusCode returns the HTTP status equivalent of the error
+func (ae *AdmissionError) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+// Is reports whether target is ErrOverloaded
+func (ae *AdmissionError) Is(target error) bool {
+	return target == ErrOverloaded
+}
+
+// AdmissionStats summarizes admission decisions and current queue state
+type AdmissionStats struct {
+	MaxConcurrent int
+	InFlight      int
+	Queued        int
+	Classes       []AdmissionClassStats
+}
+
+// AdmissionClassStats is the admission state of one QoS class
+type AdmissionClassStats struct {
+	Class     routing.QoSClass
+	Queued    int
+	Admitted  int64
+	Cancelled int64
+
+	// Shed requests by reason: ShedQueueFull, ShedEvicted or ShedTimeout
+	Shed map[string]int64
+
+	// Time admitted requests spent queued
+	TotalWait time.Duration
+	MaxWait   time.Duration
+}
+
+// admissionWaiter is a request queued for a slot. Once done is set it has
+// left the queue, holding a slot if err is nil.
+type admissionWaiter struct {
+	class    routing.QoSClass
+	enqueued time.Time
+	ready    chan struct{}
+	done     bool
+	err      error
+}
+
+// admissionCounters accumulates the decisions for one class
+type admissionCounters struct {
+	admitted  int64
+	cancelled int64
+	shed      map[string]int64
+	totalWait time.Duration
+	maxWait   time.Duration
+}
+
+// AdmissionController bounds the requests processed at the same time and
+// queues the rest by QoS class. Freed slots go to the highest class first,
+// in arrival order within a class. When the queue is full an arriving
+// request displaces the most recent waiter of the lowest class below its
+// own, or is shed if there is none, so BestEffort traffic is dropped before
+// anything can delay CriticalMission traffic.
+type AdmissionController struct {
+	config AdmissionConfig
+
+	inFlight int
+	queued   int
+	queues   [admissionClasses][]*admissionWaiter
+	counters [admissionClasses]admissionCounters
+
+	mutex sync.Mutex
+}
+
+// NewAdmissionController creates an admission controller
+func NewAdmissionController(config *AdmissionConfig) *AdmissionController {
+	if config == nil {
+		config = DefaultAdmissionConfig()
+	}
+
+	ac := &AdmissionController{config: *config}
+	for i := range ac.counters {
+		ac.counters[i].shed = make(map[string]int64)
+	}
+	return ac
+}
+
+// Acquire waits for a slot for a request of class. On success the caller
+// must call release once the request is done. It fails with an
+// AdmissionError when the request is shed, or with ctx's error.
+func (ac *AdmissionController) Acquire(ctx context.Context, class routing.QoSClass) (release func(), err error) {
+	class = clampQoSClass(class)
+	now := time.Now()
+
+	ac.mutex.Lock()
+	if ac.inFlight < ac.config.MaxConcurrent && ac.queued == 0 {
+		ac.inFlight++
+		ac.counters[class].admitted++
+		ac.mutex.Unlock()
+		return ac.releaser(), nil
+	}
+
+	if ac.queued >= ac.config.QueueSize && !ac.evictBelowLocked(class, now) {
+		ac.counters[class].shed[ShedQueueFull]++
+		ac.mutex.Unlock()
+		return nil, &AdmissionError{Class: class, Reason: ShedQueueFull}
+	}
+
+	waiter := &admissionWaiter{class: class, enqueued: now, ready: make(chan struct{})}
+	ac.queues[class] = append(ac.queues[class], waiter)
+	ac.queued++
+	timeout := ac.config.QueueTimeout
+	ac.mutex.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var cause error
+	select {
+	case <-waiter.ready:
+	case <-timer.C:
+		cause = &AdmissionError{Class: class, Reason: ShedTimeout}
+	case <-ctx.Done():
+		cause = ctx.Err()
+	}
+
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	if !waiter.done {
+		// Still queued: leave the queue with cause
+		ac.removeLocked(waiter)
+		waiter.done = true
+		if ae, ok := cause.(*AdmissionError); ok {
+			ae.Waited = time.Since(now)
+			ac.counters[class].shed[ShedTimeout]++
+		} else {
+			ac.counters[class].cancelled++
+		}
+		return nil, cause
+	}
+
+	if waiter.err != nil {
+		return nil, waiter.err
+	}
+
+	// Granted a slot, possibly while the context was being cancelled
+	if ctx.Err() != nil {
+		ac.counters[class].cancelled++
+		ac.releaseLocked()
+		return nil, ctx.Err()
+	}
+	return ac.releaser(), nil
+}
+
+// SetConfig changes the limits. Raising MaxConcurrent admits queued
+// requests at once; lowering it takes effect as requests finish.
+func (ac *AdmissionController) SetConfig(config AdmissionConfig) {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	ac.config = config
+	for ac.inFlight < ac.config.MaxConcurrent && ac.grantNextLocked() {
+		ac.inFlight++
+	}
+}
+
+// Config returns the current limits
+func (ac *AdmissionController) Config() AdmissionConfig {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	return ac.config
+}
+
+// Stats returns admission decisions so far and the current queue state
+func (ac *AdmissionController) Stats() AdmissionStats {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	stats := AdmissionStats{
+		MaxConcurrent: ac.config.MaxConcurrent,
+		InFlight:      ac.inFlight,
+		Queued:        ac.queued,
+		Classes:       make([]AdmissionClassStats, 0, admissionClasses),
+	}
+	for i := range ac.counters {
+		counters := &ac.counters[i]
+		shed := make(map[string]int64, len(counters.shed))
+		for reason, count := range counters.shed {
+			shed[reason] = count
+		}
+
+		stats.Classes = append(stats.Classes, AdmissionClassStats{
+			Class:     routing.QoSClass(i),
+			Queued:    len(ac.queues[i]),
+			Admitted:  counters.admitted,
+			Cancelled: counters.cancelled,
+			Shed:      shed,
+			TotalWait: counters.totalWait,
+			MaxWait:   counters.maxWait,
+		})
+	}
+	return stats
+}
+
+// releaser returns the function that gives back a slot, at most once
+func (ac *AdmissionController) releaser() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ac.mutex.Lock()
+			defer ac.mutex.Unlock()
+
+			ac.releaseLocked()
+		})
+	}
+}
+
+// releaseLocked hands a freed slot to the next waiter, or returns it to the pool
+func (ac *AdmissionController) releaseLocked() {
+	if ac.inFlight > ac.config.MaxConcurrent || !ac.grantNextLocked() {
+		ac.inFlight--
+	}
+}
+
+// grantNextLocked gives a slot to the oldest waiter of the highest class,
+// returning false if nothing is queued
+func (ac *AdmissionController) grantNextLocked() bool {
+	for class := admissionClasses - 1; class >= 0; class-- {
+		queue := ac.queues[class]
+		if len(queue) == 0 {
+			continue
+		}
+
+		waiter := queue[0]
+		queue[0] = nil
+		ac.queues[class] = queue[1:]
+		ac.queued--
+
+		waited := time.Since(waiter.enqueued)
+		counters := &ac.counters[class]
+		counters.admitted++
+		counters.totalWait += waited
+		if waited > counters.maxWait {
+			counters.maxWait = waited
+		}
+
+		waiter.done = true
+		close(waiter.ready)
+		return true
+	}
+	return false
+}
+
+// evictBelowLocked sheds the most recent waiter of the lowest class below
+// class, returning false if there is none
+func (ac *AdmissionController) evictBelowLocked(class routing.QoSClass, now time.Time) bool {
+	for lower := 0; lower < int(class); lower++ {
+		queue := ac.queues[lower]
+		if len(queue) == 0 {
+			continue
+		}
+
+		victim := queue[len(queue)-1]
+		queue[len(queue)-1] = nil
+		ac.queues[lower] = queue[:len(queue)-1]
+		ac.queued--
+		ac.counters[lower].shed[ShedEvicted]++
+
+		victim.done = true
+		victim.err = &AdmissionError{Class: victim.class, Reason: ShedEvicted, Waited: now.Sub(victim.enqueued)}
+		close(victim.ready)
+		return true
+	}
+	return false
+}
+
+// removeLocked takes a waiter that gave up out of its queue
+func (ac *AdmissionController) removeLocked(waiter *admissionWaiter) {
+	queue := ac.queues[waiter.class]
+	for i, queuedWaiter := range queue {
+		if queuedWaiter == waiter {
+			ac.queues[waiter.class] = append(queue[:i], queue[i+1:]...)
+			ac.queued--
+			return
+		}
+	}
+}
+
+// clampQoSClass maps unknown classes to the nearest known one
+func clampQoSClass(class routing.QoSClass) routing.QoSClass {
+	if class < routing.BestEffort {
+		return routing.BestEffort
+	}
+	if class > routing.CriticalMission {
+		return routing.CriticalMission
+	}
+	return class
+}
+
+// RouteAdmission returns the admission controller for FindOptimalRoute
+func (alm *ALMCoordinator) RouteAdmission() *AdmissionController {
+	return alm.admission
+}
+
+// DefaultAdmissionConfig returns default admission configuration
+func DefaultAdmissionConfig() *AdmissionConfig {
+	return &AdmissionConfig{
+		MaxConcurrent: 256,
+		QueueSize:     4096,
+		QueueTimeout:  250 * time.Millisecond,
+	}
+}
@@ -0,0 +1,233 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+// admissionResult is the outcome of a queued Acquire
+type admissionResult struct {
+	release func()
+	err     error
+}
+
+// enqueue starts an Acquire for class in the background and waits until
+// it is queued
+func enqueue(t *testing.T, ctx context.Context, ac *AdmissionController, class routing.QoSClass) <-chan admissionResult {
+	t.Helper()
+
+	queued := ac.Stats().Queued
+	result := make(chan admissionResult, 1)
+	go func() {
+		release, err := ac.Acquire(ctx, class)
+		result <- admissionResult{release, err}
+	}()
+	waitFor(t, "a request to queue", func() bool { return ac.Stats().Queued > queued })
+	return result
+}
+
+func shedReason(err error) string {
+	var admissionErr *AdmissionError
+	if errors.As(err, &admissionErr) {
+		return admissionErr.Reason
+	}
+	return ""
+}
+
+func TestAdmissionGrantsHighestClassFirst(t *testing.T) {
+	ac := NewAdmissionController(&AdmissionConfig{MaxConcurrent: 1, QueueSize: 8, QueueTimeout: time.Minute})
+
+	release, err := ac.Acquire(context.Background(), routing.BestEffort)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	arrivals := []routing.QoSClass{routing.BestEffort, routing.LowLatency, routing.CriticalMission, routing.BestEffort, routing.CriticalMission}
+	var results []<-chan admissionResult
+	for _, class := range arrivals {
+		results = append(results, enqueue(t, context.Background(), ac, class))
+	}
+
+	// Each release hands the slot to one waiter; record who got it
+	var granted []int
+	for range arrivals {
+		release()
+		for i, result := range results {
+			select {
+			case r := <-result:
+				if r.err != nil {
+					t.Fatalf("waiter %d: %v", i, r.err)
+				}
+				granted = append(granted, i)
+				release = r.release
+			case <-time.After(10 * time.Millisecond):
+				continue
+			}
+			break
+		}
+	}
+	release()
+
+	want := []int{2, 4, 1, 0, 3}
+	if len(granted) != len(want) {
+		t.Fatalf("granted %v, want %v", granted, want)
+	}
+	for i := range want {
+		if granted[i] != want[i] {
+			t.Fatalf("granted %v, want %v", granted, want)
+		}
+	}
+	if stats := ac.Stats(); stats.InFlight != 0 || stats.Queued != 0 {
+		t.Errorf("%d in flight and %d queued after all released", stats.InFlight, stats.Queued)
+	}
+}
+
+// fillQueue takes the only slot of ac and queues a request of each class
+// behind it, until the test ends
+func fillQueue(t *testing.T, ac *AdmissionController, classes ...routing.QoSClass) []<-chan admissionResult {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	release, err := ac.Acquire(ctx, routing.CriticalMission)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		release()
+	})
+
+	var results []<-chan admissionResult
+	for _, class := range classes {
+		results = append(results, enqueue(t, ctx, ac, class))
+	}
+	return results
+}
+
+func TestAdmissionShedsWhenQueueFull(t *testing.T) {
+	ac := NewAdmissionController(&AdmissionConfig{MaxConcurrent: 1, QueueSize: 2, QueueTimeout: time.Minute})
+	fillQueue(t, ac, routing.BestEffort, routing.LowLatency)
+
+	// A request can only displace a waiter below its own class
+	_, err := ac.Acquire(context.Background(), routing.BestEffort)
+	if reason := shedReason(err); reason != ShedQueueFull {
+		t.Fatalf("best effort request into a full queue returned %v, want shed as %s", err, ShedQueueFull)
+	}
+	if shed := ac.Stats().Classes[routing.BestEffort].Shed[ShedQueueFull]; shed != 1 {
+		t.Errorf("%d best effort requests shed, want 1", shed)
+	}
+	if queued := ac.Stats().Queued; queued != 2 {
+		t.Errorf("%d queued, want the queue left full at 2", queued)
+	}
+}
+
+func TestAdmissionEvictsLowestClass(t *testing.T) {
+	ac := NewAdmissionController(&AdmissionConfig{MaxConcurrent: 1, QueueSize: 3, QueueTimeout: time.Minute})
+	results := fillQueue(t, ac, routing.HighThroughput, routing.BestEffort, routing.BestEffort)
+
+	// The most recent waiter of the lowest class makes room for a higher one
+	go ac.Acquire(context.Background(), routing.CriticalMission)
+	select {
+	case r := <-results[2]:
+		if reason := shedReason(r.err); reason != ShedEvicted {
+			t.Errorf("last best effort waiter finished with %v, want evicted", r.err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no waiter evicted for a critical request")
+	}
+
+	for _, result := range results[:2] {
+		select {
+		case r := <-result:
+			t.Errorf("waiter finished early with %v", r.err)
+		default:
+		}
+	}
+	if queued := ac.Stats().Queued; queued != 3 {
+		t.Errorf("%d queued, want the critical request in the evicted place", queued)
+	}
+}
+
+func TestAdmissionQueueTimeout(t *testing.T) {
+	ac := NewAdmissionController(&AdmissionConfig{MaxConcurrent: 1, QueueSize: 1, QueueTimeout: 20 * time.Millisecond})
+
+	release, _ := ac.Acquire(context.Background(), routing.BestEffort)
+	defer release()
+
+	_, err := ac.Acquire(context.Background(), routing.LowLatency)
+	var admissionErr *AdmissionError
+	if !errors.As(err, &admissionErr) || admissionErr.Reason != ShedTimeout {
+		t.Fatalf("Acquire returned %v, want a timeout shed", err)
+	}
+	if admissionErr.Waited < 20*time.Millisecond || admissionErr.Class != routing.LowLatency {
+		t.Errorf("shed after %v as %s, want at least 20ms as low_latency", admissionErr.Waited, admissionErr.Class)
+	}
+	if !errors.Is(err, ErrOverloaded) || admissionErr.StatusCode() != http.StatusServiceUnavailable {
+		t.Error("admission error does not match ErrOverloaded with status 503")
+	}
+	if shed := ac.Stats().Classes[routing.LowLatency].Shed[ShedTimeout]; shed != 1 {
+		t.Errorf("%d timeouts counted, want 1", shed)
+	}
+}
+
+func TestAdmissionCancelledWhileQueued(t *testing.T) {
+	ac := NewAdmissionController(&AdmissionConfig{MaxConcurrent: 1, QueueSize: 1, QueueTimeout: time.Minute})
+
+	release, _ := ac.Acquire(context.Background(), routing.BestEffort)
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := enqueue(t, ctx, ac, routing.BestEffort)
+	cancel()
+
+	if r := <-result; !errors.Is(r.err, context.Canceled) {
+		t.Errorf("cancelled Acquire returned %v", r.err)
+	}
+	stats := ac.Stats()
+	if stats.Queued != 0 || stats.Classes[routing.BestEffort].Cancelled != 1 {
+		t.Errorf("%d queued and %d cancelled, want 0 and 1", stats.Queued, stats.Classes[routing.BestEffort].Cancelled)
+	}
+}
+
+func TestAdmissionSetConfigAdmitsQueued(t *testing.T) {
+	ac := NewAdmissionController(&AdmissionConfig{MaxConcurrent: 1, QueueSize: 4, QueueTimeout: time.Minute})
+
+	release, _ := ac.Acquire(context.Background(), routing.BestEffort)
+	first := enqueue(t, context.Background(), ac, routing.BestEffort)
+	second := enqueue(t, context.Background(), ac, routing.BestEffort)
+
+	ac.SetConfig(AdmissionConfig{MaxConcurrent: 3, QueueSize: 4, QueueTimeout: time.Minute})
+
+	for _, result := range []<-chan admissionResult{first, second} {
+		r := <-result
+		if r.err != nil {
+			t.Fatalf("queued request not admitted: %v", r.err)
+		}
+		defer r.release()
+	}
+	if stats := ac.Stats(); stats.InFlight != 3 || stats.Queued != 0 {
+		t.Errorf("%d in flight and %d queued, want 3 and 0", stats.InFlight, stats.Queued)
+	}
+	release()
+
+	if stats := ac.Stats(); stats.InFlight != 2 || stats.MaxConcurrent != 3 {
+		t.Errorf("%d in flight with limit %d, want 2 and 3", stats.InFlight, stats.MaxConcurrent)
+	}
+}
+
+func TestClampQoSClass(t *testing.T) {
+	if got := clampQoSClass(routing.QoSClass(-1)); got != routing.BestEffort {
+		t.Errorf("clampQoSClass(-1) = %s, want best effort", got)
+	}
+	if got := clampQoSClass(routing.QoSClass(99)); got != routing.CriticalMission {
+		t.Errorf("clampQoSClass(99) = %s, want critical mission", got)
+	}
+	if got := clampQoSClass(routing.HighThroughput); got != routing.HighThroughput {
+		t.Errorf("clampQoSClass(high throughput) = %s", got)
+	}
+}
@@ -3,15 +3,18 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/associative"
 	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/logging"
 	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/optimization"
 	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/scrub"
 	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/service"
 	"go.uber.org/zap"
 )
@@ -20,15 +23,52 @@ import (
 type ALMCoordinator struct {
 	// Core components
 	networkGraph      *graph.NetworkGraph
-	associativeEngine *associative.AssociativeSearchEngine
+	associativeEngine *associative.SimpleAssociativeSearchEngine
 	optimizer         *optimization.MultiObjectiveOptimizer
 	routingTable      *routing.RoutingTable
 	serviceRegistry   *service.EnhancedServiceRegistry
 	
+	// Priority admission for route lookups
+	admission         *AdmissionController
+	
+	// Artificial faults for degradation and failover drills
+	faults            *FaultInjector
+	
+	// Per-tenant and percentage gates for experimental behavior
+	featureFlags      *FeatureFlags
+	
+	// Keys routing policies and topology imports must be signed with
+	trustAnchors      TrustAnchors
+	
+	// Redacts configured metadata keys and address patterns from exports,
+	// logs and traces; nil when nothing is scrubbed
+	scrubber          atomic.Pointer[scrub.Scrubber]
+	
+	// Time-of-day objective weights and operator overrides of them
+	schedule          *RoutingSchedule
+	
+	// Nodes draining for maintenance
+	nodeDrains        nodeDrains
+	
+	// Regional hierarchy for destinations outside this coordinator's graph
+	regions           *RegionHierarchy
+	
 	// Performance monitoring
 	performanceMonitor *PerformanceMonitor
 	metricsCollector   *MetricsCollector
 	
+	// Cache capacities bounded by the process memory budget
+	memoryBudget *MemoryBudget
+	
+	// Switches lookups to brownout mode under global overload
+	brownout     *BrownoutDetector
+	
+	// History of the dashboard time series
+	dashboard    *DashboardRecorder
+	
+	// Per-tenant and per-service quotas on lookups and discovery
+	quotas       *Quotas
+	
 	// Configuration
 	config *ALMConfig
 	
@@ -36,6 +76,50 @@ type ALMCoordinator struct {
 	isRunning    bool
 	startTime    time.Time
 	
+	// Background components; set by Start and never reset, so a stopped
+	// coordinator cannot be restarted
+	lifecycle    *LifecycleManager
+	
+	// Topology observers
+	topologyListeners []func(updates []TopologyUpdate)
+	
+	// Closed and replaced whenever the configuration changes, so background
+	// loops can pick up new intervals
+	configChanged chan struct{}
+	
+	// Layer 2 link-state feed, started with the coordinator
+	layer2 *Layer2Bridge
+	
+	// STOQ transport quality feed and path hint sink, started with the
+	// coordinator; loaded on every route lookup
+	stoq atomic.Pointer[STOQBridge]
+	
+	// Peer measurements applied every TopologyRefresh
+	topologyRefresher *TopologyRefresher
+	
+	// Probes the next hops of cached routes once a node probe is attached
+	routeHealth *routing.HealthChecker
+	
+	// Keys persisted state is encrypted with, set by SetStateKeys
+	stateKeys KeyProvider
+	
+	// Snapshot and write-ahead log, set by Start when persistence is enabled
+	stateStore *StateStore
+	
+	// Leader election among the coordinators of a region; nil when this
+	// coordinator runs alone
+	elector *LeaderElector
+	
+	// Hot-standby replication: the replicator on a primary, the receiver
+	// on its standby
+	replicator *StandbyReplicator
+	standby    *StandbyReceiver
+	
+	// Sampled request recording for benchmark replay; nil until the first
+	// recording starts
+	recorder       atomic.Pointer[RequestRecorder]
+	recordingMutex sync.Mutex
+	
 	// Thread safety
 	mutex        sync.RWMutex
 	
@@ -56,12 +140,99 @@ type ALMConfig struct {
 	BeamWidth         int
 	
 	// Optimization settings
-	OptimizationLevel optimization.OptimizationLevel
+	OptimizationLevel routing.OptimizationLevel
 	MaxOptimizeTime   time.Duration
 	
-	// Service discovery
+	// Route and optimal path caching
+	RouteCacheSize    int
+	RouteCacheTTL     time.Duration
+	PathCacheSize     int
+	
+	// RouteLoadRefresh is how often routes served from the cache take up
+	// their path's current load; routes whose path saturates are dropped
+	RouteLoadRefresh  time.Duration
+	
+	// Once a node probe is attached, the next hops of cached routes are
+	// probed every RouteProbeInterval; one failing RouteProbeFailures
+	// probes in a row is marked unhealthy and the routes through it dropped
+	RouteProbeInterval time.Duration
+	RouteProbeFailures int
+	
+	// Route lookup latency quantiles cover LatencyWindow, retiring the
+	// oldest lookups in LatencyWindowSlots steps
+	LatencyWindow      time.Duration
+	LatencyWindowSlots int
+	
+	// Latency SLOs per QoS class, such as low latency lookups within 1ms
+	// at P99 over 30 days (quantile 0.99, threshold_ns 1000000). Their
+	// error budgets are reported and burn-rate alerts raised through
+	// OnSLOAlert.
+	LatencySLOs        []routing.LatencySLO
+	
+	// HubPathTrees is how many of the sources with the most path cache
+	// misses keep precomputed shortest-path trees; zero disables them
+	HubPathTrees      int
+	
+	// Landmarks is how many nodes keep their latency to and from every
+	// node, bounding path latency without a search so lookups no path can
+	// meet fail fast; zero disables them
+	Landmarks         int
+	
+	// Measured edge latency and jitter, and node latency, are smoothed by
+	// EdgeSmoothing: 0 applies them as is, 1 takes an EWMA weighting
+	// accepted samples by EdgeSmoothingAlpha, 2 the median of the last
+	// EdgeSmoothingWindow.
+	// Samples over EdgeOutlierThreshold median absolute deviations from
+	// that window's median are rejected; zero accepts every sample.
+	EdgeSmoothing        graph.SmoothingMethod
+	EdgeSmoothingWindow  int
+	EdgeSmoothingAlpha   float64
+	EdgeOutlierThreshold float64
+	
+	// Route admission: at most MaxConcurrentRoutes lookups run at once and
+	// up to RouteQueueSize more wait, highest QoS class first, for at most
+	// RouteQueueTimeout. Under overload the lowest classes are shed first.
+	MaxConcurrentRoutes int
+	RouteQueueSize      int
+	RouteQueueTimeout   time.Duration
+	
+	// Brownout: while more than BrownoutQueueDepth route requests are
+	// queued, system CPU is above BrownoutCPUPercent or lookup P99 above
+	// BrownoutP99Latency, lookups use FastLookup only, optimizer runs and
+	// affinity learning are suspended and cached routes are served for
+	// BrownoutCacheTTL. It ends once all three have stayed below 80% of
+	// their thresholds for BrownoutHold. A zero threshold disables its
+	// signal.
+	BrownoutQueueDepth  int
+	BrownoutCPUPercent  float64
+	BrownoutP99Latency  time.Duration
+	BrownoutCacheTTL    time.Duration
+	BrownoutHold        time.Duration
+	
+	// Service discovery. Instances whose health score falls below
+	// DegradedThreshold are degraded, and below UnhealthyThreshold unhealthy.
 	ServiceCacheSize  int
 	ServiceCacheTTL   time.Duration
+	DegradedThreshold  float64
+	UnhealthyThreshold float64
+	
+	// MemoryBudget is the heap, in bytes, the route, path and discovery
+	// caches and learned service affinities are sized to fit; their
+	// capacities shrink as the heap approaches it. Size it from the
+	// MemoryPerService target. Zero disables the budget.
+	MemoryBudget      int64
+	
+	// Route optimization objective weights
+	LatencyWeight     float64
+	ThroughputWeight  float64
+	ReliabilityWeight float64
+	CostWeight        float64
+	
+	// Service ranking weights
+	ProximityWeight   float64
+	HealthWeight      float64
+	AffinityWeight    float64
+	PerformanceWeight float64
 	
 	// Performance targets
 	TargetLatencyMs   float64  // Target <0.16ms for 777% improvement
@@ -71,7 +242,81 @@ type ALMConfig struct {
 	MetricsInterval   time.Duration
 	HealthCheckInterval time.Duration
 	
-	// Integration
+	// DashboardRetention is how much history of the dashboard time series,
+	// sampled every MetricsInterval, is kept for the admin API
+	DashboardRetention time.Duration
+	
+	// Shutdown: time each background component may take to drain, and
+	// the bound on Stop as a whole
+	DrainTimeout      time.Duration
+	ShutdownTimeout   time.Duration
+	
+	// Persistence: topology updates are logged before they are applied and
+	// state is snapshotted every SnapshotInterval under StateDir, which
+	// defaults to ~/.blockmatrix/<NodeID>/alm. NodeID defaults to the
+	// hostname.
+	Persistence       bool
+	NodeID            string
+	StateDir          string
+	SnapshotInterval  time.Duration
+	
+	// Persisted state is encrypted with AES-256-GCM when StateKeyFile
+	// names a file of base64 keys, or StateKeyEnv an environment variable
+	// of comma separated ones, newest first; older keys still decrypt
+	// state written before a rotation. SetStateKeys supplies keys from a
+	// KMS instead. With keys, state written in the clear is refused
+	// unless StateMigratePlaintext is set to encrypt an existing store.
+	StateKeyFile      string
+	StateKeyEnv       string
+	StateMigratePlaintext bool
+	
+	// Maintenance: every MaintenanceInterval the leader prunes learned
+	// service affinities whose decayed weight is below
+	// AffinityPruneThreshold
+	MaintenanceInterval    time.Duration
+	AffinityPruneThreshold float64
+	
+	// Fault injection: when set, faults can be injected through Faults()
+	// or the admin API. Never enable it in production.
+	FaultInjection    bool
+	
+	// Feature flags keyed by name, gating experimental behavior such as
+	// hedging and predictive circuit breaking per tenant or share of traffic
+	FeatureFlags      map[string]FeatureFlag
+	
+	// Policy signing: ed25519 public keys, base64 and keyed by key ID. When
+	// any is set, config and feature flag changes and topology imports
+	// through the admin and gRPC APIs must be signed by one of them, so
+	// reaching the admin port is not enough to redirect traffic. Config
+	// files and reloads, which are local, are trusted.
+	TrustAnchors      map[string]string
+	
+	// Route constraint templates keyed by name, such as "payments" with
+	// max_latency_ns 5000000 and min_reliability 0.999, which route requests
+	// reference by name instead of spelling out constraints
+	ConstraintTemplates map[string]routing.RouteConstraints
+	
+	// Disaster recovery plans: the failover regions, in order and with the
+	// flows each takes over, for the destinations of a failed region
+	DRPlans           map[string][]routing.FailoverTarget
+	
+	// Scrubbing: values of ScrubMetadataKeys (such as "customer.*") and
+	// matches of ScrubAddressPatterns (regular expressions, or "ipv4" and
+	// "ipv6") are redacted from state exports, the admin topology view,
+	// debug dumps, and the logs and trace attributes of loggers created by
+	// the logging package and spans started by the tracing package
+	ScrubMetadataKeys    []string
+	ScrubAddressPatterns []string
+	
+	// Routing schedule: objective weights in effect during daily windows,
+	// such as cost-weighted transit overnight and latency-weighted routes
+	// during business hours in a given timezone. The first open window
+	// wins; outside all of them the objective weights above apply.
+	RoutingSchedule   []RoutingPreference
+	
+	// Integration. With STOQIntegration, an adapter attached with
+	// AttachSTOQ feeds transport quality into edge metrics and receives
+	// the routes chosen as path hints.
 	HyperMeshIntegration bool
 	STOQIntegration     bool
 	Layer2Integration   bool
@@ -97,8 +342,9 @@ func NewALMCoordinator(config *ALMConfig, logger *zap.Logger) (*ALMCoordinator,
 	}
 	
 	coordinator := &ALMCoordinator{
-		config: config,
-		logger: logger,
+		config:        config,
+		configChanged: make(chan struct{}),
+		logger:        logger,
 	}
 	
 	// Initialize components
@@ -124,20 +370,121 @@ func (alm *ALMCoordinator) Start(ctx context.Context) error {
 		return fmt.Errorf("ALM coordinator is already running")
 	}
 	
+	if alm.lifecycle != nil {
+		return fmt.Errorf("ALM coordinator has been stopped and cannot be restarted")
+	}
+	
 	alm.logger.Info("Starting ALM Layer 3 Coordinator...")
 	
-	// Start performance monitor
-	go alm.performanceMonitor.Start(ctx)
+	// Restore state before anything can observe or change the topology
+	var stateStore *StateStore
+	if alm.config.Persistence {
+		store, err := alm.openStateStore()
+		if err != nil {
+			return err
+		}
+		stateStore = store
+	}
 	
-	// Start metrics collection
-	go alm.metricsCollector.Start(ctx)
+	lifecycle := NewLifecycleManager(&LifecycleConfig{DrainTimeout: alm.config.DrainTimeout}, alm.logger)
+	
+	// Registered in dependency order. Shutdown runs in reverse, so topology
+	// producers stop first and the graph they feed is closed last.
+	components := []Component{
+		{Name: "network-graph", Stop: func(context.Context) error {
+			alm.networkGraph.Close()
+			return nil
+		}},
+		{Name: "service-registry", Stop: func(context.Context) error {
+			alm.serviceRegistry.Close()
+			return nil
+		}},
+	}
+	
+	// Snapshot periodically, and once more after the components that
+	// change state have stopped
+	if stateStore != nil {
+		components = append(components, Component{
+			Name: "state-store",
+			Run:  alm.runSnapshots(stateStore),
+			Stop: func(context.Context) error {
+				return errors.Join(alm.snapshotState(stateStore), stateStore.Close())
+			},
+		})
+	}
+	
+	components = append(components, []Component{
+		{Name: "performance-monitor", Run: alm.performanceMonitor.Start},
+		{Name: "memory-budget", Run: alm.memoryBudget.Run},
+		{Name: "brownout-detector", Run: alm.brownout.Run},
+		{Name: "metrics-collector", Run: alm.metricsCollector.Start},
+		{Name: "dashboard-recorder", Run: alm.dashboard.Run},
+		{Name: "health-monitoring", Run: alm.startHealthMonitoring},
+		{Name: "topology-refresh", Run: alm.startTopologyRefresh},
+		{Name: "route-load-refresh", Run: alm.routingTable.RunLoadRefresh},
+		{Name: "route-health-checks", Run: alm.routeHealth.Run},
+	}...)
+	
+	// Elect a leader, then run topology-wide maintenance while leading
+	if alm.elector != nil {
+		components = append(components, Component{Name: "leader-election", Run: alm.elector.Run})
+	}
+	components = append(components, Component{Name: "maintenance", Run: alm.runMaintenance})
 	
-	// Start topology refresh
-	go alm.startTopologyRefresh(ctx)
+	// Switch objective weights as scheduled routing preferences open and close
+	components = append(components, Component{Name: "routing-schedule", Run: alm.runRoutingSchedule})
 	
-	// Start health monitoring
-	go alm.startHealthMonitoring(ctx)
+	// Drain nodes in maintenance from routes and remove them at their deadline
+	components = append(components, Component{Name: "node-maintenance", Run: alm.runNodeMaintenance})
 	
+	// Measure latency SLOs and raise or resolve burn-rate alerts
+	components = append(components, Component{Name: "slo-alerts", Run: alm.runSLOAlerts})
+	
+	// Rebuild landmark distances once the topology has changed
+	components = append(components, Component{Name: "landmarks", Run: alm.runLandmarks})
+	
+	// Fire periodic faults; stopped early so flapped links are restored
+	// while the graph is still open
+	components = append(components, Component{Name: "fault-injection", Run: alm.faults.Run})
+	
+	// Stream state to a standby, or follow a primary and take over from it
+	if alm.replicator != nil {
+		components = append(components, Component{Name: "standby-replication", Run: alm.replicator.Run})
+	}
+	if alm.standby != nil {
+		components = append(components, Component{Name: "standby-failover", Run: alm.standby.Run})
+	}
+	
+	// Exchange region summaries with the rest of the hierarchy
+	if alm.regions != nil {
+		components = append(components, Component{Name: "region-hierarchy", Run: alm.regions.Run})
+	}
+	
+	// Consume Layer 2 link state
+	if alm.config.Layer2Integration && alm.layer2 != nil {
+		components = append(components, Component{Name: "layer2-bridge", Run: alm.layer2.Run})
+	}
+	
+	// Consume STOQ transport quality and send it path hints
+	if stoq := alm.stoq.Load(); alm.config.STOQIntegration && stoq != nil {
+		components = append(components, Component{Name: "stoq-bridge", Run: stoq.Run})
+	}
+	
+	for _, component := range components {
+		if err := lifecycle.Register(component); err != nil {
+			return err
+		}
+	}
+	
+	if err := lifecycle.Start(ctx); err != nil {
+		if stateStore != nil {
+			stateStore.Close()
+		}
+		return fmt.Errorf("failed to start ALM components: %w", err)
+	}
+	
+	alm.lifecycle = lifecycle
+	alm.stateStore = stateStore
 	alm.isRunning = true
 	alm.startTime = time.Now()
 	
@@ -146,33 +493,120 @@ func (alm *ALMCoordinator) Start(ctx context.Context) error {
 	return nil
 }
 
-// Stop gracefully stops the ALM coordinator
+// Stop gracefully stops the ALM coordinator, waiting up to ShutdownTimeout
+// for background components to drain
 func (alm *ALMCoordinator) Stop() error {
-	alm.mutex.Lock()
-	defer alm.mutex.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), alm.Config().ShutdownTimeout)
+	defer cancel()
 	
+	return alm.Shutdown(ctx)
+}
+
+// Shutdown stops background components in the reverse of their start order,
+// giving each DrainTimeout to finish before releasing its resources. Once
+// ctx is done remaining components are cancelled without waiting. A stopped
+// coordinator cannot be restarted.
+func (alm *ALMCoordinator) Shutdown(ctx context.Context) error {
+	alm.mutex.Lock()
 	if !alm.isRunning {
+		alm.mutex.Unlock()
 		return nil
 	}
+	alm.isRunning = false
+	lifecycle := alm.lifecycle
+	alm.mutex.Unlock()
 	
 	alm.logger.Info("Stopping ALM Layer 3 Coordinator...")
 	
-	alm.isRunning = false
+	// Drain without holding the lock: the Layer 2 bridge applies its final
+	// batch through UpdateNetworkTopology
+	if err := lifecycle.Shutdown(ctx); err != nil {
+		alm.logger.Warn("ALM Layer 3 Coordinator stopped with errors", zap.Error(err))
+		return fmt.Errorf("ALM coordinator shutdown incomplete: %w", err)
+	}
+	
+	if _, err := alm.StopRecording(); err != nil && !errors.Is(err, ErrNotRecording) {
+		alm.logger.Warn("Failed to stop request recording", zap.Error(err))
+	}
 	
 	alm.logger.Info("ALM Layer 3 Coordinator stopped")
 	
 	return nil
 }
 
+// Components returns the state of the coordinator's background components,
+// or nil before Start
+func (alm *ALMCoordinator) Components() []ComponentStatus {
+	alm.mutex.RLock()
+	lifecycle := alm.lifecycle
+	alm.mutex.RUnlock()
+	
+	if lifecycle == nil {
+		return nil
+	}
+	return lifecycle.Status()
+}
+
 // FindOptimalRoute finds the optimal route using associative search and multi-objective optimization
 func (alm *ALMCoordinator) FindOptimalRoute(ctx context.Context, request RouteRequest) (*RouteResponse, error) {
 	startTime := time.Now()
+	ctx, _ = logging.EnsureCorrelationID(ctx)
+	logger := logging.WithContext(ctx, alm.logger)
 	
 	// Validate request
 	if err := alm.validateRouteRequest(request); err != nil {
 		return nil, fmt.Errorf("invalid route request: %w", err)
 	}
 	
+	if recorder := alm.recorder.Load(); recorder != nil {
+		recorder.Record(request)
+	}
+	
+	// Charge the tenant's and service's quotas before queueing for a slot
+	releaseQuota, err := alm.admitQuota(ctx, request.ServiceType)
+	if err != nil {
+		logger.Debug("Route request over quota",
+			zap.String("service_type", request.ServiceType),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("route request not admitted: %w", err)
+	}
+	defer releaseQuota()
+	
+	// Wait for a slot; critical traffic is admitted ahead of best effort
+	release, err := alm.admission.Acquire(ctx, routing.QoSClass(request.QoSClass))
+	if err != nil {
+		logger.Debug("Route request not admitted",
+			zap.Int("qos_class", request.QoSClass),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("route request not admitted: %w", err)
+	}
+	defer release()
+	
+	if err := alm.faults.Intercept(ctx, FaultRouteLookup); err != nil {
+		return nil, fmt.Errorf("route lookup failed: %w", err)
+	}
+	
+	// Destinations outside this coordinator's graph are routed from region
+	// summaries
+	if alm.regions != nil {
+		if _, local := alm.networkGraph.GetNode(request.DestinationID); !local {
+			response, summarized, err := alm.regions.Route(ctx, request)
+			if err != nil {
+				logger.Debug("Cross-region route lookup failed",
+					zap.Int64("source", request.SourceID),
+					zap.Int64("destination", request.DestinationID),
+					zap.Error(err),
+				)
+				return nil, fmt.Errorf("route lookup failed: %w", err)
+			}
+			if summarized {
+				return response, nil
+			}
+		}
+	}
+	
 	// Create routing request
 	routingReq := routing.RoutingRequest{
 		Source:      request.SourceID,
@@ -186,19 +620,28 @@ func (alm *ALMCoordinator) FindOptimalRoute(ctx context.Context, request RouteRe
 			MaxCost:       request.MaxCost,
 			MaxHops:       request.MaxHops,
 		},
+		ConstraintTemplate: request.ConstraintTemplate,
 		Context: ctx,
+		NoCache: !alm.quotas.CacheAllowed(TenantFromContext(ctx)),
+		Disjoint:      graph.DisjointMode(request.Disjoint),
+		DisjointPaths: request.DisjointPaths,
 	}
 	
 	// Perform intelligent routing lookup
 	routingResp, err := alm.routingTable.LookupRoute(routingReq)
 	if err != nil {
-		alm.logger.Error("Route lookup failed",
+		alm.metricsCollector.RecordRoutingFailure()
+		logger.Error("Route lookup failed",
 			zap.Error(err),
 			zap.Int64("source", request.SourceID),
 			zap.Int64("destination", request.DestinationID),
 		)
 		return nil, fmt.Errorf("route lookup failed: %w", err)
 	}
+	if !routingResp.CacheHit && !routingResp.Coalesced && !routingReq.NoCache && routingReq.Disjoint == graph.NotDisjoint {
+		alm.quotas.RecordCached(TenantFromContext(ctx), fmt.Sprintf("%d-%d-%s-%d",
+			request.SourceID, request.DestinationID, request.ServiceType, request.QoSClass))
+	}
 	
 	// Convert to ALM response format
 	response := &RouteResponse{
@@ -215,16 +658,28 @@ func (alm *ALMCoordinator) FindOptimalRoute(ctx context.Context, request RouteRe
 		CacheHit:     routingResp.CacheHit,
 		Confidence:   routingResp.Confidence,
 		
-		// Alternatives
-		Alternatives: alm.convertAlternatives(routingResp.Alternatives),
+		// Alternatives and the traffic split between them
+		Alternatives: alm.convertAlternatives(routingResp.Alternatives, routingResp.AlternativeWeights),
+		Weight:       routingResp.Weight,
+		Diversity:    routingResp.Diversity,
+	}
+	if failover := routingResp.Failover; failover != nil {
+		response.FailedRegion = failover.FailedRegion
+		response.FailoverRegion = failover.Region
+		response.FailoverDestination = routingResp.Route.Destination
 	}
 	
 	// Record performance metrics
-	alm.metricsCollector.RecordRouting(response)
+	alm.metricsCollector.RecordRouting(response, routingReq.QoSClass)
+	
+	// Let the transport follow the chosen path
+	if stoq := alm.stoq.Load(); stoq != nil {
+		stoq.Hint(request, response)
+	}
 	
 	// Check if we achieved the 777% improvement target
 	if response.SearchTime <= time.Duration(alm.config.TargetLatencyMs*float64(time.Millisecond)) {
-		alm.logger.Debug("Achieved 777% improvement target",
+		logger.Debug("Achieved 777% improvement target",
 			zap.Duration("search_time", response.SearchTime),
 			zap.Float64("target_ms", alm.config.TargetLatencyMs),
 		)
@@ -236,6 +691,23 @@ func (alm *ALMCoordinator) FindOptimalRoute(ctx context.Context, request RouteRe
 // DiscoverServices performs intelligent service discovery
 func (alm *ALMCoordinator) DiscoverServices(ctx context.Context, query ServiceQuery) (*ServiceDiscoveryResponse, error) {
 	startTime := time.Now()
+	ctx, _ = logging.EnsureCorrelationID(ctx)
+	
+	if err := alm.faults.Intercept(ctx, FaultServiceDiscovery); err != nil {
+		return nil, fmt.Errorf("service discovery failed: %w", err)
+	}
+	
+	// Charge the tenant's quota and that of the service sought, by name or
+	// else by type
+	serviceKey := query.ServiceName
+	if serviceKey == "" {
+		serviceKey = query.ServiceType
+	}
+	releaseQuota, err := alm.admitQuota(ctx, serviceKey)
+	if err != nil {
+		return nil, fmt.Errorf("service discovery not admitted: %w", err)
+	}
+	defer releaseQuota()
 	
 	// Convert to internal query format
 	internalQuery := service.ServiceQuery{
@@ -253,6 +725,7 @@ func (alm *ALMCoordinator) DiscoverServices(ctx context.Context, query ServiceQu
 		IncludeDegraded: query.IncludeDegraded,
 		MaxResults:      query.MaxResults,
 		SortBy:         service.SortCriteria(query.SortBy),
+		AffinityKey:    query.AffinityKey,
 		Context:        ctx,
 	}
 	
@@ -307,20 +780,107 @@ func (alm *ALMCoordinator) GetPerformanceMetrics() *PerformanceMetrics {
 		Uptime:                 time.Since(alm.startTime),
 		MemoryUsage:           alm.performanceMonitor.GetMemoryUsage(),
 		CPUUsage:              alm.performanceMonitor.GetCPUUsage(),
+		Goroutines:            alm.performanceMonitor.GetGoroutines(),
+		GCPause:               alm.performanceMonitor.GetGCPause(),
+		Resources:             alm.performanceMonitor.GetResourceUsage(),
 		
 		// Component stats
 		RoutingStats:          alm.routingTable.GetRoutingStats(),
-		AssociativeStats:      alm.associativeEngine.GetSearchStats(),
 		ServiceRegistryStats:  alm.serviceRegistry.GetRegistryStats(),
 	}
 }
 
+// PerformanceMonitor returns the process resource sampler
+func (alm *ALMCoordinator) PerformanceMonitor() *PerformanceMonitor {
+	return alm.performanceMonitor
+}
+
+// NetworkGraph returns the network graph used for routing
+func (alm *ALMCoordinator) NetworkGraph() *graph.NetworkGraph {
+	return alm.networkGraph
+}
+
+// RoutingTable returns the routing table serving route lookups
+func (alm *ALMCoordinator) RoutingTable() *routing.RoutingTable {
+	return alm.routingTable
+}
+
+// AttachNodeProbe probes the next hops of cached routes with probe every
+// RouteProbeInterval once the coordinator starts
+func (alm *ALMCoordinator) AttachNodeProbe(probe routing.NodeProbe) {
+	alm.routeHealth.SetProbe(probe)
+}
+
+// Optimizer returns the multi-objective route optimizer
+func (alm *ALMCoordinator) Optimizer() *optimization.MultiObjectiveOptimizer {
+	return alm.optimizer
+}
+
+// ServiceRegistry returns the enhanced service registry backing service discovery
+func (alm *ALMCoordinator) ServiceRegistry() *service.EnhancedServiceRegistry {
+	return alm.serviceRegistry
+}
+
 // UpdateNetworkTopology updates the network graph with new topology information
 func (alm *ALMCoordinator) UpdateNetworkTopology(updates []TopologyUpdate) error {
+	if err := alm.faults.Intercept(context.Background(), FaultTopologyUpdate); err != nil {
+		return fmt.Errorf("topology update failed: %w", err)
+	}
+	
 	alm.mutex.Lock()
 	defer alm.mutex.Unlock()
 	
+	if alm.standby != nil && !alm.standby.Promoted() {
+		return ErrStandby
+	}
+	
+	// Log before applying so an applied update survives a crash
+	if alm.stateStore != nil {
+		if err := alm.stateStore.Append(updates); err != nil {
+			return fmt.Errorf("failed to log topology updates: %w", err)
+		}
+	}
+	
+	alm.applyTopologyUpdates(updates)
+	
+	// Invalidate only the cached routes the updates can affect
+	invalidated := alm.routingTable.InvalidateTopology(topologyChange(updates))
+	
+	alm.logger.Debug("Network topology updated",
+		zap.Int("updates_processed", len(updates)),
+		zap.Int("routes_invalidated", invalidated),
+	)
+	
+	for _, listener := range alm.topologyListeners {
+		listener(updates)
+	}
+	
+	return nil
+}
+
+// applyTopologyUpdates applies updates to the network graph, logging and
+// skipping any that fail; callers must hold the write lock. Consecutive
+// node metric updates are applied as one batch.
+func (alm *ALMCoordinator) applyTopologyUpdates(updates []TopologyUpdate) {
+	var metrics []graph.NodeMetricsUpdate
+	flushMetrics := func() {
+		if len(metrics) == 0 {
+			return
+		}
+		if err := alm.networkGraph.UpdateNodeMetricsBatch(metrics); err != nil {
+			alm.logger.Error("Failed to update node metrics", zap.Error(err))
+		}
+		metrics = metrics[:0]
+	}
+	defer flushMetrics()
+	
 	for _, update := range updates {
+		if update.Type == MetricsUpdate {
+			metrics = append(metrics, graph.NodeMetricsUpdate{NodeID: update.NodeID, Metrics: update.Metrics})
+			continue
+		}
+		flushMetrics()
+		
 		switch update.Type {
 		case NodeAddUpdate:
 			if err := alm.networkGraph.AddNode(update.Node); err != nil {
@@ -346,28 +906,88 @@ func (alm *ALMCoordinator) UpdateNetworkTopology(updates []TopologyUpdate) error
 				continue
 			}
 			
-		case MetricsUpdate:
-			if err := alm.networkGraph.UpdateNodeMetrics(update.NodeID, update.Metrics); err != nil {
-				alm.logger.Error("Failed to update node metrics", zap.Error(err))
+		case EdgeMetricsUpdate:
+			if err := alm.networkGraph.UpdateEdgeMetrics(update.EdgeFrom, update.EdgeTo, update.EdgeMetrics); err != nil {
+				alm.logger.Error("Failed to update edge metrics", zap.Error(err))
 				continue
 			}
 		}
 	}
+}
+
+// topologyChange maps updates to the routes they can affect. Removals and
+// metric changes affect routes through the node or over the edge; a new edge
+// may shorten routes through its source, and a new node is only reachable by
+// routes to it.
+func topologyChange(updates []TopologyUpdate) routing.TopologyChange {
+	var change routing.TopologyChange
 	
-	// Invalidate affected cached routes
-	alm.routingTable.InvalidateCache()
+	for _, update := range updates {
+		switch update.Type {
+		case NodeAddUpdate:
+			if update.Node != nil {
+				change.Destinations = append(change.Destinations, update.Node.ID)
+			}
+			
+		case NodeRemoveUpdate, MetricsUpdate:
+			change.Nodes = append(change.Nodes, update.NodeID)
+			
+		case EdgeAddUpdate:
+			if update.Edge != nil {
+				change.Nodes = append(change.Nodes, update.Edge.From)
+			}
+			
+		case EdgeRemoveUpdate, EdgeMetricsUpdate:
+			change.Links = append(change.Links, routing.Link{From: update.EdgeFrom, To: update.EdgeTo})
+		}
+	}
 	
-	alm.logger.Debug("Network topology updated",
-		zap.Int("updates_processed", len(updates)),
-	)
+	return change
+}
+
+// AttachLayer2 feeds link-state events from source into the topology once
+// the coordinator starts. It has no effect unless Layer2Integration is set.
+func (alm *ALMCoordinator) AttachLayer2(source Layer2Source, config *Layer2Config) *Layer2Bridge {
+	bridge := NewLayer2Bridge(alm, source, config, alm.logger)
 	
-	return nil
+	alm.mutex.Lock()
+	defer alm.mutex.Unlock()
+	
+	alm.layer2 = bridge
+	return bridge
+}
+
+// AttachSTOQ applies transport quality measured by adapter to the topology
+// and sends it hints of the routes chosen, once the coordinator starts. It
+// has no effect unless STOQIntegration is set.
+func (alm *ALMCoordinator) AttachSTOQ(adapter STOQAdapter, config *STOQConfig) *STOQBridge {
+	bridge := NewSTOQBridge(alm, adapter, config, alm.logger)
+	alm.stoq.Store(bridge)
+	return bridge
+}
+
+// OnTopologyUpdate registers a callback invoked after each applied topology
+// update batch. Callbacks run while the coordinator lock is held and must not
+// call back into the coordinator.
+func (alm *ALMCoordinator) OnTopologyUpdate(listener func(updates []TopologyUpdate)) {
+	alm.mutex.Lock()
+	defer alm.mutex.Unlock()
+	
+	alm.topologyListeners = append(alm.topologyListeners, listener)
 }
 
 // initializeComponents sets up all ALM components
 func (alm *ALMCoordinator) initializeComponents() error {
 	// Initialize network graph
 	alm.networkGraph = graph.NewNetworkGraph(alm.config.MaxNodes)
+	if err := alm.networkGraph.ResizePathCache(alm.config.PathCacheSize); err != nil {
+		return err
+	}
+	alm.networkGraph.SetHubPathTrees(alm.config.HubPathTrees)
+	alm.networkGraph.SetLandmarks(alm.config.Landmarks)
+	if err := alm.networkGraph.SetEdgeSmoothing(alm.config.edgeSmoothing()); err != nil {
+		return err
+	}
 	
 	// Initialize associative search engine
 	searchConfig := associative.DefaultSearchConfig()
@@ -375,40 +995,146 @@ func (alm *ALMCoordinator) initializeComponents() error {
 	searchConfig.BeamSearchWidth = alm.config.BeamWidth
 	alm.associativeEngine = associative.NewAssociativeSearchEngine(alm.networkGraph, searchConfig)
 	
-	// Initialize multi-objective optimizer
+	// Initialize multi-objective optimizer, weighted by the preference
+	// scheduled now
+	schedule, err := NewRoutingSchedule(alm.config.RoutingSchedule)
+	if err != nil {
+		return fmt.Errorf("invalid routing schedule: %w", err)
+	}
+	alm.schedule = schedule
+	_, weights := alm.schedule.Active(alm.config.objectiveWeights())
 	optConfig := optimization.DefaultOptimizerConfig()
 	optConfig.OptimizationTimeout = alm.config.MaxOptimizeTime
+	*optConfig = withObjectiveWeights(*optConfig, weights)
 	alm.optimizer = optimization.NewMultiObjectiveOptimizer(optConfig)
 	
 	// Initialize routing table
 	routingConfig := routing.DefaultRoutingConfig()
 	routingConfig.SearchTimeout = alm.config.SearchTimeout
-	routingConfig.OptimizationLevel = routing.OptimizationLevel(alm.config.OptimizationLevel)
+	routingConfig.CacheSize = alm.config.RouteCacheSize
+	routingConfig.CacheTTL = alm.config.RouteCacheTTL
+	routingConfig.LoadRefreshInterval = alm.config.RouteLoadRefresh
+	routingConfig.HealthCheckInterval = alm.config.RouteProbeInterval
+	routingConfig.HealthCheckFailures = alm.config.RouteProbeFailures
+	routingConfig.OptimizationLevel = alm.config.OptimizationLevel
+	routingConfig.LatencyWindow = alm.config.LatencyWindow
+	routingConfig.LatencyWindowSlots = alm.config.LatencyWindowSlots
 	alm.routingTable = routing.NewRoutingTable(
 		alm.networkGraph,
 		alm.associativeEngine,
 		alm.optimizer,
 		routingConfig,
 	)
+	alm.routeHealth = routing.NewHealthChecker(alm.routingTable, nil)
+	if err := alm.routingTable.ConstraintTemplates().Replace(alm.config.ConstraintTemplates); err != nil {
+		return fmt.Errorf("invalid constraint templates: %w", err)
+	}
+	if err := alm.routingTable.DRPlans().Replace(alm.config.DRPlans); err != nil {
+		return fmt.Errorf("invalid dr plans: %w", err)
+	}
+	if err := alm.routingTable.GetRoutingMetrics().SLOs().Replace(alm.config.LatencySLOs); err != nil {
+		return fmt.Errorf("invalid latency slos: %w", err)
+	}
+	
+	// Initialize route admission
+	alm.admission = NewAdmissionController(&AdmissionConfig{
+		MaxConcurrent: alm.config.MaxConcurrentRoutes,
+		QueueSize:     alm.config.RouteQueueSize,
+		QueueTimeout:  alm.config.RouteQueueTimeout,
+	})
 	
 	// Initialize service registry
 	serviceConfig := service.DefaultRegistryConfig()
 	serviceConfig.CacheSize = alm.config.ServiceCacheSize
 	serviceConfig.CacheTTL = alm.config.ServiceCacheTTL
+	serviceConfig.DegradedThreshold = alm.config.DegradedThreshold
+	serviceConfig.UnhealthyThreshold = alm.config.UnhealthyThreshold
+	serviceConfig.ProximityWeight = alm.config.ProximityWeight
+	serviceConfig.HealthWeight = alm.config.HealthWeight
+	serviceConfig.AffinityWeight = alm.config.AffinityWeight
+	serviceConfig.PerformanceWeight = alm.config.PerformanceWeight
 	alm.serviceRegistry = service.NewEnhancedServiceRegistry(
 		alm.networkGraph,
 		alm.routingTable,
 		serviceConfig,
 	)
 	
+	alm.faults = NewFaultInjector(alm, alm.config.FaultInjection, alm.logger)
+	alm.featureFlags = NewFeatureFlags(alm.config.FeatureFlags)
+	
+	trustAnchors, err := ParseTrustAnchors(alm.config.TrustAnchors)
+	if err != nil {
+		return fmt.Errorf("invalid trust anchors: %w", err)
+	}
+	alm.trustAnchors = trustAnchors
+	
+	if err := alm.applyScrubPolicy(alm.config.scrubPolicy()); err != nil {
+		return err
+	}
+	
 	// Initialize monitoring components
 	alm.performanceMonitor = NewPerformanceMonitor(alm.config.MetricsInterval)
-	alm.metricsCollector = NewMetricsCollector()
+	alm.metricsCollector = NewMetricsCollector(alm.config.MetricsInterval)
+	alm.memoryBudget = alm.newMemoryBudget()
+	alm.brownout = alm.newBrownoutDetector()
+	alm.dashboard = alm.newDashboardRecorder()
+	alm.quotas = alm.newQuotas()
 	
 	return nil
 }
 
-// calculateImprovementFactor calculates the current improvement factor vs baseline
+// newMemoryBudget puts the caches and service affinities under the memory
+// budget, apportioning it by rough per-entry sizes: a cached route holds its
+// path and alternatives, a discovery result its ranked instances
+func (alm *ALMCoordinator) newMemoryBudget() *MemoryBudget {
+	heap := func() uint64 {
+		return alm.performanceMonitor.GetResourceUsage().HeapInUse
+	}
+	budget := NewMemoryBudget(alm.config.MemoryBudget, alm.config.MetricsInterval, heap, alm.logger)
+	
+	budget.AddConsumer(MemoryConsumer{
+		Name:       "route_cache",
+		Share:      0.4,
+		EntryBytes: 512,
+		MaxEntries: alm.config.RouteCacheSize,
+		Len:        func() int { return alm.routingTable.GetRouteCacheStats().Size },
+		Resize:     alm.routingTable.ResizeRouteCache,
+	})
+	budget.AddConsumer(MemoryConsumer{
+		Name:       "path_cache",
+		Share:      0.2,
+		EntryBytes: 256,
+		MaxEntries: alm.config.PathCacheSize,
+		Len:        func() int { return alm.networkGraph.GetPathCacheStats().Size },
+		Resize:     alm.networkGraph.ResizePathCache,
+	})
+	budget.AddConsumer(MemoryConsumer{
+		Name:       "discovery_cache",
+		Share:      0.3,
+		EntryBytes: 2048,
+		MaxEntries: alm.config.ServiceCacheSize,
+		Len:        alm.serviceRegistry.DiscoveryCacheLen,
+		Resize: func(capacity int) error {
+			alm.serviceRegistry.ResizeDiscoveryCache(capacity)
+			return nil
+		},
+	})
+	budget.AddConsumer(MemoryConsumer{
+		Name:       "service_affinities",
+		Share:      0.1,
+		EntryBytes: 128,
+		Len:        alm.serviceRegistry.AffinitiesLen,
+		Resize: func(capacity int) error {
+			alm.serviceRegistry.LimitAffinities(capacity)
+			return nil
+		},
+	})
+	
+	return budget
+}
+
+// calculateImprovementFactor calculates the current improvement factor vs
+// baseline; callers hold at least the read lock
 func (alm *ALMCoordinator) calculateImprovementFactor() float64 {
 	currentLatency := alm.metricsCollector.GetAverageRoutingLatency()
 	baselineLatency := time.Duration(alm.config.BaselineLatencyMs * float64(time.Millisecond))
@@ -448,6 +1174,17 @@ type RouteRequest struct {
 	MinReliability float64
 	MaxCost        float64
 	MaxHops        int
+	
+	// ConstraintTemplate names constraints registered in
+	// ConstraintTemplates; the limits above can only tighten them
+	ConstraintTemplate string
+	
+	// Disjoint asks for alternatives sharing no link (graph.EdgeDisjoint)
+	// or no intermediate node (graph.NodeDisjoint) with the path or each
+	// other, within this coordinator's graph. DisjointPaths counts the
+	// paths, the path included; zero means two.
+	Disjoint      int
+	DisjointPaths int
 }
 
 type RouteResponse struct {
@@ -462,6 +1199,26 @@ type RouteResponse struct {
 	CacheHit       bool
 	Confidence     float64
 	Alternatives   []AlternativeRoute
+	
+	// Share of traffic recommended for Path; each alternative carries its
+	// own, and together they sum to 1
+	Weight         float64
+	
+	// Lowest graph.PathDiversity between any two of Path and the
+	// alternatives; 0 without alternatives
+	Diversity      float64
+	
+	// Set when the destination is in another region. Path then ends with
+	// the remote gateways crossed and the destination, and the metrics
+	// beyond this region are estimated from region summaries.
+	DestinationRegion string
+	
+	// Set when the destination's region has failed: the region whose
+	// disaster recovery plan redirected the route, and the failover region
+	// and destination it leads to instead
+	FailedRegion        string
+	FailoverRegion      string
+	FailoverDestination int64
 }
 
 type AlternativeRoute struct {
@@ -471,6 +1228,98 @@ type AlternativeRoute struct {
 	Reliability    float64
 	Cost           float64
 	Score          float64
+	Weight         float64
+}
+
+// PerformanceMetrics is a point-in-time view of coordinator performance
+type PerformanceMetrics struct {
+	// Core metrics
+	AverageRoutingLatency   time.Duration
+	RoutingSuccessRate      float64
+	ServiceDiscoveryLatency time.Duration
+	CacheHitRate            float64
+	
+	// 777% improvement tracking
+	ImprovementFactor       float64
+	TargetAchievement       float64
+	
+	GraphStats              graph.TopologyStats
+	
+	// System metrics from the latest PerformanceMonitor sample
+	Uptime                  time.Duration
+	MemoryUsage             int64   // Resident bytes
+	CPUUsage                float64 // Percent of one core
+	Goroutines              int
+	GCPause                 time.Duration // Longest pause in the last interval
+	Resources               ResourceUsage
+	
+	// Component stats
+	RoutingStats            routing.RoutingStats
+	ServiceRegistryStats    service.RegistryStats
+}
+
+// TopologyUpdate describes one change applied by UpdateNetworkTopology
+type TopologyUpdate struct {
+	Type     TopologyUpdateType
+	Node     *graph.NetworkNode
+	NodeID   int64
+	Edge     *graph.NetworkEdge
+	EdgeFrom int64
+	EdgeTo   int64
+	Metrics  graph.NodeMetrics
+	
+	// Link quality for EdgeMetricsUpdate
+	EdgeMetrics graph.EdgeMetrics
+}
+
+// TopologyUpdateType identifies the kind of topology change
+type TopologyUpdateType int
+
+const (
+	NodeAddUpdate TopologyUpdateType = iota
+	NodeRemoveUpdate
+	EdgeAddUpdate
+	EdgeRemoveUpdate
+	MetricsUpdate
+	EdgeMetricsUpdate
+)
+
+// String returns the update type name
+func (t TopologyUpdateType) String() string {
+	switch t {
+	case NodeAddUpdate:
+		return "node_add"
+	case NodeRemoveUpdate:
+		return "node_remove"
+	case EdgeAddUpdate:
+		return "edge_add"
+	case EdgeRemoveUpdate:
+		return "edge_remove"
+	case MetricsUpdate:
+		return "metrics"
+	case EdgeMetricsUpdate:
+		return "edge_metrics"
+	default:
+		return "unknown"
+	}
+}
+
+// Validate checks that an update carries the payload its type needs
+func (u TopologyUpdate) Validate() error {
+	switch u.Type {
+	case NodeAddUpdate:
+		if u.Node == nil {
+			return fmt.Errorf("%s requires node", u.Type)
+		}
+	case EdgeAddUpdate:
+		if u.Edge == nil {
+			return fmt.Errorf("%s requires edge", u.Type)
+		}
+	case NodeRemoveUpdate, EdgeRemoveUpdate, MetricsUpdate, EdgeMetricsUpdate:
+	default:
+		return fmt.Errorf("unknown update type %d", u.Type)
+	}
+	return nil
 }
 
 type ServiceQuery struct {
@@ -488,6 +1337,7 @@ type ServiceQuery struct {
 	IncludeDegraded  bool
 	MaxResults       int
 	SortBy          int
+	AffinityKey      string
 }
 
 type ServiceDiscoveryResponse struct {
@@ -523,14 +1373,54 @@ func DefaultALMConfig() *ALMConfig {
 		SearchTimeout:         1 * time.Second,
 		MaxSearchDepth:        20,
 		BeamWidth:            8,
-		OptimizationLevel:     2, // BalancedOptimization
+		OptimizationLevel:     routing.BalancedOptimization,
 		MaxOptimizeTime:      5 * time.Second,
+		RouteCacheSize:       10000,
+		RouteCacheTTL:        5 * time.Minute,
+		PathCacheSize:        1000,
+		RouteLoadRefresh:     1 * time.Second,
+		RouteProbeInterval:   30 * time.Second,
+		RouteProbeFailures:   3,
+		LatencyWindow:        5 * time.Minute,
+		LatencyWindowSlots:   5,
+		LatencySLOs:          routing.DefaultLatencySLOs(),
+		Landmarks:            8,
+		EdgeSmoothing:        graph.EWMASmoothing,
+		EdgeSmoothingWindow:  8,
+		EdgeSmoothingAlpha:   0.3,
+		EdgeOutlierThreshold: 4,
+		MaxConcurrentRoutes:  256,
+		RouteQueueSize:       4096,
+		RouteQueueTimeout:    250 * time.Millisecond,
+		BrownoutQueueDepth:   2048,
+		BrownoutCPUPercent:   90,
+		BrownoutP99Latency:   100 * time.Millisecond,
+		BrownoutCacheTTL:     30 * time.Minute,
+		BrownoutHold:         30 * time.Second,
 		ServiceCacheSize:     10000,
 		ServiceCacheTTL:      5 * time.Minute,
+		DegradedThreshold:    0.7,
+		UnhealthyThreshold:   0.3,
+		LatencyWeight:        0.3,
+		ThroughputWeight:     0.3,
+		ReliabilityWeight:    0.2,
+		CostWeight:           0.2,
+		ProximityWeight:      0.3,
+		HealthWeight:         0.3,
+		AffinityWeight:       0.2,
+		PerformanceWeight:    0.2,
 		TargetLatencyMs:      0.16,  // 777% improvement target
 		BaselineLatencyMs:    1.39,  // HTTP baseline
 		MetricsInterval:      10 * time.Second,
 		HealthCheckInterval: 30 * time.Second,
+		DashboardRetention:   6 * time.Hour,
+		DrainTimeout:         5 * time.Second,
+		ShutdownTimeout:      30 * time.Second,
+		Persistence:          false,
+		SnapshotInterval:     5 * time.Minute,
+		MaintenanceInterval:  10 * time.Minute,
+		AffinityPruneThreshold: 0.01,
+		FaultInjection:       false,
 		HyperMeshIntegration: true,
 		STOQIntegration:     true,
 		Layer2Integration:   true,
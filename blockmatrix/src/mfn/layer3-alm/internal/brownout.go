@@ -0,0 +1,260 @@
+// Package internal implements brownout mode under global overload
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// brownoutExitAt is the fraction of each threshold every signal must stay
+// below for the hold time before brownout ends
+const brownoutExitAt = 0.8
+
+// Signals that can trigger brownout
+const (
+	BrownoutQueueDepth = "queue_depth"
+	BrownoutCPU        = "cpu"
+	BrownoutP99Latency = "p99_latency"
+)
+
+// BrownoutConfig sets the thresholds that trigger brownout, zero disabling
+// a signal, the TTL cached routes are served for while it lasts and how
+// long load must stay down before it ends
+type BrownoutConfig struct {
+	QueueDepth int
+	CPUPercent float64
+	P99Latency time.Duration
+	CacheTTL   time.Duration
+	Hold       time.Duration
+}
+
+// BrownoutSignals are the load readings compared with the thresholds
+type BrownoutSignals struct {
+	QueueDepth int
+	CPUPercent float64
+	P99Latency time.Duration
+}
+
+// BrownoutStats is the brownout state and the last readings
+type BrownoutStats struct {
+	Active  bool
+	Since   time.Time
+	Reason  string
+	Entered int64
+	Signals BrownoutSignals
+}
+
+// BrownoutDetector watches route queue depth, CPU and P99 lookup latency
+// and switches the coordinator into brownout when any exceeds its
+// threshold: lookups use FastLookup only, optimizer runs and affinity
+// learning are suspended and cached routes are served for the extended
+// CacheTTL. Brownout ends once every signal has stayed below 80% of its
+// threshold for Hold.
+type BrownoutDetector struct {
+	interval time.Duration
+	signals  func() BrownoutSignals
+	apply    func(active bool, cacheTTL time.Duration)
+
+	mutex  sync.Mutex
+	config BrownoutConfig
+	active bool
+	since  time.Time
+	reason string
+
+	// Start of the current run of readings below the exit thresholds
+	calmSince time.Time
+
+	entered int64
+	last    BrownoutSignals
+
+	logger *zap.Logger
+}
+
+// NewBrownoutDetector creates a detector that reads signals every interval
+// and calls apply when brownout starts or ends
+func NewBrownoutDetector(config BrownoutConfig, interval time.Duration, signals func() BrownoutSignals, apply func(active bool, cacheTTL time.Duration), logger *zap.Logger) *BrownoutDetector {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &BrownoutDetector{
+		interval: interval,
+		signals:  signals,
+		apply:    apply,
+		config:   config,
+		logger:   logger,
+	}
+}
+
+// Run checks the signals every interval until ctx is cancelled, leaving
+// brownout on the way out
+func (bd *BrownoutDetector) Run(ctx context.Context) {
+	ticker := time.NewTicker(bd.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			bd.leave()
+			return
+		case now := <-ticker.C:
+			bd.Check(now)
+		}
+	}
+}
+
+// Check reads the signals, entering brownout if any exceeds its threshold
+// and leaving it once all have been calm for the hold time. It reports
+// whether brownout is on.
+func (bd *BrownoutDetector) Check(now time.Time) bool {
+	signals := bd.signals()
+
+	bd.mutex.Lock()
+	defer bd.mutex.Unlock()
+
+	bd.last = signals
+	if reason := bd.overloaded(signals, 1); reason != "" {
+		bd.calmSince = time.Time{}
+		if !bd.active {
+			bd.active = true
+			bd.since = now
+			bd.reason = reason
+			bd.entered++
+			bd.logger.Warn("Entering brownout under overload",
+				zap.String("reason", reason),
+				zap.Int("queue_depth", signals.QueueDepth),
+				zap.Float64("cpu_percent", signals.CPUPercent),
+				zap.Duration("p99_latency", signals.P99Latency),
+			)
+			bd.apply(true, bd.config.CacheTTL)
+		}
+		return true
+	}
+	if !bd.active {
+		return false
+	}
+
+	if bd.overloaded(signals, brownoutExitAt) != "" {
+		bd.calmSince = time.Time{}
+		return true
+	}
+	if bd.calmSince.IsZero() {
+		bd.calmSince = now
+	}
+	if now.Sub(bd.calmSince) < bd.config.Hold {
+		return true
+	}
+
+	bd.logger.Info("Leaving brownout", zap.Duration("duration", now.Sub(bd.since)))
+	bd.leaveLocked()
+	return false
+}
+
+// SetConfig replaces the thresholds, hold time and extended TTL, applying a
+// changed TTL at once if in brownout
+func (bd *BrownoutDetector) SetConfig(config BrownoutConfig) {
+	bd.mutex.Lock()
+	defer bd.mutex.Unlock()
+
+	previous := bd.config
+	bd.config = config
+	if bd.active && config.CacheTTL != previous.CacheTTL {
+		bd.apply(true, config.CacheTTL)
+	}
+}
+
+// Config returns the current thresholds
+func (bd *BrownoutDetector) Config() BrownoutConfig {
+	bd.mutex.Lock()
+	defer bd.mutex.Unlock()
+
+	return bd.config
+}
+
+// Stats returns whether brownout is on, since when and why, how often it
+// was entered and the last readings
+func (bd *BrownoutDetector) Stats() BrownoutStats {
+	bd.mutex.Lock()
+	defer bd.mutex.Unlock()
+
+	return BrownoutStats{
+		Active:  bd.active,
+		Since:   bd.since,
+		Reason:  bd.reason,
+		Entered: bd.entered,
+		Signals: bd.last,
+	}
+}
+
+// overloaded returns the first signal above fraction of its threshold, or
+// "" if none is. The caller holds the mutex.
+func (bd *BrownoutDetector) overloaded(signals BrownoutSignals, fraction float64) string {
+	config := bd.config
+	switch {
+	case config.QueueDepth > 0 && float64(signals.QueueDepth) > float64(config.QueueDepth)*fraction:
+		return BrownoutQueueDepth
+	case config.CPUPercent > 0 && signals.CPUPercent > config.CPUPercent*fraction:
+		return BrownoutCPU
+	case config.P99Latency > 0 && float64(signals.P99Latency) > float64(config.P99Latency)*fraction:
+		return BrownoutP99Latency
+	}
+	return ""
+}
+
+// leave ends brownout if it is on
+func (bd *BrownoutDetector) leave() {
+	bd.mutex.Lock()
+	defer bd.mutex.Unlock()
+
+	if bd.active {
+		bd.leaveLocked()
+	}
+}
+
+// leaveLocked ends brownout. The caller holds the mutex.
+func (bd *BrownoutDetector) leaveLocked() {
+	bd.active = false
+	bd.reason = ""
+	bd.calmSince = time.Time{}
+	bd.apply(false, bd.config.CacheTTL)
+}
+
+// brownoutConfig returns the brownout settings of an ALM configuration
+func brownoutConfig(config *ALMConfig) BrownoutConfig {
+	return BrownoutConfig{
+		QueueDepth: config.BrownoutQueueDepth,
+		CPUPercent: config.BrownoutCPUPercent,
+		P99Latency: config.BrownoutP99Latency,
+		CacheTTL:   config.BrownoutCacheTTL,
+		Hold:       config.BrownoutHold,
+	}
+}
+
+// newBrownoutDetector watches the route queue, system CPU and lookup P99,
+// switching the routing table and service registry into brownout
+func (alm *ALMCoordinator) newBrownoutDetector() *BrownoutDetector {
+	signals := func() BrownoutSignals {
+		return BrownoutSignals{
+			QueueDepth: alm.admission.Stats().Queued,
+			CPUPercent: alm.performanceMonitor.GetResourceUsage().SystemCPUPercent,
+			P99Latency: alm.routingTable.GetRoutingMetrics().LatencyQuantile(0.99),
+		}
+	}
+	apply := func(active bool, cacheTTL time.Duration) {
+		alm.routingTable.SetBrownout(active, cacheTTL)
+		alm.serviceRegistry.SuspendLearning(active)
+	}
+	return NewBrownoutDetector(brownoutConfig(alm.config), alm.config.MetricsInterval, signals, apply, alm.logger)
+}
+
+// Brownout returns the overload detector switching the coordinator into
+// brownout
+func (alm *ALMCoordinator) Brownout() *BrownoutDetector {
+	return alm.brownout
+}
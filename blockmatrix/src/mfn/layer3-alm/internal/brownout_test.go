@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBrownoutDetectorEntersAndLeaves(t *testing.T) {
+	var signals BrownoutSignals
+	var applied []bool
+	detector := NewBrownoutDetector(BrownoutConfig{
+		QueueDepth: 100,
+		CPUPercent: 90,
+		P99Latency: 10 * time.Millisecond,
+		CacheTTL:   time.Hour,
+		Hold:       time.Minute,
+	}, 0, func() BrownoutSignals { return signals }, func(active bool, cacheTTL time.Duration) {
+		applied = append(applied, active)
+	}, nil)
+
+	now := time.Now()
+	if detector.Check(now) {
+		t.Fatal("entered brownout without load")
+	}
+
+	signals.P99Latency = 20 * time.Millisecond
+	if !detector.Check(now) || len(applied) != 1 || !applied[0] {
+		t.Fatalf("did not enter brownout over the P99 threshold, applied %v", applied)
+	}
+	if stats := detector.Stats(); stats.Reason != BrownoutP99Latency || stats.Entered != 1 {
+		t.Errorf("stats after entering: %+v", stats)
+	}
+
+	// Load just under the threshold keeps brownout on however long it lasts
+	signals.P99Latency = 9 * time.Millisecond
+	if !detector.Check(now.Add(time.Hour)) {
+		t.Error("left brownout with load above the exit threshold")
+	}
+
+	// Load below the exit threshold ends it only after the hold time
+	signals.P99Latency = time.Millisecond
+	now = now.Add(2 * time.Hour)
+	if !detector.Check(now) || !detector.Check(now.Add(30*time.Second)) {
+		t.Error("left brownout before the hold time")
+	}
+	if detector.Check(now.Add(time.Minute)) {
+		t.Error("still in brownout after the hold time")
+	}
+	if len(applied) != 2 || applied[1] {
+		t.Errorf("applied %v, want brownout on then off", applied)
+	}
+}
+
+func TestBrownoutDetectorIgnoresDisabledSignals(t *testing.T) {
+	signals := BrownoutSignals{QueueDepth: 1 << 20, CPUPercent: 100}
+	detector := NewBrownoutDetector(BrownoutConfig{P99Latency: time.Second, Hold: time.Minute},
+		0, func() BrownoutSignals { return signals }, func(bool, time.Duration) {}, nil)
+
+	if detector.Check(time.Now()) {
+		t.Error("entered brownout on signals without a threshold")
+	}
+}
@@ -0,0 +1,836 @@
+// Package internal implements ALM configuration loading, validation and hot reload
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigEnvPrefix prefixes environment variables that override config file
+// values, such as ALM_SEARCH_TIMEOUT=500ms
+const ConfigEnvPrefix = "ALM_"
+
+// configKeyOverrides names fields whose key is not their snake_case name
+var configKeyOverrides = map[string]string{
+	"HyperMeshIntegration": "hypermesh_integration",
+	"LatencySLOs":          "latency_slos",
+}
+
+// restartOnlyFields size or start components, so changing them needs a
+// restart; ReloadConfig keeps their current values
+var restartOnlyFields = []string{
+	"MaxNodes",
+	"MaxEdges",
+	"MaxSearchDepth",
+	"BeamWidth",
+	"OptimizationLevel",
+	"LatencyWindow",
+	"LatencyWindowSlots",
+	"ServiceCacheSize",
+	"MetricsInterval",
+	"HealthCheckInterval",
+	"DashboardRetention",
+	"HyperMeshIntegration",
+	"STOQIntegration",
+	"Layer2Integration",
+	"Persistence",
+	"NodeID",
+	"StateDir",
+	"StateKeyFile",
+	"StateKeyEnv",
+	"StateMigratePlaintext",
+	"TrustAnchors",
+}
+
+// LoadALMConfig reads an ALM configuration file, applies ALM_* environment
+// overrides and validates the result. Files ending in .toml are parsed as
+// TOML, anything else as YAML. Keys are the snake_case ALMConfig field names
+// and durations are strings such as "30s":
+//
+//	max_nodes: 100000
+//	search_timeout: 1s
+//	route_cache_size: 20000
+//	latency_weight: 0.4
+//
+// Fields missing from the file keep their DefaultALMConfig values.
+func LoadALMConfig(path string) (*ALMConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	values := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		if _, err := toml.Decode(string(data), &values); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	default:
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+
+	config := DefaultALMConfig()
+	if err := applyConfigValues(config, values, os.Environ()); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// Validate checks the configuration and reports every problem found
+func (c *ALMConfig) Validate() error {
+	var problems []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.MaxNodes > 0, "max_nodes must be positive, got %d", c.MaxNodes)
+	check(c.MaxEdges > 0, "max_edges must be positive, got %d", c.MaxEdges)
+	check(c.MaxSearchDepth > 0, "max_search_depth must be positive, got %d", c.MaxSearchDepth)
+	check(c.BeamWidth > 0, "beam_width must be positive, got %d", c.BeamWidth)
+	check(c.RouteCacheSize > 0, "route_cache_size must be positive, got %d", c.RouteCacheSize)
+	check(c.PathCacheSize > 0, "path_cache_size must be positive, got %d", c.PathCacheSize)
+	check(c.LatencyWindowSlots > 0, "latency_window_slots must be positive, got %d", c.LatencyWindowSlots)
+	check(c.RouteProbeFailures > 0, "route_probe_failures must be positive, got %d", c.RouteProbeFailures)
+	check(c.HubPathTrees >= 0, "hub_path_trees must not be negative, got %d", c.HubPathTrees)
+	check(c.Landmarks >= 0, "landmarks must not be negative, got %d", c.Landmarks)
+	check(c.MaxConcurrentRoutes > 0, "max_concurrent_routes must be positive, got %d", c.MaxConcurrentRoutes)
+	check(c.RouteQueueSize >= 0, "route_queue_size must not be negative, got %d", c.RouteQueueSize)
+	check(c.ServiceCacheSize > 0, "service_cache_size must be positive, got %d", c.ServiceCacheSize)
+	check(c.MemoryBudget >= 0, "memory_budget must not be negative, got %d", c.MemoryBudget)
+	check(c.BrownoutQueueDepth >= 0, "brownout_queue_depth must not be negative, got %d", c.BrownoutQueueDepth)
+	check(c.BrownoutCPUPercent >= 0 && c.BrownoutCPUPercent <= 100,
+		"brownout_cpu_percent must be between 0 and 100, got %g", c.BrownoutCPUPercent)
+	check(c.BrownoutP99Latency >= 0, "brownout_p99_latency must not be negative, got %s", c.BrownoutP99Latency)
+	check(int(c.OptimizationLevel) >= int(routing.FastLookup) && int(c.OptimizationLevel) <= int(routing.DeepOptimization),
+		"optimization_level must be between %d (fast) and %d (deep), got %d",
+		routing.FastLookup, routing.DeepOptimization, c.OptimizationLevel)
+
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"topology_refresh", c.TopologyRefresh},
+		{"search_timeout", c.SearchTimeout},
+		{"max_optimize_time", c.MaxOptimizeTime},
+		{"route_cache_ttl", c.RouteCacheTTL},
+		{"route_load_refresh", c.RouteLoadRefresh},
+		{"route_probe_interval", c.RouteProbeInterval},
+		{"latency_window", c.LatencyWindow},
+		{"service_cache_ttl", c.ServiceCacheTTL},
+		{"route_queue_timeout", c.RouteQueueTimeout},
+		{"brownout_cache_ttl", c.BrownoutCacheTTL},
+		{"brownout_hold", c.BrownoutHold},
+		{"metrics_interval", c.MetricsInterval},
+		{"health_check_interval", c.HealthCheckInterval},
+		{"dashboard_retention", c.DashboardRetention},
+		{"drain_timeout", c.DrainTimeout},
+		{"shutdown_timeout", c.ShutdownTimeout},
+		{"maintenance_interval", c.MaintenanceInterval},
+	} {
+		check(d.value > 0, "%s must be a positive duration such as \"30s\", got %s", d.key, d.value)
+	}
+
+	for _, group := range []struct {
+		name    string
+		keys    []string
+		weights []float64
+	}{
+		{"route objective", []string{"latency_weight", "throughput_weight", "reliability_weight", "cost_weight"},
+			[]float64{c.LatencyWeight, c.ThroughputWeight, c.ReliabilityWeight, c.CostWeight}},
+		{"service ranking", []string{"proximity_weight", "health_weight", "affinity_weight", "performance_weight"},
+			[]float64{c.ProximityWeight, c.HealthWeight, c.AffinityWeight, c.PerformanceWeight}},
+	} {
+		total := 0.0
+		for i, weight := range group.weights {
+			check(weight >= 0, "%s must not be negative, got %g", group.keys[i], weight)
+			total += weight
+		}
+		check(total > 0, "%s weights (%s) must not all be zero", group.name, strings.Join(group.keys, ", "))
+	}
+
+	check(c.UnhealthyThreshold >= 0 && c.UnhealthyThreshold < c.DegradedThreshold && c.DegradedThreshold <= 1,
+		"health thresholds must satisfy 0 <= unhealthy_threshold (%g) < degraded_threshold (%g) <= 1",
+		c.UnhealthyThreshold, c.DegradedThreshold)
+	check(c.AffinityPruneThreshold >= 0 && c.AffinityPruneThreshold < 1,
+		"affinity_prune_threshold must be in [0, 1), got %g", c.AffinityPruneThreshold)
+
+	if c.Persistence {
+		check(c.SnapshotInterval > 0, "snapshot_interval must be a positive duration such as \"5m\", got %s", c.SnapshotInterval)
+	}
+	check(c.StateKeyFile == "" || c.StateKeyEnv == "", "state_key_file and state_key_env are mutually exclusive")
+
+	for name, flag := range c.FeatureFlags {
+		flag.Name = name
+		if err := flag.Validate(); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	for name, constraints := range c.ConstraintTemplates {
+		if err := constraints.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("constraint template %s: %w", name, err))
+		}
+	}
+	if err := routing.ValidateDRPlans(c.DRPlans); err != nil {
+		problems = append(problems, err)
+	}
+	if err := routing.ValidateLatencySLOs(c.LatencySLOs); err != nil {
+		problems = append(problems, err)
+	}
+	if err := c.edgeSmoothing().Validate(); err != nil {
+		problems = append(problems, err)
+	}
+
+	if _, err := ParseTrustAnchors(c.TrustAnchors); err != nil {
+		problems = append(problems, err)
+	}
+	if err := c.scrubPolicy().Validate(); err != nil {
+		problems = append(problems, err)
+	}
+	if err := ValidateRoutingSchedule(c.RoutingSchedule); err != nil {
+		problems = append(problems, err)
+	}
+
+	check(c.TargetLatencyMs > 0, "target_latency_ms must be positive, got %g", c.TargetLatencyMs)
+	check(c.BaselineLatencyMs > c.TargetLatencyMs,
+		"baseline_latency_ms (%g) must be greater than target_latency_ms (%g)", c.BaselineLatencyMs, c.TargetLatencyMs)
+
+	return errors.Join(problems...)
+}
+
+// edgeSmoothing returns the edge measurement smoothing configured by c
+func (c *ALMConfig) edgeSmoothing() graph.EdgeSmoothing {
+	return graph.EdgeSmoothing{
+		Method:           c.EdgeSmoothing,
+		Window:           c.EdgeSmoothingWindow,
+		Alpha:            c.EdgeSmoothingAlpha,
+		OutlierThreshold: c.EdgeOutlierThreshold,
+	}
+}
+
+// ConfigDelta holds configuration changes keyed like the config file, such
+// as {"route_cache_size": 20000, "search_timeout": "500ms"}
+type ConfigDelta map[string]interface{}
+
+// ReloadConfig applies config to the running coordinator without a restart.
+// Cache sizes and TTLs, objective and ranking weights, thresholds, timeouts,
+// maintenance, snapshot and topology refresh intervals, feature flags,
+// constraint templates, DR plans, latency SLOs, landmark count, edge
+// smoothing, scrubbing policy, routing schedule and latency targets take
+// effect immediately. Settings that size or start components keep their
+// current values and are logged as needing a restart.
+func (alm *ALMCoordinator) ReloadConfig(config *ALMConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	alm.mutex.Lock()
+	defer alm.mutex.Unlock()
+
+	next := *config
+	current := reflect.ValueOf(alm.config).Elem()
+	updated := reflect.ValueOf(&next).Elem()
+
+	var restartRequired []string
+	for _, name := range restartOnlyFields {
+		if !reflect.DeepEqual(current.FieldByName(name).Interface(), updated.FieldByName(name).Interface()) {
+			restartRequired = append(restartRequired, configKey(name))
+			updated.FieldByName(name).Set(current.FieldByName(name))
+		}
+	}
+
+	if err := alm.applyComponentConfig(&next); err != nil {
+		return err
+	}
+	changed := alm.commitConfigLocked(&next)
+
+	alm.logger.Info("Configuration reloaded", zap.Strings("changed", changed))
+	if len(restartRequired) > 0 {
+		alm.logger.Warn("Configuration changes require a restart to take effect",
+			zap.Strings("settings", restartRequired),
+		)
+	}
+
+	return nil
+}
+
+// ApplyConfig changes the settings in delta on the running coordinator and
+// returns the keys that changed. The delta is rejected as a whole if a key
+// is unknown, a value does not parse, a setting can only change on restart
+// or the resulting configuration is invalid. If a component rejects its
+// new settings, components already updated are rolled back and the
+// configuration is left unchanged.
+func (alm *ALMCoordinator) ApplyConfig(delta ConfigDelta) ([]string, error) {
+	alm.mutex.Lock()
+	defer alm.mutex.Unlock()
+
+	next := *alm.config
+	if err := applyConfigValues(&next, delta, nil); err != nil {
+		return nil, fmt.Errorf("invalid config change: %w", err)
+	}
+
+	current := reflect.ValueOf(alm.config).Elem()
+	updated := reflect.ValueOf(&next).Elem()
+
+	var restartOnly []string
+	for _, name := range restartOnlyFields {
+		if !reflect.DeepEqual(current.FieldByName(name).Interface(), updated.FieldByName(name).Interface()) {
+			restartOnly = append(restartOnly, configKey(name))
+		}
+	}
+	if len(restartOnly) > 0 {
+		return nil, fmt.Errorf("settings can only change on restart: %s", strings.Join(restartOnly, ", "))
+	}
+
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := alm.applyComponentConfig(&next); err != nil {
+		return nil, err
+	}
+	changed := alm.commitConfigLocked(&next)
+
+	alm.logger.Info("Configuration changed at runtime", zap.Strings("changed", changed))
+
+	return changed, nil
+}
+
+// ConfigValues returns the configuration keyed like the config file, with
+// durations as strings such as "30s", so it can be edited and passed back to
+// ApplyConfig
+func (alm *ALMCoordinator) ConfigValues() map[string]interface{} {
+	config := alm.Config()
+	value := reflect.ValueOf(&config).Elem()
+
+	values := make(map[string]interface{}, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if field.Type() == reflect.TypeOf(time.Duration(0)) {
+			values[configKey(value.Type().Field(i).Name)] = time.Duration(field.Int()).String()
+			continue
+		}
+		values[configKey(value.Type().Field(i).Name)] = field.Interface()
+	}
+	return values
+}
+
+// applyComponentConfig pushes next to the routing table, path cache, memory
+// budget, optimizer and service registry. If a step fails the steps before
+// it are undone. The caller holds the coordinator lock.
+func (alm *ALMCoordinator) applyComponentConfig(next *ALMConfig) (err error) {
+	var undo []func() error
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(undo) - 1; i >= 0; i-- {
+			if undoErr := undo[i](); undoErr != nil {
+				alm.logger.Error("Failed to roll back configuration change", zap.Error(undoErr))
+			}
+		}
+	}()
+
+	previousRouting := alm.routingTable.Config()
+	routingConfig := previousRouting
+	routingConfig.SearchTimeout = next.SearchTimeout
+	routingConfig.CacheSize = next.RouteCacheSize
+	routingConfig.CacheTTL = next.RouteCacheTTL
+	routingConfig.LoadRefreshInterval = next.RouteLoadRefresh
+	routingConfig.HealthCheckInterval = next.RouteProbeInterval
+	routingConfig.HealthCheckFailures = next.RouteProbeFailures
+	if err := alm.routingTable.UpdateConfig(routingConfig); err != nil {
+		return fmt.Errorf("failed to apply routing config: %w", err)
+	}
+	undo = append(undo, func() error {
+		return alm.routingTable.UpdateConfig(previousRouting)
+	})
+
+	if previousSize := alm.config.PathCacheSize; next.PathCacheSize != previousSize {
+		if err := alm.networkGraph.ResizePathCache(next.PathCacheSize); err != nil {
+			return fmt.Errorf("failed to resize path cache: %w", err)
+		}
+		undo = append(undo, func() error {
+			return alm.networkGraph.ResizePathCache(previousSize)
+		})
+	}
+
+	if previousTrees := alm.config.HubPathTrees; next.HubPathTrees != previousTrees {
+		alm.networkGraph.SetHubPathTrees(next.HubPathTrees)
+		undo = append(undo, func() error {
+			alm.networkGraph.SetHubPathTrees(previousTrees)
+			return nil
+		})
+	}
+
+	if previousLandmarks := alm.config.Landmarks; next.Landmarks != previousLandmarks {
+		alm.networkGraph.SetLandmarks(next.Landmarks)
+		undo = append(undo, func() error {
+			alm.networkGraph.SetLandmarks(previousLandmarks)
+			return nil
+		})
+	}
+
+	if previousSmoothing := alm.config.edgeSmoothing(); next.edgeSmoothing() != previousSmoothing {
+		if err := alm.networkGraph.SetEdgeSmoothing(next.edgeSmoothing()); err != nil {
+			return fmt.Errorf("failed to set edge smoothing: %w", err)
+		}
+		undo = append(undo, func() error {
+			return alm.networkGraph.SetEdgeSmoothing(previousSmoothing)
+		})
+	}
+
+	// The budget reapplies its capacities to the resized caches at its next
+	// rebalance
+	previous := alm.config
+	alm.memoryBudget.SetLimit(next.MemoryBudget)
+	alm.memoryBudget.SetMaxEntries("route_cache", next.RouteCacheSize)
+	alm.memoryBudget.SetMaxEntries("path_cache", next.PathCacheSize)
+	undo = append(undo, func() error {
+		alm.memoryBudget.SetLimit(previous.MemoryBudget)
+		alm.memoryBudget.SetMaxEntries("route_cache", previous.RouteCacheSize)
+		alm.memoryBudget.SetMaxEntries("path_cache", previous.PathCacheSize)
+		return nil
+	})
+
+	previousAdmission := alm.admission.Config()
+	alm.admission.SetConfig(AdmissionConfig{
+		MaxConcurrent: next.MaxConcurrentRoutes,
+		QueueSize:     next.RouteQueueSize,
+		QueueTimeout:  next.RouteQueueTimeout,
+	})
+	undo = append(undo, func() error {
+		alm.admission.SetConfig(previousAdmission)
+		return nil
+	})
+
+	previousBrownout := alm.brownout.Config()
+	alm.brownout.SetConfig(brownoutConfig(next))
+	undo = append(undo, func() error {
+		alm.brownout.SetConfig(previousBrownout)
+		return nil
+	})
+
+	previousFaults := alm.faults.Enabled()
+	alm.faults.SetEnabled(next.FaultInjection)
+	undo = append(undo, func() error {
+		alm.faults.SetEnabled(previousFaults)
+		return nil
+	})
+
+	templates := alm.routingTable.ConstraintTemplates()
+	previousTemplates := alm.config.ConstraintTemplates
+	if err := templates.Replace(next.ConstraintTemplates); err != nil {
+		return err
+	}
+	undo = append(undo, func() error {
+		return templates.Replace(previousTemplates)
+	})
+
+	drPlans := alm.routingTable.DRPlans()
+	previousPlans := alm.config.DRPlans
+	if err := drPlans.Replace(next.DRPlans); err != nil {
+		return err
+	}
+	undo = append(undo, func() error {
+		return drPlans.Replace(previousPlans)
+	})
+
+	slos := alm.routingTable.GetRoutingMetrics().SLOs()
+	previousSLOs := alm.config.LatencySLOs
+	if err := slos.Replace(next.LatencySLOs); err != nil {
+		return err
+	}
+	undo = append(undo, func() error {
+		return slos.Replace(previousSLOs)
+	})
+
+	previousFlags := alm.config.FeatureFlags
+	alm.featureFlags.Replace(next.FeatureFlags)
+	undo = append(undo, func() error {
+		alm.featureFlags.Replace(previousFlags)
+		return nil
+	})
+
+	previousSchedule := alm.config.RoutingSchedule
+	if err := alm.schedule.Replace(next.RoutingSchedule); err != nil {
+		return err
+	}
+	undo = append(undo, func() error {
+		return alm.schedule.Replace(previousSchedule)
+	})
+
+	previousOptimizer := alm.optimizer.Config()
+	optConfig := previousOptimizer
+	optConfig.OptimizationTimeout = next.MaxOptimizeTime
+	_, weights := alm.schedule.Active(next.objectiveWeights())
+	alm.optimizer.UpdateConfig(withObjectiveWeights(optConfig, weights))
+	undo = append(undo, func() error {
+		alm.optimizer.UpdateConfig(previousOptimizer)
+		return nil
+	})
+
+	// A new scrubber redacts to new hashes, so keep the current one unless
+	// the policy changed
+	if previousPolicy := alm.config.scrubPolicy(); !reflect.DeepEqual(next.scrubPolicy(), previousPolicy) {
+		previousScrubber := alm.Scrubber()
+		if err := alm.applyScrubPolicy(next.scrubPolicy()); err != nil {
+			return err
+		}
+		undo = append(undo, func() error {
+			alm.installScrubber(previousScrubber)
+			return nil
+		})
+	}
+
+	serviceConfig := alm.serviceRegistry.Config()
+	serviceConfig.CacheTTL = next.ServiceCacheTTL
+	serviceConfig.DegradedThreshold = next.DegradedThreshold
+	serviceConfig.UnhealthyThreshold = next.UnhealthyThreshold
+	serviceConfig.ProximityWeight = next.ProximityWeight
+	serviceConfig.HealthWeight = next.HealthWeight
+	serviceConfig.AffinityWeight = next.AffinityWeight
+	serviceConfig.PerformanceWeight = next.PerformanceWeight
+	alm.serviceRegistry.UpdateConfig(serviceConfig)
+
+	return nil
+}
+
+// commitConfigLocked makes next the configuration, wakes loops waiting on
+// configUpdates and returns the keys that changed
+func (alm *ALMCoordinator) commitConfigLocked(next *ALMConfig) []string {
+	current := reflect.ValueOf(alm.config).Elem()
+	updated := reflect.ValueOf(next).Elem()
+
+	var changed []string
+	for i := 0; i < current.NumField(); i++ {
+		if !reflect.DeepEqual(current.Field(i).Interface(), updated.Field(i).Interface()) {
+			changed = append(changed, configKey(current.Type().Field(i).Name))
+		}
+	}
+
+	alm.config = next
+	close(alm.configChanged)
+	alm.configChanged = make(chan struct{})
+
+	return changed
+}
+
+// configUpdates returns a channel closed at the next configuration change
+func (alm *ALMCoordinator) configUpdates() <-chan struct{} {
+	alm.mutex.RLock()
+	defer alm.mutex.RUnlock()
+
+	return alm.configChanged
+}
+
+// Config returns a copy of the coordinator configuration
+func (alm *ALMCoordinator) Config() ALMConfig {
+	alm.mutex.RLock()
+	defer alm.mutex.RUnlock()
+
+	return *alm.config
+}
+
+// ConfigReloader reloads the coordinator configuration from a file on SIGHUP.
+// Reload can also be called directly, for example as the admin API reload
+// handler.
+type ConfigReloader struct {
+	coordinator *ALMCoordinator
+	path        string
+	logger      *zap.Logger
+
+	// Counters
+	reloads  atomic.Int64
+	failures atomic.Int64
+}
+
+// NewConfigReloader creates a reloader for the config file at path
+func NewConfigReloader(coordinator *ALMCoordinator, path string, logger *zap.Logger) *ConfigReloader {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &ConfigReloader{
+		coordinator: coordinator,
+		path:        path,
+		logger:      logger,
+	}
+}
+
+// Reload loads the config file and applies it. An invalid file leaves the
+// running configuration unchanged.
+func (cr *ConfigReloader) Reload(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	config, err := LoadALMConfig(cr.path)
+	if err == nil {
+		err = cr.coordinator.ReloadConfig(config)
+	}
+	if err != nil {
+		cr.failures.Add(1)
+		return err
+	}
+
+	cr.reloads.Add(1)
+	return nil
+}
+
+// Run reloads the configuration on every SIGHUP until ctx is cancelled
+func (cr *ConfigReloader) Run(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			cr.logger.Info("Received SIGHUP, reloading configuration", zap.String("path", cr.path))
+			if err := cr.Reload(ctx); err != nil {
+				cr.logger.Error("Configuration reload failed, keeping current configuration",
+					zap.String("path", cr.path),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+}
+
+// Stats returns the number of successful and failed reloads
+func (cr *ConfigReloader) Stats() (reloads, failures int64) {
+	return cr.reloads.Load(), cr.failures.Load()
+}
+
+// applyConfigValues sets config fields from file values and then from
+// ALM_* entries in environ, which take precedence
+func applyConfigValues(config *ALMConfig, values map[string]interface{}, environ []string) error {
+	target := reflect.ValueOf(config).Elem()
+
+	fields := make(map[string]int, target.NumField())
+	for i := 0; i < target.NumField(); i++ {
+		fields[configKey(target.Type().Field(i).Name)] = i
+	}
+
+	var problems []error
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		index, ok := fields[key]
+		if !ok {
+			problems = append(problems, unknownKeyError(key, fields))
+			continue
+		}
+		if err := setConfigField(target.Field(index), values[key]); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+
+	for _, entry := range environ {
+		name, value, _ := strings.Cut(entry, "=")
+		if !strings.HasPrefix(name, ConfigEnvPrefix) {
+			continue
+		}
+		index, ok := fields[strings.ToLower(strings.TrimPrefix(name, ConfigEnvPrefix))]
+		if !ok {
+			continue
+		}
+		if err := setConfigField(target.Field(index), value); err != nil {
+			problems = append(problems, fmt.Errorf("environment variable %s: %w", name, err))
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// setConfigField converts a decoded YAML/TOML value or an environment
+// string to the field's type
+func setConfigField(field reflect.Value, value interface{}) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected a duration such as \"30s\", got %v", value)
+		}
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return fmt.Errorf("expected a duration such as \"30s\", got %q", text)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			field.SetBool(v)
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected true or false, got %q", v)
+			}
+			field.SetBool(b)
+		default:
+			return fmt.Errorf("expected true or false, got %v", value)
+		}
+
+	case reflect.Int, reflect.Int64:
+		switch v := value.(type) {
+		case int:
+			field.SetInt(int64(v))
+		case int64:
+			field.SetInt(v)
+		case float64:
+			if v != float64(int64(v)) {
+				return fmt.Errorf("expected an integer, got %g", v)
+			}
+			field.SetInt(int64(v))
+		case string:
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return fmt.Errorf("expected an integer, got %q", v)
+			}
+			field.SetInt(n)
+		default:
+			return fmt.Errorf("expected an integer, got %v", value)
+		}
+
+	case reflect.Float64:
+		switch v := value.(type) {
+		case int:
+			field.SetFloat(float64(v))
+		case int64:
+			field.SetFloat(float64(v))
+		case float64:
+			field.SetFloat(v)
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("expected a number, got %q", v)
+			}
+			field.SetFloat(f)
+		default:
+			return fmt.Errorf("expected a number, got %v", value)
+		}
+
+	case reflect.Map, reflect.Slice, reflect.Struct:
+		// Structured settings such as feature_flags decode from the file's
+		// maps and lists, or from JSON in environment variables
+		var data []byte
+		if text, ok := value.(string); ok {
+			data = []byte(text)
+		} else {
+			var err error
+			if data, err = json.Marshal(value); err != nil {
+				return fmt.Errorf("unsupported value %v: %w", value, err)
+			}
+		}
+		decoded := reflect.New(field.Type())
+		if err := json.Unmarshal(data, decoded.Interface()); err != nil {
+			return fmt.Errorf("expected %s: %w", field.Type(), err)
+		}
+		field.Set(decoded.Elem())
+
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+
+	return nil
+}
+
+// unknownKeyError reports an unknown key, suggesting the closest known key
+func unknownKeyError(key string, fields map[string]int) error {
+	best, bestDistance := "", 3
+	for known := range fields {
+		if d := editDistance(key, known); d < bestDistance || (d == bestDistance && known < best) {
+			best, bestDistance = known, d
+		}
+	}
+
+	if best != "" {
+		return fmt.Errorf("unknown key %q (did you mean %q?)", key, best)
+	}
+	return fmt.Errorf("unknown key %q", key)
+}
+
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+// configKey converts a field name such as SearchTimeout or STOQIntegration
+// to its config key, search_timeout or stoq_integration
+func configKey(field string) string {
+	if key, ok := configKeyOverrides[field]; ok {
+		return key
+	}
+
+	var key strings.Builder
+	runes := []rune(field)
+	for i, r := range runes {
+		upper := r >= 'A' && r <= 'Z'
+		if upper && i > 0 {
+			previous := runes[i-1]
+			nextLower := i+1 < len(runes) && runes[i+1] >= 'a' && runes[i+1] <= 'z'
+			previousUpper := previous >= 'A' && previous <= 'Z'
+			if !previousUpper || nextLower {
+				key.WriteByte('_')
+			}
+		}
+		key.WriteRune(r)
+	}
+
+	return strings.ToLower(key.String())
+}
@@ -0,0 +1,297 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+// newTestCoordinator creates a coordinator with the default configuration
+// without starting it
+func newTestCoordinator(t *testing.T) *ALMCoordinator {
+	t.Helper()
+
+	alm, err := NewALMCoordinator(DefaultALMConfig(), nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+	return alm
+}
+
+func TestApplyConfigUpdatesComponents(t *testing.T) {
+	alm := newTestCoordinator(t)
+	updates := alm.configUpdates()
+
+	changed, err := alm.ApplyConfig(ConfigDelta{"route_cache_size": 20000, "search_timeout": "500ms"})
+	if err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	if strings.Join(changed, ",") != "search_timeout,route_cache_size" {
+		t.Errorf("changed %v, want search_timeout and route_cache_size", changed)
+	}
+	if routing := alm.routingTable.Config(); routing.CacheSize != 20000 || routing.SearchTimeout.String() != "500ms" {
+		t.Errorf("routing table cache size %d timeout %v, want 20000 and 500ms", routing.CacheSize, routing.SearchTimeout)
+	}
+	select {
+	case <-updates:
+	default:
+		t.Error("loops waiting on configUpdates not woken")
+	}
+
+	// Applying the current values changes nothing
+	if changed, err := alm.ApplyConfig(ConfigDelta{"route_cache_size": 20000}); err != nil || len(changed) != 0 {
+		t.Errorf("reapplying route_cache_size changed %v, %v", changed, err)
+	}
+}
+
+func TestApplyConfigRejectsWholeDelta(t *testing.T) {
+	alm := newTestCoordinator(t)
+	before := alm.Config()
+
+	rejected := map[string]ConfigDelta{
+		`did you mean "route_cache_size"`:   {"route_cache_sise": 10},
+		"search_timeout":                    {"route_cache_size": 20000, "search_timeout": "soon"},
+		"only change on restart: max_nodes": {"route_cache_size": 20000, "max_nodes": 5},
+		"latency_weight":                    {"route_cache_size": 20000, "latency_weight": -1},
+	}
+	for want, delta := range rejected {
+		_, err := alm.ApplyConfig(delta)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ApplyConfig(%v) = %v, want an error mentioning %s", delta, err, want)
+		}
+	}
+
+	if after := alm.Config(); after.RouteCacheSize != before.RouteCacheSize {
+		t.Errorf("route_cache_size %d after rejected changes, want %d", after.RouteCacheSize, before.RouteCacheSize)
+	}
+	if size := alm.routingTable.Config().CacheSize; size != before.RouteCacheSize {
+		t.Errorf("rejected change reached the routing table: cache size %d", size)
+	}
+}
+
+func TestApplyComponentConfigRollsBack(t *testing.T) {
+	alm := newTestCoordinator(t)
+	before := alm.Config()
+
+	// The routing table takes its change before the path cache rejects an
+	// impossible size, so the routing change has to be undone
+	next := before
+	next.RouteCacheSize = before.RouteCacheSize * 2
+	next.SearchTimeout = before.SearchTimeout * 2
+	next.PathCacheSize = -1
+
+	alm.mutex.Lock()
+	err := alm.applyComponentConfig(&next)
+	alm.mutex.Unlock()
+
+	if err == nil || !strings.Contains(err.Error(), "path cache") {
+		t.Fatalf("applyComponentConfig = %v, want a path cache error", err)
+	}
+	if routing := alm.routingTable.Config(); routing.CacheSize != before.RouteCacheSize || routing.SearchTimeout != before.SearchTimeout {
+		t.Errorf("routing cache size %d timeout %v, want %d and %v restored",
+			routing.CacheSize, routing.SearchTimeout, before.RouteCacheSize, before.SearchTimeout)
+	}
+}
+
+func TestConfigValuesRoundTrip(t *testing.T) {
+	alm := newTestCoordinator(t)
+
+	values := alm.ConfigValues()
+	if values["search_timeout"] != DefaultALMConfig().SearchTimeout.String() {
+		t.Errorf("search_timeout = %v, want a duration string", values["search_timeout"])
+	}
+
+	// The values can be passed back unchanged
+	delta := ConfigDelta{"search_timeout": values["search_timeout"], "latency_weight": values["latency_weight"]}
+	if changed, err := alm.ApplyConfig(delta); err != nil || len(changed) != 0 {
+		t.Errorf("ApplyConfig(ConfigValues()) changed %v, %v", changed, err)
+	}
+}
+
+// loadConfig loads content as a YAML config file
+func loadConfig(t *testing.T, content string) (*ALMConfig, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "alm.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return LoadALMConfig(path)
+}
+
+func TestLoadALMConfig(t *testing.T) {
+	config, err := loadConfig(t, "route_cache_size: 1234\nsearch_timeout: 2s\n")
+	if err != nil {
+		t.Fatalf("LoadALMConfig: %v", err)
+	}
+	if config.RouteCacheSize != 1234 || config.SearchTimeout != 2*time.Second {
+		t.Errorf("loaded route_cache_size %d search_timeout %v, want 1234 and 2s", config.RouteCacheSize, config.SearchTimeout)
+	}
+	if config.BeamWidth != DefaultALMConfig().BeamWidth {
+		t.Errorf("beam_width %d, want the default for a missing key", config.BeamWidth)
+	}
+
+	// Environment variables override the file
+	t.Setenv("ALM_ROUTE_CACHE_SIZE", "4321")
+	if config, err = loadConfig(t, "route_cache_size: 1234\n"); err != nil || config.RouteCacheSize != 4321 {
+		t.Errorf("route_cache_size with ALM_ROUTE_CACHE_SIZE set = %v, %v; want 4321", config, err)
+	}
+}
+
+func TestLoadALMConfigTOML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alm.toml")
+	if err := os.WriteFile(path, []byte("route_cache_size = 1234\nlatency_weight = 0.5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadALMConfig(path)
+	if err != nil {
+		t.Fatalf("LoadALMConfig: %v", err)
+	}
+	if config.RouteCacheSize != 1234 || config.LatencyWeight != 0.5 {
+		t.Errorf("loaded route_cache_size %d latency_weight %g, want 1234 and 0.5", config.RouteCacheSize, config.LatencyWeight)
+	}
+}
+
+func TestLoadALMConfigRejects(t *testing.T) {
+	if _, err := loadConfig(t, "serch_timeout: 2s\n"); err == nil || !strings.Contains(err.Error(), `did you mean "search_timeout"`) {
+		t.Errorf("misspelled key: %v", err)
+	}
+	if _, err := loadConfig(t, "beam_width: 0\n"); err == nil || !strings.Contains(err.Error(), "beam_width must be positive") {
+		t.Errorf("zero beam width: %v", err)
+	}
+}
+
+func TestReloadConfigKeepsRestartOnlySettings(t *testing.T) {
+	alm := newTestCoordinator(t)
+	before := alm.Config()
+
+	next := before
+	next.MaxNodes = before.MaxNodes * 2
+	next.RouteCacheTTL = before.RouteCacheTTL + time.Minute
+	if err := alm.ReloadConfig(&next); err != nil {
+		t.Fatalf("ReloadConfig: %v", err)
+	}
+
+	after := alm.Config()
+	if after.MaxNodes != before.MaxNodes {
+		t.Errorf("max_nodes reloaded to %d, want %d kept until restart", after.MaxNodes, before.MaxNodes)
+	}
+	if after.RouteCacheTTL != next.RouteCacheTTL {
+		t.Errorf("route_cache_ttl %v, want %v", after.RouteCacheTTL, next.RouteCacheTTL)
+	}
+}
+
+// TestConfigReloaderWhileRunning reloads while the coordinator's background
+// processes run, for the race detector
+func TestConfigReloaderWhileRunning(t *testing.T) {
+	config := DefaultALMConfig()
+	config.HyperMeshIntegration = false
+	config.STOQIntegration = false
+	config.Layer2Integration = false
+	config.HealthCheckInterval = time.Millisecond
+	config.MetricsInterval = time.Millisecond
+	alm, err := NewALMCoordinator(config, nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+	if err := alm.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer alm.Stop()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "alm.yaml")
+	reloader := NewConfigReloader(alm, path, nil)
+
+	for i := 0; i < 20; i++ {
+		content := fmt.Sprintf("health_check_interval: 1ms\nmetrics_interval: 1ms\nroute_cache_size: %d\nservice_cache_ttl: %dms\n", 1000+i, 100+i)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := reloader.Reload(context.Background()); err != nil {
+			t.Fatalf("Reload %d: %v", i, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if reloads, failures := reloader.Stats(); reloads != 20 || failures != 0 {
+		t.Errorf("%d reloads and %d failures, want 20 and 0", reloads, failures)
+	}
+	if size := alm.Config().RouteCacheSize; size != 1019 {
+		t.Errorf("route_cache_size %d after the last reload, want 1019", size)
+	}
+}
+
+func TestLoadALMConfigConstraintTemplates(t *testing.T) {
+	config, err := loadConfig(t, "constraint_templates:\n  payments:\n    max_latency_ns: 5000000\n    min_reliability: 0.999\n")
+	if err != nil {
+		t.Fatalf("LoadALMConfig: %v", err)
+	}
+	payments := config.ConstraintTemplates["payments"]
+	if payments.MaxLatency != 5*time.Millisecond || payments.MinReliability != 0.999 {
+		t.Errorf("payments template %+v, want 5ms and 0.999", payments)
+	}
+
+	_, err = loadConfig(t, "constraint_templates:\n  payments:\n    max_hops: -1\n")
+	if err == nil || !strings.Contains(err.Error(), "constraint template payments") {
+		t.Errorf("LoadALMConfig(max_hops: -1) = %v, want a constraint template error", err)
+	}
+}
+
+func TestLoadALMConfigDRPlans(t *testing.T) {
+	config, err := loadConfig(t, "dr_plans:\n  us-east:\n    - region: us-west\n      max_flows: 500\n    - region: eu-west\n")
+	if err != nil {
+		t.Fatalf("LoadALMConfig: %v", err)
+	}
+	plan := config.DRPlans["us-east"]
+	if len(plan) != 2 || plan[0].Region != "us-west" || plan[0].MaxFlows != 500 || plan[1].Region != "eu-west" {
+		t.Errorf("us-east plan %+v, want us-west capped at 500 then eu-west", plan)
+	}
+
+	_, err = loadConfig(t, "dr_plans:\n  us-east:\n    - region: us-east\n")
+	if err == nil || !strings.Contains(err.Error(), "cannot fail over to itself") {
+		t.Errorf("LoadALMConfig(us-east to itself) = %v, want a dr plan error", err)
+	}
+}
+
+func TestLoadALMConfigLatencySLOs(t *testing.T) {
+	config, err := loadConfig(t, "latency_slos:\n  - name: low-latency-p999\n    qos_class: 1\n    quantile: 0.999\n    threshold_ns: 2000000\n    window_ns: 604800000000000\n")
+	if err != nil {
+		t.Fatalf("LoadALMConfig: %v", err)
+	}
+	if len(config.LatencySLOs) != 1 {
+		t.Fatalf("loaded %d SLOs, want 1", len(config.LatencySLOs))
+	}
+	if slo := config.LatencySLOs[0]; slo.QoSClass != routing.LowLatency || slo.Threshold != 2*time.Millisecond || slo.Window != 7*24*time.Hour {
+		t.Errorf("SLO %+v, want low latency under 2ms over a week", slo)
+	}
+
+	_, err = loadConfig(t, "latency_slos:\n  - name: p100\n    qos_class: 1\n    quantile: 1\n    threshold_ns: 1000000\n    window_ns: 604800000000000\n")
+	if err == nil || !strings.Contains(err.Error(), "latency SLO p100: quantile") {
+		t.Errorf("LoadALMConfig(quantile: 1) = %v, want a quantile error", err)
+	}
+}
+
+func TestLoadALMConfigEdgeSmoothing(t *testing.T) {
+	config, err := loadConfig(t, "edge_smoothing: 2\nedge_smoothing_window: 5\nedge_outlier_threshold: 0\n")
+	if err != nil {
+		t.Fatalf("LoadALMConfig: %v", err)
+	}
+	want := graph.EdgeSmoothing{Method: graph.MedianSmoothing, Window: 5, Alpha: 0.3}
+	if got := config.edgeSmoothing(); got != want {
+		t.Errorf("edge smoothing %+v, want %+v", got, want)
+	}
+
+	_, err = loadConfig(t, "edge_smoothing_alpha: 0\n")
+	if err == nil || !strings.Contains(err.Error(), "edge smoothing alpha") {
+		t.Errorf("LoadALMConfig(edge_smoothing_alpha: 0) = %v, want an alpha error", err)
+	}
+}
@@ -0,0 +1,144 @@
+// Package internal implements request validation, response conversion and
+// health monitoring for the coordinator
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/service"
+	"go.uber.org/zap"
+)
+
+// minRoutingSuccessRate is the routing success percentage below which
+// health monitoring warns
+const minRoutingSuccessRate = 95.0
+
+// validateRouteRequest checks a route request before it is admitted
+func (alm *ALMCoordinator) validateRouteRequest(request RouteRequest) error {
+	switch {
+	case request.SourceID <= 0 || request.DestinationID <= 0:
+		return fmt.Errorf("node IDs must be positive, got %d and %d", request.SourceID, request.DestinationID)
+	case request.SourceID == request.DestinationID:
+		return errors.New("source and destination must differ")
+	case request.QoSClass < int(routing.BestEffort) || request.QoSClass > int(routing.CriticalMission):
+		return fmt.Errorf("unknown QoS class %d", request.QoSClass)
+	case request.MaxHops < 0:
+		return fmt.Errorf("max hops must not be negative, got %d", request.MaxHops)
+	case request.MaxLatency < 0 || request.MinThroughput < 0 || request.MaxCost < 0:
+		return errors.New("constraints must not be negative")
+	case request.MinReliability < 0 || request.MinReliability > 1:
+		return fmt.Errorf("min reliability must be between 0 and 1, got %g", request.MinReliability)
+	case request.Disjoint < int(graph.NotDisjoint) || request.Disjoint > int(graph.NodeDisjoint):
+		return fmt.Errorf("unknown disjoint mode %d", request.Disjoint)
+	case request.DisjointPaths < 0:
+		return fmt.Errorf("disjoint paths must not be negative, got %d", request.DisjointPaths)
+	}
+	if name := request.ConstraintTemplate; name != "" {
+		if _, ok := alm.routingTable.ConstraintTemplates().Get(name); !ok {
+			return fmt.Errorf("%w %q", routing.ErrUnknownConstraintTemplate, name)
+		}
+	}
+	return nil
+}
+
+// convertPath returns the IDs of the nodes along a route
+func (alm *ALMCoordinator) convertPath(nodes []*graph.NetworkNode) []int64 {
+	path := make([]int64, 0, len(nodes))
+	for _, node := range nodes {
+		path = append(path, node.ID)
+	}
+	return path
+}
+
+// convertAlternatives converts alternative routes to the API form
+func (alm *ALMCoordinator) convertAlternatives(routes []*routing.RouteEntry, weights []float64) []AlternativeRoute {
+	alternatives := make([]AlternativeRoute, 0, len(routes))
+	for i, route := range routes {
+		if route == nil {
+			continue
+		}
+		weight := 0.0
+		if i < len(weights) {
+			weight = weights[i]
+		}
+		alternatives = append(alternatives, AlternativeRoute{
+			Path:        alm.convertPath(route.Path),
+			Latency:     route.Metrics.Latency,
+			Throughput:  route.Metrics.Throughput,
+			Reliability: route.Metrics.Reliability,
+			Cost:        route.Metrics.Cost,
+			Score:       route.QualityScore,
+			Weight:      weight,
+		})
+	}
+	return alternatives
+}
+
+// convertDiscoveredServices converts ranked instances to the API form
+func (alm *ALMCoordinator) convertDiscoveredServices(ranked []*service.RankedService) []DiscoveredService {
+	services := make([]DiscoveredService, 0, len(ranked))
+	for _, rankedService := range ranked {
+		instance := rankedService.Service
+		services = append(services, DiscoveredService{
+			ServiceID:    instance.ID,
+			Name:         instance.Name,
+			NodeID:       instance.NodeID,
+			Address:      instance.Address,
+			Port:         instance.Port,
+			HealthScore:  rankedService.HealthScore,
+			ResponseTime: instance.ResponseTime,
+			Rank:         rankedService.Rank,
+			Score:        rankedService.Score,
+			Distance:     rankedService.Distance,
+		})
+	}
+	return services
+}
+
+// startHealthMonitoring checks the coordinator against its targets every
+// HealthCheckInterval until ctx is done, warning when routing fails or
+// slows past the baseline it is meant to improve on
+func (alm *ALMCoordinator) startHealthMonitoring(ctx context.Context) {
+	interval := alm.Config().HealthCheckInterval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-alm.configUpdates():
+			if next := alm.Config().HealthCheckInterval; next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+		case <-ticker.C:
+			alm.checkHealth()
+		}
+	}
+}
+
+// checkHealth logs the routing figures that miss their targets
+func (alm *ALMCoordinator) checkHealth() {
+	config := alm.Config()
+
+	if rate := alm.metricsCollector.GetRoutingSuccessRate(); rate < minRoutingSuccessRate {
+		alm.logger.Warn("Routing success rate below target",
+			zap.Float64("success_rate", rate),
+			zap.Float64("target", minRoutingSuccessRate),
+		)
+	}
+
+	baseline := time.Duration(config.BaselineLatencyMs * float64(time.Millisecond))
+	if latency := alm.metricsCollector.GetAverageRoutingLatency(); baseline > 0 && latency > baseline {
+		alm.logger.Warn("Routing latency above baseline",
+			zap.Duration("average_latency", latency),
+			zap.Duration("baseline", baseline),
+		)
+	}
+}
@@ -0,0 +1,232 @@
+// Package internal implements the time series behind the dashboard API
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+// Dashboard series. P99 latency has one series per QoS class, named
+// DashboardP99Latency followed by the class, such as
+// "p99_latency_ms.critical_mission".
+const (
+	DashboardImprovementFactor = "improvement_factor"
+	DashboardCacheHitRate      = "cache_hit_rate"
+	DashboardDiscoveryLatency  = "discovery_latency_ms"
+	DashboardP99Latency        = "p99_latency_ms."
+)
+
+// DashboardSample is one reading of the dashboard series
+type DashboardSample struct {
+	Time              time.Time
+	ImprovementFactor float64
+	CacheHitRate      float64
+	DiscoveryLatency  time.Duration
+
+	// P99 route lookup latency, indexed by QoS class
+	P99Latency [admissionClasses]time.Duration
+}
+
+// DashboardPoint is the value of a series at a time
+type DashboardPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+// DashboardRecorder samples the improvement factor, cache hit rate,
+// discovery latency and P99 lookup latency by QoS class every interval and
+// keeps them for the retention period, so dashboards can chart recent
+// history without a Prometheus server
+type DashboardRecorder struct {
+	interval time.Duration
+	sample   func() DashboardSample
+
+	mutex sync.RWMutex
+
+	// Ring of samples; next is the slot written next and full reports
+	// whether the ring has wrapped
+	samples []DashboardSample
+	next    int
+	full    bool
+}
+
+// NewDashboardRecorder creates a recorder that calls sample every interval,
+// keeping retention of history
+func NewDashboardRecorder(interval, retention time.Duration, sample func() DashboardSample) *DashboardRecorder {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	size := int(retention / interval)
+	if size < 1 {
+		size = 1
+	}
+
+	return &DashboardRecorder{
+		interval: interval,
+		sample:   sample,
+		samples:  make([]DashboardSample, size),
+	}
+}
+
+// Run records a sample every interval until ctx is cancelled
+func (dr *DashboardRecorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(dr.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sample := dr.sample()
+			sample.Time = now
+			dr.Record(sample)
+		}
+	}
+}
+
+// Record adds a sample, replacing the oldest once retention is reached.
+// Samples must be recorded in time order.
+func (dr *DashboardRecorder) Record(sample DashboardSample) {
+	dr.mutex.Lock()
+	defer dr.mutex.Unlock()
+
+	dr.samples[dr.next] = sample
+	dr.next++
+	if dr.next == len(dr.samples) {
+		dr.next = 0
+		dr.full = true
+	}
+}
+
+// Series returns the names of the recorded series
+func (dr *DashboardRecorder) Series() []string {
+	series := []string{DashboardImprovementFactor, DashboardCacheHitRate, DashboardDiscoveryLatency}
+	for class := 0; class < admissionClasses; class++ {
+		series = append(series, DashboardP99Latency+routing.QoSClass(class).String())
+	}
+	return series
+}
+
+// Query returns the points of a series between from and to, inclusive.
+// When there are more than maxPoints, consecutive points are consolidated
+// to at most maxPoints: by their maximum for latency quantiles and their
+// mean otherwise. A maxPoints of zero returns every point.
+func (dr *DashboardRecorder) Query(series string, from, to time.Time, maxPoints int) ([]DashboardPoint, error) {
+	value, err := dashboardValue(series)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := dr.samplesBetween(from, to)
+	points := make([]DashboardPoint, len(samples))
+	for i, sample := range samples {
+		points[i] = DashboardPoint{Time: sample.Time, Value: value(sample)}
+	}
+
+	if maxPoints <= 0 || len(points) <= maxPoints {
+		return points, nil
+	}
+	return consolidate(points, maxPoints, strings.HasPrefix(series, DashboardP99Latency)), nil
+}
+
+// samplesBetween returns the samples from from to to in time order
+func (dr *DashboardRecorder) samplesBetween(from, to time.Time) []DashboardSample {
+	dr.mutex.RLock()
+	defer dr.mutex.RUnlock()
+
+	ordered := dr.samples[:dr.next]
+	if dr.full {
+		ordered = append(append([]DashboardSample(nil), dr.samples[dr.next:]...), ordered...)
+	}
+
+	start := sort.Search(len(ordered), func(i int) bool { return !ordered[i].Time.Before(from) })
+	end := sort.Search(len(ordered), func(i int) bool { return ordered[i].Time.After(to) })
+	if start >= end {
+		return nil
+	}
+	return append([]DashboardSample(nil), ordered[start:end]...)
+}
+
+// dashboardValue returns the function reading a series from a sample
+func dashboardValue(series string) (func(DashboardSample) float64, error) {
+	switch series {
+	case DashboardImprovementFactor:
+		return func(s DashboardSample) float64 { return s.ImprovementFactor }, nil
+	case DashboardCacheHitRate:
+		return func(s DashboardSample) float64 { return s.CacheHitRate }, nil
+	case DashboardDiscoveryLatency:
+		return func(s DashboardSample) float64 { return milliseconds(s.DiscoveryLatency) }, nil
+	}
+
+	if name, ok := strings.CutPrefix(series, DashboardP99Latency); ok {
+		for class := 0; class < admissionClasses; class++ {
+			if routing.QoSClass(class).String() == name {
+				return func(s DashboardSample) float64 { return milliseconds(s.P99Latency[class]) }, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unknown dashboard series %q", series)
+}
+
+// consolidate merges consecutive points into at most maxPoints, each
+// taking the time of the last point merged
+func consolidate(points []DashboardPoint, maxPoints int, useMax bool) []DashboardPoint {
+	per := (len(points) + maxPoints - 1) / maxPoints
+	merged := make([]DashboardPoint, 0, maxPoints)
+	for start := 0; start < len(points); start += per {
+		group := points[start:min(start+per, len(points))]
+
+		value := group[0].Value
+		for _, point := range group[1:] {
+			if useMax {
+				value = max(value, point.Value)
+			} else {
+				value += point.Value
+			}
+		}
+		if !useMax {
+			value /= float64(len(group))
+		}
+		merged = append(merged, DashboardPoint{Time: group[len(group)-1].Time, Value: value})
+	}
+	return merged
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// newDashboardRecorder samples the coordinator's request metrics every
+// metrics interval
+func (alm *ALMCoordinator) newDashboardRecorder() *DashboardRecorder {
+	sample := func() DashboardSample {
+		// The baseline latency comes from the reloadable configuration
+		alm.mutex.RLock()
+		improvement := alm.calculateImprovementFactor()
+		alm.mutex.RUnlock()
+
+		sample := DashboardSample{
+			ImprovementFactor: improvement,
+			CacheHitRate:      alm.metricsCollector.GetCacheHitRate(),
+			DiscoveryLatency:  alm.metricsCollector.GetServiceDiscoveryLatency(),
+		}
+		for class := range sample.P99Latency {
+			sample.P99Latency[class] = alm.metricsCollector.GetRoutingLatencyQuantile(routing.QoSClass(class), 0.99)
+		}
+		return sample
+	}
+	return NewDashboardRecorder(alm.config.MetricsInterval, alm.config.DashboardRetention, sample)
+}
+
+// Dashboard returns the recorder of the dashboard time series
+func (alm *ALMCoordinator) Dashboard() *DashboardRecorder {
+	return alm.dashboard
+}
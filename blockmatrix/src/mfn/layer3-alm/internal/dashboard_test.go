@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+func TestDashboardRecorderKeepsRetention(t *testing.T) {
+	recorder := NewDashboardRecorder(time.Second, 4*time.Second, nil)
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < 6; i++ {
+		recorder.Record(DashboardSample{Time: start.Add(time.Duration(i) * time.Second), CacheHitRate: float64(i)})
+	}
+
+	points, err := recorder.Query(DashboardCacheHitRate, start, start.Add(time.Hour), 0)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	var values []float64
+	for _, point := range points {
+		values = append(values, point.Value)
+	}
+	if want := []float64{2, 3, 4, 5}; !slices.Equal(values, want) {
+		t.Errorf("values %v, want the last four %v", values, want)
+	}
+
+	points, _ = recorder.Query(DashboardCacheHitRate, start.Add(3*time.Second), start.Add(4*time.Second), 0)
+	if len(points) != 2 || !points[0].Time.Equal(start.Add(3*time.Second)) {
+		t.Errorf("range query returned %v, want the samples at 3s and 4s", points)
+	}
+}
+
+func TestDashboardRecorderConsolidates(t *testing.T) {
+	recorder := NewDashboardRecorder(time.Second, time.Minute, nil)
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < 6; i++ {
+		sample := DashboardSample{Time: start.Add(time.Duration(i) * time.Second), ImprovementFactor: float64(i)}
+		sample.P99Latency[routing.CriticalMission] = time.Duration(i) * time.Millisecond
+		recorder.Record(sample)
+	}
+
+	points, err := recorder.Query(DashboardImprovementFactor, start, start.Add(time.Minute), 3)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(points) != 3 || points[0].Value != 0.5 || points[2].Value != 4.5 || !points[2].Time.Equal(start.Add(5*time.Second)) {
+		t.Errorf("improvement factor consolidated to %v, want means of pairs", points)
+	}
+
+	points, err = recorder.Query(DashboardP99Latency+"critical_mission", start, start.Add(time.Minute), 2)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(points) != 2 || points[0].Value != 2 || points[1].Value != 5 {
+		t.Errorf("P99 consolidated to %v, want maxima 2 and 5", points)
+	}
+}
+
+func TestDashboardRecorderSeries(t *testing.T) {
+	recorder := NewDashboardRecorder(time.Second, time.Minute, nil)
+	for _, series := range recorder.Series() {
+		if _, err := recorder.Query(series, time.Time{}, time.Now(), 0); err != nil {
+			t.Errorf("Query(%q): %v", series, err)
+		}
+	}
+	if _, err := recorder.Query(DashboardP99Latency+"bulk", time.Time{}, time.Now(), 0); err == nil || !strings.Contains(err.Error(), "unknown dashboard series") {
+		t.Errorf("unknown series: err %v", err)
+	}
+}
+
+func TestMetricsCollectorLatencyByClass(t *testing.T) {
+	collector := NewMetricsCollector(time.Minute)
+	for i := 1; i <= 100; i++ {
+		collector.RecordRouting(&RouteResponse{SearchTime: time.Duration(i) * time.Microsecond}, routing.BestEffort)
+	}
+	collector.RecordRouting(&RouteResponse{SearchTime: time.Millisecond}, routing.CriticalMission)
+
+	if p99 := collector.GetRoutingLatencyQuantile(routing.BestEffort, 0.99); p99 < 98*time.Microsecond || p99 > 100*time.Microsecond {
+		t.Errorf("best effort P99 %s, want about 99µs", p99)
+	}
+	if p99 := collector.GetRoutingLatencyQuantile(routing.CriticalMission, 0.99); p99 < 999*time.Microsecond || p99 > 1001*time.Microsecond {
+		t.Errorf("critical P99 %s, want 1ms", p99)
+	}
+	if p99 := collector.GetRoutingLatencyQuantile(routing.LowLatency, 0.99); p99 != 0 {
+		t.Errorf("low latency P99 %s without lookups, want 0", p99)
+	}
+}
@@ -0,0 +1,543 @@
+// Package internal implements fault injection for exercising degradation and failover
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+	"go.uber.org/zap"
+)
+
+// ErrInjectedFault matches every error returned by a FaultError fault
+var ErrInjectedFault = errors.New("injected fault")
+
+// ErrFaultInjectionDisabled is returned when faults are injected while the
+// FaultInjection setting is off
+var ErrFaultInjectionDisabled = errors.New("fault injection is disabled")
+
+// FaultKind identifies what a fault does
+type FaultKind string
+
+const (
+	// FaultLatency delays calls into a component by Latency plus up to Jitter
+	FaultLatency FaultKind = "latency"
+
+	// FaultError fails calls into a component with Message
+	FaultError FaultKind = "error"
+
+	// FaultCorruption rewrites cached routes every Interval so they claim a
+	// direct link to their destination that may not exist
+	FaultCorruption FaultKind = "corruption"
+
+	// FaultFlap takes the link From -> To down and back up every Interval
+	FaultFlap FaultKind = "flap"
+)
+
+// Components faults can target
+const (
+	FaultRouteLookup      = "route_lookup"
+	FaultServiceDiscovery = "service_discovery"
+	FaultTopologyUpdate   = "topology_update"
+	FaultReplication      = "replication"
+	FaultRouteCache       = "route_cache"
+	FaultTopology         = "topology"
+)
+
+// faultComponents lists the components each kind can target
+var faultComponents = map[FaultKind][]string{
+	FaultLatency:    {FaultRouteLookup, FaultServiceDiscovery, FaultTopologyUpdate, FaultReplication},
+	FaultError:      {FaultRouteLookup, FaultServiceDiscovery, FaultTopologyUpdate, FaultReplication},
+	FaultCorruption: {FaultRouteCache},
+	FaultFlap:       {FaultTopology},
+}
+
+// faultTick is how often periodic faults and expiry are checked
+const faultTick = 100 * time.Millisecond
+
+// Fault describes an injected fault
+type Fault struct {
+	// Assigned by Inject
+	ID string
+
+	Kind      FaultKind
+	Component string
+
+	// Chance that a call is affected, or that a cached route is corrupted
+	// each Interval; zero means always
+	Probability float64
+
+	// FaultLatency
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// FaultError message; defaults to "injected fault"
+	Message string
+
+	// FaultFlap link
+	From int64
+	To   int64
+
+	// Period of FaultCorruption and FaultFlap
+	Interval time.Duration
+
+	// The fault is removed after Duration; zero keeps it until removed
+	Duration time.Duration
+
+	// Set by Inject
+	CreatedAt time.Time
+	ExpiresAt time.Time
+
+	// Calls, routes or flaps affected so far
+	Triggered int64
+}
+
+// Validate checks that the fault can be injected
+func (f *Fault) Validate() error {
+	components, ok := faultComponents[f.Kind]
+	if !ok {
+		return fmt.Errorf("unknown fault kind %q", f.Kind)
+	}
+
+	valid := false
+	for _, component := range components {
+		valid = valid || component == f.Component
+	}
+
+	var problems []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(valid, "%s faults apply to %v, not %q", f.Kind, components, f.Component)
+	check(f.Probability >= 0 && f.Probability <= 1, "probability must be in [0, 1], got %g", f.Probability)
+	check(f.Duration >= 0, "duration must not be negative, got %s", f.Duration)
+
+	switch f.Kind {
+	case FaultLatency:
+		check(f.Latency > 0, "latency faults need a positive latency, got %s", f.Latency)
+		check(f.Jitter >= 0, "jitter must not be negative, got %s", f.Jitter)
+	case FaultCorruption:
+		check(f.Interval > 0, "corruption faults need a positive interval, got %s", f.Interval)
+	case FaultFlap:
+		check(f.Interval > 0, "flap faults need a positive interval, got %s", f.Interval)
+		check(f.From != f.To, "flap faults need a link between two nodes, got %d -> %d", f.From, f.To)
+	}
+
+	return errors.Join(problems...)
+}
+
+// activeFault is an injected fault and its state
+type activeFault struct {
+	fault     Fault
+	triggered atomic.Int64
+
+	// Periodic faults: when the fault next fires
+	nextRun time.Time
+
+	// FaultFlap: the link's metrics while up, set while it is down
+	down    bool
+	restore graph.EdgeMetrics
+
+	// Set once the fault is removed, so an in-progress flap restores the link
+	removed bool
+}
+
+// FaultInjector injects artificial latency, failures, route cache
+// corruption and link flaps into the coordinator so operators can check that
+// degradation and failover behave as intended. It does nothing unless the
+// FaultInjection setting is on; turning it off removes every fault.
+type FaultInjector struct {
+	coordinator *ALMCoordinator
+	logger      *zap.Logger
+
+	enabled atomic.Bool
+
+	// Set while any fault is injected, so calls skip the lock otherwise
+	active atomic.Bool
+
+	faults map[string]*activeFault
+	nextID int64
+	mutex  sync.Mutex
+}
+
+// NewFaultInjector creates a fault injector for coordinator
+func NewFaultInjector(coordinator *ALMCoordinator, enabled bool, logger *zap.Logger) *FaultInjector {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	fi := &FaultInjector{
+		coordinator: coordinator,
+		logger:      logger,
+		faults:      make(map[string]*activeFault),
+	}
+	fi.enabled.Store(enabled)
+	return fi
+}
+
+// Enabled reports whether faults can be injected
+func (fi *FaultInjector) Enabled() bool {
+	return fi.enabled.Load()
+}
+
+// SetEnabled turns fault injection on or off. Turning it off stops every
+// fault at once; Run then removes them and brings flapped links back up. It
+// may be called with the coordinator lock held.
+func (fi *FaultInjector) SetEnabled(enabled bool) {
+	fi.enabled.Store(enabled)
+}
+
+// Inject adds fault and returns it with its ID and timestamps set
+func (fi *FaultInjector) Inject(fault Fault) (Fault, error) {
+	if !fi.Enabled() {
+		return Fault{}, ErrFaultInjectionDisabled
+	}
+	if err := fault.Validate(); err != nil {
+		return Fault{}, fmt.Errorf("invalid fault: %w", err)
+	}
+
+	if fault.Kind == FaultFlap {
+		if _, exists := fi.coordinator.networkGraph.GetEdge(fault.From, fault.To); !exists {
+			return Fault{}, fmt.Errorf("invalid fault: no link %d -> %d", fault.From, fault.To)
+		}
+	}
+
+	now := time.Now()
+	fault.CreatedAt = now
+	fault.ExpiresAt = time.Time{}
+	fault.Triggered = 0
+	if fault.Duration > 0 {
+		fault.ExpiresAt = now.Add(fault.Duration)
+	}
+
+	fi.mutex.Lock()
+	fi.nextID++
+	fault.ID = "fault-" + strconv.FormatInt(fi.nextID, 10)
+	fi.faults[fault.ID] = &activeFault{fault: fault, nextRun: now}
+	fi.active.Store(true)
+	fi.mutex.Unlock()
+
+	fi.logger.Warn("Fault injected",
+		zap.String("id", fault.ID),
+		zap.String("kind", string(fault.Kind)),
+		zap.String("component", fault.Component),
+		zap.Duration("duration", fault.Duration),
+	)
+
+	return fault, nil
+}
+
+// Remove removes the fault with id, returning false if there is none
+func (fi *FaultInjector) Remove(id string) bool {
+	fi.mutex.Lock()
+	active, exists := fi.faults[id]
+	if exists {
+		delete(fi.faults, id)
+		fi.active.Store(len(fi.faults) > 0)
+	}
+	fi.mutex.Unlock()
+
+	if !exists {
+		return false
+	}
+
+	fi.retire(active)
+	fi.logger.Info("Fault removed", zap.String("id", id))
+	return true
+}
+
+// Clear removes every fault and returns how many there were
+func (fi *FaultInjector) Clear() int {
+	fi.mutex.Lock()
+	faults := fi.faults
+	fi.faults = make(map[string]*activeFault)
+	fi.active.Store(false)
+	fi.mutex.Unlock()
+
+	for _, active := range faults {
+		fi.retire(active)
+	}
+	if len(faults) > 0 {
+		fi.logger.Info("Faults cleared", zap.Int("removed", len(faults)))
+	}
+	return len(faults)
+}
+
+// Faults returns the injected faults ordered by ID
+func (fi *FaultInjector) Faults() []Fault {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+
+	faults := make([]Fault, 0, len(fi.faults))
+	for _, active := range fi.faults {
+		fault := active.fault
+		fault.Triggered = active.triggered.Load()
+		faults = append(faults, fault)
+	}
+	sort.Slice(faults, func(i, j int) bool {
+		return faults[i].CreatedAt.Before(faults[j].CreatedAt) ||
+			(faults[i].CreatedAt.Equal(faults[j].CreatedAt) && faults[i].ID < faults[j].ID)
+	})
+	return faults
+}
+
+// Intercept applies the latency and error faults targeting component to a
+// call into it. It returns ctx's error if ctx is done while delayed, or an
+// error matching ErrInjectedFault if the call should fail.
+func (fi *FaultInjector) Intercept(ctx context.Context, component string) error {
+	if !fi.active.Load() || !fi.enabled.Load() {
+		return nil
+	}
+
+	var delay time.Duration
+	var failure error
+
+	now := time.Now()
+	fi.mutex.Lock()
+	for _, active := range fi.faults {
+		fault := &active.fault
+		if fault.Component != component || fault.expired(now) || !fault.fires() {
+			continue
+		}
+
+		switch fault.Kind {
+		case FaultLatency:
+			active.triggered.Add(1)
+			delay += fault.Latency
+			if fault.Jitter > 0 {
+				delay += time.Duration(rand.Int63n(int64(fault.Jitter)))
+			}
+		case FaultError:
+			if failure == nil {
+				active.triggered.Add(1)
+				failure = fault.err()
+			}
+		}
+	}
+	fi.mutex.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return failure
+}
+
+// Run fires periodic faults and removes expired ones until ctx is done, then
+// brings flapped links back up
+func (fi *FaultInjector) Run(ctx context.Context) {
+	ticker := time.NewTicker(faultTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			fi.Clear()
+			return
+		case <-ticker.C:
+			if !fi.active.Load() {
+				continue
+			}
+			if !fi.enabled.Load() {
+				fi.Clear()
+				continue
+			}
+			fi.tick(time.Now())
+		}
+	}
+}
+
+// tick removes expired faults and fires the periodic faults that are due
+func (fi *FaultInjector) tick(now time.Time) {
+	var expired, due []*activeFault
+
+	fi.mutex.Lock()
+	for id, active := range fi.faults {
+		if active.fault.expired(now) {
+			delete(fi.faults, id)
+			expired = append(expired, active)
+			continue
+		}
+
+		periodic := active.fault.Kind == FaultCorruption || active.fault.Kind == FaultFlap
+		if periodic && !now.Before(active.nextRun) {
+			active.nextRun = now.Add(active.fault.Interval)
+			due = append(due, active)
+		}
+	}
+	fi.active.Store(len(fi.faults) > 0)
+	fi.mutex.Unlock()
+
+	for _, active := range expired {
+		fi.retire(active)
+		fi.logger.Info("Fault expired", zap.String("id", active.fault.ID))
+	}
+
+	for _, active := range due {
+		switch active.fault.Kind {
+		case FaultCorruption:
+			fi.corruptRoutes(active)
+		case FaultFlap:
+			fi.flap(active)
+		}
+	}
+}
+
+// retire undoes a removed fault's lasting effects
+func (fi *FaultInjector) retire(active *activeFault) {
+	if active.fault.Kind != FaultFlap {
+		return
+	}
+
+	fi.mutex.Lock()
+	down, restore := active.down, active.restore
+	active.down = false
+	active.removed = true
+	fi.mutex.Unlock()
+
+	if down {
+		fi.setLink(active, restore)
+	}
+}
+
+// corruptRoutes rewrites cached routes so they claim a direct, perfect link
+// from their source to their destination
+func (fi *FaultInjector) corruptRoutes(active *activeFault) {
+	routingTable := fi.coordinator.routingTable
+
+	corrupted := make(map[string]*routing.RouteEntry)
+	for key, route := range routingTable.ExportRoutes() {
+		if len(route.Path) < 3 || !active.fault.fires() {
+			continue
+		}
+
+		entry := *route
+		entry.Path = []*graph.NetworkNode{route.Path[0], route.Path[len(route.Path)-1]}
+		entry.NextHop = route.Destination
+		entry.Metrics.HopCount = 1
+		entry.Metrics.Latency = 0
+		entry.Metrics.Reliability = 1
+		entry.QualityScore = 1
+		entry.Confidence = 1
+		corrupted[key] = &entry
+	}
+	if len(corrupted) == 0 {
+		return
+	}
+
+	routingTable.ImportRoutes(corrupted)
+	active.triggered.Add(int64(len(corrupted)))
+
+	fi.logger.Warn("Injected route cache corruption",
+		zap.String("id", active.fault.ID),
+		zap.Int("routes", len(corrupted)),
+	)
+}
+
+// flap takes the fault's link down if it is up, or restores it if it is down
+func (fi *FaultInjector) flap(active *activeFault) {
+	fi.mutex.Lock()
+	down, restore, removed := active.down, active.restore, active.removed
+	fi.mutex.Unlock()
+
+	if removed {
+		return
+	}
+	if down {
+		if fi.setLink(active, restore) {
+			fi.mutex.Lock()
+			active.down = false
+			fi.mutex.Unlock()
+		}
+		return
+	}
+
+	edge, exists := fi.coordinator.networkGraph.GetEdge(active.fault.From, active.fault.To)
+	if !exists {
+		return
+	}
+	restore = graph.EdgeMetrics{
+		Latency:     edge.Latency,
+		Bandwidth:   edge.Bandwidth,
+		PacketLoss:  edge.PacketLoss,
+		Jitter:      edge.Jitter,
+		Reliability: edge.Reliability,
+	}
+
+	failed := restore
+	failed.PacketLoss = 1
+	failed.Reliability = 0
+	if fi.setLink(active, failed) {
+		fi.mutex.Lock()
+		active.down = true
+		active.restore = restore
+		removed = active.removed
+		fi.mutex.Unlock()
+		active.triggered.Add(1)
+
+		// Removed while the link was going down
+		if removed {
+			fi.retire(active)
+		}
+	}
+}
+
+// setLink sets the metrics of the fault's link through the coordinator, so
+// the change is logged, replicated and invalidates routes like a real one
+func (fi *FaultInjector) setLink(active *activeFault, metrics graph.EdgeMetrics) bool {
+	err := fi.coordinator.UpdateNetworkTopology([]TopologyUpdate{{
+		Type:        EdgeMetricsUpdate,
+		EdgeFrom:    active.fault.From,
+		EdgeTo:      active.fault.To,
+		EdgeMetrics: metrics,
+	}})
+	if err != nil {
+		fi.logger.Warn("Failed to flap link",
+			zap.String("id", active.fault.ID),
+			zap.Int64("from", active.fault.From),
+			zap.Int64("to", active.fault.To),
+			zap.Error(err),
+		)
+		return false
+	}
+	return true
+}
+
+func (f *Fault) expired(now time.Time) bool {
+	return !f.ExpiresAt.IsZero() && !now.Before(f.ExpiresAt)
+}
+
+// fires reports whether the fault affects one call or route
+func (f *Fault) fires() bool {
+	return f.Probability == 0 || rand.Float64() < f.Probability
+}
+
+func (f *Fault) err() error {
+	if f.Message == "" {
+		return fmt.Errorf("%w (%s)", ErrInjectedFault, f.ID)
+	}
+	return fmt.Errorf("%s (%s): %w", f.Message, f.ID, ErrInjectedFault)
+}
+
+// Faults returns the coordinator's fault injector
+func (alm *ALMCoordinator) Faults() *FaultInjector {
+	return alm.faults
+}
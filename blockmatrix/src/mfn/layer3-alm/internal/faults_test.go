@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFaultValidate(t *testing.T) {
+	valid := []Fault{
+		{Kind: FaultLatency, Component: FaultRouteLookup, Latency: time.Millisecond},
+		{Kind: FaultError, Component: FaultReplication, Probability: 0.5},
+		{Kind: FaultCorruption, Component: FaultRouteCache, Interval: time.Second},
+		{Kind: FaultFlap, Component: FaultTopology, From: 1, To: 2, Interval: time.Second},
+	}
+	for _, fault := range valid {
+		if err := fault.Validate(); err != nil {
+			t.Errorf("Validate(%+v): %v", fault, err)
+		}
+	}
+
+	invalid := []Fault{
+		{Kind: "partition", Component: FaultTopology},
+		{Kind: FaultError, Component: FaultRouteCache},
+		{Kind: FaultError, Component: FaultRouteLookup, Probability: 1.5},
+		{Kind: FaultError, Component: FaultRouteLookup, Duration: -time.Second},
+		{Kind: FaultLatency, Component: FaultRouteLookup},
+		{Kind: FaultCorruption, Component: FaultRouteCache},
+		{Kind: FaultFlap, Component: FaultTopology, From: 1, To: 1, Interval: time.Second},
+	}
+	for _, fault := range invalid {
+		if err := fault.Validate(); err == nil {
+			t.Errorf("Validate(%+v) accepted an invalid fault", fault)
+		}
+	}
+}
+
+func TestFaultInjectionDisabled(t *testing.T) {
+	injector := NewFaultInjector(newTestCoordinator(t), false, nil)
+
+	_, err := injector.Inject(Fault{Kind: FaultError, Component: FaultRouteLookup})
+	if !errors.Is(err, ErrFaultInjectionDisabled) {
+		t.Errorf("Inject while disabled = %v, want ErrFaultInjectionDisabled", err)
+	}
+}
+
+func TestFaultInterceptFailsTargetedComponent(t *testing.T) {
+	injector := NewFaultInjector(newTestCoordinator(t), true, nil)
+
+	fault, err := injector.Inject(Fault{Kind: FaultError, Component: FaultRouteLookup, Message: "lookup down"})
+	if err != nil {
+		t.Fatalf("Inject: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := injector.Intercept(ctx, FaultRouteLookup); !errors.Is(err, ErrInjectedFault) {
+		t.Errorf("Intercept(route lookup) = %v, want an injected fault", err)
+	}
+	if err := injector.Intercept(ctx, FaultServiceDiscovery); err != nil {
+		t.Errorf("Intercept(service discovery) = %v, want no fault on another component", err)
+	}
+	if faults := injector.Faults(); len(faults) != 1 || faults[0].Triggered != 1 {
+		t.Errorf("Faults() = %+v, want one fault triggered once", faults)
+	}
+
+	// Disabling stops the fault at once
+	injector.SetEnabled(false)
+	if err := injector.Intercept(ctx, FaultRouteLookup); err != nil {
+		t.Errorf("Intercept while disabled = %v", err)
+	}
+	injector.SetEnabled(true)
+
+	if !injector.Remove(fault.ID) || injector.Remove(fault.ID) {
+		t.Error("Remove did not remove the fault exactly once")
+	}
+	if err := injector.Intercept(ctx, FaultRouteLookup); err != nil {
+		t.Errorf("Intercept after Remove = %v", err)
+	}
+}
+
+func TestFaultInterceptDelays(t *testing.T) {
+	injector := NewFaultInjector(newTestCoordinator(t), true, nil)
+	if _, err := injector.Inject(Fault{Kind: FaultLatency, Component: FaultTopologyUpdate, Latency: 20 * time.Millisecond}); err != nil {
+		t.Fatalf("Inject: %v", err)
+	}
+
+	start := time.Now()
+	if err := injector.Intercept(context.Background(), FaultTopologyUpdate); err != nil {
+		t.Fatalf("Intercept: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Intercept returned after %v, want at least the 20ms latency", elapsed)
+	}
+
+	// The caller's deadline cuts a long delay short
+	if _, err := injector.Inject(Fault{Kind: FaultLatency, Component: FaultTopologyUpdate, Latency: time.Hour}); err != nil {
+		t.Fatalf("Inject: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := injector.Intercept(ctx, FaultTopologyUpdate); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Intercept with a short deadline = %v, want the context's error", err)
+	}
+}
+
+func TestFaultExpires(t *testing.T) {
+	injector := NewFaultInjector(newTestCoordinator(t), true, nil)
+	fault, err := injector.Inject(Fault{Kind: FaultError, Component: FaultReplication, Duration: time.Minute})
+	if err != nil {
+		t.Fatalf("Inject: %v", err)
+	}
+
+	injector.tick(fault.ExpiresAt.Add(-time.Second))
+	if len(injector.Faults()) != 1 {
+		t.Fatal("fault removed before it expired")
+	}
+	injector.tick(fault.ExpiresAt)
+	if len(injector.Faults()) != 0 {
+		t.Error("fault kept after it expired")
+	}
+	if err := injector.Intercept(context.Background(), FaultReplication); err != nil {
+		t.Errorf("Intercept after expiry = %v", err)
+	}
+}
+
+func TestFaultFlapRestoresLink(t *testing.T) {
+	alm := newDiamondCoordinator(t)
+	injector := alm.Faults()
+	injector.SetEnabled(true)
+
+	fault, err := injector.Inject(Fault{Kind: FaultFlap, Component: FaultTopology, From: 1, To: 2, Interval: time.Minute})
+	if err != nil {
+		t.Fatalf("Inject: %v", err)
+	}
+	if _, err := injector.Inject(Fault{Kind: FaultFlap, Component: FaultTopology, From: 1, To: 4, Interval: time.Minute}); err == nil {
+		t.Error("Inject accepted a flap on a link that does not exist")
+	}
+
+	linkDown := func() bool {
+		edge, _ := alm.networkGraph.GetEdge(1, 2)
+		return edge.PacketLoss == 1
+	}
+
+	now := time.Now()
+	injector.tick(now)
+	if !linkDown() {
+		t.Fatal("link still up after the flap fired")
+	}
+
+	injector.tick(now.Add(time.Minute))
+	if linkDown() {
+		t.Fatal("link still down a flap interval later")
+	}
+
+	// Removing the fault while the link is down brings it back up
+	injector.tick(now.Add(2 * time.Minute))
+	if !linkDown() {
+		t.Fatal("link up after the second flap")
+	}
+	injector.Remove(fault.ID)
+	if linkDown() {
+		t.Error("link left down after its fault was removed")
+	}
+}
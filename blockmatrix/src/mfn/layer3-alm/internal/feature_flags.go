@@ -0,0 +1,281 @@
+// Package internal implements feature flags gating experimental routing behavior
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Well-known feature flags
+const (
+	// FeatureHedging gates hedged copies of idempotent requests
+	FeatureHedging = "hedging"
+
+	// FeaturePredictiveCircuitBreaking gates circuit decisions made from
+	// predicted service health rather than observed failures
+	FeaturePredictiveCircuitBreaking = "predictive_circuit_breaking"
+)
+
+// DefaultTenant is the tenant of contexts that carry none
+const DefaultTenant = "default"
+
+// FeatureFlag turns a feature on for some tenants and a share of traffic.
+// A disabled flag is off everywhere. Otherwise the feature is off for
+// ExcludedTenants, on for Tenants, and on for Percentage percent of the
+// remaining requests.
+type FeatureFlag struct {
+	Name            string   `json:"name" yaml:"name"`
+	Description     string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Enabled         bool     `json:"enabled" yaml:"enabled"`
+	Percentage      float64  `json:"percentage" yaml:"percentage"`
+	Tenants         []string `json:"tenants,omitempty" yaml:"tenants,omitempty"`
+	ExcludedTenants []string `json:"excluded_tenants,omitempty" yaml:"excluded_tenants,omitempty"`
+}
+
+// Validate checks the flag
+func (f *FeatureFlag) Validate() error {
+	if f.Name == "" {
+		return errors.New("feature flag name is required")
+	}
+	if f.Percentage < 0 || f.Percentage > 100 {
+		return fmt.Errorf("feature flag %s: percentage must be between 0 and 100, got %g", f.Name, f.Percentage)
+	}
+	return nil
+}
+
+// FeatureFlagStats counts evaluations of one flag
+type FeatureFlagStats struct {
+	Evaluations int64
+	Enabled     int64
+}
+
+// featureFlagState is a configured flag and its counters
+type featureFlagState struct {
+	flag     FeatureFlag
+	tenants  map[string]bool
+	excluded map[string]bool
+
+	evaluations atomic.Int64
+	enabled     atomic.Int64
+}
+
+// FeatureFlags evaluates feature flags per request. Percentage rollouts
+// bucket requests by correlation ID, so every component consulted for one
+// request sees the same answer.
+type FeatureFlags struct {
+	flags map[string]*featureFlagState
+	mutex sync.RWMutex
+}
+
+// NewFeatureFlags creates feature flags from the configured flags, keyed by name
+func NewFeatureFlags(flags map[string]FeatureFlag) *FeatureFlags {
+	ff := &FeatureFlags{}
+	ff.Replace(flags)
+	return ff
+}
+
+// Replace swaps in a new set of flags. Counters of flags that remain are kept.
+func (ff *FeatureFlags) Replace(flags map[string]FeatureFlag) {
+	ff.mutex.Lock()
+	defer ff.mutex.Unlock()
+
+	next := make(map[string]*featureFlagState, len(flags))
+	for name, flag := range flags {
+		flag.Name = name
+		state := &featureFlagState{
+			flag:     flag,
+			tenants:  stringSet(flag.Tenants),
+			excluded: stringSet(flag.ExcludedTenants),
+		}
+		if previous, exists := ff.flags[name]; exists {
+			state.evaluations.Store(previous.evaluations.Load())
+			state.enabled.Store(previous.enabled.Load())
+		}
+		next[name] = state
+	}
+	ff.flags = next
+}
+
+// Enabled reports whether the named feature is on for the request in ctx.
+// fallback is returned when the flag is not configured, so features that
+// predate their flag keep their behavior until one is added.
+func (ff *FeatureFlags) Enabled(ctx context.Context, name string, fallback bool) bool {
+	if ff == nil {
+		return fallback
+	}
+
+	ff.mutex.RLock()
+	state, exists := ff.flags[name]
+	ff.mutex.RUnlock()
+	if !exists {
+		return fallback
+	}
+
+	enabled := state.evaluate(ctx)
+	state.evaluations.Add(1)
+	if enabled {
+		state.enabled.Add(1)
+	}
+	return enabled
+}
+
+// Flags returns the configured flags sorted by name
+func (ff *FeatureFlags) Flags() []FeatureFlag {
+	ff.mutex.RLock()
+	defer ff.mutex.RUnlock()
+
+	flags := make([]FeatureFlag, 0, len(ff.flags))
+	for _, state := range ff.flags {
+		flags = append(flags, state.flag)
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].Name < flags[j].Name
+	})
+	return flags
+}
+
+// Stats returns evaluation counters by flag name
+func (ff *FeatureFlags) Stats() map[string]FeatureFlagStats {
+	ff.mutex.RLock()
+	defer ff.mutex.RUnlock()
+
+	stats := make(map[string]FeatureFlagStats, len(ff.flags))
+	for name, state := range ff.flags {
+		stats[name] = FeatureFlagStats{
+			Evaluations: state.evaluations.Load(),
+			Enabled:     state.enabled.Load(),
+		}
+	}
+	return stats
+}
+
+// evaluate applies the flag to the request in ctx
+func (s *featureFlagState) evaluate(ctx context.Context) bool {
+	if !s.flag.Enabled {
+		return false
+	}
+
+	tenant := TenantFromContext(ctx)
+	if s.excluded[tenant] {
+		return false
+	}
+	if s.tenants[tenant] {
+		return true
+	}
+
+	switch {
+	case s.flag.Percentage >= 100:
+		return true
+	case s.flag.Percentage <= 0:
+		return false
+	}
+	return rolloutBucket(s.flag.Name, logging.CorrelationID(ctx)) < s.flag.Percentage
+}
+
+// rolloutBucket maps a request to a bucket in [0, 100) for a flag. Requests
+// without a correlation ID are bucketed at random.
+func rolloutBucket(flag, correlationID string) float64 {
+	if correlationID == "" {
+		return rand.Float64() * 100
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(flag))
+	hash.Write([]byte{0})
+	hash.Write([]byte(correlationID))
+	return float64(hash.Sum64()%10000) / 100
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+// tenantContextKey is the context key of the tenant
+type tenantContextKey struct{}
+
+// WithTenant returns a context whose requests belong to tenant
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant set with WithTenant, or DefaultTenant
+func TenantFromContext(ctx context.Context) string {
+	if ctx != nil {
+		if tenant, ok := ctx.Value(tenantContextKey{}).(string); ok && tenant != "" {
+			return tenant
+		}
+	}
+	return DefaultTenant
+}
+
+// FeatureFlags returns the coordinator's feature flags
+func (alm *ALMCoordinator) FeatureFlags() *FeatureFlags {
+	return alm.featureFlags
+}
+
+// SetFeatureFlag adds or replaces a flag in the running configuration
+func (alm *ALMCoordinator) SetFeatureFlag(flag FeatureFlag) error {
+	if err := flag.Validate(); err != nil {
+		return err
+	}
+
+	_, err := alm.updateFeatureFlags(func(flags map[string]FeatureFlag) bool {
+		flags[flag.Name] = flag
+		return true
+	})
+	return err
+}
+
+// RemoveFeatureFlag removes a flag from the running configuration and
+// reports whether it existed
+func (alm *ALMCoordinator) RemoveFeatureFlag(name string) (bool, error) {
+	return alm.updateFeatureFlags(func(flags map[string]FeatureFlag) bool {
+		if _, exists := flags[name]; !exists {
+			return false
+		}
+		delete(flags, name)
+		return true
+	})
+}
+
+// updateFeatureFlags applies update to a copy of the configured flags and
+// commits the result like ApplyConfig. update returns false to leave the
+// configuration unchanged.
+func (alm *ALMCoordinator) updateFeatureFlags(update func(flags map[string]FeatureFlag) bool) (bool, error) {
+	alm.mutex.Lock()
+	defer alm.mutex.Unlock()
+
+	flags := make(map[string]FeatureFlag, len(alm.config.FeatureFlags)+1)
+	for name, flag := range alm.config.FeatureFlags {
+		flags[name] = flag
+	}
+	if !update(flags) {
+		return false, nil
+	}
+
+	next := *alm.config
+	next.FeatureFlags = flags
+	if err := next.Validate(); err != nil {
+		return false, err
+	}
+	if err := alm.applyComponentConfig(&next); err != nil {
+		return false, err
+	}
+	alm.commitConfigLocked(&next)
+
+	alm.logger.Info("Feature flags changed", zap.Int("flags", len(flags)))
+	return true, nil
+}
@@ -0,0 +1,29 @@
+// Package internal implements periodic rebuilds of landmark distances
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+// landmarkRefreshInterval is how often landmark distances are rebuilt if
+// the topology changed; until then bounds the changes may have broken are
+// withheld
+const landmarkRefreshInterval = 30 * time.Second
+
+// runLandmarks rebuilds stale landmark distances until ctx is done
+func (alm *ALMCoordinator) runLandmarks(ctx context.Context) {
+	ticker := time.NewTicker(landmarkRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if alm.networkGraph.LandmarksStale() {
+				alm.networkGraph.RebuildLandmarks()
+			}
+		}
+	}
+}
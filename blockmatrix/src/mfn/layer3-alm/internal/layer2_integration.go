@@ -0,0 +1,358 @@
+// Package internal implements the bridge from Layer 2 link state to the ALM topology
+package internal
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"go.uber.org/zap"
+)
+
+// Layer2EventType identifies a Layer 2 (blockmatrix) link-state change
+type Layer2EventType int
+
+const (
+	Layer2NodeJoin Layer2EventType = iota
+	Layer2NodeLeave
+	Layer2LinkUp
+	Layer2LinkDown
+	Layer2LinkQuality
+)
+
+// String returns the event type name
+func (t Layer2EventType) String() string {
+	switch t {
+	case Layer2NodeJoin:
+		return "node_join"
+	case Layer2NodeLeave:
+		return "node_leave"
+	case Layer2LinkUp:
+		return "link_up"
+	case Layer2LinkDown:
+		return "link_down"
+	case Layer2LinkQuality:
+		return "link_quality"
+	default:
+		return "unknown"
+	}
+}
+
+// Layer2Event is one link-state change reported by Layer 2. Node events use
+// NodeID and the node fields; link events describe the link from NodeID to
+// PeerID and, for LinkUp and LinkQuality, its measured quality.
+type Layer2Event struct {
+	Type      Layer2EventType
+	NodeID    int64
+	Timestamp time.Time
+
+	// Node attributes for NodeJoin
+	Address   string
+	Region    string
+	Latitude  float64
+	Longitude float64
+
+	// Link endpoint and quality for link events
+	PeerID     int64
+	Latency    time.Duration
+	Jitter     time.Duration
+	Bandwidth  float64 // MB/s
+	PacketLoss float64 // 0.0-1.0
+}
+
+// Layer2Source is implemented by the Layer 2 blockmatrix to stream its link
+// state. The channel is closed when the subscription ends; the bridge then
+// subscribes again.
+type Layer2Source interface {
+	Subscribe(ctx context.Context) (<-chan Layer2Event, error)
+}
+
+// Layer2Config configures a Layer2Bridge
+type Layer2Config struct {
+	// Events are applied in batches of up to BatchSize, at least every BatchInterval
+	BatchSize     int
+	BatchInterval time.Duration
+
+	// Apply link events in both directions; Layer 2 links are symmetric
+	SymmetricLinks bool
+
+	// Delay before subscribing again after Subscribe fails or the feed ends
+	ResubscribeInterval time.Duration
+}
+
+// Layer2Stats summarizes bridge activity
+type Layer2Stats struct {
+	Events        int64
+	Updates       int64
+	Batches       int64
+	Ignored       int64
+	Subscriptions int64
+}
+
+// Layer2Bridge converts Layer 2 link-state events into TopologyUpdates and
+// applies them to the coordinator, so routing follows the link layer without
+// waiting for the next topology refresh
+type Layer2Bridge struct {
+	coordinator *ALMCoordinator
+	source      Layer2Source
+	config      *Layer2Config
+	logger      *zap.Logger
+
+	// Counters
+	events        atomic.Int64
+	updates       atomic.Int64
+	batches       atomic.Int64
+	ignored       atomic.Int64
+	subscriptions atomic.Int64
+}
+
+// NewLayer2Bridge creates a bridge from source to coordinator
+func NewLayer2Bridge(coordinator *ALMCoordinator, source Layer2Source, config *Layer2Config, logger *zap.Logger) *Layer2Bridge {
+	if config == nil {
+		config = DefaultLayer2Config()
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1
+	}
+
+	return &Layer2Bridge{
+		coordinator: coordinator,
+		source:      source,
+		config:      config,
+		logger:      logger,
+	}
+}
+
+// Run consumes link-state events until ctx is cancelled
+func (lb *Layer2Bridge) Run(ctx context.Context) {
+	for {
+		events, err := lb.source.Subscribe(ctx)
+		if err != nil {
+			lb.logger.Error("Layer 2 subscription failed", zap.Error(err))
+		} else {
+			lb.subscriptions.Add(1)
+			lb.consume(ctx, events)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(lb.config.ResubscribeInterval):
+		}
+	}
+}
+
+// Stats returns bridge counters
+func (lb *Layer2Bridge) Stats() Layer2Stats {
+	return Layer2Stats{
+		Events:        lb.events.Load(),
+		Updates:       lb.updates.Load(),
+		Batches:       lb.batches.Load(),
+		Ignored:       lb.ignored.Load(),
+		Subscriptions: lb.subscriptions.Load(),
+	}
+}
+
+// consume batches events from one subscription until it ends
+func (lb *Layer2Bridge) consume(ctx context.Context, events <-chan Layer2Event) {
+	ticker := time.NewTicker(lb.config.BatchInterval)
+	defer ticker.Stop()
+
+	batch := make([]Layer2Event, 0, lb.config.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			lb.apply(batch)
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			flush()
+			return
+
+		case event, ok := <-events:
+			if !ok {
+				flush()
+				return
+			}
+			lb.events.Add(1)
+			batch = append(batch, event)
+			if len(batch) >= lb.config.BatchSize {
+				flush()
+			}
+
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// apply converts a batch of events and applies the resulting updates
+func (lb *Layer2Bridge) apply(events []Layer2Event) {
+	updates := lb.convert(events)
+	if len(updates) == 0 {
+		return
+	}
+
+	if err := lb.coordinator.UpdateNetworkTopology(updates); err != nil {
+		lb.logger.Error("Failed to apply Layer 2 topology updates", zap.Error(err))
+		return
+	}
+
+	lb.batches.Add(1)
+	lb.updates.Add(int64(len(updates)))
+}
+
+// convert maps events to topology updates. Nodes and links are checked
+// against the current graph so that repeated joins are ignored and quality
+// reports for unknown links add them.
+func (lb *Layer2Bridge) convert(events []Layer2Event) []TopologyUpdate {
+	networkGraph := lb.coordinator.NetworkGraph()
+	updates := make([]TopologyUpdate, 0, len(events))
+
+	// Nodes and links added or removed earlier in the batch
+	nodes := make(map[int64]bool)
+	links := make(map[[2]int64]bool)
+
+	nodeExists := func(id int64) bool {
+		if exists, seen := nodes[id]; seen {
+			return exists
+		}
+		_, exists := networkGraph.GetNode(id)
+		return exists
+	}
+	linkExists := func(from, to int64) bool {
+		if exists, seen := links[[2]int64{from, to}]; seen {
+			return exists
+		}
+		_, exists := networkGraph.GetEdge(from, to)
+		return exists
+	}
+
+	for _, event := range events {
+		switch event.Type {
+		case Layer2NodeJoin:
+			if nodeExists(event.NodeID) {
+				lb.ignored.Add(1)
+				continue
+			}
+			nodes[event.NodeID] = true
+			updates = append(updates, TopologyUpdate{
+				Type: NodeAddUpdate,
+				Node: &graph.NetworkNode{
+					ID:          event.NodeID,
+					Address:     event.Address,
+					Region:      event.Region,
+					Latitude:    event.Latitude,
+					Longitude:   event.Longitude,
+					Reliability: 1.0,
+					LastSeen:    eventTime(event),
+					Services:    make(map[string]graph.ServiceInfo),
+				},
+			})
+
+		case Layer2NodeLeave:
+			if !nodeExists(event.NodeID) {
+				lb.ignored.Add(1)
+				continue
+			}
+			nodes[event.NodeID] = false
+			updates = append(updates, TopologyUpdate{Type: NodeRemoveUpdate, NodeID: event.NodeID})
+
+		case Layer2LinkUp, Layer2LinkQuality, Layer2LinkDown:
+			for _, direction := range lb.directions(event) {
+				from, to := direction[0], direction[1]
+
+				if event.Type == Layer2LinkDown {
+					if !linkExists(from, to) {
+						lb.ignored.Add(1)
+						continue
+					}
+					links[direction] = false
+					updates = append(updates, TopologyUpdate{Type: EdgeRemoveUpdate, EdgeFrom: from, EdgeTo: to})
+					continue
+				}
+
+				if linkExists(from, to) {
+					updates = append(updates, TopologyUpdate{
+						Type:        EdgeMetricsUpdate,
+						EdgeFrom:    from,
+						EdgeTo:      to,
+						EdgeMetrics: linkMetrics(event),
+					})
+					continue
+				}
+
+				if !nodeExists(from) || !nodeExists(to) {
+					lb.ignored.Add(1)
+					continue
+				}
+				links[direction] = true
+				updates = append(updates, TopologyUpdate{Type: EdgeAddUpdate, Edge: linkEdge(from, to, event)})
+			}
+
+		default:
+			lb.ignored.Add(1)
+		}
+	}
+
+	return updates
+}
+
+// directions returns the graph edges a link event applies to
+func (lb *Layer2Bridge) directions(event Layer2Event) [][2]int64 {
+	if lb.config.SymmetricLinks && event.NodeID != event.PeerID {
+		return [][2]int64{{event.NodeID, event.PeerID}, {event.PeerID, event.NodeID}}
+	}
+	return [][2]int64{{event.NodeID, event.PeerID}}
+}
+
+func linkMetrics(event Layer2Event) graph.EdgeMetrics {
+	return graph.EdgeMetrics{
+		Latency:     event.Latency,
+		Bandwidth:   event.Bandwidth,
+		PacketLoss:  event.PacketLoss,
+		Jitter:      event.Jitter,
+		Reliability: 1.0 - event.PacketLoss,
+	}
+}
+
+func linkEdge(from, to int64, event Layer2Event) *graph.NetworkEdge {
+	return &graph.NetworkEdge{
+		From:        from,
+		To:          to,
+		Weight:      float64(event.Latency.Microseconds()),
+		Latency:     event.Latency,
+		Bandwidth:   event.Bandwidth,
+		PacketLoss:  event.PacketLoss,
+		Jitter:      event.Jitter,
+		Reliability: 1.0 - event.PacketLoss,
+		Stability:   1.0,
+		LastUpdate:  eventTime(event),
+	}
+}
+
+func eventTime(event Layer2Event) time.Time {
+	if event.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return event.Timestamp
+}
+
+// DefaultLayer2Config returns default Layer 2 bridge configuration
+func DefaultLayer2Config() *Layer2Config {
+	return &Layer2Config{
+		BatchSize:           64,
+		BatchInterval:       100 * time.Millisecond,
+		SymmetricLinks:      true,
+		ResubscribeInterval: 5 * time.Second,
+	}
+}
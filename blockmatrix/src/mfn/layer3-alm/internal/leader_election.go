@@ -0,0 +1,589 @@
+// Package internal implements leader election between coordinators in a region
+package internal
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ElectionTransport carries election RPCs to peer coordinators
+type ElectionTransport interface {
+	RequestVote(ctx context.Context, peer string, request VoteRequest) (VoteResponse, error)
+	Heartbeat(ctx context.Context, peer string, request HeartbeatRequest) (HeartbeatResponse, error)
+}
+
+// VoteRequest asks a peer to vote for a candidate in a term
+type VoteRequest struct {
+	Term        uint64
+	CandidateID string
+}
+
+// VoteResponse carries the peer's term and whether it voted for the candidate
+type VoteResponse struct {
+	Term    uint64
+	Granted bool
+}
+
+// HeartbeatRequest asserts leadership for a term
+type HeartbeatRequest struct {
+	Term     uint64
+	LeaderID string
+}
+
+// HeartbeatResponse carries the peer's term and whether it accepted the leader
+type HeartbeatResponse struct {
+	Term    uint64
+	Success bool
+}
+
+// ElectionRole is a coordinator's role in the election
+type ElectionRole int
+
+const (
+	RoleFollower ElectionRole = iota
+	RoleCandidate
+	RoleLeader
+)
+
+// String returns the role name
+func (r ElectionRole) String() string {
+	switch r {
+	case RoleFollower:
+		return "follower"
+	case RoleCandidate:
+		return "candidate"
+	case RoleLeader:
+		return "leader"
+	default:
+		return "unknown"
+	}
+}
+
+// ElectionConfig configures a LeaderElector
+type ElectionConfig struct {
+	// This coordinator's ID and the IDs of the other coordinators in its
+	// region, as understood by the ElectionTransport
+	NodeID string
+	Peers  []string
+
+	// Followers start an election after a randomized timeout between
+	// ElectionTimeout and twice that without hearing from a leader
+	ElectionTimeout time.Duration
+
+	// How often the leader asserts leadership; well below ElectionTimeout
+	HeartbeatInterval time.Duration
+
+	// Bound on a single vote or heartbeat RPC
+	RPCTimeout time.Duration
+}
+
+// ElectionStats reports the elector's state and history
+type ElectionStats struct {
+	Role             ElectionRole
+	Term             uint64
+	Leader           string
+	ElectionsStarted int64
+	ElectionsWon     int64
+	LeaderChanges    int64
+	LastTransition   time.Time
+}
+
+// LeaderElector elects one leader among the coordinators of a region using
+// Raft's election rules: terms, one vote per term and randomized timeouts.
+// There is no replicated log; leadership only decides which coordinator
+// runs topology-wide maintenance, so votes are kept in memory.
+//
+// A leader that cannot reach a majority for ElectionTimeout steps down, so a
+// partitioned leader stops its maintenance before the majority elects a new
+// one. A coordinator without peers is always the leader.
+type LeaderElector struct {
+	config    *ElectionConfig
+	transport ElectionTransport
+
+	role     ElectionRole
+	term     uint64
+	votedFor string
+	leaderID string
+
+	// When a leader was last heard from, or when this leader last reached
+	// a majority
+	lastContact time.Time
+
+	// Wakes a waiting follower when a heartbeat arrives
+	contact chan struct{}
+
+	// Leadership change notification
+	listeners   []func(leader bool, term uint64)
+	notified    bool
+	notifyMutex sync.Mutex
+
+	// Statistics
+	electionsStarted atomic.Int64
+	electionsWon     atomic.Int64
+	leaderChanges    atomic.Int64
+	lastTransition   time.Time
+
+	logger *zap.Logger
+	mutex  sync.Mutex
+}
+
+// NewLeaderElector creates an elector; transport may be nil when there are no peers
+func NewLeaderElector(config *ElectionConfig, transport ElectionTransport, logger *zap.Logger) *LeaderElector {
+	if config == nil {
+		config = DefaultElectionConfig()
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &LeaderElector{
+		config:    config,
+		transport: transport,
+		contact:   make(chan struct{}, 1),
+		logger:    logger.With(zap.String("node_id", config.NodeID)),
+	}
+}
+
+// OnLeadershipChange registers a callback invoked when this coordinator
+// gains or loses leadership. Callbacks run in order on the goroutine that
+// observed the change and must not block.
+func (le *LeaderElector) OnLeadershipChange(listener func(leader bool, term uint64)) {
+	le.notifyMutex.Lock()
+	defer le.notifyMutex.Unlock()
+
+	le.listeners = append(le.listeners, listener)
+}
+
+// Run takes part in elections until ctx is done, then gives up leadership
+func (le *LeaderElector) Run(ctx context.Context) {
+	defer func() {
+		le.mutex.Lock()
+		le.becomeFollowerLocked(le.term, "")
+		le.mutex.Unlock()
+		le.notify()
+	}()
+
+	if len(le.config.Peers) == 0 || le.transport == nil {
+		le.mutex.Lock()
+		le.term++
+		le.becomeLeaderLocked()
+		le.mutex.Unlock()
+		le.notify()
+
+		<-ctx.Done()
+		return
+	}
+
+	for ctx.Err() == nil {
+		le.mutex.Lock()
+		role := le.role
+		le.mutex.Unlock()
+
+		switch role {
+		case RoleFollower:
+			le.runFollower(ctx)
+		case RoleCandidate:
+			le.runCandidate(ctx)
+		case RoleLeader:
+			le.runLeader(ctx)
+		}
+	}
+}
+
+// HandleVote answers a peer's vote request
+func (le *LeaderElector) HandleVote(request VoteRequest) VoteResponse {
+	le.mutex.Lock()
+	defer le.notify()
+	defer le.mutex.Unlock()
+
+	if request.Term < le.term {
+		return VoteResponse{Term: le.term}
+	}
+
+	// Ignore candidates while a live leader is known, so a peer that was
+	// briefly cut off cannot depose it
+	if le.role != RoleCandidate && le.leaderID != "" && le.leaderID != request.CandidateID &&
+		time.Since(le.lastContact) < le.config.ElectionTimeout {
+		return VoteResponse{Term: le.term}
+	}
+
+	if request.Term > le.term {
+		le.becomeFollowerLocked(request.Term, "")
+	}
+
+	if le.votedFor != "" && le.votedFor != request.CandidateID {
+		return VoteResponse{Term: le.term}
+	}
+
+	le.votedFor = request.CandidateID
+	le.lastContact = time.Now()
+	le.signalContact()
+	return VoteResponse{Term: le.term, Granted: true}
+}
+
+// HandleHeartbeat answers a leader's heartbeat
+func (le *LeaderElector) HandleHeartbeat(request HeartbeatRequest) HeartbeatResponse {
+	le.mutex.Lock()
+	defer le.notify()
+	defer le.mutex.Unlock()
+
+	if request.Term < le.term {
+		return HeartbeatResponse{Term: le.term}
+	}
+
+	if request.Term > le.term || le.role != RoleFollower || le.leaderID != request.LeaderID {
+		le.becomeFollowerLocked(request.Term, request.LeaderID)
+	}
+
+	le.lastContact = time.Now()
+	le.signalContact()
+	return HeartbeatResponse{Term: le.term, Success: true}
+}
+
+// IsLeader reports whether this coordinator is currently the leader
+func (le *LeaderElector) IsLeader() bool {
+	le.mutex.Lock()
+	defer le.mutex.Unlock()
+
+	return le.role == RoleLeader
+}
+
+// Leader returns the current leader's ID, or "" if none is known
+func (le *LeaderElector) Leader() string {
+	le.mutex.Lock()
+	defer le.mutex.Unlock()
+
+	return le.leaderID
+}
+
+// Stats returns the elector's state and history
+func (le *LeaderElector) Stats() ElectionStats {
+	le.mutex.Lock()
+	defer le.mutex.Unlock()
+
+	return ElectionStats{
+		Role:             le.role,
+		Term:             le.term,
+		Leader:           le.leaderID,
+		ElectionsStarted: le.electionsStarted.Load(),
+		ElectionsWon:     le.electionsWon.Load(),
+		LeaderChanges:    le.leaderChanges.Load(),
+		LastTransition:   le.lastTransition,
+	}
+}
+
+// runFollower waits for heartbeats and becomes a candidate once they stop
+func (le *LeaderElector) runFollower(ctx context.Context) {
+	timer := time.NewTimer(le.electionTimeout())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-le.contact:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(le.electionTimeout())
+		case <-timer.C:
+			le.mutex.Lock()
+			if le.role == RoleFollower {
+				le.role = RoleCandidate
+			}
+			le.mutex.Unlock()
+			return
+		}
+	}
+}
+
+// runCandidate runs one election round, then waits out a randomized timeout
+// if it neither won nor heard from a new leader
+func (le *LeaderElector) runCandidate(ctx context.Context) {
+	le.mutex.Lock()
+	if le.role != RoleCandidate {
+		le.mutex.Unlock()
+		return
+	}
+	le.term++
+	le.votedFor = le.config.NodeID
+	le.leaderID = ""
+	term := le.term
+	le.mutex.Unlock()
+
+	le.electionsStarted.Add(1)
+	le.logger.Debug("Starting leader election", zap.Uint64("term", term))
+
+	votes := 1
+	responses := make(chan VoteResponse, len(le.config.Peers))
+	rpcCtx, cancel := context.WithTimeout(ctx, le.config.RPCTimeout)
+	for _, peer := range le.config.Peers {
+		go func(peer string) {
+			response, err := le.transport.RequestVote(rpcCtx, peer, VoteRequest{Term: term, CandidateID: le.config.NodeID})
+			if err != nil {
+				le.logger.Debug("Vote request failed", zap.String("peer", peer), zap.Error(err))
+			}
+			responses <- response
+		}(peer)
+	}
+
+	for range le.config.Peers {
+		response := <-responses
+		if le.observeTerm(response.Term) {
+			break
+		}
+		if response.Granted && response.Term == term {
+			votes++
+		}
+	}
+	cancel()
+
+	le.mutex.Lock()
+	if le.role == RoleCandidate && le.term == term && votes > (len(le.config.Peers)+1)/2 {
+		le.becomeLeaderLocked()
+		le.electionsWon.Add(1)
+		le.mutex.Unlock()
+		le.notify()
+		return
+	}
+	stillCandidate := le.role == RoleCandidate
+	le.mutex.Unlock()
+
+	if stillCandidate {
+		le.logger.Debug("Leader election inconclusive", zap.Uint64("term", term), zap.Int("votes", votes))
+		timer := time.NewTimer(le.electionTimeout())
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-le.contact:
+		case <-timer.C:
+		}
+	}
+}
+
+// runLeader sends heartbeats every HeartbeatInterval and steps down if a
+// majority has not acknowledged one within ElectionTimeout
+func (le *LeaderElector) runLeader(ctx context.Context) {
+	ticker := time.NewTicker(le.config.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		le.mutex.Lock()
+		if le.role != RoleLeader {
+			le.mutex.Unlock()
+			return
+		}
+		term := le.term
+		le.mutex.Unlock()
+
+		acks := 1
+		responses := make(chan HeartbeatResponse, len(le.config.Peers))
+		rpcCtx, cancel := context.WithTimeout(ctx, le.config.RPCTimeout)
+		for _, peer := range le.config.Peers {
+			go func(peer string) {
+				response, err := le.transport.Heartbeat(rpcCtx, peer, HeartbeatRequest{Term: term, LeaderID: le.config.NodeID})
+				if err != nil {
+					le.logger.Debug("Heartbeat failed", zap.String("peer", peer), zap.Error(err))
+				}
+				responses <- response
+			}(peer)
+		}
+		for range le.config.Peers {
+			response := <-responses
+			if le.observeTerm(response.Term) {
+				break
+			}
+			if response.Success && response.Term == term {
+				acks++
+			}
+		}
+		cancel()
+
+		le.mutex.Lock()
+		if le.role == RoleLeader && le.term == term {
+			if acks > (len(le.config.Peers)+1)/2 {
+				le.lastContact = time.Now()
+			} else if time.Since(le.lastContact) > le.config.ElectionTimeout {
+				le.logger.Warn("Lost contact with a majority, stepping down", zap.Uint64("term", term))
+				le.becomeFollowerLocked(le.term, "")
+			}
+		}
+		le.mutex.Unlock()
+		le.notify()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// observeTerm steps down if a peer reported a newer term, returning true if it did
+func (le *LeaderElector) observeTerm(term uint64) bool {
+	le.mutex.Lock()
+	newer := term > le.term
+	if newer {
+		le.becomeFollowerLocked(term, "")
+	}
+	le.mutex.Unlock()
+
+	if newer {
+		le.notify()
+	}
+	return newer
+}
+
+// becomeLeaderLocked takes leadership for the current term
+func (le *LeaderElector) becomeLeaderLocked() {
+	le.role = RoleLeader
+	le.votedFor = le.config.NodeID
+	le.leaderID = le.config.NodeID
+	le.lastContact = time.Now()
+	le.lastTransition = le.lastContact
+	le.leaderChanges.Add(1)
+
+	le.logger.Info("Elected ALM coordinator leader", zap.Uint64("term", le.term))
+}
+
+// becomeFollowerLocked follows leaderID in term, clearing the vote when the
+// term advances
+func (le *LeaderElector) becomeFollowerLocked(term uint64, leaderID string) {
+	if term > le.term {
+		le.term = term
+		le.votedFor = ""
+	}
+	if le.role != RoleFollower {
+		le.lastTransition = time.Now()
+	}
+	if leaderID != le.leaderID && leaderID != "" {
+		le.leaderChanges.Add(1)
+		le.logger.Debug("Following ALM coordinator leader", zap.String("leader", leaderID), zap.Uint64("term", term))
+	}
+
+	le.role = RoleFollower
+	le.leaderID = leaderID
+}
+
+func (le *LeaderElector) signalContact() {
+	select {
+	case le.contact <- struct{}{}:
+	default:
+	}
+}
+
+// notify calls listeners if leadership changed since they were last called
+func (le *LeaderElector) notify() {
+	le.notifyMutex.Lock()
+	defer le.notifyMutex.Unlock()
+
+	le.mutex.Lock()
+	leader, term := le.role == RoleLeader, le.term
+	le.mutex.Unlock()
+
+	if leader == le.notified {
+		return
+	}
+	le.notified = leader
+
+	for _, listener := range le.listeners {
+		listener(leader, term)
+	}
+}
+
+// electionTimeout returns a random duration in [ElectionTimeout, 2*ElectionTimeout)
+func (le *LeaderElector) electionTimeout() time.Duration {
+	return le.config.ElectionTimeout + time.Duration(rand.Int63n(int64(le.config.ElectionTimeout)))
+}
+
+// AttachElection makes this coordinator take part in leader election with
+// the other coordinators of its region once it starts. Only the leader runs
+// topology-wide maintenance; every coordinator keeps serving lookups from
+// its own state. Peers answer through elector.HandleVote and
+// elector.HandleHeartbeat.
+func (alm *ALMCoordinator) AttachElection(transport ElectionTransport, config *ElectionConfig) *LeaderElector {
+	elector := NewLeaderElector(config, transport, alm.logger)
+
+	alm.mutex.Lock()
+	defer alm.mutex.Unlock()
+
+	alm.elector = elector
+	return elector
+}
+
+// IsLeader reports whether this coordinator runs topology-wide maintenance.
+// A coordinator without an attached election is always the leader.
+func (alm *ALMCoordinator) IsLeader() bool {
+	alm.mutex.RLock()
+	elector := alm.elector
+	alm.mutex.RUnlock()
+
+	return elector == nil || elector.IsLeader()
+}
+
+// runMaintenance prunes weak service affinities every MaintenanceInterval
+// while this coordinator is the leader
+func (alm *ALMCoordinator) runMaintenance(ctx context.Context) {
+	interval := alm.Config().MaintenanceInterval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-alm.configUpdates():
+			if next := alm.Config().MaintenanceInterval; next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+		case <-ticker.C:
+			if !alm.IsLeader() {
+				continue
+			}
+
+			threshold := alm.Config().AffinityPruneThreshold
+			if pruned := alm.serviceRegistry.PruneAffinities(threshold); pruned > 0 {
+				alm.logger.Debug("Pruned weak service affinities",
+					zap.Int("pruned", pruned),
+					zap.Float64("threshold", threshold),
+				)
+			}
+		}
+	}
+}
+
+// Validate checks the election configuration
+func (c *ElectionConfig) Validate() error {
+	if c.NodeID == "" {
+		return fmt.Errorf("election node ID is required")
+	}
+	for _, peer := range c.Peers {
+		if peer == c.NodeID {
+			return fmt.Errorf("election peers must not include this node (%s)", c.NodeID)
+		}
+	}
+	if c.ElectionTimeout <= 0 || c.HeartbeatInterval <= 0 || c.RPCTimeout <= 0 {
+		return fmt.Errorf("election timeout, heartbeat interval and RPC timeout must be positive")
+	}
+	if c.HeartbeatInterval >= c.ElectionTimeout {
+		return fmt.Errorf("heartbeat interval %s must be shorter than election timeout %s", c.HeartbeatInterval, c.ElectionTimeout)
+	}
+	return nil
+}
+
+// DefaultElectionConfig returns default election configuration
+func DefaultElectionConfig() *ElectionConfig {
+	return &ElectionConfig{
+		ElectionTimeout:   1500 * time.Millisecond,
+		HeartbeatInterval: 500 * time.Millisecond,
+		RPCTimeout:        400 * time.Millisecond,
+	}
+}
@@ -0,0 +1,243 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// localElection delivers election RPCs between electors in process. Cut
+// nodes can neither send nor receive.
+type localElection struct {
+	mutex    sync.Mutex
+	electors map[string]*LeaderElector
+	cut      map[string]bool
+}
+
+func (le *localElection) peer(from, to string) (*LeaderElector, error) {
+	le.mutex.Lock()
+	defer le.mutex.Unlock()
+
+	if le.cut[from] || le.cut[to] {
+		return nil, errors.New("unreachable")
+	}
+	return le.electors[to], nil
+}
+
+func (le *localElection) setCut(id string, cut bool) {
+	le.mutex.Lock()
+	defer le.mutex.Unlock()
+
+	le.cut[id] = cut
+}
+
+func (le *localElection) RequestVote(ctx context.Context, peer string, request VoteRequest) (VoteResponse, error) {
+	elector, err := le.peer(request.CandidateID, peer)
+	if err != nil {
+		return VoteResponse{}, err
+	}
+	return elector.HandleVote(request), nil
+}
+
+func (le *localElection) Heartbeat(ctx context.Context, peer string, request HeartbeatRequest) (HeartbeatResponse, error) {
+	elector, err := le.peer(request.LeaderID, peer)
+	if err != nil {
+		return HeartbeatResponse{}, err
+	}
+	return elector.HandleHeartbeat(request), nil
+}
+
+// startElection runs an election between n electors with short timeouts
+// until the test ends
+func startElection(t *testing.T, n int) (*localElection, []*LeaderElector) {
+	t.Helper()
+
+	cluster := &localElection{electors: make(map[string]*LeaderElector), cut: make(map[string]bool)}
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("node-%d", i)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+
+	electors := make([]*LeaderElector, n)
+	for i, id := range ids {
+		var peers []string
+		for _, peer := range ids {
+			if peer != id {
+				peers = append(peers, peer)
+			}
+		}
+		electors[i] = NewLeaderElector(&ElectionConfig{
+			NodeID:            id,
+			Peers:             peers,
+			ElectionTimeout:   50 * time.Millisecond,
+			HeartbeatInterval: 10 * time.Millisecond,
+			RPCTimeout:        20 * time.Millisecond,
+		}, cluster, nil)
+		cluster.electors[id] = electors[i]
+	}
+	for _, elector := range electors {
+		wg.Add(1)
+		go func(elector *LeaderElector) {
+			defer wg.Done()
+			elector.Run(ctx)
+		}(elector)
+	}
+	return cluster, electors
+}
+
+// awaitLeader waits for exactly one of electors to lead and the rest to
+// follow it, returning the leader
+func awaitLeader(t *testing.T, electors []*LeaderElector) *LeaderElector {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var leaders []*LeaderElector
+		for _, elector := range electors {
+			if elector.IsLeader() {
+				leaders = append(leaders, elector)
+			}
+		}
+		if len(leaders) == 1 {
+			id := leaders[0].config.NodeID
+			following := 0
+			for _, elector := range electors {
+				if elector.Leader() == id {
+					following++
+				}
+			}
+			if following == len(electors) {
+				return leaders[0]
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("no single leader elected")
+	return nil
+}
+
+func TestLeaderElectorWithoutPeers(t *testing.T) {
+	elector := NewLeaderElector(&ElectionConfig{NodeID: "solo"}, nil, nil)
+	changes := make(chan bool, 2)
+	elector.OnLeadershipChange(func(leader bool, term uint64) {
+		changes <- leader
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		elector.Run(ctx)
+		close(done)
+	}()
+
+	if leader := <-changes; !leader || !elector.IsLeader() || elector.Leader() != "solo" {
+		t.Fatal("coordinator without peers did not lead")
+	}
+	cancel()
+	<-done
+	if leader := <-changes; leader || elector.IsLeader() {
+		t.Error("leadership kept after Run returned")
+	}
+}
+
+func TestLeaderElectorElectsOneLeader(t *testing.T) {
+	_, electors := startElection(t, 3)
+	leader := awaitLeader(t, electors)
+
+	stats := leader.Stats()
+	if stats.Role != RoleLeader || stats.Term == 0 || stats.ElectionsWon == 0 {
+		t.Errorf("leader stats %+v", stats)
+	}
+	for _, elector := range electors {
+		if elector != leader && elector.Stats().Term != stats.Term {
+			t.Errorf("%s in term %d, leader in %d", elector.config.NodeID, elector.Stats().Term, stats.Term)
+		}
+	}
+}
+
+func TestLeaderElectorPartitionedLeaderStepsDown(t *testing.T) {
+	cluster, electors := startElection(t, 3)
+	leader := awaitLeader(t, electors)
+	term := leader.Stats().Term
+
+	cluster.setCut(leader.config.NodeID, true)
+	var rest []*LeaderElector
+	for _, elector := range electors {
+		if elector != leader {
+			rest = append(rest, elector)
+		}
+	}
+	next := awaitLeader(t, rest)
+	if next.Stats().Term <= term {
+		t.Errorf("new leader in term %d, not after %d", next.Stats().Term, term)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for leader.IsLeader() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if leader.IsLeader() {
+		t.Fatal("leader cut off from the majority kept leading")
+	}
+
+	// Once reconnected the cluster settles on one leader again
+	cluster.setCut(leader.config.NodeID, false)
+	awaitLeader(t, electors)
+}
+
+func TestLeaderElectorHandleVote(t *testing.T) {
+	elector := NewLeaderElector(&ElectionConfig{NodeID: "a", ElectionTimeout: time.Minute}, nil, nil)
+
+	if response := elector.HandleVote(VoteRequest{Term: 2, CandidateID: "b"}); !response.Granted || response.Term != 2 {
+		t.Fatalf("first vote in term 2: %+v", response)
+	}
+	if response := elector.HandleVote(VoteRequest{Term: 2, CandidateID: "c"}); response.Granted {
+		t.Error("voted twice in one term")
+	}
+	if response := elector.HandleVote(VoteRequest{Term: 1, CandidateID: "c"}); response.Granted || response.Term != 2 {
+		t.Errorf("vote for a stale term: %+v", response)
+	}
+
+	// A follower hearing from a live leader ignores other candidates
+	elector.HandleHeartbeat(HeartbeatRequest{Term: 3, LeaderID: "b"})
+	if response := elector.HandleVote(VoteRequest{Term: 4, CandidateID: "c"}); response.Granted {
+		t.Error("voted against a live leader")
+	}
+	if elector.Leader() != "b" || elector.Stats().Term != 3 {
+		t.Errorf("following %q in term %d, want b in 3", elector.Leader(), elector.Stats().Term)
+	}
+}
+
+func TestElectionConfigValidate(t *testing.T) {
+	valid := DefaultElectionConfig()
+	valid.NodeID = "a"
+	valid.Peers = []string{"b", "c"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate(default) = %v", err)
+	}
+
+	invalid := map[string]func(*ElectionConfig){
+		"no node ID":        func(c *ElectionConfig) { c.NodeID = "" },
+		"self as peer":      func(c *ElectionConfig) { c.Peers = []string{"a"} },
+		"zero RPC timeout":  func(c *ElectionConfig) { c.RPCTimeout = 0 },
+		"slow heartbeats":   func(c *ElectionConfig) { c.HeartbeatInterval = c.ElectionTimeout },
+		"negative timeouts": func(c *ElectionConfig) { c.ElectionTimeout = -time.Second },
+	}
+	for name, change := range invalid {
+		config := *valid
+		change(&config)
+		if err := config.Validate(); err == nil {
+			t.Errorf("%s: Validate() accepted %+v", name, config)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+// Package internal implements ordered startup and shutdown of coordinator components
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Component is a unit of work managed by a LifecycleManager
+type Component struct {
+	Name string
+
+	// Start, when set, is called synchronously in registration order before
+	// Run is started; an error stops the components already started and
+	// fails LifecycleManager.Start
+	Start func(ctx context.Context) error
+
+	// Run, when set, is started in its own goroutine and must return once
+	// ctx is cancelled
+	Run func(ctx context.Context)
+
+	// Stop, when set, is called after Run has returned to release resources
+	Stop func(ctx context.Context) error
+
+	// How long Run may take to return after cancellation; zero uses the
+	// manager's DrainTimeout
+	DrainTimeout time.Duration
+}
+
+// ComponentState is where a component is in its lifecycle
+type ComponentState int
+
+const (
+	ComponentPending ComponentState = iota
+	ComponentRunning
+	ComponentStopped
+	ComponentFailed
+)
+
+// String returns the component state name
+func (s ComponentState) String() string {
+	switch s {
+	case ComponentPending:
+		return "pending"
+	case ComponentRunning:
+		return "running"
+	case ComponentStopped:
+		return "stopped"
+	case ComponentFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// ComponentStatus reports the state of one managed component
+type ComponentStatus struct {
+	Name      string
+	State     ComponentState
+	StartedAt time.Time
+	StoppedAt time.Time
+	Error     string
+}
+
+// LifecycleConfig configures a LifecycleManager
+type LifecycleConfig struct {
+	// Default time a component may take to drain after cancellation
+	DrainTimeout time.Duration
+}
+
+// LifecycleManager starts components in registration order and shuts them
+// down in reverse order, so producers stop before the components they feed.
+// Each component runs under its own context, which is cancelled when the
+// component is shut down.
+type LifecycleManager struct {
+	components []*managedComponent
+	config     *LifecycleConfig
+	started    bool
+	shutdown   bool
+
+	logger *zap.Logger
+	mutex  sync.Mutex
+
+	// Held while starting so Shutdown waits for a start in progress
+	startMutex sync.Mutex
+}
+
+type managedComponent struct {
+	Component
+
+	cancel    context.CancelFunc
+	done      chan struct{}
+	state     ComponentState
+	startedAt time.Time
+	stoppedAt time.Time
+	err       error
+}
+
+// NewLifecycleManager creates an empty lifecycle manager
+func NewLifecycleManager(config *LifecycleConfig, logger *zap.Logger) *LifecycleManager {
+	if config == nil {
+		config = DefaultLifecycleConfig()
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &LifecycleManager{
+		config: config,
+		logger: logger,
+	}
+}
+
+// Register adds a component. Components must be registered before Start.
+func (lm *LifecycleManager) Register(component Component) error {
+	lm.mutex.Lock()
+	defer lm.mutex.Unlock()
+
+	if lm.started {
+		return fmt.Errorf("cannot register %s after start", component.Name)
+	}
+
+	lm.components = append(lm.components, &managedComponent{Component: component})
+	return nil
+}
+
+// Start runs every registered component. Cancelling ctx stops all of them,
+// but only Shutdown waits for them to drain and releases their resources.
+//
+// When a component fails to start, the components started before it are
+// shut down in reverse order and the manager cannot be started again.
+func (lm *LifecycleManager) Start(ctx context.Context) error {
+	lm.startMutex.Lock()
+	defer lm.startMutex.Unlock()
+
+	lm.mutex.Lock()
+	if lm.shutdown {
+		lm.mutex.Unlock()
+		return fmt.Errorf("lifecycle manager has been shut down")
+	}
+	if lm.started {
+		lm.mutex.Unlock()
+		return fmt.Errorf("lifecycle manager is already started")
+	}
+	lm.started = true
+	components := lm.components
+	lm.mutex.Unlock()
+
+	for i, mc := range components {
+		if mc.Start != nil {
+			if err := mc.Start(ctx); err != nil {
+				err = fmt.Errorf("failed to start %s: %w", mc.Name, err)
+				lm.finish(mc, err)
+				lm.rollback(ctx, components[:i])
+				return err
+			}
+		}
+
+		lm.mutex.Lock()
+		mc.state = ComponentRunning
+		mc.startedAt = time.Now()
+		lm.mutex.Unlock()
+
+		if mc.Run == nil {
+			continue
+		}
+
+		componentCtx, cancel := context.WithCancel(ctx)
+		mc.cancel = cancel
+		mc.done = make(chan struct{})
+		go lm.run(componentCtx, mc)
+	}
+
+	lm.logger.Debug("Components started", zap.Int("components", len(components)))
+	return nil
+}
+
+// rollback stops started components in reverse order after a failed start
+func (lm *LifecycleManager) rollback(ctx context.Context, started []*managedComponent) {
+	lm.mutex.Lock()
+	lm.shutdown = true
+	lm.mutex.Unlock()
+
+	for i := len(started) - 1; i >= 0; i-- {
+		lm.stop(ctx, started[i])
+	}
+}
+
+// Shutdown stops components in reverse registration order. Each component is
+// cancelled, given its drain timeout to return, and then stopped. Components
+// are still cancelled and stopped once ctx is done, but no longer waited for.
+func (lm *LifecycleManager) Shutdown(ctx context.Context) error {
+	lm.startMutex.Lock()
+	defer lm.startMutex.Unlock()
+
+	lm.mutex.Lock()
+	if !lm.started || lm.shutdown {
+		lm.shutdown = true
+		lm.mutex.Unlock()
+		return nil
+	}
+	lm.shutdown = true
+	components := lm.components
+	lm.mutex.Unlock()
+
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		if err := lm.stop(ctx, components[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Status returns the state of every component in registration order
+func (lm *LifecycleManager) Status() []ComponentStatus {
+	lm.mutex.Lock()
+	defer lm.mutex.Unlock()
+
+	statuses := make([]ComponentStatus, 0, len(lm.components))
+	for _, mc := range lm.components {
+		status := ComponentStatus{
+			Name:      mc.Name,
+			State:     mc.state,
+			StartedAt: mc.startedAt,
+			StoppedAt: mc.stoppedAt,
+		}
+		if mc.err != nil {
+			status.Error = mc.err.Error()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// run executes a component until it returns, recording a panic as failure
+func (lm *LifecycleManager) run(ctx context.Context, mc *managedComponent) {
+	defer close(mc.done)
+	defer func() {
+		if r := recover(); r != nil {
+			lm.logger.Error("Component panicked", zap.String("component", mc.Name), zap.Any("panic", r))
+			lm.finish(mc, fmt.Errorf("panic: %v", r))
+		}
+	}()
+
+	mc.Run(ctx)
+}
+
+// stop cancels a component, waits for it to drain and calls its Stop
+func (lm *LifecycleManager) stop(ctx context.Context, mc *managedComponent) error {
+	var err error
+
+	if mc.cancel != nil {
+		mc.cancel()
+
+		drainTimeout := mc.DrainTimeout
+		if drainTimeout <= 0 {
+			drainTimeout = lm.config.DrainTimeout
+		}
+		timer := time.NewTimer(drainTimeout)
+
+		select {
+		case <-mc.done:
+		case <-timer.C:
+			err = fmt.Errorf("%s did not drain within %s", mc.Name, drainTimeout)
+		case <-ctx.Done():
+			err = fmt.Errorf("%s did not drain before shutdown deadline: %w", mc.Name, ctx.Err())
+		}
+		timer.Stop()
+	}
+
+	if mc.Stop != nil {
+		if stopErr := mc.Stop(ctx); stopErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to stop %s: %w", mc.Name, stopErr))
+		}
+	}
+
+	if err != nil {
+		lm.logger.Warn("Component did not shut down cleanly", zap.String("component", mc.Name), zap.Error(err))
+	} else {
+		lm.logger.Debug("Component stopped", zap.String("component", mc.Name))
+	}
+
+	lm.finish(mc, err)
+	return err
+}
+
+func (lm *LifecycleManager) finish(mc *managedComponent, err error) {
+	lm.mutex.Lock()
+	defer lm.mutex.Unlock()
+
+	if mc.state == ComponentFailed {
+		return
+	}
+
+	mc.stoppedAt = time.Now()
+	mc.state = ComponentStopped
+	if err != nil {
+		mc.state = ComponentFailed
+		mc.err = err
+	}
+}
+
+// DefaultLifecycleConfig returns default lifecycle configuration
+func DefaultLifecycleConfig() *LifecycleConfig {
+	return &LifecycleConfig{
+		DrainTimeout: 5 * time.Second,
+	}
+}
@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lifecycleRecorder records component events in the order they happen
+type lifecycleRecorder struct {
+	events []string
+	mutex  sync.Mutex
+}
+
+func (lr *lifecycleRecorder) record(event string) {
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+	lr.events = append(lr.events, event)
+}
+
+func (lr *lifecycleRecorder) String() string {
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+	return strings.Join(lr.events, " ")
+}
+
+// component returns a component that records its start and stop and runs
+// until cancelled
+func (lr *lifecycleRecorder) component(name string) Component {
+	return Component{
+		Name: name,
+		Start: func(context.Context) error {
+			lr.record("start:" + name)
+			return nil
+		},
+		Run: func(ctx context.Context) {
+			<-ctx.Done()
+		},
+		Stop: func(context.Context) error {
+			lr.record("stop:" + name)
+			return nil
+		},
+	}
+}
+
+func registerComponents(t *testing.T, manager *LifecycleManager, components ...Component) {
+	t.Helper()
+
+	for _, component := range components {
+		if err := manager.Register(component); err != nil {
+			t.Fatalf("Register(%s): %v", component.Name, err)
+		}
+	}
+}
+
+func TestLifecycleStartsInOrderAndStopsInReverse(t *testing.T) {
+	var recorder lifecycleRecorder
+	manager := NewLifecycleManager(nil, nil)
+	registerComponents(t, manager, recorder.component("graph"), recorder.component("registry"), recorder.component("monitor"))
+
+	if err := manager.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if got, want := recorder.String(), "start:graph start:registry start:monitor"; got != want {
+		t.Errorf("start events %q, want %q", got, want)
+	}
+	for _, status := range manager.Status() {
+		if status.State != ComponentRunning {
+			t.Errorf("%s is %s after start, want running", status.Name, status.State)
+		}
+	}
+
+	if err := manager.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	want := "start:graph start:registry start:monitor stop:monitor stop:registry stop:graph"
+	if got := recorder.String(); got != want {
+		t.Errorf("events %q, want %q", got, want)
+	}
+	for _, status := range manager.Status() {
+		if status.State != ComponentStopped {
+			t.Errorf("%s is %s after shutdown, want stopped", status.Name, status.State)
+		}
+	}
+}
+
+func TestLifecycleRollsBackFailedStart(t *testing.T) {
+	var recorder lifecycleRecorder
+	manager := NewLifecycleManager(nil, nil)
+
+	failing := recorder.component("registry")
+	failing.Start = func(context.Context) error {
+		return errors.New("port in use")
+	}
+	registerComponents(t, manager, recorder.component("graph"), recorder.component("store"), failing, recorder.component("monitor"))
+
+	err := manager.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "registry") || !strings.Contains(err.Error(), "port in use") {
+		t.Fatalf("Start = %v, want the registry's start error", err)
+	}
+
+	// Components started before the failure are stopped in reverse; the
+	// failed one and those after it were never started
+	if got, want := recorder.String(), "start:graph start:store stop:store stop:graph"; got != want {
+		t.Errorf("events %q, want %q", got, want)
+	}
+
+	want := map[string]ComponentState{
+		"graph":    ComponentStopped,
+		"store":    ComponentStopped,
+		"registry": ComponentFailed,
+		"monitor":  ComponentPending,
+	}
+	for _, status := range manager.Status() {
+		if status.State != want[status.Name] {
+			t.Errorf("%s is %s after a failed start, want %s", status.Name, status.State, want[status.Name])
+		}
+	}
+
+	if err := manager.Start(context.Background()); err == nil {
+		t.Error("Start succeeded again after a failed start")
+	}
+	if err := manager.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown after a failed start: %v", err)
+	}
+	if got := strings.Count(recorder.String(), "stop:"); got != 2 {
+		t.Errorf("components stopped %d times in total, want 2", got)
+	}
+}
+
+func TestLifecycleReportsDrainTimeout(t *testing.T) {
+	manager := NewLifecycleManager(&LifecycleConfig{DrainTimeout: 10 * time.Millisecond}, nil)
+
+	release := make(chan struct{})
+	defer close(release)
+	stopped := false
+	registerComponents(t, manager, Component{
+		Name: "stuck",
+		Run: func(ctx context.Context) {
+			<-release
+		},
+		Stop: func(context.Context) error {
+			stopped = true
+			return nil
+		},
+	})
+
+	if err := manager.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	err := manager.Shutdown(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "did not drain") {
+		t.Fatalf("Shutdown = %v, want a drain timeout", err)
+	}
+	if !stopped {
+		t.Error("Stop was not called for a component that did not drain")
+	}
+	if status := manager.Status()[0]; status.State != ComponentFailed {
+		t.Errorf("stuck component is %s, want failed", status.State)
+	}
+}
+
+func TestLifecycleRejectsLateRegistration(t *testing.T) {
+	manager := NewLifecycleManager(nil, nil)
+	if err := manager.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer manager.Shutdown(context.Background())
+
+	if err := manager.Register(Component{Name: "late"}); err == nil {
+		t.Error("Register succeeded after Start")
+	}
+}
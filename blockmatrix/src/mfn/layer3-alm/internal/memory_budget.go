@@ -0,0 +1,265 @@
+// Package internal implements a memory budget apportioned across the ALM caches
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Heap thresholds, as fractions of the budget, at which capacities shrink
+// and grow back
+const (
+	budgetShrinkAt = 0.9
+	budgetGrowAt   = 0.7
+)
+
+// Bounds on the fraction of their budgeted capacity consumers keep
+const (
+	minBudgetScale = 0.05
+	maxBudgetScale = 1.0
+)
+
+// MemoryConsumer is a cache or matrix whose capacity a MemoryBudget controls
+type MemoryConsumer struct {
+	Name string
+
+	// Share of the budget, relative to the other consumers' shares
+	Share float64
+
+	// Estimated heap bytes held per entry
+	EntryBytes int64
+
+	// Configured capacity, never exceeded; zero means unbounded
+	MaxEntries int
+
+	// Len returns the entries held and Resize sets the capacity
+	Len    func() int
+	Resize func(capacity int) error
+}
+
+// MemoryBudget apportions a heap budget across consumers by share and
+// estimated entry size, and scales their capacities down when the heap
+// approaches the budget. Capacities shrink by a quarter each rebalance while
+// the heap is above 90% of the budget, by half while it is over, and grow
+// back while it is below 70%.
+type MemoryBudget struct {
+	limit     int64
+	interval  time.Duration
+	heap      func() uint64
+	consumers []*budgetConsumer
+
+	// Fraction of each consumer's budgeted capacity it currently keeps
+	scale    float64
+	lastHeap uint64
+
+	logger *zap.Logger
+	mutex  sync.Mutex
+}
+
+// budgetConsumer is a consumer and the capacity the budget last gave it
+type budgetConsumer struct {
+	MemoryConsumer
+	capacity  int
+	evictions int64
+
+	// Set when the capacity must be applied even if unchanged
+	resize bool
+}
+
+// MemoryBudgetStats is the state of the budget and its consumers
+type MemoryBudgetStats struct {
+	Limit     int64
+	HeapInUse uint64
+	Scale     float64
+	Consumers []MemoryConsumerStats
+}
+
+// MemoryConsumerStats is a consumer's capacity, size and the entries the
+// budget has evicted from it
+type MemoryConsumerStats struct {
+	Name      string
+	Capacity  int
+	Entries   int
+	Evictions int64
+}
+
+// NewMemoryBudget creates a budget of limit bytes, zero disabling it, that
+// reads the heap in use from heap every interval
+func NewMemoryBudget(limit int64, interval time.Duration, heap func() uint64, logger *zap.Logger) *MemoryBudget {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &MemoryBudget{
+		limit:    limit,
+		interval: interval,
+		heap:     heap,
+		scale:    maxBudgetScale,
+		logger:   logger,
+	}
+}
+
+// AddConsumer puts a consumer under the budget from the next rebalance
+func (mb *MemoryBudget) AddConsumer(consumer MemoryConsumer) {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+
+	mb.consumers = append(mb.consumers, &budgetConsumer{
+		MemoryConsumer: consumer,
+		capacity:       consumer.MaxEntries,
+	})
+}
+
+// Run rebalances every interval until ctx is cancelled
+func (mb *MemoryBudget) Run(ctx context.Context) {
+	ticker := time.NewTicker(mb.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := mb.Rebalance(); err != nil {
+				mb.logger.Warn("Failed to apply memory budget", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Rebalance adjusts the scale to the heap in use and resizes every consumer
+// whose capacity changed, counting the entries each resize evicted
+func (mb *MemoryBudget) Rebalance() error {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+
+	mb.lastHeap = mb.heap()
+	previousScale := mb.scale
+	if mb.limit <= 0 {
+		mb.scale = maxBudgetScale
+	} else {
+		heap, limit := float64(mb.lastHeap), float64(mb.limit)
+		switch {
+		case heap > limit:
+			mb.scale *= 0.5
+		case heap > limit*budgetShrinkAt:
+			mb.scale *= 0.75
+		case heap < limit*budgetGrowAt:
+			mb.scale *= 1.25
+		}
+		mb.scale = min(max(mb.scale, minBudgetScale), maxBudgetScale)
+	}
+	if mb.scale < previousScale {
+		mb.logger.Info("Shrinking caches under memory pressure",
+			zap.Uint64("heap_inuse", mb.lastHeap),
+			zap.Int64("budget", mb.limit),
+			zap.Float64("scale", mb.scale),
+		)
+	}
+
+	totalShare := 0.0
+	for _, consumer := range mb.consumers {
+		totalShare += consumer.Share
+	}
+
+	var errs []error
+	for _, consumer := range mb.consumers {
+		capacity := mb.capacityOf(consumer, totalShare)
+		if capacity == consumer.capacity && !consumer.resize {
+			continue
+		}
+
+		before := consumer.Len()
+		if err := consumer.Resize(capacity); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", consumer.Name, err))
+			continue
+		}
+		if evicted := before - consumer.Len(); evicted > 0 {
+			consumer.evictions += int64(evicted)
+		}
+		consumer.capacity = capacity
+		consumer.resize = false
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to resize %d consumers: %v", len(errs), errs)
+	}
+	return nil
+}
+
+// capacityOf returns the consumer's share of the budget in entries, capped
+// at its configured capacity and scaled. The caller holds the mutex.
+func (mb *MemoryBudget) capacityOf(consumer *budgetConsumer, totalShare float64) int {
+	if mb.limit <= 0 {
+		return consumer.MaxEntries
+	}
+
+	capacity := consumer.MaxEntries
+	if totalShare > 0 && consumer.EntryBytes > 0 {
+		budgeted := int(float64(mb.limit) * consumer.Share / totalShare / float64(consumer.EntryBytes))
+		if capacity <= 0 || budgeted < capacity {
+			capacity = budgeted
+		}
+	}
+	if capacity <= 0 {
+		return 0
+	}
+	return max(int(float64(capacity)*mb.scale), 1)
+}
+
+// SetLimit changes the budget, zero disabling it and restoring every
+// consumer's configured capacity at the next rebalance
+func (mb *MemoryBudget) SetLimit(limit int64) {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+
+	mb.limit = limit
+}
+
+// SetMaxEntries changes a consumer's configured capacity. The consumer is
+// resized at the next rebalance.
+func (mb *MemoryBudget) SetMaxEntries(name string, maxEntries int) {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+
+	for _, consumer := range mb.consumers {
+		if consumer.Name == name {
+			consumer.MaxEntries = maxEntries
+			// The owner may have resized it to the new maximum
+			consumer.resize = true
+		}
+	}
+}
+
+// Stats returns the budget and each consumer's capacity and evictions
+func (mb *MemoryBudget) Stats() MemoryBudgetStats {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+
+	stats := MemoryBudgetStats{
+		Limit:     mb.limit,
+		HeapInUse: mb.lastHeap,
+		Scale:     mb.scale,
+		Consumers: make([]MemoryConsumerStats, len(mb.consumers)),
+	}
+	for i, consumer := range mb.consumers {
+		stats.Consumers[i] = MemoryConsumerStats{
+			Name:      consumer.Name,
+			Capacity:  consumer.capacity,
+			Entries:   consumer.Len(),
+			Evictions: consumer.evictions,
+		}
+	}
+	return stats
+}
+
+// MemoryBudget returns the memory budget shared by the caches
+func (alm *ALMCoordinator) MemoryBudget() *MemoryBudget {
+	return alm.memoryBudget
+}
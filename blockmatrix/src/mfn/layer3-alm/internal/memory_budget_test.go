@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"testing"
+)
+
+// fakeConsumer is a cache holding entries up to its capacity
+type fakeConsumer struct {
+	entries  int
+	capacity int
+}
+
+func (fc *fakeConsumer) consumer(name string, share float64, entryBytes int64, maxEntries int) MemoryConsumer {
+	return MemoryConsumer{
+		Name:       name,
+		Share:      share,
+		EntryBytes: entryBytes,
+		MaxEntries: maxEntries,
+		Len:        func() int { return fc.entries },
+		Resize: func(capacity int) error {
+			fc.capacity = capacity
+			fc.entries = min(fc.entries, capacity)
+			return nil
+		},
+	}
+}
+
+func TestMemoryBudgetShrinksUnderPressure(t *testing.T) {
+	var heap uint64
+	budget := NewMemoryBudget(1000, 0, func() uint64 { return heap }, nil)
+
+	routes := &fakeConsumer{entries: 100, capacity: 100}
+	paths := &fakeConsumer{entries: 10, capacity: 10}
+	budget.AddConsumer(routes.consumer("routes", 3, 10, 100))
+	budget.AddConsumer(paths.consumer("paths", 1, 10, 10))
+
+	// Routes get 3/4 of 1000 bytes at 10 bytes each; paths stay at their maximum
+	if err := budget.Rebalance(); err != nil {
+		t.Fatalf("Rebalance: %v", err)
+	}
+	if routes.capacity != 75 || paths.capacity != 10 {
+		t.Fatalf("capacities %d and %d under the budget, want 75 and 10", routes.capacity, paths.capacity)
+	}
+
+	heap = 950
+	budget.Rebalance()
+	if routes.capacity != 56 || paths.capacity != 7 {
+		t.Errorf("capacities %d and %d near the budget, want 56 and 7", routes.capacity, paths.capacity)
+	}
+	heap = 2000
+	budget.Rebalance()
+	if routes.capacity != 28 || paths.capacity != 3 {
+		t.Errorf("capacities %d and %d over the budget, want 28 and 3", routes.capacity, paths.capacity)
+	}
+
+	stats := budget.Stats()
+	if stats.HeapInUse != 2000 || stats.Consumers[0].Evictions != 72 || stats.Consumers[1].Evictions != 7 {
+		t.Errorf("stats after shrinking: %+v", stats)
+	}
+
+	// Capacities grow back once the heap falls, and disabling the budget
+	// restores the configured maximums
+	heap = 100
+	budget.Rebalance()
+	if routes.capacity <= 28 {
+		t.Errorf("route capacity stayed at %d after the heap fell", routes.capacity)
+	}
+	budget.SetLimit(0)
+	budget.Rebalance()
+	if routes.capacity != 100 || paths.capacity != 10 {
+		t.Errorf("capacities %d and %d with the budget disabled, want 100 and 10", routes.capacity, paths.capacity)
+	}
+
+	budget.SetMaxEntries("routes", 50)
+	budget.Rebalance()
+	if routes.capacity != 50 {
+		t.Errorf("route capacity %d after lowering its maximum, want 50", routes.capacity)
+	}
+}
@@ -0,0 +1,164 @@
+// Package internal implements the coordinator's request metrics
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/histogram"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+// maxTrackedRouteTime is the highest route lookup latency told apart in the
+// per-class histograms
+const maxTrackedRouteTime = 10 * time.Second
+
+// MetricsCollector aggregates route lookups and service discoveries over a
+// rolling window: every interval the current window becomes the previous
+// one, and figures cover both, so they follow recent traffic rather than
+// the process lifetime
+type MetricsCollector struct {
+	interval time.Duration
+
+	current  requestWindow
+	previous requestWindow
+
+	// Lookup latency by QoS class over the same two intervals, for
+	// quantiles
+	classLatencies [admissionClasses]*histogram.Windowed
+
+	mutex sync.Mutex
+}
+
+// requestWindow holds the counts of one metrics interval
+type requestWindow struct {
+	routes        int64
+	routeFailures int64
+	routeLatency  time.Duration
+	cacheHits     int64
+
+	discoveries      int64
+	discoveryLatency time.Duration
+}
+
+// NewMetricsCollector creates a collector rolling its window every interval
+func NewMetricsCollector(interval time.Duration) *MetricsCollector {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	mc := &MetricsCollector{interval: interval}
+	for class := range mc.classLatencies {
+		mc.classLatencies[class] = histogram.NewWindowed(maxTrackedRouteTime, 3, 2*interval, 2)
+	}
+	return mc
+}
+
+// Start rolls the window every interval until ctx is done
+func (mc *MetricsCollector) Start(ctx context.Context) {
+	ticker := time.NewTicker(mc.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			mc.mutex.Lock()
+			mc.previous = mc.current
+			mc.current = requestWindow{}
+			mc.mutex.Unlock()
+		}
+	}
+}
+
+// RecordRouting counts a successful route lookup of the given QoS class
+func (mc *MetricsCollector) RecordRouting(response *RouteResponse, class routing.QoSClass) {
+	mc.classLatencies[clampQoSClass(class)].Record(response.SearchTime)
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	mc.current.routes++
+	mc.current.routeLatency += response.SearchTime
+	if response.CacheHit {
+		mc.current.cacheHits++
+	}
+}
+
+// RecordRoutingFailure counts a route lookup that returned no route
+func (mc *MetricsCollector) RecordRoutingFailure() {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	mc.current.routeFailures++
+}
+
+// RecordServiceDiscovery counts a service discovery
+func (mc *MetricsCollector) RecordServiceDiscovery(response *ServiceDiscoveryResponse) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	mc.current.discoveries++
+	mc.current.discoveryLatency += response.SearchTime
+}
+
+// GetAverageRoutingLatency returns the mean latency of successful lookups
+func (mc *MetricsCollector) GetAverageRoutingLatency() time.Duration {
+	window := mc.window()
+	if window.routes == 0 {
+		return 0
+	}
+	return window.routeLatency / time.Duration(window.routes)
+}
+
+// GetRoutingSuccessRate returns the percentage of lookups that found a
+// route, or 100 when there were none
+func (mc *MetricsCollector) GetRoutingSuccessRate() float64 {
+	window := mc.window()
+	total := window.routes + window.routeFailures
+	if total == 0 {
+		return 100
+	}
+	return float64(window.routes) / float64(total) * 100
+}
+
+// GetServiceDiscoveryLatency returns the mean service discovery latency
+func (mc *MetricsCollector) GetServiceDiscoveryLatency() time.Duration {
+	window := mc.window()
+	if window.discoveries == 0 {
+		return 0
+	}
+	return window.discoveryLatency / time.Duration(window.discoveries)
+}
+
+// GetCacheHitRate returns the percentage of successful lookups served from
+// the route cache
+func (mc *MetricsCollector) GetCacheHitRate() float64 {
+	window := mc.window()
+	if window.routes == 0 {
+		return 0
+	}
+	return float64(window.cacheHits) / float64(window.routes) * 100
+}
+
+// GetRoutingLatencyQuantile returns the latency below which q of the
+// successful lookups of a QoS class fell
+func (mc *MetricsCollector) GetRoutingLatencyQuantile(class routing.QoSClass, q float64) time.Duration {
+	return mc.classLatencies[clampQoSClass(class)].Snapshot().Quantile(q)
+}
+
+// window returns the previous and current windows combined
+func (mc *MetricsCollector) window() requestWindow {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	return requestWindow{
+		routes:           mc.previous.routes + mc.current.routes,
+		routeFailures:    mc.previous.routeFailures + mc.current.routeFailures,
+		routeLatency:     mc.previous.routeLatency + mc.current.routeLatency,
+		cacheHits:        mc.previous.cacheHits + mc.current.cacheHits,
+		discoveries:      mc.previous.discoveries + mc.current.discoveries,
+		discoveryLatency: mc.previous.discoveryLatency + mc.current.discoveryLatency,
+	}
+}
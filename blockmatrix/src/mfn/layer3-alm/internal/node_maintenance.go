@@ -0,0 +1,228 @@
+// Package internal implements graceful node maintenance drains
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maintenanceStepInterval is how often draining nodes are penalized
+// further and more of their cached routes invalidated
+const maintenanceStepInterval = time.Second
+
+// maxMaintenancePenalty is the penalty, in edge weight units (microseconds
+// of latency), a draining node reaches at its deadline; by then only routes
+// with no alternative within ten seconds still cross it
+const maxMaintenancePenalty = 1e7
+
+var (
+	// ErrNodeInMaintenance is returned when marking a node already in
+	// maintenance
+	ErrNodeInMaintenance = errors.New("node is already in maintenance")
+
+	// ErrNodeNotInMaintenance is returned when cancelling maintenance of a
+	// node not in it
+	ErrNodeNotInMaintenance = errors.New("node is not in maintenance")
+
+	// ErrUnknownNode is returned when marking a node the graph does not hold
+	ErrUnknownNode = errors.New("unknown node")
+)
+
+// NodeMaintenanceStatus reports how far a node has drained and the traffic
+// still on it
+type NodeMaintenanceStatus struct {
+	NodeID    int64     `json:"node_id"`
+	StartedAt time.Time `json:"started_at"`
+	Deadline  time.Time `json:"deadline"`
+
+	// Share of the drain window elapsed, from 0 to 1, and the weight added
+	// to edges into the node for it
+	Progress float64 `json:"progress"`
+	Penalty  float64 `json:"penalty"`
+
+	// Traffic left: requests in flight through the node, cached routes
+	// through it, and bandwidth reservations on paths through it
+	InFlightRequests int64 `json:"in_flight_requests"`
+	CachedRoutes     int   `json:"cached_routes"`
+	Reservations     int   `json:"reservations"`
+
+	// Removed from the topology at its deadline
+	Removed bool `json:"removed"`
+
+	// Removed with no traffic left, so the node can be rebooted
+	SafeToReboot bool `json:"safe_to_reboot"`
+}
+
+// nodeDrain is a node in maintenance
+type nodeDrain struct {
+	nodeID    int64
+	startedAt time.Time
+	deadline  time.Time
+	progress  float64
+	removed   bool
+}
+
+// nodeDrains tracks the nodes in maintenance
+type nodeDrains struct {
+	drains map[int64]*nodeDrain
+	mutex  sync.Mutex
+}
+
+// MarkNodeMaintenance drains a node from new routes ahead of deadline. A
+// penalty on the edges into it ramps up until paths avoid it wherever
+// another route exists, its cached routes are invalidated progressively,
+// coldest first, and at the deadline it is removed from the topology.
+// NodeMaintenance reports the traffic left on it until it is safe to reboot.
+func (alm *ALMCoordinator) MarkNodeMaintenance(nodeID int64, deadline time.Time) error {
+	now := time.Now()
+	if !deadline.After(now) {
+		return fmt.Errorf("maintenance deadline for node %d must be in the future", nodeID)
+	}
+	if _, exists := alm.networkGraph.GetNode(nodeID); !exists {
+		return fmt.Errorf("%w %d", ErrUnknownNode, nodeID)
+	}
+
+	alm.nodeDrains.mutex.Lock()
+	defer alm.nodeDrains.mutex.Unlock()
+
+	if _, exists := alm.nodeDrains.drains[nodeID]; exists {
+		return fmt.Errorf("%w: node %d", ErrNodeInMaintenance, nodeID)
+	}
+	if alm.nodeDrains.drains == nil {
+		alm.nodeDrains.drains = make(map[int64]*nodeDrain)
+	}
+	drain := &nodeDrain{nodeID: nodeID, startedAt: now, deadline: deadline}
+	alm.nodeDrains.drains[nodeID] = drain
+
+	alm.logger.Info("Node entering maintenance",
+		zap.Int64("node_id", nodeID),
+		zap.Time("deadline", deadline),
+	)
+	return nil
+}
+
+// CancelNodeMaintenance stops draining a node, clearing its penalty. A node
+// already removed is forgotten and rejoins the topology when next added.
+func (alm *ALMCoordinator) CancelNodeMaintenance(nodeID int64) error {
+	alm.nodeDrains.mutex.Lock()
+	defer alm.nodeDrains.mutex.Unlock()
+
+	drain, exists := alm.nodeDrains.drains[nodeID]
+	if !exists {
+		return fmt.Errorf("%w: node %d", ErrNodeNotInMaintenance, nodeID)
+	}
+	delete(alm.nodeDrains.drains, nodeID)
+
+	if !drain.removed {
+		if err := alm.networkGraph.SetNodePenalty(nodeID, 0); err != nil {
+			alm.logger.Warn("Failed to clear maintenance penalty", zap.Int64("node_id", nodeID), zap.Error(err))
+		}
+	}
+	alm.logger.Info("Node maintenance cancelled", zap.Int64("node_id", nodeID), zap.Bool("removed", drain.removed))
+	return nil
+}
+
+// NodeMaintenance reports the nodes in maintenance, by node ID
+func (alm *ALMCoordinator) NodeMaintenance() []NodeMaintenanceStatus {
+	alm.nodeDrains.mutex.Lock()
+	defer alm.nodeDrains.mutex.Unlock()
+
+	reservations := alm.routingTable.Reservations().List()
+	statuses := make([]NodeMaintenanceStatus, 0, len(alm.nodeDrains.drains))
+	for _, drain := range alm.nodeDrains.drains {
+		status := NodeMaintenanceStatus{
+			NodeID:           drain.nodeID,
+			StartedAt:        drain.startedAt,
+			Deadline:         drain.deadline,
+			Progress:         drain.progress,
+			Penalty:          drain.progress * maxMaintenancePenalty,
+			InFlightRequests: alm.routingTable.NodeInFlight(drain.nodeID),
+			Removed:          drain.removed,
+		}
+		_, status.CachedRoutes = alm.routingTable.DrainNode(drain.nodeID, 0)
+		for _, reservation := range reservations {
+			if slices.Contains(reservation.Path, drain.nodeID) {
+				status.Reservations++
+			}
+		}
+		status.SafeToReboot = status.Removed && status.InFlightRequests == 0 &&
+			status.CachedRoutes == 0 && status.Reservations == 0
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].NodeID < statuses[j].NodeID })
+	return statuses
+}
+
+// stepNodeMaintenance advances every drain to now: it raises the node's
+// penalty with the share of its window elapsed, invalidates the share of
+// its cached routes due before the next step, and removes nodes past their
+// deadline. Removed nodes that rejoin the topology are forgotten.
+func (alm *ALMCoordinator) stepNodeMaintenance(now time.Time) {
+	alm.nodeDrains.mutex.Lock()
+	defer alm.nodeDrains.mutex.Unlock()
+
+	for nodeID, drain := range alm.nodeDrains.drains {
+		_, exists := alm.networkGraph.GetNode(nodeID)
+		switch {
+		case drain.removed && exists:
+			delete(alm.nodeDrains.drains, nodeID)
+			alm.logger.Info("Node rejoined after maintenance", zap.Int64("node_id", nodeID))
+			continue
+		case drain.removed:
+			continue
+		case !exists:
+			drain.removed = true
+			continue
+		}
+
+		if !now.Before(drain.deadline) {
+			drain.progress = 1
+			if err := alm.UpdateNetworkTopology([]TopologyUpdate{{Type: NodeRemoveUpdate, NodeID: nodeID}}); err != nil {
+				alm.logger.Warn("Failed to remove node at maintenance deadline; retrying",
+					zap.Int64("node_id", nodeID),
+					zap.Error(err),
+				)
+				continue
+			}
+			drain.removed = true
+			alm.logger.Info("Node removed for maintenance",
+				zap.Int64("node_id", nodeID),
+				zap.Int64("in_flight_requests", alm.routingTable.NodeInFlight(nodeID)),
+			)
+			continue
+		}
+
+		window := drain.deadline.Sub(drain.startedAt)
+		drain.progress = min(float64(now.Sub(drain.startedAt))/float64(window), 1)
+		if err := alm.networkGraph.SetNodePenalty(nodeID, drain.progress*maxMaintenancePenalty); err != nil {
+			alm.logger.Warn("Failed to penalize draining node", zap.Int64("node_id", nodeID), zap.Error(err))
+		}
+
+		// Spread the invalidations over the steps left, so routes move off
+		// the node gradually rather than all at once
+		share := min(float64(maintenanceStepInterval)/float64(drain.deadline.Sub(now)), 1)
+		alm.routingTable.DrainNode(nodeID, share)
+	}
+}
+
+// runNodeMaintenance steps the node drains until ctx is done
+func (alm *ALMCoordinator) runNodeMaintenance(ctx context.Context) {
+	ticker := time.NewTicker(maintenanceStepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			alm.stepNodeMaintenance(now)
+		}
+	}
+}
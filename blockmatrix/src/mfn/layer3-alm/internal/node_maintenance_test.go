@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+// newDiamondCoordinator returns a coordinator whose shortest path from 1 to
+// 4 crosses node 2, with a detour through node 3
+func newDiamondCoordinator(t *testing.T) *ALMCoordinator {
+	t.Helper()
+
+	alm := newTestCoordinator(t)
+	updates := []TopologyUpdate{}
+	for id := int64(1); id <= 4; id++ {
+		updates = append(updates, TopologyUpdate{Type: NodeAddUpdate, Node: &graph.NetworkNode{ID: id}})
+	}
+	for _, edge := range []*graph.NetworkEdge{
+		{From: 1, To: 2, Weight: 10},
+		{From: 2, To: 4, Weight: 10},
+		{From: 1, To: 3, Weight: 15},
+		{From: 3, To: 4, Weight: 15},
+	} {
+		updates = append(updates, TopologyUpdate{Type: EdgeAddUpdate, Edge: edge})
+	}
+	if err := alm.UpdateNetworkTopology(updates); err != nil {
+		t.Fatalf("UpdateNetworkTopology: %v", err)
+	}
+	return alm
+}
+
+func TestNodeMaintenanceDrainsAndRemovesNode(t *testing.T) {
+	alm := newDiamondCoordinator(t)
+
+	if err := alm.MarkNodeMaintenance(9, time.Now().Add(time.Minute)); !errors.Is(err, ErrUnknownNode) {
+		t.Errorf("marking an unknown node: got %v, want ErrUnknownNode", err)
+	}
+	if err := alm.MarkNodeMaintenance(2, time.Now()); err == nil {
+		t.Error("maintenance accepted with a past deadline")
+	}
+	if err := alm.MarkNodeMaintenance(2, time.Now().Add(10*time.Second)); err != nil {
+		t.Fatalf("MarkNodeMaintenance: %v", err)
+	}
+	if err := alm.MarkNodeMaintenance(2, time.Now().Add(time.Minute)); !errors.Is(err, ErrNodeInMaintenance) {
+		t.Errorf("marking a node twice: got %v, want ErrNodeInMaintenance", err)
+	}
+	status := alm.NodeMaintenance()[0]
+
+	// Halfway through, paths avoid the node wherever they can
+	alm.stepNodeMaintenance(status.StartedAt.Add(status.Deadline.Sub(status.StartedAt) / 2))
+	if status := alm.NodeMaintenance()[0]; status.Progress < 0.49 || status.Progress > 0.51 || status.Removed {
+		t.Errorf("halfway status: %+v", status)
+	}
+	if path, err := alm.networkGraph.FindShortestPath(1, 4); err != nil || path.NodeIDs[1] != 3 {
+		t.Errorf("path still crosses the draining node: %+v, %v", path, err)
+	}
+
+	// At the deadline the node is removed, but not safe to reboot until its
+	// last request ends
+	end := alm.routingTable.BeginPathRequest([]int64{1, 2, 4}, 0)
+	alm.stepNodeMaintenance(status.Deadline)
+	if _, exists := alm.networkGraph.GetNode(2); exists {
+		t.Fatal("node kept past its maintenance deadline")
+	}
+	if status := alm.NodeMaintenance()[0]; !status.Removed || status.InFlightRequests != 1 || status.SafeToReboot {
+		t.Errorf("status with a request in flight: %+v", status)
+	}
+	end(0)
+	if status := alm.NodeMaintenance()[0]; !status.SafeToReboot {
+		t.Errorf("drained node not safe to reboot: %+v", status)
+	}
+
+	// A removed node that rejoins is forgotten
+	if err := alm.UpdateNetworkTopology([]TopologyUpdate{{Type: NodeAddUpdate, Node: &graph.NetworkNode{ID: 2}}}); err != nil {
+		t.Fatalf("UpdateNetworkTopology: %v", err)
+	}
+	alm.stepNodeMaintenance(time.Now())
+	if statuses := alm.NodeMaintenance(); len(statuses) != 0 {
+		t.Errorf("rejoined node still in maintenance: %+v", statuses)
+	}
+}
+
+func TestCancelNodeMaintenanceClearsPenalty(t *testing.T) {
+	alm := newDiamondCoordinator(t)
+	if err := alm.MarkNodeMaintenance(2, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("MarkNodeMaintenance: %v", err)
+	}
+	alm.stepNodeMaintenance(time.Now().Add(30 * time.Second))
+	if path, err := alm.networkGraph.FindShortestPath(1, 4); err != nil || path.NodeIDs[1] != 3 {
+		t.Fatalf("path still crosses the draining node: %+v, %v", path, err)
+	}
+
+	if err := alm.CancelNodeMaintenance(2); err != nil {
+		t.Fatalf("CancelNodeMaintenance: %v", err)
+	}
+	if err := alm.CancelNodeMaintenance(2); !errors.Is(err, ErrNodeNotInMaintenance) {
+		t.Errorf("cancelling twice: got %v, want ErrNodeNotInMaintenance", err)
+	}
+	if path, err := alm.networkGraph.FindShortestPath(1, 4); err != nil || path.NodeIDs[1] != 2 {
+		t.Errorf("path avoids the node after cancelling: %+v, %v", path, err)
+	}
+}
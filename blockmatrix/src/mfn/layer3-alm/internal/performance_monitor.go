@@ -0,0 +1,179 @@
+// Package internal implements process resource sampling for the coordinator
+package internal
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// ResourceUsage is one sample of the coordinator process's resource usage
+type ResourceUsage struct {
+	SampledAt time.Time
+
+	// CPU used by this process since the previous sample as a percentage of
+	// one core, so it may exceed 100 on multi-core hosts, and by the whole
+	// host as a percentage of all cores
+	ProcessCPUPercent float64
+	SystemCPUPercent  float64
+
+	// Memory in bytes. ResidentMemory falls back to SysMemory where the
+	// platform does not report it.
+	ResidentMemory uint64
+	HeapAlloc      uint64
+	HeapInUse      uint64
+	SysMemory      uint64
+
+	Goroutines int
+
+	// Garbage collection. MaxGCPause is the longest pause since the
+	// previous sample.
+	GCCycles      uint32
+	GCPauseTotal  time.Duration
+	LastGCPause   time.Duration
+	MaxGCPause    time.Duration
+	GCCPUFraction float64
+}
+
+// PerformanceMonitor samples CPU, memory, goroutine and garbage collection
+// statistics every interval. Readers get the latest sample, so they never
+// pay for runtime.ReadMemStats or a /proc read themselves.
+type PerformanceMonitor struct {
+	interval time.Duration
+
+	// Nil when the platform does not expose per-process statistics
+	process *process.Process
+
+	latest    ResourceUsage
+	lastNumGC uint32
+
+	mutex sync.RWMutex
+}
+
+// NewPerformanceMonitor creates a monitor sampling every interval
+func NewPerformanceMonitor(interval time.Duration) *PerformanceMonitor {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	pm := &PerformanceMonitor{interval: interval}
+	if proc, err := process.NewProcess(int32(os.Getpid())); err == nil {
+		pm.process = proc
+	}
+
+	return pm
+}
+
+// Start samples until ctx is done. The first sample is taken immediately;
+// CPU percentages are measured between samples and read zero until the
+// second.
+func (pm *PerformanceMonitor) Start(ctx context.Context) {
+	pm.Sample()
+
+	ticker := time.NewTicker(pm.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pm.Sample()
+		}
+	}
+}
+
+// Sample takes a sample now, records it as the latest and returns it
+func (pm *PerformanceMonitor) Sample() ResourceUsage {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	usage := ResourceUsage{
+		SampledAt:      time.Now(),
+		ResidentMemory: memStats.Sys,
+		HeapAlloc:      memStats.HeapAlloc,
+		HeapInUse:      memStats.HeapInuse,
+		SysMemory:      memStats.Sys,
+		Goroutines:     runtime.NumGoroutine(),
+		GCCycles:       memStats.NumGC,
+		GCPauseTotal:   time.Duration(memStats.PauseTotalNs),
+		GCCPUFraction:  memStats.GCCPUFraction,
+	}
+	if memStats.NumGC > 0 {
+		usage.LastGCPause = time.Duration(memStats.PauseNs[(memStats.NumGC+255)%256])
+	}
+
+	if pm.process != nil {
+		if percent, err := pm.process.Percent(0); err == nil {
+			usage.ProcessCPUPercent = percent
+		}
+		if memory, err := pm.process.MemoryInfo(); err == nil {
+			usage.ResidentMemory = memory.RSS
+		}
+	}
+	if percents, err := cpu.Percent(0, false); err == nil && len(percents) > 0 {
+		usage.SystemCPUPercent = percents[0]
+	}
+
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+
+	usage.MaxGCPause = maxGCPause(&memStats, pm.lastNumGC)
+	pm.lastNumGC = memStats.NumGC
+	pm.latest = usage
+
+	return usage
+}
+
+// GetResourceUsage returns the latest sample
+func (pm *PerformanceMonitor) GetResourceUsage() ResourceUsage {
+	pm.mutex.RLock()
+	defer pm.mutex.RUnlock()
+
+	return pm.latest
+}
+
+// GetMemoryUsage returns the resident memory of the process in bytes
+func (pm *PerformanceMonitor) GetMemoryUsage() int64 {
+	return int64(pm.GetResourceUsage().ResidentMemory)
+}
+
+// GetCPUUsage returns the process CPU usage as a percentage of one core
+func (pm *PerformanceMonitor) GetCPUUsage() float64 {
+	return pm.GetResourceUsage().ProcessCPUPercent
+}
+
+// GetGoroutines returns the number of goroutines at the latest sample
+func (pm *PerformanceMonitor) GetGoroutines() int {
+	return pm.GetResourceUsage().Goroutines
+}
+
+// GetGCPause returns the longest GC pause in the latest sampling interval
+func (pm *PerformanceMonitor) GetGCPause() time.Duration {
+	return pm.GetResourceUsage().MaxGCPause
+}
+
+// maxGCPause returns the longest pause among collections after sinceNumGC.
+// The runtime keeps the last 256 pauses, so older ones in a busy interval
+// are not seen.
+func maxGCPause(memStats *runtime.MemStats, sinceNumGC uint32) time.Duration {
+	cycles := memStats.NumGC - sinceNumGC
+	if cycles > uint32(len(memStats.PauseNs)) {
+		cycles = uint32(len(memStats.PauseNs))
+	}
+
+	var longest uint64
+	for i := uint32(0); i < cycles; i++ {
+		pause := memStats.PauseNs[(memStats.NumGC-i+255)%256]
+		if pause > longest {
+			longest = pause
+		}
+	}
+
+	return time.Duration(longest)
+}
@@ -0,0 +1,969 @@
+// Package internal implements coordinator state snapshots and write-ahead log recovery
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/service"
+	"go.uber.org/zap"
+)
+
+const (
+	snapshotFileName     = "snapshot.json"
+	prevSnapshotFileName = "snapshot.prev.json"
+	walFilePrefix        = "wal-"
+	walFileSuffix        = ".log"
+
+	snapshotFormatVersion = 1
+
+	// Snapshots whose state is encrypted
+	sealedSnapshotFormatVersion = 2
+
+	// WAL records are framed as a 4 byte payload length and a 4 byte
+	// CRC-32C of the payload, followed by the JSON payload, or the sealed
+	// JSON payload when state is encrypted
+	walHeaderSize     = 8
+	maxWALRecordBytes = 64 << 20
+)
+
+var walChecksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errStateDecryption marks state that could not be opened: encrypted state
+// without its key, or plaintext state refused while encryption is enabled.
+// The data is intact, so it is never mistaken for corruption and discarded.
+var errStateDecryption = errors.New("cannot decrypt state")
+
+// StateStoreConfig configures a StateStore
+type StateStoreConfig struct {
+	// Directory holding snapshots and write-ahead log segments
+	Dir string
+
+	// How often the running coordinator writes a snapshot
+	SnapshotInterval time.Duration
+
+	// Sync the write-ahead log to disk after every record
+	SyncWrites bool
+
+	// Encrypts snapshots and log records when set. State written in the
+	// clear is then refused, as anyone able to write to Dir could have
+	// planted it.
+	Keys KeyProvider
+
+	// AllowPlaintext reads state written in the clear while Keys is set,
+	// to enable encryption on an existing store; the next snapshot and
+	// compaction leave only encrypted state
+	AllowPlaintext bool
+}
+
+// StateStore persists coordinator state as periodic snapshots plus a
+// write-ahead log of the topology updates applied since. Recovery mirrors the
+// node's chain state pipeline: load the latest snapshot, replay the log,
+// verify integrity, then apply.
+//
+// The previous snapshot and the log segments it needs are kept, so a snapshot
+// that fails its checksum can be recovered from the one before it.
+type StateStore struct {
+	config *StateStoreConfig
+
+	// Open log segment and its size
+	segment     *os.File
+	segmentSize int64
+	segments    []walSegment
+
+	// Last sequence written to the log, and the sequence covered by the
+	// current snapshot file
+	sequence         uint64
+	snapshotSequence uint64
+
+	// Serializes snapshots; held before the coordinator lock
+	snapshotMutex sync.Mutex
+
+	// Seals state when encryption is enabled
+	cipher *stateCipher
+
+	logger *zap.Logger
+	mutex  sync.Mutex
+}
+
+// RecoveryReport summarizes what was restored at startup
+type RecoveryReport struct {
+	SnapshotSequence uint64
+	SnapshotTime     time.Time
+	UsedPrevious     bool // The latest snapshot was corrupt
+	ReplayedRecords  int
+	TruncatedBytes   int64 // Torn tail removed from the log
+	Nodes            int
+	Edges            int
+	Routes           int
+	DroppedRoutes    int // Cached routes through nodes that no longer exist
+	Services         int
+	Associations     int
+	Duration         time.Duration
+}
+
+// coordinatorState is the snapshot payload
+type coordinatorState struct {
+	Nodes    []*graph.NetworkNode
+	Edges    []*graph.NetworkEdge
+	Routes   map[string]*routing.RouteEntry
+	Registry service.RegistrySnapshot
+	Quotas   []QuotaEntry `json:",omitempty"`
+}
+
+type snapshotEnvelope struct {
+	Version   int
+	Sequence  uint64
+	CreatedAt time.Time
+	Checksum  string          // Hex SHA-256 of State, or of Sealed
+	State     json.RawMessage `json:",omitempty"`
+
+	// Encrypted State, in place of it from sealedSnapshotFormatVersion
+	Sealed []byte `json:",omitempty"`
+}
+
+type walRecord struct {
+	Sequence uint64
+	Time     time.Time
+	Updates  []TopologyUpdate
+}
+
+type walSegment struct {
+	first uint64
+	path  string
+}
+
+// DefaultStateDir returns ~/.blockmatrix/<nodeID>/alm
+func DefaultStateDir(nodeID string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate home directory: %w", err)
+	}
+	return filepath.Join(home, ".blockmatrix", nodeID, "alm"), nil
+}
+
+// NewStateStore creates a state store, creating its directory if needed.
+// The coordinator recovers from the store before appending to it.
+func NewStateStore(config *StateStoreConfig, logger *zap.Logger) (*StateStore, error) {
+	if config == nil {
+		config = DefaultStateStoreConfig()
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	if config.Dir == "" {
+		return nil, fmt.Errorf("state directory is required")
+	}
+	if err := os.MkdirAll(config.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	store := &StateStore{
+		config: config,
+		logger: logger,
+	}
+	if config.Keys != nil {
+		var err error
+		if store.cipher, err = newStateCipher(config.Keys); err != nil {
+			return nil, fmt.Errorf("failed to set up state encryption: %w", err)
+		}
+	}
+	return store, nil
+}
+
+// Append writes a batch of topology updates to the log
+func (ss *StateStore) Append(updates []TopologyUpdate) error {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	if ss.segment == nil {
+		return fmt.Errorf("state store is not open")
+	}
+
+	payload, err := json.Marshal(walRecord{
+		Sequence: ss.sequence + 1,
+		Time:     time.Now(),
+		Updates:  updates,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode log record: %w", err)
+	}
+	if ss.cipher != nil {
+		if payload, err = ss.cipher.seal(payload, sealRecord); err != nil {
+			return fmt.Errorf("failed to encrypt log record: %w", err)
+		}
+	}
+
+	frame := make([]byte, walHeaderSize, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload, walChecksumTable))
+	frame = append(frame, payload...)
+
+	if _, err := ss.segment.Write(frame); err != nil {
+		// Drop the partial frame so later records stay readable
+		if truncErr := ss.segment.Truncate(ss.segmentSize); truncErr != nil {
+			err = errors.Join(err, truncErr)
+		}
+		return fmt.Errorf("failed to write log record: %w", err)
+	}
+	if ss.config.SyncWrites {
+		if err := ss.segment.Sync(); err != nil {
+			return fmt.Errorf("failed to sync log: %w", err)
+		}
+	}
+
+	ss.segmentSize += int64(len(frame))
+	ss.sequence++
+	return nil
+}
+
+// Close syncs and closes the open log segment
+func (ss *StateStore) Close() error {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	if ss.segment == nil {
+		return nil
+	}
+
+	err := errors.Join(ss.segment.Sync(), ss.segment.Close())
+	ss.segment = nil
+	return err
+}
+
+// recover runs the load, replay and verify phases and opens the log for
+// appending. It returns the state to apply, which is empty on first start.
+func (ss *StateStore) recover(report *RecoveryReport) (*coordinatorState, []walRecord, error) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	// Load
+	envelope, state, err := ss.loadSnapshot(report)
+	if err != nil {
+		return nil, nil, err
+	}
+	if envelope != nil {
+		ss.snapshotSequence = envelope.Sequence
+		report.SnapshotSequence = envelope.Sequence
+		report.SnapshotTime = envelope.CreatedAt
+	}
+
+	// Replay
+	records, err := ss.readLog(report)
+	if err != nil {
+		return nil, nil, err
+	}
+	ss.sequence = ss.snapshotSequence
+	if len(records) > 0 {
+		ss.sequence = records[len(records)-1].Sequence
+	}
+
+	pending := records[:0]
+	for _, record := range records {
+		if record.Sequence > ss.snapshotSequence {
+			pending = append(pending, record)
+		}
+	}
+	if len(pending) > 0 && pending[0].Sequence != ss.snapshotSequence+1 {
+		return nil, nil, fmt.Errorf("write-ahead log is missing records %d to %d",
+			ss.snapshotSequence+1, pending[0].Sequence-1)
+	}
+	if len(records) == 0 && len(ss.segments) > 0 && ss.segments[0].first > ss.snapshotSequence+1 {
+		return nil, nil, fmt.Errorf("write-ahead log starts at %d but snapshot ends at %d",
+			ss.segments[0].first, ss.snapshotSequence)
+	}
+
+	// Verify
+	if err := verifyState(state, pending); err != nil {
+		return nil, nil, fmt.Errorf("state integrity check failed: %w", err)
+	}
+
+	if err := ss.openSegmentLocked(); err != nil {
+		return nil, nil, err
+	}
+
+	return state, pending, nil
+}
+
+// loadSnapshot reads the latest valid snapshot, falling back to the previous
+// one and quarantining a corrupt latest snapshot. It returns nil if neither
+// exists.
+func (ss *StateStore) loadSnapshot(report *RecoveryReport) (*snapshotEnvelope, *coordinatorState, error) {
+	currentPath := filepath.Join(ss.config.Dir, snapshotFileName)
+	prevPath := filepath.Join(ss.config.Dir, prevSnapshotFileName)
+
+	envelope, state, err := readSnapshot(currentPath, ss.cipher, ss.acceptsPlaintext())
+	if err == nil {
+		return envelope, state, nil
+	}
+	if errors.Is(err, errStateDecryption) {
+		return nil, nil, err
+	}
+
+	currentMissing := errors.Is(err, os.ErrNotExist)
+	if !currentMissing {
+		ss.logger.Error("Latest state snapshot is unusable, trying previous snapshot",
+			zap.String("path", currentPath), zap.Error(err))
+	}
+
+	prevEnvelope, prevState, prevErr := readSnapshot(prevPath, ss.cipher, ss.acceptsPlaintext())
+	switch {
+	case prevErr == nil:
+	case errors.Is(prevErr, os.ErrNotExist) && currentMissing:
+		return nil, &coordinatorState{}, nil
+	case errors.Is(prevErr, os.ErrNotExist):
+		return nil, nil, fmt.Errorf("snapshot %s is corrupt and no previous snapshot exists: %w", currentPath, err)
+	default:
+		return nil, nil, fmt.Errorf("no usable snapshot: %w", errors.Join(err, prevErr))
+	}
+
+	// Promote the previous snapshot so the next snapshot does not rotate
+	// the corrupt one into its place
+	if !currentMissing {
+		quarantine := fmt.Sprintf("%s.corrupt-%d", currentPath, time.Now().Unix())
+		if err := os.Rename(currentPath, quarantine); err != nil {
+			return nil, nil, fmt.Errorf("failed to quarantine corrupt snapshot: %w", err)
+		}
+		ss.logger.Warn("Quarantined corrupt state snapshot", zap.String("path", quarantine))
+	}
+	if err := os.Rename(prevPath, currentPath); err != nil {
+		return nil, nil, fmt.Errorf("failed to promote previous snapshot: %w", err)
+	}
+
+	report.UsedPrevious = true
+	return prevEnvelope, prevState, nil
+}
+
+// readLog reads the records in the log in sequence order, skipping segments
+// the loaded snapshot covers, which are kept only for the previous snapshot.
+// A torn record at the end of the last segment is the result of a crash
+// mid-write and is truncated; damage anywhere else is an error.
+func (ss *StateStore) readLog(report *RecoveryReport) ([]walRecord, error) {
+	segments, err := listSegments(ss.config.Dir)
+	if err != nil {
+		return nil, err
+	}
+	ss.segments = segments
+
+	var records []walRecord
+	for i, segment := range segments {
+		if i < len(segments)-1 && segments[i+1].first-1 <= ss.snapshotSequence {
+			continue
+		}
+
+		data, err := os.ReadFile(segment.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", segment.path, err)
+		}
+
+		offset := 0
+		for offset < len(data) {
+			record, size, err := decodeRecord(data[offset:], ss.cipher, ss.acceptsPlaintext())
+			if err != nil {
+				if errors.Is(err, errStateDecryption) {
+					return nil, fmt.Errorf("%s at offset %d: %w", segment.path, offset, err)
+				}
+				if i < len(segments)-1 {
+					return nil, fmt.Errorf("%s is corrupt at offset %d: %w", segment.path, offset, err)
+				}
+
+				torn := int64(len(data) - offset)
+				if err := os.Truncate(segment.path, int64(offset)); err != nil {
+					return nil, fmt.Errorf("failed to truncate torn log tail: %w", err)
+				}
+				report.TruncatedBytes = torn
+				ss.logger.Warn("Truncated torn write-ahead log tail",
+					zap.String("segment", segment.path),
+					zap.Int64("bytes", torn),
+					zap.Error(err),
+				)
+				break
+			}
+
+			expected := segment.first
+			if len(records) > 0 {
+				expected = records[len(records)-1].Sequence + 1
+			}
+			if record.Sequence != expected {
+				return nil, fmt.Errorf("%s has record %d where %d was expected", segment.path, record.Sequence, expected)
+			}
+
+			records = append(records, record)
+			offset += size
+		}
+	}
+
+	return records, nil
+}
+
+// openSegmentLocked opens the last log segment for appending, or starts a
+// new one
+func (ss *StateStore) openSegmentLocked() error {
+	if len(ss.segments) == 0 {
+		return ss.rotateLocked()
+	}
+
+	path := ss.segments[len(ss.segments)-1].path
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open log segment: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log segment: %w", err)
+	}
+
+	ss.segment = file
+	ss.segmentSize = info.Size()
+	return nil
+}
+
+// rotateLocked starts a new log segment at the next sequence, unless the
+// open segment is still empty
+func (ss *StateStore) rotateLocked() error {
+	if ss.segment != nil {
+		if ss.segmentSize == 0 {
+			return nil
+		}
+		if err := errors.Join(ss.segment.Sync(), ss.segment.Close()); err != nil {
+			return fmt.Errorf("failed to close log segment: %w", err)
+		}
+		ss.segment = nil
+	}
+
+	first := ss.sequence + 1
+	path := filepath.Join(ss.config.Dir, fmt.Sprintf("%s%020d%s", walFilePrefix, first, walFileSuffix))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create log segment: %w", err)
+	}
+	if err := syncDir(ss.config.Dir); err != nil {
+		file.Close()
+		return err
+	}
+
+	ss.segment = file
+	ss.segmentSize = 0
+	ss.segments = append(ss.segments, walSegment{first: first, path: path})
+	return nil
+}
+
+// writeSnapshot atomically replaces the latest snapshot, keeping the one it
+// replaces, then removes log segments neither snapshot needs
+func (ss *StateStore) writeSnapshot(data []byte, sequence uint64) error {
+	snapshot := snapshotEnvelope{
+		Version:   snapshotFormatVersion,
+		Sequence:  sequence,
+		CreatedAt: time.Now(),
+		State:     data,
+	}
+	if ss.cipher != nil {
+		sealed, err := ss.cipher.seal(data, sealSnapshot)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt snapshot: %w", err)
+		}
+		snapshot.Version = sealedSnapshotFormatVersion
+		snapshot.State, snapshot.Sealed, data = nil, sealed, sealed
+	}
+	checksum := sha256.Sum256(data)
+	snapshot.Checksum = hex.EncodeToString(checksum[:])
+
+	envelope, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	currentPath := filepath.Join(ss.config.Dir, snapshotFileName)
+	tmpPath := currentPath + ".tmp"
+	if err := writeFileSync(tmpPath, envelope); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if err := os.Rename(currentPath, filepath.Join(ss.config.Dir, prevSnapshotFileName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to keep previous snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, currentPath); err != nil {
+		return fmt.Errorf("failed to install snapshot: %w", err)
+	}
+	if err := syncDir(ss.config.Dir); err != nil {
+		return err
+	}
+
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	// The previous snapshot covers up to its sequence; older segments are
+	// no longer needed by either snapshot
+	previous := ss.snapshotSequence
+	ss.snapshotSequence = sequence
+
+	kept := ss.segments[:0]
+	for i, segment := range ss.segments {
+		if i < len(ss.segments)-1 && ss.segments[i+1].first-1 <= previous {
+			if err := os.Remove(segment.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				ss.logger.Warn("Failed to remove compacted log segment", zap.String("segment", segment.path), zap.Error(err))
+				kept = append(kept, segment)
+			}
+			continue
+		}
+		kept = append(kept, segment)
+	}
+	ss.segments = kept
+
+	return nil
+}
+
+// verifyState checks the loaded snapshot and the log records that will be
+// replayed onto it
+func verifyState(state *coordinatorState, records []walRecord) error {
+	var problems []error
+
+	nodes := make(map[int64]bool, len(state.Nodes))
+	for _, node := range state.Nodes {
+		if node == nil {
+			problems = append(problems, fmt.Errorf("snapshot contains an empty node"))
+			continue
+		}
+		if nodes[node.ID] {
+			problems = append(problems, fmt.Errorf("snapshot contains node %d more than once", node.ID))
+		}
+		nodes[node.ID] = true
+	}
+
+	edges := make(map[[2]int64]bool, len(state.Edges))
+	for _, edge := range state.Edges {
+		if edge == nil {
+			problems = append(problems, fmt.Errorf("snapshot contains an empty edge"))
+			continue
+		}
+		if !nodes[edge.From] || !nodes[edge.To] {
+			problems = append(problems, fmt.Errorf("snapshot edge %d->%d references a missing node", edge.From, edge.To))
+		}
+		key := [2]int64{edge.From, edge.To}
+		if edges[key] {
+			problems = append(problems, fmt.Errorf("snapshot contains edge %d->%d more than once", edge.From, edge.To))
+		}
+		edges[key] = true
+	}
+
+	services := make(map[string]bool, len(state.Registry.Services))
+	for _, instance := range state.Registry.Services {
+		if instance == nil || instance.ID == "" {
+			problems = append(problems, fmt.Errorf("snapshot contains a service without an ID"))
+			continue
+		}
+		if services[instance.ID] {
+			problems = append(problems, fmt.Errorf("snapshot contains service %s more than once", instance.ID))
+		}
+		services[instance.ID] = true
+	}
+
+	for _, record := range records {
+		for i, update := range record.Updates {
+			switch {
+			case update.Type < NodeAddUpdate || update.Type > EdgeMetricsUpdate:
+				problems = append(problems, fmt.Errorf("log record %d update %d has unknown type %d", record.Sequence, i, update.Type))
+			case update.Type == NodeAddUpdate && update.Node == nil:
+				problems = append(problems, fmt.Errorf("log record %d update %d adds an empty node", record.Sequence, i))
+			case update.Type == EdgeAddUpdate && update.Edge == nil:
+				problems = append(problems, fmt.Errorf("log record %d update %d adds an empty edge", record.Sequence, i))
+			}
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// acceptsPlaintext reports whether state written in the clear is read: always
+// without encryption, and only when allowed with it
+func (ss *StateStore) acceptsPlaintext() bool {
+	return ss.cipher == nil || ss.config.AllowPlaintext
+}
+
+// refusePlaintext is the error for state written in the clear when
+// encryption is enabled without allowing it
+func refusePlaintext() error {
+	return fmt.Errorf("%w: state is not encrypted; allow plaintext state to encrypt an existing store", errStateDecryption)
+}
+
+// readSnapshot reads a snapshot file, verifies its version and checksum
+// and decrypts it with cipher if it is encrypted. A plaintext snapshot is
+// refused unless plaintext is set.
+func readSnapshot(path string, cipher *stateCipher, plaintext bool) (*snapshotEnvelope, *coordinatorState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var envelope snapshotEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	stored := envelope.State
+	switch envelope.Version {
+	case snapshotFormatVersion:
+	case sealedSnapshotFormatVersion:
+		stored = envelope.Sealed
+	default:
+		return nil, nil, fmt.Errorf("unsupported snapshot version %d", envelope.Version)
+	}
+
+	checksum := sha256.Sum256(stored)
+	if hex.EncodeToString(checksum[:]) != envelope.Checksum {
+		return nil, nil, fmt.Errorf("snapshot checksum mismatch")
+	}
+
+	opened := []byte(envelope.State)
+	if envelope.Version == sealedSnapshotFormatVersion {
+		if opened, err = openState(cipher, envelope.Sealed, sealSnapshot); err != nil {
+			return nil, nil, fmt.Errorf("snapshot %s: %w", path, err)
+		}
+	} else if !plaintext {
+		return nil, nil, fmt.Errorf("snapshot %s: %w", path, refusePlaintext())
+	}
+
+	state := &coordinatorState{}
+	if err := json.Unmarshal(opened, state); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode snapshot state: %w", err)
+	}
+
+	return &envelope, state, nil
+}
+
+// decodeRecord decodes one framed record, decrypting it with cipher if it
+// is encrypted, and returns its size. A plaintext record is refused unless
+// plaintext is set.
+func decodeRecord(data []byte, cipher *stateCipher, plaintext bool) (walRecord, int, error) {
+	var record walRecord
+
+	if len(data) < walHeaderSize {
+		return record, 0, io.ErrUnexpectedEOF
+	}
+	length := binary.BigEndian.Uint32(data[0:4])
+	if length > maxWALRecordBytes {
+		return record, 0, fmt.Errorf("record length %d exceeds limit", length)
+	}
+	size := walHeaderSize + int(length)
+	if len(data) < size {
+		return record, 0, io.ErrUnexpectedEOF
+	}
+
+	payload := data[walHeaderSize:size]
+	if crc32.Checksum(payload, walChecksumTable) != binary.BigEndian.Uint32(data[4:8]) {
+		return record, 0, fmt.Errorf("record checksum mismatch")
+	}
+	if len(payload) > 0 && payload[0] == sealedStateVersion {
+		var err error
+		if payload, err = openState(cipher, payload, sealRecord); err != nil {
+			return record, 0, err
+		}
+	} else if !plaintext {
+		return record, 0, refusePlaintext()
+	}
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return record, 0, fmt.Errorf("failed to decode record: %w", err)
+	}
+
+	return record, size, nil
+}
+
+// openState decrypts sealed state, failing with errStateDecryption when
+// cipher is nil or cannot open it
+func openState(cipher *stateCipher, sealed []byte, purpose string) ([]byte, error) {
+	if cipher == nil {
+		return nil, fmt.Errorf("%w: state is encrypted but no state keys are configured", errStateDecryption)
+	}
+	data, err := cipher.open(sealed, purpose)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errStateDecryption, err)
+	}
+	return data, nil
+}
+
+// listSegments returns the log segments in dir ordered by first sequence
+func listSegments(dir string) ([]walSegment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list state directory: %w", err)
+	}
+
+	var segments []walSegment
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, walFilePrefix) || !strings.HasSuffix(name, walFileSuffix) {
+			continue
+		}
+		first, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, walFilePrefix), walFileSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, walSegment{first: first, path: filepath.Join(dir, name)})
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].first < segments[j].first
+	})
+	return segments, nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return errors.Join(file.Sync(), file.Close())
+}
+
+// syncDir makes renames and file creation in dir durable
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open state directory: %w", err)
+	}
+	defer file.Close()
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync state directory: %w", err)
+	}
+	return nil
+}
+
+// openStateStore opens the state store and restores the coordinator from
+// it; callers must hold the write lock
+func (alm *ALMCoordinator) openStateStore() (*StateStore, error) {
+	dir := alm.config.StateDir
+	if dir == "" {
+		nodeID := alm.config.NodeID
+		if nodeID == "" {
+			hostname, err := os.Hostname()
+			if err != nil {
+				return nil, fmt.Errorf("node_id is not set and hostname is unavailable: %w", err)
+			}
+			nodeID = hostname
+		}
+
+		var err error
+		if dir, err = DefaultStateDir(nodeID); err != nil {
+			return nil, err
+		}
+	}
+
+	keys, err := alm.stateKeyProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := NewStateStore(&StateStoreConfig{
+		Dir:              dir,
+		SnapshotInterval: alm.config.SnapshotInterval,
+		SyncWrites:       true,
+		Keys:             keys,
+		AllowPlaintext:   alm.config.StateMigratePlaintext,
+	}, alm.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := alm.recoverState(store)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("state recovery from %s failed: %w", dir, err)
+	}
+
+	alm.logger.Info("ALM state recovered",
+		zap.String("dir", dir),
+		zap.Bool("encrypted", keys != nil),
+		zap.Uint64("snapshot_sequence", report.SnapshotSequence),
+		zap.Bool("used_previous_snapshot", report.UsedPrevious),
+		zap.Int("replayed_records", report.ReplayedRecords),
+		zap.Int64("truncated_bytes", report.TruncatedBytes),
+		zap.Int("nodes", report.Nodes),
+		zap.Int("edges", report.Edges),
+		zap.Int("routes", report.Routes),
+		zap.Int("dropped_routes", report.DroppedRoutes),
+		zap.Int("services", report.Services),
+		zap.Int("associations", report.Associations),
+		zap.Duration("duration", report.Duration),
+	)
+
+	return store, nil
+}
+
+// recoverState runs the store's recovery phases and applies the result:
+// the snapshot topology, then the replayed updates, then route caches and
+// the registry, which depend on the recovered topology. Callers must hold
+// the write lock.
+func (alm *ALMCoordinator) recoverState(store *StateStore) (*RecoveryReport, error) {
+	startTime := time.Now()
+	report := &RecoveryReport{}
+
+	state, records, err := store.recover(report)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range state.Nodes {
+		if err := alm.networkGraph.AddNode(node); err != nil {
+			return nil, fmt.Errorf("failed to restore node %d: %w", node.ID, err)
+		}
+	}
+	for _, edge := range state.Edges {
+		if err := alm.networkGraph.AddEdge(edge); err != nil {
+			return nil, fmt.Errorf("failed to restore edge %d->%d: %w", edge.From, edge.To, err)
+		}
+	}
+
+	for _, record := range records {
+		alm.applyTopologyUpdates(record.Updates)
+	}
+	report.ReplayedRecords = len(records)
+
+	// Cached routes hold their own copies of path nodes; point them back at
+	// the graph and drop those through nodes that are gone
+	routes := make(map[string]*routing.RouteEntry, len(state.Routes))
+	for key, route := range state.Routes {
+		if alm.relinkRoute(route) {
+			routes[key] = route
+		} else {
+			report.DroppedRoutes++
+		}
+	}
+	report.Routes = alm.routingTable.ImportRoutes(routes)
+
+	services, err := alm.serviceRegistry.Restore(state.Registry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore service registry: %w", err)
+	}
+	report.Services = services
+	report.Associations = len(state.Registry.Affinities)
+
+	if err := alm.quotas.Replace(state.Quotas); err != nil {
+		return nil, fmt.Errorf("failed to restore quotas: %w", err)
+	}
+
+	report.Nodes = len(alm.networkGraph.Nodes())
+	report.Edges = len(alm.networkGraph.Edges())
+	report.Duration = time.Since(startTime)
+	return report, nil
+}
+
+// relinkRoute replaces a restored route's path nodes with the graph's nodes,
+// reporting false if any is missing
+func (alm *ALMCoordinator) relinkRoute(route *routing.RouteEntry) bool {
+	for i, pathNode := range route.Path {
+		if pathNode == nil {
+			return false
+		}
+		node, exists := alm.networkGraph.GetNode(pathNode.ID)
+		if !exists {
+			return false
+		}
+		route.Path[i] = node
+	}
+	return true
+}
+
+// SnapshotState writes a snapshot of the coordinator state now. It fails
+// unless persistence is enabled and the coordinator has started.
+func (alm *ALMCoordinator) SnapshotState() error {
+	alm.mutex.RLock()
+	store := alm.stateStore
+	alm.mutex.RUnlock()
+
+	if store == nil {
+		return fmt.Errorf("state persistence is not enabled")
+	}
+	return alm.snapshotState(store)
+}
+
+// snapshotState captures state under the read lock, which excludes log
+// appends, so the snapshot covers exactly the records up to its sequence
+func (alm *ALMCoordinator) snapshotState(store *StateStore) error {
+	store.snapshotMutex.Lock()
+	defer store.snapshotMutex.Unlock()
+
+	startTime := time.Now()
+
+	alm.mutex.RLock()
+	data, err := json.Marshal(alm.captureState())
+	store.mutex.Lock()
+	sequence := store.sequence
+	rotateErr := store.rotateLocked()
+	store.mutex.Unlock()
+	alm.mutex.RUnlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	if rotateErr != nil {
+		return rotateErr
+	}
+
+	if err := store.writeSnapshot(data, sequence); err != nil {
+		return err
+	}
+
+	alm.logger.Debug("ALM state snapshot written",
+		zap.Uint64("sequence", sequence),
+		zap.Int("bytes", len(data)),
+		zap.Duration("duration", time.Since(startTime)),
+	)
+	return nil
+}
+
+// runSnapshots writes a snapshot every SnapshotInterval until ctx is done
+func (alm *ALMCoordinator) runSnapshots(store *StateStore) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		interval := store.config.SnapshotInterval
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-alm.configUpdates():
+				if next := alm.Config().SnapshotInterval; next != interval {
+					interval = next
+					ticker.Reset(interval)
+				}
+			case <-ticker.C:
+				if err := alm.snapshotState(store); err != nil {
+					alm.logger.Error("Failed to write ALM state snapshot", zap.Error(err))
+				}
+			}
+		}
+	}
+}
+
+// DefaultStateStoreConfig returns default state store configuration. Dir
+// must be set before use.
+func DefaultStateStoreConfig() *StateStoreConfig {
+	return &StateStoreConfig{
+		SnapshotInterval: 5 * time.Minute,
+		SyncWrites:       true,
+	}
+}
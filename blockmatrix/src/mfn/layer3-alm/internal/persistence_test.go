@@ -0,0 +1,217 @@
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+// openTestStore opens a state store on dir and recovers it
+func openTestStore(t *testing.T, dir string) (*StateStore, *coordinatorState, []walRecord, *RecoveryReport) {
+	t.Helper()
+
+	store, err := NewStateStore(&StateStoreConfig{Dir: dir}, nil)
+	if err != nil {
+		t.Fatalf("NewStateStore: %v", err)
+	}
+	report := &RecoveryReport{}
+	state, records, err := store.recover(report)
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, state, records, report
+}
+
+// appendNode logs one record adding a node
+func appendNode(t *testing.T, store *StateStore, id int64) {
+	t.Helper()
+
+	update := TopologyUpdate{Type: NodeAddUpdate, Node: &graph.NetworkNode{ID: id}, NodeID: id}
+	if err := store.Append([]TopologyUpdate{update}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+}
+
+// snapshotNodes snapshots a state of the given nodes
+func snapshotNodes(t *testing.T, store *StateStore, ids ...int64) {
+	t.Helper()
+
+	state := &coordinatorState{}
+	for _, id := range ids {
+		state.Nodes = append(state.Nodes, &graph.NetworkNode{ID: id})
+	}
+	snapshotState(t, store, state)
+}
+
+// snapshotState snapshots state the way the coordinator does: rotate the
+// log, then snapshot at its last sequence
+func snapshotState(t *testing.T, store *StateStore, state *coordinatorState) {
+	t.Helper()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("encode state: %v", err)
+	}
+
+	store.mutex.Lock()
+	sequence := store.sequence
+	err = store.rotateLocked()
+	store.mutex.Unlock()
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if err := store.writeSnapshot(data, sequence); err != nil {
+		t.Fatalf("writeSnapshot: %v", err)
+	}
+}
+
+func TestStateStoreRecoversSnapshotAndLog(t *testing.T) {
+	dir := t.TempDir()
+
+	store, state, records, _ := openTestStore(t, dir)
+	if len(state.Nodes) != 0 || len(records) != 0 {
+		t.Fatalf("first start recovered %d nodes and %d records", len(state.Nodes), len(records))
+	}
+	appendNode(t, store, 1)
+	appendNode(t, store, 2)
+	snapshotNodes(t, store, 1, 2)
+	appendNode(t, store, 3)
+	store.Close()
+
+	// Only the record after the snapshot is replayed
+	store, state, records, report := openTestStore(t, dir)
+	if report.SnapshotSequence != 2 || len(state.Nodes) != 2 {
+		t.Fatalf("recovered snapshot %d with %d nodes, want 2 with 2", report.SnapshotSequence, len(state.Nodes))
+	}
+	if len(records) != 1 || records[0].Sequence != 3 || records[0].Updates[0].NodeID != 3 {
+		t.Fatalf("replayed %+v, want record 3 adding node 3", records)
+	}
+
+	// Appends continue the sequence
+	appendNode(t, store, 4)
+	store.Close()
+	_, _, records, _ = openTestStore(t, dir)
+	if len(records) != 2 || records[1].Sequence != 4 {
+		t.Errorf("replayed %d records, want 3 and 4", len(records))
+	}
+}
+
+func TestStateStoreTruncatesTornTail(t *testing.T) {
+	dir := t.TempDir()
+
+	store, _, _, _ := openTestStore(t, dir)
+	appendNode(t, store, 1)
+	appendNode(t, store, 2)
+	segment := store.segments[0].path
+	store.Close()
+
+	// A crash mid-write leaves a header promising more than was written
+	file, err := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte{0, 0, 0, 100, 1, 2, 3, 4, '{'})
+	file.Close()
+
+	store, _, records, report := openTestStore(t, dir)
+	if len(records) != 2 || report.TruncatedBytes != 9 {
+		t.Fatalf("recovered %d records and truncated %d bytes, want 2 and 9", len(records), report.TruncatedBytes)
+	}
+
+	// The log is usable past the truncation
+	appendNode(t, store, 3)
+	store.Close()
+	if _, _, records, _ = openTestStore(t, dir); len(records) != 3 {
+		t.Errorf("recovered %d records after appending past a torn tail, want 3", len(records))
+	}
+}
+
+func TestStateStoreRejectsDamageBeforeTail(t *testing.T) {
+	dir := t.TempDir()
+
+	store, _, _, _ := openTestStore(t, dir)
+	appendNode(t, store, 1)
+	store.mutex.Lock()
+	store.rotateLocked()
+	store.mutex.Unlock()
+	appendNode(t, store, 2)
+	first := store.segments[0].path
+	store.Close()
+
+	data, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-2] ^= 0xff
+	if err := os.WriteFile(first, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewStateStore(&StateStoreConfig{Dir: dir}, nil)
+	if err != nil {
+		t.Fatalf("NewStateStore: %v", err)
+	}
+	if _, _, err := reopened.recover(&RecoveryReport{}); err == nil {
+		t.Error("recovered past a corrupt record in an earlier segment")
+	}
+}
+
+func TestStateStoreFallsBackToPreviousSnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	store, _, _, _ := openTestStore(t, dir)
+	appendNode(t, store, 1)
+	snapshotNodes(t, store, 1)
+	appendNode(t, store, 2)
+	snapshotNodes(t, store, 1, 2)
+	appendNode(t, store, 3)
+	store.Close()
+
+	latest := filepath.Join(dir, snapshotFileName)
+	if err := os.WriteFile(latest, []byte(`{"Version":1,"Checksum":"00"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The previous snapshot is promoted and the log it needs was kept
+	_, state, records, report := openTestStore(t, dir)
+	if !report.UsedPrevious || report.SnapshotSequence != 1 || len(state.Nodes) != 1 {
+		t.Fatalf("report %+v with %d nodes, want the previous snapshot at 1", report, len(state.Nodes))
+	}
+	if len(records) != 2 || records[0].Sequence != 2 {
+		t.Errorf("replayed %d records, want 2 and 3", len(records))
+	}
+
+	quarantined, _ := filepath.Glob(latest + ".corrupt-*")
+	if len(quarantined) != 1 {
+		t.Errorf("corrupt snapshot quarantined as %v", quarantined)
+	}
+}
+
+func TestVerifyState(t *testing.T) {
+	node := func(id int64) *graph.NetworkNode { return &graph.NetworkNode{ID: id} }
+
+	valid := &coordinatorState{
+		Nodes: []*graph.NetworkNode{node(1), node(2)},
+		Edges: []*graph.NetworkEdge{{From: 1, To: 2}},
+	}
+	if err := verifyState(valid, []walRecord{{Sequence: 1, Updates: []TopologyUpdate{{Type: EdgeRemoveUpdate}}}}); err != nil {
+		t.Errorf("verifyState(valid) = %v", err)
+	}
+
+	duplicate := &coordinatorState{Nodes: []*graph.NetworkNode{node(1), node(1)}}
+	if err := verifyState(duplicate, nil); err == nil {
+		t.Error("duplicate node accepted")
+	}
+	dangling := &coordinatorState{Nodes: []*graph.NetworkNode{node(1)}, Edges: []*graph.NetworkEdge{{From: 1, To: 3}}}
+	if err := verifyState(dangling, nil); err == nil {
+		t.Error("edge to a missing node accepted")
+	}
+	emptyAdd := []walRecord{{Sequence: 1, Updates: []TopologyUpdate{{Type: NodeAddUpdate}}}}
+	if err := verifyState(&coordinatorState{}, emptyAdd); err == nil {
+		t.Error("log record adding an empty node accepted")
+	}
+}
@@ -0,0 +1,188 @@
+// Package internal implements signed routing policies and topology imports
+package internal
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Kinds of signed policy, naming what the payload changes
+const (
+	// PolicyKindConfig is a JSON object of config keys, as ApplyConfig takes
+	PolicyKindConfig = "config"
+
+	// PolicyKindFeatureFlag is a JSON FeatureFlag added or replaced
+	PolicyKindFeatureFlag = "feature_flag"
+
+	// PolicyKindTopology is a JSON list of TopologyUpdate imported into the
+	// network graph
+	PolicyKindTopology = "topology"
+)
+
+// Errors returned when a policy is refused
+var (
+	ErrPolicyUnsigned  = errors.New("policy must be signed")
+	ErrPolicySignature = errors.New("invalid policy signature")
+)
+
+// policySignatureContext prefixes every signed message, so signatures made
+// for anything else never verify as policies
+const policySignatureContext = "alm-policy/v1"
+
+// SignedPolicy is a routing policy or topology import signed with an
+// ed25519 key. The signature covers the kind, the expiry and the payload,
+// so a payload cannot be replayed as another kind or past its expiry.
+type SignedPolicy struct {
+	Kind  string `json:"kind"`
+	KeyID string `json:"key_id"`
+
+	// Unix time in seconds after which the policy is refused; zero never
+	// expires
+	Expires int64 `json:"expires,omitempty"`
+
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
+
+// signedMessage returns the bytes the signature covers
+func (sp *SignedPolicy) signedMessage() []byte {
+	header := policySignatureContext + "\n" + sp.Kind + "\n" + strconv.FormatInt(sp.Expires, 10) + "\n"
+	return append([]byte(header), sp.Payload...)
+}
+
+// SignPolicy signs payload as a policy of kind with the trust anchor key
+// keyID, expiring at expires unless it is zero
+func SignPolicy(kind, keyID string, payload []byte, expires time.Time, key ed25519.PrivateKey) SignedPolicy {
+	policy := SignedPolicy{Kind: kind, KeyID: keyID, Payload: payload}
+	if !expires.IsZero() {
+		policy.Expires = expires.Unix()
+	}
+	policy.Signature = ed25519.Sign(key, policy.signedMessage())
+	return policy
+}
+
+// TrustAnchors are the public keys, by key ID, that policies must be
+// signed with
+type TrustAnchors map[string]ed25519.PublicKey
+
+// ParseTrustAnchors decodes base64 ed25519 public keys keyed by key ID
+func ParseTrustAnchors(encoded map[string]string) (TrustAnchors, error) {
+	anchors := make(TrustAnchors, len(encoded))
+	var problems []error
+	for keyID, text := range encoded {
+		key, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("trust anchor %q: %w", keyID, err))
+			continue
+		}
+		if len(key) != ed25519.PublicKeySize {
+			problems = append(problems, fmt.Errorf("trust anchor %q: expected a %d byte ed25519 public key, got %d bytes",
+				keyID, ed25519.PublicKeySize, len(key)))
+			continue
+		}
+		anchors[keyID] = ed25519.PublicKey(key)
+	}
+	return anchors, errors.Join(problems...)
+}
+
+// Verify checks that policy is of kind, unexpired at now and signed by one
+// of the anchors
+func (ta TrustAnchors) Verify(policy SignedPolicy, kind string, now time.Time) error {
+	if policy.Kind != kind {
+		return fmt.Errorf("%w: expected a %s policy, got %q", ErrPolicySignature, kind, policy.Kind)
+	}
+	key, ok := ta[policy.KeyID]
+	if !ok {
+		return fmt.Errorf("%w: unknown key %q", ErrPolicySignature, policy.KeyID)
+	}
+	if !ed25519.Verify(key, policy.signedMessage(), policy.Signature) {
+		return fmt.Errorf("%w: signature does not match key %q", ErrPolicySignature, policy.KeyID)
+	}
+	if policy.Expires != 0 && now.Unix() > policy.Expires {
+		return fmt.Errorf("%w: expired at %s", ErrPolicySignature, time.Unix(policy.Expires, 0).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// KeyIDs returns the anchor key IDs, sorted
+func (ta TrustAnchors) KeyIDs() []string {
+	ids := make([]string, 0, len(ta))
+	for id := range ta {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// PolicySigningRequired reports whether trust anchors are configured, in
+// which case routing policies and topology imports from the admin and gRPC
+// APIs must be signed
+func (alm *ALMCoordinator) PolicySigningRequired() bool {
+	return len(alm.trustAnchors) > 0
+}
+
+// CheckUnsignedPolicy returns an error wrapping ErrPolicyUnsigned when an
+// unsigned change of kind must be refused. API handlers call it before
+// applying config, feature flag or topology changes their callers did not
+// sign.
+func (alm *ALMCoordinator) CheckUnsignedPolicy(kind string) error {
+	if alm.PolicySigningRequired() {
+		return fmt.Errorf("%w: %s changes require a signed policy", ErrPolicyUnsigned, kind)
+	}
+	return nil
+}
+
+// ApplySignedPolicy verifies policy against the trust anchors and applies
+// its payload, returning the number of config keys changed, flags set or
+// topology updates imported
+func (alm *ALMCoordinator) ApplySignedPolicy(policy SignedPolicy) (int, error) {
+	if !alm.PolicySigningRequired() {
+		return 0, fmt.Errorf("%w: no trust anchors are configured", ErrPolicySignature)
+	}
+	if err := alm.trustAnchors.Verify(policy, policy.Kind, time.Now()); err != nil {
+		return 0, err
+	}
+
+	switch policy.Kind {
+	case PolicyKindConfig:
+		var delta ConfigDelta
+		if err := json.Unmarshal(policy.Payload, &delta); err != nil {
+			return 0, fmt.Errorf("invalid config policy: %w", err)
+		}
+		changed, err := alm.ApplyConfig(delta)
+		return len(changed), err
+
+	case PolicyKindFeatureFlag:
+		var flag FeatureFlag
+		if err := json.Unmarshal(policy.Payload, &flag); err != nil {
+			return 0, fmt.Errorf("invalid feature flag policy: %w", err)
+		}
+		if err := alm.SetFeatureFlag(flag); err != nil {
+			return 0, err
+		}
+		return 1, nil
+
+	case PolicyKindTopology:
+		var updates []TopologyUpdate
+		if err := json.Unmarshal(policy.Payload, &updates); err != nil {
+			return 0, fmt.Errorf("invalid topology policy: %w", err)
+		}
+		for i, update := range updates {
+			if err := update.Validate(); err != nil {
+				return 0, fmt.Errorf("invalid topology policy: update %d: %w", i, err)
+			}
+		}
+		if err := alm.UpdateNetworkTopology(updates); err != nil {
+			return 0, err
+		}
+		return len(updates), nil
+	}
+
+	return 0, fmt.Errorf("unknown policy kind %q", policy.Kind)
+}
@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTrustAnchorsVerify(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anchors, err := ParseTrustAnchors(map[string]string{"ops": base64.StdEncoding.EncodeToString(public)})
+	if err != nil {
+		t.Fatalf("ParseTrustAnchors: %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	payload := []byte(`{"route_cache_size":20000}`)
+	signed := SignPolicy(PolicyKindConfig, "ops", payload, now.Add(time.Hour), private)
+
+	cases := []struct {
+		name   string
+		policy func() SignedPolicy
+		kind   string
+		now    time.Time
+		ok     bool
+	}{
+		{name: "valid", policy: func() SignedPolicy { return signed }, kind: PolicyKindConfig, now: now, ok: true},
+		{name: "other kind", policy: func() SignedPolicy { return signed }, kind: PolicyKindTopology, now: now},
+		{name: "expired", policy: func() SignedPolicy { return signed }, kind: PolicyKindConfig, now: now.Add(2 * time.Hour)},
+		{
+			name: "payload changed",
+			policy: func() SignedPolicy {
+				policy := signed
+				policy.Payload = []byte(`{"route_cache_size":1}`)
+				return policy
+			},
+			kind: PolicyKindConfig, now: now,
+		},
+		{
+			name: "expiry lifted",
+			policy: func() SignedPolicy {
+				policy := signed
+				policy.Expires = 0
+				return policy
+			},
+			kind: PolicyKindConfig, now: now,
+		},
+		{
+			name: "kind changed",
+			policy: func() SignedPolicy {
+				policy := signed
+				policy.Kind = PolicyKindFeatureFlag
+				return policy
+			},
+			kind: PolicyKindFeatureFlag, now: now,
+		},
+		{
+			name:   "unknown key",
+			policy: func() SignedPolicy { return SignPolicy(PolicyKindConfig, "dev", payload, time.Time{}, private) },
+			kind:   PolicyKindConfig, now: now,
+		},
+		{
+			name:   "untrusted signer",
+			policy: func() SignedPolicy { return SignPolicy(PolicyKindConfig, "ops", payload, time.Time{}, other) },
+			kind:   PolicyKindConfig, now: now,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := anchors.Verify(tc.policy(), tc.kind, tc.now)
+			if tc.ok && err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+			if !tc.ok && !errors.Is(err, ErrPolicySignature) {
+				t.Errorf("Verify: got %v, want ErrPolicySignature", err)
+			}
+		})
+	}
+}
+
+func TestParseTrustAnchorsRejectsInvalidKeys(t *testing.T) {
+	_, err := ParseTrustAnchors(map[string]string{
+		"short":   base64.StdEncoding.EncodeToString([]byte("too short")),
+		"garbled": "not base64!",
+	})
+	if err == nil {
+		t.Fatal("invalid trust anchors accepted")
+	}
+}
+
+func TestApplySignedPolicy(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultALMConfig()
+	config.MaxNodes = 1000
+	config.MaxEdges = 10000
+	config.TrustAnchors = map[string]string{"ops": base64.StdEncoding.EncodeToString(public)}
+	coordinator, err := NewALMCoordinator(config, nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+
+	if err := coordinator.CheckUnsignedPolicy(PolicyKindConfig); !errors.Is(err, ErrPolicyUnsigned) {
+		t.Errorf("CheckUnsignedPolicy: got %v, want ErrPolicyUnsigned", err)
+	}
+
+	policy := SignPolicy(PolicyKindConfig, "ops", []byte(`{"route_cache_size":2000}`), time.Time{}, private)
+	if applied, err := coordinator.ApplySignedPolicy(policy); err != nil || applied != 1 {
+		t.Fatalf("ApplySignedPolicy: applied %d, %v", applied, err)
+	}
+	if got := coordinator.Config().RouteCacheSize; got != 2000 {
+		t.Errorf("route_cache_size is %d, want 2000", got)
+	}
+
+	policy = SignPolicy(PolicyKindTopology, "ops", []byte(`[{"Type":0}]`), time.Time{}, private)
+	if _, err := coordinator.ApplySignedPolicy(policy); err == nil {
+		t.Error("topology import of a node add without a node applied")
+	}
+
+	if _, err := coordinator.ApplyConfig(ConfigDelta{"trust_anchors": map[string]interface{}{}}); err == nil {
+		t.Error("trust anchors changed at runtime")
+	}
+}
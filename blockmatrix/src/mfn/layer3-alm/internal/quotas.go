@@ -0,0 +1,453 @@
+// Package internal implements per-tenant and per-service quotas on route lookups and discovery
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Quota scopes
+const (
+	QuotaScopeTenant  = "tenant"
+	QuotaScopeService = "service"
+)
+
+// Limits a request can exceed
+const (
+	QuotaLimitQPS         = "qps"
+	QuotaLimitConcurrency = "concurrency"
+)
+
+// ErrQuotaExceeded matches every QuotaError with errors.Is
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quota limits the route lookups and service discoveries of one tenant or
+// service: QPS per second sustained with bursts of Burst, at most
+// MaxConcurrent at once, and, for tenants, at most CacheShare of the route
+// cache. Zero leaves a limit off.
+type Quota struct {
+	QPS           float64 `json:"qps,omitempty" yaml:"qps,omitempty"`
+	Burst         int     `json:"burst,omitempty" yaml:"burst,omitempty"`
+	MaxConcurrent int     `json:"max_concurrent,omitempty" yaml:"max_concurrent,omitempty"`
+	CacheShare    float64 `json:"cache_share,omitempty" yaml:"cache_share,omitempty"`
+}
+
+// Validate checks the quota
+func (q *Quota) Validate() error {
+	switch {
+	case q.QPS < 0:
+		return fmt.Errorf("qps must not be negative, got %g", q.QPS)
+	case q.Burst < 0:
+		return fmt.Errorf("burst must not be negative, got %d", q.Burst)
+	case q.MaxConcurrent < 0:
+		return fmt.Errorf("max_concurrent must not be negative, got %d", q.MaxConcurrent)
+	case q.CacheShare < 0 || q.CacheShare > 1:
+		return fmt.Errorf("cache_share must be between 0 and 1, got %g", q.CacheShare)
+	}
+	return nil
+}
+
+// burst returns the bucket size, at least one second of QPS
+func (q *Quota) burst() float64 {
+	if q.Burst > 0 {
+		return float64(q.Burst)
+	}
+	return math.Max(1, math.Ceil(q.QPS))
+}
+
+// QuotaEntry is the quota of one tenant or service
+type QuotaEntry struct {
+	Scope string `json:"scope"`
+	Key   string `json:"key"`
+	Quota Quota  `json:"quota"`
+}
+
+// QuotaStats is the usage of one quota
+type QuotaStats struct {
+	QuotaEntry
+	InFlight     int
+	CachedRoutes int
+	Admitted     int64
+	Rejected     map[string]int64
+	Uncached     int64
+}
+
+// QuotaError is returned when a request exceeds a quota. It is the
+// equivalent of an HTTP 429 response.
+type QuotaError struct {
+	Scope      string
+	Key        string
+	Limit      string
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (qe *QuotaError) Error() string {
+	if qe.Limit == QuotaLimitQPS {
+		return fmt.Sprintf("%s quota of %s %q exceeded, retry after %v", qe.Limit, qe.Scope, qe.Key, qe.RetryAfter)
+	}
+	return fmt.Sprintf("%s quota of %s %q exceeded", qe.Limit, qe.Scope, qe.Key)
+}
+
+// StatusCode returns the HTTP status equivalent of the error
+func (qe *QuotaError) StatusCode() int {
+	return http.StatusTooManyRequests
+}
+
+// Is reports whether target is ErrQuotaExceeded
+func (qe *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// quotaState is a configured quota and its usage
+type quotaState struct {
+	QuotaEntry
+
+	tokens   float64
+	updated  time.Time
+	inFlight int
+
+	// Route cache keys added by the tenant, and when, within the cache TTL
+	cached map[string]time.Time
+
+	admitted int64
+	rejected map[string]int64
+	uncached int64
+}
+
+// Quotas enforces quotas keyed by tenant and by service. A request is
+// admitted only while its tenant's and its service's quotas both allow it,
+// and consumes nothing from either when rejected.
+//
+// A tenant's cache share is counted by the routes its lookups added to the
+// route cache within the cache TTL, an upper bound on what it holds since
+// routes may be evicted sooner. Lookups of a tenant at its share are served
+// without caching their route.
+type Quotas struct {
+	// Route cache capacity and TTL, read outside the mutex when cache
+	// shares are checked
+	cacheCapacity func() int
+	cacheTTL      func() time.Duration
+
+	states map[string]map[string]*quotaState
+	mutex  sync.Mutex
+
+	now func() time.Time
+}
+
+// NewQuotas creates quotas for a route cache of the given capacity and TTL
+func NewQuotas(cacheCapacity func() int, cacheTTL func() time.Duration) *Quotas {
+	return &Quotas{
+		cacheCapacity: cacheCapacity,
+		cacheTTL:      cacheTTL,
+		states: map[string]map[string]*quotaState{
+			QuotaScopeTenant:  make(map[string]*quotaState),
+			QuotaScopeService: make(map[string]*quotaState),
+		},
+		now: time.Now,
+	}
+}
+
+// Set adds or replaces the quota of a tenant or service. Usage of a
+// replaced quota carries over.
+func (qs *Quotas) Set(scope, key string, quota Quota) error {
+	if err := quota.Validate(); err != nil {
+		return fmt.Errorf("%s %q: %w", scope, key, err)
+	}
+	if key == "" {
+		return fmt.Errorf("%s quota requires a key", scope)
+	}
+
+	qs.mutex.Lock()
+	defer qs.mutex.Unlock()
+
+	states, ok := qs.states[scope]
+	if !ok {
+		return fmt.Errorf("unknown quota scope %q", scope)
+	}
+
+	state, exists := states[key]
+	if exists {
+		state.tokens = math.Min(state.tokens, quota.burst())
+	} else {
+		state = &quotaState{
+			tokens:   quota.burst(),
+			updated:  qs.now(),
+			cached:   make(map[string]time.Time),
+			rejected: make(map[string]int64),
+		}
+		states[key] = state
+	}
+	state.QuotaEntry = QuotaEntry{Scope: scope, Key: key, Quota: quota}
+	return nil
+}
+
+// Remove drops the quota of a tenant or service and reports whether it
+// existed
+func (qs *Quotas) Remove(scope, key string) bool {
+	qs.mutex.Lock()
+	defer qs.mutex.Unlock()
+
+	if _, exists := qs.states[scope][key]; !exists {
+		return false
+	}
+	delete(qs.states[scope], key)
+	return true
+}
+
+// Entries returns every quota, tenants first, sorted by key
+func (qs *Quotas) Entries() []QuotaEntry {
+	qs.mutex.Lock()
+	defer qs.mutex.Unlock()
+
+	entries := make([]QuotaEntry, 0)
+	for _, scope := range []string{QuotaScopeTenant, QuotaScopeService} {
+		for _, state := range qs.sortedLocked(scope) {
+			entries = append(entries, state.QuotaEntry)
+		}
+	}
+	return entries
+}
+
+// Replace swaps in a new set of quotas, such as restored state. Usage of
+// quotas that remain is kept.
+func (qs *Quotas) Replace(entries []QuotaEntry) error {
+	for _, entry := range entries {
+		if err := entry.Quota.Validate(); err != nil {
+			return fmt.Errorf("%s %q: %w", entry.Scope, entry.Key, err)
+		}
+		if entry.Scope != QuotaScopeTenant && entry.Scope != QuotaScopeService {
+			return fmt.Errorf("unknown quota scope %q", entry.Scope)
+		}
+	}
+
+	qs.mutex.Lock()
+	previous := qs.states
+	qs.states = map[string]map[string]*quotaState{
+		QuotaScopeTenant:  make(map[string]*quotaState),
+		QuotaScopeService: make(map[string]*quotaState),
+	}
+	for _, entry := range entries {
+		if state, exists := previous[entry.Scope][entry.Key]; exists {
+			qs.states[entry.Scope][entry.Key] = state
+		}
+	}
+	qs.mutex.Unlock()
+
+	for _, entry := range entries {
+		if err := qs.Set(entry.Scope, entry.Key, entry.Quota); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats returns the usage of every quota, tenants first, sorted by key
+func (qs *Quotas) Stats() []QuotaStats {
+	ttl := qs.cacheTTL()
+
+	qs.mutex.Lock()
+	defer qs.mutex.Unlock()
+
+	now := qs.now()
+	stats := make([]QuotaStats, 0)
+	for _, scope := range []string{QuotaScopeTenant, QuotaScopeService} {
+		for _, state := range qs.sortedLocked(scope) {
+			expireCachedLocked(state, now, ttl)
+			rejected := make(map[string]int64, len(state.rejected))
+			for limit, count := range state.rejected {
+				rejected[limit] = count
+			}
+			stats = append(stats, QuotaStats{
+				QuotaEntry:   state.QuotaEntry,
+				InFlight:     state.inFlight,
+				CachedRoutes: len(state.cached),
+				Admitted:     state.admitted,
+				Rejected:     rejected,
+				Uncached:     state.uncached,
+			})
+		}
+	}
+	return stats
+}
+
+// Acquire admits a request of tenant to service, returning a release to
+// call when it finishes, or a *QuotaError when either quota is exhausted
+func (qs *Quotas) Acquire(tenant, service string) (release func(), err error) {
+	qs.mutex.Lock()
+	defer qs.mutex.Unlock()
+
+	now := qs.now()
+	var states []*quotaState
+	for _, scoped := range [][2]string{{QuotaScopeTenant, tenant}, {QuotaScopeService, service}} {
+		state, ok := qs.states[scoped[0]][scoped[1]]
+		if !ok {
+			continue
+		}
+		if err := qs.checkLocked(state, now); err != nil {
+			state.rejected[err.Limit]++
+			return nil, err
+		}
+		states = append(states, state)
+	}
+
+	for _, state := range states {
+		state.admitted++
+		if state.Quota.QPS > 0 {
+			state.tokens--
+		}
+		state.inFlight++
+	}
+
+	released := false
+	return func() {
+		qs.mutex.Lock()
+		defer qs.mutex.Unlock()
+
+		if released {
+			return
+		}
+		released = true
+		for _, state := range states {
+			state.inFlight--
+		}
+	}, nil
+}
+
+// CacheAllowed reports whether a lookup of tenant may add its route to the
+// route cache, counting the lookup as uncached when it may not
+func (qs *Quotas) CacheAllowed(tenant string) bool {
+	capacity, ttl := qs.cacheCapacity(), qs.cacheTTL()
+
+	qs.mutex.Lock()
+	defer qs.mutex.Unlock()
+
+	state, ok := qs.states[QuotaScopeTenant][tenant]
+	if !ok || state.Quota.CacheShare == 0 {
+		return true
+	}
+
+	expireCachedLocked(state, qs.now(), ttl)
+	limit := int(state.Quota.CacheShare * float64(capacity))
+	if len(state.cached) < limit {
+		return true
+	}
+	state.uncached++
+	return false
+}
+
+// RecordCached counts a route a lookup of tenant added to the route cache
+// under key
+func (qs *Quotas) RecordCached(tenant, key string) {
+	qs.mutex.Lock()
+	defer qs.mutex.Unlock()
+
+	if state, ok := qs.states[QuotaScopeTenant][tenant]; ok && state.Quota.CacheShare > 0 {
+		state.cached[key] = qs.now()
+	}
+}
+
+// checkLocked returns the limit of state a request at now would exceed,
+// after refilling its tokens. The caller holds the mutex.
+func (qs *Quotas) checkLocked(state *quotaState, now time.Time) *QuotaError {
+	quota := state.Quota
+	if quota.MaxConcurrent > 0 && state.inFlight >= quota.MaxConcurrent {
+		return &QuotaError{Scope: state.Scope, Key: state.Key, Limit: QuotaLimitConcurrency}
+	}
+	if quota.QPS <= 0 {
+		return nil
+	}
+
+	if elapsed := now.Sub(state.updated).Seconds(); elapsed > 0 {
+		state.tokens = math.Min(quota.burst(), state.tokens+elapsed*quota.QPS)
+	}
+	state.updated = now
+	if state.tokens < 1 {
+		retryAfter := time.Duration((1 - state.tokens) / quota.QPS * float64(time.Second))
+		return &QuotaError{Scope: state.Scope, Key: state.Key, Limit: QuotaLimitQPS, RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// expireCachedLocked forgets cached routes older than ttl. The caller
+// holds the mutex.
+func expireCachedLocked(state *quotaState, now time.Time, ttl time.Duration) {
+	for key, added := range state.cached {
+		if now.Sub(added) >= ttl {
+			delete(state.cached, key)
+		}
+	}
+}
+
+// sortedLocked returns the quotas of scope sorted by key. The caller holds
+// the mutex.
+func (qs *Quotas) sortedLocked(scope string) []*quotaState {
+	states := make([]*quotaState, 0, len(qs.states[scope]))
+	for _, state := range qs.states[scope] {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Key < states[j].Key })
+	return states
+}
+
+// newQuotas bounds tenant cache shares by the routing table's configured
+// cache size and TTL
+func (alm *ALMCoordinator) newQuotas() *Quotas {
+	return NewQuotas(
+		func() int { return alm.routingTable.Config().CacheSize },
+		func() time.Duration { return alm.routingTable.Config().CacheTTL },
+	)
+}
+
+// Quotas returns the per-tenant and per-service quotas
+func (alm *ALMCoordinator) Quotas() *Quotas {
+	return alm.quotas
+}
+
+// SetQuota adds or replaces the quota of a tenant or service and, when
+// persistence is enabled, snapshots the state so it survives a restart
+func (alm *ALMCoordinator) SetQuota(scope, key string, quota Quota) error {
+	if err := alm.quotas.Set(scope, key, quota); err != nil {
+		return err
+	}
+	alm.persistQuotas()
+	return nil
+}
+
+// RemoveQuota drops the quota of a tenant or service and reports whether it
+// existed
+func (alm *ALMCoordinator) RemoveQuota(scope, key string) bool {
+	removed := alm.quotas.Remove(scope, key)
+	if removed {
+		alm.persistQuotas()
+	}
+	return removed
+}
+
+// persistQuotas snapshots the state after a quota change when persistence
+// is enabled
+func (alm *ALMCoordinator) persistQuotas() {
+	alm.mutex.RLock()
+	store := alm.stateStore
+	alm.mutex.RUnlock()
+
+	if store == nil {
+		return
+	}
+	if err := alm.snapshotState(store); err != nil {
+		alm.logger.Error("Failed to persist quota change", zap.Error(err))
+	}
+}
+
+// admitQuota charges a request to the tenant in ctx and to service
+func (alm *ALMCoordinator) admitQuota(ctx context.Context, service string) (release func(), err error) {
+	return alm.quotas.Acquire(TenantFromContext(ctx), service)
+}
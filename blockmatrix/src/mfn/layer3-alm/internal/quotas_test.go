@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestQuotas returns quotas over a cache of 10 routes with a minute TTL,
+// driven by the returned clock
+func newTestQuotas() (*Quotas, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	quotas := NewQuotas(func() int { return 10 }, func() time.Duration { return time.Minute })
+	quotas.now = func() time.Time { return now }
+	return quotas, &now
+}
+
+func TestQuotasQPS(t *testing.T) {
+	quotas, now := newTestQuotas()
+	if err := quotas.Set(QuotaScopeTenant, "acme", Quota{QPS: 2, Burst: 2}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		release, err := quotas.Acquire("acme", "search")
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		release()
+	}
+
+	_, err := quotas.Acquire("acme", "search")
+	var quotaErr *QuotaError
+	if !errors.As(err, &quotaErr) || !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("third request: got %v, want a QuotaError", err)
+	}
+	if quotaErr.Limit != QuotaLimitQPS || quotaErr.RetryAfter != 500*time.Millisecond {
+		t.Errorf("got limit %s retry after %v, want qps after 500ms", quotaErr.Limit, quotaErr.RetryAfter)
+	}
+
+	// Other tenants are not charged
+	if _, err := quotas.Acquire("other", "search"); err != nil {
+		t.Errorf("other tenant: %v", err)
+	}
+
+	*now = now.Add(500 * time.Millisecond)
+	if _, err := quotas.Acquire("acme", "search"); err != nil {
+		t.Errorf("after refill: %v", err)
+	}
+
+	stats := quotas.Stats()
+	if len(stats) != 1 || stats[0].Admitted != 3 || stats[0].Rejected[QuotaLimitQPS] != 1 {
+		t.Errorf("stats: %+v", stats)
+	}
+}
+
+func TestQuotasConcurrency(t *testing.T) {
+	quotas, _ := newTestQuotas()
+	if err := quotas.Set(QuotaScopeService, "search", Quota{MaxConcurrent: 1}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := quotas.Set(QuotaScopeTenant, "acme", Quota{MaxConcurrent: 1}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	release, err := quotas.Acquire("other", "search")
+	if err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+
+	_, err = quotas.Acquire("acme", "search")
+	var quotaErr *QuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.Scope != QuotaScopeService || quotaErr.Limit != QuotaLimitConcurrency {
+		t.Fatalf("second request: got %v, want the service concurrency quota exceeded", err)
+	}
+
+	// The rejected request holds nothing from the tenant quota it passed
+	if _, err := quotas.Acquire("acme", "index"); err != nil {
+		t.Errorf("tenant request to another service: %v", err)
+	}
+
+	release()
+	release()
+	if release, err := quotas.Acquire("other", "search"); err != nil {
+		t.Errorf("after release: %v", err)
+	} else {
+		release()
+	}
+}
+
+func TestQuotasCacheShare(t *testing.T) {
+	quotas, now := newTestQuotas()
+	if err := quotas.Set(QuotaScopeTenant, "acme", Quota{CacheShare: 0.2}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	for _, key := range []string{"a", "b"} {
+		if !quotas.CacheAllowed("acme") {
+			t.Fatalf("route %s not allowed in the cache", key)
+		}
+		quotas.RecordCached("acme", key)
+	}
+	if quotas.CacheAllowed("acme") {
+		t.Error("tenant at its share of 2 routes allowed to cache more")
+	}
+	if !quotas.CacheAllowed("other") {
+		t.Error("tenant without a quota denied the cache")
+	}
+
+	stats := quotas.Stats()
+	if stats[0].CachedRoutes != 2 || stats[0].Uncached != 1 {
+		t.Errorf("stats: %+v", stats[0])
+	}
+
+	*now = now.Add(time.Minute)
+	if !quotas.CacheAllowed("acme") {
+		t.Error("cached routes not forgotten after the cache TTL")
+	}
+}
+
+func TestQuotasReplace(t *testing.T) {
+	quotas, _ := newTestQuotas()
+	if err := quotas.Set(QuotaScopeTenant, "acme", Quota{MaxConcurrent: 2}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := quotas.Set(QuotaScopeService, "search", Quota{QPS: 5}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := quotas.Acquire("acme", "index"); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	entries := []QuotaEntry{{Scope: QuotaScopeTenant, Key: "acme", Quota: Quota{MaxConcurrent: 1}}}
+	if err := quotas.Replace(entries); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if got := quotas.Entries(); len(got) != 1 || got[0] != entries[0] {
+		t.Errorf("entries: got %+v, want %+v", got, entries)
+	}
+
+	// The request in flight before the replace still counts
+	if _, err := quotas.Acquire("acme", "index"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("got %v, want the concurrency quota exceeded", err)
+	}
+
+	invalid := []QuotaEntry{{Scope: QuotaScopeTenant, Key: "acme", Quota: Quota{CacheShare: 2}}}
+	if err := quotas.Replace(invalid); err == nil {
+		t.Error("invalid quota replaced")
+	}
+	if err := quotas.Set("region", "eu", Quota{}); err == nil {
+		t.Error("quota of an unknown scope set")
+	}
+}
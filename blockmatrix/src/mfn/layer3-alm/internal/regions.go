@@ -0,0 +1,706 @@
+// Package internal implements multi-region coordinator hierarchies with route summarization
+package internal
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"go.uber.org/zap"
+)
+
+// ErrNoRegionRoute is returned when a destination belongs to a known region
+// that cannot be reached through the known gateways
+var ErrNoRegionRoute = errors.New("no route to region")
+
+// NodeRange is an inclusive range of node IDs
+type NodeRange struct {
+	From int64
+	To   int64
+}
+
+// RegionGateway is a border node of a region, with the aggregate cost of
+// reaching the region's nodes from it
+type RegionGateway struct {
+	NodeID  int64
+	Address string
+
+	// Nodes of the region reachable from the gateway, and the mean and
+	// worst latency to them
+	Reachable   int
+	MeanLatency time.Duration
+	MaxLatency  time.Duration
+
+	// Mean bottleneck bandwidth (MB/s) and mean reliability of the paths
+	// to the region's nodes
+	Throughput  float64
+	Reliability float64
+}
+
+// RegionLink is a path between two gateways: across a region between two
+// of its gateways, or a link from a gateway to another region's gateway
+type RegionLink struct {
+	From        int64
+	To          int64
+	Latency     time.Duration
+	Bandwidth   float64
+	Reliability float64
+}
+
+// RegionSummary is what a regional coordinator publishes about its region
+// instead of its graph: the node IDs it owns, its gateways, transit between
+// them and its links to other regions
+type RegionSummary struct {
+	Region      string
+	Coordinator string
+	Sequence    uint64
+	GeneratedAt time.Time
+
+	Nodes    int
+	Ranges   []NodeRange
+	Gateways []RegionGateway
+	Transit  []RegionLink
+	Links    []RegionLink
+}
+
+// Contains reports whether nodeID falls in one of the summary's ranges, and
+// the width of that range
+func (rs *RegionSummary) Contains(nodeID int64) (bool, int64) {
+	index := sort.Search(len(rs.Ranges), func(i int) bool {
+		return rs.Ranges[i].To >= nodeID
+	})
+	if index < len(rs.Ranges) && rs.Ranges[index].From <= nodeID {
+		return true, rs.Ranges[index].To - rs.Ranges[index].From
+	}
+	return false, 0
+}
+
+// RegionExchange carries summaries between a coordinator and its parent in
+// the hierarchy. The child sends the summaries of its subtree and receives
+// every other summary the parent knows.
+type RegionExchange interface {
+	Exchange(ctx context.Context, summaries []RegionSummary) ([]RegionSummary, error)
+}
+
+// RegionConfig configures a RegionHierarchy
+type RegionConfig struct {
+	// Region this coordinator's graph covers; nodes without a region are
+	// counted in it. Empty for a coordinator that only relays summaries.
+	Region string
+
+	// How often the region is summarized and exchanged with the parent
+	SummaryInterval time.Duration
+
+	// Summaries not refreshed within SummaryTTL are dropped
+	SummaryTTL time.Duration
+
+	// Bound on a single exchange with the parent
+	ExchangeTimeout time.Duration
+
+	// Bound on the node ranges in a summary; beyond it the closest ranges
+	// are merged, over-approximating the region
+	MaxRanges int
+
+	// Confidence reported for routes that leave the region, whose remote
+	// segments are estimated from summaries
+	SummarizedConfidence float64
+}
+
+// RegionStats summarizes hierarchy activity
+type RegionStats struct {
+	Region           string
+	Sequence         uint64
+	KnownRegions     []string
+	Exchanges        int64
+	Failures         int64
+	Received         int64
+	Expired          int64
+	SummarizedRoutes int64
+	LastSummary      time.Time
+}
+
+// knownSummary is a summary received from the parent or a child
+type knownSummary struct {
+	summary   RegionSummary
+	fromChild bool
+	received  time.Time
+}
+
+// RegionHierarchy places a coordinator in a tree of regional coordinators.
+// Each coordinator holds only its region's graph, plus the gateways of
+// neighbouring regions it links to, and periodically summarizes the region.
+// Summaries travel up the tree to the parent and the summaries of every
+// other region come back down, so any coordinator can route towards any
+// node in the deployment: locally to a gateway, then from gateway to
+// gateway to the destination region, whose coordinator completes the route.
+type RegionHierarchy struct {
+	coordinator *ALMCoordinator
+	exchange    RegionExchange
+	config      *RegionConfig
+	logger      *zap.Logger
+
+	// Own summary and summaries of other regions by region
+	local    *RegionSummary
+	sequence uint64
+	known    map[string]*knownSummary
+	mutex    sync.RWMutex
+
+	// Counters
+	exchanges        atomic.Int64
+	failures         atomic.Int64
+	received         atomic.Int64
+	expired          atomic.Int64
+	summarizedRoutes atomic.Int64
+}
+
+// NewRegionHierarchy creates a hierarchy member for coordinator. A nil
+// exchange makes it the root of the tree.
+func NewRegionHierarchy(coordinator *ALMCoordinator, exchange RegionExchange, config *RegionConfig, logger *zap.Logger) *RegionHierarchy {
+	if config == nil {
+		config = DefaultRegionConfig()
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &RegionHierarchy{
+		coordinator: coordinator,
+		exchange:    exchange,
+		config:      config,
+		logger:      logger,
+		known:       make(map[string]*knownSummary),
+	}
+}
+
+// Run summarizes the region and exchanges summaries with the parent every
+// SummaryInterval until ctx is done
+func (rh *RegionHierarchy) Run(ctx context.Context) {
+	ticker := time.NewTicker(rh.config.SummaryInterval)
+	defer ticker.Stop()
+
+	for {
+		rh.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh resummarizes the region, expires stale summaries and exchanges
+// with the parent
+func (rh *RegionHierarchy) refresh(ctx context.Context) {
+	if rh.config.Region != "" {
+		summary := rh.Summarize()
+		rh.mutex.Lock()
+		rh.local = summary
+		rh.mutex.Unlock()
+	}
+	rh.expire(time.Now())
+
+	if rh.exchange == nil {
+		return
+	}
+
+	exchangeCtx, cancel := context.WithTimeout(ctx, rh.config.ExchangeTimeout)
+	summaries, err := rh.exchange.Exchange(exchangeCtx, rh.subtree())
+	cancel()
+	rh.exchanges.Add(1)
+	if err != nil {
+		rh.failures.Add(1)
+		if ctx.Err() == nil {
+			rh.logger.Warn("Region summary exchange failed", zap.String("region", rh.config.Region), zap.Error(err))
+		}
+		return
+	}
+	rh.merge(summaries, false)
+}
+
+// Handle serves a child's exchange: it stores the child's summaries and
+// returns every summary known here, including this coordinator's own,
+// except those of the child's subtree
+func (rh *RegionHierarchy) Handle(summaries []RegionSummary) []RegionSummary {
+	rh.merge(summaries, true)
+
+	sent := make(map[string]bool, len(summaries))
+	for _, summary := range summaries {
+		sent[summary.Region] = true
+	}
+
+	rh.mutex.RLock()
+	defer rh.mutex.RUnlock()
+
+	var reply []RegionSummary
+	if rh.local != nil && !sent[rh.local.Region] {
+		reply = append(reply, *rh.local)
+	}
+	for region, known := range rh.known {
+		if !sent[region] {
+			reply = append(reply, known.summary)
+		}
+	}
+	return reply
+}
+
+// Summarize builds the summary of this coordinator's region from its graph
+func (rh *RegionHierarchy) Summarize() *RegionSummary {
+	networkGraph := rh.coordinator.NetworkGraph()
+	region := rh.config.Region
+
+	local := make(map[int64]*graph.NetworkNode)
+	for _, node := range networkGraph.Nodes() {
+		if node.Region == region || node.Region == "" {
+			local[node.ID] = node
+		}
+	}
+
+	// Local links, and links leaving the region from its gateways
+	adjacency := make(map[int64][]RegionLink)
+	gatewayIDs := make(map[int64]bool)
+	var links []RegionLink
+	for _, edge := range networkGraph.Edges() {
+		if edge.Reliability <= 0 {
+			continue
+		}
+		link := RegionLink{From: edge.From, To: edge.To, Latency: edge.Latency, Bandwidth: edge.Bandwidth, Reliability: edge.Reliability}
+
+		_, fromLocal := local[edge.From]
+		_, toLocal := local[edge.To]
+		switch {
+		case fromLocal && toLocal:
+			adjacency[edge.From] = append(adjacency[edge.From], link)
+		case fromLocal:
+			gatewayIDs[edge.From] = true
+			links = append(links, link)
+		}
+	}
+
+	summary := &RegionSummary{
+		Region:      region,
+		Coordinator: rh.coordinator.Config().NodeID,
+		GeneratedAt: time.Now(),
+		Nodes:       len(local),
+		Links:       links,
+	}
+
+	ids := make([]int64, 0, len(local))
+	for id := range local {
+		ids = append(ids, id)
+	}
+	summary.Ranges = summarizeRanges(ids, rh.config.MaxRanges)
+
+	for id := range gatewayIDs {
+		paths := shortestRegionPaths(adjacency, id)
+
+		gateway := RegionGateway{NodeID: id, Address: local[id].Address}
+		var totalLatency time.Duration
+		var totalThroughput, totalReliability float64
+		for target, path := range paths {
+			if _, isLocal := local[target]; !isLocal {
+				continue
+			}
+			gateway.Reachable++
+			totalLatency += path.latency
+			if path.latency > gateway.MaxLatency {
+				gateway.MaxLatency = path.latency
+			}
+			totalThroughput += path.bandwidth
+			totalReliability += path.reliability
+
+			if target != id && gatewayIDs[target] {
+				summary.Transit = append(summary.Transit, RegionLink{
+					From:        id,
+					To:          target,
+					Latency:     path.latency,
+					Bandwidth:   path.bandwidth,
+					Reliability: path.reliability,
+				})
+			}
+		}
+		if gateway.Reachable > 0 {
+			gateway.MeanLatency = totalLatency / time.Duration(gateway.Reachable)
+			gateway.Throughput = totalThroughput / float64(gateway.Reachable)
+			gateway.Reliability = totalReliability / float64(gateway.Reachable)
+		}
+		summary.Gateways = append(summary.Gateways, gateway)
+	}
+	sort.Slice(summary.Gateways, func(i, j int) bool {
+		return summary.Gateways[i].NodeID < summary.Gateways[j].NodeID
+	})
+
+	rh.mutex.Lock()
+	rh.sequence++
+	summary.Sequence = rh.sequence
+	rh.mutex.Unlock()
+
+	return summary
+}
+
+// Resolve returns the summary of the region owning nodeID. When ranges of
+// several regions cover it, the narrowest range wins.
+func (rh *RegionHierarchy) Resolve(nodeID int64) (RegionSummary, bool) {
+	rh.mutex.RLock()
+	defer rh.mutex.RUnlock()
+
+	var best *RegionSummary
+	bestWidth := int64(math.MaxInt64)
+	for _, known := range rh.known {
+		if contains, width := known.summary.Contains(nodeID); contains && width < bestWidth {
+			best, bestWidth = &known.summary, width
+		}
+	}
+	if best == nil {
+		return RegionSummary{}, false
+	}
+	return *best, true
+}
+
+// Route finds a route from a local source to a destination in another
+// region: along the local graph to one of the region's gateways, then from
+// gateway to gateway to the destination region. Path lists the local hops
+// followed by the remote gateways crossed and the destination; latency and
+// metrics beyond the region are estimated from summaries. It returns false
+// when the destination is in no known region.
+func (rh *RegionHierarchy) Route(ctx context.Context, request RouteRequest) (*RouteResponse, bool, error) {
+	target, ok := rh.Resolve(request.DestinationID)
+	if !ok {
+		return nil, false, nil
+	}
+
+	startTime := time.Now()
+	networkGraph := rh.coordinator.NetworkGraph()
+
+	rh.mutex.RLock()
+	local := rh.local
+	summaries := make([]RegionSummary, 0, len(rh.known))
+	for _, known := range rh.known {
+		summaries = append(summaries, known.summary)
+	}
+	rh.mutex.RUnlock()
+
+	if local == nil {
+		return nil, true, fmt.Errorf("%w %s: region not summarized yet", ErrNoRegionRoute, target.Region)
+	}
+
+	// Gateway graph: the source reaches the local gateways over local paths,
+	// gateways reach each other over transit and links, and the destination
+	// region's gateways reach the destination at their mean latency
+	adjacency := make(map[int64][]RegionLink)
+	localPaths := make(map[int64]*graph.OptimalPath)
+	for _, gateway := range local.Gateways {
+		// A source that is itself a gateway leaves over its own links
+		if gateway.NodeID == request.SourceID {
+			continue
+		}
+		path, err := networkGraph.FindShortestPath(request.SourceID, gateway.NodeID)
+		if err != nil {
+			continue
+		}
+		localPaths[gateway.NodeID] = path
+		adjacency[request.SourceID] = append(adjacency[request.SourceID], RegionLink{
+			From:        request.SourceID,
+			To:          gateway.NodeID,
+			Latency:     path.TotalLatency,
+			Bandwidth:   path.MinThroughput,
+			Reliability: path.AvgReliability,
+		})
+	}
+
+	for _, summary := range append(summaries, *local) {
+		for _, link := range summary.Transit {
+			adjacency[link.From] = append(adjacency[link.From], link)
+		}
+		for _, link := range summary.Links {
+			adjacency[link.From] = append(adjacency[link.From], link)
+		}
+	}
+	for _, gateway := range target.Gateways {
+		adjacency[gateway.NodeID] = append(adjacency[gateway.NodeID], RegionLink{
+			From:        gateway.NodeID,
+			To:          request.DestinationID,
+			Latency:     gateway.MeanLatency,
+			Bandwidth:   gateway.Throughput,
+			Reliability: gateway.Reliability,
+		})
+	}
+
+	paths := shortestRegionPaths(adjacency, request.SourceID)
+	destination, reached := paths[request.DestinationID]
+	if !reached {
+		return nil, true, fmt.Errorf("%w %s from node %d", ErrNoRegionRoute, target.Region, request.SourceID)
+	}
+
+	// Walk back to the first hop after the source, then expand the local
+	// segment to the gateway the route leaves through
+	hops := []int64{request.DestinationID}
+	for node := destination.previous; node != request.SourceID; node = paths[node].previous {
+		hops = append(hops, node)
+	}
+
+	path := []int64{request.SourceID}
+	qualityScore := 0.0
+	next := len(hops) - 1
+	if localPath, exists := localPaths[hops[next]]; exists {
+		path = append(path[:0], localPath.NodeIDs...)
+		qualityScore = localPath.CompositeScore
+		next--
+	}
+	for i := next; i >= 0; i-- {
+		path = append(path, hops[i])
+	}
+
+	response := &RouteResponse{
+		Path:              path,
+		TotalLatency:      destination.latency,
+		MinThroughput:     destination.bandwidth,
+		AvgReliability:    destination.reliability,
+		HopCount:          len(path) - 1,
+		QualityScore:      qualityScore,
+		SearchTime:        time.Since(startTime),
+		Confidence:        rh.config.SummarizedConfidence,
+		Weight:            1,
+		DestinationRegion: target.Region,
+	}
+
+	if request.MaxLatency > 0 && response.TotalLatency > request.MaxLatency {
+		return nil, true, fmt.Errorf("%w %s: estimated latency %v exceeds %v", ErrNoRegionRoute, target.Region, response.TotalLatency, request.MaxLatency)
+	}
+
+	rh.summarizedRoutes.Add(1)
+	return response, true, nil
+}
+
+// Stats returns hierarchy counters
+func (rh *RegionHierarchy) Stats() RegionStats {
+	rh.mutex.RLock()
+	defer rh.mutex.RUnlock()
+
+	stats := RegionStats{
+		Region:           rh.config.Region,
+		Sequence:         rh.sequence,
+		Exchanges:        rh.exchanges.Load(),
+		Failures:         rh.failures.Load(),
+		Received:         rh.received.Load(),
+		Expired:          rh.expired.Load(),
+		SummarizedRoutes: rh.summarizedRoutes.Load(),
+	}
+	if rh.local != nil {
+		stats.LastSummary = rh.local.GeneratedAt
+	}
+	for region := range rh.known {
+		stats.KnownRegions = append(stats.KnownRegions, region)
+	}
+	sort.Strings(stats.KnownRegions)
+	return stats
+}
+
+// subtree returns the summaries this coordinator sends to its parent: its
+// own and those received from its children
+func (rh *RegionHierarchy) subtree() []RegionSummary {
+	rh.mutex.RLock()
+	defer rh.mutex.RUnlock()
+
+	var summaries []RegionSummary
+	if rh.local != nil {
+		summaries = append(summaries, *rh.local)
+	}
+	for _, known := range rh.known {
+		if known.fromChild {
+			summaries = append(summaries, known.summary)
+		}
+	}
+	return summaries
+}
+
+// merge stores summaries newer than those already known
+func (rh *RegionHierarchy) merge(summaries []RegionSummary, fromChild bool) {
+	now := time.Now()
+
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	for _, summary := range summaries {
+		if summary.Region == "" || summary.Region == rh.config.Region {
+			continue
+		}
+		if existing, exists := rh.known[summary.Region]; exists &&
+			existing.summary.Coordinator == summary.Coordinator && existing.summary.Sequence >= summary.Sequence {
+			existing.received = now
+			continue
+		}
+
+		rh.known[summary.Region] = &knownSummary{summary: summary, fromChild: fromChild, received: now}
+		rh.received.Add(1)
+	}
+}
+
+// expire drops summaries not refreshed within SummaryTTL
+func (rh *RegionHierarchy) expire(now time.Time) {
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	for region, known := range rh.known {
+		if now.Sub(known.received) > rh.config.SummaryTTL {
+			delete(rh.known, region)
+			rh.expired.Add(1)
+			rh.logger.Info("Region summary expired", zap.String("region", region))
+		}
+	}
+}
+
+// summarizeRanges collapses node IDs into sorted inclusive ranges, merging
+// the ranges separated by the smallest gaps until at most maxRanges remain
+func summarizeRanges(ids []int64, maxRanges int) []NodeRange {
+	if len(ids) == 0 {
+		return nil
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	ranges := []NodeRange{{From: ids[0], To: ids[0]}}
+	for _, id := range ids[1:] {
+		last := &ranges[len(ranges)-1]
+		if id <= last.To+1 {
+			last.To = id
+			continue
+		}
+		ranges = append(ranges, NodeRange{From: id, To: id})
+	}
+
+	if maxRanges <= 0 || len(ranges) <= maxRanges {
+		return ranges
+	}
+
+	// Keep the largest gaps as range boundaries
+	gaps := make([]int, len(ranges)-1)
+	for i := range gaps {
+		gaps[i] = i
+	}
+	sort.Slice(gaps, func(a, b int) bool {
+		gapA := ranges[gaps[a]+1].From - ranges[gaps[a]].To
+		gapB := ranges[gaps[b]+1].From - ranges[gaps[b]].To
+		return gapA > gapB
+	})
+	boundaries := gaps[:maxRanges-1]
+	sort.Ints(boundaries)
+
+	merged := make([]NodeRange, 0, maxRanges)
+	start := 0
+	for _, boundary := range boundaries {
+		merged = append(merged, NodeRange{From: ranges[start].From, To: ranges[boundary].To})
+		start = boundary + 1
+	}
+	merged = append(merged, NodeRange{From: ranges[start].From, To: ranges[len(ranges)-1].To})
+	return merged
+}
+
+// regionPath is the best known path to a node from a shortest path search
+type regionPath struct {
+	latency     time.Duration
+	bandwidth   float64
+	reliability float64
+	previous    int64
+}
+
+// regionQueueItem is a node waiting in the shortest path search
+type regionQueueItem struct {
+	node    int64
+	latency time.Duration
+}
+
+type regionQueue []regionQueueItem
+
+func (q regionQueue) Len() int            { return len(q) }
+func (q regionQueue) Less(i, j int) bool  { return q[i].latency < q[j].latency }
+func (q regionQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *regionQueue) Push(x interface{}) { *q = append(*q, x.(regionQueueItem)) }
+func (q *regionQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// shortestRegionPaths finds the lowest latency paths from source over
+// adjacency, tracking the bottleneck bandwidth and the reliability of each.
+// Links without a bandwidth do not limit it.
+func shortestRegionPaths(adjacency map[int64][]RegionLink, source int64) map[int64]regionPath {
+	paths := map[int64]regionPath{source: {bandwidth: math.MaxFloat64, reliability: 1, previous: source}}
+	done := make(map[int64]bool)
+
+	queue := &regionQueue{{node: source}}
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(regionQueueItem)
+		if done[item.node] {
+			continue
+		}
+		done[item.node] = true
+		current := paths[item.node]
+
+		for _, link := range adjacency[item.node] {
+			latency := current.latency + link.Latency
+			if existing, seen := paths[link.To]; seen && existing.latency <= latency {
+				continue
+			}
+
+			bandwidth := current.bandwidth
+			if link.Bandwidth > 0 && link.Bandwidth < bandwidth {
+				bandwidth = link.Bandwidth
+			}
+			paths[link.To] = regionPath{
+				latency:     latency,
+				bandwidth:   bandwidth,
+				reliability: current.reliability * link.Reliability,
+				previous:    item.node,
+			}
+			heap.Push(queue, regionQueueItem{node: link.To, latency: latency})
+		}
+	}
+
+	for node, path := range paths {
+		if path.bandwidth == math.MaxFloat64 {
+			path.bandwidth = 0
+			paths[node] = path
+		}
+	}
+	return paths
+}
+
+// AttachRegionHierarchy makes this coordinator a member of a regional
+// hierarchy whose parent is reached through exchange; a nil exchange makes
+// it the root. The returned hierarchy's Handle must be served to the
+// children's exchanges. Call before Start.
+func (alm *ALMCoordinator) AttachRegionHierarchy(exchange RegionExchange, config *RegionConfig) *RegionHierarchy {
+	hierarchy := NewRegionHierarchy(alm, exchange, config, alm.logger)
+
+	alm.mutex.Lock()
+	defer alm.mutex.Unlock()
+
+	alm.regions = hierarchy
+	return hierarchy
+}
+
+// Regions returns the coordinator's region hierarchy, or nil
+func (alm *ALMCoordinator) Regions() *RegionHierarchy {
+	return alm.regions
+}
+
+// DefaultRegionConfig returns default region hierarchy configuration
+func DefaultRegionConfig() *RegionConfig {
+	return &RegionConfig{
+		SummaryInterval:      30 * time.Second,
+		SummaryTTL:           2 * time.Minute,
+		ExchangeTimeout:      5 * time.Second,
+		MaxRanges:            1024,
+		SummarizedConfidence: 0.5,
+	}
+}
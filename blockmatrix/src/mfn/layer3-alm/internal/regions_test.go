@@ -0,0 +1,193 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+func TestSummarizeRanges(t *testing.T) {
+	cases := []struct {
+		ids       []int64
+		maxRanges int
+		want      []NodeRange
+	}{
+		{nil, 4, nil},
+		{[]int64{3, 1, 2}, 4, []NodeRange{{1, 3}}},
+		{[]int64{1, 2, 5, 9, 10}, 0, []NodeRange{{1, 2}, {5, 5}, {9, 10}}},
+		// The smallest gap, 2..5, is merged first
+		{[]int64{1, 2, 5, 20, 21}, 2, []NodeRange{{1, 5}, {20, 21}}},
+		{[]int64{1, 10, 100}, 1, []NodeRange{{1, 100}}},
+	}
+	for _, tc := range cases {
+		if got := summarizeRanges(append([]int64(nil), tc.ids...), tc.maxRanges); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("summarizeRanges(%v, %d) = %v, want %v", tc.ids, tc.maxRanges, got, tc.want)
+		}
+	}
+}
+
+func TestRegionResolvePrefersNarrowestRange(t *testing.T) {
+	hierarchy := NewRegionHierarchy(newTestCoordinator(t), nil, nil, nil)
+	hierarchy.merge([]RegionSummary{
+		{Region: "eu", Coordinator: "eu-1", Sequence: 1, Ranges: []NodeRange{{1, 1000}}},
+		{Region: "eu-west", Coordinator: "eu-west-1", Sequence: 1, Ranges: []NodeRange{{100, 199}, {500, 500}}},
+	}, true)
+
+	cases := map[int64]string{1: "eu", 150: "eu-west", 500: "eu-west", 501: "eu", 2000: ""}
+	for nodeID, want := range cases {
+		summary, ok := hierarchy.Resolve(nodeID)
+		if got := summary.Region; got != want || ok != (want != "") {
+			t.Errorf("Resolve(%d) = %q, %v, want %q", nodeID, got, ok, want)
+		}
+	}
+}
+
+func TestRegionMergeKeepsNewestSummary(t *testing.T) {
+	hierarchy := NewRegionHierarchy(newTestCoordinator(t), nil, &RegionConfig{Region: "eu", SummaryTTL: time.Minute}, nil)
+
+	hierarchy.merge([]RegionSummary{{Region: "us", Coordinator: "us-1", Sequence: 2, Nodes: 20}}, false)
+	hierarchy.merge([]RegionSummary{
+		{Region: "us", Coordinator: "us-1", Sequence: 1, Nodes: 10},
+		{Region: "eu", Coordinator: "other", Sequence: 9},
+	}, false)
+
+	if stats := hierarchy.Stats(); len(stats.KnownRegions) != 1 || stats.Received != 1 {
+		t.Fatalf("stats %+v, want only the first us summary stored", stats)
+	}
+	hierarchy.mutex.RLock()
+	summary := hierarchy.known["us"].summary
+	hierarchy.mutex.RUnlock()
+	if summary.Nodes != 20 {
+		t.Errorf("an older summary replaced the newer one: %+v", summary)
+	}
+
+	hierarchy.expire(time.Now().Add(2 * time.Minute))
+	if stats := hierarchy.Stats(); len(stats.KnownRegions) != 0 || stats.Expired != 1 {
+		t.Errorf("stats %+v after the TTL, want the summary expired", stats)
+	}
+}
+
+// regionCoordinator returns a coordinator holding nodes of region and the
+// listed foreign nodes, connected by two-way links of latency
+func regionCoordinator(t *testing.T, region string, nodes []int64, foreign map[int64]string, links [][2]int64, latency time.Duration) *ALMCoordinator {
+	t.Helper()
+
+	config := DefaultALMConfig()
+	config.NodeID = region + "-coordinator"
+	alm, err := NewALMCoordinator(config, nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+
+	var updates []TopologyUpdate
+	for _, id := range nodes {
+		updates = append(updates, TopologyUpdate{Type: NodeAddUpdate, Node: &graph.NetworkNode{ID: id, Region: region, Address: fmt.Sprintf("node-%d", id)}})
+	}
+	for id, foreignRegion := range foreign {
+		updates = append(updates, TopologyUpdate{Type: NodeAddUpdate, Node: &graph.NetworkNode{ID: id, Region: foreignRegion}})
+	}
+	for _, link := range links {
+		for _, edge := range []*graph.NetworkEdge{{From: link[0], To: link[1]}, {From: link[1], To: link[0]}} {
+			edge.Weight = 1
+			edge.Latency = latency
+			edge.Bandwidth = 100
+			edge.Reliability = 1
+			updates = append(updates, TopologyUpdate{Type: EdgeAddUpdate, Edge: edge})
+		}
+	}
+	if err := alm.UpdateNetworkTopology(updates); err != nil {
+		t.Fatalf("UpdateNetworkTopology: %v", err)
+	}
+	return alm
+}
+
+func TestRegionSummarize(t *testing.T) {
+	alm := regionCoordinator(t, "eu", []int64{1, 2, 3}, map[int64]string{100: "us"},
+		[][2]int64{{1, 2}, {2, 3}, {3, 100}}, 10*time.Millisecond)
+	hierarchy := NewRegionHierarchy(alm, nil, &RegionConfig{Region: "eu", MaxRanges: 8}, nil)
+
+	summary := hierarchy.Summarize()
+	if summary.Nodes != 3 || !reflect.DeepEqual(summary.Ranges, []NodeRange{{1, 3}}) {
+		t.Errorf("summary covers %d nodes in %v, want 3 in [1, 3]", summary.Nodes, summary.Ranges)
+	}
+	if len(summary.Gateways) != 1 || summary.Gateways[0].NodeID != 3 {
+		t.Fatalf("gateways %+v, want node 3", summary.Gateways)
+	}
+
+	// From node 3 the region's nodes are 0, 10 and 20ms away
+	gateway := summary.Gateways[0]
+	if gateway.Reachable != 3 || gateway.MeanLatency != 10*time.Millisecond || gateway.MaxLatency != 20*time.Millisecond {
+		t.Errorf("gateway %+v, want 3 reachable at a mean of 10ms and at most 20ms", gateway)
+	}
+	if len(summary.Links) != 1 || summary.Links[0].To != 100 {
+		t.Errorf("links %+v, want the link to node 100", summary.Links)
+	}
+	if summary.Coordinator != "eu-coordinator" || summary.Sequence != 1 {
+		t.Errorf("summary from %s sequence %d", summary.Coordinator, summary.Sequence)
+	}
+}
+
+// parentExchange delivers a child's exchange straight to its parent
+type parentExchange struct {
+	parent *RegionHierarchy
+}
+
+func (pe parentExchange) Exchange(ctx context.Context, summaries []RegionSummary) ([]RegionSummary, error) {
+	return pe.parent.Handle(summaries), nil
+}
+
+func TestRegionRouteAcrossHierarchy(t *testing.T) {
+	config := func(region string) *RegionConfig {
+		config := DefaultRegionConfig()
+		config.Region = region
+		return config
+	}
+
+	eu := regionCoordinator(t, "eu", []int64{1, 2, 3}, map[int64]string{100: "us"},
+		[][2]int64{{1, 2}, {2, 3}, {3, 100}}, 10*time.Millisecond)
+	us := regionCoordinator(t, "us", []int64{100, 101}, map[int64]string{3: "eu"},
+		[][2]int64{{100, 101}, {100, 3}}, 10*time.Millisecond)
+
+	root := NewRegionHierarchy(us, nil, config("us"), nil)
+	child := NewRegionHierarchy(eu, parentExchange{root}, config("eu"), nil)
+
+	ctx := context.Background()
+	if _, found, _ := child.Route(ctx, RouteRequest{SourceID: 1, DestinationID: 101}); found {
+		t.Fatal("routed to a region before any summaries were exchanged")
+	}
+
+	root.refresh(ctx)
+	child.refresh(ctx)
+	if stats := root.Stats(); !reflect.DeepEqual(stats.KnownRegions, []string{"eu"}) {
+		t.Errorf("root knows %v, want eu", stats.KnownRegions)
+	}
+	if stats := child.Stats(); !reflect.DeepEqual(stats.KnownRegions, []string{"us"}) || stats.Exchanges != 1 {
+		t.Errorf("child stats %+v, want us known after one exchange", stats)
+	}
+
+	response, found, err := child.Route(ctx, RouteRequest{SourceID: 1, DestinationID: 101})
+	if err != nil || !found {
+		t.Fatalf("Route(1, 101) = %v, %v", found, err)
+	}
+	if want := []int64{1, 2, 3, 100, 101}; !reflect.DeepEqual(response.Path, want) {
+		t.Errorf("path %v, want %v", response.Path, want)
+	}
+	if response.DestinationRegion != "us" || response.Confidence != 0.5 {
+		t.Errorf("response for region %s with confidence %g", response.DestinationRegion, response.Confidence)
+	}
+
+	// Local 20ms to the gateway, 10ms across, then the us gateway's 5ms mean
+	if response.TotalLatency != 35*time.Millisecond {
+		t.Errorf("estimated latency %v, want 35ms", response.TotalLatency)
+	}
+
+	_, _, err = child.Route(ctx, RouteRequest{SourceID: 1, DestinationID: 101, MaxLatency: 30 * time.Millisecond})
+	if !errors.Is(err, ErrNoRegionRoute) {
+		t.Errorf("Route beyond MaxLatency = %v, want ErrNoRegionRoute", err)
+	}
+}
@@ -0,0 +1,788 @@
+// Package internal implements hot-standby replication of coordinator state
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+	"go.uber.org/zap"
+)
+
+// ErrStandby is returned by UpdateNetworkTopology on a standby coordinator
+// that has not been promoted; its topology only changes through replication
+var ErrStandby = errors.New("coordinator is a standby")
+
+// ReplicationTransport carries replication messages from a primary
+// coordinator to its standby
+type ReplicationTransport interface {
+	Replicate(ctx context.Context, message *ReplicationMessage) (*ReplicationAck, error)
+}
+
+// ReplicationKind identifies what a replication message carries
+type ReplicationKind int
+
+const (
+	// Full state: topology, route cache and registry
+	ReplicateFull ReplicationKind = iota
+
+	// Topology update batches applied since BaseSequence
+	ReplicateDelta
+
+	// Route cache and registry, which change with every lookup and are
+	// synced periodically rather than per change, along with quotas
+	ReplicateCaches
+
+	// Nothing; keeps the standby from failing over
+	ReplicateHeartbeat
+)
+
+// String returns the kind name
+func (k ReplicationKind) String() string {
+	switch k {
+	case ReplicateFull:
+		return "full"
+	case ReplicateDelta:
+		return "delta"
+	case ReplicateCaches:
+		return "caches"
+	case ReplicateHeartbeat:
+		return "heartbeat"
+	default:
+		return "unknown"
+	}
+}
+
+// ReplicationMessage is sent from a primary to its standby
+type ReplicationMessage struct {
+	Kind      ReplicationKind
+	PrimaryID string
+
+	// Identifies one run of the primary; topology sequences restart with it
+	Epoch uint64
+
+	// A delta applies on top of BaseSequence and brings the standby to
+	// Sequence. Full state is at Sequence.
+	BaseSequence uint64
+	Sequence     uint64
+
+	Updates []TopologyUpdate
+
+	// Encoded state for full and cache messages
+	State json.RawMessage
+
+	SentAt time.Time
+}
+
+// ReplicationAck is the standby's reply
+type ReplicationAck struct {
+	// Last sequence the standby has applied
+	Sequence uint64
+
+	// The standby is missing updates and needs full state
+	Resync bool
+
+	// The standby has been promoted and no longer accepts replication
+	Promoted bool
+}
+
+// ReplicationConfig configures a StandbyReplicator on the primary
+type ReplicationConfig struct {
+	// Identifies the primary in messages; defaults to ALMConfig.NodeID
+	PrimaryID string
+
+	// Sent when no other message has been for this long, well below the
+	// standby's FailoverTimeout
+	HeartbeatInterval time.Duration
+
+	// How often the route cache and registry are synced
+	CacheSyncInterval time.Duration
+
+	// Topology batches buffered for sending; on overflow the standby is
+	// resynced with full state
+	QueueSize int
+
+	// Bound on a single message
+	RPCTimeout time.Duration
+}
+
+// StandbyConfig configures a StandbyReceiver
+type StandbyConfig struct {
+	// Take over once no message has arrived from the primary for this long
+	FailoverTimeout time.Duration
+
+	// Promote automatically on failover timeout; otherwise only Promote does
+	AutoPromote bool
+}
+
+// ReplicationStats reports a replicator's or receiver's progress
+type ReplicationStats struct {
+	Epoch       uint64
+	Sequence    uint64
+	FullSyncs   int64
+	Deltas      int64
+	CacheSyncs  int64
+	Heartbeats  int64
+	Failures    int64
+	Resyncs     int64
+	LastContact time.Time
+	Promoted    bool
+	PromotedAt  time.Time
+}
+
+// replicatedBatch is a topology update batch queued for the standby
+type replicatedBatch struct {
+	sequence uint64
+	updates  []TopologyUpdate
+}
+
+// StandbyReplicator streams a primary coordinator's state to a hot standby.
+// Topology updates are sent as they are applied, in order and numbered; the
+// route cache and registry are sent every CacheSyncInterval. A standby that
+// misses a batch, or a send that fails, is caught up with full state.
+type StandbyReplicator struct {
+	coordinator *ALMCoordinator
+	transport   ReplicationTransport
+	config      *ReplicationConfig
+	logger      *zap.Logger
+
+	epoch uint64
+
+	// Sequence of the last queued batch, advanced under the coordinator lock
+	sequence uint64
+	queue    chan replicatedBatch
+	overflow atomic.Bool
+
+	// Counters
+	fullSyncs  atomic.Int64
+	deltas     atomic.Int64
+	cacheSyncs atomic.Int64
+	heartbeats atomic.Int64
+	failures   atomic.Int64
+	resyncs    atomic.Int64
+	lastAck    atomic.Int64
+	acked      atomic.Uint64
+	superseded atomic.Bool
+}
+
+// NewStandbyReplicator creates a replicator sending coordinator's state over transport
+func NewStandbyReplicator(coordinator *ALMCoordinator, transport ReplicationTransport, config *ReplicationConfig, logger *zap.Logger) *StandbyReplicator {
+	if config == nil {
+		config = DefaultReplicationConfig()
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &StandbyReplicator{
+		coordinator: coordinator,
+		transport:   transport,
+		config:      config,
+		logger:      logger,
+		epoch:       uint64(time.Now().UnixNano()),
+		queue:       make(chan replicatedBatch, config.QueueSize),
+	}
+}
+
+// enqueue numbers and queues an applied batch. It runs as a topology
+// listener under the coordinator lock, so sequence order is apply order.
+func (sr *StandbyReplicator) enqueue(updates []TopologyUpdate) {
+	sr.sequence++
+	select {
+	case sr.queue <- replicatedBatch{sequence: sr.sequence, updates: updates}:
+	default:
+		sr.overflow.Store(true)
+	}
+}
+
+// Run replicates until ctx is done. The standby first receives full state.
+func (sr *StandbyReplicator) Run(ctx context.Context) {
+	heartbeat := time.NewTicker(sr.config.HeartbeatInterval)
+	defer heartbeat.Stop()
+	cacheSync := time.NewTicker(sr.config.CacheSyncInterval)
+	defer cacheSync.Stop()
+
+	needFull := true
+	var sent uint64 // Standby's sequence after the last successful send
+	lastSend := time.Time{}
+
+	for ctx.Err() == nil && !sr.superseded.Load() {
+		if sr.overflow.Swap(false) {
+			sr.logger.Warn("Standby replication queue overflowed, resyncing standby")
+			needFull = true
+		}
+
+		if needFull {
+			sequence, err := sr.sendFull(ctx)
+			if err != nil {
+				sr.logger.Warn("Full state replication failed", zap.Error(err))
+				if !sr.wait(ctx, heartbeat.C) {
+					return
+				}
+				continue
+			}
+			needFull, sent, lastSend = false, sequence, time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+
+		case batch := <-sr.queue:
+			if batch.sequence <= sent {
+				continue // Covered by full state
+			}
+			if batch.sequence != sent+1 {
+				needFull = true // A batch was dropped on overflow
+				continue
+			}
+			message := sr.delta(sent, batch)
+			ack, err := sr.send(ctx, message)
+			if err != nil || ack.Resync {
+				needFull = true
+				continue
+			}
+			sr.deltas.Add(1)
+			sent, lastSend = message.Sequence, time.Now()
+
+		case <-cacheSync.C:
+			state, err := sr.captureCaches()
+			if err != nil {
+				sr.logger.Error("Failed to encode caches for replication", zap.Error(err))
+				continue
+			}
+			ack, err := sr.send(ctx, &ReplicationMessage{Kind: ReplicateCaches, BaseSequence: sent, Sequence: sent, State: state})
+			if err != nil || ack.Resync {
+				needFull = true
+				continue
+			}
+			sr.cacheSyncs.Add(1)
+			lastSend = time.Now()
+
+		case <-heartbeat.C:
+			if time.Since(lastSend) < sr.config.HeartbeatInterval {
+				continue
+			}
+			ack, err := sr.send(ctx, &ReplicationMessage{Kind: ReplicateHeartbeat, BaseSequence: sent, Sequence: sent})
+			if err != nil || ack.Resync {
+				needFull = true
+				continue
+			}
+			sr.heartbeats.Add(1)
+			lastSend = time.Now()
+		}
+	}
+}
+
+// delta merges batch with any others already queued into one message,
+// stopping at a gap left by an overflow
+func (sr *StandbyReplicator) delta(base uint64, batch replicatedBatch) *ReplicationMessage {
+	message := &ReplicationMessage{
+		Kind:         ReplicateDelta,
+		BaseSequence: base,
+		Sequence:     batch.sequence,
+		Updates:      append([]TopologyUpdate(nil), batch.updates...),
+	}
+	for {
+		select {
+		case next := <-sr.queue:
+			if next.sequence != message.Sequence+1 {
+				sr.overflow.Store(true)
+				return message
+			}
+			message.Sequence = next.sequence
+			message.Updates = append(message.Updates, next.updates...)
+		default:
+			return message
+		}
+	}
+}
+
+// sendFull sends full state and returns the sequence it covers
+func (sr *StandbyReplicator) sendFull(ctx context.Context) (uint64, error) {
+	alm := sr.coordinator
+
+	// Capture under the read lock, which excludes topology updates, so the
+	// state is exactly at sequence
+	alm.mutex.RLock()
+	state, err := json.Marshal(alm.captureState())
+	sequence := sr.sequence
+	alm.mutex.RUnlock()
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	ack, err := sr.send(ctx, &ReplicationMessage{Kind: ReplicateFull, Sequence: sequence, State: state})
+	if err != nil {
+		return 0, err
+	}
+	if ack.Resync {
+		return 0, fmt.Errorf("standby rejected full state at sequence %d", sequence)
+	}
+
+	sr.fullSyncs.Add(1)
+	sr.logger.Info("Standby synced with full state", zap.Uint64("sequence", sequence), zap.Int("bytes", len(state)))
+	return sequence, nil
+}
+
+// captureCaches encodes the route cache, registry and quotas
+func (sr *StandbyReplicator) captureCaches() (json.RawMessage, error) {
+	alm := sr.coordinator
+	return json.Marshal(&coordinatorState{
+		Routes:   alm.routingTable.ExportRoutes(),
+		Registry: alm.serviceRegistry.Snapshot(),
+		Quotas:   alm.quotas.Entries(),
+	})
+}
+
+func (sr *StandbyReplicator) send(ctx context.Context, message *ReplicationMessage) (*ReplicationAck, error) {
+	message.PrimaryID = sr.config.PrimaryID
+	message.Epoch = sr.epoch
+	message.SentAt = time.Now()
+
+	rpcCtx, cancel := context.WithTimeout(ctx, sr.config.RPCTimeout)
+	defer cancel()
+
+	err := sr.coordinator.faults.Intercept(rpcCtx, FaultReplication)
+	var ack *ReplicationAck
+	if err == nil {
+		ack, err = sr.transport.Replicate(rpcCtx, message)
+	}
+	if err != nil {
+		sr.failures.Add(1)
+		sr.logger.Debug("Replication to standby failed", zap.String("kind", message.Kind.String()), zap.Error(err))
+		return nil, err
+	}
+
+	sr.lastAck.Store(time.Now().UnixNano())
+	sr.acked.Store(ack.Sequence)
+
+	if ack.Promoted {
+		sr.superseded.Store(true)
+		sr.logger.Error("Standby has been promoted; stopping replication. Two coordinators may now be acting as primary.")
+		return nil, fmt.Errorf("standby has been promoted")
+	}
+	if ack.Resync {
+		sr.resyncs.Add(1)
+	}
+	return ack, nil
+}
+
+// wait blocks until tick or ctx is done, reporting false on ctx
+func (sr *StandbyReplicator) wait(ctx context.Context, tick <-chan time.Time) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-tick:
+		return true
+	}
+}
+
+// Stats returns replication progress. Sequence is the last one the standby acknowledged.
+func (sr *StandbyReplicator) Stats() ReplicationStats {
+	stats := ReplicationStats{
+		Epoch:      sr.epoch,
+		Sequence:   sr.acked.Load(),
+		FullSyncs:  sr.fullSyncs.Load(),
+		Deltas:     sr.deltas.Load(),
+		CacheSyncs: sr.cacheSyncs.Load(),
+		Heartbeats: sr.heartbeats.Load(),
+		Failures:   sr.failures.Load(),
+		Resyncs:    sr.resyncs.Load(),
+		Promoted:   sr.superseded.Load(),
+	}
+	if last := sr.lastAck.Load(); last != 0 {
+		stats.LastContact = time.Unix(0, last)
+	}
+	return stats
+}
+
+// StandbyReceiver applies replication messages to a standby coordinator and
+// promotes it when the primary goes quiet. Until promoted the coordinator
+// rejects topology updates from anywhere but the primary.
+type StandbyReceiver struct {
+	coordinator *ALMCoordinator
+	config      *StandbyConfig
+	logger      *zap.Logger
+
+	// Primary run being followed and the last sequence applied from it
+	epoch    uint64
+	sequence uint64
+
+	lastContact time.Time
+	promoted    atomic.Bool
+	promotedAt  time.Time
+	listeners   []func()
+
+	// Counters
+	fullSyncs  atomic.Int64
+	deltas     atomic.Int64
+	cacheSyncs atomic.Int64
+	heartbeats atomic.Int64
+	resyncs    atomic.Int64
+
+	mutex sync.Mutex
+}
+
+// NewStandbyReceiver creates a receiver applying replicated state to coordinator
+func NewStandbyReceiver(coordinator *ALMCoordinator, config *StandbyConfig, logger *zap.Logger) *StandbyReceiver {
+	if config == nil {
+		config = DefaultStandbyConfig()
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &StandbyReceiver{
+		coordinator: coordinator,
+		config:      config,
+		logger:      logger,
+	}
+}
+
+// OnPromote registers a callback invoked once when the standby is promoted,
+// for example to start accepting traffic or attach topology producers
+func (sb *StandbyReceiver) OnPromote(listener func()) {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	sb.listeners = append(sb.listeners, listener)
+}
+
+// Handle applies a message from the primary
+func (sb *StandbyReceiver) Handle(message *ReplicationMessage) *ReplicationAck {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	if sb.promoted.Load() {
+		return &ReplicationAck{Sequence: sb.sequence, Promoted: true}
+	}
+	sb.lastContact = time.Now()
+
+	if message.Kind != ReplicateFull && (message.Epoch != sb.epoch || message.BaseSequence != sb.sequence) {
+		// The primary restarted, or a delta was lost; a heartbeat or cache
+		// sync that is ahead of us means the same
+		sb.resyncs.Add(1)
+		return &ReplicationAck{Sequence: sb.sequence, Resync: true}
+	}
+
+	alm := sb.coordinator
+	switch message.Kind {
+	case ReplicateFull:
+		var state coordinatorState
+		if err := json.Unmarshal(message.State, &state); err != nil {
+			sb.logger.Error("Invalid replicated state", zap.Error(err))
+			return &ReplicationAck{Sequence: sb.sequence, Resync: true}
+		}
+
+		alm.mutex.Lock()
+		updates := reconcileTopology(alm.networkGraph, &state)
+		err := alm.applyReplicatedUpdates(updates)
+		if err == nil {
+			err = alm.replaceCaches(&state)
+		}
+		alm.mutex.Unlock()
+		if err != nil {
+			sb.logger.Error("Failed to apply replicated state", zap.Error(err))
+			return &ReplicationAck{Sequence: sb.sequence, Resync: true}
+		}
+
+		sb.epoch, sb.sequence = message.Epoch, message.Sequence
+		sb.fullSyncs.Add(1)
+		sb.logger.Info("Standby synced with primary",
+			zap.String("primary_id", message.PrimaryID),
+			zap.Uint64("sequence", message.Sequence),
+			zap.Int("topology_changes", len(updates)),
+		)
+
+	case ReplicateDelta:
+		alm.mutex.Lock()
+		err := alm.applyReplicatedUpdates(message.Updates)
+		alm.mutex.Unlock()
+		if err != nil {
+			sb.logger.Error("Failed to apply replicated updates", zap.Error(err))
+			return &ReplicationAck{Sequence: sb.sequence, Resync: true}
+		}
+
+		sb.sequence = message.Sequence
+		sb.deltas.Add(1)
+
+	case ReplicateCaches:
+		var state coordinatorState
+		if err := json.Unmarshal(message.State, &state); err != nil {
+			sb.logger.Error("Invalid replicated caches", zap.Error(err))
+			return &ReplicationAck{Sequence: sb.sequence, Resync: true}
+		}
+
+		alm.mutex.Lock()
+		err := alm.replaceCaches(&state)
+		alm.mutex.Unlock()
+		if err != nil {
+			sb.logger.Error("Failed to apply replicated caches", zap.Error(err))
+			return &ReplicationAck{Sequence: sb.sequence, Resync: true}
+		}
+		sb.cacheSyncs.Add(1)
+
+	case ReplicateHeartbeat:
+		sb.heartbeats.Add(1)
+	}
+
+	return &ReplicationAck{Sequence: sb.sequence}
+}
+
+// Run promotes the standby once the primary has been silent for
+// FailoverTimeout, if AutoPromote is set, until ctx is done. A standby that
+// has never been synced is not promoted.
+func (sb *StandbyReceiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(sb.config.FailoverTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sb.mutex.Lock()
+			silent := sb.epoch != 0 && time.Since(sb.lastContact) > sb.config.FailoverTimeout
+			sb.mutex.Unlock()
+
+			if silent && sb.config.AutoPromote && !sb.promoted.Load() {
+				sb.logger.Warn("Primary coordinator is unresponsive, taking over",
+					zap.Duration("failover_timeout", sb.config.FailoverTimeout),
+				)
+				sb.Promote()
+			}
+		}
+	}
+}
+
+// Promote makes the standby a primary: it stops accepting replication and
+// accepts topology updates. It has no effect if already promoted.
+func (sb *StandbyReceiver) Promote() {
+	sb.mutex.Lock()
+	if sb.promoted.Swap(true) {
+		sb.mutex.Unlock()
+		return
+	}
+	sb.promotedAt = time.Now()
+	listeners := sb.listeners
+	sequence := sb.sequence
+	sb.mutex.Unlock()
+
+	sb.logger.Info("Standby coordinator promoted to primary", zap.Uint64("sequence", sequence))
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
+// Promoted reports whether the standby has taken over
+func (sb *StandbyReceiver) Promoted() bool {
+	return sb.promoted.Load()
+}
+
+// Stats returns replication progress
+func (sb *StandbyReceiver) Stats() ReplicationStats {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	return ReplicationStats{
+		Epoch:       sb.epoch,
+		Sequence:    sb.sequence,
+		FullSyncs:   sb.fullSyncs.Load(),
+		Deltas:      sb.deltas.Load(),
+		CacheSyncs:  sb.cacheSyncs.Load(),
+		Heartbeats:  sb.heartbeats.Load(),
+		Resyncs:     sb.resyncs.Load(),
+		LastContact: sb.lastContact,
+		Promoted:    sb.promoted.Load(),
+		PromotedAt:  sb.promotedAt,
+	}
+}
+
+// AttachStandby streams this coordinator's state to a hot standby over
+// transport once the coordinator starts
+func (alm *ALMCoordinator) AttachStandby(transport ReplicationTransport, config *ReplicationConfig) *StandbyReplicator {
+	if config == nil {
+		config = DefaultReplicationConfig()
+	}
+	if config.PrimaryID == "" {
+		config.PrimaryID = alm.Config().NodeID
+	}
+
+	replicator := NewStandbyReplicator(alm, transport, config, alm.logger)
+
+	alm.mutex.Lock()
+	defer alm.mutex.Unlock()
+
+	alm.replicator = replicator
+	alm.topologyListeners = append(alm.topologyListeners, replicator.enqueue)
+	return replicator
+}
+
+// EnableStandby makes this coordinator a hot standby: its state follows a
+// primary through the returned receiver, whose Handle must be served to
+// the primary's ReplicationTransport, and it takes over when the primary
+// goes quiet. Call before Start.
+func (alm *ALMCoordinator) EnableStandby(config *StandbyConfig) *StandbyReceiver {
+	receiver := NewStandbyReceiver(alm, config, alm.logger)
+
+	alm.mutex.Lock()
+	defer alm.mutex.Unlock()
+
+	alm.standby = receiver
+	return receiver
+}
+
+// captureState returns the state to snapshot or replicate; callers hold
+// at least the read lock
+func (alm *ALMCoordinator) captureState() *coordinatorState {
+	return &coordinatorState{
+		Nodes:    alm.networkGraph.Nodes(),
+		Edges:    alm.networkGraph.Edges(),
+		Routes:   alm.routingTable.ExportRoutes(),
+		Registry: alm.serviceRegistry.Snapshot(),
+		Quotas:   alm.quotas.Entries(),
+	}
+}
+
+// applyReplicatedUpdates logs and applies updates from the primary without
+// notifying topology listeners, which the primary has already notified;
+// callers must hold the write lock
+func (alm *ALMCoordinator) applyReplicatedUpdates(updates []TopologyUpdate) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	if alm.stateStore != nil {
+		if err := alm.stateStore.Append(updates); err != nil {
+			return fmt.Errorf("failed to log replicated updates: %w", err)
+		}
+	}
+
+	alm.applyTopologyUpdates(updates)
+	alm.routingTable.InvalidateTopology(topologyChange(updates))
+	return nil
+}
+
+// replaceCaches replaces the route cache, registry and quotas with
+// replicated ones; callers must hold the write lock
+func (alm *ALMCoordinator) replaceCaches(state *coordinatorState) error {
+	routes := make(map[string]*routing.RouteEntry, len(state.Routes))
+	for key, route := range state.Routes {
+		if alm.relinkRoute(route) {
+			routes[key] = route
+		}
+	}
+	alm.routingTable.InvalidateCache()
+	alm.routingTable.ImportRoutes(routes)
+
+	if _, err := alm.serviceRegistry.Replace(state.Registry); err != nil {
+		return fmt.Errorf("failed to replace service registry: %w", err)
+	}
+	if err := alm.quotas.Replace(state.Quotas); err != nil {
+		return fmt.Errorf("failed to replace quotas: %w", err)
+	}
+	return nil
+}
+
+// reconcileTopology returns the updates that turn current into the target
+// state's topology: removals first, then additions and metric updates
+func reconcileTopology(current *graph.NetworkGraph, target *coordinatorState) []TopologyUpdate {
+	type link struct{ from, to int64 }
+
+	targetNodes := make(map[int64]*graph.NetworkNode, len(target.Nodes))
+	for _, node := range target.Nodes {
+		targetNodes[node.ID] = node
+	}
+	targetEdges := make(map[link]*graph.NetworkEdge, len(target.Edges))
+	for _, edge := range target.Edges {
+		targetEdges[link{edge.From, edge.To}] = edge
+	}
+
+	currentNodes := make(map[int64]bool)
+	for _, node := range current.Nodes() {
+		currentNodes[node.ID] = true
+	}
+	currentEdges := make(map[link]bool)
+
+	var removeEdges, removeNodes, addNodes, nodeMetrics, addEdges, edgeMetrics []TopologyUpdate
+	for _, edge := range current.Edges() {
+		key := link{edge.From, edge.To}
+		currentEdges[key] = true
+		if targetEdges[key] == nil {
+			removeEdges = append(removeEdges, TopologyUpdate{Type: EdgeRemoveUpdate, EdgeFrom: edge.From, EdgeTo: edge.To})
+		}
+	}
+	for nodeID := range currentNodes {
+		if targetNodes[nodeID] == nil {
+			removeNodes = append(removeNodes, TopologyUpdate{Type: NodeRemoveUpdate, NodeID: nodeID})
+		}
+	}
+	for _, node := range target.Nodes {
+		if !currentNodes[node.ID] {
+			addNodes = append(addNodes, TopologyUpdate{Type: NodeAddUpdate, Node: node, NodeID: node.ID})
+			continue
+		}
+		nodeMetrics = append(nodeMetrics, TopologyUpdate{
+			Type:   MetricsUpdate,
+			NodeID: node.ID,
+			Metrics: graph.NodeMetrics{
+				Latency:     node.Latency,
+				Throughput:  node.Throughput,
+				Reliability: node.Reliability,
+				LoadFactor:  node.LoadFactor,
+			},
+		})
+	}
+	for _, edge := range target.Edges {
+		if !currentEdges[link{edge.From, edge.To}] {
+			addEdges = append(addEdges, TopologyUpdate{Type: EdgeAddUpdate, Edge: edge, EdgeFrom: edge.From, EdgeTo: edge.To})
+			continue
+		}
+		edgeMetrics = append(edgeMetrics, TopologyUpdate{
+			Type:     EdgeMetricsUpdate,
+			EdgeFrom: edge.From,
+			EdgeTo:   edge.To,
+			EdgeMetrics: graph.EdgeMetrics{
+				Latency:     edge.Latency,
+				Bandwidth:   edge.Bandwidth,
+				PacketLoss:  edge.PacketLoss,
+				Jitter:      edge.Jitter,
+				Reliability: edge.Reliability,
+			},
+		})
+	}
+
+	updates := make([]TopologyUpdate, 0, len(removeEdges)+len(removeNodes)+len(addNodes)+len(nodeMetrics)+len(addEdges)+len(edgeMetrics))
+	for _, group := range [][]TopologyUpdate{removeEdges, removeNodes, addNodes, nodeMetrics, addEdges, edgeMetrics} {
+		updates = append(updates, group...)
+	}
+	return updates
+}
+
+// DefaultReplicationConfig returns default primary-side replication configuration
+func DefaultReplicationConfig() *ReplicationConfig {
+	return &ReplicationConfig{
+		HeartbeatInterval: 500 * time.Millisecond,
+		CacheSyncInterval: 10 * time.Second,
+		QueueSize:         1024,
+		RPCTimeout:        2 * time.Second,
+	}
+}
+
+// DefaultStandbyConfig returns default standby configuration, taking over
+// after three seconds without contact
+func DefaultStandbyConfig() *StandbyConfig {
+	return &StandbyConfig{
+		FailoverTimeout: 3 * time.Second,
+		AutoPromote:     true,
+	}
+}
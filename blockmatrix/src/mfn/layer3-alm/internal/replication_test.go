@@ -0,0 +1,218 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+// standbyLink delivers replication messages straight to a receiver and
+// fails while down is set
+type standbyLink struct {
+	receiver *StandbyReceiver
+	down     atomic.Bool
+}
+
+func (sl *standbyLink) Replicate(ctx context.Context, message *ReplicationMessage) (*ReplicationAck, error) {
+	if sl.down.Load() {
+		return nil, errors.New("standby unreachable")
+	}
+	return sl.receiver.Handle(message), nil
+}
+
+// addNodes returns updates adding a node for each id
+func addNodes(ids ...int64) []TopologyUpdate {
+	var updates []TopologyUpdate
+	for _, id := range ids {
+		updates = append(updates, TopologyUpdate{Type: NodeAddUpdate, Node: &graph.NetworkNode{ID: id}, NodeID: id})
+	}
+	return updates
+}
+
+// fullState returns a full replication message of a topology of the given nodes
+func fullState(t *testing.T, epoch, sequence uint64, ids ...int64) *ReplicationMessage {
+	t.Helper()
+
+	state := &coordinatorState{}
+	for _, id := range ids {
+		state.Nodes = append(state.Nodes, &graph.NetworkNode{ID: id})
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("encode state: %v", err)
+	}
+	return &ReplicationMessage{Kind: ReplicateFull, Epoch: epoch, Sequence: sequence, State: data}
+}
+
+// waitFor polls condition until it holds, failing the test after a few seconds
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStandbyReceiverAppliesInOrder(t *testing.T) {
+	alm := newTestCoordinator(t)
+	receiver := alm.EnableStandby(nil)
+
+	// Nothing but full state is accepted before the first sync
+	if ack := receiver.Handle(&ReplicationMessage{Kind: ReplicateHeartbeat, Epoch: 1}); !ack.Resync {
+		t.Fatalf("heartbeat before sync acked %+v, want a resync", *ack)
+	}
+
+	if ack := receiver.Handle(fullState(t, 1, 2, 1, 2)); *ack != (ReplicationAck{Sequence: 2}) {
+		t.Fatalf("full state acked %+v", *ack)
+	}
+	delta := &ReplicationMessage{Kind: ReplicateDelta, Epoch: 1, BaseSequence: 2, Sequence: 4, Updates: addNodes(3, 4)}
+	if ack := receiver.Handle(delta); *ack != (ReplicationAck{Sequence: 4}) {
+		t.Fatalf("delta acked %+v", *ack)
+	}
+	if nodes := len(alm.networkGraph.Nodes()); nodes != 4 {
+		t.Errorf("standby has %d nodes, want 4", nodes)
+	}
+
+	// A delta that skips a sequence means one was lost
+	lost := &ReplicationMessage{Kind: ReplicateDelta, Epoch: 1, BaseSequence: 5, Sequence: 6, Updates: addNodes(5)}
+	if ack := receiver.Handle(lost); !ack.Resync || ack.Sequence != 4 {
+		t.Errorf("delta after a gap acked %+v, want a resync at 4", *ack)
+	}
+
+	// So does a new epoch: the primary restarted
+	if ack := receiver.Handle(&ReplicationMessage{Kind: ReplicateHeartbeat, Epoch: 2}); !ack.Resync {
+		t.Errorf("heartbeat from a new epoch acked %+v, want a resync", *ack)
+	}
+	if ack := receiver.Handle(fullState(t, 2, 0, 2, 3)); ack.Resync {
+		t.Fatalf("full state from the new epoch acked %+v", *ack)
+	}
+	if nodes := len(alm.networkGraph.Nodes()); nodes != 2 {
+		t.Errorf("standby has %d nodes after a resync to 2", nodes)
+	}
+}
+
+func TestStandbyReceiverRejectsInvalidState(t *testing.T) {
+	alm := newTestCoordinator(t)
+	receiver := alm.EnableStandby(nil)
+
+	invalid := &ReplicationMessage{Kind: ReplicateFull, Epoch: 1, Sequence: 1, State: json.RawMessage("{")}
+	if ack := receiver.Handle(invalid); !ack.Resync || ack.Sequence != 0 {
+		t.Errorf("invalid state acked %+v, want a resync at 0", *ack)
+	}
+}
+
+func TestStandbyRejectsTopologyUntilPromoted(t *testing.T) {
+	alm := newTestCoordinator(t)
+	receiver := alm.EnableStandby(nil)
+
+	if err := alm.UpdateNetworkTopology(addNodes(1)); !errors.Is(err, ErrStandby) {
+		t.Fatalf("standby accepted a topology update: %v", err)
+	}
+
+	receiver.Promote()
+	if err := alm.UpdateNetworkTopology(addNodes(1)); err != nil {
+		t.Errorf("promoted standby rejected a topology update: %v", err)
+	}
+	if ack := receiver.Handle(fullState(t, 1, 1, 2)); !ack.Promoted {
+		t.Errorf("promoted standby acked %+v", *ack)
+	}
+}
+
+func TestReconcileTopology(t *testing.T) {
+	alm := newTestCoordinator(t)
+	for _, id := range []int64{1, 2, 3} {
+		alm.networkGraph.AddNode(&graph.NetworkNode{ID: id})
+	}
+	alm.networkGraph.AddEdge(&graph.NetworkEdge{From: 1, To: 2, Weight: 1})
+	alm.networkGraph.AddEdge(&graph.NetworkEdge{From: 2, To: 3, Weight: 1})
+
+	target := &coordinatorState{
+		Nodes: []*graph.NetworkNode{{ID: 1}, {ID: 2}, {ID: 4}},
+		Edges: []*graph.NetworkEdge{{From: 1, To: 2, Weight: 1}, {From: 2, To: 4, Weight: 1}},
+	}
+	updates := reconcileTopology(alm.networkGraph, target)
+
+	// Removals come first so additions never collide with what they replace
+	want := []TopologyUpdateType{EdgeRemoveUpdate, NodeRemoveUpdate, NodeAddUpdate}
+	if len(updates) < len(want) {
+		t.Fatalf("got %d updates, want at least %d", len(updates), len(want))
+	}
+	for i, updateType := range want {
+		if updates[i].Type != updateType {
+			t.Errorf("update %d is %s, want %s", i, updates[i].Type, updateType)
+		}
+	}
+
+	alm.applyTopologyUpdates(updates)
+	if nodes, edges := len(alm.networkGraph.Nodes()), len(alm.networkGraph.Edges()); nodes != 3 || edges != 2 {
+		t.Errorf("reconciled to %d nodes and %d edges, want 3 and 2", nodes, edges)
+	}
+	if _, ok := alm.networkGraph.GetNode(3); ok {
+		t.Error("node 3 kept after reconciling")
+	}
+}
+
+func TestStandbyFailover(t *testing.T) {
+	primary := newTestCoordinator(t)
+	standby := newTestCoordinator(t)
+
+	receiver := standby.EnableStandby(&StandbyConfig{FailoverTimeout: 100 * time.Millisecond, AutoPromote: true})
+	promoted := make(chan struct{})
+	receiver.OnPromote(func() { close(promoted) })
+
+	link := &standbyLink{receiver: receiver}
+	replicator := primary.AttachStandby(link, &ReplicationConfig{
+		PrimaryID:         "primary",
+		HeartbeatInterval: 10 * time.Millisecond,
+		CacheSyncInterval: 20 * time.Millisecond,
+		QueueSize:         16,
+		RPCTimeout:        50 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	replicating := make(chan struct{})
+	go func() {
+		replicator.Run(ctx)
+		close(replicating)
+	}()
+	go receiver.Run(ctx)
+
+	waitFor(t, "the initial full sync", func() bool { return receiver.Stats().FullSyncs == 1 })
+
+	// Updates on the primary stream to the standby as deltas
+	if err := primary.UpdateNetworkTopology(addNodes(1, 2)); err != nil {
+		t.Fatalf("UpdateNetworkTopology: %v", err)
+	}
+	waitFor(t, "the standby to apply the delta", func() bool {
+		return receiver.Stats().Deltas == 1 && replicator.Stats().Sequence == 1
+	})
+
+	// The primary goes quiet and the standby takes over
+	link.down.Store(true)
+	select {
+	case <-promoted:
+	case <-time.After(3 * time.Second):
+		t.Fatal("standby not promoted after the primary went quiet")
+	}
+
+	// A primary that comes back learns it was superseded and stops
+	link.down.Store(false)
+	select {
+	case <-replicating:
+	case <-time.After(3 * time.Second):
+		t.Fatal("superseded primary kept replicating")
+	}
+	if stats := replicator.Stats(); !stats.Promoted || stats.Failures == 0 {
+		t.Errorf("replicator stats %+v, want superseded after failed sends", stats)
+	}
+}
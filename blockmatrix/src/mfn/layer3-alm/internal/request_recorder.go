@@ -0,0 +1,330 @@
+// Package internal implements sampled, anonymized recording of route requests for replay
+package internal
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	mathrand "math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrNotRecording is returned when recording is stopped while none is running
+var ErrNotRecording = errors.New("no request recording is running")
+
+// ErrAlreadyRecording is returned when recording is started while one is running
+var ErrAlreadyRecording = errors.New("a request recording is already running")
+
+// TraceRecord is one recorded route request. Node IDs and service types are
+// replaced by keyed hashes, so a trace keeps the shape of the traffic (which
+// requests share endpoints, and how often) without revealing the topology.
+type TraceRecord struct {
+	// Offset is when the request arrived, from the start of the recording
+	Offset time.Duration `json:"offset"`
+
+	Source      uint64 `json:"source"`
+	Destination uint64 `json:"destination"`
+	ServiceType string `json:"service_type,omitempty"`
+	QoSClass    int    `json:"qos_class"`
+
+	MaxLatency time.Duration `json:"max_latency,omitempty"`
+	MaxHops    int           `json:"max_hops,omitempty"`
+}
+
+// RecordingConfig configures a request recording
+type RecordingConfig struct {
+	// Path of the trace file, written as one JSON TraceRecord per line
+	Path string `json:"path"`
+
+	// SampleRate is the fraction of requests recorded
+	SampleRate float64 `json:"sample_rate"`
+
+	// The recording stops by itself after MaxRecords records or Duration,
+	// whichever comes first; zero is unlimited
+	MaxRecords int64         `json:"max_records,omitempty"`
+	Duration   time.Duration `json:"duration,omitempty"`
+
+	// Key of the anonymizing hash, hex encoded. A random key is used when
+	// empty; give one to correlate several recordings.
+	Key string `json:"key,omitempty"`
+}
+
+// Validate checks the recording configuration
+func (c *RecordingConfig) Validate() error {
+	if c.Path == "" {
+		return errors.New("recording path is required")
+	}
+	if c.SampleRate <= 0 || c.SampleRate > 1 {
+		return fmt.Errorf("sample rate must be above 0 and at most 1, got %g", c.SampleRate)
+	}
+	if c.MaxRecords < 0 || c.Duration < 0 {
+		return errors.New("recording limits must not be negative")
+	}
+	if _, err := hex.DecodeString(c.Key); err != nil {
+		return fmt.Errorf("invalid recording key: %w", err)
+	}
+	return nil
+}
+
+// RecordingStatus describes the current or last request recording
+type RecordingStatus struct {
+	Active     bool
+	Path       string
+	SampleRate float64
+	Started    time.Time
+	Stopped    time.Time `json:",omitempty"`
+
+	// Records written, and sampled requests dropped because the writer
+	// fell behind
+	Recorded int64
+	Dropped  int64
+
+	Error string `json:",omitempty"`
+}
+
+// recorderBufferSize bounds the records waiting to be written
+const recorderBufferSize = 4096
+
+// RequestRecorder writes a sample of route requests to a trace file. The
+// request path only samples and queues; encoding and writing happen on a
+// background goroutine, and records are dropped rather than slowing
+// requests when it falls behind.
+type RequestRecorder struct {
+	config  RecordingConfig
+	key     []byte
+	started time.Time
+
+	records chan TraceRecord
+	done    chan struct{}
+	stop    sync.Once
+	stopped atomic.Bool
+
+	recorded atomic.Int64
+	dropped  atomic.Int64
+	claimed  atomic.Int64
+
+	// Set by the writer when it finishes
+	finished time.Time
+	err      error
+
+	logger *zap.Logger
+}
+
+// NewRequestRecorder creates the trace file and starts writing to it
+func NewRequestRecorder(config RecordingConfig, logger *zap.Logger) (*RequestRecorder, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	key, _ := hex.DecodeString(config.Key)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate recording key: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(config.Path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace file: %w", err)
+	}
+
+	rr := &RequestRecorder{
+		config:  config,
+		key:     key,
+		started: time.Now(),
+		records: make(chan TraceRecord, recorderBufferSize),
+		done:    make(chan struct{}),
+		logger:  logger,
+	}
+	go rr.write(file)
+
+	if config.Duration > 0 {
+		time.AfterFunc(config.Duration, rr.Stop)
+	}
+	return rr, nil
+}
+
+// Record samples request into the trace
+func (rr *RequestRecorder) Record(request RouteRequest) {
+	if rr.stopped.Load() {
+		return
+	}
+	if rr.config.SampleRate < 1 && mathrand.Float64() >= rr.config.SampleRate {
+		return
+	}
+	if rr.config.MaxRecords > 0 && rr.claimed.Add(1) > rr.config.MaxRecords {
+		// Stop waits for the writer, which the request should not
+		go rr.Stop()
+		return
+	}
+
+	record := TraceRecord{
+		Offset:      time.Since(rr.started),
+		Source:      rr.anonymize(request.SourceID),
+		Destination: rr.anonymize(request.DestinationID),
+		QoSClass:    request.QoSClass,
+		MaxLatency:  request.MaxLatency,
+		MaxHops:     request.MaxHops,
+	}
+	if request.ServiceType != "" {
+		record.ServiceType = rr.anonymizeString(request.ServiceType)
+	}
+
+	defer func() {
+		// The channel is closed once the recording stops
+		if recover() != nil {
+			rr.dropped.Add(1)
+		}
+	}()
+	select {
+	case rr.records <- record:
+	default:
+		rr.dropped.Add(1)
+	}
+}
+
+// Stop ends the recording and waits for buffered records to be written
+func (rr *RequestRecorder) Stop() {
+	rr.stop.Do(func() {
+		rr.stopped.Store(true)
+		close(rr.records)
+	})
+	<-rr.done
+}
+
+// Status describes the recording
+func (rr *RequestRecorder) Status() RecordingStatus {
+	status := RecordingStatus{
+		Path:       rr.config.Path,
+		SampleRate: rr.config.SampleRate,
+		Started:    rr.started,
+		Recorded:   rr.recorded.Load(),
+		Dropped:    rr.dropped.Load(),
+	}
+
+	select {
+	case <-rr.done:
+		status.Stopped = rr.finished
+		if rr.err != nil {
+			status.Error = rr.err.Error()
+		}
+	default:
+		status.Active = true
+	}
+	return status
+}
+
+// write encodes records to file until the recording stops
+func (rr *RequestRecorder) write(file *os.File) {
+	defer close(rr.done)
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+
+	var err error
+	for record := range rr.records {
+		if err != nil {
+			rr.dropped.Add(1)
+			continue
+		}
+		if err = encoder.Encode(record); err != nil {
+			rr.logger.Error("Failed to write request trace; dropping further records",
+				zap.String("path", rr.config.Path),
+				zap.Error(err),
+			)
+			continue
+		}
+		rr.recorded.Add(1)
+	}
+
+	if flushErr := writer.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	rr.finished = time.Now()
+	rr.err = err
+
+	rr.logger.Info("Request recording stopped",
+		zap.String("path", rr.config.Path),
+		zap.Int64("recorded", rr.recorded.Load()),
+		zap.Int64("dropped", rr.dropped.Load()),
+	)
+}
+
+// anonymize maps a node ID to its keyed hash
+func (rr *RequestRecorder) anonymize(id int64) uint64 {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(id))
+	mac := hmac.New(sha256.New, rr.key)
+	mac.Write(buf[:])
+	return binary.BigEndian.Uint64(mac.Sum(nil))
+}
+
+// anonymizeString maps a service type to its keyed hash
+func (rr *RequestRecorder) anonymizeString(value string) string {
+	mac := hmac.New(sha256.New, rr.key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// StartRecording starts recording a sample of route requests to a trace
+// file for replay with the benchmark harness
+func (alm *ALMCoordinator) StartRecording(config RecordingConfig) (RecordingStatus, error) {
+	alm.recordingMutex.Lock()
+	defer alm.recordingMutex.Unlock()
+
+	if current := alm.recorder.Load(); current != nil && current.Status().Active {
+		return RecordingStatus{}, ErrAlreadyRecording
+	}
+
+	recorder, err := NewRequestRecorder(config, alm.logger)
+	if err != nil {
+		return RecordingStatus{}, err
+	}
+	alm.recorder.Store(recorder)
+
+	alm.logger.Info("Request recording started",
+		zap.String("path", config.Path),
+		zap.Float64("sample_rate", config.SampleRate),
+	)
+	return recorder.Status(), nil
+}
+
+// StopRecording stops the running request recording
+func (alm *ALMCoordinator) StopRecording() (RecordingStatus, error) {
+	alm.recordingMutex.Lock()
+	defer alm.recordingMutex.Unlock()
+
+	recorder := alm.recorder.Load()
+	if recorder == nil || !recorder.Status().Active {
+		return RecordingStatus{}, ErrNotRecording
+	}
+	recorder.Stop()
+	return recorder.Status(), nil
+}
+
+// RecordingStatus describes the running or last request recording; it is
+// zero when nothing has been recorded
+func (alm *ALMCoordinator) RecordingStatus() RecordingStatus {
+	if recorder := alm.recorder.Load(); recorder != nil {
+		return recorder.Status()
+	}
+	return RecordingStatus{}
+}
@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRecordingKey = "00112233445566778899aabbccddeeff"
+
+func TestRecordingConfigValidate(t *testing.T) {
+	valid := RecordingConfig{Path: "trace.jsonl", SampleRate: 1, Key: testRecordingKey}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate(%+v): %v", valid, err)
+	}
+
+	invalid := []RecordingConfig{
+		{SampleRate: 1},
+		{Path: "trace.jsonl"},
+		{Path: "trace.jsonl", SampleRate: 1.5},
+		{Path: "trace.jsonl", SampleRate: 1, MaxRecords: -1},
+		{Path: "trace.jsonl", SampleRate: 1, Duration: -time.Second},
+		{Path: "trace.jsonl", SampleRate: 1, Key: "not hex"},
+	}
+	for _, config := range invalid {
+		if err := config.Validate(); err == nil {
+			t.Errorf("Validate(%+v) accepted an invalid configuration", config)
+		}
+	}
+}
+
+// readTrace reads the records of a trace file
+func readTrace(t *testing.T, path string) []TraceRecord {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open trace: %v", err)
+	}
+	defer file.Close()
+
+	var records []TraceRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record TraceRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("decode %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// recordTrace records requests with key and returns the trace written
+func recordTrace(t *testing.T, key string, requests ...RouteRequest) []TraceRecord {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	recorder, err := NewRequestRecorder(RecordingConfig{Path: path, SampleRate: 1, Key: key}, nil)
+	if err != nil {
+		t.Fatalf("NewRequestRecorder: %v", err)
+	}
+	for _, request := range requests {
+		recorder.Record(request)
+	}
+	recorder.Stop()
+
+	if status := recorder.Status(); status.Active || status.Recorded != int64(len(requests)) || status.Error != "" {
+		t.Fatalf("status %+v, want %d records written", status, len(requests))
+	}
+	return readTrace(t, path)
+}
+
+func TestRequestRecorderAnonymizes(t *testing.T) {
+	requests := []RouteRequest{
+		{SourceID: 1, DestinationID: 2, ServiceType: "payments", QoSClass: 2, MaxHops: 4},
+		{SourceID: 2, DestinationID: 3, ServiceType: "payments"},
+		{SourceID: 1, DestinationID: 3},
+	}
+	records := recordTrace(t, testRecordingKey, requests...)
+	if len(records) != 3 {
+		t.Fatalf("%d records, want 3", len(records))
+	}
+
+	// The same node hashes the same way wherever it appears; different
+	// nodes do not collide
+	if records[0].Source != records[2].Source || records[0].Destination != records[1].Source || records[1].Destination != records[2].Destination {
+		t.Errorf("node hashes not consistent across records: %+v", records)
+	}
+	if records[0].Source == records[0].Destination || records[0].Source <= 3 {
+		t.Errorf("node IDs not anonymized: %+v", records[0])
+	}
+	if records[0].ServiceType == "" || records[0].ServiceType == "payments" || records[0].ServiceType != records[1].ServiceType || records[2].ServiceType != "" {
+		t.Errorf("service types %q, %q, %q; want the same hash twice and none unset", records[0].ServiceType, records[1].ServiceType, records[2].ServiceType)
+	}
+	if records[0].QoSClass != 2 || records[0].MaxHops != 4 || records[1].Offset < records[0].Offset {
+		t.Errorf("record %+v lost its constraints or order", records[0])
+	}
+
+	// The same key correlates recordings; another key does not
+	if again := recordTrace(t, testRecordingKey, requests[0]); again[0].Source != records[0].Source {
+		t.Error("the same key hashed a node differently")
+	}
+	if other := recordTrace(t, "ffeeddccbbaa99887766554433221100", requests[0]); other[0].Source == records[0].Source {
+		t.Error("a different key hashed a node the same way")
+	}
+}
+
+func TestRequestRecorderStopsAtMaxRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	recorder, err := NewRequestRecorder(RecordingConfig{Path: path, SampleRate: 1, MaxRecords: 2}, nil)
+	if err != nil {
+		t.Fatalf("NewRequestRecorder: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		recorder.Record(RouteRequest{SourceID: 1, DestinationID: 2})
+	}
+	recorder.Stop()
+	recorder.Record(RouteRequest{SourceID: 1, DestinationID: 2})
+
+	if status := recorder.Status(); status.Recorded != 2 || status.Active || status.Stopped.IsZero() {
+		t.Errorf("status %+v, want stopped after 2 records", status)
+	}
+	if records := readTrace(t, path); len(records) != 2 {
+		t.Errorf("%d records in the trace, want 2", len(records))
+	}
+}
+
+func TestRequestRecorderStopsAfterDuration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	recorder, err := NewRequestRecorder(RecordingConfig{Path: path, SampleRate: 1, Duration: time.Millisecond}, nil)
+	if err != nil {
+		t.Fatalf("NewRequestRecorder: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for recorder.Status().Active {
+		if time.Now().After(deadline) {
+			t.Fatal("recording still active long after its duration")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCoordinatorRecording(t *testing.T) {
+	alm := newDiamondCoordinator(t)
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+
+	if _, err := alm.StopRecording(); !errors.Is(err, ErrNotRecording) {
+		t.Errorf("StopRecording before Start = %v, want ErrNotRecording", err)
+	}
+	if _, err := alm.StartRecording(RecordingConfig{Path: path}); err == nil {
+		t.Error("StartRecording accepted a zero sample rate")
+	}
+
+	status, err := alm.StartRecording(RecordingConfig{Path: path, SampleRate: 1})
+	if err != nil || !status.Active {
+		t.Fatalf("StartRecording = %+v, %v", status, err)
+	}
+	if _, err := alm.StartRecording(RecordingConfig{Path: path, SampleRate: 1}); !errors.Is(err, ErrAlreadyRecording) {
+		t.Errorf("second StartRecording = %v, want ErrAlreadyRecording", err)
+	}
+
+	ctx := context.Background()
+	alm.FindOptimalRoute(ctx, RouteRequest{SourceID: 1, DestinationID: 4})
+	alm.FindOptimalRoute(ctx, RouteRequest{SourceID: 2, DestinationID: 3})
+	// Invalid requests are rejected before they are recorded
+	alm.FindOptimalRoute(ctx, RouteRequest{SourceID: 1, DestinationID: 1})
+
+	status, err = alm.StopRecording()
+	if err != nil || status.Active || status.Recorded != 2 {
+		t.Fatalf("StopRecording = %+v, %v; want 2 records", status, err)
+	}
+	if current := alm.RecordingStatus(); current.Recorded != 2 || !strings.HasSuffix(current.Path, "trace.jsonl") {
+		t.Errorf("RecordingStatus() = %+v after stopping, want the last recording", current)
+	}
+	if _, err := alm.StopRecording(); !errors.Is(err, ErrNotRecording) {
+		t.Errorf("second StopRecording = %v, want ErrNotRecording", err)
+	}
+}
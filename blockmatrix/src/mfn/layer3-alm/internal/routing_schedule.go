@@ -0,0 +1,400 @@
+// Package internal implements time-of-day routing preferences and their overrides
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	// Timezones resolve on hosts without a zoneinfo database
+	_ "time/tzdata"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/optimization"
+	"go.uber.org/zap"
+)
+
+// DefaultRoutingPreference names the configured objective weights, in
+// effect outside every scheduled window; overriding to it suspends the
+// schedule
+const DefaultRoutingPreference = "default"
+
+// scheduleEvaluationInterval is how often the active preference is
+// re-evaluated
+const scheduleEvaluationInterval = 30 * time.Second
+
+// ErrUnknownRoutingPreference is returned when overriding to a preference
+// that is not scheduled
+var ErrUnknownRoutingPreference = errors.New("unknown routing preference")
+
+// ObjectiveWeights weight the route optimization objectives
+type ObjectiveWeights struct {
+	Latency     float64 `json:"latency" yaml:"latency"`
+	Throughput  float64 `json:"throughput" yaml:"throughput"`
+	Reliability float64 `json:"reliability" yaml:"reliability"`
+	Cost        float64 `json:"cost" yaml:"cost"`
+}
+
+// Validate checks the weights are non-negative and not all zero
+func (w ObjectiveWeights) Validate() error {
+	if w.Latency < 0 || w.Throughput < 0 || w.Reliability < 0 || w.Cost < 0 {
+		return errors.New("objective weights must not be negative")
+	}
+	if w.Latency+w.Throughput+w.Reliability+w.Cost == 0 {
+		return errors.New("objective weights must not all be zero")
+	}
+	return nil
+}
+
+// RoutingPreference is a set of objective weights in effect during a daily
+// window, such as latency-optimal during business hours or cheap transit
+// overnight
+type RoutingPreference struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Days the window opens on, such as "mon" or "saturday"; every day when
+	// empty
+	Days []string `json:"days,omitempty" yaml:"days,omitempty"`
+
+	// Start and End are "15:04" wall clock times in Timezone, an IANA name
+	// such as "America/New_York" defaulting to UTC. A window ending at or
+	// before its start runs past midnight; equal times span the whole day.
+	Start    string `json:"start" yaml:"start"`
+	End      string `json:"end" yaml:"end"`
+	Timezone string `json:"timezone,omitempty" yaml:"timezone,omitempty"`
+
+	Weights ObjectiveWeights `json:"weights" yaml:"weights"`
+}
+
+// scheduledWindow is a preference with its window parsed
+type scheduledWindow struct {
+	preference RoutingPreference
+	location   *time.Location
+	days       [7]bool
+	start, end int // minutes after midnight
+}
+
+// parseWindow parses and validates a preference's window
+func parseWindow(preference RoutingPreference) (*scheduledWindow, error) {
+	if preference.Name == "" || preference.Name == DefaultRoutingPreference {
+		return nil, fmt.Errorf("routing preference name must be set and not %q", DefaultRoutingPreference)
+	}
+	if err := preference.Weights.Validate(); err != nil {
+		return nil, fmt.Errorf("routing preference %s: %w", preference.Name, err)
+	}
+
+	window := &scheduledWindow{preference: preference, location: time.UTC}
+	if preference.Timezone != "" {
+		location, err := time.LoadLocation(preference.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("routing preference %s: unknown timezone %q", preference.Name, preference.Timezone)
+		}
+		window.location = location
+	}
+
+	var err error
+	if window.start, err = parseClock(preference.Start); err != nil {
+		return nil, fmt.Errorf("routing preference %s: start: %w", preference.Name, err)
+	}
+	if window.end, err = parseClock(preference.End); err != nil {
+		return nil, fmt.Errorf("routing preference %s: end: %w", preference.Name, err)
+	}
+
+	if len(preference.Days) == 0 {
+		window.days = [7]bool{true, true, true, true, true, true, true}
+	}
+	for _, day := range preference.Days {
+		weekday, ok := parseWeekday(day)
+		if !ok {
+			return nil, fmt.Errorf("routing preference %s: unknown day %q", preference.Name, day)
+		}
+		window.days[weekday] = true
+	}
+	return window, nil
+}
+
+// parseClock parses "15:04" into minutes after midnight
+func parseClock(text string) (int, error) {
+	clock, err := time.Parse("15:04", text)
+	if err != nil {
+		return 0, fmt.Errorf("expected a time such as \"09:30\", got %q", text)
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
+
+// parseWeekday accepts day names and their three letter abbreviations
+func parseWeekday(text string) (time.Weekday, bool) {
+	text = strings.ToLower(text)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		if text == name || text == name[:3] {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// activeAt reports whether the window is open at t
+func (w *scheduledWindow) activeAt(t time.Time) bool {
+	local := t.In(w.location)
+	minute := local.Hour()*60 + local.Minute()
+	today := local.Weekday()
+	yesterday := (today + 6) % 7
+
+	switch {
+	case w.start == w.end:
+		return w.days[today]
+	case w.start < w.end:
+		return w.days[today] && minute >= w.start && minute < w.end
+	default:
+		// Past midnight, the window belongs to the day it opened
+		return (w.days[today] && minute >= w.start) || (w.days[yesterday] && minute < w.end)
+	}
+}
+
+// RoutingScheduleStatus describes the schedule and what is in effect
+type RoutingScheduleStatus struct {
+	// Active is the preference in effect, or DefaultRoutingPreference
+	Active  string
+	Weights ObjectiveWeights
+
+	// Override in effect until OverrideUntil, if any
+	Override      string    `json:",omitempty"`
+	OverrideUntil time.Time `json:",omitempty"`
+
+	Preferences []RoutingPreference
+}
+
+// RoutingSchedule picks the routing preference in effect: an unexpired
+// override, else the first scheduled preference whose window is open, else
+// the default weights
+type RoutingSchedule struct {
+	windows []*scheduledWindow
+
+	override      string
+	overrideUntil time.Time
+
+	now   func() time.Time
+	mutex sync.RWMutex
+}
+
+// NewRoutingSchedule creates a schedule of preferences
+func NewRoutingSchedule(preferences []RoutingPreference) (*RoutingSchedule, error) {
+	rs := &RoutingSchedule{now: time.Now}
+	if err := rs.Replace(preferences); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// ValidateRoutingSchedule checks every preference and that names are unique
+func ValidateRoutingSchedule(preferences []RoutingPreference) error {
+	_, err := parseWindows(preferences)
+	return err
+}
+
+func parseWindows(preferences []RoutingPreference) ([]*scheduledWindow, error) {
+	windows := make([]*scheduledWindow, 0, len(preferences))
+	seen := make(map[string]bool, len(preferences))
+	var problems []error
+	for _, preference := range preferences {
+		if seen[preference.Name] {
+			problems = append(problems, fmt.Errorf("routing preference %s is scheduled twice", preference.Name))
+			continue
+		}
+		seen[preference.Name] = true
+
+		window, err := parseWindow(preference)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		windows = append(windows, window)
+	}
+	return windows, errors.Join(problems...)
+}
+
+// Replace schedules exactly preferences, or leaves the schedule unchanged
+// if any is invalid. An override of a preference no longer scheduled is
+// cleared.
+func (rs *RoutingSchedule) Replace(preferences []RoutingPreference) error {
+	windows, err := parseWindows(preferences)
+	if err != nil {
+		return err
+	}
+
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	rs.windows = windows
+	if rs.override != "" && rs.override != DefaultRoutingPreference && rs.windowLocked(rs.override) == nil {
+		rs.override, rs.overrideUntil = "", time.Time{}
+	}
+	return nil
+}
+
+// Override puts the preference name, or DefaultRoutingPreference, in effect
+// until the given time whatever the schedule says
+func (rs *RoutingSchedule) Override(name string, until time.Time) error {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	if name != DefaultRoutingPreference && rs.windowLocked(name) == nil {
+		return fmt.Errorf("%w %q", ErrUnknownRoutingPreference, name)
+	}
+	if !until.After(rs.now()) {
+		return errors.New("override must end in the future")
+	}
+	rs.override, rs.overrideUntil = name, until
+	return nil
+}
+
+// ClearOverride returns to the schedule, reporting whether an override was
+// in effect
+func (rs *RoutingSchedule) ClearOverride() bool {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	active := rs.override != "" && rs.now().Before(rs.overrideUntil)
+	rs.override, rs.overrideUntil = "", time.Time{}
+	return active
+}
+
+// Active returns the name and weights of the preference in effect, or
+// DefaultRoutingPreference and defaults
+func (rs *RoutingSchedule) Active(defaults ObjectiveWeights) (string, ObjectiveWeights) {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+
+	return rs.activeLocked(defaults)
+}
+
+func (rs *RoutingSchedule) activeLocked(defaults ObjectiveWeights) (string, ObjectiveWeights) {
+	now := rs.now()
+	if rs.override != "" && now.Before(rs.overrideUntil) {
+		if window := rs.windowLocked(rs.override); window != nil {
+			return window.preference.Name, window.preference.Weights
+		}
+		return DefaultRoutingPreference, defaults
+	}
+	for _, window := range rs.windows {
+		if window.activeAt(now) {
+			return window.preference.Name, window.preference.Weights
+		}
+	}
+	return DefaultRoutingPreference, defaults
+}
+
+// Status describes the schedule, with defaults as the default weights
+func (rs *RoutingSchedule) Status(defaults ObjectiveWeights) RoutingScheduleStatus {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+
+	status := RoutingScheduleStatus{Preferences: make([]RoutingPreference, 0, len(rs.windows))}
+	status.Active, status.Weights = rs.activeLocked(defaults)
+	if rs.override != "" && rs.now().Before(rs.overrideUntil) {
+		status.Override, status.OverrideUntil = rs.override, rs.overrideUntil
+	}
+	for _, window := range rs.windows {
+		status.Preferences = append(status.Preferences, window.preference)
+	}
+	return status
+}
+
+func (rs *RoutingSchedule) windowLocked(name string) *scheduledWindow {
+	for _, window := range rs.windows {
+		if window.preference.Name == name {
+			return window
+		}
+	}
+	return nil
+}
+
+// objectiveWeights returns the objective weights configured by c
+func (c *ALMConfig) objectiveWeights() ObjectiveWeights {
+	return ObjectiveWeights{
+		Latency:     c.LatencyWeight,
+		Throughput:  c.ThroughputWeight,
+		Reliability: c.ReliabilityWeight,
+		Cost:        c.CostWeight,
+	}
+}
+
+// withObjectiveWeights returns config with the objective weights set to w
+func withObjectiveWeights(config optimization.OptimizerConfig, w ObjectiveWeights) optimization.OptimizerConfig {
+	config.LatencyWeight = w.Latency
+	config.ThroughputWeight = w.Throughput
+	config.ReliabilityWeight = w.Reliability
+	config.CostWeight = w.Cost
+	return config
+}
+
+// RoutingSchedule describes the routing preferences and the one in effect
+func (alm *ALMCoordinator) RoutingSchedule() RoutingScheduleStatus {
+	config := alm.Config()
+	return alm.schedule.Status(config.objectiveWeights())
+}
+
+// OverrideRoutingPreference puts the preference name, or
+// DefaultRoutingPreference, in effect for duration whatever the schedule
+// says. Overrides are not persisted.
+func (alm *ALMCoordinator) OverrideRoutingPreference(name string, duration time.Duration) error {
+	if duration <= 0 {
+		return fmt.Errorf("override duration must be positive, got %s", duration)
+	}
+	if err := alm.schedule.Override(name, time.Now().Add(duration)); err != nil {
+		return err
+	}
+	alm.evaluateRoutingSchedule()
+	return nil
+}
+
+// ClearRoutingOverride returns to the schedule, reporting whether an
+// override was in effect
+func (alm *ALMCoordinator) ClearRoutingOverride() bool {
+	cleared := alm.schedule.ClearOverride()
+	alm.evaluateRoutingSchedule()
+	return cleared
+}
+
+// evaluateRoutingSchedule gives the optimizer the weights of the preference
+// in effect. When they change, cached routes chosen under the previous
+// weights are dropped.
+func (alm *ALMCoordinator) evaluateRoutingSchedule() {
+	alm.mutex.Lock()
+	defer alm.mutex.Unlock()
+
+	name, weights := alm.schedule.Active(alm.config.objectiveWeights())
+	current := alm.optimizer.Config()
+	if withObjectiveWeights(current, weights) == current {
+		return
+	}
+
+	alm.optimizer.UpdateConfig(withObjectiveWeights(current, weights))
+	alm.routingTable.InvalidateCache()
+	alm.logger.Info("Routing preference changed",
+		zap.String("preference", name),
+		zap.Float64("latency_weight", weights.Latency),
+		zap.Float64("throughput_weight", weights.Throughput),
+		zap.Float64("reliability_weight", weights.Reliability),
+		zap.Float64("cost_weight", weights.Cost),
+	)
+}
+
+// runRoutingSchedule re-evaluates the routing schedule until ctx is done
+func (alm *ALMCoordinator) runRoutingSchedule(ctx context.Context) {
+	ticker := time.NewTicker(scheduleEvaluationInterval)
+	defer ticker.Stop()
+
+	alm.evaluateRoutingSchedule()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			alm.evaluateRoutingSchedule()
+		}
+	}
+}
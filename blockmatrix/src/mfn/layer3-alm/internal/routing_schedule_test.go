@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRoutingScheduleWindows(t *testing.T) {
+	latency := ObjectiveWeights{Latency: 0.9, Reliability: 0.1}
+	cheap := ObjectiveWeights{Cost: 0.8, Reliability: 0.2}
+	defaults := ObjectiveWeights{Latency: 0.3, Throughput: 0.3, Reliability: 0.2, Cost: 0.2}
+
+	schedule, err := NewRoutingSchedule([]RoutingPreference{
+		{Name: "business-hours", Days: []string{"mon", "tue", "wed", "thu", "friday"},
+			Start: "09:00", End: "17:00", Timezone: "America/New_York", Weights: latency},
+		{Name: "overnight", Days: []string{"fri"}, Start: "22:00", End: "06:00", Weights: cheap},
+	})
+	if err != nil {
+		t.Fatalf("NewRoutingSchedule: %v", err)
+	}
+
+	cases := []struct {
+		at   string
+		want string
+	}{
+		// 10:00 in New York during daylight saving time
+		{"2026-07-15T14:00:00Z", "business-hours"},
+		{"2026-07-15T12:59:00Z", DefaultRoutingPreference},
+		{"2026-07-18T14:00:00Z", DefaultRoutingPreference},
+		{"2026-07-17T23:30:00Z", "overnight"},
+		// Past midnight the window still belongs to Friday
+		{"2026-07-18T05:59:00Z", "overnight"},
+		{"2026-07-18T06:00:00Z", DefaultRoutingPreference},
+		{"2026-07-16T23:30:00Z", DefaultRoutingPreference},
+	}
+	for _, tc := range cases {
+		at, _ := time.Parse(time.RFC3339, tc.at)
+		schedule.now = func() time.Time { return at }
+		name, weights := schedule.Active(defaults)
+		if name != tc.want {
+			t.Errorf("%s: active %q, want %q", tc.at, name, tc.want)
+		}
+		if name == DefaultRoutingPreference && weights != defaults {
+			t.Errorf("%s: default weights %+v, want %+v", tc.at, weights, defaults)
+		}
+	}
+}
+
+func TestRoutingScheduleOverride(t *testing.T) {
+	defaults := ObjectiveWeights{Latency: 1}
+	now := time.Date(2026, 7, 15, 12, 0, 0, 0, time.UTC)
+	schedule, err := NewRoutingSchedule([]RoutingPreference{
+		{Name: "cheap", Start: "00:00", End: "00:00", Weights: ObjectiveWeights{Cost: 1}},
+	})
+	if err != nil {
+		t.Fatalf("NewRoutingSchedule: %v", err)
+	}
+	schedule.now = func() time.Time { return now }
+
+	if name, _ := schedule.Active(defaults); name != "cheap" {
+		t.Fatalf("all-day window not active: %q", name)
+	}
+	if err := schedule.Override("missing", now.Add(time.Hour)); !errors.Is(err, ErrUnknownRoutingPreference) {
+		t.Errorf("override to a missing preference: got %v", err)
+	}
+	if err := schedule.Override(DefaultRoutingPreference, now.Add(time.Hour)); err != nil {
+		t.Fatalf("Override: %v", err)
+	}
+	if name, weights := schedule.Active(defaults); name != DefaultRoutingPreference || weights != defaults {
+		t.Errorf("override to default: %q %+v", name, weights)
+	}
+
+	// Overrides expire on their own
+	now = now.Add(time.Hour)
+	if name, _ := schedule.Active(defaults); name != "cheap" {
+		t.Errorf("expired override still in effect: %q", name)
+	}
+
+	if err := schedule.Override("cheap", now.Add(time.Hour)); err != nil {
+		t.Fatalf("Override: %v", err)
+	}
+	if err := schedule.Replace(nil); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if status := schedule.Status(defaults); status.Override != "" || status.Active != DefaultRoutingPreference {
+		t.Errorf("override of a removed preference kept: %+v", status)
+	}
+}
+
+func TestValidateRoutingSchedule(t *testing.T) {
+	valid := RoutingPreference{Name: "a", Start: "09:00", End: "17:00", Weights: ObjectiveWeights{Latency: 1}}
+	cases := map[string]func(p *RoutingPreference){
+		"unnamed":      func(p *RoutingPreference) { p.Name = "" },
+		"default name": func(p *RoutingPreference) { p.Name = DefaultRoutingPreference },
+		"bad start":    func(p *RoutingPreference) { p.Start = "9am" },
+		"bad timezone": func(p *RoutingPreference) { p.Timezone = "Mars/Olympus" },
+		"bad day":      func(p *RoutingPreference) { p.Days = []string{"someday"} },
+		"zero weights": func(p *RoutingPreference) { p.Weights = ObjectiveWeights{} },
+	}
+	for name, mutate := range cases {
+		preference := valid
+		mutate(&preference)
+		if err := ValidateRoutingSchedule([]RoutingPreference{preference}); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+	if err := ValidateRoutingSchedule([]RoutingPreference{valid, valid}); err == nil {
+		t.Error("duplicate preference names accepted")
+	}
+}
+
+func TestCoordinatorRoutingOverride(t *testing.T) {
+	config := DefaultALMConfig()
+	config.MaxNodes = 1000
+	config.MaxEdges = 10000
+	config.RoutingSchedule = []RoutingPreference{
+		{Name: "never", Days: []string{"mon"}, Start: "00:00", End: "00:01", Timezone: "Pacific/Kiritimati",
+			Weights: ObjectiveWeights{Cost: 1}},
+	}
+	coordinator, err := NewALMCoordinator(config, nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+
+	if err := coordinator.OverrideRoutingPreference("never", time.Minute); err != nil {
+		t.Fatalf("OverrideRoutingPreference: %v", err)
+	}
+	if optimizer := coordinator.optimizer.Config(); optimizer.CostWeight != 1 || optimizer.LatencyWeight != 0 {
+		t.Errorf("optimizer weights not overridden: %+v", optimizer)
+	}
+	if !coordinator.ClearRoutingOverride() {
+		t.Error("ClearRoutingOverride reported no override")
+	}
+	if status := coordinator.RoutingSchedule(); status.Override != "" {
+		t.Errorf("override kept: %+v", status)
+	}
+
+	if _, err := coordinator.ApplyConfig(ConfigDelta{"routing_schedule": []interface{}{
+		map[string]interface{}{"name": "bad", "start": "25:00", "end": "06:00", "weights": map[string]interface{}{"cost": 1}},
+	}}); err == nil {
+		t.Error("schedule with an invalid start applied")
+	}
+}
@@ -0,0 +1,81 @@
+// Package internal implements the scrubbing policy applied to state exports, logs and traces
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/logging"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/scrub"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/tracing"
+)
+
+// StateExport is a copy of the coordinator state for sharing outside the
+// cluster, such as with support: nodes, edges, cached routes, registered
+// services and quotas, keyed like the snapshot format. Scrubbed reports
+// whether the scrubbing policy was applied.
+type StateExport struct {
+	ExportedAt time.Time
+	Scrubbed   bool
+	State      map[string]interface{}
+}
+
+// scrubPolicy returns the scrubbing policy configured by c
+func (c *ALMConfig) scrubPolicy() scrub.Policy {
+	return scrub.Policy{
+		MetadataKeys:    c.ScrubMetadataKeys,
+		AddressPatterns: c.ScrubAddressPatterns,
+	}
+}
+
+// Scrubber returns the scrubber of the configured policy, or nil when
+// nothing is scrubbed
+func (alm *ALMCoordinator) Scrubber() *scrub.Scrubber {
+	return alm.scrubber.Load()
+}
+
+// applyScrubPolicy builds the scrubber for policy and installs it
+func (alm *ALMCoordinator) applyScrubPolicy(policy scrub.Policy) error {
+	scrubber, err := scrub.New(policy)
+	if err != nil {
+		return fmt.Errorf("invalid scrubbing policy: %w", err)
+	}
+	alm.installScrubber(scrubber)
+	return nil
+}
+
+// installScrubber makes scrubber the coordinator's and the process's logs
+// and traces. A nil scrubber only uninstalls one this coordinator
+// installed, so coordinators without a policy leave another's in place.
+func (alm *ALMCoordinator) installScrubber(scrubber *scrub.Scrubber) {
+	if previous := alm.scrubber.Swap(scrubber); scrubber != nil || previous != nil {
+		logging.SetScrubber(scrubber)
+		tracing.SetScrubber(scrubber)
+	}
+}
+
+// ExportState returns the coordinator state with the scrubbing policy
+// applied: configured keys are redacted wherever they appear, including
+// in service metadata and tags, and address pattern matches are redacted
+// from every string
+func (alm *ALMCoordinator) ExportState() (*StateExport, error) {
+	alm.mutex.RLock()
+	data, err := json.Marshal(alm.captureState())
+	alm.mutex.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	var state map[string]interface{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode state: %w", err)
+	}
+
+	scrubber := alm.Scrubber()
+	return &StateExport{
+		ExportedAt: time.Now(),
+		Scrubbed:   scrubber != nil,
+		State:      scrubber.Metadata(state),
+	}, nil
+}
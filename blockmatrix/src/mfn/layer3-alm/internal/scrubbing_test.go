@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/service"
+)
+
+func TestExportStateScrubs(t *testing.T) {
+	config := DefaultALMConfig()
+	config.MaxNodes = 1000
+	config.MaxEdges = 10000
+	config.ScrubMetadataKeys = []string{"customer.*"}
+	config.ScrubAddressPatterns = []string{"ipv4", `[a-z0-9-]+\.corp\.internal`}
+	coordinator, err := NewALMCoordinator(config, nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+	defer coordinator.installScrubber(nil)
+
+	err = coordinator.UpdateNetworkTopology([]TopologyUpdate{
+		{Type: NodeAddUpdate, NodeID: 1, Node: &graph.NetworkNode{ID: 1, Address: "10.20.30.40:7000", Region: "eu"}},
+	})
+	if err != nil {
+		t.Fatalf("UpdateNetworkTopology: %v", err)
+	}
+	err = coordinator.ServiceRegistry().RegisterService(&service.ServiceInstance{
+		ID:       "billing-1",
+		Name:     "billing",
+		NodeID:   1,
+		Address:  "billing-1.corp.internal",
+		Port:     443,
+		Tags:     map[string]string{"customer.tier": "platinum"},
+		Metadata: map[string]interface{}{"customer.name": "Acme Corp", "build": "1.2.3"},
+	})
+	if err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+
+	export, err := coordinator.ExportState()
+	if err != nil {
+		t.Fatalf("ExportState: %v", err)
+	}
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"10.20.30.40", "corp.internal", "platinum", "Acme Corp"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("export contains %q", secret)
+		}
+	}
+	for _, kept := range []string{`"eu"`, "billing-1", ":7000"} {
+		if !strings.Contains(string(data), kept) {
+			t.Errorf("export lost %s", kept)
+		}
+	}
+	if !export.Scrubbed {
+		t.Error("export not marked scrubbed")
+	}
+
+	if _, err := coordinator.ApplyConfig(ConfigDelta{"scrub_address_patterns": []interface{}{"(unclosed"}}); err == nil {
+		t.Error("invalid address pattern applied")
+	}
+	if _, err := coordinator.ApplyConfig(ConfigDelta{"scrub_metadata_keys": []interface{}{}, "scrub_address_patterns": []interface{}{}}); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	if coordinator.Scrubber() != nil {
+		t.Error("scrubber kept after the policy was cleared")
+	}
+}
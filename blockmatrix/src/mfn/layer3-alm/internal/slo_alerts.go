@@ -0,0 +1,40 @@
+// Package internal implements periodic latency SLO evaluation
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+// sloEvaluationInterval is how often latency SLOs are measured; the
+// shortest burn-rate window of a 30 day SLO is five minutes
+const sloEvaluationInterval = 30 * time.Second
+
+// OnSLOAlert registers a callback invoked when a latency SLO burn-rate
+// alert fires, changes severity or resolves
+func (alm *ALMCoordinator) OnSLOAlert(listener func(alert routing.SLOAlert)) {
+	alm.routingTable.GetRoutingMetrics().SLOs().OnAlert(listener)
+}
+
+// LatencySLOs reports the latency SLOs and their error budgets
+func (alm *ALMCoordinator) LatencySLOs() []routing.SLOStatus {
+	return alm.routingTable.GetRoutingMetrics().SLOs().Status()
+}
+
+// runSLOAlerts evaluates the latency SLOs until ctx is done
+func (alm *ALMCoordinator) runSLOAlerts(ctx context.Context) {
+	ticker := time.NewTicker(sloEvaluationInterval)
+	defer ticker.Stop()
+
+	slos := alm.routingTable.GetRoutingMetrics().SLOs()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			slos.Evaluate()
+		}
+	}
+}
@@ -0,0 +1,353 @@
+// Package internal implements encryption of persisted coordinator state at rest
+package internal
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StateKeySize is the size of state encryption keys, for AES-256
+const StateKeySize = 32
+
+// sealedStateVersion starts every sealed payload. It is never '{', so
+// sealed and plaintext JSON records can be told apart in the log.
+const sealedStateVersion = 0x01
+
+// stateKeyTimeout bounds a key provider call
+const stateKeyTimeout = 30 * time.Second
+
+// Purposes sealed payloads are bound to, so a sealed log record cannot be
+// passed off as a snapshot or the reverse
+const (
+	sealSnapshot = "alm-snapshot"
+	sealRecord   = "alm-wal"
+)
+
+// ErrStateKeyUnavailable is returned when persisted state is encrypted with
+// a key that cannot be obtained
+var ErrStateKeyUnavailable = errors.New("state encryption key unavailable")
+
+// KeyProvider supplies the keys persisted state is encrypted with. New
+// data is sealed with the current key; the key ID is stored alongside so
+// data sealed with earlier keys can still be opened after rotation.
+type KeyProvider interface {
+	CurrentKey(ctx context.Context) (id string, key []byte, err error)
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// StaticKeys provides keys held in memory, such as those read from a key
+// file or environment variable. Keys are identified by a fingerprint, so
+// only the keys themselves need to be kept.
+type StaticKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewStaticKeys creates a provider for keys, the first of which is current
+func NewStaticKeys(keys ...[]byte) (*StaticKeys, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one state encryption key is required")
+	}
+
+	sk := &StaticKeys{keys: make(map[string][]byte, len(keys))}
+	for i, key := range keys {
+		if len(key) != StateKeySize {
+			return nil, fmt.Errorf("state encryption key %d is %d bytes, expected %d", i+1, len(key), StateKeySize)
+		}
+		id := keyFingerprint(key)
+		if i == 0 {
+			sk.current = id
+		}
+		sk.keys[id] = key
+	}
+	return sk, nil
+}
+
+// ParseStaticKeys decodes base64 keys separated by whitespace or commas,
+// the first of which is current
+func ParseStaticKeys(text string) (*StaticKeys, error) {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+
+	keys := make([][]byte, 0, len(fields))
+	for i, field := range fields {
+		key, err := base64.StdEncoding.DecodeString(field)
+		if err != nil {
+			return nil, fmt.Errorf("state encryption key %d: %w", i+1, err)
+		}
+		keys = append(keys, key)
+	}
+	return NewStaticKeys(keys...)
+}
+
+// StateKeysFromFile reads base64 keys from a file, newest first. The file
+// should be readable only by the coordinator.
+func StateKeysFromFile(path string) (*StaticKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state key file: %w", err)
+	}
+	return ParseStaticKeys(string(data))
+}
+
+// StateKeysFromEnv reads comma separated base64 keys, newest first, from
+// the environment variable name
+func StateKeysFromEnv(name string) (*StaticKeys, error) {
+	text, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+	return ParseStaticKeys(text)
+}
+
+// CurrentKey returns the first key
+func (sk *StaticKeys) CurrentKey(ctx context.Context) (string, []byte, error) {
+	return sk.current, sk.keys[sk.current], nil
+}
+
+// Key returns the key with the fingerprint id
+func (sk *StaticKeys) Key(ctx context.Context, id string) ([]byte, error) {
+	key, ok := sk.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: no key with fingerprint %s", ErrStateKeyUnavailable, id)
+	}
+	return key, nil
+}
+
+// keyFingerprint identifies a key without revealing it
+func keyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// KMS is a key management service holding the master key that state
+// encryption keys are wrapped with
+type KMS interface {
+	// GenerateDataKey returns a new data key and the key wrapped by the
+	// master key keyID
+	GenerateDataKey(ctx context.Context, keyID string) (plaintext, wrapped []byte, err error)
+
+	// Decrypt unwraps a data key wrapped by the master key keyID
+	Decrypt(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// KMSKeys provides data keys from a KMS. A data key is generated once per
+// process and its wrapped form is the key ID stored with the data, so only
+// the KMS can open persisted state.
+type KMSKeys struct {
+	kms   KMS
+	keyID string
+
+	current   string
+	unwrapped map[string][]byte
+	mutex     sync.Mutex
+}
+
+// NewKMSKeys creates a provider of data keys wrapped by the master key keyID
+func NewKMSKeys(kms KMS, keyID string) *KMSKeys {
+	return &KMSKeys{
+		kms:       kms,
+		keyID:     keyID,
+		unwrapped: make(map[string][]byte),
+	}
+}
+
+// CurrentKey returns this process's data key, generating it on first use
+func (kk *KMSKeys) CurrentKey(ctx context.Context) (string, []byte, error) {
+	kk.mutex.Lock()
+	defer kk.mutex.Unlock()
+
+	if kk.current != "" {
+		return kk.current, kk.unwrapped[kk.current], nil
+	}
+
+	key, wrapped, err := kk.kms.GenerateDataKey(ctx, kk.keyID)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: failed to generate data key: %v", ErrStateKeyUnavailable, err)
+	}
+	kk.current = base64.RawURLEncoding.EncodeToString(wrapped)
+	kk.unwrapped[kk.current] = key
+	return kk.current, key, nil
+}
+
+// Key unwraps the data key whose wrapped form is id
+func (kk *KMSKeys) Key(ctx context.Context, id string) ([]byte, error) {
+	kk.mutex.Lock()
+	defer kk.mutex.Unlock()
+
+	if key, ok := kk.unwrapped[id]; ok {
+		return key, nil
+	}
+
+	wrapped, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed wrapped key: %v", ErrStateKeyUnavailable, err)
+	}
+	key, err := kk.kms.Decrypt(ctx, kk.keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to unwrap data key: %v", ErrStateKeyUnavailable, err)
+	}
+	kk.unwrapped[id] = key
+	return key, nil
+}
+
+// stateCipher seals persisted state with AES-256-GCM. A sealed payload is
+// the version byte, the key ID length and key ID, the nonce and the
+// ciphertext; the purpose and key ID are authenticated with it.
+type stateCipher struct {
+	keys KeyProvider
+
+	currentID string
+	current   cipher.AEAD
+
+	// AEADs of keys opened so far, by ID
+	aeads map[string]cipher.AEAD
+	mutex sync.Mutex
+}
+
+// newStateCipher creates a cipher sealing with the provider's current key
+func newStateCipher(keys KeyProvider) (*stateCipher, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), stateKeyTimeout)
+	defer cancel()
+
+	id, key, err := keys.CurrentKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > 0xffff {
+		return nil, fmt.Errorf("state encryption key ID is %d bytes, longer than %d", len(id), 0xffff)
+	}
+	aead, err := newStateAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stateCipher{
+		keys:      keys,
+		currentID: id,
+		current:   aead,
+		aeads:     map[string]cipher.AEAD{id: aead},
+	}, nil
+}
+
+// newStateAEAD returns AES-256-GCM with key
+func newStateAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != StateKeySize {
+		return nil, fmt.Errorf("state encryption key is %d bytes, expected %d", len(key), StateKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext for purpose with the current key
+func (sc *stateCipher) seal(plaintext []byte, purpose string) ([]byte, error) {
+	header := make([]byte, 3, 3+len(sc.currentID)+sc.current.NonceSize())
+	header[0] = sealedStateVersion
+	binary.BigEndian.PutUint16(header[1:3], uint16(len(sc.currentID)))
+	header = append(header, sc.currentID...)
+
+	nonce := make([]byte, sc.current.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := append(header, nonce...)
+	return sc.current.Seal(sealed, nonce, plaintext, sealedAAD(purpose, sc.currentID)), nil
+}
+
+// open decrypts a payload sealed for purpose
+func (sc *stateCipher) open(sealed []byte, purpose string) ([]byte, error) {
+	if len(sealed) < 3 || sealed[0] != sealedStateVersion {
+		return nil, fmt.Errorf("unsupported sealed state format")
+	}
+	idLength := int(binary.BigEndian.Uint16(sealed[1:3]))
+	if len(sealed) < 3+idLength {
+		return nil, fmt.Errorf("sealed state is truncated")
+	}
+	id := string(sealed[3 : 3+idLength])
+
+	aead, err := sc.aead(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := sealed[3+idLength:]
+	if len(rest) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed state is truncated")
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], sealedAAD(purpose, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt state: %w", err)
+	}
+	return plaintext, nil
+}
+
+// aead returns the AEAD of the key with id, fetching the key on first use
+func (sc *stateCipher) aead(id string) (cipher.AEAD, error) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	if aead, ok := sc.aeads[id]; ok {
+		return aead, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), stateKeyTimeout)
+	defer cancel()
+
+	key, err := sc.keys.Key(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newStateAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	sc.aeads[id] = aead
+	return aead, nil
+}
+
+// sealedAAD binds a sealed payload to its purpose and key
+func sealedAAD(purpose, id string) []byte {
+	return []byte(purpose + "\n" + id)
+}
+
+// SetStateKeys sets the provider persisted state is encrypted with, such
+// as KMS data keys, overriding StateKeyFile and StateKeyEnv. It takes
+// effect when the coordinator starts.
+func (alm *ALMCoordinator) SetStateKeys(keys KeyProvider) {
+	alm.mutex.Lock()
+	defer alm.mutex.Unlock()
+
+	alm.stateKeys = keys
+}
+
+// stateKeyProvider returns the provider set with SetStateKeys, or the keys
+// configured by StateKeyFile or StateKeyEnv, or nil to persist in the
+// clear; callers hold the lock
+func (alm *ALMCoordinator) stateKeyProvider() (KeyProvider, error) {
+	switch {
+	case alm.stateKeys != nil:
+		return alm.stateKeys, nil
+	case alm.config.StateKeyFile != "":
+		return StateKeysFromFile(alm.config.StateKeyFile)
+	case alm.config.StateKeyEnv != "":
+		return StateKeysFromEnv(alm.config.StateKeyEnv)
+	}
+	return nil, nil
+}
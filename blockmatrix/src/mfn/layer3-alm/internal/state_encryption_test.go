@@ -0,0 +1,241 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+// recoverEncryptedStore opens a state store on dir encrypted with keys and
+// runs recovery
+func recoverEncryptedStore(t *testing.T, dir string, keys KeyProvider) (*StateStore, *coordinatorState, []walRecord, error) {
+	t.Helper()
+	return recoverStoreWith(t, &StateStoreConfig{Dir: dir, Keys: keys})
+}
+
+// recoverStoreWith opens a state store with config and runs recovery
+func recoverStoreWith(t *testing.T, config *StateStoreConfig) (*StateStore, *coordinatorState, []walRecord, error) {
+	t.Helper()
+
+	store, err := NewStateStore(config, nil)
+	if err != nil {
+		t.Fatalf("NewStateStore: %v", err)
+	}
+	state, records, err := store.recover(&RecoveryReport{})
+	if err == nil {
+		t.Cleanup(func() { store.Close() })
+	}
+	return store, state, records, err
+}
+
+func newStateKey(t *testing.T) []byte {
+	t.Helper()
+
+	key := make([]byte, StateKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// assertNoPlaintext fails if any file in dir contains text
+func assertNoPlaintext(t *testing.T, dir, text string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte(text)) {
+			t.Errorf("%s contains %q in the clear", entry.Name(), text)
+		}
+	}
+}
+
+func TestStateStoreEncryption(t *testing.T) {
+	dir := t.TempDir()
+	oldKey, newKey := newStateKey(t), newStateKey(t)
+	oldKeys, err := NewStaticKeys(oldKey)
+	if err != nil {
+		t.Fatalf("NewStaticKeys: %v", err)
+	}
+
+	store, _, _, err := recoverEncryptedStore(t, dir, oldKeys)
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	state := &coordinatorState{Nodes: []*graph.NetworkNode{{ID: 1, Address: "10.1.2.3:9000", Region: "secret-region"}}}
+	appendNode(t, store, 1)
+	snapshotState(t, store, state)
+	if err := store.Append([]TopologyUpdate{{Type: NodeAddUpdate, Node: &graph.NetworkNode{ID: 2, Region: "secret-region"}}}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	store.Close()
+
+	assertNoPlaintext(t, dir, "secret-region")
+
+	// Without keys the state cannot be read, and is left in place
+	_, _, _, err = recoverEncryptedStore(t, dir, nil)
+	if !errors.Is(err, errStateDecryption) {
+		t.Fatalf("recover without keys: got %v, want errStateDecryption", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, snapshotFileName)); err != nil {
+		t.Errorf("snapshot not left in place: %v", err)
+	}
+
+	// A rotated key set still opens state sealed with the old key
+	rotated, err := NewStaticKeys(newKey, oldKey)
+	if err != nil {
+		t.Fatalf("NewStaticKeys: %v", err)
+	}
+	_, recovered, records, err := recoverEncryptedStore(t, dir, rotated)
+	if err != nil {
+		t.Fatalf("recover with rotated keys: %v", err)
+	}
+	if len(recovered.Nodes) != 1 || recovered.Nodes[0].Region != "secret-region" {
+		t.Errorf("recovered nodes: %+v", recovered.Nodes)
+	}
+	if len(records) != 1 || records[0].Updates[0].Node.ID != 2 {
+		t.Errorf("replayed records: %+v", records)
+	}
+}
+
+func TestStateStoreEncryptsExistingStore(t *testing.T) {
+	dir := t.TempDir()
+
+	store, _, _, _ := openTestStore(t, dir)
+	appendNode(t, store, 1)
+	appendNode(t, store, 2)
+	store.Close()
+
+	keys, err := ParseStaticKeys(base64.StdEncoding.EncodeToString(newStateKey(t)))
+	if err != nil {
+		t.Fatalf("ParseStaticKeys: %v", err)
+	}
+
+	// Plaintext state is refused and left in place unless migrating
+	segments, _ := listSegments(dir)
+	before, _ := os.ReadFile(segments[0].path)
+	if _, _, _, err := recoverEncryptedStore(t, dir, keys); !errors.Is(err, errStateDecryption) {
+		t.Fatalf("recover plaintext store with keys: got %v, want errStateDecryption", err)
+	}
+	if after, _ := os.ReadFile(segments[0].path); !bytes.Equal(before, after) {
+		t.Error("refused plaintext log was modified")
+	}
+
+	store, _, records, err := recoverStoreWith(t, &StateStoreConfig{Dir: dir, Keys: keys, AllowPlaintext: true})
+	if err != nil {
+		t.Fatalf("migrate plaintext store: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("replayed %d plaintext records, want 2", len(records))
+	}
+
+	appendNode(t, store, 3)
+	snapshotNodes(t, store, 1, 2, 3)
+	store.Close()
+
+	// Once migrated, the store opens without allowing plaintext
+	_, state, records, err := recoverEncryptedStore(t, dir, keys)
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if len(state.Nodes) != 3 || len(records) != 0 {
+		t.Errorf("recovered %d nodes and %d records, want 3 and 0", len(state.Nodes), len(records))
+	}
+}
+
+func TestStateStoreRefusesPlaintextSnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	store, _, _, _ := openTestStore(t, dir)
+	appendNode(t, store, 1)
+	snapshotNodes(t, store, 1)
+	store.Close()
+
+	keys, err := NewStaticKeys(newStateKey(t))
+	if err != nil {
+		t.Fatalf("NewStaticKeys: %v", err)
+	}
+	if _, _, _, err := recoverEncryptedStore(t, dir, keys); !errors.Is(err, errStateDecryption) {
+		t.Fatalf("recover plaintext snapshot with keys: got %v, want errStateDecryption", err)
+	}
+	// The snapshot is not mistaken for a corrupt one and quarantined
+	if _, err := os.Stat(filepath.Join(dir, snapshotFileName)); err != nil {
+		t.Errorf("snapshot not left in place: %v", err)
+	}
+
+	_, state, _, err := recoverStoreWith(t, &StateStoreConfig{Dir: dir, Keys: keys, AllowPlaintext: true})
+	if err != nil || len(state.Nodes) != 1 {
+		t.Errorf("migrate plaintext snapshot: recovered %+v, %v", state, err)
+	}
+}
+
+// xorKMS wraps data keys by XOR with a master key
+type xorKMS struct {
+	master    []byte
+	decrypted int
+}
+
+func (k *xorKMS) GenerateDataKey(ctx context.Context, keyID string) ([]byte, []byte, error) {
+	key := make([]byte, StateKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	return key, k.xor(key), nil
+}
+
+func (k *xorKMS) Decrypt(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != "alm-state" {
+		return nil, errors.New("unknown master key")
+	}
+	k.decrypted++
+	return k.xor(wrapped), nil
+}
+
+func (k *xorKMS) xor(data []byte) []byte {
+	out := make([]byte, len(data))
+	for i := range data {
+		out[i] = data[i] ^ k.master[i%len(k.master)]
+	}
+	return out
+}
+
+func TestKMSKeys(t *testing.T) {
+	kms := &xorKMS{master: newStateKey(t)}
+	dir := t.TempDir()
+
+	store, _, _, err := recoverEncryptedStore(t, dir, NewKMSKeys(kms, "alm-state"))
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	appendNode(t, store, 1)
+	appendNode(t, store, 2)
+	store.Close()
+
+	// A new process generates a new data key and unwraps the old one once
+	_, _, records, err := recoverEncryptedStore(t, dir, NewKMSKeys(kms, "alm-state"))
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if len(records) != 2 || kms.decrypted != 1 {
+		t.Errorf("replayed %d records with %d unwraps, want 2 with 1", len(records), kms.decrypted)
+	}
+
+	_, _, _, err = recoverEncryptedStore(t, dir, NewKMSKeys(kms, "other"))
+	if !errors.Is(err, ErrStateKeyUnavailable) {
+		t.Errorf("recover with the wrong master key: got %v, want ErrStateKeyUnavailable", err)
+	}
+}
@@ -0,0 +1,365 @@
+// Package internal implements the adapter between the STOQ transport and the ALM topology
+package internal
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+	"go.uber.org/zap"
+)
+
+// STOQQuality is a transport quality measurement STOQ took on its
+// connection from NodeID to PeerID
+type STOQQuality struct {
+	NodeID    int64
+	PeerID    int64
+	Timestamp time.Time
+
+	// Smoothed round-trip time and its variation, as STOQ's congestion
+	// control tracks them
+	RTT         time.Duration
+	RTTVariance time.Duration
+
+	// Delivery rate in MB/s and the share of packets lost, 0.0-1.0
+	Throughput float64
+	LossRate   float64
+}
+
+// STOQPathHint tells STOQ the route ALM chose between two nodes, so it can
+// pin connections to the path and spread traffic over the alternatives by
+// their weights until Expires
+type STOQPathHint struct {
+	Source      int64
+	Destination int64
+	QoSClass    routing.QoSClass
+
+	Path         []int64
+	Weight       float64
+	Latency      time.Duration
+	Alternatives []STOQAlternativePath
+
+	Expires time.Time
+}
+
+// STOQAlternativePath is an alternative path in a hint with its share of
+// traffic
+type STOQAlternativePath struct {
+	Path    []int64
+	Weight  float64
+	Latency time.Duration
+}
+
+// STOQAdapter is implemented by the STOQ transport. Quality measurements
+// are streamed until the channel closes, after which the bridge subscribes
+// again; path hints are delivered in batches.
+type STOQAdapter interface {
+	SubscribeQuality(ctx context.Context) (<-chan STOQQuality, error)
+	SendPathHints(ctx context.Context, hints []STOQPathHint) error
+}
+
+// STOQConfig configures a STOQBridge
+type STOQConfig struct {
+	// Measurements are applied in batches of up to BatchSize, at least
+	// every BatchInterval; within a batch the latest of each link wins
+	BatchSize     int
+	BatchInterval time.Duration
+
+	// Route decisions wait in a queue of HintQueueSize, dropped when it is
+	// full, and are sent in batches of up to HintBatchSize. Hints expire
+	// after HintTTL.
+	HintQueueSize int
+	HintBatchSize int
+	HintTTL       time.Duration
+
+	// Delay before subscribing again after SubscribeQuality fails or the
+	// feed ends
+	ResubscribeInterval time.Duration
+}
+
+// STOQStats summarizes bridge activity
+type STOQStats struct {
+	Measurements  int64
+	Updates       int64
+	Batches       int64
+	Ignored       int64
+	Subscriptions int64
+
+	Hints        int64
+	HintsDropped int64
+	HintFailures int64
+}
+
+// STOQBridge feeds the transport quality STOQ measures on its connections
+// into the edge metrics of the topology, and hands ALM route decisions to
+// STOQ as path hints
+type STOQBridge struct {
+	coordinator *ALMCoordinator
+	adapter     STOQAdapter
+	config      *STOQConfig
+	logger      *zap.Logger
+
+	// Route decisions waiting to be sent; hints are only queued while the
+	// bridge runs
+	hints   chan STOQPathHint
+	running atomic.Bool
+
+	// Counters
+	measurements  atomic.Int64
+	updates       atomic.Int64
+	batches       atomic.Int64
+	ignored       atomic.Int64
+	subscriptions atomic.Int64
+	sent          atomic.Int64
+	dropped       atomic.Int64
+	failures      atomic.Int64
+}
+
+// NewSTOQBridge creates a bridge between adapter and coordinator
+func NewSTOQBridge(coordinator *ALMCoordinator, adapter STOQAdapter, config *STOQConfig, logger *zap.Logger) *STOQBridge {
+	if config == nil {
+		config = DefaultSTOQConfig()
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1
+	}
+	if config.HintBatchSize <= 0 {
+		config.HintBatchSize = 1
+	}
+
+	return &STOQBridge{
+		coordinator: coordinator,
+		adapter:     adapter,
+		config:      config,
+		logger:      logger,
+		hints:       make(chan STOQPathHint, config.HintQueueSize),
+	}
+}
+
+// Run applies quality measurements and sends path hints until ctx is
+// cancelled
+func (sb *STOQBridge) Run(ctx context.Context) {
+	sb.running.Store(true)
+	defer sb.running.Store(false)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sb.sendHints(ctx)
+	}()
+	defer wg.Wait()
+
+	for {
+		measurements, err := sb.adapter.SubscribeQuality(ctx)
+		if err != nil {
+			sb.logger.Error("STOQ quality subscription failed", zap.Error(err))
+		} else {
+			sb.subscriptions.Add(1)
+			sb.consume(ctx, measurements)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(sb.config.ResubscribeInterval):
+		}
+	}
+}
+
+// Hint queues the route chosen for request as a path hint. It never
+// blocks: the hint is dropped when the queue is full or the bridge is not
+// running.
+func (sb *STOQBridge) Hint(request RouteRequest, response *RouteResponse) {
+	if !sb.running.Load() || len(response.Path) == 0 {
+		return
+	}
+
+	select {
+	case sb.hints <- sb.pathHint(request, response, time.Now()):
+	default:
+		sb.dropped.Add(1)
+	}
+}
+
+// Stats returns bridge counters
+func (sb *STOQBridge) Stats() STOQStats {
+	return STOQStats{
+		Measurements:  sb.measurements.Load(),
+		Updates:       sb.updates.Load(),
+		Batches:       sb.batches.Load(),
+		Ignored:       sb.ignored.Load(),
+		Subscriptions: sb.subscriptions.Load(),
+		Hints:         sb.sent.Load(),
+		HintsDropped:  sb.dropped.Load(),
+		HintFailures:  sb.failures.Load(),
+	}
+}
+
+// consume batches measurements from one subscription until it ends
+func (sb *STOQBridge) consume(ctx context.Context, measurements <-chan STOQQuality) {
+	ticker := time.NewTicker(sb.config.BatchInterval)
+	defer ticker.Stop()
+
+	batch := make([]STOQQuality, 0, sb.config.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			sb.apply(batch)
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			flush()
+			return
+
+		case measurement, ok := <-measurements:
+			if !ok {
+				flush()
+				return
+			}
+			sb.measurements.Add(1)
+			batch = append(batch, measurement)
+			if len(batch) >= sb.config.BatchSize {
+				flush()
+			}
+
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// apply converts a batch of measurements and applies the resulting updates
+func (sb *STOQBridge) apply(measurements []STOQQuality) {
+	updates := sb.convert(measurements)
+	if len(updates) == 0 {
+		return
+	}
+
+	if err := sb.coordinator.UpdateNetworkTopology(updates); err != nil {
+		sb.logger.Error("Failed to apply STOQ quality updates", zap.Error(err))
+		return
+	}
+
+	sb.batches.Add(1)
+	sb.updates.Add(int64(len(updates)))
+}
+
+// convert maps measurements to edge metric updates. STOQ measures
+// connections over links ALM already knows, so measurements of links
+// missing from the graph are ignored rather than added.
+func (sb *STOQBridge) convert(measurements []STOQQuality) []TopologyUpdate {
+	networkGraph := sb.coordinator.NetworkGraph()
+	updates := make([]TopologyUpdate, 0, len(measurements))
+	index := make(map[[2]int64]int)
+
+	for _, measurement := range measurements {
+		link := [2]int64{measurement.NodeID, measurement.PeerID}
+		if i, seen := index[link]; seen {
+			updates[i].EdgeMetrics = qualityMetrics(measurement)
+			continue
+		}
+		if _, exists := networkGraph.GetEdge(link[0], link[1]); !exists {
+			sb.ignored.Add(1)
+			continue
+		}
+
+		index[link] = len(updates)
+		updates = append(updates, TopologyUpdate{
+			Type:        EdgeMetricsUpdate,
+			EdgeFrom:    link[0],
+			EdgeTo:      link[1],
+			EdgeMetrics: qualityMetrics(measurement),
+		})
+	}
+
+	return updates
+}
+
+// sendHints delivers queued hints in batches until ctx is cancelled
+func (sb *STOQBridge) sendHints(ctx context.Context) {
+	batch := make([]STOQPathHint, 0, sb.config.HintBatchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case hint := <-sb.hints:
+			batch = append(batch[:0], hint)
+		}
+
+		// Take whatever else is already waiting
+	fill:
+		for len(batch) < sb.config.HintBatchSize {
+			select {
+			case hint := <-sb.hints:
+				batch = append(batch, hint)
+			default:
+				break fill
+			}
+		}
+
+		if err := sb.adapter.SendPathHints(ctx, batch); err != nil {
+			sb.failures.Add(int64(len(batch)))
+			sb.logger.Debug("Failed to send STOQ path hints", zap.Int("hints", len(batch)), zap.Error(err))
+			continue
+		}
+		sb.sent.Add(int64(len(batch)))
+	}
+}
+
+// pathHint converts the route chosen for a request into a hint expiring
+// HintTTL after now
+func (sb *STOQBridge) pathHint(request RouteRequest, response *RouteResponse, now time.Time) STOQPathHint {
+	hint := STOQPathHint{
+		Source:      request.SourceID,
+		Destination: request.DestinationID,
+		QoSClass:    clampQoSClass(routing.QoSClass(request.QoSClass)),
+		Path:        response.Path,
+		Weight:      response.Weight,
+		Latency:     response.TotalLatency,
+		Expires:     now.Add(sb.config.HintTTL),
+	}
+	for _, alternative := range response.Alternatives {
+		hint.Alternatives = append(hint.Alternatives, STOQAlternativePath{
+			Path:    alternative.Path,
+			Weight:  alternative.Weight,
+			Latency: alternative.Latency,
+		})
+	}
+	return hint
+}
+
+// qualityMetrics converts a STOQ measurement to edge metrics. The RTT
+// covers both directions, so the link latency is half of it.
+func qualityMetrics(measurement STOQQuality) graph.EdgeMetrics {
+	return graph.EdgeMetrics{
+		Latency:     measurement.RTT / 2,
+		Bandwidth:   measurement.Throughput,
+		PacketLoss:  measurement.LossRate,
+		Jitter:      measurement.RTTVariance,
+		Reliability: 1.0 - measurement.LossRate,
+	}
+}
+
+// DefaultSTOQConfig returns default STOQ bridge configuration
+func DefaultSTOQConfig() *STOQConfig {
+	return &STOQConfig{
+		BatchSize:           64,
+		BatchInterval:       100 * time.Millisecond,
+		HintQueueSize:       1024,
+		HintBatchSize:       64,
+		HintTTL:             30 * time.Second,
+		ResubscribeInterval: 5 * time.Second,
+	}
+}
@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+// fakeSTOQ streams measurements from a channel and records hints
+type fakeSTOQ struct {
+	quality chan STOQQuality
+
+	mutex sync.Mutex
+	hints []STOQPathHint
+}
+
+func (f *fakeSTOQ) SubscribeQuality(ctx context.Context) (<-chan STOQQuality, error) {
+	return f.quality, nil
+}
+
+func (f *fakeSTOQ) SendPathHints(ctx context.Context, hints []STOQPathHint) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.hints = append(f.hints, hints...)
+	return nil
+}
+
+func (f *fakeSTOQ) sent() []STOQPathHint {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]STOQPathHint(nil), f.hints...)
+}
+
+func TestSTOQBridgeConvertsQuality(t *testing.T) {
+	alm := newTestCoordinator(t)
+	var updates []TopologyUpdate
+	for id := int64(1); id <= 2; id++ {
+		updates = append(updates, TopologyUpdate{Type: NodeAddUpdate, Node: &graph.NetworkNode{ID: id, Reliability: 1, Services: map[string]graph.ServiceInfo{}}})
+	}
+	updates = append(updates, TopologyUpdate{Type: EdgeAddUpdate, Edge: &graph.NetworkEdge{From: 1, To: 2, Latency: time.Millisecond, Reliability: 1}})
+	if err := alm.UpdateNetworkTopology(updates); err != nil {
+		t.Fatalf("UpdateNetworkTopology: %v", err)
+	}
+
+	bridge := NewSTOQBridge(alm, &fakeSTOQ{}, nil, nil)
+	converted := bridge.convert([]STOQQuality{
+		{NodeID: 1, PeerID: 2, RTT: 4 * time.Millisecond, LossRate: 0.1},
+		{NodeID: 2, PeerID: 1, RTT: 4 * time.Millisecond},
+		{NodeID: 1, PeerID: 2, RTT: 6 * time.Millisecond, RTTVariance: time.Millisecond, Throughput: 50, LossRate: 0.05},
+	})
+
+	if len(converted) != 1 || converted[0].Type != EdgeMetricsUpdate || converted[0].EdgeFrom != 1 || converted[0].EdgeTo != 2 {
+		t.Fatalf("converted to %+v, want one metrics update of 1->2", converted)
+	}
+	want := graph.EdgeMetrics{Latency: 3 * time.Millisecond, Bandwidth: 50, PacketLoss: 0.05, Jitter: time.Millisecond, Reliability: 0.95}
+	if converted[0].EdgeMetrics != want {
+		t.Errorf("metrics %+v, want the latest measurement %+v", converted[0].EdgeMetrics, want)
+	}
+	if stats := bridge.Stats(); stats.Ignored != 1 {
+		t.Errorf("ignored %d measurements, want the unknown link 2->1", stats.Ignored)
+	}
+}
+
+func TestSTOQBridgeSendsPathHints(t *testing.T) {
+	adapter := &fakeSTOQ{quality: make(chan STOQQuality)}
+	bridge := NewSTOQBridge(newTestCoordinator(t), adapter, nil, nil)
+
+	request := RouteRequest{SourceID: 1, DestinationID: 3, QoSClass: int(routing.CriticalMission)}
+	response := &RouteResponse{
+		Path:         []int64{1, 2, 3},
+		Weight:       0.7,
+		TotalLatency: 2 * time.Millisecond,
+		Alternatives: []AlternativeRoute{{Path: []int64{1, 4, 3}, Weight: 0.3, Latency: 3 * time.Millisecond}},
+	}
+
+	bridge.Hint(request, response)
+	if stats := bridge.Stats(); stats.HintsDropped != 0 {
+		t.Fatalf("hint queued before the bridge runs")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		bridge.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !bridge.running.Load() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	bridge.Hint(request, response)
+	for len(adapter.sent()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	hints := adapter.sent()
+	if len(hints) != 1 {
+		t.Fatalf("sent %d hints, want 1", len(hints))
+	}
+	hint := hints[0]
+	if hint.Source != 1 || hint.Destination != 3 || hint.QoSClass != routing.CriticalMission || hint.Weight != 0.7 || len(hint.Path) != 3 {
+		t.Errorf("hint %+v does not describe the route", hint)
+	}
+	if len(hint.Alternatives) != 1 || hint.Alternatives[0].Weight != 0.3 || hint.Alternatives[0].Latency != 3*time.Millisecond {
+		t.Errorf("hint alternatives %+v", hint.Alternatives)
+	}
+	if until := time.Until(hint.Expires); until <= 0 || until > DefaultSTOQConfig().HintTTL {
+		t.Errorf("hint expires in %s, want within the hint TTL", until)
+	}
+}
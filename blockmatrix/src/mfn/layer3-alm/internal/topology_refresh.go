@@ -0,0 +1,375 @@
+// Package internal implements periodic topology refresh from live peer measurements
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"go.uber.org/zap"
+)
+
+// PeerSample is one measurement of the link from the local node to a peer
+type PeerSample struct {
+	PeerID int64
+
+	// False when the peer could not be reached; the other fields are then unset
+	Reachable bool
+
+	Latency    time.Duration
+	Jitter     time.Duration
+	Bandwidth  float64 // MB/s; zero when not measured
+	PacketLoss float64 // 0.0-1.0
+	Timestamp  time.Time
+}
+
+// TopologySource measures the links from the local node to its peers, for
+// example from transport connection statistics and probes
+type TopologySource interface {
+	Sample(ctx context.Context) ([]PeerSample, error)
+}
+
+// TopologyRefreshConfig configures a TopologyRefresher
+type TopologyRefreshConfig struct {
+	// Node the sampled links start from
+	LocalNodeID int64
+
+	// Apply link measurements in both directions
+	SymmetricLinks bool
+
+	// Weight of a new sample in the smoothed link metrics, in (0, 1]; 1
+	// uses each sample as is
+	Smoothing float64
+
+	// Consecutive unreachable samples before a link is marked down
+	FailureThreshold int
+
+	// Bound on one Sample call
+	SampleTimeout time.Duration
+}
+
+// TopologyRefreshStats summarizes refresh activity
+type TopologyRefreshStats struct {
+	Refreshes   int64
+	Failures    int64
+	Samples     int64
+	Updates     int64
+	LinksDown   int64
+	LastRefresh time.Time
+}
+
+// peerLink is the smoothed state of the link to one peer
+type peerLink struct {
+	latency    time.Duration
+	jitter     time.Duration
+	bandwidth  float64
+	packetLoss float64
+	sampled    bool
+
+	// Consecutive unreachable samples, and whether the link was marked down
+	failures int
+	down     bool
+}
+
+// TopologyRefresher converts peer measurements into node and link metric
+// updates. Each refresh smooths the new samples into the links it has seen,
+// updates the links and peer nodes in the graph, adds links to known peers
+// that are missing, and marks a link down once its peer has been unreachable
+// FailureThreshold times in a row.
+type TopologyRefresher struct {
+	coordinator *ALMCoordinator
+	source      TopologySource
+	config      *TopologyRefreshConfig
+	logger      *zap.Logger
+
+	// Link state by peer, owned by Refresh
+	links map[int64]*peerLink
+	mutex sync.Mutex
+
+	// Counters
+	refreshes   atomic.Int64
+	failures    atomic.Int64
+	samples     atomic.Int64
+	updates     atomic.Int64
+	linksDown   atomic.Int64
+	lastRefresh atomic.Int64
+}
+
+// NewTopologyRefresher creates a refresher applying samples from source to coordinator
+func NewTopologyRefresher(coordinator *ALMCoordinator, source TopologySource, config *TopologyRefreshConfig, logger *zap.Logger) *TopologyRefresher {
+	if config == nil {
+		config = DefaultTopologyRefreshConfig()
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &TopologyRefresher{
+		coordinator: coordinator,
+		source:      source,
+		config:      config,
+		logger:      logger,
+		links:       make(map[int64]*peerLink),
+	}
+}
+
+// Refresh samples the source once and applies the resulting updates
+func (tr *TopologyRefresher) Refresh(ctx context.Context) error {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	sampleCtx, cancel := context.WithTimeout(ctx, tr.config.SampleTimeout)
+	samples, err := tr.source.Sample(sampleCtx)
+	cancel()
+	if err != nil {
+		tr.failures.Add(1)
+		return fmt.Errorf("topology sample failed: %w", err)
+	}
+	tr.samples.Add(int64(len(samples)))
+
+	updates := tr.convert(samples)
+	if len(updates) > 0 {
+		if err := tr.coordinator.UpdateNetworkTopology(updates); err != nil {
+			tr.failures.Add(1)
+			return fmt.Errorf("failed to apply refreshed topology: %w", err)
+		}
+		tr.updates.Add(int64(len(updates)))
+	}
+
+	tr.refreshes.Add(1)
+	tr.lastRefresh.Store(time.Now().UnixNano())
+	return nil
+}
+
+// Stats returns refresh counters
+func (tr *TopologyRefresher) Stats() TopologyRefreshStats {
+	stats := TopologyRefreshStats{
+		Refreshes: tr.refreshes.Load(),
+		Failures:  tr.failures.Load(),
+		Samples:   tr.samples.Load(),
+		Updates:   tr.updates.Load(),
+		LinksDown: tr.linksDown.Load(),
+	}
+	if last := tr.lastRefresh.Load(); last != 0 {
+		stats.LastRefresh = time.Unix(0, last)
+	}
+	return stats
+}
+
+// convert folds samples into the link state and maps them to topology updates
+func (tr *TopologyRefresher) convert(samples []PeerSample) []TopologyUpdate {
+	networkGraph := tr.coordinator.NetworkGraph()
+	local := tr.config.LocalNodeID
+	if _, exists := networkGraph.GetNode(local); !exists {
+		tr.logger.Debug("Local node is not in the topology; skipping refresh", zap.Int64("node_id", local))
+		return nil
+	}
+
+	var updates []TopologyUpdate
+	for _, sample := range samples {
+		if sample.PeerID == local {
+			continue
+		}
+		peer, exists := networkGraph.GetNode(sample.PeerID)
+		if !exists {
+			continue
+		}
+
+		link := tr.links[sample.PeerID]
+		if link == nil {
+			link = &peerLink{}
+			tr.links[sample.PeerID] = link
+		}
+
+		if !sample.Reachable {
+			link.failures++
+			if link.down || link.failures < tr.config.FailureThreshold {
+				continue
+			}
+
+			link.down = true
+			tr.linksDown.Add(1)
+			tr.logger.Warn("Peer unreachable; marking link down",
+				zap.Int64("peer_id", sample.PeerID),
+				zap.Int("failed_samples", link.failures),
+			)
+			for _, direction := range tr.directions(sample.PeerID) {
+				edge, exists := networkGraph.GetEdge(direction[0], direction[1])
+				if !exists {
+					continue
+				}
+				updates = append(updates, TopologyUpdate{
+					Type:     EdgeMetricsUpdate,
+					EdgeFrom: direction[0],
+					EdgeTo:   direction[1],
+					EdgeMetrics: graph.EdgeMetrics{
+						Latency:     edge.Latency,
+						Bandwidth:   edge.Bandwidth,
+						Jitter:      edge.Jitter,
+						PacketLoss:  1,
+						Reliability: 0,
+					},
+				})
+			}
+			continue
+		}
+
+		if link.down {
+			tr.logger.Info("Peer reachable again", zap.Int64("peer_id", sample.PeerID))
+		}
+		link.failures = 0
+		link.down = false
+		tr.smooth(link, sample)
+
+		timestamp := sample.Timestamp
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+
+		for _, direction := range tr.directions(sample.PeerID) {
+			from, to := direction[0], direction[1]
+
+			edge, exists := networkGraph.GetEdge(from, to)
+			if !exists {
+				updates = append(updates, TopologyUpdate{Type: EdgeAddUpdate, Edge: &graph.NetworkEdge{
+					From:        from,
+					To:          to,
+					Weight:      float64(link.latency.Microseconds()),
+					Latency:     link.latency,
+					Bandwidth:   link.bandwidth,
+					PacketLoss:  link.packetLoss,
+					Jitter:      link.jitter,
+					Reliability: 1.0 - link.packetLoss,
+					Stability:   1.0,
+					LastUpdate:  timestamp,
+				}})
+				continue
+			}
+
+			bandwidth := link.bandwidth
+			if bandwidth == 0 {
+				bandwidth = edge.Bandwidth
+			}
+			updates = append(updates, TopologyUpdate{
+				Type:     EdgeMetricsUpdate,
+				EdgeFrom: from,
+				EdgeTo:   to,
+				EdgeMetrics: graph.EdgeMetrics{
+					Latency:     link.latency,
+					Bandwidth:   bandwidth,
+					PacketLoss:  link.packetLoss,
+					Jitter:      link.jitter,
+					Reliability: 1.0 - link.packetLoss,
+				},
+			})
+		}
+
+		// The peer as seen from here; its load is left to the peer to report
+		throughput := link.bandwidth
+		if throughput == 0 {
+			throughput = peer.Throughput
+		}
+		updates = append(updates, TopologyUpdate{
+			Type:   MetricsUpdate,
+			NodeID: sample.PeerID,
+			Metrics: graph.NodeMetrics{
+				Latency:     link.latency,
+				Throughput:  throughput,
+				Reliability: 1.0 - link.packetLoss,
+				LoadFactor:  peer.LoadFactor,
+			},
+		})
+	}
+
+	return updates
+}
+
+// smooth folds a reachable sample into the link's moving averages
+func (tr *TopologyRefresher) smooth(link *peerLink, sample PeerSample) {
+	alpha := tr.config.Smoothing
+	if !link.sampled || alpha <= 0 || alpha >= 1 {
+		link.latency = sample.Latency
+		link.jitter = sample.Jitter
+		link.bandwidth = sample.Bandwidth
+		link.packetLoss = sample.PacketLoss
+		link.sampled = true
+		return
+	}
+
+	blend := func(previous, next float64) float64 {
+		return alpha*next + (1-alpha)*previous
+	}
+	link.latency = time.Duration(blend(float64(link.latency), float64(sample.Latency)))
+	link.jitter = time.Duration(blend(float64(link.jitter), float64(sample.Jitter)))
+	link.packetLoss = blend(link.packetLoss, sample.PacketLoss)
+	if sample.Bandwidth > 0 {
+		link.bandwidth = blend(link.bandwidth, sample.Bandwidth)
+	}
+}
+
+// directions returns the graph edges a sample of the link to peer applies to
+func (tr *TopologyRefresher) directions(peer int64) [][2]int64 {
+	local := tr.config.LocalNodeID
+	if tr.config.SymmetricLinks {
+		return [][2]int64{{local, peer}, {peer, local}}
+	}
+	return [][2]int64{{local, peer}}
+}
+
+// AttachTopologySource refreshes the topology from source every
+// TopologyRefresh once the coordinator starts
+func (alm *ALMCoordinator) AttachTopologySource(source TopologySource, config *TopologyRefreshConfig) *TopologyRefresher {
+	refresher := NewTopologyRefresher(alm, source, config, alm.logger)
+
+	alm.mutex.Lock()
+	defer alm.mutex.Unlock()
+
+	alm.topologyRefresher = refresher
+	return refresher
+}
+
+// startTopologyRefresh refreshes the topology from the attached source every
+// TopologyRefresh until ctx is done
+func (alm *ALMCoordinator) startTopologyRefresh(ctx context.Context) {
+	interval := alm.Config().TopologyRefresh
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-alm.configUpdates():
+			if next := alm.Config().TopologyRefresh; next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+		case <-ticker.C:
+			alm.mutex.RLock()
+			refresher := alm.topologyRefresher
+			alm.mutex.RUnlock()
+
+			if refresher == nil {
+				continue
+			}
+			if err := refresher.Refresh(ctx); err != nil && !errors.Is(err, ErrStandby) && ctx.Err() == nil {
+				alm.logger.Warn("Topology refresh failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// DefaultTopologyRefreshConfig returns default topology refresh configuration
+func DefaultTopologyRefreshConfig() *TopologyRefreshConfig {
+	return &TopologyRefreshConfig{
+		SymmetricLinks:   true,
+		Smoothing:        0.3,
+		FailureThreshold: 3,
+		SampleTimeout:    10 * time.Second,
+	}
+}
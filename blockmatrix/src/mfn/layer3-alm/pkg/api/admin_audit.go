@@ -0,0 +1,277 @@
+// Package api implements the append-only audit log of admin API mutations
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// AuditEntry records one admin API mutation
+type AuditEntry struct {
+	Sequence      uint64
+	Time          time.Time
+	Actor         string
+	Role          string `json:",omitempty"`
+	RemoteAddress string
+	CorrelationID string `json:",omitempty"`
+
+	Method string
+	Path   string
+	Query  string      `json:",omitempty"`
+	Body   interface{} `json:",omitempty"`
+
+	// HTTP status, or the gRPC status code of a gRPC call
+	Status int
+	Error  string `json:",omitempty"`
+
+	// State the mutation touched, before and after it. Config changes
+	// record only the keys that changed.
+	Before interface{} `json:",omitempty"`
+	After  interface{} `json:",omitempty"`
+}
+
+// AuditLog is an append-only record of admin API mutations. The most recent
+// entries are kept in memory for the audit endpoint; when a file is opened
+// every entry is also appended to it as a line of JSON.
+type AuditLog struct {
+	entries  []AuditEntry
+	size     int
+	sequence uint64
+
+	file  *os.File
+	mutex sync.Mutex
+}
+
+// NewAuditLog creates an audit log keeping the last size entries in memory
+func NewAuditLog(size int) *AuditLog {
+	if size <= 0 {
+		size = 1
+	}
+	return &AuditLog{size: size}
+}
+
+// Open appends entries to the file at path from now on, after loading the
+// entries already in it so sequence numbers continue
+func (al *AuditLog) Open(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*maxConfigBody)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			file.Close()
+			return fmt.Errorf("corrupt audit log %s at entry %d: %w", path, al.sequence+1, err)
+		}
+		al.keep(entry)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	if al.file != nil {
+		al.file.Close()
+	}
+	al.file = file
+	return nil
+}
+
+// Close closes the audit log file, if any
+func (al *AuditLog) Close() error {
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	if al.file == nil {
+		return nil
+	}
+	err := al.file.Close()
+	al.file = nil
+	return err
+}
+
+// Append assigns entry the next sequence number and records it. The entry is
+// kept in memory even if writing it to the file fails.
+func (al *AuditLog) Append(entry AuditEntry) (AuditEntry, error) {
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	entry.Sequence = al.sequence + 1
+	al.keep(entry)
+
+	if al.file == nil {
+		return entry, nil
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return entry, fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	if _, err := al.file.Write(append(line, '\n')); err != nil {
+		return entry, fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	if err := al.file.Sync(); err != nil {
+		return entry, fmt.Errorf("failed to sync audit log: %w", err)
+	}
+	return entry, nil
+}
+
+// Entries returns up to limit retained entries, oldest first: those
+// following sequence since, or the most recent when since is 0
+func (al *AuditLog) Entries(since uint64, limit int) []AuditEntry {
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	start := len(al.entries)
+	for start > 0 && al.entries[start-1].Sequence > since {
+		start--
+	}
+
+	entries := al.entries[start:]
+	if limit > 0 && len(entries) > limit {
+		if since == 0 {
+			entries = entries[len(entries)-limit:]
+		} else {
+			entries = entries[:limit]
+		}
+	}
+	return append([]AuditEntry(nil), entries...)
+}
+
+// Sequence returns the sequence number of the last entry
+func (al *AuditLog) Sequence() uint64 {
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	return al.sequence
+}
+
+// keep adds entry to the retained entries. The caller holds the lock.
+func (al *AuditLog) keep(entry AuditEntry) {
+	al.sequence = entry.Sequence
+	al.entries = append(al.entries, entry)
+	if len(al.entries) > al.size {
+		al.entries = append(al.entries[:0], al.entries[len(al.entries)-al.size:]...)
+	}
+}
+
+// startAudit begins the audit entry of an admin mutation, capturing the
+// request body and the endpoint's state before it runs. The handler reads
+// the body from a copy.
+func (as *AdminServer) startAudit(r *http.Request, endpoint adminEndpoint) *AuditEntry {
+	entry := &AuditEntry{
+		Time:          time.Now(),
+		Actor:         as.actor(r),
+		RemoteAddress: r.RemoteAddr,
+		CorrelationID: logging.CorrelationID(r.Context()),
+		Method:        r.Method,
+		Path:          r.URL.Path,
+		Query:         r.URL.RawQuery,
+	}
+
+	if r.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxConfigBody))
+		r.Body.Close()
+		if err == nil && len(body) > 0 {
+			var decoded interface{}
+			if json.Unmarshal(body, &decoded) == nil {
+				entry.Body = decoded
+			} else {
+				entry.Body = string(body)
+			}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if principal, ok := PrincipalFromContext(r.Context()); ok {
+		entry.Role = principal.Role.String()
+	}
+
+	if endpoint.State != nil {
+		entry.Before = endpoint.State()
+	}
+	return entry
+}
+
+// finishAudit completes entry with the outcome of the mutation and the
+// endpoint's state after it, and appends it to the audit log
+func (as *AdminServer) finishAudit(r *http.Request, endpoint adminEndpoint, entry *AuditEntry, status int, err error) {
+	entry.Status = status
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if endpoint.State != nil {
+		entry.Before, entry.After = auditChanges(entry.Before, endpoint.State())
+	}
+
+	if _, err := as.auditLog.Append(*entry); err != nil {
+		logging.WithContext(r.Context(), as.logger).Error("Failed to record admin audit entry",
+			zap.String("path", entry.Path),
+			zap.Error(err),
+		)
+	}
+}
+
+// actor identifies who made an admin request: the authenticated principal,
+// else the common name of a verified client certificate, else the
+// ActorHeader, else "anonymous"
+func (as *AdminServer) actor(r *http.Request) string {
+	if principal, ok := PrincipalFromContext(r.Context()); ok {
+		return principal.Name
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		if name := r.TLS.VerifiedChains[0][0].Subject.CommonName; name != "" {
+			return name
+		}
+	}
+	if as.config.ActorHeader != "" {
+		if actor := r.Header.Get(as.config.ActorHeader); actor != "" {
+			return actor
+		}
+	}
+	return "anonymous"
+}
+
+// auditChanges reduces keyed state such as the configuration to the keys
+// whose values differ; other state is returned unchanged
+func auditChanges(before, after interface{}) (interface{}, interface{}) {
+	beforeValues, ok := before.(map[string]interface{})
+	if !ok {
+		return before, after
+	}
+	afterValues, ok := after.(map[string]interface{})
+	if !ok {
+		return before, after
+	}
+
+	changedBefore := make(map[string]interface{})
+	changedAfter := make(map[string]interface{})
+	for key, value := range beforeValues {
+		if next, exists := afterValues[key]; !exists || !reflect.DeepEqual(value, next) {
+			changedBefore[key] = value
+		}
+	}
+	for key, value := range afterValues {
+		if previous, exists := beforeValues[key]; !exists || !reflect.DeepEqual(previous, value) {
+			changedAfter[key] = value
+		}
+	}
+	return changedBefore, changedAfter
+}
@@ -0,0 +1,95 @@
+// Package api implements authentication and authorization of admin API requests
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// adminRealm is the realm of the bearer challenge on admin endpoints
+const adminRealm = "alm-admin"
+
+// authenticate identifies the caller of r and checks that it holds role,
+// returning r carrying the principal. Without an Authenticator every
+// request is allowed.
+func (as *AdminServer) authenticate(r *http.Request, role Role) (*http.Request, error) {
+	if as.config.Authenticator == nil {
+		return r, nil
+	}
+
+	creds, err := httpCredentials(r)
+	if err != nil {
+		return r, err
+	}
+	principal, err := as.config.Authenticator.Authenticate(r.Context(), creds)
+	if err != nil {
+		return r, err
+	}
+	r = r.WithContext(withPrincipal(r.Context(), principal))
+	return r, authorize(principal, role)
+}
+
+// requireRole serves next only to callers holding role
+func (as *AdminServer) requireRole(role Role, next http.Handler) http.Handler {
+	if as.config.Authenticator == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, err := as.authenticate(r, role)
+		if err != nil {
+			as.deny(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// deny responds to a request that failed authentication or authorization
+// and returns the status written
+func (as *AdminServer) deny(w http.ResponseWriter, r *http.Request, err error) int {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrUnauthenticated):
+		code = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", adminRealm))
+	case errors.Is(err, ErrPermissionDenied):
+		code = http.StatusForbidden
+	}
+
+	logging.WithContext(r.Context(), as.logger).Warn("Admin request denied",
+		zap.String("path", r.URL.Path),
+		zap.String("actor", as.actor(r)),
+		zap.Int("status", code),
+		zap.Error(err),
+	)
+	writeJSON(w, code, adminError{Error: err.Error()})
+	return code
+}
+
+// httpCredentials returns the TLS state and bearer token of a request. An
+// Authorization header of any other scheme is refused rather than taken as
+// the token.
+func httpCredentials(r *http.Request) (Credentials, error) {
+	token, err := bearerToken(r.Header.Get("Authorization"))
+	return Credentials{TLS: r.TLS, Token: token}, err
+}
+
+// bearerToken returns the token of an authorization value, empty when the
+// value is. It returns an error wrapping ErrUnauthenticated when the value is
+// not a bearer token.
+func bearerToken(authorization string) (string, error) {
+	if authorization == "" {
+		return "", nil
+	}
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		return "", fmt.Errorf("%w: authorization is not a bearer token", ErrUnauthenticated)
+	}
+	return token, nil
+}
@@ -0,0 +1,93 @@
+// Package api implements the runtime debug endpoints of the admin server
+package api
+
+import (
+	"crypto/subtle"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"strings"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// debugRealm is the realm of the bearer challenge on debug endpoints
+const debugRealm = "alm-debug"
+
+// debugHandler serves net/http/pprof under <PathPrefix>/debug/pprof/,
+// expvar under <PathPrefix>/debug/vars, and a text dump of every goroutine
+// and of mutex and block contention under <PathPrefix>/debug/dump, each
+// requiring DebugToken as a bearer token
+func (as *AdminServer) debugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/dump", as.dumpRuntime)
+
+	// pprof.Index resolves named profiles from paths under /debug/pprof/
+	return as.debugAuth(http.StripPrefix(as.config.PathPrefix, mux))
+}
+
+// debugAuth rejects requests without the debug token, unless they come from
+// an admin when authentication is enabled, and logs who used the debug
+// endpoints
+func (as *AdminServer) debugAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		admin, err := as.authenticate(r, RoleAdmin)
+		if as.config.Authenticator != nil && err == nil {
+			r = admin
+		} else if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); !ok || subtle.ConstantTimeCompare([]byte(token), []byte(as.config.DebugToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", debugRealm))
+			writeJSON(w, http.StatusUnauthorized, adminError{Error: "debug endpoints require a bearer token"})
+			return
+		}
+
+		logging.WithContext(r.Context(), as.logger).Info("Admin debug request",
+			zap.String("path", r.URL.Path),
+			zap.String("actor", as.actor(r)),
+		)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// dumpRuntime writes the stack of every goroutine, then the mutex and
+// block contention profiles, with the scrubbing policy's address patterns
+// redacted. Contention is only sampled while MutexProfileFraction and
+// BlockProfileRate are set.
+func (as *AdminServer) dumpRuntime(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	var dump strings.Builder
+	fmt.Fprintf(&dump, "# goroutines: %d\n\n", runtime.NumGoroutine())
+	runtimepprof.Lookup("goroutine").WriteTo(&dump, 2)
+	for _, name := range []string{"mutex", "block"} {
+		fmt.Fprintf(&dump, "\n# %s contention\n\n", name)
+		runtimepprof.Lookup(name).WriteTo(&dump, 1)
+	}
+	text := dump.String()
+	if as.coordinator != nil {
+		text = as.coordinator.Scrubber().String(text)
+	}
+	io.WriteString(w, text)
+}
+
+// enableContentionProfiling applies the configured mutex and block
+// profiling rates, which are process-wide
+func (as *AdminServer) enableContentionProfiling() {
+	if as.config.MutexProfileFraction > 0 {
+		runtime.SetMutexProfileFraction(as.config.MutexProfileFraction)
+	}
+	if as.config.BlockProfileRate > 0 {
+		runtime.SetBlockProfileRate(as.config.BlockProfileRate)
+	}
+}
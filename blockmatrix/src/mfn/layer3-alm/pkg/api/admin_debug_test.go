@@ -0,0 +1,66 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminDebugEndpointsRequireToken(t *testing.T) {
+	config := DefaultAdminServerConfig()
+	config.DebugToken = "s3cret"
+	server := httptest.NewServer(NewAdminServer(nil, config, nil).Handler())
+	defer server.Close()
+
+	get := func(path, token string) (int, string) {
+		t.Helper()
+		request, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		return response.StatusCode, string(body)
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		if status, _ := get("/admin/debug/pprof/", token); status != http.StatusUnauthorized {
+			t.Errorf("token %q: status %d, want 401", token, status)
+		}
+	}
+
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{"/admin/debug/pprof/", "goroutine"},
+		{"/admin/debug/pprof/heap?debug=1", "heap profile"},
+		{"/admin/debug/vars", "memstats"},
+		{"/admin/debug/dump", "# mutex contention"},
+	} {
+		status, body := get(tc.path, "s3cret")
+		if status != http.StatusOK || !strings.Contains(body, tc.want) {
+			t.Errorf("%s: status %d, body without %q", tc.path, status, tc.want)
+		}
+	}
+}
+
+func TestAdminDebugEndpointsOffWithoutToken(t *testing.T) {
+	server := httptest.NewServer(NewAdminServer(nil, DefaultAdminServerConfig(), nil).Handler())
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/admin/debug/vars")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("status %d without a debug token, want 404", response.StatusCode)
+	}
+}
@@ -0,0 +1,157 @@
+// Package api implements the Grafana JSON datasource endpoints of the admin server
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxGrafanaBody bounds the request body of a datasource query
+const maxGrafanaBody = 1 << 20
+
+// grafanaQuery is the body Grafana's JSON datasource posts to /query
+type grafanaQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	MaxDataPoints int `json:"maxDataPoints"`
+	Targets       []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+		Hide   bool   `json:"hide"`
+	} `json:"targets"`
+}
+
+// grafanaSeries is a time series answering one query target, with
+// datapoints as [value, Unix milliseconds] pairs
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	RefID      string       `json:"refId,omitempty"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// grafanaMetric lists a series for the /metrics endpoint
+type grafanaMetric struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// grafanaHandler serves the dashboard time series under
+// <PathPrefix>/grafana/ in the protocol of Grafana's JSON datasource: GET /
+// for the connection test, POST /search or /metrics to list the series and
+// POST /query for their points over a time range
+func (as *AdminServer) grafanaHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/grafana/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/grafana/" {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("/grafana/search", as.grafanaPost(as.grafanaSearch))
+	mux.HandleFunc("/grafana/metrics", as.grafanaPost(as.grafanaMetrics))
+	mux.HandleFunc("/grafana/query", as.grafanaPost(as.grafanaQuery))
+
+	return http.StripPrefix(as.config.PathPrefix, mux)
+}
+
+// grafanaPost answers a datasource request, which Grafana posts even though
+// none changes state, so they are not audited
+func (as *AdminServer) grafanaPost(handler adminHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSON(w, http.StatusMethodNotAllowed, adminError{Error: "method not allowed"})
+			return
+		}
+
+		body, err := handler(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, adminError{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, body)
+	}
+}
+
+// grafanaSearch lists the series containing the posted target
+func (as *AdminServer) grafanaSearch(r *http.Request) (interface{}, error) {
+	var search struct {
+		Target string `json:"target"`
+	}
+	if err := decodeGrafana(r, &search); err != nil {
+		return nil, err
+	}
+
+	series := []string{}
+	for _, name := range as.coordinator.Dashboard().Series() {
+		if strings.Contains(name, search.Target) {
+			series = append(series, name)
+		}
+	}
+	return series, nil
+}
+
+// grafanaMetrics lists the series containing the posted metric
+func (as *AdminServer) grafanaMetrics(r *http.Request) (interface{}, error) {
+	var search struct {
+		Metric string `json:"metric"`
+	}
+	if err := decodeGrafana(r, &search); err != nil {
+		return nil, err
+	}
+
+	metrics := []grafanaMetric{}
+	for _, name := range as.coordinator.Dashboard().Series() {
+		if strings.Contains(name, search.Metric) {
+			metrics = append(metrics, grafanaMetric{Label: name, Value: name})
+		}
+	}
+	return metrics, nil
+}
+
+// grafanaQuery returns the points of each visible target over the range,
+// consolidated to at most maxDataPoints
+func (as *AdminServer) grafanaQuery(r *http.Request) (interface{}, error) {
+	var query grafanaQuery
+	if err := decodeGrafana(r, &query); err != nil {
+		return nil, err
+	}
+	if query.Range.To.Before(query.Range.From) {
+		return nil, badRequest("query range ends before it starts")
+	}
+
+	dashboard := as.coordinator.Dashboard()
+	series := []grafanaSeries{}
+	for _, target := range query.Targets {
+		if target.Hide || target.Target == "" {
+			continue
+		}
+
+		points, err := dashboard.Query(target.Target, query.Range.From, query.Range.To, query.MaxDataPoints)
+		if err != nil {
+			return nil, badRequest("%v", err)
+		}
+		datapoints := make([][2]float64, len(points))
+		for i, point := range points {
+			datapoints[i] = [2]float64{point.Value, float64(point.Time.UnixMilli())}
+		}
+		series = append(series, grafanaSeries{Target: target.Target, RefID: target.RefID, Datapoints: datapoints})
+	}
+	return series, nil
+}
+
+// decodeGrafana decodes a datasource request body into v; an empty body
+// leaves v unchanged
+func decodeGrafana(r *http.Request, v interface{}) error {
+	err := json.NewDecoder(io.LimitReader(r.Body, maxGrafanaBody)).Decode(v)
+	if err != nil && err != io.EOF {
+		return badRequest("invalid datasource request: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+)
+
+func TestAdminGrafanaDatasource(t *testing.T) {
+	config := internal.DefaultALMConfig()
+	config.MaxNodes = 1000
+	config.MaxEdges = 10000
+	coordinator, err := internal.NewALMCoordinator(config, nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+
+	start := time.UnixMilli(1700000000000)
+	for i := 0; i < 4; i++ {
+		sample := internal.DashboardSample{
+			Time:              start.Add(time.Duration(i) * 10 * time.Second),
+			ImprovementFactor: 8 + float64(i),
+			DiscoveryLatency:  time.Duration(i) * time.Millisecond,
+		}
+		coordinator.Dashboard().Record(sample)
+	}
+
+	server := httptest.NewServer(NewAdminServer(coordinator, DefaultAdminServerConfig(), nil).Handler())
+	defer server.Close()
+
+	post := func(path, body string, v interface{}) int {
+		t.Helper()
+		response, err := http.Post(server.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer response.Body.Close()
+		if v != nil && response.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+				t.Fatalf("POST %s: %v", path, err)
+			}
+		}
+		return response.StatusCode
+	}
+
+	response, err := http.Get(server.URL + "/admin/grafana/")
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("connection test: %v, %v", response, err)
+	}
+	response.Body.Close()
+
+	var series []string
+	if status := post("/admin/grafana/search", `{"target":"p99"}`, &series); status != http.StatusOK || len(series) != 5 || series[4] != "p99_latency_ms.critical_mission" {
+		t.Errorf("search: status %d, series %v", status, series)
+	}
+	var metrics []grafanaMetric
+	if status := post("/admin/grafana/metrics", `{}`, &metrics); status != http.StatusOK || len(metrics) != 8 {
+		t.Errorf("metrics: status %d, metrics %v", status, metrics)
+	}
+
+	var result []grafanaSeries
+	query := `{
+		"range": {"from": "2023-11-14T22:13:20Z", "to": "2023-11-14T22:13:50Z"},
+		"maxDataPoints": 2,
+		"targets": [
+			{"target": "improvement_factor", "refId": "A"},
+			{"target": "discovery_latency_ms", "refId": "B"},
+			{"target": "cache_hit_rate", "refId": "C", "hide": true}
+		]
+	}`
+	if status := post("/admin/grafana/query", query, &result); status != http.StatusOK {
+		t.Fatalf("query: status %d", status)
+	}
+	if len(result) != 2 || result[0].RefID != "A" || result[1].Target != "discovery_latency_ms" {
+		t.Fatalf("query returned %+v", result)
+	}
+	want := [][2]float64{{8.5, float64(start.Add(10 * time.Second).UnixMilli())}, {10.5, float64(start.Add(30 * time.Second).UnixMilli())}}
+	if got := result[0].Datapoints; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("improvement factor datapoints %v, want %v", got, want)
+	}
+	if got := result[1].Datapoints; len(got) != 2 || got[1][0] != 2.5 {
+		t.Errorf("discovery latency datapoints %v, want a 2.5ms mean last", got)
+	}
+
+	if status := post("/admin/grafana/query", `{"targets":[{"target":"qps"}]}`, nil); status != http.StatusBadRequest {
+		t.Errorf("unknown target: status %d, want 400", status)
+	}
+	if response, _ := http.Get(server.URL + "/admin/grafana/query"); response.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET query: status %d, want 405", response.StatusCode)
+	}
+}
@@ -0,0 +1,1535 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/associative"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/logging"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/service"
+	"go.uber.org/zap"
+)
+
+// AdminServer serves an HTTP/JSON admin API for operators and tooling:
+// routing table dumps, cache statistics, association analytics, topology
+// views, runtime configuration changes and reload, fault injection,
+// per-tenant and per-service quotas, and a state export for support. The
+// API is described by an OpenAPI document served at
+// <PathPrefix>/openapi.json.
+//
+// The coordinator's scrubbing policy is applied to the topology view, the
+// state export and the debug dump, redacting configured metadata keys and
+// address patterns.
+//
+// Every mutation is recorded in an audit log with the operator, the request
+// and the state it changed, retrievable at <PathPrefix>/audit.
+//
+// With an Authenticator configured, every request must identify its caller,
+// by client certificate or bearer token, and the caller must hold the role
+// the endpoint requires: read-only callers may read state, operators may
+// also inject faults and record requests, and admins may also change
+// configuration, feature flags and quotas. Denied mutations are audited too.
+//
+// When the coordinator has trust anchors configured, config and feature
+// flag changes must instead be posted to <PathPrefix>/policy/apply as
+// policies signed by one of them; the unsigned endpoints refuse them.
+//
+// When a DebugToken is configured, pprof, expvar and a goroutine and lock
+// contention dump are served under <PathPrefix>/debug/ to requests bearing
+// it, so latency can be investigated in production without a rebuild.
+//
+// Recent history of the improvement factor, cache hit rate, discovery
+// latency and P99 lookup latency by QoS class is served under
+// <PathPrefix>/grafana/ for Grafana's JSON datasource, so teams without
+// Prometheus can chart them.
+//
+// The server is disabled unless AdminServerConfig.Enabled is set.
+type AdminServer struct {
+	coordinator *internal.ALMCoordinator
+	config      *AdminServerConfig
+	endpoints   []adminEndpoint
+	handlers    map[string]adminHandler
+
+	// Called by the config reload endpoint
+	reload func(ctx context.Context) error
+
+	// Record of mutations made through the API
+	auditLog *AuditLog
+
+	server   *http.Server
+	listener net.Listener
+
+	logger *zap.Logger
+	mutex  sync.Mutex
+}
+
+// maxConfigBody bounds the request body of a config change
+const maxConfigBody = 1 << 20
+
+// AdminServerConfig configures the admin API
+type AdminServerConfig struct {
+	Enabled bool
+
+	// Address the API is served on, and the prefix of every path
+	ListenAddress string
+	PathPrefix    string
+
+	// TLSConfig serves the API over TLS when set. Verify client
+	// certificates in it to authenticate callers by mutual TLS.
+	TLSConfig *tls.Config
+
+	// Authenticator identifies callers, who are then authorized by role.
+	// Requests it does not recognize and endpoints that declare no role are
+	// denied. Without one the API is open to anyone who can reach it.
+	Authenticator Authenticator
+
+	// Default and upper bound of entries in route and topology dumps
+	DefaultLimit int
+	MaxLimit     int
+
+	// Bound on a single request
+	RequestTimeout time.Duration
+
+	// Audit log of mutations: entries kept in memory for the audit endpoint,
+	// and a file every entry is appended to when set
+	AuditLogSize int
+	AuditLogPath string
+
+	// Request header naming the operator behind a mutation when no verified
+	// client certificate identifies them
+	ActorHeader string
+
+	// DebugToken is the bearer token required by the debug endpoints; they
+	// are not served when it is empty
+	DebugToken string
+
+	// Sampling of lock contention for the debug dump, applied process-wide
+	// when the server starts with debug endpoints: on average one in
+	// MutexProfileFraction contended mutexes is recorded, and one blocking
+	// event per BlockProfileRate nanoseconds blocked. Zero leaves the
+	// runtime's rates unchanged.
+	MutexProfileFraction int
+	BlockProfileRate     int
+}
+
+// adminHandler serves one endpoint and returns the response body
+type adminHandler func(r *http.Request) (interface{}, error)
+
+// adminStatusError is returned by handlers to choose the HTTP status
+type adminStatusError struct {
+	status  int
+	message string
+}
+
+func (e *adminStatusError) Error() string {
+	return e.message
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &adminStatusError{status: http.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+// Response bodies
+
+type adminError struct {
+	Error string
+}
+
+type routeView struct {
+	Destination  int64
+	NextHop      int64
+	Path         []int64
+	Metrics      routing.RouteMetrics
+	QualityScore float64
+	Confidence   float64
+	CreatedAt    time.Time
+	LastUsed     time.Time
+	UseCount     int64
+}
+
+type routesView struct {
+	CachedRoutes int
+	Routes       []routeView
+}
+
+type cacheStatsView struct {
+	Routing      routing.RoutingStats
+	RouteCache   routing.RouteCacheStatistics
+	PathCache    graph.CacheStatistics
+	LoadBalancer routing.LoadBalancerStatistics
+	Discovery    service.DiscoveryStats
+}
+
+type associationsView struct {
+	Stats        associative.AssociationMatrixStats
+	NodeID       int64
+	Associations []associative.Association
+}
+
+type nodeView struct {
+	ID           int64
+	Address      string
+	Region       string
+	Provider     string
+	Latitude     float64
+	Longitude    float64
+	Latency      time.Duration
+	Throughput   float64
+	Reliability  float64
+	LoadFactor   float64
+	LastSeen     time.Time
+	Capabilities []string
+	Services     []string
+}
+
+type edgeView struct {
+	From        int64
+	To          int64
+	Weight      float64
+	Latency     time.Duration
+	Bandwidth   float64
+	PacketLoss  float64
+	Jitter      time.Duration
+	Cost        float64
+	Reliability float64
+	Stability   float64
+	LastUpdate  time.Time
+}
+
+type topologyView struct {
+	Stats     graph.TopologyStats
+	Truncated bool
+	Nodes     []nodeView
+	Edges     []edgeView
+}
+
+type reloadView struct {
+	Reloaded   bool
+	ReloadedAt time.Time
+}
+
+type configView struct {
+	Config map[string]interface{}
+}
+
+type configApplyView struct {
+	Changed   []string
+	AppliedAt time.Time
+	Config    map[string]interface{}
+}
+
+type constraintTemplatesView struct {
+	Templates map[string]routing.RouteConstraints
+}
+
+type faultsView struct {
+	Enabled bool
+	Faults  []internal.Fault
+}
+
+type faultRemoveView struct {
+	Removed int
+}
+
+type featureFlagsView struct {
+	Flags []internal.FeatureFlag
+	Stats map[string]internal.FeatureFlagStats
+}
+
+type featureFlagRemoveView struct {
+	Removed bool
+}
+
+type policyApplyView struct {
+	Kind    string
+	KeyID   string
+	Applied int
+}
+
+type quotasView struct {
+	Quotas []internal.QuotaStats
+}
+
+type quotaRemoveView struct {
+	Removed bool
+}
+
+type reservationsView struct {
+	Reservations []routing.Reservation
+}
+
+// reservationRequest reserves Mbps on every edge of Path for TTL
+type reservationRequest struct {
+	Path []int64       `json:"path"`
+	Mbps float64       `json:"mbps"`
+	TTL  time.Duration `json:"ttl_ns"`
+}
+
+type reservationReleaseView struct {
+	Released bool
+}
+
+type drPlansView struct {
+	Plans []routing.DRPlanStatus
+}
+
+type sloView struct {
+	SLOs []routing.SLOStatus
+}
+
+type maintenanceView struct {
+	Nodes []internal.NodeMaintenanceStatus
+}
+
+type maintenanceCancelView struct {
+	Cancelled bool
+}
+
+type auditView struct {
+	Sequence uint64
+	Entries  []AuditEntry
+}
+
+// NewAdminServer creates an admin API server for coordinator
+func NewAdminServer(coordinator *internal.ALMCoordinator, config *AdminServerConfig, logger *zap.Logger) *AdminServer {
+	if config == nil {
+		config = DefaultAdminServerConfig()
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	as := &AdminServer{
+		coordinator: coordinator,
+		config:      config,
+		handlers:    make(map[string]adminHandler),
+		auditLog:    NewAuditLog(config.AuditLogSize),
+		logger:      logger,
+	}
+
+	limit := adminParameter{Name: "limit", Description: "Maximum number of entries returned", Type: "integer"}
+
+	as.handle(adminEndpoint{
+		Method:     http.MethodGet,
+		Path:       "/routes",
+		Summary:    "Dump cached routes, most used first",
+		Parameters: []adminParameter{limit},
+		Response:   routesView{},
+		Role:       RoleReadOnly,
+	}, as.routes)
+	as.handle(adminEndpoint{
+		Method:   http.MethodGet,
+		Path:     "/cache/stats",
+		Summary:  "Routing, route cache, path cache, load balancer and discovery statistics",
+		Response: cacheStatsView{},
+		Role:     RoleReadOnly,
+	}, as.cacheStats)
+	as.handle(adminEndpoint{
+		Method:  http.MethodGet,
+		Path:    "/associations",
+		Summary: "Learned service affinity statistics and the strongest associations of a node",
+		Parameters: []adminParameter{
+			{Name: "node", Description: "Node whose associations are listed", Type: "integer"},
+			limit,
+		},
+		Response: associationsView{},
+		Role:     RoleReadOnly,
+	}, as.associations)
+	as.handle(adminEndpoint{
+		Method:  http.MethodGet,
+		Path:    "/topology",
+		Summary: "Network topology view",
+		Parameters: []adminParameter{
+			{Name: "region", Description: "Only include nodes in this region", Type: "string"},
+			limit,
+		},
+		Response: topologyView{},
+		Role:     RoleReadOnly,
+	}, as.topology)
+	as.handle(adminEndpoint{
+		Method:   http.MethodGet,
+		Path:     "/constraints",
+		Summary:  "Route constraint templates requests reference by name; changed through the constraint_templates config key",
+		Response: constraintTemplatesView{},
+		Role:     RoleReadOnly,
+	}, as.constraintTemplates)
+	as.handle(adminEndpoint{
+		Method:   http.MethodGet,
+		Path:     "/schedule",
+		Summary:  "Scheduled routing preferences and the objective weights in effect; changed through the routing_schedule config key",
+		Response: internal.RoutingScheduleStatus{},
+		Role:     RoleReadOnly,
+	}, as.routingSchedule)
+	as.handle(adminEndpoint{
+		Method:  http.MethodPost,
+		Path:    "/schedule/override",
+		Summary: "Put a scheduled routing preference, or default for the configured weights, in effect for a while whatever the schedule says",
+		Parameters: []adminParameter{
+			{Name: "preference", Description: "Scheduled preference name, or default", Type: "string"},
+			{Name: "duration", Description: "How long the override lasts, such as 2h", Type: "string"},
+		},
+		Response: internal.RoutingScheduleStatus{},
+		State:    as.scheduleState,
+		Role:     RoleOperator,
+	}, as.overrideRoutingPreference)
+	as.handle(adminEndpoint{
+		Method:   http.MethodPost,
+		Path:     "/schedule/override/clear",
+		Summary:  "End a routing preference override and return to the schedule",
+		Response: internal.RoutingScheduleStatus{},
+		State:    as.scheduleState,
+		Role:     RoleOperator,
+	}, as.clearRoutingOverride)
+	as.handle(adminEndpoint{
+		Method:   http.MethodGet,
+		Path:     "/state/export",
+		Summary:  "Nodes, edges, cached routes, services and quotas with the scrubbing policy applied, for sharing with support",
+		Response: internal.StateExport{},
+		Role:     RoleOperator,
+	}, as.exportState)
+	as.handle(adminEndpoint{
+		Method:   http.MethodGet,
+		Path:     "/config",
+		Summary:  "Current configuration, keyed like the config file",
+		Response: configView{},
+		Role:     RoleReadOnly,
+	}, as.currentConfig)
+	as.handle(adminEndpoint{
+		Method:   http.MethodPost,
+		Path:     "/config/apply",
+		Summary:  "Change settings at runtime from a JSON object of config keys; rejected as a whole and rolled back on failure",
+		Response: configApplyView{},
+		State:    as.configState,
+		Role:     RoleAdmin,
+	}, as.applyConfig)
+	as.handle(adminEndpoint{
+		Method:   http.MethodGet,
+		Path:     "/faults",
+		Summary:  "Injected faults and how often each has triggered",
+		Response: faultsView{},
+		Role:     RoleReadOnly,
+	}, as.faults)
+	as.handle(adminEndpoint{
+		Method:   http.MethodPost,
+		Path:     "/faults/inject",
+		Summary:  "Inject a fault from a JSON Fault, with durations in nanoseconds; requires fault_injection",
+		Response: internal.Fault{},
+		State:    as.faultState,
+		Role:     RoleOperator,
+	}, as.injectFault)
+	as.handle(adminEndpoint{
+		Method:  http.MethodPost,
+		Path:    "/faults/remove",
+		Summary: "Remove one fault, or every fault when no id is given",
+		Parameters: []adminParameter{
+			{Name: "id", Description: "Fault to remove", Type: "string"},
+		},
+		Response: faultRemoveView{},
+		State:    as.faultState,
+		Role:     RoleOperator,
+	}, as.removeFault)
+	as.handle(adminEndpoint{
+		Method:   http.MethodGet,
+		Path:     "/recording",
+		Summary:  "State of the running or last request recording",
+		Response: internal.RecordingStatus{},
+		Role:     RoleReadOnly,
+	}, as.recording)
+	as.handle(adminEndpoint{
+		Method:   http.MethodPost,
+		Path:     "/recording/start",
+		Summary:  "Record a sample of anonymized route requests for benchmark replay, from a JSON RecordingConfig with durations in nanoseconds",
+		Response: internal.RecordingStatus{},
+		State:    as.recordingState,
+		Role:     RoleOperator,
+	}, as.startRecording)
+	as.handle(adminEndpoint{
+		Method:   http.MethodPost,
+		Path:     "/recording/stop",
+		Summary:  "Stop the running request recording",
+		Response: internal.RecordingStatus{},
+		State:    as.recordingState,
+		Role:     RoleOperator,
+	}, as.stopRecording)
+	as.handle(adminEndpoint{
+		Method:   http.MethodGet,
+		Path:     "/flags",
+		Summary:  "Feature flags and how often each has been evaluated and enabled",
+		Response: featureFlagsView{},
+		Role:     RoleReadOnly,
+	}, as.featureFlags)
+	as.handle(adminEndpoint{
+		Method:   http.MethodPost,
+		Path:     "/flags/set",
+		Summary:  "Add or replace a feature flag from a JSON FeatureFlag",
+		Response: internal.FeatureFlag{},
+		State:    as.configState,
+		Role:     RoleAdmin,
+	}, as.setFeatureFlag)
+	as.handle(adminEndpoint{
+		Method:  http.MethodPost,
+		Path:    "/flags/remove",
+		Summary: "Remove a feature flag; the feature reverts to its default",
+		Parameters: []adminParameter{
+			{Name: "name", Description: "Flag to remove", Type: "string"},
+		},
+		Response: featureFlagRemoveView{},
+		State:    as.configState,
+		Role:     RoleAdmin,
+	}, as.removeFeatureFlag)
+	as.handle(adminEndpoint{
+		Method:   http.MethodPost,
+		Path:     "/policy/apply",
+		Summary:  "Apply a config change, feature flag or topology import from a JSON SignedPolicy, verified against the configured trust anchors",
+		Response: policyApplyView{},
+		State:    as.configState,
+		Role:     RoleAdmin,
+	}, as.applyPolicy)
+	as.handle(adminEndpoint{
+		Method:   http.MethodGet,
+		Path:     "/quotas",
+		Summary:  "Tenant and service quotas on route lookups and discovery, and their usage",
+		Response: quotasView{},
+		Role:     RoleReadOnly,
+	}, as.quotas)
+	as.handle(adminEndpoint{
+		Method:   http.MethodPost,
+		Path:     "/quotas/set",
+		Summary:  "Add or replace a tenant or service quota from a JSON QuotaEntry; persisted with the coordinator state",
+		Response: internal.QuotaEntry{},
+		State:    as.quotaState,
+		Role:     RoleAdmin,
+	}, as.setQuota)
+	as.handle(adminEndpoint{
+		Method:  http.MethodPost,
+		Path:    "/quotas/remove",
+		Summary: "Remove a tenant or service quota, lifting its limits",
+		Parameters: []adminParameter{
+			{Name: "scope", Description: "tenant or service", Type: "string"},
+			{Name: "key", Description: "Tenant or service the quota applies to", Type: "string"},
+		},
+		Response: quotaRemoveView{},
+		State:    as.quotaState,
+		Role:     RoleAdmin,
+	}, as.removeQuota)
+	as.handle(adminEndpoint{
+		Method:   http.MethodGet,
+		Path:     "/reservations",
+		Summary:  "Bandwidth reserved on paths, which route lookups must leave room beside for their min_throughput",
+		Response: reservationsView{},
+		Role:     RoleReadOnly,
+	}, as.reservations)
+	as.handle(adminEndpoint{
+		Method:   http.MethodPost,
+		Path:     "/reservations/reserve",
+		Summary:  "Reserve bandwidth on every edge of a path from a JSON object with path, mbps and ttl_ns; 409 if an edge lacks the bandwidth",
+		Response: routing.Reservation{},
+		State:    as.reservationState,
+		Role:     RoleOperator,
+	}, as.reserveBandwidth)
+	as.handle(adminEndpoint{
+		Method:  http.MethodPost,
+		Path:    "/reservations/renew",
+		Summary: "Extend a bandwidth reservation",
+		Parameters: []adminParameter{
+			{Name: "id", Description: "Reservation to renew", Type: "string"},
+			{Name: "ttl", Description: "How long from now it lasts, such as 10m", Type: "string"},
+		},
+		Response: routing.Reservation{},
+		State:    as.reservationState,
+		Role:     RoleOperator,
+	}, as.renewReservation)
+	as.handle(adminEndpoint{
+		Method:  http.MethodPost,
+		Path:    "/reservations/release",
+		Summary: "Release a bandwidth reservation before it expires",
+		Parameters: []adminParameter{
+			{Name: "id", Description: "Reservation to release", Type: "string"},
+		},
+		Response: reservationReleaseView{},
+		State:    as.reservationState,
+		Role:     RoleOperator,
+	}, as.releaseReservation)
+	as.handle(adminEndpoint{
+		Method:   http.MethodGet,
+		Path:     "/dr",
+		Summary:  "Disaster recovery plans, the failed regions whose plans are active and the flows each failover region has taken over; changed through the dr_plans config key",
+		Response: drPlansView{},
+		Role:     RoleReadOnly,
+	}, as.drPlans)
+	as.handle(adminEndpoint{
+		Method:  http.MethodPost,
+		Path:    "/dr/fail",
+		Summary: "Mark a region failed, redirecting routes to its destinations to the failover regions of its plan; 409 if it has already failed",
+		Parameters: []adminParameter{
+			{Name: "region", Description: "Region with a DR plan", Type: "string"},
+		},
+		Response: drPlansView{},
+		State:    as.drState,
+		Role:     RoleOperator,
+	}, as.failRegion)
+	as.handle(adminEndpoint{
+		Method:  http.MethodPost,
+		Path:    "/dr/recover",
+		Summary: "Mark a failed region recovered, routing to its destinations again",
+		Parameters: []adminParameter{
+			{Name: "region", Description: "Failed region", Type: "string"},
+		},
+		Response: drPlansView{},
+		State:    as.drState,
+		Role:     RoleOperator,
+	}, as.recoverRegion)
+	as.handle(adminEndpoint{
+		Method:   http.MethodGet,
+		Path:     "/slo",
+		Summary:  "Latency SLOs per QoS class with the latency at their quantile, error budget left, burn rates and the burn-rate alert firing; changed through the latency_slos config key",
+		Response: sloView{},
+		Role:     RoleReadOnly,
+	}, as.latencySLOs)
+	as.handle(adminEndpoint{
+		Method:   http.MethodGet,
+		Path:     "/maintenance",
+		Summary:  "Nodes draining for maintenance with the traffic left on each and whether it is safe to reboot",
+		Response: maintenanceView{},
+		Role:     RoleReadOnly,
+	}, as.nodeMaintenance)
+	as.handle(adminEndpoint{
+		Method:  http.MethodPost,
+		Path:    "/maintenance/start",
+		Summary: "Drain a node from new routes and remove it from the topology once the drain ends; 409 if it is already draining",
+		Parameters: []adminParameter{
+			{Name: "node", Description: "Node to drain", Type: "integer"},
+			{Name: "drain", Description: "How long until the node is removed, such as 15m", Type: "string"},
+		},
+		Response: maintenanceView{},
+		State:    as.maintenanceState,
+		Role:     RoleOperator,
+	}, as.startNodeMaintenance)
+	as.handle(adminEndpoint{
+		Method:  http.MethodPost,
+		Path:    "/maintenance/cancel",
+		Summary: "Stop draining a node, or forget one already removed",
+		Parameters: []adminParameter{
+			{Name: "node", Description: "Node in maintenance", Type: "integer"},
+		},
+		Response: maintenanceCancelView{},
+		State:    as.maintenanceState,
+		Role:     RoleOperator,
+	}, as.cancelNodeMaintenance)
+	as.handle(adminEndpoint{
+		Method:   http.MethodPost,
+		Path:     "/config/reload",
+		Summary:  "Reload configuration",
+		Response: reloadView{},
+		State:    as.configState,
+		Role:     RoleAdmin,
+	}, as.reloadConfig)
+	as.handle(adminEndpoint{
+		Method:  http.MethodGet,
+		Path:    "/audit",
+		Summary: "Audit log of mutations made through the admin API, oldest first",
+		Parameters: []adminParameter{
+			{Name: "since", Description: "Only entries after this sequence number; the most recent entries when omitted", Type: "integer"},
+			limit,
+		},
+		Response: auditView{},
+		Role:     RoleOperator,
+	}, as.audit)
+
+	return as
+}
+
+// SetReloadHandler sets the function called by the config reload endpoint.
+// Without one the endpoint responds 501 Not Implemented.
+func (as *AdminServer) SetReloadHandler(reload func(ctx context.Context) error) {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	as.reload = reload
+}
+
+// Handler returns the admin API handler, including the OpenAPI document
+func (as *AdminServer) Handler() http.Handler {
+	document, err := json.MarshalIndent(as.OpenAPI(), "", "  ")
+	if err != nil {
+		as.logger.Error("Failed to encode OpenAPI document", zap.Error(err))
+	}
+
+	mux := http.NewServeMux()
+	for _, endpoint := range as.endpoints {
+		endpoint := endpoint
+		handler := as.handlers[endpoint.Path]
+		mux.HandleFunc(as.config.PathPrefix+endpoint.Path, func(w http.ResponseWriter, r *http.Request) {
+			as.serve(w, r, endpoint, handler)
+		})
+	}
+	mux.Handle(as.config.PathPrefix+"/openapi.json", as.requireRole(RoleReadOnly, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(document)
+	})))
+	mux.Handle(as.config.PathPrefix+"/grafana/", as.requireRole(RoleReadOnly, as.grafanaHandler()))
+	if as.config.DebugToken != "" {
+		mux.Handle(as.config.PathPrefix+"/debug/", as.debugHandler())
+	}
+
+	return mux
+}
+
+// OpenAPI returns the OpenAPI 3.0 document describing the admin API
+func (as *AdminServer) OpenAPI() map[string]interface{} {
+	endpoints := make([]adminEndpoint, len(as.endpoints))
+	for i, endpoint := range as.endpoints {
+		endpoint.Path = as.config.PathPrefix + endpoint.Path
+		endpoints[i] = endpoint
+	}
+	return openAPIDocument("HyperMesh Layer 3 ALM admin API", "1.0.0", endpoints)
+}
+
+// Start serves the admin API in the background. It does nothing when the
+// API is disabled.
+func (as *AdminServer) Start() error {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+
+	if !as.config.Enabled {
+		return nil
+	}
+
+	if as.server != nil {
+		return fmt.Errorf("admin server is already running")
+	}
+
+	if as.config.AuditLogPath != "" {
+		if err := as.auditLog.Open(as.config.AuditLogPath); err != nil {
+			return err
+		}
+	}
+
+	if as.config.DebugToken != "" {
+		as.enableContentionProfiling()
+	}
+
+	listener, err := net.Listen("tcp", as.config.ListenAddress)
+	if err != nil {
+		as.auditLog.Close()
+		return fmt.Errorf("failed to listen on %s: %w", as.config.ListenAddress, err)
+	}
+	if as.config.TLSConfig != nil {
+		listener = tls.NewListener(listener, as.config.TLSConfig)
+	}
+
+	as.listener = listener
+	as.server = &http.Server{
+		Handler:           as.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func(server *http.Server) {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			as.logger.Error("Admin server stopped", zap.Error(err))
+		}
+	}(as.server)
+
+	as.logger.Info("Admin server started",
+		zap.String("address", listener.Addr().String()),
+		zap.String("prefix", as.config.PathPrefix),
+	)
+
+	return nil
+}
+
+// AuditLog returns the log of mutations made through the API
+func (as *AdminServer) AuditLog() *AuditLog {
+	return as.auditLog
+}
+
+// Address returns the address the API is listening on, or "" when stopped
+func (as *AdminServer) Address() string {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+
+	if as.listener == nil {
+		return ""
+	}
+	return as.listener.Addr().String()
+}
+
+// Shutdown stops the admin API
+func (as *AdminServer) Shutdown(ctx context.Context) error {
+	as.mutex.Lock()
+	server := as.server
+	as.server = nil
+	as.listener = nil
+	as.mutex.Unlock()
+
+	if server == nil {
+		return nil
+	}
+	err := server.Shutdown(ctx)
+	if closeErr := as.auditLog.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("failed to close audit log: %w", closeErr)
+	}
+	return err
+}
+
+func (as *AdminServer) handle(endpoint adminEndpoint, handler adminHandler) {
+	as.endpoints = append(as.endpoints, endpoint)
+	as.handlers[endpoint.Path] = handler
+}
+
+// serve runs handler and writes its result or error as JSON
+func (as *AdminServer) serve(w http.ResponseWriter, r *http.Request, endpoint adminEndpoint, handler adminHandler) {
+	if r.Method != endpoint.Method {
+		w.Header().Set("Allow", endpoint.Method)
+		writeJSON(w, http.StatusMethodNotAllowed, adminError{Error: "method not allowed"})
+		return
+	}
+
+	ctx, correlationID := logging.EnsureCorrelationID(logging.WithCorrelationID(r.Context(), r.Header.Get(logging.CorrelationHeader)))
+	w.Header().Set(logging.CorrelationHeader, correlationID)
+	r = r.WithContext(ctx)
+
+	if as.config.RequestTimeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), as.config.RequestTimeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+
+	r, authErr := as.authenticate(r, endpoint.Role)
+
+	// Every mutation is recorded in the audit log, whether or not it succeeds
+	// or is allowed
+	var entry *AuditEntry
+	if endpoint.Method != http.MethodGet {
+		entry = as.startAudit(r, endpoint)
+	}
+
+	if authErr != nil {
+		code := as.deny(w, r, authErr)
+		if entry != nil {
+			as.finishAudit(r, endpoint, entry, code, authErr)
+		}
+		return
+	}
+
+	body, err := handler(r)
+	if err != nil {
+		code := http.StatusInternalServerError
+		var statusErr *adminStatusError
+		if errors.As(err, &statusErr) {
+			code = statusErr.status
+		}
+		if code >= http.StatusInternalServerError {
+			logging.WithContext(r.Context(), as.logger).Error("Admin request failed", zap.String("path", r.URL.Path), zap.Error(err))
+		}
+		if entry != nil {
+			as.finishAudit(r, endpoint, entry, code, err)
+		}
+		writeJSON(w, code, adminError{Error: err.Error()})
+		return
+	}
+
+	if entry != nil {
+		as.finishAudit(r, endpoint, entry, http.StatusOK, nil)
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (as *AdminServer) routes(r *http.Request) (interface{}, error) {
+	limit, err := as.limit(r)
+	if err != nil {
+		return nil, err
+	}
+
+	routingTable := as.coordinator.RoutingTable()
+	entries := routingTable.GetCachedRoutes(limit)
+
+	view := routesView{
+		CachedRoutes: routingTable.GetRouteCacheStats().Size,
+		Routes:       make([]routeView, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		path := make([]int64, len(entry.Path))
+		for i, node := range entry.Path {
+			path[i] = node.ID
+		}
+		view.Routes = append(view.Routes, routeView{
+			Destination:  entry.Destination,
+			NextHop:      entry.NextHop,
+			Path:         path,
+			Metrics:      entry.Metrics,
+			QualityScore: entry.QualityScore,
+			Confidence:   entry.Confidence,
+			CreatedAt:    entry.CreatedAt,
+			LastUsed:     entry.LastUsed,
+			UseCount:     entry.UseCount,
+		})
+	}
+	return view, nil
+}
+
+func (as *AdminServer) cacheStats(r *http.Request) (interface{}, error) {
+	routingTable := as.coordinator.RoutingTable()
+	return cacheStatsView{
+		Routing:      routingTable.GetRoutingStats(),
+		RouteCache:   routingTable.GetRouteCacheStats(),
+		PathCache:    as.coordinator.NetworkGraph().GetPathCacheStats(),
+		LoadBalancer: routingTable.GetLoadBalancerStats(),
+		Discovery:    as.coordinator.ServiceRegistry().GetDiscoveryStats(),
+	}, nil
+}
+
+func (as *AdminServer) associations(r *http.Request) (interface{}, error) {
+	registry := as.coordinator.ServiceRegistry()
+	view := associationsView{Stats: registry.GetAffinityStats()}
+
+	node := r.URL.Query().Get("node")
+	if node == "" {
+		return view, nil
+	}
+
+	nodeID, err := strconv.ParseInt(node, 10, 64)
+	if err != nil {
+		return nil, badRequest("invalid node %q", node)
+	}
+	limit, err := as.limit(r)
+	if err != nil {
+		return nil, err
+	}
+
+	view.NodeID = nodeID
+	view.Associations = registry.GetNodeAffinities(nodeID, limit)
+	return view, nil
+}
+
+func (as *AdminServer) topology(r *http.Request) (interface{}, error) {
+	limit, err := as.limit(r)
+	if err != nil {
+		return nil, err
+	}
+	region := r.URL.Query().Get("region")
+
+	networkGraph := as.coordinator.NetworkGraph()
+	scrubber := as.coordinator.Scrubber()
+	view := topologyView{Stats: networkGraph.GetTopologyStats()}
+
+	included := make(map[int64]bool)
+	for _, node := range networkGraph.Nodes() {
+		if region != "" && !strings.EqualFold(node.Region, region) {
+			continue
+		}
+		if len(view.Nodes) >= limit {
+			view.Truncated = true
+			break
+		}
+
+		services := make([]string, 0, len(node.Services))
+		for name := range node.Services {
+			services = append(services, name)
+		}
+		sort.Strings(services)
+
+		included[node.ID] = true
+		view.Nodes = append(view.Nodes, nodeView{
+			ID:           node.ID,
+			Address:      scrubber.Field("address", node.Address),
+			Region:       scrubber.Field("region", node.Region),
+			Provider:     node.Provider,
+			Latitude:     node.Latitude,
+			Longitude:    node.Longitude,
+			Latency:      node.Latency,
+			Throughput:   node.Throughput,
+			Reliability:  node.Reliability,
+			LoadFactor:   node.LoadFactor,
+			LastSeen:     node.LastSeen,
+			Capabilities: scrubber.Strings(node.Capabilities),
+			Services:     services,
+		})
+	}
+
+	// Only edges between listed nodes, so the view is a consistent subgraph
+	for _, edge := range networkGraph.Edges() {
+		if !included[edge.From] || !included[edge.To] {
+			continue
+		}
+		view.Edges = append(view.Edges, edgeView{
+			From:        edge.From,
+			To:          edge.To,
+			Weight:      edge.Weight,
+			Latency:     edge.Latency,
+			Bandwidth:   edge.Bandwidth,
+			PacketLoss:  edge.PacketLoss,
+			Jitter:      edge.Jitter,
+			Cost:        edge.Cost,
+			Reliability: edge.Reliability,
+			Stability:   edge.Stability,
+			LastUpdate:  edge.LastUpdate,
+		})
+	}
+
+	return view, nil
+}
+
+func (as *AdminServer) constraintTemplates(r *http.Request) (interface{}, error) {
+	return constraintTemplatesView{Templates: as.coordinator.RoutingTable().ConstraintTemplates().All()}, nil
+}
+
+// scheduleState is the audited state of routing preference overrides
+func (as *AdminServer) scheduleState() interface{} {
+	status := as.coordinator.RoutingSchedule()
+	return struct {
+		Active        string
+		Override      string
+		OverrideUntil time.Time
+	}{status.Active, status.Override, status.OverrideUntil}
+}
+
+func (as *AdminServer) routingSchedule(r *http.Request) (interface{}, error) {
+	return as.coordinator.RoutingSchedule(), nil
+}
+
+func (as *AdminServer) overrideRoutingPreference(r *http.Request) (interface{}, error) {
+	preference := r.URL.Query().Get("preference")
+	if preference == "" {
+		return nil, badRequest("preference is required")
+	}
+	value := r.URL.Query().Get("duration")
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return nil, badRequest("duration must be a positive duration such as \"2h\", got %q", value)
+	}
+
+	if err := as.coordinator.OverrideRoutingPreference(preference, duration); err != nil {
+		if errors.Is(err, internal.ErrUnknownRoutingPreference) {
+			return nil, &adminStatusError{status: http.StatusNotFound, message: err.Error()}
+		}
+		return nil, badRequest("%v", err)
+	}
+
+	as.logger.Info("Routing preference overridden through admin API",
+		zap.String("preference", preference),
+		zap.Duration("duration", duration),
+	)
+	return as.coordinator.RoutingSchedule(), nil
+}
+
+func (as *AdminServer) clearRoutingOverride(r *http.Request) (interface{}, error) {
+	if as.coordinator.ClearRoutingOverride() {
+		as.logger.Info("Routing preference override cleared through admin API")
+	}
+	return as.coordinator.RoutingSchedule(), nil
+}
+
+func (as *AdminServer) exportState(r *http.Request) (interface{}, error) {
+	return as.coordinator.ExportState()
+}
+
+func (as *AdminServer) reloadConfig(r *http.Request) (interface{}, error) {
+	as.mutex.Lock()
+	reload := as.reload
+	as.mutex.Unlock()
+
+	if reload == nil {
+		return nil, &adminStatusError{status: http.StatusNotImplemented, message: "config reload is not configured"}
+	}
+
+	if err := reload(r.Context()); err != nil {
+		return nil, fmt.Errorf("config reload failed: %w", err)
+	}
+
+	as.logger.Info("Configuration reloaded through admin API")
+	return reloadView{Reloaded: true, ReloadedAt: time.Now()}, nil
+}
+
+func (as *AdminServer) currentConfig(r *http.Request) (interface{}, error) {
+	return configView{Config: as.coordinator.ConfigValues()}, nil
+}
+
+func (as *AdminServer) applyConfig(r *http.Request) (interface{}, error) {
+	if err := as.coordinator.CheckUnsignedPolicy(internal.PolicyKindConfig); err != nil {
+		return nil, policyError(err)
+	}
+
+	var delta internal.ConfigDelta
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxConfigBody)).Decode(&delta); err != nil {
+		return nil, badRequest("invalid config change: %v", err)
+	}
+	if len(delta) == 0 {
+		return nil, badRequest("config change is empty")
+	}
+
+	changed, err := as.coordinator.ApplyConfig(delta)
+	if err != nil {
+		return nil, badRequest("%v", err)
+	}
+
+	as.logger.Info("Configuration changed through admin API", zap.Strings("changed", changed))
+	return configApplyView{
+		Changed:   changed,
+		AppliedAt: time.Now(),
+		Config:    as.coordinator.ConfigValues(),
+	}, nil
+}
+
+func (as *AdminServer) audit(r *http.Request) (interface{}, error) {
+	limit, err := as.limit(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var since uint64
+	if value := r.URL.Query().Get("since"); value != "" {
+		if since, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return nil, badRequest("since must be a sequence number, got %q", value)
+		}
+	}
+
+	return auditView{
+		Sequence: as.auditLog.Sequence(),
+		Entries:  as.auditLog.Entries(since, limit),
+	}, nil
+}
+
+// configState is the audited state of config and feature flag changes
+func (as *AdminServer) configState() interface{} {
+	return as.coordinator.ConfigValues()
+}
+
+// faultState is the audited state of fault changes
+func (as *AdminServer) faultState() interface{} {
+	return as.coordinator.Faults().Faults()
+}
+
+func (as *AdminServer) faults(r *http.Request) (interface{}, error) {
+	injector := as.coordinator.Faults()
+	return faultsView{Enabled: injector.Enabled(), Faults: injector.Faults()}, nil
+}
+
+func (as *AdminServer) injectFault(r *http.Request) (interface{}, error) {
+	var fault internal.Fault
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxConfigBody)).Decode(&fault); err != nil {
+		return nil, badRequest("invalid fault: %v", err)
+	}
+
+	injected, err := as.coordinator.Faults().Inject(fault)
+	if errors.Is(err, internal.ErrFaultInjectionDisabled) {
+		return nil, &adminStatusError{status: http.StatusConflict, message: err.Error()}
+	}
+	if err != nil {
+		return nil, badRequest("%v", err)
+	}
+	return injected, nil
+}
+
+func (as *AdminServer) removeFault(r *http.Request) (interface{}, error) {
+	injector := as.coordinator.Faults()
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		return faultRemoveView{Removed: injector.Clear()}, nil
+	}
+
+	if !injector.Remove(id) {
+		return nil, &adminStatusError{status: http.StatusNotFound, message: fmt.Sprintf("no fault %q", id)}
+	}
+	return faultRemoveView{Removed: 1}, nil
+}
+
+// recordingState is the audited state of request recording changes
+func (as *AdminServer) recordingState() interface{} {
+	status := as.coordinator.RecordingStatus()
+	return struct {
+		Active     bool
+		Path       string
+		SampleRate float64
+	}{status.Active, status.Path, status.SampleRate}
+}
+
+func (as *AdminServer) recording(r *http.Request) (interface{}, error) {
+	return as.coordinator.RecordingStatus(), nil
+}
+
+func (as *AdminServer) startRecording(r *http.Request) (interface{}, error) {
+	var config internal.RecordingConfig
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxConfigBody)).Decode(&config); err != nil {
+		return nil, badRequest("invalid recording config: %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, badRequest("%v", err)
+	}
+
+	status, err := as.coordinator.StartRecording(config)
+	if errors.Is(err, internal.ErrAlreadyRecording) {
+		return nil, &adminStatusError{status: http.StatusConflict, message: err.Error()}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (as *AdminServer) stopRecording(r *http.Request) (interface{}, error) {
+	status, err := as.coordinator.StopRecording()
+	if errors.Is(err, internal.ErrNotRecording) {
+		return nil, &adminStatusError{status: http.StatusConflict, message: err.Error()}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (as *AdminServer) featureFlags(r *http.Request) (interface{}, error) {
+	flags := as.coordinator.FeatureFlags()
+	return featureFlagsView{Flags: flags.Flags(), Stats: flags.Stats()}, nil
+}
+
+func (as *AdminServer) setFeatureFlag(r *http.Request) (interface{}, error) {
+	if err := as.coordinator.CheckUnsignedPolicy(internal.PolicyKindFeatureFlag); err != nil {
+		return nil, policyError(err)
+	}
+
+	var flag internal.FeatureFlag
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxConfigBody)).Decode(&flag); err != nil {
+		return nil, badRequest("invalid feature flag: %v", err)
+	}
+
+	if err := as.coordinator.SetFeatureFlag(flag); err != nil {
+		return nil, badRequest("%v", err)
+	}
+
+	as.logger.Info("Feature flag set through admin API",
+		zap.String("flag", flag.Name),
+		zap.Bool("enabled", flag.Enabled),
+		zap.Float64("percentage", flag.Percentage),
+	)
+	return flag, nil
+}
+
+func (as *AdminServer) removeFeatureFlag(r *http.Request) (interface{}, error) {
+	if err := as.coordinator.CheckUnsignedPolicy(internal.PolicyKindFeatureFlag); err != nil {
+		return nil, policyError(err)
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		return nil, badRequest("name is required")
+	}
+
+	removed, err := as.coordinator.RemoveFeatureFlag(name)
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		return nil, &adminStatusError{status: http.StatusNotFound, message: fmt.Sprintf("no feature flag %q", name)}
+	}
+
+	as.logger.Info("Feature flag removed through admin API", zap.String("flag", name))
+	return featureFlagRemoveView{Removed: true}, nil
+}
+
+func (as *AdminServer) applyPolicy(r *http.Request) (interface{}, error) {
+	var policy internal.SignedPolicy
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxConfigBody)).Decode(&policy); err != nil {
+		return nil, badRequest("invalid signed policy: %v", err)
+	}
+
+	applied, err := as.coordinator.ApplySignedPolicy(policy)
+	if err != nil {
+		return nil, policyError(err)
+	}
+
+	as.logger.Info("Signed policy applied through admin API",
+		zap.String("kind", policy.Kind),
+		zap.String("key_id", policy.KeyID),
+		zap.Int("applied", applied),
+	)
+	return policyApplyView{Kind: policy.Kind, KeyID: policy.KeyID, Applied: applied}, nil
+}
+
+// policyError converts a refused or invalid policy to a response status
+func policyError(err error) error {
+	if errors.Is(err, internal.ErrPolicyUnsigned) || errors.Is(err, internal.ErrPolicySignature) {
+		return &adminStatusError{status: http.StatusForbidden, message: err.Error()}
+	}
+	if errors.Is(err, internal.ErrStandby) {
+		return &adminStatusError{status: http.StatusServiceUnavailable, message: err.Error()}
+	}
+	return badRequest("%v", err)
+}
+
+// quotaState is the audited state of quota changes
+func (as *AdminServer) quotaState() interface{} {
+	return as.coordinator.Quotas().Entries()
+}
+
+func (as *AdminServer) quotas(r *http.Request) (interface{}, error) {
+	return quotasView{Quotas: as.coordinator.Quotas().Stats()}, nil
+}
+
+func (as *AdminServer) setQuota(r *http.Request) (interface{}, error) {
+	var entry internal.QuotaEntry
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxConfigBody)).Decode(&entry); err != nil {
+		return nil, badRequest("invalid quota: %v", err)
+	}
+
+	if err := as.coordinator.SetQuota(entry.Scope, entry.Key, entry.Quota); err != nil {
+		return nil, badRequest("%v", err)
+	}
+
+	as.logger.Info("Quota set through admin API",
+		zap.String("scope", entry.Scope),
+		zap.String("key", entry.Key),
+		zap.Float64("qps", entry.Quota.QPS),
+		zap.Int("max_concurrent", entry.Quota.MaxConcurrent),
+		zap.Float64("cache_share", entry.Quota.CacheShare),
+	)
+	return entry, nil
+}
+
+// reservationState is the audited state of bandwidth reservations
+func (as *AdminServer) reservationState() interface{} {
+	return as.coordinator.RoutingTable().Reservations().List()
+}
+
+func (as *AdminServer) reservations(r *http.Request) (interface{}, error) {
+	return reservationsView{Reservations: as.coordinator.RoutingTable().Reservations().List()}, nil
+}
+
+func (as *AdminServer) reserveBandwidth(r *http.Request) (interface{}, error) {
+	var request reservationRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxConfigBody)).Decode(&request); err != nil {
+		return nil, badRequest("invalid reservation: %v", err)
+	}
+
+	reservation, err := as.coordinator.RoutingTable().ReserveBandwidth(request.Path, request.Mbps, request.TTL)
+	if errors.Is(err, routing.ErrInsufficientBandwidth) {
+		return nil, &adminStatusError{status: http.StatusConflict, message: err.Error()}
+	}
+	if err != nil {
+		return nil, badRequest("%v", err)
+	}
+
+	as.logger.Info("Bandwidth reserved through admin API",
+		zap.String("reservation", reservation.ID),
+		zap.Int64s("path", reservation.Path),
+		zap.Float64("mbps", reservation.Mbps),
+		zap.Time("expires_at", reservation.ExpiresAt),
+	)
+	return reservation, nil
+}
+
+func (as *AdminServer) renewReservation(r *http.Request) (interface{}, error) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		return nil, badRequest("id is required")
+	}
+	value := r.URL.Query().Get("ttl")
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return nil, badRequest("ttl must be a positive duration such as \"10m\", got %q", value)
+	}
+
+	reservation, err := as.coordinator.RoutingTable().Reservations().Renew(id, ttl)
+	if errors.Is(err, routing.ErrUnknownReservation) {
+		return nil, &adminStatusError{status: http.StatusNotFound, message: err.Error()}
+	}
+	if err != nil {
+		return nil, badRequest("%v", err)
+	}
+	return reservation, nil
+}
+
+func (as *AdminServer) releaseReservation(r *http.Request) (interface{}, error) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		return nil, badRequest("id is required")
+	}
+
+	if !as.coordinator.RoutingTable().Reservations().Release(id) {
+		return nil, &adminStatusError{status: http.StatusNotFound, message: fmt.Sprintf("no reservation %q", id)}
+	}
+	as.logger.Info("Bandwidth reservation released through admin API", zap.String("reservation", id))
+	return reservationReleaseView{Released: true}, nil
+}
+
+// drState is the audited state of region failures
+func (as *AdminServer) drState() interface{} {
+	var failed []string
+	for _, status := range as.coordinator.RoutingTable().DRPlans().Status() {
+		if status.Active {
+			failed = append(failed, status.Region)
+		}
+	}
+	return failed
+}
+
+func (as *AdminServer) drPlans(r *http.Request) (interface{}, error) {
+	return drPlansView{Plans: as.coordinator.RoutingTable().DRPlans().Status()}, nil
+}
+
+func (as *AdminServer) latencySLOs(r *http.Request) (interface{}, error) {
+	return sloView{SLOs: as.coordinator.LatencySLOs()}, nil
+}
+
+func (as *AdminServer) failRegion(r *http.Request) (interface{}, error) {
+	region := r.URL.Query().Get("region")
+	if region == "" {
+		return nil, badRequest("region is required")
+	}
+
+	table := as.coordinator.RoutingTable()
+	if _, exists := table.DRPlans().All()[region]; !exists {
+		return nil, &adminStatusError{status: http.StatusNotFound, message: fmt.Sprintf("no dr plan for region %q", region)}
+	}
+	if !table.FailRegion(region) {
+		return nil, &adminStatusError{status: http.StatusConflict, message: fmt.Sprintf("region %q has already failed", region)}
+	}
+	as.logger.Warn("Region marked failed through admin API", zap.String("region", region))
+	return drPlansView{Plans: table.DRPlans().Status()}, nil
+}
+
+func (as *AdminServer) recoverRegion(r *http.Request) (interface{}, error) {
+	region := r.URL.Query().Get("region")
+	if region == "" {
+		return nil, badRequest("region is required")
+	}
+
+	table := as.coordinator.RoutingTable()
+	if !table.RecoverRegion(region) {
+		return nil, &adminStatusError{status: http.StatusNotFound, message: fmt.Sprintf("region %q has not failed", region)}
+	}
+	as.logger.Info("Region marked recovered through admin API", zap.String("region", region))
+	return drPlansView{Plans: table.DRPlans().Status()}, nil
+}
+
+// maintenanceState is the audited state of node maintenance
+func (as *AdminServer) maintenanceState() interface{} {
+	statuses := as.coordinator.NodeMaintenance()
+	type drain struct {
+		NodeID   int64
+		Deadline time.Time
+		Removed  bool
+	}
+	drains := make([]drain, len(statuses))
+	for i, status := range statuses {
+		drains[i] = drain{status.NodeID, status.Deadline, status.Removed}
+	}
+	return drains
+}
+
+func (as *AdminServer) nodeMaintenance(r *http.Request) (interface{}, error) {
+	return maintenanceView{Nodes: as.coordinator.NodeMaintenance()}, nil
+}
+
+func (as *AdminServer) startNodeMaintenance(r *http.Request) (interface{}, error) {
+	node := r.URL.Query().Get("node")
+	nodeID, err := strconv.ParseInt(node, 10, 64)
+	if err != nil {
+		return nil, badRequest("invalid node %q", node)
+	}
+	value := r.URL.Query().Get("drain")
+	drain, err := time.ParseDuration(value)
+	if err != nil || drain <= 0 {
+		return nil, badRequest("drain must be a positive duration such as \"15m\", got %q", value)
+	}
+
+	err = as.coordinator.MarkNodeMaintenance(nodeID, time.Now().Add(drain))
+	switch {
+	case errors.Is(err, internal.ErrUnknownNode):
+		return nil, &adminStatusError{status: http.StatusNotFound, message: err.Error()}
+	case errors.Is(err, internal.ErrNodeInMaintenance):
+		return nil, &adminStatusError{status: http.StatusConflict, message: err.Error()}
+	case err != nil:
+		return nil, badRequest("%v", err)
+	}
+
+	as.logger.Info("Node maintenance started through admin API",
+		zap.Int64("node_id", nodeID),
+		zap.Duration("drain", drain),
+	)
+	return maintenanceView{Nodes: as.coordinator.NodeMaintenance()}, nil
+}
+
+func (as *AdminServer) cancelNodeMaintenance(r *http.Request) (interface{}, error) {
+	node := r.URL.Query().Get("node")
+	nodeID, err := strconv.ParseInt(node, 10, 64)
+	if err != nil {
+		return nil, badRequest("invalid node %q", node)
+	}
+
+	if err := as.coordinator.CancelNodeMaintenance(nodeID); err != nil {
+		return nil, &adminStatusError{status: http.StatusNotFound, message: err.Error()}
+	}
+	as.logger.Info("Node maintenance cancelled through admin API", zap.Int64("node_id", nodeID))
+	return maintenanceCancelView{Cancelled: true}, nil
+}
+
+func (as *AdminServer) removeQuota(r *http.Request) (interface{}, error) {
+	scope, key := r.URL.Query().Get("scope"), r.URL.Query().Get("key")
+	if scope == "" || key == "" {
+		return nil, badRequest("scope and key are required")
+	}
+
+	if !as.coordinator.RemoveQuota(scope, key) {
+		return nil, &adminStatusError{status: http.StatusNotFound, message: fmt.Sprintf("no %s quota for %q", scope, key)}
+	}
+
+	as.logger.Info("Quota removed through admin API",
+		zap.String("scope", scope),
+		zap.String("key", key),
+	)
+	return quotaRemoveView{Removed: true}, nil
+}
+
+// limit returns the limit query parameter bounded by the configuration
+func (as *AdminServer) limit(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return as.config.DefaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return 0, badRequest("invalid limit %q", value)
+	}
+	if limit > as.config.MaxLimit {
+		limit = as.config.MaxLimit
+	}
+	return limit, nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+// DefaultAdminServerConfig returns default admin API configuration. The API
+// is disabled and, once enabled, only listens on loopback.
+func DefaultAdminServerConfig() *AdminServerConfig {
+	return &AdminServerConfig{
+		Enabled:        false,
+		ListenAddress:  "127.0.0.1:9465",
+		PathPrefix:     "/admin",
+		DefaultLimit:   100,
+		MaxLimit:       10000,
+		RequestTimeout: 10 * time.Second,
+		AuditLogSize:   1000,
+		ActorHeader:    "X-Admin-Actor",
+	}
+}
@@ -0,0 +1,423 @@
+package api
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+func TestAdminQuotas(t *testing.T) {
+	config := internal.DefaultALMConfig()
+	config.MaxNodes = 1000
+	config.MaxEdges = 10000
+	coordinator, err := internal.NewALMCoordinator(config, nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+
+	admin := NewAdminServer(coordinator, DefaultAdminServerConfig(), nil)
+	server := httptest.NewServer(admin.Handler())
+	defer server.Close()
+
+	post := func(path, body string) int {
+		t.Helper()
+		response, err := http.Post(server.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		response.Body.Close()
+		return response.StatusCode
+	}
+
+	if status := post("/admin/quotas/set", `{"scope":"tenant","key":"acme","quota":{"qps":50,"max_concurrent":4,"cache_share":0.1}}`); status != http.StatusOK {
+		t.Fatalf("set: status %d", status)
+	}
+	if status := post("/admin/quotas/set", `{"scope":"tenant","key":"acme","quota":{"cache_share":1.5}}`); status != http.StatusBadRequest {
+		t.Errorf("invalid set: status %d, want 400", status)
+	}
+
+	response, err := http.Get(server.URL + "/admin/quotas")
+	if err != nil {
+		t.Fatalf("GET quotas: %v", err)
+	}
+	var view quotasView
+	err = json.NewDecoder(response.Body).Decode(&view)
+	response.Body.Close()
+	if err != nil {
+		t.Fatalf("decode quotas: %v", err)
+	}
+	want := internal.Quota{QPS: 50, MaxConcurrent: 4, CacheShare: 0.1}
+	if len(view.Quotas) != 1 || view.Quotas[0].Key != "acme" || view.Quotas[0].Quota != want {
+		t.Errorf("quotas: %+v", view.Quotas)
+	}
+
+	if status := post("/admin/quotas/remove?scope=tenant&key=acme", ""); status != http.StatusOK {
+		t.Errorf("remove: status %d", status)
+	}
+	if status := post("/admin/quotas/remove?scope=tenant&key=acme", ""); status != http.StatusNotFound {
+		t.Errorf("second remove: status %d, want 404", status)
+	}
+
+	entries := admin.AuditLog().Entries(0, 10)
+	if len(entries) != 4 || entries[0].Path != "/admin/quotas/set" {
+		t.Errorf("audit entries: %+v", entries)
+	}
+}
+
+func TestAdminSignedPolicies(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := internal.DefaultALMConfig()
+	config.MaxNodes = 1000
+	config.MaxEdges = 10000
+	config.TrustAnchors = map[string]string{"ops": base64.StdEncoding.EncodeToString(public)}
+	coordinator, err := internal.NewALMCoordinator(config, nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+
+	server := httptest.NewServer(NewAdminServer(coordinator, DefaultAdminServerConfig(), nil).Handler())
+	defer server.Close()
+
+	post := func(path string, body interface{}) int {
+		t.Helper()
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := http.Post(server.URL+path, "application/json", bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		response.Body.Close()
+		return response.StatusCode
+	}
+
+	if status := post("/admin/config/apply", map[string]interface{}{"route_cache_size": 2000}); status != http.StatusForbidden {
+		t.Errorf("unsigned config change: status %d, want 403", status)
+	}
+	if status := post("/admin/flags/set", internal.FeatureFlag{Name: "hedging", Enabled: true}); status != http.StatusForbidden {
+		t.Errorf("unsigned feature flag: status %d, want 403", status)
+	}
+
+	payload := []byte(`{"route_cache_size":2000}`)
+	forged := internal.SignedPolicy{Kind: internal.PolicyKindConfig, KeyID: "ops", Payload: payload, Signature: make([]byte, ed25519.SignatureSize)}
+	if status := post("/admin/policy/apply", forged); status != http.StatusForbidden {
+		t.Errorf("forged policy: status %d, want 403", status)
+	}
+
+	signed := internal.SignPolicy(internal.PolicyKindConfig, "ops", payload, time.Now().Add(time.Minute), private)
+	if status := post("/admin/policy/apply", signed); status != http.StatusOK {
+		t.Errorf("signed policy: status %d", status)
+	}
+	if got := coordinator.Config().RouteCacheSize; got != 2000 {
+		t.Errorf("route_cache_size is %d, want 2000", got)
+	}
+}
+
+func TestAdminScrubbedExports(t *testing.T) {
+	config := internal.DefaultALMConfig()
+	config.MaxNodes = 1000
+	config.MaxEdges = 10000
+	config.ScrubAddressPatterns = []string{"ipv4"}
+	coordinator, err := internal.NewALMCoordinator(config, nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+	defer coordinator.ApplyConfig(internal.ConfigDelta{"scrub_address_patterns": []interface{}{}})
+
+	err = coordinator.UpdateNetworkTopology([]internal.TopologyUpdate{
+		{Type: internal.NodeAddUpdate, NodeID: 1, Node: &graph.NetworkNode{ID: 1, Address: "192.168.7.20:7000"}},
+	})
+	if err != nil {
+		t.Fatalf("UpdateNetworkTopology: %v", err)
+	}
+
+	admin := NewAdminServer(coordinator, DefaultAdminServerConfig(), nil)
+	server := httptest.NewServer(admin.Handler())
+	defer server.Close()
+
+	for _, path := range []string{"/admin/topology", "/admin/state/export"} {
+		response, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		var body bytes.Buffer
+		body.ReadFrom(response.Body)
+		response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			t.Errorf("%s: status %d", path, response.StatusCode)
+		}
+		if strings.Contains(body.String(), "192.168.7.20") || !strings.Contains(body.String(), ":7000") {
+			t.Errorf("%s: address not scrubbed: %s", path, body.String())
+		}
+	}
+}
+
+func TestAdminRoutingScheduleOverride(t *testing.T) {
+	config := internal.DefaultALMConfig()
+	config.MaxNodes = 1000
+	config.MaxEdges = 10000
+	config.RoutingSchedule = []internal.RoutingPreference{
+		{Name: "off-peak", Start: "22:00", End: "06:00", Weights: internal.ObjectiveWeights{Cost: 0.7, Reliability: 0.3}},
+	}
+	coordinator, err := internal.NewALMCoordinator(config, nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+
+	admin := NewAdminServer(coordinator, DefaultAdminServerConfig(), nil)
+	server := httptest.NewServer(admin.Handler())
+	defer server.Close()
+
+	post := func(path string) (int, internal.RoutingScheduleStatus) {
+		t.Helper()
+		response, err := http.Post(server.URL+path, "application/json", nil)
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer response.Body.Close()
+		var status internal.RoutingScheduleStatus
+		json.NewDecoder(response.Body).Decode(&status)
+		return response.StatusCode, status
+	}
+
+	code, status := post("/admin/schedule/override?preference=off-peak&duration=30m")
+	if code != http.StatusOK || status.Active != "off-peak" || status.Override != "off-peak" {
+		t.Fatalf("override: status %d, %+v", code, status)
+	}
+	if code, _ := post("/admin/schedule/override?preference=missing&duration=30m"); code != http.StatusNotFound {
+		t.Errorf("override to a missing preference: status %d, want 404", code)
+	}
+	if code, _ := post("/admin/schedule/override?preference=off-peak&duration=-1h"); code != http.StatusBadRequest {
+		t.Errorf("negative duration: status %d, want 400", code)
+	}
+	if code, status := post("/admin/schedule/override/clear"); code != http.StatusOK || status.Override != "" {
+		t.Errorf("clear: status %d, %+v", code, status)
+	}
+}
+
+func TestAdminBandwidthReservations(t *testing.T) {
+	config := internal.DefaultALMConfig()
+	config.MaxNodes = 1000
+	config.MaxEdges = 10000
+	coordinator, err := internal.NewALMCoordinator(config, nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+	err = coordinator.UpdateNetworkTopology([]internal.TopologyUpdate{
+		{Type: internal.NodeAddUpdate, NodeID: 1, Node: &graph.NetworkNode{ID: 1}},
+		{Type: internal.NodeAddUpdate, NodeID: 2, Node: &graph.NetworkNode{ID: 2}},
+		{Type: internal.EdgeAddUpdate, Edge: &graph.NetworkEdge{From: 1, To: 2, Bandwidth: 100, Latency: time.Millisecond}},
+	})
+	if err != nil {
+		t.Fatalf("UpdateNetworkTopology: %v", err)
+	}
+
+	admin := NewAdminServer(coordinator, DefaultAdminServerConfig(), nil)
+	server := httptest.NewServer(admin.Handler())
+	defer server.Close()
+
+	post := func(path, body string) (int, []byte) {
+		t.Helper()
+		response, err := http.Post(server.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer response.Body.Close()
+		var data bytes.Buffer
+		data.ReadFrom(response.Body)
+		return response.StatusCode, data.Bytes()
+	}
+
+	status, body := post("/admin/reservations/reserve", `{"path":[1,2],"mbps":60,"ttl_ns":60000000000}`)
+	if status != http.StatusOK {
+		t.Fatalf("reserve: status %d: %s", status, body)
+	}
+	var reservation routing.Reservation
+	if err := json.Unmarshal(body, &reservation); err != nil || reservation.ID == "" {
+		t.Fatalf("reserve response %s: %v", body, err)
+	}
+	if status, _ := post("/admin/reservations/reserve", `{"path":[1,2],"mbps":60,"ttl_ns":60000000000}`); status != http.StatusConflict {
+		t.Errorf("overcommitting reserve: status %d, want 409", status)
+	}
+	if status, _ := post("/admin/reservations/renew?id="+reservation.ID+"&ttl=5m", ""); status != http.StatusOK {
+		t.Errorf("renew: status %d", status)
+	}
+	if status, _ := post("/admin/reservations/release?id="+reservation.ID, ""); status != http.StatusOK {
+		t.Errorf("release: status %d", status)
+	}
+	if status, _ := post("/admin/reservations/release?id="+reservation.ID, ""); status != http.StatusNotFound {
+		t.Errorf("second release: status %d, want 404", status)
+	}
+}
+
+func TestAdminNodeMaintenance(t *testing.T) {
+	config := internal.DefaultALMConfig()
+	config.MaxNodes = 1000
+	config.MaxEdges = 10000
+	coordinator, err := internal.NewALMCoordinator(config, nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+	err = coordinator.UpdateNetworkTopology([]internal.TopologyUpdate{
+		{Type: internal.NodeAddUpdate, NodeID: 1, Node: &graph.NetworkNode{ID: 1}},
+		{Type: internal.NodeAddUpdate, NodeID: 2, Node: &graph.NetworkNode{ID: 2}},
+		{Type: internal.EdgeAddUpdate, Edge: &graph.NetworkEdge{From: 1, To: 2, Bandwidth: 100, Latency: time.Millisecond}},
+	})
+	if err != nil {
+		t.Fatalf("UpdateNetworkTopology: %v", err)
+	}
+	if _, err := coordinator.RoutingTable().ReserveBandwidth([]int64{1, 2}, 10, time.Minute); err != nil {
+		t.Fatalf("ReserveBandwidth: %v", err)
+	}
+
+	admin := NewAdminServer(coordinator, DefaultAdminServerConfig(), nil)
+	server := httptest.NewServer(admin.Handler())
+	defer server.Close()
+
+	post := func(path string) (int, []byte) {
+		t.Helper()
+		response, err := http.Post(server.URL+path, "application/json", nil)
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer response.Body.Close()
+		var data bytes.Buffer
+		data.ReadFrom(response.Body)
+		return response.StatusCode, data.Bytes()
+	}
+
+	status, body := post("/admin/maintenance/start?node=2&drain=15m")
+	if status != http.StatusOK {
+		t.Fatalf("start: status %d: %s", status, body)
+	}
+	var view maintenanceView
+	if err := json.Unmarshal(body, &view); err != nil || len(view.Nodes) != 1 {
+		t.Fatalf("start response %s: %v", body, err)
+	}
+	if drain := view.Nodes[0]; drain.NodeID != 2 || drain.Reservations != 1 || drain.SafeToReboot {
+		t.Errorf("draining node status: %+v", drain)
+	}
+
+	for path, want := range map[string]int{
+		"/admin/maintenance/start?node=2&drain=15m": http.StatusConflict,
+		"/admin/maintenance/start?node=9&drain=15m": http.StatusNotFound,
+		"/admin/maintenance/start?node=1&drain=-1m": http.StatusBadRequest,
+		"/admin/maintenance/cancel?node=1":          http.StatusNotFound,
+	} {
+		if status, body := post(path); status != want {
+			t.Errorf("%s: status %d, want %d: %s", path, status, want, body)
+		}
+	}
+
+	if status, body := post("/admin/maintenance/cancel?node=2"); status != http.StatusOK {
+		t.Errorf("cancel: status %d: %s", status, body)
+	}
+	if statuses := coordinator.NodeMaintenance(); len(statuses) != 0 {
+		t.Errorf("node still in maintenance after cancel: %+v", statuses)
+	}
+}
+
+func TestAdminDRPlans(t *testing.T) {
+	config := internal.DefaultALMConfig()
+	config.MaxNodes = 1000
+	config.MaxEdges = 10000
+	config.DRPlans = map[string][]routing.FailoverTarget{"us-east": {{Region: "us-west", MaxFlows: 10}}}
+	coordinator, err := internal.NewALMCoordinator(config, nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+
+	admin := NewAdminServer(coordinator, DefaultAdminServerConfig(), nil)
+	server := httptest.NewServer(admin.Handler())
+	defer server.Close()
+
+	post := func(path string) (int, []byte) {
+		t.Helper()
+		response, err := http.Post(server.URL+path, "application/json", nil)
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer response.Body.Close()
+		var data bytes.Buffer
+		data.ReadFrom(response.Body)
+		return response.StatusCode, data.Bytes()
+	}
+
+	status, body := post("/admin/dr/fail?region=us-east")
+	if status != http.StatusOK {
+		t.Fatalf("fail: status %d: %s", status, body)
+	}
+	var view drPlansView
+	if err := json.Unmarshal(body, &view); err != nil || len(view.Plans) != 1 || !view.Plans[0].Active {
+		t.Fatalf("fail response %s: %v", body, err)
+	}
+
+	for path, want := range map[string]int{
+		"/admin/dr/fail?region=us-east":    http.StatusConflict,
+		"/admin/dr/fail?region=eu-west":    http.StatusNotFound,
+		"/admin/dr/fail":                   http.StatusBadRequest,
+		"/admin/dr/recover?region=eu-west": http.StatusNotFound,
+	} {
+		if status, body := post(path); status != want {
+			t.Errorf("%s: status %d, want %d: %s", path, status, want, body)
+		}
+	}
+
+	if status, body := post("/admin/dr/recover?region=us-east"); status != http.StatusOK {
+		t.Errorf("recover: status %d: %s", status, body)
+	}
+	if coordinator.RoutingTable().DRPlans().Failed("us-east") {
+		t.Error("region still failed after recover")
+	}
+}
+
+func TestAdminLatencySLOs(t *testing.T) {
+	config := internal.DefaultALMConfig()
+	config.MaxNodes = 1000
+	config.MaxEdges = 10000
+	config.LatencySLOs = []routing.LatencySLO{
+		{Name: "low-latency-p99", QoSClass: routing.LowLatency, Quantile: 0.99, Threshold: time.Millisecond, Window: 24 * time.Hour},
+	}
+	coordinator, err := internal.NewALMCoordinator(config, nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		coordinator.RoutingTable().GetRoutingMetrics().RecordClassLookup(routing.LowLatency, 2*time.Millisecond)
+	}
+
+	admin := NewAdminServer(coordinator, DefaultAdminServerConfig(), nil)
+	server := httptest.NewServer(admin.Handler())
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/admin/slo")
+	if err != nil {
+		t.Fatalf("GET slo: %v", err)
+	}
+	var view sloView
+	err = json.NewDecoder(response.Body).Decode(&view)
+	response.Body.Close()
+	if err != nil {
+		t.Fatalf("decode slo: %v", err)
+	}
+	if len(view.SLOs) != 1 || view.SLOs[0].SLO.Name != "low-latency-p99" || view.SLOs[0].SlowLookups != 10 || view.SLOs[0].Met {
+		t.Errorf("slos: %+v", view.SLOs)
+	}
+}
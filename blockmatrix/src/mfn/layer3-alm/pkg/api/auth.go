@@ -0,0 +1,197 @@
+// Package api implements authentication and role-based authorization of the admin and gRPC APIs
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+)
+
+// Role is what an authenticated caller may do. Each role includes the ones
+// below it.
+type Role int
+
+const (
+	// RoleNone grants nothing; an operation that declares no role is denied
+	// to every caller
+	RoleNone Role = iota
+
+	// RoleReadOnly may look up routes and read state and statistics
+	RoleReadOnly
+
+	// RoleOperator may also change topology, inject faults and record
+	// requests
+	RoleOperator
+
+	// RoleAdmin may also change configuration and feature flags and use
+	// the debug endpoints
+	RoleAdmin
+)
+
+// String returns the role name
+func (r Role) String() string {
+	switch r {
+	case RoleNone:
+		return "none"
+	case RoleReadOnly:
+		return "read_only"
+	case RoleOperator:
+		return "operator"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return fmt.Sprintf("role_%d", int(r))
+	}
+}
+
+// ParseRole returns the role named by String
+func ParseRole(name string) (Role, error) {
+	for role := RoleNone; role <= RoleAdmin; role++ {
+		if role.String() == name {
+			return role, nil
+		}
+	}
+	return RoleNone, fmt.Errorf("unknown role %q", name)
+}
+
+// Principal is an authenticated caller
+type Principal struct {
+	Name string
+	Role Role
+
+	// Method is how the caller authenticated, "mtls" or "token"
+	Method string
+}
+
+// Credentials are what a caller presented: the state of its TLS connection,
+// nil over plaintext, and the bearer token from its Authorization header or
+// metadata
+type Credentials struct {
+	TLS   *tls.ConnectionState
+	Token string
+}
+
+// Errors returned by authentication and authorization
+var (
+	ErrUnauthenticated  = errors.New("unauthenticated")
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// Authenticator identifies callers from their credentials. It returns an
+// error wrapping ErrUnauthenticated when it does not recognize them.
+type Authenticator interface {
+	Authenticate(ctx context.Context, credentials Credentials) (Principal, error)
+}
+
+// Authenticators tries each authenticator in turn, returning the first
+// principal identified
+type Authenticators []Authenticator
+
+// Authenticate returns the principal of the first authenticator that
+// recognizes credentials
+func (a Authenticators) Authenticate(ctx context.Context, credentials Credentials) (Principal, error) {
+	for _, authenticator := range a {
+		principal, err := authenticator.Authenticate(ctx, credentials)
+		if err == nil {
+			return principal, nil
+		}
+		if !errors.Is(err, ErrUnauthenticated) {
+			return Principal{}, err
+		}
+	}
+	return Principal{}, ErrUnauthenticated
+}
+
+// TokenAuthenticator identifies callers by bearer token. Tokens are held
+// as SHA-256 digests, so lookups do not leak them through timing.
+type TokenAuthenticator struct {
+	principals map[[sha256.Size]byte]Principal
+}
+
+// NewTokenAuthenticator creates an authenticator for the principals keyed
+// by their tokens
+func NewTokenAuthenticator(tokens map[string]Principal) *TokenAuthenticator {
+	principals := make(map[[sha256.Size]byte]Principal, len(tokens))
+	for token, principal := range tokens {
+		principal.Method = "token"
+		principals[sha256.Sum256([]byte(token))] = principal
+	}
+	return &TokenAuthenticator{principals: principals}
+}
+
+// Authenticate returns the principal holding the presented token
+func (ta *TokenAuthenticator) Authenticate(ctx context.Context, credentials Credentials) (Principal, error) {
+	if credentials.Token == "" {
+		return Principal{}, fmt.Errorf("%w: no bearer token", ErrUnauthenticated)
+	}
+	principal, ok := ta.principals[sha256.Sum256([]byte(credentials.Token))]
+	if !ok {
+		return Principal{}, fmt.Errorf("%w: unknown bearer token", ErrUnauthenticated)
+	}
+	return principal, nil
+}
+
+// CertificateAuthenticator identifies callers by the client certificate
+// they presented over mutual TLS. The server's TLS configuration must
+// verify client certificates; unverified ones are not trusted.
+type CertificateAuthenticator struct {
+	// Roles maps client identities, the SPIFFE ID of a certificate or else
+	// its common name, to their roles
+	Roles map[string]Role
+
+	// DefaultRole is granted to verified clients missing from Roles;
+	// RoleNone rejects them
+	DefaultRole Role
+}
+
+// Authenticate returns the principal named by the verified client
+// certificate
+func (ca *CertificateAuthenticator) Authenticate(ctx context.Context, credentials Credentials) (Principal, error) {
+	if credentials.TLS == nil || len(credentials.TLS.VerifiedChains) == 0 || len(credentials.TLS.VerifiedChains[0]) == 0 {
+		return Principal{}, fmt.Errorf("%w: no verified client certificate", ErrUnauthenticated)
+	}
+
+	certificate := credentials.TLS.VerifiedChains[0][0]
+	name := certificate.Subject.CommonName
+	for _, uri := range certificate.URIs {
+		if uri.Scheme == "spiffe" {
+			name = uri.String()
+			break
+		}
+	}
+
+	role, ok := ca.Roles[name]
+	if !ok {
+		role = ca.DefaultRole
+	}
+	if name == "" || role == RoleNone {
+		return Principal{}, fmt.Errorf("%w: client certificate %q has no role", ErrUnauthenticated, name)
+	}
+	return Principal{Name: name, Role: role, Method: "mtls"}, nil
+}
+
+// authorize checks that principal holds the required role. Operations
+// requiring RoleNone have not declared a role and are denied.
+func authorize(principal Principal, required Role) error {
+	if required == RoleNone || principal.Role < required {
+		return fmt.Errorf("%w: requires role %s, %s has %s", ErrPermissionDenied, required, principal.Name, principal.Role)
+	}
+	return nil
+}
+
+// principalKey keys the authenticated principal in a request context
+type principalKey struct{}
+
+// withPrincipal returns ctx carrying principal
+func withPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the caller authenticated for a request, if
+// authentication is enabled
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthenticators(t *testing.T) {
+	tokens := NewTokenAuthenticator(map[string]Principal{
+		"op-token": {Name: "ops", Role: RoleOperator},
+	})
+	spiffe, _ := url.Parse("spiffe://mesh.local/ns/prod/sa/deployer")
+	certificates := &CertificateAuthenticator{
+		Roles:       map[string]Role{spiffe.String(): RoleAdmin},
+		DefaultRole: RoleReadOnly,
+	}
+	authenticator := Authenticators{certificates, tokens}
+
+	verified := func(certificate *x509.Certificate) *tls.ConnectionState {
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{certificate}}}
+	}
+
+	for _, tc := range []struct {
+		name        string
+		credentials Credentials
+		want        Principal
+		wantErr     error
+	}{
+		{"token", Credentials{Token: "op-token"}, Principal{Name: "ops", Role: RoleOperator, Method: "token"}, nil},
+		{"unknown token", Credentials{Token: "guess"}, Principal{}, ErrUnauthenticated},
+		{"nothing", Credentials{}, Principal{}, ErrUnauthenticated},
+		{"spiffe identity", Credentials{TLS: verified(&x509.Certificate{URIs: []*url.URL{spiffe}})}, Principal{Name: spiffe.String(), Role: RoleAdmin, Method: "mtls"}, nil},
+		{"default role", Credentials{TLS: verified(&x509.Certificate{Subject: pkix.Name{CommonName: "dashboard"}})}, Principal{Name: "dashboard", Role: RoleReadOnly, Method: "mtls"}, nil},
+		{"unverified certificate", Credentials{TLS: &tls.ConnectionState{}}, Principal{}, ErrUnauthenticated},
+	} {
+		principal, err := authenticator.Authenticate(context.Background(), tc.credentials)
+		if !errors.Is(err, tc.wantErr) || principal != tc.want {
+			t.Errorf("%s: got %+v, %v; want %+v, %v", tc.name, principal, err, tc.want, tc.wantErr)
+		}
+	}
+
+	if err := authorize(Principal{Role: RoleAdmin}, RoleNone); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("operation without a role allowed: %v", err)
+	}
+	for _, role := range []Role{RoleNone, RoleReadOnly, RoleOperator, RoleAdmin} {
+		if parsed, err := ParseRole(role.String()); err != nil || parsed != role {
+			t.Errorf("ParseRole(%q) = %v, %v", role, parsed, err)
+		}
+	}
+}
+
+func TestAdminServerAuthorizesByRole(t *testing.T) {
+	config := internal.DefaultALMConfig()
+	config.MaxNodes = 1000
+	config.MaxEdges = 10000
+	coordinator, err := internal.NewALMCoordinator(config, nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+
+	adminConfig := DefaultAdminServerConfig()
+	adminConfig.Authenticator = NewTokenAuthenticator(map[string]Principal{
+		"viewer": {Name: "grafana", Role: RoleReadOnly},
+		"root":   {Name: "alice", Role: RoleAdmin},
+	})
+	adminServer := NewAdminServer(coordinator, adminConfig, nil)
+	server := httptest.NewServer(adminServer.Handler())
+	defer server.Close()
+
+	do := func(method, path, token, body string) int {
+		t.Helper()
+		request, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		response.Body.Close()
+		return response.StatusCode
+	}
+
+	for _, tc := range []struct {
+		method, path, token, body string
+		want                      int
+	}{
+		{http.MethodGet, "/admin/cache/stats", "", "", http.StatusUnauthorized},
+		{http.MethodGet, "/admin/cache/stats", "wrong", "", http.StatusUnauthorized},
+		{http.MethodGet, "/admin/cache/stats", "viewer", "", http.StatusOK},
+		{http.MethodGet, "/admin/openapi.json", "", "", http.StatusUnauthorized},
+		{http.MethodPost, "/admin/grafana/search", "", "{}", http.StatusUnauthorized},
+		{http.MethodPost, "/admin/grafana/search", "viewer", "{}", http.StatusOK},
+		{http.MethodGet, "/admin/audit", "viewer", "", http.StatusForbidden},
+		{http.MethodPost, "/admin/config/apply", "viewer", `{"search_timeout":"2s"}`, http.StatusForbidden},
+		{http.MethodPost, "/admin/config/apply", "root", `{"search_timeout":"2s"}`, http.StatusOK},
+	} {
+		if status := do(tc.method, tc.path, tc.token, tc.body); status != tc.want {
+			t.Errorf("%s %s with %q: status %d, want %d", tc.method, tc.path, tc.token, status, tc.want)
+		}
+	}
+
+	// A known token under another scheme, or none, is not accepted
+	for _, authorization := range []string{"viewer", "Basic viewer", "bearer viewer"} {
+		request, _ := http.NewRequest(http.MethodGet, server.URL+"/admin/cache/stats", nil)
+		request.Header.Set("Authorization", authorization)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("GET with %q: %v", authorization, err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status %d, want %d", authorization, response.StatusCode, http.StatusUnauthorized)
+		}
+	}
+
+	entries := adminServer.AuditLog().Entries(0, 0)
+	if len(entries) != 2 {
+		t.Fatalf("audited %d mutations, want the denied and the applied config change", len(entries))
+	}
+	if denied := entries[0]; denied.Actor != "grafana" || denied.Role != "read_only" || denied.Status != http.StatusForbidden {
+		t.Errorf("denied change audited as %+v", denied)
+	}
+	if applied := entries[1]; applied.Actor != "alice" || applied.Role != "admin" || applied.Status != http.StatusOK {
+		t.Errorf("applied change audited as %+v", applied)
+	}
+}
+
+func TestGRPCServerAuthorizesByRole(t *testing.T) {
+	config := DefaultGRPCServerConfig()
+	config.Authenticator = NewTokenAuthenticator(map[string]Principal{
+		"viewer": {Name: "router", Role: RoleReadOnly},
+		"writer": {Name: "layer2", Role: RoleOperator},
+	})
+	config.AuditLog = NewAuditLog(10)
+	server := NewGRPCServer(nil, config, nil)
+
+	call := func(method, token string) error {
+		ctx := context.Background()
+		if token != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+		}
+		info := &grpc.UnaryServerInfo{FullMethod: "/" + ServiceName + "/" + method}
+		_, err := server.authorizeCall(ctx, nil, info, func(ctx context.Context, request interface{}) (interface{}, error) {
+			if _, ok := PrincipalFromContext(ctx); !ok {
+				t.Errorf("%s: handler called without a principal", method)
+			}
+			return nil, nil
+		})
+		return err
+	}
+
+	for _, tc := range []struct {
+		method, token string
+		want          codes.Code
+	}{
+		{"FindOptimalRoute", "", codes.Unauthenticated},
+		{"FindOptimalRoute", "viewer", codes.OK},
+		{"UpdateNetworkTopology", "viewer", codes.PermissionDenied},
+		{"UpdateNetworkTopology", "writer", codes.OK},
+		{"Undeclared", "writer", codes.PermissionDenied},
+	} {
+		if code := status.Code(call(tc.method, tc.token)); code != tc.want {
+			t.Errorf("%s with %q: %s, want %s", tc.method, tc.token, code, tc.want)
+		}
+	}
+
+	unprefixed := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "viewer"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/" + ServiceName + "/FindOptimalRoute"}
+	_, err := server.authorizeCall(unprefixed, nil, info, func(ctx context.Context, request interface{}) (interface{}, error) {
+		t.Error("handler called for a token without the Bearer scheme")
+		return nil, nil
+	})
+	if code := status.Code(err); code != codes.Unauthenticated {
+		t.Errorf("token without the Bearer scheme: %s, want %s", code, codes.Unauthenticated)
+	}
+
+	entries := config.AuditLog.Entries(0, 0)
+	if len(entries) != 2 || entries[0].Actor != "router" || entries[0].Status != int(codes.PermissionDenied) || entries[1].Actor != "layer2" || entries[1].Status != int(codes.OK) {
+		t.Errorf("audited %+v, want the denied and the allowed topology update", entries)
+	}
+}
@@ -0,0 +1,106 @@
+// Package api implements conversion of public ALM types to and from the alm.proto wire format
+package api
+
+import (
+	"fmt"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/associative"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+// Marshal encodes v as its message in alm.proto, so components in other
+// languages and stored state can share one format. v must be a pointer to
+// one of:
+//
+//	internal.RouteRequest                     RouteRequest
+//	internal.RouteResponse                    RouteResponse
+//	internal.ServiceQuery                     ServiceQuery
+//	internal.ServiceDiscoveryResponse         ServiceDiscoveryResponse
+//	internal.TopologyUpdate                   TopologyUpdate
+//	[]internal.TopologyUpdate                 UpdateTopologyRequest
+//	internal.PerformanceMetrics               PerformanceMetrics
+//	routing.RoutingStats                      RoutingStats
+//	map[string]associative.AssociationExport  AssociationExport
+//
+// PerformanceMetrics carries only the fields in its message.
+func Marshal(v interface{}) ([]byte, error) {
+	msg, err := wrap(v)
+	if err != nil {
+		return nil, err
+	}
+	return msg.appendWire(nil), nil
+}
+
+// Unmarshal decodes data, a message encoded by Marshal or any protobuf
+// library, into v, which takes the types Marshal does. Fields missing from
+// data keep their value in v; repeated fields are appended to.
+func Unmarshal(data []byte, v interface{}) error {
+	msg, err := wrap(v)
+	if err != nil {
+		return err
+	}
+	if err := msg.readWire(data); err != nil {
+		return err
+	}
+
+	// Copy back values held by the wrapper rather than through v
+	switch v := v.(type) {
+	case *internal.RouteRequest:
+		*v = msg.(*routeRequest).RouteRequest
+	case *internal.RouteResponse:
+		*v = msg.(*routeResponse).RouteResponse
+	case *internal.ServiceQuery:
+		*v = msg.(*serviceQuery).ServiceQuery
+	case *internal.ServiceDiscoveryResponse:
+		*v = msg.(*discoveryResponse).ServiceDiscoveryResponse
+	case *internal.TopologyUpdate:
+		*v = msg.(*topologyUpdate).TopologyUpdate
+	case *[]internal.TopologyUpdate:
+		*v = msg.(*updateTopologyRequest).Updates
+	case *internal.PerformanceMetrics:
+		m := msg.(*performanceMetrics)
+		v.AverageRoutingLatency = m.AverageRoutingLatency
+		v.RoutingSuccessRate = m.RoutingSuccessRate
+		v.ServiceDiscoveryLatency = m.ServiceDiscoveryLatency
+		v.CacheHitRate = m.CacheHitRate
+		v.ImprovementFactor = m.ImprovementFactor
+		v.TargetAchievement = m.TargetAchievement
+		v.GraphStats.TotalNodes = m.TotalNodes
+		v.GraphStats.TotalEdges = m.TotalEdges
+		v.Uptime = m.Uptime
+		v.MemoryUsage = m.MemoryUsage
+		v.CPUUsage = m.CPUUsage
+		v.Goroutines = int(m.Goroutines)
+		v.GCPause = m.GCPause
+		v.RoutingStats = m.Routing
+	case *map[string]associative.AssociationExport:
+		*v = msg.(*associationExport).Associations
+	}
+	return nil
+}
+
+// wrap returns the message encoding the value v points to
+func wrap(v interface{}) (message, error) {
+	switch v := v.(type) {
+	case *internal.RouteRequest:
+		return &routeRequest{*v}, nil
+	case *internal.RouteResponse:
+		return &routeResponse{*v}, nil
+	case *internal.ServiceQuery:
+		return &serviceQuery{*v}, nil
+	case *internal.ServiceDiscoveryResponse:
+		return &discoveryResponse{*v}, nil
+	case *internal.TopologyUpdate:
+		return &topologyUpdate{*v}, nil
+	case *[]internal.TopologyUpdate:
+		return &updateTopologyRequest{Updates: *v}, nil
+	case *internal.PerformanceMetrics:
+		return newPerformanceMetrics(v), nil
+	case *routing.RoutingStats:
+		return &routingStats{v}, nil
+	case *map[string]associative.AssociationExport:
+		return &associationExport{Associations: *v}, nil
+	}
+	return nil, fmt.Errorf("no alm.proto message for %T", v)
+}
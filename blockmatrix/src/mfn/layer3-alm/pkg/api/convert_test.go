@@ -0,0 +1,75 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/associative"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+func TestMarshalRoundTripsPublicTypes(t *testing.T) {
+	affinity := associative.AssociationExport{From: 7, To: 3, Type: associative.NodeToService, Weight: 0.8, LastUpdate: time.UnixMicro(1700000000000000)}
+	affinities := map[string]associative.AssociationExport{affinity.Key(): affinity}
+
+	cases := []struct {
+		name  string
+		value interface{}
+		fresh interface{}
+	}{
+		{
+			name:  "RouteRequest",
+			value: &internal.RouteRequest{SourceID: 1, DestinationID: 2, ServiceType: "api", MaxLatency: time.Millisecond, MaxHops: 4},
+			fresh: &internal.RouteRequest{},
+		},
+		{
+			name:  "TopologyUpdates",
+			value: &[]internal.TopologyUpdate{{Type: internal.NodeRemoveUpdate, NodeID: 8}, {Type: internal.EdgeRemoveUpdate, EdgeFrom: 7, EdgeTo: 8}},
+			fresh: &[]internal.TopologyUpdate{},
+		},
+		{
+			name:  "RoutingStats",
+			value: &routing.RoutingStats{TotalLookups: 10, CacheHitRate: 50, AverageLatency: 80 * time.Microsecond, CachedRoutes: 4},
+			fresh: &routing.RoutingStats{},
+		},
+		{
+			name:  "Associations",
+			value: &affinities,
+			fresh: &map[string]associative.AssociationExport{},
+		},
+	}
+
+	for _, tc := range cases {
+		data, err := Marshal(tc.value)
+		if err != nil {
+			t.Fatalf("%s: Marshal: %v", tc.name, err)
+		}
+		if err := Unmarshal(data, tc.fresh); err != nil {
+			t.Fatalf("%s: Unmarshal: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(tc.fresh, tc.value) {
+			t.Errorf("%s: round trip mismatch\n got  %+v\n want %+v", tc.name, tc.fresh, tc.value)
+		}
+	}
+
+	// Metrics keep the fields their message carries
+	metrics := &internal.PerformanceMetrics{CacheHitRate: 75, Goroutines: 12, RoutingStats: routing.RoutingStats{TotalLookups: 3}}
+	metrics.GraphStats.TotalNodes = 9
+	data, err := Marshal(metrics)
+	if err != nil {
+		t.Fatalf("Marshal metrics: %v", err)
+	}
+	var decoded internal.PerformanceMetrics
+	if err := Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal metrics: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, *metrics) {
+		t.Errorf("metrics round trip\n got  %+v\n want %+v", decoded, *metrics)
+	}
+
+	if _, err := Marshal(&struct{}{}); err == nil {
+		t.Error("Marshal accepted a type without a message")
+	}
+}
@@ -0,0 +1,127 @@
+// Package api implements authentication, authorization and auditing of gRPC calls
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/logging"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// methodRoles is the role each gRPC method requires when authentication is
+// enabled. Methods missing here are denied.
+var methodRoles = map[string]Role{
+	"/" + ServiceName + "/FindOptimalRoute":      RoleReadOnly,
+	"/" + ServiceName + "/DiscoverServices":      RoleReadOnly,
+	"/" + ServiceName + "/GetPerformanceMetrics": RoleReadOnly,
+	"/" + ServiceName + "/UpdateNetworkTopology": RoleOperator,
+}
+
+// authorizeCall authenticates and authorizes a call, and records calls
+// requiring operator or above, which change state, in the audit log
+func (s *GRPCServer) authorizeCall(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	role := methodRoles[info.FullMethod]
+
+	var principal Principal
+	var err error
+	if s.config.Authenticator != nil {
+		var creds Credentials
+		if creds, err = grpcCredentials(ctx); err == nil {
+			principal, err = s.config.Authenticator.Authenticate(ctx, creds)
+		}
+		if err == nil {
+			ctx = withPrincipal(ctx, principal)
+			err = authorize(principal, role)
+		}
+		if err != nil {
+			err = authStatus(err)
+			logging.WithContext(ctx, s.logger).Warn("API call denied",
+				zap.String("method", info.FullMethod),
+				zap.String("actor", principal.Name),
+				zap.Error(err),
+			)
+		}
+	}
+
+	var response interface{}
+	if err == nil {
+		response, err = handler(ctx, request)
+	}
+
+	if s.config.AuditLog != nil && role >= RoleOperator {
+		s.audit(ctx, info.FullMethod, principal, err)
+	}
+	return response, err
+}
+
+// audit appends a call to the audit log
+func (s *GRPCServer) audit(ctx context.Context, method string, principal Principal, err error) {
+	entry := AuditEntry{
+		Time:          time.Now(),
+		Actor:         principal.Name,
+		CorrelationID: logging.CorrelationID(ctx),
+		Method:        "GRPC",
+		Path:          method,
+		Status:        int(status.Code(err)),
+	}
+	if entry.Actor == "" {
+		entry.Actor = "anonymous"
+	} else {
+		entry.Role = principal.Role.String()
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		entry.RemoteAddress = p.Addr.String()
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if _, err := s.config.AuditLog.Append(entry); err != nil {
+		logging.WithContext(ctx, s.logger).Error("Failed to record API audit entry",
+			zap.String("method", method),
+			zap.Error(err),
+		)
+	}
+}
+
+// authStatus converts an authentication or authorization error to a gRPC
+// status
+func authStatus(err error) error {
+	switch {
+	case errors.Is(err, ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// grpcCredentials returns the TLS state of a call's connection and the
+// bearer token in its authorization metadata, refusing metadata of any
+// other scheme
+func grpcCredentials(ctx context.Context) (Credentials, error) {
+	var creds Credentials
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			creds.TLS = &info.State
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			token, err := bearerToken(values[0])
+			if err != nil {
+				return creds, err
+			}
+			creds.Token = token
+		}
+	}
+	return creds, nil
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// GRPCClient calls the API of a remote coordinator with the same hand
+// encoded messages the server uses, for Go tools such as benchmarks that
+// drive a real deployment
+type GRPCClient struct {
+	conn *grpc.ClientConn
+}
+
+// GRPCClientConfig configures an API client
+type GRPCClientConfig struct {
+	// Largest request or response message accepted
+	MaxMessageSize int
+
+	// Additional options such as transport credentials; without them the
+	// connection is plaintext
+	DialOptions []grpc.DialOption
+}
+
+// DialGRPC connects to the API server at address. The connection is made
+// lazily, so an unreachable server fails the first call rather than the dial.
+func DialGRPC(address string, config *GRPCClientConfig) (*GRPCClient, error) {
+	if config == nil {
+		config = DefaultGRPCClientConfig()
+	}
+
+	options := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(
+			grpc.ForceCodec(wireCodec{}),
+			grpc.MaxCallRecvMsgSize(config.MaxMessageSize),
+			grpc.MaxCallSendMsgSize(config.MaxMessageSize),
+		),
+	}
+	options = append(options, config.DialOptions...)
+
+	conn, err := grpc.Dial(address, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial API server %s: %w", address, err)
+	}
+	return &GRPCClient{conn: conn}, nil
+}
+
+// FindOptimalRoute calls FindOptimalRoute on the remote coordinator
+func (c *GRPCClient) FindOptimalRoute(ctx context.Context, request internal.RouteRequest) (*internal.RouteResponse, error) {
+	var response routeResponse
+	if err := c.invoke(ctx, "FindOptimalRoute", &routeRequest{request}, &response); err != nil {
+		return nil, err
+	}
+	return &response.RouteResponse, nil
+}
+
+// UpdateNetworkTopology calls UpdateNetworkTopology on the remote
+// coordinator and returns the number of updates it accepted
+func (c *GRPCClient) UpdateNetworkTopology(ctx context.Context, updates []internal.TopologyUpdate) (int, error) {
+	var response updateTopologyResponse
+	if err := c.invoke(ctx, "UpdateNetworkTopology", &updateTopologyRequest{Updates: updates}, &response); err != nil {
+		return 0, err
+	}
+	return response.Submitted, nil
+}
+
+// ImportTopology sends a signed topology policy to the remote coordinator,
+// which verifies it against its trust anchors, and returns the number of
+// updates it accepted
+func (c *GRPCClient) ImportTopology(ctx context.Context, policy internal.SignedPolicy) (int, error) {
+	var response updateTopologyResponse
+	if err := c.invoke(ctx, "UpdateNetworkTopology", &updateTopologyRequest{Signed: &policy}, &response); err != nil {
+		return 0, err
+	}
+	return response.Submitted, nil
+}
+
+// Close closes the connection
+func (c *GRPCClient) Close() error {
+	return c.conn.Close()
+}
+
+// invoke calls method, passing on the correlation ID of ctx if it has one
+func (c *GRPCClient) invoke(ctx context.Context, method string, request, response message) error {
+	if id := logging.CorrelationID(ctx); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, logging.CorrelationMetadataKey, id)
+	}
+	return c.conn.Invoke(ctx, "/"+ServiceName+"/"+method, request, response)
+}
+
+// DefaultGRPCClientConfig returns default API client configuration
+func DefaultGRPCClientConfig() *GRPCClientConfig {
+	return &GRPCClientConfig{
+		MaxMessageSize: 16 * 1024 * 1024,
+	}
+}
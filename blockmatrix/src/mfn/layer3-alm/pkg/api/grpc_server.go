@@ -0,0 +1,299 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/logging"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceName is the fully qualified gRPC service name from alm.proto
+const ServiceName = "hypermesh.alm.v1.ALMCoordinator"
+
+// almServiceDesc describes the ALMCoordinator service; handlers receive the *GRPCServer
+var almServiceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "FindOptimalRoute", Handler: unaryHandler("FindOptimalRoute", (*GRPCServer).findOptimalRoute)},
+		{MethodName: "DiscoverServices", Handler: unaryHandler("DiscoverServices", (*GRPCServer).discoverServices)},
+		{MethodName: "UpdateNetworkTopology", Handler: unaryHandler("UpdateNetworkTopology", (*GRPCServer).updateNetworkTopology)},
+		{MethodName: "GetPerformanceMetrics", Handler: unaryHandler("GetPerformanceMetrics", (*GRPCServer).getPerformanceMetrics)},
+	},
+	Metadata: "alm.proto",
+}
+
+// GRPCServer exposes the ALM coordinator over gRPC so components outside the
+// Go module, such as the Rust node, can route and discover without cgo.
+//
+// Messages follow alm.proto and are served with the standard "proto" codec;
+// clients generate stubs from that file.
+type GRPCServer struct {
+	coordinator *internal.ALMCoordinator
+	config      *GRPCServerConfig
+
+	server   *grpc.Server
+	listener net.Listener
+
+	logger *zap.Logger
+	mutex  sync.Mutex
+}
+
+// GRPCServerConfig configures the API server
+type GRPCServerConfig struct {
+	// Address the server listens on. The API is unauthenticated unless an
+	// Authenticator is set, so the default is loopback only.
+	ListenAddress string
+
+	// Authenticator identifies callers, by the client certificate of a TLS
+	// connection or a bearer token in the authorization metadata, who are
+	// then authorized by the role each method requires; calls it does not
+	// recognize are denied. Add transport credentials to ServerOptions for
+	// mutual TLS.
+	Authenticator Authenticator
+
+	// AuditLog records calls that change state, allowed or denied, when
+	// set; pass the admin server's to keep one log
+	AuditLog *AuditLog
+
+	// Largest request or response message accepted
+	MaxMessageSize int
+
+	// Additional options such as transport credentials
+	ServerOptions []grpc.ServerOption
+}
+
+// NewGRPCServer creates an API server for coordinator
+func NewGRPCServer(coordinator *internal.ALMCoordinator, config *GRPCServerConfig, logger *zap.Logger) *GRPCServer {
+	if config == nil {
+		config = DefaultGRPCServerConfig()
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &GRPCServer{
+		coordinator: coordinator,
+		config:      config,
+		logger:      logger,
+	}
+}
+
+// Start serves the API in the background
+func (s *GRPCServer) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.server != nil {
+		return fmt.Errorf("API server is already running")
+	}
+
+	listener, err := net.Listen("tcp", s.config.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.config.ListenAddress, err)
+	}
+
+	options := []grpc.ServerOption{
+		grpc.ForceServerCodec(wireCodec{}),
+		grpc.MaxRecvMsgSize(s.config.MaxMessageSize),
+		grpc.MaxSendMsgSize(s.config.MaxMessageSize),
+	}
+	if s.config.Authenticator != nil || s.config.AuditLog != nil {
+		options = append(options, grpc.ChainUnaryInterceptor(s.authorizeCall))
+	}
+	options = append(options, s.config.ServerOptions...)
+
+	s.listener = listener
+	s.server = grpc.NewServer(options...)
+	s.server.RegisterService(&almServiceDesc, s)
+
+	go func(server *grpc.Server) {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			s.logger.Error("API server stopped", zap.Error(err))
+		}
+	}(s.server)
+
+	s.logger.Info("API server started", zap.String("address", listener.Addr().String()))
+
+	return nil
+}
+
+// Address returns the address the server is listening on, or "" when stopped
+func (s *GRPCServer) Address() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Shutdown stops accepting calls and waits for in-flight calls to finish.
+// Remaining calls are cancelled when ctx is done.
+func (s *GRPCServer) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	server := s.server
+	s.server = nil
+	s.listener = nil
+	s.mutex.Unlock()
+
+	if server == nil {
+		return nil
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		server.Stop()
+		return ctx.Err()
+	}
+}
+
+func (s *GRPCServer) findOptimalRoute(ctx context.Context, request *routeRequest) (message, error) {
+	if request.MaxHops < 0 || request.MaxLatency < 0 {
+		return nil, status.Error(codes.InvalidArgument, "max_hops and max_latency_us must not be negative")
+	}
+
+	response, err := s.coordinator.FindOptimalRoute(ctx, request.RouteRequest)
+	if err != nil {
+		return nil, errorStatus(err)
+	}
+	return &routeResponse{*response}, nil
+}
+
+func (s *GRPCServer) discoverServices(ctx context.Context, query *serviceQuery) (message, error) {
+	if query.ServiceName == "" && query.ServiceType == "" {
+		return nil, status.Error(codes.InvalidArgument, "service_name or service_type is required")
+	}
+
+	response, err := s.coordinator.DiscoverServices(ctx, query.ServiceQuery)
+	if err != nil {
+		return nil, errorStatus(err)
+	}
+	return &discoveryResponse{*response}, nil
+}
+
+func (s *GRPCServer) updateNetworkTopology(ctx context.Context, request *updateTopologyRequest) (message, error) {
+	if request.Signed != nil {
+		if len(request.Updates) > 0 {
+			return nil, status.Error(codes.InvalidArgument, "updates and signed are mutually exclusive")
+		}
+		if request.Signed.Kind != internal.PolicyKindTopology {
+			return nil, status.Errorf(codes.InvalidArgument, "expected a %s policy, got %q", internal.PolicyKindTopology, request.Signed.Kind)
+		}
+		submitted, err := s.coordinator.ApplySignedPolicy(*request.Signed)
+		if err != nil {
+			return nil, errorStatus(err)
+		}
+		return &updateTopologyResponse{Submitted: submitted}, nil
+	}
+
+	if err := s.coordinator.CheckUnsignedPolicy(internal.PolicyKindTopology); err != nil {
+		return nil, errorStatus(err)
+	}
+	for i, update := range request.Updates {
+		if err := update.Validate(); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "update %d: %v", i, err)
+		}
+	}
+
+	if err := s.coordinator.UpdateNetworkTopology(request.Updates); err != nil {
+		return nil, errorStatus(err)
+	}
+	return &updateTopologyResponse{Submitted: len(request.Updates)}, nil
+}
+
+func (s *GRPCServer) getPerformanceMetrics(ctx context.Context, _ *metricsRequest) (message, error) {
+	metrics := s.coordinator.GetPerformanceMetrics()
+	if metrics == nil {
+		return nil, status.Error(codes.Unavailable, "coordinator is not running")
+	}
+	return newPerformanceMetrics(metrics), nil
+}
+
+// errorStatus converts a coordinator error to a gRPC status
+func errorStatus(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	if errors.Is(err, internal.ErrOverloaded) || errors.Is(err, internal.ErrQuotaExceeded) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if errors.Is(err, internal.ErrPolicyUnsigned) || errors.Is(err, internal.ErrPolicySignature) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	if errors.Is(err, routing.ErrUnknownConstraintTemplate) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// correlate returns ctx with the caller's correlation ID from the incoming
+// metadata, or a new one, and echoes it in the response header
+func correlate(ctx context.Context) context.Context {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(logging.CorrelationMetadataKey); len(ids) > 0 {
+			ctx = logging.WithCorrelationID(ctx, ids[0])
+		}
+	}
+
+	ctx, id := logging.EnsureCorrelationID(ctx)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(logging.CorrelationMetadataKey, id))
+	return ctx
+}
+
+// unaryHandler adapts a typed method to a grpc.MethodDesc handler
+func unaryHandler[Req any, PReq interface {
+	*Req
+	message
+}](method string, handle func(*GRPCServer, context.Context, PReq) (message, error)) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	fullMethod := "/" + ServiceName + "/" + method
+
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		server := srv.(*GRPCServer)
+
+		request := PReq(new(Req))
+		if err := dec(request); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		ctx = correlate(ctx)
+
+		call := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return handle(server, ctx, req.(PReq))
+		}
+
+		if interceptor == nil {
+			return call(ctx, request)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}
+		return interceptor(ctx, request, info, call)
+	}
+}
+
+// DefaultGRPCServerConfig returns default API server configuration
+func DefaultGRPCServerConfig() *GRPCServerConfig {
+	return &GRPCServerConfig{
+		ListenAddress:  "127.0.0.1:7443",
+		MaxMessageSize: 16 * 1024 * 1024,
+	}
+}
@@ -0,0 +1,808 @@
+package api
+
+import (
+	"sort"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/associative"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+// API messages wrap the coordinator types and encode them as the messages of
+// the same name in alm.proto. Durations travel as microseconds, and times as
+// microseconds since the Unix epoch with the zero time as 0.
+
+func micros(d time.Duration) int64 {
+	return d.Microseconds()
+}
+
+func fromMicros(us int64) time.Duration {
+	return time.Duration(us) * time.Microsecond
+}
+
+func unixMicros(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMicro()
+}
+
+func fromUnixMicros(us int64) time.Time {
+	if us == 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(us)
+}
+
+// routeRequest is the RouteRequest message
+type routeRequest struct {
+	internal.RouteRequest
+}
+
+func (m *routeRequest) appendWire(b []byte) []byte {
+	b = appendInt64(b, 1, m.SourceID)
+	b = appendInt64(b, 2, m.DestinationID)
+	b = appendString(b, 3, m.ServiceType)
+	b = appendInt32(b, 4, int32(m.QoSClass))
+	b = appendInt64(b, 5, micros(m.MaxLatency))
+	b = appendDouble(b, 6, m.MinThroughput)
+	b = appendDouble(b, 7, m.MinReliability)
+	b = appendDouble(b, 8, m.MaxCost)
+	b = appendInt32(b, 9, int32(m.MaxHops))
+	b = appendString(b, 10, m.ConstraintTemplate)
+	b = appendInt32(b, 11, int32(m.Disjoint))
+	return appendInt32(b, 12, int32(m.DisjointPaths))
+}
+
+func (m *routeRequest) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		switch field.num {
+		case 1:
+			m.SourceID = field.int64()
+		case 2:
+			m.DestinationID = field.int64()
+		case 3:
+			m.ServiceType = field.string()
+		case 4:
+			m.QoSClass = int(field.int32())
+		case 5:
+			m.MaxLatency = fromMicros(field.int64())
+		case 6:
+			m.MinThroughput = field.double()
+		case 7:
+			m.MinReliability = field.double()
+		case 8:
+			m.MaxCost = field.double()
+		case 9:
+			m.MaxHops = int(field.int32())
+		case 10:
+			m.ConstraintTemplate = field.string()
+		case 11:
+			m.Disjoint = int(field.int32())
+		case 12:
+			m.DisjointPaths = int(field.int32())
+		}
+	})
+}
+
+// alternativeRoute is the AlternativeRoute message
+type alternativeRoute struct {
+	internal.AlternativeRoute
+}
+
+func (m *alternativeRoute) appendWire(b []byte) []byte {
+	b = appendInt64s(b, 1, m.Path)
+	b = appendInt64(b, 2, micros(m.Latency))
+	b = appendDouble(b, 3, m.Throughput)
+	b = appendDouble(b, 4, m.Reliability)
+	b = appendDouble(b, 5, m.Cost)
+	b = appendDouble(b, 6, m.Score)
+	return appendDouble(b, 7, m.Weight)
+}
+
+func (m *alternativeRoute) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		switch field.num {
+		case 1:
+			m.Path = field.int64s(m.Path)
+		case 2:
+			m.Latency = fromMicros(field.int64())
+		case 3:
+			m.Throughput = field.double()
+		case 4:
+			m.Reliability = field.double()
+		case 5:
+			m.Cost = field.double()
+		case 6:
+			m.Score = field.double()
+		case 7:
+			m.Weight = field.double()
+		}
+	})
+}
+
+// routeResponse is the RouteResponse message
+type routeResponse struct {
+	internal.RouteResponse
+}
+
+func (m *routeResponse) appendWire(b []byte) []byte {
+	b = appendInt64s(b, 1, m.Path)
+	b = appendInt64(b, 2, micros(m.TotalLatency))
+	b = appendDouble(b, 3, m.MinThroughput)
+	b = appendDouble(b, 4, m.AvgReliability)
+	b = appendDouble(b, 5, m.TotalCost)
+	b = appendInt32(b, 6, int32(m.HopCount))
+	b = appendDouble(b, 7, m.QualityScore)
+	b = appendInt64(b, 8, micros(m.SearchTime))
+	b = appendBool(b, 9, m.CacheHit)
+	b = appendDouble(b, 10, m.Confidence)
+	for i := range m.Alternatives {
+		b = appendMessage(b, 11, &alternativeRoute{m.Alternatives[i]})
+	}
+	b = appendDouble(b, 12, m.Weight)
+	b = appendString(b, 13, m.FailedRegion)
+	b = appendString(b, 14, m.FailoverRegion)
+	b = appendInt64(b, 15, m.FailoverDestination)
+	return appendDouble(b, 16, m.Diversity)
+}
+
+func (m *routeResponse) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		switch field.num {
+		case 1:
+			m.Path = field.int64s(m.Path)
+		case 2:
+			m.TotalLatency = fromMicros(field.int64())
+		case 3:
+			m.MinThroughput = field.double()
+		case 4:
+			m.AvgReliability = field.double()
+		case 5:
+			m.TotalCost = field.double()
+		case 6:
+			m.HopCount = int(field.int32())
+		case 7:
+			m.QualityScore = field.double()
+		case 8:
+			m.SearchTime = fromMicros(field.int64())
+		case 9:
+			m.CacheHit = field.bool()
+		case 10:
+			m.Confidence = field.double()
+		case 11:
+			var alternative alternativeRoute
+			field.message(&alternative)
+			m.Alternatives = append(m.Alternatives, alternative.AlternativeRoute)
+		case 12:
+			m.Weight = field.double()
+		case 13:
+			m.FailedRegion = field.string()
+		case 14:
+			m.FailoverRegion = field.string()
+		case 15:
+			m.FailoverDestination = field.int64()
+		case 16:
+			m.Diversity = field.double()
+		}
+	})
+}
+
+// serviceQuery is the ServiceQuery message
+type serviceQuery struct {
+	internal.ServiceQuery
+}
+
+func (m *serviceQuery) appendWire(b []byte) []byte {
+	b = appendString(b, 1, m.ServiceName)
+	b = appendString(b, 2, m.ServiceType)
+	b = appendString(b, 3, m.Version)
+	b = appendStringMap(b, 4, m.RequiredTags)
+	b = appendStrings(b, 5, m.Capabilities)
+	b = appendStrings(b, 6, m.PreferredRegions)
+	b = appendInt64(b, 7, m.SourceNodeID)
+	b = appendDouble(b, 8, m.MaxDistance)
+	b = appendDouble(b, 9, m.MinHealthScore)
+	b = appendInt64(b, 10, micros(m.MaxResponseTime))
+	b = appendDouble(b, 11, m.MinThroughput)
+	b = appendBool(b, 12, m.IncludeDegraded)
+	b = appendInt32(b, 13, int32(m.MaxResults))
+	b = appendInt32(b, 14, int32(m.SortBy))
+	return appendString(b, 15, m.AffinityKey)
+}
+
+func (m *serviceQuery) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		switch field.num {
+		case 1:
+			m.ServiceName = field.string()
+		case 2:
+			m.ServiceType = field.string()
+		case 3:
+			m.Version = field.string()
+		case 4:
+			if m.RequiredTags == nil {
+				m.RequiredTags = make(map[string]string)
+			}
+			field.stringMapEntry(m.RequiredTags)
+		case 5:
+			m.Capabilities = append(m.Capabilities, field.string())
+		case 6:
+			m.PreferredRegions = append(m.PreferredRegions, field.string())
+		case 7:
+			m.SourceNodeID = field.int64()
+		case 8:
+			m.MaxDistance = field.double()
+		case 9:
+			m.MinHealthScore = field.double()
+		case 10:
+			m.MaxResponseTime = fromMicros(field.int64())
+		case 11:
+			m.MinThroughput = field.double()
+		case 12:
+			m.IncludeDegraded = field.bool()
+		case 13:
+			m.MaxResults = int(field.int32())
+		case 14:
+			m.SortBy = int(field.int32())
+		case 15:
+			m.AffinityKey = field.string()
+		}
+	})
+}
+
+// discoveredService is the DiscoveredService message
+type discoveredService struct {
+	internal.DiscoveredService
+}
+
+func (m *discoveredService) appendWire(b []byte) []byte {
+	b = appendString(b, 1, m.ServiceID)
+	b = appendString(b, 2, m.Name)
+	b = appendInt64(b, 3, m.NodeID)
+	b = appendString(b, 4, m.Address)
+	b = appendInt32(b, 5, int32(m.Port))
+	b = appendDouble(b, 6, m.HealthScore)
+	b = appendInt64(b, 7, micros(m.ResponseTime))
+	b = appendInt32(b, 8, int32(m.Rank))
+	b = appendDouble(b, 9, m.Score)
+	return appendDouble(b, 10, m.Distance)
+}
+
+func (m *discoveredService) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		switch field.num {
+		case 1:
+			m.ServiceID = field.string()
+		case 2:
+			m.Name = field.string()
+		case 3:
+			m.NodeID = field.int64()
+		case 4:
+			m.Address = field.string()
+		case 5:
+			m.Port = int(field.int32())
+		case 6:
+			m.HealthScore = field.double()
+		case 7:
+			m.ResponseTime = fromMicros(field.int64())
+		case 8:
+			m.Rank = int(field.int32())
+		case 9:
+			m.Score = field.double()
+		case 10:
+			m.Distance = field.double()
+		}
+	})
+}
+
+// discoveryResponse is the ServiceDiscoveryResponse message
+type discoveryResponse struct {
+	internal.ServiceDiscoveryResponse
+}
+
+func (m *discoveryResponse) appendWire(b []byte) []byte {
+	for i := range m.Services {
+		b = appendMessage(b, 1, &discoveredService{m.Services[i]})
+	}
+	b = appendInt32(b, 2, int32(m.TotalFound))
+	b = appendInt64(b, 3, micros(m.QueryTime))
+	b = appendBool(b, 4, m.CacheHit)
+	b = appendDouble(b, 5, m.AverageHealth)
+	b = appendInt64(b, 6, micros(m.AverageLatency))
+	b = appendDouble(b, 7, m.GeographicSpread)
+	return appendInt64(b, 8, micros(m.SearchTime))
+}
+
+func (m *discoveryResponse) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		switch field.num {
+		case 1:
+			var service discoveredService
+			field.message(&service)
+			m.Services = append(m.Services, service.DiscoveredService)
+		case 2:
+			m.TotalFound = int(field.int32())
+		case 3:
+			m.QueryTime = fromMicros(field.int64())
+		case 4:
+			m.CacheHit = field.bool()
+		case 5:
+			m.AverageHealth = field.double()
+		case 6:
+			m.AverageLatency = fromMicros(field.int64())
+		case 7:
+			m.GeographicSpread = field.double()
+		case 8:
+			m.SearchTime = fromMicros(field.int64())
+		}
+	})
+}
+
+// networkNode is the NetworkNode message
+type networkNode struct {
+	*graph.NetworkNode
+}
+
+func (m *networkNode) appendWire(b []byte) []byte {
+	b = appendInt64(b, 1, m.ID)
+	b = appendString(b, 2, m.Address)
+	b = appendString(b, 3, m.Region)
+	b = appendDouble(b, 4, m.Latitude)
+	b = appendDouble(b, 5, m.Longitude)
+	b = appendStrings(b, 6, m.Capabilities)
+	return appendString(b, 7, m.Provider)
+}
+
+func (m *networkNode) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		switch field.num {
+		case 1:
+			m.ID = field.int64()
+		case 2:
+			m.Address = field.string()
+		case 3:
+			m.Region = field.string()
+		case 4:
+			m.Latitude = field.double()
+		case 5:
+			m.Longitude = field.double()
+		case 6:
+			m.Capabilities = append(m.Capabilities, field.string())
+		case 7:
+			m.Provider = field.string()
+		}
+	})
+}
+
+// networkEdge is the NetworkEdge message
+type networkEdge struct {
+	*graph.NetworkEdge
+}
+
+func (m *networkEdge) appendWire(b []byte) []byte {
+	b = appendInt64(b, 1, m.From)
+	b = appendInt64(b, 2, m.To)
+	b = appendDouble(b, 3, m.Weight)
+	b = appendInt64(b, 4, micros(m.Latency))
+	b = appendDouble(b, 5, m.Bandwidth)
+	b = appendDouble(b, 6, m.PacketLoss)
+	b = appendInt64(b, 7, micros(m.Jitter))
+	b = appendDouble(b, 8, m.Cost)
+	b = appendDouble(b, 9, m.Reliability)
+	return appendDouble(b, 10, m.Stability)
+}
+
+func (m *networkEdge) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		switch field.num {
+		case 1:
+			m.From = field.int64()
+		case 2:
+			m.To = field.int64()
+		case 3:
+			m.Weight = field.double()
+		case 4:
+			m.Latency = fromMicros(field.int64())
+		case 5:
+			m.Bandwidth = field.double()
+		case 6:
+			m.PacketLoss = field.double()
+		case 7:
+			m.Jitter = fromMicros(field.int64())
+		case 8:
+			m.Cost = field.double()
+		case 9:
+			m.Reliability = field.double()
+		case 10:
+			m.Stability = field.double()
+		}
+	})
+}
+
+// nodeMetrics is the NodeMetrics message
+type nodeMetrics struct {
+	*graph.NodeMetrics
+}
+
+func (m *nodeMetrics) appendWire(b []byte) []byte {
+	b = appendInt64(b, 1, micros(m.Latency))
+	b = appendDouble(b, 2, m.Throughput)
+	b = appendDouble(b, 3, m.Reliability)
+	return appendDouble(b, 4, m.LoadFactor)
+}
+
+func (m *nodeMetrics) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		switch field.num {
+		case 1:
+			m.Latency = fromMicros(field.int64())
+		case 2:
+			m.Throughput = field.double()
+		case 3:
+			m.Reliability = field.double()
+		case 4:
+			m.LoadFactor = field.double()
+		}
+	})
+}
+
+// edgeMetrics is the EdgeMetrics message
+type edgeMetrics struct {
+	*graph.EdgeMetrics
+}
+
+func (m *edgeMetrics) appendWire(b []byte) []byte {
+	b = appendInt64(b, 1, micros(m.Latency))
+	b = appendDouble(b, 2, m.Bandwidth)
+	b = appendDouble(b, 3, m.PacketLoss)
+	b = appendInt64(b, 4, micros(m.Jitter))
+	return appendDouble(b, 5, m.Reliability)
+}
+
+func (m *edgeMetrics) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		switch field.num {
+		case 1:
+			m.Latency = fromMicros(field.int64())
+		case 2:
+			m.Bandwidth = field.double()
+		case 3:
+			m.PacketLoss = field.double()
+		case 4:
+			m.Jitter = fromMicros(field.int64())
+		case 5:
+			m.Reliability = field.double()
+		}
+	})
+}
+
+// topologyUpdate is the TopologyUpdate message
+type topologyUpdate struct {
+	internal.TopologyUpdate
+}
+
+func (m *topologyUpdate) appendWire(b []byte) []byte {
+	b = appendInt32(b, 1, int32(m.Type))
+	if m.Node != nil {
+		b = appendMessage(b, 2, &networkNode{m.Node})
+	}
+	b = appendInt64(b, 3, m.NodeID)
+	if m.Edge != nil {
+		b = appendMessage(b, 4, &networkEdge{m.Edge})
+	}
+	b = appendInt64(b, 5, m.EdgeFrom)
+	b = appendInt64(b, 6, m.EdgeTo)
+	if m.Type == internal.MetricsUpdate {
+		b = appendMessage(b, 7, &nodeMetrics{&m.Metrics})
+	}
+	if m.Type == internal.EdgeMetricsUpdate {
+		b = appendMessage(b, 8, &edgeMetrics{&m.EdgeMetrics})
+	}
+	return b
+}
+
+func (m *topologyUpdate) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		switch field.num {
+		case 1:
+			m.Type = internal.TopologyUpdateType(field.int32())
+		case 2:
+			m.Node = &graph.NetworkNode{Services: make(map[string]graph.ServiceInfo)}
+			field.message(&networkNode{m.Node})
+		case 3:
+			m.NodeID = field.int64()
+		case 4:
+			m.Edge = &graph.NetworkEdge{}
+			field.message(&networkEdge{m.Edge})
+		case 5:
+			m.EdgeFrom = field.int64()
+		case 6:
+			m.EdgeTo = field.int64()
+		case 7:
+			field.message(&nodeMetrics{&m.Metrics})
+		case 8:
+			field.message(&edgeMetrics{&m.EdgeMetrics})
+		}
+	})
+}
+
+// signedPolicy is the SignedPolicy message
+type signedPolicy struct {
+	*internal.SignedPolicy
+}
+
+func (m *signedPolicy) appendWire(b []byte) []byte {
+	b = appendString(b, 1, m.Kind)
+	b = appendString(b, 2, m.KeyID)
+	b = appendInt64(b, 3, m.Expires)
+	b = appendBytes(b, 4, m.Payload)
+	return appendBytes(b, 5, m.Signature)
+}
+
+func (m *signedPolicy) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		switch field.num {
+		case 1:
+			m.Kind = field.string()
+		case 2:
+			m.KeyID = field.string()
+		case 3:
+			m.Expires = field.int64()
+		case 4:
+			m.Payload = append([]byte(nil), field.bytes()...)
+		case 5:
+			m.Signature = append([]byte(nil), field.bytes()...)
+		}
+	})
+}
+
+// updateTopologyRequest is the UpdateTopologyRequest message
+type updateTopologyRequest struct {
+	Updates []internal.TopologyUpdate
+
+	// A signed topology import, sent instead of Updates
+	Signed *internal.SignedPolicy
+}
+
+func (m *updateTopologyRequest) appendWire(b []byte) []byte {
+	for i := range m.Updates {
+		b = appendMessage(b, 1, &topologyUpdate{m.Updates[i]})
+	}
+	if m.Signed != nil {
+		b = appendMessage(b, 2, &signedPolicy{m.Signed})
+	}
+	return b
+}
+
+func (m *updateTopologyRequest) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		switch field.num {
+		case 1:
+			var update topologyUpdate
+			field.message(&update)
+			m.Updates = append(m.Updates, update.TopologyUpdate)
+		case 2:
+			m.Signed = &internal.SignedPolicy{}
+			field.message(&signedPolicy{m.Signed})
+		}
+	})
+}
+
+// updateTopologyResponse is the UpdateTopologyResponse message
+type updateTopologyResponse struct {
+	Submitted int
+}
+
+func (m *updateTopologyResponse) appendWire(b []byte) []byte {
+	return appendInt32(b, 1, int32(m.Submitted))
+}
+
+func (m *updateTopologyResponse) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		if field.num == 1 {
+			m.Submitted = int(field.int32())
+		}
+	})
+}
+
+// metricsRequest is the empty GetPerformanceMetricsRequest message
+type metricsRequest struct{}
+
+func (m *metricsRequest) appendWire(b []byte) []byte {
+	return b
+}
+
+func (m *metricsRequest) readWire(b []byte) error {
+	return readFields(b, func(*fieldReader) {})
+}
+
+// performanceMetrics is the PerformanceMetrics message
+type performanceMetrics struct {
+	AverageRoutingLatency   time.Duration
+	RoutingSuccessRate      float64
+	ServiceDiscoveryLatency time.Duration
+	CacheHitRate            float64
+	ImprovementFactor       float64
+	TargetAchievement       float64
+	TotalNodes              int64
+	TotalEdges              int64
+	Uptime                  time.Duration
+	MemoryUsage             int64
+	CPUUsage                float64
+	Goroutines              int64
+	GCPause                 time.Duration
+	Routing                 routing.RoutingStats
+}
+
+func newPerformanceMetrics(metrics *internal.PerformanceMetrics) *performanceMetrics {
+	return &performanceMetrics{
+		AverageRoutingLatency:   metrics.AverageRoutingLatency,
+		RoutingSuccessRate:      metrics.RoutingSuccessRate,
+		ServiceDiscoveryLatency: metrics.ServiceDiscoveryLatency,
+		CacheHitRate:            metrics.CacheHitRate,
+		ImprovementFactor:       metrics.ImprovementFactor,
+		TargetAchievement:       metrics.TargetAchievement,
+		TotalNodes:              metrics.GraphStats.TotalNodes,
+		TotalEdges:              metrics.GraphStats.TotalEdges,
+		Uptime:                  metrics.Uptime,
+		MemoryUsage:             metrics.MemoryUsage,
+		CPUUsage:                metrics.CPUUsage,
+		Goroutines:              int64(metrics.Goroutines),
+		GCPause:                 metrics.GCPause,
+		Routing:                 metrics.RoutingStats,
+	}
+}
+
+func (m *performanceMetrics) appendWire(b []byte) []byte {
+	b = appendInt64(b, 1, micros(m.AverageRoutingLatency))
+	b = appendDouble(b, 2, m.RoutingSuccessRate)
+	b = appendInt64(b, 3, micros(m.ServiceDiscoveryLatency))
+	b = appendDouble(b, 4, m.CacheHitRate)
+	b = appendDouble(b, 5, m.ImprovementFactor)
+	b = appendDouble(b, 6, m.TargetAchievement)
+	b = appendInt64(b, 7, m.TotalNodes)
+	b = appendInt64(b, 8, m.TotalEdges)
+	b = appendInt64(b, 9, micros(m.Uptime))
+	b = appendInt64(b, 10, m.MemoryUsage)
+	b = appendDouble(b, 11, m.CPUUsage)
+	b = appendInt64(b, 12, m.Goroutines)
+	b = appendInt64(b, 13, micros(m.GCPause))
+	return appendMessage(b, 14, &routingStats{&m.Routing})
+}
+
+func (m *performanceMetrics) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		switch field.num {
+		case 1:
+			m.AverageRoutingLatency = fromMicros(field.int64())
+		case 2:
+			m.RoutingSuccessRate = field.double()
+		case 3:
+			m.ServiceDiscoveryLatency = fromMicros(field.int64())
+		case 4:
+			m.CacheHitRate = field.double()
+		case 5:
+			m.ImprovementFactor = field.double()
+		case 6:
+			m.TargetAchievement = field.double()
+		case 7:
+			m.TotalNodes = field.int64()
+		case 8:
+			m.TotalEdges = field.int64()
+		case 9:
+			m.Uptime = fromMicros(field.int64())
+		case 10:
+			m.MemoryUsage = field.int64()
+		case 11:
+			m.CPUUsage = field.double()
+		case 12:
+			m.Goroutines = field.int64()
+		case 13:
+			m.GCPause = fromMicros(field.int64())
+		case 14:
+			field.message(&routingStats{&m.Routing})
+		}
+	})
+}
+
+// routingStats is the RoutingStats message
+type routingStats struct {
+	*routing.RoutingStats
+}
+
+func (m *routingStats) appendWire(b []byte) []byte {
+	b = appendInt64(b, 1, m.TotalLookups)
+	b = appendDouble(b, 2, m.CacheHitRate)
+	b = appendInt64(b, 3, micros(m.AverageLatency))
+	b = appendDouble(b, 4, m.SuccessRate)
+	b = appendInt32(b, 5, int32(m.CachedRoutes))
+	b = appendDouble(b, 6, m.InvalidationRate)
+	return appendDouble(b, 7, m.LoadBalanceRate)
+}
+
+func (m *routingStats) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		switch field.num {
+		case 1:
+			m.TotalLookups = field.int64()
+		case 2:
+			m.CacheHitRate = field.double()
+		case 3:
+			m.AverageLatency = fromMicros(field.int64())
+		case 4:
+			m.SuccessRate = field.double()
+		case 5:
+			m.CachedRoutes = int(field.int32())
+		case 6:
+			m.InvalidationRate = field.double()
+		case 7:
+			m.LoadBalanceRate = field.double()
+		}
+	})
+}
+
+// association is the Association message
+type association struct {
+	associative.AssociationExport
+}
+
+func (m *association) appendWire(b []byte) []byte {
+	b = appendInt64(b, 1, m.From)
+	b = appendInt64(b, 2, m.To)
+	b = appendInt32(b, 3, int32(m.Type))
+	b = appendDouble(b, 4, m.Weight)
+	return appendInt64(b, 5, unixMicros(m.LastUpdate))
+}
+
+func (m *association) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		switch field.num {
+		case 1:
+			m.From = field.int64()
+		case 2:
+			m.To = field.int64()
+		case 3:
+			m.Type = associative.AssociationType(field.int32())
+		case 4:
+			m.Weight = field.double()
+		case 5:
+			m.LastUpdate = fromUnixMicros(field.int64())
+		}
+	})
+}
+
+// associationExport is the AssociationExport message. Associations are
+// written in key order so encodings are deterministic, and keyed by
+// AssociationExport.Key when read.
+type associationExport struct {
+	Associations map[string]associative.AssociationExport
+}
+
+func (m *associationExport) appendWire(b []byte) []byte {
+	keys := make([]string, 0, len(m.Associations))
+	for key := range m.Associations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		b = appendMessage(b, 1, &association{m.Associations[key]})
+	}
+	return b
+}
+
+func (m *associationExport) readWire(b []byte) error {
+	return readFields(b, func(field *fieldReader) {
+		if field.num == 1 {
+			var entry association
+			field.message(&entry)
+			if m.Associations == nil {
+				m.Associations = make(map[string]associative.AssociationExport)
+			}
+			m.Associations[entry.Key()] = entry.AssociationExport
+		}
+	})
+}
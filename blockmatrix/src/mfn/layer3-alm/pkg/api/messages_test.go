@@ -0,0 +1,352 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/associative"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+// compileALMProto builds the message descriptors from alm.proto
+func compileALMProto(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{}),
+	}
+	files, err := compiler.Compile(context.Background(), "alm.proto")
+	if err != nil {
+		t.Fatalf("failed to compile alm.proto: %v", err)
+	}
+	return files[0]
+}
+
+// TestMessagesMatchProto decodes every hand-encoded message with the
+// descriptor from alm.proto, checks the decoded field values, and re-encodes
+// the descriptor's view back through readWire
+func TestMessagesMatchProto(t *testing.T) {
+	file := compileALMProto(t)
+
+	node := &graph.NetworkNode{
+		ID:           7,
+		Address:      "10.0.0.7:9000",
+		Region:       "eu-west-1",
+		Provider:     "aws",
+		Latitude:     52.5,
+		Longitude:    13.25,
+		Services:     make(map[string]graph.ServiceInfo),
+		Capabilities: []string{"storage.object", "compute.gpu"},
+	}
+	edge := &graph.NetworkEdge{
+		From:        7,
+		To:          8,
+		Weight:      1.5,
+		Latency:     3 * time.Millisecond,
+		Bandwidth:   940,
+		PacketLoss:  0.01,
+		Jitter:      250 * time.Microsecond,
+		Cost:        2.5,
+		Reliability: 0.995,
+		Stability:   0.9,
+	}
+
+	cases := []struct {
+		name    string
+		message message
+		fresh   func() message
+		want    string
+	}{
+		{
+			name: "RouteRequest",
+			message: &routeRequest{internal.RouteRequest{
+				SourceID:       1,
+				DestinationID:  2,
+				ServiceType:    "api",
+				QoSClass:       3,
+				MaxLatency:     5 * time.Millisecond,
+				MinThroughput:  100.5,
+				MinReliability: 0.99,
+				MaxCost:        12.5,
+				MaxHops:        6,
+
+				ConstraintTemplate: "payments",
+				Disjoint:           2,
+				DisjointPaths:      3,
+			}},
+			fresh: func() message { return &routeRequest{} },
+			want: `{"source_id":"1","destination_id":"2","service_type":"api","qos_class":3,"max_latency_us":"5000",
+				"min_throughput":100.5,"min_reliability":0.99,"max_cost":12.5,"max_hops":6,"constraint_template":"payments",
+				"disjoint":"DISJOINT_MODE_NODE","disjoint_paths":3}`,
+		},
+		{
+			name: "RouteResponse",
+			message: &routeResponse{internal.RouteResponse{
+				Path:           []int64{1, 4, 2},
+				TotalLatency:   1200 * time.Microsecond,
+				MinThroughput:  50,
+				AvgReliability: 0.98,
+				TotalCost:      3.5,
+				HopCount:       2,
+				QualityScore:   0.87,
+				SearchTime:     40 * time.Microsecond,
+				CacheHit:       true,
+				Confidence:     0.75,
+				Alternatives: []internal.AlternativeRoute{{
+					Path:        []int64{1, 5, 2},
+					Latency:     2 * time.Millisecond,
+					Throughput:  40,
+					Reliability: 0.97,
+					Cost:        4,
+					Score:       0.6,
+					Weight:      0.25,
+				}},
+				Weight:              0.75,
+				FailedRegion:        "us-east",
+				FailoverRegion:      "us-west",
+				FailoverDestination: 2,
+				Diversity:           0.5,
+			}},
+			fresh: func() message { return &routeResponse{} },
+			want: `{"path":["1","4","2"],"total_latency_us":"1200","min_throughput":50,"avg_reliability":0.98,
+				"total_cost":3.5,"hop_count":2,"quality_score":0.87,"search_time_us":"40","cache_hit":true,"confidence":0.75,
+				"alternatives":[{"path":["1","5","2"],"latency_us":"2000","throughput":40,"reliability":0.97,"cost":4,"score":0.6,"weight":0.25}],
+				"weight":0.75,"failed_region":"us-east","failover_region":"us-west","failover_destination":"2","diversity":0.5}`,
+		},
+		{
+			name: "ServiceQuery",
+			message: &serviceQuery{internal.ServiceQuery{
+				ServiceName:      "search",
+				ServiceType:      "api",
+				Version:          "v2",
+				RequiredTags:     map[string]string{"tier": "gold", "zone": "a"},
+				Capabilities:     []string{"storage.object"},
+				PreferredRegions: []string{"eu-west-1", "us-east-1"},
+				SourceNodeID:     9,
+				MaxDistance:      250.5,
+				MinHealthScore:   0.8,
+				MaxResponseTime:  15 * time.Millisecond,
+				MinThroughput:    10,
+				IncludeDegraded:  true,
+				MaxResults:       5,
+				SortBy:           2,
+				AffinityKey:      "session-1",
+			}},
+			fresh: func() message { return &serviceQuery{} },
+			want: `{"service_name":"search","service_type":"api","version":"v2","required_tags":{"tier":"gold","zone":"a"},
+				"capabilities":["storage.object"],"preferred_regions":["eu-west-1","us-east-1"],"source_node_id":"9",
+				"max_distance":250.5,"min_health_score":0.8,"max_response_time_us":"15000","min_throughput":10,
+				"include_degraded":true,"max_results":5,"sort_by":2,"affinity_key":"session-1"}`,
+		},
+		{
+			name: "ServiceDiscoveryResponse",
+			message: &discoveryResponse{internal.ServiceDiscoveryResponse{
+				Services: []internal.DiscoveredService{{
+					ServiceID:    "search-1",
+					Name:         "search",
+					NodeID:       7,
+					Address:      "10.0.0.7",
+					Port:         8443,
+					HealthScore:  0.95,
+					ResponseTime: 800 * time.Microsecond,
+					Rank:         1,
+					Score:        0.91,
+					Distance:     12.5,
+				}},
+				TotalFound:       1,
+				QueryTime:        90 * time.Microsecond,
+				CacheHit:         true,
+				AverageHealth:    0.95,
+				AverageLatency:   800 * time.Microsecond,
+				GeographicSpread: 0.5,
+				SearchTime:       60 * time.Microsecond,
+			}},
+			fresh: func() message { return &discoveryResponse{} },
+			want: `{"services":[{"service_id":"search-1","name":"search","node_id":"7","address":"10.0.0.7","port":8443,
+				"health_score":0.95,"response_time_us":"800","rank":1,"score":0.91,"distance":12.5}],"total_found":1,
+				"query_time_us":"90","cache_hit":true,"average_health":0.95,"average_latency_us":"800",
+				"geographic_spread":0.5,"search_time_us":"60"}`,
+		},
+		{
+			name: "UpdateTopologyRequest",
+			message: &updateTopologyRequest{Updates: []internal.TopologyUpdate{
+				{Type: internal.NodeAddUpdate, Node: node},
+				{Type: internal.NodeRemoveUpdate, NodeID: 8},
+				{Type: internal.EdgeAddUpdate, Edge: edge},
+				{Type: internal.EdgeRemoveUpdate, EdgeFrom: 7, EdgeTo: 8},
+				{Type: internal.MetricsUpdate, NodeID: 7, Metrics: graph.NodeMetrics{
+					Latency: time.Millisecond, Throughput: 500, Reliability: 0.99, LoadFactor: 0.4,
+				}},
+				{Type: internal.EdgeMetricsUpdate, EdgeFrom: 7, EdgeTo: 8, EdgeMetrics: graph.EdgeMetrics{
+					Latency: 2 * time.Millisecond, Bandwidth: 900, PacketLoss: 0.02, Jitter: 100 * time.Microsecond, Reliability: 0.98,
+				}},
+			}},
+			fresh: func() message { return &updateTopologyRequest{} },
+			want: `{"updates":[
+				{"node":{"id":"7","address":"10.0.0.7:9000","region":"eu-west-1","latitude":52.5,"longitude":13.25,
+					"capabilities":["storage.object","compute.gpu"],"provider":"aws"}},
+				{"type":"TOPOLOGY_UPDATE_TYPE_NODE_REMOVE","node_id":"8"},
+				{"type":"TOPOLOGY_UPDATE_TYPE_EDGE_ADD","edge":{"from":"7","to":"8","weight":1.5,"latency_us":"3000",
+					"bandwidth":940,"packet_loss":0.01,"jitter_us":"250","cost":2.5,"reliability":0.995,"stability":0.9}},
+				{"type":"TOPOLOGY_UPDATE_TYPE_EDGE_REMOVE","edge_from":"7","edge_to":"8"},
+				{"type":"TOPOLOGY_UPDATE_TYPE_METRICS","node_id":"7","metrics":{"latency_us":"1000","throughput":500,
+					"reliability":0.99,"load_factor":0.4}},
+				{"type":"TOPOLOGY_UPDATE_TYPE_EDGE_METRICS","edge_from":"7","edge_to":"8","edge_metrics":{"latency_us":"2000",
+					"bandwidth":900,"packet_loss":0.02,"jitter_us":"100","reliability":0.98}}]}`,
+		},
+		{
+			name: "SignedPolicy",
+			message: &signedPolicy{&internal.SignedPolicy{
+				Kind:      internal.PolicyKindTopology,
+				KeyID:     "ops-2026",
+				Expires:   1700000000,
+				Payload:   []byte("[]"),
+				Signature: []byte{1, 2, 3},
+			}},
+			fresh: func() message { return &signedPolicy{&internal.SignedPolicy{}} },
+			want:  `{"kind":"topology","key_id":"ops-2026","expires":"1700000000","payload":"W10=","signature":"AQID"}`,
+		},
+		{
+			name:    "UpdateTopologyResponse",
+			message: &updateTopologyResponse{Submitted: 6},
+			fresh:   func() message { return &updateTopologyResponse{} },
+			want:    `{"submitted":6}`,
+		},
+		{
+			name:    "GetPerformanceMetricsRequest",
+			message: &metricsRequest{},
+			fresh:   func() message { return &metricsRequest{} },
+			want:    `{}`,
+		},
+		{
+			name: "PerformanceMetrics",
+			message: &performanceMetrics{
+				AverageRoutingLatency:   300 * time.Microsecond,
+				RoutingSuccessRate:      99.5,
+				ServiceDiscoveryLatency: 150 * time.Microsecond,
+				CacheHitRate:            80,
+				ImprovementFactor:       8.1,
+				TargetAchievement:       100,
+				TotalNodes:              1000,
+				TotalEdges:              5000,
+				Uptime:                  time.Hour,
+				MemoryUsage:             64 << 20,
+				CPUUsage:                35.5,
+				Goroutines:              120,
+				GCPause:                 500 * time.Microsecond,
+				Routing: routing.RoutingStats{
+					TotalLookups:     1200,
+					CacheHitRate:     80,
+					AverageLatency:   120 * time.Microsecond,
+					SuccessRate:      99.5,
+					CachedRoutes:     300,
+					InvalidationRate: 1.5,
+					LoadBalanceRate:  4,
+				},
+			},
+			fresh: func() message { return &performanceMetrics{} },
+			want: `{"average_routing_latency_us":"300","routing_success_rate":99.5,"service_discovery_latency_us":"150",
+				"cache_hit_rate":80,"improvement_factor":8.1,"target_achievement":100,"total_nodes":"1000",
+				"total_edges":"5000","uptime_us":"3600000000","memory_usage_bytes":"67108864","cpu_usage_percent":35.5,
+				"goroutines":"120","gc_pause_us":"500","routing":{"total_lookups":"1200","cache_hit_rate":80,
+				"average_latency_us":"120","success_rate":99.5,"cached_routes":300,"invalidation_rate":1.5,
+				"load_balance_rate":4}}`,
+		},
+		{
+			name: "AssociationExport",
+			message: &associationExport{Associations: map[string]associative.AssociationExport{
+				"7-8-0": {From: 7, To: 8, Type: associative.NodeToNode, Weight: 0.75, LastUpdate: time.UnixMicro(1700000000000000)},
+				"7-2-2": {From: 7, To: 2, Type: associative.NodeToService, Weight: 0.5},
+			}},
+			fresh: func() message { return &associationExport{} },
+			want: `{"associations":[
+				{"from":"7","to":"2","type":"ASSOCIATION_TYPE_NODE_TO_SERVICE","weight":0.5},
+				{"from":"7","to":"8","weight":0.75,"last_update_unix_us":"1700000000000000"}]}`,
+		},
+	}
+
+	covered := make(map[protoreflect.FullName]bool)
+
+	for _, tc := range cases {
+		descriptor := file.Messages().ByName(protoreflect.Name(tc.name))
+		if descriptor == nil {
+			t.Fatalf("%s: message not found in alm.proto", tc.name)
+		}
+
+		decoded := dynamicpb.NewMessage(descriptor)
+		if err := proto.Unmarshal(tc.message.appendWire(nil), decoded); err != nil {
+			t.Fatalf("%s: descriptor decode failed: %v", tc.name, err)
+		}
+		checkKnownFields(t, tc.name, decoded, covered)
+
+		encoded, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(decoded)
+		if err != nil {
+			t.Fatalf("%s: protojson marshal failed: %v", tc.name, err)
+		}
+		var got, want interface{}
+		if err := json.Unmarshal(encoded, &got); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if err := json.Unmarshal([]byte(tc.want), &want); err != nil {
+			t.Fatalf("%s: bad expectation: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: decoded fields\n got  %s\n want %s", tc.name, encoded, tc.want)
+		}
+
+		reencoded, err := proto.MarshalOptions{Deterministic: true}.Marshal(decoded)
+		if err != nil {
+			t.Fatalf("%s: descriptor encode failed: %v", tc.name, err)
+		}
+		roundTrip := tc.fresh()
+		if err := roundTrip.readWire(reencoded); err != nil {
+			t.Fatalf("%s: readWire failed: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(roundTrip, tc.message) {
+			t.Errorf("%s: round trip mismatch\n got  %+v\n want %+v", tc.name, roundTrip, tc.message)
+		}
+	}
+
+	messages := file.Messages()
+	for i := 0; i < messages.Len(); i++ {
+		if name := messages.Get(i).FullName(); !covered[name] {
+			t.Errorf("message %s is not exercised", name)
+		}
+	}
+}
+
+// checkKnownFields fails when the descriptor left any encoded field unknown,
+// which happens when a field number or wire type disagrees with alm.proto
+func checkKnownFields(t *testing.T, name string, m protoreflect.Message, covered map[protoreflect.FullName]bool) {
+	t.Helper()
+
+	covered[m.Descriptor().FullName()] = true
+	if unknown := m.GetUnknown(); len(unknown) > 0 {
+		t.Errorf("%s: %s has %d bytes of unknown fields", name, m.Descriptor().FullName(), len(unknown))
+	}
+
+	m.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		switch {
+		case field.IsMap():
+		case field.IsList() && field.Message() != nil:
+			list := value.List()
+			for i := 0; i < list.Len(); i++ {
+				checkKnownFields(t, name, list.Get(i).Message(), covered)
+			}
+		case field.Message() != nil:
+			checkKnownFields(t, name, value.Message(), covered)
+		}
+		return true
+	})
+}
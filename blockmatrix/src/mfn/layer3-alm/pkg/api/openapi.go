@@ -0,0 +1,208 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// adminEndpoint describes one admin API operation. The OpenAPI document is
+// generated from the registered endpoints, so it cannot drift from the
+// handlers that serve them.
+type adminEndpoint struct {
+	Method     string
+	Path       string
+	Summary    string
+	Parameters []adminParameter
+
+	// Response is a value of the type returned on success
+	Response interface{}
+
+	// Role a caller needs when authentication is enabled
+	Role Role
+
+	// State returns what a mutation changes, recorded in the audit log
+	// before and after it runs
+	State func() interface{}
+}
+
+// adminParameter describes a query parameter
+type adminParameter struct {
+	Name        string
+	Description string
+	Type        string
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// openAPIDocument builds an OpenAPI 3.0 document for endpoints. Response
+// schemas are derived from the Go types with encoding/json naming rules and
+// shared through components.
+func openAPIDocument(title, version string, endpoints []adminEndpoint) map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]interface{})
+
+	for _, endpoint := range endpoints {
+		operation := map[string]interface{}{
+			"summary":     endpoint.Summary,
+			"operationId": operationID(endpoint),
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "Success",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": schemaFor(reflect.TypeOf(endpoint.Response), schemas),
+						},
+					},
+				},
+				"default": map[string]interface{}{
+					"description": "Error",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": schemaFor(reflect.TypeOf(adminError{}), schemas),
+						},
+					},
+				},
+			},
+		}
+
+		if endpoint.Role != RoleNone {
+			operation["x-required-role"] = endpoint.Role.String()
+		}
+
+		if len(endpoint.Parameters) > 0 {
+			parameters := make([]interface{}, 0, len(endpoint.Parameters))
+			for _, parameter := range endpoint.Parameters {
+				parameters = append(parameters, map[string]interface{}{
+					"name":        parameter.Name,
+					"in":          "query",
+					"description": parameter.Description,
+					"schema":      map[string]interface{}{"type": parameter.Type},
+				})
+			}
+			operation["parameters"] = parameters
+		}
+
+		item, _ := paths[endpoint.Path].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[endpoint.Path] = item
+		}
+		item[strings.ToLower(endpoint.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": version,
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+// operationID derives an identifier such as getTopology from an endpoint
+func operationID(endpoint adminEndpoint) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(endpoint.Method))
+	for _, part := range strings.FieldsFunc(endpoint.Path, func(r rune) bool {
+		return r == '/' || r == '-' || r == '_' || r == '.'
+	}) {
+		id.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return id.String()
+}
+
+// schemaFor returns the JSON schema for t, adding named structs to schemas
+// and referring to them by name
+func schemaFor(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch {
+	case t == durationType:
+		return map[string]interface{}{"type": "integer", "format": "int64", "description": "Duration in nanoseconds"}
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaFor(t.Elem(), schemas)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return map[string]interface{}{"type": "object"}
+		}
+		return structSchema(t, schemas)
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	name := schemaName(t)
+	ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	if _, exists := schemas[name]; exists {
+		return ref
+	}
+
+	// Reserve the name first so recursive types terminate
+	schemas[name] = nil
+
+	properties := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		fieldName := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				fieldName = tagName
+			}
+		}
+
+		properties[fieldName] = schemaFor(field.Type, schemas)
+	}
+
+	schemas[name] = map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	return ref
+}
+
+// schemaName names a struct schema after its package and type, such as
+// RoutingRouteCacheStatistics; types from this package keep their own name
+func schemaName(t reflect.Type) string {
+	name := strings.TrimSuffix(strings.TrimPrefix(t.Name(), "admin"), "View")
+	name = strings.ToUpper(name[:1]) + name[1:]
+
+	pkg := t.PkgPath()
+	if pkg == reflect.TypeOf(adminError{}).PkgPath() {
+		return name
+	}
+	pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + name
+}
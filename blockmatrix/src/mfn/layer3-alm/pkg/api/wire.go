@@ -0,0 +1,256 @@
+// Package api implements protobuf wire encoding for the ALM coordinator API
+package api
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// message is implemented by every API message. Messages are encoded by hand
+// following alm.proto, so clients generated from it in any language
+// interoperate without generated Go code.
+type message interface {
+	appendWire(b []byte) []byte
+	readWire(b []byte) error
+}
+
+// wireCodec is the gRPC codec for API messages. It is registered under the
+// name "proto" so standard protobuf clients are served.
+type wireCodec struct{}
+
+func (wireCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("api codec cannot marshal %T", v)
+	}
+	return msg.appendWire(nil), nil
+}
+
+func (wireCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("api codec cannot unmarshal into %T", v)
+	}
+	return msg.readWire(data)
+}
+
+func (wireCodec) Name() string {
+	return "proto"
+}
+
+// Encoding helpers; proto3 scalars equal to their default are omitted
+
+func appendInt64(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendInt32(b []byte, num protowire.Number, v int32) []byte {
+	return appendInt64(b, num, int64(v))
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, 1)
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func appendStrings(b []byte, num protowire.Number, values []string) []byte {
+	for _, v := range values {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendString(b, v)
+	}
+	return b
+}
+
+// appendInt64s writes a packed repeated int64 field
+func appendInt64s(b []byte, num protowire.Number, values []int64) []byte {
+	if len(values) == 0 {
+		return b
+	}
+	var packed []byte
+	for _, v := range values {
+		packed = protowire.AppendVarint(packed, uint64(v))
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, packed)
+}
+
+func appendMessage(b []byte, num protowire.Number, msg message) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg.appendWire(nil))
+}
+
+// appendStringMap writes a map<string, string> field with sorted keys so
+// encodings are deterministic
+func appendStringMap(b []byte, num protowire.Number, values map[string]string) []byte {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		entry := appendString(nil, 1, key)
+		entry = appendString(entry, 2, values[key])
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b
+}
+
+// fieldReader decodes the value of one field, keeping the first error
+type fieldReader struct {
+	num   protowire.Number
+	typ   protowire.Type
+	value []byte
+	err   error
+}
+
+// readFields calls visit for every field in b. Unknown fields are skipped by
+// ignoring them in visit.
+func readFields(b []byte, visit func(field *fieldReader)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		m := protowire.ConsumeFieldValue(num, typ, b)
+		if m < 0 {
+			return protowire.ParseError(m)
+		}
+
+		field := &fieldReader{num: num, typ: typ, value: b[:m]}
+		visit(field)
+		if field.err != nil {
+			return fmt.Errorf("field %d: %w", num, field.err)
+		}
+		b = b[m:]
+	}
+	return nil
+}
+
+func (fr *fieldReader) expect(typ protowire.Type) bool {
+	if fr.err == nil && fr.typ != typ {
+		fr.err = fmt.Errorf("unexpected wire type %d", fr.typ)
+	}
+	return fr.err == nil
+}
+
+func (fr *fieldReader) varint() uint64 {
+	if !fr.expect(protowire.VarintType) {
+		return 0
+	}
+	v, _ := protowire.ConsumeVarint(fr.value)
+	return v
+}
+
+func (fr *fieldReader) int64() int64 {
+	return int64(fr.varint())
+}
+
+func (fr *fieldReader) int32() int32 {
+	return int32(fr.varint())
+}
+
+func (fr *fieldReader) bool() bool {
+	return fr.varint() != 0
+}
+
+func (fr *fieldReader) double() float64 {
+	if !fr.expect(protowire.Fixed64Type) {
+		return 0
+	}
+	v, _ := protowire.ConsumeFixed64(fr.value)
+	return math.Float64frombits(v)
+}
+
+func (fr *fieldReader) bytes() []byte {
+	if !fr.expect(protowire.BytesType) {
+		return nil
+	}
+	v, _ := protowire.ConsumeBytes(fr.value)
+	return v
+}
+
+func (fr *fieldReader) string() string {
+	return string(fr.bytes())
+}
+
+// int64s appends a repeated int64 field in packed or unpacked form
+func (fr *fieldReader) int64s(values []int64) []int64 {
+	if fr.typ == protowire.VarintType {
+		return append(values, fr.int64())
+	}
+
+	packed := fr.bytes()
+	for len(packed) > 0 && fr.err == nil {
+		v, n := protowire.ConsumeVarint(packed)
+		if n < 0 {
+			fr.err = protowire.ParseError(n)
+			break
+		}
+		values = append(values, int64(v))
+		packed = packed[n:]
+	}
+	return values
+}
+
+func (fr *fieldReader) message(msg message) {
+	if data := fr.bytes(); fr.err == nil {
+		fr.err = msg.readWire(data)
+	}
+}
+
+// stringMapEntry adds one map<string, string> entry to values
+func (fr *fieldReader) stringMapEntry(values map[string]string) {
+	data := fr.bytes()
+	if fr.err != nil {
+		return
+	}
+
+	var key, value string
+	fr.err = readFields(data, func(field *fieldReader) {
+		switch field.num {
+		case 1:
+			key = field.string()
+		case 2:
+			value = field.string()
+		}
+	})
+	values[key] = value
+}
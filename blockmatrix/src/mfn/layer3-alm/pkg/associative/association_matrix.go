@@ -72,6 +72,11 @@ func (am *AssociationMatrix) UpdateAssociation(from, to int64, assocType Associa
 	
 	am.weights[key] = newWeight
 	am.lastUpdate[key] = now
+	
+	// Prune with some slack so a full matrix is not sorted on every update
+	if am.capacity > 0 && len(am.weights) > am.capacity+am.capacity/8 {
+		am.evictWeakest(am.capacity)
+	}
 }
 
 // GetStrongestAssociations returns the strongest associations from a node
@@ -163,6 +168,58 @@ func (am *AssociationMatrix) PruneWeakAssociations(threshold float64) int {
 	return len(toRemove)
 }
 
+// SetCapacity bounds the number of associations kept, removing the weakest
+// decayed associations beyond it, and returns how many were removed. Zero
+// removes the bound.
+func (am *AssociationMatrix) SetCapacity(capacity int) int {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	
+	if capacity < 0 {
+		capacity = 0
+	}
+	am.capacity = capacity
+	if capacity == 0 {
+		return 0
+	}
+	return am.evictWeakest(capacity)
+}
+
+// Len returns the number of associations kept
+func (am *AssociationMatrix) Len() int {
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+	
+	return len(am.weights)
+}
+
+// evictWeakest removes the weakest decayed associations until at most
+// capacity remain. The caller holds the write lock.
+func (am *AssociationMatrix) evictWeakest(capacity int) int {
+	excess := len(am.weights) - capacity
+	if excess <= 0 {
+		return 0
+	}
+	
+	type weighted struct {
+		key    AssociationKey
+		weight float64
+	}
+	all := make([]weighted, 0, len(am.weights))
+	for key, weight := range am.weights {
+		all = append(all, weighted{key, weight * am.calculateDecay(am.lastUpdate[key])})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].weight < all[j].weight
+	})
+	
+	for _, association := range all[:excess] {
+		delete(am.weights, association.key)
+		delete(am.lastUpdate, association.key)
+	}
+	return excess
+}
+
 // GetMatrixStats returns statistics about the association matrix
 func (am *AssociationMatrix) GetMatrixStats() AssociationMatrixStats {
 	am.mutex.RLock()
@@ -272,14 +329,14 @@ func (am *AssociationMatrix) ExportAssociations() map[string]AssociationExport {
 	exports := make(map[string]AssociationExport)
 	
 	for key, weight := range am.weights {
-		keyStr := fmt.Sprintf("%d-%d-%d", key.From, key.To, int(key.Type))
-		exports[keyStr] = AssociationExport{
+		export := AssociationExport{
 			From:       key.From,
 			To:         key.To,
 			Type:       key.Type,
 			Weight:     weight,
 			LastUpdate: am.lastUpdate[key],
 		}
+		exports[export.Key()] = export
 	}
 	
 	return exports
@@ -300,6 +357,10 @@ func (am *AssociationMatrix) ImportAssociations(imports map[string]AssociationEx
 		am.weights[key] = export.Weight
 		am.lastUpdate[key] = export.LastUpdate
 	}
+	
+	if am.capacity > 0 {
+		am.evictWeakest(am.capacity)
+	}
 }
 
 // AssociationExport represents an exportable association
@@ -311,3 +372,7 @@ type AssociationExport struct {
 	LastUpdate time.Time       `json:"last_update"`
 }
 
+// Key returns the key of the association in ExportAssociations
+func (e AssociationExport) Key() string {
+	return fmt.Sprintf("%d-%d-%d", e.From, e.To, int(e.Type))
+}
@@ -0,0 +1,41 @@
+// Package associative implements micro-benchmarks of association learning
+package associative
+
+import (
+	"fmt"
+	"testing"
+)
+
+// BenchmarkUpdateAssociation measures reinforcing associations in matrices
+// already holding a growing number of them
+func BenchmarkUpdateAssociation(b *testing.B) {
+	for _, size := range []int{1000, 100000} {
+		b.Run(fmt.Sprintf("associations=%d", size), func(b *testing.B) {
+			am := NewAssociationMatrix(0.01, 0.1)
+			for i := 0; i < size; i++ {
+				am.UpdateAssociation(int64(i%1000), int64(i/1000), NodeToNode, 0.5)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				am.UpdateAssociation(int64(i%1000), int64(i/1000%100), NodeToNode, float64(i%10)/10)
+			}
+		})
+	}
+}
+
+// BenchmarkUpdateAssociationParallel measures reinforcement under
+// contention for the matrix lock
+func BenchmarkUpdateAssociationParallel(b *testing.B) {
+	am := NewAssociationMatrix(0.01, 0.1)
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			am.UpdateAssociation(int64(i%1000), int64(i/1000%100), NodeToNode, 0.5)
+			i++
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package associative
+
+import (
+	"testing"
+)
+
+func TestAssociationMatrixCapacity(t *testing.T) {
+	am := NewAssociationMatrix(0.95, 1)
+	for i := int64(1); i <= 10; i++ {
+		am.UpdateAssociation(i, 0, NodeToNode, float64(i)/10)
+	}
+
+	if removed := am.SetCapacity(4); removed != 6 || am.Len() != 4 {
+		t.Fatalf("removed %d associations leaving %d, want 6 leaving 4", removed, am.Len())
+	}
+	for i := int64(1); i <= 6; i++ {
+		if am.GetAssociation(i, 0, NodeToNode) != nil {
+			t.Errorf("weak association from %d kept", i)
+		}
+	}
+
+	// Updates past the capacity prune back to it once over the slack
+	for i := int64(11); i <= 15; i++ {
+		am.UpdateAssociation(i, 0, NodeToNode, 1)
+	}
+	if am.Len() > 4+4/8+1 {
+		t.Errorf("kept %d associations with capacity 4", am.Len())
+	}
+
+	am.SetCapacity(0)
+	for i := int64(20); i < 30; i++ {
+		am.UpdateAssociation(i, 0, NodeToNode, 1)
+	}
+	if am.Len() < 10 {
+		t.Errorf("kept %d associations with no capacity", am.Len())
+	}
+}
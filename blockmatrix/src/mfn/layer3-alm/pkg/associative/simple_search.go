@@ -3,6 +3,7 @@ package associative
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
@@ -18,6 +19,10 @@ type SearchRequest struct {
 	MaxResults    int
 	Timeout       time.Duration
 	Context       context.Context
+	
+	// MaxLatency prunes searches landmark bounds prove cannot find a path
+	// this fast; zero searches regardless
+	MaxLatency    time.Duration
 }
 
 // SearchResult contains the results of associative search
@@ -72,10 +77,30 @@ type AssociationMatrix struct {
 	decayRate    float64
 	learningRate float64
 	
+	// Most associations kept; zero means unbounded
+	capacity     int
+	
 	// Thread safety
 	mutex        sync.RWMutex
 }
 
+// SearchConfig configures an associative search engine
+type SearchConfig struct {
+	// MaxSearchDepth bounds the hops explored from the source
+	MaxSearchDepth int
+	
+	// BeamSearchWidth is the number of partial paths kept per step
+	BeamSearchWidth int
+}
+
+// DefaultSearchConfig returns the default search configuration
+func DefaultSearchConfig() *SearchConfig {
+	return &SearchConfig{
+		MaxSearchDepth:  20,
+		BeamSearchWidth: 8,
+	}
+}
+
 // SimpleAssociativeSearchEngine provides a basic implementation for benchmarking
 type SimpleAssociativeSearchEngine struct {
 	networkGraph *graph.NetworkGraph
@@ -93,6 +118,11 @@ func (sase *SimpleAssociativeSearchEngine) Search(request *SearchRequest) (*Sear
 	// Simple implementation for benchmarking - uses basic pathfinding
 	startTime := time.Now()
 	
+	// Skip the search when no path can meet the latency limit
+	if request.MaxLatency > 0 && !sase.networkGraph.PathPossibleWithin(request.SourceID, request.DestinationID, request.MaxLatency) {
+		return nil, fmt.Errorf("%w: %v from %d to %d", graph.ErrNoPathWithin, request.MaxLatency, request.SourceID, request.DestinationID)
+	}
+	
 	// Get optimal path from network graph
 	optimalPath, err := sase.networkGraph.FindShortestPath(request.SourceID, request.DestinationID)
 	if err != nil {
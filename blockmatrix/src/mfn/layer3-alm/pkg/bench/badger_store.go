@@ -0,0 +1,126 @@
+package bench
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// recordPrefix prefixes the key of every record in a BadgerStore; the key
+// continues with the record's start time and a sequence number
+const recordPrefix = "record/"
+
+// BadgerStore is a Store in a Badger database directory. Records are keyed
+// by start time, so queries over recent runs read only those records
+// rather than the whole history. A directory is held by one process at a
+// time; its Add and Query are safe for concurrent use within that process.
+type BadgerStore struct {
+	db       *badger.DB
+	sequence *badger.Sequence
+}
+
+// OpenBadgerStore opens the store in directory dir, creating it if missing
+func OpenBadgerStore(dir string) (*BadgerStore, error) {
+	db, err := badger.Open(badger.DefaultOptions(dir).WithLogger(nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open result store: %w", err)
+	}
+
+	sequence, err := db.GetSequence([]byte("sequence"), 64)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open result store: %w", err)
+	}
+	return &BadgerStore{db: db, sequence: sequence}, nil
+}
+
+// recordKey returns the key of the record started at started with sequence
+// number seq. Times are offset so that keys sort in time order before 1970
+// as well as after.
+func recordKey(started time.Time, seq uint64) []byte {
+	key := make([]byte, len(recordPrefix)+16)
+	copy(key, recordPrefix)
+	binary.BigEndian.PutUint64(key[len(recordPrefix):], uint64(started.UnixNano())^1<<63)
+	binary.BigEndian.PutUint64(key[len(recordPrefix)+8:], seq)
+	return key
+}
+
+// Add implements Store
+func (bs *BadgerStore) Add(record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+
+	seq, err := bs.sequence.Next()
+	if err != nil {
+		return fmt.Errorf("failed to store result: %w", err)
+	}
+	err = bs.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(recordKey(record.Result.Started, seq), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store result: %w", err)
+	}
+	return nil
+}
+
+// Query implements Store. Records are read newest first, stopping at
+// query.Since or once query.Limit records match.
+func (bs *BadgerStore) Query(query Query) ([]*Record, error) {
+	var since []byte
+	if !query.Since.IsZero() {
+		since = recordKey(query.Since, 0)
+	}
+
+	var records []*Record
+	err := bs.db.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = []byte(recordPrefix)
+		options.Reverse = true
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Seek(append([]byte(recordPrefix), 0xff)); it.Valid(); it.Next() {
+			item := it.Item()
+			if since != nil && string(item.Key()) < string(since) {
+				break
+			}
+
+			var record Record
+			err := item.Value(func(data []byte) error {
+				return json.Unmarshal(data, &record)
+			})
+			if err != nil || record.Result == nil {
+				return fmt.Errorf("invalid record %x", item.Key())
+			}
+			if query.matches(&record) {
+				records = append(records, &record)
+				if query.Limit > 0 && len(records) == query.Limit {
+					break
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read result store: %w", err)
+	}
+
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, nil
+}
+
+// Close implements Store
+func (bs *BadgerStore) Close() error {
+	if err := bs.sequence.Release(); err != nil {
+		bs.db.Close()
+		return err
+	}
+	return bs.db.Close()
+}
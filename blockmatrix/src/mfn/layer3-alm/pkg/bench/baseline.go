@@ -0,0 +1,157 @@
+// Package bench implements measurement of the HTTP baseline that ALM
+// improvements are computed against
+package bench
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Baseline protocols
+const (
+	HTTP1 = "http1"
+	HTTP2 = "http2"
+)
+
+// HTTPBaselineConfig configures a baseline measurement
+type HTTPBaselineConfig struct {
+	// Protocol is HTTP1 or HTTP2, the latter over cleartext
+	Protocol string
+
+	// Address of a baseline server on another machine, as started by
+	// ServeBaseline, to measure round trips across the LAN. Empty starts
+	// one on the loopback interface.
+	Address string
+}
+
+// Validate checks the configuration
+func (c *HTTPBaselineConfig) Validate() error {
+	switch c.Protocol {
+	case HTTP1, HTTP2:
+		return nil
+	default:
+		return fmt.Errorf("unknown baseline protocol %q: expected %s or %s", c.Protocol, HTTP1, HTTP2)
+	}
+}
+
+// HTTPBaseline answers route lookups over HTTP, a plain request-response
+// exchange with no routing intelligence. Its latency is the baseline to
+// pass to the other benchmarks in place of the assumed BaselineLatency.
+type HTTPBaseline struct {
+	server   *http.Server
+	client   *http.Client
+	endpoint string
+	protocol int
+}
+
+// NewHTTPBaseline connects to the baseline server config names, starting
+// one on an ephemeral loopback port if it names none
+func NewHTTPBaseline(config *HTTPBaselineConfig) (*HTTPBaseline, error) {
+	if config == nil {
+		config = DefaultHTTPBaselineConfig()
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	hb := &HTTPBaseline{protocol: 1}
+	address := config.Address
+	if address == "" {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen for baseline server: %w", err)
+		}
+		hb.server = ServeBaseline(listener)
+		address = listener.Addr().String()
+	}
+	hb.endpoint = "http://" + strings.TrimPrefix(address, "http://") + "/route"
+
+	if config.Protocol == HTTP2 {
+		// Prior knowledge: cleartext HTTP/2 from the first request
+		hb.protocol = 2
+		hb.client = &http.Client{Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, addr)
+			},
+		}}
+	} else {
+		hb.client = &http.Client{Transport: &http.Transport{
+			MaxIdleConns:        1024,
+			MaxIdleConnsPerHost: 1024,
+		}}
+	}
+	return hb, nil
+}
+
+// ServeBaseline serves baseline lookups over HTTP/1.1 and cleartext HTTP/2
+// on listener until the returned server is closed
+func ServeBaseline(listener net.Listener) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/route", func(w http.ResponseWriter, r *http.Request) {
+		source, _ := strconv.ParseInt(r.URL.Query().Get("source"), 10, 64)
+		destination, _ := strconv.ParseInt(r.URL.Query().Get("destination"), 10, 64)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"path": []int64{source, destination},
+		})
+	})
+
+	server := &http.Server{Handler: h2c.NewHandler(mux, &http2.Server{})}
+	go server.Serve(listener)
+	return server
+}
+
+// Lookup implements Target with one HTTP round trip
+func (hb *HTTPBaseline) Lookup(ctx context.Context, request Request) (bool, error) {
+	url := fmt.Sprintf("%s?source=%d&destination=%d&qos=%s", hb.endpoint, request.Source, request.Destination, request.QoSClass)
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	response, err := hb.client.Do(httpRequest)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	if response.ProtoMajor != hb.protocol {
+		return false, fmt.Errorf("baseline server answered over %s, not HTTP/%d", response.Proto, hb.protocol)
+	}
+	var route struct {
+		Path []int64 `json:"path"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&route); err != nil {
+		return false, fmt.Errorf("failed to decode baseline route: %w", err)
+	}
+	io.Copy(io.Discard, response.Body)
+	return false, nil
+}
+
+// Close closes idle connections and stops the baseline server if this
+// baseline started it
+func (hb *HTTPBaseline) Close() error {
+	hb.client.CloseIdleConnections()
+	if hb.server == nil {
+		return nil
+	}
+	return hb.server.Close()
+}
+
+// DefaultHTTPBaselineConfig returns the default baseline configuration: HTTP/1.1 on loopback
+func DefaultHTTPBaselineConfig() *HTTPBaselineConfig {
+	return &HTTPBaselineConfig{Protocol: HTTP1}
+}
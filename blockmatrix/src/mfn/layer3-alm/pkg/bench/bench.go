@@ -0,0 +1,552 @@
+// Package bench implements the ALM routing benchmarks run by cmd/alm-bench
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/histogram"
+)
+
+// Performance targets the benchmarks are measured against
+const (
+	// BaselineLatency is the assumed HTTP round trip the ALM improves on
+	BaselineLatency = 1390 * time.Microsecond
+
+	// TargetImprovement is the improvement over the baseline a run must reach
+	TargetImprovement = 7.77
+
+	// HighestLatency is the largest lookup latency recorded; slower lookups
+	// are counted as this
+	HighestLatency = time.Minute
+)
+
+// Target is a routing implementation under benchmark
+type Target interface {
+	// Lookup resolves a route and reports whether it was served from a cache
+	Lookup(ctx context.Context, request Request) (cacheHit bool, err error)
+}
+
+// Updater is implemented by targets that take workload writes: topology
+// changes affecting routes from source to destination. Writes to targets
+// without it are skipped.
+type Updater interface {
+	Update(ctx context.Context, source, destination int64) error
+}
+
+// Config configures a benchmark run
+type Config struct {
+	// Name labels the run in reports
+	Name string
+
+	// Requests is the number of measured lookups, shared among the workers
+	Requests    int
+	Concurrency int
+
+	// Warmup lookups run before measuring and are not recorded
+	Warmup int
+
+	// Lookups pick source and destination nodes from 1 to Nodes
+	Nodes int
+
+	// Workload shapes the traffic; nil is DefaultWorkload
+	Workload *Workload
+
+	// Replay replaces the workload with recorded requests when set
+	Replay *Replay
+
+	// Topology labels the topology profile the target was built over
+	Topology string
+
+	// Faults are injected into the target while measuring, which must
+	// then be an Injector
+	Faults []Fault `json:"-"`
+
+	BaselineLatency   time.Duration
+	TargetImprovement float64
+
+	// Profile captures profiles while measuring when set. It stays with
+	// the process running the benchmark, so agents don't profile.
+	Profile *ProfileConfig `json:"-"`
+
+	// Progress is called every ProgressInterval while measuring when set
+	Progress         func(Progress) `json:"-"`
+	ProgressInterval time.Duration  `json:"-"`
+}
+
+// Progress is a view of a run while it measures
+type Progress struct {
+	Elapsed time.Duration
+
+	// Requests of Total have been made so far
+	Requests   int64
+	Total      int
+	Successful int64
+	CacheHits  int64
+
+	// RequestsPerSecond is the rate since the previous report
+	RequestsPerSecond float64
+
+	// Recent is the distribution of successful lookup latencies over the
+	// last ProgressWindow
+	Recent *histogram.Histogram
+}
+
+// ProgressWindow is the span of the latencies reported as progress
+const ProgressWindow = 5 * time.Second
+
+// Result summarizes a benchmark run. Latencies marshal as nanoseconds.
+type Result struct {
+	Name     string        `json:"name"`
+	Workload string        `json:"workload,omitempty"`
+	Topology string        `json:"topology,omitempty"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration_ns"`
+
+	Requests   int64 `json:"requests"`
+	Successful int64 `json:"successful"`
+	CacheHits  int64 `json:"cache_hits"`
+	Writes     int64 `json:"writes,omitempty"`
+
+	AverageLatency time.Duration `json:"average_latency_ns"`
+	MinLatency     time.Duration `json:"min_latency_ns"`
+	MaxLatency     time.Duration `json:"max_latency_ns"`
+	P50Latency     time.Duration `json:"p50_latency_ns"`
+	P90Latency     time.Duration `json:"p90_latency_ns"`
+	P95Latency     time.Duration `json:"p95_latency_ns"`
+	P99Latency     time.Duration `json:"p99_latency_ns"`
+	P999Latency    time.Duration `json:"p999_latency_ns"`
+	P9999Latency   time.Duration `json:"p9999_latency_ns"`
+
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	SuccessRate       float64 `json:"success_rate"`
+	CacheHitRate      float64 `json:"cache_hit_rate"`
+
+	BaselineLatency   time.Duration `json:"baseline_latency_ns,omitempty"`
+	TargetImprovement float64       `json:"target_improvement,omitempty"`
+	ImprovementFactor float64       `json:"improvement_factor,omitempty"`
+	TargetAchieved    bool          `json:"target_achieved"`
+
+	// Profiles lists what was captured while measuring
+	Profiles *Profiles `json:"profiles,omitempty"`
+
+	// Faults is the impact of each fault injected
+	Faults []*FaultResult `json:"faults,omitempty"`
+
+	// Latencies is the distribution of successful lookup latencies
+	Latencies *histogram.Histogram `json:"-"`
+}
+
+// source draws the operations of a run; each worker has its own
+type source interface {
+	next() operation
+
+	// interval is the gap before the last operation drawn under an
+	// open-loop arrival process
+	interval() time.Duration
+}
+
+// Run warms target up and then measures config.Requests lookups across
+// config.Concurrency workers, drawing them from config.Workload, or from
+// config.Replay when set. Workload writes are interleaved but not measured.
+// Failed lookups count against the success rate but not the latency
+// figures.
+//
+// With an open-loop arrival process or a paced replay, latency is measured from when a lookup
+// was due rather than when a worker got to it, so queueing behind slow
+// lookups shows up in the results.
+func Run(ctx context.Context, config *Config, target Target) (*Result, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.Nodes < 2 {
+		return nil, errors.New("benchmark needs at least 2 nodes")
+	}
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	// newSource returns the source of a worker
+	var (
+		newSource func(worker int) source
+		name      string
+		closed    bool
+	)
+	if replay := config.Replay; replay != nil {
+		if err := replay.validate(); err != nil {
+			return nil, fmt.Errorf("invalid replay: %w", err)
+		}
+		// Closed-loop workers start spread through the trace
+		newSource = func(worker int) source {
+			return newReplayer(replay, config.Nodes, worker*len(replay.Records)/concurrency)
+		}
+		name, closed = replay.Name, replay.Speed == 0
+	} else {
+		workload := config.Workload
+		if workload == nil {
+			workload = DefaultWorkload()
+		}
+		if err := workload.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid workload: %w", err)
+		}
+		seed := time.Now().UnixNano()
+		newSource = func(worker int) source {
+			return newGenerator(workload, config.Nodes, seed+int64(worker))
+		}
+		name, closed = workload.Name, workload.Arrival.Process == Closed
+	}
+	updater, _ := target.(Updater)
+	injector, _ := target.(Injector)
+	if len(config.Faults) > 0 && injector == nil {
+		return nil, errors.New("target does not support fault injection")
+	}
+	for i := range config.Faults {
+		if err := config.Faults[i].Validate(); err != nil {
+			return nil, fmt.Errorf("invalid fault %d: %w", i+1, err)
+		}
+	}
+
+	warmup := newSource(0)
+	for i := 0; i < config.Warmup; i++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		op := warmup.next()
+		if op.write {
+			if updater != nil {
+				updater.Update(ctx, op.Source, op.Destination)
+			}
+			continue
+		}
+		target.Lookup(ctx, op.Request)
+	}
+
+	var recent *histogram.Windowed
+	if config.Progress != nil {
+		recent = histogram.NewWindowed(HighestLatency, 3, ProgressWindow, 5)
+	}
+	workers := make([]*workerStats, concurrency)
+	for i := range workers {
+		workers[i] = newWorkerStats(recent)
+	}
+
+	// execute runs op for a worker, measuring a lookup from due
+	execute := func(stats *workerStats, op operation, due time.Time) {
+		if op.write {
+			if updater != nil {
+				updater.Update(ctx, op.Source, op.Destination)
+			}
+			stats.writes.Add(1)
+			return
+		}
+
+		cacheHit, err := target.Lookup(ctx, op.Request)
+		stats.record(time.Now(), time.Since(due), cacheHit, err != nil)
+	}
+
+	// Writes are drawn on top of the measured lookups, so keep drawing
+	// until enough lookups have been claimed
+	var claimed atomic.Int64
+	claim := func(op operation) bool {
+		return op.write || claimed.Add(1) <= int64(config.Requests)
+	}
+
+	started := time.Now()
+	var profiling *capture
+	if config.Profile.enabled() {
+		var err error
+		profiling, err = startCapture(config.Profile, config.Name, started)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var injections chan []*injection
+	stopInjecting := make(chan struct{})
+	if len(config.Faults) > 0 {
+		for _, stats := range workers {
+			stats.faults = newTimeline(started)
+		}
+		injections = make(chan []*injection, 1)
+		go func() {
+			injections <- injectFaults(ctx, injector, config.Faults, started, stopInjecting)
+		}()
+	}
+
+	reported := make(chan struct{})
+	stopReporting := make(chan struct{})
+	if config.Progress != nil {
+		go func() {
+			defer close(reported)
+			interval := config.ProgressInterval
+			if interval <= 0 {
+				interval = 500 * time.Millisecond
+			}
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			previous, previousAt := int64(0), started
+			for {
+				select {
+				case now := <-ticker.C:
+					progress := Progress{
+						Elapsed: now.Sub(started),
+						Total:   config.Requests,
+						Recent:  recent.Snapshot(),
+					}
+					for _, stats := range workers {
+						progress.Requests += stats.requests.Load()
+						progress.Successful += stats.successful.Load()
+						progress.CacheHits += stats.cacheHits.Load()
+					}
+					if seconds := now.Sub(previousAt).Seconds(); seconds > 0 {
+						progress.RequestsPerSecond = float64(progress.Requests-previous) / seconds
+					}
+					previous, previousAt = progress.Requests, now
+					config.Progress(progress)
+				case <-stopReporting:
+					return
+				}
+			}
+		}()
+	} else {
+		close(reported)
+	}
+
+	var wg sync.WaitGroup
+	if closed {
+		for worker := 0; worker < concurrency; worker++ {
+			wg.Add(1)
+			go func(worker int) {
+				defer wg.Done()
+				stats := workers[worker]
+				defer stats.flush()
+
+				source := newSource(worker + 1)
+				for ctx.Err() == nil {
+					op := source.next()
+					if !claim(op) {
+						return
+					}
+					execute(stats, op, time.Now())
+				}
+			}(worker)
+		}
+	} else {
+		type scheduled struct {
+			op  operation
+			due time.Time
+		}
+		queue := make(chan scheduled, concurrency)
+		for worker := 0; worker < concurrency; worker++ {
+			wg.Add(1)
+			go func(stats *workerStats) {
+				defer wg.Done()
+				defer stats.flush()
+
+				for item := range queue {
+					execute(stats, item.op, item.due)
+				}
+			}(workers[worker])
+		}
+
+		// Waits for the next arrival and for a free worker end as soon as
+		// the run is cancelled, however long the arrival gap
+		timer := time.NewTimer(0)
+		<-timer.C
+		source := newSource(1)
+		due := time.Now()
+	dispatch:
+		for ctx.Err() == nil {
+			op := source.next()
+			if !claim(op) {
+				break
+			}
+			due = due.Add(source.interval())
+			if wait := time.Until(due); wait > 0 {
+				timer.Reset(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					break dispatch
+				}
+			}
+			select {
+			case queue <- scheduled{op: op, due: due}:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		close(queue)
+	}
+	wg.Wait()
+	duration := time.Since(started)
+	close(stopReporting)
+	<-reported
+
+	// Faults still active are repaired now the run is over
+	close(stopInjecting)
+	var faultResults []*FaultResult
+	if injections != nil {
+		faults := newTimeline(started)
+		for _, stats := range workers {
+			faults.merge(stats.faults)
+		}
+		faultResults = faults.analyze(<-injections)
+	}
+
+	var profiles *Profiles
+	if profiling != nil {
+		var err error
+		if profiles, err = profiling.stop(); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Result{
+		Name:              config.Name,
+		Workload:          name,
+		Topology:          config.Topology,
+		Started:           started,
+		Duration:          duration,
+		BaselineLatency:   config.BaselineLatency,
+		TargetImprovement: config.TargetImprovement,
+		Profiles:          profiles,
+		Faults:            faultResults,
+		Latencies:         histogram.New(HighestLatency, 3),
+	}
+	for _, stats := range workers {
+		result.Requests += stats.requests.Load()
+		result.Successful += stats.successful.Load()
+		result.CacheHits += stats.cacheHits.Load()
+		result.Writes += stats.writes.Load()
+		result.Latencies.Merge(stats.latencies)
+	}
+	result.summarize()
+	return result, ctx.Err()
+}
+
+// workerStats accumulates one worker's measurements, so workers record
+// without contending on shared counters or locks. The run merges them once
+// the workers are done; the progress reporter reads the counters as they go
+// and recent latencies are flushed to it in batches.
+type workerStats struct {
+	requests   atomic.Int64
+	successful atomic.Int64
+	cacheHits  atomic.Int64
+	writes     atomic.Int64
+
+	latencies *histogram.Histogram
+	faults    *timeline
+
+	// Latencies not yet flushed to the shared recent window, and when the
+	// last flush was
+	recent    *histogram.Windowed
+	unflushed []time.Duration
+	flushedAt time.Time
+
+	// Keeps the next worker's counters off this worker's cache line
+	_ [64]byte
+}
+
+// Recent latencies are flushed to the progress window once a worker has
+// buffered this many or this long has passed
+const (
+	recentFlushBatch    = 256
+	recentFlushInterval = 100 * time.Millisecond
+)
+
+// newWorkerStats creates the stats of a worker, flushing recent latencies to
+// recent when it is set
+func newWorkerStats(recent *histogram.Windowed) *workerStats {
+	stats := &workerStats{
+		latencies: histogram.New(HighestLatency, 3),
+		recent:    recent,
+		flushedAt: time.Now(),
+	}
+	if recent != nil {
+		stats.unflushed = make([]time.Duration, 0, recentFlushBatch)
+	}
+	return stats
+}
+
+// record counts a lookup that finished at now after latency
+func (ws *workerStats) record(now time.Time, latency time.Duration, cacheHit, failed bool) {
+	if ws.faults != nil {
+		ws.faults.record(now, latency, failed)
+	}
+
+	ws.requests.Add(1)
+	if failed {
+		return
+	}
+	ws.successful.Add(1)
+	if cacheHit {
+		ws.cacheHits.Add(1)
+	}
+	ws.latencies.Record(latency)
+
+	if ws.recent != nil {
+		ws.unflushed = append(ws.unflushed, latency)
+		if len(ws.unflushed) == recentFlushBatch || now.Sub(ws.flushedAt) >= recentFlushInterval {
+			ws.flush()
+		}
+	}
+}
+
+// flush records the buffered latencies in the progress window
+func (ws *workerStats) flush() {
+	if ws.recent == nil {
+		return
+	}
+	ws.recent.RecordBatch(ws.unflushed)
+	ws.unflushed = ws.unflushed[:0]
+	ws.flushedAt = time.Now()
+}
+
+// summarize fills in the latency, rate and improvement figures
+func (r *Result) summarize() {
+	if seconds := r.Duration.Seconds(); seconds > 0 {
+		r.RequestsPerSecond = float64(r.Successful) / seconds
+	}
+	if r.Requests > 0 {
+		r.SuccessRate = float64(r.Successful) / float64(r.Requests) * 100
+		r.CacheHitRate = float64(r.CacheHits) / float64(r.Requests) * 100
+	}
+	if r.Latencies.Count() == 0 {
+		return
+	}
+
+	r.AverageLatency = r.Latencies.Mean()
+	r.MinLatency = r.Latencies.Min()
+	r.MaxLatency = r.Latencies.Max()
+	r.P50Latency = r.Latencies.Quantile(0.50)
+	r.P90Latency = r.Latencies.Quantile(0.90)
+	r.P95Latency = r.Latencies.Quantile(0.95)
+	r.P99Latency = r.Latencies.Quantile(0.99)
+	r.P999Latency = r.Latencies.Quantile(0.999)
+	r.P9999Latency = r.Latencies.Quantile(0.9999)
+
+	if r.BaselineLatency > 0 && r.AverageLatency > 0 {
+		r.ImprovementFactor = float64(r.BaselineLatency) / float64(r.AverageLatency)
+		r.TargetAchieved = r.ImprovementFactor >= r.TargetImprovement
+	}
+}
+
+// DefaultConfig returns the default benchmark configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Requests:          10000,
+		Concurrency:       20,
+		Warmup:            1000,
+		Nodes:             100,
+		BaselineLatency:   BaselineLatency,
+		TargetImprovement: TargetImprovement,
+	}
+}
@@ -0,0 +1,212 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type nopTarget struct{}
+
+func (nopTarget) Lookup(ctx context.Context, request Request) (bool, error) {
+	return false, nil
+}
+
+func TestOpenLoopRunStopsOnCancel(t *testing.T) {
+	cases := []struct {
+		name    string
+		process string
+	}{
+		{"constant", Constant},
+		{"poisson", Poisson},
+	}
+
+	for _, tc := range cases {
+		workload := DefaultWorkload()
+		// One arrival every 100s on average: far longer than the test runs
+		workload.Arrival = Arrival{Process: tc.process, Rate: 0.01}
+
+		config := DefaultConfig()
+		config.Workload = workload
+		config.Warmup = 0
+		config.Requests = 10
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		started := time.Now()
+		_, err := Run(ctx, config, nopTarget{})
+		elapsed := time.Since(started)
+		cancel()
+
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: Run returned %v, want the context error", tc.name, err)
+		}
+		if elapsed > time.Second {
+			t.Errorf("%s: Run took %v to stop after cancellation", tc.name, elapsed)
+		}
+	}
+}
+
+func TestRunCapturesProfiles(t *testing.T) {
+	cases := []struct {
+		name    string
+		profile string
+	}{
+		{"cpu", "cpu"},
+		{"heap", "heap"},
+		{"all", "cpu, heap,trace"},
+	}
+
+	for _, tc := range cases {
+		profile := &ProfileConfig{Dir: filepath.Join(t.TempDir(), "profiles")}
+		if err := ParseProfiles(tc.profile, profile); err != nil {
+			t.Fatalf("%s: ParseProfiles: %v", tc.name, err)
+		}
+
+		config := DefaultConfig()
+		config.Name = tc.name
+		config.Requests = 100
+		config.Warmup = 0
+		config.Profile = profile
+		result, err := Run(context.Background(), config, nopTarget{})
+		if err != nil {
+			t.Fatalf("%s: Run: %v", tc.name, err)
+		}
+		if result.Profiles == nil {
+			t.Fatalf("%s: no profiles reported", tc.name)
+		}
+
+		for _, capture := range []struct {
+			enabled bool
+			path    string
+		}{
+			{profile.CPU, result.Profiles.CPU},
+			{profile.Heap, result.Profiles.Heap},
+			{profile.Trace, result.Profiles.Trace},
+		} {
+			if !capture.enabled {
+				if capture.path != "" {
+					t.Errorf("%s: unrequested capture %s", tc.name, capture.path)
+				}
+				continue
+			}
+			if info, err := os.Stat(capture.path); err != nil || info.Size() == 0 {
+				t.Errorf("%s: capture %q missing or empty (%v)", tc.name, capture.path, err)
+			}
+		}
+	}
+
+	if err := ParseProfiles("cpu,mutex", &ProfileConfig{}); err == nil {
+		t.Error("ParseProfiles accepted an unknown profile")
+	}
+}
+
+// slowTarget takes delay over every lookup
+type slowTarget struct {
+	delay time.Duration
+}
+
+func (st slowTarget) Lookup(ctx context.Context, request Request) (bool, error) {
+	time.Sleep(st.delay)
+	return true, nil
+}
+
+func TestRunReportsProgress(t *testing.T) {
+	config := DefaultConfig()
+	config.Requests = 200
+	config.Warmup = 0
+	config.Concurrency = 2
+	config.BaselineLatency = time.Second
+	config.ProgressInterval = 10 * time.Millisecond
+
+	var reports []Progress
+	config.Progress = func(p Progress) { reports = append(reports, p) }
+	var frames strings.Builder
+	dashboard := NewDashboard(&frames, config)
+
+	if _, err := Run(context.Background(), config, slowTarget{delay: time.Millisecond}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(reports) < 2 {
+		t.Fatalf("%d progress reports, want several over a run of about 100ms", len(reports))
+	}
+	for i, p := range reports {
+		if p.Total != config.Requests || p.Requests > int64(config.Requests) {
+			t.Errorf("report %d: %d of %d requests", i, p.Requests, p.Total)
+		}
+		if i > 0 && (p.Requests < reports[i-1].Requests || p.Elapsed <= reports[i-1].Elapsed) {
+			t.Errorf("report %d went backwards: %+v after %+v", i, p, reports[i-1])
+		}
+	}
+
+	last := reports[len(reports)-1]
+	if last.Recent.Count() == 0 || last.RequestsPerSecond <= 0 || last.CacheHits != last.Successful {
+		t.Errorf("last report %+v with %d recent latencies", last, last.Recent.Count())
+	}
+
+	dashboard.Update(reports[0])
+	dashboard.Update(last)
+	for _, want := range []string{"A\033[2K", "Cache Hit Rate:       100.00%", "Improvement Factor:", "on target"} {
+		if !strings.Contains(frames.String(), want) {
+			t.Errorf("dashboard frames missing %q:\n%s", want, frames.String())
+		}
+	}
+}
+
+func TestHTTPBaseline(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := ServeBaseline(listener)
+	defer remote.Close()
+
+	cases := []struct {
+		name    string
+		config  *HTTPBaselineConfig
+		wantErr string
+	}{
+		{"http1 on loopback", &HTTPBaselineConfig{Protocol: HTTP1}, ""},
+		{"http2 on loopback", &HTTPBaselineConfig{Protocol: HTTP2}, ""},
+		{"http1 to a server", &HTTPBaselineConfig{Protocol: HTTP1, Address: listener.Addr().String()}, ""},
+		{"http2 to a server", &HTTPBaselineConfig{Protocol: HTTP2, Address: listener.Addr().String()}, ""},
+		{"unknown protocol", &HTTPBaselineConfig{Protocol: "spdy"}, "unknown baseline protocol"},
+	}
+
+	for _, tc := range cases {
+		baseline, err := NewHTTPBaseline(tc.config)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s: NewHTTPBaseline returned %v, want an error containing %q", tc.name, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: NewHTTPBaseline: %v", tc.name, err)
+		}
+
+		config := DefaultConfig()
+		config.Requests = 50
+		config.Warmup = 5
+		config.Concurrency = 4
+		result, err := Run(context.Background(), config, baseline)
+		baseline.Close()
+		if err != nil {
+			t.Fatalf("%s: Run: %v", tc.name, err)
+		}
+		if result.Successful != 50 || result.AverageLatency <= 0 {
+			t.Errorf("%s: %d of 50 lookups succeeded averaging %v", tc.name, result.Successful, result.AverageLatency)
+		}
+	}
+
+	// The remote server outlives the baselines that measured against it
+	baseline, _ := NewHTTPBaseline(&HTTPBaselineConfig{Protocol: HTTP1, Address: listener.Addr().String()})
+	defer baseline.Close()
+	if _, err := baseline.Lookup(context.Background(), Request{Source: 1, Destination: 2}); err != nil {
+		t.Errorf("remote server stopped by a baseline: %v", err)
+	}
+}
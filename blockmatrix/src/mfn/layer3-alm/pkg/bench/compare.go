@@ -0,0 +1,183 @@
+// Package bench implements comparison of benchmark results against a stored
+// baseline run for regression gating
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// comparedMetric is a result figure compared between runs
+type comparedMetric struct {
+	name string
+
+	// lowerIsBetter is set for latencies and unset for rates
+	lowerIsBetter bool
+	value         func(r *Result) float64
+	format        func(value float64) string
+}
+
+func latencyMetric(name string, value func(r *Result) time.Duration) comparedMetric {
+	return comparedMetric{
+		name:          name,
+		lowerIsBetter: true,
+		value:         func(r *Result) float64 { return float64(value(r)) },
+		format:        func(value float64) string { return time.Duration(value).String() },
+	}
+}
+
+func rateMetric(name, unit string, value func(r *Result) float64) comparedMetric {
+	return comparedMetric{
+		name:  name,
+		value: value,
+		format: func(value float64) string {
+			return fmt.Sprintf("%.2f%s", value, unit)
+		},
+	}
+}
+
+// comparedMetrics are the figures a run is gated on
+var comparedMetrics = []comparedMetric{
+	latencyMetric("average_latency", func(r *Result) time.Duration { return r.AverageLatency }),
+	latencyMetric("p50_latency", func(r *Result) time.Duration { return r.P50Latency }),
+	latencyMetric("p90_latency", func(r *Result) time.Duration { return r.P90Latency }),
+	latencyMetric("p95_latency", func(r *Result) time.Duration { return r.P95Latency }),
+	latencyMetric("p99_latency", func(r *Result) time.Duration { return r.P99Latency }),
+	latencyMetric("p999_latency", func(r *Result) time.Duration { return r.P999Latency }),
+	rateMetric("requests_per_second", "", func(r *Result) float64 { return r.RequestsPerSecond }),
+	rateMetric("success_rate", "%", func(r *Result) float64 { return r.SuccessRate }),
+}
+
+// MetricChange is the change in one figure between two runs
+type MetricChange struct {
+	Name     string  `json:"name"`
+	Previous float64 `json:"previous"`
+	Current  float64 `json:"current"`
+
+	// Change is the relative change from Previous, positive when the
+	// figure got worse
+	Change    float64 `json:"change"`
+	Regressed bool    `json:"regressed"`
+}
+
+// formatValue formats a value of the figure, looked up by name so changes
+// decoded from JSON format the same as fresh ones
+func (mc MetricChange) formatValue(value float64) string {
+	for _, metric := range comparedMetrics {
+		if metric.name == mc.Name {
+			return metric.format(value)
+		}
+	}
+	return fmt.Sprintf("%g", value)
+}
+
+// Comparison is the outcome of comparing a run against a baseline run
+type Comparison struct {
+	Previous      string         `json:"previous"`
+	Current       string         `json:"current"`
+	MaxRegression float64        `json:"max_regression"`
+	Changes       []MetricChange `json:"changes"`
+	Regressions   int            `json:"regressions"`
+}
+
+// Regressed reports whether any figure got worse by more than MaxRegression
+func (c *Comparison) Regressed() bool {
+	return c.Regressions > 0
+}
+
+// Compare diffs current against previous. A figure regresses when it gets
+// worse by more than maxRegression, a fraction such as 0.05 for 5%. Figures
+// previous does not have are skipped. Runs against different targets,
+// workloads or topologies are not comparable and return an error.
+func Compare(previous, current *Result, maxRegression float64) (*Comparison, error) {
+	if previous.Name != current.Name {
+		return nil, fmt.Errorf("cannot compare a %s run against a %s baseline", current.Name, previous.Name)
+	}
+	if previous.Workload != current.Workload {
+		return nil, fmt.Errorf("cannot compare workload %q against a baseline of workload %q", current.Workload, previous.Workload)
+	}
+	if previous.Topology != current.Topology {
+		return nil, fmt.Errorf("cannot compare topology %q against a baseline of topology %q", current.Topology, previous.Topology)
+	}
+
+	comparison := &Comparison{
+		Previous:      previous.Name,
+		Current:       current.Name,
+		MaxRegression: maxRegression,
+	}
+
+	for _, metric := range comparedMetrics {
+		before := metric.value(previous)
+		after := metric.value(current)
+		if before == 0 {
+			continue
+		}
+
+		change := (after - before) / before
+		if !metric.lowerIsBetter {
+			change = (before - after) / before
+		}
+
+		regressed := change > maxRegression
+		if regressed {
+			comparison.Regressions++
+		}
+		comparison.Changes = append(comparison.Changes, MetricChange{
+			Name:      metric.name,
+			Previous:  before,
+			Current:   after,
+			Change:    change,
+			Regressed: regressed,
+		})
+	}
+	return comparison, nil
+}
+
+// ReadResult loads a result written by WriteJSON from the file at path
+func ReadResult(path string) (*Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read benchmark result: %w", err)
+	}
+
+	var result Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode benchmark result %s: %w", path, err)
+	}
+	return &result, nil
+}
+
+// ReportComparison writes a table of the changes in comparison to w
+func ReportComparison(w io.Writer, comparison *Comparison) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nCOMPARISON WITH PREVIOUS RUN (max regression %.1f%%):\n", comparison.MaxRegression*100)
+
+	table := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "  METRIC\tPREVIOUS\tCURRENT\tCHANGE\t\n")
+	for _, change := range comparison.Changes {
+		status := ""
+		switch {
+		case change.Regressed:
+			status = "REGRESSED"
+		case change.Change < 0:
+			status = "improved"
+		}
+		fmt.Fprintf(table, "  %s\t%s\t%s\t%+.1f%%\t%s\n",
+			change.Name, change.formatValue(change.Previous), change.formatValue(change.Current), change.Change*100, status)
+	}
+	table.Flush()
+
+	if comparison.Regressed() {
+		fmt.Fprintf(&b, "  Status:               FAILURE - %d metrics regressed\n", comparison.Regressions)
+	} else {
+		fmt.Fprintf(&b, "  Status:               SUCCESS - no regressions\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,106 @@
+package bench
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testResult(p99 time.Duration, rps float64) *Result {
+	return &Result{
+		Name:              "simulated",
+		Workload:          "uniform",
+		P99Latency:        p99,
+		RequestsPerSecond: rps,
+	}
+}
+
+func TestCompareGating(t *testing.T) {
+	cases := []struct {
+		name          string
+		current       *Result
+		maxRegression float64
+		wantRegressed []string
+	}{
+		{"unchanged", testResult(time.Millisecond, 1000), 0.05, nil},
+		{"latency within threshold", testResult(1049*time.Microsecond, 1000), 0.05, nil},
+		{"latency at threshold", testResult(1050*time.Microsecond, 1000), 0.05, nil},
+		{"latency over threshold", testResult(1051*time.Microsecond, 1000), 0.05, []string{"p99_latency"}},
+		{"latency improved", testResult(500*time.Microsecond, 1000), 0, nil},
+		{"throughput within threshold", testResult(time.Millisecond, 951), 0.05, nil},
+		{"throughput over threshold", testResult(time.Millisecond, 949), 0.05, []string{"requests_per_second"}},
+		{"throughput improved", testResult(time.Millisecond, 2000), 0, nil},
+		{"zero tolerance", testResult(1001*time.Microsecond, 999), 0, []string{"p99_latency", "requests_per_second"}},
+	}
+
+	previous := testResult(time.Millisecond, 1000)
+	for _, tc := range cases {
+		comparison, err := Compare(previous, tc.current, tc.maxRegression)
+		if err != nil {
+			t.Fatalf("%s: Compare: %v", tc.name, err)
+		}
+
+		var regressed []string
+		for _, change := range comparison.Changes {
+			if change.Regressed {
+				regressed = append(regressed, change.Name)
+			}
+		}
+		if strings.Join(regressed, ",") != strings.Join(tc.wantRegressed, ",") {
+			t.Errorf("%s: regressed %v, want %v", tc.name, regressed, tc.wantRegressed)
+		}
+		if comparison.Regressions != len(tc.wantRegressed) || comparison.Regressed() != (len(tc.wantRegressed) > 0) {
+			t.Errorf("%s: %d regressions counted, want %d", tc.name, comparison.Regressions, len(tc.wantRegressed))
+		}
+		// Figures the baseline does not have are skipped
+		if len(comparison.Changes) != 2 {
+			t.Errorf("%s: compared %d figures, want 2", tc.name, len(comparison.Changes))
+		}
+	}
+}
+
+func TestCompareRefusesMismatchedRuns(t *testing.T) {
+	cases := []struct {
+		name   string
+		mutate func(r *Result)
+	}{
+		{"different target", func(r *Result) { r.Name = "integrated" }},
+		{"different workload", func(r *Result) { r.Workload = "hotspot" }},
+		{"different topology", func(r *Result) { r.Topology = "global-cdn" }},
+	}
+
+	for _, tc := range cases {
+		current := testResult(time.Millisecond, 1000)
+		tc.mutate(current)
+		if _, err := Compare(testResult(time.Millisecond, 1000), current, 0.05); err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+}
+
+func TestReportComparisonAfterJSONRoundTrip(t *testing.T) {
+	comparison, err := Compare(testResult(time.Millisecond, 1000), testResult(2*time.Millisecond, 1000), 0.05)
+	if err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+
+	data, err := json.Marshal(comparison)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var decoded Comparison
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	var report strings.Builder
+	if err := ReportComparison(&report, &decoded); err != nil {
+		t.Fatalf("ReportComparison: %v", err)
+	}
+	for _, want := range []string{"1ms", "2ms", "1000.00", "REGRESSED", "FAILURE - 1 metrics regressed"} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, report.String())
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Package bench implements a live terminal dashboard of a run's progress
+package bench
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dashboardBarWidth is the width of the progress bar in characters
+const dashboardBarWidth = 40
+
+// Dashboard redraws a run's progress in place on a terminal: rolling
+// latency percentiles, throughput, cache hit rate and the improvement over
+// the baseline so far. Pass its Update as Config.Progress.
+type Dashboard struct {
+	mutex  sync.Mutex
+	w      io.Writer
+	config *Config
+	lines  int
+}
+
+// NewDashboard creates a dashboard of runs of config drawn on w, which
+// must be a terminal that understands ANSI escapes
+func NewDashboard(w io.Writer, config *Config) *Dashboard {
+	return &Dashboard{w: w, config: config}
+}
+
+// Update redraws the dashboard with progress
+func (d *Dashboard) Update(progress Progress) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	lines := d.render(progress)
+
+	var b strings.Builder
+	if d.lines > 0 {
+		// Back to the top of the previous frame
+		fmt.Fprintf(&b, "\033[%dA", d.lines)
+	}
+	for _, line := range lines {
+		fmt.Fprintf(&b, "\033[2K%s\n", line)
+	}
+	d.lines = len(lines)
+	io.WriteString(d.w, b.String())
+}
+
+// Close clears the dashboard so the final report replaces it
+func (d *Dashboard) Close() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.lines > 0 {
+		fmt.Fprintf(d.w, "\033[%dA\033[J", d.lines)
+		d.lines = 0
+	}
+}
+
+// render returns the lines of a frame
+func (d *Dashboard) render(progress Progress) []string {
+	done := 0.0
+	if progress.Total > 0 {
+		done = float64(progress.Requests) / float64(progress.Total)
+		if done > 1 {
+			done = 1
+		}
+	}
+	filled := int(done * dashboardBarWidth)
+	bar := strings.Repeat("#", filled) + strings.Repeat(".", dashboardBarWidth-filled)
+
+	name := d.config.Name
+	if name == "" {
+		name = "benchmark"
+	}
+	lines := []string{
+		fmt.Sprintf("%s  [%s] %5.1f%%  %d/%d  %v",
+			name, bar, done*100, progress.Requests, progress.Total, progress.Elapsed.Truncate(100*time.Millisecond)),
+		fmt.Sprintf("  Requests/Second:      %.0f", progress.RequestsPerSecond),
+	}
+
+	var successRate, cacheHitRate float64
+	if progress.Requests > 0 {
+		successRate = float64(progress.Successful) / float64(progress.Requests) * 100
+		cacheHitRate = float64(progress.CacheHits) / float64(progress.Requests) * 100
+	}
+	lines = append(lines,
+		fmt.Sprintf("  Success Rate:         %.2f%%", successRate),
+		fmt.Sprintf("  Cache Hit Rate:       %.2f%%", cacheHitRate),
+	)
+
+	recent := progress.Recent
+	if recent == nil || recent.Count() == 0 {
+		return append(lines, fmt.Sprintf("  Latency (last %v):   no lookups yet", ProgressWindow))
+	}
+	quantiles := recent.Quantiles(0.50, 0.99, 0.999)
+	lines = append(lines, fmt.Sprintf("  Latency (last %v):   avg %v  p50 %v  p99 %v  p99.9 %v",
+		ProgressWindow, recent.Mean(), quantiles[0], quantiles[1], quantiles[2]))
+
+	if baseline := d.config.BaselineLatency; baseline > 0 && recent.Mean() > 0 {
+		improvement := float64(baseline) / float64(recent.Mean())
+		status := "on target"
+		if improvement < d.config.TargetImprovement {
+			status = "below target"
+		}
+		lines = append(lines, fmt.Sprintf("  Improvement Factor:   %.2fx of %.2fx (%s)", improvement, d.config.TargetImprovement, status))
+	}
+	return lines
+}
@@ -0,0 +1,265 @@
+// Package bench implements benchmark runs spread across agents on several
+// machines, so the load a deployment is measured under is not limited by a
+// single client's CPU
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/histogram"
+)
+
+// AgentJobPath is where an agent accepts jobs
+const AgentJobPath = "/run"
+
+// Job is an agent's share of a distributed run: a benchmark of the gRPC API
+// at Address
+type Job struct {
+	Address string
+	Target  *GRPCTargetConfig
+	Config  *Config
+}
+
+// AgentResult is the outcome of one agent's job. Latencies carries the
+// agent's full distribution so the coordinator can merge them exactly.
+type AgentResult struct {
+	Agent     string               `json:"agent"`
+	Result    *Result              `json:"result"`
+	Latencies *histogram.Histogram `json:"latencies"`
+}
+
+// DistributedResult is a run spread across agents. Result merges the
+// agents' counts and latency distributions; its duration spans the first
+// agent starting to the last one finishing.
+type DistributedResult struct {
+	Result *Result
+	Agents []*AgentResult
+}
+
+// Agent runs benchmark jobs sent by a coordinator over HTTP: a POST to
+// AgentJobPath with a JSON Job replies with a JSON AgentResult once the job
+// is done. It runs one job at a time, and stops a job when the
+// coordinator's request is cancelled.
+type Agent struct {
+	name string
+	busy atomic.Bool
+
+	// newTarget builds the target of a job
+	newTarget func(job *Job) (Target, func(), error)
+}
+
+// NewAgent creates an agent reporting its results under name
+func NewAgent(name string) *Agent {
+	return &Agent{
+		name: name,
+		newTarget: func(job *Job) (Target, func(), error) {
+			target, err := NewGRPCTarget(job.Address, job.Target)
+			if err != nil {
+				return nil, nil, err
+			}
+			return target, func() { target.Close() }, nil
+		},
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (a *Agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != AgentJobPath {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "jobs must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var job Job
+	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+		http.Error(w, fmt.Sprintf("invalid job: %v", err), http.StatusBadRequest)
+		return
+	}
+	if job.Address == "" || job.Config == nil {
+		http.Error(w, "job needs an address and a config", http.StatusBadRequest)
+		return
+	}
+
+	if !a.busy.CompareAndSwap(false, true) {
+		http.Error(w, "agent is already running a job", http.StatusConflict)
+		return
+	}
+	defer a.busy.Store(false)
+
+	target, closeTarget, err := a.newTarget(&job)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer closeTarget()
+
+	result, err := Run(r.Context(), job.Config, target)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("run failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&AgentResult{Agent: a.name, Result: result, Latencies: result.Latencies})
+}
+
+// RunDistributed spreads a benchmark of the gRPC API at address across
+// agents, given as host:port or URL, and merges their results. Measured
+// and warmup lookups are divided among the agents, as is the rate of an
+// open-loop arrival process, so the totals match a run from one client;
+// Concurrency applies to each agent. Replays are not supported. The run
+// fails if any agent fails.
+func RunDistributed(ctx context.Context, config *Config, agents []string, address string, target *GRPCTargetConfig) (*DistributedResult, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if target == nil {
+		target = DefaultGRPCTargetConfig()
+	}
+	if len(agents) == 0 {
+		return nil, errors.New("distributed run needs at least one agent")
+	}
+	if config.Replay != nil {
+		return nil, errors.New("replays cannot be spread across agents")
+	}
+
+	workload := config.Workload
+	if workload == nil {
+		workload = DefaultWorkload()
+	}
+	if err := workload.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid workload: %w", err)
+	}
+
+	results := make([]*AgentResult, len(agents))
+	errs := make([]error, len(agents))
+	var wg sync.WaitGroup
+	for i, agent := range agents {
+		share := *config
+		share.Requests = splitShare(config.Requests, len(agents), i)
+		share.Warmup = splitShare(config.Warmup, len(agents), i)
+		shareWorkload := *workload
+		shareWorkload.Arrival.Rate = workload.Arrival.Rate / float64(len(agents))
+		share.Workload = &shareWorkload
+
+		wg.Add(1)
+		go func(i int, agent string, job *Job) {
+			defer wg.Done()
+			results[i], errs[i] = runAgentJob(ctx, agent, job)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("agent %s: %w", agent, errs[i])
+			}
+		}(i, agent, &Job{Address: address, Target: target, Config: &share})
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	merged := &Result{
+		Name:              config.Name,
+		Workload:          workload.Name,
+		Topology:          config.Topology,
+		BaselineLatency:   config.BaselineLatency,
+		TargetImprovement: config.TargetImprovement,
+		Latencies:         histogram.New(HighestLatency, 3),
+	}
+	var finished time.Time
+	for _, agent := range results {
+		r := agent.Result
+		if merged.Started.IsZero() || r.Started.Before(merged.Started) {
+			merged.Started = r.Started
+		}
+		if end := r.Started.Add(r.Duration); end.After(finished) {
+			finished = end
+		}
+		merged.Requests += r.Requests
+		merged.Successful += r.Successful
+		merged.CacheHits += r.CacheHits
+		merged.Writes += r.Writes
+		if err := merged.Latencies.Merge(agent.Latencies); err != nil {
+			return nil, fmt.Errorf("agent %s: %w", agent.Agent, err)
+		}
+	}
+	merged.Duration = finished.Sub(merged.Started)
+	merged.summarize()
+
+	return &DistributedResult{Result: merged, Agents: results}, nil
+}
+
+// runAgentJob sends job to agent and waits for its result
+func runAgentJob(ctx context.Context, agent string, job *Job) (*AgentResult, error) {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job: %w", err)
+	}
+
+	url := strings.TrimSuffix(agent, "/")
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url+AgentJobPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		return nil, fmt.Errorf("%s: %s", response.Status, strings.TrimSpace(string(message)))
+	}
+
+	var result AgentResult
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode result: %w", err)
+	}
+	if result.Result == nil || result.Latencies == nil {
+		return nil, errors.New("result is incomplete")
+	}
+	result.Result.Latencies = result.Latencies
+	return &result, nil
+}
+
+// splitShare returns the share of total given to part i of n, with the
+// remainder going to the first parts
+func splitShare(total, n, i int) int {
+	share := total / n
+	if i < total%n {
+		share++
+	}
+	return share
+}
+
+// ReportAgents writes each agent's share of a distributed run to w
+func ReportAgents(w io.Writer, r *DistributedResult) error {
+	fmt.Fprintf(w, "\nAgents: %d\n", len(r.Agents))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Agent\tRequests\tRequests/s\tP50\tP99\tSuccess\t")
+	for _, agent := range r.Agents {
+		result := agent.Result
+		fmt.Fprintf(tw, "%s\t%d\t%.0f\t%v\t%v\t%.2f%%\t\n",
+			agent.Agent, result.Requests, result.RequestsPerSecond, result.P50Latency, result.P99Latency, result.SuccessRate)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,126 @@
+package bench
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestAgent serves an agent whose jobs look routes up from nopTarget
+func newTestAgent(t *testing.T, name string) (*Agent, string) {
+	t.Helper()
+
+	agent := NewAgent(name)
+	agent.newTarget = func(job *Job) (Target, func(), error) {
+		return nopTarget{}, func() {}, nil
+	}
+	server := httptest.NewServer(agent)
+	t.Cleanup(server.Close)
+	return agent, server.URL
+}
+
+func TestRunDistributed(t *testing.T) {
+	cases := []struct {
+		name         string
+		agents       int
+		requests     int
+		wantRequests []int64
+	}{
+		{"single agent", 1, 100, []int64{100}},
+		{"even split", 2, 100, []int64{50, 50}},
+		{"remainder to the first agents", 3, 101, []int64{34, 34, 33}},
+	}
+
+	for _, tc := range cases {
+		var agents []string
+		for i := 0; i < tc.agents; i++ {
+			_, url := newTestAgent(t, string(rune('a'+i)))
+			agents = append(agents, url)
+		}
+
+		config := DefaultConfig()
+		config.Requests = tc.requests
+		config.Warmup = 10
+		config.Concurrency = 4
+		result, err := RunDistributed(context.Background(), config, agents, "deployment:9090", nil)
+		if err != nil {
+			t.Errorf("%s: RunDistributed: %v", tc.name, err)
+			continue
+		}
+
+		for i, agent := range result.Agents {
+			if agent.Agent != string(rune('a'+i)) || agent.Result.Requests != tc.wantRequests[i] {
+				t.Errorf("%s: agent %d is %q with %d requests, want %q with %d",
+					tc.name, i, agent.Agent, agent.Result.Requests, string(rune('a'+i)), tc.wantRequests[i])
+			}
+		}
+		merged := result.Result
+		if merged.Requests != int64(tc.requests) || merged.Latencies.Count() != int64(tc.requests) {
+			t.Errorf("%s: merged %d requests with %d latencies, want %d", tc.name, merged.Requests, merged.Latencies.Count(), tc.requests)
+		}
+		if merged.Successful != int64(tc.requests) || merged.Duration <= 0 || merged.P50Latency <= 0 {
+			t.Errorf("%s: merged result not summarized: %+v", tc.name, merged)
+		}
+	}
+}
+
+func TestRunDistributedFailures(t *testing.T) {
+	busy, busyURL := newTestAgent(t, "busy")
+	busy.busy.Store(true)
+	_, idleURL := newTestAgent(t, "idle")
+
+	replay := DefaultConfig()
+	replay.Replay = &Replay{}
+
+	cases := []struct {
+		name    string
+		config  *Config
+		agents  []string
+		wantErr string
+	}{
+		{"no agents", DefaultConfig(), nil, "at least one agent"},
+		{"replay", replay, []string{idleURL}, "replays cannot be spread"},
+		{"busy agent", DefaultConfig(), []string{idleURL, busyURL}, "409 Conflict: agent is already running a job"},
+		{"unreachable agent", DefaultConfig(), []string{"127.0.0.1:1"}, "agent 127.0.0.1:1"},
+	}
+
+	for _, tc := range cases {
+		tc.config.Requests = 10
+		tc.config.Warmup = 0
+		_, err := RunDistributed(context.Background(), tc.config, tc.agents, "deployment:9090", nil)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: RunDistributed returned %v, want an error containing %q", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestAgentRejectsInvalidJobs(t *testing.T) {
+	_, url := newTestAgent(t, "agent")
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"wrong method", http.MethodGet, AgentJobPath, "", http.StatusMethodNotAllowed},
+		{"wrong path", http.MethodPost, "/jobs", "{}", http.StatusNotFound},
+		{"malformed job", http.MethodPost, AgentJobPath, "{", http.StatusBadRequest},
+		{"missing address", http.MethodPost, AgentJobPath, `{"Config":{}}`, http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		request, _ := http.NewRequest(tc.method, url+tc.path, strings.NewReader(tc.body))
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		response.Body.Close()
+		if response.StatusCode != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, response.StatusCode, tc.want)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+// Package bench implements fault injection during benchmark runs, measuring
+// how far latency degrades under a fault and how long it takes to recover
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/histogram"
+	"gopkg.in/yaml.v3"
+)
+
+// Fault kinds
+const (
+	// NodeFailure removes nodes with their links until repaired
+	NodeFailure = "node_failure"
+
+	// LatencySpike multiplies the latency of a fraction of links until
+	// repaired
+	LatencySpike = "latency_spike"
+
+	// CacheFlush empties the route cache; it needs no repair, so recovery
+	// is the time the cache takes to warm up again
+	CacheFlush = "cache_flush"
+)
+
+const (
+	// FaultSlice is the resolution recovery is measured at
+	FaultSlice = 100 * time.Millisecond
+
+	// faultBaselineWindow is how long before a fault latency is taken as
+	// its baseline
+	faultBaselineWindow = time.Second
+
+	// RecoveryTolerance is how far above its baseline p99 latency may stay
+	// and still count as recovered
+	RecoveryTolerance = 1.5
+)
+
+// Fault is a failure injected into a run
+type Fault struct {
+	Kind string `yaml:"kind"`
+
+	// At is when the fault is injected, from the start of measuring. The
+	// second before it is the baseline its impact is measured against.
+	At time.Duration `yaml:"at"`
+
+	// Duration is how long the fault lasts before it is repaired
+	Duration time.Duration `yaml:"duration"`
+
+	// Nodes is how many nodes a node failure takes down
+	Nodes int `yaml:"nodes"`
+
+	// Fraction of links a latency spike slows, and the Factor it
+	// multiplies their latency by
+	Fraction float64 `yaml:"fraction"`
+	Factor   float64 `yaml:"factor"`
+}
+
+// Validate checks the fault
+func (f *Fault) Validate() error {
+	if f.At <= 0 {
+		return fmt.Errorf("%s must be injected after measuring starts", f.Kind)
+	}
+	switch f.Kind {
+	case NodeFailure:
+		if f.Nodes < 1 {
+			return errors.New("node failure needs at least 1 node")
+		}
+	case LatencySpike:
+		if f.Fraction <= 0 || f.Fraction > 1 {
+			return errors.New("latency spike fraction must be above 0 and at most 1")
+		}
+		if f.Factor <= 1 {
+			return errors.New("latency spike factor must be above 1")
+		}
+	case CacheFlush:
+		return nil
+	default:
+		return fmt.Errorf("unknown fault kind %q: expected %s, %s or %s", f.Kind, NodeFailure, LatencySpike, CacheFlush)
+	}
+	if f.Duration <= 0 {
+		return fmt.Errorf("%s needs a duration", f.Kind)
+	}
+	return nil
+}
+
+// LoadFaults reads a fault scenario, a YAML list of faults under "faults",
+// from the file at path
+func LoadFaults(path string) ([]Fault, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fault scenario: %w", err)
+	}
+
+	var scenario struct {
+		Faults []Fault `yaml:"faults"`
+	}
+	if err := yaml.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("failed to parse fault scenario: %w", err)
+	}
+	if len(scenario.Faults) == 0 {
+		return nil, errors.New("fault scenario has no faults")
+	}
+	for i := range scenario.Faults {
+		if err := scenario.Faults[i].Validate(); err != nil {
+			return nil, fmt.Errorf("invalid fault %d: %w", i+1, err)
+		}
+	}
+	return scenario.Faults, nil
+}
+
+// Injector is implemented by targets faults can be injected into
+type Injector interface {
+	// Inject applies fault and returns what repairs it
+	Inject(ctx context.Context, fault Fault) (repair func() error, err error)
+}
+
+// FaultResult is the impact of a fault on a run. Times are from the start
+// of measuring.
+type FaultResult struct {
+	Kind     string `json:"kind"`
+	Injected bool   `json:"injected"`
+	Error    string `json:"error,omitempty"`
+
+	InjectedAt time.Duration `json:"injected_at_ns"`
+	RepairedAt time.Duration `json:"repaired_at_ns"`
+
+	// BaselineP99 is p99 latency in the second before the fault and
+	// PeakP99 the worst p99 of a FaultSlice from then until recovery
+	BaselineP99 time.Duration `json:"baseline_p99_ns"`
+	PeakP99     time.Duration `json:"peak_p99_ns"`
+
+	// Failures is the number of lookups that failed from injection until
+	// recovery
+	Failures int64 `json:"failures"`
+
+	// RecoveryTime is from repair until lookups succeed again within
+	// RecoveryTolerance of the baseline p99
+	Recovered    bool          `json:"recovered"`
+	RecoveryTime time.Duration `json:"recovery_time_ns"`
+}
+
+// Impact returns how many times the baseline p99 the peak p99 reached
+func (fr *FaultResult) Impact() float64 {
+	if fr.BaselineP99 <= 0 {
+		return 0
+	}
+	return float64(fr.PeakP99) / float64(fr.BaselineP99)
+}
+
+// timeline records lookups in FaultSlice slices from the start of
+// measuring. Each worker records into its own, merged once the run is over.
+type timeline struct {
+	started   time.Time
+	latencies []*histogram.Histogram
+	failures  []int64
+}
+
+func newTimeline(started time.Time) *timeline {
+	return &timeline{started: started}
+}
+
+// record counts a lookup that finished at
+func (t *timeline) record(at time.Time, latency time.Duration, failed bool) {
+	slice := int(at.Sub(t.started) / FaultSlice)
+	if slice < 0 {
+		slice = 0
+	}
+
+	t.grow(slice + 1)
+	if failed {
+		t.failures[slice]++
+		return
+	}
+	t.latencies[slice].Record(latency)
+}
+
+// grow extends the timeline to at least slices slices
+func (t *timeline) grow(slices int) {
+	for len(t.latencies) < slices {
+		t.latencies = append(t.latencies, histogram.New(HighestLatency, 2))
+		t.failures = append(t.failures, 0)
+	}
+}
+
+// merge adds the lookups of other, which started at the same time
+func (t *timeline) merge(other *timeline) {
+	t.grow(len(other.latencies))
+	for slice, latencies := range other.latencies {
+		t.latencies[slice].Merge(latencies)
+		t.failures[slice] += other.failures[slice]
+	}
+}
+
+// injection is a fault as it happened
+type injection struct {
+	fault      Fault
+	injected   bool
+	err        error
+	injectedAt time.Duration
+	repairedAt time.Duration
+}
+
+// injectFaults injects each fault into injector at its time from started,
+// repairing it once it has lasted its duration or stop is closed, and
+// returns when each was injected and repaired
+func injectFaults(ctx context.Context, injector Injector, faults []Fault, started time.Time, stop <-chan struct{}) []*injection {
+	injections := make([]*injection, len(faults))
+	var wg sync.WaitGroup
+	for i, fault := range faults {
+		injections[i] = &injection{fault: fault}
+		wg.Add(1)
+		go func(in *injection) {
+			defer wg.Done()
+
+			wait := func(until time.Time) bool {
+				timer := time.NewTimer(time.Until(until))
+				defer timer.Stop()
+				select {
+				case <-timer.C:
+					return true
+				case <-stop:
+					return false
+				}
+			}
+
+			if !wait(started.Add(in.fault.At)) {
+				return
+			}
+			in.injectedAt = time.Since(started)
+			repair, err := injector.Inject(ctx, in.fault)
+			if err != nil {
+				in.err = err
+				return
+			}
+			in.injected = true
+
+			if repair != nil {
+				wait(started.Add(in.fault.At + in.fault.Duration))
+				in.err = repair()
+			}
+			in.repairedAt = time.Since(started)
+		}(injections[i])
+	}
+	wg.Wait()
+	return injections
+}
+
+// analyze measures the impact of each injection on the lookups in t
+func (t *timeline) analyze(injections []*injection) []*FaultResult {
+	var results []*FaultResult
+	for _, in := range injections {
+		result := &FaultResult{
+			Kind:       in.fault.Kind,
+			Injected:   in.injected,
+			InjectedAt: in.injectedAt,
+			RepairedAt: in.repairedAt,
+		}
+		results = append(results, result)
+		if in.err != nil {
+			result.Error = in.err.Error()
+		}
+		if !in.injected {
+			continue
+		}
+
+		injected := int(in.injectedAt / FaultSlice)
+		baseline := histogram.New(HighestLatency, 2)
+		for slice := injected - int(faultBaselineWindow/FaultSlice); slice < injected && slice < len(t.latencies); slice++ {
+			if slice >= 0 {
+				baseline.Merge(t.latencies[slice])
+			}
+		}
+		if baseline.Count() > 0 {
+			result.BaselineP99 = baseline.Quantile(0.99)
+		}
+
+		repaired := int(in.repairedAt / FaultSlice)
+		for slice := injected; slice < len(t.latencies); slice++ {
+			latencies, failures := t.latencies[slice], t.failures[slice]
+			result.Failures += failures
+			p99 := latencies.Quantile(0.99)
+			if latencies.Count() > 0 && p99 > result.PeakP99 {
+				result.PeakP99 = p99
+			}
+
+			if slice <= repaired || result.BaselineP99 == 0 {
+				continue
+			}
+			if failures == 0 && latencies.Count() > 0 && float64(p99) <= float64(result.BaselineP99)*RecoveryTolerance {
+				result.Recovered = true
+				result.RecoveryTime = time.Duration(slice+1)*FaultSlice - in.repairedAt
+				if result.RecoveryTime < 0 {
+					result.RecoveryTime = 0
+				}
+				break
+			}
+		}
+	}
+	return results
+}
@@ -0,0 +1,144 @@
+package bench
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// faultyTarget slows its lookups while a latency spike is injected and
+// fails them while nodes are down
+type faultyTarget struct {
+	mutex    sync.Mutex
+	delay    time.Duration
+	down     bool
+	injected []string
+}
+
+func (ft *faultyTarget) Lookup(ctx context.Context, request Request) (bool, error) {
+	ft.mutex.Lock()
+	delay, down := ft.delay, ft.down
+	ft.mutex.Unlock()
+
+	time.Sleep(delay)
+	if down {
+		return false, context.DeadlineExceeded
+	}
+	return false, nil
+}
+
+func (ft *faultyTarget) Inject(ctx context.Context, fault Fault) (func() error, error) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	ft.injected = append(ft.injected, fault.Kind)
+	switch fault.Kind {
+	case LatencySpike:
+		ft.delay *= time.Duration(fault.Factor)
+	case NodeFailure:
+		ft.down = true
+	default:
+		return nil, nil
+	}
+	return func() error {
+		ft.mutex.Lock()
+		defer ft.mutex.Unlock()
+		ft.delay, ft.down = time.Millisecond, false
+		return nil
+	}, nil
+}
+
+func TestRunInjectsFaults(t *testing.T) {
+	cases := []struct {
+		name         string
+		fault        Fault
+		wantFailures bool
+		wantImpact   float64
+	}{
+		{"latency spike", Fault{Kind: LatencySpike, At: 1100 * time.Millisecond, Duration: 200 * time.Millisecond, Fraction: 1, Factor: 50}, false, 3},
+		{"node failure", Fault{Kind: NodeFailure, At: 1100 * time.Millisecond, Duration: 200 * time.Millisecond, Nodes: 1}, true, 0},
+		{"cache flush", Fault{Kind: CacheFlush, At: 1100 * time.Millisecond}, false, 0},
+	}
+
+	for _, tc := range cases {
+		target := &faultyTarget{delay: time.Millisecond}
+		workload := DefaultWorkload()
+		workload.Arrival = Arrival{Process: Constant, Rate: 1000}
+
+		config := DefaultConfig()
+		config.Workload = workload
+		config.Requests = 1600
+		config.Warmup = 0
+		config.Concurrency = 20
+		config.Faults = []Fault{tc.fault}
+		result, err := Run(context.Background(), config, target)
+		if err != nil {
+			t.Fatalf("%s: Run: %v", tc.name, err)
+		}
+
+		if len(result.Faults) != 1 || strings.Join(target.injected, ",") != tc.fault.Kind {
+			t.Fatalf("%s: injected %v with results %v", tc.name, target.injected, result.Faults)
+		}
+		fault := result.Faults[0]
+		if !fault.Injected || fault.Error != "" || fault.BaselineP99 <= 0 {
+			t.Errorf("%s: fault result %+v", tc.name, *fault)
+		}
+		if !fault.Recovered || fault.RecoveryTime > time.Second {
+			t.Errorf("%s: recovered %v after %v", tc.name, fault.Recovered, fault.RecoveryTime)
+		}
+		if (fault.Failures > 0) != tc.wantFailures {
+			t.Errorf("%s: %d failed lookups", tc.name, fault.Failures)
+		}
+		if fault.Impact() < tc.wantImpact {
+			t.Errorf("%s: impact %.1fx, want at least %.1fx", tc.name, fault.Impact(), tc.wantImpact)
+		}
+	}
+}
+
+func TestRunRejectsFaultsItCannotInject(t *testing.T) {
+	config := DefaultConfig()
+	config.Faults = []Fault{{Kind: CacheFlush, At: time.Second}}
+	if _, err := Run(context.Background(), config, nopTarget{}); err == nil {
+		t.Error("Run accepted faults for a target that cannot inject them")
+	}
+}
+
+func TestLoadFaults(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"valid", "faults:\n  - kind: node_failure\n    at: 2s\n    duration: 1s\n    nodes: 2\n  - kind: cache_flush\n    at: 5s\n", ""},
+		{"empty", "faults: []\n", "no faults"},
+		{"unknown kind", "faults:\n  - kind: meteor\n    at: 1s\n", "unknown fault kind"},
+		{"no duration", "faults:\n  - kind: latency_spike\n    at: 1s\n    fraction: 0.5\n    factor: 3\n", "needs a duration"},
+		{"at start", "faults:\n  - kind: cache_flush\n", "after measuring starts"},
+	}
+
+	for _, tc := range cases {
+		path := filepath.Join(t.TempDir(), "faults.yaml")
+		if err := os.WriteFile(path, []byte(tc.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		faults, err := LoadFaults(path)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s: LoadFaults returned %v, want an error containing %q", tc.name, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || len(faults) != 2 || faults[0].Duration != time.Second || faults[1].At != 5*time.Second {
+			t.Errorf("%s: loaded %+v (%v)", tc.name, faults, err)
+		}
+	}
+
+	if _, err := LoadFaults("../../cmd/alm-bench/faults/resilience.yaml"); err != nil {
+		t.Errorf("example scenario: %v", err)
+	}
+}
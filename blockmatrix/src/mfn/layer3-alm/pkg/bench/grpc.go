@@ -0,0 +1,72 @@
+// Package bench implements a benchmark target that drives a running ALM
+// deployment through its gRPC API
+package bench
+
+import (
+	"context"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/api"
+)
+
+// GRPCTargetConfig configures the lookups a GRPCTarget makes
+type GRPCTargetConfig struct {
+	// Service type and hop limit of every route request
+	ServiceType string
+	MaxHops     int
+
+	// Client options; nil uses api.DefaultGRPCClientConfig. Not sent to
+	// benchmark agents, which dial with the defaults.
+	Client *api.GRPCClientConfig `json:"-"`
+}
+
+// GRPCTarget looks routes up from a running coordinator through its gRPC
+// API. Lookups go between node IDs 1 to Config.Nodes, which must exist in
+// the deployment's topology. It does not implement Updater: a benchmark
+// must not reshape a real deployment, so workload writes are skipped.
+type GRPCTarget struct {
+	address string
+	config  *GRPCTargetConfig
+	client  *api.GRPCClient
+}
+
+// NewGRPCTarget connects to the API server at address
+func NewGRPCTarget(address string, config *GRPCTargetConfig) (*GRPCTarget, error) {
+	if config == nil {
+		config = DefaultGRPCTargetConfig()
+	}
+
+	client, err := api.DialGRPC(address, config.Client)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCTarget{address: address, config: config, client: client}, nil
+}
+
+// Lookup implements Target
+func (gt *GRPCTarget) Lookup(ctx context.Context, request Request) (bool, error) {
+	response, err := gt.client.FindOptimalRoute(ctx, internal.RouteRequest{
+		SourceID:      request.Source,
+		DestinationID: request.Destination,
+		ServiceType:   gt.config.ServiceType,
+		QoSClass:      int(request.QoSClass),
+		MaxHops:       gt.config.MaxHops,
+	})
+	if err != nil {
+		return false, err
+	}
+	return response.CacheHit, nil
+}
+
+// Close closes the connection
+func (gt *GRPCTarget) Close() error {
+	return gt.client.Close()
+}
+
+// DefaultGRPCTargetConfig returns the default gRPC target configuration
+func DefaultGRPCTargetConfig() *GRPCTargetConfig {
+	return &GRPCTargetConfig{
+		ServiceType: "api",
+		MaxHops:     10,
+	}
+}
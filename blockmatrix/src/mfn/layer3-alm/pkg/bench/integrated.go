@@ -0,0 +1,295 @@
+// Package bench implements benchmarking of the real routing table over a
+// generated topology
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/associative"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/optimization"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+// IntegratedTarget benchmarks routing.RoutingTable with the associative
+// search engine and multi-objective optimizer over a random topology or a
+// topology profile
+type IntegratedTarget struct {
+	graph    *graph.NetworkGraph
+	table    *routing.RoutingTable
+	nodes    int
+	topology *Topology
+
+	// rng draws a topology profile's metrics; it is not safe for
+	// concurrent use, so mutex guards it
+	mutex sync.Mutex
+	rng   *rand.Rand
+}
+
+// NewIntegratedTarget builds a topology of nodes numbered from 1 joined in a
+// ring, so every pair is reachable, plus connections random edges
+func NewIntegratedTarget(nodes, connections int, level routing.OptimizationLevel) (*IntegratedTarget, error) {
+	if nodes < 2 {
+		return nil, fmt.Errorf("integrated benchmark needs at least 2 nodes, got %d", nodes)
+	}
+
+	networkGraph := graph.NewNetworkGraph(nodes)
+	for i := 1; i <= nodes; i++ {
+		node := &graph.NetworkNode{
+			ID:          int64(i),
+			Address:     fmt.Sprintf("node-%d", i),
+			Region:      fmt.Sprintf("region-%d", (i-1)%5+1),
+			Latency:     time.Duration(5+rand.Intn(45)) * time.Millisecond,
+			Throughput:  100 + rand.Float64()*900,
+			Reliability: 0.95 + rand.Float64()*0.05,
+			LoadFactor:  rand.Float64() * 0.5,
+			LastSeen:    time.Now(),
+			Services:    make(map[string]graph.ServiceInfo),
+		}
+		if err := networkGraph.AddNode(node); err != nil {
+			networkGraph.Close()
+			return nil, fmt.Errorf("failed to add node: %w", err)
+		}
+	}
+
+	addEdge := func(from, to int64) error {
+		latency := time.Duration(1+rand.Intn(20)) * time.Millisecond
+		return networkGraph.AddEdge(&graph.NetworkEdge{
+			From:        from,
+			To:          to,
+			Weight:      float64(latency.Microseconds()),
+			Latency:     latency,
+			Bandwidth:   100 + rand.Float64()*900,
+			PacketLoss:  rand.Float64() * 0.01,
+			Cost:        rand.Float64(),
+			Reliability: 0.95 + rand.Float64()*0.05,
+			Stability:   0.9 + rand.Float64()*0.1,
+			LastUpdate:  time.Now(),
+		})
+	}
+	for i := 1; i <= nodes; i++ {
+		next := int64(i%nodes + 1)
+		if err := addEdge(int64(i), next); err != nil {
+			networkGraph.Close()
+			return nil, fmt.Errorf("failed to add edge: %w", err)
+		}
+		if err := addEdge(next, int64(i)); err != nil {
+			networkGraph.Close()
+			return nil, fmt.Errorf("failed to add edge: %w", err)
+		}
+	}
+	for i := 0; i < connections; i++ {
+		from := rand.Intn(nodes) + 1
+		to := (from+rand.Intn(nodes-1))%nodes + 1
+		if err := addEdge(int64(from), int64(to)); err != nil {
+			networkGraph.Close()
+			return nil, fmt.Errorf("failed to add edge: %w", err)
+		}
+	}
+
+	return newIntegratedTarget(networkGraph, nodes, level), nil
+}
+
+// NewTopologyTarget builds the topology profile's graph. Its nodes are
+// numbered from 1 to topology.Nodes, which a benchmark's Config.Nodes
+// should match.
+func NewTopologyTarget(topology *Topology, level routing.OptimizationLevel) (*IntegratedTarget, error) {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	networkGraph, err := topology.Build(rng)
+	if err != nil {
+		return nil, err
+	}
+
+	target := newIntegratedTarget(networkGraph, topology.Nodes, level)
+	target.topology = topology
+	target.rng = rng
+	return target, nil
+}
+
+// newIntegratedTarget routes over networkGraph
+func newIntegratedTarget(networkGraph *graph.NetworkGraph, nodes int, level routing.OptimizationLevel) *IntegratedTarget {
+	routingConfig := routing.DefaultRoutingConfig()
+	routingConfig.OptimizationLevel = level
+
+	return &IntegratedTarget{
+		graph: networkGraph,
+		table: routing.NewRoutingTable(
+			networkGraph,
+			associative.NewAssociativeSearchEngine(networkGraph, nil),
+			optimization.NewMultiObjectiveOptimizer(optimization.DefaultOptimizerConfig()),
+			routingConfig,
+		),
+		nodes: nodes,
+	}
+}
+
+// Lookup implements Target
+func (it *IntegratedTarget) Lookup(ctx context.Context, request Request) (bool, error) {
+	response, err := it.table.LookupRoute(routing.RoutingRequest{
+		Source:      request.Source,
+		Destination: request.Destination,
+		ServiceType: "api",
+		QoSClass:    request.QoSClass,
+		Constraints: routing.RouteConstraints{
+			MaxHops: 10,
+		},
+		Context: ctx,
+	})
+	if err != nil {
+		return false, err
+	}
+	return response.CacheHit, nil
+}
+
+// Update implements Updater by changing the latency of the ring edge
+// leaving source and invalidating the cached routes over it
+func (it *IntegratedTarget) Update(ctx context.Context, source, destination int64) error {
+	next := source%int64(it.nodes) + 1
+	metrics := graph.EdgeMetrics{
+		Latency:     time.Duration(1+rand.Intn(20)) * time.Millisecond,
+		Bandwidth:   100 + rand.Float64()*900,
+		PacketLoss:  rand.Float64() * 0.01,
+		Reliability: 0.95 + rand.Float64()*0.05,
+	}
+	if t := it.topology; t != nil {
+		// Drawn like the link's original metrics
+		it.mutex.Lock()
+		metrics.Latency = t.IntraRegionLatency.sample(it.rng)
+		if t.region(source) != t.region(next) {
+			metrics.Latency = t.InterRegionLatency.sample(it.rng)
+		}
+		metrics.Bandwidth = t.MinBandwidth + it.rng.Float64()*(t.MaxBandwidth-t.MinBandwidth)
+		metrics.PacketLoss = t.FailureRate * (0.5 + it.rng.Float64())
+		it.mutex.Unlock()
+		metrics.Reliability = 1 - metrics.PacketLoss
+	}
+	err := it.graph.UpdateEdgeMetrics(source, next, metrics)
+	if err != nil {
+		return err
+	}
+
+	it.table.InvalidateTopology(routing.TopologyChange{
+		Links: []routing.Link{{From: source, To: next}},
+	})
+	return nil
+}
+
+// Inject implements Injector
+func (it *IntegratedTarget) Inject(ctx context.Context, fault Fault) (func() error, error) {
+	switch fault.Kind {
+	case NodeFailure:
+		return it.failNodes(fault.Nodes)
+	case LatencySpike:
+		return it.spikeLatency(fault.Fraction, fault.Factor)
+	case CacheFlush:
+		it.table.InvalidateCache()
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown fault kind %q", fault.Kind)
+	}
+}
+
+// failNodes removes count random nodes with their links, returning what
+// restores them
+func (it *IntegratedTarget) failNodes(count int) (func() error, error) {
+	nodes := it.graph.Nodes()
+	if count > len(nodes)-2 {
+		return nil, fmt.Errorf("cannot fail %d of %d nodes", count, len(nodes))
+	}
+	rand.Shuffle(len(nodes), func(i, j int) { nodes[i], nodes[j] = nodes[j], nodes[i] })
+	failed := nodes[:count]
+
+	ids := make(map[int64]bool, count)
+	change := routing.TopologyChange{}
+	for _, node := range failed {
+		ids[node.ID] = true
+		change.Nodes = append(change.Nodes, node.ID)
+	}
+	var links []*graph.NetworkEdge
+	for _, edge := range it.graph.Edges() {
+		if ids[edge.From] || ids[edge.To] {
+			links = append(links, edge)
+		}
+	}
+
+	for _, node := range failed {
+		if err := it.graph.RemoveNode(node.ID); err != nil {
+			return nil, err
+		}
+	}
+	it.table.InvalidateTopology(change)
+
+	return func() error {
+		var errs []error
+		for _, node := range failed {
+			errs = append(errs, it.graph.AddNode(node))
+		}
+		for _, edge := range links {
+			if err := it.graph.AddEdge(edge); err != nil {
+				// A link to a node failed by another fault comes back with it
+				if _, exists := it.graph.GetNode(edge.From); exists {
+					if _, exists := it.graph.GetNode(edge.To); exists {
+						errs = append(errs, err)
+					}
+				}
+			}
+		}
+		it.table.InvalidateTopology(change)
+		return errors.Join(errs...)
+	}, nil
+}
+
+// spikeLatency multiplies the latency of a random fraction of links by
+// factor, returning what restores them
+func (it *IntegratedTarget) spikeLatency(fraction, factor float64) (func() error, error) {
+	edges := it.graph.Edges()
+	rand.Shuffle(len(edges), func(i, j int) { edges[i], edges[j] = edges[j], edges[i] })
+	edges = edges[:int(math.Ceil(fraction*float64(len(edges))))]
+
+	change := routing.TopologyChange{}
+	original := make([]graph.EdgeMetrics, len(edges))
+	for i, edge := range edges {
+		original[i] = graph.EdgeMetrics{
+			Latency:     edge.Latency,
+			Bandwidth:   edge.Bandwidth,
+			PacketLoss:  edge.PacketLoss,
+			Jitter:      edge.Jitter,
+			Reliability: edge.Reliability,
+		}
+		spiked := original[i]
+		spiked.Latency = time.Duration(float64(spiked.Latency) * factor)
+		if err := it.graph.UpdateEdgeMetrics(edge.From, edge.To, spiked); err != nil {
+			return nil, err
+		}
+		change.Links = append(change.Links, routing.Link{From: edge.From, To: edge.To})
+	}
+	it.table.InvalidateTopology(change)
+
+	return func() error {
+		var errs []error
+		for i, edge := range edges {
+			errs = append(errs, it.graph.UpdateEdgeMetrics(edge.From, edge.To, original[i]))
+		}
+		it.table.InvalidateTopology(change)
+		return errors.Join(errs...)
+	}, nil
+}
+
+// Sizes implements Sizer with the route and path cache sizes
+func (it *IntegratedTarget) Sizes() map[string]int {
+	return map[string]int{
+		"route_cache_entries": it.table.GetRouteCacheStats().Size,
+		"path_cache_entries":  it.graph.GetPathCacheStats().Size,
+	}
+}
+
+// Close stops the topology's update processing
+func (it *IntegratedTarget) Close() {
+	it.graph.Close()
+}
@@ -0,0 +1,176 @@
+// Package bench implements machine-readable output of benchmark results for
+// CI and dashboards
+package bench
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// column is one field of a result in CSV and Prometheus output
+type column struct {
+	name  string
+	help  string
+	value func(r *Result) float64
+}
+
+// columns lists the numeric result fields in output order. Latencies are
+// reported in seconds.
+var columns = []column{
+	{"duration_seconds", "Wall time of the measured run", func(r *Result) float64 { return r.Duration.Seconds() }},
+	{"requests", "Lookups made", func(r *Result) float64 { return float64(r.Requests) }},
+	{"successful", "Lookups that returned a route", func(r *Result) float64 { return float64(r.Successful) }},
+	{"cache_hits", "Lookups served from a cache", func(r *Result) float64 { return float64(r.CacheHits) }},
+	{"average_latency_seconds", "Mean lookup latency", func(r *Result) float64 { return r.AverageLatency.Seconds() }},
+	{"min_latency_seconds", "Fastest lookup", func(r *Result) float64 { return r.MinLatency.Seconds() }},
+	{"max_latency_seconds", "Slowest lookup", func(r *Result) float64 { return r.MaxLatency.Seconds() }},
+	{"p50_latency_seconds", "Median lookup latency", func(r *Result) float64 { return r.P50Latency.Seconds() }},
+	{"p90_latency_seconds", "90th percentile lookup latency", func(r *Result) float64 { return r.P90Latency.Seconds() }},
+	{"p95_latency_seconds", "95th percentile lookup latency", func(r *Result) float64 { return r.P95Latency.Seconds() }},
+	{"p99_latency_seconds", "99th percentile lookup latency", func(r *Result) float64 { return r.P99Latency.Seconds() }},
+	{"p999_latency_seconds", "99.9th percentile lookup latency", func(r *Result) float64 { return r.P999Latency.Seconds() }},
+	{"p9999_latency_seconds", "99.99th percentile lookup latency", func(r *Result) float64 { return r.P9999Latency.Seconds() }},
+	{"requests_per_second", "Successful lookups per second", func(r *Result) float64 { return r.RequestsPerSecond }},
+	{"success_rate_percent", "Share of lookups that succeeded", func(r *Result) float64 { return r.SuccessRate }},
+	{"cache_hit_rate_percent", "Share of lookups served from a cache", func(r *Result) float64 { return r.CacheHitRate }},
+	{"baseline_latency_seconds", "Baseline the improvement is computed against", func(r *Result) float64 { return r.BaselineLatency.Seconds() }},
+	{"improvement_factor", "Baseline latency over mean lookup latency", func(r *Result) float64 { return r.ImprovementFactor }},
+	{"target_improvement", "Improvement factor the run must reach", func(r *Result) float64 { return r.TargetImprovement }},
+	{"target_achieved", "1 if the improvement target was reached", func(r *Result) float64 {
+		if r.TargetAchieved {
+			return 1
+		}
+		return 0
+	}},
+}
+
+// WriteJSON writes result to w as an indented JSON document
+func WriteJSON(w io.Writer, result *Result) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return fmt.Errorf("failed to encode benchmark result: %w", err)
+	}
+	return nil
+}
+
+// WriteCSV writes results to w as CSV rows, preceded by a header row if
+// header is set. Leaving the header out appends runs to an existing file.
+func WriteCSV(w io.Writer, results []*Result, header bool) error {
+	writer := csv.NewWriter(w)
+
+	if header {
+		row := []string{"name", "started"}
+		for _, column := range columns {
+			row = append(row, column.name)
+		}
+		writer.Write(row)
+	}
+
+	for _, result := range results {
+		row := []string{result.Name, result.Started.UTC().Format(time.RFC3339)}
+		for _, column := range columns {
+			row = append(row, strconv.FormatFloat(column.value(result), 'g', -1, 64))
+		}
+		writer.Write(row)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write benchmark CSV: %w", err)
+	}
+	return nil
+}
+
+// WriteHistogram writes the latency distribution of result to w as CSV: one
+// row per non-empty bucket with its upper bound, count, cumulative count and
+// the share of lookups at or below it
+func WriteHistogram(w io.Writer, result *Result) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"upper_bound_seconds", "count", "cumulative_count", "quantile"})
+
+	total := result.Latencies.Count()
+	var cumulative int64
+	for _, bucket := range result.Latencies.Buckets() {
+		cumulative += bucket.Count
+		writer.Write([]string{
+			strconv.FormatFloat(bucket.UpperBound.Seconds(), 'g', -1, 64),
+			strconv.FormatInt(bucket.Count, 10),
+			strconv.FormatInt(cumulative, 10),
+			strconv.FormatFloat(float64(cumulative)/float64(total), 'f', 6, 64),
+		})
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write latency histogram: %w", err)
+	}
+	return nil
+}
+
+// PushConfig configures pushing results to a Prometheus Pushgateway
+type PushConfig struct {
+	// URL of the Pushgateway
+	URL string
+
+	// Job and Instance group the pushed metrics; the benchmark name is added
+	// as the "benchmark" grouping label
+	Job      string
+	Instance string
+
+	// Prefix for every pushed metric name
+	Namespace string
+}
+
+// Push replaces the metrics of result's group on the Pushgateway with the
+// gauges of result
+func Push(ctx context.Context, config *PushConfig, result *Result) error {
+	if config == nil {
+		config = DefaultPushConfig()
+	}
+
+	registry := prometheus.NewRegistry()
+	for _, column := range columns {
+		gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: config.Namespace,
+			Name:      column.name,
+			Help:      column.help,
+		})
+		gauge.Set(column.value(result))
+		registry.MustRegister(gauge)
+	}
+	completed := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: config.Namespace,
+		Name:      "completed_timestamp_seconds",
+		Help:      "Unix time the run completed",
+	})
+	completed.Set(float64(result.Started.Add(result.Duration).Unix()))
+	registry.MustRegister(completed)
+
+	pusher := push.New(config.URL, config.Job).
+		Gatherer(registry).
+		Grouping("benchmark", result.Name)
+	if config.Instance != "" {
+		pusher = pusher.Grouping("instance", config.Instance)
+	}
+	if err := pusher.PushContext(ctx); err != nil {
+		return fmt.Errorf("failed to push benchmark result to %s: %w", config.URL, err)
+	}
+	return nil
+}
+
+// DefaultPushConfig returns the default Pushgateway configuration
+func DefaultPushConfig() *PushConfig {
+	return &PushConfig{
+		Job:       "alm_bench",
+		Namespace: "alm_bench",
+	}
+}
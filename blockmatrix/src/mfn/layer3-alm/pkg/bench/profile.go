@@ -0,0 +1,149 @@
+// Package bench implements profile and execution trace capture over the
+// measurement window of a benchmark run
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strings"
+	"time"
+)
+
+// ProfileConfig selects what a run captures while it measures. Warmup is
+// excluded, so the data matches the latencies reported.
+type ProfileConfig struct {
+	// Dir is where captures are written, created if missing
+	Dir string
+
+	CPU   bool
+	Heap  bool
+	Trace bool
+}
+
+// Profiles are the files a run captured
+type Profiles struct {
+	CPU   string `json:"cpu,omitempty"`
+	Heap  string `json:"heap,omitempty"`
+	Trace string `json:"trace,omitempty"`
+}
+
+// ParseProfiles parses a comma-separated list of captures such as
+// "cpu,heap,trace" into config
+func ParseProfiles(value string, config *ProfileConfig) error {
+	for _, kind := range strings.Split(value, ",") {
+		switch strings.TrimSpace(kind) {
+		case "cpu":
+			config.CPU = true
+		case "heap":
+			config.Heap = true
+		case "trace":
+			config.Trace = true
+		case "":
+		default:
+			return fmt.Errorf("unknown profile %q: expected cpu, heap or trace", kind)
+		}
+	}
+	return nil
+}
+
+// enabled reports whether anything is captured
+func (pc *ProfileConfig) enabled() bool {
+	return pc != nil && (pc.CPU || pc.Heap || pc.Trace)
+}
+
+// capture is a capture in progress
+type capture struct {
+	config   *ProfileConfig
+	prefix   string
+	profiles Profiles
+	cpu      *os.File
+	trace    *os.File
+}
+
+// startCapture starts the captures config selects for the run name. File
+// names carry the start time so repeated runs don't overwrite each other.
+func startCapture(config *ProfileConfig, name string, started time.Time) (*capture, error) {
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create profile directory: %w", err)
+	}
+	if name == "" {
+		name = "bench"
+	}
+	c := &capture{
+		config: config,
+		prefix: filepath.Join(config.Dir, name+"-"+started.Format("20060102T150405")),
+	}
+
+	if config.CPU {
+		c.profiles.CPU = c.prefix + ".cpu.pprof"
+		file, err := os.Create(c.profiles.CPU)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		c.cpu = file
+	}
+
+	if config.Trace {
+		c.profiles.Trace = c.prefix + ".trace"
+		file, err := os.Create(c.profiles.Trace)
+		if err != nil {
+			c.stop()
+			return nil, fmt.Errorf("failed to create execution trace: %w", err)
+		}
+		if err := trace.Start(file); err != nil {
+			file.Close()
+			c.stop()
+			return nil, fmt.Errorf("failed to start execution trace: %w", err)
+		}
+		c.trace = file
+	}
+	return c, nil
+}
+
+// stop ends the captures and writes the heap profile, returning the files
+// written
+func (c *capture) stop() (*Profiles, error) {
+	var errs []error
+	if c.cpu != nil {
+		pprof.StopCPUProfile()
+		errs = append(errs, c.cpu.Close())
+		c.cpu = nil
+	}
+	if c.trace != nil {
+		trace.Stop()
+		errs = append(errs, c.trace.Close())
+		c.trace = nil
+	}
+
+	if c.config.Heap {
+		c.profiles.Heap = c.prefix + ".heap.pprof"
+		errs = append(errs, writeHeapProfile(c.profiles.Heap))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("failed to write profiles: %w", err)
+	}
+	profiles := c.profiles
+	return &profiles, nil
+}
+
+// writeHeapProfile writes the live heap after a collection to path
+func writeHeapProfile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	runtime.GC()
+	return pprof.WriteHeapProfile(file)
+}
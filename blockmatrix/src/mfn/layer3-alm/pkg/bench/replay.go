@@ -0,0 +1,143 @@
+// Package bench implements replay of route requests recorded from a live
+// coordinator
+package bench
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+// Replay drives a run with recorded requests in place of a workload
+type Replay struct {
+	// Name labels the trace in reports
+	Name string
+
+	Records []internal.TraceRecord
+
+	// Speed scales the recorded pace: 1 replays requests as they arrived,
+	// 2 twice as fast. Zero replays them closed-loop, as fast as the
+	// workers go.
+	Speed float64
+}
+
+// LoadTrace reads a trace written by a coordinator's request recording
+func LoadTrace(path string, speed float64) (*Replay, error) {
+	if speed < 0 {
+		return nil, fmt.Errorf("replay speed must not be negative, got %g", speed)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace: %w", err)
+	}
+	defer file.Close()
+
+	replay := &Replay{Name: "replay:" + filepath.Base(path), Speed: speed}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record internal.TraceRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("invalid trace %s line %d: %w", path, line, err)
+		}
+		replay.Records = append(replay.Records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read trace: %w", err)
+	}
+	if len(replay.Records) == 0 {
+		return nil, fmt.Errorf("trace %s has no records", path)
+	}
+	return replay, nil
+}
+
+// replayer issues the requests of a replay in order, starting over at the
+// end of the trace. Like generator it is not safe for concurrent use.
+type replayer struct {
+	replay   *Replay
+	requests []Request
+	position int
+
+	// Offset of the last request issued, and the gap before it
+	previous time.Duration
+	gap      time.Duration
+}
+
+// newReplayer maps the anonymized node IDs of replay onto nodes 1 to nodes,
+// in order of first appearance, so requests sharing endpoints in the trace
+// share them in the run. Traces with more distinct nodes than the run fold
+// the extra ones onto the same nodes. Each replayer starts start records in.
+func newReplayer(replay *Replay, nodes int, start int) *replayer {
+	ids := make(map[uint64]int64)
+	node := func(id uint64) int64 {
+		n, ok := ids[id]
+		if !ok {
+			n = int64(len(ids)%nodes) + 1
+			ids[id] = n
+		}
+		return n
+	}
+
+	requests := make([]Request, len(replay.Records))
+	for i, record := range replay.Records {
+		source := node(record.Source)
+		destination := node(record.Destination)
+		if destination == source {
+			destination = destination%int64(nodes) + 1
+		}
+
+		class := routing.QoSClass(record.QoSClass)
+		if class < routing.BestEffort || class > routing.CriticalMission {
+			class = routing.BestEffort
+		}
+		requests[i] = Request{Source: source, Destination: destination, QoSClass: class}
+	}
+
+	position := start % len(requests)
+	return &replayer{
+		replay:   replay,
+		requests: requests,
+		position: position,
+		previous: replay.Records[position].Offset,
+	}
+}
+
+func (r *replayer) next() operation {
+	offset := r.replay.Records[r.position].Offset
+	r.gap = offset - r.previous
+	r.previous = offset
+
+	op := operation{Request: r.requests[r.position]}
+	r.position = (r.position + 1) % len(r.requests)
+	return op
+}
+
+// interval returns the recorded gap before the last request, scaled by the
+// replay speed; starting over is immediate
+func (r *replayer) interval() time.Duration {
+	if r.gap <= 0 || r.replay.Speed <= 0 {
+		return 0
+	}
+	return time.Duration(float64(r.gap) / r.replay.Speed)
+}
+
+// validate checks the replay
+func (r *Replay) validate() error {
+	if len(r.Records) == 0 {
+		return errors.New("replay has no records")
+	}
+	if r.Speed < 0 {
+		return fmt.Errorf("replay speed must not be negative, got %g", r.Speed)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package bench
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+func writeTrace(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "orders.jsonl")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write trace: %v", err)
+	}
+	return path
+}
+
+func TestLoadTrace(t *testing.T) {
+	path := writeTrace(t, `{"offset":0,"source":11,"destination":22,"qos_class":1}
+
+{"offset":5000000,"source":22,"destination":33,"qos_class":0,"service_type":"9f2c"}
+`)
+
+	replay, err := LoadTrace(path, 2)
+	if err != nil {
+		t.Fatalf("LoadTrace: %v", err)
+	}
+	if replay.Name != "replay:orders.jsonl" || replay.Speed != 2 || len(replay.Records) != 2 {
+		t.Fatalf("replay %s at %gx with %d records", replay.Name, replay.Speed, len(replay.Records))
+	}
+	if second := replay.Records[1]; second.Offset != 5*time.Millisecond || second.Source != 22 || second.ServiceType != "9f2c" {
+		t.Errorf("second record %+v", second)
+	}
+
+	if _, err := LoadTrace(path, -1); err == nil {
+		t.Error("LoadTrace accepted a negative speed")
+	}
+	if _, err := LoadTrace(writeTrace(t, "\n"), 1); err == nil {
+		t.Error("LoadTrace accepted a trace without records")
+	}
+	_, err = LoadTrace(writeTrace(t, `{"source":1}`+"\n{bad\n"), 1)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("LoadTrace of a corrupt line = %v, want an error naming line 2", err)
+	}
+}
+
+func TestLoadTraceReadsRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recorded.jsonl")
+	recorder, err := internal.NewRequestRecorder(internal.RecordingConfig{Path: path, SampleRate: 1}, nil)
+	if err != nil {
+		t.Fatalf("NewRequestRecorder: %v", err)
+	}
+	recorder.Record(internal.RouteRequest{SourceID: 1, DestinationID: 2, QoSClass: 1})
+	recorder.Record(internal.RouteRequest{SourceID: 2, DestinationID: 1})
+	recorder.Stop()
+
+	replay, err := LoadTrace(path, 0)
+	if err != nil {
+		t.Fatalf("LoadTrace: %v", err)
+	}
+	records := replay.Records
+	if len(records) != 2 || records[0].Source != records[1].Destination || records[0].QoSClass != 1 {
+		t.Errorf("records %+v, want both requests with consistent node hashes", records)
+	}
+}
+
+func TestReplayerMapsNodes(t *testing.T) {
+	replay := &Replay{Speed: 2, Records: []internal.TraceRecord{
+		{Offset: 0, Source: 900, Destination: 800, QoSClass: int(routing.LowLatency)},
+		{Offset: 10 * time.Millisecond, Source: 800, Destination: 700},
+		// A fourth distinct node folds back onto node 1, the same as its source
+		{Offset: 30 * time.Millisecond, Source: 900, Destination: 600, QoSClass: 42},
+	}}
+
+	r := newReplayer(replay, 3, 0)
+	want := []Request{
+		{Source: 1, Destination: 2, QoSClass: routing.LowLatency},
+		{Source: 2, Destination: 3, QoSClass: routing.BestEffort},
+		{Source: 1, Destination: 2, QoSClass: routing.BestEffort},
+	}
+	intervals := []time.Duration{0, 5 * time.Millisecond, 10 * time.Millisecond}
+	for i := range want {
+		op := r.next()
+		if op.Request != want[i] {
+			t.Errorf("request %d = %+v, want %+v", i, op.Request, want[i])
+		}
+		if got := r.interval(); got != intervals[i] {
+			t.Errorf("interval before request %d = %v, want %v at 2x", i, got, intervals[i])
+		}
+	}
+
+	// Starting over is immediate
+	if op := r.next(); op.Request != want[0] || r.interval() != 0 {
+		t.Errorf("wrapped to %+v after %v, want the first request at once", op.Request, r.interval())
+	}
+
+	// Workers start at different records; closed-loop replays never wait
+	replay.Speed = 0
+	r = newReplayer(replay, 3, 4)
+	if op := r.next(); op.Request != want[1] {
+		t.Errorf("replayer starting at 4 issued %+v, want %+v", op.Request, want[1])
+	}
+	if op := r.next(); op.Request != want[2] || r.interval() != 0 {
+		t.Errorf("closed-loop replay waited %v", r.interval())
+	}
+}
+
+func TestReplayValidate(t *testing.T) {
+	if err := (&Replay{}).validate(); err == nil {
+		t.Error("validated a replay without records")
+	}
+	if err := (&Replay{Records: []internal.TraceRecord{{}}, Speed: -1}).validate(); err == nil {
+		t.Error("validated a negative speed")
+	}
+}
@@ -0,0 +1,113 @@
+// Package bench implements the text report of benchmark results
+package bench
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Report writes a human-readable summary of result to w. The improvement
+// analysis is left out of runs without a baseline.
+func Report(w io.Writer, result *Result, verbose bool) error {
+	rule := strings.Repeat("=", 80)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n%s\n", rule)
+	if result.Name != "" {
+		fmt.Fprintf(&b, "ALM ROUTING BENCHMARK RESULTS: %s\n", strings.ToUpper(result.Name))
+	} else {
+		fmt.Fprintf(&b, "ALM ROUTING BENCHMARK RESULTS\n")
+	}
+	fmt.Fprintf(&b, "%s\n", rule)
+	if result.Workload != "" {
+		fmt.Fprintf(&b, "Workload: %s\n", result.Workload)
+	}
+	if result.Topology != "" {
+		fmt.Fprintf(&b, "Topology: %s\n", result.Topology)
+	}
+	if result.Workload != "" || result.Topology != "" {
+		fmt.Fprintf(&b, "\n")
+	}
+
+	fmt.Fprintf(&b, "PERFORMANCE SUMMARY:\n")
+	fmt.Fprintf(&b, "  Average Latency:      %v\n", result.AverageLatency)
+	fmt.Fprintf(&b, "  P50 Latency:          %v\n", result.P50Latency)
+	fmt.Fprintf(&b, "  P90 Latency:          %v\n", result.P90Latency)
+	fmt.Fprintf(&b, "  P95 Latency:          %v\n", result.P95Latency)
+	fmt.Fprintf(&b, "  P99 Latency:          %v\n", result.P99Latency)
+	fmt.Fprintf(&b, "  P99.9 Latency:        %v\n", result.P999Latency)
+	fmt.Fprintf(&b, "  P99.99 Latency:       %v\n", result.P9999Latency)
+	if verbose {
+		fmt.Fprintf(&b, "  Min Latency:          %v\n", result.MinLatency)
+		fmt.Fprintf(&b, "  Max Latency:          %v\n", result.MaxLatency)
+	}
+	fmt.Fprintf(&b, "  Requests/Second:      %.0f\n", result.RequestsPerSecond)
+	fmt.Fprintf(&b, "  Success Rate:         %.2f%%\n", result.SuccessRate)
+	fmt.Fprintf(&b, "  Cache Hit Rate:       %.2f%%\n", result.CacheHitRate)
+	if verbose {
+		fmt.Fprintf(&b, "  Requests:             %d (%d succeeded)\n", result.Requests, result.Successful)
+		fmt.Fprintf(&b, "  Writes:               %d\n", result.Writes)
+		fmt.Fprintf(&b, "  Duration:             %v\n", result.Duration)
+	}
+
+	if result.BaselineLatency > 0 {
+		fmt.Fprintf(&b, "\nIMPROVEMENT ANALYSIS:\n")
+		fmt.Fprintf(&b, "  Baseline Latency:     %v\n", result.BaselineLatency)
+		fmt.Fprintf(&b, "  ALM Latency:          %v\n", result.AverageLatency)
+		fmt.Fprintf(&b, "  Improvement Factor:   %.2fx\n", result.ImprovementFactor)
+		fmt.Fprintf(&b, "  Improvement %%:        %.1f%%\n", (result.ImprovementFactor-1)*100)
+		fmt.Fprintf(&b, "  Target:               %.2fx\n", result.TargetImprovement)
+
+		fmt.Fprintf(&b, "\nBENCHMARK RESULT:\n")
+		if result.TargetAchieved {
+			fmt.Fprintf(&b, "  Status:               SUCCESS - target achieved\n")
+			fmt.Fprintf(&b, "  Achievement:          %.2fx improvement (%.1f%% above target)\n",
+				result.ImprovementFactor,
+				(result.ImprovementFactor/result.TargetImprovement-1)*100)
+		} else {
+			fmt.Fprintf(&b, "  Status:               FAILURE - target not achieved\n")
+			fmt.Fprintf(&b, "  Shortfall:            %.2fx improvement (%.1f%% below target)\n",
+				result.ImprovementFactor,
+				(1-result.ImprovementFactor/result.TargetImprovement)*100)
+		}
+	}
+
+	if len(result.Faults) > 0 {
+		fmt.Fprintf(&b, "\nRESILIENCE:\n")
+		for _, fault := range result.Faults {
+			switch {
+			case fault.Error != "":
+				fmt.Fprintf(&b, "  %-22s%s\n", fault.Kind+":", "failed: "+fault.Error)
+			case !fault.Injected:
+				fmt.Fprintf(&b, "  %-22s%s\n", fault.Kind+":", "not injected, the run ended first")
+			default:
+				recovery := "not recovered by the end of the run"
+				if fault.Recovered {
+					recovery = fmt.Sprintf("recovered %v after repair", fault.RecoveryTime)
+				}
+				fmt.Fprintf(&b, "  %-22sat %v: p99 %v -> %v (%.1fx), %d failed, %s\n",
+					fault.Kind+":", fault.InjectedAt.Round(time.Millisecond), fault.BaselineP99, fault.PeakP99, fault.Impact(), fault.Failures, recovery)
+			}
+		}
+	}
+
+	if profiles := result.Profiles; profiles != nil {
+		fmt.Fprintf(&b, "\nPROFILES:\n")
+		for _, profile := range []struct{ kind, path string }{
+			{"CPU", profiles.CPU},
+			{"Heap", profiles.Heap},
+			{"Trace", profiles.Trace},
+		} {
+			if profile.path != "" {
+				fmt.Fprintf(&b, "  %-22s%s\n", profile.kind+":", profile.path)
+			}
+		}
+	}
+
+	fmt.Fprintf(&b, "%s\n", rule)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,70 @@
+// Package bench implements a simulated routing engine for benchmarking the
+// harness itself without building real components
+package bench
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// SimulatedEngine models ALM route lookups with fixed delays: a cache hit
+// costs 5-25µs, a miss the associative search, optimization and route
+// computation stages at 15-50µs, 20-60µs and 8-25µs, after which the route
+// is cached
+type SimulatedEngine struct {
+	cache map[[2]int64]struct{}
+	mutex sync.RWMutex
+}
+
+// NewSimulatedEngine creates a simulated engine with an empty cache
+func NewSimulatedEngine() *SimulatedEngine {
+	return &SimulatedEngine{
+		cache: make(map[[2]int64]struct{}),
+	}
+}
+
+// Lookup implements Target
+func (se *SimulatedEngine) Lookup(ctx context.Context, request Request) (bool, error) {
+	key := [2]int64{request.Source, request.Destination}
+
+	se.mutex.RLock()
+	_, cached := se.cache[key]
+	se.mutex.RUnlock()
+
+	if cached {
+		time.Sleep(randomMicroseconds(5, 25))
+		return true, nil
+	}
+
+	time.Sleep(randomMicroseconds(15, 50))
+	time.Sleep(randomMicroseconds(20, 60))
+	time.Sleep(randomMicroseconds(8, 25))
+
+	se.mutex.Lock()
+	se.cache[key] = struct{}{}
+	se.mutex.Unlock()
+	return false, nil
+}
+
+// Update implements Updater by evicting the cached route
+func (se *SimulatedEngine) Update(ctx context.Context, source, destination int64) error {
+	se.mutex.Lock()
+	delete(se.cache, [2]int64{source, destination})
+	se.mutex.Unlock()
+	return nil
+}
+
+// Sizes implements Sizer. The cache is never evicted from, so it grows
+// with every distinct pair looked up.
+func (se *SimulatedEngine) Sizes() map[string]int {
+	se.mutex.RLock()
+	defer se.mutex.RUnlock()
+	return map[string]int{"cache_entries": len(se.cache)}
+}
+
+// randomMicroseconds returns a duration between min and max microseconds
+func randomMicroseconds(min, max int) time.Duration {
+	return time.Duration(min+rand.Intn(max-min)) * time.Microsecond
+}
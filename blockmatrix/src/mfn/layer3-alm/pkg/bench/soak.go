@@ -0,0 +1,348 @@
+// Package bench implements long-running soak benchmarks that watch memory,
+// goroutines and target structures for leaks
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/histogram"
+)
+
+// Sizer is implemented by targets that report the sizes of their internal
+// structures, such as cache entries, for soak runs to watch
+type Sizer interface {
+	Sizes() map[string]int
+}
+
+// Series sampled by every soak run
+const (
+	SeriesHeapBytes   = "heap_bytes"
+	SeriesHeapObjects = "heap_objects"
+	SeriesGoroutines  = "goroutines"
+)
+
+// minTrendSamples is the fewest settled samples a trend is judged on
+const minTrendSamples = 4
+
+// minLeakGrowth is the least overall growth of a series flagged as leaking,
+// so measurement noise is not extrapolated into a leak on short runs
+const minLeakGrowth = 0.01
+
+// SoakConfig configures a soak run
+type SoakConfig struct {
+	// Duration of the whole run
+	Duration time.Duration
+
+	// Interval between samples. Samples are taken between benchmark
+	// rounds, so rounds should be short next to it.
+	Interval time.Duration
+
+	// Settle excludes the first samples from leak detection while caches
+	// fill up to their working size
+	Settle time.Duration
+
+	// MaxGrowth is the largest tolerated growth of any series per hour, as
+	// a fraction of its first settled value
+	MaxGrowth float64
+}
+
+// Validate checks the soak configuration
+func (c *SoakConfig) Validate() error {
+	if c.Duration <= 0 || c.Interval <= 0 {
+		return errors.New("soak duration and interval must be positive")
+	}
+	if c.Settle < 0 || c.Settle >= c.Duration {
+		return fmt.Errorf("soak settle time must be at least 0 and below the duration, got %v", c.Settle)
+	}
+	if c.MaxGrowth < 0 {
+		return fmt.Errorf("soak max growth must not be negative, got %g", c.MaxGrowth)
+	}
+	return nil
+}
+
+// Sample is the state of the process and target at one point of a soak run
+type Sample struct {
+	Elapsed time.Duration  `json:"elapsed_ns"`
+	Values  map[string]int `json:"values"`
+
+	// RequestsPerSecond of the round before the sample
+	RequestsPerSecond float64 `json:"requests_per_second"`
+}
+
+// Trend is the growth of one series over the settled part of a soak run
+type Trend struct {
+	Series string  `json:"series"`
+	First  int     `json:"first"`
+	Last   int     `json:"last"`
+	Peak   int     `json:"peak"`
+	Slope  float64 `json:"slope_per_hour"`
+
+	// Growth is Slope as a fraction of First
+	Growth  float64 `json:"growth_per_hour"`
+	Leaking bool    `json:"leaking"`
+}
+
+// SoakResult summarizes a soak run
+type SoakResult struct {
+	Config *SoakConfig `json:"config"`
+
+	// Result aggregates every round of the run
+	Result *Result `json:"result"`
+	Rounds int     `json:"rounds"`
+
+	Samples []Sample `json:"samples"`
+	Trends  []Trend  `json:"trends"`
+}
+
+// Leaks returns the series found leaking
+func (r *SoakResult) Leaks() []string {
+	var leaks []string
+	for _, trend := range r.Trends {
+		if trend.Leaking {
+			leaks = append(leaks, trend.Series)
+		}
+	}
+	return leaks
+}
+
+// Soak runs rounds of config against target until soak.Duration has passed,
+// sampling the live heap, goroutine count and, for a Sizer, target
+// structure sizes every soak.Interval. Series that keep growing faster than
+// soak.MaxGrowth after soak.Settle are flagged as leaking. Only the first
+// round warms up.
+func Soak(ctx context.Context, config *Config, soak *SoakConfig, target Target) (*SoakResult, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if soak == nil {
+		soak = DefaultSoakConfig()
+	}
+	if err := soak.Validate(); err != nil {
+		return nil, err
+	}
+	sizer, _ := target.(Sizer)
+
+	round := *config
+	total := &Result{
+		Name:              config.Name,
+		BaselineLatency:   config.BaselineLatency,
+		TargetImprovement: config.TargetImprovement,
+		Latencies:         histogram.New(HighestLatency, 3),
+	}
+	result := &SoakResult{Config: soak, Result: total}
+
+	started := time.Now()
+	runCtx, cancel := context.WithDeadline(ctx, started.Add(soak.Duration))
+	defer cancel()
+
+	result.Samples = append(result.Samples, takeSample(0, sizer, 0))
+	nextSample := started.Add(soak.Interval)
+
+	var requestsPerSecond float64
+	for runCtx.Err() == nil {
+		// The last round is cut short at the deadline
+		roundResult, err := Run(runCtx, &round, target)
+		if err != nil && runCtx.Err() == nil {
+			return nil, fmt.Errorf("soak round %d failed: %w", result.Rounds+1, err)
+		}
+		if roundResult == nil {
+			break
+		}
+		round.Warmup = 0
+		// Only the first round is profiled and has faults injected; its
+		// captures and fault results stand for the run
+		round.Profile = nil
+		round.Faults = nil
+		if result.Rounds == 0 {
+			total.Profiles = roundResult.Profiles
+			total.Faults = roundResult.Faults
+		}
+		if err := total.add(roundResult); err != nil {
+			return nil, err
+		}
+		result.Rounds++
+		requestsPerSecond = roundResult.RequestsPerSecond
+
+		if now := time.Now(); !now.Before(nextSample) && runCtx.Err() == nil {
+			result.Samples = append(result.Samples, takeSample(now.Sub(started), sizer, requestsPerSecond))
+			nextSample = now.Add(soak.Interval)
+		}
+	}
+	result.Samples = append(result.Samples, takeSample(time.Since(started), sizer, requestsPerSecond))
+
+	total.Started = started
+	total.Duration = time.Since(started)
+	total.summarize()
+	result.Trends = trends(result.Samples, soak)
+	return result, ctx.Err()
+}
+
+// add accumulates a round into the aggregate result
+func (r *Result) add(round *Result) error {
+	if err := r.Latencies.Merge(round.Latencies); err != nil {
+		return fmt.Errorf("failed to aggregate soak round: %w", err)
+	}
+	r.Workload = round.Workload
+	r.Topology = round.Topology
+	r.Requests += round.Requests
+	r.Successful += round.Successful
+	r.CacheHits += round.CacheHits
+	r.Writes += round.Writes
+	return nil
+}
+
+// takeSample collects garbage, so the heap figures are live memory, and
+// records every series
+func takeSample(elapsed time.Duration, sizer Sizer, requestsPerSecond float64) Sample {
+	runtime.GC()
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+
+	values := map[string]int{
+		SeriesHeapBytes:   int(memory.HeapAlloc),
+		SeriesHeapObjects: int(memory.HeapObjects),
+		SeriesGoroutines:  runtime.NumGoroutine(),
+	}
+	if sizer != nil {
+		for name, size := range sizer.Sizes() {
+			values[name] = size
+		}
+	}
+	return Sample{Elapsed: elapsed, Values: values, RequestsPerSecond: requestsPerSecond}
+}
+
+// trends fits a least-squares line to each series over the settled samples
+func trends(samples []Sample, soak *SoakConfig) []Trend {
+	var settled []Sample
+	for _, sample := range samples {
+		if sample.Elapsed >= soak.Settle {
+			settled = append(settled, sample)
+		}
+	}
+	if len(settled) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(settled[0].Values))
+	for name := range settled[0].Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]Trend, 0, len(names))
+	for _, name := range names {
+		trend := Trend{
+			Series: name,
+			First:  settled[0].Values[name],
+			Last:   settled[len(settled)-1].Values[name],
+		}
+
+		var sumX, sumY, sumXY, sumXX float64
+		for _, sample := range settled {
+			x := sample.Elapsed.Hours()
+			y := float64(sample.Values[name])
+			sumX += x
+			sumY += y
+			sumXY += x * y
+			sumXX += x * x
+			if sample.Values[name] > trend.Peak {
+				trend.Peak = sample.Values[name]
+			}
+		}
+		n := float64(len(settled))
+		if denominator := n*sumXX - sumX*sumX; denominator > 0 {
+			trend.Slope = (n*sumXY - sumX*sumY) / denominator
+		}
+
+		base := trend.First
+		if base < 1 {
+			base = 1
+		}
+		trend.Growth = trend.Slope / float64(base)
+		trend.Leaking = len(settled) >= minTrendSamples &&
+			float64(trend.Last-trend.First)/float64(base) > minLeakGrowth &&
+			trend.Growth > soak.MaxGrowth
+		result = append(result, trend)
+	}
+	return result
+}
+
+// ReportSoak writes the series trends of a soak run as a table
+func ReportSoak(w io.Writer, r *SoakResult) error {
+	fmt.Fprintf(w, "\nSoak: %d rounds over %v, %d samples every %v after %v settling\n",
+		r.Rounds, r.Result.Duration.Round(time.Second), len(r.Samples), r.Config.Interval, r.Config.Settle)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Series\tFirst\tLast\tPeak\tGrowth/h\t\t")
+	for _, trend := range r.Trends {
+		status := "ok"
+		if trend.Leaking {
+			status = "LEAK"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%+.1f%%\t%s\t\n",
+			trend.Series, trend.First, trend.Last, trend.Peak, trend.Growth*100, status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	settled := 0
+	for _, sample := range r.Samples {
+		if sample.Elapsed >= r.Config.Settle {
+			settled++
+		}
+	}
+
+	switch leaks := r.Leaks(); {
+	case settled < minTrendSamples:
+		fmt.Fprintf(w, "Too few samples to judge growth; lengthen the run or shorten -soak-interval\n")
+	case len(leaks) > 0:
+		fmt.Fprintf(w, "Growing beyond %.1f%%/h: %s\n", r.Config.MaxGrowth*100, strings.Join(leaks, ", "))
+	default:
+		fmt.Fprintf(w, "No series growing beyond %.1f%%/h\n", r.Config.MaxGrowth*100)
+	}
+	return nil
+}
+
+// WriteSamples writes the samples of a soak run as CSV, one column per series
+func WriteSamples(w io.Writer, r *SoakResult) error {
+	if len(r.Samples) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(r.Samples[0].Values))
+	for name := range r.Samples[0].Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "elapsed_seconds,requests_per_second,%s\n", strings.Join(names, ","))
+	for _, sample := range r.Samples {
+		fmt.Fprintf(w, "%.1f,%.1f", sample.Elapsed.Seconds(), sample.RequestsPerSecond)
+		for _, name := range names {
+			fmt.Fprintf(w, ",%d", sample.Values[name])
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DefaultSoakConfig returns a four hour soak sampled every minute, judged
+// after ten minutes against 5% growth per hour
+func DefaultSoakConfig() *SoakConfig {
+	return &SoakConfig{
+		Duration:  4 * time.Hour,
+		Interval:  time.Minute,
+		Settle:    10 * time.Minute,
+		MaxGrowth: 0.05,
+	}
+}
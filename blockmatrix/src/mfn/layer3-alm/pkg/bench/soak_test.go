@@ -0,0 +1,190 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// growingTarget answers every lookup and reports an entries structure that
+// grows with each one next to a fixed one
+type growingTarget struct {
+	lookups atomic.Int64
+}
+
+func (gt *growingTarget) Lookup(ctx context.Context, request Request) (bool, error) {
+	gt.lookups.Add(1)
+	return true, nil
+}
+
+func (gt *growingTarget) Sizes() map[string]int {
+	return map[string]int{"entries": int(gt.lookups.Load()), "fixed": 10}
+}
+
+func TestSoakConfigValidate(t *testing.T) {
+	if err := DefaultSoakConfig().Validate(); err != nil {
+		t.Errorf("default config: %v", err)
+	}
+
+	invalid := []SoakConfig{
+		{Interval: time.Second},
+		{Duration: time.Hour},
+		{Duration: time.Hour, Interval: time.Second, Settle: time.Hour},
+		{Duration: time.Hour, Interval: time.Second, Settle: -time.Second},
+		{Duration: time.Hour, Interval: time.Second, MaxGrowth: -0.1},
+	}
+	for _, config := range invalid {
+		if err := config.Validate(); err == nil {
+			t.Errorf("Validate(%+v) accepted an invalid configuration", config)
+		}
+	}
+}
+
+// hourlySamples returns one sample per hour from hour 0 with the values of
+// each series in order
+func hourlySamples(series map[string][]int) []Sample {
+	var samples []Sample
+	for name, values := range series {
+		for i, value := range values {
+			if i == len(samples) {
+				samples = append(samples, Sample{Elapsed: time.Duration(i) * time.Hour, Values: map[string]int{}})
+			}
+			samples[i].Values[name] = value
+		}
+	}
+	return samples
+}
+
+func TestSoakTrends(t *testing.T) {
+	soak := &SoakConfig{Duration: 5 * time.Hour, Interval: time.Hour, Settle: 30 * time.Minute, MaxGrowth: 0.05}
+
+	// The unsettled first sample is ignored
+	samples := hourlySamples(map[string][]int{
+		"grow": {0, 100, 110, 120, 130},
+		"flat": {900, 50, 50, 50, 50},
+	})
+	got := trends(samples, soak)
+	if len(got) != 2 {
+		t.Fatalf("trends %+v, want one per series", got)
+	}
+
+	want := Trend{Series: "grow", First: 100, Last: 130, Peak: 130, Slope: 10, Growth: 0.1, Leaking: true}
+	if got[1] != want {
+		t.Errorf("grow trend %+v, want %+v", got[1], want)
+	}
+	if flat := got[0]; flat.Series != "flat" || flat.Slope != 0 || flat.Leaking {
+		t.Errorf("flat trend %+v, want no growth", flat)
+	}
+
+	// Growth is not judged on too few samples, nor on a change within noise
+	few := trends(hourlySamples(map[string][]int{"grow": {0, 100, 110, 120}}), soak)
+	if few[0].Leaking {
+		t.Error("flagged a leak on 3 settled samples")
+	}
+	soak.MaxGrowth = 0
+	noise := trends(hourlySamples(map[string][]int{"heap": {0, 1000, 1002, 1004, 1006}}), soak)
+	if noise[0].Slope <= 0 || noise[0].Leaking {
+		t.Errorf("trend %+v, want growth under 1%% overall not flagged", noise[0])
+	}
+
+	soak.Settle = 10 * time.Hour
+	if got := trends(samples, soak); got != nil {
+		t.Errorf("trends %+v with no settled samples", got)
+	}
+}
+
+func TestSoakFlagsGrowingStructures(t *testing.T) {
+	config := DefaultConfig()
+	config.Requests = 200
+	config.Warmup = 0
+	soak := &SoakConfig{Duration: 300 * time.Millisecond, Interval: 20 * time.Millisecond, MaxGrowth: 0.05}
+
+	target := &growingTarget{}
+	result, err := Soak(context.Background(), config, soak, target)
+	if err != nil {
+		t.Fatalf("Soak: %v", err)
+	}
+	if result.Rounds < 2 || result.Result.Requests == 0 || len(result.Samples) < minTrendSamples {
+		t.Fatalf("%d rounds, %d requests and %d samples; want several", result.Rounds, result.Result.Requests, len(result.Samples))
+	}
+	if result.Result.Duration < soak.Duration {
+		t.Errorf("soak ran for %v, want at least %v", result.Result.Duration, soak.Duration)
+	}
+
+	sampled := map[string]bool{}
+	for _, trend := range result.Trends {
+		sampled[trend.Series] = true
+		switch trend.Series {
+		case "entries":
+			if !trend.Leaking || trend.Last <= trend.First {
+				t.Errorf("entries trend %+v, want the growing structure flagged", trend)
+			}
+		case "fixed":
+			if trend.Leaking {
+				t.Errorf("fixed trend %+v flagged", trend)
+			}
+		}
+	}
+	for _, series := range []string{SeriesHeapBytes, SeriesHeapObjects, SeriesGoroutines, "entries", "fixed"} {
+		if !sampled[series] {
+			t.Errorf("series %s not sampled", series)
+		}
+	}
+	found := false
+	for _, leak := range result.Leaks() {
+		found = found || leak == "entries"
+	}
+	if !found {
+		t.Errorf("Leaks() = %v, want entries", result.Leaks())
+	}
+}
+
+func TestSoakStopsOnCancel(t *testing.T) {
+	config := DefaultConfig()
+	config.Requests = 100
+	config.Warmup = 0
+	soak := &SoakConfig{Duration: time.Hour, Interval: time.Minute}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result, err := Soak(ctx, config, soak, &growingTarget{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Soak = %v, want the context error", err)
+	}
+	if result == nil || result.Rounds == 0 || len(result.Samples) != 2 {
+		t.Errorf("result %+v, want the rounds run so far with first and last samples", result)
+	}
+}
+
+func TestReportSoak(t *testing.T) {
+	soak := &SoakConfig{Duration: 5 * time.Hour, Interval: time.Hour, MaxGrowth: 0.05}
+	samples := hourlySamples(map[string][]int{"grow": {100, 110, 120, 130}, "flat": {50, 50, 50, 50}})
+	result := &SoakResult{Config: soak, Result: &Result{}, Samples: samples, Trends: trends(samples, soak)}
+
+	var report strings.Builder
+	if err := ReportSoak(&report, result); err != nil {
+		t.Fatalf("ReportSoak: %v", err)
+	}
+	if !strings.Contains(report.String(), "LEAK") || !strings.Contains(report.String(), "Growing beyond 5.0%/h: grow") {
+		t.Errorf("report does not flag the growing series:\n%s", report.String())
+	}
+
+	var csv strings.Builder
+	if err := WriteSamples(&csv, result); err != nil {
+		t.Fatalf("WriteSamples: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if len(lines) != 5 || lines[0] != "elapsed_seconds,requests_per_second,flat,grow" || lines[4] != "10800.0,0.0,50,130" {
+		t.Errorf("samples CSV:\n%s", csv.String())
+	}
+
+	result.Samples = result.Samples[:2]
+	report.Reset()
+	ReportSoak(&report, result)
+	if !strings.Contains(report.String(), "Too few samples") {
+		t.Errorf("report on 2 samples does not warn:\n%s", report.String())
+	}
+}
@@ -0,0 +1,244 @@
+// Package bench implements a local store of benchmark results with queries
+// of their history per commit, needing no external infrastructure
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Store keeps benchmark results for history queries
+type Store interface {
+	// Add records a result
+	Add(record *Record) error
+
+	// Query returns the records matching query, oldest first
+	Query(query Query) ([]*Record, error)
+
+	Close() error
+}
+
+// Supported result store backends
+const (
+	StoreBackendFile   = "file"
+	StoreBackendBadger = "badger"
+)
+
+// OpenStore opens the store at path with the named backend: a JSON lines
+// file or a Badger database directory
+func OpenStore(backend, path string) (Store, error) {
+	switch strings.ToLower(backend) {
+	case StoreBackendFile:
+		return OpenFileStore(path)
+	case StoreBackendBadger:
+		return OpenBadgerStore(path)
+	default:
+		return nil, fmt.Errorf("unsupported store backend: %s", backend)
+	}
+}
+
+// Record is a stored result with the commit it measured
+type Record struct {
+	Commit string  `json:"commit,omitempty"`
+	Result *Result `json:"result"`
+}
+
+// Query selects stored records. Empty fields match everything.
+type Query struct {
+	Name     string
+	Workload string
+	Topology string
+	Since    time.Time
+
+	// Limit keeps only the most recent records when positive
+	Limit int
+}
+
+// matches reports whether record is selected by the query
+func (q *Query) matches(record *Record) bool {
+	r := record.Result
+	return (q.Name == "" || r.Name == q.Name) &&
+		(q.Workload == "" || r.Workload == q.Workload) &&
+		(q.Topology == "" || r.Topology == q.Topology) &&
+		(q.Since.IsZero() || !r.Started.Before(q.Since))
+}
+
+// FileStore is a Store appending records to a file as JSON lines. Records
+// are never rewritten, so a crash loses at most the record being added,
+// and the file can be kept in version control or shipped between machines.
+// Its Add and Query are safe for concurrent use within a process.
+type FileStore struct {
+	mutex sync.Mutex
+	path  string
+	file  *os.File
+}
+
+// OpenFileStore opens the store at path, creating it if missing. A torn
+// last record, left by a crash while adding it, is truncated.
+func OpenFileStore(path string) (*FileStore, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open result store: %w", err)
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read result store: %w", err)
+	}
+	if complete := bytes.LastIndexByte(data, '\n') + 1; complete < len(data) {
+		if err := file.Truncate(int64(complete)); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to truncate torn record: %w", err)
+		}
+	}
+	return &FileStore{path: path, file: file}, nil
+}
+
+// Add implements Store
+func (fs *FileStore) Add(record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if _, err := fs.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to store result: %w", err)
+	}
+	return nil
+}
+
+// Query implements Store, ordering records by start time
+func (fs *FileStore) Query(query Query) ([]*Record, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if _, err := fs.file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read result store: %w", err)
+	}
+
+	var records []*Record
+	reader := bufio.NewReader(fs.file)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read result store: %w", err)
+		}
+
+		var record Record
+		if err := json.Unmarshal(data, &record); err != nil || record.Result == nil {
+			return nil, fmt.Errorf("%s:%d: invalid record", fs.path, line)
+		}
+		if query.matches(&record) {
+			records = append(records, &record)
+		}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Result.Started.Before(records[j].Result.Started)
+	})
+	if query.Limit > 0 && len(records) > query.Limit {
+		records = records[len(records)-query.Limit:]
+	}
+	return records, nil
+}
+
+// Close implements Store
+func (fs *FileStore) Close() error {
+	return fs.file.Close()
+}
+
+// HistoryPoint is a metric over the runs of one commit
+type HistoryPoint struct {
+	Commit  string    `json:"commit"`
+	Started time.Time `json:"started"`
+	Runs    int       `json:"runs"`
+
+	// Value is the median of the runs' values, damping one noisy run
+	Value float64 `json:"value"`
+}
+
+// History returns metric, named as in CSV output such as
+// p99_latency_seconds, per commit of records in the order the commits were
+// first measured. Records without a commit each stand alone.
+func History(records []*Record, metric string) ([]*HistoryPoint, error) {
+	var value func(r *Result) float64
+	var names []string
+	for _, c := range columns {
+		names = append(names, c.name)
+		if c.name == metric {
+			value = c.value
+		}
+	}
+	if value == nil {
+		return nil, fmt.Errorf("unknown metric %q: expected one of %s", metric, strings.Join(names, ", "))
+	}
+
+	var points []*HistoryPoint
+	values := make(map[*HistoryPoint][]float64)
+	byCommit := make(map[string]*HistoryPoint)
+	for _, record := range records {
+		point := byCommit[record.Commit]
+		if point == nil || record.Commit == "" {
+			point = &HistoryPoint{Commit: record.Commit, Started: record.Result.Started}
+			points = append(points, point)
+			byCommit[record.Commit] = point
+		}
+		point.Runs++
+		values[point] = append(values[point], value(record.Result))
+	}
+
+	for _, point := range points {
+		runs := values[point]
+		sort.Float64s(runs)
+		point.Value = runs[len(runs)/2]
+		if len(runs)%2 == 0 {
+			point.Value = (runs[len(runs)/2-1] + runs[len(runs)/2]) / 2
+		}
+	}
+	return points, nil
+}
+
+// ReportHistory writes points of metric to w as a table with a bar per point
+// scaled to the largest value
+func ReportHistory(w io.Writer, metric string, points []*HistoryPoint) error {
+	const barWidth = 40
+
+	var largest float64
+	for _, point := range points {
+		if point.Value > largest {
+			largest = point.Value
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Commit\tStarted\tRuns\t%s\t\n", metric)
+	for _, point := range points {
+		commit := point.Commit
+		if commit == "" {
+			commit = "-"
+		}
+		bar := ""
+		if largest > 0 {
+			bar = strings.Repeat("#", int(point.Value/largest*barWidth+0.5))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.6g\t%s\n",
+			commit, point.Started.UTC().Format(time.RFC3339), point.Runs, point.Value, bar)
+	}
+	return tw.Flush()
+}
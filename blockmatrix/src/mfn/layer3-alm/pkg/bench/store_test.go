@@ -0,0 +1,141 @@
+package bench
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// storeTestStart is the start of the first run added by addTestRuns
+var storeTestStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// addTestRuns adds runs of two commits an hour apart, out of order
+func addTestRuns(t *testing.T, store Store) {
+	t.Helper()
+
+	runs := []struct {
+		commit   string
+		topology string
+		p99      time.Duration
+	}{
+		{"aaa", "single-dc", 3 * time.Millisecond},
+		{"aaa", "single-dc", 5 * time.Millisecond},
+		{"aaa", "global-cdn", 40 * time.Millisecond},
+		{"bbb", "single-dc", 2 * time.Millisecond},
+		{"bbb", "single-dc", 9 * time.Millisecond},
+		{"bbb", "single-dc", 4 * time.Millisecond},
+		{"", "single-dc", time.Millisecond},
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		result := &Result{Name: "integrated", Topology: runs[i].topology, Started: storeTestStart.Add(time.Duration(i) * time.Hour), P99Latency: runs[i].p99}
+		if err := store.Add(&Record{Commit: runs[i].commit, Result: result}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+}
+
+// checkTestQueries checks the history of queries over the runs of
+// addTestRuns, which are returned by start time
+func checkTestQueries(t *testing.T, store Store) {
+	t.Helper()
+
+	cases := []struct {
+		name  string
+		query Query
+		want  string // commit:runs:value of each point
+	}{
+		{"one topology", Query{Topology: "single-dc"}, "aaa:2:0.004 bbb:3:0.004 :1:0.001"},
+		{"other topology", Query{Topology: "global-cdn"}, "aaa:1:0.04"},
+		{"since", Query{Topology: "single-dc", Since: storeTestStart.Add(4 * time.Hour)}, "bbb:2:0.0065 :1:0.001"},
+		{"limit", Query{Limit: 2}, "bbb:1:0.004 :1:0.001"},
+		{"limit of matches", Query{Topology: "global-cdn", Limit: 2}, "aaa:1:0.04"},
+		{"other name", Query{Name: "baseline"}, ""},
+	}
+
+	for _, tc := range cases {
+		records, err := store.Query(tc.query)
+		if err != nil {
+			t.Fatalf("%s: Query: %v", tc.name, err)
+		}
+		points, err := History(records, "p99_latency_seconds")
+		if err != nil {
+			t.Fatalf("%s: History: %v", tc.name, err)
+		}
+
+		var got []string
+		for _, point := range points {
+			got = append(got, fmt.Sprintf("%s:%d:%.4g", point.Commit, point.Runs, point.Value))
+		}
+		if strings.Join(got, " ") != tc.want {
+			t.Errorf("%s: history %q, want %q", tc.name, strings.Join(got, " "), tc.want)
+		}
+	}
+}
+
+func TestFileStoreHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore: %v", err)
+	}
+	addTestRuns(t, store)
+	store.Close()
+
+	// A crash while adding leaves a torn record, dropped on reopening
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	file.WriteString(`{"commit":"ccc","result":{"na`)
+	file.Close()
+	store, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore after a torn record: %v", err)
+	}
+	defer store.Close()
+
+	checkTestQueries(t, store)
+
+	if _, err := History(nil, "p99"); err == nil || !strings.Contains(err.Error(), "p99_latency_seconds") {
+		t.Errorf("History of an unknown metric returned %v, want an error listing the metrics", err)
+	}
+}
+
+func TestBadgerStoreHistory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "results")
+	store, err := OpenStore("Badger", dir)
+	if err != nil {
+		t.Fatalf("OpenStore(Badger): %v", err)
+	}
+	addTestRuns(t, store)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Records persist across reopening, and runs started at the same time
+	// are all kept in the order they were added
+	badger, err := OpenBadgerStore(dir)
+	if err != nil {
+		t.Fatalf("OpenBadgerStore: %v", err)
+	}
+	defer badger.Close()
+	for _, commit := range []string{"ccc", "ddd"} {
+		result := &Result{Name: "soak", Started: storeTestStart}
+		if err := badger.Add(&Record{Commit: commit, Result: result}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	checkTestQueries(t, badger)
+
+	records, err := badger.Query(Query{Name: "soak"})
+	if err != nil || len(records) != 2 || records[0].Commit != "ccc" || records[1].Commit != "ddd" {
+		t.Errorf("Query(soak) = %v, %v; want both runs in order", records, err)
+	}
+}
+
+func TestOpenStoreRejectsUnknownBackend(t *testing.T) {
+	if _, err := OpenStore("sqlite", filepath.Join(t.TempDir(), "results")); err == nil {
+		t.Error("opened a store with an unsupported backend")
+	}
+}
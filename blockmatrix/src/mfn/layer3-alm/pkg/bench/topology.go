@@ -0,0 +1,252 @@
+// Package bench implements named topology profiles shaped like production
+// clusters, so benchmark results translate to real deployments
+package bench
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+// Topology is the shape of a deployment to benchmark over. Nodes are split
+// evenly across regions; links within a region and between regions draw
+// their latencies from separate ranges.
+type Topology struct {
+	Name        string
+	Description string
+
+	Nodes   int
+	Regions int
+
+	// EdgesPerNode is the number of links each node has beyond the ring
+	// joining all nodes
+	EdgesPerNode int
+
+	// CrossRegion is the fraction of those links that leave the node's
+	// region
+	CrossRegion float64
+
+	IntraRegionLatency LatencyRange
+	InterRegionLatency LatencyRange
+
+	// Bandwidth range of links in Mbps
+	MinBandwidth float64
+	MaxBandwidth float64
+
+	// FailureRate is the mean fraction of traffic a link or node drops,
+	// setting packet loss and reliability
+	FailureRate float64
+}
+
+// LatencyRange is a uniform latency distribution
+type LatencyRange struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// sample draws a latency from the range
+func (lr LatencyRange) sample(rng *rand.Rand) time.Duration {
+	if lr.Max <= lr.Min {
+		return lr.Min
+	}
+	return lr.Min + time.Duration(rng.Int63n(int64(lr.Max-lr.Min)))
+}
+
+// Topologies are the named topology profiles. Latencies are one-way link
+// latencies typical of each environment: sub-millisecond within a data
+// center, a few milliseconds between its zones, tens of milliseconds across
+// a continent and over a hundred between continents.
+var Topologies = map[string]*Topology{
+	"single-dc": {
+		Name:               "single-dc",
+		Description:        "one data center: a flat, fast and reliable network",
+		Nodes:              50,
+		Regions:            1,
+		EdgesPerNode:       4,
+		IntraRegionLatency: LatencyRange{100 * time.Microsecond, 500 * time.Microsecond},
+		MinBandwidth:       10000,
+		MaxBandwidth:       25000,
+		FailureRate:        0.0005,
+	},
+	"3-region-cloud": {
+		Name:               "3-region-cloud",
+		Description:        "three cloud regions with several zones each, joined by inter-region links",
+		Nodes:              150,
+		Regions:            3,
+		EdgesPerNode:       4,
+		CrossRegion:        0.1,
+		IntraRegionLatency: LatencyRange{500 * time.Microsecond, 2 * time.Millisecond},
+		InterRegionLatency: LatencyRange{30 * time.Millisecond, 80 * time.Millisecond},
+		MinBandwidth:       1000,
+		MaxBandwidth:       10000,
+		FailureRate:        0.002,
+	},
+	"global-cdn": {
+		Name:               "global-cdn",
+		Description:        "points of presence on every continent, with many long-haul links",
+		Nodes:              500,
+		Regions:            12,
+		EdgesPerNode:       3,
+		CrossRegion:        0.3,
+		IntraRegionLatency: LatencyRange{1 * time.Millisecond, 10 * time.Millisecond},
+		InterRegionLatency: LatencyRange{20 * time.Millisecond, 250 * time.Millisecond},
+		MinBandwidth:       1000,
+		MaxBandwidth:       40000,
+		FailureRate:        0.01,
+	},
+	"edge-heavy": {
+		Name:               "edge-heavy",
+		Description:        "many sparsely connected edge sites on slow, lossy last-mile links",
+		Nodes:              1000,
+		Regions:            20,
+		EdgesPerNode:       2,
+		CrossRegion:        0.05,
+		IntraRegionLatency: LatencyRange{5 * time.Millisecond, 40 * time.Millisecond},
+		InterRegionLatency: LatencyRange{20 * time.Millisecond, 120 * time.Millisecond},
+		MinBandwidth:       10,
+		MaxBandwidth:       100,
+		FailureRate:        0.05,
+	},
+}
+
+// LookupTopology returns the topology profile called name
+func LookupTopology(name string) (*Topology, error) {
+	topology, exists := Topologies[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown topology %q: expected one of %s", name, strings.Join(TopologyNames(), ", "))
+	}
+	return topology, nil
+}
+
+// TopologyNames returns the names of the topology profiles in order
+func TopologyNames() []string {
+	names := make([]string, 0, len(Topologies))
+	for name := range Topologies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks the topology
+func (t *Topology) Validate() error {
+	switch {
+	case t.Nodes < 2:
+		return fmt.Errorf("topology needs at least 2 nodes, got %d", t.Nodes)
+	case t.Regions < 1 || t.Regions > t.Nodes:
+		return fmt.Errorf("topology needs between 1 and %d regions, got %d", t.Nodes, t.Regions)
+	case t.EdgesPerNode < 0:
+		return fmt.Errorf("edges per node must not be negative")
+	case t.CrossRegion < 0 || t.CrossRegion > 1:
+		return fmt.Errorf("cross-region fraction must be between 0 and 1")
+	case t.FailureRate < 0 || t.FailureRate >= 1:
+		return fmt.Errorf("failure rate must be at least 0 and below 1")
+	case t.MaxBandwidth < t.MinBandwidth:
+		return fmt.Errorf("maximum bandwidth is below the minimum")
+	}
+	return nil
+}
+
+// region returns the region of node id, numbered from 0
+func (t *Topology) region(id int64) int {
+	return int(id-1) * t.Regions / t.Nodes
+}
+
+// Build creates the topology's graph: nodes numbered from 1 joined in a
+// ring, so every pair is reachable, plus EdgesPerNode links from each node
+func (t *Topology) Build(rng *rand.Rand) (*graph.NetworkGraph, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	// failure jitters the failure rate by up to half either way
+	failure := func() float64 {
+		return t.FailureRate * (0.5 + rng.Float64())
+	}
+
+	networkGraph := graph.NewNetworkGraph(t.Nodes)
+	for i := 1; i <= t.Nodes; i++ {
+		id := int64(i)
+		node := &graph.NetworkNode{
+			ID:          id,
+			Address:     fmt.Sprintf("node-%d", i),
+			Region:      fmt.Sprintf("region-%d", t.region(id)+1),
+			Latency:     t.IntraRegionLatency.sample(rng),
+			Throughput:  t.MinBandwidth + rng.Float64()*(t.MaxBandwidth-t.MinBandwidth),
+			Reliability: 1 - failure(),
+			LoadFactor:  rng.Float64() * 0.5,
+			LastSeen:    time.Now(),
+			Services:    make(map[string]graph.ServiceInfo),
+		}
+		if err := networkGraph.AddNode(node); err != nil {
+			networkGraph.Close()
+			return nil, fmt.Errorf("failed to add node: %w", err)
+		}
+	}
+
+	addEdge := func(from, to int64) error {
+		latency := t.IntraRegionLatency.sample(rng)
+		if t.region(from) != t.region(to) {
+			latency = t.InterRegionLatency.sample(rng)
+		}
+		loss := failure()
+		return networkGraph.AddEdge(&graph.NetworkEdge{
+			From:        from,
+			To:          to,
+			Weight:      float64(latency.Microseconds()),
+			Latency:     latency,
+			Bandwidth:   t.MinBandwidth + rng.Float64()*(t.MaxBandwidth-t.MinBandwidth),
+			PacketLoss:  loss,
+			Cost:        rng.Float64(),
+			Reliability: 1 - loss,
+			Stability:   1 - failure(),
+			LastUpdate:  time.Now(),
+		})
+	}
+
+	nodes := int64(t.Nodes)
+	for i := int64(1); i <= nodes; i++ {
+		next := i%nodes + 1
+		if err := addEdge(i, next); err != nil {
+			networkGraph.Close()
+			return nil, fmt.Errorf("failed to add edge: %w", err)
+		}
+		if err := addEdge(next, i); err != nil {
+			networkGraph.Close()
+			return nil, fmt.Errorf("failed to add edge: %w", err)
+		}
+	}
+
+	// Links stay in the node's region unless drawn to cross, or there is
+	// only one other node to link to there
+	regionStart := func(region int) int64 {
+		return int64((region*t.Nodes+t.Regions-1)/t.Regions) + 1
+	}
+	for i := int64(1); i <= nodes; i++ {
+		region := t.region(i)
+		first, last := regionStart(region), regionStart(region+1)-1
+		for e := 0; e < t.EdgesPerNode; e++ {
+			var to int64
+			if t.Regions > 1 && (last-first < 2 || rng.Float64() < t.CrossRegion) {
+				other := (region + 1 + rng.Intn(t.Regions-1)) % t.Regions
+				to = regionStart(other) + rng.Int63n(regionStart(other+1)-regionStart(other))
+			} else if last > first {
+				to = first + rng.Int63n(last-first)
+				if to >= i {
+					to++
+				}
+			} else {
+				continue
+			}
+			if err := addEdge(i, to); err != nil {
+				networkGraph.Close()
+				return nil, fmt.Errorf("failed to add edge: %w", err)
+			}
+		}
+	}
+	return networkGraph, nil
+}
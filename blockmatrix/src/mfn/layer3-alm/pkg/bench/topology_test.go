@@ -0,0 +1,78 @@
+package bench
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+func TestTopologyProfiles(t *testing.T) {
+	for _, name := range TopologyNames() {
+		topology, err := LookupTopology(name)
+		if err != nil {
+			t.Fatalf("LookupTopology(%s): %v", name, err)
+		}
+		networkGraph, err := topology.Build(rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Errorf("%s: Build: %v", name, err)
+			continue
+		}
+
+		regions := make(map[string]int)
+		for _, node := range networkGraph.Nodes() {
+			regions[node.Region]++
+		}
+		if len(networkGraph.Nodes()) != topology.Nodes || len(regions) != topology.Regions {
+			t.Errorf("%s: %d nodes in %d regions, want %d in %d",
+				name, len(networkGraph.Nodes()), len(regions), topology.Nodes, topology.Regions)
+		}
+
+		var crossing int
+		for _, edge := range networkGraph.Edges() {
+			latency := topology.IntraRegionLatency
+			if topology.region(edge.From) != topology.region(edge.To) {
+				latency = topology.InterRegionLatency
+				crossing++
+			}
+			if edge.Latency < latency.Min || edge.Latency > latency.Max {
+				t.Errorf("%s: edge %d->%d latency %v outside %v to %v", name, edge.From, edge.To, edge.Latency, latency.Min, latency.Max)
+				break
+			}
+			if edge.PacketLoss > topology.FailureRate*1.5 {
+				t.Errorf("%s: edge %d->%d loses %.4f, above 1.5 times the failure rate", name, edge.From, edge.To, edge.PacketLoss)
+				break
+			}
+		}
+		if topology.Regions > 1 && crossing == 0 {
+			t.Errorf("%s: no links between regions", name)
+		}
+		networkGraph.Close()
+	}
+
+	if _, err := LookupTopology("mainframe"); err == nil {
+		t.Error("LookupTopology accepted an unknown profile")
+	}
+}
+
+func TestTopologyTargetRoutes(t *testing.T) {
+	target, err := NewTopologyTarget(Topologies["3-region-cloud"], 0)
+	if err != nil {
+		t.Fatalf("NewTopologyTarget: %v", err)
+	}
+	defer target.Close()
+
+	config := DefaultConfig()
+	config.Nodes = target.topology.Nodes
+	config.Topology = target.topology.Name
+	config.Requests = 200
+	config.Warmup = 0
+	config.Workload = DefaultWorkload()
+	config.Workload.Writes = 0.1
+	result, err := Run(context.Background(), config, target)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Topology != "3-region-cloud" || result.Successful == 0 || result.Writes == 0 {
+		t.Errorf("result over %q: %d of %d lookups succeeded with %d writes", result.Topology, result.Successful, result.Requests, result.Writes)
+	}
+}
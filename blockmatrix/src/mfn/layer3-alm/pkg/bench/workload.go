@@ -0,0 +1,260 @@
+// Package bench implements workload specifications shaping the traffic a
+// benchmark sends
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+	"gopkg.in/yaml.v3"
+)
+
+// Node popularity distributions
+const (
+	// Uniform picks every node equally often
+	Uniform = "uniform"
+
+	// Zipfian picks the node of rank k, counting from node 1, with
+	// probability proportional to 1/k^Skew
+	Zipfian = "zipfian"
+)
+
+// Arrival processes
+const (
+	// Closed has each worker issue its next operation as soon as the
+	// previous one completes
+	Closed = "closed"
+
+	// Poisson issues operations at Rate with exponentially distributed gaps
+	Poisson = "poisson"
+
+	// Constant issues operations at Rate with fixed gaps
+	Constant = "constant"
+)
+
+// Workload describes the traffic of a benchmark run
+type Workload struct {
+	Name string `yaml:"name"`
+
+	// Popularity of the nodes routes are looked up from and to
+	Sources      Popularity `yaml:"sources"`
+	Destinations Popularity `yaml:"destinations"`
+
+	// Writes is the fraction of operations that update the topology,
+	// invalidating cached routes, rather than look one up
+	Writes float64 `yaml:"writes"`
+
+	// QoSClasses weights the QoS classes of lookups by snake_case name,
+	// such as low_latency; weights need not sum to 1
+	QoSClasses map[string]float64 `yaml:"qos_classes"`
+
+	Arrival Arrival `yaml:"arrival"`
+}
+
+// Popularity is a node popularity distribution
+type Popularity struct {
+	Distribution string `yaml:"distribution"`
+
+	// Skew of a Zipfian distribution, above 1; higher concentrates traffic
+	// on fewer nodes
+	Skew float64 `yaml:"skew"`
+}
+
+// Arrival is the process operations are issued by
+type Arrival struct {
+	Process string `yaml:"process"`
+
+	// Rate of an open-loop process in operations per second
+	Rate float64 `yaml:"rate"`
+}
+
+// LoadWorkload reads a workload from the YAML file at path. Fields it leaves
+// out keep the values of DefaultWorkload.
+func LoadWorkload(path string) (*Workload, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workload: %w", err)
+	}
+
+	workload := DefaultWorkload()
+	workload.QoSClasses = nil
+	if err := yaml.Unmarshal(data, workload); err != nil {
+		return nil, fmt.Errorf("failed to parse workload %s: %w", path, err)
+	}
+	if workload.QoSClasses == nil {
+		workload.QoSClasses = DefaultWorkload().QoSClasses
+	}
+	if err := workload.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid workload %s: %w", path, err)
+	}
+	return workload, nil
+}
+
+// Validate checks the workload
+func (w *Workload) Validate() error {
+	for _, popularity := range []Popularity{w.Sources, w.Destinations} {
+		switch popularity.Distribution {
+		case Uniform:
+		case Zipfian:
+			if popularity.Skew <= 1 {
+				return fmt.Errorf("zipfian skew must be above 1, got %g", popularity.Skew)
+			}
+		default:
+			return fmt.Errorf("unknown popularity distribution %q", popularity.Distribution)
+		}
+	}
+
+	if w.Writes < 0 || w.Writes >= 1 {
+		return fmt.Errorf("writes must be at least 0 and below 1, got %g", w.Writes)
+	}
+
+	if len(w.QoSClasses) == 0 {
+		return errors.New("at least one QoS class is required")
+	}
+	var total float64
+	for name, weight := range w.QoSClasses {
+		if _, err := parseQoSClass(name); err != nil {
+			return err
+		}
+		if weight < 0 {
+			return fmt.Errorf("QoS class %s has negative weight %g", name, weight)
+		}
+		total += weight
+	}
+	if total == 0 {
+		return errors.New("QoS class weights sum to 0")
+	}
+
+	switch w.Arrival.Process {
+	case Closed:
+	case Poisson, Constant:
+		if w.Arrival.Rate <= 0 {
+			return fmt.Errorf("%s arrival needs a positive rate, got %g", w.Arrival.Process, w.Arrival.Rate)
+		}
+	default:
+		return fmt.Errorf("unknown arrival process %q", w.Arrival.Process)
+	}
+	return nil
+}
+
+// Request is a route lookup issued to a Target
+type Request struct {
+	Source      int64
+	Destination int64
+	QoSClass    routing.QoSClass
+}
+
+// operation is one step of a workload
+type operation struct {
+	Request
+	write bool
+}
+
+// generator draws operations from a workload. It is not safe for
+// concurrent use; each worker has its own.
+type generator struct {
+	workload *Workload
+	nodes    int
+	random   *rand.Rand
+
+	sources      func() int64
+	destinations func() int64
+
+	classes []routing.QoSClass
+	weights []float64 // cumulative
+}
+
+func newGenerator(workload *Workload, nodes int, seed int64) *generator {
+	g := &generator{
+		workload: workload,
+		nodes:    nodes,
+		random:   rand.New(rand.NewSource(seed)),
+	}
+	g.sources = g.picker(workload.Sources)
+	g.destinations = g.picker(workload.Destinations)
+
+	names := make([]string, 0, len(workload.QoSClasses))
+	for name := range workload.QoSClasses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var total float64
+	for _, name := range names {
+		class, _ := parseQoSClass(name)
+		total += workload.QoSClasses[name]
+		g.classes = append(g.classes, class)
+		g.weights = append(g.weights, total)
+	}
+	return g
+}
+
+// picker returns a function drawing node IDs from popularity
+func (g *generator) picker(popularity Popularity) func() int64 {
+	if popularity.Distribution == Zipfian {
+		zipf := rand.NewZipf(g.random, popularity.Skew, 1, uint64(g.nodes-1))
+		return func() int64 {
+			return int64(zipf.Uint64()) + 1
+		}
+	}
+	return func() int64 {
+		return int64(g.random.Intn(g.nodes)) + 1
+	}
+}
+
+// next draws the next operation. Source and destination always differ.
+func (g *generator) next() operation {
+	source := g.sources()
+	destination := g.destinations()
+	for destination == source {
+		destination = int64(g.random.Intn(g.nodes)) + 1
+	}
+
+	op := operation{
+		Request: Request{Source: source, Destination: destination},
+		write:   g.workload.Writes > 0 && g.random.Float64() < g.workload.Writes,
+	}
+
+	pick := g.random.Float64() * g.weights[len(g.weights)-1]
+	op.QoSClass = g.classes[sort.Search(len(g.weights), func(i int) bool {
+		return g.weights[i] > pick
+	})]
+	return op
+}
+
+// interval returns the gap before the next operation of an open-loop
+// arrival process
+func (g *generator) interval() time.Duration {
+	mean := float64(time.Second) / g.workload.Arrival.Rate
+	if g.workload.Arrival.Process == Poisson {
+		return time.Duration(g.random.ExpFloat64() * mean)
+	}
+	return time.Duration(mean)
+}
+
+// parseQoSClass returns the QoS class with the given snake_case name
+func parseQoSClass(name string) (routing.QoSClass, error) {
+	for class := routing.BestEffort; class <= routing.CriticalMission; class++ {
+		if class.String() == name {
+			return class, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown QoS class %q", name)
+}
+
+// DefaultWorkload returns closed-loop low-latency lookups between uniformly
+// chosen nodes
+func DefaultWorkload() *Workload {
+	return &Workload{
+		Name:         "uniform",
+		Sources:      Popularity{Distribution: Uniform},
+		Destinations: Popularity{Distribution: Uniform},
+		QoSClasses:   map[string]float64{routing.LowLatency.String(): 1},
+		Arrival:      Arrival{Process: Closed},
+	}
+}
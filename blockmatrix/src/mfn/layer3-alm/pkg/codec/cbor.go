@@ -0,0 +1,620 @@
+// Package codec implements a compact CBOR (RFC 8949) encoding of graph
+// updates and association exports
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/associative"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+// CBOR major types
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+// Null and float heads under major type 7
+const (
+	cborNull    = 22
+	cborFloat16 = 25
+	cborFloat32 = 26
+	cborFloat64 = 27
+)
+
+// maxCBORNesting bounds recursion when skipping values
+const maxCBORNesting = 32
+
+var errTruncated = errors.New("cbor: unexpected end of data")
+
+// cborCodec encodes structs as arrays of their fields in a fixed order
+// rather than maps keyed by name, floats in the shortest form that keeps
+// their value, durations as nanoseconds and times as Unix nanoseconds, zero
+// for the zero time. Decoders skip fields appended by newer peers, so fields
+// may be added at the end of an array but never reordered.
+//
+// A batch of graph updates is an array of
+//
+//	[type, node_id, edge_from, edge_to, node or null, edge or null]
+//
+// with nodes encoded as
+//
+//	[id, address, region, latitude, longitude, latency, throughput,
+//	 reliability, load_factor, last_seen, {name: service}, [capability],
+//	 provider]
+//	service: [name, version, port, protocol, health_score, [endpoint]]
+//
+// and edges as
+//
+//	[from, to, weight, latency, bandwidth, packet_loss, jitter, cost,
+//	 reliability, stability, last_update]
+//
+// Exported associations are an array of [from, to, type, weight,
+// last_update], keyed by AssociationExport.Key when decoded.
+type cborCodec struct{}
+
+func (cborCodec) Name() string {
+	return "cbor"
+}
+
+func (cborCodec) Marshal(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case *[]graph.GraphUpdate:
+		return appendGraphUpdates(nil, *v), nil
+	case *map[string]associative.AssociationExport:
+		return appendAssociations(nil, *v), nil
+	}
+	return nil, fmt.Errorf("codec cannot encode %T", v)
+}
+
+func (cborCodec) Unmarshal(data []byte, v interface{}) error {
+	r := &cborReader{data: data}
+	switch v := v.(type) {
+	case *[]graph.GraphUpdate:
+		*v = r.graphUpdates()
+	case *map[string]associative.AssociationExport:
+		*v = r.associations()
+	default:
+		return fmt.Errorf("codec cannot decode into %T", v)
+	}
+	if r.err == nil && len(r.data) > 0 {
+		r.err = fmt.Errorf("cbor: %d bytes after value", len(r.data))
+	}
+	return r.err
+}
+
+// Encoding
+
+func appendGraphUpdates(b []byte, updates []graph.GraphUpdate) []byte {
+	b = appendHead(b, cborArray, uint64(len(updates)))
+	for i := range updates {
+		update := &updates[i]
+		b = appendHead(b, cborArray, 6)
+		b = appendInt(b, int64(update.Type))
+		b = appendInt(b, update.NodeID)
+		b = appendInt(b, update.EdgeFrom)
+		b = appendInt(b, update.EdgeTo)
+		if update.Node != nil {
+			b = appendNode(b, update.Node)
+		} else {
+			b = append(b, cborSimple<<5|cborNull)
+		}
+		if update.Edge != nil {
+			b = appendEdge(b, update.Edge)
+		} else {
+			b = append(b, cborSimple<<5|cborNull)
+		}
+	}
+	return b
+}
+
+// appendNode encodes a node. Its fields are read without its lock, so the
+// caller must own the node.
+func appendNode(b []byte, node *graph.NetworkNode) []byte {
+	b = appendHead(b, cborArray, 13)
+	b = appendInt(b, node.ID)
+	b = appendText(b, node.Address)
+	b = appendText(b, node.Region)
+	b = appendFloat(b, node.Latitude)
+	b = appendFloat(b, node.Longitude)
+	b = appendInt(b, int64(node.Latency))
+	b = appendFloat(b, node.Throughput)
+	b = appendFloat(b, node.Reliability)
+	b = appendFloat(b, node.LoadFactor)
+	b = appendTime(b, node.LastSeen)
+
+	b = appendHead(b, cborMap, uint64(len(node.Services)))
+	for _, name := range sortedKeys(node.Services) {
+		service := node.Services[name]
+		b = appendText(b, name)
+		b = appendHead(b, cborArray, 6)
+		b = appendText(b, service.Name)
+		b = appendText(b, service.Version)
+		b = appendInt(b, int64(service.Port))
+		b = appendText(b, service.Protocol)
+		b = appendFloat(b, service.HealthScore)
+		b = appendTexts(b, service.Endpoints)
+	}
+	b = appendTexts(b, node.Capabilities)
+	return appendText(b, node.Provider)
+}
+
+func appendEdge(b []byte, edge *graph.NetworkEdge) []byte {
+	b = appendHead(b, cborArray, 11)
+	b = appendInt(b, edge.From)
+	b = appendInt(b, edge.To)
+	b = appendFloat(b, edge.Weight)
+	b = appendInt(b, int64(edge.Latency))
+	b = appendFloat(b, edge.Bandwidth)
+	b = appendFloat(b, edge.PacketLoss)
+	b = appendInt(b, int64(edge.Jitter))
+	b = appendFloat(b, edge.Cost)
+	b = appendFloat(b, edge.Reliability)
+	b = appendFloat(b, edge.Stability)
+	return appendTime(b, edge.LastUpdate)
+}
+
+// appendAssociations writes associations in key order
+func appendAssociations(b []byte, associations map[string]associative.AssociationExport) []byte {
+	b = appendHead(b, cborArray, uint64(len(associations)))
+	for _, key := range sortedKeys(associations) {
+		association := associations[key]
+		b = appendHead(b, cborArray, 5)
+		b = appendInt(b, association.From)
+		b = appendInt(b, association.To)
+		b = appendInt(b, int64(association.Type))
+		b = appendFloat(b, association.Weight)
+		b = appendTime(b, association.LastUpdate)
+	}
+	return b
+}
+
+// appendHead writes a major type and its argument in the fewest bytes
+func appendHead(b []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return append(b, major|25, byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		return append(b, major|26, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(b, major|27, byte(n>>56), byte(n>>48), byte(n>>40), byte(n>>32),
+		byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+func appendInt(b []byte, v int64) []byte {
+	if v < 0 {
+		return appendHead(b, cborNegInt, uint64(-1-v))
+	}
+	return appendHead(b, cborUint, uint64(v))
+}
+
+// appendFloat writes v as a half, single or double precision float,
+// whichever is shortest without losing precision
+func appendFloat(b []byte, v float64) []byte {
+	f32 := float32(v)
+	if float64(f32) != v && !math.IsNaN(v) {
+		bits := math.Float64bits(v)
+		return append(b, cborSimple<<5|cborFloat64, byte(bits>>56), byte(bits>>48), byte(bits>>40),
+			byte(bits>>32), byte(bits>>24), byte(bits>>16), byte(bits>>8), byte(bits))
+	}
+	if half, ok := float16(f32); ok {
+		return append(b, cborSimple<<5|cborFloat16, byte(half>>8), byte(half))
+	}
+	bits := math.Float32bits(f32)
+	return append(b, cborSimple<<5|cborFloat32, byte(bits>>24), byte(bits>>16), byte(bits>>8), byte(bits))
+}
+
+// float16 returns the half precision bits of f if it converts exactly
+func float16(f float32) (uint16, bool) {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exponent := int(bits>>23&0xff) - 127
+	mantissa := bits & 0x7fffff
+
+	switch {
+	case bits&0x7fffffff == 0:
+		return sign, true
+	case exponent == 128:
+		// Infinities, and NaN as the canonical quiet NaN
+		if mantissa != 0 {
+			return 0x7e00, true
+		}
+		return sign | 0x7c00, true
+	case exponent >= -14 && exponent <= 15 && mantissa&0x1fff == 0:
+		return sign | uint16(exponent+15)<<10 | uint16(mantissa>>13), true
+	case exponent >= -24 && exponent < -14:
+		// Subnormal: the implicit leading bit joins the mantissa
+		shift := uint(-exponent - 1)
+		full := mantissa | 0x800000
+		if full&(1<<shift-1) == 0 {
+			return sign | uint16(full>>shift), true
+		}
+	}
+	return 0, false
+}
+
+func appendText(b []byte, s string) []byte {
+	b = appendHead(b, cborText, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendTexts(b []byte, values []string) []byte {
+	b = appendHead(b, cborArray, uint64(len(values)))
+	for _, v := range values {
+		b = appendText(b, v)
+	}
+	return b
+}
+
+func appendTime(b []byte, t time.Time) []byte {
+	if t.IsZero() {
+		return appendInt(b, 0)
+	}
+	return appendInt(b, t.UnixNano())
+}
+
+// sortedKeys returns the keys of m in order, so encodings are deterministic
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Decoding
+
+// cborReader decodes values from data, keeping the first error. Once an
+// error is set every read returns a zero value.
+type cborReader struct {
+	data []byte
+	err  error
+}
+
+func (r *cborReader) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf("cbor: "+format, args...)
+	}
+}
+
+// head reads a major type and its argument
+func (r *cborReader) head() (byte, uint64) {
+	if r.err != nil {
+		return 0, 0
+	}
+	if len(r.data) == 0 {
+		r.err = errTruncated
+		return 0, 0
+	}
+
+	major, info := r.data[0]>>5, r.data[0]&0x1f
+	r.data = r.data[1:]
+	if info < 24 {
+		return major, uint64(info)
+	}
+
+	var size int
+	switch info {
+	case 24:
+		size = 1
+	case 25:
+		size = 2
+	case 26:
+		size = 4
+	case 27:
+		size = 8
+	default:
+		r.fail("unsupported additional information %d", info)
+		return 0, 0
+	}
+	if len(r.data) < size {
+		r.err = errTruncated
+		return 0, 0
+	}
+	var n uint64
+	for _, c := range r.data[:size] {
+		n = n<<8 | uint64(c)
+	}
+	r.data = r.data[size:]
+	return major, n
+}
+
+// null consumes a null if one is next and reports whether it did
+func (r *cborReader) null() bool {
+	if r.err == nil && len(r.data) > 0 && r.data[0] == cborSimple<<5|cborNull {
+		r.data = r.data[1:]
+		return true
+	}
+	return false
+}
+
+func (r *cborReader) int() int64 {
+	major, n := r.head()
+	switch {
+	case r.err != nil:
+		return 0
+	case n > math.MaxInt64:
+		r.fail("integer overflows int64")
+		return 0
+	case major == cborUint:
+		return int64(n)
+	case major == cborNegInt:
+		return -1 - int64(n)
+	}
+	r.fail("expected integer, got major type %d", major)
+	return 0
+}
+
+// float reads a float of any precision, or an integer
+func (r *cborReader) float() float64 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) == 0 {
+		r.err = errTruncated
+		return 0
+	}
+
+	initial := r.data[0]
+	if major := initial >> 5; major == cborUint || major == cborNegInt {
+		return float64(r.int())
+	}
+	_, n := r.head()
+	if r.err != nil {
+		return 0
+	}
+	switch initial {
+	case cborSimple<<5 | cborFloat16:
+		return float64(fromFloat16(uint16(n)))
+	case cborSimple<<5 | cborFloat32:
+		return float64(math.Float32frombits(uint32(n)))
+	case cborSimple<<5 | cborFloat64:
+		return math.Float64frombits(n)
+	}
+	r.fail("expected float, got initial byte %#x", initial)
+	return 0
+}
+
+// fromFloat16 converts half precision bits to a float
+func fromFloat16(half uint16) float32 {
+	sign := uint32(half&0x8000) << 16
+	exponent := uint32(half >> 10 & 0x1f)
+	mantissa := uint32(half & 0x3ff)
+
+	switch exponent {
+	case 0:
+		// Zero or subnormal: mantissa * 2^-24
+		f := float32(mantissa) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case 0x1f:
+		if mantissa != 0 {
+			return float32(math.NaN())
+		}
+		return math.Float32frombits(sign | 0x7f800000)
+	}
+	return math.Float32frombits(sign | (exponent+127-15)<<23 | mantissa<<13)
+}
+
+func (r *cborReader) text() string {
+	major, n := r.head()
+	if r.err != nil {
+		return ""
+	}
+	if major != cborText {
+		r.fail("expected text, got major type %d", major)
+		return ""
+	}
+	if uint64(len(r.data)) < n {
+		r.err = errTruncated
+		return ""
+	}
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s
+}
+
+func (r *cborReader) texts() []string {
+	n := r.array(0)
+	if n == 0 {
+		return nil
+	}
+	values := make([]string, n)
+	for i := range values {
+		values[i] = r.text()
+	}
+	return values
+}
+
+func (r *cborReader) time() time.Time {
+	if ns := r.int(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
+// array reads an array head holding at least fields items and returns its
+// length
+func (r *cborReader) array(fields int) int {
+	return r.container(cborArray, fields)
+}
+
+func (r *cborReader) container(want byte, fields int) int {
+	major, n := r.head()
+	if r.err != nil {
+		return 0
+	}
+	if major != want {
+		r.fail("expected major type %d, got %d", want, major)
+		return 0
+	}
+	// Every item takes at least a byte, which bounds allocations by the
+	// size of the input
+	if n > uint64(len(r.data)) {
+		r.err = errTruncated
+		return 0
+	}
+	if n < uint64(fields) {
+		r.fail("expected %d fields, got %d", fields, n)
+		return 0
+	}
+	return int(n)
+}
+
+// skip discards the next n values, such as fields added by newer peers
+func (r *cborReader) skip(n int, depth int) {
+	if depth > maxCBORNesting {
+		r.fail("nesting deeper than %d", maxCBORNesting)
+		return
+	}
+	for i := 0; i < n && r.err == nil; i++ {
+		major, arg := r.head()
+		switch major {
+		case cborBytes, cborText:
+			if uint64(len(r.data)) < arg {
+				r.err = errTruncated
+				return
+			}
+			r.data = r.data[arg:]
+		case cborArray, cborMap:
+			if arg > uint64(len(r.data)) {
+				r.err = errTruncated
+				return
+			}
+			items := int(arg)
+			if major == cborMap {
+				items *= 2
+			}
+			r.skip(items, depth+1)
+		case cborTag:
+			r.skip(1, depth+1)
+		}
+	}
+}
+
+func (r *cborReader) graphUpdates() []graph.GraphUpdate {
+	n := r.array(0)
+	if r.err != nil {
+		return nil
+	}
+	updates := make([]graph.GraphUpdate, n)
+	for i := range updates {
+		fields := r.array(6)
+		update := &updates[i]
+		update.Type = graph.UpdateType(r.int())
+		update.NodeID = r.int()
+		update.EdgeFrom = r.int()
+		update.EdgeTo = r.int()
+		if !r.null() {
+			update.Node = r.node()
+		}
+		if !r.null() {
+			update.Edge = r.edge()
+		}
+		r.skip(fields-6, 0)
+	}
+	if r.err != nil {
+		return nil
+	}
+	return updates
+}
+
+func (r *cborReader) node() *graph.NetworkNode {
+	fields := r.array(12)
+	node := &graph.NetworkNode{
+		ID:          r.int(),
+		Address:     r.text(),
+		Region:      r.text(),
+		Latitude:    r.float(),
+		Longitude:   r.float(),
+		Latency:     time.Duration(r.int()),
+		Throughput:  r.float(),
+		Reliability: r.float(),
+		LoadFactor:  r.float(),
+		LastSeen:    r.time(),
+	}
+
+	services := r.container(cborMap, 0)
+	node.Services = make(map[string]graph.ServiceInfo, services)
+	for i := 0; i < services && r.err == nil; i++ {
+		name := r.text()
+		serviceFields := r.array(6)
+		node.Services[name] = graph.ServiceInfo{
+			Name:        r.text(),
+			Version:     r.text(),
+			Port:        int(r.int()),
+			Protocol:    r.text(),
+			HealthScore: r.float(),
+			Endpoints:   r.texts(),
+		}
+		r.skip(serviceFields-6, 0)
+	}
+	node.Capabilities = r.texts()
+	if fields > 12 {
+		node.Provider = r.text()
+	}
+	r.skip(fields-13, 0)
+	return node
+}
+
+func (r *cborReader) edge() *graph.NetworkEdge {
+	fields := r.array(11)
+	edge := &graph.NetworkEdge{
+		From:        r.int(),
+		To:          r.int(),
+		Weight:      r.float(),
+		Latency:     time.Duration(r.int()),
+		Bandwidth:   r.float(),
+		PacketLoss:  r.float(),
+		Jitter:      time.Duration(r.int()),
+		Cost:        r.float(),
+		Reliability: r.float(),
+		Stability:   r.float(),
+		LastUpdate:  r.time(),
+	}
+	r.skip(fields-11, 0)
+	return edge
+}
+
+func (r *cborReader) associations() map[string]associative.AssociationExport {
+	n := r.array(0)
+	if r.err != nil {
+		return nil
+	}
+	associations := make(map[string]associative.AssociationExport, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		fields := r.array(5)
+		association := associative.AssociationExport{
+			From:       r.int(),
+			To:         r.int(),
+			Type:       associative.AssociationType(r.int()),
+			Weight:     r.float(),
+			LastUpdate: r.time(),
+		}
+		r.skip(fields-5, 0)
+		associations[association.Key()] = association
+	}
+	if r.err != nil {
+		return nil
+	}
+	return associations
+}
@@ -0,0 +1,260 @@
+package codec
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/associative"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+// sampleUpdates returns a batch adding nodes nodes, each linked to the next,
+// then updating and removing a few of them
+func sampleUpdates(nodes int) []graph.GraphUpdate {
+	seen := time.Unix(1700000000, 123456789)
+	var updates []graph.GraphUpdate
+	for i := int64(1); i <= int64(nodes); i++ {
+		node := &graph.NetworkNode{
+			ID:          i,
+			Address:     fmt.Sprintf("10.0.%d.%d:9000", i/256, i%256),
+			Region:      []string{"eu-west-1", "us-east-1", "ap-south-1"}[i%3],
+			Provider:    []string{"aws", "gcp"}[i%2],
+			Latitude:    52.5 + float64(i)/100,
+			Longitude:   13.25,
+			Latency:     time.Duration(i) * 37 * time.Microsecond,
+			Throughput:  940,
+			Reliability: 0.995,
+			LoadFactor:  float64(i%10) / 10,
+			LastSeen:    seen,
+			Services: map[string]graph.ServiceInfo{
+				"search": {Name: "search", Version: "v2", Port: 8443, Protocol: "grpc", HealthScore: 0.9, Endpoints: []string{"/search"}},
+			},
+			Capabilities: []string{"storage.object"},
+		}
+		updates = append(updates, graph.GraphUpdate{Type: graph.NodeAdd, NodeID: i, Node: node})
+		if i > 1 {
+			edge := &graph.NetworkEdge{
+				From:        i - 1,
+				To:          i,
+				Weight:      1.5,
+				Latency:     time.Duration(i) * time.Millisecond,
+				Bandwidth:   1000,
+				PacketLoss:  0.001,
+				Jitter:      250 * time.Microsecond,
+				Cost:        2,
+				Reliability: 0.99,
+				Stability:   0.9,
+				LastUpdate:  seen,
+			}
+			updates = append(updates, graph.GraphUpdate{Type: graph.EdgeAdd, EdgeFrom: i - 1, EdgeTo: i, Edge: edge})
+		}
+	}
+	updates = append(updates,
+		graph.GraphUpdate{Type: graph.EdgeRemove, EdgeFrom: 1, EdgeTo: 2},
+		graph.GraphUpdate{Type: graph.NodeRemove, NodeID: 1},
+	)
+	return updates
+}
+
+// sampleAssociations returns n learned node to service affinities
+func sampleAssociations(n int) map[string]associative.AssociationExport {
+	associations := make(map[string]associative.AssociationExport, n)
+	for i := 0; i < n; i++ {
+		association := associative.AssociationExport{
+			From:       int64(i % 100),
+			To:         int64(i / 100),
+			Type:       associative.NodeToService,
+			Weight:     float64(i%97) / 97,
+			LastUpdate: time.Unix(1700000000, int64(i)*1000),
+		}
+		associations[association.Key()] = association
+	}
+	return associations
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{JSON, CBOR} {
+		updates := sampleUpdates(20)
+		data, err := codec.Marshal(&updates)
+		if err != nil {
+			t.Fatalf("%s: Marshal updates: %v", codec.Name(), err)
+		}
+		decoded := []graph.GraphUpdate{{NodeID: 99}}
+		if err := codec.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("%s: Unmarshal updates: %v", codec.Name(), err)
+		}
+		if !reflect.DeepEqual(normalize(decoded), normalize(updates)) {
+			t.Errorf("%s: graph updates changed in a round trip", codec.Name())
+		}
+
+		associations := sampleAssociations(50)
+		data, err = codec.Marshal(&associations)
+		if err != nil {
+			t.Fatalf("%s: Marshal associations: %v", codec.Name(), err)
+		}
+		var decodedAssociations map[string]associative.AssociationExport
+		if err := codec.Unmarshal(data, &decodedAssociations); err != nil {
+			t.Fatalf("%s: Unmarshal associations: %v", codec.Name(), err)
+		}
+		if !reflect.DeepEqual(normalizeAssociations(decodedAssociations), normalizeAssociations(associations)) {
+			t.Errorf("%s: associations changed in a round trip", codec.Name())
+		}
+
+		if _, err := codec.Marshal(&struct{}{}); err == nil {
+			t.Errorf("%s: encoded a type that is not synced", codec.Name())
+		}
+	}
+}
+
+// normalize puts times in UTC, as JSON keeps their zone and CBOR does not
+func normalize(updates []graph.GraphUpdate) []graph.GraphUpdate {
+	for _, update := range updates {
+		if update.Node != nil {
+			update.Node.LastSeen = update.Node.LastSeen.UTC()
+		}
+		if update.Edge != nil {
+			update.Edge.LastUpdate = update.Edge.LastUpdate.UTC()
+		}
+	}
+	return updates
+}
+
+func normalizeAssociations(associations map[string]associative.AssociationExport) map[string]associative.AssociationExport {
+	for key, association := range associations {
+		association.LastUpdate = association.LastUpdate.UTC()
+		associations[key] = association
+	}
+	return associations
+}
+
+func TestCBORIsSmallerThanJSON(t *testing.T) {
+	updates := sampleUpdates(100)
+	associations := sampleAssociations(1000)
+	for _, tc := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"graph updates", &updates},
+		{"associations", &associations},
+	} {
+		asJSON, _ := JSON.Marshal(tc.value)
+		asCBOR, _ := CBOR.Marshal(tc.value)
+		if len(asCBOR)*2 > len(asJSON) {
+			t.Errorf("%s take %d bytes as CBOR, want under half of %d as JSON", tc.name, len(asCBOR), len(asJSON))
+		}
+	}
+}
+
+func TestCBORFloats(t *testing.T) {
+	for _, tc := range []struct {
+		value float64
+		size  int
+	}{
+		{0, 3},
+		{math.Copysign(0, -1), 3},
+		{1.5, 3},
+		{-65504, 3},
+		{math.Ldexp(1, -24), 3},
+		{math.Inf(1), 3},
+		{100000, 5},
+		{0.1, 9},
+		{0.995, 9},
+	} {
+		data := appendFloat(nil, tc.value)
+		if len(data) != tc.size {
+			t.Errorf("%g encoded in %d bytes, want %d", tc.value, len(data), tc.size)
+		}
+		r := &cborReader{data: data}
+		got := r.float()
+		if r.err != nil || math.Float64bits(got) != math.Float64bits(tc.value) {
+			t.Errorf("%g decoded as %g (%v)", tc.value, got, r.err)
+		}
+	}
+
+	r := &cborReader{data: appendFloat(nil, math.NaN())}
+	if got := r.float(); !math.IsNaN(got) {
+		t.Errorf("NaN decoded as %g", got)
+	}
+}
+
+func TestCBORSkipsFieldsAddedByNewerPeers(t *testing.T) {
+	// An association with a sixth field holding an array
+	data := appendHead(nil, cborArray, 1)
+	data = appendHead(data, cborArray, 6)
+	data = appendInt(data, 7)
+	data = appendInt(data, 3)
+	data = appendInt(data, int64(associative.NodeToService))
+	data = appendFloat(data, 0.5)
+	data = appendInt(data, 0)
+	data = appendTexts(data, []string{"future"})
+
+	var associations map[string]associative.AssociationExport
+	if err := CBOR.Unmarshal(data, &associations); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	want := associative.AssociationExport{From: 7, To: 3, Type: associative.NodeToService, Weight: 0.5}
+	if got := associations[want.Key()]; !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded %+v, want %+v", got, want)
+	}
+}
+
+func TestCBORDecodesNodesFromOlderPeers(t *testing.T) {
+	// A node from before providers were encoded: twelve fields, the empty
+	// provider text dropped from the end
+	node := sampleUpdates(1)[0].Node
+	node.Provider = ""
+	encoded := appendNode(nil, node)
+	encoded[0] = cborArray<<5 | 12
+	encoded = encoded[:len(encoded)-1]
+
+	data := appendHead(nil, cborArray, 1)
+	data = appendHead(data, cborArray, 6)
+	data = appendInt(data, int64(graph.NodeAdd))
+	data = appendInt(data, node.ID)
+	data = appendInt(data, 0)
+	data = appendInt(data, 0)
+	data = append(data, encoded...)
+	data = append(data, cborSimple<<5|cborNull)
+
+	var updates []graph.GraphUpdate
+	if err := CBOR.Unmarshal(data, &updates); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if got := updates[0].Node; got.Address != node.Address || got.Provider != "" || len(got.Capabilities) != 1 {
+		t.Errorf("decoded %+v", got)
+	}
+}
+
+func TestCBORRejectsMalformedInput(t *testing.T) {
+	updates := sampleUpdates(3)
+	data, _ := CBOR.Marshal(&updates)
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"truncated", data[:len(data)-1]},
+		{"trailing bytes", append(append([]byte{}, data...), 0)},
+		{"wrong type", appendText(nil, "updates")},
+		{"huge array", []byte{cborArray<<5 | 27, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	} {
+		var decoded []graph.GraphUpdate
+		if err := CBOR.Unmarshal(tc.data, &decoded); err == nil {
+			t.Errorf("%s: decoded without error", tc.name)
+		}
+	}
+}
+
+func TestByName(t *testing.T) {
+	for _, name := range []string{"json", "cbor"} {
+		if codec, err := ByName(name); err != nil || codec.Name() != name {
+			t.Errorf("ByName(%q) = %v, %v", name, codec, err)
+		}
+	}
+	if _, err := ByName("xml"); err == nil {
+		t.Error("ByName accepted an unknown codec")
+	}
+}
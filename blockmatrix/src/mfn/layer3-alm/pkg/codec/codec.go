@@ -0,0 +1,76 @@
+// Package codec implements encodings of topology and association state for
+// sync between coordinators: JSON, and CBOR for a fraction of the size
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/associative"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+// Codec encodes the state coordinators sync: batches of graph updates, as
+// *[]graph.GraphUpdate, and exported associations, as
+// *map[string]associative.AssociationExport
+type Codec interface {
+	// Name identifies the encoding, such as "json" or "cbor"
+	Name() string
+
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal decodes data into v, replacing its contents
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Codecs by name
+var (
+	JSON Codec = jsonCodec{}
+	CBOR Codec = cborCodec{}
+)
+
+// ByName returns the codec with the given name
+func ByName(name string) (Codec, error) {
+	for _, codec := range []Codec{JSON, CBOR} {
+		if codec.Name() == name {
+			return codec, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown codec %q", name)
+}
+
+// jsonCodec encodes with encoding/json, readable and accepted by any peer
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	if err := checkType(v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	if err := checkType(v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case *[]graph.GraphUpdate:
+		*v = nil
+	case *map[string]associative.AssociationExport:
+		*v = nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// checkType rejects values other than the synced state types
+func checkType(v interface{}) error {
+	switch v.(type) {
+	case *[]graph.GraphUpdate, *map[string]associative.AssociationExport:
+		return nil
+	}
+	return fmt.Errorf("codec cannot encode %T", v)
+}
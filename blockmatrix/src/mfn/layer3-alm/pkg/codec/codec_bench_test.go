@@ -0,0 +1,74 @@
+// Package codec implements benchmarks comparing codec speed and encoded size
+package codec
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/associative"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+// BenchmarkMarshal measures encoding sync batches with each codec and
+// reports their size as bytes/msg and as a fraction of the JSON encoding
+func BenchmarkMarshal(b *testing.B) {
+	updates := sampleUpdates(100)
+	associations := sampleAssociations(1000)
+	values := []struct {
+		name  string
+		value interface{}
+	}{
+		{"graph-updates", &updates},
+		{"associations", &associations},
+	}
+
+	for _, v := range values {
+		asJSON, err := JSON.Marshal(v.value)
+		if err != nil {
+			b.Fatalf("failed to encode %s as JSON: %v", v.name, err)
+		}
+		for _, codec := range []Codec{JSON, CBOR} {
+			b.Run(fmt.Sprintf("%s/%s", v.name, codec.Name()), func(b *testing.B) {
+				var data []byte
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					data, _ = codec.Marshal(v.value)
+				}
+				b.ReportMetric(float64(len(data)), "bytes/msg")
+				b.ReportMetric(float64(len(data))/float64(len(asJSON)), "size/json")
+			})
+		}
+	}
+}
+
+// BenchmarkUnmarshal measures decoding sync batches with each codec
+func BenchmarkUnmarshal(b *testing.B) {
+	updates := sampleUpdates(100)
+	associations := sampleAssociations(1000)
+
+	for _, codec := range []Codec{JSON, CBOR} {
+		updateData, _ := codec.Marshal(&updates)
+		associationData, _ := codec.Marshal(&associations)
+
+		b.Run("graph-updates/"+codec.Name(), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(updateData)))
+			for i := 0; i < b.N; i++ {
+				var decoded []graph.GraphUpdate
+				if err := codec.Unmarshal(updateData, &decoded); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run("associations/"+codec.Name(), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(associationData)))
+			for i := 0; i < b.N; i++ {
+				var decoded map[string]associative.AssociationExport
+				if err := codec.Unmarshal(associationData, &decoded); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// Package graph implements edge pricing from node providers and regions
+package graph
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CostModel prices the edges of a graph, so route costs and the cost
+// objective are in money rather than arbitrary units. Prices are per GB
+// sent over the edge.
+type CostModel interface {
+	Name() string
+
+	// EdgeCost returns the price of sending a GB over edge, from node from
+	// to node to
+	EdgeCost(from, to *NetworkNode, edge *NetworkEdge) float64
+}
+
+// FlatCostModel prices every edge the same, for networks billed per link
+// rather than by traffic
+type FlatCostModel struct {
+	LinkCost float64
+}
+
+// Name returns "flat"
+func (f FlatCostModel) Name() string { return "flat" }
+
+// EdgeCost returns the link cost
+func (f FlatCostModel) EdgeCost(from, to *NetworkNode, edge *NetworkEdge) float64 {
+	return f.LinkCost
+}
+
+// EgressRule prices traffic leaving From for To, each a provider such as
+// "aws", a provider and region such as "aws/us-east-1", or "*" for any
+type EgressRule struct {
+	From       string
+	To         string
+	PricePerGB float64
+}
+
+// specificity ranks how narrowly the rule matches, or returns -1 if it
+// does not match traffic from one location to the other
+func (r EgressRule) specificity(from, to *NetworkNode) int {
+	fromRank, toRank := matchLocation(r.From, from), matchLocation(r.To, to)
+	if fromRank < 0 || toRank < 0 {
+		return -1
+	}
+	return fromRank + toRank
+}
+
+// matchLocation ranks a "*", "provider" or "provider/region" pattern
+// matching node by how specific it is, or returns -1
+func matchLocation(pattern string, node *NetworkNode) int {
+	if pattern == "*" {
+		return 0
+	}
+	provider, region, hasRegion := strings.Cut(pattern, "/")
+	if !strings.EqualFold(provider, node.Provider) {
+		return -1
+	}
+	if !hasRegion {
+		return 1
+	}
+	if !strings.EqualFold(region, node.Region) {
+		return -1
+	}
+	return 2
+}
+
+// EgressCostModel prices edges by the egress charged between the
+// providers and regions of their nodes, plus a flat per-link cost. Traffic
+// within a provider's region costs IntraRegion; otherwise the most specific
+// matching rule applies, the earliest on ties, or Default when none does.
+type EgressCostModel struct {
+	Rules       []EgressRule
+	IntraRegion float64
+	Default     float64
+	LinkCost    float64
+}
+
+// Validate checks the rules' locations and that no price is negative
+func (e *EgressCostModel) Validate() error {
+	if e.IntraRegion < 0 || e.Default < 0 || e.LinkCost < 0 {
+		return fmt.Errorf("egress prices must not be negative")
+	}
+	for _, rule := range e.Rules {
+		for _, location := range []string{rule.From, rule.To} {
+			provider, region, hasRegion := strings.Cut(location, "/")
+			if provider == "" || (hasRegion && region == "") || (provider == "*" && hasRegion) {
+				return fmt.Errorf("egress location %q must be \"*\", a provider or provider/region", location)
+			}
+		}
+		if rule.PricePerGB < 0 {
+			return fmt.Errorf("egress price from %s to %s must not be negative", rule.From, rule.To)
+		}
+	}
+	return nil
+}
+
+// Name returns "egress"
+func (e *EgressCostModel) Name() string { return "egress" }
+
+// EdgeCost returns the egress price between the edge's nodes plus the link
+// cost
+func (e *EgressCostModel) EdgeCost(from, to *NetworkNode, edge *NetworkEdge) float64 {
+	if from.Provider != "" && strings.EqualFold(from.Provider, to.Provider) && strings.EqualFold(from.Region, to.Region) {
+		return e.IntraRegion + e.LinkCost
+	}
+
+	price, best := e.Default, -1
+	for _, rule := range e.Rules {
+		if rank := rule.specificity(from, to); rank > best {
+			price, best = rule.PricePerGB, rank
+		}
+	}
+	return price + e.LinkCost
+}
+
+// SetCostModel prices every edge with model, now and as edges are added,
+// replacing the costs they were added with. A nil model leaves the current
+// costs and prices no further edges.
+func (ng *NetworkGraph) SetCostModel(model CostModel) {
+	ng.mutex.Lock()
+	defer ng.mutex.Unlock()
+
+	ng.costModel = model
+	if model == nil {
+		return
+	}
+	for _, edge := range ng.topology.edges(ng.totalEdges) {
+		ng.priceEdge(edge)
+	}
+
+	// Cached paths carry the costs they were found with
+	ng.pathCache.InvalidateAll()
+}
+
+// priceEdge sets edge's cost from the cost model, if there is one. The
+// caller must hold the write lock.
+func (ng *NetworkGraph) priceEdge(edge *NetworkEdge) {
+	if ng.costModel == nil {
+		return
+	}
+	from, fromExists := ng.topology.node(edge.From)
+	to, toExists := ng.topology.node(edge.To)
+	if fromExists && toExists {
+		edge.Cost = ng.costModel.EdgeCost(from, to, edge)
+	}
+}
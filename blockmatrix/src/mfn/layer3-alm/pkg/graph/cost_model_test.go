@@ -0,0 +1,89 @@
+package graph
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEgressCostModel(t *testing.T) {
+	model := &EgressCostModel{
+		Rules: []EgressRule{
+			{From: "aws", To: "*", PricePerGB: 0.09},
+			{From: "aws", To: "aws", PricePerGB: 0.02},
+			{From: "aws/us-east-1", To: "aws/us-east-2", PricePerGB: 0.01},
+		},
+		IntraRegion: 0.001,
+		Default:     0.12,
+		LinkCost:    0.005,
+	}
+	if err := model.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	virginia := &NetworkNode{Provider: "aws", Region: "us-east-1"}
+	ohio := &NetworkNode{Provider: "aws", Region: "us-east-2"}
+	ireland := &NetworkNode{Provider: "AWS", Region: "eu-west-1"}
+	belgium := &NetworkNode{Provider: "gcp", Region: "europe-west1"}
+
+	cases := []struct {
+		name     string
+		from, to *NetworkNode
+		want     float64
+	}{
+		{"same region", virginia, &NetworkNode{Provider: "aws", Region: "us-east-1"}, 0.001},
+		{"region pair rule", virginia, ohio, 0.01},
+		{"within provider", ohio, virginia, 0.02},
+		{"provider names ignore case", virginia, ireland, 0.02},
+		{"internet egress", virginia, belgium, 0.09},
+		{"no rule", belgium, virginia, 0.12},
+	}
+	for _, tc := range cases {
+		if got := model.EdgeCost(tc.from, tc.to, &NetworkEdge{}); math.Abs(got-(tc.want+0.005)) > 1e-12 {
+			t.Errorf("%s: cost %g, want %g", tc.name, got, tc.want+0.005)
+		}
+	}
+
+	for _, rule := range []EgressRule{{From: "*/us-east-1", To: "aws"}, {From: "aws/", To: "*"}, {From: "aws", To: "*", PricePerGB: -1}} {
+		invalid := &EgressCostModel{Rules: []EgressRule{rule}}
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("rule %+v accepted", rule)
+		}
+	}
+}
+
+func TestSetCostModelPricesEdges(t *testing.T) {
+	ng := NewNetworkGraph(4)
+	defer ng.Close()
+	for id, provider := range map[int64]string{1: "aws", 2: "aws", 3: "gcp"} {
+		if err := ng.AddNode(&NetworkNode{ID: id, Provider: provider, Region: "eu"}); err != nil {
+			t.Fatalf("AddNode: %v", err)
+		}
+	}
+	for _, edge := range []*NetworkEdge{
+		{From: 1, To: 2, Latency: time.Millisecond, Cost: 50},
+		{From: 2, To: 3, Latency: time.Millisecond, Cost: 50},
+	} {
+		if err := ng.AddEdge(edge); err != nil {
+			t.Fatalf("AddEdge: %v", err)
+		}
+	}
+
+	// Cached before pricing, with the costs the edges were added with
+	if path, err := ng.FindShortestPath(1, 3); err != nil || path.TotalCost != 100 {
+		t.Fatalf("unpriced path: %+v, %v", path, err)
+	}
+
+	ng.SetCostModel(&EgressCostModel{IntraRegion: 0.01, Default: 0.08})
+	if path, err := ng.FindShortestPath(1, 3); err != nil || math.Abs(path.TotalCost-0.09) > 1e-12 {
+		t.Errorf("priced path: %+v, %v", path, err)
+	}
+
+	// Edges added later are priced too
+	if err := ng.AddEdge(&NetworkEdge{From: 3, To: 1, Cost: 50}); err != nil {
+		t.Fatalf("AddEdge: %v", err)
+	}
+	if edge, _ := ng.GetEdge(3, 1); edge.Cost != 0.08 {
+		t.Errorf("added edge cost %g, want 0.08", edge.Cost)
+	}
+}
@@ -0,0 +1,289 @@
+// Package graph implements disjoint path search and path diversity scoring
+package graph
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DisjointMode is what alternative paths between two nodes must not share
+type DisjointMode int
+
+const (
+	// NotDisjoint places no requirement on shared nodes or links
+	NotDisjoint DisjointMode = iota
+
+	// EdgeDisjoint paths share no link, but may cross the same nodes
+	EdgeDisjoint
+
+	// NodeDisjoint paths share no node besides their endpoints, and so no
+	// link either
+	NodeDisjoint
+)
+
+// String returns the name of the mode
+func (m DisjointMode) String() string {
+	switch m {
+	case NotDisjoint:
+		return "none"
+	case EdgeDisjoint:
+		return "edge"
+	case NodeDisjoint:
+		return "node"
+	default:
+		return fmt.Sprintf("disjoint_mode_%d", int(m))
+	}
+}
+
+// flowArc is an arc of the residual graph disjoint paths are found in. Each
+// arc is stored next to its reverse, so arc i's reverse is arc i^1.
+type flowArc struct {
+	to       int32
+	cost     float64
+	capacity int32
+}
+
+// flowGraph is the residual graph of a unit-capacity min-cost flow
+type flowGraph struct {
+	arcs []flowArc
+	out  [][]int32
+}
+
+// addArc adds an arc of capacity one and its empty reverse
+func (g *flowGraph) addArc(from, to int32, cost float64) {
+	g.out[from] = append(g.out[from], int32(len(g.arcs)))
+	g.arcs = append(g.arcs, flowArc{to: to, cost: cost, capacity: 1})
+	g.out[to] = append(g.out[to], int32(len(g.arcs)))
+	g.arcs = append(g.arcs, flowArc{to: from, cost: -cost})
+}
+
+// FindDisjointPaths returns up to count paths from one node to another that
+// share no link, or with NodeDisjoint no intermediate node, with the least
+// total weight of any such set, cheapest first. Paths do not cross the nodes
+// in avoid. Fewer paths are returned when the topology has no more disjoint
+// ones; it is an error when there is none.
+//
+// The search generalizes Suurballe's algorithm: each path is a shortest
+// augmenting path in the residual graph of those found so far, which may
+// reroute earlier paths where that lowers the total, so a greedy first path
+// never blocks a second.
+func (ng *NetworkGraph) FindDisjointPaths(from, to int64, count int, mode DisjointMode, avoid []int64) ([]*OptimalPath, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("disjoint path count must be positive, got %d", count)
+	}
+	if mode != EdgeDisjoint && mode != NodeDisjoint {
+		return nil, fmt.Errorf("unsupported disjoint mode %s", mode)
+	}
+
+	ng.mutex.RLock()
+	defer ng.mutex.RUnlock()
+
+	paths := ng.topology.disjointPaths(from, to, count, mode == NodeDisjoint, avoid)
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no path found from %d to %d", from, to)
+	}
+
+	preferences := PathPreferences{LatencyWeight: 1.0}
+	optimal := make([]*OptimalPath, len(paths))
+	for i, nodeIDs := range paths {
+		optimal[i] = ng.calculatePathMetrics(nodeIDs, preferences)
+	}
+	return optimal, nil
+}
+
+// disjointPaths finds up to count disjoint paths by successive shortest
+// paths with Johnson potentials, so every search runs Dijkstra on
+// non-negative reduced costs. For node-disjoint paths each intermediate node
+// is split into an entry and an exit vertex joined by an arc of capacity
+// one.
+func (t *topology) disjointPaths(from, to int64, count int, nodeDisjoint bool, avoid []int64) [][]int64 {
+	source, exists := t.index[from]
+	if !exists {
+		return nil
+	}
+	target, exists := t.index[to]
+	if !exists || source == target {
+		return nil
+	}
+	avoided := make(map[int32]bool, len(avoid))
+	for _, id := range avoid {
+		if slot, exists := t.index[id]; exists && slot != source && slot != target {
+			avoided[slot] = true
+		}
+	}
+
+	// Vertices of slot s: entry and exit are the same vertex unless nodes
+	// are split
+	vertices := int32(len(t.slots))
+	entry := func(s int32) int32 { return s }
+	exit := entry
+	if nodeDisjoint {
+		vertices *= 2
+		entry = func(s int32) int32 { return 2 * s }
+		exit = func(s int32) int32 { return 2*s + 1 }
+	}
+
+	g := &flowGraph{out: make([][]int32, vertices)}
+	for s := range t.slots {
+		slot := int32(s)
+		if t.slots[slot].node == nil || avoided[slot] || slot == target {
+			continue
+		}
+		if nodeDisjoint && slot != source {
+			g.addArc(entry(slot), exit(slot), 0)
+		}
+		for _, edge := range t.slots[slot].out {
+			if edge.to == source || avoided[edge.to] {
+				continue
+			}
+			g.addArc(exit(slot), entry(edge.to), max(edge.weight, 0))
+		}
+	}
+
+	start, sink := exit(source), entry(target)
+	potential := make([]float64, vertices)
+	dist := make([]float64, vertices)
+	prevArc := make([]int32, vertices)
+	done := make([]bool, vertices)
+	found := 0
+	for found < count {
+		for v := range dist {
+			dist[v], prevArc[v], done[v] = math.Inf(1), -1, false
+		}
+		dist[start] = 0
+		queue := distanceQueue{{id: int64(start)}}
+		for queue.Len() > 0 {
+			item := heap.Pop(&queue).(distanceItem)
+			v := int32(item.id)
+			if done[v] {
+				continue
+			}
+			done[v] = true
+			if v == sink {
+				break
+			}
+			for _, a := range g.out[v] {
+				arc := g.arcs[a]
+				if arc.capacity == 0 || done[arc.to] {
+					continue
+				}
+				reduced := max(arc.cost+potential[v]-potential[arc.to], 0)
+				if d := item.dist + reduced; d < dist[arc.to] {
+					dist[arc.to], prevArc[arc.to] = d, a
+					heap.Push(&queue, distanceItem{id: int64(arc.to), dist: d})
+				}
+			}
+		}
+		if !done[sink] {
+			break
+		}
+
+		// Settled vertices move their potential by their distance past the
+		// sink's, which keeps every residual reduced cost non-negative
+		for v := range potential {
+			if done[v] {
+				potential[v] += dist[v] - dist[sink]
+			}
+		}
+		for v := sink; v != start; v = g.arcs[prevArc[v]^1].to {
+			g.arcs[prevArc[v]].capacity--
+			g.arcs[prevArc[v]^1].capacity++
+		}
+		found++
+	}
+
+	// Forward arcs left without capacity carry a path; walk them from the
+	// source, cutting out any zero-weight cycle a walk closes
+	paths := make([][]int64, 0, found)
+	costs := make([]float64, 0, found)
+	for i := 0; i < found; i++ {
+		var walk []int32
+		var cost float64
+		for v := start; v != sink; {
+			next := int32(-1)
+			for _, a := range g.out[v] {
+				if a%2 == 0 && g.arcs[a].capacity == 0 {
+					next = a
+					break
+				}
+			}
+			if next < 0 {
+				break
+			}
+			g.arcs[next].capacity = 1
+			cost += g.arcs[next].cost
+			walk = append(walk, v)
+			v = g.arcs[next].to
+		}
+		walk = append(walk, sink)
+
+		nodeIDs := make([]int64, 0, len(walk))
+		position := make(map[int64]int, len(walk))
+		for _, v := range walk {
+			slot := v
+			if nodeDisjoint {
+				slot = v / 2
+			}
+			id := t.slots[slot].id
+			if at, seen := position[id]; seen {
+				if at == len(nodeIDs)-1 {
+					continue
+				}
+				for _, cut := range nodeIDs[at+1:] {
+					delete(position, cut)
+				}
+				nodeIDs = nodeIDs[:at+1]
+				continue
+			}
+			position[id] = len(nodeIDs)
+			nodeIDs = append(nodeIDs, id)
+		}
+		paths = append(paths, nodeIDs)
+		costs = append(costs, cost)
+	}
+
+	order := make([]int, len(paths))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return costs[order[i]] < costs[order[j]] })
+	sorted := make([][]int64, len(paths))
+	for i, at := range order {
+		sorted[i] = paths[at]
+	}
+	return sorted
+}
+
+// PathDiversity scores how independently two paths fail, from 0 when one
+// path's intermediate nodes and links all lie on the other to 1 when they
+// share none. Links count in either direction, and the score is the share
+// of the shorter path's nodes and links the other does not cross.
+func PathDiversity(a, b []int64) float64 {
+	if len(a) < 2 || len(b) < 2 {
+		return 0
+	}
+	elements := func(path []int64) map[[2]int64]bool {
+		set := make(map[[2]int64]bool, 2*len(path))
+		for i := 1; i < len(path)-1; i++ {
+			set[[2]int64{path[i], path[i]}] = true
+		}
+		for i := 0; i+1 < len(path); i++ {
+			set[[2]int64{min(path[i], path[i+1]), max(path[i], path[i+1])}] = true
+		}
+		return set
+	}
+	setA, setB := elements(a), elements(b)
+	if len(setA) > len(setB) {
+		setA, setB = setB, setA
+	}
+
+	shared := 0
+	for element := range setA {
+		if setB[element] {
+			shared++
+		}
+	}
+	return 1 - float64(shared)/float64(len(setA))
+}
@@ -0,0 +1,108 @@
+package graph
+
+import (
+	"slices"
+	"testing"
+)
+
+// newDisjointTestGraph returns a graph holding nodes 1 to n and edges
+func newDisjointTestGraph(t *testing.T, n int64, edges []*NetworkEdge) *NetworkGraph {
+	t.Helper()
+
+	ng := NewNetworkGraph(int(n))
+	t.Cleanup(ng.Close)
+	for id := int64(1); id <= n; id++ {
+		if err := ng.AddNode(&NetworkNode{ID: id}); err != nil {
+			t.Fatalf("AddNode: %v", err)
+		}
+	}
+	for _, edge := range edges {
+		if err := ng.AddEdge(edge); err != nil {
+			t.Fatalf("AddEdge: %v", err)
+		}
+	}
+	return ng
+}
+
+func TestFindDisjointPathsAvoidsGreedyTrap(t *testing.T) {
+	// The shortest path 1-2-3-4 takes both middle nodes, leaving no second
+	// path; the optimal pair reroutes around it
+	ng := newDisjointTestGraph(t, 6, []*NetworkEdge{
+		{From: 1, To: 2, Weight: 1},
+		{From: 2, To: 3, Weight: 1},
+		{From: 3, To: 4, Weight: 1},
+		{From: 2, To: 6, Weight: 2},
+		{From: 6, To: 4, Weight: 2},
+		{From: 1, To: 5, Weight: 2},
+		{From: 5, To: 3, Weight: 2},
+	})
+
+	for _, mode := range []DisjointMode{EdgeDisjoint, NodeDisjoint} {
+		paths, err := ng.FindDisjointPaths(1, 4, 2, mode, nil)
+		if err != nil {
+			t.Fatalf("%s: FindDisjointPaths: %v", mode, err)
+		}
+		var got [][]int64
+		for _, path := range paths {
+			got = append(got, path.NodeIDs)
+		}
+		if len(got) != 2 || !slices.Equal(got[0], []int64{1, 2, 6, 4}) || !slices.Equal(got[1], []int64{1, 5, 3, 4}) {
+			t.Errorf("%s: paths %v, want [1 2 6 4] and [1 5 3 4]", mode, got)
+		}
+	}
+
+	paths, err := ng.FindDisjointPaths(1, 4, 2, NodeDisjoint, []int64{6})
+	if err != nil || len(paths) != 1 || !slices.Equal(paths[0].NodeIDs, []int64{1, 2, 3, 4}) {
+		t.Errorf("avoiding node 6: %+v, %v; want only [1 2 3 4]", paths, err)
+	}
+	if _, err := ng.FindDisjointPaths(1, 4, 2, NotDisjoint, nil); err == nil {
+		t.Error("accepted a search without a disjoint mode")
+	}
+}
+
+func TestFindDisjointPathsNodeModeRespectsCutNodes(t *testing.T) {
+	// Every path from 1 to 7 crosses node 4, over distinct links
+	ng := newDisjointTestGraph(t, 7, []*NetworkEdge{
+		{From: 1, To: 2, Weight: 1},
+		{From: 1, To: 3, Weight: 1},
+		{From: 2, To: 4, Weight: 1},
+		{From: 3, To: 4, Weight: 1},
+		{From: 4, To: 5, Weight: 1},
+		{From: 4, To: 6, Weight: 1},
+		{From: 5, To: 7, Weight: 1},
+		{From: 6, To: 7, Weight: 1},
+	})
+
+	edgePaths, err := ng.FindDisjointPaths(1, 7, 3, EdgeDisjoint, nil)
+	if err != nil || len(edgePaths) != 2 {
+		t.Fatalf("edge-disjoint: %d paths, %v; want 2", len(edgePaths), err)
+	}
+	if diversity := PathDiversity(edgePaths[0].NodeIDs, edgePaths[1].NodeIDs); diversity <= 0 || diversity >= 1 {
+		t.Errorf("edge-disjoint paths through a shared node scored diversity %v", diversity)
+	}
+
+	nodePaths, err := ng.FindDisjointPaths(1, 7, 3, NodeDisjoint, nil)
+	if err != nil || len(nodePaths) != 1 {
+		t.Errorf("node-disjoint: %d paths, %v; want 1", len(nodePaths), err)
+	}
+}
+
+func TestPathDiversity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []int64
+		want float64
+	}{
+		{name: "same path", a: []int64{1, 2, 3}, b: []int64{1, 2, 3}, want: 0},
+		{name: "reversed", a: []int64{1, 2, 3}, b: []int64{3, 2, 1}, want: 0},
+		{name: "node disjoint", a: []int64{1, 2, 4}, b: []int64{1, 3, 4}, want: 1},
+		{name: "direct link", a: []int64{1, 4}, b: []int64{1, 2, 4}, want: 1},
+		{name: "shared node", a: []int64{1, 2, 3, 5}, b: []int64{1, 4, 3, 6, 5}, want: 1 - 1.0/5},
+		{name: "no path", a: []int64{1}, b: []int64{1, 2}, want: 0},
+	}
+	for _, tt := range tests {
+		if got := PathDiversity(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: PathDiversity(%v, %v) = %v, want %v", tt.name, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Package graph implements smoothing and outlier rejection of measured edge
+// latency
+package graph
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	// minOutlierSamples is how many samples an edge needs before one can be
+	// judged an outlier against them
+	minOutlierSamples = 4
+
+	// outlierDeviationFloor is the least spread, as a share of the median,
+	// outliers are measured in, so a steady link does not reject every
+	// small change
+	outlierDeviationFloor = 0.05
+
+	// minOutlierDeviation is the least spread outliers are measured in
+	minOutlierDeviation = 50 * time.Microsecond
+)
+
+// SmoothingMethod is how measured edge latency and jitter are smoothed
+type SmoothingMethod int
+
+const (
+	// NoSmoothing applies each measurement as is
+	NoSmoothing SmoothingMethod = iota
+
+	// EWMASmoothing takes an exponentially weighted moving average of
+	// accepted measurements
+	EWMASmoothing
+
+	// MedianSmoothing takes the median of the window
+	MedianSmoothing
+)
+
+// String returns the name of the method
+func (m SmoothingMethod) String() string {
+	switch m {
+	case NoSmoothing:
+		return "none"
+	case EWMASmoothing:
+		return "ewma"
+	case MedianSmoothing:
+		return "median"
+	default:
+		return fmt.Sprintf("smoothing_method_%d", int(m))
+	}
+}
+
+// EdgeSmoothing configures how UpdateEdgeMetrics smooths measured edge
+// latency and jitter, so probe noise does not move edge weights and drop
+// cached paths on every update. UpdateNodeMetrics smooths node latency the
+// same way.
+type EdgeSmoothing struct {
+	Method SmoothingMethod
+
+	// Recent raw samples kept per edge, for the median and to judge
+	// outliers against
+	Window int
+
+	// Weight of an accepted sample in the EWMA, in (0, 1]
+	Alpha float64
+
+	// Samples whose latency is further than this many median absolute
+	// deviations from the window's median are outliers and leave the
+	// smoothed metrics unchanged. They still join the window, so a lasting
+	// shift is accepted once it makes up half of it. Zero accepts every
+	// sample.
+	OutlierThreshold float64
+}
+
+// DefaultEdgeSmoothing returns an EWMA over accepted samples that rejects
+// those four deviations out of the last eight
+func DefaultEdgeSmoothing() EdgeSmoothing {
+	return EdgeSmoothing{
+		Method:           EWMASmoothing,
+		Window:           8,
+		Alpha:            0.3,
+		OutlierThreshold: 4,
+	}
+}
+
+// Validate checks the method is known and its parameters in range
+func (s EdgeSmoothing) Validate() error {
+	switch {
+	case s.Method < NoSmoothing || s.Method > MedianSmoothing:
+		return fmt.Errorf("unknown edge smoothing method %d", s.Method)
+	case s.Method == NoSmoothing:
+		return nil
+	case s.Window < 1:
+		return fmt.Errorf("edge smoothing window must be positive, got %d", s.Window)
+	case s.Method == EWMASmoothing && !(s.Alpha > 0 && s.Alpha <= 1):
+		return fmt.Errorf("edge smoothing alpha must be in (0, 1], got %v", s.Alpha)
+	case s.OutlierThreshold < 0 || math.IsNaN(s.OutlierThreshold):
+		return fmt.Errorf("edge outlier threshold must not be negative, got %v", s.OutlierThreshold)
+	}
+	return nil
+}
+
+// latencySmoother is the smoothing state of one edge or node: rings of its
+// latest raw latency and jitter samples and their smoothed values
+type latencySmoother struct {
+	latencies []float64
+	jitters   []float64
+	next      int
+	count     int
+
+	latency float64
+	jitter  float64
+}
+
+// SetEdgeSmoothing changes how edge and node measurements are smoothed.
+// They keep their smoothed metrics; a changed window starts each afresh.
+func (ng *NetworkGraph) SetEdgeSmoothing(smoothing EdgeSmoothing) error {
+	if err := smoothing.Validate(); err != nil {
+		return err
+	}
+
+	ng.mutex.Lock()
+	defer ng.mutex.Unlock()
+
+	ng.smoothing = smoothing
+	return nil
+}
+
+// smooth folds a measured latency and jitter into the smoothing state at
+// smoother and returns the latency and jitter to apply, reporting whether
+// the sample was rejected as an outlier
+func (s EdgeSmoothing) smooth(smoother **latencySmoother, measuredLatency, measuredJitter time.Duration) (time.Duration, time.Duration, bool) {
+	if s.Method == NoSmoothing {
+		*smoother = nil
+		return measuredLatency, measuredJitter, false
+	}
+
+	state := *smoother
+	if state == nil || len(state.latencies) != s.Window {
+		state = &latencySmoother{latencies: make([]float64, s.Window), jitters: make([]float64, s.Window)}
+		*smoother = state
+	}
+	latency, jitter := float64(measuredLatency), float64(measuredJitter)
+	outlier := s.outlier(state.window(state.latencies), latency)
+
+	state.latencies[state.next], state.jitters[state.next] = latency, jitter
+	state.next = (state.next + 1) % s.Window
+	state.count = min(state.count+1, s.Window)
+
+	switch {
+	case state.count == 1:
+		state.latency, state.jitter = latency, jitter
+	case outlier:
+	case s.Method == EWMASmoothing:
+		state.latency = s.Alpha*latency + (1-s.Alpha)*state.latency
+		state.jitter = s.Alpha*jitter + (1-s.Alpha)*state.jitter
+	default:
+		state.latency = median(state.window(state.latencies))
+		state.jitter = median(state.window(state.jitters))
+	}
+	return time.Duration(state.latency), time.Duration(state.jitter), outlier
+}
+
+// outlier reports whether a sample lies further from the median of window
+// than the threshold allows
+func (s EdgeSmoothing) outlier(window []float64, sample float64) bool {
+	if s.OutlierThreshold == 0 || len(window) < minOutlierSamples {
+		return false
+	}
+
+	center := median(window)
+	deviations := make([]float64, len(window))
+	for i, value := range window {
+		deviations[i] = math.Abs(value - center)
+	}
+	spread := max(median(deviations), outlierDeviationFloor*center, float64(minOutlierDeviation))
+	return math.Abs(sample-center) > s.OutlierThreshold*spread
+}
+
+// window returns the filled part of a ring of samples
+func (state *latencySmoother) window(ring []float64) []float64 {
+	return ring[:state.count]
+}
+
+// median returns the median of values without reordering them
+func median(values []float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,125 @@
+package graph
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEdgeSmoothingRejectsOutliers(t *testing.T) {
+	ng := newDisjointTestGraph(t, 2, []*NetworkEdge{{From: 1, To: 2, Weight: 1000, Latency: time.Millisecond}})
+	update := func(latency time.Duration) *NetworkEdge {
+		t.Helper()
+		if err := ng.UpdateEdgeMetrics(1, 2, EdgeMetrics{Latency: latency, Jitter: latency / 10}); err != nil {
+			t.Fatalf("UpdateEdgeMetrics: %v", err)
+		}
+		edge, _ := ng.GetEdge(1, 2)
+		return edge
+	}
+
+	// Without smoothing every sample applies as is
+	if edge := update(20 * time.Millisecond); edge.Latency != 20*time.Millisecond || edge.Weight != 20000 {
+		t.Fatalf("unsmoothed edge latency %v weight %v, want the raw 20ms", edge.Latency, edge.Weight)
+	}
+
+	if err := ng.SetEdgeSmoothing(DefaultEdgeSmoothing()); err != nil {
+		t.Fatalf("SetEdgeSmoothing: %v", err)
+	}
+	for _, latency := range []time.Duration{1000, 1010, 990, 1000, 1005} {
+		update(latency * time.Microsecond)
+	}
+	steady, _ := ng.GetEdge(1, 2)
+	if steady.Latency < 990*time.Microsecond || steady.Latency > 1010*time.Microsecond {
+		t.Fatalf("steady samples smoothed to %v", steady.Latency)
+	}
+
+	// A spike is kept raw but leaves the smoothed latency and weight alone
+	before := steady.Latency
+	edge := update(20 * time.Millisecond)
+	if edge.Latency != before || edge.Raw.Latency != 20*time.Millisecond || edge.Raw.Jitter != 2*time.Millisecond {
+		t.Errorf("spike: latency %v raw %v, want %v raw 20ms", edge.Latency, edge.Raw.Latency, before)
+	}
+	if stats := ng.GetTopologyStats(); stats.RejectedOutliers != 1 {
+		t.Errorf("rejected %d outliers, want 1", stats.RejectedOutliers)
+	}
+
+	// A lasting shift is accepted once it fills half the window
+	for i := 0; i < 8; i++ {
+		edge = update(3 * time.Millisecond)
+	}
+	if edge.Latency < 2500*time.Microsecond {
+		t.Errorf("after a lasting shift to 3ms the edge is at %v", edge.Latency)
+	}
+}
+
+func TestEdgeSmoothingMedian(t *testing.T) {
+	ng := newDisjointTestGraph(t, 2, []*NetworkEdge{{From: 1, To: 2, Weight: 1}})
+	if err := ng.SetEdgeSmoothing(EdgeSmoothing{Method: MedianSmoothing, Window: 3}); err != nil {
+		t.Fatalf("SetEdgeSmoothing: %v", err)
+	}
+	want := []time.Duration{5, 3, 5, 7, 8}
+	for i, latency := range []time.Duration{5, 1, 9, 7, 8} {
+		if err := ng.UpdateEdgeMetrics(1, 2, EdgeMetrics{Latency: latency * time.Millisecond}); err != nil {
+			t.Fatalf("UpdateEdgeMetrics: %v", err)
+		}
+		if edge, _ := ng.GetEdge(1, 2); edge.Latency != want[i]*time.Millisecond {
+			t.Errorf("sample %d: median %v, want %v", i, edge.Latency, want[i]*time.Millisecond)
+		}
+	}
+}
+
+func TestNodeLatencySmoothing(t *testing.T) {
+	ng := newDisjointTestGraph(t, 2, nil)
+	if err := ng.SetEdgeSmoothing(DefaultEdgeSmoothing()); err != nil {
+		t.Fatalf("SetEdgeSmoothing: %v", err)
+	}
+	for _, latency := range []time.Duration{1000, 1010, 990, 1000, 1005} {
+		if err := ng.UpdateNodeMetrics(1, NodeMetrics{Latency: latency * time.Microsecond, LoadFactor: 0.5}); err != nil {
+			t.Fatalf("UpdateNodeMetrics: %v", err)
+		}
+	}
+	node, _ := ng.GetNode(1)
+	steady := node.Latency
+	if steady < 990*time.Microsecond || steady > 1010*time.Microsecond {
+		t.Fatalf("steady samples smoothed to %v", steady)
+	}
+
+	// Batched updates are smoothed too; a spike is kept raw only, while the
+	// other metrics apply as reported
+	err := ng.UpdateNodeMetricsBatch([]NodeMetricsUpdate{{NodeID: 1, Metrics: NodeMetrics{Latency: 20 * time.Millisecond, LoadFactor: 0.9}}})
+	if err != nil {
+		t.Fatalf("UpdateNodeMetricsBatch: %v", err)
+	}
+	if node.Latency != steady || node.RawLatency != 20*time.Millisecond || node.LoadFactor != 0.9 {
+		t.Errorf("spike: latency %v raw %v load %v, want %v raw 20ms load 0.9", node.Latency, node.RawLatency, node.LoadFactor, steady)
+	}
+	if stats := ng.GetTopologyStats(); stats.RejectedNodeOutliers != 1 || stats.RejectedOutliers != 0 {
+		t.Errorf("rejected %d node and %d edge outliers, want 1 and 0", stats.RejectedNodeOutliers, stats.RejectedOutliers)
+	}
+
+	// Without smoothing node latency applies as is
+	ng.SetEdgeSmoothing(EdgeSmoothing{})
+	ng.UpdateNodeMetrics(1, NodeMetrics{Latency: 20 * time.Millisecond})
+	if node.Latency != 20*time.Millisecond {
+		t.Errorf("unsmoothed node latency %v, want the raw 20ms", node.Latency)
+	}
+}
+
+func TestEdgeSmoothingValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		smoothing EdgeSmoothing
+		valid     bool
+	}{
+		{name: "default", smoothing: DefaultEdgeSmoothing(), valid: true},
+		{name: "none", smoothing: EdgeSmoothing{}, valid: true},
+		{name: "unknown method", smoothing: EdgeSmoothing{Method: 3, Window: 4}},
+		{name: "empty window", smoothing: EdgeSmoothing{Method: MedianSmoothing}},
+		{name: "zero alpha", smoothing: EdgeSmoothing{Method: EWMASmoothing, Window: 4}},
+		{name: "negative threshold", smoothing: EdgeSmoothing{Method: MedianSmoothing, Window: 4, OutlierThreshold: -1}},
+	}
+	for _, tt := range tests {
+		if err := tt.smoothing.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Package graph implements geographic bounds that guide shortest path
+// searches towards their target
+package graph
+
+const (
+	// fiberMicrosPerKm is the one-way latency of a kilometre of optical
+	// fibre, where light covers about 200 km per millisecond. No path
+	// between two points is faster than their great-circle distance at this
+	// speed.
+	fiberMicrosPerKm = 5.0
+
+	// geoBoundSlack shrinks bounds slightly, so rounding in the distance
+	// formula never lifts one above the weight it bounds
+	geoBoundSlack = 1 - 1e-9
+)
+
+// located reports whether a node has coordinates; a node at exactly 0,0 is
+// taken to have none
+func located(node *NetworkNode) bool {
+	return node.Latitude != 0 || node.Longitude != 0
+}
+
+// geoGuide bounds the weight left from any node to a search's target by
+// their great-circle distance, making the search A*. The bound is
+// consistent: along any edge it drops by no more than the edge weighs, so
+// a node is settled at its shortest distance, as in Dijkstra, but fewer
+// nodes away from the target are expanded.
+type geoGuide struct {
+	slots    []nodeSlot
+	lat, lng float64
+
+	// Weight per kilometre of the bound; zero guides nothing
+	perKm float64
+}
+
+// guide returns the bound for a search to target, which guides nothing
+// while any node lacks coordinates or an edge weighs nothing over a
+// distance
+func (t *topology) guide(target int32) geoGuide {
+	node := t.slots[target].node
+	if t.unlocated > 0 || t.weightPerKm <= 0 {
+		return geoGuide{}
+	}
+	return geoGuide{
+		slots: t.slots,
+		lat:   node.Latitude,
+		lng:   node.Longitude,
+		perKm: t.weightPerKm * geoBoundSlack,
+	}
+}
+
+// bound returns the least weight of any path from a slot to the target
+func (g geoGuide) bound(slot int32) float64 {
+	if g.perKm == 0 {
+		return 0
+	}
+	node := g.slots[slot].node
+	return g.perKm * HaversineDistance(node.Latitude, node.Longitude, g.lat, g.lng)
+}
+
+// fitGuide lowers the weight per kilometre of bounds to at most that of an
+// edge between two slots, keeping the bound consistent. It is only ever
+// lowered, so it stays a bound as edges are removed or grow costlier.
+func (t *topology) fitGuide(from, to int32, weight float64) {
+	a, b := t.slots[from].node, t.slots[to].node
+	if !located(a) || !located(b) {
+		return
+	}
+	if km := HaversineDistance(a.Latitude, a.Longitude, b.Latitude, b.Longitude); km > 0 {
+		t.weightPerKm = min(t.weightPerKm, weight/km)
+	}
+}
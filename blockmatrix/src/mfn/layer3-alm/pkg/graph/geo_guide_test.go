@@ -0,0 +1,130 @@
+package graph
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/graph/path"
+	"gonum.org/v1/gonum/graph/simple"
+)
+
+// newContinentGraph returns a grid of located nodes spanning a continent,
+// linked both ways to their neighbours by edges weighing their fibre
+// latency and up to a fifth more
+func newContinentGraph(t *testing.T, side int64) *NetworkGraph {
+	t.Helper()
+
+	rng := rand.New(rand.NewSource(1))
+	ng := NewNetworkGraph(int(side * side))
+	t.Cleanup(ng.Close)
+	id := func(row, col int64) int64 { return row*side + col + 1 }
+	for row := int64(0); row < side; row++ {
+		for col := int64(0); col < side; col++ {
+			node := &NetworkNode{
+				ID:        id(row, col),
+				Latitude:  30 + 20*float64(row)/float64(side),
+				Longitude: -120 + 50*float64(col)/float64(side),
+			}
+			if err := ng.AddNode(node); err != nil {
+				t.Fatalf("AddNode: %v", err)
+			}
+		}
+	}
+	link := func(a, b int64) {
+		from, _ := ng.GetNode(a)
+		to, _ := ng.GetNode(b)
+		km := HaversineDistance(from.Latitude, from.Longitude, to.Latitude, to.Longitude)
+		weight := math.Ceil(km * fiberMicrosPerKm * (1 + 0.2*rng.Float64()))
+		for _, edge := range []*NetworkEdge{{From: a, To: b, Weight: weight}, {From: b, To: a, Weight: weight}} {
+			if err := ng.AddEdge(edge); err != nil {
+				t.Fatalf("AddEdge: %v", err)
+			}
+		}
+	}
+	for row := int64(0); row < side; row++ {
+		for col := int64(0); col < side; col++ {
+			if col+1 < side {
+				link(id(row, col), id(row, col+1))
+			}
+			if row+1 < side {
+				link(id(row, col), id(row+1, col))
+			}
+		}
+	}
+	return ng
+}
+
+func TestGeoGuidedSearchMatchesDijkstra(t *testing.T) {
+	const side = 20
+	ng := newContinentGraph(t, side)
+
+	// searchWeight runs a search and returns the weight of its path and the
+	// nodes it expanded
+	searchWeight := func(from, to int64) (float64, int64) {
+		t.Helper()
+		before := ng.topology.expanded.Load()
+		nodeIDs := ng.topology.shortestPath(from, to)
+		weight := 0.0
+		for i := 1; i < len(nodeIDs); i++ {
+			edge, _ := ng.topology.edge(nodeIDs[i-1], nodeIDs[i])
+			weight += edge.Weight
+		}
+		return weight, ng.topology.expanded.Load() - before
+	}
+
+	reference := referenceGraph(ng)
+	rng := rand.New(rand.NewSource(2))
+	guidedExpanded := int64(0)
+	for i := 0; i < 50; i++ {
+		from, to := 1+rng.Int63n(side*side), 1+rng.Int63n(side*side)
+		if from == to {
+			continue
+		}
+		weight, expanded := searchWeight(from, to)
+		if want := path.DijkstraFrom(simple.Node(from), reference).WeightTo(to); weight != want {
+			t.Fatalf("guided path %d->%d weighs %v, want %v", from, to, weight, want)
+		}
+		guidedExpanded += expanded
+	}
+	if stats := ng.GetTopologyStats(); stats.GuidedSearches != stats.PathSearches {
+		t.Errorf("%d of %d searches guided, want all", stats.GuidedSearches, stats.PathSearches)
+	}
+
+	// Across the continent, the guide expands a fraction of the nodes
+	// Dijkstra does; a node without coordinates turns it off
+	west, east := int64(side*side/2+1), int64(side*side/2+side)
+	_, guided := searchWeight(west, east)
+	if err := ng.AddNode(&NetworkNode{ID: side*side + 1}); err != nil {
+		t.Fatalf("AddNode: %v", err)
+	}
+	_, unguided := searchWeight(west, east)
+	if guided*2 > unguided {
+		t.Errorf("guided search expanded %d nodes, unguided %d", guided, unguided)
+	}
+	if stats := ng.GetTopologyStats(); stats.GuidedSearches != stats.PathSearches-1 {
+		t.Errorf("search with an unlocated node was guided")
+	}
+
+	// An edge faster than light in fibre lowers the bound rather than
+	// breaking it
+	if err := ng.RemoveNode(side*side + 1); err != nil {
+		t.Fatalf("RemoveNode: %v", err)
+	}
+	if err := ng.AddEdge(&NetworkEdge{From: west, To: east, Weight: 1}); err != nil {
+		t.Fatalf("AddEdge: %v", err)
+	}
+	if weight, _ := searchWeight(west, east); weight != 1 {
+		t.Errorf("path over the fast edge weighs %v, want 1", weight)
+	}
+	reference = referenceGraph(ng)
+	for i := 0; i < 20; i++ {
+		from, to := 1+rng.Int63n(side*side), 1+rng.Int63n(side*side)
+		if from == to {
+			continue
+		}
+		if weight, _ := searchWeight(from, to); weight != path.DijkstraFrom(simple.Node(from), reference).WeightTo(to) {
+			t.Fatalf("path %d->%d weighs %v after lowering the bound", from, to, weight)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+// Package graph implements landmark distance oracles bounding the latency
+// between nodes without a search
+package graph
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrNoPathWithin is returned when landmarks prove no path meets a latency
+// limit
+var ErrNoPathWithin = errors.New("no path within latency limit")
+
+// Unbounded is the upper latency bound of a pair no landmark path is known
+// between
+const Unbounded = time.Duration(math.MaxInt64)
+
+// LatencyBounds brackets the latency of the fastest path from one node to
+// another
+type LatencyBounds struct {
+	// No path is faster than Lower; Unreachable is set when there is no
+	// path at all
+	Lower       time.Duration
+	Unreachable bool
+
+	// Some path through a landmark takes Upper, or Unbounded when none is
+	// known
+	Upper time.Duration
+}
+
+// landmarks holds, for a few landmark nodes spread across the topology,
+// the least latency from each landmark to every node and back (ALT). By the
+// triangle inequality these bound the latency between any two nodes from
+// below, and a path through a landmark bounds it from above.
+//
+// Distances are those of the last build. Lower bounds stay valid while
+// links only slow down or disappear, and upper bounds while links only
+// speed up or appear; each kind of change is recorded so bounds it may
+// have broken are withheld until the next build.
+type landmarks struct {
+	mutex sync.RWMutex
+	count int
+	ids   []int64
+
+	// Per node, the latency from landmark i at 2i and to it at 2i+1, in
+	// nanoseconds; +Inf where there is no path
+	vectors map[int64][]float64
+
+	// Changes since the build: links that got faster or appeared, links
+	// that got slower or disappeared, and nodes added
+	shortened  bool
+	lengthened bool
+	added      bool
+}
+
+// SetLandmarks selects count landmarks and precomputes their latency to and
+// from every node, giving LatencyBounds between any two nodes. Zero
+// disables them.
+func (ng *NetworkGraph) SetLandmarks(count int) {
+	ng.landmarks.mutex.Lock()
+	ng.landmarks.count = max(count, 0)
+	ng.landmarks.mutex.Unlock()
+
+	ng.RebuildLandmarks()
+}
+
+// RebuildLandmarks reselects the landmarks and recomputes their distances
+// over the current topology
+func (ng *NetworkGraph) RebuildLandmarks() {
+	ng.mutex.RLock()
+	defer ng.mutex.RUnlock()
+
+	l := ng.landmarks
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.ids, l.vectors = ng.topology.landmarkVectors(l.count)
+	l.shortened, l.lengthened, l.added = false, false, false
+
+	logger.L().Debug("Landmarks rebuilt", zap.Int("landmarks", len(l.ids)), zap.Int("nodes", len(l.vectors)))
+}
+
+// LandmarksStale reports whether the topology changed since the landmarks
+// were built, so some bounds are withheld or some nodes uncovered
+func (ng *NetworkGraph) LandmarksStale() bool {
+	ng.landmarks.mutex.RLock()
+	defer ng.landmarks.mutex.RUnlock()
+
+	l := ng.landmarks
+	return l.count > 0 && (l.shortened || l.lengthened || l.added)
+}
+
+// LatencyBounds bounds the latency of the fastest path from one node to
+// another from the landmark distances alone. It returns false when
+// landmarks are disabled or either node was added since they were built.
+func (ng *NetworkGraph) LatencyBounds(from, to int64) (LatencyBounds, bool) {
+	ng.landmarks.mutex.RLock()
+	defer ng.landmarks.mutex.RUnlock()
+
+	return ng.landmarks.bounds(from, to)
+}
+
+// PathPossibleWithin reports whether a path from one node to another might
+// take no longer than latency. It is false only when the landmarks prove
+// none can, so a search for one may be skipped.
+func (ng *NetworkGraph) PathPossibleWithin(from, to int64, latency time.Duration) bool {
+	bounds, ok := ng.LatencyBounds(from, to)
+	return !ok || !bounds.Unreachable && bounds.Lower <= latency
+}
+
+// bounds computes the bounds between two nodes, withholding those changes
+// since the build may have broken
+func (l *landmarks) bounds(from, to int64) (LatencyBounds, bool) {
+	a, found := l.vectors[from]
+	if !found {
+		return LatencyBounds{}, false
+	}
+	b, found := l.vectors[to]
+	if !found {
+		return LatencyBounds{}, false
+	}
+	if from == to {
+		return LatencyBounds{}, true
+	}
+
+	// From landmark L: d(L,to) <= d(L,from) + d(from,to). To it:
+	// d(from,L) <= d(from,to) + d(to,L). An infinite side of either, with
+	// the other finite, proves there is no path.
+	lower, upper := 0.0, math.Inf(1)
+	for i := 0; i < len(a); i += 2 {
+		lower = max(lower, gap(b[i], a[i]), gap(a[i+1], b[i+1]))
+		upper = min(upper, a[i+1]+b[i])
+	}
+
+	bounds := LatencyBounds{Upper: Unbounded}
+	if !l.shortened {
+		if math.IsInf(lower, 1) {
+			bounds.Unreachable = true
+		} else {
+			bounds.Lower = time.Duration(lower)
+		}
+	}
+	if !l.lengthened && !math.IsInf(upper, 1) {
+		bounds.Upper = time.Duration(upper)
+	}
+	return bounds, true
+}
+
+// gap returns how far far exceeds near, infinite when only far is, and zero
+// when neither is known
+func gap(far, near float64) float64 {
+	if math.IsInf(near, 1) {
+		return 0
+	}
+	return max(far-near, 0)
+}
+
+// edgeSet records an edge added or replacing previous, which is nil for a
+// new link. An edge replaced by itself may have changed either way.
+func (l *landmarks) edgeSet(previous, edge *NetworkEdge) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	switch {
+	case previous == nil || edge.Latency < previous.Latency:
+		l.shortened = true
+	case previous == edge:
+		l.shortened, l.lengthened = true, true
+	case edge.Latency > previous.Latency:
+		l.lengthened = true
+	}
+}
+
+// latencyChanged records an edge's latency changing from previous
+func (l *landmarks) latencyChanged(previous, latency time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if latency < previous {
+		l.shortened = true
+	} else if latency > previous {
+		l.lengthened = true
+	}
+}
+
+// edgesRemoved records links disappearing
+func (l *landmarks) edgesRemoved() {
+	l.mutex.Lock()
+	l.lengthened = true
+	l.mutex.Unlock()
+}
+
+// size returns the number of landmarks built
+func (l *landmarks) size() int {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return len(l.ids)
+}
+
+// nodeAdded records a node the landmarks do not cover yet
+func (l *landmarks) nodeAdded() {
+	l.mutex.Lock()
+	l.added = true
+	l.mutex.Unlock()
+}
+
+// landmarkVectors selects up to count landmarks by farthest-point
+// sampling, each the node with the greatest round-trip latency to those
+// already chosen, and returns them with every node's distance vector.
+// Sampling starts from the node farthest from the first in the topology, and
+// nodes no landmark reaches are picked first.
+func (t *topology) landmarkVectors(count int) ([]int64, map[int64][]float64) {
+	live := len(t.index)
+	if count <= 0 || live == 0 {
+		return nil, nil
+	}
+	count = min(count, live)
+
+	// nearest is each slot's round trip to the closest landmark so far,
+	// seeded with that to the first occupied slot
+	nearest := make([]float64, len(t.slots))
+	for s := range t.slots {
+		if t.slots[s].node != nil {
+			forward, backward := t.latencies(int32(s), false), t.latencies(int32(s), true)
+			for v := range nearest {
+				nearest[v] = forward[v] + backward[v]
+			}
+			break
+		}
+	}
+
+	ids := make([]int64, 0, count)
+	vectors := make(map[int64][]float64, live)
+	for s := range t.slots {
+		if t.slots[s].node != nil {
+			vectors[t.slots[s].id] = make([]float64, 0, 2*count)
+		}
+	}
+	chosen := make([]bool, len(t.slots))
+	for len(ids) < count {
+		landmark := int32(-1)
+		for s := range t.slots {
+			if t.slots[s].node == nil || chosen[s] {
+				continue
+			}
+			if landmark < 0 || nearest[s] > nearest[landmark] {
+				landmark = int32(s)
+			}
+		}
+		chosen[landmark] = true
+		ids = append(ids, t.slots[landmark].id)
+
+		forward, backward := t.latencies(landmark, false), t.latencies(landmark, true)
+		for s := range t.slots {
+			if t.slots[s].node == nil {
+				continue
+			}
+			vectors[t.slots[s].id] = append(vectors[t.slots[s].id], forward[s], backward[s])
+			nearest[s] = min(nearest[s], forward[s]+backward[s])
+		}
+	}
+	return ids, vectors
+}
+
+// latencies returns the least latency from a slot to every slot, or with
+// reverse from every slot to it, in nanoseconds; +Inf where there is no
+// path
+func (t *topology) latencies(source int32, reverse bool) []float64 {
+	dist := make([]float64, len(t.slots))
+	for s := range dist {
+		dist[s] = math.Inf(1)
+	}
+	dist[source] = 0
+
+	done := make([]bool, len(t.slots))
+	queue := distanceQueue{{id: int64(source)}}
+	relax := func(slot int32, d float64) {
+		if d < dist[slot] {
+			dist[slot] = d
+			heap.Push(&queue, distanceItem{id: int64(slot), dist: d})
+		}
+	}
+	for queue.Len() > 0 {
+		item := heap.Pop(&queue).(distanceItem)
+		slot := int32(item.id)
+		if done[slot] {
+			continue
+		}
+		done[slot] = true
+
+		if !reverse {
+			for _, edge := range t.slots[slot].out {
+				relax(edge.to, item.dist+float64(max(edge.edge.Latency, 0)))
+			}
+			continue
+		}
+		for _, from := range t.slots[slot].in {
+			out := t.slots[from].out
+			if i := findEdgeSlot(out, slot); i >= 0 {
+				relax(from, item.dist+float64(max(out[i].edge.Latency, 0)))
+			}
+		}
+	}
+	return dist
+}
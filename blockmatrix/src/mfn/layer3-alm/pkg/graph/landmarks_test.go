@@ -0,0 +1,114 @@
+package graph
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// addLatencyEdge adds an edge whose weight is its latency in microseconds
+func addLatencyEdge(t *testing.T, ng *NetworkGraph, from, to int64, latency time.Duration) {
+	t.Helper()
+
+	edge := &NetworkEdge{From: from, To: to, Latency: latency, Weight: float64(latency.Microseconds())}
+	if err := ng.AddEdge(edge); err != nil {
+		t.Fatalf("AddEdge: %v", err)
+	}
+}
+
+func TestLandmarkBoundsBracketShortestPaths(t *testing.T) {
+	const nodes = 60
+	rng := rand.New(rand.NewSource(1))
+	ng := newDisjointTestGraph(t, nodes, nil)
+	for i := 0; i < 4*nodes; i++ {
+		from, to := rng.Int63n(nodes)+1, rng.Int63n(nodes)+1
+		if from != to {
+			addLatencyEdge(t, ng, from, to, time.Duration(1+rng.Intn(5000))*time.Microsecond)
+		}
+	}
+	ng.SetLandmarks(6)
+	if stats := ng.GetTopologyStats(); stats.Landmarks != 6 || ng.LandmarksStale() {
+		t.Fatalf("built %d landmarks, stale %v; want 6 fresh", stats.Landmarks, ng.LandmarksStale())
+	}
+
+	tight := 0
+	for from := int64(1); from <= nodes; from++ {
+		for to := int64(1); to <= nodes; to++ {
+			bounds, ok := ng.LatencyBounds(from, to)
+			if !ok {
+				t.Fatalf("no bounds from %d to %d", from, to)
+			}
+			path, err := ng.FindShortestPath(from, to)
+			if from == to || err != nil {
+				continue
+			}
+			if bounds.Unreachable || bounds.Lower > path.TotalLatency || bounds.Upper < path.TotalLatency {
+				t.Fatalf("%d to %d: bounds %+v exclude shortest path latency %v", from, to, bounds, path.TotalLatency)
+			}
+			if !ng.PathPossibleWithin(from, to, path.TotalLatency) {
+				t.Fatalf("%d to %d: shortest path of %v ruled out", from, to, path.TotalLatency)
+			}
+			if bounds.Lower > 0 {
+				tight++
+			}
+		}
+	}
+	if tight == 0 {
+		t.Error("no pair got a positive lower bound")
+	}
+}
+
+func TestLandmarkBoundsWithheldUntilRebuild(t *testing.T) {
+	// A chain 1-2-3-4 both ways, each link a millisecond; the landmark is
+	// the end farthest from node 1
+	ng := newDisjointTestGraph(t, 4, nil)
+	for from := int64(1); from < 4; from++ {
+		addLatencyEdge(t, ng, from, from+1, time.Millisecond)
+		addLatencyEdge(t, ng, from+1, from, time.Millisecond)
+	}
+	ng.SetLandmarks(1)
+
+	bounds, _ := ng.LatencyBounds(1, 4)
+	if bounds.Lower != 3*time.Millisecond || bounds.Upper != 3*time.Millisecond {
+		t.Fatalf("chain bounds %+v, want exactly 3ms", bounds)
+	}
+	if ng.PathPossibleWithin(1, 4, 2*time.Millisecond) || !ng.PathPossibleWithin(1, 4, 3*time.Millisecond) {
+		t.Error("2ms should be ruled out and 3ms possible")
+	}
+
+	// A shortcut may undercut the lower bound but keeps the upper
+	addLatencyEdge(t, ng, 1, 4, time.Millisecond)
+	if bounds, _ := ng.LatencyBounds(1, 4); bounds.Lower != 0 || bounds.Upper != 3*time.Millisecond || !ng.LandmarksStale() {
+		t.Errorf("after a shortcut: bounds %+v, stale %v; want lower withheld", bounds, ng.LandmarksStale())
+	}
+	ng.RebuildLandmarks()
+	if bounds, _ := ng.LatencyBounds(1, 4); bounds.Lower != time.Millisecond || bounds.Upper == Unbounded {
+		t.Errorf("rebuilt bounds %+v, want a lower bound of 1ms", bounds)
+	}
+
+	// A slower shortcut may exceed the upper bound but keeps the lower
+	if err := ng.UpdateEdgeMetrics(1, 4, EdgeMetrics{Latency: 5 * time.Millisecond}); err != nil {
+		t.Fatalf("UpdateEdgeMetrics: %v", err)
+	}
+	if bounds, _ := ng.LatencyBounds(1, 4); bounds.Lower != time.Millisecond || bounds.Upper != Unbounded {
+		t.Errorf("after slowing the shortcut: bounds %+v, want upper withheld", bounds)
+	}
+
+	// New nodes are uncovered until a rebuild, which proves them
+	// unreachable while isolated
+	if err := ng.AddNode(&NetworkNode{ID: 5}); err != nil {
+		t.Fatalf("AddNode: %v", err)
+	}
+	if _, ok := ng.LatencyBounds(1, 5); ok || !ng.PathPossibleWithin(1, 5, 0) {
+		t.Error("bounded a node added after the build")
+	}
+	ng.RebuildLandmarks()
+	if bounds, ok := ng.LatencyBounds(1, 5); !ok || !bounds.Unreachable || ng.PathPossibleWithin(1, 5, time.Hour) {
+		t.Errorf("isolated node bounds %+v, %v; want unreachable", bounds, ok)
+	}
+
+	ng.SetLandmarks(0)
+	if _, ok := ng.LatencyBounds(1, 4); ok || ng.LandmarksStale() {
+		t.Error("disabled landmarks still bound paths")
+	}
+}
@@ -9,16 +9,19 @@ import (
 	"sync"
 	"time"
 
-	"gonum.org/v1/gonum/graph"
-	"gonum.org/v1/gonum/graph/path"
-	"gonum.org/v1/gonum/graph/simple"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/logging"
+	"go.uber.org/zap"
 )
 
+// logger logs topology changes
+var logger = logging.Named("graph")
+
 // NetworkNode represents a node in the network graph with performance metrics
 type NetworkNode struct {
 	ID         int64
 	Address    string
 	Region     string
+	Provider   string  // Cloud or transit provider, for egress pricing
 	Latitude   float64
 	Longitude  float64
 	
@@ -29,6 +32,10 @@ type NetworkNode struct {
 	LoadFactor    float64  // 0.0-1.0
 	LastSeen      time.Time
 	
+	// Last latency as reported; Latency holds it smoothed
+	RawLatency    time.Duration
+	smoother      *latencySmoother
+	
 	// Service information
 	Services      map[string]ServiceInfo
 	Capabilities  []string
@@ -63,21 +70,42 @@ type NetworkEdge struct {
 	Reliability float64
 	Stability   float64
 	LastUpdate  time.Time
+	
+	// Last measurement as reported; Latency and Jitter hold it smoothed
+	Raw         EdgeMetrics
+	
+	// Recent samples smoothed into the edge
+	smoother    *latencySmoother
 }
 
 // NetworkGraph implements a high-performance graph for network topology
 type NetworkGraph struct {
-	graph       *simple.WeightedDirectedGraph
-	nodes       map[int64]*NetworkNode
-	edges       map[int64]map[int64]*NetworkEdge
+	// Nodes and edges, with index-based adjacency for traversals
+	topology    *topology
 	
 	// Spatial indexing for geographic queries
 	spatialIndex *SpatialIndex
 	
 	// Performance optimization
 	pathCache    *PathCache
+	hubTrees     *hubTrees
+	landmarks    *landmarks
 	updateChan   chan GraphUpdate
 	
+	// Prices edges as they are added; nil keeps the costs they carry
+	costModel    CostModel
+	
+	// Smooths edge and node measurements, counting samples rejected as
+	// outliers
+	smoothing    EdgeSmoothing
+	outliers     int64
+	nodeOutliers int64
+	
+	// Update processor shutdown
+	done         chan struct{}
+	stopped      chan struct{}
+	closeOnce    sync.Once
+	
 	// Thread safety
 	mutex        sync.RWMutex
 	
@@ -111,12 +139,14 @@ const (
 // NewNetworkGraph creates a new high-performance network graph
 func NewNetworkGraph(capacity int) *NetworkGraph {
 	ng := &NetworkGraph{
-		graph:        simple.NewWeightedDirectedGraph(0, math.Inf(1)),
-		nodes:        make(map[int64]*NetworkNode, capacity),
-		edges:        make(map[int64]map[int64]*NetworkEdge),
+		topology:     newTopology(capacity),
 		spatialIndex: NewSpatialIndex(),
 		pathCache:    NewPathCache(1000), // Cache 1000 paths
+		hubTrees:     newHubTrees(0),
+		landmarks:    &landmarks{},
 		updateChan:   make(chan GraphUpdate, 100),
+		done:         make(chan struct{}),
+		stopped:      make(chan struct{}),
 	}
 	
 	// Start update processor
@@ -130,19 +160,14 @@ func (ng *NetworkGraph) AddNode(node *NetworkNode) error {
 	ng.mutex.Lock()
 	defer ng.mutex.Unlock()
 	
-	if _, exists := ng.nodes[node.ID]; exists {
+	// Store node
+	if !ng.topology.addNode(node) {
 		return fmt.Errorf("node %d already exists", node.ID)
 	}
 	
-	// Add to gonum graph
-	ng.graph.AddNode(simple.Node(node.ID))
-	
-	// Store node
-	ng.nodes[node.ID] = node
-	ng.edges[node.ID] = make(map[int64]*NetworkEdge)
-	
 	// Add to spatial index
 	ng.spatialIndex.AddNode(node.ID, node.Latitude, node.Longitude)
+	ng.landmarks.nodeAdded()
 	
 	ng.totalNodes++
 	ng.lastUpdate = time.Now()
@@ -152,6 +177,7 @@ func (ng *NetworkGraph) AddNode(node *NetworkNode) error {
 	case ng.updateChan <- GraphUpdate{Type: NodeAdd, NodeID: node.ID, Node: node}:
 	default:
 		// Channel full, update lost (non-critical)
+		logger.L().Debug("Graph update channel full; dropped node notification", zap.Int64("node_id", node.ID))
 	}
 	
 	return nil
@@ -163,31 +189,96 @@ func (ng *NetworkGraph) AddEdge(edge *NetworkEdge) error {
 	defer ng.mutex.Unlock()
 	
 	// Verify nodes exist
-	if _, exists := ng.nodes[edge.From]; !exists {
+	if _, exists := ng.topology.slot(edge.From); !exists {
 		return fmt.Errorf("source node %d does not exist", edge.From)
 	}
-	if _, exists := ng.nodes[edge.To]; !exists {
+	if _, exists := ng.topology.slot(edge.To); !exists {
 		return fmt.Errorf("destination node %d does not exist", edge.To)
 	}
 	
-	// Add to gonum graph
-	gnEdge := ng.graph.NewWeightedEdge(simple.Node(edge.From), simple.Node(edge.To), edge.Weight)
-	ng.graph.SetWeightedEdge(gnEdge)
-	
-	// Store edge
-	ng.edges[edge.From][edge.To] = edge
-	
-	ng.totalEdges++
+	// Price and store edge, replacing any between the same nodes
+	ng.priceEdge(edge)
+	previous, _ := ng.topology.edge(edge.From, edge.To)
+	if ng.topology.setEdge(edge) {
+		ng.totalEdges++
+	}
 	ng.lastUpdate = time.Now()
 	
-	// Invalidate affected cached paths
+	// Invalidate affected cached paths and repair hub trees
 	ng.pathCache.InvalidateNode(edge.From)
 	ng.pathCache.InvalidateNode(edge.To)
+	ng.hubTrees.edgeChanged(ng, edge.From, edge.To)
+	ng.landmarks.edgeSet(previous, edge)
 	
 	// Send update notification
 	select {
 	case ng.updateChan <- GraphUpdate{Type: EdgeAdd, EdgeFrom: edge.From, EdgeTo: edge.To, Edge: edge}:
 	default:
+		logger.L().Debug("Graph update channel full; dropped edge notification",
+			zap.Int64("from", edge.From),
+			zap.Int64("to", edge.To),
+		)
+	}
+	
+	return nil
+}
+
+// RemoveNode removes a node together with every edge to or from it
+func (ng *NetworkGraph) RemoveNode(id int64) error {
+	ng.mutex.Lock()
+	defer ng.mutex.Unlock()
+	
+	if _, exists := ng.topology.slot(id); !exists {
+		return fmt.Errorf("node %d not found", id)
+	}
+	
+	// Drop the node with its edges; only paths through it lose their
+	// shortest path
+	_, removed := ng.topology.removeNode(id)
+	ng.totalEdges -= int64(removed)
+	ng.spatialIndex.RemoveNode(id)
+	
+	ng.totalNodes--
+	ng.lastUpdate = time.Now()
+	ng.pathCache.InvalidateNode(id)
+	ng.hubTrees.nodeRemoved(ng, id)
+	if removed > 0 {
+		ng.landmarks.edgesRemoved()
+	}
+	
+	select {
+	case ng.updateChan <- GraphUpdate{Type: NodeRemove, NodeID: id}:
+	default:
+		logger.L().Debug("Graph update channel full; dropped node removal notification", zap.Int64("node_id", id))
+	}
+	
+	return nil
+}
+
+// RemoveEdge removes the edge from one node to another
+func (ng *NetworkGraph) RemoveEdge(from, to int64) error {
+	ng.mutex.Lock()
+	defer ng.mutex.Unlock()
+	
+	if !ng.topology.removeEdge(from, to) {
+		return fmt.Errorf("edge %d->%d not found", from, to)
+	}
+	
+	ng.totalEdges--
+	ng.lastUpdate = time.Now()
+	
+	// Invalidate cached paths crossing the edge and repair hub trees
+	ng.pathCache.InvalidateEdge(from, to)
+	ng.hubTrees.edgeChanged(ng, from, to)
+	ng.landmarks.edgesRemoved()
+	
+	select {
+	case ng.updateChan <- GraphUpdate{Type: EdgeRemove, EdgeFrom: from, EdgeTo: to}:
+	default:
+		logger.L().Debug("Graph update channel full; dropped edge removal notification",
+			zap.Int64("from", from),
+			zap.Int64("to", to),
+		)
 	}
 	
 	return nil
@@ -198,8 +289,7 @@ func (ng *NetworkGraph) GetNode(id int64) (*NetworkNode, bool) {
 	ng.mutex.RLock()
 	defer ng.mutex.RUnlock()
 	
-	node, exists := ng.nodes[id]
-	return node, exists
+	return ng.topology.node(id)
 }
 
 // GetEdge retrieves an edge between two nodes
@@ -207,11 +297,23 @@ func (ng *NetworkGraph) GetEdge(from, to int64) (*NetworkEdge, bool) {
 	ng.mutex.RLock()
 	defer ng.mutex.RUnlock()
 	
-	if edges, exists := ng.edges[from]; exists {
-		edge, exists := edges[to]
-		return edge, exists
-	}
-	return nil, false
+	return ng.topology.edge(from, to)
+}
+
+// Nodes returns every node ordered by ID
+func (ng *NetworkGraph) Nodes() []*NetworkNode {
+	ng.mutex.RLock()
+	defer ng.mutex.RUnlock()
+	
+	return ng.topology.nodes()
+}
+
+// Edges returns every edge ordered by source and destination
+func (ng *NetworkGraph) Edges() []*NetworkEdge {
+	ng.mutex.RLock()
+	defer ng.mutex.RUnlock()
+	
+	return ng.topology.edges(ng.totalEdges)
 }
 
 // FindNearestNodes returns nodes within a geographic radius
@@ -223,7 +325,7 @@ func (ng *NetworkGraph) FindNearestNodes(lat, lng, radiusKm float64, maxNodes in
 	
 	nodes := make([]*NetworkNode, 0, len(nodeIDs))
 	for _, id := range nodeIDs {
-		if node, exists := ng.nodes[id]; exists {
+		if node, exists := ng.topology.node(id); exists {
 			nodes = append(nodes, node)
 		}
 	}
@@ -252,16 +354,23 @@ func (ng *NetworkGraph) FindOptimalPath(from, to int64, preferences PathPreferen
 		return path, nil
 	}
 	
-	// Use weighted shortest path with custom weight function
-	shortest := path.DijkstraFrom(simple.Node(from), ng.graph)
-	
-	pathNodes, _ := shortest.To(to)
-	if len(pathNodes) == 0 {
+	// Walk a hub source's shortest-path tree, or use weighted shortest path
+	nodeIDs, found, build := ng.hubTrees.lookup(from, to)
+	if !found {
+		if _, exists := ng.topology.slot(from); build && exists {
+			tree := buildShortestPathTree(ng, from)
+			ng.hubTrees.add(tree)
+			nodeIDs = tree.pathTo(to)
+		} else {
+			nodeIDs = ng.topology.shortestPath(from, to)
+		}
+	}
+	if len(nodeIDs) == 0 {
 		return nil, fmt.Errorf("no path found from %d to %d", from, to)
 	}
 	
 	// Calculate detailed path metrics
-	optimized := ng.calculatePathMetrics(pathNodes, preferences)
+	optimized := ng.calculatePathMetrics(nodeIDs, preferences)
 	
 	// Cache the result
 	ng.pathCache.Put(from, to, preferences, optimized)
@@ -303,30 +412,200 @@ func (ng *NetworkGraph) FindMultiPath(from, to int64, maxPaths int) ([]*OptimalP
 	return paths, nil
 }
 
-// UpdateNodeMetrics updates performance metrics for a node
+// UpdateNodeMetrics updates performance metrics for a node, smoothing its
+// latency as SetEdgeSmoothing configures
 func (ng *NetworkGraph) UpdateNodeMetrics(nodeID int64, metrics NodeMetrics) error {
 	ng.mutex.Lock()
 	defer ng.mutex.Unlock()
 	
-	node, exists := ng.nodes[nodeID]
+	node, exists := ng.topology.node(nodeID)
 	if !exists {
 		return fmt.Errorf("node %d not found", nodeID)
 	}
 	
+	ng.applyNodeMetrics(node, metrics, time.Now())
+	
+	// Invalidate cached paths involving this node
+	ng.pathCache.InvalidateNode(nodeID)
+	
+	return nil
+}
+
+// UpdateNodeMetricsBatch updates the metrics of many nodes under one lock,
+// invalidating their cached paths together. Updates for unknown nodes are
+// skipped and reported in the error once the rest are applied.
+func (ng *NetworkGraph) UpdateNodeMetricsBatch(updates []NodeMetricsUpdate) error {
+	ng.mutex.Lock()
+	defer ng.mutex.Unlock()
+	
+	now := time.Now()
+	updated := make([]int64, 0, len(updates))
+	var missing []int64
+	for _, update := range updates {
+		node, exists := ng.topology.node(update.NodeID)
+		if !exists {
+			missing = append(missing, update.NodeID)
+			continue
+		}
+		
+		ng.applyNodeMetrics(node, update.Metrics, now)
+		updated = append(updated, update.NodeID)
+	}
+	
+	// Invalidate cached paths involving any updated node
+	ng.pathCache.InvalidateNodes(updated)
+	
+	if len(missing) > 0 {
+		return fmt.Errorf("%d nodes not found: %v", len(missing), missing)
+	}
+	return nil
+}
+
+// applyNodeMetrics sets the metrics of node, keeping its raw latency and
+// applying it smoothed; an outlier leaves the latency as it was. Callers
+// hold the write lock.
+func (ng *NetworkGraph) applyNodeMetrics(node *NetworkNode, metrics NodeMetrics, now time.Time) {
 	node.mutex.Lock()
-	node.Latency = metrics.Latency
+	defer node.mutex.Unlock()
+	
+	latency, _, outlier := ng.smoothing.smooth(&node.smoother, metrics.Latency, 0)
+	if outlier {
+		ng.nodeOutliers++
+		logger.L().Debug("Rejected outlying node latency sample",
+			zap.Int64("node", node.ID),
+			zap.Duration("latency", metrics.Latency),
+			zap.Duration("smoothed", latency),
+		)
+	}
+	
+	node.RawLatency = metrics.Latency
+	node.Latency = latency
 	node.Throughput = metrics.Throughput
 	node.Reliability = metrics.Reliability
 	node.LoadFactor = metrics.LoadFactor
-	node.LastSeen = time.Now()
-	node.mutex.Unlock()
+	node.LastSeen = now
+}
+
+// UpdateEdgeMetrics updates the measured quality of an existing edge,
+// smoothing its latency and jitter as SetEdgeSmoothing configures. The edge
+// weight follows its smoothed latency.
+func (ng *NetworkGraph) UpdateEdgeMetrics(from, to int64, metrics EdgeMetrics) error {
+	ng.mutex.Lock()
+	defer ng.mutex.Unlock()
 	
-	// Invalidate cached paths involving this node
-	ng.pathCache.InvalidateNode(nodeID)
+	edge, exists := ng.topology.edge(from, to)
+	if !exists {
+		return fmt.Errorf("edge %d->%d not found", from, to)
+	}
+	
+	// Keep the raw measurement and apply its smoothed latency and jitter;
+	// an outlier leaves them as they were
+	latency, jitter, outlier := ng.smoothing.smooth(&edge.smoother, metrics.Latency, metrics.Jitter)
+	if outlier {
+		ng.outliers++
+		logger.L().Debug("Rejected outlying edge latency sample",
+			zap.Int64("from", from),
+			zap.Int64("to", to),
+			zap.Duration("latency", metrics.Latency),
+			zap.Duration("smoothed", latency),
+		)
+	}
+	
+	previous, previousLatency := edge.Weight, edge.Latency
+	edge.Raw = metrics
+	edge.Latency = latency
+	edge.Bandwidth = metrics.Bandwidth
+	edge.PacketLoss = metrics.PacketLoss
+	edge.Jitter = jitter
+	edge.Reliability = metrics.Reliability
+	edge.Weight = float64(latency.Microseconds())
+	edge.LastUpdate = time.Now()
+	
+	ng.topology.reweight(from, to)
+	ng.lastUpdate = edge.LastUpdate
+	
+	// A costlier edge leaves every path not crossing it a shortest path,
+	// so only those crossing it are dropped; a cheaper one may shorten
+	// paths through either end. Hub trees are repaired incrementally.
+	if edge.Weight >= previous {
+		ng.pathCache.InvalidateEdge(from, to)
+	} else {
+		ng.pathCache.InvalidateNode(from)
+		ng.pathCache.InvalidateNode(to)
+	}
+	ng.hubTrees.edgeChanged(ng, from, to)
+	ng.landmarks.latencyChanged(previousLatency, edge.Latency)
+	
+	select {
+	case ng.updateChan <- GraphUpdate{Type: EdgeUpdate, EdgeFrom: from, EdgeTo: to, Edge: edge}:
+	default:
+		logger.L().Debug("Graph update channel full; dropped edge metrics notification",
+			zap.Int64("from", from),
+			zap.Int64("to", to),
+		)
+	}
+	
+	return nil
+}
+
+// SetNodePenalty adds penalty to the weight of every edge to a node, so
+// shortest paths avoid it unless no other route is within the penalty.
+// Zero clears it.
+func (ng *NetworkGraph) SetNodePenalty(id int64, penalty float64) error {
+	if penalty < 0 || math.IsNaN(penalty) || math.IsInf(penalty, 0) {
+		return fmt.Errorf("node penalty must be a finite non-negative weight, got %g", penalty)
+	}
+	
+	ng.mutex.Lock()
+	defer ng.mutex.Unlock()
+	
+	slot, exists := ng.topology.slot(id)
+	if !exists {
+		return fmt.Errorf("node %d not found", id)
+	}
+	previous := ng.topology.slots[slot].penalty
+	
+	// Reweight the edges into the node and repair hub trees through them.
+	// A higher penalty only lengthens paths through the node; a lower one
+	// may shorten paths from the nodes linking to it.
+	sources := ng.topology.setPenalty(id, penalty)
+	for _, from := range sources {
+		if penalty < previous {
+			ng.pathCache.InvalidateNode(from)
+		}
+		ng.hubTrees.edgeChanged(ng, from, id)
+	}
+	ng.pathCache.InvalidateNode(id)
+	ng.lastUpdate = time.Now()
+	
+	return nil
+}
+
+// SetHubPathTrees keeps precomputed shortest-path trees for up to count of
+// the sources with the most path cache misses, repaired as edges change, so
+// their paths are found by walking a tree instead of running Dijkstra. Zero
+// disables the trees.
+func (ng *NetworkGraph) SetHubPathTrees(count int) {
+	ng.hubTrees.resize(count)
+	
+	logger.L().Debug("Hub path trees resized", zap.Int("count", count))
+}
+
+// ResizePathCache changes how many optimal paths are cached
+func (ng *NetworkGraph) ResizePathCache(size int) error {
+	if err := ng.pathCache.Resize(size); err != nil {
+		return err
+	}
 	
+	logger.L().Debug("Path cache resized", zap.Int("size", size))
 	return nil
 }
 
+// GetPathCacheStats returns path cache statistics
+func (ng *NetworkGraph) GetPathCacheStats() CacheStatistics {
+	return ng.pathCache.GetStats()
+}
+
 // GetTopologyStats returns current graph statistics
 func (ng *NetworkGraph) GetTopologyStats() TopologyStats {
 	ng.mutex.RLock()
@@ -337,12 +616,37 @@ func (ng *NetworkGraph) GetTopologyStats() TopologyStats {
 		TotalEdges:   ng.totalEdges,
 		LastUpdate:   ng.lastUpdate,
 		CacheHitRate: ng.pathCache.GetHitRate(),
+		HubPathTrees: ng.hubTrees.size(),
+		Landmarks:    ng.landmarks.size(),
+		RejectedOutliers: ng.outliers,
+		RejectedNodeOutliers: ng.nodeOutliers,
+		PathSearches:   ng.topology.searches.Load(),
+		GuidedSearches: ng.topology.guided.Load(),
+		NodesExpanded:  ng.topology.expanded.Load(),
 	}
 }
 
+// Close stops the background update processor and waits for it to exit.
+// Pending notifications are dropped; the graph remains readable.
+func (ng *NetworkGraph) Close() {
+	ng.closeOnce.Do(func() {
+		close(ng.done)
+	})
+	<-ng.stopped
+}
+
 // processUpdates handles graph update notifications in background
 func (ng *NetworkGraph) processUpdates() {
-	for update := range ng.updateChan {
+	defer close(ng.stopped)
+	
+	for {
+		var update GraphUpdate
+		select {
+		case <-ng.done:
+			return
+		case update = <-ng.updateChan:
+		}
+		
 		// Process topology change notifications
 		// This can trigger recomputation of cached paths,
 		// load balancing decisions, etc.
@@ -357,8 +661,8 @@ func (ng *NetworkGraph) processUpdates() {
 }
 
 // calculatePathMetrics computes detailed metrics for a path
-func (ng *NetworkGraph) calculatePathMetrics(pathNodes []graph.Node, preferences PathPreferences) *OptimalPath {
-	if len(pathNodes) < 2 {
+func (ng *NetworkGraph) calculatePathMetrics(nodeIDs []int64, preferences PathPreferences) *OptimalPath {
+	if len(nodeIDs) < 2 {
 		return nil
 	}
 	
@@ -366,19 +670,14 @@ func (ng *NetworkGraph) calculatePathMetrics(pathNodes []graph.Node, preferences
 	var minThroughput float64 = math.Inf(1)
 	var avgReliability float64
 	var totalCost float64
-	hopCount := len(pathNodes) - 1
-	
-	nodeIDs := make([]int64, len(pathNodes))
-	for i, node := range pathNodes {
-		nodeIDs[i] = node.ID()
-	}
+	hopCount := len(nodeIDs) - 1
 	
 	// Calculate path metrics
-	for i := 0; i < len(pathNodes)-1; i++ {
-		fromID := pathNodes[i].ID()
-		toID := pathNodes[i+1].ID()
+	for i := 0; i < len(nodeIDs)-1; i++ {
+		fromID := nodeIDs[i]
+		toID := nodeIDs[i+1]
 		
-		if edge, exists := ng.edges[fromID][toID]; exists {
+		if edge, exists := ng.topology.edge(fromID, toID); exists {
 			totalLatency += edge.Latency
 			if edge.Bandwidth < minThroughput {
 				minThroughput = edge.Bandwidth
@@ -439,10 +738,37 @@ type NodeMetrics struct {
 	LoadFactor  float64
 }
 
+// NodeMetricsUpdate is the new metrics of one node in a batch
+type NodeMetricsUpdate struct {
+	NodeID  int64
+	Metrics NodeMetrics
+}
+
+// EdgeMetrics contains measured quality metrics for an edge
+type EdgeMetrics struct {
+	Latency     time.Duration
+	Bandwidth   float64
+	PacketLoss  float64
+	Jitter      time.Duration
+	Reliability float64
+}
+
 // TopologyStats provides graph statistics
 type TopologyStats struct {
 	TotalNodes   int64
 	TotalEdges   int64
 	LastUpdate   time.Time
 	CacheHitRate float64
+	HubPathTrees int
+	Landmarks    int
+	
+	// Shortest path searches run, those guided towards their target by
+	// node coordinates, and the nodes they expanded
+	PathSearches   int64
+	GuidedSearches int64
+	NodesExpanded  int64
+	
+	// Edge latency samples rejected as outliers, and node ones
+	RejectedOutliers     int64
+	RejectedNodeOutliers int64
 }
\ No newline at end of file
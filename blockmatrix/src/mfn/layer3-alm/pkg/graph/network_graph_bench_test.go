@@ -0,0 +1,149 @@
+// Package graph implements micro-benchmarks of path finding and spatial
+// queries
+package graph
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// benchmarkPreferences weights paths as the routing table does by default
+var benchmarkPreferences = PathPreferences{
+	LatencyWeight:     0.4,
+	ThroughputWeight:  0.3,
+	ReliabilityWeight: 0.2,
+	CostWeight:        0.1,
+}
+
+// newBenchmarkGraph returns a graph of nodes spread over the globe, joined
+// in a ring plus four random links each so every pair is reachable
+func newBenchmarkGraph(b *testing.B, nodes int) *NetworkGraph {
+	b.Helper()
+
+	rng := rand.New(rand.NewSource(1))
+	ng := NewNetworkGraph(nodes)
+	b.Cleanup(ng.Close)
+
+	for i := 1; i <= nodes; i++ {
+		node := &NetworkNode{
+			ID:          int64(i),
+			Address:     fmt.Sprintf("node-%d", i),
+			Latitude:    -90 + rng.Float64()*180,
+			Longitude:   -180 + rng.Float64()*360,
+			Latency:     time.Duration(1+rng.Intn(20)) * time.Millisecond,
+			Throughput:  100 + rng.Float64()*900,
+			Reliability: 0.95 + rng.Float64()*0.05,
+			LastSeen:    time.Now(),
+			Services:    make(map[string]ServiceInfo),
+		}
+		if err := ng.AddNode(node); err != nil {
+			b.Fatalf("failed to add node: %v", err)
+		}
+	}
+
+	addEdge := func(from, to int64) {
+		latency := time.Duration(1+rng.Intn(50)) * time.Millisecond
+		err := ng.AddEdge(&NetworkEdge{
+			From:        from,
+			To:          to,
+			Weight:      float64(latency.Microseconds()),
+			Latency:     latency,
+			Bandwidth:   100 + rng.Float64()*900,
+			PacketLoss:  rng.Float64() * 0.01,
+			Cost:        rng.Float64(),
+			Reliability: 0.95 + rng.Float64()*0.05,
+			Stability:   0.9 + rng.Float64()*0.1,
+			LastUpdate:  time.Now(),
+		})
+		if err != nil {
+			b.Fatalf("failed to add edge: %v", err)
+		}
+	}
+	for i := int64(1); i <= int64(nodes); i++ {
+		next := i%int64(nodes) + 1
+		addEdge(i, next)
+		addEdge(next, i)
+		for e := 0; e < 4; e++ {
+			if to := 1 + rng.Int63n(int64(nodes)); to != i {
+				addEdge(i, to)
+			}
+		}
+	}
+	return ng
+}
+
+// BenchmarkFindOptimalPath measures path finding with the path cache warm
+// and empty, and empty with a shortest-path tree kept for the source, over
+// graphs of growing size
+func BenchmarkFindOptimalPath(b *testing.B) {
+	for _, nodes := range []int{100, 1000, 100000} {
+		ng := newBenchmarkGraph(b, nodes)
+		to := int64(nodes / 2)
+
+		b.Run(fmt.Sprintf("nodes=%d/cached", nodes), func(b *testing.B) {
+			if _, err := ng.FindOptimalPath(1, to, benchmarkPreferences); err != nil {
+				b.Fatalf("path finding failed: %v", err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := ng.FindOptimalPath(1, to, benchmarkPreferences); err != nil {
+					b.Fatalf("path finding failed: %v", err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("nodes=%d/uncached", nodes), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				ng.pathCache.InvalidateAll()
+				b.StartTimer()
+
+				if _, err := ng.FindOptimalPath(1, to, benchmarkPreferences); err != nil {
+					b.Fatalf("path finding failed: %v", err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("nodes=%d/uncached-hub-tree", nodes), func(b *testing.B) {
+			ng.SetHubPathTrees(1)
+			defer ng.SetHubPathTrees(0)
+			if _, err := ng.FindOptimalPath(1, to, benchmarkPreferences); err != nil {
+				b.Fatalf("path finding failed: %v", err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				ng.pathCache.InvalidateAll()
+				b.StartTimer()
+
+				if _, err := ng.FindOptimalPath(1, to, benchmarkPreferences); err != nil {
+					b.Fatalf("path finding failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkFindNearestNodes measures spatial queries of growing radius
+func BenchmarkFindNearestNodes(b *testing.B) {
+	ng := newBenchmarkGraph(b, 10000)
+
+	for _, radiusKm := range []float64{100, 1000, 5000} {
+		b.Run(fmt.Sprintf("radius=%.0fkm", radiusKm), func(b *testing.B) {
+			rng := rand.New(rand.NewSource(2))
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ng.FindNearestNodes(-90+rng.Float64()*180, -180+rng.Float64()*360, radiusKm, 10)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateNodeMetricsBatch(t *testing.T) {
+	ng := NewNetworkGraph(4)
+	defer ng.Close()
+	for id := int64(1); id <= 3; id++ {
+		if err := ng.AddNode(&NetworkNode{ID: id}); err != nil {
+			t.Fatalf("AddNode: %v", err)
+		}
+	}
+	preferences := PathPreferences{LatencyWeight: 1}
+	ng.pathCache.Put(1, 2, preferences, &OptimalPath{NodeIDs: []int64{1, 2}})
+	ng.pathCache.Put(1, 3, preferences, &OptimalPath{NodeIDs: []int64{1, 3}})
+
+	generation := ng.pathCache.generation
+	err := ng.UpdateNodeMetricsBatch([]NodeMetricsUpdate{
+		{NodeID: 2, Metrics: NodeMetrics{Latency: time.Millisecond, LoadFactor: 0.5}},
+		{NodeID: 9, Metrics: NodeMetrics{Latency: time.Second}},
+		{NodeID: 3, Metrics: NodeMetrics{Reliability: 0.9}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "9") {
+		t.Errorf("missing node not reported: %v", err)
+	}
+
+	if node, _ := ng.GetNode(2); node.Latency != time.Millisecond || node.LoadFactor != 0.5 {
+		t.Errorf("node 2 not updated: latency %v, load %v", node.Latency, node.LoadFactor)
+	}
+	if node, _ := ng.GetNode(3); node.Reliability != 0.9 {
+		t.Errorf("node 3 not updated after a missing node: reliability %v", node.Reliability)
+	}
+	if ng.pathCache.generation != generation+1 {
+		t.Errorf("batch invalidated %d times, want once", ng.pathCache.generation-generation)
+	}
+	if path := ng.pathCache.Get(1, 2, preferences); path != nil {
+		t.Error("path through updated node 2 still cached")
+	}
+	if path := ng.pathCache.Get(1, 3, preferences); path != nil {
+		t.Error("path through updated node 3 still cached")
+	}
+}
+
+func TestSetNodePenaltySteersPathsAround(t *testing.T) {
+	ng := NewNetworkGraph(4)
+	defer ng.Close()
+	for id := int64(1); id <= 4; id++ {
+		if err := ng.AddNode(&NetworkNode{ID: id}); err != nil {
+			t.Fatalf("AddNode: %v", err)
+		}
+	}
+	for _, edge := range []*NetworkEdge{
+		{From: 1, To: 2, Weight: 10},
+		{From: 2, To: 4, Weight: 10},
+		{From: 1, To: 3, Weight: 15},
+		{From: 3, To: 4, Weight: 15},
+	} {
+		if err := ng.AddEdge(edge); err != nil {
+			t.Fatalf("AddEdge: %v", err)
+		}
+	}
+	via := func() int64 {
+		t.Helper()
+		path, err := ng.FindShortestPath(1, 4)
+		if err != nil || len(path.NodeIDs) != 3 {
+			t.Fatalf("FindShortestPath: %+v, %v", path, err)
+		}
+		return path.NodeIDs[1]
+	}
+
+	if hop := via(); hop != 2 {
+		t.Fatalf("unpenalized path via %d, want 2", hop)
+	}
+	if err := ng.SetNodePenalty(2, 5); err != nil {
+		t.Fatalf("SetNodePenalty: %v", err)
+	}
+	if hop := via(); hop != 2 {
+		t.Errorf("path left node 2 for a penalty within the detour: via %d", hop)
+	}
+	if err := ng.SetNodePenalty(2, 1000); err != nil {
+		t.Fatalf("SetNodePenalty: %v", err)
+	}
+	if hop := via(); hop != 3 {
+		t.Errorf("cached path kept through penalized node: via %d", hop)
+	}
+
+	// Edges added later carry the penalty, and clearing it restores the path
+	if err := ng.AddEdge(&NetworkEdge{From: 1, To: 2, Weight: 1}); err != nil {
+		t.Fatalf("AddEdge: %v", err)
+	}
+	if hop := via(); hop != 3 {
+		t.Errorf("replaced edge dropped the penalty: via %d", hop)
+	}
+	if err := ng.SetNodePenalty(2, 0); err != nil {
+		t.Fatalf("SetNodePenalty: %v", err)
+	}
+	if hop := via(); hop != 2 {
+		t.Errorf("cleared penalty still steers the path: via %d", hop)
+	}
+
+	if err := ng.SetNodePenalty(9, 1); err == nil {
+		t.Error("penalty set on a missing node")
+	}
+	if err := ng.SetNodePenalty(2, -1); err == nil {
+		t.Error("negative penalty accepted")
+	}
+}
+
+func TestUpdateEdgeMetricsKeepsPathsOffCostlierEdge(t *testing.T) {
+	ng := NewNetworkGraph(4)
+	defer ng.Close()
+	for id := int64(1); id <= 4; id++ {
+		if err := ng.AddNode(&NetworkNode{ID: id}); err != nil {
+			t.Fatalf("AddNode: %v", err)
+		}
+	}
+	for _, edge := range []*NetworkEdge{
+		{From: 1, To: 2, Weight: 10, Latency: 10 * time.Microsecond},
+		{From: 2, To: 3, Weight: 10, Latency: 10 * time.Microsecond},
+		{From: 2, To: 4, Weight: 10, Latency: 10 * time.Microsecond},
+	} {
+		if err := ng.AddEdge(edge); err != nil {
+			t.Fatalf("AddEdge: %v", err)
+		}
+	}
+	preferences := PathPreferences{LatencyWeight: 1}
+	for _, to := range []int64{3, 4} {
+		if _, err := ng.FindOptimalPath(1, to, preferences); err != nil {
+			t.Fatalf("FindOptimalPath 1->%d: %v", to, err)
+		}
+	}
+
+	// A costlier 2->3 only drops the path crossing it
+	if err := ng.UpdateEdgeMetrics(2, 3, EdgeMetrics{Latency: 20 * time.Microsecond}); err != nil {
+		t.Fatalf("UpdateEdgeMetrics: %v", err)
+	}
+	if path := ng.pathCache.Get(1, 3, preferences); path != nil {
+		t.Errorf("path crossing the costlier edge still cached: %+v", path)
+	}
+	if path := ng.pathCache.Get(1, 4, preferences); path == nil {
+		t.Error("path through node 2 off the costlier edge was dropped")
+	}
+
+	// A cheaper one may shorten any path through its ends
+	if err := ng.UpdateEdgeMetrics(2, 3, EdgeMetrics{Latency: 5 * time.Microsecond}); err != nil {
+		t.Fatalf("UpdateEdgeMetrics: %v", err)
+	}
+	if path := ng.pathCache.Get(1, 4, preferences); path != nil {
+		t.Errorf("path through a cheaper edge's end still cached: %+v", path)
+	}
+}
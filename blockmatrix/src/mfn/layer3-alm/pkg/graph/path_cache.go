@@ -3,24 +3,59 @@ package graph
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	lru "github.com/hashicorp/golang-lru"
 )
 
+const (
+	// maxPathAge is how long a cached path stays valid
+	maxPathAge = 5 * time.Minute
+	
+	// maxNodeInvalidations bounds the node and edge invalidation records
+	// kept before invalid paths are swept out and the records dropped
+	maxNodeInvalidations = 4096
+)
+
 // PathCache provides intelligent caching of routing paths
 type PathCache struct {
 	cache      *lru.ARCCache
 	stats      *CacheStats
 	
-	// Node invalidation tracking
-	nodeInvalidation map[int64]time.Time
+	// Node invalidation tracking: each invalidation advances generation and
+	// stamps the node with it, and a path cached before any of its nodes'
+	// stamps is dropped when next read, so invalidating a node is O(1).
+	// Records older than maxPathAge are expired, since every path they
+	// invalidate has aged out too.
+	generation     uint64
+	nodeGeneration map[int64]nodeInvalidation
+	lastExpiry     time.Time
+	
+	// Edge invalidations work alike for paths crossing one edge, and count
+	// towards the same bound
+	edgeGeneration map[pathEdge]nodeInvalidation
 	
 	mutex sync.RWMutex
 }
 
-// CacheKey represents a unique cache key for path queries
+// nodeInvalidation records when a node was last invalidated
+type nodeInvalidation struct {
+	generation uint64
+	at         time.Time
+}
+
+// pathEdge is a directed edge between consecutive nodes of a path
+type pathEdge struct {
+	from int64
+	to   int64
+}
+
+// CacheKey represents a unique cache key for path queries. It is
+// comparable, so lookups build no key strings.
 type CacheKey struct {
 	From        int64
 	To          int64
@@ -33,16 +68,18 @@ type CachedPath struct {
 	CreatedAt time.Time
 	AccessAt  time.Time
 	HitCount  int64
+	
+	// Generation of the cache when the path was stored
+	Generation uint64
 }
 
-// CacheStats tracks cache performance metrics
+// CacheStats tracks cache performance metrics with atomic counters, so
+// recording never contends on a lock
 type CacheStats struct {
-	Hits        int64
-	Misses      int64
-	Evictions   int64
-	Invalidations int64
-	
-	mutex sync.Mutex
+	Hits        atomic.Int64
+	Misses      atomic.Int64
+	Evictions   atomic.Int64
+	Invalidations atomic.Int64
 }
 
 // NewPathCache creates a new path cache with the specified capacity
@@ -50,9 +87,11 @@ func NewPathCache(capacity int) *PathCache {
 	cache, _ := lru.NewARC(capacity)
 	
 	return &PathCache{
-		cache:            cache,
-		stats:            &CacheStats{},
-		nodeInvalidation: make(map[int64]time.Time),
+		cache:          cache,
+		stats:          &CacheStats{},
+		nodeGeneration: make(map[int64]nodeInvalidation),
+		edgeGeneration: make(map[pathEdge]nodeInvalidation),
+		lastExpiry:     time.Now(),
 	}
 }
 
@@ -66,7 +105,7 @@ func (pc *PathCache) Get(from, to int64, preferences PathPreferences) *OptimalPa
 	if value, ok := pc.cache.Get(key); ok {
 		cached := value.(*CachedPath)
 		
-		// Check if path is still valid (no node invalidations after creation)
+		// Check if path is still valid (no node invalidations after it was stored)
 		if pc.isPathValid(cached) {
 			cached.AccessAt = time.Now()
 			cached.HitCount++
@@ -92,44 +131,135 @@ func (pc *PathCache) Put(from, to int64, preferences PathPreferences, path *Opti
 	key := pc.createKey(from, to, preferences)
 	
 	cached := &CachedPath{
-		Path:      path,
-		CreatedAt: time.Now(),
-		AccessAt:  time.Now(),
-		HitCount:  0,
+		Path:       path,
+		CreatedAt:  time.Now(),
+		AccessAt:   time.Now(),
+		HitCount:   0,
+		Generation: pc.generation,
 	}
 	
 	pc.cache.Add(key, cached)
 	pc.stats.recordPut()
 }
 
-// InvalidateNode invalidates all cached paths that include the specified node
+// InvalidateNode invalidates all cached paths that include the specified
+// node. Paths are dropped when next read rather than found by a scan.
 func (pc *PathCache) InvalidateNode(nodeID int64) {
 	pc.mutex.Lock()
 	defer pc.mutex.Unlock()
 	
-	pc.nodeInvalidation[nodeID] = time.Now()
+	now := time.Now()
+	pc.generation++
+	pc.nodeGeneration[nodeID] = nodeInvalidation{generation: pc.generation, at: now}
+	
+	pc.boundInvalidations(now)
+}
+
+// InvalidateEdge invalidates the cached paths that cross the edge from one
+// node to another, leaving those that only pass through either node. When
+// an edge grows costlier or is removed, every other cached path stays a
+// shortest path, so this is all that needs dropping.
+func (pc *PathCache) InvalidateEdge(from, to int64) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	
+	now := time.Now()
+	pc.generation++
+	pc.edgeGeneration[pathEdge{from: from, to: to}] = nodeInvalidation{generation: pc.generation, at: now}
+	pc.boundInvalidations(now)
+}
+
+// InvalidateNodes invalidates the cached paths including any of the nodes
+// in one pass, as a single invalidation
+func (pc *PathCache) InvalidateNodes(nodeIDs []int64) {
+	if len(nodeIDs) == 0 {
+		return
+	}
+	
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	
+	now := time.Now()
+	pc.generation++
+	for _, nodeID := range nodeIDs {
+		pc.nodeGeneration[nodeID] = nodeInvalidation{generation: pc.generation, at: now}
+	}
+	
+	pc.boundInvalidations(now)
+}
+
+// boundInvalidations expires old invalidation records and sweeps once
+// there are more than maxNodeInvalidations. The caller holds the mutex.
+func (pc *PathCache) boundInvalidations(now time.Time) {
+	if now.Sub(pc.lastExpiry) >= maxPathAge {
+		pc.expireInvalidations(now)
+	}
+	if len(pc.nodeGeneration)+len(pc.edgeGeneration) > maxNodeInvalidations {
+		pc.sweepInvalidPaths()
+	}
+}
+
+// expireInvalidations drops invalidation records older than maxPathAge.
+// The caller holds the mutex.
+func (pc *PathCache) expireInvalidations(now time.Time) {
+	for nodeID, invalidation := range pc.nodeGeneration {
+		if now.Sub(invalidation.at) > maxPathAge {
+			delete(pc.nodeGeneration, nodeID)
+		}
+	}
+	for edge, invalidation := range pc.edgeGeneration {
+		if now.Sub(invalidation.at) > maxPathAge {
+			delete(pc.edgeGeneration, edge)
+		}
+	}
+	pc.lastExpiry = now
+}
+
+// sweepInvalidPaths removes every invalid path from the cache, after which
+// no invalidation record can affect a cached or future path, and drops the
+// records. The caller holds the mutex.
+func (pc *PathCache) sweepInvalidPaths() {
+	for _, keyInterface := range pc.cache.Keys() {
+		if value, ok := pc.cache.Peek(keyInterface); ok && !pc.isPathValid(value.(*CachedPath)) {
+			pc.cache.Remove(keyInterface)
+			pc.stats.recordInvalidation()
+		}
+	}
+	clear(pc.nodeGeneration)
+	clear(pc.edgeGeneration)
+}
+
+// Resize changes the cache capacity. When shrinking, the most recently
+// accessed paths are kept.
+func (pc *PathCache) Resize(capacity int) error {
+	cache, err := lru.NewARC(capacity)
+	if err != nil {
+		return fmt.Errorf("invalid path cache size %d: %w", capacity, err)
+	}
 	
-	// Remove paths that include this node
-	keys := pc.cache.Keys()
-	removed := 0
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
 	
-	for _, keyInterface := range keys {
-		key := keyInterface.(string)
+	paths := make(map[CacheKey]*CachedPath, pc.cache.Len())
+	keys := make([]CacheKey, 0, pc.cache.Len())
+	for _, keyInterface := range pc.cache.Keys() {
+		key := keyInterface.(CacheKey)
 		if value, ok := pc.cache.Peek(key); ok {
-			cached := value.(*CachedPath)
-			
-			// Check if path includes the invalidated node
-			for _, pathNodeID := range cached.Path.NodeIDs {
-				if pathNodeID == nodeID {
-					pc.cache.Remove(key)
-					removed++
-					break
-				}
-			}
+			paths[key] = value.(*CachedPath)
+			keys = append(keys, key)
 		}
 	}
 	
-	pc.stats.recordInvalidations(int64(removed))
+	// Add least recently accessed first so the new cache evicts those
+	sort.Slice(keys, func(i, j int) bool {
+		return paths[keys[i]].AccessAt.Before(paths[keys[j]].AccessAt)
+	})
+	for _, key := range keys {
+		cache.Add(key, paths[key])
+	}
+	
+	pc.cache = cache
+	return nil
 }
 
 // InvalidateAll clears the entire cache
@@ -138,62 +268,76 @@ func (pc *PathCache) InvalidateAll() {
 	defer pc.mutex.Unlock()
 	
 	pc.cache.Purge()
-	pc.nodeInvalidation = make(map[int64]time.Time)
+	clear(pc.nodeGeneration)
+	clear(pc.edgeGeneration)
 }
 
 // GetHitRate returns the cache hit rate as a percentage
 func (pc *PathCache) GetHitRate() float64 {
-	pc.stats.mutex.Lock()
-	defer pc.stats.mutex.Unlock()
-	
-	total := pc.stats.Hits + pc.stats.Misses
+	hits := pc.stats.Hits.Load()
+	total := hits + pc.stats.Misses.Load()
 	if total == 0 {
 		return 0.0
 	}
 	
-	return float64(pc.stats.Hits) / float64(total) * 100.0
+	return float64(hits) / float64(total) * 100.0
 }
 
 // GetStats returns current cache statistics
 func (pc *PathCache) GetStats() CacheStatistics {
-	pc.stats.mutex.Lock()
-	defer pc.stats.mutex.Unlock()
-	
-	return CacheStatistics{
-		Hits:          pc.stats.Hits,
-		Misses:        pc.stats.Misses,
-		Evictions:     pc.stats.Evictions,
-		Invalidations: pc.stats.Invalidations,
-		HitRate:       pc.GetHitRate(),
+	stats := CacheStatistics{
+		Hits:          pc.stats.Hits.Load(),
+		Misses:        pc.stats.Misses.Load(),
+		Evictions:     pc.stats.Evictions.Load(),
+		Invalidations: pc.stats.Invalidations.Load(),
 		Size:          pc.cache.Len(),
 	}
+	
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total) * 100.0
+	}
+	
+	return stats
 }
 
-// createKey generates a unique cache key
-func (pc *PathCache) createKey(from, to int64, preferences PathPreferences) string {
-	return fmt.Sprintf("%d-%d-%.3f-%.3f-%.3f-%.3f",
-		from, to,
-		preferences.LatencyWeight,
-		preferences.ThroughputWeight,
-		preferences.ReliabilityWeight,
-		preferences.CostWeight,
-	)
+// createKey generates a unique cache key. Weights are rounded to three
+// decimals so preferences differing only by float noise share paths.
+func (pc *PathCache) createKey(from, to int64, preferences PathPreferences) CacheKey {
+	round := func(weight float64) float64 {
+		return math.Round(weight*1000) / 1000
+	}
+	
+	return CacheKey{
+		From: from,
+		To:   to,
+		Preferences: PathPreferences{
+			LatencyWeight:     round(preferences.LatencyWeight),
+			ThroughputWeight:  round(preferences.ThroughputWeight),
+			ReliabilityWeight: round(preferences.ReliabilityWeight),
+			CostWeight:        round(preferences.CostWeight),
+		},
+	}
 }
 
 // isPathValid checks if a cached path is still valid
 func (pc *PathCache) isPathValid(cached *CachedPath) bool {
-	// Check if any nodes in the path have been invalidated after the path was created
+	// Check if any nodes in the path have been invalidated since the path was stored
 	for _, nodeID := range cached.Path.NodeIDs {
-		if invalidTime, exists := pc.nodeInvalidation[nodeID]; exists {
-			if invalidTime.After(cached.CreatedAt) {
+		if pc.nodeGeneration[nodeID].generation > cached.Generation {
+			return false
+		}
+	}
+	if len(pc.edgeGeneration) > 0 {
+		nodeIDs := cached.Path.NodeIDs
+		for i := 0; i+1 < len(nodeIDs); i++ {
+			if pc.edgeGeneration[pathEdge{from: nodeIDs[i], to: nodeIDs[i+1]}].generation > cached.Generation {
 				return false
 			}
 		}
 	}
 	
-	// Check if path is too old (configurable TTL)
-	maxAge := 5 * time.Minute
-	if time.Since(cached.CreatedAt) > maxAge {
+	// Check if path is too old
+	if time.Since(cached.CreatedAt) > maxPathAge {
 		return false
 	}
 	
@@ -212,42 +356,30 @@ type CacheStatistics struct {
 
 // recordHit increments the hit counter
 func (cs *CacheStats) recordHit() {
-	cs.mutex.Lock()
-	defer cs.mutex.Unlock()
-	cs.Hits++
+	cs.Hits.Add(1)
 }
 
 // recordMiss increments the miss counter
 func (cs *CacheStats) recordMiss() {
-	cs.mutex.Lock()
-	defer cs.mutex.Unlock()
-	cs.Misses++
+	cs.Misses.Add(1)
 }
 
 // recordEviction increments the eviction counter
 func (cs *CacheStats) recordEviction() {
-	cs.mutex.Lock()
-	defer cs.mutex.Unlock()
-	cs.Evictions++
+	cs.Evictions.Add(1)
 }
 
 // recordInvalidation increments the invalidation counter
 func (cs *CacheStats) recordInvalidation() {
-	cs.mutex.Lock()
-	defer cs.mutex.Unlock()
-	cs.Invalidations++
+	cs.Invalidations.Add(1)
 }
 
 // recordInvalidations adds to the invalidation counter
 func (cs *CacheStats) recordInvalidations(count int64) {
-	cs.mutex.Lock()
-	defer cs.mutex.Unlock()
-	cs.Invalidations += count
+	cs.Invalidations.Add(count)
 }
 
 // recordPut increments the put counter
 func (cs *CacheStats) recordPut() {
-	cs.mutex.Lock()
-	defer cs.mutex.Unlock()
 	// This is handled differently since LRU doesn't return eviction info
 }
\ No newline at end of file
@@ -0,0 +1,134 @@
+package graph
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPathCacheInvalidateNode(t *testing.T) {
+	pc := NewPathCache(16)
+	preferences := PathPreferences{LatencyWeight: 1}
+	pc.Put(1, 3, preferences, &OptimalPath{NodeIDs: []int64{1, 2, 3}})
+	pc.Put(1, 4, preferences, &OptimalPath{NodeIDs: []int64{1, 4}})
+
+	pc.InvalidateNode(2)
+	if path := pc.Get(1, 3, preferences); path != nil {
+		t.Errorf("path through invalidated node 2 still cached: %+v", path)
+	}
+	if path := pc.Get(1, 4, preferences); path == nil {
+		t.Error("path avoiding node 2 was invalidated")
+	}
+	if invalidations := pc.stats.Invalidations.Load(); invalidations != 1 {
+		t.Errorf("recorded %d invalidations, want 1", invalidations)
+	}
+
+	// Paths stored after the invalidation stay valid
+	pc.Put(1, 3, preferences, &OptimalPath{NodeIDs: []int64{1, 2, 3}})
+	if path := pc.Get(1, 3, preferences); path == nil {
+		t.Error("path stored after invalidating node 2 was dropped")
+	}
+
+	pc.InvalidateAll()
+	pc.Put(1, 3, preferences, &OptimalPath{NodeIDs: []int64{1, 2, 3}})
+	if path := pc.Get(1, 3, preferences); path == nil {
+		t.Error("path dropped after InvalidateAll cleared node generations")
+	}
+}
+
+func TestPathCacheBoundsNodeInvalidations(t *testing.T) {
+	pc := NewPathCache(16)
+	preferences := PathPreferences{LatencyWeight: 1}
+	pc.Put(1, 3, preferences, &OptimalPath{NodeIDs: []int64{1, 2, 3}})
+	pc.Put(1, 4, preferences, &OptimalPath{NodeIDs: []int64{1, 4}})
+
+	// Records older than the path age expire
+	pc.InvalidateNode(5)
+	stale := time.Now().Add(-2 * maxPathAge)
+	pc.nodeGeneration[5] = nodeInvalidation{generation: pc.nodeGeneration[5].generation, at: stale}
+	pc.lastExpiry = stale
+	pc.InvalidateNode(6)
+	if _, ok := pc.nodeGeneration[5]; ok || len(pc.nodeGeneration) != 1 {
+		t.Errorf("kept %d invalidation records, want only node 6", len(pc.nodeGeneration))
+	}
+
+	// Exceeding the bound sweeps invalid paths and drops every record. With
+	// nodes 6 and 2, the loop's last invalidation is one past the bound.
+	pc.InvalidateNode(2)
+	for nodeID := int64(0); nodeID < maxNodeInvalidations-1; nodeID++ {
+		pc.InvalidateNode(nodeID + 1000)
+	}
+	if len(pc.nodeGeneration) != 0 {
+		t.Errorf("kept %d invalidation records after sweeping", len(pc.nodeGeneration))
+	}
+	if pc.cache.Len() != 1 {
+		t.Errorf("cache holds %d paths after sweeping, want 1", pc.cache.Len())
+	}
+	if path := pc.Get(1, 3, preferences); path != nil {
+		t.Errorf("path through invalidated node 2 still cached: %+v", path)
+	}
+	if path := pc.Get(1, 4, preferences); path == nil {
+		t.Error("path avoiding node 2 was dropped")
+	}
+}
+
+func TestPathCacheInvalidateNodes(t *testing.T) {
+	pc := NewPathCache(16)
+	preferences := PathPreferences{LatencyWeight: 1}
+	pc.Put(1, 3, preferences, &OptimalPath{NodeIDs: []int64{1, 2, 3}})
+	pc.Put(1, 5, preferences, &OptimalPath{NodeIDs: []int64{1, 4, 5}})
+	pc.Put(1, 6, preferences, &OptimalPath{NodeIDs: []int64{1, 6}})
+
+	generation := pc.generation
+	pc.InvalidateNodes([]int64{2, 4})
+	if pc.generation != generation+1 {
+		t.Errorf("batch advanced the generation by %d, want 1", pc.generation-generation)
+	}
+	if path := pc.Get(1, 3, preferences); path != nil {
+		t.Errorf("path through invalidated node 2 still cached: %+v", path)
+	}
+	if path := pc.Get(1, 5, preferences); path != nil {
+		t.Errorf("path through invalidated node 4 still cached: %+v", path)
+	}
+	if path := pc.Get(1, 6, preferences); path == nil {
+		t.Error("path avoiding the batch was invalidated")
+	}
+}
+
+// BenchmarkPathCacheInvalidateNode measures invalidating a hub node that
+// every cached path passes through
+func BenchmarkPathCacheInvalidateNode(b *testing.B) {
+	for _, size := range []int{1000, 100000} {
+		b.Run(fmt.Sprintf("paths=%d", size), func(b *testing.B) {
+			pc := NewPathCache(size)
+			for i := 0; i < size; i++ {
+				pc.Put(0, int64(i+1), PathPreferences{}, &OptimalPath{NodeIDs: []int64{0, int64(i + 1)}})
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pc.InvalidateNode(0)
+			}
+		})
+	}
+}
+
+func TestPathCacheInvalidateEdge(t *testing.T) {
+	pc := NewPathCache(16)
+	preferences := PathPreferences{LatencyWeight: 1}
+	pc.Put(1, 3, preferences, &OptimalPath{NodeIDs: []int64{1, 2, 3}})
+	pc.Put(2, 4, preferences, &OptimalPath{NodeIDs: []int64{2, 4}})
+	pc.Put(3, 1, preferences, &OptimalPath{NodeIDs: []int64{3, 2, 1}})
+
+	pc.InvalidateEdge(1, 2)
+	if path := pc.Get(1, 3, preferences); path != nil {
+		t.Errorf("path crossing invalidated edge 1->2 still cached: %+v", path)
+	}
+	if path := pc.Get(2, 4, preferences); path == nil {
+		t.Error("path through node 2 off the edge was invalidated")
+	}
+	if path := pc.Get(3, 1, preferences); path == nil {
+		t.Error("path crossing the reverse edge was invalidated")
+	}
+}
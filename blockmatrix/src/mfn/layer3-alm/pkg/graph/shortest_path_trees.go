@@ -0,0 +1,404 @@
+// Package graph implements shortest-path trees maintained for the hottest
+// path sources
+package graph
+
+import (
+	"container/heap"
+	"math"
+	"sync"
+)
+
+// hubTreeDecayInterval is how many path lookups pass between halvings of
+// the per-source lookup counts, so hotness follows recent traffic
+const hubTreeDecayInterval = 4096
+
+// hubTrees keeps shortest-path trees for up to capacity of the sources with
+// the most path lookups that missed the path cache. Trees are repaired as
+// edges change, so a lookup from a hub source walks its tree instead of
+// running Dijkstra.
+//
+// Lookups and builds run under the graph's read lock and repairs under its
+// write lock; the mutex guards the trees and counts among lookups.
+type hubTrees struct {
+	capacity int
+	trees    map[int64]*shortestPathTree
+	lookups  map[int64]int64
+	counted  int64
+
+	mutex sync.Mutex
+}
+
+// shortestPathTree holds the distance of every node reachable from source
+// and its predecessor on a shortest path, with each node's successors so
+// repairs can walk down from a changed edge
+type shortestPathTree struct {
+	source   int64
+	dist     map[int64]float64
+	parent   map[int64]int64
+	children map[int64][]int64
+}
+
+// newHubTrees creates hub tree tracking for up to capacity sources
+func newHubTrees(capacity int) *hubTrees {
+	return &hubTrees{
+		capacity: capacity,
+		trees:    make(map[int64]*shortestPathTree),
+		lookups:  make(map[int64]int64),
+	}
+}
+
+// lookup counts a path lookup from one node that missed the path cache and
+// returns the node IDs of the shortest path to another if from has a tree.
+// found reports whether from has a tree; a nil path with found set means to
+// is unreachable. Otherwise build reports whether a tree should be built for
+// from: there is a free slot, or from is now looked up more than the
+// coldest source with a tree.
+func (ht *hubTrees) lookup(from, to int64) (nodeIDs []int64, found, build bool) {
+	ht.mutex.Lock()
+	defer ht.mutex.Unlock()
+
+	if ht.capacity <= 0 {
+		return nil, false, false
+	}
+
+	ht.counted++
+	if ht.counted%hubTreeDecayInterval == 0 {
+		for id, count := range ht.lookups {
+			if count /= 2; count == 0 {
+				delete(ht.lookups, id)
+			} else {
+				ht.lookups[id] = count
+			}
+		}
+	}
+	ht.lookups[from]++
+
+	if tree, exists := ht.trees[from]; exists {
+		return tree.pathTo(to), true, false
+	}
+	if len(ht.trees) < ht.capacity {
+		return nil, false, true
+	}
+	_, coldest := ht.coldest()
+	return nil, false, ht.lookups[from] > coldest
+}
+
+// add stores a tree built for a hot source, evicting the coldest tree if
+// every slot is taken
+func (ht *hubTrees) add(tree *shortestPathTree) {
+	ht.mutex.Lock()
+	defer ht.mutex.Unlock()
+
+	if ht.capacity <= 0 {
+		return
+	}
+	if _, exists := ht.trees[tree.source]; !exists && len(ht.trees) >= ht.capacity {
+		source, _ := ht.coldest()
+		delete(ht.trees, source)
+	}
+	ht.trees[tree.source] = tree
+}
+
+// coldest returns the source with a tree and the fewest lookups. The caller
+// holds the mutex.
+func (ht *hubTrees) coldest() (int64, int64) {
+	var source int64
+	coldest := int64(math.MaxInt64)
+	for id := range ht.trees {
+		if count := ht.lookups[id]; count < coldest {
+			source, coldest = id, count
+		}
+	}
+	return source, coldest
+}
+
+// resize changes how many trees are kept, dropping the coldest
+func (ht *hubTrees) resize(capacity int) {
+	ht.mutex.Lock()
+	defer ht.mutex.Unlock()
+
+	ht.capacity = capacity
+	for len(ht.trees) > 0 && len(ht.trees) > capacity {
+		source, _ := ht.coldest()
+		delete(ht.trees, source)
+	}
+	if capacity <= 0 {
+		ht.lookups = make(map[int64]int64)
+	}
+}
+
+// size returns the number of trees kept
+func (ht *hubTrees) size() int {
+	ht.mutex.Lock()
+	defer ht.mutex.Unlock()
+
+	return len(ht.trees)
+}
+
+// edgeChanged repairs every tree after the edge from one node to another
+// was added, removed or reweighted. The caller holds the graph write lock.
+func (ht *hubTrees) edgeChanged(ng *NetworkGraph, from, to int64) {
+	ht.mutex.Lock()
+	defer ht.mutex.Unlock()
+
+	for _, tree := range ht.trees {
+		tree.edgeChanged(ng, from, to)
+	}
+}
+
+// nodeRemoved drops the removed node's tree and repairs the others. The
+// caller holds the graph write lock and has removed the node's edges.
+func (ht *hubTrees) nodeRemoved(ng *NetworkGraph, id int64) {
+	ht.mutex.Lock()
+	defer ht.mutex.Unlock()
+
+	delete(ht.trees, id)
+	delete(ht.lookups, id)
+	for _, tree := range ht.trees {
+		tree.nodeRemoved(ng, id)
+	}
+}
+
+// buildShortestPathTree runs Dijkstra from source over the graph's edge
+// weights. The caller holds the graph lock.
+func buildShortestPathTree(ng *NetworkGraph, source int64) *shortestPathTree {
+	tree := &shortestPathTree{
+		source:   source,
+		dist:     map[int64]float64{source: 0},
+		parent:   make(map[int64]int64),
+		children: make(map[int64][]int64),
+	}
+	queue := &distanceQueue{{id: source}}
+	tree.propagate(ng, queue)
+	return tree
+}
+
+// pathTo walks predecessors back from to, returning nil if it is
+// unreachable
+func (t *shortestPathTree) pathTo(to int64) []int64 {
+	if _, reachable := t.dist[to]; !reachable {
+		return nil
+	}
+
+	hops := 1
+	for id := to; id != t.source; id = t.parent[id] {
+		hops++
+	}
+	nodeIDs := make([]int64, hops)
+	for i, id := hops-1, to; i >= 0; i, id = i-1, t.parent[id] {
+		nodeIDs[i] = id
+	}
+	return nodeIDs
+}
+
+// edgeChanged repairs the tree after the edge from one node to another was
+// added, removed or reweighted, following Ramalingam and Reps: a shorter
+// path through the edge is relaxed onwards from to, and a longer or removed
+// tree edge rescans only the nodes below it left without another path of
+// the same length.
+func (t *shortestPathTree) edgeChanged(ng *NetworkGraph, from, to int64) {
+	fromDist, reachable := t.dist[from]
+	weight, exists := ng.topology.weight(from, to)
+	toDist, reached := t.dist[to]
+	if reachable && exists && (!reached || fromDist+weight < toDist) {
+		t.dist[to] = fromDist + weight
+		t.setParent(to, from)
+		t.propagate(ng, &distanceQueue{{id: to, dist: t.dist[to]}})
+		return
+	}
+	if parent, exists := t.parent[to]; exists && parent == from {
+		t.raise(ng, []int64{to})
+	}
+}
+
+// nodeRemoved repairs the subtree below a removed node
+func (t *shortestPathTree) nodeRemoved(ng *NetworkGraph, id int64) {
+	if _, reachable := t.dist[id]; !reachable {
+		return
+	}
+
+	orphans := append([]int64(nil), t.children[id]...)
+	t.detach(id)
+	delete(t.dist, id)
+	delete(t.children, id)
+	t.raise(ng, orphans)
+}
+
+// raise repairs the tree after the paths to roots grew longer or broke.
+// Nodes below them are visited in order of their old distance, and each
+// with another path of the same length, through a node the change did not
+// affect, keeps its distance and spares its subtree. The rest are affected
+// and repaired; raise returns how many.
+func (t *shortestPathTree) raise(ng *NetworkGraph, roots []int64) int {
+	// Whether each visited node is affected
+	visited := make(map[int64]bool)
+	queue := &distanceQueue{}
+	for _, root := range roots {
+		heap.Push(queue, distanceItem{id: root, dist: t.dist[root]})
+	}
+
+	var affected []int64
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(distanceItem)
+		if _, seen := visited[item.id]; seen {
+			continue
+		}
+		if parent, found := t.unaffectedParent(ng, item.id, visited); found {
+			visited[item.id] = false
+			t.setParent(item.id, parent)
+			continue
+		}
+		visited[item.id] = true
+		affected = append(affected, item.id)
+		for _, child := range t.children[item.id] {
+			heap.Push(queue, distanceItem{id: child, dist: t.dist[child]})
+		}
+	}
+	t.repair(ng, affected)
+	return len(affected)
+}
+
+// unaffectedParent returns a predecessor of id whose distance plus the edge
+// weight still equals id's. A predecessor not yet visited by raise cannot
+// lie below a raised node when the edge weighs more than zero, since raise
+// visits nodes in order of distance; across a zero-weight edge it must have
+// been visited and kept.
+func (t *shortestPathTree) unaffectedParent(ng *NetworkGraph, id int64, visited map[int64]bool) (int64, bool) {
+	slot, exists := ng.topology.slot(id)
+	if !exists {
+		return 0, false
+	}
+
+	slots := ng.topology.slots
+	dist := t.dist[id]
+	for _, fromSlot := range slots[slot].in {
+		from := slots[fromSlot].id
+		fromDist, reachable := t.dist[from]
+		if !reachable || from == id {
+			continue
+		}
+		out := slots[fromSlot].out
+		i := findEdgeSlot(out, slot)
+		if i < 0 || fromDist+out[i].weight != dist {
+			continue
+		}
+		if isAffected, seen := visited[from]; isAffected || !seen && out[i].weight <= 0 {
+			continue
+		}
+		return from, true
+	}
+	return 0, false
+}
+
+// repair recomputes the distances of affected nodes from their neighbours
+// outside the set, then relaxes onwards
+func (t *shortestPathTree) repair(ng *NetworkGraph, affected []int64) {
+	inAffected := make(map[int64]bool, len(affected))
+	for _, id := range affected {
+		inAffected[id] = true
+		delete(t.dist, id)
+		t.detach(id)
+	}
+
+	slots := ng.topology.slots
+	queue := &distanceQueue{}
+	for _, id := range affected {
+		slot, exists := ng.topology.slot(id)
+		if !exists {
+			continue
+		}
+		best, bestParent := math.Inf(1), int64(0)
+		for _, fromSlot := range slots[slot].in {
+			from := slots[fromSlot].id
+			fromDist, reachable := t.dist[from]
+			if inAffected[from] || !reachable {
+				continue
+			}
+			out := slots[fromSlot].out
+			if i := findEdgeSlot(out, slot); i >= 0 && fromDist+out[i].weight < best {
+				best, bestParent = fromDist+out[i].weight, from
+			}
+		}
+		if !math.IsInf(best, 1) {
+			t.dist[id] = best
+			t.setParent(id, bestParent)
+			heap.Push(queue, distanceItem{id: id, dist: best})
+		}
+	}
+	t.propagate(ng, queue)
+}
+
+// propagate runs Dijkstra from the queued nodes, shortening the distance of
+// every node reachable through them
+func (t *shortestPathTree) propagate(ng *NetworkGraph, queue *distanceQueue) {
+	slots := ng.topology.slots
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(distanceItem)
+		if item.dist > t.dist[item.id] {
+			continue
+		}
+		slot, exists := ng.topology.slot(item.id)
+		if !exists {
+			continue
+		}
+		for _, edge := range slots[slot].out {
+			to, dist := slots[edge.to].id, item.dist+edge.weight
+			if current, reached := t.dist[to]; reached && current <= dist {
+				continue
+			}
+			t.dist[to] = dist
+			t.setParent(to, item.id)
+			heap.Push(queue, distanceItem{id: to, dist: dist})
+		}
+	}
+}
+
+// setParent makes parent the predecessor of id
+func (t *shortestPathTree) setParent(id, parent int64) {
+	t.detach(id)
+	t.parent[id] = parent
+	t.children[parent] = append(t.children[parent], id)
+}
+
+// detach removes id from the children of its predecessor
+func (t *shortestPathTree) detach(id int64) {
+	parent, exists := t.parent[id]
+	if !exists {
+		return
+	}
+	delete(t.parent, id)
+
+	siblings := t.children[parent]
+	for i, child := range siblings {
+		if child == id {
+			siblings[i] = siblings[len(siblings)-1]
+			siblings = siblings[:len(siblings)-1]
+			break
+		}
+	}
+	if len(siblings) == 0 {
+		delete(t.children, parent)
+	} else {
+		t.children[parent] = siblings
+	}
+}
+
+// distanceItem is a node queued at a tentative distance
+type distanceItem struct {
+	id   int64
+	dist float64
+}
+
+// distanceQueue is a min-heap of nodes by distance
+type distanceQueue []distanceItem
+
+func (q distanceQueue) Len() int            { return len(q) }
+func (q distanceQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q distanceQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *distanceQueue) Push(x interface{}) { *q = append(*q, x.(distanceItem)) }
+func (q *distanceQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,226 @@
+package graph
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"gonum.org/v1/gonum/graph/path"
+	"gonum.org/v1/gonum/graph/simple"
+)
+
+func TestHubPathTreesRepairOnEdgeChanges(t *testing.T) {
+	const nodes = 60
+	hubs := []int64{1, 2, 3}
+
+	rng := rand.New(rand.NewSource(1))
+	ng := NewNetworkGraph(nodes)
+	defer ng.Close()
+	ng.SetHubPathTrees(len(hubs))
+
+	for i := int64(1); i <= nodes; i++ {
+		if err := ng.AddNode(&NetworkNode{ID: i}); err != nil {
+			t.Fatalf("AddNode: %v", err)
+		}
+	}
+	addEdge := func(from, to int64) {
+		if from == to {
+			return
+		}
+		latency := time.Duration(1+rng.Intn(20)) * time.Microsecond
+		edge := &NetworkEdge{From: from, To: to, Weight: float64(latency.Microseconds()), Latency: latency}
+		if err := ng.AddEdge(edge); err != nil {
+			t.Fatalf("AddEdge: %v", err)
+		}
+	}
+	for i := int64(1); i <= nodes; i++ {
+		addEdge(i, i%nodes+1)
+		addEdge(i, 1+rng.Int63n(nodes))
+	}
+
+	for _, hub := range hubs {
+		if _, err := ng.FindShortestPath(hub, nodes); err != nil {
+			t.Fatalf("FindShortestPath from %d: %v", hub, err)
+		}
+	}
+	if trees := ng.GetTopologyStats().HubPathTrees; trees != len(hubs) {
+		t.Fatalf("built %d hub trees, want %d", trees, len(hubs))
+	}
+
+	// checkTrees compares every tree with a fresh Dijkstra
+	checkTrees := func(step int) {
+		t.Helper()
+		for source, tree := range ng.hubTrees.trees {
+			shortest := path.DijkstraFrom(simple.Node(source), referenceGraph(ng))
+			for _, node := range ng.Nodes() {
+				id := node.ID
+				want := shortest.WeightTo(id)
+				got, reachable := tree.dist[id]
+				if !reachable {
+					got = math.Inf(1)
+				}
+				if got != want {
+					t.Fatalf("step %d: tree from %d has distance %v to %d, want %v", step, source, got, id, want)
+				}
+			}
+		}
+	}
+	checkTrees(0)
+
+	removable := int64(nodes)
+	for step := 1; step <= 300; step++ {
+		edges := ng.Edges()
+		switch op := rng.Intn(12); {
+		case op < 4:
+			addEdge(1+rng.Int63n(removable), 1+rng.Int63n(removable))
+		case op < 6:
+			penalty := float64(rng.Intn(3) * rng.Intn(30))
+			if err := ng.SetNodePenalty(1+rng.Int63n(removable), penalty); err != nil {
+				t.Fatalf("SetNodePenalty: %v", err)
+			}
+		case op < 9:
+			edge := edges[rng.Intn(len(edges))]
+			latency := time.Duration(1+rng.Intn(40)) * time.Microsecond
+			if err := ng.UpdateEdgeMetrics(edge.From, edge.To, EdgeMetrics{Latency: latency}); err != nil {
+				t.Fatalf("UpdateEdgeMetrics: %v", err)
+			}
+		case op < 11 || removable <= 10:
+			edge := edges[rng.Intn(len(edges))]
+			if err := ng.RemoveEdge(edge.From, edge.To); err != nil {
+				t.Fatalf("RemoveEdge: %v", err)
+			}
+		default:
+			if err := ng.RemoveNode(removable); err != nil {
+				t.Fatalf("RemoveNode: %v", err)
+			}
+			removable--
+		}
+		checkTrees(step)
+	}
+
+	// Cleared penalties leave latency as the only weight
+	for _, node := range ng.Nodes() {
+		if err := ng.SetNodePenalty(node.ID, 0); err != nil {
+			t.Fatalf("SetNodePenalty: %v", err)
+		}
+	}
+	checkTrees(-1)
+
+	// Lookups walk the repaired trees
+	for _, hub := range hubs {
+		shortest := path.DijkstraFrom(simple.Node(hub), referenceGraph(ng))
+		for to := int64(1); to <= removable; to++ {
+			if to == hub || math.IsInf(shortest.WeightTo(to), 1) {
+				continue
+			}
+			found, err := ng.FindShortestPath(hub, to)
+			if err != nil {
+				t.Fatalf("FindShortestPath %d->%d: %v", hub, to, err)
+			}
+			if want := time.Duration(shortest.WeightTo(to)) * time.Microsecond; found.TotalLatency != want {
+				t.Errorf("path %d->%d takes %v, want %v", hub, to, found.TotalLatency, want)
+			}
+		}
+	}
+
+	if err := ng.RemoveNode(hubs[0]); err != nil {
+		t.Fatalf("RemoveNode: %v", err)
+	}
+	if trees := ng.GetTopologyStats().HubPathTrees; trees != len(hubs)-1 {
+		t.Errorf("kept %d hub trees after removing a hub, want %d", trees, len(hubs)-1)
+	}
+}
+
+func TestShortestPathTreeRaiseSparesEqualPaths(t *testing.T) {
+	// Node 4 is reached at distance 2 through both 2 and 3, and node 5
+	// hangs below it
+	ng := NewNetworkGraph(5)
+	defer ng.Close()
+	for id := int64(1); id <= 5; id++ {
+		if err := ng.AddNode(&NetworkNode{ID: id}); err != nil {
+			t.Fatalf("AddNode: %v", err)
+		}
+	}
+	for _, edge := range []*NetworkEdge{
+		{From: 1, To: 2, Weight: 1},
+		{From: 1, To: 3, Weight: 1},
+		{From: 2, To: 4, Weight: 1},
+		{From: 3, To: 4, Weight: 1},
+		{From: 4, To: 5, Weight: 1},
+	} {
+		if err := ng.AddEdge(edge); err != nil {
+			t.Fatalf("AddEdge: %v", err)
+		}
+	}
+	tree := buildShortestPathTree(ng, 1)
+	via := tree.parent[4]
+
+	// Lengthening the tree edge into 4 moves it to its other parent, and
+	// leaves 5 untouched
+	edge, _ := ng.topology.edge(via, 4)
+	edge.Weight = 10
+	ng.topology.reweight(via, 4)
+	if affected := tree.raise(ng, []int64{4}); affected != 0 {
+		t.Errorf("raise repaired %d nodes, want none", affected)
+	}
+	if parent := tree.parent[4]; parent == via || tree.dist[4] != 2 || tree.dist[5] != 3 {
+		t.Errorf("node 4 at %v via %d, node 5 at %v; want 2 via the other parent and 3", tree.dist[4], parent, tree.dist[5])
+	}
+
+	// Without another path of the same length, 4 and 5 are repaired
+	other := tree.parent[4]
+	if err := ng.RemoveEdge(other, 4); err != nil {
+		t.Fatalf("RemoveEdge: %v", err)
+	}
+	if affected := tree.raise(ng, []int64{4}); affected != 2 {
+		t.Errorf("raise repaired %d nodes, want 2", affected)
+	}
+	if tree.parent[4] != via || tree.dist[4] != 11 || tree.dist[5] != 12 {
+		t.Errorf("node 4 at %v via %d, node 5 at %v; want 11 via %d and 12", tree.dist[4], tree.parent[4], tree.dist[5], via)
+	}
+}
+
+func TestHubPathTreesFollowHottestSources(t *testing.T) {
+	ht := newHubTrees(1)
+	if _, _, build := ht.lookup(1, 2); !build {
+		t.Fatal("no tree built for the first source")
+	}
+	ht.add(&shortestPathTree{source: 1, dist: map[int64]float64{1: 0}})
+	ht.lookup(1, 2)
+
+	// Source 2 takes the slot once it is looked up more than source 1
+	for i := 0; i < 2; i++ {
+		if _, _, build := ht.lookup(2, 3); build {
+			t.Fatalf("lookup %d of a colder source asked for a tree", i)
+		}
+	}
+	if _, _, build := ht.lookup(2, 3); !build {
+		t.Fatal("hotter source did not ask for a tree")
+	}
+	ht.add(&shortestPathTree{source: 2, dist: map[int64]float64{2: 0}})
+	if _, found, _ := ht.lookup(1, 2); found {
+		t.Error("colder source kept its tree")
+	}
+
+	ht.resize(0)
+	if _, found, build := ht.lookup(2, 3); found || build || ht.size() != 0 {
+		t.Error("trees kept after disabling")
+	}
+}
+
+// referenceGraph copies the graph into gonum's to check searches against,
+// with node penalties added to edge weights
+func referenceGraph(ng *NetworkGraph) *simple.WeightedDirectedGraph {
+	g := simple.NewWeightedDirectedGraph(0, math.Inf(1))
+	for _, node := range ng.Nodes() {
+		g.AddNode(simple.Node(node.ID))
+	}
+	for _, edge := range ng.Edges() {
+		if edge.From != edge.To {
+			weight, _ := ng.topology.weight(edge.From, edge.To)
+			g.SetWeightedEdge(g.NewWeightedEdge(simple.Node(edge.From), simple.Node(edge.To), weight))
+		}
+	}
+	return g
+}
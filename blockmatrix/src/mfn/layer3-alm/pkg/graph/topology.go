@@ -0,0 +1,388 @@
+// Package graph implements index-based storage of the network topology
+package graph
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// topology stores the graph's nodes in slots of one contiguous slice,
+// indexed by node ID, with each node's outgoing edges in a contiguous slice
+// of destination slots and weights. Traversals walk these slices instead of
+// chasing maps of pointers; the nodes and edges themselves stay where their
+// callers allocated them. Slots of removed nodes are reused.
+//
+// The graph's lock guards the topology; searches may run concurrently
+// under the read lock.
+type topology struct {
+	index map[int64]int32
+	slots []nodeSlot
+	free  []int32
+
+	// Per-search state reused across shortestPath calls
+	scratch sync.Pool
+
+	// Nodes without coordinates, and the weight per kilometre of
+	// great-circle distance no edge undercuts; searches are guided by
+	// geoGuide only while every node is located
+	unlocated   int
+	weightPerKm float64
+
+	// Searches run, those guided by geography, and nodes they expanded
+	searches atomic.Int64
+	guided   atomic.Int64
+	expanded atomic.Int64
+}
+
+// nodeSlot is a node's place in the topology. node is nil while the slot
+// is free.
+type nodeSlot struct {
+	id   int64
+	node *NetworkNode
+	out  []edgeSlot
+
+	// Slots of the nodes with an edge to this one
+	in []int32
+
+	// Added to the weight of every edge to this node, steering traversals
+	// around it
+	penalty float64
+}
+
+// edgeSlot is an outgoing edge: its destination slot and traversal weight,
+// the edge's Weight plus its destination's penalty, kept together for
+// traversals, and the edge with the rest of its metrics
+type edgeSlot struct {
+	to     int32
+	weight float64
+	edge   *NetworkEdge
+}
+
+// pathScratch is the state of one shortest path search, indexed by slot.
+// Entries are valid only where reached matches the search's stamp, so the
+// slices need not be cleared between searches.
+type pathScratch struct {
+	dist    []float64
+	bound   []float64
+	prev    []int32
+	reached []uint32
+	stamp   uint32
+	queue   distanceQueue
+}
+
+// newTopology creates a topology with room for capacity nodes
+func newTopology(capacity int) *topology {
+	return &topology{
+		index:       make(map[int64]int32, capacity),
+		slots:       make([]nodeSlot, 0, capacity),
+		weightPerKm: fiberMicrosPerKm,
+	}
+}
+
+// slot returns the slot of a node
+func (t *topology) slot(id int64) (int32, bool) {
+	slot, exists := t.index[id]
+	return slot, exists
+}
+
+// node returns a node by ID
+func (t *topology) node(id int64) (*NetworkNode, bool) {
+	if slot, exists := t.index[id]; exists {
+		return t.slots[slot].node, true
+	}
+	return nil, false
+}
+
+// addNode stores a node in a free slot, returning false if its ID is taken
+func (t *topology) addNode(node *NetworkNode) bool {
+	if _, exists := t.index[node.ID]; exists {
+		return false
+	}
+
+	var slot int32
+	if n := len(t.free); n > 0 {
+		slot = t.free[n-1]
+		t.free = t.free[:n-1]
+		t.slots[slot] = nodeSlot{id: node.ID, node: node}
+	} else {
+		slot = int32(len(t.slots))
+		t.slots = append(t.slots, nodeSlot{id: node.ID, node: node})
+	}
+	t.index[node.ID] = slot
+	if !located(node) {
+		t.unlocated++
+	}
+	return true
+}
+
+// removeNode frees a node's slot with every edge to or from it. It returns
+// the nodes that had an edge to it and the number of edges removed.
+func (t *topology) removeNode(id int64) (sources []int64, removed int) {
+	slot, exists := t.index[id]
+	if !exists {
+		return nil, 0
+	}
+
+	node := &t.slots[slot]
+	for _, from := range node.in {
+		if from == slot {
+			continue
+		}
+		t.slots[from].out = removeEdgeSlot(t.slots[from].out, slot)
+		sources = append(sources, t.slots[from].id)
+		removed++
+	}
+	for _, edge := range node.out {
+		if edge.to != slot {
+			t.slots[edge.to].in = removeSlot(t.slots[edge.to].in, slot)
+		}
+		removed++
+	}
+
+	if !located(node.node) {
+		t.unlocated--
+	}
+	t.slots[slot] = nodeSlot{}
+	t.free = append(t.free, slot)
+	delete(t.index, id)
+	return sources, removed
+}
+
+// edge returns the edge from one node to another
+func (t *topology) edge(from, to int64) (*NetworkEdge, bool) {
+	fromSlot, exists := t.index[from]
+	if !exists {
+		return nil, false
+	}
+	toSlot, exists := t.index[to]
+	if !exists {
+		return nil, false
+	}
+	if i := findEdgeSlot(t.slots[fromSlot].out, toSlot); i >= 0 {
+		return t.slots[fromSlot].out[i].edge, true
+	}
+	return nil, false
+}
+
+// weight returns the traversal weight of the edge from one node to another
+func (t *topology) weight(from, to int64) (float64, bool) {
+	fromSlot, exists := t.index[from]
+	if !exists {
+		return 0, false
+	}
+	toSlot, exists := t.index[to]
+	if !exists {
+		return 0, false
+	}
+	if i := findEdgeSlot(t.slots[fromSlot].out, toSlot); i >= 0 {
+		return t.slots[fromSlot].out[i].weight, true
+	}
+	return 0, false
+}
+
+// setEdge stores an edge between existing nodes, replacing any edge between
+// them, and reports whether it is new
+func (t *topology) setEdge(edge *NetworkEdge) bool {
+	from, to := t.index[edge.From], t.index[edge.To]
+	out := t.slots[from].out
+	weight := edge.Weight + t.slots[to].penalty
+	t.fitGuide(from, to, weight)
+	if i := findEdgeSlot(out, to); i >= 0 {
+		out[i] = edgeSlot{to: to, weight: weight, edge: edge}
+		return false
+	}
+
+	t.slots[from].out = append(out, edgeSlot{to: to, weight: weight, edge: edge})
+	t.slots[to].in = append(t.slots[to].in, from)
+	return true
+}
+
+// reweight sets the traversal weight of the edge from one node to another
+// to its edge's Weight plus the destination's penalty
+func (t *topology) reweight(from, to int64) {
+	fromSlot, toSlot := t.index[from], t.index[to]
+	out := t.slots[fromSlot].out
+	if i := findEdgeSlot(out, toSlot); i >= 0 {
+		out[i].weight = out[i].edge.Weight + t.slots[toSlot].penalty
+		t.fitGuide(fromSlot, toSlot, out[i].weight)
+	}
+}
+
+// setPenalty sets a node's penalty and reweights the edges to it, returning
+// the nodes they come from
+func (t *topology) setPenalty(id int64, penalty float64) []int64 {
+	slot := t.index[id]
+	t.slots[slot].penalty = penalty
+
+	sources := make([]int64, 0, len(t.slots[slot].in))
+	for _, from := range t.slots[slot].in {
+		sources = append(sources, t.slots[from].id)
+		t.reweight(t.slots[from].id, id)
+	}
+	return sources
+}
+
+// removeEdge removes the edge from one node to another, reporting whether
+// there was one
+func (t *topology) removeEdge(from, to int64) bool {
+	if _, exists := t.edge(from, to); !exists {
+		return false
+	}
+
+	fromSlot, toSlot := t.index[from], t.index[to]
+	t.slots[fromSlot].out = removeEdgeSlot(t.slots[fromSlot].out, toSlot)
+	t.slots[toSlot].in = removeSlot(t.slots[toSlot].in, fromSlot)
+	return true
+}
+
+// nodes returns every node ordered by ID
+func (t *topology) nodes() []*NetworkNode {
+	nodes := make([]*NetworkNode, 0, len(t.index))
+	for i := range t.slots {
+		if node := t.slots[i].node; node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+	return nodes
+}
+
+// edges returns every edge ordered by source and destination
+func (t *topology) edges(count int64) []*NetworkEdge {
+	edges := make([]*NetworkEdge, 0, count)
+	for i := range t.slots {
+		for _, edge := range t.slots[i].out {
+			edges = append(edges, edge.edge)
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	return edges
+}
+
+// shortestPath runs A* from one node until it reaches another, guided by
+// geography when every node is located and otherwise plain Dijkstra, and
+// returns the node IDs of the path, or nil if there is none
+func (t *topology) shortestPath(from, to int64) []int64 {
+	source, exists := t.index[from]
+	if !exists {
+		return nil
+	}
+	target, exists := t.index[to]
+	if !exists {
+		return nil
+	}
+
+	s := t.newScratch()
+	defer t.scratch.Put(s)
+
+	// Nodes are queued by their distance plus the guide's bound on the
+	// distance left, which is zero, and so Dijkstra, without coordinates
+	guide := t.guide(target)
+	expanded := int64(0)
+	s.dist[source], s.bound[source], s.prev[source], s.reached[source] = 0, guide.bound(source), -1, s.stamp
+	s.queue = append(s.queue[:0], distanceItem{id: int64(source), dist: s.bound[source]})
+	for s.queue.Len() > 0 {
+		item := heap.Pop(&s.queue).(distanceItem)
+		slot := int32(item.id)
+		if item.dist > s.dist[slot]+s.bound[slot] {
+			continue
+		}
+		if slot == target {
+			break
+		}
+		expanded++
+		for _, edge := range t.slots[slot].out {
+			dist := s.dist[slot] + edge.weight
+			if s.reached[edge.to] == s.stamp && s.dist[edge.to] <= dist {
+				continue
+			}
+			if s.reached[edge.to] != s.stamp {
+				s.bound[edge.to] = guide.bound(edge.to)
+			}
+			s.dist[edge.to], s.prev[edge.to], s.reached[edge.to] = dist, slot, s.stamp
+			heap.Push(&s.queue, distanceItem{id: int64(edge.to), dist: dist + s.bound[edge.to]})
+		}
+	}
+	t.searches.Add(1)
+	t.expanded.Add(expanded)
+	if guide.perKm > 0 {
+		t.guided.Add(1)
+	}
+	if s.reached[target] != s.stamp {
+		return nil
+	}
+
+	hops := 1
+	for slot := target; slot != source; slot = s.prev[slot] {
+		hops++
+	}
+	nodeIDs := make([]int64, hops)
+	for i, slot := hops-1, target; i >= 0; i, slot = i-1, s.prev[slot] {
+		nodeIDs[i] = t.slots[slot].id
+	}
+	return nodeIDs
+}
+
+// newScratch returns search state sized for every slot, with a fresh stamp
+func (t *topology) newScratch() *pathScratch {
+	s, _ := t.scratch.Get().(*pathScratch)
+	if s == nil {
+		s = &pathScratch{}
+	}
+	if n := len(t.slots); len(s.dist) < n {
+		s.dist = make([]float64, n)
+		s.bound = make([]float64, n)
+		s.prev = make([]int32, n)
+		s.reached = make([]uint32, n)
+		s.stamp = 0
+	}
+
+	s.stamp++
+	if s.stamp == 0 {
+		clear(s.reached)
+		s.stamp = 1
+	}
+	return s
+}
+
+// findEdgeSlot returns the index of the edge to slot, or -1
+func findEdgeSlot(out []edgeSlot, slot int32) int {
+	for i := range out {
+		if out[i].to == slot {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeEdgeSlot removes the edge to slot, not keeping order
+func removeEdgeSlot(out []edgeSlot, slot int32) []edgeSlot {
+	if i := findEdgeSlot(out, slot); i >= 0 {
+		last := len(out) - 1
+		out[i] = out[last]
+		out[last] = edgeSlot{}
+		return out[:last]
+	}
+	return out
+}
+
+// removeSlot removes one occurrence of slot, not keeping order
+func removeSlot(slots []int32, slot int32) []int32 {
+	for i := range slots {
+		if slots[i] == slot {
+			last := len(slots) - 1
+			slots[i] = slots[last]
+			return slots[:last]
+		}
+	}
+	return slots
+}
@@ -0,0 +1,109 @@
+package graph
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/graph/path"
+	"gonum.org/v1/gonum/graph/simple"
+)
+
+func TestTopologyReusesSlotsAndDropsEdges(t *testing.T) {
+	topo := newTopology(0)
+	for id := int64(1); id <= 3; id++ {
+		if !topo.addNode(&NetworkNode{ID: id}) {
+			t.Fatalf("addNode %d failed", id)
+		}
+	}
+	if topo.addNode(&NetworkNode{ID: 2}) {
+		t.Fatal("added a duplicate node")
+	}
+
+	for _, edge := range []*NetworkEdge{
+		{From: 1, To: 2, Weight: 1},
+		{From: 2, To: 3, Weight: 1},
+		{From: 3, To: 2, Weight: 1},
+		{From: 2, To: 2, Weight: 1},
+	} {
+		if !topo.setEdge(edge) {
+			t.Fatalf("edge %d->%d not new", edge.From, edge.To)
+		}
+	}
+	if topo.setEdge(&NetworkEdge{From: 1, To: 2, Weight: 5}) {
+		t.Fatal("replacing an edge reported it new")
+	}
+	if edge, _ := topo.edge(1, 2); edge.Weight != 5 {
+		t.Fatalf("edge 1->2 has weight %v, want the replacement's", edge.Weight)
+	}
+
+	sources, removed := topo.removeNode(2)
+	if removed != 4 {
+		t.Errorf("removed %d edges with node 2, want 4", removed)
+	}
+	if len(sources) != 2 {
+		t.Errorf("node 2 had %d other sources, want 2", len(sources))
+	}
+	if edges := topo.edges(0); len(edges) != 0 {
+		t.Errorf("%d edges left after removing node 2", len(edges))
+	}
+	if len(topo.slots[0].out) != 0 || len(topo.slots[2].in) != 0 {
+		t.Error("remaining nodes still link to the removed node")
+	}
+
+	topo.addNode(&NetworkNode{ID: 4})
+	if slot, _ := topo.slot(4); slot != 1 || len(topo.slots) != 3 {
+		t.Errorf("node 4 got slot %d of %d, want the freed slot 1", slot, len(topo.slots))
+	}
+	if _, exists := topo.edge(1, 4); exists {
+		t.Error("reused slot kept an edge of the removed node")
+	}
+}
+
+func TestTopologyShortestPathMatchesDijkstra(t *testing.T) {
+	const nodes = 200
+
+	rng := rand.New(rand.NewSource(1))
+	ng := NewNetworkGraph(nodes)
+	defer ng.Close()
+	for id := int64(1); id <= nodes; id++ {
+		ng.AddNode(&NetworkNode{ID: id})
+	}
+	for i := 0; i < nodes*4; i++ {
+		from, to := 1+rng.Int63n(nodes), 1+rng.Int63n(nodes)
+		ng.AddEdge(&NetworkEdge{From: from, To: to, Weight: float64(1 + rng.Intn(50))})
+	}
+	// Churn the slots so searches cover reused ones
+	for id := int64(1); id <= nodes; id += 7 {
+		ng.RemoveNode(id)
+		ng.AddNode(&NetworkNode{ID: id})
+		ng.AddEdge(&NetworkEdge{From: id, To: 1 + rng.Int63n(nodes), Weight: 3})
+	}
+
+	reference := referenceGraph(ng)
+	for from := int64(1); from <= nodes; from += 13 {
+		shortest := path.DijkstraFrom(simple.Node(from), reference)
+		for to := int64(1); to <= nodes; to++ {
+			nodeIDs := ng.topology.shortestPath(from, to)
+			want := shortest.WeightTo(to)
+			if math.IsInf(want, 1) {
+				if nodeIDs != nil {
+					t.Fatalf("path %d->%d found to an unreachable node", from, to)
+				}
+				continue
+			}
+
+			got := 0.0
+			for i := 1; i < len(nodeIDs); i++ {
+				edge, exists := ng.topology.edge(nodeIDs[i-1], nodeIDs[i])
+				if !exists {
+					t.Fatalf("path %d->%d uses missing edge %d->%d", from, to, nodeIDs[i-1], nodeIDs[i])
+				}
+				got += edge.Weight
+			}
+			if nodeIDs[0] != from || nodeIDs[len(nodeIDs)-1] != to || got != want {
+				t.Fatalf("path %d->%d is %v with weight %v, want weight %v", from, to, nodeIDs, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,381 @@
+// Package histogram implements an HDR latency histogram: values are counted
+// in log-linear buckets whose width keeps a fixed number of significant
+// figures, so quantiles up to P99.99 come from a fixed amount of memory no
+// matter how many values are recorded
+package histogram
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// Histogram counts latencies between 1ns and a configured highest value.
+// Recording is lock-free and safe for concurrent use; values above the
+// highest trackable value are clamped to it.
+type Histogram struct {
+	highest            int64
+	significantFigures int
+
+	subBucketHalfCountMagnitude uint
+	subBucketHalfCount          int64
+	subBucketMask               int64
+	leadingZeroCountBase        int
+
+	counts []int64
+
+	count atomic.Int64
+	sum   atomic.Int64
+	min   atomic.Int64
+	max   atomic.Int64
+}
+
+// Bucket is the number of values recorded in a range of the histogram
+type Bucket struct {
+	// Values up to and including UpperBound, and above the previous
+	// bucket's UpperBound, fall in this bucket
+	UpperBound time.Duration
+	Count      int64
+}
+
+// New creates a histogram tracking latencies up to highest with the given
+// number of significant figures, between 1 and 5. Memory grows with both:
+// three figures up to an hour takes about 280KB.
+func New(highest time.Duration, significantFigures int) *Histogram {
+	if significantFigures < 1 {
+		significantFigures = 1
+	}
+	if significantFigures > 5 {
+		significantFigures = 5
+	}
+	if highest < 2 {
+		highest = 2
+	}
+
+	largestSingleUnitResolution := int64(2 * math.Pow10(significantFigures))
+	subBucketCountMagnitude := uint(math.Ceil(math.Log2(float64(largestSingleUnitResolution))))
+	subBucketHalfCountMagnitude := subBucketCountMagnitude - 1
+	subBucketCount := int64(1) << subBucketCountMagnitude
+
+	bucketCount := 1
+	for smallestUntrackable := subBucketCount; smallestUntrackable <= int64(highest); {
+		if smallestUntrackable > math.MaxInt64/2 {
+			bucketCount++
+			break
+		}
+		smallestUntrackable <<= 1
+		bucketCount++
+	}
+
+	h := &Histogram{
+		highest:                     int64(highest),
+		significantFigures:          significantFigures,
+		subBucketHalfCountMagnitude: subBucketHalfCountMagnitude,
+		subBucketHalfCount:          subBucketCount / 2,
+		subBucketMask:               subBucketCount - 1,
+		leadingZeroCountBase:        64 - int(subBucketHalfCountMagnitude) - 1,
+		counts:                      make([]int64, (bucketCount+1)*int(subBucketCount/2)),
+	}
+	h.min.Store(math.MaxInt64)
+	return h
+}
+
+// Record counts one latency
+func (h *Histogram) Record(latency time.Duration) {
+	h.RecordN(latency, 1)
+}
+
+// RecordN counts n occurrences of a latency
+func (h *Histogram) RecordN(latency time.Duration, n int64) {
+	if n <= 0 {
+		return
+	}
+	value := h.clamp(latency)
+
+	atomic.AddInt64(&h.counts[h.countsIndex(value)], n)
+	h.count.Add(n)
+	h.sum.Add(value * n)
+	h.widen(value, value)
+}
+
+// RecordBatch counts each of latencies. The count, sum and range shared by
+// every recorder are updated once for the batch rather than once per value,
+// so recorders that buffer latencies contend on them less.
+func (h *Histogram) RecordBatch(latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+
+	var sum int64
+	lowest, highest := int64(math.MaxInt64), int64(0)
+	for _, latency := range latencies {
+		value := h.clamp(latency)
+		atomic.AddInt64(&h.counts[h.countsIndex(value)], 1)
+		sum += value
+		lowest, highest = min(lowest, value), max(highest, value)
+	}
+	h.count.Add(int64(len(latencies)))
+	h.sum.Add(sum)
+	h.widen(lowest, highest)
+}
+
+// clamp limits a latency to the trackable range
+func (h *Histogram) clamp(latency time.Duration) int64 {
+	return min(max(int64(latency), 0), h.highest)
+}
+
+// widen extends the recorded range to include lowest and highest
+func (h *Histogram) widen(lowest, highest int64) {
+	for current := h.min.Load(); lowest < current && !h.min.CompareAndSwap(current, lowest); current = h.min.Load() {
+	}
+	for current := h.max.Load(); highest > current && !h.max.CompareAndSwap(current, highest); current = h.max.Load() {
+	}
+}
+
+// Merge adds the counts of other, which must have been created with the
+// same highest value and significant figures
+func (h *Histogram) Merge(other *Histogram) error {
+	if other.highest != h.highest || other.significantFigures != h.significantFigures {
+		return fmt.Errorf("cannot merge histogram of %v at %d figures into one of %v at %d figures",
+			time.Duration(other.highest), other.significantFigures, time.Duration(h.highest), h.significantFigures)
+	}
+
+	for i := range other.counts {
+		if count := atomic.LoadInt64(&other.counts[i]); count > 0 {
+			atomic.AddInt64(&h.counts[i], count)
+		}
+	}
+	h.count.Add(other.count.Load())
+	h.sum.Add(other.sum.Load())
+	h.widen(other.min.Load(), other.max.Load())
+	return nil
+}
+
+// Reset clears the histogram. Values recorded concurrently may be lost.
+func (h *Histogram) Reset() {
+	for i := range h.counts {
+		atomic.StoreInt64(&h.counts[i], 0)
+	}
+	h.count.Store(0)
+	h.sum.Store(0)
+	h.min.Store(math.MaxInt64)
+	h.max.Store(0)
+}
+
+// Copy returns an independent histogram with the same counts
+func (h *Histogram) Copy() *Histogram {
+	clone := New(time.Duration(h.highest), h.significantFigures)
+	clone.Merge(h)
+	return clone
+}
+
+// Count returns the number of values recorded
+func (h *Histogram) Count() int64 {
+	return h.count.Load()
+}
+
+// Min returns the smallest value recorded, or 0 if none has been
+func (h *Histogram) Min() time.Duration {
+	if value := h.min.Load(); value != math.MaxInt64 {
+		return time.Duration(value)
+	}
+	return 0
+}
+
+// Max returns the largest value recorded
+func (h *Histogram) Max() time.Duration {
+	return time.Duration(h.max.Load())
+}
+
+// Mean returns the exact mean of the values recorded
+func (h *Histogram) Mean() time.Duration {
+	count := h.count.Load()
+	if count == 0 {
+		return 0
+	}
+	return time.Duration(h.sum.Load() / count)
+}
+
+// Quantile returns the value below which q of the recorded values fall,
+// for q between 0 and 1, to the histogram's significant figures
+func (h *Histogram) Quantile(q float64) time.Duration {
+	total := h.count.Load()
+	if total == 0 {
+		return 0
+	}
+	if q < 0 {
+		q = 0
+	}
+	if q > 1 {
+		q = 1
+	}
+
+	target := int64(math.Ceil(q * float64(total)))
+	if target < 1 {
+		target = 1
+	}
+
+	var seen int64
+	for i := range h.counts {
+		seen += atomic.LoadInt64(&h.counts[i])
+		if seen >= target {
+			value := h.highestEquivalentValue(h.valueFromIndex(i))
+			if max := h.max.Load(); value > max {
+				value = max
+			}
+			return time.Duration(value)
+		}
+	}
+	return h.Max()
+}
+
+// Quantiles returns the value at each of qs
+func (h *Histogram) Quantiles(qs ...float64) []time.Duration {
+	values := make([]time.Duration, len(qs))
+	for i, q := range qs {
+		values[i] = h.Quantile(q)
+	}
+	return values
+}
+
+// Buckets returns the non-empty buckets in ascending order, for exporting
+// the full distribution
+func (h *Histogram) Buckets() []Bucket {
+	var buckets []Bucket
+	for i := range h.counts {
+		if count := atomic.LoadInt64(&h.counts[i]); count > 0 {
+			buckets = append(buckets, Bucket{
+				UpperBound: time.Duration(h.highestEquivalentValue(h.valueFromIndex(i))),
+				Count:      count,
+			})
+		}
+	}
+	return buckets
+}
+
+// CumulativeCounts returns the number of values at or below each of
+// bounds, which must be ascending, as exported by Prometheus histograms.
+// Counts are exact to the histogram's significant figures.
+func (h *Histogram) CumulativeCounts(bounds []time.Duration) []uint64 {
+	cumulative := make([]uint64, len(bounds))
+
+	var seen uint64
+	next := 0
+	for _, bucket := range h.Buckets() {
+		for next < len(bounds) && bucket.UpperBound > bounds[next] {
+			cumulative[next] = seen
+			next++
+		}
+		seen += uint64(bucket.Count)
+	}
+	for ; next < len(bounds); next++ {
+		cumulative[next] = seen
+	}
+	return cumulative
+}
+
+// encoded is the JSON form of a histogram, with only non-empty counts
+type encoded struct {
+	Highest            time.Duration `json:"highest_ns"`
+	SignificantFigures int           `json:"significant_figures"`
+	Sum                int64         `json:"sum_ns"`
+	Min                int64         `json:"min_ns"`
+	Max                int64         `json:"max_ns"`
+
+	// Counts pairs each non-empty count with its index
+	Counts [][2]int64 `json:"counts"`
+}
+
+// MarshalJSON encodes the histogram losslessly, so histograms recorded in
+// other processes can be merged
+func (h *Histogram) MarshalJSON() ([]byte, error) {
+	e := encoded{
+		Highest:            time.Duration(h.highest),
+		SignificantFigures: h.significantFigures,
+		Sum:                h.sum.Load(),
+		Min:                h.min.Load(),
+		Max:                h.max.Load(),
+		Counts:             [][2]int64{},
+	}
+	for i := range h.counts {
+		if count := atomic.LoadInt64(&h.counts[i]); count > 0 {
+			e.Counts = append(e.Counts, [2]int64{int64(i), count})
+		}
+	}
+	return json.Marshal(e)
+}
+
+// UnmarshalJSON replaces the histogram with one encoded by MarshalJSON
+func (h *Histogram) UnmarshalJSON(data []byte) error {
+	var e encoded
+	if err := json.Unmarshal(data, &e); err != nil {
+		return err
+	}
+
+	decoded := New(e.Highest, e.SignificantFigures)
+	var count int64
+	for _, pair := range e.Counts {
+		if pair[0] < 0 || pair[0] >= int64(len(decoded.counts)) || pair[1] < 0 {
+			return fmt.Errorf("histogram count %d at index %d is out of range", pair[1], pair[0])
+		}
+		decoded.counts[pair[0]] = pair[1]
+		count += pair[1]
+	}
+	decoded.count.Store(count)
+	decoded.sum.Store(e.Sum)
+	decoded.min.Store(e.Min)
+	decoded.max.Store(e.Max)
+
+	h.highest = decoded.highest
+	h.significantFigures = decoded.significantFigures
+	h.subBucketHalfCountMagnitude = decoded.subBucketHalfCountMagnitude
+	h.subBucketHalfCount = decoded.subBucketHalfCount
+	h.subBucketMask = decoded.subBucketMask
+	h.leadingZeroCountBase = decoded.leadingZeroCountBase
+	h.counts = decoded.counts
+	h.count.Store(count)
+	h.sum.Store(e.Sum)
+	h.min.Store(e.Min)
+	h.max.Store(e.Max)
+	return nil
+}
+
+// Sum returns the total of the values recorded
+func (h *Histogram) Sum() time.Duration {
+	return time.Duration(h.sum.Load())
+}
+
+func (h *Histogram) bucketIndex(value int64) int {
+	return h.leadingZeroCountBase - bits.LeadingZeros64(uint64(value|h.subBucketMask))
+}
+
+func (h *Histogram) countsIndex(value int64) int {
+	bucketIndex := h.bucketIndex(value)
+	subBucketIndex := value >> uint(bucketIndex)
+	return (bucketIndex+1)<<h.subBucketHalfCountMagnitude + int(subBucketIndex-h.subBucketHalfCount)
+}
+
+func (h *Histogram) valueFromIndex(index int) int64 {
+	bucketIndex := (index >> h.subBucketHalfCountMagnitude) - 1
+	subBucketIndex := int64(index)&(h.subBucketHalfCount-1) + h.subBucketHalfCount
+	if bucketIndex < 0 {
+		subBucketIndex -= h.subBucketHalfCount
+		bucketIndex = 0
+	}
+	return subBucketIndex << uint(bucketIndex)
+}
+
+// highestEquivalentValue returns the largest value counted in the same
+// bucket as value
+func (h *Histogram) highestEquivalentValue(value int64) int64 {
+	bucketIndex := h.bucketIndex(value)
+	subBucketIndex := value >> uint(bucketIndex)
+	if subBucketIndex >= 2*h.subBucketHalfCount {
+		bucketIndex++
+	}
+	lowest := subBucketIndex << uint(h.bucketIndex(value))
+	return lowest + int64(1)<<uint(bucketIndex) - 1
+}
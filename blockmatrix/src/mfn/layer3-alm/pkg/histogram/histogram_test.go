@@ -0,0 +1,308 @@
+package histogram
+
+import (
+	"encoding/json"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// withinFigures reports whether got is within the relative error allowed by
+// the given number of significant figures
+func withinFigures(got, want time.Duration, figures int) bool {
+	if want == 0 {
+		return got == 0
+	}
+	return math.Abs(float64(got-want))/float64(want) <= math.Pow10(-figures)
+}
+
+func TestBucketMath(t *testing.T) {
+	h := New(time.Hour, 3)
+
+	cases := []int64{1, 2, 1023, 1024, 2047, 2048, 4095, 4096, 1_000_000, int64(time.Second), int64(time.Hour)}
+	for _, value := range cases {
+		index := h.countsIndex(value)
+		lowest := h.valueFromIndex(index)
+		highest := h.highestEquivalentValue(lowest)
+
+		if value < lowest || value > highest {
+			t.Errorf("value %d: bucket [%d, %d] does not contain it", value, lowest, highest)
+		}
+		if h.countsIndex(highest) != index {
+			t.Errorf("value %d: bucket upper bound %d maps to another bucket", value, highest)
+		}
+		if highest+1 <= h.highest && h.countsIndex(highest+1) == index {
+			t.Errorf("value %d: %d past the bucket upper bound maps to the same bucket", value, highest+1)
+		}
+		if width := highest - lowest + 1; float64(width) > float64(value)/1000+1 {
+			t.Errorf("value %d: bucket width %d exceeds three significant figures", value, width)
+		}
+	}
+}
+
+func TestQuantileAccuracyAtBounds(t *testing.T) {
+	cases := []struct {
+		name    string
+		figures int
+		highest time.Duration
+		values  []time.Duration
+		q       float64
+		want    time.Duration
+	}{
+		{"single value", 3, time.Minute, []time.Duration{time.Millisecond}, 0.5, time.Millisecond},
+		{"minimum value", 3, time.Minute, []time.Duration{time.Nanosecond}, 1, time.Nanosecond},
+		{"q below range", 3, time.Minute, []time.Duration{time.Microsecond, time.Second}, -1, time.Microsecond},
+		{"q above range", 3, time.Minute, []time.Duration{time.Microsecond, time.Second}, 2, time.Second},
+		{"q zero", 3, time.Minute, []time.Duration{time.Microsecond, time.Second}, 0, time.Microsecond},
+		{"q one", 3, time.Minute, []time.Duration{time.Microsecond, time.Second}, 1, time.Second},
+		{"highest trackable", 3, time.Minute, []time.Duration{time.Minute}, 1, time.Minute},
+		{"clamped above highest", 3, time.Minute, []time.Duration{time.Hour}, 1, time.Minute},
+		{"negative clamped to zero", 3, time.Minute, []time.Duration{-time.Second}, 1, 0},
+		{"five figures", 5, time.Minute, []time.Duration{123456789}, 0.5, 123456789},
+	}
+
+	for _, tc := range cases {
+		h := New(tc.highest, tc.figures)
+		for _, value := range tc.values {
+			h.Record(value)
+		}
+		if got := h.Quantile(tc.q); !withinFigures(got, tc.want, tc.figures) {
+			t.Errorf("%s: Quantile(%v) = %v, want %v", tc.name, tc.q, got, tc.want)
+		}
+	}
+}
+
+func TestQuantileUniformDistribution(t *testing.T) {
+	h := New(time.Minute, 3)
+	for i := 1; i <= 10000; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+
+	cases := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0.5, 5000 * time.Microsecond},
+		{0.9, 9000 * time.Microsecond},
+		{0.99, 9900 * time.Microsecond},
+		{0.999, 9990 * time.Microsecond},
+		{0.9999, 9999 * time.Microsecond},
+		{1, 10000 * time.Microsecond},
+	}
+	for _, tc := range cases {
+		if got := h.Quantile(tc.q); !withinFigures(got, tc.want, 3) {
+			t.Errorf("Quantile(%v) = %v, want %v", tc.q, got, tc.want)
+		}
+	}
+
+	if h.Count() != 10000 {
+		t.Errorf("Count = %d, want 10000", h.Count())
+	}
+	if h.Min() != time.Microsecond || h.Max() != 10000*time.Microsecond {
+		t.Errorf("Min, Max = %v, %v", h.Min(), h.Max())
+	}
+	if want := 5000500 * time.Nanosecond; h.Mean() != want {
+		t.Errorf("Mean = %v, want %v", h.Mean(), want)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := New(time.Minute, 3)
+	b := New(time.Minute, 3)
+	a.RecordN(time.Millisecond, 3)
+	b.Record(time.Microsecond)
+	b.Record(time.Second)
+
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	if a.Count() != 5 {
+		t.Errorf("Count = %d, want 5", a.Count())
+	}
+	if a.Min() != time.Microsecond || a.Max() != time.Second {
+		t.Errorf("Min, Max = %v, %v", a.Min(), a.Max())
+	}
+	if want := 3*time.Millisecond + time.Microsecond + time.Second; a.Sum() != want {
+		t.Errorf("Sum = %v, want %v", a.Sum(), want)
+	}
+	if b.Count() != 2 {
+		t.Errorf("merge modified its argument: Count = %d", b.Count())
+	}
+
+	if err := a.Merge(New(time.Hour, 3)); err == nil {
+		t.Error("merge of a different highest value succeeded")
+	}
+	if err := a.Merge(New(time.Minute, 2)); err == nil {
+		t.Error("merge of different significant figures succeeded")
+	}
+
+	empty := New(time.Minute, 3)
+	if err := a.Merge(empty); err != nil || a.Min() != time.Microsecond {
+		t.Errorf("merging an empty histogram changed Min to %v (%v)", a.Min(), err)
+	}
+}
+
+func TestRecordBatch(t *testing.T) {
+	batch := []time.Duration{time.Millisecond, -time.Second, time.Hour, 3 * time.Microsecond}
+	batched, single := New(time.Minute, 3), New(time.Minute, 3)
+	batched.RecordBatch(batch)
+	batched.RecordBatch(nil)
+	for _, latency := range batch {
+		single.Record(latency)
+	}
+
+	if batched.Count() != single.Count() || batched.Sum() != single.Sum() {
+		t.Errorf("batch counted %d summing %v, want %d summing %v", batched.Count(), batched.Sum(), single.Count(), single.Sum())
+	}
+	if batched.Min() != 0 || batched.Max() != time.Minute {
+		t.Errorf("Min, Max = %v, %v", batched.Min(), batched.Max())
+	}
+	for _, q := range []float64{0.25, 0.5, 0.75, 1} {
+		if got, want := batched.Quantile(q), single.Quantile(q); got != want {
+			t.Errorf("Quantile(%v) = %v, want %v", q, got, want)
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	h := New(time.Minute, 3)
+	h.RecordN(time.Millisecond, 3)
+	h.Record(time.Microsecond)
+	h.Record(time.Second)
+
+	data, err := json.Marshal(h)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var decoded Histogram
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if decoded.Count() != h.Count() || decoded.Sum() != h.Sum() || decoded.Min() != h.Min() || decoded.Max() != h.Max() {
+		t.Errorf("decoded count %d sum %v min %v max %v, want %d %v %v %v",
+			decoded.Count(), decoded.Sum(), decoded.Min(), decoded.Max(), h.Count(), h.Sum(), h.Min(), h.Max())
+	}
+	if decoded.Quantile(0.5) != h.Quantile(0.5) {
+		t.Errorf("decoded median %v, want %v", decoded.Quantile(0.5), h.Quantile(0.5))
+	}
+	if err := decoded.Merge(h); err != nil {
+		t.Errorf("decoded histogram does not merge with the original: %v", err)
+	}
+
+	invalid := []string{
+		`{"highest_ns":60000000000,"significant_figures":3,"counts":[[-1,1]]}`,
+		`{"highest_ns":60000000000,"significant_figures":3,"counts":[[100000000,1]]}`,
+		`{"highest_ns":60000000000,"significant_figures":3,"counts":[[1,-1]]}`,
+	}
+	for _, data := range invalid {
+		if err := json.Unmarshal([]byte(data), &decoded); err == nil {
+			t.Errorf("Unmarshal accepted %s", data)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	h := New(time.Minute, 3)
+	h.Record(time.Millisecond)
+	h.Reset()
+
+	if h.Count() != 0 || h.Sum() != 0 || h.Min() != 0 || h.Max() != 0 || h.Quantile(0.5) != 0 {
+		t.Errorf("histogram not empty after Reset: count %d sum %v min %v max %v",
+			h.Count(), h.Sum(), h.Min(), h.Max())
+	}
+	if len(h.Buckets()) != 0 {
+		t.Errorf("%d buckets left after Reset", len(h.Buckets()))
+	}
+
+	h.Record(2 * time.Millisecond)
+	if h.Min() != 2*time.Millisecond || h.Max() != 2*time.Millisecond {
+		t.Errorf("Min, Max after Reset = %v, %v", h.Min(), h.Max())
+	}
+}
+
+func TestCumulativeCounts(t *testing.T) {
+	h := New(time.Minute, 3)
+	// Values sit clear of the bounds, since a bucket straddling a bound
+	// counts above it
+	h.Record(500 * time.Microsecond)
+	h.Record(900 * time.Microsecond)
+	h.Record(5 * time.Millisecond)
+
+	bounds := []time.Duration{100 * time.Microsecond, time.Millisecond, 10 * time.Millisecond}
+	got := h.CumulativeCounts(bounds)
+	want := []uint64{0, 2, 3}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("count at or below %v = %d, want %d", bounds[i], got[i], want[i])
+		}
+	}
+}
+
+func TestWindowedRotation(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := start
+	w := NewWindowed(time.Minute, 3, 4*time.Second, 4)
+	w.now = func() time.Time { return clock }
+	w.rotated.Store(start.UnixNano())
+
+	cases := []struct {
+		advance time.Duration
+		record  time.Duration
+		want    int64
+	}{
+		{0, time.Millisecond, 1},
+		{time.Second, time.Millisecond, 2},
+		{2 * time.Second, time.Millisecond, 3},
+		// The first two slots have now rotated out
+		{2 * time.Second, time.Millisecond, 2},
+		// Idle for longer than the window clears everything
+		{time.Minute, 0, 0},
+	}
+
+	for i, tc := range cases {
+		clock = clock.Add(tc.advance)
+		if tc.record > 0 {
+			w.Record(tc.record)
+		}
+		if got := w.Snapshot().Count(); got != tc.want {
+			t.Errorf("step %d: window holds %d values, want %d", i, got, tc.want)
+		}
+	}
+
+	w.Record(time.Second)
+	w.Reset()
+	if got := w.Snapshot().Count(); got != 0 {
+		t.Errorf("window holds %d values after Reset", got)
+	}
+}
+
+func TestWindowedConcurrentRecord(t *testing.T) {
+	const goroutines, records = 8, 1000
+
+	// No rotation mid-test, so every record counts
+	w := NewWindowed(time.Minute, 3, 4*time.Hour, 4)
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < records; i++ {
+				w.Record(time.Millisecond)
+			}
+		}()
+	}
+	// Snapshots race the recorders
+	for i := 0; i < 10; i++ {
+		w.Snapshot()
+	}
+	wg.Wait()
+
+	if got := w.Snapshot().Count(); got != goroutines*records {
+		t.Errorf("window holds %d values, want %d", got, goroutines*records)
+	}
+	if got := w.Window(); got != 4*time.Hour {
+		t.Errorf("window covers %v, want %v", got, 4*time.Hour)
+	}
+}
@@ -0,0 +1,134 @@
+// Package histogram implements a sliding-window view over HDR histograms, so
+// quantiles follow recent latency instead of everything since startup
+package histogram
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Windowed is a histogram of the values recorded over a sliding window. The
+// window is a ring of slots that each count one interval, and the oldest
+// slot is cleared as the window advances, so a snapshot covers between
+// window-interval and window of history.
+//
+// Record takes no lock unless the window is due to advance, so it stays
+// cheap on hot paths recording from many goroutines.
+type Windowed struct {
+	// mutex serializes advancing the window with snapshots and resets
+	mutex    sync.Mutex
+	slots    []*Histogram
+	interval time.Duration
+
+	// current is the slot recorded into and rotated the time, in Unix
+	// nanoseconds, the window last advanced
+	current atomic.Int64
+	rotated atomic.Int64
+
+	highest            time.Duration
+	significantFigures int
+
+	now func() time.Time
+}
+
+// NewWindowed creates a windowed histogram covering window, split into
+// slots intervals, each a histogram as created by New
+func NewWindowed(highest time.Duration, significantFigures int, window time.Duration, slots int) *Windowed {
+	if slots < 1 {
+		slots = 1
+	}
+	interval := window / time.Duration(slots)
+	if interval <= 0 {
+		interval = time.Nanosecond
+	}
+
+	w := &Windowed{
+		slots:              make([]*Histogram, slots),
+		interval:           interval,
+		highest:            highest,
+		significantFigures: significantFigures,
+		now:                time.Now,
+	}
+	for i := range w.slots {
+		w.slots[i] = New(highest, significantFigures)
+	}
+	w.rotated.Store(w.now().UnixNano())
+	return w
+}
+
+// Window returns the span of history the histogram covers
+func (w *Windowed) Window() time.Duration {
+	return w.interval * time.Duration(len(w.slots))
+}
+
+// Record counts one latency in the current interval
+func (w *Windowed) Record(latency time.Duration) {
+	if w.now().UnixNano()-w.rotated.Load() >= int64(w.interval) {
+		w.mutex.Lock()
+		w.advance()
+		w.mutex.Unlock()
+	}
+	w.slots[w.current.Load()].Record(latency)
+}
+
+// RecordBatch counts each of latencies in the current interval, as
+// Histogram.RecordBatch does
+func (w *Windowed) RecordBatch(latencies []time.Duration) {
+	if w.now().UnixNano()-w.rotated.Load() >= int64(w.interval) {
+		w.mutex.Lock()
+		w.advance()
+		w.mutex.Unlock()
+	}
+	w.slots[w.current.Load()].RecordBatch(latencies)
+}
+
+// Snapshot returns an independent histogram of the values in the window
+func (w *Windowed) Snapshot() *Histogram {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.advance()
+	merged := New(w.highest, w.significantFigures)
+	for _, slot := range w.slots {
+		merged.Merge(slot)
+	}
+	return merged
+}
+
+// Reset clears every interval in the window
+func (w *Windowed) Reset() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, slot := range w.slots {
+		slot.Reset()
+	}
+	w.current.Store(0)
+	w.rotated.Store(w.now().UnixNano())
+}
+
+// advance moves the ring forward one slot per interval elapsed since the
+// last rotation, clearing each slot it moves into. The caller holds the
+// mutex.
+func (w *Windowed) advance() {
+	rotated := w.rotated.Load()
+	elapsed := (w.now().UnixNano() - rotated) / int64(w.interval)
+	if elapsed <= 0 {
+		return
+	}
+
+	steps := elapsed
+	if steps > int64(len(w.slots)) {
+		steps = int64(len(w.slots))
+	}
+	current := w.current.Load()
+	for i := int64(0); i < steps; i++ {
+		current = (current + 1) % int64(len(w.slots))
+		w.slots[current].Reset()
+	}
+
+	// Clear the slots before recorders move into them
+	w.current.Store(current)
+	w.rotated.Store(rotated + elapsed*int64(w.interval))
+}
@@ -0,0 +1,490 @@
+// Package integration implements the ledger-anchored topology and routing audit trail
+package integration
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"go.uber.org/zap"
+)
+
+// AuditAnchor is the digest of one audit period submitted to the ledger.
+// Anchors form a hash chain through PreviousDigest, so a missing or altered
+// anchor breaks verification of every later one.
+type AuditAnchor struct {
+	NodeID      string    `json:"node_id"`
+	Sequence    uint64    `json:"sequence"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+
+	// SHA-256 of the topology snapshot at PeriodEnd and of the routing
+	// decisions aggregated over the period, hex encoded
+	TopologyDigest string `json:"topology_digest"`
+	RoutingDigest  string `json:"routing_digest"`
+	Decisions      int64  `json:"decisions"`
+
+	PreviousDigest string `json:"previous_digest"`
+	Digest         string `json:"digest"`
+
+	// Ledger reference returned on submission; not part of the digest
+	Receipt string `json:"receipt,omitempty"`
+}
+
+// AuditLedger stores anchors on the trustchain/blockmatrix layer
+type AuditLedger interface {
+	// SubmitAnchor records anchor and returns the ledger's receipt for it
+	SubmitAnchor(ctx context.Context, anchor *AuditAnchor) (string, error)
+
+	// Anchors returns the anchors of nodeID from sequence from onwards, in order
+	Anchors(ctx context.Context, nodeID string, from uint64) ([]*AuditAnchor, error)
+}
+
+// AuditTrailConfig configures an AuditTrail
+type AuditTrailConfig struct {
+	// Identifies this node's anchor chain on the ledger
+	NodeID string
+
+	// Length of an audit period
+	Interval time.Duration
+
+	// Bound on a single ledger submission
+	SubmitTimeout time.Duration
+
+	// Anchors kept for resubmission while the ledger is unavailable; the
+	// oldest are dropped beyond this, which later verification reports
+	MaxPending int
+
+	// Submitted anchors kept locally for Verify
+	HistorySize int
+}
+
+// AuditTrailStats summarizes audit trail activity
+type AuditTrailStats struct {
+	Sequence  uint64
+	Anchored  int64
+	Failed    int64
+	Dropped   int64
+	Pending   int
+	LastError string
+}
+
+// AuditVerification is the result of checking anchors against the ledger
+type AuditVerification struct {
+	Verified   int
+	Missing    []uint64
+	Mismatched []uint64
+}
+
+// routeAggregate summarizes the decisions for one source/destination pair
+type routeAggregate struct {
+	Source         string   `json:"source"`
+	Destination    string   `json:"destination"`
+	Decisions      int64    `json:"decisions"`
+	TotalLatencyUs int64    `json:"total_latency_us"`
+	LastPath       []string `json:"last_path"`
+}
+
+// auditTopologyNode and auditTopologyEdge are the hashed topology fields.
+// Only structure and edge weights are included; fluctuating load metrics
+// would make digests unreproducible.
+type auditTopologyNode struct {
+	ID      int64  `json:"id"`
+	Address string `json:"address"`
+	Region  string `json:"region"`
+}
+
+type auditTopologyEdge struct {
+	From   int64   `json:"from"`
+	To     int64   `json:"to"`
+	Weight float64 `json:"weight"`
+}
+
+// AuditTrail periodically hashes the coordinator topology and the routing
+// decisions made since the last period, and anchors the digests on the
+// ledger so routing behaviour can be audited and tampering detected.
+type AuditTrail struct {
+	coordinator *internal.ALMCoordinator
+	ledger      AuditLedger
+	config      *AuditTrailConfig
+	logger      *zap.Logger
+
+	// Current period
+	periodStart time.Time
+	routes      map[string]*routeAggregate
+	decisions   int64
+
+	// Chain state
+	sequence uint64
+	previous string
+	pending  []*AuditAnchor
+	history  []*AuditAnchor
+
+	// Counters
+	anchored  int64
+	failed    int64
+	dropped   int64
+	lastError string
+
+	mutex sync.Mutex
+
+	// Serializes checkpoints and submissions
+	submitMutex sync.Mutex
+}
+
+// NewAuditTrail creates an audit trail anchoring to ledger
+func NewAuditTrail(coordinator *internal.ALMCoordinator, ledger AuditLedger, config *AuditTrailConfig, logger *zap.Logger) *AuditTrail {
+	if config == nil {
+		config = DefaultAuditTrailConfig()
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &AuditTrail{
+		coordinator: coordinator,
+		ledger:      ledger,
+		config:      config,
+		logger:      logger,
+		periodStart: time.Now(),
+		routes:      make(map[string]*routeAggregate),
+	}
+}
+
+// RecordRoutingDecision adds a routing decision to the current period
+func (at *AuditTrail) RecordRoutingDecision(source, destination string, decision *RoutingDecision) {
+	if decision == nil {
+		return
+	}
+
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+
+	key := source + "\x00" + destination
+	aggregate, exists := at.routes[key]
+	if !exists {
+		aggregate = &routeAggregate{Source: source, Destination: destination}
+		at.routes[key] = aggregate
+	}
+
+	aggregate.Decisions++
+	aggregate.TotalLatencyUs += decision.TotalLatency.Microseconds()
+	aggregate.LastPath = append(aggregate.LastPath[:0], decision.SelectedPath...)
+	at.decisions++
+}
+
+// Run closes an audit period every Interval until ctx is cancelled, then
+// closes the final period
+func (at *AuditTrail) Run(ctx context.Context) {
+	ticker := time.NewTicker(at.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Submit the partial period without the cancelled context
+			submitCtx, cancel := context.WithTimeout(context.Background(), at.config.SubmitTimeout)
+			at.Checkpoint(submitCtx)
+			cancel()
+			return
+
+		case <-ticker.C:
+			at.Checkpoint(ctx)
+		}
+	}
+}
+
+// Checkpoint closes the current period, chains its anchor and submits every
+// pending anchor in order. Anchors that fail to submit are retried on the
+// next checkpoint.
+func (at *AuditTrail) Checkpoint(ctx context.Context) (*AuditAnchor, error) {
+	at.submitMutex.Lock()
+	defer at.submitMutex.Unlock()
+
+	topologyDigest, err := at.topologyDigest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash topology: %w", err)
+	}
+
+	at.mutex.Lock()
+	anchor, err := at.closePeriod(topologyDigest, time.Now())
+	at.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return anchor, at.flush(ctx)
+}
+
+// Recover resumes the anchor chain from the ledger after a restart. The
+// node's recovery pipeline calls it before Run; it fails when the anchored
+// chain does not verify, signalling that the audit trail was tampered with.
+func (at *AuditTrail) Recover(ctx context.Context) error {
+	anchors, err := at.ledger.Anchors(ctx, at.config.NodeID, 0)
+	if err != nil {
+		return fmt.Errorf("failed to fetch audit anchors: %w", err)
+	}
+
+	if err := VerifyAuditChain(anchors); err != nil {
+		return err
+	}
+
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+
+	at.sequence, at.previous = 0, ""
+	if len(anchors) > 0 {
+		last := anchors[len(anchors)-1]
+		at.sequence, at.previous = last.Sequence, last.Digest
+	}
+	at.pending = nil
+	at.history = at.appendHistory(nil, anchors...)
+
+	at.logger.Info("Audit trail recovered",
+		zap.String("node_id", at.config.NodeID),
+		zap.Int("anchors", len(anchors)),
+		zap.Uint64("sequence", at.sequence),
+	)
+
+	return nil
+}
+
+// Verify checks the locally submitted anchors against the ledger. Anchors
+// absent from the ledger are reported as missing and anchors whose digest
+// differs as mismatched.
+func (at *AuditTrail) Verify(ctx context.Context) (*AuditVerification, error) {
+	at.mutex.Lock()
+	local := append([]*AuditAnchor(nil), at.history...)
+	at.mutex.Unlock()
+
+	result := &AuditVerification{}
+	if len(local) == 0 {
+		return result, nil
+	}
+
+	anchored, err := at.ledger.Anchors(ctx, at.config.NodeID, local[0].Sequence)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch audit anchors: %w", err)
+	}
+	if err := VerifyAuditChain(anchored); err != nil {
+		return nil, err
+	}
+
+	bySequence := make(map[uint64]*AuditAnchor, len(anchored))
+	for _, anchor := range anchored {
+		bySequence[anchor.Sequence] = anchor
+	}
+
+	for _, anchor := range local {
+		remote, exists := bySequence[anchor.Sequence]
+		switch {
+		case !exists:
+			result.Missing = append(result.Missing, anchor.Sequence)
+		case remote.Digest != anchor.Digest:
+			result.Mismatched = append(result.Mismatched, anchor.Sequence)
+		default:
+			result.Verified++
+		}
+	}
+
+	return result, nil
+}
+
+// Stats returns audit trail counters
+func (at *AuditTrail) Stats() AuditTrailStats {
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+
+	return AuditTrailStats{
+		Sequence:  at.sequence,
+		Anchored:  at.anchored,
+		Failed:    at.failed,
+		Dropped:   at.dropped,
+		Pending:   len(at.pending),
+		LastError: at.lastError,
+	}
+}
+
+// closePeriod builds the anchor for the current period and starts the next
+func (at *AuditTrail) closePeriod(topologyDigest string, now time.Time) (*AuditAnchor, error) {
+	routes := make([]*routeAggregate, 0, len(at.routes))
+	for _, aggregate := range at.routes {
+		routes = append(routes, aggregate)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Source != routes[j].Source {
+			return routes[i].Source < routes[j].Source
+		}
+		return routes[i].Destination < routes[j].Destination
+	})
+
+	routingDigest, err := digestJSON(routes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash routing decisions: %w", err)
+	}
+
+	anchor := &AuditAnchor{
+		NodeID:         at.config.NodeID,
+		Sequence:       at.sequence + 1,
+		PeriodStart:    at.periodStart,
+		PeriodEnd:      now,
+		TopologyDigest: topologyDigest,
+		RoutingDigest:  routingDigest,
+		Decisions:      at.decisions,
+		PreviousDigest: at.previous,
+	}
+	anchor.Digest = anchor.computeDigest()
+
+	at.sequence = anchor.Sequence
+	at.previous = anchor.Digest
+	at.periodStart = now
+	at.routes = make(map[string]*routeAggregate)
+	at.decisions = 0
+
+	at.pending = append(at.pending, anchor)
+	if excess := len(at.pending) - at.config.MaxPending; at.config.MaxPending > 0 && excess > 0 {
+		at.pending = at.pending[excess:]
+		at.dropped += int64(excess)
+		at.logger.Warn("Dropped unsubmitted audit anchors", zap.Int("dropped", excess))
+	}
+
+	return anchor, nil
+}
+
+// flush submits pending anchors in sequence order, stopping at the first failure
+func (at *AuditTrail) flush(ctx context.Context) error {
+	for {
+		at.mutex.Lock()
+		if len(at.pending) == 0 {
+			at.mutex.Unlock()
+			return nil
+		}
+		anchor := at.pending[0]
+		at.mutex.Unlock()
+
+		submitCtx, cancel := context.WithTimeout(ctx, at.config.SubmitTimeout)
+		receipt, err := at.ledger.SubmitAnchor(submitCtx, anchor)
+		cancel()
+
+		at.mutex.Lock()
+		if err != nil {
+			at.failed++
+			at.lastError = err.Error()
+			at.mutex.Unlock()
+
+			at.logger.Error("Failed to anchor audit digest",
+				zap.Error(err),
+				zap.Uint64("sequence", anchor.Sequence),
+			)
+			return fmt.Errorf("failed to anchor audit period %d: %w", anchor.Sequence, err)
+		}
+
+		anchor.Receipt = receipt
+		at.pending = at.pending[1:]
+		at.history = at.appendHistory(at.history, anchor)
+		at.anchored++
+		at.mutex.Unlock()
+
+		at.logger.Debug("Audit digest anchored",
+			zap.Uint64("sequence", anchor.Sequence),
+			zap.String("digest", anchor.Digest),
+			zap.String("receipt", receipt),
+		)
+	}
+}
+
+func (at *AuditTrail) appendHistory(history []*AuditAnchor, anchors ...*AuditAnchor) []*AuditAnchor {
+	history = append(history, anchors...)
+	if excess := len(history) - at.config.HistorySize; at.config.HistorySize > 0 && excess > 0 {
+		history = history[excess:]
+	}
+	return history
+}
+
+// topologyDigest hashes the coordinator's current nodes and edges
+func (at *AuditTrail) topologyDigest() (string, error) {
+	networkGraph := at.coordinator.NetworkGraph()
+
+	var snapshot struct {
+		Nodes []auditTopologyNode `json:"nodes"`
+		Edges []auditTopologyEdge `json:"edges"`
+	}
+	for _, node := range networkGraph.Nodes() {
+		snapshot.Nodes = append(snapshot.Nodes, auditTopologyNode{ID: node.ID, Address: node.Address, Region: node.Region})
+	}
+	for _, edge := range networkGraph.Edges() {
+		snapshot.Edges = append(snapshot.Edges, auditTopologyEdge{From: edge.From, To: edge.To, Weight: edge.Weight})
+	}
+
+	return digestJSON(snapshot)
+}
+
+// computeDigest hashes every anchor field except Digest and Receipt
+func (aa *AuditAnchor) computeDigest() string {
+	hash := sha256.New()
+	for _, field := range []string{
+		aa.NodeID,
+		strconv.FormatUint(aa.Sequence, 10),
+		strconv.FormatInt(aa.PeriodStart.UnixNano(), 10),
+		strconv.FormatInt(aa.PeriodEnd.UnixNano(), 10),
+		aa.TopologyDigest,
+		aa.RoutingDigest,
+		strconv.FormatInt(aa.Decisions, 10),
+		aa.PreviousDigest,
+	} {
+		hash.Write([]byte(field))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// VerifyAuditChain checks that anchors are consecutive, that each digest
+// matches its contents and that each anchor links to its predecessor
+func VerifyAuditChain(anchors []*AuditAnchor) error {
+	for i, anchor := range anchors {
+		if digest := anchor.computeDigest(); digest != anchor.Digest {
+			return fmt.Errorf("audit anchor %d: digest %s does not match contents (%s)", anchor.Sequence, anchor.Digest, digest)
+		}
+
+		if i == 0 {
+			continue
+		}
+		previous := anchors[i-1]
+		if anchor.Sequence != previous.Sequence+1 {
+			return fmt.Errorf("audit anchor %d follows %d: chain has a gap", anchor.Sequence, previous.Sequence)
+		}
+		if anchor.PreviousDigest != previous.Digest {
+			return fmt.Errorf("audit anchor %d does not link to anchor %d", anchor.Sequence, previous.Sequence)
+		}
+	}
+	return nil
+}
+
+// digestJSON hashes the JSON encoding of value. encoding/json writes struct
+// fields in declaration order, so the encoding of ordered data is canonical.
+func digestJSON(value interface{}) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// DefaultAuditTrailConfig returns default audit trail configuration
+func DefaultAuditTrailConfig() *AuditTrailConfig {
+	return &AuditTrailConfig{
+		Interval:      5 * time.Minute,
+		SubmitTimeout: 30 * time.Second,
+		MaxPending:    288, // One day at the default interval
+		HistorySize:   288,
+	}
+}
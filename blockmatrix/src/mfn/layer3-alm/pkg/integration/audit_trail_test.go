@@ -0,0 +1,241 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+// auditTopology returns an audit trail over a coordinator holding nodes and
+// edges, added in the order given
+func auditTopology(t *testing.T, nodes []*graph.NetworkNode, edges []*graph.NetworkEdge) *AuditTrail {
+	t.Helper()
+
+	config := internal.DefaultALMConfig()
+	config.MaxNodes = 100
+	config.MaxEdges = 1000
+	coordinator, err := internal.NewALMCoordinator(config, nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+
+	for _, node := range nodes {
+		if err := coordinator.NetworkGraph().AddNode(node); err != nil {
+			t.Fatalf("AddNode(%d): %v", node.ID, err)
+		}
+	}
+	for _, edge := range edges {
+		if err := coordinator.NetworkGraph().AddEdge(edge); err != nil {
+			t.Fatalf("AddEdge(%d, %d): %v", edge.From, edge.To, err)
+		}
+	}
+	return NewAuditTrail(coordinator, nil, nil, nil)
+}
+
+func auditNodes(ids ...int64) []*graph.NetworkNode {
+	nodes := make([]*graph.NetworkNode, 0, len(ids))
+	for _, id := range ids {
+		nodes = append(nodes, &graph.NetworkNode{ID: id, Address: fmt.Sprintf("10.0.0.%d:7777", id), Region: "eu-west"})
+	}
+	return nodes
+}
+
+func topologyDigest(t *testing.T, trail *AuditTrail) string {
+	t.Helper()
+
+	digest, err := trail.topologyDigest()
+	if err != nil {
+		t.Fatalf("topologyDigest: %v", err)
+	}
+	return digest
+}
+
+func TestAuditTopologyDigestIgnoresInsertionOrder(t *testing.T) {
+	a := auditTopology(t, auditNodes(1, 2, 3), []*graph.NetworkEdge{
+		{From: 1, To: 2, Weight: 1.5},
+		{From: 2, To: 3, Weight: 2},
+		{From: 3, To: 1, Weight: 4},
+	})
+	b := auditTopology(t, auditNodes(3, 1, 2), []*graph.NetworkEdge{
+		{From: 3, To: 1, Weight: 4},
+		{From: 1, To: 2, Weight: 1.5},
+		{From: 2, To: 3, Weight: 2},
+	})
+
+	if digestA, digestB := topologyDigest(t, a), topologyDigest(t, b); digestA != digestB {
+		t.Errorf("topology digests differ by insertion order: %s and %s", digestA, digestB)
+	}
+}
+
+func TestAuditTopologyDigestChangesWithContent(t *testing.T) {
+	edges := func(weight float64) []*graph.NetworkEdge {
+		return []*graph.NetworkEdge{{From: 1, To: 2, Weight: weight}}
+	}
+	base := topologyDigest(t, auditTopology(t, auditNodes(1, 2), edges(1)))
+
+	if digest := topologyDigest(t, auditTopology(t, auditNodes(1, 2), edges(2))); digest == base {
+		t.Error("topology digest unchanged by an edge weight")
+	}
+	if digest := topologyDigest(t, auditTopology(t, auditNodes(1, 2, 3), edges(1))); digest == base {
+		t.Error("topology digest unchanged by an added node")
+	}
+
+	moved := auditNodes(1, 2)
+	moved[1].Region = "us-east"
+	if digest := topologyDigest(t, auditTopology(t, moved, edges(1))); digest == base {
+		t.Error("topology digest unchanged by a node's region")
+	}
+
+	// Load metrics are excluded so the digest is reproducible
+	loaded := auditNodes(1, 2)
+	loaded[0].LoadFactor = 0.9
+	if digest := topologyDigest(t, auditTopology(t, loaded, edges(1))); digest != base {
+		t.Error("topology digest changed with node load")
+	}
+}
+
+type auditDecision struct {
+	source, destination string
+	latency             time.Duration
+}
+
+// routingAnchor records decisions on a fresh trail and closes the period
+func routingAnchor(t *testing.T, decisions []auditDecision) *AuditAnchor {
+	t.Helper()
+
+	trail := NewAuditTrail(nil, nil, &AuditTrailConfig{NodeID: "node-1"}, nil)
+	trail.periodStart = time.Unix(1_000_000, 0)
+	for _, d := range decisions {
+		trail.RecordRoutingDecision(d.source, d.destination, &RoutingDecision{
+			SelectedPath: []string{d.source, d.destination},
+			TotalLatency: d.latency,
+		})
+	}
+
+	anchor, err := trail.closePeriod("topology", time.Unix(1_000_300, 0))
+	if err != nil {
+		t.Fatalf("closePeriod: %v", err)
+	}
+	return anchor
+}
+
+func TestAuditRoutingDigestIgnoresDecisionOrder(t *testing.T) {
+	decisions := []auditDecision{
+		{"a", "b", 10 * time.Millisecond},
+		{"b", "c", 20 * time.Millisecond},
+		{"a", "c", 30 * time.Millisecond},
+		{"a", "b", 5 * time.Millisecond},
+	}
+	reordered := []auditDecision{decisions[2], decisions[1], decisions[0], decisions[3]}
+
+	first, second := routingAnchor(t, decisions), routingAnchor(t, reordered)
+	if first.RoutingDigest != second.RoutingDigest || first.Digest != second.Digest {
+		t.Errorf("digests differ by decision order: %s/%s and %s/%s",
+			first.RoutingDigest, first.Digest, second.RoutingDigest, second.Digest)
+	}
+	if first.Decisions != 4 {
+		t.Errorf("anchor counted %d decisions, want 4", first.Decisions)
+	}
+}
+
+func TestAuditRoutingDigestChangesWithContent(t *testing.T) {
+	base := routingAnchor(t, []auditDecision{{"a", "b", 10 * time.Millisecond}})
+
+	for _, decisions := range [][]auditDecision{
+		{{"a", "b", 11 * time.Millisecond}},
+		{{"a", "c", 10 * time.Millisecond}},
+		{{"a", "b", 10 * time.Millisecond}, {"a", "b", 0}},
+	} {
+		if anchor := routingAnchor(t, decisions); anchor.RoutingDigest == base.RoutingDigest || anchor.Digest == base.Digest {
+			t.Errorf("digests unchanged for decisions %v", decisions)
+		}
+	}
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	trail := NewAuditTrail(nil, nil, &AuditTrailConfig{NodeID: "node-1"}, nil)
+
+	var chain []*AuditAnchor
+	for i := 0; i < 3; i++ {
+		anchor, err := trail.closePeriod(fmt.Sprintf("topology-%d", i), time.Now())
+		if err != nil {
+			t.Fatalf("closePeriod: %v", err)
+		}
+		chain = append(chain, anchor)
+	}
+	if err := VerifyAuditChain(chain); err != nil {
+		t.Fatalf("VerifyAuditChain: %v", err)
+	}
+
+	tampered := *chain[1]
+	tampered.Decisions++
+	if err := VerifyAuditChain([]*AuditAnchor{chain[0], &tampered, chain[2]}); err == nil {
+		t.Error("chain verified with an altered anchor")
+	}
+	if err := VerifyAuditChain([]*AuditAnchor{chain[0], chain[2]}); err == nil {
+		t.Error("chain verified with a missing anchor")
+	}
+}
+
+// memoryLedger stores anchors in memory, failing submissions while down
+type memoryLedger struct {
+	anchors []*AuditAnchor
+	down    bool
+}
+
+func (ml *memoryLedger) SubmitAnchor(ctx context.Context, anchor *AuditAnchor) (string, error) {
+	if ml.down {
+		return "", fmt.Errorf("ledger unavailable")
+	}
+	stored := *anchor
+	ml.anchors = append(ml.anchors, &stored)
+	return fmt.Sprintf("receipt-%d", anchor.Sequence), nil
+}
+
+func (ml *memoryLedger) Anchors(ctx context.Context, nodeID string, from uint64) ([]*AuditAnchor, error) {
+	var anchors []*AuditAnchor
+	for _, anchor := range ml.anchors {
+		if anchor.NodeID == nodeID && anchor.Sequence >= from {
+			anchors = append(anchors, anchor)
+		}
+	}
+	return anchors, nil
+}
+
+func TestAuditTrailResubmitsAndVerifies(t *testing.T) {
+	ledger := &memoryLedger{down: true}
+	trail := auditTopology(t, auditNodes(1, 2), nil)
+	trail.ledger = ledger
+	trail.config.NodeID = "node-1"
+
+	ctx := context.Background()
+	if _, err := trail.Checkpoint(ctx); err == nil {
+		t.Fatal("Checkpoint succeeded with the ledger down")
+	}
+
+	ledger.down = false
+	if _, err := trail.Checkpoint(ctx); err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	if len(ledger.anchors) != 2 || ledger.anchors[0].Sequence != 1 {
+		t.Fatalf("ledger holds %d anchors, want both periods in order", len(ledger.anchors))
+	}
+
+	result, err := trail.Verify(ctx)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if result.Verified != 2 || len(result.Missing) != 0 || len(result.Mismatched) != 0 {
+		t.Errorf("Verify = %+v, want 2 verified", result)
+	}
+
+	// A rewritten anchor breaks the chain on the ledger
+	ledger.anchors[0].TopologyDigest = "forged"
+	if _, err := trail.Verify(ctx); err == nil {
+		t.Error("Verify accepted a rewritten ledger anchor")
+	}
+}
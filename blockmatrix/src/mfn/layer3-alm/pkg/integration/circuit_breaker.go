@@ -0,0 +1,539 @@
+// Package integration implements a sliding-window circuit breaker for HyperMesh services
+package integration
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a service circuit
+type BreakerState int
+
+const (
+	// CircuitClosed allows all calls and records their outcomes
+	CircuitClosed BreakerState = iota
+	// CircuitOpen rejects calls until OpenTimeout elapses
+	CircuitOpen
+	// CircuitHalfOpen allows a limited number of trial calls
+	CircuitHalfOpen
+)
+
+// String returns the state name
+func (bs BreakerState) String() string {
+	switch bs {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerConfig configures circuit breaking for a service
+type CircuitBreakerConfig struct {
+	// Sliding window of the most recent calls
+	WindowSize   int
+	MinimumCalls int
+
+	// Failure thresholds (0-1 fractions of calls in the window)
+	FailureRateThreshold  float64
+	SlowCallRateThreshold float64
+	SlowCallThreshold     time.Duration
+
+	// Recovery
+	OpenTimeout      time.Duration
+	HalfOpenMaxCalls int
+}
+
+// CircuitState is the result of checking a service circuit
+type CircuitState struct {
+	ServiceID    string
+	State        BreakerState
+	AllowRequest bool
+	FailureRate  float64
+	SlowCallRate float64
+	OpenedAt     time.Time
+	RetryAfter   time.Duration
+}
+
+// CircuitMetrics contains per-service circuit breaker metrics
+type CircuitMetrics struct {
+	ServiceID        string
+	State            BreakerState
+	TotalCalls       int64
+	SuccessfulCalls  int64
+	FailedCalls      int64
+	SlowCalls        int64
+	RejectedCalls    int64
+	WindowCalls      int
+	FailureRate      float64
+	SlowCallRate     float64
+	StateTransitions int64
+	LastStateChange  time.Time
+}
+
+// CircuitBreaker implements CircuitBreakerInterface with a count-based sliding
+// window per service. The circuit opens when the failure rate or slow-call
+// rate within the window crosses its threshold, moves to half-open after
+// OpenTimeout, and closes again once HalfOpenMaxCalls trial calls succeed.
+//
+// The same breaker can guard transport connections (see Guard) and serve the
+// integration layer, so both observe a single view of service health.
+type CircuitBreaker struct {
+	config         *CircuitBreakerConfig
+	serviceConfigs map[string]*CircuitBreakerConfig
+	circuits       map[string]*serviceCircuit
+	onStateChange  []func(serviceID string, from, to BreakerState)
+	mutex          sync.RWMutex
+}
+
+// serviceCircuit tracks the sliding window and state for one service
+type serviceCircuit struct {
+	config *CircuitBreakerConfig
+
+	state           BreakerState
+	openedAt        time.Time
+	lastStateChange time.Time
+
+	// Sliding window ring of call outcomes
+	outcomes []callOutcome
+	cursor   int
+	filled   int
+	failures int
+	slow     int
+
+	// Half-open trial accounting
+	trialsIssued    int
+	trialsSucceeded int
+
+	// Lifetime counters
+	totalCalls       int64
+	successfulCalls  int64
+	failedCalls      int64
+	slowCalls        int64
+	rejectedCalls    int64
+	stateTransitions int64
+
+	mutex sync.Mutex
+}
+
+// callOutcome is one entry of the sliding window
+type callOutcome struct {
+	failed bool
+	slow   bool
+}
+
+// NewCircuitBreaker creates a circuit breaker using config for all services
+// without their own configuration
+func NewCircuitBreaker(config *CircuitBreakerConfig) *CircuitBreaker {
+	if config == nil {
+		config = DefaultCircuitBreakerConfig()
+	}
+
+	return &CircuitBreaker{
+		config:         config,
+		serviceConfigs: make(map[string]*CircuitBreakerConfig),
+		circuits:       make(map[string]*serviceCircuit),
+	}
+}
+
+// SetServiceConfig overrides the configuration for a single service and resets its circuit
+func (cb *CircuitBreaker) SetServiceConfig(serviceID string, config *CircuitBreakerConfig) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	if config == nil {
+		delete(cb.serviceConfigs, serviceID)
+	} else {
+		cb.serviceConfigs[serviceID] = config
+	}
+	delete(cb.circuits, serviceID)
+}
+
+// OnStateChange registers a callback invoked on every state transition.
+// Callbacks run in registration order after the circuit lock is released.
+func (cb *CircuitBreaker) OnStateChange(callback func(serviceID string, from, to BreakerState)) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.onStateChange = append(cb.onStateChange, callback)
+}
+
+// CheckCircuit reports the circuit state and acquires permission for one call.
+// When AllowRequest is true the caller must report the outcome through
+// RecordSuccess, RecordFailure or RecordCall.
+func (cb *CircuitBreaker) CheckCircuit(serviceID string) (*CircuitState, error) {
+	circuit := cb.circuit(serviceID)
+
+	circuit.mutex.Lock()
+	now := time.Now()
+	from := circuit.state
+	allowed := circuit.tryAcquire(now)
+	to := circuit.state
+	state := circuit.snapshotState(serviceID, now)
+	state.AllowRequest = allowed
+	circuit.mutex.Unlock()
+
+	cb.notify(serviceID, from, to)
+	return state, nil
+}
+
+// Allow acquires permission for one call, returning an error when the circuit is open
+func (cb *CircuitBreaker) Allow(serviceID string) error {
+	state, _ := cb.CheckCircuit(serviceID)
+	if state.AllowRequest {
+		return nil
+	}
+
+	return &TransportError{
+		Code:      ErrorCodeServerUnavailable,
+		Message:   fmt.Sprintf("circuit for %s is %s, retry after %v", serviceID, state.State, state.RetryAfter),
+		Retryable: false,
+		Temporary: true,
+	}
+}
+
+// RecordSuccess records a successful call
+func (cb *CircuitBreaker) RecordSuccess(serviceID string) error {
+	cb.RecordCall(serviceID, 0, nil)
+	return nil
+}
+
+// RecordFailure records a failed call
+func (cb *CircuitBreaker) RecordFailure(serviceID string, err error) error {
+	if err == nil {
+		err = fmt.Errorf("call to %s failed", serviceID)
+	}
+	cb.RecordCall(serviceID, 0, err)
+	return nil
+}
+
+// RecordCall records a call outcome with its duration, counting slow calls
+func (cb *CircuitBreaker) RecordCall(serviceID string, duration time.Duration, err error) {
+	circuit := cb.circuit(serviceID)
+
+	circuit.mutex.Lock()
+	from := circuit.state
+	circuit.record(time.Now(), duration, err != nil)
+	to := circuit.state
+	circuit.mutex.Unlock()
+
+	cb.notify(serviceID, from, to)
+}
+
+// GetCircuitMetrics returns metrics for a service circuit
+func (cb *CircuitBreaker) GetCircuitMetrics(serviceID string) (*CircuitMetrics, error) {
+	cb.mutex.RLock()
+	circuit, exists := cb.circuits[serviceID]
+	cb.mutex.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("no circuit for service %s", serviceID)
+	}
+
+	return circuit.metrics(serviceID), nil
+}
+
+// GetAllCircuitMetrics returns metrics for every tracked service
+func (cb *CircuitBreaker) GetAllCircuitMetrics() map[string]*CircuitMetrics {
+	cb.mutex.RLock()
+	circuits := make(map[string]*serviceCircuit, len(cb.circuits))
+	for serviceID, circuit := range cb.circuits {
+		circuits[serviceID] = circuit
+	}
+	cb.mutex.RUnlock()
+
+	metrics := make(map[string]*CircuitMetrics, len(circuits))
+	for serviceID, circuit := range circuits {
+		metrics[serviceID] = circuit.metrics(serviceID)
+	}
+	return metrics
+}
+
+// Guard wraps a connection so its requests pass through the service circuit
+func (cb *CircuitBreaker) Guard(serviceID string, conn Connection) Connection {
+	return &guardedConnection{
+		Connection: conn,
+		breaker:    cb,
+		serviceID:  serviceID,
+	}
+}
+
+func (cb *CircuitBreaker) circuit(serviceID string) *serviceCircuit {
+	cb.mutex.RLock()
+	circuit, exists := cb.circuits[serviceID]
+	cb.mutex.RUnlock()
+	if exists {
+		return circuit
+	}
+
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	if circuit, exists := cb.circuits[serviceID]; exists {
+		return circuit
+	}
+
+	config := cb.config
+	if serviceConfig, exists := cb.serviceConfigs[serviceID]; exists {
+		config = serviceConfig
+	}
+
+	circuit = newServiceCircuit(config)
+	cb.circuits[serviceID] = circuit
+	return circuit
+}
+
+func (cb *CircuitBreaker) notify(serviceID string, from, to BreakerState) {
+	if from == to {
+		return
+	}
+
+	cb.mutex.RLock()
+	callbacks := cb.onStateChange
+	cb.mutex.RUnlock()
+
+	for _, callback := range callbacks {
+		callback(serviceID, from, to)
+	}
+}
+
+func newServiceCircuit(config *CircuitBreakerConfig) *serviceCircuit {
+	windowSize := config.WindowSize
+	if windowSize <= 0 {
+		windowSize = 1
+	}
+
+	return &serviceCircuit{
+		config:          config,
+		state:           CircuitClosed,
+		lastStateChange: time.Now(),
+		outcomes:        make([]callOutcome, windowSize),
+	}
+}
+
+// tryAcquire decides whether a call may proceed; callers must hold the mutex
+func (sc *serviceCircuit) tryAcquire(now time.Time) bool {
+	if sc.state == CircuitOpen && now.Sub(sc.openedAt) >= sc.config.OpenTimeout {
+		sc.transition(CircuitHalfOpen, now)
+	}
+
+	switch sc.state {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		if sc.trialsIssued < sc.halfOpenMaxCalls() {
+			sc.trialsIssued++
+			return true
+		}
+	}
+
+	sc.rejectedCalls++
+	return false
+}
+
+// record adds a call outcome and re-evaluates the state; callers must hold the mutex
+func (sc *serviceCircuit) record(now time.Time, duration time.Duration, failed bool) {
+	slow := sc.config.SlowCallThreshold > 0 && duration >= sc.config.SlowCallThreshold
+
+	sc.totalCalls++
+	if failed {
+		sc.failedCalls++
+	} else {
+		sc.successfulCalls++
+	}
+	if slow {
+		sc.slowCalls++
+	}
+
+	switch sc.state {
+	case CircuitHalfOpen:
+		if failed || slow {
+			sc.transition(CircuitOpen, now)
+			return
+		}
+		sc.trialsSucceeded++
+		if sc.trialsSucceeded >= sc.halfOpenMaxCalls() {
+			sc.transition(CircuitClosed, now)
+		}
+		return
+	case CircuitOpen:
+		return // Late results from calls admitted before opening
+	}
+
+	sc.push(callOutcome{failed: failed, slow: slow})
+
+	if sc.filled < sc.config.MinimumCalls {
+		return
+	}
+
+	failureRate, slowRate := sc.rates()
+	if (sc.config.FailureRateThreshold > 0 && failureRate >= sc.config.FailureRateThreshold) ||
+		(sc.config.SlowCallRateThreshold > 0 && slowRate >= sc.config.SlowCallRateThreshold) {
+		sc.transition(CircuitOpen, now)
+	}
+}
+
+// push adds an outcome to the sliding window, evicting the oldest
+func (sc *serviceCircuit) push(outcome callOutcome) {
+	if sc.filled == len(sc.outcomes) {
+		evicted := sc.outcomes[sc.cursor]
+		if evicted.failed {
+			sc.failures--
+		}
+		if evicted.slow {
+			sc.slow--
+		}
+	} else {
+		sc.filled++
+	}
+
+	sc.outcomes[sc.cursor] = outcome
+	sc.cursor = (sc.cursor + 1) % len(sc.outcomes)
+
+	if outcome.failed {
+		sc.failures++
+	}
+	if outcome.slow {
+		sc.slow++
+	}
+}
+
+func (sc *serviceCircuit) rates() (failureRate, slowRate float64) {
+	if sc.filled == 0 {
+		return 0, 0
+	}
+	return float64(sc.failures) / float64(sc.filled), float64(sc.slow) / float64(sc.filled)
+}
+
+// transition moves the circuit to a new state; callers must hold the mutex
+func (sc *serviceCircuit) transition(to BreakerState, now time.Time) {
+	if sc.state == to {
+		return
+	}
+
+	sc.state = to
+	sc.lastStateChange = now
+	sc.stateTransitions++
+	sc.trialsIssued = 0
+	sc.trialsSucceeded = 0
+
+	switch to {
+	case CircuitOpen:
+		sc.openedAt = now
+	case CircuitClosed:
+		// Start from a clean window so stale failures cannot reopen the circuit
+		sc.cursor, sc.filled, sc.failures, sc.slow = 0, 0, 0, 0
+	}
+}
+
+func (sc *serviceCircuit) halfOpenMaxCalls() int {
+	if sc.config.HalfOpenMaxCalls <= 0 {
+		return 1
+	}
+	return sc.config.HalfOpenMaxCalls
+}
+
+// snapshotState builds a CircuitState; callers must hold the mutex
+func (sc *serviceCircuit) snapshotState(serviceID string, now time.Time) *CircuitState {
+	failureRate, slowRate := sc.rates()
+
+	state := &CircuitState{
+		ServiceID:    serviceID,
+		State:        sc.state,
+		FailureRate:  failureRate,
+		SlowCallRate: slowRate,
+	}
+
+	if sc.state == CircuitOpen {
+		state.OpenedAt = sc.openedAt
+		if remaining := sc.config.OpenTimeout - now.Sub(sc.openedAt); remaining > 0 {
+			state.RetryAfter = remaining
+		}
+	}
+
+	return state
+}
+
+func (sc *serviceCircuit) metrics(serviceID string) *CircuitMetrics {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	failureRate, slowRate := sc.rates()
+
+	return &CircuitMetrics{
+		ServiceID:        serviceID,
+		State:            sc.state,
+		TotalCalls:       sc.totalCalls,
+		SuccessfulCalls:  sc.successfulCalls,
+		FailedCalls:      sc.failedCalls,
+		SlowCalls:        sc.slowCalls,
+		RejectedCalls:    sc.rejectedCalls,
+		WindowCalls:      sc.filled,
+		FailureRate:      failureRate,
+		SlowCallRate:     slowRate,
+		StateTransitions: sc.stateTransitions,
+		LastStateChange:  sc.lastStateChange,
+	}
+}
+
+// guardedConnection routes requests through a circuit breaker
+type guardedConnection struct {
+	Connection
+	breaker   *CircuitBreaker
+	serviceID string
+}
+
+// Execute runs the request if the circuit allows it and records the outcome.
+// Transport errors and 5xx responses count as failures.
+func (gc *guardedConnection) Execute(request *Request) (*Response, error) {
+	if err := gc.breaker.Allow(gc.serviceID); err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+	response, err := gc.Connection.Execute(request)
+
+	outcome := err
+	if outcome == nil && response != nil && response.StatusCode >= 500 {
+		outcome = fmt.Errorf("service %s returned status %d", gc.serviceID, response.StatusCode)
+	}
+	gc.breaker.RecordCall(gc.serviceID, time.Since(startTime), outcome)
+
+	return response, err
+}
+
+// ExecuteAsync executes a guarded request in the background
+func (gc *guardedConnection) ExecuteAsync(request *Request) (<-chan *Response, <-chan error) {
+	respChan := make(chan *Response, 1)
+	errChan := make(chan error, 1)
+
+	go func() {
+		resp, err := gc.Execute(request)
+		if err != nil {
+			errChan <- err
+		} else {
+			respChan <- resp
+		}
+		close(respChan)
+		close(errChan)
+	}()
+
+	return respChan, errChan
+}
+
+// DefaultCircuitBreakerConfig returns default circuit breaker configuration
+func DefaultCircuitBreakerConfig() *CircuitBreakerConfig {
+	return &CircuitBreakerConfig{
+		WindowSize:            100,
+		MinimumCalls:          20,
+		FailureRateThreshold:  0.5,
+		SlowCallRateThreshold: 0.8,
+		SlowCallThreshold:     2 * time.Second,
+		OpenTimeout:           30 * time.Second,
+		HalfOpenMaxCalls:      5,
+	}
+}
@@ -0,0 +1,165 @@
+package integration
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// breakerTestConfig opens on half of four calls failing and recovers after
+// a short timeout with two trials
+func breakerTestConfig() *CircuitBreakerConfig {
+	return &CircuitBreakerConfig{
+		WindowSize:           4,
+		MinimumCalls:         4,
+		FailureRateThreshold: 0.5,
+		OpenTimeout:          10 * time.Millisecond,
+		HalfOpenMaxCalls:     2,
+	}
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	cb := NewCircuitBreaker(breakerTestConfig())
+	var transitions []BreakerState
+	cb.OnStateChange(func(serviceID string, from, to BreakerState) {
+		transitions = append(transitions, to)
+	})
+
+	// Below the minimum calls the circuit stays closed however bad the rate
+	failure := errors.New("unavailable")
+	cb.RecordFailure("svc", failure)
+	cb.RecordFailure("svc", failure)
+	cb.RecordSuccess("svc")
+	if state, _ := cb.CheckCircuit("svc"); state.State != CircuitClosed || !state.AllowRequest {
+		t.Fatalf("state %s after 3 calls, want closed", state.State)
+	}
+
+	cb.RecordSuccess("svc")
+	state, _ := cb.CheckCircuit("svc")
+	if state.State != CircuitOpen || state.AllowRequest || state.RetryAfter <= 0 {
+		t.Fatalf("state %+v after 2 of 4 calls failed, want open and rejecting", state)
+	}
+	if err := cb.Allow("svc"); err == nil {
+		t.Fatal("open circuit allowed a call")
+	}
+
+	// After the timeout a limited number of trials pass; their success closes
+	// the circuit
+	time.Sleep(15 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if err := cb.Allow("svc"); err != nil {
+			t.Fatalf("trial %d: %v", i, err)
+		}
+	}
+	if err := cb.Allow("svc"); err == nil {
+		t.Fatal("half-open circuit allowed more trials than configured")
+	}
+	cb.RecordSuccess("svc")
+	cb.RecordSuccess("svc")
+
+	metrics, err := cb.GetCircuitMetrics("svc")
+	if err != nil {
+		t.Fatalf("GetCircuitMetrics: %v", err)
+	}
+	if metrics.State != CircuitClosed || metrics.WindowCalls != 0 || metrics.RejectedCalls != 3 {
+		t.Errorf("metrics %+v, want closed with a clean window and 3 rejections", metrics)
+	}
+	want := []BreakerState{CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("transition %d to %s, want %s", i, transitions[i], want[i])
+		}
+	}
+}
+
+func TestCircuitBreakerFailedTrialReopens(t *testing.T) {
+	config := breakerTestConfig()
+	config.MinimumCalls, config.WindowSize = 1, 1
+	cb := NewCircuitBreaker(config)
+
+	cb.RecordFailure("svc", nil)
+	time.Sleep(15 * time.Millisecond)
+	if err := cb.Allow("svc"); err != nil {
+		t.Fatalf("trial refused: %v", err)
+	}
+	cb.RecordFailure("svc", nil)
+	if state, _ := cb.CheckCircuit("svc"); state.State != CircuitOpen {
+		t.Errorf("state %s after a failed trial, want open", state.State)
+	}
+}
+
+func TestCircuitBreakerSlidingWindow(t *testing.T) {
+	cb := NewCircuitBreaker(breakerTestConfig())
+
+	// Old failures leave the window as newer successes arrive
+	cb.RecordFailure("svc", nil)
+	for i := 0; i < 4; i++ {
+		cb.RecordSuccess("svc")
+	}
+	cb.RecordFailure("svc", nil)
+	metrics, _ := cb.GetCircuitMetrics("svc")
+	if metrics.State != CircuitClosed || metrics.FailureRate != 0.25 || metrics.FailedCalls != 2 {
+		t.Errorf("metrics %+v, want closed at a 0.25 failure rate over 2 failures", metrics)
+	}
+}
+
+func TestCircuitBreakerSlowCalls(t *testing.T) {
+	config := breakerTestConfig()
+	config.FailureRateThreshold = 0
+	config.SlowCallThreshold = 100 * time.Millisecond
+	config.SlowCallRateThreshold = 0.75
+	cb := NewCircuitBreaker(config)
+
+	for _, duration := range []time.Duration{200, 50, 300, 150} {
+		cb.RecordCall("svc", duration*time.Millisecond, nil)
+	}
+	metrics, _ := cb.GetCircuitMetrics("svc")
+	if metrics.State != CircuitOpen || metrics.SlowCalls != 3 {
+		t.Errorf("metrics %+v, want open after 3 slow calls of 4", metrics)
+	}
+}
+
+func TestCircuitBreakerServiceConfig(t *testing.T) {
+	cb := NewCircuitBreaker(breakerTestConfig())
+	strict := breakerTestConfig()
+	strict.MinimumCalls, strict.WindowSize = 1, 1
+	cb.SetServiceConfig("strict", strict)
+
+	cb.RecordFailure("strict", nil)
+	cb.RecordFailure("lenient", nil)
+	all := cb.GetAllCircuitMetrics()
+	if all["strict"].State != CircuitOpen || all["lenient"].State != CircuitClosed {
+		t.Errorf("strict %s, lenient %s; want only strict open", all["strict"].State, all["lenient"].State)
+	}
+	if _, err := cb.GetCircuitMetrics("unknown"); err == nil {
+		t.Error("metrics returned for a service never seen")
+	}
+}
+
+func TestCircuitBreakerGuard(t *testing.T) {
+	config := breakerTestConfig()
+	config.MinimumCalls, config.WindowSize = 2, 2
+	cb := NewCircuitBreaker(config)
+	conn := cb.Guard("svc", newScriptedConnection(func(int, *Request) (*Response, error) {
+		return &Response{StatusCode: 503}, nil
+	}))
+
+	// Server errors count as failures until the circuit rejects requests
+	// without reaching the connection
+	for i := 0; i < 2; i++ {
+		if response, err := conn.Execute(&Request{}); err != nil || response.StatusCode != 503 {
+			t.Fatalf("call %d = %v, %v", i, response, err)
+		}
+	}
+	_, err := conn.Execute(&Request{})
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) || transportErr.Code != ErrorCodeServerUnavailable {
+		t.Errorf("call through an open circuit returned %v", err)
+	}
+	if metrics, _ := cb.GetCircuitMetrics("svc"); metrics.TotalCalls != 2 || metrics.RejectedCalls != 1 {
+		t.Errorf("metrics %+v, want 2 calls and 1 rejection", metrics)
+	}
+}
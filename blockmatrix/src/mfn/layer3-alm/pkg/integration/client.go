@@ -0,0 +1,355 @@
+// Package integration implements end-to-end request execution over ALM routes
+package integration
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+	"go.uber.org/zap"
+)
+
+// Client executes requests against HyperMesh services by name, combining the
+// integration components that would otherwise be wired by hand:
+//
+//  1. service discovery and ALM route lookup to each candidate endpoint
+//  2. endpoint selection by route quality
+//  3. circuit breaker check with health prediction
+//  4. connection acquisition from the pool
+//  5. execution with retries
+//  6. feedback of the observed latency and outcome into the routing table
+//     and circuit breaker
+type Client struct {
+	integration *HyperMeshIntegration
+	pool        *ConnectionPool
+	retry       *RetryExecutor
+	config      *ClientConfig
+	logger      *zap.Logger
+
+	// Counters
+	requests  atomic.Int64
+	failures  atomic.Int64
+	rejected  atomic.Int64
+	fallbacks atomic.Int64
+}
+
+// ClientConfig configures a Client
+type ClientConfig struct {
+	// ALM node the client runs on; routes are looked up from this node
+	LocalNodeID int64
+
+	// Constraints applied to every route lookup; nil means unconstrained
+	Constraints *RoutingConstraints
+
+	// Endpoints whose routes are looked up per request
+	MaxCandidates int
+
+	// Algorithm used by the load balancer when no endpoint can be routed
+	LoadBalancingAlgorithm string
+
+	// Timeout applied to requests without their own Timeout
+	RequestTimeout time.Duration
+}
+
+// ClientStats summarizes client activity
+type ClientStats struct {
+	Requests  int64
+	Failures  int64
+	Rejected  int64
+	Fallbacks int64
+	Retry     RetryStats
+	Pool      ConnectionPoolStats
+}
+
+// clientTarget is the endpoint chosen for a request and the route to it
+type clientTarget struct {
+	endpoint *Endpoint
+	address  string
+	route    *internal.RouteResponse
+}
+
+// NewClient creates a client on top of an integration and connection pool.
+// A nil retry executor uses the default retry configuration. Hedging is gated
+// on the coordinator's feature flags unless the executor already has flags.
+func NewClient(integration *HyperMeshIntegration, pool *ConnectionPool, retry *RetryExecutor, config *ClientConfig, logger *zap.Logger) *Client {
+	if config == nil {
+		config = DefaultClientConfig()
+	}
+	if retry == nil {
+		retry = NewRetryExecutor(nil)
+	}
+	if retry.featureFlags.Load() == nil && integration != nil && integration.almCoordinator != nil {
+		retry.SetFeatureFlags(integration.almCoordinator.FeatureFlags())
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &Client{
+		integration: integration,
+		pool:        pool,
+		retry:       retry,
+		config:      config,
+		logger:      logger,
+	}
+}
+
+// Do executes request against the service named serviceName
+func (c *Client) Do(ctx context.Context, serviceName string, request *Request) (*Response, error) {
+	c.requests.Add(1)
+
+	if request.Timeout <= 0 {
+		request.Timeout = c.config.RequestTimeout
+	}
+	if request.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, request.Timeout)
+		defer cancel()
+	}
+	request.Context = ctx
+
+	hmService, err := c.resolveService(ctx, serviceName)
+	if err != nil {
+		c.failures.Add(1)
+		return nil, err
+	}
+
+	target, err := c.selectTarget(ctx, hmService)
+	if err != nil {
+		c.failures.Add(1)
+		return nil, err
+	}
+
+	decision, err := c.integration.EnhanceCircuitBreaker(ctx, hmService.ID)
+	if err != nil {
+		c.failures.Add(1)
+		return nil, err
+	}
+	if decision.Action != CircuitActionAllow {
+		c.rejected.Add(1)
+		return nil, &TransportError{
+			Code:      ErrorCodeServerUnavailable,
+			Message:   fmt.Sprintf("request to %s rejected by circuit breaker (%s): %s", serviceName, decision.Action, decision.Reason),
+			Retryable: false,
+			Temporary: true,
+		}
+	}
+
+	// Count the request towards its route's path load while it runs
+	carried := int64(0)
+	if target.route != nil {
+		endPathRequest := c.integration.almCoordinator.RoutingTable().BeginPathRequest(target.route.Path, target.route.MinThroughput)
+		defer func() { endPathRequest(carried) }()
+	}
+
+	startTime := time.Now()
+
+	conn, release, err := c.pool.Acquire(ctx, target.address)
+	if err != nil {
+		c.failures.Add(1)
+		c.feedback(hmService.ID, target, time.Since(startTime), err)
+		return nil, err
+	}
+
+	response, err := c.retry.Execute(conn, request)
+	release()
+	carried = int64(len(request.Body))
+	if response != nil {
+		carried += int64(len(response.Body))
+	}
+
+	// Server errors count against the route and circuit but are returned as responses
+	outcome := err
+	if err == nil && response.StatusCode >= 500 {
+		outcome = fmt.Errorf("%s responded with status %d", serviceName, response.StatusCode)
+	}
+
+	c.feedback(hmService.ID, target, time.Since(startTime), outcome)
+	if outcome != nil {
+		c.failures.Add(1)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// Stats returns client statistics
+func (c *Client) Stats() ClientStats {
+	return ClientStats{
+		Requests:  c.requests.Load(),
+		Failures:  c.failures.Load(),
+		Rejected:  c.rejected.Load(),
+		Fallbacks: c.fallbacks.Load(),
+		Retry:     c.retry.Stats(),
+		Pool:      c.pool.Stats(),
+	}
+}
+
+// resolveService discovers the healthy instances of serviceName
+func (c *Client) resolveService(ctx context.Context, serviceName string) (*HyperMeshService, error) {
+	services, err := c.integration.EnhanceServiceDiscovery(ctx, &ServiceQuery{
+		ServiceName: serviceName,
+		HealthOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover service %s: %w", serviceName, err)
+	}
+
+	for _, hmService := range services {
+		if hmService.Name == serviceName && len(hmService.Endpoints) > 0 {
+			return hmService, nil
+		}
+	}
+	for _, hmService := range services {
+		if len(hmService.Endpoints) > 0 {
+			return hmService, nil
+		}
+	}
+
+	return nil, &TransportError{
+		Code:      ErrorCodeServerUnavailable,
+		Message:   fmt.Sprintf("no endpoints available for service %s", serviceName),
+		Retryable: true,
+		Temporary: true,
+	}
+}
+
+// selectTarget looks up the ALM route to each candidate endpoint and picks
+// the endpoint with the best route quality, preferring less loaded endpoints
+// on ties. When no endpoint can be routed the load balancer chooses instead.
+func (c *Client) selectTarget(ctx context.Context, hmService *HyperMeshService) (*clientTarget, error) {
+	candidates := make([]*clientTarget, 0, len(hmService.Endpoints))
+	routes := make(map[int64]*internal.RouteResponse)
+
+	for _, endpoint := range hmService.Endpoints {
+		if c.config.MaxCandidates > 0 && len(candidates) >= c.config.MaxCandidates {
+			break
+		}
+		if endpoint.NodeID == 0 {
+			continue
+		}
+
+		route, looked := routes[endpoint.NodeID]
+		if !looked {
+			var err error
+			route, err = c.lookupRoute(ctx, hmService.Name, endpoint.NodeID)
+			if err != nil {
+				c.logger.Debug("Route lookup failed",
+					zap.Error(err),
+					zap.String("service", hmService.Name),
+					zap.Int64("node_id", endpoint.NodeID),
+				)
+			}
+			routes[endpoint.NodeID] = route
+		}
+		if route == nil {
+			continue
+		}
+
+		candidates = append(candidates, &clientTarget{
+			endpoint: endpoint,
+			address:  endpointAddress(endpoint),
+			route:    route,
+		})
+	}
+
+	if len(candidates) == 0 {
+		c.fallbacks.Add(1)
+
+		endpoint, err := c.integration.EnhanceLoadBalancing(ctx, hmService.ID, c.config.LoadBalancingAlgorithm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to select endpoint for service %s: %w", hmService.Name, err)
+		}
+		return &clientTarget{endpoint: endpoint, address: endpointAddress(endpoint)}, nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].route.QualityScore != candidates[j].route.QualityScore {
+			return candidates[i].route.QualityScore > candidates[j].route.QualityScore
+		}
+		return activeConnections(candidates[i].endpoint) < activeConnections(candidates[j].endpoint)
+	})
+
+	return candidates[0], nil
+}
+
+// lookupRoute finds the ALM route from the local node to nodeID
+func (c *Client) lookupRoute(ctx context.Context, serviceName string, nodeID int64) (*internal.RouteResponse, error) {
+	routeReq := internal.RouteRequest{
+		SourceID:      c.config.LocalNodeID,
+		DestinationID: nodeID,
+		ServiceType:   serviceName,
+	}
+	if constraints := c.config.Constraints; constraints != nil {
+		if constraints.ServiceType != "" {
+			routeReq.ServiceType = constraints.ServiceType
+		}
+		routeReq.QoSClass = constraints.QoSClass
+		routeReq.MaxLatency = constraints.MaxLatency
+		routeReq.MinThroughput = constraints.MinThroughput
+		routeReq.MinReliability = constraints.MinReliability
+		routeReq.MaxCost = constraints.MaxCost
+		routeReq.MaxHops = constraints.MaxHops
+	}
+
+	return c.integration.almCoordinator.FindOptimalRoute(ctx, routeReq)
+}
+
+// feedback reports the request outcome to the circuit breaker and, for
+// routed requests, the observed route performance to the routing table
+func (c *Client) feedback(serviceID string, target *clientTarget, latency time.Duration, err error) {
+	switch breaker := c.integration.circuitBreaker.(type) {
+	case *CircuitBreaker:
+		breaker.RecordCall(serviceID, latency, err)
+	default:
+		if err != nil {
+			breaker.RecordFailure(serviceID, err)
+		} else {
+			breaker.RecordSuccess(serviceID)
+		}
+	}
+
+	if target.route == nil {
+		return
+	}
+
+	reliability := 1.0
+	if err != nil {
+		reliability = 0.0
+	}
+
+	c.integration.almCoordinator.RoutingTable().UpdateRouteMetrics(target.endpoint.NodeID, routing.RouteMetrics{
+		Latency:     latency,
+		Throughput:  target.route.MinThroughput,
+		Reliability: reliability,
+		Cost:        target.route.TotalCost,
+		HopCount:    target.route.HopCount,
+	}, err == nil)
+}
+
+// endpointAddress returns the "host:port" transport address of an endpoint
+func endpointAddress(endpoint *Endpoint) string {
+	return transportAddress(endpoint.Address, endpoint.Port)
+}
+
+func activeConnections(endpoint *Endpoint) int32 {
+	if endpoint.Metrics == nil {
+		return 0
+	}
+	return endpoint.Metrics.ActiveConnections
+}
+
+// DefaultClientConfig returns default client configuration
+func DefaultClientConfig() *ClientConfig {
+	return &ClientConfig{
+		MaxCandidates:          8,
+		LoadBalancingAlgorithm: "alm-optimized",
+		RequestTimeout:         30 * time.Second,
+	}
+}
@@ -0,0 +1,183 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+)
+
+// scriptedTransport dials connections answered by respond and records the
+// addresses dialed
+type scriptedTransport struct {
+	MockHyperMeshTransport
+	respond func(call int, request *Request) (*Response, error)
+
+	mutex  sync.Mutex
+	dialed []string
+}
+
+func (st *scriptedTransport) Connect(config *TransportConfig) (Connection, error) {
+	st.mutex.Lock()
+	st.dialed = append(st.dialed, config.Address)
+	st.mutex.Unlock()
+
+	conn := newScriptedConnection(st.respond)
+	conn.remoteAddress = config.Address
+	return conn, nil
+}
+
+func (st *scriptedTransport) dialedAddresses() []string {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	return append([]string(nil), st.dialed...)
+}
+
+// newTestClient returns a client on node 1 of a graph where node 2 is a
+// lower latency route than node 3, with the "orders" service on both. A nil breaker
+// uses the default configuration.
+func newTestClient(t *testing.T, transport *scriptedTransport, breaker *CircuitBreaker) *Client {
+	t.Helper()
+
+	if breaker == nil {
+		breaker = NewCircuitBreaker(nil)
+	}
+
+	config := internal.DefaultALMConfig()
+	config.MaxNodes = 100
+	config.MaxEdges = 1000
+	coordinator, err := internal.NewALMCoordinator(config, nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+
+	for _, id := range []int64{1, 2, 3} {
+		if err := coordinator.NetworkGraph().AddNode(&graph.NetworkNode{ID: id, Region: "eu-west"}); err != nil {
+			t.Fatalf("AddNode(%d): %v", id, err)
+		}
+	}
+	for _, edge := range []*graph.NetworkEdge{
+		{From: 1, To: 2, Weight: 1, Latency: time.Millisecond, Bandwidth: 1000, Reliability: 0.99},
+		{From: 1, To: 3, Weight: 5, Latency: 50 * time.Millisecond, Bandwidth: 100, Reliability: 0.9},
+	} {
+		if err := coordinator.NetworkGraph().AddEdge(edge); err != nil {
+			t.Fatalf("AddEdge(%d, %d): %v", edge.From, edge.To, err)
+		}
+	}
+
+	integration := NewHyperMeshIntegration(coordinator, nil, nil, breaker, nil, nil)
+	if err := integration.serviceDiscovery.RegisterService(&HyperMeshService{
+		ID:   "orders",
+		Name: "orders",
+		Endpoints: []*Endpoint{
+			{ID: "far", Address: "10.0.0.3", Port: 7000, NodeID: 3},
+			{ID: "near", Address: "10.0.0.2", Port: 7000, NodeID: 2},
+		},
+	}); err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+
+	pool := NewConnectionPool(transport, nil, &ConnectionPoolConfig{MaxConnectionsPerNode: 1, IdleTimeout: time.Hour})
+	t.Cleanup(func() { pool.Close() })
+
+	return NewClient(integration, pool, NewRetryExecutor(retryTestConfig()), &ClientConfig{
+		LocalNodeID:    1,
+		Constraints:    &RoutingConstraints{QoSClass: int(routing.LowLatency)},
+		MaxCandidates:  8,
+		RequestTimeout: time.Second,
+	}, nil)
+}
+
+func TestClientDoUsesBestRoutedEndpoint(t *testing.T) {
+	transport := &scriptedTransport{respond: func(int, *Request) (*Response, error) {
+		return &Response{StatusCode: 200}, nil
+	}}
+	client := newTestClient(t, transport, nil)
+
+	response, err := client.Do(context.Background(), "orders", &Request{Method: "GET", Path: "/orders"})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Do = %v, %v; want 200", response, err)
+	}
+	if dialed := transport.dialedAddresses(); len(dialed) != 1 || dialed[0] != "10.0.0.2:7000" {
+		t.Errorf("dialed %v, want the endpoint on node 2", dialed)
+	}
+
+	stats := client.Stats()
+	if stats.Requests != 1 || stats.Failures != 0 || stats.Fallbacks != 0 {
+		t.Errorf("stats %+v, want one routed request without failures", stats)
+	}
+}
+
+func TestClientDoRetriesUnavailable(t *testing.T) {
+	transport := &scriptedTransport{respond: func(call int, request *Request) (*Response, error) {
+		if call < 3 {
+			return &Response{StatusCode: 503}, nil
+		}
+		return &Response{StatusCode: 200}, nil
+	}}
+	client := newTestClient(t, transport, nil)
+
+	response, err := client.Do(context.Background(), "orders", &Request{Method: "GET", Path: "/orders"})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Do = %v, %v; want 200 on the third attempt", response, err)
+	}
+	if stats := client.Stats(); stats.Retry.Retries != 2 || stats.Failures != 0 {
+		t.Errorf("stats %+v, want 2 retries and no failures", stats)
+	}
+}
+
+func TestClientDoRejectsOpenCircuit(t *testing.T) {
+	unavailable := errors.New("connection reset")
+	transport := &scriptedTransport{respond: func(int, *Request) (*Response, error) {
+		return nil, unavailable
+	}}
+	config := breakerTestConfig()
+	config.OpenTimeout = time.Hour
+	client := newTestClient(t, transport, NewCircuitBreaker(config))
+
+	// Failed requests are fed back to the breaker until it opens
+	var err error
+	for i := 0; i < 10; i++ {
+		if _, err = client.Do(context.Background(), "orders", &Request{Method: "GET"}); err == nil {
+			t.Fatalf("request %d succeeded", i)
+		}
+		if client.Stats().Rejected > 0 {
+			break
+		}
+	}
+
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) || transportErr.Code != ErrorCodeServerUnavailable {
+		t.Fatalf("Do with an open circuit = %v, want a server unavailable error", err)
+	}
+	stats := client.Stats()
+	if stats.Rejected != 1 || stats.Failures != stats.Requests-1 {
+		t.Errorf("stats %+v, want every request but the rejected one counted as failed", stats)
+	}
+
+	// Rejected requests never reach the pool
+	dials := len(transport.dialedAddresses())
+	if _, err := client.Do(context.Background(), "orders", &Request{Method: "GET"}); err == nil {
+		t.Fatal("request succeeded with an open circuit")
+	}
+	if got := len(transport.dialedAddresses()); got != dials {
+		t.Errorf("rejected request dialed %d new connections", got-dials)
+	}
+}
+
+func TestClientDoUnknownService(t *testing.T) {
+	transport := &scriptedTransport{}
+	client := newTestClient(t, transport, nil)
+
+	if _, err := client.Do(context.Background(), "billing", &Request{Method: "GET"}); err == nil {
+		t.Fatal("Do succeeded for a service with no endpoints")
+	}
+	if stats := client.Stats(); stats.Failures != 1 || len(transport.dialedAddresses()) != 0 {
+		t.Errorf("stats %+v after dialing %v, want one failure without a dial", stats, transport.dialedAddresses())
+	}
+}
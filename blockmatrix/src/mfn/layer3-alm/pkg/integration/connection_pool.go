@@ -0,0 +1,614 @@
+// Package integration implements connection pooling for HyperMesh transports
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errNodeRetired signals that a node pool was dropped by maintenance and must be looked up again
+var errNodeRetired = errors.New("node pool retired")
+
+// ConnectionPool keeps warm connections to remote nodes so requests, such as
+// those following an OptimizeRouting decision, do not pay dial latency.
+//
+// Idle connections are probed with Ping and reaped after IdleTimeout, and the
+// number of concurrent requests per destination is bounded.
+type ConnectionPool struct {
+	transport  HyperMeshTransport
+	baseConfig *TransportConfig
+	config     *ConnectionPoolConfig
+
+	// Per-destination pools keyed by "host:port"
+	nodes map[string]*nodePool
+	mutex sync.RWMutex
+
+	// Counters
+	dials         atomic.Int64
+	dialFailures  atomic.Int64
+	reuses        atomic.Int64
+	reaped        atomic.Int64
+	evicted       atomic.Int64
+	rejected      atomic.Int64
+	probeFailures atomic.Int64
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+	closed   atomic.Bool
+}
+
+// ConnectionPoolConfig configures a ConnectionPool
+type ConnectionPoolConfig struct {
+	// Connections kept per destination
+	MaxConnectionsPerNode int
+
+	// Concurrent requests allowed per destination; 0 means unlimited
+	MaxConcurrentRequests int
+	// How long to wait for a request slot before failing
+	AcquireTimeout time.Duration
+
+	// Idle connections are reaped after IdleTimeout; 0 uses the transport IdleTimeout
+	IdleTimeout         time.Duration
+	HealthCheckInterval time.Duration
+	PingTimeout         time.Duration
+}
+
+// ConnectionPoolStats summarizes pool state and activity
+type ConnectionPoolStats struct {
+	Nodes            int
+	OpenConnections  int
+	InFlightRequests int
+	Dials            int64
+	DialFailures     int64
+	Reuses           int64
+	Reaped           int64
+	Evicted          int64
+	Rejected         int64
+	ProbeFailures    int64
+}
+
+// nodePool holds the connections to a single destination
+type nodePool struct {
+	address     string
+	connections []*pooledConnection
+	dialing     int
+	retired     bool
+	slots       chan struct{}
+	mutex       sync.Mutex
+}
+
+// pooledConnection tracks usage of a pooled connection
+type pooledConnection struct {
+	conn     Connection
+	inFlight int
+	lastUsed time.Time
+	probing  bool
+}
+
+// NewConnectionPool creates a connection pool on top of a transport.
+// baseConfig supplies everything except the destination address.
+func NewConnectionPool(transport HyperMeshTransport, baseConfig *TransportConfig, config *ConnectionPoolConfig) *ConnectionPool {
+	if baseConfig == nil {
+		baseConfig = DefaultTransportConfig()
+	}
+	if config == nil {
+		config = DefaultConnectionPoolConfig()
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = baseConfig.IdleTimeout
+	}
+
+	pool := &ConnectionPool{
+		transport:  transport,
+		baseConfig: baseConfig,
+		config:     config,
+		nodes:      make(map[string]*nodePool),
+		stopChan:   make(chan struct{}),
+	}
+
+	if config.HealthCheckInterval > 0 {
+		pool.wg.Add(1)
+		go pool.maintenanceLoop()
+	}
+
+	return pool
+}
+
+// Execute runs a request against address using a pooled connection
+func (cp *ConnectionPool) Execute(ctx context.Context, address string, request *Request) (*Response, error) {
+	conn, release, err := cp.Acquire(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if request.Context == nil {
+		request.Context = ctx
+	}
+
+	return conn.Execute(request)
+}
+
+// Acquire returns a pooled connection to address together with a release
+// function that must be called once the caller is done with it
+func (cp *ConnectionPool) Acquire(ctx context.Context, address string) (Connection, func(), error) {
+	if cp.closed.Load() {
+		return nil, nil, &TransportError{Code: ErrorCodeConnectionClosed, Message: "connection pool is closed"}
+	}
+
+	var node *nodePool
+	var pooled *pooledConnection
+	for pooled == nil {
+		node = cp.node(address)
+
+		if err := cp.acquireSlot(ctx, node); err != nil {
+			return nil, nil, err
+		}
+
+		var err error
+		pooled, err = cp.connection(node)
+		if err != nil {
+			node.releaseSlot()
+			if err == errNodeRetired {
+				continue
+			}
+			return nil, nil, err
+		}
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			node.mutex.Lock()
+			pooled.inFlight--
+			pooled.lastUsed = time.Now()
+			node.mutex.Unlock()
+			node.releaseSlot()
+		})
+	}
+
+	return pooled.conn, release, nil
+}
+
+// Ping probes a pooled connection to address, dialing one if needed
+func (cp *ConnectionPool) Ping(ctx context.Context, address string) error {
+	conn, release, err := cp.Acquire(ctx, address)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return cp.ping(conn)
+}
+
+// Warm dials connections to the given addresses ahead of use
+func (cp *ConnectionPool) Warm(addresses ...string) error {
+	var firstErr error
+	for _, address := range addresses {
+		node := cp.node(address)
+
+		node.mutex.Lock()
+		hasConnection := len(node.connections) > 0
+		node.mutex.Unlock()
+		if hasConnection {
+			continue
+		}
+
+		pooled, err := cp.connection(node)
+		if err == errNodeRetired {
+			node = cp.node(address)
+			pooled, err = cp.connection(node)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		node.mutex.Lock()
+		pooled.inFlight--
+		node.mutex.Unlock()
+	}
+	return firstErr
+}
+
+// WarmRoute warms connections to the next hop of a routing decision and of its
+// alternative paths. resolve maps a service name to a dialable address.
+func (cp *ConnectionPool) WarmRoute(decision *RoutingDecision, resolve func(service string) (string, error)) error {
+	if decision == nil {
+		return fmt.Errorf("routing decision is required")
+	}
+
+	paths := [][]string{decision.SelectedPath}
+	for _, alternative := range decision.AlternativePaths {
+		paths = append(paths, alternative.Path)
+	}
+
+	seen := make(map[string]bool)
+	var addresses []string
+	for _, path := range paths {
+		if len(path) < 2 {
+			continue
+		}
+		address, err := resolve(path[1])
+		if err != nil {
+			return fmt.Errorf("failed to resolve next hop %s: %w", path[1], err)
+		}
+		if !seen[address] {
+			seen[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+
+	return cp.Warm(addresses...)
+}
+
+// Stats returns pool statistics
+func (cp *ConnectionPool) Stats() ConnectionPoolStats {
+	cp.mutex.RLock()
+	nodes := make([]*nodePool, 0, len(cp.nodes))
+	for _, node := range cp.nodes {
+		nodes = append(nodes, node)
+	}
+	cp.mutex.RUnlock()
+
+	stats := ConnectionPoolStats{
+		Nodes:         len(nodes),
+		Dials:         cp.dials.Load(),
+		DialFailures:  cp.dialFailures.Load(),
+		Reuses:        cp.reuses.Load(),
+		Reaped:        cp.reaped.Load(),
+		Evicted:       cp.evicted.Load(),
+		Rejected:      cp.rejected.Load(),
+		ProbeFailures: cp.probeFailures.Load(),
+	}
+
+	for _, node := range nodes {
+		node.mutex.Lock()
+		stats.OpenConnections += len(node.connections)
+		for _, pooled := range node.connections {
+			stats.InFlightRequests += pooled.inFlight
+		}
+		node.mutex.Unlock()
+	}
+
+	return stats
+}
+
+// NodeConnectionMetrics returns the metrics of the pooled connections to
+// address, or nil if there are none
+func (cp *ConnectionPool) NodeConnectionMetrics(address string) []ConnectionMetrics {
+	cp.mutex.RLock()
+	node, exists := cp.nodes[address]
+	cp.mutex.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	node.mutex.Lock()
+	connections := make([]Connection, 0, len(node.connections))
+	for _, pooled := range node.connections {
+		connections = append(connections, pooled.conn)
+	}
+	node.mutex.Unlock()
+
+	metrics := make([]ConnectionMetrics, 0, len(connections))
+	for _, conn := range connections {
+		metrics = append(metrics, conn.GetConnectionMetrics())
+	}
+	return metrics
+}
+
+// Close stops maintenance and closes all pooled connections
+func (cp *ConnectionPool) Close() error {
+	cp.stopOnce.Do(func() {
+		cp.closed.Store(true)
+		close(cp.stopChan)
+	})
+	cp.wg.Wait()
+
+	cp.mutex.Lock()
+	nodes := cp.nodes
+	cp.nodes = make(map[string]*nodePool)
+	cp.mutex.Unlock()
+
+	for _, node := range nodes {
+		node.mutex.Lock()
+		connections := node.connections
+		node.connections = nil
+		node.mutex.Unlock()
+
+		for _, pooled := range connections {
+			pooled.conn.Close()
+		}
+	}
+
+	return nil
+}
+
+// node returns the pool for address, creating it on first use
+func (cp *ConnectionPool) node(address string) *nodePool {
+	cp.mutex.RLock()
+	node, exists := cp.nodes[address]
+	cp.mutex.RUnlock()
+	if exists {
+		return node
+	}
+
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	if node, exists := cp.nodes[address]; exists {
+		return node
+	}
+
+	node = &nodePool{address: address}
+	if cp.config.MaxConcurrentRequests > 0 {
+		node.slots = make(chan struct{}, cp.config.MaxConcurrentRequests)
+	}
+	cp.nodes[address] = node
+
+	return node
+}
+
+// acquireSlot waits for a concurrency slot on node
+func (cp *ConnectionPool) acquireSlot(ctx context.Context, node *nodePool) error {
+	if node.slots == nil {
+		return nil
+	}
+
+	select {
+	case node.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if cp.config.AcquireTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cp.config.AcquireTimeout)
+		defer cancel()
+	}
+
+	select {
+	case node.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		cp.rejected.Add(1)
+		return &TransportError{
+			Code:      ErrorCodeResourceExhausted,
+			Message:   fmt.Sprintf("concurrency limit of %d reached for %s", cap(node.slots), node.address),
+			Cause:     ctx.Err(),
+			Retryable: true,
+			Temporary: true,
+		}
+	case <-cp.stopChan:
+		return &TransportError{Code: ErrorCodeConnectionClosed, Message: "connection pool is closed"}
+	}
+}
+
+func (np *nodePool) releaseSlot() {
+	if np.slots != nil {
+		<-np.slots
+	}
+}
+
+// connection picks the least loaded healthy connection, dialing a new one when
+// all are busy and the node is below its connection limit. The returned
+// connection has been marked in flight.
+func (cp *ConnectionPool) connection(node *nodePool) (*pooledConnection, error) {
+	node.mutex.Lock()
+
+	if node.retired {
+		node.mutex.Unlock()
+		return nil, errNodeRetired
+	}
+
+	var best *pooledConnection
+	for _, pooled := range node.connections {
+		if pooled.probing || !pooled.conn.IsHealthy() {
+			continue
+		}
+		if best == nil || pooled.inFlight < best.inFlight {
+			best = pooled
+		}
+	}
+
+	canDial := len(node.connections)+node.dialing < cp.config.MaxConnectionsPerNode
+	if best != nil && (best.inFlight == 0 || !canDial) {
+		best.inFlight++
+		best.lastUsed = time.Now()
+		node.mutex.Unlock()
+		cp.reuses.Add(1)
+		return best, nil
+	}
+
+	if !canDial && best == nil {
+		node.mutex.Unlock()
+		return nil, &TransportError{
+			Code:      ErrorCodeServerUnavailable,
+			Message:   fmt.Sprintf("no healthy connection to %s", node.address),
+			Retryable: true,
+			Temporary: true,
+		}
+	}
+
+	node.dialing++
+	node.mutex.Unlock()
+
+	conn, err := cp.dial(node.address)
+
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+	node.dialing--
+
+	if err != nil {
+		if best != nil {
+			// Fall back to sharing the busy connection
+			best.inFlight++
+			best.lastUsed = time.Now()
+			cp.reuses.Add(1)
+			return best, nil
+		}
+		return nil, err
+	}
+
+	pooled := &pooledConnection{
+		conn:     conn,
+		inFlight: 1,
+		lastUsed: time.Now(),
+	}
+	node.connections = append(node.connections, pooled)
+
+	return pooled, nil
+}
+
+func (cp *ConnectionPool) dial(address string) (Connection, error) {
+	config := *cp.baseConfig
+	config.Address = address
+	config.Port = 0
+
+	cp.dials.Add(1)
+	conn, err := cp.transport.Connect(&config)
+	if err != nil {
+		cp.dialFailures.Add(1)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// maintenanceLoop periodically reaps idle connections and probes the rest
+func (cp *ConnectionPool) maintenanceLoop() {
+	defer cp.wg.Done()
+
+	ticker := time.NewTicker(cp.config.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cp.stopChan:
+			return
+		case <-ticker.C:
+			cp.maintain()
+		}
+	}
+}
+
+// maintain runs one reap and probe pass over all nodes
+func (cp *ConnectionPool) maintain() {
+	cp.mutex.RLock()
+	nodes := make([]*nodePool, 0, len(cp.nodes))
+	for _, node := range cp.nodes {
+		nodes = append(nodes, node)
+	}
+	cp.mutex.RUnlock()
+
+	for _, node := range nodes {
+		cp.maintainNode(node)
+	}
+
+	// Forget destinations that no longer hold connections
+	cp.mutex.Lock()
+	for address, node := range cp.nodes {
+		node.mutex.Lock()
+		if len(node.connections) == 0 && node.dialing == 0 {
+			node.retired = true
+			delete(cp.nodes, address)
+		}
+		node.mutex.Unlock()
+	}
+	cp.mutex.Unlock()
+}
+
+func (cp *ConnectionPool) maintainNode(node *nodePool) {
+	now := time.Now()
+
+	var toClose, toProbe []*pooledConnection
+
+	node.mutex.Lock()
+	kept := node.connections[:0]
+	for _, pooled := range node.connections {
+		switch {
+		case pooled.inFlight > 0 || pooled.probing:
+			kept = append(kept, pooled)
+		case cp.config.IdleTimeout > 0 && now.Sub(pooled.lastUsed) > cp.config.IdleTimeout:
+			toClose = append(toClose, pooled)
+			cp.reaped.Add(1)
+		case !pooled.conn.IsHealthy():
+			toClose = append(toClose, pooled)
+			cp.evicted.Add(1)
+		default:
+			pooled.probing = true
+			toProbe = append(toProbe, pooled)
+			kept = append(kept, pooled)
+		}
+	}
+	node.connections = kept
+	node.mutex.Unlock()
+
+	for _, pooled := range toClose {
+		pooled.conn.Close()
+	}
+
+	for _, pooled := range toProbe {
+		err := cp.ping(pooled.conn)
+
+		node.mutex.Lock()
+		pooled.probing = false
+		if err != nil {
+			cp.probeFailures.Add(1)
+			node.removeLocked(pooled)
+			cp.evicted.Add(1)
+		}
+		node.mutex.Unlock()
+
+		if err != nil {
+			pooled.conn.Close()
+		}
+	}
+}
+
+// ping probes a connection, bounded by PingTimeout
+func (cp *ConnectionPool) ping(conn Connection) error {
+	if cp.config.PingTimeout <= 0 {
+		return conn.Ping()
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- conn.Ping()
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(cp.config.PingTimeout):
+		return &TransportError{Code: ErrorCodeConnectionTimeout, Message: "ping timed out", Temporary: true}
+	}
+}
+
+// removeLocked drops a connection from the node; callers must hold the node mutex
+func (np *nodePool) removeLocked(target *pooledConnection) {
+	for i, pooled := range np.connections {
+		if pooled == target {
+			np.connections = append(np.connections[:i], np.connections[i+1:]...)
+			return
+		}
+	}
+}
+
+// DefaultConnectionPoolConfig returns default connection pool configuration
+func DefaultConnectionPoolConfig() *ConnectionPoolConfig {
+	return &ConnectionPoolConfig{
+		MaxConnectionsPerNode: 2,
+		MaxConcurrentRequests: 256,
+		AcquireTimeout:        time.Second,
+		HealthCheckInterval:   15 * time.Second,
+		PingTimeout:           2 * time.Second,
+	}
+}
@@ -0,0 +1,124 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConnectionPoolSharesConnectionsAtLimit(t *testing.T) {
+	pool := NewConnectionPool(&MockHyperMeshTransport{}, nil, &ConnectionPoolConfig{MaxConnectionsPerNode: 2, IdleTimeout: time.Hour})
+	defer pool.Close()
+
+	// Two requests in flight dial two connections; a third shares one
+	var releases []func()
+	for i := 0; i < 3; i++ {
+		_, release, err := pool.Acquire(context.Background(), "10.0.0.1:7000")
+		if err != nil {
+			t.Fatalf("Acquire %d: %v", i, err)
+		}
+		releases = append(releases, release)
+	}
+	if stats := pool.Stats(); stats.Dials != 2 || stats.Reuses != 1 || stats.InFlightRequests != 3 {
+		t.Fatalf("stats %+v, want 2 dials, 1 reuse and 3 in flight", stats)
+	}
+
+	for _, release := range releases {
+		release()
+		release()
+	}
+	if stats := pool.Stats(); stats.InFlightRequests != 0 {
+		t.Fatalf("%d requests in flight after releasing each twice", stats.InFlightRequests)
+	}
+
+	// Idle connections are reused rather than dialing again
+	_, release, err := pool.Acquire(context.Background(), "10.0.0.1:7000")
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	release()
+	if stats := pool.Stats(); stats.Dials != 2 || stats.OpenConnections != 2 {
+		t.Errorf("stats %+v, want the 2 open connections reused", stats)
+	}
+}
+
+func TestConnectionPoolLimitsConcurrentRequests(t *testing.T) {
+	pool := NewConnectionPool(&MockHyperMeshTransport{}, nil, &ConnectionPoolConfig{
+		MaxConnectionsPerNode: 1,
+		MaxConcurrentRequests: 1,
+		AcquireTimeout:        10 * time.Millisecond,
+	})
+	defer pool.Close()
+
+	_, release, err := pool.Acquire(context.Background(), "10.0.0.1:7000")
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	_, _, err = pool.Acquire(context.Background(), "10.0.0.1:7000")
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) || transportErr.Code != ErrorCodeResourceExhausted {
+		t.Fatalf("Acquire over the limit returned %v, want resource exhausted", err)
+	}
+	if rejected := pool.Stats().Rejected; rejected != 1 {
+		t.Errorf("rejected %d requests, want 1", rejected)
+	}
+
+	// The limit is per destination
+	_, other, err := pool.Acquire(context.Background(), "10.0.0.2:7000")
+	if err != nil {
+		t.Fatalf("Acquire for another destination: %v", err)
+	}
+	other()
+
+	release()
+	if _, release, err = pool.Acquire(context.Background(), "10.0.0.1:7000"); err != nil {
+		t.Fatalf("Acquire after release: %v", err)
+	}
+	release()
+}
+
+func TestConnectionPoolMaintenance(t *testing.T) {
+	pool := NewConnectionPool(&MockHyperMeshTransport{}, nil, &ConnectionPoolConfig{MaxConnectionsPerNode: 1, IdleTimeout: time.Minute})
+	defer pool.Close()
+
+	if err := pool.Warm("10.0.0.1:7000", "10.0.0.2:7000", "10.0.0.3:7000"); err != nil {
+		t.Fatalf("Warm: %v", err)
+	}
+
+	// The first destination's connection goes idle and the second's fails;
+	// the third stays healthy and passes its probe
+	idle := pool.node("10.0.0.1:7000").connections[0]
+	idle.lastUsed = time.Now().Add(-time.Hour)
+	failed := pool.node("10.0.0.2:7000").connections[0]
+	failed.conn.Close()
+
+	pool.maintain()
+
+	stats := pool.Stats()
+	if stats.Reaped != 1 || stats.Evicted != 1 || stats.OpenConnections != 1 || stats.Nodes != 1 {
+		t.Errorf("after maintenance: %+v, want 1 reaped, 1 evicted and 1 destination left", stats)
+	}
+	if idle.conn.IsHealthy() {
+		t.Error("reaped connection left open")
+	}
+}
+
+func TestConnectionPoolClosed(t *testing.T) {
+	pool := NewConnectionPool(&MockHyperMeshTransport{}, nil, nil)
+	if err := pool.Warm("10.0.0.1:7000"); err != nil {
+		t.Fatalf("Warm: %v", err)
+	}
+	conn := pool.node("10.0.0.1:7000").connections[0].conn
+	pool.Close()
+
+	_, _, err := pool.Acquire(context.Background(), "10.0.0.1:7000")
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) || transportErr.Code != ErrorCodeConnectionClosed {
+		t.Errorf("Acquire on a closed pool returned %v", err)
+	}
+	if conn.IsHealthy() {
+		t.Error("Close left a pooled connection open")
+	}
+}
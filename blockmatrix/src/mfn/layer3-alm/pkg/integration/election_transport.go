@@ -0,0 +1,133 @@
+// Package integration implements coordinator leader election over the HyperMesh transport
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+)
+
+// Election request paths served by ElectionHandler
+const (
+	electionVotePath      = "/alm/election/vote"
+	electionHeartbeatPath = "/alm/election/heartbeat"
+)
+
+// TransportElection implements internal.ElectionTransport over a
+// ConnectionPool. Peers are coordinator node IDs mapped to their transport
+// addresses; each peer serves the requests with ElectionHandler.
+type TransportElection struct {
+	pool  *ConnectionPool
+	peers map[string]string
+}
+
+// NewTransportElection creates an election transport sending to peers, a
+// map of node ID to address
+func NewTransportElection(pool *ConnectionPool, peers map[string]string) *TransportElection {
+	addresses := make(map[string]string, len(peers))
+	for nodeID, address := range peers {
+		addresses[nodeID] = address
+	}
+
+	return &TransportElection{
+		pool:  pool,
+		peers: addresses,
+	}
+}
+
+// Peers returns the peer node IDs, for use as ElectionConfig.Peers
+func (te *TransportElection) Peers() []string {
+	peers := make([]string, 0, len(te.peers))
+	for nodeID := range te.peers {
+		peers = append(peers, nodeID)
+	}
+	return peers
+}
+
+// RequestVote asks peer to vote for a candidate
+func (te *TransportElection) RequestVote(ctx context.Context, peer string, request internal.VoteRequest) (internal.VoteResponse, error) {
+	var response internal.VoteResponse
+	err := te.call(ctx, peer, electionVotePath, request, &response)
+	return response, err
+}
+
+// Heartbeat asserts leadership to peer
+func (te *TransportElection) Heartbeat(ctx context.Context, peer string, request internal.HeartbeatRequest) (internal.HeartbeatResponse, error) {
+	var response internal.HeartbeatResponse
+	err := te.call(ctx, peer, electionHeartbeatPath, request, &response)
+	return response, err
+}
+
+func (te *TransportElection) call(ctx context.Context, peer, path string, request, response interface{}) error {
+	address, ok := te.peers[peer]
+	if !ok {
+		return fmt.Errorf("unknown election peer %s", peer)
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode election request: %w", err)
+	}
+
+	timeout := 5 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	reply, err := te.pool.Execute(ctx, address, &Request{
+		ID:      newConnectionID("election"),
+		Method:  "POST",
+		Path:    path,
+		Body:    body,
+		Timeout: timeout,
+	})
+	if err != nil {
+		return fmt.Errorf("election request to %s failed: %w", peer, err)
+	}
+	if reply.StatusCode != 200 {
+		return fmt.Errorf("election request to %s returned status %d: %s", peer, reply.StatusCode, reply.StatusMessage)
+	}
+
+	if err := json.Unmarshal(reply.Body, response); err != nil {
+		return fmt.Errorf("failed to decode election response from %s: %w", peer, err)
+	}
+	return nil
+}
+
+// ElectionHandler answers election requests for elector and passes every
+// other request to next
+func ElectionHandler(elector *internal.LeaderElector, next RequestHandler) RequestHandler {
+	return func(request *Request) *Response {
+		var reply interface{}
+		switch request.Path {
+		case electionVotePath:
+			var vote internal.VoteRequest
+			if err := json.Unmarshal(request.Body, &vote); err != nil {
+				return &Response{StatusCode: 400, StatusMessage: fmt.Sprintf("invalid vote request: %v", err)}
+			}
+			reply = elector.HandleVote(vote)
+
+		case electionHeartbeatPath:
+			var heartbeat internal.HeartbeatRequest
+			if err := json.Unmarshal(request.Body, &heartbeat); err != nil {
+				return &Response{StatusCode: 400, StatusMessage: fmt.Sprintf("invalid heartbeat: %v", err)}
+			}
+			reply = elector.HandleHeartbeat(heartbeat)
+
+		default:
+			if next == nil {
+				return nil
+			}
+			return next(request)
+		}
+
+		body, err := json.Marshal(reply)
+		if err != nil {
+			return &Response{StatusCode: 500, StatusMessage: err.Error()}
+		}
+		return &Response{StatusCode: 200, Body: body}
+	}
+}
@@ -0,0 +1,434 @@
+// Package integration implements event bus publication of ALM integration events
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+	"go.uber.org/zap"
+)
+
+// EventSchemaVersion is the version of the event envelope and payload schema.
+// It is incremented whenever a field is removed or changes meaning; new
+// optional fields may be added without a version change.
+const EventSchemaVersion = 1
+
+// Event types, also used as the topic suffix under EventPublisherConfig.TopicPrefix
+const (
+	EventTypeRoutingDecision = "routing.decision"
+	EventTypeCircuitState    = "circuit.state"
+	EventTypeTopologyUpdate  = "topology.update"
+	EventTypeSLOBurnRate     = "slo.burn_rate"
+)
+
+// Event is the envelope published for every integration event.
+//
+// Events are JSON encoded and published to "<TopicPrefix>.<Type>", for
+// example "hypermesh.alm.routing.decision". Data holds one of
+// RoutingDecisionEvent, CircuitStateEvent, TopologyUpdateEvent or
+// SLOBurnRateEvent according to Type. Durations are encoded in microseconds and times in RFC 3339 with
+// nanoseconds.
+//
+//	{
+//	  "schema_version": 1,
+//	  "id": "alm-node-1-42",
+//	  "type": "circuit.state",
+//	  "source": "alm-node-1",
+//	  "time": "2024-01-02T15:04:05.123456789Z",
+//	  "data": {"service_id": "payments", "from": "closed", "to": "open", ...}
+//	}
+type Event struct {
+	SchemaVersion int             `json:"schema_version"`
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	Source        string          `json:"source"`
+	Time          time.Time       `json:"time"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// RoutingDecisionEvent is published for every OptimizeRouting decision.
+// The message key is the destination service.
+type RoutingDecisionEvent struct {
+	Source             string   `json:"source"`
+	Destination        string   `json:"destination"`
+	ServiceType        string   `json:"service_type,omitempty"`
+	QoSClass           int      `json:"qos_class,omitempty"`
+	SelectedPath       []string `json:"selected_path"`
+	TotalLatencyUs     int64    `json:"total_latency_us"`
+	ExpectedThroughput float64  `json:"expected_throughput"`
+	Reliability        float64  `json:"reliability"`
+	QualityScore       float64  `json:"quality_score"`
+	Confidence         float64  `json:"confidence"`
+	Alternatives       int      `json:"alternatives"`
+	DecisionTimeUs     int64    `json:"decision_time_us"`
+}
+
+// CircuitStateEvent is published on every circuit breaker state transition.
+// The message key is the service ID.
+type CircuitStateEvent struct {
+	ServiceID    string  `json:"service_id"`
+	From         string  `json:"from"`
+	To           string  `json:"to"`
+	FailureRate  float64 `json:"failure_rate"`
+	SlowCallRate float64 `json:"slow_call_rate"`
+	WindowCalls  int     `json:"window_calls"`
+}
+
+// TopologyUpdateEvent is published for every batch applied by
+// ALMCoordinator.UpdateNetworkTopology. It carries no message key.
+type TopologyUpdateEvent struct {
+	Changes []TopologyChange `json:"changes"`
+}
+
+// TopologyChange is one entry of a TopologyUpdateEvent. Node IDs are set
+// according to Type: node_id for node and metrics changes, edge_from and
+// edge_to for edge changes.
+type TopologyChange struct {
+	Type     string `json:"type"`
+	NodeID   int64  `json:"node_id,omitempty"`
+	EdgeFrom int64  `json:"edge_from,omitempty"`
+	EdgeTo   int64  `json:"edge_to,omitempty"`
+}
+
+// SLOBurnRateEvent is published when a latency SLO burn-rate alert fires,
+// changes severity or resolves, in which case severity is empty. The
+// message key is the SLO name.
+type SLOBurnRateEvent struct {
+	SLO             string  `json:"slo"`
+	QoSClass        string  `json:"qos_class"`
+	Quantile        float64 `json:"quantile"`
+	ThresholdUs     int64   `json:"threshold_us"`
+	Severity        string  `json:"severity,omitempty"`
+	Previous        string  `json:"previous,omitempty"`
+	BurnRate        float64 `json:"burn_rate"`
+	BudgetRemaining float64 `json:"budget_remaining"`
+}
+
+// EventMessage is an encoded event addressed to a topic
+type EventMessage struct {
+	Topic string
+	Key   string
+	Value []byte
+}
+
+// EventSink delivers encoded events to a message bus such as NATS or Kafka
+type EventSink interface {
+	Publish(ctx context.Context, messages []EventMessage) error
+	Close() error
+}
+
+// EventPublisherConfig configures an EventPublisher
+type EventPublisherConfig struct {
+	// Topics are "<TopicPrefix>.<event type>"
+	TopicPrefix string
+
+	// Identifies this ALM instance in the event envelope
+	Source string
+
+	// Events queued beyond BufferSize are dropped rather than blocking routing
+	BufferSize   int
+	MaxBatchSize int
+
+	// Bound on a single sink publish
+	PublishTimeout time.Duration
+}
+
+// EventPublisherStats summarizes publisher activity
+type EventPublisherStats struct {
+	Published int64
+	Dropped   int64
+	Failed    int64
+	Queued    int
+}
+
+// EventPublisher publishes routing decisions, circuit state changes and
+// topology updates to an EventSink.
+//
+// Publishing never blocks the caller: events are queued and delivered in
+// batches by a background worker, and dropped when the queue is full.
+type EventPublisher struct {
+	sink   EventSink
+	config *EventPublisherConfig
+	logger *zap.Logger
+
+	queue    chan EventMessage
+	sequence atomic.Uint64
+
+	// Counters
+	published atomic.Int64
+	dropped   atomic.Int64
+	failed    atomic.Int64
+
+	stopChan  chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+	wg        sync.WaitGroup
+}
+
+// NewEventPublisher creates a publisher delivering to sink and starts its worker
+func NewEventPublisher(sink EventSink, config *EventPublisherConfig, logger *zap.Logger) *EventPublisher {
+	if config == nil {
+		config = DefaultEventPublisherConfig()
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	if config.MaxBatchSize <= 0 {
+		config.MaxBatchSize = 1
+	}
+
+	ep := &EventPublisher{
+		sink:     sink,
+		config:   config,
+		logger:   logger,
+		queue:    make(chan EventMessage, config.BufferSize),
+		stopChan: make(chan struct{}),
+	}
+
+	ep.wg.Add(1)
+	go ep.run()
+
+	return ep
+}
+
+// PublishRoutingDecision publishes a routing decision between two services
+func (ep *EventPublisher) PublishRoutingDecision(source, destination string, constraints *RoutingConstraints, decision *RoutingDecision) {
+	data := RoutingDecisionEvent{
+		Source:             source,
+		Destination:        destination,
+		SelectedPath:       decision.SelectedPath,
+		TotalLatencyUs:     decision.TotalLatency.Microseconds(),
+		ExpectedThroughput: decision.ExpectedThroughput,
+		Reliability:        decision.Reliability,
+		QualityScore:       decision.QualityScore,
+		Confidence:         decision.Confidence,
+		Alternatives:       len(decision.AlternativePaths),
+		DecisionTimeUs:     decision.DecisionTime.Microseconds(),
+	}
+	if constraints != nil {
+		data.ServiceType = constraints.ServiceType
+		data.QoSClass = constraints.QoSClass
+	}
+
+	ep.publish(EventTypeRoutingDecision, destination, data)
+}
+
+// PublishCircuitStateChange publishes a circuit breaker state transition.
+// metrics may be nil when the breaker does not expose window statistics.
+func (ep *EventPublisher) PublishCircuitStateChange(serviceID string, from, to BreakerState, metrics *CircuitMetrics) {
+	data := CircuitStateEvent{
+		ServiceID: serviceID,
+		From:      from.String(),
+		To:        to.String(),
+	}
+	if metrics != nil {
+		data.FailureRate = metrics.FailureRate
+		data.SlowCallRate = metrics.SlowCallRate
+		data.WindowCalls = metrics.WindowCalls
+	}
+
+	ep.publish(EventTypeCircuitState, serviceID, data)
+}
+
+// PublishTopologyUpdates publishes a batch of applied topology updates
+func (ep *EventPublisher) PublishTopologyUpdates(updates []internal.TopologyUpdate) {
+	if len(updates) == 0 {
+		return
+	}
+
+	changes := make([]TopologyChange, 0, len(updates))
+	for _, update := range updates {
+		change := TopologyChange{Type: update.Type.String()}
+		switch update.Type {
+		case internal.NodeAddUpdate:
+			if update.Node != nil {
+				change.NodeID = update.Node.ID
+			}
+		case internal.NodeRemoveUpdate, internal.MetricsUpdate:
+			change.NodeID = update.NodeID
+		case internal.EdgeAddUpdate:
+			if update.Edge != nil {
+				change.EdgeFrom = update.Edge.From
+				change.EdgeTo = update.Edge.To
+			}
+		case internal.EdgeRemoveUpdate, internal.EdgeMetricsUpdate:
+			change.EdgeFrom = update.EdgeFrom
+			change.EdgeTo = update.EdgeTo
+		}
+		changes = append(changes, change)
+	}
+
+	ep.publish(EventTypeTopologyUpdate, "", TopologyUpdateEvent{Changes: changes})
+}
+
+// PublishSLOAlert publishes a latency SLO burn-rate alert
+func (ep *EventPublisher) PublishSLOAlert(alert routing.SLOAlert) {
+	ep.publish(EventTypeSLOBurnRate, alert.SLO.Name, SLOBurnRateEvent{
+		SLO:             alert.SLO.Name,
+		QoSClass:        alert.SLO.QoSClass.String(),
+		Quantile:        alert.SLO.Quantile,
+		ThresholdUs:     alert.SLO.Threshold.Microseconds(),
+		Severity:        alert.Severity,
+		Previous:        alert.Previous,
+		BurnRate:        alert.BurnRate,
+		BudgetRemaining: alert.BudgetRemaining,
+	})
+}
+
+// WatchCircuitBreaker publishes every state transition of cb
+func (ep *EventPublisher) WatchCircuitBreaker(cb *CircuitBreaker) {
+	cb.OnStateChange(func(serviceID string, from, to BreakerState) {
+		metrics, _ := cb.GetCircuitMetrics(serviceID)
+		ep.PublishCircuitStateChange(serviceID, from, to, metrics)
+	})
+}
+
+// WatchCoordinator publishes every topology update applied by coordinator
+// and its latency SLO burn-rate alerts
+func (ep *EventPublisher) WatchCoordinator(coordinator *internal.ALMCoordinator) {
+	coordinator.OnTopologyUpdate(ep.PublishTopologyUpdates)
+	coordinator.OnSLOAlert(ep.PublishSLOAlert)
+}
+
+// Stats returns publisher counters
+func (ep *EventPublisher) Stats() EventPublisherStats {
+	return EventPublisherStats{
+		Published: ep.published.Load(),
+		Dropped:   ep.dropped.Load(),
+		Failed:    ep.failed.Load(),
+		Queued:    len(ep.queue),
+	}
+}
+
+// Close delivers queued events, stops the worker and closes the sink
+func (ep *EventPublisher) Close() error {
+	ep.closeOnce.Do(func() {
+		close(ep.stopChan)
+		ep.wg.Wait()
+		ep.closeErr = ep.sink.Close()
+	})
+	return ep.closeErr
+}
+
+// publish encodes an event and queues it without blocking
+func (ep *EventPublisher) publish(eventType, key string, data interface{}) {
+	select {
+	case <-ep.stopChan:
+		ep.dropped.Add(1)
+		return
+	default:
+	}
+
+	value, err := ep.encode(eventType, data)
+	if err != nil {
+		ep.failed.Add(1)
+		ep.logger.Error("Failed to encode event", zap.String("type", eventType), zap.Error(err))
+		return
+	}
+
+	message := EventMessage{
+		Topic: ep.config.TopicPrefix + "." + eventType,
+		Key:   key,
+		Value: value,
+	}
+
+	select {
+	case ep.queue <- message:
+	default:
+		ep.dropped.Add(1)
+	}
+}
+
+func (ep *EventPublisher) encode(eventType string, data interface{}) ([]byte, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s payload: %w", eventType, err)
+	}
+
+	return json.Marshal(Event{
+		SchemaVersion: EventSchemaVersion,
+		ID:            fmt.Sprintf("%s-%d", ep.config.Source, ep.sequence.Add(1)),
+		Type:          eventType,
+		Source:        ep.config.Source,
+		Time:          time.Now().UTC(),
+		Data:          payload,
+	})
+}
+
+// run delivers queued events in batches until Close, then flushes the queue
+func (ep *EventPublisher) run() {
+	defer ep.wg.Done()
+
+	batch := make([]EventMessage, 0, ep.config.MaxBatchSize)
+	for {
+		select {
+		case message := <-ep.queue:
+			batch = append(batch[:0], message)
+			batch = ep.fill(batch)
+			ep.deliver(batch)
+
+		case <-ep.stopChan:
+			for len(ep.queue) > 0 {
+				batch = ep.fill(batch[:0])
+				ep.deliver(batch)
+			}
+			return
+		}
+	}
+}
+
+// fill appends queued events to batch up to MaxBatchSize without waiting
+func (ep *EventPublisher) fill(batch []EventMessage) []EventMessage {
+	for len(batch) < ep.config.MaxBatchSize {
+		select {
+		case message := <-ep.queue:
+			batch = append(batch, message)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+func (ep *EventPublisher) deliver(batch []EventMessage) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	if ep.config.PublishTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ep.config.PublishTimeout)
+		defer cancel()
+	}
+
+	if err := ep.sink.Publish(ctx, batch); err != nil {
+		ep.failed.Add(int64(len(batch)))
+		ep.logger.Warn("Failed to publish events",
+			zap.Int("events", len(batch)),
+			zap.Error(err),
+		)
+		return
+	}
+
+	ep.published.Add(int64(len(batch)))
+}
+
+// DefaultEventPublisherConfig returns default event publisher configuration
+func DefaultEventPublisherConfig() *EventPublisherConfig {
+	return &EventPublisherConfig{
+		TopicPrefix:    "hypermesh.alm",
+		Source:         "alm",
+		BufferSize:     4096,
+		MaxBatchSize:   100,
+		PublishTimeout: 5 * time.Second,
+	}
+}
@@ -0,0 +1,236 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+// recordingSink keeps published batches. When hold is set, the first
+// publish signals entered and waits for hold to be closed.
+type recordingSink struct {
+	err     error
+	hold    chan struct{}
+	entered chan struct{}
+
+	mutex   sync.Mutex
+	batches [][]EventMessage
+	closed  bool
+}
+
+func (rs *recordingSink) Publish(ctx context.Context, messages []EventMessage) error {
+	if rs.hold != nil {
+		close(rs.entered)
+		<-rs.hold
+		rs.hold = nil
+	}
+
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	rs.batches = append(rs.batches, append([]EventMessage(nil), messages...))
+	return rs.err
+}
+
+func (rs *recordingSink) Close() error {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	rs.closed = true
+	return nil
+}
+
+func (rs *recordingSink) messages() []EventMessage {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	var messages []EventMessage
+	for _, batch := range rs.batches {
+		messages = append(messages, batch...)
+	}
+	return messages
+}
+
+func eventTestConfig() *EventPublisherConfig {
+	config := DefaultEventPublisherConfig()
+	config.Source = "alm-test"
+	return config
+}
+
+// decodeEvent decodes a message's envelope and its data into data
+func decodeEvent(t *testing.T, message EventMessage, data interface{}) Event {
+	t.Helper()
+
+	var event Event
+	if err := json.Unmarshal(message.Value, &event); err != nil {
+		t.Fatalf("decode envelope: %v", err)
+	}
+	if err := json.Unmarshal(event.Data, data); err != nil {
+		t.Fatalf("decode %s data: %v", event.Type, err)
+	}
+	return event
+}
+
+func TestEventPublisherEnvelope(t *testing.T) {
+	sink := &recordingSink{}
+	publisher := NewEventPublisher(sink, eventTestConfig(), nil)
+
+	publisher.PublishCircuitStateChange("payments", CircuitClosed, CircuitOpen, &CircuitMetrics{FailureRate: 0.75, WindowCalls: 4})
+	publisher.PublishRoutingDecision("web", "payments", &RoutingConstraints{ServiceType: "http", QoSClass: 2}, &RoutingDecision{
+		SelectedPath:     []string{"1", "2"},
+		AlternativePaths: []AlternativePath{{Path: []string{"1", "3", "2"}}},
+		QualityScore:     0.9,
+	})
+	if err := publisher.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	messages := sink.messages()
+	if len(messages) != 2 || !sink.closed {
+		t.Fatalf("sink got %d messages, closed %v; want 2 and closed", len(messages), sink.closed)
+	}
+
+	var circuit CircuitStateEvent
+	event := decodeEvent(t, messages[0], &circuit)
+	if messages[0].Topic != "hypermesh.alm.circuit.state" || messages[0].Key != "payments" {
+		t.Errorf("circuit event on %s keyed %q", messages[0].Topic, messages[0].Key)
+	}
+	if event.SchemaVersion != EventSchemaVersion || event.ID != "alm-test-1" || event.Source != "alm-test" || event.Time.IsZero() {
+		t.Errorf("envelope %+v", event)
+	}
+	want := CircuitStateEvent{ServiceID: "payments", From: "closed", To: "open", FailureRate: 0.75, WindowCalls: 4}
+	if circuit != want {
+		t.Errorf("circuit data %+v, want %+v", circuit, want)
+	}
+
+	var routed RoutingDecisionEvent
+	event = decodeEvent(t, messages[1], &routed)
+	if event.ID != "alm-test-2" || messages[1].Topic != "hypermesh.alm.routing.decision" {
+		t.Errorf("routing event %s on %s", event.ID, messages[1].Topic)
+	}
+	if routed.ServiceType != "http" || routed.QoSClass != 2 || routed.Alternatives != 1 || !reflect.DeepEqual(routed.SelectedPath, []string{"1", "2"}) {
+		t.Errorf("routing data %+v", routed)
+	}
+}
+
+func TestEventPublisherTopologyChanges(t *testing.T) {
+	sink := &recordingSink{}
+	publisher := NewEventPublisher(sink, eventTestConfig(), nil)
+
+	publisher.PublishTopologyUpdates(nil)
+	publisher.PublishTopologyUpdates([]internal.TopologyUpdate{
+		{Type: internal.NodeAddUpdate, Node: &graph.NetworkNode{ID: 1}},
+		{Type: internal.NodeRemoveUpdate, NodeID: 2},
+		{Type: internal.EdgeAddUpdate, Edge: &graph.NetworkEdge{From: 1, To: 3}},
+		{Type: internal.EdgeMetricsUpdate, EdgeFrom: 3, EdgeTo: 4},
+	})
+	publisher.Close()
+
+	messages := sink.messages()
+	if len(messages) != 1 || messages[0].Key != "" {
+		t.Fatalf("messages %+v, want one unkeyed event for the non-empty batch", messages)
+	}
+
+	var update TopologyUpdateEvent
+	decodeEvent(t, messages[0], &update)
+	want := []TopologyChange{
+		{Type: "node_add", NodeID: 1},
+		{Type: "node_remove", NodeID: 2},
+		{Type: "edge_add", EdgeFrom: 1, EdgeTo: 3},
+		{Type: "edge_metrics", EdgeFrom: 3, EdgeTo: 4},
+	}
+	if !reflect.DeepEqual(update.Changes, want) {
+		t.Errorf("changes %+v, want %+v", update.Changes, want)
+	}
+}
+
+func TestEventPublisherDropsWhenQueueFull(t *testing.T) {
+	sink := &recordingSink{hold: make(chan struct{}), entered: make(chan struct{})}
+	hold := sink.hold
+	config := eventTestConfig()
+	config.BufferSize = 2
+	publisher := NewEventPublisher(sink, config, nil)
+
+	// The worker takes the first event and blocks in the sink; two more fit
+	// the queue and the rest are dropped without blocking the caller
+	publisher.PublishCircuitStateChange("svc", CircuitClosed, CircuitOpen, nil)
+	<-sink.entered
+	for i := 0; i < 5; i++ {
+		publisher.PublishCircuitStateChange("svc", CircuitClosed, CircuitOpen, nil)
+	}
+	if stats := publisher.Stats(); stats.Dropped != 3 || stats.Queued != 2 {
+		t.Errorf("stats %+v while the sink is blocked, want 3 dropped and 2 queued", stats)
+	}
+
+	close(hold)
+	if err := publisher.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if stats := publisher.Stats(); stats.Published != 3 || stats.Queued != 0 {
+		t.Errorf("stats %+v after Close, want the queue flushed", stats)
+	}
+
+	publisher.PublishCircuitStateChange("svc", CircuitOpen, CircuitHalfOpen, nil)
+	if stats := publisher.Stats(); stats.Dropped != 4 {
+		t.Errorf("%d dropped, want an event published after Close dropped", stats.Dropped)
+	}
+}
+
+func TestEventPublisherCountsSinkFailures(t *testing.T) {
+	sink := &recordingSink{err: errors.New("broker unavailable")}
+	publisher := NewEventPublisher(sink, eventTestConfig(), nil)
+
+	publisher.PublishCircuitStateChange("svc", CircuitClosed, CircuitOpen, nil)
+	publisher.PublishCircuitStateChange("svc", CircuitOpen, CircuitHalfOpen, nil)
+	publisher.Close()
+
+	if stats := publisher.Stats(); stats.Failed != 2 || stats.Published != 0 {
+		t.Errorf("stats %+v, want both events failed", stats)
+	}
+}
+
+func TestEventPublisherWatchCircuitBreaker(t *testing.T) {
+	sink := &recordingSink{}
+	publisher := NewEventPublisher(sink, eventTestConfig(), nil)
+	cb := NewCircuitBreaker(breakerTestConfig())
+	publisher.WatchCircuitBreaker(cb)
+
+	failure := errors.New("unavailable")
+	for i := 0; i < 4; i++ {
+		cb.RecordFailure("orders", failure)
+	}
+	publisher.Close()
+
+	messages := sink.messages()
+	if len(messages) != 1 {
+		t.Fatalf("%d events, want the one transition to open", len(messages))
+	}
+	var circuit CircuitStateEvent
+	decodeEvent(t, messages[0], &circuit)
+	if circuit.ServiceID != "orders" || circuit.To != "open" || circuit.WindowCalls != 4 {
+		t.Errorf("circuit data %+v, want orders opened after 4 calls", circuit)
+	}
+}
+
+func TestNewEventSinkRejectsInvalidBackend(t *testing.T) {
+	if _, err := NewEventSink(EventBackendKafka, nil); err == nil {
+		t.Error("created a sink without endpoints")
+	}
+	if _, err := NewEventSink("rabbitmq", []string{"localhost:5672"}); err == nil {
+		t.Error("created a sink for an unsupported backend")
+	}
+
+	// Kafka connects lazily, so a sink can be created without a broker
+	sink, err := NewEventSink("Kafka", []string{"127.0.0.1:1"})
+	if err != nil {
+		t.Fatalf("NewEventSink(Kafka): %v", err)
+	}
+	if _, ok := sink.(*KafkaEventSink); !ok {
+		t.Errorf("NewEventSink(Kafka) = %T", sink)
+	}
+	sink.Close()
+}
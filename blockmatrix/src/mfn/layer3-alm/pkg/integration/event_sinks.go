@@ -0,0 +1,118 @@
+// Package integration implements NATS and Kafka sinks for integration events
+package integration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+)
+
+// Supported event bus backends
+const (
+	EventBackendNATS  = "nats"
+	EventBackendKafka = "kafka"
+)
+
+// NewEventSink connects to the named backend. endpoints are NATS server URLs
+// or Kafka broker addresses.
+func NewEventSink(backend string, endpoints []string) (EventSink, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no %s endpoints configured", backend)
+	}
+
+	switch strings.ToLower(backend) {
+	case EventBackendNATS:
+		return NewNATSEventSink(strings.Join(endpoints, ","))
+	case EventBackendKafka:
+		return NewKafkaEventSink(endpoints), nil
+	default:
+		return nil, fmt.Errorf("unsupported event backend: %s", backend)
+	}
+}
+
+// NATSEventSink publishes events as NATS messages with the topic as subject.
+// The message key is carried in the "Key" header.
+type NATSEventSink struct {
+	conn *nats.Conn
+}
+
+// NewNATSEventSink connects to the comma-separated NATS server URLs
+func NewNATSEventSink(urls string, options ...nats.Option) (*NATSEventSink, error) {
+	options = append([]nats.Option{nats.Name("hypermesh-alm-events")}, options...)
+
+	conn, err := nats.Connect(urls, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS at %s: %w", urls, err)
+	}
+
+	return &NATSEventSink{conn: conn}, nil
+}
+
+// Publish sends messages and waits for the server to acknowledge receipt
+func (ns *NATSEventSink) Publish(ctx context.Context, messages []EventMessage) error {
+	for _, message := range messages {
+		msg := nats.NewMsg(message.Topic)
+		msg.Data = message.Value
+		if message.Key != "" {
+			msg.Header.Set("Key", message.Key)
+		}
+
+		if err := ns.conn.PublishMsg(msg); err != nil {
+			return fmt.Errorf("failed to publish to %s: %w", message.Topic, err)
+		}
+	}
+
+	return ns.conn.FlushWithContext(ctx)
+}
+
+// Close drains pending messages and closes the connection
+func (ns *NATSEventSink) Close() error {
+	return ns.conn.Drain()
+}
+
+// KafkaEventSink publishes events as Kafka records, partitioned by key
+type KafkaEventSink struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaEventSink creates a sink writing to the given brokers. Topics are
+// created on first use when the cluster allows it.
+func NewKafkaEventSink(brokers []string) *KafkaEventSink {
+	return &KafkaEventSink{
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(brokers...),
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireOne,
+			BatchTimeout:           10 * time.Millisecond,
+			AllowAutoTopicCreation: true,
+		},
+	}
+}
+
+// Publish writes messages as one batch
+func (ks *KafkaEventSink) Publish(ctx context.Context, messages []EventMessage) error {
+	records := make([]kafka.Message, len(messages))
+	for i, message := range messages {
+		records[i] = kafka.Message{
+			Topic: message.Topic,
+			Value: message.Value,
+		}
+		if message.Key != "" {
+			records[i].Key = []byte(message.Key)
+		}
+	}
+
+	if err := ks.writer.WriteMessages(ctx, records...); err != nil {
+		return fmt.Errorf("failed to write to Kafka: %w", err)
+	}
+	return nil
+}
+
+// Close flushes pending writes and closes the writer
+func (ks *KafkaEventSink) Close() error {
+	return ks.writer.Close()
+}
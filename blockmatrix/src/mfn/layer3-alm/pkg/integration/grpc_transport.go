@@ -0,0 +1,1364 @@
+// Package integration implements the gRPC transport for HyperMesh
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// grpcServiceName is the service registered on HyperMesh gRPC listeners
+	grpcServiceName = "hypermesh.transport.v1.Transport"
+
+	grpcCallMethod   = "/" + grpcServiceName + "/Call"
+	grpcStreamMethod = "/" + grpcServiceName + "/Stream"
+
+	// grpcDefaultMaxStreams bounds concurrent HTTP/2 streams per connection
+	grpcDefaultMaxStreams = 1000
+
+	// grpcStreamIDKey carries the application stream ID in stream metadata
+	grpcStreamIDKey = "x-hypermesh-stream-id"
+	// grpcPriorityKey carries the stream priority in stream metadata
+	grpcPriorityKey = "x-hypermesh-priority"
+	// grpcWindowKey carries the stream flow control window in stream metadata
+	grpcWindowKey = "x-hypermesh-window"
+	// grpcAcceptEncodingKey carries the compression codecs the stream opener accepts
+	grpcAcceptEncodingKey = "x-hypermesh-accept-encoding"
+
+	// grpcAcceptBacklog bounds accepted connections waiting for Accept
+	grpcAcceptBacklog = 128
+)
+
+// rawCodec passes pre-encoded frames through gRPC without protobuf.
+// Requests and responses use the same JSON encoding as the QUIC transport.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	data, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("raw codec cannot marshal %T", v)
+	}
+	return *data, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	target, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("raw codec cannot unmarshal into %T", v)
+	}
+	*target = append((*target)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "hypermesh-raw"
+}
+
+// grpcServiceDesc describes the HyperMesh transport service; handlers receive the *grpcListener
+var grpcServiceDesc = grpc.ServiceDesc{
+	ServiceName: grpcServiceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Call", Handler: grpcCallHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "Stream", Handler: grpcStreamHandler, ServerStreams: true, ClientStreams: true},
+	},
+}
+
+// GRPCTransport implements HyperMeshTransport over gRPC/HTTP2.
+//
+// It is intended for networks where UDP is blocked and QUIC cannot be used.
+// Requests are unary calls and application streams are bidirectional gRPC
+// streams, all multiplexed over one HTTP/2 connection per peer. Calls flow
+// from the dialing side to the listening side only.
+type GRPCTransport struct {
+	config *TransportConfig
+	tls    *tlsManager
+
+	// Tracked resources for shutdown and statistics
+	connections map[string]*grpcConnection
+	listeners   map[*grpcListener]struct{}
+	stats       *transportStats
+
+	isShutdown bool
+	mutex      sync.RWMutex
+}
+
+// NewGRPCTransport creates a gRPC transport
+func NewGRPCTransport(config *TransportConfig) (*GRPCTransport, error) {
+	if config == nil {
+		config = DefaultTransportConfig()
+		config.Protocol = ProtocolGRPC
+		config.EnableQUIC = false
+	}
+
+	return &GRPCTransport{
+		config:      config,
+		tls:         newTLSManager(),
+		connections: make(map[string]*grpcConnection),
+		listeners:   make(map[*grpcListener]struct{}),
+		stats:       newTransportStats(),
+	}, nil
+}
+
+// Connect dials a remote HyperMesh node
+func (gt *GRPCTransport) Connect(config *TransportConfig) (Connection, error) {
+	gt.mutex.RLock()
+	shutdown := gt.isShutdown
+	if config == nil {
+		config = gt.config
+	}
+	gt.mutex.RUnlock()
+
+	if shutdown {
+		return nil, &TransportError{Code: ErrorCodeConnectionClosed, Message: "transport is shut down"}
+	}
+
+	address := transportAddress(config.Address, config.Port)
+
+	identity := new(atomic.Pointer[PeerIdentity])
+	options, err := grpcDialOptions(gt.tls, config, address, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withOptionalTimeout(context.Background(), config.ConnectTimeout)
+	defer cancel()
+
+	clientConn, err := grpc.DialContext(ctx, address, options...)
+	if err != nil {
+		gt.stats.failedConnections.Add(1)
+
+		code := ErrorCodeConnectionFailed
+		if errors.Is(err, context.DeadlineExceeded) {
+			code = ErrorCodeConnectionTimeout
+		}
+		return nil, &TransportError{
+			Code:      code,
+			Message:   fmt.Sprintf("failed to connect to %s", address),
+			Cause:     err,
+			Retryable: true,
+			Temporary: true,
+		}
+	}
+
+	conn := &grpcConnection{
+		transport:     gt,
+		config:        config,
+		address:       address,
+		id:            newConnectionID("grpc"),
+		conn:          clientConn,
+		identity:      identity,
+		establishedAt: time.Now(),
+		compression:   newPayloadCompressor(config, gt.stats),
+	}
+	conn.stats.touch()
+
+	gt.track(conn)
+	return conn, nil
+}
+
+// Listen starts a gRPC server for incoming HyperMesh connections
+func (gt *GRPCTransport) Listen(config *ListenerConfig) (Listener, error) {
+	if config == nil {
+		return nil, &TransportError{Code: ErrorCodeProtocolError, Message: "listener config is required"}
+	}
+
+	gt.mutex.Lock()
+	defer gt.mutex.Unlock()
+
+	if gt.isShutdown {
+		return nil, &TransportError{Code: ErrorCodeConnectionClosed, Message: "transport is shut down"}
+	}
+
+	var listener *grpcListener
+	options, err := grpcServerOptions(gt.tls, gt.config, config, func(remote net.Addr, identity *PeerIdentity) {
+		listener.recordIdentity(remote, identity)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	network := "tcp"
+	if gt.config != nil && gt.config.IPv6Only {
+		network = "tcp6"
+	}
+
+	address := transportAddress(config.Address, config.Port)
+	tcpListener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, &TransportError{
+			Code:    ErrorCodeConnectionFailed,
+			Message: fmt.Sprintf("failed to listen on %s", address),
+			Cause:   err,
+		}
+	}
+
+	listener = &grpcListener{
+		transport: gt,
+		config:    config,
+		server:    grpc.NewServer(options...),
+		startedAt: time.Now(),
+		accepted:  make(chan *grpcConnection, grpcAcceptBacklog),
+		byAddress: make(map[string]*grpcConnection),
+		done:      make(chan struct{}),
+	}
+	listener.netListener = &trackingListener{Listener: tcpListener, owner: listener}
+	listener.server.RegisterService(&grpcServiceDesc, listener)
+
+	go func() {
+		if err := listener.server.Serve(listener.netListener); err != nil && !listener.closed.Load() {
+			listener.lastError.Store(err)
+		}
+	}()
+
+	gt.listeners[listener] = struct{}{}
+	return listener, nil
+}
+
+// GetCapabilities describes what the gRPC transport supports
+func (gt *GRPCTransport) GetCapabilities() TransportCapabilities {
+	return TransportCapabilities{
+		SupportedProtocols:    []string{ProtocolGRPC},
+		MaxConcurrentStreams:  grpcDefaultMaxStreams,
+		MaxConnectionsPerHost: 1,
+		SupportsMultiplexing:  true,
+		SupportsCompression:   true,
+		SupportsEncryption:    true,
+		SupportsIPv6:          true,
+		SupportsQUIC:          false,
+		MaxMessageSize:        defaultMaxFrameSize,
+	}
+}
+
+// GetStatistics returns transport-wide statistics
+func (gt *GRPCTransport) GetStatistics() TransportStatistics {
+	return gt.stats.snapshot()
+}
+
+// UpdateConfiguration applies a new configuration to subsequent connections
+func (gt *GRPCTransport) UpdateConfiguration(config *TransportConfig) error {
+	if config == nil {
+		return &TransportError{Code: ErrorCodeProtocolError, Message: "transport config is required"}
+	}
+
+	gt.mutex.Lock()
+	defer gt.mutex.Unlock()
+
+	gt.config = config
+	return nil
+}
+
+// Shutdown closes all listeners and connections
+func (gt *GRPCTransport) Shutdown() error {
+	gt.mutex.Lock()
+	if gt.isShutdown {
+		gt.mutex.Unlock()
+		return nil
+	}
+	gt.isShutdown = true
+
+	connections := make([]*grpcConnection, 0, len(gt.connections))
+	for _, conn := range gt.connections {
+		connections = append(connections, conn)
+	}
+	listeners := make([]*grpcListener, 0, len(gt.listeners))
+	for listener := range gt.listeners {
+		listeners = append(listeners, listener)
+	}
+	gt.mutex.Unlock()
+
+	for _, listener := range listeners {
+		listener.Close()
+	}
+
+	var firstErr error
+	for _, conn := range connections {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	gt.tls.Close()
+
+	return firstErr
+}
+
+func (gt *GRPCTransport) track(conn *grpcConnection) {
+	gt.mutex.Lock()
+	gt.connections[conn.id] = conn
+	gt.mutex.Unlock()
+
+	gt.stats.totalConnections.Add(1)
+	gt.stats.activeConnections.Add(1)
+}
+
+func (gt *GRPCTransport) untrack(conn *grpcConnection) {
+	gt.mutex.Lock()
+	_, tracked := gt.connections[conn.id]
+	delete(gt.connections, conn.id)
+	gt.mutex.Unlock()
+
+	if tracked {
+		gt.stats.activeConnections.Add(-1)
+	}
+}
+
+func (gt *GRPCTransport) removeListener(listener *grpcListener) {
+	gt.mutex.Lock()
+	delete(gt.listeners, listener)
+	gt.mutex.Unlock()
+}
+
+// grpcConnection implements Connection over a gRPC client connection, or
+// represents a peer connected to a grpcListener when conn is nil
+type grpcConnection struct {
+	transport *GRPCTransport
+	config    *TransportConfig
+	address   string
+	id        string
+
+	conn          *grpc.ClientConn
+	identity      *atomic.Pointer[PeerIdentity]
+	establishedAt time.Time
+	lastError     error
+	mutex         sync.RWMutex
+
+	// Payload compression and the codec negotiated with the peer, if any
+	compression *payloadCompressor
+	peerCodec   atomic.Pointer[payloadCodec]
+
+	stats     connectionStats
+	closed    atomic.Bool
+	closeOnce sync.Once
+	onClose   func()
+}
+
+// Execute sends a request as a unary gRPC call
+func (gc *grpcConnection) Execute(request *Request) (response *Response, err error) {
+	if err := gc.checkClient(); err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+
+	request, span := startClientSpan(request, "grpc", gc.address)
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := gc.requestContext(request)
+	defer cancel()
+
+	payload, err := encodeRequest(request, gc.compression.accepted())
+	if err != nil {
+		return nil, &TransportError{Code: ErrorCodeProtocolError, Message: "failed to encode request", Cause: err}
+	}
+	message := grpcMessage(gc.compression.encodeFrame(frameRequest, gc.peerCodec.Load(), payload))
+
+	var reply []byte
+	err = gc.conn.Invoke(ctx, grpcCallMethod, &message, &reply)
+	latency := time.Since(startTime)
+	if err != nil {
+		transportErr := grpcError(err)
+		gc.recordRequest(latency, false, transportErr)
+		return nil, transportErr
+	}
+
+	response, err = gc.decodeReply(reply)
+	gc.recordRequest(latency, err == nil, err)
+	if err != nil {
+		return nil, err
+	}
+	gc.recordTransfer(len(message), len(reply))
+
+	response.Latency = latency
+	response.ConnectionID = gc.id
+	span.SetAttributes(attribute.Int("alm.response.status_code", response.StatusCode))
+
+	return response, nil
+}
+
+// ExecuteAsync executes a request in the background
+func (gc *grpcConnection) ExecuteAsync(request *Request) (<-chan *Response, <-chan error) {
+	respChan := make(chan *Response, 1)
+	errChan := make(chan error, 1)
+
+	go func() {
+		resp, err := gc.Execute(request)
+		if err != nil {
+			errChan <- err
+		} else {
+			respChan <- resp
+		}
+		close(respChan)
+		close(errChan)
+	}()
+
+	return respChan, errChan
+}
+
+// CreateStream opens a bidirectional gRPC stream
+func (gc *grpcConnection) CreateStream(streamConfig *StreamConfig) (Stream, error) {
+	if err := gc.checkClient(); err != nil {
+		return nil, err
+	}
+	if streamConfig == nil {
+		streamConfig = &StreamConfig{}
+	}
+
+	streamID := streamConfig.StreamID
+	if streamID == 0 {
+		streamID = time.Now().UnixNano()
+	}
+
+	window := streamConfig.FlowControlWindow
+	if window <= 0 {
+		window = defaultFlowControlWindow
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = metadata.AppendToOutgoingContext(ctx,
+		grpcStreamIDKey, strconv.FormatInt(streamID, 10),
+		grpcPriorityKey, strconv.Itoa(int(streamConfig.Priority)),
+		grpcWindowKey, strconv.FormatInt(int64(window), 10),
+	)
+	if accepted := gc.compression.accepted(); len(accepted) > 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, grpcAcceptEncodingKey, strings.Join(accepted, ","))
+	}
+
+	clientStream, err := gc.conn.NewStream(ctx, &grpcServiceDesc.Streams[0], grpcStreamMethod)
+	if err != nil {
+		cancel()
+		return nil, grpcError(err)
+	}
+
+	stream := newGRPCStream(clientStream, streamID, gc, streamConfig.Timeout, window, cancel)
+	stream.closeFn = func() error {
+		err := clientStream.CloseSend()
+		cancel()
+		return err
+	}
+	return stream, nil
+}
+
+// GetRemoteAddress returns the address of the remote peer
+func (gc *grpcConnection) GetRemoteAddress() string {
+	return gc.address
+}
+
+// GetConnectionID returns the connection identifier
+func (gc *grpcConnection) GetConnectionID() string {
+	return gc.id
+}
+
+// GetConnectionMetrics returns per-connection metrics
+func (gc *grpcConnection) GetConnectionMetrics() ConnectionMetrics {
+	gc.mutex.RLock()
+	lastError := gc.lastError
+	gc.mutex.RUnlock()
+
+	return ConnectionMetrics{
+		ConnectionID:       gc.id,
+		RemoteAddress:      gc.address,
+		EstablishedAt:      gc.establishedAt,
+		LastActivity:       gc.stats.lastActive(),
+		TotalRequests:      gc.stats.totalRequests.Load(),
+		SuccessfulRequests: gc.stats.successfulRequests.Load(),
+		FailedRequests:     gc.stats.failedRequests.Load(),
+		AverageLatency:     gc.stats.averageLatency(),
+		BytesSent:          gc.stats.bytesSent.Load(),
+		BytesReceived:      gc.stats.bytesReceived.Load(),
+		IsHealthy:          gc.IsHealthy(),
+		LastError:          lastError,
+		LastHealthCheck:    time.Now(),
+	}
+}
+
+// GetPeerIdentity returns the identity from the peer's certificate, or nil for
+// plaintext connections and before the handshake completes
+func (gc *grpcConnection) GetPeerIdentity() *PeerIdentity {
+	if gc.identity == nil {
+		return nil
+	}
+	return gc.identity.Load()
+}
+
+// IsHealthy reports whether the connection can carry requests
+func (gc *grpcConnection) IsHealthy() bool {
+	if gc.closed.Load() {
+		return false
+	}
+	if gc.conn == nil {
+		return true // Accepted connections are healthy until the peer disconnects
+	}
+
+	switch gc.conn.GetState() {
+	case connectivity.TransientFailure, connectivity.Shutdown:
+		return false
+	default:
+		return true
+	}
+}
+
+// Ping performs an application-level round trip
+func (gc *grpcConnection) Ping() error {
+	timeout := gc.config.ConnectTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	response, err := gc.Execute(&Request{
+		ID:      newConnectionID("ping"),
+		Method:  pingMethod,
+		Timeout: timeout,
+	})
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != 200 {
+		return &TransportError{Code: ErrorCodeServerUnavailable, Message: fmt.Sprintf("ping returned status %d", response.StatusCode)}
+	}
+	return nil
+}
+
+// Close closes the connection
+func (gc *grpcConnection) Close() error {
+	gc.closed.Store(true)
+
+	var err error
+	if gc.conn != nil {
+		err = gc.conn.Close()
+	}
+	gc.markClosed()
+	return err
+}
+
+// markClosed releases tracking state exactly once
+func (gc *grpcConnection) markClosed() {
+	gc.closeOnce.Do(func() {
+		gc.transport.untrack(gc)
+		if gc.onClose != nil {
+			gc.onClose()
+		}
+	})
+}
+
+// checkClient rejects calls on accepted connections, since gRPC calls are client-initiated
+func (gc *grpcConnection) checkClient() error {
+	if gc.closed.Load() {
+		return &TransportError{Code: ErrorCodeConnectionClosed, Message: "connection is closed"}
+	}
+	if gc.conn == nil {
+		return &TransportError{Code: ErrorCodeProtocolError, Message: "gRPC calls can only be issued by the dialing side"}
+	}
+	return nil
+}
+
+// decodeReply decodes a unary response message and records the codecs the peer accepts
+func (gc *grpcConnection) decodeReply(reply []byte) (*Response, error) {
+	frameType, body, err := splitGRPCMessage(reply)
+	if err != nil {
+		return nil, err
+	}
+	frameType, body, err = gc.compression.decodeFrame(frameType, body)
+	if err != nil {
+		return nil, err
+	}
+	if frameType != frameResponse {
+		return nil, &TransportError{Code: ErrorCodeProtocolError, Message: fmt.Sprintf("unexpected frame type 0x%02x", frameType)}
+	}
+
+	response, acceptEncoding, err := decodeResponse(body)
+	if err != nil {
+		return nil, err
+	}
+	gc.peerCodec.Store(gc.compression.negotiate(acceptEncoding))
+
+	return response, nil
+}
+
+func (gc *grpcConnection) requestContext(request *Request) (context.Context, context.CancelFunc) {
+	parent := request.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	timeout := request.Timeout
+	if timeout <= 0 {
+		timeout = gc.config.RequestTimeout
+	}
+
+	return withOptionalTimeout(parent, timeout)
+}
+
+func (gc *grpcConnection) recordRequest(latency time.Duration, success bool, err error) {
+	gc.stats.recordRequest(latency, success)
+	gc.transport.stats.recordRequest(latency, success)
+
+	if err != nil {
+		gc.mutex.Lock()
+		gc.lastError = err
+		gc.mutex.Unlock()
+	}
+}
+
+func (gc *grpcConnection) recordTransfer(sent, received int) {
+	gc.stats.recordTransfer(sent, received)
+	gc.transport.stats.recordTransfer(sent, received)
+}
+
+// grpcStream implements Stream over a client or server gRPC stream. Each
+// message carries a leading frame type byte so window updates share the
+// stream with data; a reader goroutine buffers data and applies updates.
+type grpcStream struct {
+	stream       grpc.Stream
+	id           int64
+	conn         *grpcConnection
+	timeout      time.Duration
+	idleTimer    *time.Timer
+	createdAt    time.Time
+	flow         *streamFlow
+	sendMutex    sync.Mutex
+	receiveMutex sync.Mutex
+	closeFn      func() error
+
+	messagesSent     atomic.Int64
+	messagesReceived atomic.Int64
+	bytesSent        atomic.Int64
+	bytesReceived    atomic.Int64
+	lastActivity     atomic.Int64
+	closed           atomic.Bool
+
+	lastError atomic.Value
+}
+
+// newGRPCStream wraps a gRPC stream. When cancel is set and timeout is
+// positive, the stream is cancelled after timeout without activity.
+func newGRPCStream(stream grpc.Stream, id int64, conn *grpcConnection, timeout time.Duration, window int32, cancel context.CancelFunc) *grpcStream {
+	gs := &grpcStream{
+		stream:    stream,
+		id:        id,
+		conn:      conn,
+		timeout:   timeout,
+		createdAt: time.Now(),
+	}
+	gs.flow = newStreamFlow(window, backpressureReporter(conn.config, conn.address))
+	gs.lastActivity.Store(gs.createdAt.UnixNano())
+
+	if timeout > 0 && cancel != nil {
+		gs.idleTimer = time.AfterFunc(timeout, cancel)
+	}
+
+	go gs.readLoop()
+
+	return gs
+}
+
+// Send writes a message to the stream, waiting up to the stream timeout for
+// flow control credit
+func (gs *grpcStream) Send(data []byte) error {
+	return gs.SendDeadline(data, sendDeadline(gs.timeout))
+}
+
+// SendDeadline writes a message to the stream, waiting until deadline for
+// flow control credit. A zero deadline waits indefinitely.
+func (gs *grpcStream) SendDeadline(data []byte, deadline time.Time) error {
+	if gs.closed.Load() {
+		return &TransportError{Code: ErrorCodeConnectionClosed, Message: "stream is closed"}
+	}
+
+	if err := gs.flow.acquire(len(data), deadline); err != nil {
+		return err
+	}
+
+	frameType, payload := gs.conn.compression.encodeFrame(frameStreamData, gs.conn.peerCodec.Load(), data)
+	if err := gs.sendFrame(frameType, payload); err != nil {
+		return gs.fail(err)
+	}
+
+	gs.messagesSent.Add(1)
+	gs.bytesSent.Add(int64(len(data)))
+	gs.touch()
+	gs.conn.recordTransfer(len(payload)+1, 0)
+
+	return nil
+}
+
+// Available returns the bytes that can be sent without blocking
+func (gs *grpcStream) Available() int {
+	return gs.flow.available()
+}
+
+// Receive returns the next message from the stream
+func (gs *grpcStream) Receive() ([]byte, error) {
+	if gs.closed.Load() {
+		return nil, &TransportError{Code: ErrorCodeConnectionClosed, Message: "stream is closed"}
+	}
+
+	gs.receiveMutex.Lock()
+	defer gs.receiveMutex.Unlock()
+
+	data, update, err := gs.flow.pop(sendDeadline(gs.timeout))
+	if err != nil {
+		return nil, err
+	}
+
+	if update > 0 {
+		if err := gs.sendFrame(frameWindowUpdate, encodeWindowUpdate(update)); err != nil {
+			gs.fail(err)
+		}
+	}
+
+	gs.messagesReceived.Add(1)
+	gs.bytesReceived.Add(int64(len(data)))
+	gs.touch()
+
+	return data, nil
+}
+
+// GetStreamID returns the stream identifier
+func (gs *grpcStream) GetStreamID() int64 {
+	return gs.id
+}
+
+// GetStreamMetrics returns per-stream metrics
+func (gs *grpcStream) GetStreamMetrics() StreamMetrics {
+	metrics := StreamMetrics{
+		StreamID:         gs.id,
+		ConnectionID:     gs.conn.id,
+		CreatedAt:        gs.createdAt,
+		LastActivity:     time.Unix(0, gs.lastActivity.Load()),
+		MessagesSent:     gs.messagesSent.Load(),
+		MessagesReceived: gs.messagesReceived.Load(),
+		BytesSent:        gs.bytesSent.Load(),
+		BytesReceived:    gs.bytesReceived.Load(),
+		IsActive:         !gs.closed.Load(),
+	}
+	metrics.SendWindow, metrics.SendAvailable, metrics.BlockedSends, metrics.BlockedTime, metrics.Backpressure = gs.flow.stats()
+
+	if elapsed := time.Since(gs.createdAt).Seconds(); elapsed > 0 {
+		metrics.Throughput = float64(metrics.BytesSent+metrics.BytesReceived) / elapsed
+	}
+	if err, ok := gs.lastError.Load().(error); ok {
+		metrics.LastError = err
+	}
+
+	return metrics
+}
+
+// Close ends the stream. Server-side streams finish when the handler returns.
+func (gs *grpcStream) Close() error {
+	if !gs.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	gs.flow.fail(&TransportError{Code: ErrorCodeConnectionClosed, Message: "stream is closed"})
+	if gs.idleTimer != nil {
+		gs.idleTimer.Stop()
+	}
+	if gs.closeFn != nil {
+		return gs.closeFn()
+	}
+	return nil
+}
+
+// readLoop buffers data messages and applies window updates until the stream ends
+func (gs *grpcStream) readLoop() {
+	for {
+		var message []byte
+		if err := gs.stream.RecvMsg(&message); err != nil {
+			gs.flow.fail(gs.fail(err))
+			return
+		}
+
+		frameType, payload, err := splitGRPCMessage(message)
+		if err == nil {
+			frameType, payload, err = gs.conn.compression.decodeFrame(frameType, payload)
+		}
+		if err != nil {
+			gs.flow.fail(gs.fail(err))
+			return
+		}
+
+		switch frameType {
+		case frameStreamData:
+			gs.touch()
+			gs.conn.recordTransfer(0, len(message))
+			if err := gs.flow.push(payload); err != nil {
+				gs.flow.fail(gs.fail(err))
+				return
+			}
+
+		case frameWindowUpdate:
+			credit, err := decodeWindowUpdate(payload)
+			if err != nil {
+				gs.flow.fail(gs.fail(err))
+				return
+			}
+			gs.flow.grant(credit)
+
+		default:
+			gs.flow.fail(gs.fail(&TransportError{Code: ErrorCodeProtocolError, Message: fmt.Sprintf("unexpected frame type 0x%02x", frameType)}))
+			return
+		}
+	}
+}
+
+// sendFrame sends one typed message, serialized with other senders
+func (gs *grpcStream) sendFrame(frameType byte, payload []byte) error {
+	message := grpcMessage(frameType, payload)
+
+	gs.sendMutex.Lock()
+	defer gs.sendMutex.Unlock()
+	return gs.stream.SendMsg(&message)
+}
+
+func (gs *grpcStream) touch() {
+	gs.lastActivity.Store(time.Now().UnixNano())
+	if gs.idleTimer != nil {
+		gs.idleTimer.Reset(gs.timeout)
+	}
+}
+
+func (gs *grpcStream) fail(err error) error {
+	if errors.Is(err, io.EOF) {
+		err = &TransportError{Code: ErrorCodeConnectionClosed, Message: "stream closed by peer", Cause: err}
+	} else {
+		err = grpcError(err)
+	}
+	gs.lastError.Store(err)
+	return err
+}
+
+// grpcListener implements Listener on top of a gRPC server
+type grpcListener struct {
+	transport   *GRPCTransport
+	config      *ListenerConfig
+	server      *grpc.Server
+	netListener *trackingListener
+	startedAt   time.Time
+
+	// Accepted peers, indexed by remote address for per-connection metrics
+	accepted  chan *grpcConnection
+	byAddress map[string]*grpcConnection
+	mutex     sync.RWMutex
+
+	totalAccepted atomic.Int64
+	active        atomic.Int64
+	rejected      atomic.Int64
+	closed        atomic.Bool
+	lastError     atomic.Value
+	done          chan struct{}
+	closeOnce     sync.Once
+	asyncOnce     sync.Once
+	asyncChan     chan Connection
+}
+
+// Accept waits for the next peer to connect
+func (gl *grpcListener) Accept() (Connection, error) {
+	var timeout <-chan time.Time
+	if gl.config.AcceptTimeout > 0 {
+		timer := time.NewTimer(gl.config.AcceptTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case conn := <-gl.accepted:
+		return conn, nil
+	case <-gl.done:
+		return nil, &TransportError{Code: ErrorCodeConnectionClosed, Message: "listener is closed"}
+	case <-timeout:
+		return nil, &TransportError{Code: ErrorCodeConnectionTimeout, Message: "accept timed out", Temporary: true}
+	}
+}
+
+// AcceptAsync delivers incoming connections on a channel until the listener closes
+func (gl *grpcListener) AcceptAsync() <-chan Connection {
+	gl.asyncOnce.Do(func() {
+		gl.asyncChan = make(chan Connection)
+
+		go func() {
+			defer close(gl.asyncChan)
+			for {
+				select {
+				case conn := <-gl.accepted:
+					gl.asyncChan <- conn
+				case <-gl.done:
+					return
+				}
+			}
+		}()
+	})
+
+	return gl.asyncChan
+}
+
+// GetListenAddress returns the bound address
+func (gl *grpcListener) GetListenAddress() string {
+	return gl.netListener.Addr().String()
+}
+
+// GetListenerMetrics returns listener metrics
+func (gl *grpcListener) GetListenerMetrics() ListenerMetrics {
+	metrics := ListenerMetrics{
+		ListenAddress:       gl.GetListenAddress(),
+		StartedAt:           gl.startedAt,
+		TotalAccepted:       gl.totalAccepted.Load(),
+		ActiveConnections:   gl.active.Load(),
+		RejectedConnections: gl.rejected.Load(),
+		IsListening:         !gl.closed.Load(),
+	}
+
+	if elapsed := time.Since(gl.startedAt).Seconds(); elapsed > 0 {
+		metrics.AcceptRate = float64(metrics.TotalAccepted) / elapsed
+	}
+	if err, ok := gl.lastError.Load().(error); ok {
+		metrics.LastError = err
+	}
+
+	return metrics
+}
+
+// Close stops the gRPC server and disconnects all peers
+func (gl *grpcListener) Close() error {
+	gl.closeOnce.Do(func() {
+		gl.closed.Store(true)
+		close(gl.done)
+		gl.server.Stop()
+		gl.transport.removeListener(gl)
+	})
+	return nil
+}
+
+// admit registers a newly accepted TCP connection; it returns nil when the limit is reached
+func (gl *grpcListener) admit(netConn net.Conn) *grpcConnection {
+	if max := gl.config.MaxConnections; max > 0 && gl.active.Load() >= int64(max) {
+		gl.rejected.Add(1)
+		return nil
+	}
+
+	remoteAddress := netConn.RemoteAddr().String()
+	conn := &grpcConnection{
+		transport:     gl.transport,
+		config:        gl.transport.config,
+		address:       remoteAddress,
+		id:            newConnectionID("grpc-accepted"),
+		identity:      new(atomic.Pointer[PeerIdentity]),
+		establishedAt: time.Now(),
+		compression:   newPayloadCompressor(gl.transport.config, gl.transport.stats),
+	}
+	conn.onClose = func() {
+		gl.active.Add(-1)
+		gl.mutex.Lock()
+		delete(gl.byAddress, remoteAddress)
+		gl.mutex.Unlock()
+	}
+	conn.stats.touch()
+
+	gl.mutex.Lock()
+	gl.byAddress[remoteAddress] = conn
+	gl.mutex.Unlock()
+
+	gl.totalAccepted.Add(1)
+	gl.active.Add(1)
+	gl.transport.track(conn)
+
+	select {
+	case gl.accepted <- conn:
+	default: // Nobody is accepting; the connection is still served
+	}
+
+	return conn
+}
+
+// recordIdentity attaches the handshake identity to the accepted connection from remote
+func (gl *grpcListener) recordIdentity(remote net.Addr, identity *PeerIdentity) {
+	gl.mutex.RLock()
+	conn, exists := gl.byAddress[remote.String()]
+	gl.mutex.RUnlock()
+
+	if exists && identity != nil {
+		conn.identity.Store(identity)
+	}
+}
+
+// peerConnection finds the accepted connection that issued a call
+func (gl *grpcListener) peerConnection(ctx context.Context) *grpcConnection {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	gl.mutex.RLock()
+	defer gl.mutex.RUnlock()
+	return gl.byAddress[p.Addr.String()]
+}
+
+// trackingListener reports accepted TCP connections to the owning grpcListener
+type trackingListener struct {
+	net.Listener
+	owner *grpcListener
+}
+
+func (tl *trackingListener) Accept() (net.Conn, error) {
+	for {
+		netConn, err := tl.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		conn := tl.owner.admit(netConn)
+		if conn == nil {
+			netConn.Close()
+			continue
+		}
+
+		return &trackedConn{Conn: netConn, owner: conn}, nil
+	}
+}
+
+// trackedConn marks its accepted connection closed when the TCP connection closes
+type trackedConn struct {
+	net.Conn
+	owner *grpcConnection
+}
+
+func (tc *trackedConn) Close() error {
+	tc.owner.closed.Store(true)
+	tc.owner.markClosed()
+	return tc.Conn.Close()
+}
+
+// grpcMessage prefixes payload with its frame type. Unary calls and stream
+// messages share this format so both can carry compressed payloads.
+func grpcMessage(frameType byte, payload []byte) []byte {
+	message := make([]byte, 1+len(payload))
+	message[0] = frameType
+	copy(message[1:], payload)
+	return message
+}
+
+// splitGRPCMessage separates the frame type from a message
+func splitGRPCMessage(message []byte) (byte, []byte, error) {
+	if len(message) == 0 {
+		return 0, nil, &TransportError{Code: ErrorCodeProtocolError, Message: "empty message"}
+	}
+	return message[0], message[1:], nil
+}
+
+// grpcCallHandler serves unary requests on a grpcListener
+func grpcCallHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	listener := srv.(*grpcListener)
+
+	var payload []byte
+	if err := dec(&payload); err != nil {
+		return nil, err
+	}
+
+	handle := func(ctx context.Context, req interface{}) (interface{}, error) {
+		message := *req.(*[]byte)
+
+		conn := listener.peerConnection(ctx)
+		compression := newPayloadCompressor(listener.transport.config, listener.transport.stats)
+		if conn != nil {
+			compression = conn.compression
+		}
+
+		frameType, payload, err := splitGRPCMessage(message)
+		if err == nil {
+			frameType, payload, err = compression.decodeFrame(frameType, payload)
+		}
+		if err == nil && frameType != frameRequest {
+			err = fmt.Errorf("unexpected frame type 0x%02x", frameType)
+		}
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		request, acceptEncoding, err := decodeRequest(payload)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		request.Context = ctx
+
+		codec := compression.negotiate(acceptEncoding)
+		if conn != nil {
+			conn.peerCodec.Store(codec)
+		}
+
+		var response *Response
+		if request.Method == pingMethod {
+			response = serveRequest(func(*Request) *Response {
+				return &Response{StatusCode: 200, StatusMessage: "pong"}
+			}, request)
+		} else {
+			response = serveRequest(listener.config.Handler, request)
+		}
+
+		body, err := encodeResponse(response, compression.accepted())
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		reply := grpcMessage(compression.encodeFrame(frameResponse, codec, body))
+
+		if conn != nil {
+			conn.recordTransfer(len(reply), len(message))
+		}
+		return &reply, nil
+	}
+
+	if interceptor == nil {
+		return handle(ctx, &payload)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: grpcCallMethod}
+	return interceptor(ctx, &payload, info, handle)
+}
+
+// grpcStreamHandler hands peer-initiated streams to the listener's StreamHandler
+func grpcStreamHandler(srv interface{}, serverStream grpc.ServerStream) error {
+	listener := srv.(*grpcListener)
+	if listener.config.StreamHandler == nil {
+		return status.Error(codes.Unimplemented, "no stream handler registered")
+	}
+
+	ctx := serverStream.Context()
+	conn := listener.peerConnection(ctx)
+	if conn == nil {
+		return status.Error(codes.Unavailable, "connection is no longer tracked")
+	}
+
+	var streamID int64
+	var window int64
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(grpcStreamIDKey); len(values) > 0 {
+			streamID, _ = strconv.ParseInt(values[0], 10, 64)
+		}
+		if values := md.Get(grpcWindowKey); len(values) > 0 {
+			window, _ = strconv.ParseInt(values[0], 10, 32)
+		}
+		if values := md.Get(grpcAcceptEncodingKey); len(values) > 0 {
+			conn.peerCodec.Store(conn.compression.negotiate(strings.Split(values[0], ",")))
+		}
+	}
+
+	stream := newGRPCStream(serverStream, streamID, conn, 0, int32(window), nil)
+	listener.config.StreamHandler(stream)
+	stream.Close()
+
+	return nil
+}
+
+// grpcDialOptions derives client options from the transport configuration.
+// The server's identity is stored in identity after each TLS handshake.
+func grpcDialOptions(tm *tlsManager, config *TransportConfig, address string, identity *atomic.Pointer[PeerIdentity]) ([]grpc.DialOption, error) {
+	options := []grpc.DialOption{
+		grpc.WithBlock(),
+		grpc.WithReturnConnectionError(),
+		grpc.FailOnNonTempDialError(true),
+		grpc.WithDefaultCallOptions(
+			grpc.ForceCodec(rawCodec{}),
+			grpc.MaxCallRecvMsgSize(defaultMaxFrameSize),
+			grpc.MaxCallSendMsgSize(defaultMaxFrameSize),
+		),
+	}
+
+	// Dial through the dual-stack dialer rather than gRPC's own, which
+	// neither races address families nor honours IPv6Only
+	network := "tcp"
+	if config.IPv6Only {
+		network = "tcp6"
+	}
+	dialer := &net.Dialer{KeepAlive: config.KeepAliveTimeout}
+	options = append(options, grpc.WithContextDialer(func(ctx context.Context, target string) (net.Conn, error) {
+		return dialDualStack(ctx, target, config.IPv6Only, config.DualStackFallbackDelay,
+			func(ctx context.Context, address string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, address)
+			},
+			func(conn net.Conn) {
+				conn.Close()
+			},
+		)
+	}))
+
+	host := config.Address
+	if ea, err := ParseEndpointAddress(address, 0); err == nil {
+		host = ea.Host
+
+		// Zones are local to this node and are not part of the authority
+		if ea.Zone != "" {
+			options = append(options, grpc.WithAuthority(ea.HostPort()))
+		}
+	}
+
+	if config.EnableTLS || config.TLSConfig != nil {
+		tlsConfig, err := tm.clientConfig(config.TLSConfig, host, []string{"h2"})
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, grpc.WithTransportCredentials(&identityCredentials{
+			TransportCredentials: credentials.NewTLS(tlsConfig),
+			onHandshake: func(_ net.Addr, peerIdentity *PeerIdentity) {
+				identity.Store(peerIdentity)
+			},
+		}))
+	} else {
+		options = append(options, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+
+	if config.KeepAliveTimeout > 0 {
+		options = append(options, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                config.KeepAliveTimeout,
+			Timeout:             config.KeepAliveTimeout,
+			PermitWithoutStream: true,
+		}))
+	}
+
+	if config.BufferSize > 0 {
+		options = append(options,
+			grpc.WithInitialWindowSize(int32(config.BufferSize)),
+			grpc.WithInitialConnWindowSize(int32(config.BufferSize)*2),
+		)
+	}
+
+	return options, nil
+}
+
+// grpcServerOptions derives server options from the transport and listener configuration.
+// Listeners use their own TLSConfig, falling back to the transport's. Plaintext
+// HTTP/2 is served only when EnableTLS is off and neither config is set.
+func grpcServerOptions(tm *tlsManager, config *TransportConfig, listenerConfig *ListenerConfig, onHandshake func(net.Addr, *PeerIdentity)) ([]grpc.ServerOption, error) {
+	options := []grpc.ServerOption{
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.MaxConcurrentStreams(grpcDefaultMaxStreams),
+		grpc.MaxRecvMsgSize(defaultMaxFrameSize),
+		grpc.MaxSendMsgSize(defaultMaxFrameSize),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             5 * time.Second,
+			PermitWithoutStream: true,
+		}),
+	}
+
+	listenerTLS := listenerConfig.TLSConfig
+	if listenerTLS == nil {
+		listenerTLS = config.TLSConfig
+	}
+	if config.EnableTLS && listenerTLS == nil {
+		return nil, tlsError("TLS is enabled but no TLS configuration was given for the listener", nil)
+	}
+
+	if listenerTLS != nil {
+		tlsConfig, err := tm.serverConfig(listenerTLS, []string{"h2"})
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, grpc.Creds(&identityCredentials{
+			TransportCredentials: credentials.NewTLS(tlsConfig),
+			onHandshake:          onHandshake,
+		}))
+	}
+
+	if config.IdleTimeout > 0 {
+		options = append(options, grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionIdle: config.IdleTimeout,
+		}))
+	}
+
+	if config.BufferSize > 0 {
+		options = append(options,
+			grpc.InitialWindowSize(int32(config.BufferSize)),
+			grpc.InitialConnWindowSize(int32(config.BufferSize)*2),
+		)
+	}
+
+	return options, nil
+}
+
+// identityCredentials reports the peer identity of every completed TLS handshake
+type identityCredentials struct {
+	credentials.TransportCredentials
+	onHandshake func(remote net.Addr, identity *PeerIdentity)
+}
+
+func (ic *identityCredentials) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conn, authInfo, err := ic.TransportCredentials.ClientHandshake(ctx, authority, rawConn)
+	if err == nil {
+		ic.report(rawConn.RemoteAddr(), authInfo)
+	}
+	return conn, authInfo, err
+}
+
+func (ic *identityCredentials) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conn, authInfo, err := ic.TransportCredentials.ServerHandshake(rawConn)
+	if err == nil {
+		ic.report(rawConn.RemoteAddr(), authInfo)
+	}
+	return conn, authInfo, err
+}
+
+func (ic *identityCredentials) Clone() credentials.TransportCredentials {
+	return &identityCredentials{
+		TransportCredentials: ic.TransportCredentials.Clone(),
+		onHandshake:          ic.onHandshake,
+	}
+}
+
+func (ic *identityCredentials) report(remote net.Addr, authInfo credentials.AuthInfo) {
+	if tlsInfo, ok := authInfo.(credentials.TLSInfo); ok && ic.onHandshake != nil {
+		ic.onHandshake(remote, peerIdentityFromState(tlsInfo.State))
+	}
+}
+
+// grpcError maps gRPC status codes onto TransportError codes
+func grpcError(err error) error {
+	var transportErr *TransportError
+	if errors.As(err, &transportErr) {
+		return transportErr
+	}
+
+	st, _ := status.FromError(err)
+	transportErr = &TransportError{Message: st.Message(), Cause: err}
+
+	switch st.Code() {
+	case codes.DeadlineExceeded:
+		transportErr.Code = ErrorCodeRequestTimeout
+		transportErr.Retryable = true
+		transportErr.Temporary = true
+	case codes.Canceled:
+		transportErr.Code = ErrorCodeConnectionClosed
+	case codes.Unavailable:
+		transportErr.Code = ErrorCodeServerUnavailable
+		transportErr.Retryable = true
+		transportErr.Temporary = true
+	case codes.ResourceExhausted:
+		transportErr.Code = ErrorCodeResourceExhausted
+		transportErr.Retryable = true
+		transportErr.Temporary = true
+	case codes.Unauthenticated, codes.PermissionDenied:
+		transportErr.Code = ErrorCodeTLSError
+	case codes.InvalidArgument, codes.Unimplemented, codes.Internal:
+		transportErr.Code = ErrorCodeProtocolError
+	default:
+		transportErr.Code = ErrorCodeUnknown
+	}
+
+	return transportErr
+}
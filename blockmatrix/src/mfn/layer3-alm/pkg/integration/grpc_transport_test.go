@@ -0,0 +1,40 @@
+package integration
+
+import "testing"
+
+func TestGRPCServerOptionsRequireTLSConfig(t *testing.T) {
+	cases := []struct {
+		name        string
+		enableTLS   bool
+		transport   *TLSConfig
+		listener    *TLSConfig
+		wantErr     bool
+		wantOptions int
+	}{
+		{"plaintext", false, nil, nil, false, 5},
+		{"tls without config", true, nil, nil, true, 0},
+		{"listener config", true, nil, &TLSConfig{}, false, 6},
+		{"transport config", true, &TLSConfig{}, nil, false, 6},
+		{"listener config without EnableTLS", false, nil, &TLSConfig{}, false, 6},
+	}
+
+	manager := newTLSManager()
+	defer manager.Close()
+
+	for _, tc := range cases {
+		config := &TransportConfig{EnableTLS: tc.enableTLS, TLSConfig: tc.transport}
+		options, err := grpcServerOptions(manager, config, &ListenerConfig{TLSConfig: tc.listener}, nil)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if len(options) != tc.wantOptions {
+			t.Errorf("%s: got %d options, want %d", tc.name, len(options), tc.wantOptions)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+// Package integration implements predictive service health for circuit decisions
+package integration
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// HealthPredictor forecasts the error rate and latency of services from
+// periodic EndpointMetrics samples and converts the forecast into a
+// probability of imminent failure.
+//
+// Each signal is modelled with exponentially weighted level and trend terms
+// plus a time-of-day seasonal offset, so a service that degrades every
+// evening is anticipated rather than only observed. Confidence grows with the
+// number of samples and shrinks as one-step forecast errors grow.
+type HealthPredictor struct {
+	config   *HealthPredictorConfig
+	services map[string]*serviceHealthModel
+	mutex    sync.Mutex
+}
+
+// HealthPredictorConfig configures a HealthPredictor
+type HealthPredictorConfig struct {
+	// Smoothing factors (0-1) for level, trend and seasonal terms
+	LevelAlpha    float64
+	TrendBeta     float64
+	SeasonalGamma float64
+
+	// Seasonal period divided into SeasonSlots offsets
+	SeasonLength time.Duration
+	SeasonSlots  int
+
+	// How far ahead predictions look
+	Horizon time.Duration
+
+	// Samples closer together than MinSampleInterval are ignored, and error
+	// rates are only derived from at least MinRequests new requests
+	MinSampleInterval time.Duration
+	MinRequests       int64
+
+	// Predicted values at which failure becomes likely
+	ErrorRateThreshold float64
+	LatencyThreshold   time.Duration
+
+	// Samples needed before predictions are returned
+	MinSamples int
+}
+
+// HealthSample is one observation of a service. Requests and Failures are
+// cumulative counters; a zero AverageLatency means latency is unknown.
+type HealthSample struct {
+	Requests       int64
+	Failures       int64
+	AverageLatency time.Duration
+	Timestamp      time.Time
+}
+
+// HealthPrediction is the forecast health of a service at Horizon
+type HealthPrediction struct {
+	ServiceID string
+
+	// Probability (0-1) that the service fails within Horizon
+	FailureProbability float64
+
+	PredictedErrorRate float64
+	PredictedLatency   time.Duration
+
+	// Trends per minute
+	ErrorRateTrend float64
+	LatencyTrend   time.Duration
+
+	// Confidence (0-1) in the prediction
+	Confidence float64
+
+	Samples     int
+	Horizon     time.Duration
+	GeneratedAt time.Time
+}
+
+// serviceHealthModel holds the per-service forecasting state
+type serviceHealthModel struct {
+	errorRate ewmaSeries
+	latency   ewmaSeries
+
+	samples      int
+	lastSample   time.Time
+	lastRequests int64
+	lastFailures int64
+	hasCounters  bool
+}
+
+// ewmaSeries tracks the level, per-second trend and seasonal offsets of one signal
+type ewmaSeries struct {
+	level    float64
+	trend    float64
+	seasonal []float64
+
+	// Smoothed absolute one-step forecast error
+	residual float64
+
+	lastUpdate  time.Time
+	initialized bool
+}
+
+// NewHealthPredictor creates a health predictor
+func NewHealthPredictor(config *HealthPredictorConfig) *HealthPredictor {
+	if config == nil {
+		config = DefaultHealthPredictorConfig()
+	}
+	if config.SeasonSlots <= 0 {
+		config.SeasonSlots = 1
+	}
+
+	return &HealthPredictor{
+		config:   config,
+		services: make(map[string]*serviceHealthModel),
+	}
+}
+
+// Observe adds a sample for serviceID
+func (hp *HealthPredictor) Observe(serviceID string, sample HealthSample) {
+	if sample.Timestamp.IsZero() {
+		sample.Timestamp = time.Now()
+	}
+
+	hp.mutex.Lock()
+	defer hp.mutex.Unlock()
+
+	model, exists := hp.services[serviceID]
+	if !exists {
+		model = &serviceHealthModel{}
+		hp.services[serviceID] = model
+	}
+
+	if model.samples > 0 && sample.Timestamp.Sub(model.lastSample) < hp.config.MinSampleInterval {
+		return
+	}
+
+	observed := false
+	rebaseline := false
+
+	requests := sample.Requests - model.lastRequests
+	failures := sample.Failures - model.lastFailures
+	switch {
+	case !model.hasCounters || requests < 0 || failures < 0:
+		// First sample, or counters went backwards after an endpoint restart
+		rebaseline = true
+	case requests > 0 && requests >= hp.config.MinRequests:
+		model.errorRate.observe(float64(failures)/float64(requests), sample.Timestamp, hp.config)
+		observed = true
+		rebaseline = true
+	}
+
+	// Below MinRequests, requests keep accumulating against the old baseline
+	if rebaseline {
+		model.lastRequests = sample.Requests
+		model.lastFailures = sample.Failures
+		model.hasCounters = true
+	}
+
+	if sample.AverageLatency > 0 {
+		model.latency.observe(sample.AverageLatency.Seconds(), sample.Timestamp, hp.config)
+		observed = true
+	}
+
+	if observed {
+		model.samples++
+		model.lastSample = sample.Timestamp
+	}
+}
+
+// ObserveEndpoints adds a sample aggregated over the metrics of a service's endpoints
+func (hp *HealthPredictor) ObserveEndpoints(serviceID string, endpoints []*Endpoint) bool {
+	var sample HealthSample
+	var latencyWeight float64
+	var weightedLatency float64
+
+	for _, endpoint := range endpoints {
+		metrics := endpoint.Metrics
+		if metrics == nil {
+			continue
+		}
+
+		sample.Requests += metrics.RequestCount
+		sample.Failures += metrics.FailureCount
+		if metrics.LastUpdated.After(sample.Timestamp) {
+			sample.Timestamp = metrics.LastUpdated
+		}
+
+		if metrics.AverageLatency > 0 {
+			weight := math.Max(float64(metrics.RequestCount), 1)
+			weightedLatency += float64(metrics.AverageLatency) * weight
+			latencyWeight += weight
+		}
+	}
+
+	if sample.Timestamp.IsZero() {
+		return false
+	}
+	if latencyWeight > 0 {
+		sample.AverageLatency = time.Duration(weightedLatency / latencyWeight)
+	}
+
+	hp.Observe(serviceID, sample)
+	return true
+}
+
+// Predict forecasts the health of serviceID at the configured horizon
+func (hp *HealthPredictor) Predict(serviceID string) (*HealthPrediction, error) {
+	hp.mutex.Lock()
+	defer hp.mutex.Unlock()
+
+	model, exists := hp.services[serviceID]
+	if !exists || model.samples < hp.config.MinSamples {
+		return nil, fmt.Errorf("insufficient health samples for service %s", serviceID)
+	}
+
+	now := time.Now()
+	at := now.Add(hp.config.Horizon)
+
+	prediction := &HealthPrediction{
+		ServiceID:   serviceID,
+		Samples:     model.samples,
+		Horizon:     hp.config.Horizon,
+		GeneratedAt: now,
+	}
+
+	survival := 1.0
+	stability := 1.0
+
+	if model.errorRate.initialized {
+		prediction.PredictedErrorRate = math.Max(0, math.Min(1, model.errorRate.forecast(at, hp.config)))
+		prediction.ErrorRateTrend = model.errorRate.trend * 60
+
+		threshold := hp.config.ErrorRateThreshold
+		survival *= 1 - logistic((prediction.PredictedErrorRate-threshold)/(0.2*threshold))
+		stability += model.errorRate.residual / threshold
+	}
+
+	if model.latency.initialized {
+		seconds := math.Max(0, model.latency.forecast(at, hp.config))
+		prediction.PredictedLatency = time.Duration(seconds * float64(time.Second))
+		prediction.LatencyTrend = time.Duration(model.latency.trend * 60 * float64(time.Second))
+
+		threshold := hp.config.LatencyThreshold.Seconds()
+		survival *= 1 - logistic((seconds/threshold-1)/0.2)
+		stability += model.latency.residual / threshold
+	}
+
+	prediction.FailureProbability = 1 - survival
+
+	sampleFactor := 1 - math.Exp(-float64(model.samples)/float64(2*hp.config.MinSamples))
+	prediction.Confidence = sampleFactor / stability
+
+	return prediction, nil
+}
+
+// Forget drops the model for a service
+func (hp *HealthPredictor) Forget(serviceID string) {
+	hp.mutex.Lock()
+	defer hp.mutex.Unlock()
+	delete(hp.services, serviceID)
+}
+
+// observe updates the series with value observed at time at
+func (es *ewmaSeries) observe(value float64, at time.Time, config *HealthPredictorConfig) {
+	if es.seasonal == nil {
+		es.seasonal = make([]float64, config.SeasonSlots)
+	}
+	slot := seasonSlot(at, config)
+
+	if !es.initialized {
+		es.level = value
+		es.lastUpdate = at
+		es.initialized = true
+		return
+	}
+
+	elapsed := at.Sub(es.lastUpdate).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	season := es.seasonal[slot]
+	predicted := es.level + es.trend*elapsed + season
+	es.residual = (1-config.LevelAlpha)*es.residual + config.LevelAlpha*math.Abs(value-predicted)
+
+	level := config.LevelAlpha*(value-season) + (1-config.LevelAlpha)*(es.level+es.trend*elapsed)
+	es.trend = config.TrendBeta*(level-es.level)/elapsed + (1-config.TrendBeta)*es.trend
+	es.level = level
+	es.seasonal[slot] = config.SeasonalGamma*(value-level) + (1-config.SeasonalGamma)*season
+	es.lastUpdate = at
+}
+
+// forecast extrapolates the series to time at
+func (es *ewmaSeries) forecast(at time.Time, config *HealthPredictorConfig) float64 {
+	value := es.level + es.trend*at.Sub(es.lastUpdate).Seconds()
+	if es.seasonal != nil {
+		value += es.seasonal[seasonSlot(at, config)]
+	}
+	return value
+}
+
+// seasonSlot returns the seasonal offset index for time at
+func seasonSlot(at time.Time, config *HealthPredictorConfig) int {
+	if config.SeasonSlots <= 1 || config.SeasonLength <= 0 {
+		return 0
+	}
+	position := time.Duration(at.UnixNano()) % config.SeasonLength
+	return int(position * time.Duration(config.SeasonSlots) / config.SeasonLength)
+}
+
+func logistic(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+// DefaultHealthPredictorConfig returns default health predictor configuration
+func DefaultHealthPredictorConfig() *HealthPredictorConfig {
+	return &HealthPredictorConfig{
+		LevelAlpha:         0.3,
+		TrendBeta:          0.1,
+		SeasonalGamma:      0.05,
+		SeasonLength:       24 * time.Hour,
+		SeasonSlots:        24,
+		Horizon:            30 * time.Second,
+		MinSampleInterval:  time.Second,
+		MinRequests:        10,
+		ErrorRateThreshold: 0.5,
+		LatencyThreshold:   time.Second,
+		MinSamples:         5,
+	}
+}
@@ -0,0 +1,145 @@
+package integration
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEWMASeriesSmoothing(t *testing.T) {
+	config := &HealthPredictorConfig{LevelAlpha: 0.5, TrendBeta: 0.5, SeasonSlots: 1}
+	start := time.Unix(1_000_000, 0)
+
+	var series ewmaSeries
+	series.observe(10, start, config)
+	if series.level != 10 || series.trend != 0 {
+		t.Fatalf("first sample: level %v trend %v, want 10 and 0", series.level, series.trend)
+	}
+
+	// predicted 10: level 0.5*20 + 0.5*10 = 15, trend 0.5*(15-10)/1s = 2.5
+	series.observe(20, start.Add(time.Second), config)
+	if series.level != 15 || series.trend != 2.5 || series.residual != 5 {
+		t.Fatalf("second sample: level %v trend %v residual %v, want 15, 2.5, 5",
+			series.level, series.trend, series.residual)
+	}
+
+	// predicted 15 + 2.5 = 17.5: level 18.75, trend 0.5*3.75 + 0.5*2.5 = 3.125
+	series.observe(20, start.Add(2*time.Second), config)
+	if series.level != 18.75 || series.trend != 3.125 || series.residual != 3.75 {
+		t.Fatalf("third sample: level %v trend %v residual %v, want 18.75, 3.125, 3.75",
+			series.level, series.trend, series.residual)
+	}
+
+	if got := series.forecast(start.Add(4*time.Second), config); got != 25 {
+		t.Errorf("forecast two seconds ahead = %v, want 25", got)
+	}
+}
+
+func TestEWMASeriesIgnoresOutOfOrderSamples(t *testing.T) {
+	config := &HealthPredictorConfig{LevelAlpha: 0.5, TrendBeta: 0.5, SeasonSlots: 1}
+	start := time.Unix(1_000_000, 0)
+
+	var series ewmaSeries
+	series.observe(10, start, config)
+	series.observe(50, start, config)
+	series.observe(50, start.Add(-time.Second), config)
+	if series.level != 10 || series.trend != 0 {
+		t.Errorf("level %v trend %v after samples without elapsed time, want 10 and 0", series.level, series.trend)
+	}
+}
+
+func TestSeasonSlot(t *testing.T) {
+	config := &HealthPredictorConfig{SeasonLength: 4 * time.Hour, SeasonSlots: 4}
+
+	cases := []struct {
+		offset time.Duration
+		want   int
+	}{
+		{0, 0},
+		{90 * time.Minute, 1},
+		{3*time.Hour + 59*time.Minute, 3},
+		{4 * time.Hour, 0},
+	}
+	for _, tc := range cases {
+		if got := seasonSlot(time.Unix(0, 0).Add(tc.offset), config); got != tc.want {
+			t.Errorf("seasonSlot(+%v) = %d, want %d", tc.offset, got, tc.want)
+		}
+	}
+}
+
+// observeErrorRate feeds samples failing at rate, one second apart, ending now
+func observeErrorRate(predictor *HealthPredictor, serviceID string, rate float64, samples int) {
+	start := time.Now().Add(-time.Duration(samples) * time.Second)
+	for i := 0; i <= samples; i++ {
+		requests := int64(i * 100)
+		predictor.Observe(serviceID, HealthSample{
+			Requests:  requests,
+			Failures:  int64(float64(requests) * rate),
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+		})
+	}
+}
+
+func TestHealthPredictorThreshold(t *testing.T) {
+	predictor := NewHealthPredictor(DefaultHealthPredictorConfig())
+
+	cases := []struct {
+		serviceID string
+		rate      float64
+		min, max  float64
+	}{
+		{"healthy", 0.1, 0, 0.05},
+		{"at-threshold", 0.5, 0.499, 0.501},
+		{"failing", 0.9, 0.95, 1},
+	}
+	for _, tc := range cases {
+		observeErrorRate(predictor, tc.serviceID, tc.rate, 10)
+
+		prediction, err := predictor.Predict(tc.serviceID)
+		if err != nil {
+			t.Fatalf("Predict(%s): %v", tc.serviceID, err)
+		}
+		if math.Abs(prediction.PredictedErrorRate-tc.rate) > 1e-9 {
+			t.Errorf("%s: predicted error rate %v, want %v", tc.serviceID, prediction.PredictedErrorRate, tc.rate)
+		}
+		if p := prediction.FailureProbability; p < tc.min || p > tc.max {
+			t.Errorf("%s: failure probability %v, want between %v and %v", tc.serviceID, p, tc.min, tc.max)
+		}
+	}
+}
+
+func TestHealthPredictorNeedsMinSamples(t *testing.T) {
+	predictor := NewHealthPredictor(DefaultHealthPredictorConfig())
+
+	// The first sample only sets the counter baseline
+	observeErrorRate(predictor, "orders", 0.1, 4)
+	if _, err := predictor.Predict("orders"); err == nil {
+		t.Fatal("Predict returned a prediction from 4 samples, want MinSamples 5")
+	}
+
+	observeErrorRate(predictor, "payments", 0.1, 5)
+	if _, err := predictor.Predict("payments"); err != nil {
+		t.Errorf("Predict with MinSamples samples: %v", err)
+	}
+}
+
+func TestHealthPredictorAccumulatesBelowMinRequests(t *testing.T) {
+	predictor := NewHealthPredictor(DefaultHealthPredictorConfig())
+	start := time.Now().Add(-time.Minute)
+
+	// Five requests at a time fall below MinRequests, so pairs are combined
+	for i := 0; i <= 4; i++ {
+		predictor.Observe("orders", HealthSample{
+			Requests:  int64(i * 5),
+			Failures:  int64(i * 5),
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	predictor.mutex.Lock()
+	samples := predictor.services["orders"].samples
+	predictor.mutex.Unlock()
+	if samples != 2 {
+		t.Errorf("recorded %d error rate samples from 4 small intervals, want 2", samples)
+	}
+}
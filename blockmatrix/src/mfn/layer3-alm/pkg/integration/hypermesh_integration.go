@@ -4,11 +4,13 @@ package integration
 import (
 	"context"
 	"fmt"
+	"math"
 	"sync"
 	"time"
 
 	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
-	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/service"
 	"go.uber.org/zap"
 )
 
@@ -29,6 +31,23 @@ type HyperMeshIntegration struct {
 	// Performance tracking
 	integrationMetrics *IntegrationMetrics
 	
+	// Predictive health model behind EnhanceCircuitBreaker
+	healthPredictor *HealthPredictor
+	
+	// Admission control for discovery and routing; nil when disabled
+	rateLimiter *RateLimiter
+	
+	// Event bus publication
+	events      *EventPublisher
+	eventsWired bool
+	
+	// Milestone webhooks
+	webhooks      *WebhookNotifier
+	webhooksWired bool
+	
+	// Ledger-anchored routing audit
+	auditTrail *AuditTrail
+	
 	// Configuration
 	config *IntegrationConfig
 	
@@ -87,6 +106,12 @@ type IntegrationConfig struct {
 	// Monitoring
 	MetricsCollectionInterval time.Duration
 	PerformanceReportInterval time.Duration
+	
+	// Webhook alert once the improvement target is missed this long
+	TargetMissAlertAfter      time.Duration
+	
+	// Per-service and per-tenant request limits; nil disables limiting
+	RateLimits                *RateLimitConfig
 }
 
 // HyperMeshService represents a service in the HyperMesh environment
@@ -151,12 +176,27 @@ func NewHyperMeshIntegration(
 		logger = zap.NewNop()
 	}
 	
+	if circuitBreaker == nil {
+		circuitBreaker = NewCircuitBreaker(nil)
+	}
+	
+	if serviceDiscovery == nil && almCoordinator != nil {
+		serviceDiscovery = NewRegistryServiceDiscovery(almCoordinator.ServiceRegistry())
+	}
+	
+	var rateLimiter *RateLimiter
+	if config.RateLimits != nil {
+		rateLimiter = NewRateLimiter(config.RateLimits)
+	}
+	
 	return &HyperMeshIntegration{
 		almCoordinator:     almCoordinator,
 		serviceDiscovery:   serviceDiscovery,
 		loadBalancer:      loadBalancer,
 		circuitBreaker:    circuitBreaker,
 		integrationMetrics: NewIntegrationMetrics(),
+		healthPredictor:   NewHealthPredictor(nil),
+		rateLimiter:       rateLimiter,
 		config:            config,
 		logger:            logger,
 	}
@@ -193,9 +233,22 @@ func (hmi *HyperMeshIntegration) Initialize(ctx context.Context) error {
 	// Start metrics collection
 	go hmi.startMetricsCollection(ctx)
 	
+	// Watch the improvement target for milestone webhooks
+	go hmi.monitorTargetAchievement(ctx)
+	
 	hmi.isIntegrated = true
 	hmi.integrationTime = time.Now()
 	
+	if hmi.webhooks != nil {
+		hmi.webhooks.Notify(WebhookEventIntegrationInitialized, hmi.config.ServiceMeshNamespace, &IntegrationInitializedEvent{
+			Namespace:                 hmi.config.ServiceMeshNamespace,
+			EnableServiceMesh:         hmi.config.EnableServiceMesh,
+			EnableRoutingOptimization: hmi.config.EnableRoutingOptimization,
+			EnableLoadBalancingAI:     hmi.config.EnableLoadBalancingAI,
+			EnableCircuitBreakerAI:    hmi.config.EnableCircuitBreakerAI,
+		})
+	}
+	
 	hmi.logger.Info("HyperMesh integration with ALM Layer 3 initialized successfully")
 	
 	return nil
@@ -205,6 +258,14 @@ func (hmi *HyperMeshIntegration) Initialize(ctx context.Context) error {
 func (hmi *HyperMeshIntegration) EnhanceServiceDiscovery(ctx context.Context, query *ServiceQuery) ([]*HyperMeshService, error) {
 	startTime := time.Now()
 	
+	serviceName := ""
+	if query != nil {
+		serviceName = query.ServiceName
+	}
+	if err := hmi.admit(ctx, serviceName); err != nil {
+		return nil, err
+	}
+	
 	// Convert HyperMesh query to ALM format
 	almQuery := hmi.convertToALMServiceQuery(query)
 	
@@ -212,6 +273,13 @@ func (hmi *HyperMeshIntegration) EnhanceServiceDiscovery(ctx context.Context, qu
 	almResponse, err := hmi.almCoordinator.DiscoverServices(ctx, almQuery)
 	if err != nil {
 		hmi.logger.Error("ALM service discovery failed", zap.Error(err))
+		if webhooks := hmi.webhookNotifier(); webhooks != nil && query != nil {
+			webhooks.Notify(WebhookEventDiscoveryFallback, query.ServiceName, &DiscoveryFallbackEvent{
+				ServiceName: query.ServiceName,
+				Namespace:   query.Namespace,
+				Error:       err.Error(),
+			})
+		}
 		// Fallback to native HyperMesh discovery
 		return hmi.serviceDiscovery.DiscoverServices(query)
 	}
@@ -221,6 +289,9 @@ func (hmi *HyperMeshIntegration) EnhanceServiceDiscovery(ctx context.Context, qu
 	
 	// Enhance with HyperMesh-specific data
 	enhancedServices := hmi.enhanceServicesWithHyperMeshData(services)
+	if query != nil && query.MaxResults > 0 && len(enhancedServices) > query.MaxResults {
+		enhancedServices = enhancedServices[:query.MaxResults]
+	}
 	
 	// Record performance improvement
 	discoveryTime := time.Since(startTime)
@@ -239,6 +310,10 @@ func (hmi *HyperMeshIntegration) EnhanceServiceDiscovery(ctx context.Context, qu
 func (hmi *HyperMeshIntegration) OptimizeRouting(ctx context.Context, source, destination string, constraints *RoutingConstraints) (*RoutingDecision, error) {
 	startTime := time.Now()
 	
+	if err := hmi.admit(ctx, destination); err != nil {
+		return nil, err
+	}
+	
 	// Convert service names to node IDs
 	sourceNodeID, err := hmi.resolveServiceToNodeID(source)
 	if err != nil {
@@ -278,6 +353,7 @@ func (hmi *HyperMeshIntegration) OptimizeRouting(ctx context.Context, source, de
 		QualityScore:   routeResp.QualityScore,
 		Confidence:     routeResp.Confidence,
 		AlternativePaths: hmi.convertAlternativePaths(routeResp.Alternatives),
+		Weight:         routeResp.Weight,
 		DecisionTime:   time.Since(startTime),
 		ImprovementFactor: hmi.calculateRoutingImprovement(routeResp.SearchTime),
 	}
@@ -285,6 +361,14 @@ func (hmi *HyperMeshIntegration) OptimizeRouting(ctx context.Context, source, de
 	// Record routing optimization
 	hmi.integrationMetrics.RecordRouting(decision.DecisionTime, decision.ImprovementFactor)
 	
+	if auditTrail := hmi.auditTrailRecorder(); auditTrail != nil {
+		auditTrail.RecordRoutingDecision(source, destination, decision)
+	}
+	
+	if events := hmi.eventPublisher(); events != nil {
+		events.PublishRoutingDecision(source, destination, constraints, decision)
+	}
+	
 	hmi.logger.Debug("Routing optimization completed",
 		zap.Duration("decision_time", decision.DecisionTime),
 		zap.Float64("improvement_factor", decision.ImprovementFactor),
@@ -337,6 +421,11 @@ func (hmi *HyperMeshIntegration) EnhanceCircuitBreaker(ctx context.Context, serv
 		return nil, fmt.Errorf("failed to check circuit state: %w", err)
 	}
 	
+	// Predictive decisions are canaried behind a feature flag
+	if !hmi.almCoordinator.FeatureFlags().Enabled(ctx, internal.FeaturePredictiveCircuitBreaking, true) {
+		return hmi.standardCircuitDecision(circuitState), nil
+	}
+	
 	// Use ALM to predict service health and network conditions
 	prediction, err := hmi.predictServiceHealth(ctx, serviceID)
 	if err != nil {
@@ -373,7 +462,7 @@ func (hmi *HyperMeshIntegration) GetIntegrationMetrics() *IntegrationPerformance
 		return nil
 	}
 	
-	return &IntegrationPerformanceMetrics{
+	metrics := &IntegrationPerformanceMetrics{
 		IntegrationUptime:     time.Since(hmi.integrationTime),
 		ServiceDiscoveryImprovement: hmi.integrationMetrics.GetServiceDiscoveryImprovement(),
 		RoutingImprovement:          hmi.integrationMetrics.GetRoutingImprovement(),
@@ -382,6 +471,222 @@ func (hmi *HyperMeshIntegration) GetIntegrationMetrics() *IntegrationPerformance
 		OverallImprovementFactor:    hmi.calculateOverallImprovement(),
 		TargetAchievement:          hmi.calculateTargetAchievement(),
 	}
+	
+	if hmi.rateLimiter != nil {
+		rateLimits := hmi.rateLimiter.Stats()
+		metrics.RateLimits = &rateLimits
+	}
+	
+	return metrics
+}
+
+// SetEventPublisher publishes routing decisions, circuit state changes,
+// topology updates and latency SLO alerts through publisher. Passing nil stops publication.
+func (hmi *HyperMeshIntegration) SetEventPublisher(publisher *EventPublisher) {
+	hmi.mutex.Lock()
+	defer hmi.mutex.Unlock()
+	
+	hmi.events = publisher
+	if publisher == nil || hmi.eventsWired {
+		return
+	}
+	
+	// Observers are registered once and forward to the current publisher
+	if cb, ok := hmi.circuitBreaker.(*CircuitBreaker); ok {
+		cb.OnStateChange(func(serviceID string, from, to BreakerState) {
+			if events := hmi.eventPublisher(); events != nil {
+				metrics, _ := cb.GetCircuitMetrics(serviceID)
+				events.PublishCircuitStateChange(serviceID, from, to, metrics)
+			}
+		})
+	}
+	
+	if hmi.almCoordinator != nil {
+		hmi.almCoordinator.OnTopologyUpdate(func(updates []internal.TopologyUpdate) {
+			if events := hmi.eventPublisher(); events != nil {
+				events.PublishTopologyUpdates(updates)
+			}
+		})
+		hmi.almCoordinator.OnSLOAlert(func(alert routing.SLOAlert) {
+			if events := hmi.eventPublisher(); events != nil {
+				events.PublishSLOAlert(alert)
+			}
+		})
+	}
+	
+	hmi.eventsWired = true
+}
+
+// RateLimiter returns the limiter applied to discovery and routing, or nil
+// when rate limiting is disabled
+func (hmi *HyperMeshIntegration) RateLimiter() *RateLimiter {
+	return hmi.rateLimiter
+}
+
+// BackpressureHandler returns a handler for TransportConfig.BackpressureHandler
+// that feeds stream flow control pressure into ALM load balancing. Addresses
+// are mapped to nodes through the registered service endpoints; pressure
+// towards unknown addresses is ignored.
+func (hmi *HyperMeshIntegration) BackpressureHandler() BackpressureHandler {
+	return func(remoteAddress string, pressure float64) {
+		resolver, ok := hmi.serviceDiscovery.(addressResolver)
+		if !ok || hmi.almCoordinator == nil {
+			return
+		}
+		
+		if nodeID, found := resolver.nodeForAddress(remoteAddress); found {
+			hmi.almCoordinator.RoutingTable().UpdateNodeBackpressure(nodeID, pressure)
+		}
+	}
+}
+
+// NodeProbe returns a probe for ALMCoordinator.AttachNodeProbe that pings a
+// node over a pooled connection to one of its service endpoints. Nodes
+// hosting no known endpoint cannot be probed and are reported reachable.
+func (hmi *HyperMeshIntegration) NodeProbe(pool *ConnectionPool) routing.NodeProbe {
+	return func(ctx context.Context, nodeID int64) error {
+		resolver, ok := hmi.serviceDiscovery.(addressResolver)
+		if !ok {
+			return nil
+		}
+		
+		address, found := resolver.addressForNode(nodeID)
+		if !found {
+			return nil
+		}
+		return pool.Ping(ctx, address)
+	}
+}
+
+// admit charges a request for service to the tenant of ctx. Requests without
+// a service name share one bucket.
+func (hmi *HyperMeshIntegration) admit(ctx context.Context, service string) error {
+	if hmi.rateLimiter == nil {
+		return nil
+	}
+	
+	if service == "" {
+		service = "*"
+	}
+	
+	tenant := TenantFromContext(ctx)
+	if err := hmi.rateLimiter.Allow(tenant, service); err != nil {
+		hmi.logger.Debug("Request rate limited",
+			zap.String("tenant", tenant),
+			zap.String("service", service),
+			zap.Error(err),
+		)
+		return err
+	}
+	
+	return nil
+}
+
+func (hmi *HyperMeshIntegration) eventPublisher() *EventPublisher {
+	hmi.mutex.RLock()
+	defer hmi.mutex.RUnlock()
+	return hmi.events
+}
+
+// SetAuditTrail records routing decisions in trail. Passing nil stops
+// recording; running and anchoring the trail is left to the caller.
+func (hmi *HyperMeshIntegration) SetAuditTrail(trail *AuditTrail) {
+	hmi.mutex.Lock()
+	defer hmi.mutex.Unlock()
+	
+	hmi.auditTrail = trail
+}
+
+func (hmi *HyperMeshIntegration) auditTrailRecorder() *AuditTrail {
+	hmi.mutex.RLock()
+	defer hmi.mutex.RUnlock()
+	return hmi.auditTrail
+}
+
+// SetWebhookNotifier sends integration milestone webhooks through notifier.
+// Passing nil stops delivery.
+func (hmi *HyperMeshIntegration) SetWebhookNotifier(notifier *WebhookNotifier) {
+	hmi.mutex.Lock()
+	defer hmi.mutex.Unlock()
+	
+	hmi.webhooks = notifier
+	if notifier == nil || hmi.webhooksWired {
+		return
+	}
+	
+	if cb, ok := hmi.circuitBreaker.(*CircuitBreaker); ok {
+		cb.OnStateChange(func(serviceID string, from, to BreakerState) {
+			if to != CircuitOpen {
+				return
+			}
+			if webhooks := hmi.webhookNotifier(); webhooks != nil {
+				data := &CircuitStateEvent{
+					ServiceID: serviceID,
+					From:      from.String(),
+					To:        to.String(),
+				}
+				if metrics, err := cb.GetCircuitMetrics(serviceID); err == nil && metrics != nil {
+					data.FailureRate = metrics.FailureRate
+					data.SlowCallRate = metrics.SlowCallRate
+					data.WindowCalls = metrics.WindowCalls
+				}
+				webhooks.Notify(WebhookEventCircuitOpened, serviceID, data)
+			}
+		})
+	}
+	
+	hmi.webhooksWired = true
+}
+
+func (hmi *HyperMeshIntegration) webhookNotifier() *WebhookNotifier {
+	hmi.mutex.RLock()
+	defer hmi.mutex.RUnlock()
+	return hmi.webhooks
+}
+
+// monitorTargetAchievement sends a webhook once per episode in which the
+// improvement target stays missed for TargetMissAlertAfter
+func (hmi *HyperMeshIntegration) monitorTargetAchievement(ctx context.Context) {
+	if hmi.config.TargetMissAlertAfter <= 0 || hmi.config.PerformanceReportInterval <= 0 {
+		return
+	}
+	
+	ticker := time.NewTicker(hmi.config.PerformanceReportInterval)
+	defer ticker.Stop()
+	
+	var missedSince time.Time
+	alerted := false
+	
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			achievement := hmi.calculateTargetAchievement()
+			if achievement >= 100.0 {
+				missedSince = time.Time{}
+				alerted = false
+				continue
+			}
+			
+			if missedSince.IsZero() {
+				missedSince = now
+			}
+			if alerted || now.Sub(missedSince) < hmi.config.TargetMissAlertAfter {
+				continue
+			}
+			
+			if webhooks := hmi.webhookNotifier(); webhooks != nil {
+				webhooks.Notify(WebhookEventTargetMissed, hmi.config.ServiceMeshNamespace, &TargetMissedEvent{
+					TargetImprovement:  hmi.config.TargetLatencyReduction,
+					OverallImprovement: hmi.calculateOverallImprovement(),
+					TargetAchievement:  achievement,
+					MissedSince:        missedSince,
+				})
+				alerted = true
+			}
+		}
+	}
 }
 
 // Helper methods for integration logic
@@ -400,6 +705,223 @@ func (hmi *HyperMeshIntegration) startServiceMeshIntegration(ctx context.Context
 	}
 }
 
+// predictServiceHealth samples the current metrics of a service and forecasts
+// its health. Endpoint metrics from discovery are preferred; services without
+// them are sampled from circuit breaker call counts.
+func (hmi *HyperMeshIntegration) predictServiceHealth(ctx context.Context, serviceID string) (*HealthPrediction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	
+	sampled := false
+	if resolver, ok := hmi.serviceDiscovery.(serviceResolver); ok {
+		if hmService, found := resolver.lookupService(serviceID); found {
+			sampled = hmi.healthPredictor.ObserveEndpoints(serviceID, hmService.Endpoints)
+		}
+	}
+	
+	if !sampled {
+		metrics, err := hmi.circuitBreaker.GetCircuitMetrics(serviceID)
+		if err != nil {
+			return nil, fmt.Errorf("no health data for service %s: %w", serviceID, err)
+		}
+		hmi.healthPredictor.Observe(serviceID, HealthSample{
+			Requests: metrics.TotalCalls - metrics.RejectedCalls,
+			Failures: metrics.FailedCalls,
+		})
+	}
+	
+	return hmi.healthPredictor.Predict(serviceID)
+}
+
+// makeIntelligentCircuitDecision combines the circuit state with the health
+// prediction. Confident predictions may open a closed circuit before failures
+// accumulate, or signal that an open circuit is ready for an early probe;
+// half-open trial permits granted by the breaker are never withdrawn.
+func (hmi *HyperMeshIntegration) makeIntelligentCircuitDecision(state *CircuitState, prediction *HealthPrediction) *CircuitDecision {
+	threshold := hmi.config.CircuitBreakerThreshold
+	probability := prediction.FailureProbability
+	
+	// Decisions that contradict the breaker need a confident prediction
+	confident := prediction.Confidence >= minPredictionConfidence
+	
+	// Distance from the threshold scales confidence in the chosen action
+	margin := math.Abs(probability-threshold) / math.Max(threshold, 1-threshold)
+	confidence := prediction.Confidence * (0.5 + 0.5*math.Min(margin, 1))
+	
+	switch state.State {
+	case CircuitOpen:
+		if confident && probability < threshold/2 {
+			return &CircuitDecision{
+				Action:     CircuitActionProbe,
+				Reason:     fmt.Sprintf("recovery predicted: failure probability %.2f", probability),
+				Confidence: confidence,
+				TTL:        state.RetryAfter,
+			}
+		}
+		return &CircuitDecision{
+			Action:     CircuitActionReject,
+			Reason:     fmt.Sprintf("circuit open: failure probability %.2f", probability),
+			Confidence: confidence,
+			TTL:        state.RetryAfter,
+		}
+		
+	case CircuitHalfOpen:
+		if !state.AllowRequest {
+			return hmi.standardCircuitDecision(state)
+		}
+		return &CircuitDecision{
+			Action:     CircuitActionAllow,
+			Reason:     fmt.Sprintf("half-open trial: failure probability %.2f", probability),
+			Confidence: confidence,
+			TTL:        prediction.Horizon,
+		}
+		
+	default:
+		if confident && probability >= threshold {
+			return &CircuitDecision{
+				Action:     CircuitActionOpen,
+				Reason:     fmt.Sprintf("failure predicted: probability %.2f, error rate %.2f, latency %v", probability, prediction.PredictedErrorRate, prediction.PredictedLatency),
+				Confidence: confidence,
+				TTL:        prediction.Horizon,
+			}
+		}
+		return &CircuitDecision{
+			Action:     CircuitActionAllow,
+			Reason:     fmt.Sprintf("healthy: failure probability %.2f", probability),
+			Confidence: confidence,
+			TTL:        prediction.Horizon,
+		}
+	}
+}
+
+// standardCircuitDecision follows the circuit breaker state alone
+func (hmi *HyperMeshIntegration) standardCircuitDecision(state *CircuitState) *CircuitDecision {
+	if state.AllowRequest {
+		return &CircuitDecision{
+			Action:     CircuitActionAllow,
+			Reason:     fmt.Sprintf("circuit %s", state.State),
+			Confidence: 1.0,
+		}
+	}
+	return &CircuitDecision{
+		Action:     CircuitActionReject,
+		Reason:     fmt.Sprintf("circuit %s", state.State),
+		Confidence: 1.0,
+		TTL:        state.RetryAfter,
+	}
+}
+
+// serviceResolver is implemented by discovery backends that keep the
+// HyperMesh services registered through them
+type serviceResolver interface {
+	lookupService(serviceID string) (*HyperMeshService, bool)
+}
+
+// instanceResolver is implemented by discovery backends that can map ALM
+// registry instances back to the HyperMesh services they belong to
+type instanceResolver interface {
+	lookupInstance(instanceID string) (*HyperMeshService, *Endpoint, bool)
+}
+
+// convertToALMServiceQuery maps a HyperMesh query onto the ALM discovery query.
+// MaxResults is applied after endpoints are grouped into services.
+func (hmi *HyperMeshIntegration) convertToALMServiceQuery(query *ServiceQuery) internal.ServiceQuery {
+	if query == nil {
+		query = &ServiceQuery{}
+	}
+	
+	return internal.ServiceQuery{
+		ServiceName:     query.ServiceName,
+		RequiredTags:    registryTags(query.Namespace, query.Labels),
+		IncludeDegraded: !query.HealthOnly,
+		SortBy:          int(service.SortByHealth),
+	}
+}
+
+// convertToHyperMeshServices wraps each discovered ALM instance as a single-endpoint service
+func (hmi *HyperMeshIntegration) convertToHyperMeshServices(discovered []internal.DiscoveredService) []*HyperMeshService {
+	services := make([]*HyperMeshService, 0, len(discovered))
+	
+	for _, instance := range discovered {
+		health := &HealthStatus{
+			Status:       hmi.healthStatusForScore(instance.HealthScore),
+			Score:        instance.HealthScore,
+			ResponseTime: instance.ResponseTime,
+		}
+		
+		services = append(services, &HyperMeshService{
+			ID:   instance.ServiceID,
+			Name: instance.Name,
+			Endpoints: []*Endpoint{{
+				ID:      instance.ServiceID,
+				Address: instance.Address,
+				Port:    instance.Port,
+				NodeID:  instance.NodeID,
+				Health:  health,
+			}},
+			Health: health,
+		})
+	}
+	
+	return services
+}
+
+// enhanceServicesWithHyperMeshData regroups ALM instances under the HyperMesh
+// services that registered them, restoring namespace, labels and metadata.
+// Instances unknown to the discovery backend are returned unchanged.
+func (hmi *HyperMeshIntegration) enhanceServicesWithHyperMeshData(services []*HyperMeshService) []*HyperMeshService {
+	resolver, ok := hmi.serviceDiscovery.(instanceResolver)
+	if !ok {
+		return services
+	}
+	
+	grouped := make(map[string]*HyperMeshService)
+	enhanced := make([]*HyperMeshService, 0, len(services))
+	
+	for _, instance := range services {
+		owner, original, found := resolver.lookupInstance(instance.ID)
+		if !found {
+			enhanced = append(enhanced, instance)
+			continue
+		}
+		
+		hmService, exists := grouped[owner.ID]
+		if !exists {
+			hmService = copyServiceWithoutEndpoints(owner)
+			hmService.Health = instance.Health
+			grouped[owner.ID] = hmService
+			enhanced = append(enhanced, hmService)
+		}
+		
+		for _, endpoint := range instance.Endpoints {
+			restored := *endpoint
+			restored.ID = original.ID
+			restored.Weight = original.Weight
+			restored.Metrics = original.Metrics
+			hmService.Endpoints = append(hmService.Endpoints, &restored)
+		}
+	}
+	
+	return enhanced
+}
+
+// healthStatusForScore derives a HyperMesh status name from a health score
+func (hmi *HyperMeshIntegration) healthStatusForScore(score float64) string {
+	defaults := service.DefaultRegistryConfig()
+	
+	switch {
+	case score >= defaults.DegradedThreshold:
+		return HealthStatusHealthy
+	case score >= defaults.UnhealthyThreshold:
+		return HealthStatusDegraded
+	case score > 0:
+		return HealthStatusUnhealthy
+	default:
+		return HealthStatusUnknown
+	}
+}
+
 func (hmi *HyperMeshIntegration) calculateRoutingImprovement(almSearchTime time.Duration) float64 {
 	// Calculate improvement factor vs baseline HTTP routing
 	baselineLatency := 1.39 * float64(time.Millisecond) // HTTP baseline
@@ -441,6 +963,13 @@ func (hmi *HyperMeshIntegration) calculateTargetAchievement() float64 {
 	return (improvement / targetImprovement) * 100.0
 }
 
+// addressResolver is implemented by discovery backends that can map a
+// transport address to the ALM node hosting it, and back
+type addressResolver interface {
+	nodeForAddress(address string) (int64, bool)
+	addressForNode(nodeID int64) (string, bool)
+}
+
 // Configuration and types
 
 // ServiceQuery represents a HyperMesh service query
@@ -472,6 +1001,11 @@ type RoutingDecision struct {
 	QualityScore       float64
 	Confidence         float64
 	AlternativePaths   []AlternativePath
+	
+	// Share of traffic recommended for SelectedPath; each alternative
+	// carries its own, and together they sum to 1
+	Weight             float64
+	
 	DecisionTime       time.Duration
 	ImprovementFactor  float64
 }
@@ -483,8 +1017,26 @@ type AlternativePath struct {
 	Throughput     float64
 	Reliability    float64
 	Score          float64
+	Weight         float64
 }
 
+// Circuit decision actions
+const (
+	// CircuitActionAllow lets the request proceed
+	CircuitActionAllow = "allow"
+	// CircuitActionReject rejects the request
+	CircuitActionReject = "reject"
+	// CircuitActionOpen rejects the request because failure is predicted
+	// while the circuit is still closed
+	CircuitActionOpen = "open"
+	// CircuitActionProbe indicates an open circuit is predicted to have
+	// recovered and may be probed before RetryAfter
+	CircuitActionProbe = "probe"
+)
+
+// minPredictionConfidence is required before a prediction overrides the breaker
+const minPredictionConfidence = 0.6
+
 // CircuitDecision contains circuit breaker decision
 type CircuitDecision struct {
 	Action     string
@@ -502,6 +1054,9 @@ type IntegrationPerformanceMetrics struct {
 	CircuitBreakerAccuracy      float64
 	OverallImprovementFactor    float64
 	TargetAchievement           float64
+	
+	// Rate limiter state; nil when rate limiting is disabled
+	RateLimits                  *RateLimiterStats
 }
 
 // DefaultIntegrationConfig returns default integration configuration
@@ -519,5 +1074,7 @@ func DefaultIntegrationConfig() *IntegrationConfig {
 		MaxIntegrationLatency:    10 * time.Millisecond,
 		MetricsCollectionInterval: 10 * time.Second,
 		PerformanceReportInterval: 1 * time.Minute,
+		TargetMissAlertAfter:      5 * time.Minute,
+		RateLimits:                DefaultRateLimitConfig(),
 	}
 }
\ No newline at end of file
@@ -4,6 +4,7 @@ package integration
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -36,6 +37,9 @@ type Connection interface {
 	GetConnectionID() string
 	GetConnectionMetrics() ConnectionMetrics
 	
+	// Peer identity from the TLS handshake; nil for unauthenticated connections
+	GetPeerIdentity() *PeerIdentity
+	
 	// Health and status
 	IsHealthy() bool
 	Ping() error
@@ -50,6 +54,11 @@ type Stream interface {
 	Send(data []byte) error
 	Receive() ([]byte, error)
 	
+	// Flow control: SendDeadline blocks until the peer grants window or
+	// deadline passes; Available reports bytes sendable without blocking
+	SendDeadline(data []byte, deadline time.Time) error
+	Available() int
+	
 	// Stream control
 	GetStreamID() int64
 	GetStreamMetrics() StreamMetrics
@@ -85,6 +94,8 @@ type TransportConfig struct {
 	EnableMultiplexing bool
 	EnableCompression  bool
 	CompressionLevel   int
+	CompressionCodecs  []string // Preference order; defaults to zstd, lz4, gzip
+	CompressionThreshold int    // Smallest payload compressed; defaults to 1 KiB
 	BufferSize        int
 	
 	// Timeout settings
@@ -99,8 +110,19 @@ type TransportConfig struct {
 	
 	// Advanced settings
 	EnableQUIC        bool
+	
+	// Send requests as 0-RTT data on resumed QUIC connections. 0-RTT data
+	// can be replayed by the network, so only enable it for idempotent traffic.
+	Enable0RTT        bool
 	IPv6Only          bool
 	CustomHeaders     map[string]string
+	
+	// Happy Eyeballs delay before racing the next resolved address;
+	// defaults to 250ms
+	DualStackFallbackDelay time.Duration
+	
+	// Receives stream flow control pressure per remote address
+	BackpressureHandler BackpressureHandler
 }
 
 // TLSConfig configures TLS settings
@@ -111,6 +133,13 @@ type TLSConfig struct {
 	VerifyPeer       bool
 	MinTLSVersion    string
 	CipherSuites     []string
+
+	// SPIFFE identity validation; setting either enforces a SPIFFE ID on the peer
+	TrustDomain      string
+	AllowedSPIFFEIDs []string   // Exact IDs or prefixes ending in "/*"
+
+	// Certificate rotation; files are checked for changes at this interval
+	ReloadInterval   time.Duration
 }
 
 // ListenerConfig configures HyperMesh listeners
@@ -121,6 +150,10 @@ type ListenerConfig struct {
 	MaxConnections   int
 	AcceptTimeout    time.Duration
 	TLSConfig       *TLSConfig
+
+	// Handlers for requests and streams initiated by accepted peers
+	Handler          RequestHandler
+	StreamHandler    StreamHandler
 }
 
 // StreamConfig configures stream behavior
@@ -188,7 +221,7 @@ type RetryPolicy struct {
 	InitialBackoff   time.Duration
 	MaxBackoff       time.Duration
 	BackoffMultiplier float64
-	RetryableErrors  []string
+	RetryableErrors  []string // ErrorCode names (e.g. "request_timeout") or response status codes (e.g. "503")
 }
 
 // Priority levels for requests and streams
@@ -242,7 +275,7 @@ type TransportStatistics struct {
 	BytesSent           int64
 	BytesReceived       int64
 	MessagesPerSecond   float64
-	CompressionRatio    float64
+	CompressionRatio    float64 // Payload bytes per byte on the wire
 	
 	// Performance statistics
 	P50Latency          time.Duration
@@ -298,6 +331,13 @@ type StreamMetrics struct {
 	AverageLatency     time.Duration
 	Throughput         float64
 	
+	// Flow control
+	SendWindow         int64
+	SendAvailable      int64
+	BlockedSends       int64
+	BlockedTime        time.Duration
+	Backpressure       float64 // Fraction of the send window in use
+	
 	// Status
 	IsActive          bool
 	LastError         error
@@ -351,6 +391,34 @@ const (
 	ErrorCodeResourceExhausted
 )
 
+// String returns the error code name used in RetryPolicy.RetryableErrors
+func (ec ErrorCode) String() string {
+	switch ec {
+	case ErrorCodeConnectionFailed:
+		return "connection_failed"
+	case ErrorCodeConnectionTimeout:
+		return "connection_timeout"
+	case ErrorCodeConnectionClosed:
+		return "connection_closed"
+	case ErrorCodeRequestTimeout:
+		return "request_timeout"
+	case ErrorCodeRequestTooLarge:
+		return "request_too_large"
+	case ErrorCodeServerUnavailable:
+		return "server_unavailable"
+	case ErrorCodeTLSError:
+		return "tls_error"
+	case ErrorCodeCompressionError:
+		return "compression_error"
+	case ErrorCodeProtocolError:
+		return "protocol_error"
+	case ErrorCodeResourceExhausted:
+		return "resource_exhausted"
+	default:
+		return "unknown"
+	}
+}
+
 // Error implements the error interface
 func (te *TransportError) Error() string {
 	if te.Cause != nil {
@@ -371,10 +439,50 @@ func (te *TransportError) IsTemporary() bool {
 
 // Factory functions for creating transport instances
 
-// NewHyperMeshTransport creates a new HyperMesh transport instance
+// Transport protocols selectable through TransportConfig.Protocol
+const (
+	ProtocolMock = "mock"
+	ProtocolQUIC = "quic"
+	ProtocolGRPC = "grpc"
+)
+
+// DefaultTransportConfig returns default transport configuration
+func DefaultTransportConfig() *TransportConfig {
+	return &TransportConfig{
+		Protocol:           ProtocolQUIC,
+		EnableMultiplexing: true,
+		BufferSize:         1024 * 1024,
+		ConnectTimeout:     5 * time.Second,
+		RequestTimeout:     30 * time.Second,
+		KeepAliveTimeout:   15 * time.Second,
+		IdleTimeout:        60 * time.Second,
+		EnableTLS:          true,
+		EnableQUIC:         true,
+	}
+}
+
+// NewHyperMeshTransport creates a new HyperMesh transport instance.
+// Protocol selects the backend; "grpc" suits networks that block UDP.
+// An empty protocol keeps the mock transport, so real networking is only
+// used when a protocol is named explicitly; EnableQUIC alone does not select it.
 func NewHyperMeshTransport(config *TransportConfig) (HyperMeshTransport, error) {
-	// This would be implemented by the actual HyperMesh transport layer
-	return &MockHyperMeshTransport{config: config}, nil
+	if config == nil {
+		return &MockHyperMeshTransport{config: config}, nil
+	}
+
+	switch strings.ToLower(config.Protocol) {
+	case ProtocolGRPC:
+		return NewGRPCTransport(config)
+	case ProtocolQUIC:
+		return NewQUICTransport(config)
+	case "", ProtocolMock:
+		return &MockHyperMeshTransport{config: config}, nil
+	default:
+		return nil, &TransportError{
+			Code:    ErrorCodeProtocolError,
+			Message: fmt.Sprintf("unsupported transport protocol %q", config.Protocol),
+		}
+	}
 }
 
 // MockHyperMeshTransport provides a mock implementation for testing
@@ -510,6 +618,10 @@ func (m *MockConnection) GetConnectionID() string {
 	return m.id
 }
 
+func (m *MockConnection) GetPeerIdentity() *PeerIdentity {
+	return nil
+}
+
 func (m *MockConnection) GetConnectionMetrics() ConnectionMetrics {
 	return ConnectionMetrics{
 		ConnectionID:    m.id,
@@ -558,6 +670,14 @@ func (m *MockStream) Send(data []byte) error {
 	return nil
 }
 
+func (m *MockStream) SendDeadline(data []byte, deadline time.Time) error {
+	return m.Send(data)
+}
+
+func (m *MockStream) Available() int {
+	return defaultFlowControlWindow
+}
+
 func (m *MockStream) Receive() ([]byte, error) {
 	if m.closed {
 		return nil, &TransportError{
@@ -0,0 +1,333 @@
+// Package integration implements the ALM enhancements applied to HyperMesh routing, load balancing and circuit breaking
+package integration
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"go.uber.org/zap"
+)
+
+// LoadDistribution is the current load of a service's endpoints as reported
+// by the HyperMesh load balancer
+type LoadDistribution struct {
+	ServiceID     string
+	TotalRequests int64
+
+	// Endpoint utilization (0-1) keyed by endpoint ID
+	EndpointLoad map[string]float64
+
+	UpdatedAt time.Time
+}
+
+// LoadBalancerConfig configures how HyperMesh balances a service's endpoints
+type LoadBalancerConfig struct {
+	Algorithm string
+
+	// Endpoints above this utilization (0-1) are skipped while others remain
+	MaxEndpointLoad float64
+}
+
+// serviceLister is implemented by discovery backends that can enumerate the
+// HyperMesh services registered through them
+type serviceLister interface {
+	serviceIDs() []string
+}
+
+// registeredServices returns the services known to the discovery backend
+func (hmi *HyperMeshIntegration) registeredServices() []*HyperMeshService {
+	lister, ok := hmi.serviceDiscovery.(serviceLister)
+	if !ok {
+		return nil
+	}
+	resolver, ok := hmi.serviceDiscovery.(serviceResolver)
+	if !ok {
+		return nil
+	}
+
+	ids := lister.serviceIDs()
+	services := make([]*HyperMeshService, 0, len(ids))
+	for _, id := range ids {
+		if hmService, found := resolver.lookupService(id); found {
+			services = append(services, hmService)
+		}
+	}
+	return services
+}
+
+// resolveServiceToNodeID maps a HyperMesh service ID or name to the ALM node
+// hosting its healthiest endpoint. Numeric names are taken as node IDs.
+func (hmi *HyperMeshIntegration) resolveServiceToNodeID(name string) (int64, error) {
+	if resolver, ok := hmi.serviceDiscovery.(serviceResolver); ok {
+		if hmService, found := resolver.lookupService(name); found {
+			if endpoint := healthiestEndpoint(hmService.Endpoints); endpoint != nil {
+				return endpoint.NodeID, nil
+			}
+			return 0, fmt.Errorf("service %s has no endpoints", name)
+		}
+	}
+
+	if hmi.almCoordinator != nil {
+		var best *int64
+		bestScore := -1.0
+		for _, instance := range hmi.almCoordinator.ServiceRegistry().Instances() {
+			if instance.ID != name && instance.Name != name {
+				continue
+			}
+			if instance.HealthScore > bestScore {
+				nodeID := instance.NodeID
+				best, bestScore = &nodeID, instance.HealthScore
+			}
+		}
+		if best != nil {
+			return *best, nil
+		}
+	}
+
+	if nodeID, err := strconv.ParseInt(name, 10, 64); err == nil {
+		return nodeID, nil
+	}
+
+	return 0, fmt.Errorf("service %s not found", name)
+}
+
+// healthiestEndpoint returns the endpoint with the best health score; endpoints
+// without health data rank as unknown
+func healthiestEndpoint(endpoints []*Endpoint) *Endpoint {
+	var best *Endpoint
+	bestScore := -1.0
+
+	for _, endpoint := range endpoints {
+		score := healthStatusScore(HealthStatusUnknown)
+		if endpoint.Health != nil {
+			score = endpoint.Health.Score
+		}
+		if score > bestScore {
+			best, bestScore = endpoint, score
+		}
+	}
+	return best
+}
+
+// convertPathToServiceNames names each node of an ALM path after the first
+// service it hosts, falling back to its address and then its ID
+func (hmi *HyperMeshIntegration) convertPathToServiceNames(path []int64) []string {
+	names := make([]string, 0, len(path))
+	for _, nodeID := range path {
+		names = append(names, hmi.nodeName(nodeID))
+	}
+	return names
+}
+
+func (hmi *HyperMeshIntegration) nodeName(nodeID int64) string {
+	if hmi.almCoordinator != nil {
+		if node, exists := hmi.almCoordinator.NetworkGraph().GetNode(nodeID); exists {
+			if len(node.Services) > 0 {
+				names := make([]string, 0, len(node.Services))
+				for _, info := range node.Services {
+					names = append(names, info.Name)
+				}
+				sort.Strings(names)
+				return names[0]
+			}
+			if node.Address != "" {
+				return node.Address
+			}
+		}
+	}
+	return strconv.FormatInt(nodeID, 10)
+}
+
+// convertAlternativePaths converts ALM alternative routes to HyperMesh paths
+func (hmi *HyperMeshIntegration) convertAlternativePaths(alternatives []internal.AlternativeRoute) []AlternativePath {
+	paths := make([]AlternativePath, 0, len(alternatives))
+	for _, alternative := range alternatives {
+		paths = append(paths, AlternativePath{
+			Path:        hmi.convertPathToServiceNames(alternative.Path),
+			Latency:     alternative.Latency,
+			Throughput:  alternative.Throughput,
+			Reliability: alternative.Reliability,
+			Score:       alternative.Score,
+			Weight:      alternative.Weight,
+		})
+	}
+	return paths
+}
+
+// findOptimalEndpointWithALM scores the endpoints of a service by health,
+// reported utilization and the load of the ALM node hosting them
+func (hmi *HyperMeshIntegration) findOptimalEndpointWithALM(ctx context.Context, serviceID string, loadDist *LoadDistribution) (*Endpoint, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	resolver, ok := hmi.serviceDiscovery.(serviceResolver)
+	if !ok {
+		return nil, fmt.Errorf("service discovery cannot resolve service %s", serviceID)
+	}
+	hmService, found := resolver.lookupService(serviceID)
+	if !found {
+		return nil, fmt.Errorf("service %s not found", serviceID)
+	}
+
+	maxLoad := 1.0
+	if hmService.LoadBalancer != nil && hmService.LoadBalancer.MaxEndpointLoad > 0 {
+		maxLoad = hmService.LoadBalancer.MaxEndpointLoad
+	}
+
+	var best *Endpoint
+	bestScore := 0.0
+	for _, endpoint := range hmService.Endpoints {
+		if endpoint.Health != nil && endpoint.Health.Status == HealthStatusCritical {
+			continue
+		}
+
+		utilization := 0.0
+		if loadDist != nil {
+			utilization = loadDist.EndpointLoad[endpoint.ID]
+		}
+		if utilization >= maxLoad {
+			continue
+		}
+
+		health := healthStatusScore(HealthStatusUnknown)
+		if endpoint.Health != nil {
+			health = endpoint.Health.Score
+		}
+
+		nodeLoad := 0.0
+		if hmi.almCoordinator != nil {
+			if node, exists := hmi.almCoordinator.NetworkGraph().GetNode(endpoint.NodeID); exists {
+				nodeLoad = node.LoadFactor
+			}
+		}
+
+		score := health * (1 - utilization) * (1 - math.Min(nodeLoad, 1))
+		if best == nil || score > bestScore {
+			best, bestScore = endpoint, score
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no available endpoint for service %s", serviceID)
+	}
+	return best, nil
+}
+
+// updateServiceMeshRouting folds endpoint success ratios reported by the
+// service mesh into the reliability of the ALM nodes hosting them
+func (hmi *HyperMeshIntegration) updateServiceMeshRouting() {
+	for _, hmService := range hmi.registeredServices() {
+		for _, endpoint := range hmService.Endpoints {
+			metrics := endpoint.Metrics
+			if metrics == nil || metrics.RequestCount == 0 {
+				continue
+			}
+			reliability := float64(metrics.SuccessCount) / float64(metrics.RequestCount)
+			hmi.adjustNode(endpoint.NodeID, func(nodeMetrics *graph.NodeMetrics) {
+				nodeMetrics.Reliability = reliability
+			})
+		}
+	}
+}
+
+// startRoutingOptimization lowers the reliability of nodes hosting services
+// whose circuits observe failures, steering routes away from them
+func (hmi *HyperMeshIntegration) startRoutingOptimization(ctx context.Context) {
+	hmi.runPeriodically(ctx, hmi.config.RoutingUpdateInterval, func() {
+		for _, hmService := range hmi.registeredServices() {
+			metrics, err := hmi.circuitBreaker.GetCircuitMetrics(hmService.ID)
+			if err != nil || metrics.WindowCalls == 0 {
+				continue
+			}
+			reliability := 1 - metrics.FailureRate
+			for _, endpoint := range hmService.Endpoints {
+				hmi.adjustNode(endpoint.NodeID, func(nodeMetrics *graph.NodeMetrics) {
+					nodeMetrics.Reliability = math.Min(nodeMetrics.Reliability, reliability)
+				})
+			}
+		}
+	})
+}
+
+// startLoadBalancingEnhancement mirrors endpoint utilization reported by the
+// HyperMesh load balancer into the load factor of the hosting ALM nodes
+func (hmi *HyperMeshIntegration) startLoadBalancingEnhancement(ctx context.Context) {
+	if hmi.loadBalancer == nil {
+		return
+	}
+
+	hmi.runPeriodically(ctx, hmi.config.RoutingUpdateInterval, func() {
+		for _, hmService := range hmi.registeredServices() {
+			loadDist, err := hmi.loadBalancer.GetLoadDistribution(hmService.ID)
+			if err != nil || loadDist == nil {
+				continue
+			}
+			for _, endpoint := range hmService.Endpoints {
+				utilization, reported := loadDist.EndpointLoad[endpoint.ID]
+				if !reported {
+					continue
+				}
+				hmi.adjustNode(endpoint.NodeID, func(nodeMetrics *graph.NodeMetrics) {
+					nodeMetrics.LoadFactor = math.Max(0, math.Min(utilization, 1))
+				})
+			}
+		}
+	})
+}
+
+// startCircuitBreakerIntelligence keeps health predictions current and warns
+// about closed circuits whose services are predicted to fail
+func (hmi *HyperMeshIntegration) startCircuitBreakerIntelligence(ctx context.Context) {
+	hmi.runPeriodically(ctx, hmi.config.MetricsCollectionInterval, func() {
+		for _, hmService := range hmi.registeredServices() {
+			prediction, err := hmi.predictServiceHealth(ctx, hmService.ID)
+			if err != nil || prediction.Confidence < minPredictionConfidence {
+				continue
+			}
+			if prediction.FailureProbability < hmi.config.CircuitBreakerThreshold {
+				continue
+			}
+			state, err := hmi.circuitBreaker.CheckCircuit(hmService.ID)
+			if err != nil || state.State != CircuitClosed {
+				continue
+			}
+			hmi.logger.Warn("Service failure predicted while circuit is closed",
+				zap.String("service_id", hmService.ID),
+				zap.Float64("failure_probability", prediction.FailureProbability),
+				zap.Float64("confidence", prediction.Confidence),
+			)
+		}
+	})
+}
+
+// adjustNode applies update to the current metrics of an ALM node
+func (hmi *HyperMeshIntegration) adjustNode(nodeID int64, update func(*graph.NodeMetrics)) {
+	if hmi.almCoordinator == nil {
+		return
+	}
+
+	networkGraph := hmi.almCoordinator.NetworkGraph()
+	node, exists := networkGraph.GetNode(nodeID)
+	if !exists {
+		return
+	}
+
+	metrics := graph.NodeMetrics{
+		Latency:     node.Latency,
+		Throughput:  node.Throughput,
+		Reliability: node.Reliability,
+		LoadFactor:  node.LoadFactor,
+	}
+	update(&metrics)
+
+	if err := networkGraph.UpdateNodeMetrics(nodeID, metrics); err != nil {
+		hmi.logger.Debug("Failed to update node metrics", zap.Int64("node_id", nodeID), zap.Error(err))
+	}
+}
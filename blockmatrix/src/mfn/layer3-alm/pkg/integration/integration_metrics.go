@@ -0,0 +1,151 @@
+// Package integration implements improvement tracking for the HyperMesh integration
+package integration
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// baselineHTTPLatency is the native HyperMesh HTTP path that integration
+// improvements are measured against
+const baselineHTTPLatency = 1390 * time.Microsecond
+
+// integrationMetricsAlpha weights the newest sample in the moving averages
+const integrationMetricsAlpha = 0.1
+
+// IntegrationMetrics tracks exponentially weighted improvement factors of the
+// ALM-enhanced operations over the native HyperMesh baseline
+type IntegrationMetrics struct {
+	serviceDiscovery movingAverage
+	routing          movingAverage
+	loadBalancing    movingAverage
+	circuitBreaker   movingAverage
+
+	servicesDiscovered int64
+
+	mutex sync.RWMutex
+}
+
+// movingAverage is an exponentially weighted average seeded by its first sample
+type movingAverage struct {
+	value   float64
+	samples int64
+}
+
+func (ma *movingAverage) add(sample float64) {
+	if ma.samples == 0 {
+		ma.value = sample
+	} else {
+		ma.value += integrationMetricsAlpha * (sample - ma.value)
+	}
+	ma.samples++
+}
+
+// NewIntegrationMetrics creates empty integration metrics
+func NewIntegrationMetrics() *IntegrationMetrics {
+	return &IntegrationMetrics{}
+}
+
+// RecordServiceDiscovery records an enhanced discovery that returned count services
+func (im *IntegrationMetrics) RecordServiceDiscovery(duration time.Duration, count int) {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+
+	im.servicesDiscovered += int64(count)
+	if duration > 0 {
+		im.serviceDiscovery.add(float64(baselineHTTPLatency) / float64(duration))
+	}
+}
+
+// RecordRouting records a routing decision and its improvement factor
+func (im *IntegrationMetrics) RecordRouting(duration time.Duration, improvement float64) {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+
+	if improvement > 0 {
+		im.routing.add(improvement)
+	}
+}
+
+// RecordLoadBalancing records an enhanced endpoint selection
+func (im *IntegrationMetrics) RecordLoadBalancing(duration time.Duration) {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+
+	if duration > 0 {
+		im.loadBalancing.add(float64(baselineHTTPLatency) / float64(duration))
+	}
+}
+
+// RecordCircuitBreaker records the confidence of an enhanced circuit decision
+func (im *IntegrationMetrics) RecordCircuitBreaker(duration time.Duration, confidence float64) {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+
+	im.circuitBreaker.add(confidence)
+}
+
+// GetServiceDiscoveryImprovement returns the discovery improvement factor
+func (im *IntegrationMetrics) GetServiceDiscoveryImprovement() float64 {
+	im.mutex.RLock()
+	defer im.mutex.RUnlock()
+	return im.serviceDiscovery.value
+}
+
+// GetRoutingImprovement returns the routing improvement factor
+func (im *IntegrationMetrics) GetRoutingImprovement() float64 {
+	im.mutex.RLock()
+	defer im.mutex.RUnlock()
+	return im.routing.value
+}
+
+// GetLoadBalancingImprovement returns the load balancing improvement factor
+func (im *IntegrationMetrics) GetLoadBalancingImprovement() float64 {
+	im.mutex.RLock()
+	defer im.mutex.RUnlock()
+	return im.loadBalancing.value
+}
+
+// GetCircuitBreakerAccuracy returns the average confidence of circuit decisions
+func (im *IntegrationMetrics) GetCircuitBreakerAccuracy() float64 {
+	im.mutex.RLock()
+	defer im.mutex.RUnlock()
+	return im.circuitBreaker.value
+}
+
+// startMetricsCollection periodically logs the integration improvement figures
+func (hmi *HyperMeshIntegration) startMetricsCollection(ctx context.Context) {
+	hmi.runPeriodically(ctx, hmi.config.MetricsCollectionInterval, func() {
+		metrics := hmi.integrationMetrics
+		hmi.logger.Debug("HyperMesh integration metrics",
+			zap.Float64("service_discovery_improvement", metrics.GetServiceDiscoveryImprovement()),
+			zap.Float64("routing_improvement", metrics.GetRoutingImprovement()),
+			zap.Float64("load_balancing_improvement", metrics.GetLoadBalancingImprovement()),
+			zap.Float64("circuit_breaker_accuracy", metrics.GetCircuitBreakerAccuracy()),
+			zap.Float64("target_achievement", hmi.calculateTargetAchievement()),
+		)
+	})
+}
+
+// runPeriodically calls fn every interval until ctx is done. A non-positive
+// interval disables the loop.
+func (hmi *HyperMeshIntegration) runPeriodically(ctx context.Context, interval time.Duration, fn func()) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn()
+		}
+	}
+}
@@ -0,0 +1,1226 @@
+// Package integration implements the QUIC transport for HyperMesh
+package integration
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/tracing"
+	"github.com/quic-go/quic-go"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// quicALPN is the application protocol negotiated during the QUIC handshake
+	quicALPN = "hypermesh-quic"
+
+	// quicDefaultMaxStreams bounds concurrent streams per connection
+	quicDefaultMaxStreams = 1000
+
+	// quicMaxConnectionsPerHost reflects that one multiplexed connection per host is enough
+	quicMaxConnectionsPerHost = 1
+
+	// pingMethod is answered by the transport itself, without invoking handlers
+	pingMethod = "PING"
+)
+
+const (
+	quicCodeNoError  quic.ApplicationErrorCode = 0x0
+	quicCodeRejected quic.ApplicationErrorCode = 0x1
+	quicCodeShutdown quic.ApplicationErrorCode = 0x2
+)
+
+// connectionSequence disambiguates connection IDs created within the same nanosecond
+var connectionSequence atomic.Int64
+
+// newConnectionID creates a unique connection identifier
+func newConnectionID(prefix string) string {
+	return fmt.Sprintf("%s-%d-%d", prefix, time.Now().UnixNano(), connectionSequence.Add(1))
+}
+
+// QUICTransport implements HyperMeshTransport over QUIC.
+//
+// Every request runs on its own bidirectional QUIC stream, so many requests
+// are multiplexed over a single connection without head-of-line blocking.
+// Client connections share a TLS session cache so reconnects resume the TLS
+// session. With Enable0RTT set, resumed connections also send requests as
+// 0-RTT data, which the network may replay; only enable it when every request
+// is idempotent.
+type QUICTransport struct {
+	config       *TransportConfig
+	quicConfig   *quic.Config
+	sessionCache tls.ClientSessionCache
+	tls          *tlsManager
+
+	// Tracked resources for shutdown and statistics
+	connections map[string]*quicConnection
+	listeners   map[*quicListener]struct{}
+	stats       *transportStats
+
+	isShutdown bool
+	mutex      sync.RWMutex
+}
+
+// NewQUICTransport creates a QUIC transport
+func NewQUICTransport(config *TransportConfig) (*QUICTransport, error) {
+	if config == nil {
+		config = DefaultTransportConfig()
+	}
+
+	return &QUICTransport{
+		config:       config,
+		quicConfig:   newQUICConfig(config),
+		sessionCache: tls.NewLRUClientSessionCache(256),
+		tls:          newTLSManager(),
+		connections:  make(map[string]*quicConnection),
+		listeners:    make(map[*quicListener]struct{}),
+		stats:        newTransportStats(),
+	}, nil
+}
+
+// Connect dials a remote HyperMesh node
+func (qt *QUICTransport) Connect(config *TransportConfig) (Connection, error) {
+	qt.mutex.RLock()
+	shutdown := qt.isShutdown
+	if config == nil {
+		config = qt.config
+	}
+	qt.mutex.RUnlock()
+
+	if shutdown {
+		return nil, &TransportError{Code: ErrorCodeConnectionClosed, Message: "transport is shut down"}
+	}
+
+	conn := &quicConnection{
+		transport: qt,
+		config:    config,
+		address:   transportAddress(config.Address, config.Port),
+		id:        newConnectionID("quic"),
+		isClient:  true,
+	}
+	conn.compression = newPayloadCompressor(config, qt.stats)
+
+	ctx, cancel := withOptionalTimeout(context.Background(), config.ConnectTimeout)
+	defer cancel()
+
+	conn.dialMutex.Lock()
+	err := conn.dial(ctx)
+	conn.dialMutex.Unlock()
+	if err != nil {
+		qt.stats.failedConnections.Add(1)
+		return nil, err
+	}
+
+	qt.track(conn)
+	return conn, nil
+}
+
+// Listen starts accepting QUIC connections
+func (qt *QUICTransport) Listen(config *ListenerConfig) (Listener, error) {
+	if config == nil {
+		return nil, &TransportError{Code: ErrorCodeProtocolError, Message: "listener config is required"}
+	}
+
+	tlsConfig, err := qt.tls.serverConfig(config.TLSConfig, []string{quicALPN})
+	if err != nil {
+		return nil, err
+	}
+
+	qt.mutex.Lock()
+	defer qt.mutex.Unlock()
+
+	if qt.isShutdown {
+		return nil, &TransportError{Code: ErrorCodeConnectionClosed, Message: "transport is shut down"}
+	}
+
+	address := transportAddress(config.Address, config.Port)
+	packetConn, err := listenPacket(address, qt.config != nil && qt.config.IPv6Only)
+	if err != nil {
+		return nil, &TransportError{
+			Code:    ErrorCodeConnectionFailed,
+			Message: fmt.Sprintf("failed to listen on %s", address),
+			Cause:   err,
+		}
+	}
+
+	ln, err := listenQUIC(packetConn, tlsConfig, qt.quicConfig.Clone())
+	if err != nil {
+		packetConn.Close()
+		return nil, &TransportError{
+			Code:    ErrorCodeConnectionFailed,
+			Message: fmt.Sprintf("failed to listen on %s", address),
+			Cause:   err,
+		}
+	}
+
+	listener := &quicListener{
+		transport:  qt,
+		listener:   ln,
+		packetConn: packetConn,
+		config:     config,
+		startedAt:  time.Now(),
+	}
+	qt.listeners[listener] = struct{}{}
+
+	return listener, nil
+}
+
+// GetCapabilities describes what the QUIC transport supports
+func (qt *QUICTransport) GetCapabilities() TransportCapabilities {
+	qt.mutex.RLock()
+	defer qt.mutex.RUnlock()
+
+	return TransportCapabilities{
+		SupportedProtocols:    []string{ProtocolQUIC},
+		MaxConcurrentStreams:  qt.quicConfig.MaxIncomingStreams,
+		MaxConnectionsPerHost: quicMaxConnectionsPerHost,
+		SupportsMultiplexing:  qt.quicConfig.MaxIncomingStreams > 1,
+		SupportsCompression:   true,
+		SupportsEncryption:    true,
+		SupportsIPv6:          true,
+		SupportsQUIC:          true,
+		MaxMessageSize:        defaultMaxFrameSize,
+	}
+}
+
+// GetStatistics returns transport-wide statistics
+func (qt *QUICTransport) GetStatistics() TransportStatistics {
+	return qt.stats.snapshot()
+}
+
+// UpdateConfiguration applies a new configuration to subsequent connections
+func (qt *QUICTransport) UpdateConfiguration(config *TransportConfig) error {
+	if config == nil {
+		return &TransportError{Code: ErrorCodeProtocolError, Message: "transport config is required"}
+	}
+
+	qt.mutex.Lock()
+	defer qt.mutex.Unlock()
+
+	qt.config = config
+	qt.quicConfig = newQUICConfig(config)
+	return nil
+}
+
+// Shutdown closes all listeners and connections
+func (qt *QUICTransport) Shutdown() error {
+	qt.mutex.Lock()
+	if qt.isShutdown {
+		qt.mutex.Unlock()
+		return nil
+	}
+	qt.isShutdown = true
+
+	connections := make([]*quicConnection, 0, len(qt.connections))
+	for _, conn := range qt.connections {
+		connections = append(connections, conn)
+	}
+	listeners := make([]*quicListener, 0, len(qt.listeners))
+	for listener := range qt.listeners {
+		listeners = append(listeners, listener)
+	}
+	qt.mutex.Unlock()
+
+	var firstErr error
+	for _, listener := range listeners {
+		if err := listener.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, conn := range connections {
+		conn.closeWithCode(quicCodeShutdown, "transport shutdown")
+	}
+	qt.tls.Close()
+
+	return firstErr
+}
+
+func (qt *QUICTransport) track(conn *quicConnection) {
+	qt.mutex.Lock()
+	qt.connections[conn.id] = conn
+	qt.mutex.Unlock()
+
+	qt.stats.totalConnections.Add(1)
+	qt.stats.activeConnections.Add(1)
+}
+
+func (qt *QUICTransport) untrack(conn *quicConnection) {
+	qt.mutex.Lock()
+	_, tracked := qt.connections[conn.id]
+	delete(qt.connections, conn.id)
+	qt.mutex.Unlock()
+
+	if tracked {
+		qt.stats.activeConnections.Add(-1)
+	}
+}
+
+func (qt *QUICTransport) removeListener(listener *quicListener) {
+	qt.mutex.Lock()
+	delete(qt.listeners, listener)
+	qt.mutex.Unlock()
+}
+
+func (qt *QUICTransport) currentQUICConfig() *quic.Config {
+	qt.mutex.RLock()
+	defer qt.mutex.RUnlock()
+	return qt.quicConfig.Clone()
+}
+
+// quicConnection implements Connection over a QUIC connection
+type quicConnection struct {
+	transport *QUICTransport
+	config    *TransportConfig
+	address   string
+	id        string
+	isClient  bool
+
+	// Server-side handlers for peer-initiated requests and streams
+	handler       RequestHandler
+	streamHandler StreamHandler
+
+	conn          quic.Connection
+	establishedAt time.Time
+	lastError     error
+	mutex         sync.RWMutex
+
+	// Serializes dials so concurrent reconnects establish one connection
+	dialMutex sync.Mutex
+
+	// Payload compression and the codec negotiated with the peer, if any
+	compression *payloadCompressor
+	peerCodec   atomic.Pointer[payloadCodec]
+
+	stats     connectionStats
+	closed    atomic.Bool
+	closeOnce sync.Once
+	onClose   func()
+}
+
+// dial establishes (or re-establishes) the underlying QUIC connection;
+// callers must hold dialMutex
+func (qc *quicConnection) dial(ctx context.Context) error {
+	host := qc.config.Address
+	if ea, err := ParseEndpointAddress(qc.address, 0); err == nil {
+		host = ea.Host
+	}
+
+	tlsConfig, err := qc.transport.tls.clientConfig(qc.config.TLSConfig, host, []string{quicALPN})
+	if err != nil {
+		return err
+	}
+	tlsConfig.ClientSessionCache = qc.transport.sessionCache
+	quicConfig := qc.transport.currentQUICConfig()
+
+	conn, err := dialDualStack(ctx, qc.address, qc.config.IPv6Only, qc.config.DualStackFallbackDelay,
+		func(ctx context.Context, address string) (quic.Connection, error) {
+			if qc.config.Enable0RTT {
+				return quic.DialAddrEarly(ctx, address, tlsConfig, quicConfig)
+			}
+			return quic.DialAddr(ctx, address, tlsConfig, quicConfig)
+		},
+		func(conn quic.Connection) {
+			conn.CloseWithError(0, "superseded by another address")
+		},
+	)
+	if err != nil {
+		code := ErrorCodeConnectionFailed
+		if errors.Is(err, context.DeadlineExceeded) {
+			code = ErrorCodeConnectionTimeout
+		}
+		return &TransportError{
+			Code:      code,
+			Message:   fmt.Sprintf("failed to connect to %s", qc.address),
+			Cause:     err,
+			Retryable: true,
+			Temporary: true,
+		}
+	}
+
+	qc.mutex.Lock()
+	qc.conn = conn
+	qc.establishedAt = time.Now()
+	qc.mutex.Unlock()
+	qc.stats.touch()
+
+	go qc.serve(conn)
+
+	return nil
+}
+
+// reconnect replaces a dead client connection, using 0-RTT when enabled and
+// a session ticket is cached. Concurrent callers share a single redial.
+func (qc *quicConnection) reconnect(ctx context.Context, dead quic.Connection) error {
+	qc.dialMutex.Lock()
+	defer qc.dialMutex.Unlock()
+
+	if current := qc.current(); current != dead && current != nil && current.Context().Err() == nil {
+		return nil // Another caller already reconnected
+	}
+
+	return qc.dial(ctx)
+}
+
+// current returns the active QUIC connection
+func (qc *quicConnection) current() quic.Connection {
+	qc.mutex.RLock()
+	defer qc.mutex.RUnlock()
+	return qc.conn
+}
+
+// openStream opens a new bidirectional stream, reconnecting once if the connection died
+func (qc *quicConnection) openStream(ctx context.Context) (quic.Stream, error) {
+	if qc.closed.Load() {
+		return nil, &TransportError{Code: ErrorCodeConnectionClosed, Message: "connection is closed", Retryable: true}
+	}
+
+	conn := qc.current()
+	if conn != nil {
+		stream, err := conn.OpenStreamSync(ctx)
+		if err == nil {
+			return stream, nil
+		}
+		if !qc.isClient || conn.Context().Err() == nil {
+			return nil, qc.streamError(ctx, err)
+		}
+	}
+
+	if !qc.isClient {
+		return nil, &TransportError{Code: ErrorCodeConnectionClosed, Message: "connection is closed"}
+	}
+
+	if err := qc.reconnect(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	stream, err := qc.current().OpenStreamSync(ctx)
+	if err != nil {
+		return nil, qc.streamError(ctx, err)
+	}
+	return stream, nil
+}
+
+// Execute sends a request on a fresh stream and waits for its response
+func (qc *quicConnection) Execute(request *Request) (response *Response, err error) {
+	startTime := time.Now()
+
+	request, span := startClientSpan(request, "quic", qc.GetRemoteAddress())
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := qc.requestContext(request)
+	defer cancel()
+
+	stream, err := qc.openStream(ctx)
+	if err != nil {
+		qc.recordRequest(time.Since(startTime), false, err)
+		return nil, err
+	}
+
+	response, err = qc.roundTrip(ctx, stream, request)
+	latency := time.Since(startTime)
+	qc.recordRequest(latency, err == nil, err)
+	if err != nil {
+		return nil, err
+	}
+
+	response.Latency = latency
+	response.ConnectionID = qc.id
+	response.StreamID = int64(stream.StreamID())
+	span.SetAttributes(attribute.Int("alm.response.status_code", response.StatusCode))
+
+	return response, nil
+}
+
+// roundTrip writes the request frame, half-closes the stream and reads the response
+func (qc *quicConnection) roundTrip(ctx context.Context, stream quic.Stream, request *Request) (*Response, error) {
+	stop := context.AfterFunc(ctx, func() {
+		stream.CancelRead(quic.StreamErrorCode(quicCodeNoError))
+		stream.CancelWrite(quic.StreamErrorCode(quicCodeNoError))
+	})
+	defer stop()
+
+	payload, err := encodeRequest(request, qc.compression.accepted())
+	if err != nil {
+		return nil, &TransportError{Code: ErrorCodeProtocolError, Message: "failed to encode request", Cause: err}
+	}
+
+	frameType, payload := qc.compression.encodeFrame(frameRequest, qc.peerCodec.Load(), payload)
+	if err := writeFrame(stream, frameType, payload); err != nil {
+		return nil, qc.streamError(ctx, err)
+	}
+	if err := stream.Close(); err != nil {
+		return nil, qc.streamError(ctx, err)
+	}
+
+	frameType, body, err := readFrame(stream)
+	if err != nil {
+		return nil, qc.streamError(ctx, err)
+	}
+	qc.recordTransfer(len(payload)+frameHeaderSize, len(body)+frameHeaderSize)
+
+	frameType, body, err = qc.compression.decodeFrame(frameType, body)
+	if err != nil {
+		return nil, err
+	}
+	if frameType != frameResponse {
+		return nil, &TransportError{Code: ErrorCodeProtocolError, Message: fmt.Sprintf("unexpected frame type 0x%02x", frameType)}
+	}
+
+	response, acceptEncoding, err := decodeResponse(body)
+	if err != nil {
+		return nil, err
+	}
+	qc.peerCodec.Store(qc.compression.negotiate(acceptEncoding))
+
+	return response, nil
+}
+
+// ExecuteAsync executes a request in the background
+func (qc *quicConnection) ExecuteAsync(request *Request) (<-chan *Response, <-chan error) {
+	respChan := make(chan *Response, 1)
+	errChan := make(chan error, 1)
+
+	go func() {
+		resp, err := qc.Execute(request)
+		if err != nil {
+			errChan <- err
+		} else {
+			respChan <- resp
+		}
+		close(respChan)
+		close(errChan)
+	}()
+
+	return respChan, errChan
+}
+
+// CreateStream opens an application stream for bidirectional messaging
+func (qc *quicConnection) CreateStream(streamConfig *StreamConfig) (Stream, error) {
+	if streamConfig == nil {
+		streamConfig = &StreamConfig{}
+	}
+
+	ctx, cancel := withOptionalTimeout(context.Background(), qc.config.ConnectTimeout)
+	defer cancel()
+
+	stream, err := qc.openStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	streamID := streamConfig.StreamID
+	if streamID == 0 {
+		streamID = int64(stream.StreamID())
+	}
+
+	window := streamConfig.FlowControlWindow
+	if window <= 0 {
+		window = defaultFlowControlWindow
+	}
+
+	open, err := json.Marshal(wireStreamOpen{
+		StreamID:       streamID,
+		Priority:       int(streamConfig.Priority),
+		Window:         window,
+		AcceptEncoding: qc.compression.accepted(),
+	})
+	if err != nil {
+		stream.CancelWrite(quic.StreamErrorCode(quicCodeNoError))
+		return nil, &TransportError{Code: ErrorCodeProtocolError, Message: "failed to encode stream header", Cause: err}
+	}
+	if err := writeFrame(stream, frameStreamOpen, open); err != nil {
+		stream.CancelWrite(quic.StreamErrorCode(quicCodeNoError))
+		return nil, qc.streamError(ctx, err)
+	}
+
+	return newQUICStream(stream, streamID, qc, streamConfig.Timeout, window), nil
+}
+
+// GetRemoteAddress returns the address of the remote peer
+func (qc *quicConnection) GetRemoteAddress() string {
+	if conn := qc.current(); conn != nil {
+		return conn.RemoteAddr().String()
+	}
+	return qc.address
+}
+
+// GetConnectionID returns the connection identifier
+func (qc *quicConnection) GetConnectionID() string {
+	return qc.id
+}
+
+// GetConnectionMetrics returns per-connection metrics
+func (qc *quicConnection) GetConnectionMetrics() ConnectionMetrics {
+	qc.mutex.RLock()
+	establishedAt := qc.establishedAt
+	lastError := qc.lastError
+	qc.mutex.RUnlock()
+
+	return ConnectionMetrics{
+		ConnectionID:       qc.id,
+		RemoteAddress:      qc.GetRemoteAddress(),
+		EstablishedAt:      establishedAt,
+		LastActivity:       qc.stats.lastActive(),
+		TotalRequests:      qc.stats.totalRequests.Load(),
+		SuccessfulRequests: qc.stats.successfulRequests.Load(),
+		FailedRequests:     qc.stats.failedRequests.Load(),
+		AverageLatency:     qc.stats.averageLatency(),
+		BytesSent:          qc.stats.bytesSent.Load(),
+		BytesReceived:      qc.stats.bytesReceived.Load(),
+		IsHealthy:          qc.IsHealthy(),
+		LastError:          lastError,
+		LastHealthCheck:    time.Now(),
+	}
+}
+
+// GetPeerIdentity returns the identity from the peer's certificate once the handshake completes
+func (qc *quicConnection) GetPeerIdentity() *PeerIdentity {
+	conn := qc.current()
+	if conn == nil {
+		return nil
+	}
+
+	// Only 0-RTT connections are handed out before their handshake completes
+	if early, ok := conn.(quic.EarlyConnection); ok {
+		select {
+		case <-early.HandshakeComplete():
+		default:
+			return nil
+		}
+	}
+	return peerIdentityFromState(conn.ConnectionState().TLS)
+}
+
+// IsHealthy reports whether the connection can carry requests
+func (qc *quicConnection) IsHealthy() bool {
+	if qc.closed.Load() {
+		return false
+	}
+	conn := qc.current()
+	return conn != nil && conn.Context().Err() == nil
+}
+
+// Ping performs an application-level round trip
+func (qc *quicConnection) Ping() error {
+	timeout := qc.config.ConnectTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	response, err := qc.Execute(&Request{
+		ID:      newConnectionID("ping"),
+		Method:  pingMethod,
+		Timeout: timeout,
+	})
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != 200 {
+		return &TransportError{Code: ErrorCodeServerUnavailable, Message: fmt.Sprintf("ping returned status %d", response.StatusCode)}
+	}
+	return nil
+}
+
+// Close closes the connection
+func (qc *quicConnection) Close() error {
+	qc.closeWithCode(quicCodeNoError, "closed")
+	return nil
+}
+
+func (qc *quicConnection) closeWithCode(code quic.ApplicationErrorCode, reason string) {
+	qc.closed.Store(true)
+	if conn := qc.current(); conn != nil {
+		_ = conn.CloseWithError(code, reason)
+	}
+	qc.markClosed()
+}
+
+// markClosed releases tracking state exactly once
+func (qc *quicConnection) markClosed() {
+	qc.closeOnce.Do(func() {
+		qc.transport.untrack(qc)
+		if qc.onClose != nil {
+			qc.onClose()
+		}
+	})
+}
+
+// serve accepts peer-initiated streams until the connection ends
+func (qc *quicConnection) serve(conn quic.Connection) {
+	for {
+		stream, err := conn.AcceptStream(conn.Context())
+		if err != nil {
+			// Server connections are finished once the peer goes away; client
+			// connections may be re-dialed by the next request
+			if !qc.isClient {
+				qc.closed.Store(true)
+				qc.markClosed()
+			}
+			return
+		}
+		go qc.handleStream(stream)
+	}
+}
+
+// handleStream dispatches a peer-initiated stream by its first frame
+func (qc *quicConnection) handleStream(stream quic.Stream) {
+	if timeout := qc.config.RequestTimeout; timeout > 0 {
+		_ = stream.SetReadDeadline(time.Now().Add(timeout))
+	}
+
+	frameType, payload, err := readFrame(stream)
+	if err != nil {
+		stream.CancelRead(quic.StreamErrorCode(quicCodeNoError))
+		stream.CancelWrite(quic.StreamErrorCode(quicCodeNoError))
+		return
+	}
+	_ = stream.SetReadDeadline(time.Time{})
+	received := len(payload) + frameHeaderSize
+
+	frameType, payload, err = qc.compression.decodeFrame(frameType, payload)
+	if err != nil {
+		stream.CancelRead(quic.StreamErrorCode(quicCodeRejected))
+		stream.CancelWrite(quic.StreamErrorCode(quicCodeRejected))
+		return
+	}
+
+	switch frameType {
+	case frameRequest:
+		request, acceptEncoding, err := decodeRequest(payload)
+		if err != nil {
+			stream.CancelWrite(quic.StreamErrorCode(quicCodeNoError))
+			return
+		}
+		codec := qc.compression.negotiate(acceptEncoding)
+		qc.peerCodec.Store(codec)
+
+		var response *Response
+		if request.Method == pingMethod {
+			response = serveRequest(func(*Request) *Response {
+				return &Response{StatusCode: 200, StatusMessage: "pong"}
+			}, request)
+		} else {
+			response = serveRequest(qc.handler, request)
+		}
+
+		body, err := encodeResponse(response, qc.compression.accepted())
+		if err != nil {
+			stream.CancelWrite(quic.StreamErrorCode(quicCodeNoError))
+			return
+		}
+		responseType, body := qc.compression.encodeFrame(frameResponse, codec, body)
+		if err := writeFrame(stream, responseType, body); err == nil {
+			qc.recordTransfer(len(body)+frameHeaderSize, received)
+		}
+		_ = stream.Close()
+
+	case frameStreamOpen:
+		var open wireStreamOpen
+		if err := json.Unmarshal(payload, &open); err != nil || qc.streamHandler == nil {
+			stream.CancelRead(quic.StreamErrorCode(quicCodeRejected))
+			stream.CancelWrite(quic.StreamErrorCode(quicCodeRejected))
+			return
+		}
+		qc.peerCodec.Store(qc.compression.negotiate(open.AcceptEncoding))
+		qc.streamHandler(newQUICStream(stream, open.StreamID, qc, 0, open.Window))
+
+	default:
+		stream.CancelRead(quic.StreamErrorCode(quicCodeRejected))
+		stream.CancelWrite(quic.StreamErrorCode(quicCodeRejected))
+	}
+}
+
+func (qc *quicConnection) requestContext(request *Request) (context.Context, context.CancelFunc) {
+	parent := request.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	timeout := request.Timeout
+	if timeout <= 0 {
+		timeout = qc.config.RequestTimeout
+	}
+
+	return withOptionalTimeout(parent, timeout)
+}
+
+func (qc *quicConnection) recordRequest(latency time.Duration, success bool, err error) {
+	qc.stats.recordRequest(latency, success)
+	qc.transport.stats.recordRequest(latency, success)
+
+	if err != nil {
+		qc.mutex.Lock()
+		qc.lastError = err
+		qc.mutex.Unlock()
+	}
+}
+
+func (qc *quicConnection) recordTransfer(sent, received int) {
+	qc.stats.recordTransfer(sent, received)
+	qc.transport.stats.recordTransfer(sent, received)
+}
+
+// streamError maps QUIC stream failures onto TransportError codes
+func (qc *quicConnection) streamError(ctx context.Context, err error) error {
+	var transportErr *TransportError
+	if errors.As(err, &transportErr) {
+		return transportErr
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return &TransportError{
+			Code:      ErrorCodeRequestTimeout,
+			Message:   "request deadline exceeded",
+			Cause:     ctxErr,
+			Retryable: true,
+			Temporary: true,
+		}
+	}
+
+	var idleErr *quic.IdleTimeoutError
+	var appErr *quic.ApplicationError
+	switch {
+	case errors.As(err, &idleErr), errors.As(err, &appErr), errors.Is(err, io.EOF):
+		return &TransportError{
+			Code:      ErrorCodeConnectionClosed,
+			Message:   "connection closed by peer",
+			Cause:     err,
+			Retryable: true,
+			Temporary: true,
+		}
+	default:
+		return &TransportError{
+			Code:      ErrorCodeProtocolError,
+			Message:   "stream failure",
+			Cause:     err,
+			Retryable: true,
+		}
+	}
+}
+
+// quicStream implements Stream over a QUIC stream using length-prefixed frames.
+// A reader goroutine buffers data frames and applies window updates, so
+// credit returned by the peer is seen even while the application only sends.
+type quicStream struct {
+	stream       quic.Stream
+	id           int64
+	conn         *quicConnection
+	timeout      time.Duration
+	createdAt    time.Time
+	flow         *streamFlow
+	sendMutex    sync.Mutex
+	receiveMutex sync.Mutex
+
+	messagesSent     atomic.Int64
+	messagesReceived atomic.Int64
+	bytesSent        atomic.Int64
+	bytesReceived    atomic.Int64
+	lastActivity     atomic.Int64
+	closed           atomic.Bool
+
+	lastError atomic.Value
+}
+
+func newQUICStream(stream quic.Stream, id int64, conn *quicConnection, timeout time.Duration, window int32) *quicStream {
+	qs := &quicStream{
+		stream:    stream,
+		id:        id,
+		conn:      conn,
+		timeout:   timeout,
+		createdAt: time.Now(),
+	}
+	qs.flow = newStreamFlow(window, backpressureReporter(conn.config, conn.address))
+	qs.lastActivity.Store(qs.createdAt.UnixNano())
+
+	go qs.readLoop()
+
+	return qs
+}
+
+// Send writes a message to the stream, waiting up to the stream timeout for
+// flow control credit
+func (qs *quicStream) Send(data []byte) error {
+	return qs.SendDeadline(data, sendDeadline(qs.timeout))
+}
+
+// SendDeadline writes a message to the stream, waiting until deadline for
+// flow control credit. A zero deadline waits indefinitely.
+func (qs *quicStream) SendDeadline(data []byte, deadline time.Time) error {
+	if qs.closed.Load() {
+		return &TransportError{Code: ErrorCodeConnectionClosed, Message: "stream is closed"}
+	}
+
+	if err := qs.flow.acquire(len(data), deadline); err != nil {
+		return err
+	}
+
+	frameType, payload := qs.conn.compression.encodeFrame(frameStreamData, qs.conn.peerCodec.Load(), data)
+	if err := qs.writeFrame(frameType, payload, deadline); err != nil {
+		return qs.fail(err)
+	}
+
+	qs.messagesSent.Add(1)
+	qs.bytesSent.Add(int64(len(data)))
+	qs.lastActivity.Store(time.Now().UnixNano())
+	qs.conn.recordTransfer(len(payload)+frameHeaderSize, 0)
+
+	return nil
+}
+
+// Available returns the bytes that can be sent without blocking
+func (qs *quicStream) Available() int {
+	return qs.flow.available()
+}
+
+// Receive returns the next message from the stream
+func (qs *quicStream) Receive() ([]byte, error) {
+	if qs.closed.Load() {
+		return nil, &TransportError{Code: ErrorCodeConnectionClosed, Message: "stream is closed"}
+	}
+
+	qs.receiveMutex.Lock()
+	defer qs.receiveMutex.Unlock()
+
+	data, update, err := qs.flow.pop(sendDeadline(qs.timeout))
+	if err != nil {
+		return nil, err
+	}
+
+	if update > 0 {
+		// A lost update only stalls the peer until the stream fails
+		if err := qs.writeFrame(frameWindowUpdate, encodeWindowUpdate(update), sendDeadline(qs.timeout)); err != nil {
+			qs.fail(err)
+		}
+	}
+
+	qs.messagesReceived.Add(1)
+	qs.bytesReceived.Add(int64(len(data)))
+	qs.lastActivity.Store(time.Now().UnixNano())
+
+	return data, nil
+}
+
+// GetStreamID returns the stream identifier
+func (qs *quicStream) GetStreamID() int64 {
+	return qs.id
+}
+
+// GetStreamMetrics returns per-stream metrics
+func (qs *quicStream) GetStreamMetrics() StreamMetrics {
+	metrics := StreamMetrics{
+		StreamID:         qs.id,
+		ConnectionID:     qs.conn.id,
+		CreatedAt:        qs.createdAt,
+		LastActivity:     time.Unix(0, qs.lastActivity.Load()),
+		MessagesSent:     qs.messagesSent.Load(),
+		MessagesReceived: qs.messagesReceived.Load(),
+		BytesSent:        qs.bytesSent.Load(),
+		BytesReceived:    qs.bytesReceived.Load(),
+		IsActive:         !qs.closed.Load(),
+	}
+	metrics.SendWindow, metrics.SendAvailable, metrics.BlockedSends, metrics.BlockedTime, metrics.Backpressure = qs.flow.stats()
+
+	if elapsed := time.Since(qs.createdAt).Seconds(); elapsed > 0 {
+		metrics.Throughput = float64(metrics.BytesSent+metrics.BytesReceived) / elapsed
+	}
+	if err, ok := qs.lastError.Load().(error); ok {
+		metrics.LastError = err
+	}
+
+	return metrics
+}
+
+// Close closes both directions of the stream
+func (qs *quicStream) Close() error {
+	if !qs.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	qs.flow.fail(&TransportError{Code: ErrorCodeConnectionClosed, Message: "stream is closed"})
+	qs.stream.CancelRead(quic.StreamErrorCode(quicCodeNoError))
+	return qs.stream.Close()
+}
+
+// readLoop buffers data frames and applies window updates until the stream ends
+func (qs *quicStream) readLoop() {
+	for {
+		frameType, payload, err := readFrame(qs.stream)
+		if err != nil {
+			qs.flow.fail(qs.fail(err))
+			return
+		}
+		received := len(payload) + frameHeaderSize
+
+		frameType, payload, err = qs.conn.compression.decodeFrame(frameType, payload)
+		if err != nil {
+			qs.flow.fail(qs.fail(err))
+			qs.stream.CancelRead(quic.StreamErrorCode(quicCodeRejected))
+			return
+		}
+
+		switch frameType {
+		case frameStreamData:
+			qs.conn.recordTransfer(0, received)
+			if err := qs.flow.push(payload); err != nil {
+				qs.flow.fail(qs.fail(err))
+				qs.stream.CancelRead(quic.StreamErrorCode(quicCodeRejected))
+				return
+			}
+
+		case frameWindowUpdate:
+			credit, err := decodeWindowUpdate(payload)
+			if err != nil {
+				qs.flow.fail(qs.fail(err))
+				return
+			}
+			qs.flow.grant(credit)
+
+		default:
+			qs.flow.fail(qs.fail(&TransportError{Code: ErrorCodeProtocolError, Message: fmt.Sprintf("unexpected frame type 0x%02x", frameType)}))
+			qs.stream.CancelRead(quic.StreamErrorCode(quicCodeRejected))
+			return
+		}
+	}
+}
+
+// writeFrame writes one frame, serialized with other writers on the stream
+func (qs *quicStream) writeFrame(frameType byte, payload []byte, deadline time.Time) error {
+	qs.sendMutex.Lock()
+	defer qs.sendMutex.Unlock()
+
+	_ = qs.stream.SetWriteDeadline(deadline)
+	return writeFrame(qs.stream, frameType, payload)
+}
+
+func (qs *quicStream) fail(err error) error {
+	if errors.Is(err, io.EOF) {
+		err = &TransportError{Code: ErrorCodeConnectionClosed, Message: "stream closed by peer", Cause: err}
+	}
+	qs.lastError.Store(err)
+	return err
+}
+
+// quicListener implements Listener over a QUIC listener
+type quicListener struct {
+	transport  *QUICTransport
+	listener   quicAcceptor
+	packetConn net.PacketConn
+	config     *ListenerConfig
+	startedAt  time.Time
+
+	totalAccepted atomic.Int64
+	active        atomic.Int64
+	rejected      atomic.Int64
+	totalAcceptNs atomic.Int64
+	closed        atomic.Bool
+	lastError     atomic.Value
+	asyncOnce     sync.Once
+	asyncChan     chan Connection
+}
+
+// Accept waits for the next incoming connection
+func (ql *quicListener) Accept() (Connection, error) {
+	for {
+		if ql.closed.Load() {
+			return nil, &TransportError{Code: ErrorCodeConnectionClosed, Message: "listener is closed"}
+		}
+
+		startTime := time.Now()
+		ctx, cancel := withOptionalTimeout(context.Background(), ql.config.AcceptTimeout)
+		conn, err := ql.listener.Accept(ctx)
+		cancel()
+		if err != nil {
+			code := ErrorCodeConnectionFailed
+			if errors.Is(err, context.DeadlineExceeded) {
+				code = ErrorCodeConnectionTimeout
+			}
+			transportErr := &TransportError{Code: code, Message: "accept failed", Cause: err, Temporary: code == ErrorCodeConnectionTimeout}
+			ql.lastError.Store(error(transportErr))
+			return nil, transportErr
+		}
+
+		if max := ql.config.MaxConnections; max > 0 && ql.active.Load() >= int64(max) {
+			ql.rejected.Add(1)
+			_ = conn.CloseWithError(quicCodeRejected, "connection limit reached")
+			continue
+		}
+
+		accepted := &quicConnection{
+			transport:     ql.transport,
+			config:        ql.transport.config,
+			address:       conn.RemoteAddr().String(),
+			id:            newConnectionID("quic-accepted"),
+			handler:       ql.config.Handler,
+			streamHandler: ql.config.StreamHandler,
+			conn:          conn,
+			establishedAt: time.Now(),
+			compression:   newPayloadCompressor(ql.transport.config, ql.transport.stats),
+			onClose: func() {
+				ql.active.Add(-1)
+			},
+		}
+		accepted.stats.touch()
+
+		ql.totalAccepted.Add(1)
+		ql.active.Add(1)
+		ql.totalAcceptNs.Add(int64(time.Since(startTime)))
+		ql.transport.track(accepted)
+
+		go accepted.serve(conn)
+
+		return accepted, nil
+	}
+}
+
+// AcceptAsync delivers incoming connections on a channel until the listener closes
+func (ql *quicListener) AcceptAsync() <-chan Connection {
+	ql.asyncOnce.Do(func() {
+		ql.asyncChan = make(chan Connection)
+
+		go func() {
+			defer close(ql.asyncChan)
+			for !ql.closed.Load() {
+				conn, err := ql.Accept()
+				if err != nil {
+					var transportErr *TransportError
+					if errors.As(err, &transportErr) && transportErr.IsTemporary() {
+						continue
+					}
+					if ql.closed.Load() {
+						return
+					}
+					time.Sleep(10 * time.Millisecond)
+					continue
+				}
+				ql.asyncChan <- conn
+			}
+		}()
+	})
+
+	return ql.asyncChan
+}
+
+// GetListenAddress returns the bound address
+func (ql *quicListener) GetListenAddress() string {
+	return ql.listener.Addr().String()
+}
+
+// GetListenerMetrics returns listener metrics
+func (ql *quicListener) GetListenerMetrics() ListenerMetrics {
+	metrics := ListenerMetrics{
+		ListenAddress:       ql.GetListenAddress(),
+		StartedAt:           ql.startedAt,
+		TotalAccepted:       ql.totalAccepted.Load(),
+		ActiveConnections:   ql.active.Load(),
+		RejectedConnections: ql.rejected.Load(),
+		IsListening:         !ql.closed.Load(),
+	}
+
+	if elapsed := time.Since(ql.startedAt).Seconds(); elapsed > 0 {
+		metrics.AcceptRate = float64(metrics.TotalAccepted) / elapsed
+	}
+	if metrics.TotalAccepted > 0 {
+		metrics.AverageAcceptTime = time.Duration(ql.totalAcceptNs.Load() / metrics.TotalAccepted)
+	}
+	if err, ok := ql.lastError.Load().(error); ok {
+		metrics.LastError = err
+	}
+
+	return metrics
+}
+
+// Close stops accepting connections
+func (ql *quicListener) Close() error {
+	if !ql.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	ql.transport.removeListener(ql)
+
+	// quic-go leaves caller-provided sockets open
+	err := ql.listener.Close()
+	if closeErr := ql.packetConn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// listenPacket opens the UDP socket for a listener. With ipv6Only set the
+// socket does not accept IPv4 traffic, including on wildcard addresses.
+func listenPacket(address string, ipv6Only bool) (net.PacketConn, error) {
+	network := "udp"
+	if ipv6Only {
+		network = "udp6"
+	}
+
+	udpAddr, err := net.ResolveUDPAddr(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return net.ListenUDP(network, udpAddr)
+}
+
+// newQUICConfig derives quic-go settings from the transport configuration
+func newQUICConfig(config *TransportConfig) *quic.Config {
+	quicConfig := &quic.Config{
+		HandshakeIdleTimeout: config.ConnectTimeout,
+		MaxIdleTimeout:       config.IdleTimeout,
+		KeepAlivePeriod:      config.KeepAliveTimeout,
+		MaxIncomingStreams:   quicDefaultMaxStreams,
+		Allow0RTT:            config.Enable0RTT,
+	}
+
+	if !config.EnableMultiplexing {
+		quicConfig.MaxIncomingStreams = 1
+	}
+
+	if config.BufferSize > 0 {
+		quicConfig.InitialStreamReceiveWindow = uint64(config.BufferSize)
+		quicConfig.InitialConnectionReceiveWindow = uint64(config.BufferSize) * 2
+	}
+
+	return quicConfig
+}
+
+// quicAcceptor is the part of a quic-go listener used by quicListener
+type quicAcceptor interface {
+	Accept(ctx context.Context) (quic.Connection, error)
+	Addr() net.Addr
+	Close() error
+}
+
+// earlyAcceptor adapts a 0-RTT capable listener to quicAcceptor
+type earlyAcceptor struct {
+	*quic.EarlyListener
+}
+
+func (ea earlyAcceptor) Accept(ctx context.Context) (quic.Connection, error) {
+	return ea.EarlyListener.Accept(ctx)
+}
+
+// listenQUIC accepts 0-RTT connections only when the config allows 0-RTT;
+// otherwise connections are handed over once their handshake completes
+func listenQUIC(packetConn net.PacketConn, tlsConfig *tls.Config, quicConfig *quic.Config) (quicAcceptor, error) {
+	if quicConfig.Allow0RTT {
+		ln, err := quic.ListenEarly(packetConn, tlsConfig, quicConfig)
+		if err != nil {
+			return nil, err
+		}
+		return earlyAcceptor{ln}, nil
+	}
+	return quic.Listen(packetConn, tlsConfig, quicConfig)
+}
+
+// withOptionalTimeout applies a timeout only when one is configured
+func withOptionalTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(parent, timeout)
+	}
+	return context.WithCancel(parent)
+}
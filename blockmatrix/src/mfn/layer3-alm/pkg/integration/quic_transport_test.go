@@ -0,0 +1,120 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewHyperMeshTransportSelection(t *testing.T) {
+	cases := []struct {
+		name   string
+		config *TransportConfig
+		want   string
+	}{
+		{"nil config", nil, "*integration.MockHyperMeshTransport"},
+		{"empty protocol with EnableQUIC", &TransportConfig{EnableQUIC: true}, "*integration.MockHyperMeshTransport"},
+		{"mock", &TransportConfig{Protocol: ProtocolMock}, "*integration.MockHyperMeshTransport"},
+		{"quic", &TransportConfig{Protocol: ProtocolQUIC}, "*integration.QUICTransport"},
+		{"grpc", &TransportConfig{Protocol: ProtocolGRPC}, "*integration.GRPCTransport"},
+	}
+
+	for _, tc := range cases {
+		transport, err := NewHyperMeshTransport(tc.config)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := fmt.Sprintf("%T", transport); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+		transport.Shutdown()
+	}
+}
+
+func TestQUICZeroRTTIsOptIn(t *testing.T) {
+	if newQUICConfig(&TransportConfig{}).Allow0RTT {
+		t.Error("0-RTT enabled without Enable0RTT")
+	}
+	if !newQUICConfig(&TransportConfig{Enable0RTT: true}).Allow0RTT {
+		t.Error("Enable0RTT did not allow 0-RTT")
+	}
+}
+
+func TestQUICConcurrentReconnectDialsOnce(t *testing.T) {
+	config := &TransportConfig{
+		Protocol:       ProtocolQUIC,
+		ConnectTimeout: 5 * time.Second,
+		IdleTimeout:    30 * time.Second,
+		TLSConfig:      &TLSConfig{},
+	}
+	transport, err := NewQUICTransport(config)
+	if err != nil {
+		t.Fatalf("NewQUICTransport: %v", err)
+	}
+	defer transport.Shutdown()
+
+	listener, err := transport.Listen(&ListenerConfig{Address: "127.0.0.1", Port: 0})
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer listener.Close()
+
+	var accepted atomic.Int64
+	waitAccepted := func(want int64) {
+		deadline := time.Now().Add(2 * time.Second)
+		for accepted.Load() < want && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	go func() {
+		for range listener.AcceptAsync() {
+			accepted.Add(1)
+		}
+	}()
+
+	address, err := ParseEndpointAddress(listener.GetListenAddress(), 0)
+	if err != nil {
+		t.Fatalf("ParseEndpointAddress: %v", err)
+	}
+	clientConfig := *config
+	clientConfig.Address = address.Host
+	clientConfig.Port = address.Port
+
+	conn, err := transport.Connect(&clientConfig)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	client := conn.(*quicConnection)
+
+	// The server drops connections closed before it accepts them, so the
+	// initial connection must be accepted before it is killed
+	waitAccepted(1)
+	dead := client.current()
+	dead.CloseWithError(0, "test")
+	<-dead.Context().Done()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.reconnect(context.Background(), dead); err != nil {
+				t.Errorf("reconnect: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	waitAccepted(2)
+	time.Sleep(100 * time.Millisecond)
+
+	if got := accepted.Load(); got != 2 {
+		t.Errorf("server accepted %d connections, want 2 (initial and one redial)", got)
+	}
+	if !client.IsHealthy() {
+		t.Error("client connection unhealthy after reconnect")
+	}
+}
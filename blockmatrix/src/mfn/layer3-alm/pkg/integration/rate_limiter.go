@@ -0,0 +1,310 @@
+// Package integration implements token-bucket rate limiting at the integration boundary
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+)
+
+// Rate limit scopes
+const (
+	RateLimitScopeService = "service"
+	RateLimitScopeTenant  = "tenant"
+)
+
+// DefaultTenant is charged for requests whose context carries no tenant
+const DefaultTenant = internal.DefaultTenant
+
+// ErrRateLimited matches every RateLimitError with errors.Is
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimit is a sustained rate in requests per second with a burst allowance.
+// A zero Rate leaves the scope unlimited.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitConfig configures a RateLimiter
+type RateLimitConfig struct {
+	// Limits applied to each service and each tenant
+	Service RateLimit
+	Tenant  RateLimit
+
+	// Limits for individual services or tenants, replacing the defaults above
+	ServiceOverrides map[string]RateLimit
+	TenantOverrides  map[string]RateLimit
+
+	// Buckets unused for this long are dropped
+	IdleTimeout time.Duration
+}
+
+// RateLimitError is returned when a request exceeds a limit. It is the
+// equivalent of an HTTP 429 response.
+type RateLimitError struct {
+	Scope      string
+	Key        string
+	Limit      RateLimit
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (rle *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded for %s %q (%.1f/s, burst %d), retry after %v",
+		rle.Scope, rle.Key, rle.Limit.Rate, rle.Limit.Burst, rle.RetryAfter)
+}
+
+// StatusCode returns the HTTP status equivalent of the error
+func (rle *RateLimitError) StatusCode() int {
+	return http.StatusTooManyRequests
+}
+
+// Is reports whether target is ErrRateLimited
+func (rle *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// RateLimiterStats summarizes limiter decisions and current bucket state
+type RateLimiterStats struct {
+	Allowed int64
+	Limited int64
+	Buckets []RateLimitBucketStats
+}
+
+// RateLimitBucketStats is the state of one service or tenant bucket
+type RateLimitBucketStats struct {
+	Scope   string
+	Key     string
+	Limit   RateLimit
+	Tokens  float64
+	Allowed int64
+	Limited int64
+}
+
+// tokenBucket holds the tokens available to one service or tenant
+type tokenBucket struct {
+	limit   RateLimit
+	tokens  float64
+	updated time.Time
+	allowed int64
+	limited int64
+}
+
+// available returns the tokens held at now
+func (tb *tokenBucket) available(now time.Time) float64 {
+	tokens := tb.tokens
+	if elapsed := now.Sub(tb.updated).Seconds(); elapsed > 0 {
+		tokens += elapsed * tb.limit.Rate
+	}
+	if burst := float64(tb.limit.Burst); tokens > burst {
+		tokens = burst
+	}
+	return tokens
+}
+
+// refill adds the tokens accrued since the last update
+func (tb *tokenBucket) refill(now time.Time) {
+	tb.tokens = tb.available(now)
+	tb.updated = now
+}
+
+// retryAfter returns how long until a token is available
+func (tb *tokenBucket) retryAfter() time.Duration {
+	missing := 1 - tb.tokens
+	if missing <= 0 {
+		return 0
+	}
+	return time.Duration(missing / tb.limit.Rate * float64(time.Second))
+}
+
+// RateLimiter applies per-service and per-tenant token buckets. A request is
+// admitted only when both its service and tenant buckets hold a token, and
+// takes one from each; a rejected request consumes nothing.
+type RateLimiter struct {
+	config *RateLimitConfig
+
+	// Buckets by scope, then by key
+	buckets map[string]map[string]*tokenBucket
+	mutex   sync.Mutex
+
+	// Counters
+	allowed atomic.Int64
+	limited atomic.Int64
+}
+
+// NewRateLimiter creates a rate limiter
+func NewRateLimiter(config *RateLimitConfig) *RateLimiter {
+	if config == nil {
+		config = DefaultRateLimitConfig()
+	}
+
+	return &RateLimiter{
+		config: config,
+		buckets: map[string]map[string]*tokenBucket{
+			RateLimitScopeService: make(map[string]*tokenBucket),
+			RateLimitScopeTenant:  make(map[string]*tokenBucket),
+		},
+	}
+}
+
+// Allow admits one request from tenant to service, returning a
+// *RateLimitError when either limit is exhausted
+func (rl *RateLimiter) Allow(tenant, service string) error {
+	if tenant == "" {
+		tenant = DefaultTenant
+	}
+
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	now := time.Now()
+	tenantBucket := rl.bucket(RateLimitScopeTenant, tenant, now)
+	serviceBucket := rl.bucket(RateLimitScopeService, service, now)
+
+	// Tenant limits are checked first as they protect the optimizer as a whole
+	for _, check := range []struct {
+		scope  string
+		key    string
+		bucket *tokenBucket
+	}{
+		{RateLimitScopeTenant, tenant, tenantBucket},
+		{RateLimitScopeService, service, serviceBucket},
+	} {
+		if check.bucket == nil || check.bucket.tokens >= 1 {
+			continue
+		}
+
+		check.bucket.limited++
+		rl.limited.Add(1)
+		return &RateLimitError{
+			Scope:      check.scope,
+			Key:        check.key,
+			Limit:      check.bucket.limit,
+			RetryAfter: check.bucket.retryAfter(),
+		}
+	}
+
+	for _, bucket := range []*tokenBucket{tenantBucket, serviceBucket} {
+		if bucket != nil {
+			bucket.tokens--
+			bucket.allowed++
+		}
+	}
+	rl.allowed.Add(1)
+
+	return nil
+}
+
+// Stats returns limiter counters and the state of every bucket
+func (rl *RateLimiter) Stats() RateLimiterStats {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	stats := RateLimiterStats{
+		Allowed: rl.allowed.Load(),
+		Limited: rl.limited.Load(),
+	}
+
+	now := time.Now()
+	for scope, buckets := range rl.buckets {
+		for key, bucket := range buckets {
+			stats.Buckets = append(stats.Buckets, RateLimitBucketStats{
+				Scope:   scope,
+				Key:     key,
+				Limit:   bucket.limit,
+				Tokens:  bucket.available(now),
+				Allowed: bucket.allowed,
+				Limited: bucket.limited,
+			})
+		}
+	}
+
+	sort.Slice(stats.Buckets, func(i, j int) bool {
+		if stats.Buckets[i].Scope != stats.Buckets[j].Scope {
+			return stats.Buckets[i].Scope < stats.Buckets[j].Scope
+		}
+		return stats.Buckets[i].Key < stats.Buckets[j].Key
+	})
+
+	return stats
+}
+
+// bucket returns the refilled bucket for key, creating it full on first use.
+// Scopes without a limit have no bucket.
+func (rl *RateLimiter) bucket(scope, key string, now time.Time) *tokenBucket {
+	buckets := rl.buckets[scope]
+	if bucket, exists := buckets[key]; exists {
+		bucket.refill(now)
+		return bucket
+	}
+
+	limit := rl.limitFor(scope, key)
+	if limit.Rate <= 0 {
+		return nil
+	}
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+
+	rl.evictIdle(buckets, now)
+
+	bucket := &tokenBucket{limit: limit, tokens: float64(limit.Burst), updated: now}
+	buckets[key] = bucket
+	return bucket
+}
+
+func (rl *RateLimiter) limitFor(scope, key string) RateLimit {
+	if scope == RateLimitScopeTenant {
+		if limit, exists := rl.config.TenantOverrides[key]; exists {
+			return limit
+		}
+		return rl.config.Tenant
+	}
+
+	if limit, exists := rl.config.ServiceOverrides[key]; exists {
+		return limit
+	}
+	return rl.config.Service
+}
+
+// evictIdle drops idle buckets once a scope grows large, so the map does not
+// grow with every key seen. An idle bucket has refilled, so dropping it does
+// not change future decisions.
+func (rl *RateLimiter) evictIdle(buckets map[string]*tokenBucket, now time.Time) {
+	if rl.config.IdleTimeout <= 0 || len(buckets) < 1024 {
+		return
+	}
+
+	for key, bucket := range buckets {
+		if now.Sub(bucket.updated) >= rl.config.IdleTimeout {
+			delete(buckets, key)
+		}
+	}
+}
+
+// WithTenant returns a context whose integration calls are charged to tenant
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return internal.WithTenant(ctx, tenant)
+}
+
+// TenantFromContext returns the tenant set with WithTenant, or DefaultTenant
+func TenantFromContext(ctx context.Context) string {
+	return internal.TenantFromContext(ctx)
+}
+
+// DefaultRateLimitConfig returns default rate limit configuration
+func DefaultRateLimitConfig() *RateLimitConfig {
+	return &RateLimitConfig{
+		Service:     RateLimit{Rate: 1000, Burst: 2000},
+		Tenant:      RateLimit{Rate: 5000, Burst: 10000},
+		IdleTimeout: 10 * time.Minute,
+	}
+}
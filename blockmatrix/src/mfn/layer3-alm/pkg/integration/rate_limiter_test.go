@@ -0,0 +1,119 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterServiceBurst(t *testing.T) {
+	rl := NewRateLimiter(&RateLimitConfig{Service: RateLimit{Rate: 1, Burst: 2}})
+
+	for i := 0; i < 2; i++ {
+		if err := rl.Allow("", "orders"); err != nil {
+			t.Fatalf("request %d within the burst: %v", i, err)
+		}
+	}
+
+	err := rl.Allow("", "orders")
+	var limited *RateLimitError
+	if !errors.As(err, &limited) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("request beyond the burst returned %v, want a rate limit error", err)
+	}
+	if limited.Scope != RateLimitScopeService || limited.Key != "orders" || limited.StatusCode() != http.StatusTooManyRequests {
+		t.Errorf("error %+v, want service orders and status 429", limited)
+	}
+	if limited.RetryAfter <= 0 || limited.RetryAfter > time.Second {
+		t.Errorf("retry after %v, want within a second at 1/s", limited.RetryAfter)
+	}
+
+	// Other services have buckets of their own
+	if err := rl.Allow("", "payments"); err != nil {
+		t.Errorf("payments limited by orders: %v", err)
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	rl := NewRateLimiter(&RateLimitConfig{Service: RateLimit{Rate: 100, Burst: 1}})
+
+	if err := rl.Allow("", "orders"); err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if err := rl.Allow("", "orders"); err == nil {
+		t.Fatal("empty bucket admitted a request")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := rl.Allow("", "orders"); err != nil {
+		t.Errorf("bucket not refilled after 20ms at 100/s: %v", err)
+	}
+}
+
+func TestRateLimiterTenants(t *testing.T) {
+	rl := NewRateLimiter(&RateLimitConfig{
+		Service:         RateLimit{Rate: 1, Burst: 2},
+		Tenant:          RateLimit{Rate: 1, Burst: 1},
+		TenantOverrides: map[string]RateLimit{"batch": {}},
+	})
+
+	if err := rl.Allow("acme", "orders"); err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+
+	// The tenant is checked first, and a rejection takes no service token
+	err := rl.Allow("acme", "orders")
+	var limited *RateLimitError
+	if !errors.As(err, &limited) || limited.Scope != RateLimitScopeTenant || limited.Key != "acme" {
+		t.Fatalf("second acme request returned %v, want the tenant limit", err)
+	}
+	if err := rl.Allow("other", "orders"); err != nil {
+		t.Fatalf("orders bucket charged for a rejected request: %v", err)
+	}
+
+	// An override without a rate leaves the tenant unlimited
+	if err := rl.Allow("batch", "reports"); err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if err := rl.Allow("batch", "reports"); err != nil {
+		t.Errorf("unlimited tenant limited: %v", err)
+	}
+
+	stats := rl.Stats()
+	if stats.Allowed != 4 || stats.Limited != 1 {
+		t.Errorf("stats allowed %d limited %d, want 4 and 1", stats.Allowed, stats.Limited)
+	}
+	for _, bucket := range stats.Buckets {
+		if bucket.Scope == RateLimitScopeTenant && bucket.Key == "batch" {
+			t.Error("bucket kept for an unlimited tenant")
+		}
+	}
+}
+
+func TestRateLimiterServiceOverride(t *testing.T) {
+	rl := NewRateLimiter(&RateLimitConfig{
+		Service:          RateLimit{Rate: 1, Burst: 1},
+		ServiceOverrides: map[string]RateLimit{"search": {Rate: 1, Burst: 3}},
+	})
+
+	for i := 0; i < 3; i++ {
+		if err := rl.Allow("", "search"); err != nil {
+			t.Fatalf("request %d within the overridden burst: %v", i, err)
+		}
+	}
+	if err := rl.Allow("", "search"); err == nil {
+		t.Error("overridden burst exceeded")
+	}
+}
+
+func TestTenantFromContext(t *testing.T) {
+	if tenant := TenantFromContext(context.Background()); tenant != DefaultTenant {
+		t.Errorf("TenantFromContext(background) = %q, want %q", tenant, DefaultTenant)
+	}
+	if tenant := TenantFromContext(WithTenant(context.Background(), "acme")); tenant != "acme" {
+		t.Errorf("TenantFromContext(acme) = %q", tenant)
+	}
+	if tenant := TenantFromContext(WithTenant(context.Background(), "")); tenant != DefaultTenant {
+		t.Errorf("TenantFromContext(empty) = %q, want %q", tenant, DefaultTenant)
+	}
+}
@@ -0,0 +1,87 @@
+// Package integration implements region summary exchange over the HyperMesh transport
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+)
+
+// regionExchangePath is served by RegionExchangeHandler
+const regionExchangePath = "/alm/regions/exchange"
+
+// TransportRegionExchange implements internal.RegionExchange over a
+// ConnectionPool, exchanging with a parent coordinator that serves
+// RegionExchangeHandler
+type TransportRegionExchange struct {
+	pool    *ConnectionPool
+	address string
+}
+
+// NewTransportRegionExchange creates a region exchange with the parent at address
+func NewTransportRegionExchange(pool *ConnectionPool, address string) *TransportRegionExchange {
+	return &TransportRegionExchange{
+		pool:    pool,
+		address: address,
+	}
+}
+
+// Exchange sends the subtree's summaries to the parent and returns the others
+func (te *TransportRegionExchange) Exchange(ctx context.Context, summaries []internal.RegionSummary) ([]internal.RegionSummary, error) {
+	body, err := json.Marshal(summaries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode region summaries: %w", err)
+	}
+
+	timeout := 5 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	reply, err := te.pool.Execute(ctx, te.address, &Request{
+		ID:      newConnectionID("regions"),
+		Method:  "POST",
+		Path:    regionExchangePath,
+		Body:    body,
+		Timeout: timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("region exchange with %s failed: %w", te.address, err)
+	}
+	if reply.StatusCode != 200 {
+		return nil, fmt.Errorf("region exchange with %s returned status %d: %s", te.address, reply.StatusCode, reply.StatusMessage)
+	}
+
+	var received []internal.RegionSummary
+	if err := json.Unmarshal(reply.Body, &received); err != nil {
+		return nil, fmt.Errorf("failed to decode region summaries from %s: %w", te.address, err)
+	}
+	return received, nil
+}
+
+// RegionExchangeHandler serves children's region exchanges with hierarchy
+// and passes every other request to next
+func RegionExchangeHandler(hierarchy *internal.RegionHierarchy, next RequestHandler) RequestHandler {
+	return func(request *Request) *Response {
+		if request.Path != regionExchangePath {
+			if next == nil {
+				return nil
+			}
+			return next(request)
+		}
+
+		var summaries []internal.RegionSummary
+		if err := json.Unmarshal(request.Body, &summaries); err != nil {
+			return &Response{StatusCode: 400, StatusMessage: fmt.Sprintf("invalid region summaries: %v", err)}
+		}
+
+		body, err := json.Marshal(hierarchy.Handle(summaries))
+		if err != nil {
+			return &Response{StatusCode: 500, StatusMessage: err.Error()}
+		}
+		return &Response{StatusCode: 200, Body: body}
+	}
+}
@@ -0,0 +1,433 @@
+// Package integration implements ServiceDiscoveryInterface on top of the ALM service registry
+package integration
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/service"
+)
+
+// Registry tags used to carry HyperMesh service attributes on registry instances
+const (
+	namespaceTag   = "hypermesh.namespace"
+	serviceIDTag   = "hypermesh.service_id"
+	endpointIDTag  = "hypermesh.endpoint_id"
+	serviceTypeTag = "hypermesh.service_type"
+)
+
+// HyperMesh health status names
+const (
+	HealthStatusUnknown   = "unknown"
+	HealthStatusHealthy   = "healthy"
+	HealthStatusDegraded  = "degraded"
+	HealthStatusUnhealthy = "unhealthy"
+	HealthStatusCritical  = "critical"
+)
+
+// RegistryServiceDiscovery implements ServiceDiscoveryInterface backed by an
+// EnhancedServiceRegistry.
+//
+// Every endpoint of a HyperMeshService is registered as its own registry
+// instance, so the registry ranks endpoints individually; discovery results
+// are grouped back into HyperMeshServices in rank order.
+type RegistryServiceDiscovery struct {
+	registry *service.EnhancedServiceRegistry
+
+	// Registered HyperMesh services and the registry instances backing them
+	services  map[string]*HyperMeshService
+	instances map[string][]string
+	owners    map[string]instanceOwner
+
+	mutex sync.RWMutex
+}
+
+// instanceOwner locates the HyperMesh endpoint behind a registry instance
+type instanceOwner struct {
+	serviceID string
+	endpoint  *Endpoint
+}
+
+// NewRegistryServiceDiscovery creates a ServiceDiscoveryInterface backed by registry
+func NewRegistryServiceDiscovery(registry *service.EnhancedServiceRegistry) *RegistryServiceDiscovery {
+	return &RegistryServiceDiscovery{
+		registry:  registry,
+		services:  make(map[string]*HyperMeshService),
+		instances: make(map[string][]string),
+		owners:    make(map[string]instanceOwner),
+	}
+}
+
+// RegisterService registers every endpoint of hmService with the registry.
+// Re-registering an existing service ID replaces its endpoints.
+func (rsd *RegistryServiceDiscovery) RegisterService(hmService *HyperMeshService) error {
+	if hmService == nil {
+		return fmt.Errorf("service is nil")
+	}
+	if hmService.ID == "" {
+		return fmt.Errorf("service ID is required")
+	}
+	if len(hmService.Endpoints) == 0 {
+		return fmt.Errorf("service %s has no endpoints", hmService.ID)
+	}
+
+	rsd.mutex.Lock()
+	defer rsd.mutex.Unlock()
+
+	if _, exists := rsd.services[hmService.ID]; exists {
+		rsd.unregisterLocked(hmService.ID)
+	}
+
+	instanceIDs := make([]string, 0, len(hmService.Endpoints))
+	for i, endpoint := range hmService.Endpoints {
+		instance := newRegistryInstance(hmService, endpoint, i)
+		if err := rsd.registry.RegisterService(instance); err != nil {
+			// Roll back the endpoints registered so far
+			for _, instanceID := range instanceIDs {
+				rsd.registry.UnregisterService(instanceID)
+				delete(rsd.owners, instanceID)
+			}
+			return fmt.Errorf("failed to register endpoint %s of service %s: %w", instance.ID, hmService.ID, err)
+		}
+
+		instanceIDs = append(instanceIDs, instance.ID)
+		rsd.owners[instance.ID] = instanceOwner{serviceID: hmService.ID, endpoint: endpoint}
+
+		// The registry marks new instances healthy; carry over known health
+		if health := endpointHealth(hmService, endpoint); health != nil {
+			rsd.registry.UpdateServiceHealth(instance.ID, toHealthMetrics(health, instance.ThroughputRPS))
+		}
+	}
+
+	rsd.services[hmService.ID] = hmService
+	rsd.instances[hmService.ID] = instanceIDs
+
+	return nil
+}
+
+// UnregisterService removes every endpoint of the service from the registry
+func (rsd *RegistryServiceDiscovery) UnregisterService(serviceID string) error {
+	rsd.mutex.Lock()
+	defer rsd.mutex.Unlock()
+
+	if _, exists := rsd.services[serviceID]; !exists {
+		return fmt.Errorf("service %s not found", serviceID)
+	}
+
+	rsd.unregisterLocked(serviceID)
+	return nil
+}
+
+// DiscoverServices queries the registry and groups ranked endpoints into services
+func (rsd *RegistryServiceDiscovery) DiscoverServices(query *ServiceQuery) ([]*HyperMeshService, error) {
+	if query == nil {
+		query = &ServiceQuery{}
+	}
+
+	result, err := rsd.registry.DiscoverServices(service.ServiceQuery{
+		ServiceName:     query.ServiceName,
+		RequiredTags:    registryTags(query.Namespace, query.Labels),
+		IncludeDegraded: !query.HealthOnly,
+		SortBy:          service.SortByHealth,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("registry discovery failed: %w", err)
+	}
+
+	instances := make([]*service.ServiceInstance, 0, len(result.Services))
+	for _, ranked := range result.Services {
+		instances = append(instances, ranked.Service)
+	}
+
+	services := rsd.groupInstances(instances)
+	if query.MaxResults > 0 && len(services) > query.MaxResults {
+		services = services[:query.MaxResults]
+	}
+
+	return services, nil
+}
+
+// UpdateServiceHealth applies health to every endpoint of the service
+func (rsd *RegistryServiceDiscovery) UpdateServiceHealth(serviceID string, health *HealthStatus) error {
+	if health == nil {
+		return fmt.Errorf("health status is nil")
+	}
+
+	rsd.mutex.Lock()
+	defer rsd.mutex.Unlock()
+
+	hmService, exists := rsd.services[serviceID]
+	if !exists {
+		return fmt.Errorf("service %s not found", serviceID)
+	}
+
+	for _, instanceID := range rsd.instances[serviceID] {
+		throughput := 0.0
+		if instance, found := rsd.registry.GetService(instanceID); found {
+			throughput = instance.ThroughputRPS
+		}
+		if err := rsd.registry.UpdateServiceHealth(instanceID, toHealthMetrics(health, throughput)); err != nil {
+			return fmt.Errorf("failed to update health of endpoint %s: %w", instanceID, err)
+		}
+	}
+
+	healthCopy := *health
+	hmService.Health = &healthCopy
+
+	return nil
+}
+
+// lookupInstance returns the HyperMesh service and endpoint behind a registry instance
+func (rsd *RegistryServiceDiscovery) lookupInstance(instanceID string) (*HyperMeshService, *Endpoint, bool) {
+	rsd.mutex.RLock()
+	defer rsd.mutex.RUnlock()
+
+	owner, exists := rsd.owners[instanceID]
+	if !exists {
+		return nil, nil, false
+	}
+	return rsd.services[owner.serviceID], owner.endpoint, true
+}
+
+// lookupService returns a registered HyperMesh service by ID
+func (rsd *RegistryServiceDiscovery) lookupService(serviceID string) (*HyperMeshService, bool) {
+	rsd.mutex.RLock()
+	defer rsd.mutex.RUnlock()
+
+	hmService, exists := rsd.services[serviceID]
+	return hmService, exists
+}
+
+// serviceIDs returns the IDs of every registered HyperMesh service, sorted
+func (rsd *RegistryServiceDiscovery) serviceIDs() []string {
+	rsd.mutex.RLock()
+	defer rsd.mutex.RUnlock()
+
+	ids := make([]string, 0, len(rsd.services))
+	for id := range rsd.services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// nodeForAddress returns the node hosting the endpoint at a "host:port" address
+func (rsd *RegistryServiceDiscovery) nodeForAddress(address string) (int64, bool) {
+	rsd.mutex.RLock()
+	defer rsd.mutex.RUnlock()
+
+	for _, owner := range rsd.owners {
+		endpoint := owner.endpoint
+		if sameTransportAddress(transportAddress(endpoint.Address, endpoint.Port), address) {
+			return endpoint.NodeID, true
+		}
+	}
+	return 0, false
+}
+
+// addressForNode returns the "host:port" address of an endpoint hosted on a
+// node, the lowest if it hosts several
+func (rsd *RegistryServiceDiscovery) addressForNode(nodeID int64) (string, bool) {
+	rsd.mutex.RLock()
+	defer rsd.mutex.RUnlock()
+
+	address := ""
+	for _, owner := range rsd.owners {
+		endpoint := owner.endpoint
+		if endpoint.NodeID != nodeID {
+			continue
+		}
+		if candidate := transportAddress(endpoint.Address, endpoint.Port); address == "" || candidate < address {
+			address = candidate
+		}
+	}
+	return address, address != ""
+}
+
+// groupInstances folds ranked registry instances into HyperMeshServices,
+// preserving the rank of each service's best endpoint
+func (rsd *RegistryServiceDiscovery) groupInstances(instances []*service.ServiceInstance) []*HyperMeshService {
+	rsd.mutex.RLock()
+	defer rsd.mutex.RUnlock()
+
+	grouped := make(map[string]*HyperMeshService)
+	var ordered []*HyperMeshService
+
+	for _, instance := range instances {
+		owner, exists := rsd.owners[instance.ID]
+		if !exists {
+			continue // Registered directly with the registry, not through HyperMesh
+		}
+
+		hmService, exists := grouped[owner.serviceID]
+		if !exists {
+			hmService = copyServiceWithoutEndpoints(rsd.services[owner.serviceID])
+			hmService.Health = fromServiceInstance(instance)
+			grouped[owner.serviceID] = hmService
+			ordered = append(ordered, hmService)
+		}
+
+		hmService.Endpoints = append(hmService.Endpoints, endpointFromInstance(owner.endpoint, instance))
+	}
+
+	return ordered
+}
+
+func (rsd *RegistryServiceDiscovery) unregisterLocked(serviceID string) {
+	for _, instanceID := range rsd.instances[serviceID] {
+		// The instance may already have been removed by a completed drain
+		rsd.registry.UnregisterService(instanceID)
+		delete(rsd.owners, instanceID)
+	}
+
+	delete(rsd.instances, serviceID)
+	delete(rsd.services, serviceID)
+}
+
+// newRegistryInstance maps one HyperMesh endpoint onto a registry instance
+func newRegistryInstance(hmService *HyperMeshService, endpoint *Endpoint, index int) *service.ServiceInstance {
+	endpointID := endpoint.ID
+	if endpointID == "" {
+		endpointID = fmt.Sprintf("%d", index)
+	}
+
+	tags := registryTags(hmService.Namespace, hmService.Labels)
+	tags[serviceIDTag] = hmService.ID
+	tags[endpointIDTag] = endpointID
+
+	metadata := make(map[string]interface{}, len(hmService.Metadata))
+	for key, value := range hmService.Metadata {
+		metadata[key] = value
+	}
+
+	serviceType := hmService.Labels[serviceTypeTag]
+	if serviceType == "" {
+		serviceType = hmService.Name
+	}
+
+	instance := &service.ServiceInstance{
+		ID:          hmService.ID + "/" + endpointID,
+		Name:        hmService.Name,
+		Version:     hmService.Version,
+		NodeID:      endpoint.NodeID,
+		Address:     endpoint.Address,
+		Port:        endpoint.Port,
+		ServiceType: serviceType,
+		Tags:        tags,
+		Metadata:    metadata,
+	}
+
+	if endpoint.Metrics != nil {
+		instance.ThroughputRPS = endpoint.Metrics.ThroughputRPS
+		instance.ResponseTime = endpoint.Metrics.AverageLatency
+	}
+
+	return instance
+}
+
+// registryTags builds the registry tag set for a namespace and label selector
+func registryTags(namespace string, labels map[string]string) map[string]string {
+	tags := make(map[string]string, len(labels)+1)
+	for key, value := range labels {
+		tags[key] = value
+	}
+	if namespace != "" {
+		tags[namespaceTag] = namespace
+	}
+	return tags
+}
+
+// endpointHealth returns the most specific known health for an endpoint
+func endpointHealth(hmService *HyperMeshService, endpoint *Endpoint) *HealthStatus {
+	if endpoint.Health != nil {
+		return endpoint.Health
+	}
+	return hmService.Health
+}
+
+func copyServiceWithoutEndpoints(hmService *HyperMeshService) *HyperMeshService {
+	copied := *hmService
+	copied.Endpoints = nil
+	return &copied
+}
+
+// endpointFromInstance rebuilds a HyperMesh endpoint with live health from its registry instance
+func endpointFromInstance(original *Endpoint, instance *service.ServiceInstance) *Endpoint {
+	return &Endpoint{
+		ID:      original.ID,
+		Address: instance.Address,
+		Port:    instance.Port,
+		NodeID:  instance.NodeID,
+		Weight:  original.Weight,
+		Health:  fromServiceInstance(instance),
+		Metrics: original.Metrics,
+	}
+}
+
+// toHealthMetrics maps a HyperMesh health status onto registry health metrics.
+//
+// The registry derives its health state from the score, so a status reported
+// without a score is translated into a score in the matching band.
+func toHealthMetrics(health *HealthStatus, throughput float64) service.HealthMetrics {
+	score := health.Score
+	if score == 0 {
+		score = healthStatusScore(health.Status)
+	}
+
+	timestamp := health.LastCheck
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	return service.HealthMetrics{
+		Score:         score,
+		ResponseTime:  health.ResponseTime,
+		ThroughputRPS: throughput,
+		ErrorRate:     health.ErrorRate,
+		Timestamp:     timestamp,
+	}
+}
+
+// fromServiceInstance maps registry instance health onto a HyperMesh health status
+func fromServiceInstance(instance *service.ServiceInstance) *HealthStatus {
+	return &HealthStatus{
+		Status:       healthStatusName(instance.HealthStatus),
+		Score:        instance.HealthScore,
+		ResponseTime: instance.ResponseTime,
+		ErrorRate:    instance.ErrorRate,
+		LastCheck:    instance.LastHealthCheck,
+	}
+}
+
+// healthStatusName returns the HyperMesh name of a registry health state
+func healthStatusName(status service.HealthStatus) string {
+	switch status {
+	case service.HealthHealthy:
+		return HealthStatusHealthy
+	case service.HealthDegraded:
+		return HealthStatusDegraded
+	case service.HealthUnhealthy:
+		return HealthStatusUnhealthy
+	case service.HealthCritical:
+		return HealthStatusCritical
+	default:
+		return HealthStatusUnknown
+	}
+}
+
+// healthStatusScore returns a representative score for a HyperMesh status name
+func healthStatusScore(status string) float64 {
+	switch strings.ToLower(status) {
+	case HealthStatusHealthy:
+		return 1.0
+	case HealthStatusDegraded:
+		return 0.5
+	case HealthStatusUnhealthy:
+		return 0.2
+	default:
+		return 0.0
+	}
+}
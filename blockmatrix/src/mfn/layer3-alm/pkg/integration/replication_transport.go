@@ -0,0 +1,86 @@
+// Package integration implements hot-standby replication over the HyperMesh transport
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+)
+
+// replicationPath is served by ReplicationHandler
+const replicationPath = "/alm/replication"
+
+// TransportReplication implements internal.ReplicationTransport over a
+// ConnectionPool, sending to a standby that serves ReplicationHandler
+type TransportReplication struct {
+	pool    *ConnectionPool
+	address string
+}
+
+// NewTransportReplication creates a replication transport to the standby at address
+func NewTransportReplication(pool *ConnectionPool, address string) *TransportReplication {
+	return &TransportReplication{
+		pool:    pool,
+		address: address,
+	}
+}
+
+// Replicate sends message to the standby
+func (tr *TransportReplication) Replicate(ctx context.Context, message *internal.ReplicationMessage) (*internal.ReplicationAck, error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode replication message: %w", err)
+	}
+
+	timeout := 5 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	reply, err := tr.pool.Execute(ctx, tr.address, &Request{
+		ID:      newConnectionID("replication"),
+		Method:  "POST",
+		Path:    replicationPath,
+		Body:    body,
+		Timeout: timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("replication to %s failed: %w", tr.address, err)
+	}
+	if reply.StatusCode != 200 {
+		return nil, fmt.Errorf("replication to %s returned status %d: %s", tr.address, reply.StatusCode, reply.StatusMessage)
+	}
+
+	var ack internal.ReplicationAck
+	if err := json.Unmarshal(reply.Body, &ack); err != nil {
+		return nil, fmt.Errorf("failed to decode replication ack from %s: %w", tr.address, err)
+	}
+	return &ack, nil
+}
+
+// ReplicationHandler applies replication messages with receiver and passes
+// every other request to next
+func ReplicationHandler(receiver *internal.StandbyReceiver, next RequestHandler) RequestHandler {
+	return func(request *Request) *Response {
+		if request.Path != replicationPath {
+			if next == nil {
+				return nil
+			}
+			return next(request)
+		}
+
+		var message internal.ReplicationMessage
+		if err := json.Unmarshal(request.Body, &message); err != nil {
+			return &Response{StatusCode: 400, StatusMessage: fmt.Sprintf("invalid replication message: %v", err)}
+		}
+
+		body, err := json.Marshal(receiver.Handle(&message))
+		if err != nil {
+			return &Response{StatusCode: 500, StatusMessage: err.Error()}
+		}
+		return &Response{StatusCode: 200, Body: body}
+	}
+}
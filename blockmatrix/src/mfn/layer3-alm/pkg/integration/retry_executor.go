@@ -0,0 +1,434 @@
+// Package integration implements retry execution for HyperMesh requests
+package integration
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+)
+
+// RetryExecutor wraps Connection.Execute with exponential backoff, per-destination
+// retry budgets and optional hedging of idempotent requests.
+//
+// Budgets cap retries to a fraction of recent requests to each destination, so
+// retries cannot multiply load on a destination that is already failing.
+type RetryExecutor struct {
+	config *RetryExecutorConfig
+
+	// Flags gating hedging per request; nil leaves it to EnableHedging
+	featureFlags atomic.Pointer[internal.FeatureFlags]
+
+	// Retry budgets keyed by remote address
+	budgets map[string]*retryBudget
+	mutex   sync.Mutex
+
+	// Counters
+	requests        atomic.Int64
+	attempts        atomic.Int64
+	retries         atomic.Int64
+	budgetExhausted atomic.Int64
+	hedges          atomic.Int64
+	hedgeWins       atomic.Int64
+
+	random      *rand.Rand
+	randomMutex sync.Mutex
+}
+
+// RetryExecutorConfig configures a RetryExecutor
+type RetryExecutorConfig struct {
+	// Policy used for requests without their own RetryPolicy
+	DefaultPolicy *RetryPolicy
+
+	// Fraction of backoff randomized to avoid synchronized retries (0-1)
+	Jitter float64
+
+	// Retry budget: retries within BudgetWindow may not exceed BudgetRatio of
+	// requests, with MinRetriesPerSecond always allowed
+	BudgetRatio         float64
+	MinRetriesPerSecond int
+	BudgetWindow        time.Duration
+
+	// Hedging sends additional copies of idempotent requests that have not
+	// completed after HedgeDelay, returning the first usable response
+	EnableHedging     bool
+	HedgeDelay        time.Duration
+	MaxHedgedRequests int
+	IdempotentMethods []string
+}
+
+// RetryStats summarizes retry executor activity
+type RetryStats struct {
+	Requests        int64
+	Attempts        int64
+	Retries         int64
+	BudgetExhausted int64
+	Hedges          int64
+	HedgeWins       int64
+}
+
+// attemptResult is the outcome of a single attempt
+type attemptResult struct {
+	response *Response
+	err      error
+	hedged   bool
+}
+
+// NewRetryExecutor creates a retry executor
+func NewRetryExecutor(config *RetryExecutorConfig) *RetryExecutor {
+	if config == nil {
+		config = DefaultRetryExecutorConfig()
+	}
+
+	return &RetryExecutor{
+		config:  config,
+		budgets: make(map[string]*retryBudget),
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Execute runs request on conn, retrying retryable failures according to the
+// request's RetryPolicy (or the default policy)
+func (re *RetryExecutor) Execute(conn Connection, request *Request) (*Response, error) {
+	policy := normalizeRetryPolicy(request.RetryPolicy, re.config.DefaultPolicy)
+
+	ctx := request.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	budget := re.budget(conn.GetRemoteAddress())
+	budget.recordRequest()
+	re.requests.Add(1)
+
+	hedge := re.config.EnableHedging && re.isIdempotent(request.Method) &&
+		re.featureFlags.Load().Enabled(ctx, internal.FeatureHedging, true)
+
+	var response *Response
+	var err error
+	for attempt := 1; ; attempt++ {
+		if hedge {
+			response, err = re.executeHedged(ctx, conn, request, policy, budget)
+		} else {
+			re.attempts.Add(1)
+			response, err = conn.Execute(request)
+		}
+
+		if !shouldRetry(policy, response, err) || attempt >= policy.MaxAttempts {
+			return response, err
+		}
+
+		if !budget.tryRetry() {
+			re.budgetExhausted.Add(1)
+			return response, err
+		}
+		re.retries.Add(1)
+
+		timer := time.NewTimer(re.backoff(policy, attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			if err == nil {
+				return response, nil
+			}
+			return nil, &TransportError{Code: ErrorCodeRequestTimeout, Message: "retry cancelled", Cause: ctx.Err()}
+		}
+	}
+}
+
+// SetFeatureFlags gates hedging on the internal.FeatureHedging flag, so it can
+// be rolled out to some tenants or a share of requests. Hedging stays off
+// unless EnableHedging is set.
+func (re *RetryExecutor) SetFeatureFlags(flags *internal.FeatureFlags) {
+	re.featureFlags.Store(flags)
+}
+
+// executeHedged runs one attempt plus up to MaxHedgedRequests hedges
+func (re *RetryExecutor) executeHedged(ctx context.Context, conn Connection, request *Request, policy *RetryPolicy, budget *retryBudget) (*Response, error) {
+	hedgeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan attemptResult, re.config.MaxHedgedRequests+1)
+	launch := func(hedged bool) {
+		attemptRequest := *request
+		attemptRequest.Context = hedgeCtx
+		re.attempts.Add(1)
+
+		go func() {
+			response, err := conn.Execute(&attemptRequest)
+			results <- attemptResult{response: response, err: err, hedged: hedged}
+		}()
+	}
+
+	launch(false)
+	outstanding := 1
+	hedgesSent := 0
+
+	var hedgeTimer <-chan time.Time
+	if re.config.MaxHedgedRequests > 0 {
+		timer := time.NewTimer(re.config.HedgeDelay)
+		defer timer.Stop()
+		hedgeTimer = timer.C
+	}
+
+	var last attemptResult
+	for outstanding > 0 {
+		select {
+		case result := <-results:
+			outstanding--
+			last = result
+			if !shouldRetry(policy, result.response, result.err) {
+				if result.hedged {
+					re.hedgeWins.Add(1)
+				}
+				return result.response, result.err
+			}
+
+		case <-hedgeTimer:
+			hedgeTimer = nil
+			if hedgesSent < re.config.MaxHedgedRequests && budget.tryRetry() {
+				hedgesSent++
+				outstanding++
+				re.hedges.Add(1)
+				launch(true)
+
+				if hedgesSent < re.config.MaxHedgedRequests {
+					timer := time.NewTimer(re.config.HedgeDelay)
+					defer timer.Stop()
+					hedgeTimer = timer.C
+				}
+			}
+
+		case <-ctx.Done():
+			return nil, &TransportError{Code: ErrorCodeRequestTimeout, Message: "hedged request cancelled", Cause: ctx.Err()}
+		}
+	}
+
+	return last.response, last.err
+}
+
+// Stats returns retry executor statistics
+func (re *RetryExecutor) Stats() RetryStats {
+	return RetryStats{
+		Requests:        re.requests.Load(),
+		Attempts:        re.attempts.Load(),
+		Retries:         re.retries.Load(),
+		BudgetExhausted: re.budgetExhausted.Load(),
+		Hedges:          re.hedges.Load(),
+		HedgeWins:       re.hedgeWins.Load(),
+	}
+}
+
+// budget returns the retry budget for a destination
+func (re *RetryExecutor) budget(destination string) *retryBudget {
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	budget, exists := re.budgets[destination]
+	if !exists {
+		budget = newRetryBudget(re.config.BudgetRatio, re.config.MinRetriesPerSecond, re.config.BudgetWindow)
+		re.budgets[destination] = budget
+	}
+	return budget
+}
+
+// backoff computes the jittered delay before the retry following attempt
+func (re *RetryExecutor) backoff(policy *RetryPolicy, attempt int) time.Duration {
+	delay := float64(policy.InitialBackoff) * math.Pow(policy.BackoffMultiplier, float64(attempt-1))
+	if delay > float64(policy.MaxBackoff) {
+		delay = float64(policy.MaxBackoff)
+	}
+
+	if jitter := re.config.Jitter; jitter > 0 {
+		re.randomMutex.Lock()
+		factor := 1 - jitter*re.random.Float64()
+		re.randomMutex.Unlock()
+		delay *= factor
+	}
+
+	return time.Duration(delay)
+}
+
+func (re *RetryExecutor) isIdempotent(method string) bool {
+	for _, idempotent := range re.config.IdempotentMethods {
+		if strings.EqualFold(method, idempotent) {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldRetry classifies an attempt outcome as retryable
+func shouldRetry(policy *RetryPolicy, response *Response, err error) bool {
+	if err != nil {
+		var transportErr *TransportError
+		if !errors.As(err, &transportErr) {
+			return false
+		}
+		if len(policy.RetryableErrors) > 0 {
+			return containsRetryable(policy.RetryableErrors, transportErr.Code.String())
+		}
+		return transportErr.IsRetryable() || transportErr.IsTemporary()
+	}
+
+	if response == nil {
+		return false
+	}
+	if len(policy.RetryableErrors) > 0 {
+		return containsRetryable(policy.RetryableErrors, strconv.Itoa(response.StatusCode))
+	}
+
+	switch response.StatusCode {
+	case 429, 502, 503, 504:
+		return true
+	default:
+		return false
+	}
+}
+
+func containsRetryable(retryable []string, name string) bool {
+	for _, candidate := range retryable {
+		if strings.EqualFold(strings.TrimSpace(candidate), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeRetryPolicy fills unset policy fields from the fallback and built-in defaults
+func normalizeRetryPolicy(policy, fallback *RetryPolicy) *RetryPolicy {
+	if policy == nil {
+		policy = fallback
+	}
+
+	normalized := RetryPolicy{}
+	if policy != nil {
+		normalized = *policy
+	}
+
+	if normalized.MaxAttempts <= 0 {
+		normalized.MaxAttempts = 1
+	}
+	if normalized.InitialBackoff <= 0 {
+		normalized.InitialBackoff = 50 * time.Millisecond
+	}
+	if normalized.MaxBackoff < normalized.InitialBackoff {
+		normalized.MaxBackoff = normalized.InitialBackoff
+	}
+	if normalized.BackoffMultiplier < 1 {
+		normalized.BackoffMultiplier = 2.0
+	}
+
+	return &normalized
+}
+
+// retryBudget tracks requests and retries over a sliding window of buckets
+type retryBudget struct {
+	ratio      float64
+	minPerSec  int
+	window     time.Duration
+	bucketSize time.Duration
+
+	requests []int64
+	retries  []int64
+	epochs   []int64
+	mutex    sync.Mutex
+}
+
+const retryBudgetBuckets = 10
+
+func newRetryBudget(ratio float64, minPerSecond int, window time.Duration) *retryBudget {
+	if window <= 0 {
+		window = 10 * time.Second
+	}
+
+	return &retryBudget{
+		ratio:      ratio,
+		minPerSec:  minPerSecond,
+		window:     window,
+		bucketSize: window / retryBudgetBuckets,
+		requests:   make([]int64, retryBudgetBuckets),
+		retries:    make([]int64, retryBudgetBuckets),
+		epochs:     make([]int64, retryBudgetBuckets),
+	}
+}
+
+// recordRequest counts an original request toward the budget
+func (rb *retryBudget) recordRequest() {
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	rb.requests[rb.bucketLocked(time.Now())]++
+}
+
+// tryRetry consumes budget for one retry, reporting whether it is allowed
+func (rb *retryBudget) tryRetry() bool {
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	now := time.Now()
+	current := rb.bucketLocked(now)
+
+	var requests, retries int64
+	epoch := now.UnixNano() / int64(rb.bucketSize)
+	for i := range rb.epochs {
+		if epoch-rb.epochs[i] < retryBudgetBuckets {
+			requests += rb.requests[i]
+			retries += rb.retries[i]
+		}
+	}
+
+	allowed := rb.ratio * float64(requests)
+	if reserve := float64(rb.minPerSec) * rb.window.Seconds(); reserve > allowed {
+		allowed = reserve
+	}
+
+	if float64(retries) >= allowed {
+		return false
+	}
+
+	rb.retries[current]++
+	return true
+}
+
+// bucketLocked returns the bucket index for now, resetting it if stale
+func (rb *retryBudget) bucketLocked(now time.Time) int {
+	epoch := now.UnixNano() / int64(rb.bucketSize)
+	index := int(epoch % retryBudgetBuckets)
+
+	if rb.epochs[index] != epoch {
+		rb.epochs[index] = epoch
+		rb.requests[index] = 0
+		rb.retries[index] = 0
+	}
+
+	return index
+}
+
+// DefaultRetryExecutorConfig returns default retry executor configuration
+func DefaultRetryExecutorConfig() *RetryExecutorConfig {
+	return &RetryExecutorConfig{
+		DefaultPolicy: &RetryPolicy{
+			MaxAttempts:       3,
+			InitialBackoff:    50 * time.Millisecond,
+			MaxBackoff:        2 * time.Second,
+			BackoffMultiplier: 2.0,
+		},
+		Jitter:              0.5,
+		BudgetRatio:         0.2,
+		MinRetriesPerSecond: 10,
+		BudgetWindow:        10 * time.Second,
+		EnableHedging:       false,
+		HedgeDelay:          50 * time.Millisecond,
+		MaxHedgedRequests:   1,
+		IdempotentMethods:   []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE", pingMethod},
+	}
+}
@@ -0,0 +1,193 @@
+package integration
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// scriptedConnection answers each execution from respond, given the 1-based
+// number of the call
+type scriptedConnection struct {
+	*MockConnection
+	calls   atomic.Int32
+	respond func(call int, request *Request) (*Response, error)
+}
+
+func newScriptedConnection(respond func(call int, request *Request) (*Response, error)) *scriptedConnection {
+	return &scriptedConnection{
+		MockConnection: &MockConnection{id: "scripted", remoteAddress: "10.0.0.1:7000"},
+		respond:        respond,
+	}
+}
+
+func (c *scriptedConnection) Execute(request *Request) (*Response, error) {
+	return c.respond(int(c.calls.Add(1)), request)
+}
+
+// retryTestConfig retries up to three times with millisecond backoff and an
+// ample budget
+func retryTestConfig() *RetryExecutorConfig {
+	config := DefaultRetryExecutorConfig()
+	config.DefaultPolicy = &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	config.Jitter = 0
+	return config
+}
+
+func TestRetryExecutorRetriesUnavailable(t *testing.T) {
+	conn := newScriptedConnection(func(call int, request *Request) (*Response, error) {
+		if call < 3 {
+			return &Response{StatusCode: 503}, nil
+		}
+		return &Response{StatusCode: 200}, nil
+	})
+	executor := NewRetryExecutor(retryTestConfig())
+
+	response, err := executor.Execute(conn, &Request{Method: "POST"})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Execute = %v, %v; want 200 on the third attempt", response, err)
+	}
+	if stats := executor.Stats(); stats.Attempts != 3 || stats.Retries != 2 {
+		t.Errorf("stats %+v, want 3 attempts and 2 retries", stats)
+	}
+}
+
+func TestRetryExecutorGivesUp(t *testing.T) {
+	executor := NewRetryExecutor(retryTestConfig())
+
+	// Attempts stop at the policy's limit
+	unavailable := newScriptedConnection(func(int, *Request) (*Response, error) {
+		return &Response{StatusCode: 503}, nil
+	})
+	if response, _ := executor.Execute(unavailable, &Request{}); response.StatusCode != 503 || unavailable.calls.Load() != 3 {
+		t.Errorf("got %d after %d attempts, want 503 after 3", response.StatusCode, unavailable.calls.Load())
+	}
+
+	// Client errors and errors outside the transport are not retried
+	rejected := newScriptedConnection(func(int, *Request) (*Response, error) {
+		return &Response{StatusCode: 400}, nil
+	})
+	executor.Execute(rejected, &Request{})
+	failed := newScriptedConnection(func(int, *Request) (*Response, error) {
+		return nil, errors.New("boom")
+	})
+	executor.Execute(failed, &Request{})
+	if rejected.calls.Load() != 1 || failed.calls.Load() != 1 {
+		t.Errorf("retried a 400 %d times and a plain error %d times", rejected.calls.Load()-1, failed.calls.Load()-1)
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	timeout := &TransportError{Code: ErrorCodeRequestTimeout, Temporary: true}
+	tooLarge := &TransportError{Code: ErrorCodeRequestTooLarge}
+	named := &RetryPolicy{RetryableErrors: []string{"request_too_large", " 500"}}
+
+	cases := []struct {
+		policy   *RetryPolicy
+		response *Response
+		err      error
+		want     bool
+	}{
+		{&RetryPolicy{}, nil, timeout, true},
+		{&RetryPolicy{}, nil, tooLarge, false},
+		{&RetryPolicy{}, &Response{StatusCode: 429}, nil, true},
+		{&RetryPolicy{}, &Response{StatusCode: 500}, nil, false},
+		{named, nil, timeout, false},
+		{named, nil, tooLarge, true},
+		{named, &Response{StatusCode: 500}, nil, true},
+		{named, &Response{StatusCode: 503}, nil, false},
+	}
+	for _, c := range cases {
+		if got := shouldRetry(c.policy, c.response, c.err); got != c.want {
+			t.Errorf("shouldRetry(%v, %+v, %v) = %v, want %v", c.policy.RetryableErrors, c.response, c.err, got, c.want)
+		}
+	}
+}
+
+func TestRetryExecutorBudget(t *testing.T) {
+	config := retryTestConfig()
+	config.BudgetRatio = 0.5
+	config.MinRetriesPerSecond = 0
+	executor := NewRetryExecutor(config)
+	conn := newScriptedConnection(func(int, *Request) (*Response, error) {
+		return &Response{StatusCode: 503}, nil
+	})
+
+	// Four requests allow two retries between them
+	for i := 0; i < 4; i++ {
+		executor.Execute(conn, &Request{})
+	}
+	if stats := executor.Stats(); stats.Retries != 2 || stats.Attempts != 6 {
+		t.Errorf("stats %+v, want 2 retries within a budget of half the requests", stats)
+	}
+}
+
+func TestRetryBudgetWindow(t *testing.T) {
+	budget := newRetryBudget(0, 1, 10*time.Millisecond)
+	if !budget.tryRetry() {
+		t.Fatal("minimum retry refused")
+	}
+	if budget.tryRetry() {
+		t.Fatal("retry allowed beyond the minimum")
+	}
+
+	time.Sleep(15 * time.Millisecond)
+	if !budget.tryRetry() {
+		t.Error("budget not restored once the window passed")
+	}
+}
+
+func TestRetryExecutorBackoff(t *testing.T) {
+	executor := NewRetryExecutor(retryTestConfig())
+	policy := normalizeRetryPolicy(&RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}, nil)
+
+	want := []time.Duration{10, 20, 40, 50}
+	for i, delay := range want {
+		if got := executor.backoff(policy, i+1); got != delay*time.Millisecond {
+			t.Errorf("backoff after attempt %d = %v, want %v", i+1, got, delay*time.Millisecond)
+		}
+	}
+}
+
+func TestRetryExecutorHedging(t *testing.T) {
+	config := retryTestConfig()
+	config.EnableHedging = true
+	config.HedgeDelay = 5 * time.Millisecond
+	executor := NewRetryExecutor(config)
+
+	// The first attempt stalls until it is cancelled; the hedge answers
+	conn := newScriptedConnection(func(call int, request *Request) (*Response, error) {
+		if call == 1 {
+			select {
+			case <-request.Context.Done():
+			case <-time.After(time.Second):
+			}
+			return &Response{StatusCode: 200, Body: []byte("first")}, nil
+		}
+		return &Response{StatusCode: 200, Body: []byte("hedge")}, nil
+	})
+
+	start := time.Now()
+	response, err := executor.Execute(conn, &Request{Method: "GET"})
+	if err != nil || string(response.Body) != "hedge" {
+		t.Fatalf("Execute = %v, %v; want the hedge's response", response, err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("hedged request took %v", elapsed)
+	}
+	if stats := executor.Stats(); stats.Hedges != 1 || stats.HedgeWins != 1 {
+		t.Errorf("stats %+v, want 1 hedge that won", stats)
+	}
+
+	// Methods that are not idempotent are never hedged
+	if _, err := executor.Execute(newScriptedConnection(func(int, *Request) (*Response, error) {
+		time.Sleep(20 * time.Millisecond)
+		return &Response{StatusCode: 200}, nil
+	}), &Request{Method: "POST"}); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if hedges := executor.Stats().Hedges; hedges != 1 {
+		t.Errorf("hedged a POST: %d hedges", hedges)
+	}
+}
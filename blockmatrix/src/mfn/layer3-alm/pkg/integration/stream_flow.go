@@ -0,0 +1,352 @@
+// Package integration implements credit-based flow control for transport streams
+package integration
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// frameWindowUpdate returns receive credit to the peer; its payload is a
+// big-endian uint32 byte count
+const frameWindowUpdate byte = 0x05
+
+const (
+	// defaultFlowControlWindow is used when StreamConfig.FlowControlWindow is unset
+	defaultFlowControlWindow = 256 * 1024
+
+	// backpressureReportStep is the smallest pressure change worth reporting
+	backpressureReportStep = 0.1
+)
+
+// BackpressureHandler receives the send-side pressure of streams to a remote
+// address: 0 when the full window is available, 1 when senders are blocked
+type BackpressureHandler func(remoteAddress string, pressure float64)
+
+// streamFlow implements credit-based flow control for one message stream.
+//
+// Each side may have at most window bytes of unconsumed messages outstanding
+// at the peer. Senders spend credit and block once it is exhausted; the
+// receiver buffers incoming messages and returns credit with a window update
+// once half the window has been consumed by Receive. A single message larger
+// than the window is allowed once the full window is available; a peer that
+// sends beyond its credit is a protocol error.
+type streamFlow struct {
+	window int64
+
+	credit  int64
+	inbound [][]byte
+
+	// Bytes consumed by the application but not yet returned to the peer
+	consumed int64
+
+	// Bytes received and not yet returned to the peer, buffered or consumed
+	outstanding int64
+
+	// Terminal error; set once the stream fails or closes
+	err error
+
+	// Closed and replaced whenever credit, inbound or err change
+	signal chan struct{}
+
+	// Send-side statistics
+	blockedSends int64
+	blockedTime  time.Duration
+
+	report       func(pressure float64)
+	lastPressure float64
+
+	mutex sync.Mutex
+}
+
+func newStreamFlow(window int32, report func(pressure float64)) *streamFlow {
+	if window <= 0 {
+		window = defaultFlowControlWindow
+	}
+	return &streamFlow{
+		window: int64(window),
+		credit: int64(window),
+		signal: make(chan struct{}),
+		report: report,
+	}
+}
+
+// available returns the bytes that can be sent without blocking
+func (sf *streamFlow) available() int {
+	sf.mutex.Lock()
+	defer sf.mutex.Unlock()
+
+	if sf.credit < 0 {
+		return 0
+	}
+	return int(sf.credit)
+}
+
+// acquire spends credit for a message of size bytes, blocking until enough
+// credit is granted, the stream fails or deadline passes. A zero deadline
+// waits indefinitely.
+func (sf *streamFlow) acquire(size int, deadline time.Time) error {
+	need := int64(size)
+	if need > sf.window {
+		need = sf.window
+	}
+
+	var timer *time.Timer
+	var blockedAt time.Time
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		sf.mutex.Lock()
+		if sf.err != nil {
+			err := sf.err
+			sf.mutex.Unlock()
+			return err
+		}
+
+		if sf.credit >= need {
+			sf.credit -= int64(size)
+			if !blockedAt.IsZero() {
+				sf.blockedTime += time.Since(blockedAt)
+			}
+			pressure := sf.pressureLocked()
+			sf.mutex.Unlock()
+
+			sf.reportPressure(pressure)
+			return nil
+		}
+
+		if blockedAt.IsZero() {
+			blockedAt = time.Now()
+			sf.blockedSends++
+		}
+		signal := sf.signal
+		sf.mutex.Unlock()
+
+		sf.reportPressure(1.0)
+
+		var expired <-chan time.Time
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return sf.timeout(blockedAt, size)
+			}
+			if timer == nil {
+				timer = time.NewTimer(remaining)
+			}
+			expired = timer.C
+		}
+
+		select {
+		case <-signal:
+		case <-expired:
+			return sf.timeout(blockedAt, size)
+		}
+	}
+}
+
+// grant adds credit returned by the peer
+func (sf *streamFlow) grant(bytes int64) {
+	sf.mutex.Lock()
+	sf.credit += bytes
+	if sf.credit > sf.window {
+		sf.credit = sf.window
+	}
+	pressure := sf.pressureLocked()
+	sf.wakeLocked()
+	sf.mutex.Unlock()
+
+	sf.reportPressure(pressure)
+}
+
+// push buffers a message received from the peer, rejecting it when the peer
+// had too little credit to send it
+func (sf *streamFlow) push(data []byte) error {
+	sf.mutex.Lock()
+	defer sf.mutex.Unlock()
+
+	size := int64(len(data))
+	need := size
+	if need > sf.window {
+		need = sf.window
+	}
+	if sf.window-sf.outstanding < need {
+		return &TransportError{
+			Code:    ErrorCodeProtocolError,
+			Message: fmt.Sprintf("peer sent %d bytes with %d of %d window bytes outstanding", size, sf.outstanding, sf.window),
+		}
+	}
+
+	sf.outstanding += size
+	sf.inbound = append(sf.inbound, data)
+	sf.wakeLocked()
+	return nil
+}
+
+// pop returns the next buffered message, waiting until one arrives, the
+// stream fails or deadline passes. update is the credit to return to the
+// peer, or 0 when no window update is due yet.
+func (sf *streamFlow) pop(deadline time.Time) (data []byte, update int64, err error) {
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		sf.mutex.Lock()
+		if len(sf.inbound) > 0 {
+			data = sf.inbound[0]
+			sf.inbound[0] = nil
+			sf.inbound = sf.inbound[1:]
+
+			sf.consumed += int64(len(data))
+			if sf.consumed >= sf.window/2 {
+				update = sf.consumed
+				sf.consumed = 0
+				sf.outstanding -= update
+			}
+			sf.mutex.Unlock()
+			return data, update, nil
+		}
+		if sf.err != nil {
+			err = sf.err
+			sf.mutex.Unlock()
+			return nil, 0, err
+		}
+		signal := sf.signal
+		sf.mutex.Unlock()
+
+		var expired <-chan time.Time
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return nil, 0, &TransportError{Code: ErrorCodeRequestTimeout, Message: "stream receive timed out", Temporary: true}
+			}
+			if timer == nil {
+				timer = time.NewTimer(remaining)
+			}
+			expired = timer.C
+		}
+
+		select {
+		case <-signal:
+		case <-expired:
+			return nil, 0, &TransportError{Code: ErrorCodeRequestTimeout, Message: "stream receive timed out", Temporary: true}
+		}
+	}
+}
+
+// fail records the terminal stream error and wakes all waiters. Buffered
+// messages remain readable.
+func (sf *streamFlow) fail(err error) {
+	sf.mutex.Lock()
+	defer sf.mutex.Unlock()
+
+	if sf.err == nil {
+		sf.err = err
+		sf.wakeLocked()
+	}
+}
+
+// stats returns the send window state for StreamMetrics
+func (sf *streamFlow) stats() (window, available int64, blockedSends int64, blockedTime time.Duration, pressure float64) {
+	sf.mutex.Lock()
+	defer sf.mutex.Unlock()
+
+	available = sf.credit
+	if available < 0 {
+		available = 0
+	}
+	return sf.window, available, sf.blockedSends, sf.blockedTime, sf.pressureLocked()
+}
+
+// pressureLocked returns the fraction of the send window in use
+func (sf *streamFlow) pressureLocked() float64 {
+	used := float64(sf.window-sf.credit) / float64(sf.window)
+	return math.Max(0, math.Min(1, used))
+}
+
+func (sf *streamFlow) wakeLocked() {
+	close(sf.signal)
+	sf.signal = make(chan struct{})
+}
+
+// reportPressure forwards pressure changes of at least backpressureReportStep,
+// and every transition to or from a blocked sender
+func (sf *streamFlow) reportPressure(pressure float64) {
+	if sf.report == nil {
+		return
+	}
+
+	sf.mutex.Lock()
+	last := sf.lastPressure
+	changed := math.Abs(pressure-last) >= backpressureReportStep ||
+		(pressure == 1) != (last == 1) ||
+		(pressure == 0 && last != 0)
+	if changed {
+		sf.lastPressure = pressure
+	}
+	sf.mutex.Unlock()
+
+	if changed {
+		sf.report(pressure)
+	}
+}
+
+func (sf *streamFlow) timeout(blockedAt time.Time, size int) error {
+	sf.mutex.Lock()
+	sf.blockedTime += time.Since(blockedAt)
+	sf.mutex.Unlock()
+
+	return &TransportError{
+		Code:      ErrorCodeResourceExhausted,
+		Message:   fmt.Sprintf("flow control window exhausted sending %d bytes", size),
+		Retryable: true,
+		Temporary: true,
+	}
+}
+
+// encodeWindowUpdate encodes a window update payload
+func encodeWindowUpdate(bytes int64) []byte {
+	if bytes > math.MaxUint32 {
+		bytes = math.MaxUint32
+	}
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, uint32(bytes))
+	return payload
+}
+
+// decodeWindowUpdate decodes a window update payload
+func decodeWindowUpdate(payload []byte) (int64, error) {
+	if len(payload) != 4 {
+		return 0, &TransportError{Code: ErrorCodeProtocolError, Message: fmt.Sprintf("window update of %d bytes", len(payload))}
+	}
+	return int64(binary.BigEndian.Uint32(payload)), nil
+}
+
+// backpressureReporter returns the pressure callback for streams to address,
+// or nil when the transport has no BackpressureHandler
+func backpressureReporter(config *TransportConfig, address string) func(pressure float64) {
+	if config == nil || config.BackpressureHandler == nil {
+		return nil
+	}
+	handler := config.BackpressureHandler
+	return func(pressure float64) {
+		handler(address, pressure)
+	}
+}
+
+// sendDeadline returns the deadline for a send bounded by timeout, or the zero time
+func sendDeadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
@@ -0,0 +1,190 @@
+package integration
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStreamFlowBlocksWhenCreditExhausted(t *testing.T) {
+	flow := newStreamFlow(100, nil)
+
+	if err := flow.acquire(100, time.Time{}); err != nil {
+		t.Fatalf("acquire within the window: %v", err)
+	}
+	if got := flow.available(); got != 0 {
+		t.Fatalf("available() = %d after spending the window, want 0", got)
+	}
+
+	err := flow.acquire(1, time.Now().Add(20*time.Millisecond))
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) || transportErr.Code != ErrorCodeResourceExhausted {
+		t.Fatalf("acquire without credit = %v, want a resource exhausted error", err)
+	}
+
+	_, _, blockedSends, blockedTime, pressure := flow.stats()
+	if blockedSends != 1 || blockedTime <= 0 {
+		t.Errorf("stats recorded %d blocked sends for %v, want 1", blockedSends, blockedTime)
+	}
+	if pressure != 1 {
+		t.Errorf("pressure = %v with the window spent, want 1", pressure)
+	}
+}
+
+func TestStreamFlowGrantUnblocksSender(t *testing.T) {
+	flow := newStreamFlow(100, nil)
+	if err := flow.acquire(100, time.Time{}); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- flow.acquire(40, time.Now().Add(5*time.Second))
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("acquire returned %v before credit was granted", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	flow.grant(50)
+	if err := <-done; err != nil {
+		t.Fatalf("acquire after grant: %v", err)
+	}
+	if got := flow.available(); got != 10 {
+		t.Errorf("available() = %d, want 10 left of the 50 granted", got)
+	}
+
+	// Grants never raise credit above the window
+	flow.grant(1000)
+	if got := flow.available(); got != 100 {
+		t.Errorf("available() = %d after an oversized grant, want 100", got)
+	}
+}
+
+func TestStreamFlowFailWakesSender(t *testing.T) {
+	flow := newStreamFlow(10, nil)
+	if err := flow.acquire(10, time.Time{}); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- flow.acquire(10, time.Time{})
+	}()
+
+	closed := &TransportError{Code: ErrorCodeConnectionClosed, Message: "closed"}
+	flow.fail(closed)
+	if err := <-done; err != closed {
+		t.Errorf("blocked acquire returned %v, want the stream error", err)
+	}
+}
+
+func TestStreamFlowOversizedMessage(t *testing.T) {
+	flow := newStreamFlow(100, nil)
+
+	// A message larger than the window waits for the full window, then
+	// drives credit negative until the peer returns it
+	if err := flow.acquire(150, time.Time{}); err != nil {
+		t.Fatalf("acquire(150) with the full window: %v", err)
+	}
+	if got := flow.available(); got != 0 {
+		t.Errorf("available() = %d, want 0", got)
+	}
+	flow.grant(50)
+	if err := flow.acquire(1, time.Now().Add(20*time.Millisecond)); err == nil {
+		t.Error("acquire succeeded before the oversized message's credit was returned")
+	}
+}
+
+func TestStreamFlowReturnsCreditAtHalfWindow(t *testing.T) {
+	flow := newStreamFlow(100, nil)
+	for i := 0; i < 3; i++ {
+		if err := flow.push(make([]byte, 20)); err != nil {
+			t.Fatalf("push: %v", err)
+		}
+	}
+
+	var updates []int64
+	for i := 0; i < 3; i++ {
+		data, update, err := flow.pop(time.Time{})
+		if err != nil || len(data) != 20 {
+			t.Fatalf("pop = %d bytes, %v", len(data), err)
+		}
+		updates = append(updates, update)
+	}
+
+	// The update is due once 50 of the 100 bytes have been consumed
+	if updates[0] != 0 || updates[1] != 0 || updates[2] != 60 {
+		t.Errorf("window updates = %v, want [0 0 60]", updates)
+	}
+}
+
+func TestStreamFlowRejectsWindowOverflow(t *testing.T) {
+	flow := newStreamFlow(100, nil)
+
+	if err := flow.push(make([]byte, 80)); err != nil {
+		t.Fatalf("push within the window: %v", err)
+	}
+	err := flow.push(make([]byte, 30))
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) || transportErr.Code != ErrorCodeProtocolError {
+		t.Fatalf("push past the window = %v, want a protocol error", err)
+	}
+
+	// Consuming the buffered message returns its credit, after which the
+	// peer may send again
+	if _, update, _ := flow.pop(time.Time{}); update != 80 {
+		t.Fatalf("pop returned update %d, want 80", update)
+	}
+	if err := flow.push(make([]byte, 30)); err != nil {
+		t.Errorf("push after credit was returned: %v", err)
+	}
+}
+
+func TestStreamFlowAcceptsOversizedMessageOnEmptyWindow(t *testing.T) {
+	flow := newStreamFlow(100, nil)
+
+	if err := flow.push(make([]byte, 150)); err != nil {
+		t.Fatalf("push of an oversized message on an empty window: %v", err)
+	}
+	if err := flow.push(make([]byte, 1)); err == nil {
+		t.Error("push accepted while an oversized message was outstanding")
+	}
+}
+
+func TestStreamFlowReportsPressure(t *testing.T) {
+	var reports []float64
+	flow := newStreamFlow(100, func(pressure float64) {
+		reports = append(reports, pressure)
+	})
+
+	for i := 0; i < 4; i++ {
+		if err := flow.acquire(25, time.Time{}); err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+	}
+	flow.grant(100)
+
+	want := []float64{0.25, 0.5, 0.75, 1, 0}
+	if len(reports) != len(want) {
+		t.Fatalf("reports = %v, want %v", reports, want)
+	}
+	for i := range want {
+		if reports[i] != want[i] {
+			t.Errorf("reports = %v, want %v", reports, want)
+			break
+		}
+	}
+}
+
+func TestWindowUpdateEncoding(t *testing.T) {
+	got, err := decodeWindowUpdate(encodeWindowUpdate(65536))
+	if err != nil || got != 65536 {
+		t.Errorf("decodeWindowUpdate(encodeWindowUpdate(65536)) = %d, %v", got, err)
+	}
+	if _, err := decodeWindowUpdate([]byte{1, 2}); err == nil {
+		t.Error("decodeWindowUpdate accepted a short payload")
+	}
+}
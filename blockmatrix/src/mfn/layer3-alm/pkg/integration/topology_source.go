@@ -0,0 +1,177 @@
+// Package integration implements topology sampling from transport connections
+package integration
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+)
+
+// PoolTopologySourceConfig configures a PoolTopologySource
+type PoolTopologySourceConfig struct {
+	// Pings sent to each peer per sample; latency is their mean round trip
+	// and jitter the mean difference between consecutive round trips
+	Probes int
+
+	// Bound on a single ping
+	ProbeTimeout time.Duration
+}
+
+// requestTotals are a peer's connection request counters at the last sample
+type requestTotals struct {
+	total  int64
+	failed int64
+}
+
+// PoolTopologySource implements internal.TopologySource by probing peers
+// over a ConnectionPool. Latency and jitter come from pings; packet loss is
+// the larger of the failed ping ratio and the failed request ratio of the
+// peer's pooled connections since the previous sample.
+type PoolTopologySource struct {
+	pool   *ConnectionPool
+	peers  map[int64]string
+	config *PoolTopologySourceConfig
+
+	previous map[int64]requestTotals
+	mutex    sync.Mutex
+}
+
+// NewPoolTopologySource creates a topology source for peers, keyed by node
+// ID with their transport addresses
+func NewPoolTopologySource(pool *ConnectionPool, peers map[int64]string, config *PoolTopologySourceConfig) *PoolTopologySource {
+	if config == nil {
+		config = DefaultPoolTopologySourceConfig()
+	}
+	if config.Probes <= 0 {
+		config.Probes = 1
+	}
+
+	return &PoolTopologySource{
+		pool:     pool,
+		peers:    peers,
+		config:   config,
+		previous: make(map[int64]requestTotals),
+	}
+}
+
+// Sample probes every peer concurrently
+func (pts *PoolTopologySource) Sample(ctx context.Context) ([]internal.PeerSample, error) {
+	samples := make([]internal.PeerSample, 0, len(pts.peers))
+	var mutex sync.Mutex
+
+	var wg sync.WaitGroup
+	for peerID, address := range pts.peers {
+		wg.Add(1)
+		go func(peerID int64, address string) {
+			defer wg.Done()
+
+			sample := pts.probe(ctx, peerID, address)
+
+			mutex.Lock()
+			samples = append(samples, sample)
+			mutex.Unlock()
+		}(peerID, address)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// probe measures the link to one peer
+func (pts *PoolTopologySource) probe(ctx context.Context, peerID int64, address string) internal.PeerSample {
+	sample := internal.PeerSample{PeerID: peerID, Timestamp: time.Now()}
+
+	conn, release, err := pts.pool.Acquire(ctx, address)
+	if err != nil {
+		return sample
+	}
+	defer release()
+
+	var rtts []time.Duration
+	for i := 0; i < pts.config.Probes && ctx.Err() == nil; i++ {
+		if rtt, err := pts.ping(ctx, conn); err == nil {
+			rtts = append(rtts, rtt)
+		}
+	}
+	if len(rtts) == 0 {
+		return sample
+	}
+
+	var total, variation time.Duration
+	for i, rtt := range rtts {
+		total += rtt
+		if i > 0 {
+			variation += (rtt - rtts[i-1]).Abs()
+		}
+	}
+
+	sample.Reachable = true
+	sample.Latency = total / time.Duration(len(rtts))
+	if len(rtts) > 1 {
+		sample.Jitter = variation / time.Duration(len(rtts)-1)
+	}
+	sample.PacketLoss = 1 - float64(len(rtts))/float64(pts.config.Probes)
+	if loss := pts.requestLoss(peerID, address); loss > sample.PacketLoss {
+		sample.PacketLoss = loss
+	}
+
+	return sample
+}
+
+// ping sends one ping and returns its round trip time
+func (pts *PoolTopologySource) ping(ctx context.Context, conn Connection) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, pts.config.ProbeTimeout)
+	defer cancel()
+
+	startTime := time.Now()
+	result := make(chan error, 1)
+	go func() {
+		result <- conn.Ping()
+	}()
+
+	select {
+	case err := <-result:
+		return time.Since(startTime), err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// requestLoss returns the failed request ratio of the peer's pooled
+// connections since the previous sample
+func (pts *PoolTopologySource) requestLoss(peerID int64, address string) float64 {
+	var current requestTotals
+	for _, metrics := range pts.pool.NodeConnectionMetrics(address) {
+		current.total += metrics.TotalRequests
+		current.failed += metrics.FailedRequests
+	}
+
+	pts.mutex.Lock()
+	previous := pts.previous[peerID]
+	pts.previous[peerID] = current
+	pts.mutex.Unlock()
+
+	// Counters restart when connections are replaced
+	if current.total < previous.total || current.failed < previous.failed {
+		previous = requestTotals{}
+	}
+
+	requests := current.total - previous.total
+	if requests <= 0 {
+		return 0
+	}
+	return float64(current.failed-previous.failed) / float64(requests)
+}
+
+// DefaultPoolTopologySourceConfig returns default pool topology source configuration
+func DefaultPoolTopologySourceConfig() *PoolTopologySourceConfig {
+	return &PoolTopologySourceConfig{
+		Probes:       3,
+		ProbeTimeout: 2 * time.Second,
+	}
+}
@@ -0,0 +1,296 @@
+// Package integration implements endpoint address parsing and dual-stack dialing
+package integration
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultDualStackFallbackDelay is the RFC 8305 connection attempt delay used
+// when DualStackFallbackDelay is unset
+const defaultDualStackFallbackDelay = 250 * time.Millisecond
+
+// EndpointAddress is a parsed transport address. Host is a hostname or an IP
+// literal without brackets; Zone is the IPv6 scope of link-local addresses.
+type EndpointAddress struct {
+	Host string
+	Zone string
+	Port int
+}
+
+// ParseEndpointAddress parses a transport address in any of the forms used for
+// endpoints and bootstrap peers:
+//
+//	node.example:7777    192.0.2.1:7777    [2001:db8::1]:7777
+//	node.example         192.0.2.1         [2001:db8::1]    2001:db8::1
+//	[fe80::1%eth0]:7777  fe80::1%eth0
+//
+// defaultPort is used when the address carries no port. IPv6 literals are
+// returned in canonical form.
+func ParseEndpointAddress(address string, defaultPort int) (EndpointAddress, error) {
+	address = strings.TrimSpace(address)
+	host, portText := address, ""
+	bracketed := false
+
+	switch {
+	case strings.HasPrefix(address, "["):
+		end := strings.IndexByte(address, ']')
+		if end < 0 {
+			return EndpointAddress{}, fmt.Errorf("address %q: missing ']'", address)
+		}
+		host = address[1:end]
+		bracketed = true
+
+		if rest := address[end+1:]; rest != "" {
+			if rest[0] != ':' {
+				return EndpointAddress{}, fmt.Errorf("address %q: unexpected %q after ']'", address, rest)
+			}
+			portText = rest[1:]
+		}
+
+	case strings.Count(address, ":") == 1:
+		host, portText, _ = strings.Cut(address, ":")
+	}
+
+	// More than one colon without brackets is a bare IPv6 literal
+	ea := EndpointAddress{Host: host, Port: defaultPort}
+
+	if portText != "" {
+		port, err := strconv.Atoi(portText)
+		if err != nil || port < 0 || port > 65535 {
+			return EndpointAddress{}, fmt.Errorf("address %q: invalid port %q", address, portText)
+		}
+		ea.Port = port
+	}
+
+	if zoneStart := strings.IndexByte(ea.Host, '%'); zoneStart >= 0 {
+		ea.Host, ea.Zone = ea.Host[:zoneStart], ea.Host[zoneStart+1:]
+		if ea.Zone == "" {
+			return EndpointAddress{}, fmt.Errorf("address %q: empty scope id", address)
+		}
+	}
+
+	if bracketed || ea.Zone != "" || strings.Contains(ea.Host, ":") {
+		ip, err := netip.ParseAddr(ea.Host)
+		if err != nil || !ip.Is6() {
+			return EndpointAddress{}, fmt.Errorf("address %q: invalid IPv6 literal %q", address, ea.Host)
+		}
+		ea.Host = ip.String()
+	}
+
+	return ea, nil
+}
+
+// IP returns the address as an IP, including its zone, when Host is a literal
+func (ea EndpointAddress) IP() (netip.Addr, bool) {
+	ip, err := netip.ParseAddr(ea.Host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	if ea.Zone != "" {
+		ip = ip.WithZone(ea.Zone)
+	}
+	return ip, true
+}
+
+// IsIPv6 reports whether Host is an IPv6 literal
+func (ea EndpointAddress) IsIPv6() bool {
+	ip, ok := ea.IP()
+	return ok && ip.Is6() && !ip.Is4In6()
+}
+
+// HostPort returns "host:port" without the zone, as used for TLS server
+// names and HTTP authorities
+func (ea EndpointAddress) HostPort() string {
+	return net.JoinHostPort(ea.Host, strconv.Itoa(ea.Port))
+}
+
+// String returns the dialable "host:port" form, bracketing IPv6 literals and
+// keeping the zone
+func (ea EndpointAddress) String() string {
+	host := ea.Host
+	if ea.Zone != "" {
+		host += "%" + ea.Zone
+	}
+	return net.JoinHostPort(host, strconv.Itoa(ea.Port))
+}
+
+// transportAddress joins host and port, handling bracketed, bare and zoned
+// IPv6 literals. A port in address is used only when port is unset.
+func transportAddress(address string, port int) string {
+	ea, err := ParseEndpointAddress(address, 0)
+	if err != nil {
+		return net.JoinHostPort(address, strconv.Itoa(port))
+	}
+	if port > 0 {
+		ea.Port = port
+	}
+	return ea.String()
+}
+
+// sameTransportAddress reports whether two "host:port" addresses refer to the
+// same endpoint, ignoring differences in IPv6 notation
+func sameTransportAddress(a, b string) bool {
+	if a == b {
+		return true
+	}
+	eaA, errA := ParseEndpointAddress(a, 0)
+	eaB, errB := ParseEndpointAddress(b, 0)
+	return errA == nil && errB == nil && eaA == eaB
+}
+
+// dualStackDial connects to one resolved "ip:port" address
+type dualStackDial[T any] func(ctx context.Context, address string) (T, error)
+
+// dialDualStack connects to address following RFC 8305 (Happy Eyeballs v2).
+// Hostnames are resolved to all their addresses, ordered IPv6 first and
+// interleaved by family; attempts start fallbackDelay apart, or as soon as
+// the previous attempt fails, and the first to connect wins. Connections
+// that complete after the winner are passed to discard. With ipv6Only set,
+// IPv4 addresses are never dialed.
+func dialDualStack[T any](ctx context.Context, address string, ipv6Only bool, fallbackDelay time.Duration, dial dualStackDial[T], discard func(T)) (T, error) {
+	var zero T
+
+	candidates, err := resolveDualStack(ctx, address, ipv6Only)
+	if err != nil {
+		return zero, err
+	}
+	return dialCandidates(ctx, candidates, fallbackDelay, dial, discard)
+}
+
+// dialCandidates races dial over the resolved candidates in order, starting
+// each attempt fallbackDelay after the previous one or as soon as it fails
+func dialCandidates[T any](ctx context.Context, candidates []string, fallbackDelay time.Duration, dial dualStackDial[T], discard func(T)) (T, error) {
+	var zero T
+
+	if len(candidates) == 1 {
+		return dial(ctx, candidates[0])
+	}
+
+	if fallbackDelay <= 0 {
+		fallbackDelay = defaultDualStackFallbackDelay
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type attempt struct {
+		value T
+		err   error
+	}
+	results := make(chan attempt, len(candidates))
+	next, pending := 0, 0
+
+	start := func() {
+		candidate := candidates[next]
+		next++
+		pending++
+		go func() {
+			value, err := dial(ctx, candidate)
+			results <- attempt{value: value, err: err}
+		}()
+	}
+
+	start()
+	timer := time.NewTimer(fallbackDelay)
+	defer timer.Stop()
+
+	var firstErr error
+	for pending > 0 {
+		select {
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				// Close connections from attempts that were already in flight
+				go func(remaining int) {
+					for i := 0; i < remaining; i++ {
+						if late := <-results; late.err == nil && discard != nil {
+							discard(late.value)
+						}
+					}
+				}(pending)
+				return result.value, nil
+			}
+
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			if next < len(candidates) {
+				start()
+				timer.Reset(fallbackDelay)
+			}
+
+		case <-timer.C:
+			if next < len(candidates) {
+				start()
+				timer.Reset(fallbackDelay)
+			}
+		}
+	}
+
+	return zero, firstErr
+}
+
+// resolveDualStack returns the "ip:port" addresses to try for address in
+// RFC 8305 order. IP literals are returned as is.
+func resolveDualStack(ctx context.Context, address string, ipv6Only bool) ([]string, error) {
+	ea, err := ParseEndpointAddress(address, 0)
+	if err != nil {
+		return nil, &TransportError{Code: ErrorCodeConnectionFailed, Message: "invalid address", Cause: err}
+	}
+
+	if ip, ok := ea.IP(); ok {
+		if ipv6Only && !ea.IsIPv6() {
+			return nil, &TransportError{
+				Code:    ErrorCodeConnectionFailed,
+				Message: fmt.Sprintf("cannot dial IPv4 address %s with IPv6Only set", ip),
+			}
+		}
+		return []string{ea.String()}, nil
+	}
+
+	resolved, err := net.DefaultResolver.LookupIPAddr(ctx, ea.Host)
+	if err != nil {
+		return nil, &TransportError{
+			Code:      ErrorCodeConnectionFailed,
+			Message:   fmt.Sprintf("failed to resolve %s", ea.Host),
+			Cause:     err,
+			Retryable: true,
+			Temporary: true,
+		}
+	}
+
+	var ipv6, ipv4 []string
+	for _, ipAddr := range resolved {
+		candidate := EndpointAddress{Host: ipAddr.IP.String(), Zone: ipAddr.Zone, Port: ea.Port}.String()
+		if ipAddr.IP.To4() == nil {
+			ipv6 = append(ipv6, candidate)
+		} else if !ipv6Only {
+			ipv4 = append(ipv4, candidate)
+		}
+	}
+
+	candidates := make([]string, 0, len(ipv6)+len(ipv4))
+	for i := 0; i < len(ipv6) || i < len(ipv4); i++ {
+		if i < len(ipv6) {
+			candidates = append(candidates, ipv6[i])
+		}
+		if i < len(ipv4) {
+			candidates = append(candidates, ipv4[i])
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, &TransportError{
+			Code:    ErrorCodeConnectionFailed,
+			Message: fmt.Sprintf("%s has no usable addresses", ea.Host),
+		}
+	}
+
+	return candidates, nil
+}
@@ -0,0 +1,175 @@
+package integration
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseEndpointAddress(t *testing.T) {
+	cases := []struct {
+		address string
+		want    EndpointAddress
+	}{
+		{"node.example:7777", EndpointAddress{Host: "node.example", Port: 7777}},
+		{"node.example", EndpointAddress{Host: "node.example", Port: 9000}},
+		{"192.0.2.1:7777", EndpointAddress{Host: "192.0.2.1", Port: 7777}},
+		{"192.0.2.1", EndpointAddress{Host: "192.0.2.1", Port: 9000}},
+		{"[2001:db8::1]:7777", EndpointAddress{Host: "2001:db8::1", Port: 7777}},
+		{"[2001:db8::1]", EndpointAddress{Host: "2001:db8::1", Port: 9000}},
+		{"2001:db8::1", EndpointAddress{Host: "2001:db8::1", Port: 9000}},
+		{"[2001:0db8:0:0::1]:7777", EndpointAddress{Host: "2001:db8::1", Port: 7777}},
+		{"[fe80::1%eth0]:7777", EndpointAddress{Host: "fe80::1", Zone: "eth0", Port: 7777}},
+		{"fe80::1%eth0", EndpointAddress{Host: "fe80::1", Zone: "eth0", Port: 9000}},
+		{" [::1]:7777 ", EndpointAddress{Host: "::1", Port: 7777}},
+	}
+
+	for _, tc := range cases {
+		got, err := ParseEndpointAddress(tc.address, 9000)
+		if err != nil {
+			t.Errorf("ParseEndpointAddress(%q): %v", tc.address, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParseEndpointAddress(%q) = %+v, want %+v", tc.address, got, tc.want)
+		}
+	}
+}
+
+func TestParseEndpointAddressRejects(t *testing.T) {
+	for _, address := range []string{
+		"[2001:db8::1",        // unclosed bracket
+		"[2001:db8::1]7777",   // no colon before the port
+		"node.example:http",   // named port
+		"node.example:70000",  // port out of range
+		"[fe80::1%]:7777",     // empty zone
+		"[192.0.2.1]:7777",    // bracketed IPv4
+		"[node.example]:7777", // bracketed hostname
+		"2001:db8::zz",        // bad IPv6 literal
+	} {
+		if got, err := ParseEndpointAddress(address, 9000); err == nil {
+			t.Errorf("ParseEndpointAddress(%q) = %+v, want an error", address, got)
+		}
+	}
+}
+
+func TestEndpointAddressString(t *testing.T) {
+	ea, err := ParseEndpointAddress("[fe80::1%eth0]:7777", 0)
+	if err != nil {
+		t.Fatalf("ParseEndpointAddress: %v", err)
+	}
+	if got := ea.String(); got != "[fe80::1%eth0]:7777" {
+		t.Errorf("String() = %q, want the zone kept", got)
+	}
+	if got := ea.HostPort(); got != "[fe80::1]:7777" {
+		t.Errorf("HostPort() = %q, want the zone dropped", got)
+	}
+	if !ea.IsIPv6() {
+		t.Error("IsIPv6() = false for a link-local literal")
+	}
+
+	if got := transportAddress("2001:db8::1", 7777); got != "[2001:db8::1]:7777" {
+		t.Errorf("transportAddress(bare IPv6) = %q", got)
+	}
+	if !sameTransportAddress("[2001:db8:0::1]:7777", "[2001:db8::1]:7777") {
+		t.Error("sameTransportAddress treated two notations of one address as different")
+	}
+}
+
+// loopbackListener accepts connections on the loopback address until
+// the test ends, skipping the test when the family is unavailable
+func loopbackListener(t *testing.T, address string) net.Listener {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Skipf("loopback %s unavailable: %v", address, err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return listener
+}
+
+// deadAddress returns a loopback address in address's family with nothing
+// listening on it
+func deadAddress(t *testing.T, address string) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Skipf("loopback %s unavailable: %v", address, err)
+	}
+	dead := listener.Addr().String()
+	listener.Close()
+	return dead
+}
+
+func dialTCP(ctx context.Context, address string) (net.Conn, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", address)
+}
+
+func TestDialCandidatesFallsBackAcrossFamilies(t *testing.T) {
+	ipv4 := loopbackListener(t, "127.0.0.1:0")
+	ipv6 := loopbackListener(t, "[::1]:0")
+
+	cases := []struct {
+		dead, live string
+	}{
+		{deadAddress(t, "[::1]:0"), ipv4.Addr().String()},
+		{deadAddress(t, "127.0.0.1:0"), ipv6.Addr().String()},
+	}
+
+	for _, tc := range cases {
+		// A refused first attempt starts the next one at once rather than
+		// after the fallback delay
+		start := time.Now()
+		conn, err := dialCandidates(context.Background(), []string{tc.dead, tc.live}, time.Minute, dialTCP, nil)
+		if err != nil {
+			t.Errorf("dialCandidates(%s, %s): %v", tc.dead, tc.live, err)
+			continue
+		}
+		if got := conn.RemoteAddr().String(); got != tc.live {
+			t.Errorf("dialCandidates(%s, %s) connected to %s", tc.dead, tc.live, got)
+		}
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Errorf("dialCandidates waited %v for the fallback after a refused attempt", elapsed)
+		}
+		conn.Close()
+	}
+}
+
+func TestDialCandidatesAllFail(t *testing.T) {
+	candidates := []string{deadAddress(t, "[::1]:0"), deadAddress(t, "127.0.0.1:0")}
+
+	if conn, err := dialCandidates(context.Background(), candidates, time.Millisecond, dialTCP, nil); err == nil {
+		conn.Close()
+		t.Fatal("dialCandidates succeeded with nothing listening")
+	}
+}
+
+func TestDialDualStackIPv6Only(t *testing.T) {
+	ipv4 := loopbackListener(t, "127.0.0.1:0")
+
+	_, err := dialDualStack(context.Background(), ipv4.Addr().String(), true, 0, dialTCP, nil)
+	if err == nil {
+		t.Fatal("dialDualStack dialed an IPv4 address with IPv6Only set")
+	}
+
+	ipv6 := loopbackListener(t, "[::1]:0")
+	conn, err := dialDualStack(context.Background(), ipv6.Addr().String(), true, 0, dialTCP, nil)
+	if err != nil {
+		t.Fatalf("dialDualStack(%s): %v", ipv6.Addr(), err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,328 @@
+// Package integration implements negotiated payload compression for HyperMesh transports
+package integration
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// Compression codecs peers can negotiate, in default preference order
+const (
+	CompressionZstd = "zstd"
+	CompressionLZ4  = "lz4"
+	CompressionGzip = "gzip"
+)
+
+const (
+	// frameCompressed is set in the type of frames whose payload is
+	// compressed; such payloads start with the ID of the codec used
+	frameCompressed byte = 0x80
+
+	// defaultCompressionThreshold is used when CompressionThreshold is unset.
+	// Smaller payloads rarely shrink enough to pay for compression.
+	defaultCompressionThreshold = 1024
+)
+
+// errIncompressible reports a payload that did not shrink
+var errIncompressible = errors.New("payload is incompressible")
+
+// payloadCodec compresses payloads with one algorithm. Every peer can decode
+// all codecs; negotiation only decides which codec a sender may use.
+type payloadCodec struct {
+	id         byte
+	name       string
+	compress   func(src []byte, level int) ([]byte, error)
+	decompress func(src []byte, limit int) ([]byte, error)
+}
+
+var payloadCodecs = []*payloadCodec{
+	{id: 1, name: CompressionZstd, compress: zstdCompress, decompress: zstdDecompress},
+	{id: 2, name: CompressionLZ4, compress: lz4Compress, decompress: lz4Decompress},
+	{id: 3, name: CompressionGzip, compress: gzipCompress, decompress: gzipDecompress},
+}
+
+// payloadCompressor applies the compression settings of a connection.
+//
+// Each side advertises the codecs it accepts with its first request, response
+// or stream header; senders then compress with their most preferred codec the
+// peer accepts. Payloads below the threshold, or that do not shrink, are sent
+// uncompressed, so every message carries its own codec.
+type payloadCompressor struct {
+	// Local preference order; empty when compression is disabled
+	codecs    []*payloadCodec
+	level     int
+	threshold int
+	stats     *transportStats
+}
+
+func newPayloadCompressor(config *TransportConfig, stats *transportStats) *payloadCompressor {
+	pc := &payloadCompressor{
+		threshold: defaultCompressionThreshold,
+		stats:     stats,
+	}
+	if config == nil || !config.EnableCompression {
+		return pc
+	}
+
+	pc.level = config.CompressionLevel
+	if config.CompressionThreshold > 0 {
+		pc.threshold = config.CompressionThreshold
+	}
+
+	names := config.CompressionCodecs
+	if len(names) == 0 {
+		names = []string{CompressionZstd, CompressionLZ4, CompressionGzip}
+	}
+	for _, name := range names {
+		if codec := codecByName(name); codec != nil {
+			pc.codecs = append(pc.codecs, codec)
+		}
+	}
+
+	return pc
+}
+
+// accepted returns the codec names advertised to the peer
+func (pc *payloadCompressor) accepted() []string {
+	if len(pc.codecs) == 0 {
+		return nil
+	}
+	names := make([]string, len(pc.codecs))
+	for i, codec := range pc.codecs {
+		names[i] = codec.name
+	}
+	return names
+}
+
+// negotiate returns the most preferred local codec the peer accepts, or nil
+func (pc *payloadCompressor) negotiate(peer []string) *payloadCodec {
+	for _, codec := range pc.codecs {
+		for _, name := range peer {
+			if strings.EqualFold(name, codec.name) {
+				return codec
+			}
+		}
+	}
+	return nil
+}
+
+// encodeFrame compresses payload with codec when it is large enough and
+// shrinks, returning the frame type and payload to write
+func (pc *payloadCompressor) encodeFrame(frameType byte, codec *payloadCodec, payload []byte) (byte, []byte) {
+	if codec == nil || len(payload) < pc.threshold {
+		pc.stats.recordCompression(len(payload), len(payload))
+		return frameType, payload
+	}
+
+	compressed, err := codec.compress(payload, pc.level)
+	if err != nil || len(compressed)+1 >= len(payload) {
+		pc.stats.recordCompression(len(payload), len(payload))
+		return frameType, payload
+	}
+
+	framed := make([]byte, 1+len(compressed))
+	framed[0] = codec.id
+	copy(framed[1:], compressed)
+
+	pc.stats.recordCompression(len(payload), len(framed))
+	return frameType | frameCompressed, framed
+}
+
+// decodeFrame reverses encodeFrame
+func (pc *payloadCompressor) decodeFrame(frameType byte, payload []byte) (byte, []byte, error) {
+	if frameType&frameCompressed == 0 {
+		pc.stats.recordCompression(len(payload), len(payload))
+		return frameType, payload, nil
+	}
+
+	if len(payload) == 0 {
+		return 0, nil, &TransportError{Code: ErrorCodeCompressionError, Message: "compressed frame without codec"}
+	}
+	codec := codecByID(payload[0])
+	if codec == nil {
+		return 0, nil, &TransportError{Code: ErrorCodeCompressionError, Message: fmt.Sprintf("unknown compression codec 0x%02x", payload[0])}
+	}
+
+	decompressed, err := codec.decompress(payload[1:], defaultMaxFrameSize)
+	if err != nil {
+		return 0, nil, &TransportError{Code: ErrorCodeCompressionError, Message: fmt.Sprintf("failed to decompress %s frame", codec.name), Cause: err}
+	}
+
+	pc.stats.recordCompression(len(decompressed), len(payload))
+	return frameType &^ frameCompressed, decompressed, nil
+}
+
+func codecByName(name string) *payloadCodec {
+	for _, codec := range payloadCodecs {
+		if strings.EqualFold(codec.name, name) {
+			return codec
+		}
+	}
+	return nil
+}
+
+func codecByID(id byte) *payloadCodec {
+	for _, codec := range payloadCodecs {
+		if codec.id == id {
+			return codec
+		}
+	}
+	return nil
+}
+
+// zstd encoders are safe for concurrent EncodeAll calls; one is kept per level
+var (
+	zstdEncoders    = make(map[zstd.EncoderLevel]*zstd.Encoder)
+	zstdEncoderMu   sync.Mutex
+	zstdDecoder     *zstd.Decoder
+	zstdDecoderErr  error
+	zstdDecoderOnce sync.Once
+)
+
+func zstdCompress(src []byte, level int) ([]byte, error) {
+	encoderLevel := zstd.SpeedDefault
+	if level > 0 {
+		encoderLevel = zstd.EncoderLevelFromZstd(level)
+	}
+
+	zstdEncoderMu.Lock()
+	encoder, exists := zstdEncoders[encoderLevel]
+	if !exists {
+		var err error
+		encoder, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(encoderLevel), zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			zstdEncoderMu.Unlock()
+			return nil, err
+		}
+		zstdEncoders[encoderLevel] = encoder
+	}
+	zstdEncoderMu.Unlock()
+
+	return encoder.EncodeAll(src, nil), nil
+}
+
+func zstdDecompress(src []byte, limit int) ([]byte, error) {
+	zstdDecoderOnce.Do(func() {
+		zstdDecoder, zstdDecoderErr = zstd.NewReader(nil,
+			zstd.WithDecoderConcurrency(0),
+			zstd.WithDecoderMaxMemory(uint64(defaultMaxFrameSize)),
+		)
+	})
+	if zstdDecoderErr != nil {
+		return nil, zstdDecoderErr
+	}
+
+	decompressed, err := zstdDecoder.DecodeAll(src, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(decompressed) > limit {
+		return nil, fmt.Errorf("decompressed size exceeds %d bytes", limit)
+	}
+	return decompressed, nil
+}
+
+// lz4Levels maps CompressionLevel 2-9 to lz4's high compression levels;
+// level 1 and below use fast block compression
+var lz4Levels = map[int]lz4.CompressionLevel{
+	2: lz4.Level2,
+	3: lz4.Level3,
+	4: lz4.Level4,
+	5: lz4.Level5,
+	6: lz4.Level6,
+	7: lz4.Level7,
+	8: lz4.Level8,
+	9: lz4.Level9,
+}
+
+// lz4 payloads are a uvarint uncompressed length followed by one LZ4 block
+func lz4Compress(src []byte, level int) ([]byte, error) {
+	compressed := make([]byte, binary.MaxVarintLen64+lz4.CompressBlockBound(len(src)))
+	offset := binary.PutUvarint(compressed, uint64(len(src)))
+
+	var n int
+	var err error
+	if level <= 1 {
+		n, err = lz4.CompressBlock(src, compressed[offset:], nil)
+	} else {
+		if level > 9 {
+			level = 9
+		}
+		n, err = lz4.CompressBlockHC(src, compressed[offset:], lz4Levels[level], nil, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, errIncompressible
+	}
+
+	return compressed[:offset+n], nil
+}
+
+func lz4Decompress(src []byte, limit int) ([]byte, error) {
+	size, offset := binary.Uvarint(src)
+	if offset <= 0 {
+		return nil, fmt.Errorf("malformed lz4 length prefix")
+	}
+	if size > uint64(limit) {
+		return nil, fmt.Errorf("decompressed size %d exceeds %d bytes", size, limit)
+	}
+
+	decompressed := make([]byte, size)
+	n, err := lz4.UncompressBlock(src[offset:], decompressed)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(n) != size {
+		return nil, fmt.Errorf("lz4 block decompressed to %d bytes, expected %d", n, size)
+	}
+	return decompressed, nil
+}
+
+func gzipCompress(src []byte, level int) ([]byte, error) {
+	if level <= 0 {
+		level = gzip.DefaultCompression
+	} else if level > gzip.BestCompression {
+		level = gzip.BestCompression
+	}
+
+	var buffer bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buffer, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(src); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func gzipDecompress(src []byte, limit int) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(io.LimitReader(reader, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(decompressed) > limit {
+		return nil, fmt.Errorf("decompressed size exceeds %d bytes", limit)
+	}
+	return decompressed, nil
+}
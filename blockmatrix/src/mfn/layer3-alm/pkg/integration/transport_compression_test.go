@@ -0,0 +1,148 @@
+package integration
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+func TestCompressionNegotiation(t *testing.T) {
+	cases := []struct {
+		name   string
+		config *TransportConfig
+		peer   []string
+		want   string
+	}{
+		{"compression disabled", &TransportConfig{}, []string{CompressionZstd}, ""},
+		{"nil config", nil, []string{CompressionZstd}, ""},
+		{"default preference", &TransportConfig{EnableCompression: true}, []string{CompressionGzip, CompressionLZ4, CompressionZstd}, CompressionZstd},
+		{"local order wins", &TransportConfig{EnableCompression: true, CompressionCodecs: []string{CompressionGzip, CompressionLZ4}}, []string{CompressionLZ4, CompressionGzip}, CompressionGzip},
+		{"peer subset", &TransportConfig{EnableCompression: true}, []string{CompressionLZ4}, CompressionLZ4},
+		{"case insensitive", &TransportConfig{EnableCompression: true}, []string{"GZIP"}, CompressionGzip},
+		{"no overlap", &TransportConfig{EnableCompression: true, CompressionCodecs: []string{CompressionZstd}}, []string{CompressionGzip}, ""},
+		{"peer accepts none", &TransportConfig{EnableCompression: true}, nil, ""},
+		{"unknown local codecs ignored", &TransportConfig{EnableCompression: true, CompressionCodecs: []string{"brotli", CompressionLZ4}}, []string{"brotli", CompressionLZ4}, CompressionLZ4},
+	}
+
+	for _, tc := range cases {
+		compressor := newPayloadCompressor(tc.config, &transportStats{})
+		codec := compressor.negotiate(tc.peer)
+
+		got := ""
+		if codec != nil {
+			got = codec.name
+		}
+		if got != tc.want {
+			t.Errorf("%s: negotiated %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCompressionFrameRoundTrip(t *testing.T) {
+	compressible := bytes.Repeat([]byte("hypermesh routing payload "), 200)
+	random := make([]byte, 4096)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatalf("rand.Read: %v", err)
+	}
+
+	cases := []struct {
+		name           string
+		codec          string
+		level          int
+		payload        []byte
+		wantCompressed bool
+	}{
+		{"zstd default", CompressionZstd, 0, compressible, true},
+		{"zstd best", CompressionZstd, 19, compressible, true},
+		{"lz4 fast", CompressionLZ4, 1, compressible, true},
+		{"lz4 high", CompressionLZ4, 9, compressible, true},
+		{"lz4 above range", CompressionLZ4, 12, compressible, true},
+		{"gzip default", CompressionGzip, 0, compressible, true},
+		{"gzip above range", CompressionGzip, 20, compressible, true},
+		{"below threshold", CompressionZstd, 0, compressible[:100], false},
+		{"incompressible", CompressionLZ4, 0, random, false},
+		{"no codec", "", 0, compressible, false},
+	}
+
+	for _, tc := range cases {
+		compressor := newPayloadCompressor(&TransportConfig{EnableCompression: true, CompressionLevel: tc.level}, &transportStats{})
+		codec := codecByName(tc.codec)
+
+		frameType, encoded := compressor.encodeFrame(frameRequest, codec, tc.payload)
+		if compressed := frameType&frameCompressed != 0; compressed != tc.wantCompressed {
+			t.Errorf("%s: compressed = %v, want %v", tc.name, compressed, tc.wantCompressed)
+		}
+		if tc.wantCompressed && len(encoded) >= len(tc.payload) {
+			t.Errorf("%s: compressed frame of %d bytes is not smaller than %d", tc.name, len(encoded), len(tc.payload))
+		}
+
+		decodedType, decoded, err := compressor.decodeFrame(frameType, encoded)
+		if err != nil {
+			t.Fatalf("%s: decodeFrame: %v", tc.name, err)
+		}
+		if decodedType != frameRequest || !bytes.Equal(decoded, tc.payload) {
+			t.Errorf("%s: round trip changed the frame", tc.name)
+		}
+	}
+}
+
+func TestCompressionDecodeErrors(t *testing.T) {
+	compressor := newPayloadCompressor(&TransportConfig{EnableCompression: true}, &transportStats{})
+
+	cases := []struct {
+		name    string
+		payload []byte
+	}{
+		{"missing codec", nil},
+		{"unknown codec", []byte{0x7f, 1, 2, 3}},
+		{"corrupt zstd", []byte{1, 0xde, 0xad, 0xbe, 0xef}},
+		{"corrupt lz4", []byte{2, 0x10, 0xff, 0xff}},
+		{"lz4 size over limit", append([]byte{2}, 0xff, 0xff, 0xff, 0xff, 0x7f)},
+		{"corrupt gzip", []byte{3, 0x1f, 0x8b, 0x00}},
+	}
+
+	for _, tc := range cases {
+		_, _, err := compressor.decodeFrame(frameRequest|frameCompressed, tc.payload)
+		if err == nil {
+			t.Errorf("%s: expected error", tc.name)
+			continue
+		}
+		if transportErr, ok := err.(*TransportError); !ok || transportErr.Code != ErrorCodeCompressionError {
+			t.Errorf("%s: got %v, want a compression error", tc.name, err)
+		}
+	}
+}
+
+func TestLZ4LevelMapping(t *testing.T) {
+	cases := []struct {
+		level int
+		want  lz4.CompressionLevel
+	}{
+		{2, lz4.Level2},
+		{3, lz4.Level3},
+		{4, lz4.Level4},
+		{5, lz4.Level5},
+		{6, lz4.Level6},
+		{7, lz4.Level7},
+		{8, lz4.Level8},
+		{9, lz4.Level9},
+	}
+
+	payload := bytes.Repeat([]byte("hypermesh routing payload "), 200)
+	for _, tc := range cases {
+		if got := lz4Levels[tc.level]; got != tc.want {
+			t.Errorf("level %d maps to %d, want %d", tc.level, got, tc.want)
+		}
+
+		compressed, err := lz4Compress(payload, tc.level)
+		if err != nil {
+			t.Fatalf("level %d: lz4Compress: %v", tc.level, err)
+		}
+		decompressed, err := lz4Decompress(compressed, defaultMaxFrameSize)
+		if err != nil || !bytes.Equal(decompressed, payload) {
+			t.Errorf("level %d: round trip failed: %v", tc.level, err)
+		}
+	}
+}
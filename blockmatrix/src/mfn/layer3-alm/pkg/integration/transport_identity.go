@@ -0,0 +1,169 @@
+// Package integration implements peer identity validation for HyperMesh transports
+package integration
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// spiffeScheme is the URI scheme of SPIFFE IDs
+const spiffeScheme = "spiffe"
+
+// PeerIdentity describes the authenticated certificate presented by the remote peer
+type PeerIdentity struct {
+	// SPIFFE identity, when the certificate carries a spiffe:// URI SAN
+	SPIFFEID    string
+	TrustDomain string
+	Path        string
+
+	// Certificate details
+	CommonName   string
+	DNSNames     []string
+	SerialNumber string
+	NotAfter     time.Time
+}
+
+// peerIdentityFromState extracts the peer identity from a completed TLS handshake
+func peerIdentityFromState(state tls.ConnectionState) *PeerIdentity {
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return peerIdentityFromCertificate(state.PeerCertificates[0])
+}
+
+// peerIdentityFromCertificate builds a PeerIdentity from a leaf certificate
+func peerIdentityFromCertificate(certificate *x509.Certificate) *PeerIdentity {
+	identity := &PeerIdentity{
+		CommonName:   certificate.Subject.CommonName,
+		DNSNames:     certificate.DNSNames,
+		SerialNumber: certificate.SerialNumber.String(),
+		NotAfter:     certificate.NotAfter,
+	}
+
+	for _, uri := range certificate.URIs {
+		if uri.Scheme != spiffeScheme {
+			continue
+		}
+		if trustDomain, path, err := ParseSPIFFEID(uri.String()); err == nil {
+			identity.SPIFFEID = uri.String()
+			identity.TrustDomain = trustDomain
+			identity.Path = path
+			break
+		}
+	}
+
+	return identity
+}
+
+// ParseSPIFFEID splits a SPIFFE ID such as "spiffe://mesh.local/ns/prod/sa/api"
+// into its trust domain and path
+func ParseSPIFFEID(id string) (trustDomain, path string, err error) {
+	uri, err := url.Parse(id)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid SPIFFE ID %q: %w", id, err)
+	}
+
+	if uri.Scheme != spiffeScheme {
+		return "", "", fmt.Errorf("invalid SPIFFE ID %q: scheme must be %s", id, spiffeScheme)
+	}
+	if uri.Host == "" {
+		return "", "", fmt.Errorf("invalid SPIFFE ID %q: missing trust domain", id)
+	}
+	if uri.User != nil || uri.Port() != "" || uri.RawQuery != "" || uri.Fragment != "" {
+		return "", "", fmt.Errorf("invalid SPIFFE ID %q: userinfo, port, query and fragment are not allowed", id)
+	}
+	if uri.Host != strings.ToLower(uri.Host) {
+		return "", "", fmt.Errorf("invalid SPIFFE ID %q: trust domain must be lowercase", id)
+	}
+	if strings.Contains(uri.Path, "//") || strings.HasSuffix(uri.Path, "/") {
+		return "", "", fmt.Errorf("invalid SPIFFE ID %q: path segments must not be empty", id)
+	}
+
+	return uri.Host, uri.Path, nil
+}
+
+// validateSPIFFEIdentity checks the peer certificate against the configured trust
+// domain and allowed SPIFFE IDs. The leaf must carry exactly one SPIFFE ID.
+func validateSPIFFEIdentity(certificate *x509.Certificate, config *TLSConfig) error {
+	var spiffeIDs []string
+	for _, uri := range certificate.URIs {
+		if uri.Scheme == spiffeScheme {
+			spiffeIDs = append(spiffeIDs, uri.String())
+		}
+	}
+
+	if len(spiffeIDs) != 1 {
+		return tlsError(fmt.Sprintf("peer certificate must carry exactly one SPIFFE ID, found %d", len(spiffeIDs)), nil)
+	}
+
+	id := spiffeIDs[0]
+	trustDomain, _, err := ParseSPIFFEID(id)
+	if err != nil {
+		return tlsError("peer presented an invalid SPIFFE ID", err)
+	}
+
+	if config.TrustDomain != "" && trustDomain != config.TrustDomain {
+		return tlsError(fmt.Sprintf("peer trust domain %q does not match %q", trustDomain, config.TrustDomain), nil)
+	}
+
+	if len(config.AllowedSPIFFEIDs) > 0 && !matchesAllowedSPIFFEID(id, config.AllowedSPIFFEIDs) {
+		return tlsError(fmt.Sprintf("peer SPIFFE ID %q is not allowed", id), nil)
+	}
+
+	return nil
+}
+
+// matchesAllowedSPIFFEID matches an ID exactly or against patterns ending in "/*"
+func matchesAllowedSPIFFEID(id string, allowed []string) bool {
+	for _, pattern := range allowed {
+		if prefix, isWildcard := strings.CutSuffix(pattern, "/*"); isWildcard {
+			if strings.HasPrefix(id, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if id == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyPeerCertificates verifies the peer chain against roots and, when
+// SPIFFE validation is configured, the peer's SPIFFE ID. Hostname checks are
+// skipped for SPIFFE peers since their identity is carried in the URI SAN.
+func verifyPeerCertificates(certificates []*x509.Certificate, roots *x509.CertPool, serverName string, usage x509.ExtKeyUsage, config *TLSConfig) error {
+	if len(certificates) == 0 {
+		return tlsError("peer presented no certificate", nil)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+
+	options := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	}
+
+	requireSPIFFE := requiresSPIFFEIdentity(config)
+	if !requireSPIFFE {
+		options.DNSName = serverName
+	}
+
+	if _, err := certificates[0].Verify(options); err != nil {
+		return tlsError("peer certificate verification failed", err)
+	}
+
+	if requireSPIFFE {
+		return validateSPIFFEIdentity(certificates[0], config)
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+// Package integration implements statistics shared by HyperMesh transports
+package integration
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// transportStats collects transport-wide counters for GetStatistics
+type transportStats struct {
+	totalConnections  atomic.Int64
+	activeConnections atomic.Int64
+	failedConnections atomic.Int64
+
+	totalRequests      atomic.Int64
+	successfulRequests atomic.Int64
+	failedRequests     atomic.Int64
+	totalLatencyNs     atomic.Int64
+
+	bytesSent     atomic.Int64
+	bytesReceived atomic.Int64
+	messages      atomic.Int64
+
+	// Message payload sizes before compression and on the wire
+	payloadBytes atomic.Int64
+	wireBytes    atomic.Int64
+
+	latencies *latencyWindow
+	startedAt time.Time
+}
+
+func newTransportStats() *transportStats {
+	return &transportStats{
+		latencies: newLatencyWindow(1024),
+		startedAt: time.Now(),
+	}
+}
+
+// recordRequest records the outcome of a single request
+func (ts *transportStats) recordRequest(latency time.Duration, success bool) {
+	ts.totalRequests.Add(1)
+	if success {
+		ts.successfulRequests.Add(1)
+	} else {
+		ts.failedRequests.Add(1)
+	}
+	ts.totalLatencyNs.Add(int64(latency))
+	ts.latencies.Add(latency)
+}
+
+// recordTransfer records bytes moved over the transport
+func (ts *transportStats) recordTransfer(sent, received int) {
+	ts.bytesSent.Add(int64(sent))
+	ts.bytesReceived.Add(int64(received))
+	ts.messages.Add(1)
+}
+
+// recordCompression records a message payload of original bytes that took
+// encoded bytes on the wire
+func (ts *transportStats) recordCompression(original, encoded int) {
+	ts.payloadBytes.Add(int64(original))
+	ts.wireBytes.Add(int64(encoded))
+}
+
+// snapshot converts the counters into TransportStatistics
+func (ts *transportStats) snapshot() TransportStatistics {
+	elapsed := time.Since(ts.startedAt).Seconds()
+	total := ts.totalRequests.Load()
+	failed := ts.failedRequests.Load()
+
+	stats := TransportStatistics{
+		TotalConnections:   ts.totalConnections.Load(),
+		ActiveConnections:  ts.activeConnections.Load(),
+		FailedConnections:  ts.failedConnections.Load(),
+		TotalRequests:      total,
+		SuccessfulRequests: ts.successfulRequests.Load(),
+		FailedRequests:     failed,
+		BytesSent:          ts.bytesSent.Load(),
+		BytesReceived:      ts.bytesReceived.Load(),
+		CompressionRatio:   1.0,
+	}
+
+	if elapsed > 0 {
+		stats.ConnectionsPerSecond = float64(stats.TotalConnections) / elapsed
+		stats.RequestsPerSecond = float64(total) / elapsed
+		stats.MessagesPerSecond = float64(ts.messages.Load()) / elapsed
+	}
+
+	if wire := ts.wireBytes.Load(); wire > 0 {
+		stats.CompressionRatio = float64(ts.payloadBytes.Load()) / float64(wire)
+	}
+
+	if total > 0 {
+		stats.AverageLatency = time.Duration(ts.totalLatencyNs.Load() / total)
+		stats.ErrorRate = float64(failed) / float64(total)
+	}
+
+	stats.P50Latency, stats.P90Latency, stats.P99Latency = ts.latencies.Percentiles()
+
+	return stats
+}
+
+// connectionStats collects per-connection counters
+type connectionStats struct {
+	totalRequests      atomic.Int64
+	successfulRequests atomic.Int64
+	failedRequests     atomic.Int64
+	totalLatencyNs     atomic.Int64
+	bytesSent          atomic.Int64
+	bytesReceived      atomic.Int64
+	lastActivity       atomic.Int64
+}
+
+func (cs *connectionStats) recordRequest(latency time.Duration, success bool) {
+	cs.totalRequests.Add(1)
+	if success {
+		cs.successfulRequests.Add(1)
+	} else {
+		cs.failedRequests.Add(1)
+	}
+	cs.totalLatencyNs.Add(int64(latency))
+	cs.touch()
+}
+
+func (cs *connectionStats) recordTransfer(sent, received int) {
+	cs.bytesSent.Add(int64(sent))
+	cs.bytesReceived.Add(int64(received))
+	cs.touch()
+}
+
+func (cs *connectionStats) touch() {
+	cs.lastActivity.Store(time.Now().UnixNano())
+}
+
+func (cs *connectionStats) averageLatency() time.Duration {
+	total := cs.totalRequests.Load()
+	if total == 0 {
+		return 0
+	}
+	return time.Duration(cs.totalLatencyNs.Load() / total)
+}
+
+func (cs *connectionStats) lastActive() time.Time {
+	return time.Unix(0, cs.lastActivity.Load())
+}
+
+// latencyWindow keeps the most recent latencies for percentile estimates
+type latencyWindow struct {
+	samples []time.Duration
+	cursor  int
+	size    int
+	mutex   sync.Mutex
+}
+
+func newLatencyWindow(size int) *latencyWindow {
+	return &latencyWindow{
+		samples: make([]time.Duration, 0, size),
+		size:    size,
+	}
+}
+
+// Add records a latency sample, overwriting the oldest once full
+func (lw *latencyWindow) Add(latency time.Duration) {
+	lw.mutex.Lock()
+	defer lw.mutex.Unlock()
+
+	if len(lw.samples) < lw.size {
+		lw.samples = append(lw.samples, latency)
+		return
+	}
+	lw.samples[lw.cursor] = latency
+	lw.cursor = (lw.cursor + 1) % lw.size
+}
+
+// Percentiles returns the p50, p90 and p99 latencies in the window
+func (lw *latencyWindow) Percentiles() (p50, p90, p99 time.Duration) {
+	lw.mutex.Lock()
+	sorted := make([]time.Duration, len(lw.samples))
+	copy(sorted, lw.samples)
+	lw.mutex.Unlock()
+
+	if len(sorted) == 0 {
+		return 0, 0, 0
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	at := func(p float64) time.Duration {
+		index := int(float64(len(sorted)) * p)
+		if index >= len(sorted) {
+			index = len(sorted) - 1
+		}
+		return sorted[index]
+	}
+
+	return at(0.50), at(0.90), at(0.99)
+}
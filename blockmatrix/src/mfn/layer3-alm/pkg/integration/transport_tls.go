@@ -0,0 +1,473 @@
+// Package integration implements TLS configuration for HyperMesh transports
+package integration
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultReloadInterval is how often certificate files are checked for rotation
+const defaultReloadInterval = 30 * time.Second
+
+// tlsManager builds crypto/tls configurations for a transport. Certificate
+// material loaded from files is shared between connections and reloaded when
+// the files change, so rotated certificates apply to new handshakes without
+// restarting the transport.
+type tlsManager struct {
+	reloaders map[string]*certificateReloader
+	mutex     sync.Mutex
+}
+
+func newTLSManager() *tlsManager {
+	return &tlsManager{
+		reloaders: make(map[string]*certificateReloader),
+	}
+}
+
+// clientConfig converts a TLSConfig into a crypto/tls client configuration.
+// A nil config verifies the server against the system roots.
+func (tm *tlsManager) clientConfig(config *TLSConfig, serverName string, nextProtos []string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName: serverName,
+		NextProtos: nextProtos,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if config == nil {
+		return tlsConfig, nil
+	}
+
+	if err := applyCommonTLSSettings(tlsConfig, config); err != nil {
+		return nil, err
+	}
+
+	material, err := tm.material(config)
+	if err != nil {
+		return nil, err
+	}
+	if material != nil && material.hasCertificate() {
+		tlsConfig.GetClientCertificate = material.GetClientCertificate
+	}
+
+	// Verification is done in VerifyConnection so that rotated CA bundles and
+	// SPIFFE identity checks apply to every handshake. Configuring a CA bundle
+	// or SPIFFE identity implies verification; only a config naming none of
+	// them with VerifyPeer unset skips it.
+	tlsConfig.InsecureSkipVerify = true
+	if peerVerificationRequired(config) {
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			roots, err := material.roots()
+			if err != nil {
+				return err
+			}
+			return verifyPeerCertificates(state.PeerCertificates, roots, serverName, x509.ExtKeyUsageServerAuth, config)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// serverConfig converts a TLSConfig into a crypto/tls server configuration.
+// When no certificate is configured an ephemeral self-signed certificate is
+// used. VerifyPeer or a SPIFFE identity requirement makes clients present a
+// certificate (mutual TLS); a CA bundle alone verifies certificates that
+// clients choose to present.
+func (tm *tlsManager) serverConfig(config *TLSConfig, nextProtos []string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		NextProtos: nextProtos,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if config == nil {
+		config = &TLSConfig{}
+	}
+
+	if err := applyCommonTLSSettings(tlsConfig, config); err != nil {
+		return nil, err
+	}
+
+	material, err := tm.material(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if material != nil && material.hasCertificate() {
+		tlsConfig.GetCertificate = material.GetCertificate
+	} else {
+		certificate, err := generateSelfSignedCertificate()
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	requireClientCert := config.VerifyPeer || requiresSPIFFEIdentity(config)
+	switch {
+	case requireClientCert:
+		tlsConfig.ClientAuth = tls.RequireAnyClientCert
+	case config.CACertPath != "":
+		tlsConfig.ClientAuth = tls.RequestClientCert
+	}
+
+	if tlsConfig.ClientAuth != tls.NoClientCert {
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 && !requireClientCert {
+				return nil
+			}
+			roots, err := material.roots()
+			if err != nil {
+				return err
+			}
+			return verifyPeerCertificates(state.PeerCertificates, roots, "", x509.ExtKeyUsageClientAuth, config)
+		}
+	}
+
+	if material != nil {
+		tlsConfig.GetConfigForClient = material.sessionScopedConfig(tlsConfig)
+	}
+
+	return tlsConfig, nil
+}
+
+// peerVerificationRequired reports whether config asks for the peer
+// certificate to be verified, explicitly or by naming trust material
+func peerVerificationRequired(config *TLSConfig) bool {
+	return config.VerifyPeer || config.CACertPath != "" || requiresSPIFFEIdentity(config)
+}
+
+// requiresSPIFFEIdentity reports whether config enforces a SPIFFE ID on the peer
+func requiresSPIFFEIdentity(config *TLSConfig) bool {
+	return config.TrustDomain != "" || len(config.AllowedSPIFFEIDs) > 0
+}
+
+// material returns the shared reloader for the files named in config, or nil
+// when the config names no files
+func (tm *tlsManager) material(config *TLSConfig) (*certificateReloader, error) {
+	if config.CertificatePath == "" && config.CACertPath == "" {
+		return nil, nil
+	}
+
+	key := config.CertificatePath + "|" + config.KeyPath + "|" + config.CACertPath
+
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	if reloader, exists := tm.reloaders[key]; exists {
+		return reloader, nil
+	}
+
+	reloader, err := newCertificateReloader(config)
+	if err != nil {
+		return nil, err
+	}
+	tm.reloaders[key] = reloader
+
+	return reloader, nil
+}
+
+// Close stops watching certificate files
+func (tm *tlsManager) Close() {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	for key, reloader := range tm.reloaders {
+		reloader.Close()
+		delete(tm.reloaders, key)
+	}
+}
+
+// certificateReloader holds certificate material loaded from files and
+// reloads it when the files change. A failed reload keeps the previous
+// material and is retried on the next check.
+type certificateReloader struct {
+	certPath string
+	keyPath  string
+	caPath   string
+
+	certificate atomic.Pointer[tls.Certificate]
+	pool        atomic.Pointer[x509.CertPool]
+	fingerprint string
+
+	reloads   atomic.Int64
+	lastError atomic.Value
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+func newCertificateReloader(config *TLSConfig) (*certificateReloader, error) {
+	reloader := &certificateReloader{
+		certPath: config.CertificatePath,
+		keyPath:  config.KeyPath,
+		caPath:   config.CACertPath,
+		stop:     make(chan struct{}),
+	}
+
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+	reloader.fingerprint = reloader.filesFingerprint()
+
+	interval := config.ReloadInterval
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+	go reloader.watch(interval)
+
+	return reloader, nil
+}
+
+// load reads the certificate, key and CA files
+func (cr *certificateReloader) load() error {
+	if cr.certPath != "" {
+		certificate, err := tls.LoadX509KeyPair(cr.certPath, cr.keyPath)
+		if err != nil {
+			return tlsError("failed to load certificate", err)
+		}
+		cr.certificate.Store(&certificate)
+	}
+
+	if cr.caPath != "" {
+		pool, err := loadCertPool(cr.caPath)
+		if err != nil {
+			return err
+		}
+		cr.pool.Store(pool)
+	}
+
+	return nil
+}
+
+// watch polls the files and reloads them when their size or modification time changes
+func (cr *certificateReloader) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			fingerprint := cr.filesFingerprint()
+			if fingerprint == cr.fingerprint {
+				continue
+			}
+			if err := cr.load(); err != nil {
+				cr.lastError.Store(err)
+				continue
+			}
+			cr.fingerprint = fingerprint
+			cr.reloads.Add(1)
+		case <-cr.stop:
+			return
+		}
+	}
+}
+
+// filesFingerprint summarizes the size and modification time of the watched files
+func (cr *certificateReloader) filesFingerprint() string {
+	var builder strings.Builder
+	for _, path := range []string{cr.certPath, cr.keyPath, cr.caPath} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&builder, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+		} else {
+			fmt.Fprintf(&builder, "%s:missing;", path)
+		}
+	}
+	return builder.String()
+}
+
+func (cr *certificateReloader) hasCertificate() bool {
+	return cr.certificate.Load() != nil
+}
+
+// GetCertificate serves the current certificate to TLS clients
+func (cr *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return cr.certificate.Load(), nil
+}
+
+// GetClientCertificate presents the current certificate to TLS servers
+func (cr *certificateReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if certificate := cr.certificate.Load(); certificate != nil {
+		return certificate, nil
+	}
+	return &tls.Certificate{}, nil
+}
+
+// sessionScopedConfig returns a GetConfigForClient callback that serves a copy
+// of base with fresh session ticket keys after every reload, so sessions
+// resumed from tickets never outlive rotated certificates or CA bundles
+func (cr *certificateReloader) sessionScopedConfig(base *tls.Config) func(*tls.ClientHelloInfo) (*tls.Config, error) {
+	var mutex sync.Mutex
+	var current *tls.Config
+	generation := int64(-1)
+
+	return func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if reloads := cr.reloads.Load(); current == nil || reloads != generation {
+			var ticketKey [32]byte
+			if _, err := rand.Read(ticketKey[:]); err != nil {
+				return nil, tlsError("failed to generate session ticket key", err)
+			}
+
+			current = base.Clone()
+			current.GetConfigForClient = nil
+			current.SetSessionTicketKeys([][32]byte{ticketKey})
+			generation = reloads
+		}
+
+		return current, nil
+	}
+}
+
+// roots returns the configured CA pool, or the system pool when none is
+// configured. It is safe to call on a nil reloader.
+func (cr *certificateReloader) roots() (*x509.CertPool, error) {
+	if cr != nil {
+		if pool := cr.pool.Load(); pool != nil {
+			return pool, nil
+		}
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, tlsError("failed to load system certificate pool", err)
+	}
+	return pool, nil
+}
+
+// Close stops watching the files
+func (cr *certificateReloader) Close() {
+	cr.stopOnce.Do(func() {
+		close(cr.stop)
+	})
+}
+
+// applyCommonTLSSettings applies version and cipher suite settings
+func applyCommonTLSSettings(tlsConfig *tls.Config, config *TLSConfig) error {
+	if config.MinTLSVersion != "" {
+		version, err := parseTLSVersion(config.MinTLSVersion)
+		if err != nil {
+			return err
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if len(config.CipherSuites) > 0 {
+		suites, err := parseCipherSuites(config.CipherSuites)
+		if err != nil {
+			return err
+		}
+		// Only applies to TLS 1.2; TLS 1.3 suites are not configurable
+		tlsConfig.CipherSuites = suites
+	}
+
+	return nil
+}
+
+// parseTLSVersion maps a version string such as "1.3" or "TLS1.2" to a tls constant
+func parseTLSVersion(version string) (uint16, error) {
+	normalized := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(version)), "TLS")
+	normalized = strings.TrimPrefix(strings.TrimPrefix(normalized, "V"), " ")
+
+	switch normalized {
+	case "1.0", "10":
+		return tls.VersionTLS10, nil
+	case "1.1", "11":
+		return tls.VersionTLS11, nil
+	case "1.2", "12":
+		return tls.VersionTLS12, nil
+	case "1.3", "13":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, tlsError(fmt.Sprintf("unsupported TLS version %q", version), nil)
+	}
+}
+
+// parseCipherSuites maps cipher suite names to their identifiers
+func parseCipherSuites(names []string) ([]uint16, error) {
+	available := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		available[suite.Name] = suite.ID
+	}
+
+	suites := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, exists := available[strings.TrimSpace(name)]
+		if !exists {
+			return nil, tlsError(fmt.Sprintf("unsupported cipher suite %q", name), nil)
+		}
+		suites = append(suites, id)
+	}
+
+	return suites, nil
+}
+
+// loadCertPool loads PEM encoded CA certificates from a file
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pemData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, tlsError("failed to read CA certificate", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemData) {
+		return nil, tlsError(fmt.Sprintf("no certificates found in %s", path), nil)
+	}
+
+	return pool, nil
+}
+
+// generateSelfSignedCertificate creates an ephemeral certificate for listeners without one
+func generateSelfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, tlsError("failed to generate key", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, tlsError("failed to generate serial number", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "hypermesh-alm"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, tlsError("failed to create certificate", err)
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}, nil
+}
+
+func tlsError(message string, cause error) *TransportError {
+	return &TransportError{
+		Code:    ErrorCodeTLSError,
+		Message: message,
+		Cause:   cause,
+	}
+}
@@ -0,0 +1,80 @@
+package integration
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+)
+
+func TestClientConfigVerifiesWhenTrustConfigured(t *testing.T) {
+	certificate, err := generateSelfSignedCertificate()
+	if err != nil {
+		t.Fatalf("generateSelfSignedCertificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}
+
+	cases := []struct {
+		name   string
+		config *TLSConfig
+		verify bool
+	}{
+		{"no trust settings", &TLSConfig{}, false},
+		{"verify peer", &TLSConfig{VerifyPeer: true}, true},
+		{"trust domain", &TLSConfig{TrustDomain: "example.org"}, true},
+		{"allowed spiffe ids", &TLSConfig{AllowedSPIFFEIDs: []string{"spiffe://example.org/alm"}}, true},
+	}
+
+	manager := newTLSManager()
+	defer manager.Close()
+
+	for _, tc := range cases {
+		tlsConfig, err := manager.clientConfig(tc.config, "localhost", nil)
+		if err != nil {
+			t.Fatalf("%s: clientConfig: %v", tc.name, err)
+		}
+		if got := tlsConfig.VerifyConnection != nil; got != tc.verify {
+			t.Fatalf("%s: verification installed = %v, want %v", tc.name, got, tc.verify)
+		}
+		// An untrusted self-signed peer must be rejected whenever verification applies
+		if tc.verify {
+			if err := tlsConfig.VerifyConnection(state); err == nil {
+				t.Errorf("%s: untrusted peer accepted", tc.name)
+			}
+		}
+	}
+}
+
+func TestServerConfigClientAuth(t *testing.T) {
+	cases := []struct {
+		name   string
+		config *TLSConfig
+		want   tls.ClientAuthType
+	}{
+		{"no trust settings", &TLSConfig{}, tls.NoClientCert},
+		{"verify peer", &TLSConfig{VerifyPeer: true}, tls.RequireAnyClientCert},
+		{"trust domain", &TLSConfig{TrustDomain: "example.org"}, tls.RequireAnyClientCert},
+		{"allowed spiffe ids", &TLSConfig{AllowedSPIFFEIDs: []string{"spiffe://example.org/*"}}, tls.RequireAnyClientCert},
+	}
+
+	manager := newTLSManager()
+	defer manager.Close()
+
+	for _, tc := range cases {
+		tlsConfig, err := manager.serverConfig(tc.config, nil)
+		if err != nil {
+			t.Fatalf("%s: serverConfig: %v", tc.name, err)
+		}
+		if tlsConfig.ClientAuth != tc.want {
+			t.Errorf("%s: ClientAuth = %v, want %v", tc.name, tlsConfig.ClientAuth, tc.want)
+		}
+		if tc.want == tls.RequireAnyClientCert {
+			if err := tlsConfig.VerifyConnection(tls.ConnectionState{}); err == nil {
+				t.Errorf("%s: connection without client certificate accepted", tc.name)
+			}
+		}
+	}
+}
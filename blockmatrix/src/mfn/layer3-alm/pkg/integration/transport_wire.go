@@ -0,0 +1,242 @@
+// Package integration implements the wire format shared by HyperMesh transports
+package integration
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/logging"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer traces requests crossing the transport boundary
+var tracer = tracing.NewTracer("integration")
+
+// Frame types exchanged on a transport stream
+const (
+	frameRequest    byte = 0x01
+	frameResponse   byte = 0x02
+	frameStreamOpen byte = 0x03
+	frameStreamData byte = 0x04
+)
+
+const (
+	// frameHeaderSize is one type byte followed by a big-endian uint32 length
+	frameHeaderSize = 5
+
+	// defaultMaxFrameSize bounds a single message to protect receivers
+	defaultMaxFrameSize = 16 * 1024 * 1024
+)
+
+// RequestHandler serves requests arriving on an accepted connection
+type RequestHandler func(request *Request) *Response
+
+// StreamHandler serves streams opened by the remote peer
+type StreamHandler func(stream Stream)
+
+// wireRequest is the serialized form of a Request
+type wireRequest struct {
+	ID        string            `json:"id"`
+	Method    string            `json:"method"`
+	Path      string            `json:"path"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      []byte            `json:"body,omitempty"`
+	Priority  int               `json:"priority,omitempty"`
+	TimeoutMs int64             `json:"timeout_ms,omitempty"`
+
+	// Compression codecs the requester accepts in the response
+	AcceptEncoding []string `json:"accept_encoding,omitempty"`
+}
+
+// wireResponse is the serialized form of a Response
+type wireResponse struct {
+	ID               string            `json:"id"`
+	RequestID        string            `json:"request_id"`
+	StatusCode       int               `json:"status_code"`
+	StatusMessage    string            `json:"status_message,omitempty"`
+	Headers          map[string]string `json:"headers,omitempty"`
+	Body             []byte            `json:"body,omitempty"`
+	ProcessingTimeUs int64             `json:"processing_time_us,omitempty"`
+
+	// Compression codecs the responder accepts in later requests
+	AcceptEncoding []string `json:"accept_encoding,omitempty"`
+}
+
+// wireStreamOpen announces a new application stream to the peer
+type wireStreamOpen struct {
+	StreamID int64 `json:"stream_id"`
+	Priority int   `json:"priority"`
+	Window   int32 `json:"window,omitempty"` // Flow control window used by both sides
+
+	// Compression codecs the opener accepts in stream data
+	AcceptEncoding []string `json:"accept_encoding,omitempty"`
+}
+
+// writeFrame writes a single length-prefixed frame
+func writeFrame(w io.Writer, frameType byte, payload []byte) error {
+	if len(payload) > defaultMaxFrameSize {
+		return &TransportError{
+			Code:    ErrorCodeRequestTooLarge,
+			Message: fmt.Sprintf("frame of %d bytes exceeds limit of %d bytes", len(payload), defaultMaxFrameSize),
+		}
+	}
+
+	header := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+	header[0] = frameType
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+
+	_, err := w.Write(append(header, payload...))
+	return err
+}
+
+// readFrame reads a single length-prefixed frame
+func readFrame(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > defaultMaxFrameSize {
+		return 0, nil, &TransportError{
+			Code:    ErrorCodeProtocolError,
+			Message: fmt.Sprintf("peer sent frame of %d bytes, limit is %d bytes", length, defaultMaxFrameSize),
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+
+	return header[0], payload, nil
+}
+
+// encodeRequest serializes a request for the wire, advertising the
+// compression codecs accepted in the response
+func encodeRequest(request *Request, acceptEncoding []string) ([]byte, error) {
+	return json.Marshal(wireRequest{
+		ID:             request.ID,
+		Method:         request.Method,
+		Path:           request.Path,
+		Headers:        request.Headers,
+		Body:           request.Body,
+		Priority:       request.Priority,
+		TimeoutMs:      request.Timeout.Milliseconds(),
+		AcceptEncoding: acceptEncoding,
+	})
+}
+
+// decodeRequest deserializes a request received from the wire together with
+// the compression codecs the requester accepts
+func decodeRequest(payload []byte) (*Request, []string, error) {
+	var wire wireRequest
+	if err := json.Unmarshal(payload, &wire); err != nil {
+		return nil, nil, &TransportError{Code: ErrorCodeProtocolError, Message: "malformed request frame", Cause: err}
+	}
+
+	return &Request{
+		ID:       wire.ID,
+		Method:   wire.Method,
+		Path:     wire.Path,
+		Headers:  wire.Headers,
+		Body:     wire.Body,
+		Priority: wire.Priority,
+		Timeout:  time.Duration(wire.TimeoutMs) * time.Millisecond,
+	}, wire.AcceptEncoding, nil
+}
+
+// encodeResponse serializes a response for the wire, advertising the
+// compression codecs accepted in later requests
+func encodeResponse(response *Response, acceptEncoding []string) ([]byte, error) {
+	return json.Marshal(wireResponse{
+		ID:               response.ID,
+		RequestID:        response.RequestID,
+		StatusCode:       response.StatusCode,
+		StatusMessage:    response.StatusMessage,
+		Headers:          response.Headers,
+		Body:             response.Body,
+		ProcessingTimeUs: response.ProcessingTime.Microseconds(),
+		AcceptEncoding:   acceptEncoding,
+	})
+}
+
+// decodeResponse deserializes a response received from the wire together
+// with the compression codecs the responder accepts
+func decodeResponse(payload []byte) (*Response, []string, error) {
+	var wire wireResponse
+	if err := json.Unmarshal(payload, &wire); err != nil {
+		return nil, nil, &TransportError{Code: ErrorCodeProtocolError, Message: "malformed response frame", Cause: err}
+	}
+
+	return &Response{
+		ID:             wire.ID,
+		RequestID:      wire.RequestID,
+		StatusCode:     wire.StatusCode,
+		StatusMessage:  wire.StatusMessage,
+		Headers:        wire.Headers,
+		Body:           wire.Body,
+		ProcessingTime: time.Duration(wire.ProcessingTimeUs) * time.Microsecond,
+	}, wire.AcceptEncoding, nil
+}
+
+// startClientSpan starts the client span for an outgoing request. The returned
+// request is a copy whose Context carries the span and whose headers carry the
+// trace context and correlation ID to the peer.
+func startClientSpan(request *Request, protocol, remoteAddress string) (*Request, trace.Span) {
+	ctx, span := tracer.StartKind(request.Context, "transport.Execute", trace.SpanKindClient,
+		attribute.String("net.protocol", protocol),
+		attribute.String("net.peer", remoteAddress),
+		attribute.String("alm.request.method", request.Method),
+		attribute.String("alm.request.path", request.Path),
+	)
+
+	traced := *request
+	traced.Context = ctx
+	traced.Headers = tracing.Inject(ctx, request.Headers)
+	if id := logging.CorrelationID(ctx); id != "" {
+		traced.Headers[logging.CorrelationHeader] = id
+	}
+
+	return &traced, span
+}
+
+// serveRequest runs the handler for an incoming request and fills in response defaults
+func serveRequest(handler RequestHandler, request *Request) *Response {
+	startTime := time.Now()
+
+	ctx := logging.WithCorrelationID(tracing.Extract(request.Context, request.Headers), request.Headers[logging.CorrelationHeader])
+	ctx, span := tracer.StartKind(ctx, "transport.Serve", trace.SpanKindServer,
+		attribute.String("alm.request.method", request.Method),
+		attribute.String("alm.request.path", request.Path),
+	)
+	defer span.End()
+	request.Context = ctx
+
+	var response *Response
+	if handler != nil {
+		response = handler(request)
+	}
+	if response == nil {
+		response = &Response{
+			StatusCode:    501,
+			StatusMessage: "no handler registered",
+		}
+	}
+
+	if response.ID == "" {
+		response.ID = fmt.Sprintf("resp-%d", time.Now().UnixNano())
+	}
+	response.RequestID = request.ID
+	if response.ProcessingTime == 0 {
+		response.ProcessingTime = time.Since(startTime)
+	}
+	span.SetAttributes(attribute.Int("alm.response.status_code", response.StatusCode))
+
+	return response
+}
@@ -0,0 +1,455 @@
+// Package integration implements signed webhooks for integration lifecycle events
+package integration
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Webhook event types
+const (
+	WebhookEventIntegrationInitialized = "integration.initialized"
+	WebhookEventTargetMissed           = "integration.target_missed"
+	WebhookEventCircuitOpened          = "circuit.opened"
+	WebhookEventDiscoveryFallback      = "discovery.fallback"
+)
+
+// Webhook request headers
+const (
+	WebhookEventHeader     = "X-HyperMesh-Event"
+	WebhookDeliveryHeader  = "X-HyperMesh-Delivery"
+	WebhookSignatureHeader = "X-HyperMesh-Signature"
+)
+
+// IntegrationInitializedEvent is sent once Initialize completes
+type IntegrationInitializedEvent struct {
+	Namespace                 string `json:"namespace"`
+	EnableServiceMesh         bool   `json:"service_mesh"`
+	EnableRoutingOptimization bool   `json:"routing_optimization"`
+	EnableLoadBalancingAI     bool   `json:"load_balancing_ai"`
+	EnableCircuitBreakerAI    bool   `json:"circuit_breaker_ai"`
+}
+
+// TargetMissedEvent is sent when the improvement target has been missed
+// continuously for IntegrationConfig.TargetMissAlertAfter
+type TargetMissedEvent struct {
+	TargetImprovement  float64   `json:"target_improvement"`
+	OverallImprovement float64   `json:"overall_improvement"`
+	TargetAchievement  float64   `json:"target_achievement_percent"`
+	MissedSince        time.Time `json:"missed_since"`
+}
+
+// DiscoveryFallbackEvent is sent when ALM service discovery fails and the
+// native HyperMesh discovery is used instead
+type DiscoveryFallbackEvent struct {
+	ServiceName string `json:"service_name"`
+	Namespace   string `json:"namespace,omitempty"`
+	Error       string `json:"error"`
+}
+
+// WebhookEndpoint is a receiver of webhook events
+type WebhookEndpoint struct {
+	URL string
+
+	// Key for the HMAC-SHA256 signature; empty sends unsigned requests
+	Secret string
+
+	// Event types delivered to this endpoint; empty delivers all events
+	Events []string
+}
+
+// WebhookConfig configures a WebhookNotifier
+type WebhookConfig struct {
+	Endpoints []WebhookEndpoint
+
+	// Identifies this ALM instance in the event envelope
+	Source string
+
+	// Per-request timeout and retry behaviour
+	Timeout      time.Duration
+	MaxAttempts  int
+	RetryBackoff time.Duration
+
+	// Deliveries queued beyond BufferSize are dropped rather than blocking
+	BufferSize int
+	Workers    int
+
+	// Repeats of an event type for the same key within MinInterval are
+	// suppressed, so a flapping service does not flood receivers
+	MinInterval time.Duration
+}
+
+// WebhookStats summarizes notifier activity
+type WebhookStats struct {
+	Delivered  int64
+	Failed     int64
+	Dropped    int64
+	Suppressed int64
+	Queued     int
+}
+
+// webhookDelivery is one event addressed to one endpoint
+type webhookDelivery struct {
+	endpoint  *WebhookEndpoint
+	eventType string
+	eventID   string
+	body      []byte
+}
+
+// WebhookNotifier posts integration lifecycle events to HTTP endpoints.
+//
+// Each request body is an Event envelope. When the endpoint has a secret the
+// request carries a signature header of the form
+//
+//	X-HyperMesh-Signature: t=<unix seconds>,v1=<hex HMAC-SHA256>
+//
+// computed over "<t>.<body>", which receivers check with
+// VerifyWebhookSignature. Deliveries are retried with exponential backoff on
+// network errors, 429 and 5xx responses.
+type WebhookNotifier struct {
+	config *WebhookConfig
+	client *http.Client
+	logger *zap.Logger
+
+	queue    chan webhookDelivery
+	sequence atomic.Uint64
+
+	// Last send per "<type>/<key>" for duplicate suppression
+	lastSent map[string]time.Time
+	mutex    sync.Mutex
+
+	// Counters
+	delivered  atomic.Int64
+	failed     atomic.Int64
+	dropped    atomic.Int64
+	suppressed atomic.Int64
+
+	stopChan  chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewWebhookNotifier creates a notifier and starts its delivery workers
+func NewWebhookNotifier(config *WebhookConfig, logger *zap.Logger) *WebhookNotifier {
+	if config == nil {
+		config = DefaultWebhookConfig()
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+
+	wn := &WebhookNotifier{
+		config:   config,
+		client:   &http.Client{Timeout: config.Timeout},
+		logger:   logger,
+		queue:    make(chan webhookDelivery, config.BufferSize),
+		lastSent: make(map[string]time.Time),
+		stopChan: make(chan struct{}),
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		wn.wg.Add(1)
+		go wn.run()
+	}
+
+	return wn
+}
+
+// Notify queues an event of eventType for every subscribed endpoint. key
+// identifies the subject of the event for duplicate suppression.
+func (wn *WebhookNotifier) Notify(eventType, key string, data interface{}) {
+	select {
+	case <-wn.stopChan:
+		wn.dropped.Add(1)
+		return
+	default:
+	}
+
+	if wn.suppress(eventType, key) {
+		wn.suppressed.Add(1)
+		return
+	}
+
+	eventID := fmt.Sprintf("%s-webhook-%d", wn.config.Source, wn.sequence.Add(1))
+	body, err := encodeWebhookEvent(eventID, eventType, wn.config.Source, data)
+	if err != nil {
+		wn.failed.Add(1)
+		wn.logger.Error("Failed to encode webhook event", zap.String("type", eventType), zap.Error(err))
+		return
+	}
+
+	for i := range wn.config.Endpoints {
+		endpoint := &wn.config.Endpoints[i]
+		if !endpoint.subscribes(eventType) {
+			continue
+		}
+
+		select {
+		case wn.queue <- webhookDelivery{endpoint: endpoint, eventType: eventType, eventID: eventID, body: body}:
+		default:
+			wn.dropped.Add(1)
+		}
+	}
+}
+
+// Stats returns notifier counters
+func (wn *WebhookNotifier) Stats() WebhookStats {
+	return WebhookStats{
+		Delivered:  wn.delivered.Load(),
+		Failed:     wn.failed.Load(),
+		Dropped:    wn.dropped.Load(),
+		Suppressed: wn.suppressed.Load(),
+		Queued:     len(wn.queue),
+	}
+}
+
+// Close delivers queued events and stops the workers
+func (wn *WebhookNotifier) Close() error {
+	wn.closeOnce.Do(func() {
+		close(wn.stopChan)
+		wn.wg.Wait()
+	})
+	return nil
+}
+
+// suppress reports whether eventType for key was sent within MinInterval,
+// recording the send otherwise
+func (wn *WebhookNotifier) suppress(eventType, key string) bool {
+	if wn.config.MinInterval <= 0 {
+		return false
+	}
+
+	wn.mutex.Lock()
+	defer wn.mutex.Unlock()
+
+	now := time.Now()
+	id := eventType + "/" + key
+	if last, exists := wn.lastSent[id]; exists && now.Sub(last) < wn.config.MinInterval {
+		return true
+	}
+	wn.lastSent[id] = now
+
+	// Forget expired entries so the map does not grow with every key seen
+	if len(wn.lastSent) > 1024 {
+		for id, last := range wn.lastSent {
+			if now.Sub(last) >= wn.config.MinInterval {
+				delete(wn.lastSent, id)
+			}
+		}
+	}
+
+	return false
+}
+
+// run delivers queued events until Close, then flushes the queue
+func (wn *WebhookNotifier) run() {
+	defer wn.wg.Done()
+
+	for {
+		select {
+		case delivery := <-wn.queue:
+			wn.deliver(delivery)
+
+		case <-wn.stopChan:
+			for {
+				select {
+				case delivery := <-wn.queue:
+					wn.deliver(delivery)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver posts one event, retrying retryable failures
+func (wn *WebhookNotifier) deliver(delivery webhookDelivery) {
+	backoff := wn.config.RetryBackoff
+
+	var err error
+	for attempt := 1; attempt <= wn.config.MaxAttempts; attempt++ {
+		var retryable bool
+		retryable, err = wn.post(delivery)
+		if err == nil {
+			wn.delivered.Add(1)
+			return
+		}
+		if !retryable || attempt == wn.config.MaxAttempts || !wn.wait(backoff) {
+			break
+		}
+		backoff *= 2
+	}
+
+	wn.failed.Add(1)
+	wn.logger.Warn("Failed to deliver webhook",
+		zap.String("type", delivery.eventType),
+		zap.String("url", delivery.endpoint.URL),
+		zap.Error(err),
+	)
+}
+
+// wait sleeps for backoff, returning false when Close interrupts it so
+// shutdown is not held up retrying a dead receiver
+func (wn *WebhookNotifier) wait(backoff time.Duration) bool {
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-wn.stopChan:
+		return false
+	}
+}
+
+// post sends one delivery attempt, reporting whether a failure is retryable
+func (wn *WebhookNotifier) post(delivery webhookDelivery) (bool, error) {
+	ctx := context.Background()
+	if wn.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, wn.config.Timeout)
+		defer cancel()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.endpoint.URL, bytes.NewReader(delivery.body))
+	if err != nil {
+		return false, fmt.Errorf("invalid webhook request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookEventHeader, delivery.eventType)
+	request.Header.Set(WebhookDeliveryHeader, delivery.eventID)
+	if delivery.endpoint.Secret != "" {
+		request.Header.Set(WebhookSignatureHeader, SignWebhookPayload(delivery.endpoint.Secret, time.Now(), delivery.body))
+	}
+
+	response, err := wn.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	response.Body.Close()
+
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		return false, nil
+	case response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500:
+		return true, fmt.Errorf("webhook receiver returned status %d", response.StatusCode)
+	default:
+		return false, fmt.Errorf("webhook receiver returned status %d", response.StatusCode)
+	}
+}
+
+// subscribes reports whether the endpoint receives eventType
+func (we *WebhookEndpoint) subscribes(eventType string) bool {
+	if len(we.Events) == 0 {
+		return true
+	}
+	for _, subscribed := range we.Events {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// SignWebhookPayload returns the signature header value for body sent at timestamp
+func SignWebhookPayload(secret string, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + unix + ",v1=" + webhookMAC(secret, unix, body)
+}
+
+// VerifyWebhookSignature checks a signature header against body. Signatures
+// older than tolerance are rejected to limit replay; a zero tolerance skips
+// the age check.
+func VerifyWebhookSignature(secret, header string, body []byte, tolerance time.Duration) error {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	if timestamp == "" || signature == "" {
+		return fmt.Errorf("malformed webhook signature header")
+	}
+
+	if tolerance > 0 {
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return fmt.Errorf("malformed webhook signature timestamp: %w", err)
+		}
+		if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+			return fmt.Errorf("webhook signature timestamp outside tolerance of %v", tolerance)
+		}
+	}
+
+	expected := webhookMAC(secret, timestamp, body)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return fmt.Errorf("webhook signature mismatch")
+	}
+	return nil
+}
+
+func webhookMAC(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func encodeWebhookEvent(eventID, eventType, source string, data interface{}) ([]byte, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s payload: %w", eventType, err)
+	}
+
+	return json.Marshal(Event{
+		SchemaVersion: EventSchemaVersion,
+		ID:            eventID,
+		Type:          eventType,
+		Source:        source,
+		Time:          time.Now().UTC(),
+		Data:          payload,
+	})
+}
+
+// DefaultWebhookConfig returns default webhook configuration
+func DefaultWebhookConfig() *WebhookConfig {
+	return &WebhookConfig{
+		Source:       "alm",
+		Timeout:      5 * time.Second,
+		MaxAttempts:  3,
+		RetryBackoff: time.Second,
+		BufferSize:   256,
+		Workers:      2,
+		MinInterval:  time.Minute,
+	}
+}
@@ -0,0 +1,176 @@
+package integration
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookSignatureRoundTrip(t *testing.T) {
+	body := []byte(`{"type":"circuit.opened"}`)
+	header := SignWebhookPayload("secret", time.Now(), body)
+
+	if err := VerifyWebhookSignature("secret", header, body, time.Minute); err != nil {
+		t.Fatalf("VerifyWebhookSignature: %v", err)
+	}
+	if err := VerifyWebhookSignature("other", header, body, time.Minute); err == nil {
+		t.Error("signature verified with the wrong secret")
+	}
+	if err := VerifyWebhookSignature("secret", "v1=abc", body, time.Minute); err == nil {
+		t.Error("header without a timestamp accepted")
+	}
+}
+
+func TestWebhookSignatureTamperedBody(t *testing.T) {
+	body := []byte(`{"type":"circuit.opened"}`)
+	header := SignWebhookPayload("secret", time.Now(), body)
+
+	if err := VerifyWebhookSignature("secret", header, []byte(`{"type":"circuit.closed"}`), time.Minute); err == nil {
+		t.Error("signature verified over a changed body")
+	}
+}
+
+func TestWebhookSignatureTamperedTimestamp(t *testing.T) {
+	body := []byte(`{"type":"circuit.opened"}`)
+	now := time.Now()
+	header := SignWebhookPayload("secret", now, body)
+
+	// The timestamp is signed, so moving it within the tolerance still fails
+	unix := strconv.FormatInt(now.Unix(), 10)
+	moved := strings.Replace(header, "t="+unix, "t="+strconv.FormatInt(now.Unix()-1, 10), 1)
+	if err := VerifyWebhookSignature("secret", moved, body, time.Minute); err == nil {
+		t.Errorf("signature verified with a changed timestamp: %s", moved)
+	}
+}
+
+func TestWebhookSignatureStale(t *testing.T) {
+	body := []byte(`{"type":"circuit.opened"}`)
+	header := SignWebhookPayload("secret", time.Now().Add(-10*time.Minute), body)
+
+	if err := VerifyWebhookSignature("secret", header, body, 5*time.Minute); err == nil {
+		t.Error("signature older than the tolerance accepted")
+	}
+
+	// A zero tolerance skips the age check
+	if err := VerifyWebhookSignature("secret", header, body, 0); err != nil {
+		t.Errorf("VerifyWebhookSignature without a tolerance: %v", err)
+	}
+}
+
+// webhookReceiver answers every request with status and counts them
+func webhookReceiver(t *testing.T, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// awaitWebhookStats waits until the notifier has finished with n deliveries
+func awaitWebhookStats(t *testing.T, notifier *WebhookNotifier, n int64) WebhookStats {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		stats := notifier.Stats()
+		if stats.Delivered+stats.Failed >= n || time.Now().After(deadline) {
+			return stats
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// webhookTestConfig sends to url with three quick attempts and no
+// duplicate suppression
+func webhookTestConfig(url string) *WebhookConfig {
+	config := DefaultWebhookConfig()
+	config.Endpoints = []WebhookEndpoint{{URL: url, Secret: "secret"}}
+	config.RetryBackoff = time.Millisecond
+	config.MinInterval = 0
+	return config
+}
+
+func TestWebhookNotifierDelivers(t *testing.T) {
+	var verified atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		err := VerifyWebhookSignature("secret", r.Header.Get(WebhookSignatureHeader), body, time.Minute)
+		verified.Store(err == nil && r.Header.Get(WebhookEventHeader) == WebhookEventCircuitOpened)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(webhookTestConfig(server.URL), nil)
+	notifier.Notify(WebhookEventCircuitOpened, "orders", map[string]string{"service": "orders"})
+	notifier.Close()
+
+	if stats := notifier.Stats(); stats.Delivered != 1 || stats.Failed != 0 {
+		t.Errorf("stats %+v, want 1 delivered", stats)
+	}
+	if !verified.Load() {
+		t.Error("receiver could not verify the delivery's signature and event header")
+	}
+}
+
+func TestWebhookNotifierRetriesServerErrors(t *testing.T) {
+	server, requests := webhookReceiver(t, http.StatusServiceUnavailable)
+
+	notifier := NewWebhookNotifier(webhookTestConfig(server.URL), nil)
+	defer notifier.Close()
+	notifier.Notify(WebhookEventCircuitOpened, "orders", nil)
+
+	stats := awaitWebhookStats(t, notifier, 1)
+	if got := requests.Load(); got != 3 {
+		t.Errorf("receiver saw %d requests for a 503, want all 3 attempts", got)
+	}
+	if stats.Failed != 1 || stats.Delivered != 0 {
+		t.Errorf("stats %+v, want 1 failed", stats)
+	}
+}
+
+func TestWebhookNotifierDoesNotRetryClientErrors(t *testing.T) {
+	server, requests := webhookReceiver(t, http.StatusBadRequest)
+
+	notifier := NewWebhookNotifier(webhookTestConfig(server.URL), nil)
+	defer notifier.Close()
+	notifier.Notify(WebhookEventCircuitOpened, "orders", nil)
+
+	stats := awaitWebhookStats(t, notifier, 1)
+	if got := requests.Load(); got != 1 {
+		t.Errorf("receiver saw %d requests for a 400, want 1", got)
+	}
+	if stats.Failed != 1 {
+		t.Errorf("stats %+v, want 1 failed", stats)
+	}
+}
+
+func TestWebhookNotifierSuppressesRepeats(t *testing.T) {
+	server, requests := webhookReceiver(t, http.StatusOK)
+
+	config := webhookTestConfig(server.URL)
+	config.MinInterval = time.Minute
+	notifier := NewWebhookNotifier(config, nil)
+
+	// A service whose circuit keeps opening is reported once per interval;
+	// other services are reported independently
+	for i := 0; i < 5; i++ {
+		notifier.Notify(WebhookEventCircuitOpened, "orders", nil)
+	}
+	notifier.Notify(WebhookEventCircuitOpened, "payments", nil)
+	notifier.Close()
+
+	if got := requests.Load(); got != 2 {
+		t.Errorf("receiver saw %d requests, want one each for orders and payments", got)
+	}
+	if stats := notifier.Stats(); stats.Suppressed != 4 {
+		t.Errorf("stats %+v, want 4 suppressed", stats)
+	}
+}
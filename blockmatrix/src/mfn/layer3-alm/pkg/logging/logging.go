@@ -0,0 +1,381 @@
+// Package logging provides the zap loggers shared by the Layer 3 ALM
+// components, request correlation IDs carried in contexts, and sampling and
+// rate limits that keep hot-path logging affordable in production.
+//
+// Packages without a logger of their own declare one per component:
+//
+//	var logger = logging.Named("routing")
+//
+// and log through logger.Ctx(ctx), which adds the request's correlation and
+// trace IDs. Like tracing.Tracer, the logger resolves the process default on
+// use, so a logger installed with SetDefault after package initialization
+// still takes effect. Until then component loggers discard everything.
+//
+// Loggers created with New scrub every entry with the scrubber installed
+// by SetScrubber, redacting configured field keys and address patterns.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/scrub"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// CorrelationHeader carries a request's correlation ID across the transport
+// and the admin API
+const CorrelationHeader = "X-Correlation-ID"
+
+// CorrelationMetadataKey carries a request's correlation ID in gRPC metadata
+const CorrelationMetadataKey = "x-correlation-id"
+
+// Config configures a logger created with New
+type Config struct {
+	// Minimum level: debug, info, warn or error
+	Level string
+
+	// json or console
+	Encoding string
+
+	// Sampling: each distinct message is logged SampleInitial times per
+	// SampleTick, then every SampleThereafter-th time. A zero SampleTick
+	// disables sampling.
+	SampleTick       time.Duration
+	SampleInitial    int
+	SampleThereafter int
+
+	// Debug and Info entries are limited to RateLimit per second with bursts
+	// of RateBurst, across all messages; warnings and errors are never
+	// dropped. A zero RateLimit disables the limit.
+	RateLimit float64
+	RateBurst int
+}
+
+// Stats counts entries dropped by loggers created with New
+type Stats struct {
+	Sampled     int64
+	RateLimited int64
+}
+
+var (
+	// The process default, installed with SetDefault
+	defaultLogger atomic.Pointer[zap.Logger]
+
+	// Applied to entries of loggers created with New, installed with
+	// SetScrubber
+	scrubber atomic.Pointer[scrub.Scrubber]
+
+	sampled     atomic.Int64
+	rateLimited atomic.Int64
+)
+
+func init() {
+	defaultLogger.Store(zap.NewNop())
+}
+
+// SetDefault installs logger as the process default used by component
+// loggers. A nil logger discards everything.
+func SetDefault(logger *zap.Logger) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	defaultLogger.Store(logger)
+}
+
+// SetScrubber installs s to scrub the entries of every logger created with
+// New. A nil s stops scrubbing. Fields added with With before s was
+// installed are not scrubbed.
+func SetScrubber(s *scrub.Scrubber) {
+	scrubber.Store(s)
+}
+
+// Default returns the process default logger
+func Default() *zap.Logger {
+	return defaultLogger.Load()
+}
+
+// New creates a logger from config
+func New(config *Config) (*zap.Logger, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	level, err := zapcore.ParseLevel(config.Level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", config.Level, err)
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	var encoder zapcore.Encoder
+	switch config.Encoding {
+	case "json", "":
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case "console":
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, fmt.Errorf("invalid log encoding %q, expected json or console", config.Encoding)
+	}
+
+	var core zapcore.Core = &scrubbingCore{Core: zapcore.NewCore(encoder, zapcore.Lock(os.Stderr), level)}
+
+	if config.RateLimit > 0 {
+		core = &rateLimitedCore{Core: core, limiter: newEntryLimiter(config.RateLimit, config.RateBurst)}
+	}
+
+	if config.SampleTick > 0 {
+		core = zapcore.NewSamplerWithOptions(core, config.SampleTick, config.SampleInitial, config.SampleThereafter,
+			zapcore.SamplerHook(func(_ zapcore.Entry, decision zapcore.SamplingDecision) {
+				if decision&zapcore.LogDropped != 0 {
+					sampled.Add(1)
+				}
+			}),
+		)
+	}
+
+	return zap.New(core, zap.AddCaller(), zap.ErrorOutput(zapcore.Lock(os.Stderr))), nil
+}
+
+// GetStats returns the entries dropped so far by loggers created with New
+func GetStats() Stats {
+	return Stats{
+		Sampled:     sampled.Load(),
+		RateLimited: rateLimited.Load(),
+	}
+}
+
+// Logger is a component logger resolved from the process default
+type Logger struct {
+	name   string
+	cached atomic.Pointer[namedLogger]
+}
+
+// namedLogger is a component logger derived from one default logger
+type namedLogger struct {
+	base   *zap.Logger
+	logger *zap.Logger
+}
+
+// Named returns the logger for the named component
+func Named(component string) *Logger {
+	return &Logger{name: component}
+}
+
+// L returns the component's logger
+func (l *Logger) L() *zap.Logger {
+	base := defaultLogger.Load()
+	if cached := l.cached.Load(); cached != nil && cached.base == base {
+		return cached.logger
+	}
+
+	logger := base.Named(l.name)
+	l.cached.Store(&namedLogger{base: base, logger: logger})
+	return logger
+}
+
+// Ctx returns the component's logger with the correlation and trace IDs of ctx
+func (l *Logger) Ctx(ctx context.Context) *zap.Logger {
+	return WithContext(ctx, l.L())
+}
+
+// WithContext returns logger with the correlation and trace IDs of ctx, if
+// any. A nil ctx returns logger unchanged.
+func WithContext(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	if ctx == nil {
+		return logger
+	}
+
+	var fields []zap.Field
+	if id := CorrelationID(ctx); id != "" {
+		fields = append(fields, zap.String("correlation_id", id))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		fields = append(fields,
+			zap.String("trace_id", spanContext.TraceID().String()),
+			zap.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+
+	if len(fields) == 0 {
+		return logger
+	}
+	return logger.With(fields...)
+}
+
+// correlationKey is the context key of the correlation ID
+type correlationKey struct{}
+
+// WithCorrelationID returns a context carrying id. An empty id returns ctx
+// unchanged.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID returns the correlation ID carried by ctx, or ""
+func CorrelationID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// EnsureCorrelationID returns ctx and its correlation ID, adding a new ID if
+// ctx has none. A nil ctx is treated as context.Background.
+func EnsureCorrelationID(ctx context.Context) (context.Context, string) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if id := CorrelationID(ctx); id != "" {
+		return ctx, id
+	}
+
+	id := NewCorrelationID()
+	return WithCorrelationID(ctx, id), id
+}
+
+// NewCorrelationID returns a random 128-bit ID in hex
+func NewCorrelationID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// rateLimitedCore drops Debug and Info entries beyond the limiter's rate
+type rateLimitedCore struct {
+	zapcore.Core
+	limiter *entryLimiter
+}
+
+func (rc *rateLimitedCore) With(fields []zapcore.Field) zapcore.Core {
+	return &rateLimitedCore{Core: rc.Core.With(fields), limiter: rc.limiter}
+}
+
+func (rc *rateLimitedCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !rc.Enabled(entry.Level) {
+		return checked
+	}
+	if entry.Level <= zapcore.InfoLevel && !rc.limiter.allow(entry.Time) {
+		rateLimited.Add(1)
+		return checked
+	}
+	return checked.AddCore(entry, rc)
+}
+
+// scrubbingCore scrubs entry messages and fields with the installed scrubber
+type scrubbingCore struct {
+	zapcore.Core
+}
+
+func (sc *scrubbingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &scrubbingCore{Core: sc.Core.With(scrubFields(scrubber.Load(), fields))}
+}
+
+func (sc *scrubbingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if sc.Enabled(entry.Level) {
+		return checked.AddCore(entry, sc)
+	}
+	return checked
+}
+
+func (sc *scrubbingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if s := scrubber.Load(); s != nil {
+		entry.Message = s.String(entry.Message)
+		fields = scrubFields(s, fields)
+	}
+	return sc.Core.Write(entry, fields)
+}
+
+// scrubFields returns fields with redacted keys replaced and address
+// patterns redacted from strings and errors
+func scrubFields(s *scrub.Scrubber, fields []zapcore.Field) []zapcore.Field {
+	if s == nil || len(fields) == 0 {
+		return fields
+	}
+
+	scrubbed := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		switch {
+		case s.RedactsKey(field.Key):
+			field = zap.String(field.Key, s.Field(field.Key, fieldString(field)))
+		case field.Type == zapcore.StringType:
+			field = zap.String(field.Key, s.String(field.String))
+		case field.Type == zapcore.ErrorType || field.Type == zapcore.StringerType:
+			field = zap.String(field.Key, s.String(fieldString(field)))
+		}
+		scrubbed[i] = field
+	}
+	return scrubbed
+}
+
+// fieldString renders a field's value as text
+func fieldString(field zapcore.Field) string {
+	encoder := zapcore.NewMapObjectEncoder()
+	field.AddTo(encoder)
+	return fmt.Sprint(encoder.Fields[field.Key])
+}
+
+// entryLimiter is a token bucket shared by a logger and its children
+type entryLimiter struct {
+	rate    float64
+	burst   float64
+	tokens  float64
+	updated time.Time
+	mutex   sync.Mutex
+}
+
+func newEntryLimiter(rate float64, burst int) *entryLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &entryLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), updated: time.Now()}
+}
+
+func (el *entryLimiter) allow(now time.Time) bool {
+	el.mutex.Lock()
+	defer el.mutex.Unlock()
+
+	if elapsed := now.Sub(el.updated).Seconds(); elapsed > 0 {
+		el.tokens += elapsed * el.rate
+		if el.tokens > el.burst {
+			el.tokens = el.burst
+		}
+		el.updated = now
+	}
+
+	if el.tokens < 1 {
+		return false
+	}
+	el.tokens--
+	return true
+}
+
+// DefaultConfig returns default logging configuration: JSON at info level,
+// sampled like zap's production logger and limited to 1000 Debug and Info
+// entries per second
+func DefaultConfig() *Config {
+	return &Config{
+		Level:            "info",
+		Encoding:         "json",
+		SampleTick:       time.Second,
+		SampleInitial:    100,
+		SampleThereafter: 100,
+		RateLimit:        1000,
+		RateBurst:        2000,
+	}
+}
@@ -0,0 +1,595 @@
+// Package metrics implements Prometheus collectors for ALM component statistics
+package metrics
+
+import (
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/histogram"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/integration"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/optimization"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/service"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// descSet holds the descriptors of a collector so Describe can emit them all
+type descSet []*prometheus.Desc
+
+func (ds *descSet) add(namespace, subsystem, name, help string, labels ...string) *prometheus.Desc {
+	return ds.addConst(namespace, subsystem, name, help, nil, labels...)
+}
+
+// addConst adds a descriptor whose constLabels are fixed for the collector.
+// Collectors of the same kind registered more than once must differ in them.
+func (ds *descSet) addConst(namespace, subsystem, name, help string, constLabels prometheus.Labels, labels ...string) *prometheus.Desc {
+	desc := prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, name), help, labels, constLabels)
+	*ds = append(*ds, desc)
+	return desc
+}
+
+func (ds descSet) describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range ds {
+		ch <- desc
+	}
+}
+
+func counter(ch chan<- prometheus.Metric, desc *prometheus.Desc, value int64, labels ...string) {
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(value), labels...)
+}
+
+func gauge(ch chan<- prometheus.Metric, desc *prometheus.Desc, value float64, labels ...string) {
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+}
+
+// latencySummary emits a summary from a request count, an average and percentiles
+func latencySummary(ch chan<- prometheus.Metric, desc *prometheus.Desc, count int64, average time.Duration, quantiles map[float64]time.Duration, labels ...string) {
+	seconds := make(map[float64]float64, len(quantiles))
+	for quantile, latency := range quantiles {
+		seconds[quantile] = latency.Seconds()
+	}
+	sum := average.Seconds() * float64(count)
+	ch <- prometheus.MustNewConstSummary(desc, uint64(count), sum, seconds, labels...)
+}
+
+// lookupLatencyBuckets are the upper bounds of exported latency histograms
+var lookupLatencyBuckets = []time.Duration{
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// latencyHistogram emits a histogram with lookupLatencyBuckets from an HDR histogram
+func latencyHistogram(ch chan<- prometheus.Metric, desc *prometheus.Desc, h *histogram.Histogram, labels ...string) {
+	cumulative := h.CumulativeCounts(lookupLatencyBuckets)
+	buckets := make(map[float64]uint64, len(lookupLatencyBuckets))
+	for i, bound := range lookupLatencyBuckets {
+		buckets[bound.Seconds()] = cumulative[i]
+	}
+	ch <- prometheus.MustNewConstHistogram(desc, uint64(h.Count()), h.Sum().Seconds(), buckets, labels...)
+}
+
+// routingCollector exports RoutingMetrics, RouteCache and LoadBalancer statistics
+type routingCollector struct {
+	routingTable *routing.RoutingTable
+	descs        descSet
+
+	lookups              *prometheus.Desc
+	lookupDuration       *prometheus.Desc
+	lookupLatency        *prometheus.Desc
+	lookupCache          *prometheus.Desc
+	lookupCoalesced      *prometheus.Desc
+	invalidations        *prometheus.Desc
+	routeCacheOps        *prometheus.Desc
+	routeCacheSize       *prometheus.Desc
+	lbDecisions          *prometheus.Desc
+	lbLoadBalanced       *prometheus.Desc
+	lbFailovers          *prometheus.Desc
+	lbHealthFailures     *prometheus.Desc
+	lbCapacityRejections *prometheus.Desc
+	lbTracked            *prometheus.Desc
+}
+
+func newRoutingCollector(namespace string, routingTable *routing.RoutingTable) *routingCollector {
+	rc := &routingCollector{routingTable: routingTable}
+	rc.lookups = rc.descs.add(namespace, "routing", "lookups_total", "Route lookups by result.", "result")
+	rc.lookupDuration = rc.descs.add(namespace, "routing", "lookup_duration_seconds", "Route lookup latency; quantiles cover the last five minutes.")
+	rc.lookupLatency = rc.descs.add(namespace, "routing", "lookup_latency_seconds", "Distribution of route lookup latency since the last metrics reset.")
+	rc.lookupCache = rc.descs.add(namespace, "routing", "lookup_cache_total", "Route lookups by cache outcome.", "outcome")
+	rc.lookupCoalesced = rc.descs.add(namespace, "routing", "lookups_coalesced_total", "Cache misses that shared a concurrent lookup's route discovery.")
+	rc.invalidations = rc.descs.add(namespace, "routing", "invalidations_total", "Route invalidations by reason.", "reason")
+	rc.routeCacheOps = rc.descs.add(namespace, "route_cache", "operations_total", "Route cache operations by type.", "operation")
+	rc.routeCacheSize = rc.descs.add(namespace, "route_cache", "entries", "Routes currently cached.")
+	rc.lbDecisions = rc.descs.add(namespace, "load_balancer", "decisions_total", "Load balancing decisions.")
+	rc.lbLoadBalanced = rc.descs.add(namespace, "load_balancer", "load_balanced_decisions_total", "Decisions that moved traffic off the primary path.")
+	rc.lbFailovers = rc.descs.add(namespace, "load_balancer", "failover_events_total", "Failover events.")
+	rc.lbHealthFailures = rc.descs.add(namespace, "load_balancer", "health_check_failures_total", "Node health check failures.")
+	rc.lbCapacityRejections = rc.descs.add(namespace, "load_balancer", "capacity_rejections_total", "Routes rejected for crossing a node at capacity.")
+	rc.lbTracked = rc.descs.add(namespace, "load_balancer", "tracked", "Paths and nodes with load tracking state.", "kind")
+	return rc
+}
+
+func (rc *routingCollector) Describe(ch chan<- *prometheus.Desc) {
+	rc.descs.describe(ch)
+}
+
+func (rc *routingCollector) Collect(ch chan<- prometheus.Metric) {
+	routingMetrics := rc.routingTable.GetRoutingMetrics()
+	snapshot := routingMetrics.GetCurrentStats()
+	latencies := routingMetrics.LatencyHistogram()
+	recent := routingMetrics.RecentLatencyHistogram()
+
+	counter(ch, rc.lookups, snapshot.SuccessfulLookups, "success")
+	counter(ch, rc.lookups, snapshot.FailedLookups, "failure")
+	quantiles := make(map[float64]time.Duration)
+	for _, q := range []float64{0.5, 0.9, 0.95, 0.99, 0.999, 0.9999} {
+		quantiles[q] = recent.Quantile(q)
+	}
+	latencySummary(ch, rc.lookupDuration, snapshot.TotalLookups, snapshot.AverageLatency, quantiles)
+	latencyHistogram(ch, rc.lookupLatency, latencies)
+	counter(ch, rc.lookupCache, snapshot.CacheHits, "hit")
+	counter(ch, rc.lookupCache, snapshot.CacheMisses, "miss")
+	counter(ch, rc.lookupCoalesced, snapshot.CoalescedLookups)
+	for reason, count := range routingMetrics.GetInvalidationReasons() {
+		counter(ch, rc.invalidations, count, reason)
+	}
+
+	cacheStats := rc.routingTable.GetRouteCacheStats()
+	counter(ch, rc.routeCacheOps, cacheStats.Hits, "hit")
+	counter(ch, rc.routeCacheOps, cacheStats.Misses, "miss")
+	counter(ch, rc.routeCacheOps, cacheStats.Puts, "put")
+	counter(ch, rc.routeCacheOps, cacheStats.Invalidations, "invalidation")
+	gauge(ch, rc.routeCacheSize, float64(cacheStats.Size))
+
+	lbStats := rc.routingTable.GetLoadBalancerStats()
+	counter(ch, rc.lbDecisions, lbStats.TotalDecisions)
+	counter(ch, rc.lbLoadBalanced, lbStats.LoadBalancedDecisions)
+	counter(ch, rc.lbFailovers, lbStats.FailoverEvents)
+	counter(ch, rc.lbHealthFailures, lbStats.HealthCheckFailures)
+	counter(ch, rc.lbCapacityRejections, lbStats.CapacityRejections)
+	gauge(ch, rc.lbTracked, float64(lbStats.TrackedPaths), "path")
+	gauge(ch, rc.lbTracked, float64(lbStats.TrackedNodes), "node")
+}
+
+// graphCollector exports topology size, path search and PathCache statistics
+type graphCollector struct {
+	networkGraph *graph.NetworkGraph
+	descs        descSet
+
+	nodes         *prometheus.Desc
+	edges         *prometheus.Desc
+	lastUpdate    *prometheus.Desc
+	pathCacheOps  *prometheus.Desc
+	pathCacheSize *prometheus.Desc
+	pathSearches  *prometheus.Desc
+	nodesExpanded *prometheus.Desc
+	edgeOutliers  *prometheus.Desc
+	nodeOutliers  *prometheus.Desc
+}
+
+func newGraphCollector(namespace string, networkGraph *graph.NetworkGraph) *graphCollector {
+	gc := &graphCollector{networkGraph: networkGraph}
+	gc.nodes = gc.descs.add(namespace, "graph", "nodes", "Nodes in the network graph.")
+	gc.edges = gc.descs.add(namespace, "graph", "edges", "Edges in the network graph.")
+	gc.lastUpdate = gc.descs.add(namespace, "graph", "last_update_timestamp_seconds", "Unix time of the last topology change.")
+	gc.pathCacheOps = gc.descs.add(namespace, "path_cache", "operations_total", "Path cache operations by type.", "operation")
+	gc.pathCacheSize = gc.descs.add(namespace, "path_cache", "entries", "Paths currently cached.")
+	gc.pathSearches = gc.descs.add(namespace, "graph", "path_searches_total", "Shortest path searches by algorithm.", "algorithm")
+	gc.nodesExpanded = gc.descs.add(namespace, "graph", "search_nodes_expanded_total", "Nodes expanded by shortest path searches.")
+	gc.edgeOutliers = gc.descs.add(namespace, "graph", "edge_outliers_rejected_total", "Edge latency samples rejected as outliers.")
+	gc.nodeOutliers = gc.descs.add(namespace, "graph", "node_outliers_rejected_total", "Node latency samples rejected as outliers.")
+	return gc
+}
+
+func (gc *graphCollector) Describe(ch chan<- *prometheus.Desc) {
+	gc.descs.describe(ch)
+}
+
+func (gc *graphCollector) Collect(ch chan<- prometheus.Metric) {
+	topology := gc.networkGraph.GetTopologyStats()
+	gauge(ch, gc.nodes, float64(topology.TotalNodes))
+	gauge(ch, gc.edges, float64(topology.TotalEdges))
+	if !topology.LastUpdate.IsZero() {
+		gauge(ch, gc.lastUpdate, float64(topology.LastUpdate.UnixNano())/1e9)
+	}
+	counter(ch, gc.pathSearches, topology.GuidedSearches, "astar")
+	counter(ch, gc.pathSearches, topology.PathSearches-topology.GuidedSearches, "dijkstra")
+	counter(ch, gc.nodesExpanded, topology.NodesExpanded)
+	counter(ch, gc.edgeOutliers, topology.RejectedOutliers)
+	counter(ch, gc.nodeOutliers, topology.RejectedNodeOutliers)
+
+	cacheStats := gc.networkGraph.GetPathCacheStats()
+	counter(ch, gc.pathCacheOps, cacheStats.Hits, "hit")
+	counter(ch, gc.pathCacheOps, cacheStats.Misses, "miss")
+	counter(ch, gc.pathCacheOps, cacheStats.Evictions, "eviction")
+	counter(ch, gc.pathCacheOps, cacheStats.Invalidations, "invalidation")
+	gauge(ch, gc.pathCacheSize, float64(cacheStats.Size))
+}
+
+// optimizerCollector exports MultiObjectiveOptimizer statistics
+type optimizerCollector struct {
+	optimizer *optimization.MultiObjectiveOptimizer
+	descs     descSet
+
+	optimizations *prometheus.Desc
+	evaluations   *prometheus.Desc
+	averageTime   *prometheus.Desc
+}
+
+func newOptimizerCollector(namespace string, optimizer *optimization.MultiObjectiveOptimizer) *optimizerCollector {
+	oc := &optimizerCollector{optimizer: optimizer}
+	oc.optimizations = oc.descs.add(namespace, "optimizer", "runs_total", "Completed multi-objective optimization runs.")
+	oc.evaluations = oc.descs.add(namespace, "optimizer", "evaluations_total", "Candidate solution evaluations.")
+	oc.averageTime = oc.descs.add(namespace, "optimizer", "run_duration_seconds_ema", "Exponential moving average of optimization run time.")
+	return oc
+}
+
+func (oc *optimizerCollector) Describe(ch chan<- *prometheus.Desc) {
+	oc.descs.describe(ch)
+}
+
+func (oc *optimizerCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := oc.optimizer.GetOptimizationStats()
+	counter(ch, oc.optimizations, stats.TotalOptimizations)
+	counter(ch, oc.evaluations, stats.TotalEvaluations)
+	gauge(ch, oc.averageTime, stats.AverageTime.Seconds())
+}
+
+// registryCollector exports EnhancedServiceRegistry inventory and discovery statistics
+type registryCollector struct {
+	registry *service.EnhancedServiceRegistry
+	descs    descSet
+
+	services         *prometheus.Desc
+	servicesByHealth *prometheus.Desc
+	nodes            *prometheus.Desc
+	queries          *prometheus.Desc
+	queryDuration    *prometheus.Desc
+	queryCache       *prometheus.Desc
+	registrations    *prometheus.Desc
+	averageResults   *prometheus.Desc
+}
+
+func newRegistryCollector(namespace string, registry *service.EnhancedServiceRegistry) *registryCollector {
+	rc := &registryCollector{registry: registry}
+	rc.services = rc.descs.add(namespace, "registry", "services", "Registered service instances by service type.", "service_type")
+	rc.servicesByHealth = rc.descs.add(namespace, "registry", "services_by_health", "Registered service instances by health status.", "health")
+	rc.nodes = rc.descs.add(namespace, "registry", "nodes", "Nodes hosting at least one service instance.")
+	rc.queries = rc.descs.add(namespace, "discovery", "queries_total", "Discovery queries by result.", "result")
+	rc.queryDuration = rc.descs.add(namespace, "discovery", "query_duration_seconds", "Discovery query latency over the recent history window.")
+	rc.queryCache = rc.descs.add(namespace, "discovery", "cache_total", "Discovery queries by cache outcome.", "outcome")
+	rc.registrations = rc.descs.add(namespace, "discovery", "registrations_total", "Service registrations.")
+	rc.averageResults = rc.descs.add(namespace, "discovery", "average_results", "Average services returned per query.")
+	return rc
+}
+
+func (rc *registryCollector) Describe(ch chan<- *prometheus.Desc) {
+	rc.descs.describe(ch)
+}
+
+func (rc *registryCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := rc.registry.GetRegistryStats()
+
+	for serviceType, count := range stats.ServicesByType {
+		gauge(ch, rc.services, float64(count), serviceType)
+	}
+	for health, count := range stats.HealthCounts {
+		gauge(ch, rc.servicesByHealth, float64(count), healthLabel(health))
+	}
+	gauge(ch, rc.nodes, float64(stats.TotalNodes))
+
+	discovery := stats.Discovery
+	counter(ch, rc.queries, discovery.SuccessfulQueries, "success")
+	counter(ch, rc.queries, discovery.EmptyQueries, "empty")
+	latencySummary(ch, rc.queryDuration, discovery.TotalQueries, discovery.AverageLatency,
+		map[float64]time.Duration{0.5: discovery.P50Latency, 0.9: discovery.P90Latency, 0.99: discovery.P99Latency})
+	counter(ch, rc.queryCache, discovery.CacheHits, "hit")
+	counter(ch, rc.queryCache, discovery.CacheMisses, "miss")
+	counter(ch, rc.registrations, discovery.Registrations)
+	gauge(ch, rc.averageResults, discovery.AverageResults)
+}
+
+// healthLabel returns the label value for a registry health status
+func healthLabel(health service.HealthStatus) string {
+	switch health {
+	case service.HealthHealthy:
+		return "healthy"
+	case service.HealthDegraded:
+		return "degraded"
+	case service.HealthUnhealthy:
+		return "unhealthy"
+	case service.HealthCritical:
+		return "critical"
+	default:
+		return "unknown"
+	}
+}
+
+// transportCollector exports TransportStatistics for one transport, labelled
+// with its name so several transports can be registered side by side
+type transportCollector struct {
+	transport integration.HyperMeshTransport
+	descs     descSet
+
+	connections       *prometheus.Desc
+	activeConnections *prometheus.Desc
+	requests          *prometheus.Desc
+	requestDuration   *prometheus.Desc
+	bytes             *prometheus.Desc
+	errorRate         *prometheus.Desc
+}
+
+func newTransportCollector(namespace, name string, transport integration.HyperMeshTransport) *transportCollector {
+	tc := &transportCollector{transport: transport}
+	labels := prometheus.Labels{"transport": name}
+	tc.connections = tc.descs.addConst(namespace, "transport", "connections_total", "Connections opened by result.", labels, "result")
+	tc.activeConnections = tc.descs.addConst(namespace, "transport", "active_connections", "Currently open connections.", labels)
+	tc.requests = tc.descs.addConst(namespace, "transport", "requests_total", "Requests by result.", labels, "result")
+	tc.requestDuration = tc.descs.addConst(namespace, "transport", "request_duration_seconds", "Request latency over the recent history window.", labels)
+	tc.bytes = tc.descs.addConst(namespace, "transport", "bytes_total", "Bytes transferred by direction.", labels, "direction")
+	tc.errorRate = tc.descs.addConst(namespace, "transport", "error_ratio", "Fraction of failed requests.", labels)
+	return tc
+}
+
+func (tc *transportCollector) Describe(ch chan<- *prometheus.Desc) {
+	tc.descs.describe(ch)
+}
+
+func (tc *transportCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := tc.transport.GetStatistics()
+
+	counter(ch, tc.connections, stats.TotalConnections-stats.FailedConnections, "success")
+	counter(ch, tc.connections, stats.FailedConnections, "failure")
+	gauge(ch, tc.activeConnections, float64(stats.ActiveConnections))
+	counter(ch, tc.requests, stats.SuccessfulRequests, "success")
+	counter(ch, tc.requests, stats.FailedRequests, "failure")
+	latencySummary(ch, tc.requestDuration, stats.TotalRequests, stats.AverageLatency,
+		map[float64]time.Duration{0.5: stats.P50Latency, 0.9: stats.P90Latency, 0.99: stats.P99Latency})
+	counter(ch, tc.bytes, stats.BytesSent, "sent")
+	counter(ch, tc.bytes, stats.BytesReceived, "received")
+	gauge(ch, tc.errorRate, stats.ErrorRate)
+}
+
+// rateLimitCollector exports RateLimiter decisions and bucket state
+type rateLimitCollector struct {
+	limiter *integration.RateLimiter
+	descs   descSet
+
+	decisions *prometheus.Desc
+	requests  *prometheus.Desc
+	tokens    *prometheus.Desc
+	limit     *prometheus.Desc
+}
+
+func newRateLimitCollector(namespace string, limiter *integration.RateLimiter) *rateLimitCollector {
+	rc := &rateLimitCollector{limiter: limiter}
+	rc.decisions = rc.descs.add(namespace, "rate_limit", "decisions_total", "Admission decisions by result.", "result")
+	rc.requests = rc.descs.add(namespace, "rate_limit", "requests_total", "Requests per service or tenant bucket by result.", "scope", "key", "result")
+	rc.tokens = rc.descs.add(namespace, "rate_limit", "tokens", "Tokens currently available in a bucket.", "scope", "key")
+	rc.limit = rc.descs.add(namespace, "rate_limit", "limit_per_second", "Sustained rate of a bucket.", "scope", "key")
+	return rc
+}
+
+func (rc *rateLimitCollector) Describe(ch chan<- *prometheus.Desc) {
+	rc.descs.describe(ch)
+}
+
+func (rc *rateLimitCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := rc.limiter.Stats()
+
+	counter(ch, rc.decisions, stats.Allowed, "allowed")
+	counter(ch, rc.decisions, stats.Limited, "limited")
+	for _, bucket := range stats.Buckets {
+		counter(ch, rc.requests, bucket.Allowed, bucket.Scope, bucket.Key, "allowed")
+		counter(ch, rc.requests, bucket.Limited, bucket.Scope, bucket.Key, "limited")
+		gauge(ch, rc.tokens, bucket.Tokens, bucket.Scope, bucket.Key)
+		gauge(ch, rc.limit, bucket.Limit.Rate, bucket.Scope, bucket.Key)
+	}
+}
+
+// resourceCollector exports the PerformanceMonitor's latest sample. Unlike
+// the Go runtime collector it reads the monitor's cached sample, so scrapes
+// do not stop the world for ReadMemStats.
+type resourceCollector struct {
+	monitor *internal.PerformanceMonitor
+	descs   descSet
+
+	cpu           *prometheus.Desc
+	memory        *prometheus.Desc
+	goroutines    *prometheus.Desc
+	gcCycles      *prometheus.Desc
+	gcPauseTotal  *prometheus.Desc
+	gcPauseMax    *prometheus.Desc
+	gcCPUFraction *prometheus.Desc
+	sampledAt     *prometheus.Desc
+}
+
+func newResourceCollector(namespace string, monitor *internal.PerformanceMonitor) *resourceCollector {
+	rc := &resourceCollector{monitor: monitor}
+	rc.cpu = rc.descs.add(namespace, "resources", "cpu_percent", "CPU usage over the last sampling interval, by scope: process (percent of one core) or system (percent of all cores).", "scope")
+	rc.memory = rc.descs.add(namespace, "resources", "memory_bytes", "Memory usage by kind.", "kind")
+	rc.goroutines = rc.descs.add(namespace, "resources", "goroutines", "Goroutines at the last sample.")
+	rc.gcCycles = rc.descs.add(namespace, "resources", "gc_cycles_total", "Completed garbage collection cycles.")
+	rc.gcPauseTotal = rc.descs.add(namespace, "resources", "gc_pause_seconds_total", "Cumulative garbage collection pause time.")
+	rc.gcPauseMax = rc.descs.add(namespace, "resources", "gc_pause_max_seconds", "Longest garbage collection pause in the last sampling interval.")
+	rc.gcCPUFraction = rc.descs.add(namespace, "resources", "gc_cpu_fraction", "Fraction of CPU time used by garbage collection since start.")
+	rc.sampledAt = rc.descs.add(namespace, "resources", "sample_timestamp_seconds", "Unix time of the last sample.")
+	return rc
+}
+
+func (rc *resourceCollector) Describe(ch chan<- *prometheus.Desc) {
+	rc.descs.describe(ch)
+}
+
+func (rc *resourceCollector) Collect(ch chan<- prometheus.Metric) {
+	usage := rc.monitor.GetResourceUsage()
+	if usage.SampledAt.IsZero() {
+		return
+	}
+
+	gauge(ch, rc.cpu, usage.ProcessCPUPercent, "process")
+	gauge(ch, rc.cpu, usage.SystemCPUPercent, "system")
+	gauge(ch, rc.memory, float64(usage.ResidentMemory), "resident")
+	gauge(ch, rc.memory, float64(usage.HeapAlloc), "heap_alloc")
+	gauge(ch, rc.memory, float64(usage.HeapInUse), "heap_inuse")
+	gauge(ch, rc.memory, float64(usage.SysMemory), "sys")
+	gauge(ch, rc.goroutines, float64(usage.Goroutines))
+	counter(ch, rc.gcCycles, int64(usage.GCCycles))
+	ch <- prometheus.MustNewConstMetric(rc.gcPauseTotal, prometheus.CounterValue, usage.GCPauseTotal.Seconds())
+	gauge(ch, rc.gcPauseMax, usage.MaxGCPause.Seconds())
+	gauge(ch, rc.gcCPUFraction, usage.GCCPUFraction)
+	gauge(ch, rc.sampledAt, float64(usage.SampledAt.UnixNano())/1e9)
+}
+
+// admissionCollector exports route admission decisions and queue state
+type admissionCollector struct {
+	admission *internal.AdmissionController
+	descs     descSet
+
+	inFlight      *prometheus.Desc
+	maxConcurrent *prometheus.Desc
+	queued        *prometheus.Desc
+	admitted      *prometheus.Desc
+	shed          *prometheus.Desc
+	cancelled     *prometheus.Desc
+	waitTotal     *prometheus.Desc
+	waitMax       *prometheus.Desc
+}
+
+func newAdmissionCollector(namespace string, admission *internal.AdmissionController) *admissionCollector {
+	ac := &admissionCollector{admission: admission}
+	ac.inFlight = ac.descs.add(namespace, "admission", "in_flight", "Route lookups being processed.")
+	ac.maxConcurrent = ac.descs.add(namespace, "admission", "max_concurrent", "Route lookups allowed to run at the same time.")
+	ac.queued = ac.descs.add(namespace, "admission", "queued", "Route requests waiting for a slot, by QoS class.", "qos_class")
+	ac.admitted = ac.descs.add(namespace, "admission", "admitted_total", "Route requests admitted, by QoS class.", "qos_class")
+	ac.shed = ac.descs.add(namespace, "admission", "shed_total", "Route requests shed, by QoS class and reason (queue_full, evicted or timeout).", "qos_class", "reason")
+	ac.cancelled = ac.descs.add(namespace, "admission", "cancelled_total", "Route requests cancelled by their caller while queued, by QoS class.", "qos_class")
+	ac.waitTotal = ac.descs.add(namespace, "admission", "wait_seconds_total", "Time admitted route requests spent queued, by QoS class.", "qos_class")
+	ac.waitMax = ac.descs.add(namespace, "admission", "wait_max_seconds", "Longest time an admitted route request spent queued, by QoS class.", "qos_class")
+	return ac
+}
+
+func (ac *admissionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ac.descs.describe(ch)
+}
+
+func (ac *admissionCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := ac.admission.Stats()
+
+	gauge(ch, ac.inFlight, float64(stats.InFlight))
+	gauge(ch, ac.maxConcurrent, float64(stats.MaxConcurrent))
+	for _, class := range stats.Classes {
+		name := class.Class.String()
+		gauge(ch, ac.queued, float64(class.Queued), name)
+		counter(ch, ac.admitted, class.Admitted, name)
+		counter(ch, ac.cancelled, class.Cancelled, name)
+		for _, reason := range []string{internal.ShedQueueFull, internal.ShedEvicted, internal.ShedTimeout} {
+			counter(ch, ac.shed, class.Shed[reason], name, reason)
+		}
+		ch <- prometheus.MustNewConstMetric(ac.waitTotal, prometheus.CounterValue, class.TotalWait.Seconds(), name)
+		gauge(ch, ac.waitMax, class.MaxWait.Seconds(), name)
+	}
+}
+
+// memoryBudgetCollector exports the memory budget, heap in use and the
+// capacity of each consumer and entries the budget evicted from it
+type memoryBudgetCollector struct {
+	budget *internal.MemoryBudget
+	descs  descSet
+
+	limit     *prometheus.Desc
+	heap      *prometheus.Desc
+	scale     *prometheus.Desc
+	capacity  *prometheus.Desc
+	entries   *prometheus.Desc
+	evictions *prometheus.Desc
+}
+
+func newMemoryBudgetCollector(namespace string, budget *internal.MemoryBudget) *memoryBudgetCollector {
+	mc := &memoryBudgetCollector{budget: budget}
+	mc.limit = mc.descs.add(namespace, "memory_budget", "limit_bytes", "Heap the caches are sized to fit; zero when the budget is disabled.")
+	mc.heap = mc.descs.add(namespace, "memory_budget", "heap_inuse_bytes", "Heap in use at the last rebalance.")
+	mc.scale = mc.descs.add(namespace, "memory_budget", "scale", "Fraction of their budgeted capacity the consumers keep.")
+	mc.capacity = mc.descs.add(namespace, "memory_budget", "capacity", "Entries a consumer may hold, by consumer; zero when unbounded.", "consumer")
+	mc.entries = mc.descs.add(namespace, "memory_budget", "entries", "Entries a consumer holds, by consumer.", "consumer")
+	mc.evictions = mc.descs.add(namespace, "memory_budget", "evictions_total", "Entries evicted by shrinking a consumer, by consumer.", "consumer")
+	return mc
+}
+
+func (mc *memoryBudgetCollector) Describe(ch chan<- *prometheus.Desc) {
+	mc.descs.describe(ch)
+}
+
+func (mc *memoryBudgetCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := mc.budget.Stats()
+
+	gauge(ch, mc.limit, float64(stats.Limit))
+	gauge(ch, mc.heap, float64(stats.HeapInUse))
+	gauge(ch, mc.scale, stats.Scale)
+	for _, consumer := range stats.Consumers {
+		gauge(ch, mc.capacity, float64(consumer.Capacity), consumer.Name)
+		gauge(ch, mc.entries, float64(consumer.Entries), consumer.Name)
+		counter(ch, mc.evictions, consumer.Evictions, consumer.Name)
+	}
+}
+
+// brownoutCollector exports whether the coordinator is in brownout, why,
+// and the overload signals it watches
+type brownoutCollector struct {
+	brownout *internal.BrownoutDetector
+	descs    descSet
+
+	active    *prometheus.Desc
+	entered   *prometheus.Desc
+	since     *prometheus.Desc
+	signal    *prometheus.Desc
+	threshold *prometheus.Desc
+}
+
+func newBrownoutCollector(namespace string, brownout *internal.BrownoutDetector) *brownoutCollector {
+	bc := &brownoutCollector{brownout: brownout}
+	bc.active = bc.descs.add(namespace, "brownout", "active", "1 while in brownout, by the signal that triggered it.", "reason")
+	bc.entered = bc.descs.add(namespace, "brownout", "entered_total", "Times brownout was entered.")
+	bc.since = bc.descs.add(namespace, "brownout", "since_timestamp_seconds", "Unix time brownout was last entered.")
+	bc.signal = bc.descs.add(namespace, "brownout", "signal", "Last reading of each overload signal; latency in seconds.", "signal")
+	bc.threshold = bc.descs.add(namespace, "brownout", "threshold", "Reading of each overload signal that triggers brownout; zero when disabled.", "signal")
+	return bc
+}
+
+func (bc *brownoutCollector) Describe(ch chan<- *prometheus.Desc) {
+	bc.descs.describe(ch)
+}
+
+func (bc *brownoutCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := bc.brownout.Stats()
+	config := bc.brownout.Config()
+
+	active := 0.0
+	if stats.Active {
+		active = 1
+	}
+	gauge(ch, bc.active, active, stats.Reason)
+	counter(ch, bc.entered, stats.Entered)
+	if !stats.Since.IsZero() {
+		gauge(ch, bc.since, float64(stats.Since.UnixNano())/1e9)
+	}
+
+	gauge(ch, bc.signal, float64(stats.Signals.QueueDepth), internal.BrownoutQueueDepth)
+	gauge(ch, bc.signal, stats.Signals.CPUPercent, internal.BrownoutCPU)
+	gauge(ch, bc.signal, stats.Signals.P99Latency.Seconds(), internal.BrownoutP99Latency)
+	gauge(ch, bc.threshold, float64(config.QueueDepth), internal.BrownoutQueueDepth)
+	gauge(ch, bc.threshold, config.CPUPercent, internal.BrownoutCPU)
+	gauge(ch, bc.threshold, config.P99Latency.Seconds(), internal.BrownoutP99Latency)
+}
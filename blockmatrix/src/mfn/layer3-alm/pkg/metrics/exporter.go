@@ -0,0 +1,251 @@
+// Package metrics exports Layer 3 ALM statistics as Prometheus metrics
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/integration"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/optimization"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/service"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+// Exporter serves ALM component statistics on a Prometheus /metrics endpoint.
+//
+// Components are registered as collectors that read their statistics at
+// scrape time, so the exporter adds no work to the routing hot path.
+type Exporter struct {
+	config   *ExporterConfig
+	registry *prometheus.Registry
+
+	server   *http.Server
+	listener net.Listener
+
+	logger *zap.Logger
+	mutex  sync.Mutex
+}
+
+// ExporterConfig configures the metrics endpoint
+type ExporterConfig struct {
+	// Address and path the endpoint is served on
+	ListenAddress string
+	Path          string
+
+	// Prefix for every exported metric name
+	Namespace string
+
+	// Include Go runtime and process collectors
+	IncludeRuntimeMetrics bool
+
+	// Bound on a single scrape
+	ScrapeTimeout time.Duration
+}
+
+// NewExporter creates an exporter with its own Prometheus registry
+func NewExporter(config *ExporterConfig, logger *zap.Logger) *Exporter {
+	if config == nil {
+		config = DefaultExporterConfig()
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	registry := prometheus.NewRegistry()
+	if config.IncludeRuntimeMetrics {
+		registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
+
+	return &Exporter{
+		config:   config,
+		registry: registry,
+		logger:   logger,
+	}
+}
+
+// Registry returns the underlying Prometheus registry for custom collectors
+func (e *Exporter) Registry() *prometheus.Registry {
+	return e.registry
+}
+
+// RegisterCoordinator registers collectors for every component of the coordinator
+func (e *Exporter) RegisterCoordinator(coordinator *internal.ALMCoordinator) error {
+	if err := e.RegisterRoutingTable(coordinator.RoutingTable()); err != nil {
+		return err
+	}
+	if err := e.RegisterNetworkGraph(coordinator.NetworkGraph()); err != nil {
+		return err
+	}
+	if err := e.RegisterOptimizer(coordinator.Optimizer()); err != nil {
+		return err
+	}
+	if err := e.RegisterPerformanceMonitor(coordinator.PerformanceMonitor()); err != nil {
+		return err
+	}
+	if err := e.RegisterRouteAdmission(coordinator.RouteAdmission()); err != nil {
+		return err
+	}
+	if err := e.RegisterMemoryBudget(coordinator.MemoryBudget()); err != nil {
+		return err
+	}
+	if err := e.RegisterBrownout(coordinator.Brownout()); err != nil {
+		return err
+	}
+	return e.RegisterServiceRegistry(coordinator.ServiceRegistry())
+}
+
+// RegisterRoutingTable exports routing metrics, route cache and load balancer statistics
+func (e *Exporter) RegisterRoutingTable(routingTable *routing.RoutingTable) error {
+	return e.register("routing table", newRoutingCollector(e.config.Namespace, routingTable))
+}
+
+// RegisterNetworkGraph exports topology and path cache statistics
+func (e *Exporter) RegisterNetworkGraph(networkGraph *graph.NetworkGraph) error {
+	return e.register("network graph", newGraphCollector(e.config.Namespace, networkGraph))
+}
+
+// RegisterOptimizer exports multi-objective optimizer statistics
+func (e *Exporter) RegisterOptimizer(optimizer *optimization.MultiObjectiveOptimizer) error {
+	return e.register("optimizer", newOptimizerCollector(e.config.Namespace, optimizer))
+}
+
+// RegisterServiceRegistry exports registry inventory and discovery statistics
+func (e *Exporter) RegisterServiceRegistry(registry *service.EnhancedServiceRegistry) error {
+	return e.register("service registry", newRegistryCollector(e.config.Namespace, registry))
+}
+
+// RegisterPerformanceMonitor exports sampled CPU, memory, goroutine and GC statistics
+func (e *Exporter) RegisterPerformanceMonitor(monitor *internal.PerformanceMonitor) error {
+	return e.register("performance monitor", newResourceCollector(e.config.Namespace, monitor))
+}
+
+// RegisterRouteAdmission exports route admission decisions and queue depth by QoS class
+func (e *Exporter) RegisterRouteAdmission(admission *internal.AdmissionController) error {
+	return e.register("route admission", newAdmissionCollector(e.config.Namespace, admission))
+}
+
+// RegisterMemoryBudget exports the memory budget and the capacities and
+// evictions of the caches under it
+func (e *Exporter) RegisterMemoryBudget(budget *internal.MemoryBudget) error {
+	return e.register("memory budget", newMemoryBudgetCollector(e.config.Namespace, budget))
+}
+
+// RegisterBrownout exports the brownout state and the overload signals that
+// trigger it
+func (e *Exporter) RegisterBrownout(brownout *internal.BrownoutDetector) error {
+	return e.register("brownout", newBrownoutCollector(e.config.Namespace, brownout))
+}
+
+// RegisterTransport exports transport statistics labelled with name
+func (e *Exporter) RegisterTransport(name string, transport integration.HyperMeshTransport) error {
+	return e.register("transport "+name, newTransportCollector(e.config.Namespace, name, transport))
+}
+
+// RegisterRateLimiter exports integration rate limiter decisions and bucket state
+func (e *Exporter) RegisterRateLimiter(limiter *integration.RateLimiter) error {
+	return e.register("rate limiter", newRateLimitCollector(e.config.Namespace, limiter))
+}
+
+// Handler returns the HTTP handler serving the exporter's registry
+func (e *Exporter) Handler() http.Handler {
+	return promhttp.HandlerFor(e.registry, promhttp.HandlerOpts{
+		ErrorLog:      zap.NewStdLog(e.logger),
+		ErrorHandling: promhttp.ContinueOnError,
+		Timeout:       e.config.ScrapeTimeout,
+	})
+}
+
+// Start serves the metrics endpoint in the background
+func (e *Exporter) Start() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.server != nil {
+		return fmt.Errorf("metrics exporter is already running")
+	}
+
+	listener, err := net.Listen("tcp", e.config.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", e.config.ListenAddress, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(e.config.Path, e.Handler())
+
+	e.listener = listener
+	e.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func(server *http.Server) {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			e.logger.Error("Metrics endpoint stopped", zap.Error(err))
+		}
+	}(e.server)
+
+	e.logger.Info("Metrics endpoint started",
+		zap.String("address", listener.Addr().String()),
+		zap.String("path", e.config.Path),
+	)
+
+	return nil
+}
+
+// Address returns the address the endpoint is listening on, or "" when stopped
+func (e *Exporter) Address() string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.listener == nil {
+		return ""
+	}
+	return e.listener.Addr().String()
+}
+
+// Shutdown stops the metrics endpoint
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.mutex.Lock()
+	server := e.server
+	e.server = nil
+	e.listener = nil
+	e.mutex.Unlock()
+
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
+
+func (e *Exporter) register(component string, collector prometheus.Collector) error {
+	if err := e.registry.Register(collector); err != nil {
+		return fmt.Errorf("failed to register %s metrics: %w", component, err)
+	}
+	return nil
+}
+
+// DefaultExporterConfig returns default exporter configuration
+func DefaultExporterConfig() *ExporterConfig {
+	return &ExporterConfig{
+		ListenAddress:         ":9464",
+		Path:                  "/metrics",
+		Namespace:             "alm",
+		IncludeRuntimeMetrics: true,
+		ScrapeTimeout:         10 * time.Second,
+	}
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/integration"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/service"
+)
+
+func testExporter() *Exporter {
+	config := DefaultExporterConfig()
+	config.ListenAddress = "127.0.0.1:0"
+	config.IncludeRuntimeMetrics = false
+	return NewExporter(config, nil)
+}
+
+// scrape returns the exposition text served by handler
+func scrape(t *testing.T, handler http.Handler) string {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("scrape returned %d: %s", recorder.Code, recorder.Body.String())
+	}
+	return recorder.Body.String()
+}
+
+// assertSample fails unless the exposition has a sample line reading exactly want
+func assertSample(t *testing.T, exposition, want string) {
+	t.Helper()
+
+	for _, line := range strings.Split(exposition, "\n") {
+		if line == want {
+			return
+		}
+	}
+	t.Errorf("no sample %q in the scrape", want)
+}
+
+func TestExporterRegisterCoordinator(t *testing.T) {
+	coordinator, err := internal.NewALMCoordinator(internal.DefaultALMConfig(), nil)
+	if err != nil {
+		t.Fatalf("NewALMCoordinator: %v", err)
+	}
+	for _, id := range []int64{1, 2} {
+		if err := coordinator.NetworkGraph().AddNode(&graph.NetworkNode{ID: id}); err != nil {
+			t.Fatalf("AddNode(%d): %v", id, err)
+		}
+	}
+	if err := coordinator.NetworkGraph().AddEdge(&graph.NetworkEdge{From: 1, To: 2, Weight: 1}); err != nil {
+		t.Fatalf("AddEdge: %v", err)
+	}
+	if err := coordinator.ServiceRegistry().RegisterService(&service.ServiceInstance{ID: "web-1", Name: "web", NodeID: 1, ServiceType: "http"}); err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+
+	exporter := testExporter()
+	if err := exporter.RegisterCoordinator(coordinator); err != nil {
+		t.Fatalf("RegisterCoordinator: %v", err)
+	}
+
+	exposition := scrape(t, exporter.Handler())
+	assertSample(t, exposition, "alm_graph_nodes 2")
+	assertSample(t, exposition, "alm_graph_edges 1")
+	assertSample(t, exposition, `alm_registry_services{service_type="http"} 1`)
+	assertSample(t, exposition, `alm_registry_services_by_health{health="healthy"} 1`)
+	assertSample(t, exposition, `alm_routing_lookups_total{result="success"} 0`)
+	if strings.Contains(exposition, "go_goroutines") {
+		t.Error("runtime metrics exported when disabled")
+	}
+
+	// Each component can be registered only once
+	if err := exporter.RegisterNetworkGraph(coordinator.NetworkGraph()); err == nil || !strings.Contains(err.Error(), "network graph") {
+		t.Errorf("second RegisterNetworkGraph = %v, want an error naming the component", err)
+	}
+}
+
+func TestExporterRateLimiterAndTransport(t *testing.T) {
+	limiter := integration.NewRateLimiter(&integration.RateLimitConfig{Service: integration.RateLimit{Rate: 1, Burst: 1}})
+	limiter.Allow("", "orders")
+	limiter.Allow("", "orders")
+
+	exporter := testExporter()
+	if err := exporter.RegisterRateLimiter(limiter); err != nil {
+		t.Fatalf("RegisterRateLimiter: %v", err)
+	}
+	if err := exporter.RegisterTransport("quic", &integration.MockHyperMeshTransport{}); err != nil {
+		t.Fatalf("RegisterTransport(quic): %v", err)
+	}
+	if err := exporter.RegisterTransport("grpc", &integration.MockHyperMeshTransport{}); err != nil {
+		t.Fatalf("RegisterTransport(grpc): %v", err)
+	}
+
+	exposition := scrape(t, exporter.Handler())
+	assertSample(t, exposition, `alm_rate_limit_decisions_total{result="allowed"} 1`)
+	assertSample(t, exposition, `alm_rate_limit_decisions_total{result="limited"} 1`)
+	assertSample(t, exposition, `alm_rate_limit_requests_total{key="orders",result="limited",scope="service"} 1`)
+	assertSample(t, exposition, `alm_rate_limit_limit_per_second{key="orders",scope="service"} 1`)
+	for _, transport := range []string{"quic", "grpc"} {
+		if !strings.Contains(exposition, `alm_transport_active_connections{transport="`+transport+`"}`) {
+			t.Errorf("no transport metrics labelled %s", transport)
+		}
+	}
+}
+
+func TestExporterServesEndpoint(t *testing.T) {
+	exporter := testExporter()
+	if exporter.Address() != "" {
+		t.Errorf("Address() = %q before Start", exporter.Address())
+	}
+	if err := exporter.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer exporter.Shutdown(context.Background())
+
+	if err := exporter.Start(); err == nil {
+		t.Error("second Start succeeded")
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	response, err := client.Get("http://" + exporter.Address() + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer response.Body.Close()
+	if _, err := io.ReadAll(response.Body); err != nil || response.StatusCode != http.StatusOK {
+		t.Errorf("GET /metrics = %d, %v", response.StatusCode, err)
+	}
+
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if exporter.Address() != "" {
+		t.Errorf("Address() = %q after Shutdown", exporter.Address())
+	}
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Errorf("second Shutdown: %v", err)
+	}
+}
@@ -10,8 +10,13 @@ import (
 	"time"
 
 	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// tracer traces optimization runs
+var tracer = tracing.NewTracer("optimization")
+
 // MultiObjectiveOptimizer implements advanced multi-objective optimization algorithms
 type MultiObjectiveOptimizer struct {
 	// Configuration
@@ -78,6 +83,44 @@ type RoutingSolution struct {
 	HopCount         int
 }
 
+// solutionPool recycles the solutions each generation discards, so deep
+// optimization reuses their path and objective storage instead of leaving
+// thousands of copies per request to the garbage collector
+var solutionPool = sync.Pool{
+	New: func() interface{} {
+		return &RoutingSolution{ObjectiveValues: make(map[string]float64)}
+	},
+}
+
+// acquireSolution returns a reset solution from the pool
+func acquireSolution() *RoutingSolution {
+	return solutionPool.Get().(*RoutingSolution)
+}
+
+// releaseSolution resets solution and returns it to the pool. Nothing may
+// reference it afterwards.
+func releaseSolution(solution *RoutingSolution) {
+	solution.reset()
+	solutionPool.Put(solution)
+}
+
+// reset clears the solution for reuse, keeping its path capacity and
+// objective map
+func (rs *RoutingSolution) reset() {
+	for i := range rs.Path {
+		rs.Path[i] = nil
+	}
+	path, objectiveValues := rs.Path[:0], rs.ObjectiveValues
+	if objectiveValues == nil {
+		objectiveValues = make(map[string]float64)
+	}
+	for name := range objectiveValues {
+		delete(objectiveValues, name)
+	}
+	
+	*rs = RoutingSolution{Path: path, ObjectiveValues: objectiveValues}
+}
+
 // OptimizationRequest defines parameters for multi-objective optimization
 type OptimizationRequest struct {
 	SourceID       int64
@@ -133,7 +176,8 @@ func NewMultiObjectiveOptimizer(config *OptimizerConfig) *MultiObjectiveOptimize
 	}
 }
 
-// AddObjective adds an objective function to the optimizer
+// AddObjective adds an objective function to those optimized when a request
+// does not name its own
 func (moo *MultiObjectiveOptimizer) AddObjective(objective ObjectiveFunction) {
 	moo.mutex.Lock()
 	defer moo.mutex.Unlock()
@@ -141,10 +185,35 @@ func (moo *MultiObjectiveOptimizer) AddObjective(objective ObjectiveFunction) {
 	moo.objectives = append(moo.objectives, objective)
 }
 
+// Config returns a copy of the optimizer configuration
+func (moo *MultiObjectiveOptimizer) Config() OptimizerConfig {
+	moo.mutex.RLock()
+	defer moo.mutex.RUnlock()
+	
+	return *moo.config
+}
+
+// UpdateConfig applies a new optimizer configuration, such as objective
+// weights, to subsequent optimizations
+func (moo *MultiObjectiveOptimizer) UpdateConfig(config OptimizerConfig) {
+	moo.mutex.Lock()
+	defer moo.mutex.Unlock()
+	
+	moo.config = &config
+}
+
 // Optimize performs multi-objective optimization to find Pareto-optimal solutions
-func (moo *MultiObjectiveOptimizer) Optimize(request OptimizationRequest) (*OptimizationResult, error) {
+func (moo *MultiObjectiveOptimizer) Optimize(request OptimizationRequest) (result *OptimizationResult, err error) {
 	startTime := time.Now()
 	
+	ctx, span := tracer.Start(request.Context, "optimization.Optimize",
+		attribute.Int64("alm.source_id", request.SourceID),
+		attribute.Int64("alm.target_id", request.TargetID),
+		attribute.Int("alm.max_solutions", request.MaxSolutions),
+	)
+	request.Context = ctx
+	defer func() { tracing.End(span, err) }()
+	
 	// Validate request
 	if err := moo.validateRequest(request); err != nil {
 		return nil, fmt.Errorf("invalid optimization request: %w", err)
@@ -187,12 +256,17 @@ func (moo *MultiObjectiveOptimizer) Optimize(request OptimizationRequest) (*Opti
 		// Crossover and mutation
 		offspring := moo.crossoverAndMutation(newPopulation, request)
 		
-		// Combine parent and offspring
-		combined := append(population, offspring...)
-		population = combined
+		// Combine the selected parents and their offspring
+		combined := make([]*RoutingSolution, 0, len(newPopulation)+len(offspring))
+		combined = append(combined, newPopulation...)
+		combined = append(combined, offspring...)
 		
 		// Check convergence
 		currentHyperVolume := moo.calculateHyperVolume(fronts[0], objectives)
+		
+		// Recycle the solutions selection dropped
+		releaseDropped(population, newPopulation)
+		population = combined
 		if math.Abs(currentHyperVolume-previousHyperVolume) < moo.config.ConvergenceThreshold {
 			stagnationCounter++
 			if stagnationCounter >= moo.config.StagnationLimit {
@@ -209,6 +283,7 @@ func (moo *MultiObjectiveOptimizer) Optimize(request OptimizationRequest) (*Opti
 	// Extract final Pareto front
 	finalFronts := moo.nonDominatedSorting(population)
 	paretoSolutions := finalFronts[0]
+	releaseDropped(population, paretoSolutions)
 	
 	// Select best compromise solution using TOPSIS
 	bestCompromise := moo.selectBestCompromise(paretoSolutions, objectives)
@@ -218,7 +293,7 @@ func (moo *MultiObjectiveOptimizer) Optimize(request OptimizationRequest) (*Opti
 	spacing := moo.calculateSpacing(paretoSolutions, objectives)
 	spread := moo.calculateSpread(paretoSolutions, objectives)
 	
-	result := &OptimizationResult{
+	result = &OptimizationResult{
 		ParetoSolutions:  paretoSolutions,
 		BestCompromise:   bestCompromise,
 		Generations:      generation,
@@ -233,9 +308,20 @@ func (moo *MultiObjectiveOptimizer) Optimize(request OptimizationRequest) (*Opti
 	// Update metrics
 	moo.optimizationMetrics.RecordOptimization(result)
 	
+	span.SetAttributes(
+		attribute.Int("alm.generations", result.Generations),
+		attribute.Int("alm.pareto_solutions", len(paretoSolutions)),
+		attribute.Float64("alm.hypervolume", hyperVolume),
+	)
+	
 	return result, nil
 }
 
+// GetOptimizationStats returns optimizer performance statistics
+func (moo *MultiObjectiveOptimizer) GetOptimizationStats() OptimizationStats {
+	return moo.optimizationMetrics.GetStats()
+}
+
 // nonDominatedSorting implements the non-dominated sorting algorithm
 func (moo *MultiObjectiveOptimizer) nonDominatedSorting(population []*RoutingSolution) [][]*RoutingSolution {
 	fronts := make([][]*RoutingSolution, 0)
@@ -425,13 +511,18 @@ func (co *CostObjective) IsMinimizing() bool { return true }
 func (co *CostObjective) Weight() float64 { return co.weight }
 
 // getDefaultObjectives returns the standard set of optimization objectives
+// followed by any added with AddObjective
 func (moo *MultiObjectiveOptimizer) getDefaultObjectives() []ObjectiveFunction {
-	return []ObjectiveFunction{
+	moo.mutex.RLock()
+	defer moo.mutex.RUnlock()
+	
+	objectives := []ObjectiveFunction{
 		&LatencyObjective{weight: moo.config.LatencyWeight},
 		&ThroughputObjective{weight: moo.config.ThroughputWeight},
 		&ReliabilityObjective{weight: moo.config.ReliabilityWeight},
 		&CostObjective{weight: moo.config.CostWeight},
 	}
+	return append(objectives, moo.objectives...)
 }
 
 // DefaultOptimizerConfig returns default optimizer configuration
@@ -466,6 +557,27 @@ func NewOptimizationMetrics() *OptimizationMetrics {
 	return &OptimizationMetrics{}
 }
 
+// OptimizationStats is a snapshot of optimizer performance
+type OptimizationStats struct {
+	TotalOptimizations int64
+	TotalEvaluations   int64
+	CacheHits          int64
+	AverageTime        time.Duration
+}
+
+// GetStats returns a snapshot of optimizer performance
+func (om *OptimizationMetrics) GetStats() OptimizationStats {
+	om.mutex.Lock()
+	defer om.mutex.Unlock()
+	
+	return OptimizationStats{
+		TotalOptimizations: om.totalOptimizations,
+		TotalEvaluations:   om.totalEvaluations,
+		CacheHits:          om.cacheHits,
+		AverageTime:        om.averageTime,
+	}
+}
+
 func (om *OptimizationMetrics) RecordOptimization(result *OptimizationResult) {
 	om.mutex.Lock()
 	defer om.mutex.Unlock()
@@ -525,7 +637,12 @@ func (moo *MultiObjectiveOptimizer) evaluatePopulation(population []*RoutingSolu
 
 // evaluateSolution evaluates a single solution against all objectives
 func (moo *MultiObjectiveOptimizer) evaluateSolution(solution *RoutingSolution, objectives []ObjectiveFunction, constraints []OptimizationConstraint) {
-	solution.ObjectiveValues = make(map[string]float64)
+	if solution.ObjectiveValues == nil {
+		solution.ObjectiveValues = make(map[string]float64)
+	}
+	for name := range solution.ObjectiveValues {
+		delete(solution.ObjectiveValues, name)
+	}
 	
 	// Calculate objective values
 	totalFitness := 0.0
@@ -777,15 +894,13 @@ func (moo *MultiObjectiveOptimizer) selectBestCompromise(solutions []*RoutingSol
 func (moo *MultiObjectiveOptimizer) generateRandomSolution(request OptimizationRequest) *RoutingSolution {
 	// This would generate a random path from source to target
 	// For now, return a basic solution
-	return &RoutingSolution{
-		Path:            make([]*graph.NetworkNode, 0),
-		ObjectiveValues: make(map[string]float64),
-		TotalLatency:    time.Duration(1000 + moo.randomInt(5000)) * time.Microsecond,
-		MinThroughput:   100.0 + moo.randomFloat()*900.0,
-		AvgReliability:  0.5 + moo.randomFloat()*0.5,
-		TotalCost:       10.0 + moo.randomFloat()*90.0,
-		HopCount:        2 + moo.randomInt(8),
-	}
+	solution := acquireSolution()
+	solution.TotalLatency = time.Duration(1000 + moo.randomInt(5000)) * time.Microsecond
+	solution.MinThroughput = 100.0 + moo.randomFloat()*900.0
+	solution.AvgReliability = 0.5 + moo.randomFloat()*0.5
+	solution.TotalCost = 10.0 + moo.randomFloat()*90.0
+	solution.HopCount = 2 + moo.randomInt(8)
+	return solution
 }
 
 func (moo *MultiObjectiveOptimizer) sortByCrowdingDistance(front []*RoutingSolution) []*RoutingSolution {
@@ -844,21 +959,12 @@ func (moo *MultiObjectiveOptimizer) mutate(solution *RoutingSolution, request Op
 }
 
 func (moo *MultiObjectiveOptimizer) copySolution(original *RoutingSolution) *RoutingSolution {
-	solutionCopy := &RoutingSolution{
-		Path:              make([]*graph.NetworkNode, len(original.Path)),
-		ObjectiveValues:   make(map[string]float64),
-		Fitness:          original.Fitness,
-		DominationRank:   original.DominationRank,
-		CrowdingDistance: original.CrowdingDistance,
-		TotalLatency:     original.TotalLatency,
-		MinThroughput:    original.MinThroughput,
-		AvgReliability:   original.AvgReliability,
-		TotalCost:        original.TotalCost,
-		HopCount:         original.HopCount,
-	}
-	
-	copy(solutionCopy.Path, original.Path)
+	solutionCopy := acquireSolution()
+	path, objectiveValues := solutionCopy.Path, solutionCopy.ObjectiveValues
+	*solutionCopy = *original
 	
+	solutionCopy.Path = append(path, original.Path...)
+	solutionCopy.ObjectiveValues = objectiveValues
 	for k, v := range original.ObjectiveValues {
 		solutionCopy.ObjectiveValues[k] = v
 	}
@@ -866,6 +972,20 @@ func (moo *MultiObjectiveOptimizer) copySolution(original *RoutingSolution) *Rou
 	return solutionCopy
 }
 
+// releaseDropped returns the solutions of population not in kept to the pool
+func releaseDropped(population, kept []*RoutingSolution) {
+	keep := make(map[*RoutingSolution]bool, len(kept))
+	for _, solution := range kept {
+		keep[solution] = true
+	}
+	for _, solution := range population {
+		if !keep[solution] {
+			keep[solution] = true // release each solution once
+			releaseSolution(solution)
+		}
+	}
+}
+
 func (moo *MultiObjectiveOptimizer) calculateObjectiveSpaceDistance(sol1, sol2 *RoutingSolution, objectives []ObjectiveFunction) float64 {
 	distance := 0.0
 	
@@ -0,0 +1,51 @@
+// Package optimization implements micro-benchmarks of optimizer generations
+package optimization
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+// BenchmarkOptimize measures optimization runs of a fixed number of
+// generations, reporting the time each generation takes and the garbage
+// collector pause time per run. Convergence is disabled so every run evolves
+// all its generations.
+func BenchmarkOptimize(b *testing.B) {
+	for _, generations := range []int{1, 5} {
+		b.Run(fmt.Sprintf("generations=%d", generations), func(b *testing.B) {
+			config := DefaultOptimizerConfig()
+			config.MaxGenerations = generations
+			config.ConvergenceThreshold = -1
+			moo := NewMultiObjectiveOptimizer(config)
+
+			request := OptimizationRequest{
+				SourceID:     1,
+				TargetID:     2,
+				MaxSolutions: 10,
+				Context:      context.Background(),
+			}
+
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				result, err := moo.Optimize(request)
+				if err != nil {
+					b.Fatalf("optimization failed: %v", err)
+				}
+				if result.Generations != generations {
+					b.Fatalf("ran %d generations, expected %d", result.Generations, generations)
+				}
+			}
+			b.StopTimer()
+			runtime.ReadMemStats(&after)
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*generations), "ns/generation")
+			b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(b.N), "gc-pause-ns/op")
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package optimization
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+func TestOptimizeKeepsReturnedSolutions(t *testing.T) {
+	config := DefaultOptimizerConfig()
+	config.MaxGenerations = 5
+	config.ConvergenceThreshold = -1
+	moo := NewMultiObjectiveOptimizer(config)
+	request := OptimizationRequest{SourceID: 1, TargetID: 2, Context: context.Background()}
+
+	result, err := moo.Optimize(request)
+	if err != nil {
+		t.Fatalf("Optimize: %v", err)
+	}
+	if len(result.ParetoSolutions) == 0 || result.BestCompromise == nil {
+		t.Fatalf("no solutions returned: %+v", result)
+	}
+
+	type snapshot struct {
+		latency    float64
+		objectives int
+	}
+	before := make([]snapshot, len(result.ParetoSolutions))
+	seen := make(map[*RoutingSolution]bool)
+	for i, solution := range result.ParetoSolutions {
+		if seen[solution] {
+			t.Fatalf("solution %d returned twice", i)
+		}
+		seen[solution] = true
+		before[i] = snapshot{solution.ObjectiveValues["latency"], len(solution.ObjectiveValues)}
+		if before[i].objectives != len(moo.getDefaultObjectives()) {
+			t.Errorf("solution %d has %d objective values", i, before[i].objectives)
+		}
+	}
+
+	// A second run recycles the first run's dropped solutions, never the
+	// returned ones
+	if _, err := moo.Optimize(request); err != nil {
+		t.Fatalf("Optimize: %v", err)
+	}
+	for i, solution := range result.ParetoSolutions {
+		if got := (snapshot{solution.ObjectiveValues["latency"], len(solution.ObjectiveValues)}); got != before[i] {
+			t.Errorf("solution %d changed from %+v to %+v after another run", i, before[i], got)
+		}
+	}
+}
+
+func TestSolutionReset(t *testing.T) {
+	solution := &RoutingSolution{
+		Path:            make([]*graph.NetworkNode, 3),
+		ObjectiveValues: map[string]float64{"latency": 1},
+		Fitness:         0.5,
+		HopCount:        3,
+	}
+	solution.reset()
+
+	if len(solution.Path) != 0 || cap(solution.Path) != 3 {
+		t.Errorf("path has length %d and capacity %d after reset", len(solution.Path), cap(solution.Path))
+	}
+	if len(solution.ObjectiveValues) != 0 || solution.Fitness != 0 || solution.HopCount != 0 {
+		t.Errorf("solution not cleared: %+v", solution)
+	}
+}
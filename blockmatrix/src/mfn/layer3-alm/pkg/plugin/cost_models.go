@@ -0,0 +1,81 @@
+// Package plugin implements the built-in edge cost models
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+// egressRuleSeparator separates the source and destination of an egress
+// rule option, as in "aws/us-east-1>gcp"
+const egressRuleSeparator = ">"
+
+func init() {
+	// Options: link_cost, the cost of every edge
+	RegisterCostModel("flat", func(options Options) (graph.CostModel, error) {
+		linkCost, err := priceOption(options, "link_cost")
+		if err != nil {
+			return nil, err
+		}
+		return graph.FlatCostModel{LinkCost: linkCost}, nil
+	})
+
+	// Options: link_cost, intra_region and default prices, and rules keyed
+	// "from>to", such as "aws>*": "0.09" and "aws/us-east-1>aws": "0.02",
+	// with ties between equally specific rules broken in key order
+	RegisterCostModel("egress", func(options Options) (graph.CostModel, error) {
+		model := &graph.EgressCostModel{}
+		var err error
+		for key, price := range map[string]*float64{
+			"link_cost":    &model.LinkCost,
+			"intra_region": &model.IntraRegion,
+			"default":      &model.Default,
+		} {
+			if *price, err = priceOption(options, key); err != nil {
+				return nil, err
+			}
+		}
+
+		keys := make([]string, 0, len(options))
+		for key := range options {
+			if strings.Contains(key, egressRuleSeparator) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			from, to, _ := strings.Cut(key, egressRuleSeparator)
+			price, err := priceOption(options, key)
+			if err != nil {
+				return nil, err
+			}
+			model.Rules = append(model.Rules, graph.EgressRule{
+				From:       strings.TrimSpace(from),
+				To:         strings.TrimSpace(to),
+				PricePerGB: price,
+			})
+		}
+
+		if err := model.Validate(); err != nil {
+			return nil, err
+		}
+		return model, nil
+	})
+}
+
+// priceOption parses the non-negative price in options[key], zero if unset
+func priceOption(options Options, key string) (float64, error) {
+	value, ok := options[key]
+	if !ok {
+		return 0, nil
+	}
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil || price < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative price, got %q", key, value)
+	}
+	return price, nil
+}
@@ -0,0 +1,248 @@
+// Package plugin implements health probing of registry services with pluggable probes
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/service"
+	"go.uber.org/zap"
+)
+
+// HealthProbe checks the health of a service instance
+type HealthProbe interface {
+	Name() string
+
+	// Probe returns the instance's health. An error marks the instance
+	// unhealthy.
+	Probe(ctx context.Context, instance *service.ServiceInstance) (service.HealthMetrics, error)
+}
+
+// ProbeRunnerConfig configures a ProbeRunner
+type ProbeRunnerConfig struct {
+	Interval time.Duration
+
+	// Bound on a single probe
+	Timeout time.Duration
+
+	// Probes run at the same time
+	Concurrency int
+}
+
+// ProbeRunnerStats reports probe outcomes
+type ProbeRunnerStats struct {
+	Rounds    int64
+	Probes    int64
+	Failures  int64
+	LastRound time.Time
+}
+
+// ProbeRunner probes registry services every interval and reports the
+// results with UpdateServiceHealth. Each service type is probed by the probe
+// set for it, or by the default probe; services with neither are left to
+// report their own health.
+type ProbeRunner struct {
+	registry *service.EnhancedServiceRegistry
+	config   *ProbeRunnerConfig
+	logger   *zap.Logger
+
+	probes       map[string]HealthProbe
+	defaultProbe HealthProbe
+
+	rounds    atomic.Int64
+	probed    atomic.Int64
+	failures  atomic.Int64
+	lastRound atomic.Int64
+
+	mutex sync.RWMutex
+}
+
+// NewProbeRunner creates a probe runner for registry
+func NewProbeRunner(registry *service.EnhancedServiceRegistry, config *ProbeRunnerConfig, logger *zap.Logger) *ProbeRunner {
+	if config == nil {
+		config = DefaultProbeRunnerConfig()
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &ProbeRunner{
+		registry: registry,
+		config:   config,
+		logger:   logger,
+		probes:   make(map[string]HealthProbe),
+	}
+}
+
+// SetProbe probes services of serviceType with probe, or with the default
+// probe if probe is nil
+func (pr *ProbeRunner) SetProbe(serviceType string, probe HealthProbe) {
+	pr.mutex.Lock()
+	defer pr.mutex.Unlock()
+
+	if probe == nil {
+		delete(pr.probes, serviceType)
+		return
+	}
+	pr.probes[serviceType] = probe
+}
+
+// SetDefaultProbe probes services without a type-specific probe with probe;
+// nil disables default probing
+func (pr *ProbeRunner) SetDefaultProbe(probe HealthProbe) {
+	pr.mutex.Lock()
+	defer pr.mutex.Unlock()
+
+	pr.defaultProbe = probe
+}
+
+// Run probes every interval until ctx is done
+func (pr *ProbeRunner) Run(ctx context.Context) {
+	ticker := time.NewTicker(pr.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pr.ProbeAll(ctx)
+		}
+	}
+}
+
+// ProbeAll probes every registered service that has a probe and waits for
+// the results to be reported
+func (pr *ProbeRunner) ProbeAll(ctx context.Context) {
+	concurrency := pr.config.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for _, instance := range pr.registry.Instances() {
+		probe := pr.probeFor(instance.ServiceType)
+		if probe == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case slots <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(instance *service.ServiceInstance) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			pr.probe(ctx, probe, instance)
+		}(instance)
+	}
+	wg.Wait()
+
+	pr.rounds.Add(1)
+	pr.lastRound.Store(time.Now().UnixNano())
+}
+
+// Stats returns probe outcomes so far
+func (pr *ProbeRunner) Stats() ProbeRunnerStats {
+	stats := ProbeRunnerStats{
+		Rounds:   pr.rounds.Load(),
+		Probes:   pr.probed.Load(),
+		Failures: pr.failures.Load(),
+	}
+	if last := pr.lastRound.Load(); last != 0 {
+		stats.LastRound = time.Unix(0, last)
+	}
+	return stats
+}
+
+func (pr *ProbeRunner) probeFor(serviceType string) HealthProbe {
+	pr.mutex.RLock()
+	defer pr.mutex.RUnlock()
+
+	if probe, ok := pr.probes[serviceType]; ok {
+		return probe
+	}
+	return pr.defaultProbe
+}
+
+func (pr *ProbeRunner) probe(ctx context.Context, probe HealthProbe, instance *service.ServiceInstance) {
+	ctx, cancel := context.WithTimeout(ctx, pr.config.Timeout)
+	defer cancel()
+
+	startTime := time.Now()
+	health, err := probe.Probe(ctx, instance)
+	pr.probed.Add(1)
+	if err != nil {
+		pr.failures.Add(1)
+		pr.logger.Debug("Health probe failed",
+			zap.String("probe", probe.Name()),
+			zap.String("service_id", instance.ID),
+			zap.Error(err),
+		)
+		health = service.HealthMetrics{ResponseTime: time.Since(startTime), ErrorRate: 1}
+	}
+	if health.Timestamp.IsZero() {
+		health.Timestamp = time.Now()
+	}
+
+	// The instance may have been removed since the round started
+	if err := pr.registry.UpdateServiceHealth(instance.ID, health); err != nil {
+		pr.logger.Debug("Failed to report probe result", zap.String("service_id", instance.ID), zap.Error(err))
+	}
+}
+
+// TCPProbe reports a service healthy if a TCP connection to its address and
+// port succeeds, with the connect time as its response time
+type TCPProbe struct {
+	dialer net.Dialer
+}
+
+// Name returns "tcp"
+func (tp *TCPProbe) Name() string { return "tcp" }
+
+// Probe dials the instance
+func (tp *TCPProbe) Probe(ctx context.Context, instance *service.ServiceInstance) (service.HealthMetrics, error) {
+	if instance.Address == "" || instance.Port <= 0 {
+		return service.HealthMetrics{}, fmt.Errorf("service %s has no address to probe", instance.ID)
+	}
+
+	startTime := time.Now()
+	conn, err := tp.dialer.DialContext(ctx, "tcp", net.JoinHostPort(instance.Address, strconv.Itoa(instance.Port)))
+	if err != nil {
+		return service.HealthMetrics{}, err
+	}
+	conn.Close()
+
+	return service.HealthMetrics{
+		Score:        1.0,
+		ResponseTime: time.Since(startTime),
+		Timestamp:    time.Now(),
+	}, nil
+}
+
+func init() {
+	RegisterHealthProbe("tcp", func(options Options) (HealthProbe, error) {
+		return &TCPProbe{}, nil
+	})
+}
+
+// DefaultProbeRunnerConfig returns default probe runner configuration
+func DefaultProbeRunnerConfig() *ProbeRunnerConfig {
+	return &ProbeRunnerConfig{
+		Interval:    30 * time.Second,
+		Timeout:     5 * time.Second,
+		Concurrency: 16,
+	}
+}
@@ -0,0 +1,122 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/service"
+)
+
+// scoreProbe reports every instance with a fixed score, or fails with err
+type scoreProbe struct {
+	score  float64
+	err    error
+	probed atomic.Int32
+}
+
+func (sp *scoreProbe) Name() string { return "score" }
+
+func (sp *scoreProbe) Probe(ctx context.Context, instance *service.ServiceInstance) (service.HealthMetrics, error) {
+	sp.probed.Add(1)
+	if sp.err != nil {
+		return service.HealthMetrics{}, sp.err
+	}
+	return service.HealthMetrics{Score: sp.score}, nil
+}
+
+func newProbeRegistry(t *testing.T, instances ...*service.ServiceInstance) *service.EnhancedServiceRegistry {
+	t.Helper()
+
+	registry := service.NewEnhancedServiceRegistry(graph.NewNetworkGraph(10), nil, service.DefaultRegistryConfig())
+	t.Cleanup(registry.Close)
+	for _, instance := range instances {
+		if err := registry.RegisterService(instance); err != nil {
+			t.Fatalf("RegisterService(%s): %v", instance.ID, err)
+		}
+	}
+	return registry
+}
+
+func TestProbeRunnerUsesProbePerServiceType(t *testing.T) {
+	registry := newProbeRegistry(t,
+		&service.ServiceInstance{ID: "db-1", Name: "db", ServiceType: "database"},
+		&service.ServiceInstance{ID: "web-1", Name: "web", ServiceType: "http"},
+		&service.ServiceInstance{ID: "cache-1", Name: "cache", ServiceType: "cache"},
+	)
+
+	database := &scoreProbe{err: errors.New("connection refused")}
+	fallback := &scoreProbe{score: 0.9}
+	runner := NewProbeRunner(registry, nil, nil)
+	runner.SetProbe("database", database)
+	runner.SetDefaultProbe(fallback)
+
+	runner.ProbeAll(context.Background())
+
+	if database.probed.Load() != 1 || fallback.probed.Load() != 2 {
+		t.Errorf("database probe ran %d times and default %d, want 1 and 2", database.probed.Load(), fallback.probed.Load())
+	}
+	if db, _ := registry.GetService("db-1"); db.HealthStatus != service.HealthUnhealthy || db.ErrorRate != 1 {
+		t.Errorf("failed probe left db-1 %v with error rate %g", db.HealthStatus, db.ErrorRate)
+	}
+	if web, _ := registry.GetService("web-1"); web.HealthScore != 0.9 {
+		t.Errorf("web-1 health score %g, want the default probe's 0.9", web.HealthScore)
+	}
+
+	stats := runner.Stats()
+	if stats.Rounds != 1 || stats.Probes != 3 || stats.Failures != 1 || stats.LastRound.IsZero() {
+		t.Errorf("stats %+v, want 1 round of 3 probes with 1 failure", stats)
+	}
+}
+
+func TestProbeRunnerSkipsServicesWithoutProbe(t *testing.T) {
+	registry := newProbeRegistry(t, &service.ServiceInstance{ID: "web-1", Name: "web", ServiceType: "http"})
+
+	probe := &scoreProbe{score: 1}
+	runner := NewProbeRunner(registry, nil, nil)
+	runner.SetProbe("database", probe)
+	runner.ProbeAll(context.Background())
+
+	if probe.probed.Load() != 0 || runner.Stats().Probes != 0 {
+		t.Error("probed a service type without a probe or default")
+	}
+}
+
+func TestTCPProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	probe := &TCPProbe{}
+	health, err := probe.Probe(ctx, &service.ServiceInstance{ID: "web-1", Address: "127.0.0.1", Port: port})
+	if err != nil || health.Score != 1 {
+		t.Errorf("Probe of a listening port = %+v, %v", health, err)
+	}
+
+	listener.Close()
+	if _, err := probe.Probe(ctx, &service.ServiceInstance{ID: "web-1", Address: "127.0.0.1", Port: port}); err == nil {
+		t.Error("Probe succeeded against a closed port")
+	}
+	if _, err := probe.Probe(ctx, &service.ServiceInstance{ID: "web-2"}); err == nil {
+		t.Error("Probe succeeded for an instance without an address")
+	}
+}
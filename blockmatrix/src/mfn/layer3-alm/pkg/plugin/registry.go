@@ -0,0 +1,363 @@
+// Package plugin implements registration of third-party discovery backends,
+// optimization objectives, health probes and edge cost models.
+//
+// Plugins register factories by name, either at compile time from an init
+// function:
+//
+//	func init() {
+//		plugin.RegisterObjective("carbon", newCarbonObjective)
+//	}
+//
+// or at run time from a Go plugin built with -buildmode=plugin that exports
+// a RegisterPlugins function, loaded with Load. Callers then construct
+// components by the names found in their configuration.
+package plugin
+
+import (
+	"fmt"
+	goplugin "plugin"
+	"sort"
+	"sync"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/internal"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/integration"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/optimization"
+)
+
+// RegisterSymbol is the function a Go plugin exports for Load to call. Its
+// type must be func(*plugin.Registry) error.
+const RegisterSymbol = "RegisterPlugins"
+
+// Kind identifies what a plugin provides
+type Kind string
+
+const (
+	KindDiscoveryBackend Kind = "discovery_backend"
+	KindObjective        Kind = "objective"
+	KindHealthProbe      Kind = "health_probe"
+	KindCostModel        Kind = "cost_model"
+)
+
+// Options are plugin-specific settings, typically from a configuration file
+type Options map[string]string
+
+// DiscoveryBackendFactory creates a service discovery backend for
+// integration.NewHyperMeshIntegration. The coordinator may be nil.
+type DiscoveryBackendFactory func(coordinator *internal.ALMCoordinator, options Options) (integration.ServiceDiscoveryInterface, error)
+
+// ObjectiveFactory creates a routing optimization objective with the given weight
+type ObjectiveFactory func(weight float64, options Options) (optimization.ObjectiveFunction, error)
+
+// HealthProbeFactory creates a health probe for a ProbeRunner
+type HealthProbeFactory func(options Options) (HealthProbe, error)
+
+// CostModelFactory creates a model pricing the edges of a network graph
+type CostModelFactory func(options Options) (graph.CostModel, error)
+
+// Registry holds plugin factories by kind and name
+type Registry struct {
+	backends   map[string]DiscoveryBackendFactory
+	objectives map[string]ObjectiveFactory
+	probes     map[string]HealthProbeFactory
+	costModels map[string]CostModelFactory
+
+	// Go plugins already loaded, by path
+	loaded map[string]bool
+
+	mutex sync.RWMutex
+}
+
+// NewRegistry creates an empty plugin registry
+func NewRegistry() *Registry {
+	return &Registry{
+		backends:   make(map[string]DiscoveryBackendFactory),
+		objectives: make(map[string]ObjectiveFactory),
+		probes:     make(map[string]HealthProbeFactory),
+		costModels: make(map[string]CostModelFactory),
+		loaded:     make(map[string]bool),
+	}
+}
+
+// Default is the registry used by the package-level functions. It comes with
+// the built-in "registry" discovery backend, "tcp" health probe, and "flat"
+// and "egress" cost models.
+var Default = NewRegistry()
+
+func init() {
+	RegisterDiscoveryBackend("registry", func(coordinator *internal.ALMCoordinator, options Options) (integration.ServiceDiscoveryInterface, error) {
+		if coordinator == nil {
+			return nil, fmt.Errorf("the registry backend requires a coordinator")
+		}
+		return integration.NewRegistryServiceDiscovery(coordinator.ServiceRegistry()), nil
+	})
+}
+
+// RegisterDiscoveryBackend registers a discovery backend factory under name
+func (r *Registry) RegisterDiscoveryBackend(name string, factory DiscoveryBackendFactory) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.checkName(KindDiscoveryBackend, name, factory == nil, r.backends[name] != nil); err != nil {
+		return err
+	}
+	r.backends[name] = factory
+	return nil
+}
+
+// RegisterObjective registers an objective factory under name
+func (r *Registry) RegisterObjective(name string, factory ObjectiveFactory) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.checkName(KindObjective, name, factory == nil, r.objectives[name] != nil); err != nil {
+		return err
+	}
+	r.objectives[name] = factory
+	return nil
+}
+
+// RegisterHealthProbe registers a health probe factory under name
+func (r *Registry) RegisterHealthProbe(name string, factory HealthProbeFactory) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.checkName(KindHealthProbe, name, factory == nil, r.probes[name] != nil); err != nil {
+		return err
+	}
+	r.probes[name] = factory
+	return nil
+}
+
+// RegisterCostModel registers a cost model factory under name
+func (r *Registry) RegisterCostModel(name string, factory CostModelFactory) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.checkName(KindCostModel, name, factory == nil, r.costModels[name] != nil); err != nil {
+		return err
+	}
+	r.costModels[name] = factory
+	return nil
+}
+
+// NewDiscoveryBackend creates the discovery backend registered under name
+func (r *Registry) NewDiscoveryBackend(name string, coordinator *internal.ALMCoordinator, options Options) (integration.ServiceDiscoveryInterface, error) {
+	r.mutex.RLock()
+	factory := r.backends[name]
+	r.mutex.RUnlock()
+
+	if factory == nil {
+		return nil, r.unknown(KindDiscoveryBackend, name)
+	}
+
+	backend, err := factory(coordinator, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s %q: %w", KindDiscoveryBackend, name, err)
+	}
+	return backend, nil
+}
+
+// NewObjective creates the objective registered under name
+func (r *Registry) NewObjective(name string, weight float64, options Options) (optimization.ObjectiveFunction, error) {
+	r.mutex.RLock()
+	factory := r.objectives[name]
+	r.mutex.RUnlock()
+
+	if factory == nil {
+		return nil, r.unknown(KindObjective, name)
+	}
+
+	objective, err := factory(weight, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s %q: %w", KindObjective, name, err)
+	}
+	return objective, nil
+}
+
+// NewHealthProbe creates the health probe registered under name
+func (r *Registry) NewHealthProbe(name string, options Options) (HealthProbe, error) {
+	r.mutex.RLock()
+	factory := r.probes[name]
+	r.mutex.RUnlock()
+
+	if factory == nil {
+		return nil, r.unknown(KindHealthProbe, name)
+	}
+
+	probe, err := factory(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s %q: %w", KindHealthProbe, name, err)
+	}
+	return probe, nil
+}
+
+// NewCostModel creates the cost model registered under name
+func (r *Registry) NewCostModel(name string, options Options) (graph.CostModel, error) {
+	r.mutex.RLock()
+	factory := r.costModels[name]
+	r.mutex.RUnlock()
+
+	if factory == nil {
+		return nil, r.unknown(KindCostModel, name)
+	}
+
+	model, err := factory(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s %q: %w", KindCostModel, name, err)
+	}
+	return model, nil
+}
+
+// SetCostModel creates the cost model registered under name and prices the
+// edges of networkGraph with it
+func (r *Registry) SetCostModel(networkGraph *graph.NetworkGraph, name string, options Options) error {
+	model, err := r.NewCostModel(name, options)
+	if err != nil {
+		return err
+	}
+
+	networkGraph.SetCostModel(model)
+	return nil
+}
+
+// AddObjective creates the objective registered under name and adds it to
+// the objectives optimizer uses for requests that do not name their own
+func (r *Registry) AddObjective(optimizer *optimization.MultiObjectiveOptimizer, name string, weight float64, options Options) error {
+	objective, err := r.NewObjective(name, weight, options)
+	if err != nil {
+		return err
+	}
+
+	optimizer.AddObjective(objective)
+	return nil
+}
+
+// Names returns the sorted names registered for kind
+func (r *Registry) Names(kind Kind) []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var names []string
+	switch kind {
+	case KindDiscoveryBackend:
+		for name := range r.backends {
+			names = append(names, name)
+		}
+	case KindObjective:
+		for name := range r.objectives {
+			names = append(names, name)
+		}
+	case KindHealthProbe:
+		for name := range r.probes {
+			names = append(names, name)
+		}
+	case KindCostModel:
+		for name := range r.costModels {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Load opens the Go plugin at path and calls its RegisterPlugins function
+// with this registry. Loading the same path again has no effect. Go plugins
+// must be built with the same Go version and dependency versions as the
+// host, and are only supported on Linux, FreeBSD and macOS with cgo.
+func (r *Registry) Load(path string) error {
+	r.mutex.Lock()
+	if r.loaded[path] {
+		r.mutex.Unlock()
+		return nil
+	}
+	r.loaded[path] = true
+	r.mutex.Unlock()
+
+	register, err := lookupRegister(path)
+	if err == nil {
+		err = register(r)
+	}
+	if err != nil {
+		r.mutex.Lock()
+		delete(r.loaded, path)
+		r.mutex.Unlock()
+		return fmt.Errorf("failed to load plugin %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func lookupRegister(path string) (func(*Registry) error, error) {
+	module, err := goplugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	symbol, err := module.Lookup(RegisterSymbol)
+	if err != nil {
+		return nil, err
+	}
+
+	register, ok := symbol.(func(*Registry) error)
+	if !ok {
+		return nil, fmt.Errorf("%s has type %T, want func(*plugin.Registry) error", RegisterSymbol, symbol)
+	}
+	return register, nil
+}
+
+func (r *Registry) checkName(kind Kind, name string, nilFactory, exists bool) error {
+	if name == "" {
+		return fmt.Errorf("%s name is required", kind)
+	}
+	if nilFactory {
+		return fmt.Errorf("%s %q has a nil factory", kind, name)
+	}
+	if exists {
+		return fmt.Errorf("%s %q is already registered", kind, name)
+	}
+	return nil
+}
+
+func (r *Registry) unknown(kind Kind, name string) error {
+	return fmt.Errorf("unknown %s %q (registered: %v)", kind, name, r.Names(kind))
+}
+
+// RegisterDiscoveryBackend registers a discovery backend with the Default
+// registry. It panics if name is empty or already registered, so it is
+// meant to be called from init functions.
+func RegisterDiscoveryBackend(name string, factory DiscoveryBackendFactory) {
+	if err := Default.RegisterDiscoveryBackend(name, factory); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterObjective registers an objective with the Default registry. It
+// panics if name is empty or already registered.
+func RegisterObjective(name string, factory ObjectiveFactory) {
+	if err := Default.RegisterObjective(name, factory); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterHealthProbe registers a health probe with the Default registry.
+// It panics if name is empty or already registered.
+func RegisterHealthProbe(name string, factory HealthProbeFactory) {
+	if err := Default.RegisterHealthProbe(name, factory); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterCostModel registers a cost model with the Default registry. It
+// panics if name is empty or already registered.
+func RegisterCostModel(name string, factory CostModelFactory) {
+	if err := Default.RegisterCostModel(name, factory); err != nil {
+		panic(err)
+	}
+}
+
+// Load loads a Go plugin into the Default registry
+func Load(path string) error {
+	return Default.Load(path)
+}
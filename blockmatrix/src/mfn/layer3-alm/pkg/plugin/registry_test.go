@@ -0,0 +1,101 @@
+package plugin
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+func flatCostModel(options Options) (graph.CostModel, error) {
+	return graph.FlatCostModel{LinkCost: 1}, nil
+}
+
+func TestRegistryRejectsInvalidRegistrations(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.RegisterCostModel("flat", flatCostModel); err != nil {
+		t.Fatalf("RegisterCostModel: %v", err)
+	}
+
+	if err := registry.RegisterCostModel("flat", flatCostModel); err == nil {
+		t.Error("registered the same name twice")
+	}
+	if err := registry.RegisterCostModel("", flatCostModel); err == nil {
+		t.Error("registered an empty name")
+	}
+	if err := registry.RegisterCostModel("nil", nil); err == nil {
+		t.Error("registered a nil factory")
+	}
+
+	// Names are per kind
+	if err := registry.RegisterHealthProbe("flat", func(Options) (HealthProbe, error) { return &TCPProbe{}, nil }); err != nil {
+		t.Errorf("RegisterHealthProbe with a cost model's name: %v", err)
+	}
+}
+
+func TestRegistryCreatesByName(t *testing.T) {
+	registry := NewRegistry()
+	for _, name := range []string{"zeta", "alpha", "mid"} {
+		if err := registry.RegisterCostModel(name, flatCostModel); err != nil {
+			t.Fatalf("RegisterCostModel(%s): %v", name, err)
+		}
+	}
+
+	if got := strings.Join(registry.Names(KindCostModel), ","); got != "alpha,mid,zeta" {
+		t.Errorf("Names = %s, want sorted", got)
+	}
+	if got := registry.Names(KindObjective); len(got) != 0 {
+		t.Errorf("Names(objective) = %v, want none", got)
+	}
+
+	if _, err := registry.NewCostModel("mid", nil); err != nil {
+		t.Errorf("NewCostModel(mid): %v", err)
+	}
+
+	_, err := registry.NewCostModel("missing", nil)
+	if err == nil || !strings.Contains(err.Error(), "alpha mid zeta") {
+		t.Errorf("NewCostModel(missing) = %v, want an error listing the registered names", err)
+	}
+}
+
+func TestRegistryWrapsFactoryErrors(t *testing.T) {
+	registry := NewRegistry()
+	broken := errors.New("bad option")
+	if err := registry.RegisterHealthProbe("http", func(Options) (HealthProbe, error) { return nil, broken }); err != nil {
+		t.Fatalf("RegisterHealthProbe: %v", err)
+	}
+
+	_, err := registry.NewHealthProbe("http", nil)
+	if !errors.Is(err, broken) || !strings.Contains(err.Error(), `"http"`) {
+		t.Errorf("NewHealthProbe = %v, want the factory error naming the probe", err)
+	}
+}
+
+func TestDefaultRegistryBuiltins(t *testing.T) {
+	for kind, want := range map[Kind]string{
+		KindDiscoveryBackend: "registry",
+		KindHealthProbe:      "tcp",
+		KindCostModel:        "egress,flat",
+	} {
+		if got := strings.Join(Default.Names(kind), ","); !strings.Contains(got, want) {
+			t.Errorf("Default.Names(%s) = %s, want %s", kind, got, want)
+		}
+	}
+
+	if _, err := Default.NewDiscoveryBackend("registry", nil, nil); err == nil {
+		t.Error("registry backend created without a coordinator")
+	}
+}
+
+func TestRegistryLoadMissingPlugin(t *testing.T) {
+	registry := NewRegistry()
+
+	if err := registry.Load("/nonexistent/plugin.so"); err == nil {
+		t.Fatal("Load succeeded for a missing file")
+	}
+	// A failed load can be retried rather than being remembered as loaded
+	if err := registry.Load("/nonexistent/plugin.so"); err == nil {
+		t.Error("second Load of a missing file succeeded")
+	}
+}
@@ -0,0 +1,43 @@
+// Package routing implements brownout mode for lookups under global overload
+package routing
+
+import (
+	"time"
+)
+
+// SetBrownout switches brownout mode on or off. While it is on, route
+// discovery uses FastLookup whatever the configured optimization level, so
+// neither the associative search nor the optimizer runs, route feedback no
+// longer trains the associative search, and cached routes stay valid for
+// cacheTTL when that is longer than the configured CacheTTL.
+func (rt *RoutingTable) SetBrownout(active bool, cacheTTL time.Duration) {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	rt.brownoutTTL.Store(int64(cacheTTL))
+	rt.brownout.Store(active)
+	rt.routeCache.SetTTL(rt.effectiveCacheTTL(rt.config.CacheTTL))
+}
+
+// Brownout reports whether brownout mode is on
+func (rt *RoutingTable) Brownout() bool {
+	return rt.brownout.Load()
+}
+
+// effectiveCacheTTL returns how long cached routes stay valid given the
+// configured ttl, extended while in brownout
+func (rt *RoutingTable) effectiveCacheTTL(ttl time.Duration) time.Duration {
+	if rt.brownout.Load() {
+		return max(ttl, time.Duration(rt.brownoutTTL.Load()))
+	}
+	return ttl
+}
+
+// optimizationLevel returns the level discovery runs at: the configured
+// level, or FastLookup while in brownout
+func (rt *RoutingTable) optimizationLevel() OptimizationLevel {
+	if rt.brownout.Load() {
+		return FastLookup
+	}
+	return rt.config.OptimizationLevel
+}
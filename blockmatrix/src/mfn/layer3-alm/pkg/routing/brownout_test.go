@@ -0,0 +1,36 @@
+package routing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBrownoutSkipsOptimizerAndExtendsCacheTTL(t *testing.T) {
+	table, requests := newLookupTable(t, FastLookup)
+	request := requests[0]
+	table.config.OptimizationLevel = DeepOptimization
+	table.config.CacheTTL = time.Minute
+
+	// Discovery falls back to FastLookup, leaving the optimizer idle
+	table.SetBrownout(true, time.Hour)
+	table.InvalidateCache()
+	optimizations := table.optimizer.GetOptimizationStats().TotalOptimizations
+	response, err := table.LookupRoute(request)
+	if err != nil {
+		t.Fatalf("lookup in brownout failed: %v", err)
+	}
+	if got := table.optimizer.GetOptimizationStats().TotalOptimizations; got != optimizations {
+		t.Errorf("optimizer ran %d times in brownout", got-optimizations)
+	}
+
+	// Routes older than the configured TTL are served while brownout lasts
+	response.Route.CreatedAt = time.Now().Add(-10 * time.Minute)
+	if response, _ := table.LookupRoute(request); !response.CacheHit {
+		t.Error("route within the brownout TTL was not served from the cache")
+	}
+
+	table.SetBrownout(false, time.Hour)
+	if response, _ := table.LookupRoute(request); response != nil && response.CacheHit {
+		t.Error("route past the configured TTL was served from the cache after brownout")
+	}
+}
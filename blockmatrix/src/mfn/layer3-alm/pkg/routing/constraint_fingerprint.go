@@ -0,0 +1,78 @@
+// Package routing implements fingerprints keying cached routes by the
+// constraints they were discovered under
+package routing
+
+import (
+	"math"
+	"slices"
+)
+
+// Fingerprint returns a 64-bit FNV-1a hash of the constraints, or zero when
+// none is set. Avoided nodes are hashed as a set, so their order and
+// repeats do not matter; preferred regions are hashed in order.
+func (c RouteConstraints) Fingerprint() uint64 {
+	if c.unconstrained() {
+		return 0
+	}
+
+	hash := uint64(fnvOffset64)
+	hash = fingerprintNode(hash, int64(c.MaxLatency))
+	hash = fingerprintNode(hash, int64(math.Float64bits(c.MinThroughput)))
+	hash = fingerprintNode(hash, int64(math.Float64bits(c.MinReliability)))
+	hash = fingerprintNode(hash, int64(math.Float64bits(c.MaxCost)))
+	hash = fingerprintNode(hash, int64(c.MaxHops))
+
+	avoid := slices.Clone(c.AvoidNodes)
+	slices.Sort(avoid)
+	avoid = slices.Compact(avoid)
+	hash = fingerprintNode(hash, int64(len(avoid)))
+	for _, nodeID := range avoid {
+		hash = fingerprintNode(hash, nodeID)
+	}
+
+	hash = fingerprintNode(hash, int64(len(c.PreferRegions)))
+	for _, region := range c.PreferRegions {
+		hash = fingerprintNode(hash, int64(len(region)))
+		for i := 0; i < len(region); i++ {
+			hash ^= uint64(region[i])
+			hash *= fnvPrime64
+		}
+	}
+
+	// Keep zero for the unconstrained key
+	if hash == 0 {
+		hash = 1
+	}
+	return hash
+}
+
+// Relaxes reports whether c is no stricter than other in any limit, so
+// every route meeting other meets c. The best route found under c that
+// also meets other is then the best route under other too. Preferred
+// regions steer the choice rather than limit it, so they must match.
+func (c RouteConstraints) Relaxes(other RouteConstraints) bool {
+	relaxes := func(limit, otherLimit float64) bool {
+		return limit == 0 || (otherLimit != 0 && otherLimit <= limit)
+	}
+	if !relaxes(float64(c.MaxLatency), float64(other.MaxLatency)) ||
+		!relaxes(c.MaxCost, other.MaxCost) ||
+		!relaxes(float64(c.MaxHops), float64(other.MaxHops)) ||
+		c.MinThroughput > other.MinThroughput ||
+		c.MinReliability > other.MinReliability ||
+		!slices.Equal(c.PreferRegions, other.PreferRegions) {
+		return false
+	}
+
+	for _, nodeID := range c.AvoidNodes {
+		if !slices.Contains(other.AvoidNodes, nodeID) {
+			return false
+		}
+	}
+	return true
+}
+
+// unconstrained reports whether no constraint is set
+func (c RouteConstraints) unconstrained() bool {
+	return c.MaxLatency == 0 && c.MinThroughput == 0 && c.MinReliability == 0 && c.MaxCost == 0 &&
+		c.MaxHops == 0 && len(c.AvoidNodes) == 0 && len(c.PreferRegions) == 0
+}
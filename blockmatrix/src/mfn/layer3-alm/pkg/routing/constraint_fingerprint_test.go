@@ -0,0 +1,62 @@
+package routing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRouteConstraintsFingerprint(t *testing.T) {
+	if fingerprint := (RouteConstraints{}).Fingerprint(); fingerprint != 0 {
+		t.Errorf("unconstrained fingerprint %x, want 0", fingerprint)
+	}
+
+	constraints := []RouteConstraints{
+		{MaxLatency: time.Millisecond},
+		{MaxLatency: 2 * time.Millisecond},
+		{MinThroughput: 100},
+		{MinReliability: 0.99},
+		{MaxCost: 1},
+		{MaxHops: 3},
+		{AvoidNodes: []int64{1, 2}},
+		{AvoidNodes: []int64{1}},
+		{PreferRegions: []string{"east", "west"}},
+		{PreferRegions: []string{"west", "east"}},
+		{PreferRegions: []string{"eastwest"}},
+	}
+	seen := make(map[uint64]RouteConstraints)
+	for _, c := range constraints {
+		fingerprint := c.Fingerprint()
+		if other, exists := seen[fingerprint]; exists || fingerprint == 0 {
+			t.Errorf("constraints %+v and %+v share fingerprint %x", other, c, fingerprint)
+		}
+		seen[fingerprint] = c
+	}
+
+	// Avoided nodes are a set
+	if (RouteConstraints{AvoidNodes: []int64{2, 1, 2}}).Fingerprint() != (RouteConstraints{AvoidNodes: []int64{1, 2}}).Fingerprint() {
+		t.Error("fingerprint depends on the order of avoided nodes")
+	}
+}
+
+func TestRouteConstraintsRelaxes(t *testing.T) {
+	strict := RouteConstraints{MaxLatency: time.Millisecond, MinReliability: 0.99, MaxHops: 3, AvoidNodes: []int64{4, 5}}
+	cases := []struct {
+		name    string
+		loose   RouteConstraints
+		relaxes bool
+	}{
+		{"unconstrained", RouteConstraints{}, true},
+		{"equal", strict, true},
+		{"higher latency bound", RouteConstraints{MaxLatency: time.Second, AvoidNodes: []int64{5}}, true},
+		{"lower latency bound", RouteConstraints{MaxLatency: time.Microsecond}, false},
+		{"unset in strict", RouteConstraints{MaxCost: 10}, false},
+		{"higher reliability floor", RouteConstraints{MinReliability: 0.999}, false},
+		{"more avoided nodes", RouteConstraints{AvoidNodes: []int64{4, 6}}, false},
+		{"other preferred regions", RouteConstraints{PreferRegions: []string{"east"}}, false},
+	}
+	for _, tc := range cases {
+		if got := tc.loose.Relaxes(strict); got != tc.relaxes {
+			t.Errorf("%s: Relaxes = %v, want %v", tc.name, got, tc.relaxes)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Package routing implements named route constraint templates
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnknownConstraintTemplate is returned for a request naming a template
+// that is not registered
+var ErrUnknownConstraintTemplate = errors.New("unknown constraint template")
+
+// ConstraintTemplates are route constraints registered by name, such as
+// "payments" for a 5ms latency bound at 0.999 reliability, so requests name
+// the constraints of their service class instead of each team spelling
+// them out
+type ConstraintTemplates struct {
+	templates map[string]RouteConstraints
+	mutex     sync.RWMutex
+}
+
+// NewConstraintTemplates creates an empty template registry
+func NewConstraintTemplates() *ConstraintTemplates {
+	return &ConstraintTemplates{templates: make(map[string]RouteConstraints)}
+}
+
+// Validate checks that the constraints are within range
+func (c RouteConstraints) Validate() error {
+	switch {
+	case c.MaxLatency < 0 || c.MinThroughput < 0 || c.MaxCost < 0:
+		return errors.New("constraints must not be negative")
+	case c.MaxHops < 0:
+		return fmt.Errorf("max hops must not be negative, got %d", c.MaxHops)
+	case c.MinReliability < 0 || c.MinReliability > 1:
+		return fmt.Errorf("min reliability must be between 0 and 1, got %g", c.MinReliability)
+	}
+	return nil
+}
+
+// Tighten returns the stricter of c and other for each limit: the lower
+// maximums and higher minimums, where zero leaves a limit unset. Avoided
+// nodes are combined, and c's preferred regions win when it has any.
+func (c RouteConstraints) Tighten(other RouteConstraints) RouteConstraints {
+	tightened := c
+	tightened.MaxLatency = minLimit(c.MaxLatency, other.MaxLatency)
+	tightened.MaxCost = minLimit(c.MaxCost, other.MaxCost)
+	tightened.MaxHops = minLimit(c.MaxHops, other.MaxHops)
+	tightened.MinThroughput = max(c.MinThroughput, other.MinThroughput)
+	tightened.MinReliability = max(c.MinReliability, other.MinReliability)
+
+	if len(other.AvoidNodes) > 0 {
+		tightened.AvoidNodes = append(append([]int64(nil), c.AvoidNodes...), other.AvoidNodes...)
+	}
+	if len(tightened.PreferRegions) == 0 {
+		tightened.PreferRegions = other.PreferRegions
+	}
+	return tightened
+}
+
+// minLimit returns the lower of two maximums, where zero is unlimited
+func minLimit[T int | float64 | ~int64](a, b T) T {
+	switch {
+	case a == 0:
+		return b
+	case b == 0:
+		return a
+	}
+	return min(a, b)
+}
+
+// Set registers or replaces the template name
+func (ct *ConstraintTemplates) Set(name string, constraints RouteConstraints) error {
+	if name == "" {
+		return errors.New("constraint template name is required")
+	}
+	if err := constraints.Validate(); err != nil {
+		return fmt.Errorf("constraint template %s: %w", name, err)
+	}
+
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+
+	ct.templates[name] = constraints
+	return nil
+}
+
+// Remove unregisters the template name, reporting whether it existed
+func (ct *ConstraintTemplates) Remove(name string) bool {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+
+	_, ok := ct.templates[name]
+	delete(ct.templates, name)
+	return ok
+}
+
+// Replace registers exactly templates, or leaves the registry unchanged if
+// any is invalid
+func (ct *ConstraintTemplates) Replace(templates map[string]RouteConstraints) error {
+	replaced := make(map[string]RouteConstraints, len(templates))
+	for name, constraints := range templates {
+		if err := constraints.Validate(); err != nil {
+			return fmt.Errorf("constraint template %s: %w", name, err)
+		}
+		replaced[name] = constraints
+	}
+
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+
+	ct.templates = replaced
+	return nil
+}
+
+// Get returns the template name
+func (ct *ConstraintTemplates) Get(name string) (RouteConstraints, bool) {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+
+	constraints, ok := ct.templates[name]
+	return constraints, ok
+}
+
+// All returns a copy of the registered templates
+func (ct *ConstraintTemplates) All() map[string]RouteConstraints {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+
+	templates := make(map[string]RouteConstraints, len(ct.templates))
+	for name, constraints := range ct.templates {
+		templates[name] = constraints
+	}
+	return templates
+}
+
+// Resolve returns the constraints of a request naming template name,
+// tightened by any the request sets itself, so a request can only be
+// stricter than its template. An empty name returns constraints unchanged.
+func (ct *ConstraintTemplates) Resolve(name string, constraints RouteConstraints) (RouteConstraints, error) {
+	if name == "" {
+		return constraints, nil
+	}
+	template, ok := ct.Get(name)
+	if !ok {
+		return constraints, fmt.Errorf("%w %q", ErrUnknownConstraintTemplate, name)
+	}
+	return constraints.Tighten(template), nil
+}
@@ -0,0 +1,76 @@
+package routing
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConstraintTemplatesResolve(t *testing.T) {
+	templates := NewConstraintTemplates()
+	payments := RouteConstraints{MaxLatency: 5 * time.Millisecond, MinReliability: 0.999, AvoidNodes: []int64{7}}
+	if err := templates.Set("payments", payments); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := templates.Set("broken", RouteConstraints{MinReliability: 1.5}); err == nil {
+		t.Error("template with reliability above 1 registered")
+	}
+
+	cases := []struct {
+		name     string
+		template string
+		explicit RouteConstraints
+		want     RouteConstraints
+	}{
+		{name: "no template", explicit: RouteConstraints{MaxHops: 3}, want: RouteConstraints{MaxHops: 3}},
+		{name: "template only", template: "payments", want: payments},
+		{
+			name:     "tighter request",
+			template: "payments",
+			explicit: RouteConstraints{MaxLatency: 2 * time.Millisecond, MaxHops: 4, AvoidNodes: []int64{9}},
+			want: RouteConstraints{MaxLatency: 2 * time.Millisecond, MinReliability: 0.999, MaxHops: 4,
+				AvoidNodes: []int64{9, 7}},
+		},
+		{
+			name:     "looser request",
+			template: "payments",
+			explicit: RouteConstraints{MaxLatency: 50 * time.Millisecond, MinReliability: 0.9},
+			want:     RouteConstraints{MaxLatency: 5 * time.Millisecond, MinReliability: 0.999, AvoidNodes: []int64{7}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := templates.Resolve(tc.template, tc.explicit)
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := templates.Resolve("unknown", RouteConstraints{}); !errors.Is(err, ErrUnknownConstraintTemplate) {
+		t.Errorf("Resolve of an unknown template: got %v, want ErrUnknownConstraintTemplate", err)
+	}
+}
+
+func TestLookupRouteAppliesConstraintTemplate(t *testing.T) {
+	table, requests := newLookupTable(t, FastLookup)
+	request := requests[0]
+	request.Constraints = RouteConstraints{}
+
+	if err := table.ConstraintTemplates().Set("impossible", RouteConstraints{MaxLatency: time.Nanosecond}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	request.ConstraintTemplate = "impossible"
+	if response, err := table.LookupRoute(request); err == nil {
+		t.Errorf("route over a 1ns latency bound returned: %+v", response.Route.Metrics)
+	}
+
+	request.ConstraintTemplate = "missing"
+	if _, err := table.LookupRoute(request); !errors.Is(err, ErrUnknownConstraintTemplate) {
+		t.Errorf("lookup naming a missing template: got %v, want ErrUnknownConstraintTemplate", err)
+	}
+}
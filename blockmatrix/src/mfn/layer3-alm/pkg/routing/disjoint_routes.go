@@ -0,0 +1,93 @@
+// Package routing implements lookups for disjoint routes
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+const (
+	// defaultDisjointPaths is the number of disjoint paths a request gets
+	// when it sets none: a route and one alternative
+	defaultDisjointPaths = 2
+
+	// maxDisjointPaths bounds the paths one request may ask for
+	maxDisjointPaths = 8
+)
+
+// ErrNotEnoughDisjointPaths is returned when the topology lacks as many
+// usable disjoint paths as a request asks for
+var ErrNotEnoughDisjointPaths = errors.New("not enough disjoint paths")
+
+// lookupDisjoint finds the cheapest set of disjoint paths a request asks
+// for and returns the cheapest as the route and the rest as alternatives.
+// Every path must meet the request's constraints and cross only healthy
+// nodes with capacity for it; one that does not cannot be swapped out
+// without weakening the set, so the lookup fails instead.
+func (rt *RoutingTable) lookupDisjoint(request RoutingRequest, failover *DRFailover, startTime time.Time) (*RoutingResponse, error) {
+	count := request.DisjointPaths
+	if count == 0 {
+		count = defaultDisjointPaths
+	}
+
+	paths, err := rt.networkGraph.FindDisjointPaths(request.Source, request.Destination, count, request.Disjoint, request.Constraints.AvoidNodes)
+	if err != nil {
+		return nil, fmt.Errorf("route discovery failed: %w", err)
+	}
+	routes := make([]*RouteEntry, 0, len(paths))
+	for _, path := range paths {
+		route := rt.pathRoute(path, request)
+		if rt.meetsConstraints(route, request.Constraints) && rt.loadBalancer.RouteUsable(route, request.Constraints.MinThroughput) {
+			routes = append(routes, route)
+		}
+	}
+	if len(routes) < count {
+		return nil, fmt.Errorf("%w to destination %d: found %d usable %s-disjoint, need %d",
+			ErrNotEnoughDisjointPaths, request.Destination, len(routes), request.Disjoint, count)
+	}
+
+	selected, alternatives := routes[0], routes[1:]
+	rt.loadBalancer.RecordSelection(request.Source, request.Destination, selected, request.Constraints.MinThroughput)
+	rt.metrics.RecordSuccessfulLookup(time.Since(startTime))
+
+	response := &RoutingResponse{
+		Route:          selected,
+		Alternatives:   alternatives,
+		DecisionTime:   time.Since(startTime),
+		Confidence:     selected.Confidence,
+		LoadBalanced:   true,
+		SelectedReason: "disjoint",
+		Failover:       failover,
+		Diversity:      routeDiversity(selected, alternatives),
+	}
+	response.Weight, response.AlternativeWeights = rt.trafficWeights(selected, alternatives, request)
+	return response, nil
+}
+
+// routeDiversity returns the lowest diversity between any two of a route
+// and its alternatives, or 0 without alternatives
+func routeDiversity(route *RouteEntry, alternatives []*RouteEntry) float64 {
+	if route == nil || len(alternatives) == 0 {
+		return 0
+	}
+
+	paths := make([][]int64, 0, len(alternatives)+1)
+	for _, entry := range append([]*RouteEntry{route}, alternatives...) {
+		path := make([]int64, len(entry.Path))
+		for i, node := range entry.Path {
+			path[i] = node.ID
+		}
+		paths = append(paths, path)
+	}
+
+	diversity := 1.0
+	for i := range paths {
+		for j := i + 1; j < len(paths); j++ {
+			diversity = min(diversity, graph.PathDiversity(paths[i], paths[j]))
+		}
+	}
+	return diversity
+}
@@ -0,0 +1,67 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+func TestLookupRouteDisjointPaths(t *testing.T) {
+	ng := graph.NewNetworkGraph(4)
+	t.Cleanup(ng.Close)
+	for id := int64(1); id <= 4; id++ {
+		if err := ng.AddNode(&graph.NetworkNode{ID: id, Reliability: 1}); err != nil {
+			t.Fatalf("AddNode: %v", err)
+		}
+	}
+	for _, edge := range []*graph.NetworkEdge{
+		{From: 1, To: 2, Weight: 10, Latency: 10 * time.Microsecond, Reliability: 1},
+		{From: 2, To: 4, Weight: 10, Latency: 10 * time.Microsecond, Reliability: 1},
+		{From: 1, To: 3, Weight: 15, Latency: 15 * time.Microsecond, Reliability: 1},
+		{From: 3, To: 4, Weight: 15, Latency: 15 * time.Microsecond, Reliability: 1},
+	} {
+		if err := ng.AddEdge(edge); err != nil {
+			t.Fatalf("AddEdge: %v", err)
+		}
+	}
+	config := DefaultRoutingConfig()
+	config.OptimizationLevel = FastLookup
+	table := NewRoutingTable(ng, nil, nil, config)
+
+	request := RoutingRequest{Source: 1, Destination: 4, Context: context.Background(), Disjoint: graph.NodeDisjoint}
+	response, err := table.LookupRoute(request)
+	if err != nil {
+		t.Fatalf("LookupRoute: %v", err)
+	}
+	if response.Route.NextHop != 2 || len(response.Alternatives) != 1 || response.Alternatives[0].NextHop != 3 {
+		t.Errorf("disjoint lookup returned route via %d and %d alternatives", response.Route.NextHop, len(response.Alternatives))
+	}
+	if response.Diversity != 1 {
+		t.Errorf("node-disjoint paths scored diversity %v, want 1", response.Diversity)
+	}
+	if stats := table.GetRouteCacheStats(); stats.Size != 0 {
+		t.Errorf("disjoint lookup cached %d routes", stats.Size)
+	}
+
+	// A plain lookup offers no alternatives to be diverse from
+	if response, err := table.LookupRoute(RoutingRequest{Source: 1, Destination: 4, Context: context.Background()}); err != nil || response.Diversity != 0 {
+		t.Errorf("plain lookup: diversity %v, %v; want 0", response.Diversity, err)
+	}
+
+	request.DisjointPaths = 3
+	if _, err := table.LookupRoute(request); !errors.Is(err, ErrNotEnoughDisjointPaths) {
+		t.Errorf("three paths over two: got %v, want ErrNotEnoughDisjointPaths", err)
+	}
+	request.DisjointPaths = 0
+	table.UpdateNodeHealth(3, false, NodeHealthMetrics{})
+	if _, err := table.LookupRoute(request); !errors.Is(err, ErrNotEnoughDisjointPaths) {
+		t.Errorf("path through an unhealthy node: got %v, want ErrNotEnoughDisjointPaths", err)
+	}
+	request.DisjointPaths = 1
+	if _, err := table.LookupRoute(request); err == nil {
+		t.Error("accepted a request for a single disjoint path")
+	}
+}
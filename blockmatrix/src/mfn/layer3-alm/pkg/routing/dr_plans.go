@@ -0,0 +1,333 @@
+// Package routing implements per-region disaster recovery routing plans
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrNoFailoverRegion is returned for a destination in a failed region when
+// no failover region in its plan has a healthy node and flows to spare
+var ErrNoFailoverRegion = errors.New("no failover region with capacity")
+
+// FailoverTarget is a region that takes over a failed region's traffic
+type FailoverTarget struct {
+	Region string `json:"region" yaml:"region"`
+
+	// Flows the region takes over at most while the plan is active; zero
+	// is unlimited
+	MaxFlows int `json:"max_flows,omitempty" yaml:"max_flows,omitempty"`
+}
+
+// DRFailover reports a lookup redirected under a disaster recovery plan
+type DRFailover struct {
+	// Region whose plan was applied, and the region routed to instead
+	FailedRegion string
+	Region       string
+
+	// Destination requested, in the failed region
+	OriginalDestination int64
+}
+
+// DRPlanStatus reports a plan and, while its region is failed, the flows
+// each failover region has taken over
+type DRPlanStatus struct {
+	Region   string
+	Failover []FailoverTarget
+	Active   bool
+	FailedAt time.Time
+	Flows    map[string]int
+}
+
+// activePlan is the state of a plan while its region is failed
+type activePlan struct {
+	failedAt time.Time
+
+	// Failover destination of each flow, so a flow stays in one region
+	// for as long as the plan is active, and the flows per region
+	flows  map[flowKey]failoverFlow
+	counts map[string]int
+}
+
+// failoverFlow is where a flow into a failed region was redirected
+type failoverFlow struct {
+	region      string
+	destination int64
+}
+
+// DRPlans maps regions to ordered failover regions with capacity caps. When
+// a region fails, lookups for destinations in it are redirected to the
+// nearest healthy node of the first failover region with flows to spare,
+// and stay there until the region recovers.
+type DRPlans struct {
+	plans  map[string][]FailoverTarget
+	failed map[string]*activePlan
+	mutex  sync.Mutex
+}
+
+// NewDRPlans creates an empty plan registry
+func NewDRPlans() *DRPlans {
+	return &DRPlans{
+		plans:  make(map[string][]FailoverTarget),
+		failed: make(map[string]*activePlan),
+	}
+}
+
+// ValidateDRPlans checks every plan names failover regions other than its
+// own, each once, with non-negative caps
+func ValidateDRPlans(plans map[string][]FailoverTarget) error {
+	var problems []error
+	for region, targets := range plans {
+		if region == "" {
+			problems = append(problems, errors.New("dr plan region is required"))
+			continue
+		}
+		if len(targets) == 0 {
+			problems = append(problems, fmt.Errorf("dr plan %s: at least one failover region is required", region))
+		}
+		seen := make(map[string]bool, len(targets))
+		for _, target := range targets {
+			switch {
+			case target.Region == "":
+				problems = append(problems, fmt.Errorf("dr plan %s: failover region is required", region))
+			case target.Region == region:
+				problems = append(problems, fmt.Errorf("dr plan %s: cannot fail over to itself", region))
+			case seen[target.Region]:
+				problems = append(problems, fmt.Errorf("dr plan %s: failover region %s listed twice", region, target.Region))
+			case target.MaxFlows < 0:
+				problems = append(problems, fmt.Errorf("dr plan %s: max flows for %s must not be negative, got %d",
+					region, target.Region, target.MaxFlows))
+			}
+			seen[target.Region] = true
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// Replace registers exactly plans, or leaves the registry unchanged if any
+// is invalid. Failed regions stay failed, and their flows keep the regions
+// they were redirected to.
+func (dp *DRPlans) Replace(plans map[string][]FailoverTarget) error {
+	if err := ValidateDRPlans(plans); err != nil {
+		return err
+	}
+	replaced := make(map[string][]FailoverTarget, len(plans))
+	for region, targets := range plans {
+		replaced[region] = append([]FailoverTarget(nil), targets...)
+	}
+
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+
+	dp.plans = replaced
+	return nil
+}
+
+// FailRegion activates the plan of region, reporting whether it was not
+// already failed
+func (dp *DRPlans) FailRegion(region string) bool {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+
+	if _, failed := dp.failed[region]; failed {
+		return false
+	}
+	dp.failed[region] = &activePlan{
+		failedAt: time.Now(),
+		flows:    make(map[flowKey]failoverFlow),
+		counts:   make(map[string]int),
+	}
+	return true
+}
+
+// RecoverRegion deactivates the plan of region, releasing its flows, and
+// reports whether it was failed
+func (dp *DRPlans) RecoverRegion(region string) bool {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+
+	_, failed := dp.failed[region]
+	delete(dp.failed, region)
+	return failed
+}
+
+// Failed reports whether region has failed
+func (dp *DRPlans) Failed(region string) bool {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+
+	_, failed := dp.failed[region]
+	return failed
+}
+
+// All returns a copy of the registered plans
+func (dp *DRPlans) All() map[string][]FailoverTarget {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+
+	plans := make(map[string][]FailoverTarget, len(dp.plans))
+	for region, targets := range dp.plans {
+		plans[region] = append([]FailoverTarget(nil), targets...)
+	}
+	return plans
+}
+
+// Status reports every plan and failed region, by region
+func (dp *DRPlans) Status() []DRPlanStatus {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+
+	regions := make(map[string]bool, len(dp.plans)+len(dp.failed))
+	for region := range dp.plans {
+		regions[region] = true
+	}
+	for region := range dp.failed {
+		regions[region] = true
+	}
+
+	statuses := make([]DRPlanStatus, 0, len(regions))
+	for region := range regions {
+		status := DRPlanStatus{Region: region, Failover: append([]FailoverTarget(nil), dp.plans[region]...)}
+		if active, failed := dp.failed[region]; failed {
+			status.Active = true
+			status.FailedAt = active.failedAt
+			status.Flows = make(map[string]int, len(active.counts))
+			for target, flows := range active.counts {
+				status.Flows[target] = flows
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Region < statuses[j].Region })
+	return statuses
+}
+
+// redirect returns where the flow from source to destination, in failed
+// region, goes under the region's plan: the region it was redirected to
+// before, or the first failover region with flows to spare in which nearest
+// finds a node. It returns false when region has not failed or has no plan.
+func (dp *DRPlans) redirect(region string, source, destination int64, nearest func(region string) (int64, bool)) (failoverFlow, bool, error) {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+
+	active, failed := dp.failed[region]
+	targets := dp.plans[region]
+	if !failed || len(targets) == 0 {
+		return failoverFlow{}, false, nil
+	}
+
+	key := flowKey{source: source, destination: destination}
+	if flow, exists := active.flows[key]; exists {
+		return flow, true, nil
+	}
+
+	for _, target := range targets {
+		if target.MaxFlows > 0 && active.counts[target.Region] >= target.MaxFlows {
+			continue
+		}
+		if node, found := nearest(target.Region); found {
+			flow := failoverFlow{region: target.Region, destination: node}
+			active.flows[key] = flow
+			active.counts[target.Region]++
+			return flow, true, nil
+		}
+	}
+	return failoverFlow{}, true, fmt.Errorf("%w for destination %d in failed region %s", ErrNoFailoverRegion, destination, region)
+}
+
+// applyDRPlan redirects a request for a destination in a failed region to
+// the failover destination its plan assigns, returning the failover or nil
+// when the destination's region has not failed
+func (rt *RoutingTable) applyDRPlan(request *RoutingRequest) (*DRFailover, error) {
+	node, exists := rt.networkGraph.GetNode(request.Destination)
+	if !exists || node.Region == "" {
+		return nil, nil
+	}
+
+	flow, applied, err := rt.drPlans.redirect(node.Region, request.Source, request.Destination, func(region string) (int64, bool) {
+		return rt.nearestHealthyNode(request.Source, region)
+	})
+	if err != nil || !applied {
+		return nil, err
+	}
+
+	failover := &DRFailover{
+		FailedRegion:        node.Region,
+		Region:              flow.region,
+		OriginalDestination: request.Destination,
+	}
+	request.Destination = flow.destination
+	return failover, nil
+}
+
+// nearestHealthyNode returns the healthy node of region with the lowest
+// latency path from source
+func (rt *RoutingTable) nearestHealthyNode(source int64, region string) (int64, bool) {
+	best, found := int64(0), false
+	var bestLatency time.Duration
+	for _, node := range rt.networkGraph.Nodes() {
+		if node.Region != region || node.ID == source {
+			continue
+		}
+		if healthy, _ := rt.loadBalancer.GetNodeHealth(node.ID); !healthy {
+			continue
+		}
+		path, err := rt.networkGraph.FindShortestPath(source, node.ID)
+		if err != nil {
+			continue
+		}
+		if !found || path.TotalLatency < bestLatency || (path.TotalLatency == bestLatency && node.ID < best) {
+			best, bestLatency, found = node.ID, path.TotalLatency, true
+		}
+	}
+	return best, found
+}
+
+// DRPlans returns the table's disaster recovery plans
+func (rt *RoutingTable) DRPlans() *DRPlans {
+	return rt.drPlans
+}
+
+// FailRegion activates the disaster recovery plan of region: lookups for
+// its destinations are redirected to its failover regions, and cached
+// routes to or through its nodes are dropped. It reports whether the region
+// was not already failed.
+func (rt *RoutingTable) FailRegion(region string) bool {
+	if !rt.drPlans.FailRegion(region) {
+		return false
+	}
+
+	removed := rt.InvalidateTopology(TopologyChange{Nodes: rt.regionNodes(region)})
+	logger.L().Warn("Region failed; disaster recovery plan active",
+		zap.String("region", region),
+		zap.Int("routes_invalidated", removed),
+	)
+	return true
+}
+
+// RecoverRegion deactivates the disaster recovery plan of region, so its
+// destinations are routed to again, and reports whether it was failed
+func (rt *RoutingTable) RecoverRegion(region string) bool {
+	if !rt.drPlans.RecoverRegion(region) {
+		return false
+	}
+
+	logger.L().Info("Region recovered; disaster recovery plan released", zap.String("region", region))
+	return true
+}
+
+// regionNodes returns the IDs of the nodes in region
+func (rt *RoutingTable) regionNodes(region string) []int64 {
+	var nodeIDs []int64
+	for _, node := range rt.networkGraph.Nodes() {
+		if node.Region == region {
+			nodeIDs = append(nodeIDs, node.ID)
+		}
+	}
+	return nodeIDs
+}
@@ -0,0 +1,114 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+// newRegionTable returns a table over a source, node 1, linked to two nodes
+// in each of the east (2, 3), west (4, 5) and south (6, 7) regions, the
+// second of each nearer
+func newRegionTable(t *testing.T) *RoutingTable {
+	t.Helper()
+
+	ng := graph.NewNetworkGraph(16)
+	t.Cleanup(ng.Close)
+	regions := map[int64]string{1: "core", 2: "east", 3: "east", 4: "west", 5: "west", 6: "south", 7: "south"}
+	for id := int64(1); id <= 7; id++ {
+		if err := ng.AddNode(&graph.NetworkNode{ID: id, Region: regions[id], Reliability: 1}); err != nil {
+			t.Fatalf("AddNode: %v", err)
+		}
+	}
+	for id := int64(2); id <= 7; id++ {
+		latency := time.Duration(10-id%2*5) * time.Millisecond
+		if err := ng.AddEdge(&graph.NetworkEdge{From: 1, To: id, Latency: latency, Weight: float64(latency.Microseconds()), Reliability: 1}); err != nil {
+			t.Fatalf("AddEdge: %v", err)
+		}
+	}
+
+	config := DefaultRoutingConfig()
+	config.OptimizationLevel = FastLookup
+	return NewRoutingTable(ng, nil, nil, config)
+}
+
+func TestDRPlansRedirectFailedRegions(t *testing.T) {
+	table := newRegionTable(t)
+	if err := table.DRPlans().Replace(map[string][]FailoverTarget{
+		"east": {{Region: "west", MaxFlows: 1}, {Region: "south"}},
+	}); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	lookup := func(destination int64) *RoutingResponse {
+		t.Helper()
+		response, err := table.LookupRoute(RoutingRequest{Source: 1, Destination: destination, Context: context.Background()})
+		if err != nil {
+			t.Fatalf("LookupRoute to %d: %v", destination, err)
+		}
+		return response
+	}
+
+	if response := lookup(2); response.Failover != nil || response.Route.Destination != 2 {
+		t.Fatalf("healthy region redirected: %+v", response.Failover)
+	}
+
+	if !table.FailRegion("east") || table.FailRegion("east") {
+		t.Fatal("FailRegion did not report the failure exactly once")
+	}
+
+	// The first flow takes the west region's only slot, at its nearest node;
+	// the next overflows to the south
+	response := lookup(2)
+	if failover := response.Failover; failover == nil || failover.FailedRegion != "east" || failover.Region != "west" || failover.OriginalDestination != 2 {
+		t.Fatalf("first flow failover: %+v", failover)
+	}
+	if response.Route.Destination != 5 {
+		t.Errorf("first flow routed to %d, want the nearest west node 5", response.Route.Destination)
+	}
+	if response := lookup(3); response.Failover == nil || response.Failover.Region != "south" || response.Route.Destination != 7 {
+		t.Errorf("overflow flow: %+v to %d", response.Failover, response.Route.Destination)
+	}
+
+	// Flows stay in the region they were redirected to, cached or not
+	if response := lookup(2); response.Failover == nil || response.Failover.Region != "west" || !response.CacheHit {
+		t.Errorf("repeated flow: %+v, cache hit %v", response.Failover, response.CacheHit)
+	}
+	status := table.DRPlans().Status()
+	if len(status) != 1 || !status[0].Active || status[0].Flows["west"] != 1 || status[0].Flows["south"] != 1 {
+		t.Errorf("plan status: %+v", status)
+	}
+
+	// Unhealthy failover nodes are skipped; with none left the lookup fails
+	table.UpdateNodeHealth(6, false, NodeHealthMetrics{})
+	table.UpdateNodeHealth(7, false, NodeHealthMetrics{})
+	if _, err := table.LookupRoute(RoutingRequest{Source: 4, Destination: 3, Context: context.Background()}); !errors.Is(err, ErrNoFailoverRegion) {
+		t.Errorf("lookup without failover capacity: got %v, want ErrNoFailoverRegion", err)
+	}
+
+	if !table.RecoverRegion("east") {
+		t.Fatal("RecoverRegion reported the region was not failed")
+	}
+	if response := lookup(2); response.Failover != nil || response.Route.Destination != 2 {
+		t.Errorf("recovered region still redirected: %+v", response.Failover)
+	}
+}
+
+func TestValidateDRPlans(t *testing.T) {
+	for name, plans := range map[string]map[string][]FailoverTarget{
+		"no failover":   {"east": nil},
+		"self failover": {"east": {{Region: "east"}}},
+		"duplicate":     {"east": {{Region: "west"}, {Region: "west"}}},
+		"negative cap":  {"east": {{Region: "west", MaxFlows: -1}}},
+		"no region":     {"": {{Region: "west"}}},
+	} {
+		if err := ValidateDRPlans(plans); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+	if err := ValidateDRPlans(map[string][]FailoverTarget{"east": {{Region: "west"}, {Region: "south", MaxFlows: 10}}}); err != nil {
+		t.Errorf("valid plan rejected: %v", err)
+	}
+}
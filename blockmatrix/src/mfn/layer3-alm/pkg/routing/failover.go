@@ -0,0 +1,188 @@
+// Package routing implements failover detection for route selections
+package routing
+
+import (
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+const (
+	// failoverEventBuffer is the failover events queued for a reader before
+	// further events are dropped
+	failoverEventBuffer = 64
+
+	// recentFailoverEvents is the failover events kept for statistics
+	recentFailoverEvents = 16
+)
+
+// FailoverReason is why the path previously selected between two nodes was
+// abandoned
+type FailoverReason string
+
+const (
+	FailoverUnhealthyNode  FailoverReason = "unhealthy_node"
+	FailoverNodeAtCapacity FailoverReason = "node_at_capacity"
+)
+
+// FailoverEvent records traffic between two nodes moving off the path
+// previously selected for it because a node on that path failed
+type FailoverEvent struct {
+	Source      int64
+	Destination int64
+
+	// Node IDs of the abandoned and newly selected paths
+	FromPath []int64
+	ToPath   []int64
+
+	// The first failed node on FromPath and why it failed
+	FailedNode int64
+	Reason     FailoverReason
+
+	Time time.Time
+}
+
+// flowKey identifies the traffic between two nodes
+type flowKey struct {
+	source      int64
+	destination int64
+}
+
+// flowSelection is the path last selected for a flow
+type flowSelection struct {
+	fingerprint uint64
+	path        []int64
+	selectedAt  time.Time
+}
+
+// RecordSelection notes the route selected for traffic from source to
+// destination needing bandwidth Mbps. If it replaces a previously selected
+// path that crosses an unhealthy node or a node without capacity, a
+// failover is counted and reported on FailoverEvents.
+func (lb *LoadBalancer) RecordSelection(source, destination int64, route *RouteEntry, bandwidth float64) {
+	if route == nil || len(route.Path) == 0 {
+		return
+	}
+
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	now := time.Now()
+	key := flowKey{source: source, destination: destination}
+	fingerprint := route.Fingerprint()
+	previous, exists := lb.selections[key]
+	if exists && previous.fingerprint == fingerprint {
+		previous.selectedAt = now
+		return
+	}
+
+	path := make([]int64, len(route.Path))
+	for i, node := range route.Path {
+		path[i] = node.ID
+	}
+	if exists {
+		if failedNode, reason, failed := lb.pathFailure(previous.path, bandwidth); failed {
+			lb.recordFailover(FailoverEvent{
+				Source:      source,
+				Destination: destination,
+				FromPath:    previous.path,
+				ToPath:      path,
+				FailedNode:  failedNode,
+				Reason:      reason,
+				Time:        now,
+			})
+		}
+	} else if len(lb.selections) >= maxTrackedPaths {
+		for key, selection := range lb.selections {
+			if now.Sub(selection.selectedAt) > pathLoadExpiry {
+				delete(lb.selections, key)
+			}
+		}
+		if len(lb.selections) >= maxTrackedPaths {
+			return
+		}
+	}
+	lb.selections[key] = &flowSelection{fingerprint: fingerprint, path: path, selectedAt: now}
+}
+
+// FailoverEvents returns the channel failover events are reported on. Events
+// are dropped while the channel is full, so it should have a single reader
+// that keeps up.
+func (lb *LoadBalancer) FailoverEvents() <-chan FailoverEvent {
+	return lb.failovers
+}
+
+// HealthyRoutes returns the routes crossing no unhealthy node, in order, or
+// every route if each crosses one
+func (lb *LoadBalancer) HealthyRoutes(routes []*RouteEntry) []*RouteEntry {
+	lb.mutex.RLock()
+	defer lb.mutex.RUnlock()
+
+	healthy := make([]*RouteEntry, 0, len(routes))
+	for _, route := range routes {
+		if lb.healthy(route.Path) {
+			healthy = append(healthy, route)
+		}
+	}
+	if len(healthy) == 0 {
+		return routes
+	}
+	return healthy
+}
+
+// RouteUsable reports whether a route crosses only healthy nodes with
+// capacity for another request needing bandwidth Mbps
+func (lb *LoadBalancer) RouteUsable(route *RouteEntry, bandwidth float64) bool {
+	lb.mutex.RLock()
+	defer lb.mutex.RUnlock()
+
+	return lb.healthy(route.Path) && lb.admits(route.Path, bandwidth)
+}
+
+// healthy reports whether no node on a path is known to be unhealthy. The
+// caller holds the lock.
+func (lb *LoadBalancer) healthy(path []*graph.NetworkNode) bool {
+	for _, node := range path {
+		if nodeInfo, exists := lb.nodeLoads[node.ID]; exists && !nodeInfo.IsHealthy {
+			return false
+		}
+	}
+	return true
+}
+
+// pathFailure returns the first node on a path that is unhealthy or has no
+// capacity for another request needing bandwidth Mbps. The caller holds the
+// lock.
+func (lb *LoadBalancer) pathFailure(nodeIDs []int64, bandwidth float64) (int64, FailoverReason, bool) {
+	for _, nodeID := range nodeIDs {
+		nodeInfo, exists := lb.nodeLoads[nodeID]
+		if !exists {
+			continue
+		}
+		if !nodeInfo.IsHealthy {
+			return nodeID, FailoverUnhealthyNode, true
+		}
+		if !nodeInfo.admits(bandwidth) {
+			return nodeID, FailoverNodeAtCapacity, true
+		}
+	}
+	return 0, "", false
+}
+
+// recordFailover counts a failover, keeps it for statistics and reports it
+// without blocking. The caller holds the write lock.
+func (lb *LoadBalancer) recordFailover(event FailoverEvent) {
+	lb.stats.recordFailover()
+
+	if len(lb.recentFailovers) == recentFailoverEvents {
+		copy(lb.recentFailovers, lb.recentFailovers[1:])
+		lb.recentFailovers = lb.recentFailovers[:recentFailoverEvents-1]
+	}
+	lb.recentFailovers = append(lb.recentFailovers, event)
+
+	select {
+	case lb.failovers <- event:
+	default:
+		lb.stats.DroppedFailovers.Add(1)
+	}
+}
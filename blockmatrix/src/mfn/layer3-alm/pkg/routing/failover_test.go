@@ -0,0 +1,84 @@
+package routing
+
+import (
+	"testing"
+)
+
+func TestLoadBalancerReportsFailovers(t *testing.T) {
+	lb := NewLoadBalancer(0.8, 100)
+	primary := &RouteEntry{Path: nodePath(1, 2, 4)}
+	backup := &RouteEntry{Path: nodePath(1, 3, 4)}
+
+	// Moving off a healthy path is not a failover
+	lb.RecordSelection(1, 4, primary, 0)
+	lb.RecordSelection(1, 4, backup, 0)
+	if stats := lb.GetLoadBalancerStats(); stats.FailoverEvents != 0 {
+		t.Fatalf("switching between healthy paths counted %d failovers", stats.FailoverEvents)
+	}
+
+	// Moving off a path through an unhealthy node is
+	lb.UpdateNodeHealth(3, false, NodeHealthMetrics{})
+	lb.RecordSelection(1, 4, primary, 0)
+	select {
+	case event := <-lb.FailoverEvents():
+		if event.Source != 1 || event.Destination != 4 || event.FailedNode != 3 || event.Reason != FailoverUnhealthyNode {
+			t.Errorf("unexpected failover event %+v", event)
+		}
+		if len(event.FromPath) != 3 || event.FromPath[1] != 3 || event.ToPath[1] != 2 {
+			t.Errorf("failover from %v to %v, want from [1 3 4] to [1 2 4]", event.FromPath, event.ToPath)
+		}
+	default:
+		t.Fatal("no failover event reported")
+	}
+
+	// Selecting the same path again reports nothing more
+	lb.RecordSelection(1, 4, primary, 0)
+	stats := lb.GetLoadBalancerStats()
+	if stats.FailoverEvents != 1 || len(stats.RecentFailovers) != 1 {
+		t.Errorf("recorded %d failovers with %d recent, want 1", stats.FailoverEvents, len(stats.RecentFailovers))
+	}
+}
+
+func TestLoadBalancerDropsFailoversWithoutReader(t *testing.T) {
+	lb := NewLoadBalancer(0.8, 100)
+	lb.UpdateNodeHealth(2, false, NodeHealthMetrics{})
+	lb.UpdateNodeHealth(3, false, NodeHealthMetrics{})
+
+	failovers := failoverEventBuffer + 3
+	for i := 0; i < failovers; i++ {
+		via := int64(2 + i%2)
+		lb.RecordSelection(1, 4, &RouteEntry{Path: nodePath(1, via, 4)}, 0)
+	}
+
+	// The first selection had no previous path to fail over from
+	stats := lb.GetLoadBalancerStats()
+	if stats.FailoverEvents != int64(failovers-1) {
+		t.Errorf("counted %d failovers, want %d", stats.FailoverEvents, failovers-1)
+	}
+	if stats.DroppedFailovers != int64(failovers-1-failoverEventBuffer) {
+		t.Errorf("dropped %d failover events, want %d", stats.DroppedFailovers, failovers-1-failoverEventBuffer)
+	}
+	if len(stats.RecentFailovers) != recentFailoverEvents {
+		t.Errorf("kept %d recent failovers, want %d", len(stats.RecentFailovers), recentFailoverEvents)
+	}
+}
+
+func TestUnhealthyNodeInvalidatesCachedRoutes(t *testing.T) {
+	table, requests := newLookupTable(t, FastLookup)
+	request := requests[0]
+	response, err := table.LookupRoute(request)
+	if err != nil || !response.CacheHit {
+		t.Fatalf("warm lookup missed the cache: %v", err)
+	}
+
+	// A healthy report keeps the route, an unhealthy one drops it
+	destination := request.Destination
+	table.UpdateNodeHealth(destination, true, NodeHealthMetrics{})
+	if response, _ := table.LookupRoute(request); !response.CacheHit {
+		t.Fatal("healthy node report dropped its cached route")
+	}
+	table.UpdateNodeHealth(destination, false, NodeHealthMetrics{})
+	if response, _ := table.LookupRoute(request); response.CacheHit {
+		t.Fatal("route through an unhealthy node was served from the cache")
+	}
+}
@@ -0,0 +1,196 @@
+// Package routing implements health checks of the next hops of cached routes
+package routing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultHealthCheckInterval is how often next hops are probed when no
+	// interval is configured
+	defaultHealthCheckInterval = 30 * time.Second
+
+	// defaultHealthCheckFailures is the consecutive failed probes that mark
+	// a node unhealthy when none is configured
+	defaultHealthCheckFailures = 3
+
+	// maxConcurrentProbes bounds the probes a health check runs at once
+	maxConcurrentProbes = 16
+)
+
+// NodeProbe checks that a node answers, returning an error if it does not
+// before ctx is done
+type NodeProbe func(ctx context.Context, nodeID int64) error
+
+// HealthChecker probes the next hops of cached routes every
+// HealthCheckInterval and feeds the results into the routing table's node
+// health. A next hop failing HealthCheckFailures probes in a row is marked
+// unhealthy, dropping the cached routes through it; it is probed until it
+// answers again.
+type HealthChecker struct {
+	table *RoutingTable
+
+	mutex sync.Mutex
+	probe NodeProbe
+
+	// Consecutive failed probes per node
+	failures map[int64]int
+}
+
+// HealthCheckResult summarizes one round of probes
+type HealthCheckResult struct {
+	Probed    int
+	Failed    int
+	Unhealthy []int64
+}
+
+// NewHealthChecker creates a health checker for a routing table. Rounds are
+// skipped while probe is nil.
+func NewHealthChecker(table *RoutingTable, probe NodeProbe) *HealthChecker {
+	return &HealthChecker{
+		table:    table,
+		probe:    probe,
+		failures: make(map[int64]int),
+	}
+}
+
+// SetProbe replaces the probe used by the next round
+func (hc *HealthChecker) SetProbe(probe NodeProbe) {
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+
+	hc.probe = probe
+}
+
+// Run probes next hops every HealthCheckInterval until ctx is cancelled
+func (hc *HealthChecker) Run(ctx context.Context) {
+	interval := hc.interval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			hc.Check(ctx, interval)
+
+			if next := hc.interval(); next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+		}
+	}
+}
+
+// Check probes the next hops of cached routes, and nodes still failing
+// earlier probes, once each, giving every probe up to timeout
+func (hc *HealthChecker) Check(ctx context.Context, timeout time.Duration) HealthCheckResult {
+	hc.mutex.Lock()
+	probe := hc.probe
+	nodeIDs := hc.table.routeCache.NextHops()
+	for nodeID := range hc.failures {
+		if _, exists := hc.table.networkGraph.GetNode(nodeID); !exists {
+			delete(hc.failures, nodeID)
+			continue
+		}
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	hc.mutex.Unlock()
+
+	var result HealthCheckResult
+	if probe == nil {
+		return result
+	}
+
+	nodeIDs = uniqueNodeIDs(nodeIDs)
+	errs := make([]error, len(nodeIDs))
+	latencies := make([]time.Duration, len(nodeIDs))
+	semaphore := make(chan struct{}, maxConcurrentProbes)
+	var wg sync.WaitGroup
+	for i, nodeID := range nodeIDs {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, nodeID int64) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			probeCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			start := time.Now()
+			errs[i] = probe(probeCtx, nodeID)
+			latencies[i] = time.Since(start)
+		}(i, nodeID)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return result
+	}
+
+	threshold := hc.table.Config().HealthCheckFailures
+	if threshold <= 0 {
+		threshold = defaultHealthCheckFailures
+	}
+
+	result.Probed = len(nodeIDs)
+	for i, nodeID := range nodeIDs {
+		if errs[i] == nil {
+			hc.recordSuccess(nodeID, latencies[i])
+			continue
+		}
+
+		result.Failed++
+		if hc.recordFailure(nodeID) == threshold {
+			hc.table.UpdateNodeHealth(nodeID, false, NodeHealthMetrics{})
+			result.Unhealthy = append(result.Unhealthy, nodeID)
+		}
+	}
+
+	return result
+}
+
+// interval returns the configured health check interval, or the default if
+// none is set
+func (hc *HealthChecker) interval() time.Duration {
+	if interval := hc.table.Config().HealthCheckInterval; interval > 0 {
+		return interval
+	}
+	return defaultHealthCheckInterval
+}
+
+// recordSuccess clears a node's failed probes and marks it healthy
+func (hc *HealthChecker) recordSuccess(nodeID int64, latency time.Duration) {
+	hc.mutex.Lock()
+	delete(hc.failures, nodeID)
+	hc.mutex.Unlock()
+
+	hc.table.UpdateNodeHealth(nodeID, true, NodeHealthMetrics{Latency: latency})
+}
+
+// recordFailure counts a failed probe and returns the node's failures in a
+// row
+func (hc *HealthChecker) recordFailure(nodeID int64) int {
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+
+	hc.failures[nodeID]++
+	return hc.failures[nodeID]
+}
+
+// uniqueNodeIDs removes repeated IDs, keeping the first of each
+func uniqueNodeIDs(nodeIDs []int64) []int64 {
+	seen := make(map[int64]bool, len(nodeIDs))
+	unique := nodeIDs[:0]
+	for _, nodeID := range nodeIDs {
+		if !seen[nodeID] {
+			seen[nodeID] = true
+			unique = append(unique, nodeID)
+		}
+	}
+	return unique
+}
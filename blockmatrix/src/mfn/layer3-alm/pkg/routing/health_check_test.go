@@ -0,0 +1,81 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckerMarksFailingNextHops(t *testing.T) {
+	table, requests := newLookupTable(t, FastLookup)
+	request := requests[0]
+	response, err := table.LookupRoute(request)
+	if err != nil {
+		t.Fatalf("lookup failed: %v", err)
+	}
+	nextHop := response.Route.NextHop
+
+	var down atomic.Bool
+	down.Store(true)
+	checker := NewHealthChecker(table, func(ctx context.Context, nodeID int64) error {
+		if nodeID == nextHop && down.Load() {
+			return errors.New("unreachable")
+		}
+		return nil
+	})
+	check := func() HealthCheckResult {
+		return checker.Check(context.Background(), time.Second)
+	}
+
+	// Failures short of the threshold leave the route cached
+	for i := 1; i < defaultHealthCheckFailures; i++ {
+		if result := check(); result.Failed != 1 || len(result.Unhealthy) != 0 {
+			t.Fatalf("round %d: %+v, want one failure and no unhealthy node", i, result)
+		}
+	}
+	if response, _ := table.LookupRoute(request); !response.CacheHit {
+		t.Fatal("route dropped before its next hop reached the failure threshold")
+	}
+
+	result := check()
+	if len(result.Unhealthy) != 1 || result.Unhealthy[0] != nextHop {
+		t.Fatalf("round %d marked %v unhealthy, want [%d]", defaultHealthCheckFailures, result.Unhealthy, nextHop)
+	}
+	if table.loadBalancer.RouteUsable(response.Route, 0) {
+		t.Error("route through the failed next hop is still usable")
+	}
+	if response, _ := table.LookupRoute(request); response != nil && response.CacheHit {
+		t.Error("route through the failed next hop was served from the cache")
+	}
+
+	// The failed node is probed until it answers again, even once no
+	// cached route leads through it
+	table.InvalidateCache()
+	down.Store(false)
+	if result := check(); result.Probed != 1 {
+		t.Errorf("probed %d nodes with an empty cache, want the failed one", result.Probed)
+	}
+	if !table.loadBalancer.RouteUsable(response.Route, 0) {
+		t.Error("next hop answering again was not marked healthy")
+	}
+}
+
+func TestHealthCheckerSkipsRoundsWithoutProbe(t *testing.T) {
+	table, _ := newLookupTable(t, FastLookup)
+	checker := NewHealthChecker(table, nil)
+	if result := checker.Check(context.Background(), time.Second); result.Probed != 0 {
+		t.Errorf("probed %d nodes without a probe", result.Probed)
+	}
+
+	var probes atomic.Int64
+	checker.SetProbe(func(ctx context.Context, nodeID int64) error {
+		probes.Add(1)
+		return nil
+	})
+	result := checker.Check(context.Background(), time.Second)
+	if result.Probed == 0 || probes.Load() != int64(result.Probed) {
+		t.Errorf("probed %d nodes with %d probes, want one probe per cached next hop", result.Probed, probes.Load())
+	}
+}
@@ -4,19 +4,51 @@ package routing
 import (
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
 )
 
+// backpressureDecay is the time constant over which unrefreshed backpressure fades
+const backpressureDecay = 5 * time.Second
+
+const (
+	// maxTrackedPaths bounds the paths whose load is tracked; beyond it,
+	// idle paths not updated for pathLoadExpiry are dropped
+	maxTrackedPaths = 10000
+	pathLoadExpiry  = 5 * time.Minute
+	
+	// pathLoadDecay is the time constant over which path load follows its
+	// traffic, and idle paths' load fades
+	pathLoadDecay = 10 * time.Second
+	
+	// defaultPathRequestCapacity is the requests in flight that fully load
+	// a path
+	defaultPathRequestCapacity = 100
+)
+
 // LoadBalancer manages load balancing across multiple routing paths
 type LoadBalancer struct {
-	// Load tracking per path/node
-	pathLoads    map[string]*PathLoadInfo
+	// Load tracking per path, by fingerprint, and per node
+	pathLoads    map[uint64]*PathLoadInfo
 	nodeLoads    map[int64]*NodeLoadInfo
 	
+	// Requests in flight through each node, capacity or not
+	nodeRequests map[int64]int64
+	
+	// Path last selected per flow, for failover detection, and the
+	// failovers detected
+	selections      map[flowKey]*flowSelection
+	failovers       chan FailoverEvent
+	recentFailovers []FailoverEvent
+	
 	// Configuration
 	threshold    float64
+	requestCapacity int
+	
+	// Bandwidth reserved on edges, which paths must leave room beside
+	reservations *BandwidthReservations
 	
 	// Statistics
 	stats        *LoadBalancerStats
@@ -25,15 +57,22 @@ type LoadBalancer struct {
 	mutex        sync.RWMutex
 }
 
-// PathLoadInfo tracks load information for a specific path
+// PathLoadInfo tracks load information for a specific path. CurrentLoad is
+// the path's utilization, from 0 to 1, smoothed over pathLoadDecay: the
+// larger of its requests in flight as a share of the request capacity and
+// its byte rate as a share of MaxCapacity, in Mbps.
 type PathLoadInfo struct {
-	PathID       string
+	Fingerprint  uint64
 	CurrentLoad  float64
 	MaxCapacity  float64
 	LastUpdated  time.Time
 	
+	// Traffic: requests in flight and the byte rate, in bytes per second,
+	// decayed over pathLoadDecay
+	InFlight     int64
+	ByteRate     float64
+	
 	// Moving averages
-	LoadEMA      *ExponentialMovingAverage
 	LatencyEMA   *ExponentialMovingAverage
 	
 	// Quality metrics
@@ -42,13 +81,19 @@ type PathLoadInfo struct {
 	TotalCount   int64
 }
 
-// NodeLoadInfo tracks load information for individual nodes
+// NodeLoadInfo tracks load information for individual nodes. Once a node's
+// capacity is registered, CurrentLoad is its utilization.
 type NodeLoadInfo struct {
 	NodeID       int64
 	CurrentLoad  float64
-	MaxCapacity  float64
 	LastUpdated  time.Time
 	
+	// Capacity, its reported usage, and the routed requests in flight
+	// through the node, which count as connections
+	Capacity       NodeCapacity
+	Usage          NodeUsage
+	RoutedRequests int64
+	
 	// Health status
 	IsHealthy    bool
 	LastHealthCheck time.Time
@@ -57,16 +102,21 @@ type NodeLoadInfo struct {
 	AverageLatency time.Duration
 	PacketLoss     float64
 	Jitter         time.Duration
+	
+	// Stream flow control pressure reported by transports (0-1)
+	Backpressure        float64
+	BackpressureUpdated time.Time
 }
 
-// LoadBalancerStats tracks load balancer performance
+// LoadBalancerStats tracks load balancer performance with atomic counters,
+// so recording never contends on a lock
 type LoadBalancerStats struct {
-	TotalDecisions      int64
-	LoadBalancedDecisions int64
-	FailoverEvents      int64
-	HealthCheckFailures int64
-	
-	mutex sync.Mutex
+	TotalDecisions      atomic.Int64
+	LoadBalancedDecisions atomic.Int64
+	FailoverEvents      atomic.Int64
+	HealthCheckFailures atomic.Int64
+	CapacityRejections  atomic.Int64
+	DroppedFailovers    atomic.Int64
 }
 
 // ExponentialMovingAverage implements EMA calculation
@@ -85,13 +135,22 @@ type LoadBalancingDecision struct {
 	Confidence      float64
 }
 
-// NewLoadBalancer creates a new load balancer
-func NewLoadBalancer(threshold float64) *LoadBalancer {
+// NewLoadBalancer creates a new load balancer. A path carrying
+// requestCapacity requests at once, or 100 if zero, is fully loaded.
+func NewLoadBalancer(threshold float64, requestCapacity int) *LoadBalancer {
+	if requestCapacity <= 0 {
+		requestCapacity = defaultPathRequestCapacity
+	}
+	
 	return &LoadBalancer{
-		pathLoads:  make(map[string]*PathLoadInfo),
-		nodeLoads:  make(map[int64]*NodeLoadInfo),
-		threshold:  threshold,
-		stats:     &LoadBalancerStats{},
+		pathLoads:       make(map[uint64]*PathLoadInfo),
+		nodeLoads:       make(map[int64]*NodeLoadInfo),
+		nodeRequests:    make(map[int64]int64),
+		selections:      make(map[flowKey]*flowSelection),
+		failovers:       make(chan FailoverEvent, failoverEventBuffer),
+		threshold:       threshold,
+		requestCapacity: requestCapacity,
+		stats:           &LoadBalancerStats{},
 	}
 }
 
@@ -100,9 +159,47 @@ func (lb *LoadBalancer) GetPathLoad(path []*graph.NetworkNode) float64 {
 	lb.mutex.RLock()
 	defer lb.mutex.RUnlock()
 	
-	pathID := lb.generatePathID(path)
-	if loadInfo, exists := lb.pathLoads[pathID]; exists {
-		return loadInfo.CurrentLoad
+	return lb.pathLoad(path)
+}
+
+// TrafficWeights returns the share of traffic recommended for each route,
+// in order, summing to 1. Shares are proportional to the load headroom left
+// on each path; routes crossing an unhealthy node or one without capacity
+// for another request needing bandwidth Mbps get none. If no route has
+// headroom the first gets all traffic.
+func (lb *LoadBalancer) TrafficWeights(routes []*RouteEntry, bandwidth float64) []float64 {
+	if len(routes) == 0 {
+		return nil
+	}
+	
+	lb.mutex.RLock()
+	defer lb.mutex.RUnlock()
+	
+	weights := make([]float64, len(routes))
+	total := 0.0
+	for i, route := range routes {
+		if !lb.healthy(route.Path) || !lb.admits(route.Path, bandwidth) {
+			continue
+		}
+		weights[i] = math.Max(0, 1-lb.pathLoad(route.Path))
+		total += weights[i]
+	}
+	
+	if total == 0 {
+		weights[0] = 1
+		return weights
+	}
+	for i := range weights {
+		weights[i] /= total
+	}
+	return weights
+}
+
+// pathLoad returns the load of a path, from its tracked traffic or else
+// the mean load of its nodes. The caller holds the lock.
+func (lb *LoadBalancer) pathLoad(path []*graph.NetworkNode) float64 {
+	if loadInfo, exists := lb.pathLoads[PathFingerprint(path)]; exists {
+		return loadInfo.loadAt(time.Now(), lb.requestCapacity)
 	}
 	
 	// Calculate load from constituent nodes
@@ -120,7 +217,7 @@ func (lb *LoadBalancer) GetPathLoad(path []*graph.NetworkNode) float64 {
 		return totalLoad / float64(validNodes)
 	}
 	
-	return 0.5 // Default moderate load
+	return 0 // No traffic observed
 }
 
 // SelectOptimalPath selects the best path considering load balancing
@@ -176,14 +273,12 @@ func (lb *LoadBalancer) SelectOptimalPath(candidates []*RouteEntry) *LoadBalanci
 	selectedLoad := pathScores[0].load
 	
 	// Check if load balancing was triggered
-	wasLoadBalanced := false
 	reason := "best_score"
 	
 	if selectedLoad > lb.threshold && len(pathScores) > 1 {
 		// Check if we selected a different path due to load balancing
 		bestQualityRoute := candidates[0] // Assume first is highest quality
 		if selectedPath != bestQualityRoute {
-			wasLoadBalanced = true
 			reason = "load_balanced"
 			lb.stats.recordLoadBalance()
 		}
@@ -209,26 +304,160 @@ func (lb *LoadBalancer) UpdateMetrics(destination int64, metrics RouteMetrics, s
 	lb.mutex.Lock()
 	defer lb.mutex.Unlock()
 	
-	// Update path load information based on actual metrics
-	// This is a simplified implementation - in production would track specific paths
-	
-	// Update node loads based on latency and throughput
+	// Without per-path tracking the observed load is attributed to the
+	// destination node
 	loadFactor := lb.calculateLoadFromMetrics(metrics)
 	
-	// Update moving averages and statistics
-	// Implementation would depend on specific path tracking
+	nodeLoad := lb.nodeLoad(destination)
+	if !nodeLoad.Capacity.limited() {
+		nodeLoad.CurrentLoad = loadFactor
+	}
+	nodeLoad.AverageLatency = metrics.Latency
+	nodeLoad.PacketLoss = metrics.PacketLoss
+	nodeLoad.Jitter = metrics.Jitter
+	nodeLoad.LastUpdated = time.Now()
+	if !success {
+		lb.stats.recordHealthCheckFailure()
+	}
+}
+
+// UpdatePathMetrics records the latency and outcome observed on a route's
+// path
+func (lb *LoadBalancer) UpdatePathMetrics(route *RouteEntry, metrics RouteMetrics, success bool) {
+	if route == nil || len(route.Path) == 0 {
+		return
+	}
+	
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	
+	now := time.Now()
+	loadInfo := lb.trackPath(route.Fingerprint(), now)
+	if loadInfo == nil {
+		return
+	}
+	
+	loadInfo.advance(now, lb.requestCapacity)
+	loadInfo.LatencyEMA.Update(float64(metrics.Latency))
+	loadInfo.TotalCount++
+	if !success {
+		loadInfo.FailureCount++
+	}
+	loadInfo.SuccessRate = float64(loadInfo.TotalCount-loadInfo.FailureCount) / float64(loadInfo.TotalCount)
+}
+
+// BeginPathRequest counts a request in flight on the path through nodeIDs,
+// which carries up to capacity Mbps, towards its load and its nodes'
+func (lb *LoadBalancer) BeginPathRequest(nodeIDs []int64, capacity float64) {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	
+	lb.routeRequest(nodeIDs, 1)
+	
+	now := time.Now()
+	loadInfo := lb.trackPath(NodeIDsFingerprint(nodeIDs), now)
+	if loadInfo == nil {
+		return
+	}
+	
+	loadInfo.advance(now, lb.requestCapacity)
+	loadInfo.InFlight++
+	if capacity > 0 {
+		loadInfo.MaxCapacity = capacity
+	}
+}
+
+// EndPathRequest ends a request begun with BeginPathRequest, counting the
+// bytes it carried towards the path's byte rate
+func (lb *LoadBalancer) EndPathRequest(nodeIDs []int64, bytes int64) {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	
+	lb.routeRequest(nodeIDs, -1)
+	
+	loadInfo, exists := lb.pathLoads[NodeIDsFingerprint(nodeIDs)]
+	if !exists {
+		return
+	}
+	
+	loadInfo.advance(time.Now(), lb.requestCapacity)
+	if loadInfo.InFlight > 0 {
+		loadInfo.InFlight--
+	}
+	loadInfo.ByteRate += float64(bytes) / pathLoadDecay.Seconds()
+}
+
+// trackPath returns the load of a path, tracking it if it is new. It
+// returns nil if maxTrackedPaths are tracked and none can be dropped. The
+// caller holds the write lock.
+func (lb *LoadBalancer) trackPath(fingerprint uint64, now time.Time) *PathLoadInfo {
+	if loadInfo, exists := lb.pathLoads[fingerprint]; exists {
+		return loadInfo
+	}
+	
+	if len(lb.pathLoads) >= maxTrackedPaths {
+		lb.expirePathLoads(now)
+		if len(lb.pathLoads) >= maxTrackedPaths {
+			return nil
+		}
+	}
+	loadInfo := &PathLoadInfo{
+		Fingerprint: fingerprint,
+		LastUpdated: now,
+		LatencyEMA:  NewExponentialMovingAverage(0.2),
+	}
+	lb.pathLoads[fingerprint] = loadInfo
+	return loadInfo
+}
+
+// expirePathLoads drops the load of idle paths not updated for
+// pathLoadExpiry. The caller holds the write lock.
+func (lb *LoadBalancer) expirePathLoads(now time.Time) {
+	for fingerprint, loadInfo := range lb.pathLoads {
+		if loadInfo.InFlight == 0 && now.Sub(loadInfo.LastUpdated) > pathLoadExpiry {
+			delete(lb.pathLoads, fingerprint)
+		}
+	}
+}
+
+// utilization returns the path's load from its traffic right now
+func (pli *PathLoadInfo) utilization(requestCapacity int) float64 {
+	load := float64(pli.InFlight) / float64(requestCapacity)
+	if pli.MaxCapacity > 0 {
+		megabits := pli.ByteRate * 8 / 1e6
+		load = math.Max(load, megabits/pli.MaxCapacity)
+	}
+	return math.Min(load, 1.0)
+}
+
+// loadAt returns CurrentLoad moved towards the traffic seen since the last
+// update, as advance would set it at now
+func (pli *PathLoadInfo) loadAt(now time.Time, requestCapacity int) float64 {
+	decay := math.Exp(-float64(now.Sub(pli.LastUpdated)) / float64(pathLoadDecay))
+	target := pli.utilization(requestCapacity)
+	return target + (pli.CurrentLoad-target)*decay
+}
+
+// advance brings the smoothed load and decayed byte rate up to now, before
+// the path's traffic changes
+func (pli *PathLoadInfo) advance(now time.Time, requestCapacity int) {
+	if !now.After(pli.LastUpdated) {
+		return
+	}
+	
+	pli.CurrentLoad = pli.loadAt(now, requestCapacity)
+	pli.ByteRate *= math.Exp(-float64(now.Sub(pli.LastUpdated)) / float64(pathLoadDecay))
+	pli.LastUpdated = now
 }
 
 // GetLoadBalanceRate returns the percentage of decisions that involved load balancing
 func (lb *LoadBalancer) GetLoadBalanceRate() float64 {
-	lb.stats.mutex.Lock()
-	defer lb.stats.mutex.Unlock()
-	
-	if lb.stats.TotalDecisions == 0 {
+	total := lb.stats.TotalDecisions.Load()
+	if total == 0 {
 		return 0.0
 	}
 	
-	return float64(lb.stats.LoadBalancedDecisions) / float64(lb.stats.TotalDecisions) * 100.0
+	return float64(lb.stats.LoadBalancedDecisions.Load()) / float64(total) * 100.0
 }
 
 // UpdateNodeHealth updates the health status of a node
@@ -258,6 +487,19 @@ func (lb *LoadBalancer) UpdateNodeHealth(nodeID int64, isHealthy bool, metrics N
 	}
 }
 
+// UpdateNodeBackpressure records transport flow control pressure towards a
+// node. Pressure decays over backpressureDecay unless it is reported again.
+func (lb *LoadBalancer) UpdateNodeBackpressure(nodeID int64, pressure float64) {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	
+	pressure = math.Max(0.0, math.Min(1.0, pressure))
+	
+	nodeInfo := lb.nodeLoad(nodeID)
+	nodeInfo.Backpressure = pressure
+	nodeInfo.BackpressureUpdated = time.Now()
+}
+
 // GetNodeHealth returns the health status of a node
 func (lb *LoadBalancer) GetNodeHealth(nodeID int64) (bool, *NodeLoadInfo) {
 	lb.mutex.RLock()
@@ -272,17 +514,25 @@ func (lb *LoadBalancer) GetNodeHealth(nodeID int64) (bool, *NodeLoadInfo) {
 
 // GetLoadBalancerStats returns current load balancer statistics
 func (lb *LoadBalancer) GetLoadBalancerStats() LoadBalancerStatistics {
-	lb.stats.mutex.Lock()
-	defer lb.stats.mutex.Unlock()
+	loadBalanceRate := lb.GetLoadBalanceRate()
+	
+	lb.mutex.RLock()
+	trackedPaths := len(lb.pathLoads)
+	trackedNodes := len(lb.nodeLoads)
+	recentFailovers := append([]FailoverEvent(nil), lb.recentFailovers...)
+	lb.mutex.RUnlock()
 	
 	return LoadBalancerStatistics{
-		TotalDecisions:        lb.stats.TotalDecisions,
-		LoadBalancedDecisions: lb.stats.LoadBalancedDecisions,
-		LoadBalanceRate:       lb.GetLoadBalanceRate(),
-		FailoverEvents:        lb.stats.FailoverEvents,
-		HealthCheckFailures:   lb.stats.HealthCheckFailures,
-		TrackedPaths:         len(lb.pathLoads),
-		TrackedNodes:         len(lb.nodeLoads),
+		TotalDecisions:        lb.stats.TotalDecisions.Load(),
+		LoadBalancedDecisions: lb.stats.LoadBalancedDecisions.Load(),
+		LoadBalanceRate:       loadBalanceRate,
+		FailoverEvents:        lb.stats.FailoverEvents.Load(),
+		DroppedFailovers:      lb.stats.DroppedFailovers.Load(),
+		RecentFailovers:       recentFailovers,
+		HealthCheckFailures:   lb.stats.HealthCheckFailures.Load(),
+		CapacityRejections:    lb.stats.CapacityRejections.Load(),
+		TrackedPaths:         trackedPaths,
+		TrackedNodes:         trackedNodes,
 	}
 }
 
@@ -305,27 +555,14 @@ type LoadBalancerStatistics struct {
 	LoadBalancedDecisions int64
 	LoadBalanceRate       float64
 	FailoverEvents        int64
+	DroppedFailovers      int64
+	RecentFailovers       []FailoverEvent
 	HealthCheckFailures   int64
+	CapacityRejections    int64
 	TrackedPaths         int
 	TrackedNodes         int
 }
 
-// generatePathID creates a unique identifier for a path
-func (lb *LoadBalancer) generatePathID(path []*graph.NetworkNode) string {
-	if len(path) == 0 {
-		return ""
-	}
-	
-	pathID := ""
-	for i, node := range path {
-		if i > 0 {
-			pathID += "-"
-		}
-		pathID += string(rune(node.ID))
-	}
-	return pathID
-}
-
 // calculatePathLoad calculates the current load for a path
 func (lb *LoadBalancer) calculatePathLoad(route *RouteEntry) float64 {
 	if route == nil || len(route.Path) == 0 {
@@ -340,10 +577,32 @@ func (lb *LoadBalancer) calculatePathLoad(route *RouteEntry) float64 {
 	// Combine loads
 	combinedLoad := (latencyLoad*0.4 + throughputLoad*0.4 + reliabilityLoad*0.2)
 	
+	// A path is at least as loaded as its most back-pressured node
+	combinedLoad = math.Max(combinedLoad, lb.calculatePathBackpressure(route))
+	
 	// Clamp to 0-1 range
 	return math.Max(0.0, math.Min(1.0, combinedLoad))
 }
 
+// calculatePathBackpressure returns the highest decayed backpressure along a path
+func (lb *LoadBalancer) calculatePathBackpressure(route *RouteEntry) float64 {
+	now := time.Now()
+	maxPressure := 0.0
+	
+	for _, node := range route.Path {
+		nodeInfo, exists := lb.nodeLoads[node.ID]
+		if !exists || nodeInfo.Backpressure == 0 {
+			continue
+		}
+		
+		age := now.Sub(nodeInfo.BackpressureUpdated)
+		pressure := nodeInfo.Backpressure * math.Exp(-float64(age)/float64(backpressureDecay))
+		maxPressure = math.Max(maxPressure, pressure)
+	}
+	
+	return maxPressure
+}
+
 // calculatePathHealth calculates the overall health score for a path
 func (lb *LoadBalancer) calculatePathHealth(route *RouteEntry) float64 {
 	if route == nil || len(route.Path) == 0 {
@@ -407,25 +666,17 @@ func (ema *ExponentialMovingAverage) Value() float64 {
 // Statistics recording methods
 
 func (lbs *LoadBalancerStats) recordDecision() {
-	lbs.mutex.Lock()
-	defer lbs.mutex.Unlock()
-	lbs.TotalDecisions++
+	lbs.TotalDecisions.Add(1)
 }
 
 func (lbs *LoadBalancerStats) recordLoadBalance() {
-	lbs.mutex.Lock()
-	defer lbs.mutex.Unlock()
-	lbs.LoadBalancedDecisions++
+	lbs.LoadBalancedDecisions.Add(1)
 }
 
 func (lbs *LoadBalancerStats) recordFailover() {
-	lbs.mutex.Lock()
-	defer lbs.mutex.Unlock()
-	lbs.FailoverEvents++
+	lbs.FailoverEvents.Add(1)
 }
 
 func (lbs *LoadBalancerStats) recordHealthCheckFailure() {
-	lbs.mutex.Lock()
-	defer lbs.mutex.Unlock()
-	lbs.HealthCheckFailures++
+	lbs.HealthCheckFailures.Add(1)
 }
\ No newline at end of file
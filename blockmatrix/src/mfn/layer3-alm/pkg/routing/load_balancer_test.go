@@ -0,0 +1,126 @@
+package routing
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLoadBalancerPathLoadFromTraffic(t *testing.T) {
+	lb := NewLoadBalancer(0.8, 10)
+	busyIDs := []int64{0x110000, 0x110001}
+	busy, idle := nodePath(busyIDs...), nodePath(0x110002, 0x110003)
+	fingerprint := PathFingerprint(busy)
+
+	// Requests in flight drive the load towards their share of capacity
+	for i := 0; i < 8; i++ {
+		lb.BeginPathRequest(busyIDs, 0)
+	}
+	lb.pathLoads[fingerprint].LastUpdated = time.Now().Add(-10 * pathLoadDecay)
+	if load := lb.GetPathLoad(busy); math.Abs(load-0.8) > 0.01 {
+		t.Errorf("path with 8 of 10 requests in flight has load %.3f, want 0.8", load)
+	}
+	if load := lb.GetPathLoad(idle); load != 0 {
+		t.Errorf("path without traffic has load %v, want 0", load)
+	}
+
+	// Once they end the load fades
+	for i := 0; i < 8; i++ {
+		lb.EndPathRequest(busyIDs, 0)
+	}
+	lb.pathLoads[fingerprint].LastUpdated = time.Now().Add(-10 * pathLoadDecay)
+	if load := lb.GetPathLoad(busy); load > 0.01 {
+		t.Errorf("idle path kept load %.3f", load)
+	}
+}
+
+func TestLoadBalancerPathLoadFromBytes(t *testing.T) {
+	lb := NewLoadBalancer(0.8, 100)
+	route := &RouteEntry{Path: nodePath(1, 2)}
+
+	// 1 Mbps sustained over the decay window, on a 2 Mbps path
+	lb.BeginPathRequest([]int64{1, 2}, 2)
+	lb.EndPathRequest([]int64{1, 2}, int64(pathLoadDecay.Seconds()*1e6/8))
+	loadInfo := lb.pathLoads[route.Fingerprint()]
+	if megabits := loadInfo.ByteRate * 8 / 1e6; math.Abs(megabits-1) > 0.01 {
+		t.Errorf("byte rate %.3f Mbps, want 1", megabits)
+	}
+	if utilization := loadInfo.utilization(lb.requestCapacity); math.Abs(utilization-0.5) > 0.01 {
+		t.Errorf("utilization %.3f, want 0.5", utilization)
+	}
+
+	// Feedback updates the path's quality but not its load
+	lb.UpdatePathMetrics(route, RouteMetrics{Latency: time.Second}, false)
+	if loadInfo.SuccessRate != 0 || loadInfo.TotalCount != 1 {
+		t.Errorf("success rate %v over %d reports, want 0 over 1", loadInfo.SuccessRate, loadInfo.TotalCount)
+	}
+	if tracked := lb.GetLoadBalancerStats().TrackedPaths; tracked != 1 {
+		t.Errorf("tracking %d paths, want 1", tracked)
+	}
+}
+
+func TestLoadBalancerAdmitsPathsWithinNodeCapacity(t *testing.T) {
+	lb := NewLoadBalancer(0.8, 100)
+	lb.SetNodeCapacity(2, NodeCapacity{CPU: 4, Bandwidth: 100, Connections: 2})
+	through := &RouteEntry{Path: nodePath(1, 2, 3)}
+	around := &RouteEntry{Path: nodePath(1, 4, 3)}
+
+	if !lb.AdmitsRoute(through, 50) {
+		t.Fatal("idle node with capacity rejected a route")
+	}
+	if lb.AdmitsRoute(through, 150) {
+		t.Error("route needing more bandwidth than the node has was admitted")
+	}
+
+	// Routed requests fill the node's connection slots
+	lb.BeginPathRequest([]int64{1, 2, 3}, 0)
+	lb.UpdateNodeUsage(2, NodeUsage{CPU: 1, Connections: 1})
+	if _, nodeInfo := lb.GetNodeHealth(2); nodeInfo.CurrentLoad != 1 {
+		t.Errorf("node with every connection slot used has load %v, want 1", nodeInfo.CurrentLoad)
+	}
+	admitted := lb.AdmitRoutes([]*RouteEntry{through, around}, 0)
+	if len(admitted) != 1 || admitted[0] != around {
+		t.Errorf("admitted %d routes, want only the route around the full node", len(admitted))
+	}
+	if rejections := lb.GetLoadBalancerStats().CapacityRejections; rejections != 1 {
+		t.Errorf("counted %d capacity rejections, want 1", rejections)
+	}
+
+	lb.EndPathRequest([]int64{1, 2, 3}, 0)
+	if !lb.AdmitsRoute(through, 0) {
+		t.Error("route rejected after a connection slot freed up")
+	}
+
+	// A node using all its CPU admits nothing
+	lb.UpdateNodeUsage(2, NodeUsage{CPU: 4})
+	if lb.AdmitsRoute(through, 0) {
+		t.Error("route through a node at full CPU was admitted")
+	}
+}
+
+func TestLoadBalancerTrafficWeights(t *testing.T) {
+	lb := NewLoadBalancer(0.8, 100)
+	busy := &RouteEntry{Path: nodePath(1, 2, 9)}
+	idle := &RouteEntry{Path: nodePath(1, 3, 9)}
+	down := &RouteEntry{Path: nodePath(1, 4, 9)}
+
+	// Node 2 is half loaded, so its path has half the headroom
+	lb.SetNodeCapacity(2, NodeCapacity{Connections: 10})
+	lb.UpdateNodeUsage(2, NodeUsage{Connections: 5})
+	lb.UpdateNodeHealth(4, false, NodeHealthMetrics{})
+
+	weights := lb.TrafficWeights([]*RouteEntry{busy, idle, down}, 0)
+	want := []float64{0.5 / 1.5, 1 / 1.5, 0}
+	for i := range want {
+		if math.Abs(weights[i]-want[i]) > 1e-9 {
+			t.Fatalf("weights %v, want %v", weights, want)
+		}
+	}
+
+	// Without headroom anywhere the first route keeps all traffic
+	lb.UpdateNodeHealth(3, false, NodeHealthMetrics{})
+	lb.UpdateNodeUsage(2, NodeUsage{Connections: 10})
+	if weights := lb.TrafficWeights([]*RouteEntry{busy, idle, down}, 0); weights[0] != 1 || weights[1] != 0 || weights[2] != 0 {
+		t.Errorf("weights without headroom %v, want all on the first route", weights)
+	}
+}
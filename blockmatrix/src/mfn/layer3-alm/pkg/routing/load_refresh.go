@@ -0,0 +1,66 @@
+// Package routing implements live load refresh of cached routes
+package routing
+
+import (
+	"context"
+	"time"
+)
+
+// defaultLoadRefreshInterval is how often cached route loads are refreshed
+// when no interval is configured
+const defaultLoadRefreshInterval = time.Second
+
+// RefreshCachedLoads sets the load of each cached route used since a time to
+// the current load of its path. A route whose load rises above the load
+// balancing threshold is removed, so the next lookup rediscovers and can
+// balance onto another path. It returns the number of routes refreshed.
+func (rt *RoutingTable) RefreshCachedLoads(since time.Time) int {
+	threshold := rt.Config().LoadBalanceThreshold
+
+	refreshed, saturated := rt.routeCache.RefreshHot(since, func(route *RouteEntry) bool {
+		load := rt.loadBalancer.GetPathLoad(route.Path)
+		if load > threshold && route.Metrics.Load <= threshold {
+			return false
+		}
+		route.Metrics.Load = load
+		return true
+	})
+	for i := 0; i < saturated; i++ {
+		rt.metrics.RecordInvalidation("path_saturated")
+	}
+
+	return refreshed
+}
+
+// RunLoadRefresh refreshes the loads of routes served from the cache every
+// LoadRefreshInterval until ctx is cancelled
+func (rt *RoutingTable) RunLoadRefresh(ctx context.Context) {
+	interval := rt.loadRefreshInterval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	since := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			rt.RefreshCachedLoads(since)
+			since = now
+
+			if next := rt.loadRefreshInterval(); next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+		}
+	}
+}
+
+// loadRefreshInterval returns the configured load refresh interval, or the
+// default if none is set
+func (rt *RoutingTable) loadRefreshInterval() time.Duration {
+	if interval := rt.Config().LoadRefreshInterval; interval > 0 {
+		return interval
+	}
+	return defaultLoadRefreshInterval
+}
@@ -0,0 +1,49 @@
+package routing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefreshCachedLoadsDropsSaturatedRoutes(t *testing.T) {
+	table, requests := newLookupTable(t, FastLookup)
+	request := requests[0]
+	response, err := table.LookupRoute(request)
+	if err != nil || !response.CacheHit {
+		t.Fatalf("warm lookup missed the cache: %v", err)
+	}
+	cached := response.Route
+	setPathUsage := func(connections int) {
+		for _, node := range cached.Path {
+			table.SetNodeCapacity(node.ID, NodeCapacity{Connections: 10})
+			table.UpdateNodeUsage(node.ID, NodeUsage{Connections: connections})
+		}
+	}
+
+	// Routes not used since the refresh started keep their load
+	setPathUsage(5)
+	if refreshed := table.RefreshCachedLoads(time.Now().Add(time.Hour)); refreshed != 0 {
+		t.Errorf("refreshed %d routes nobody used", refreshed)
+	}
+
+	// A hot route takes up its path's load in a copy
+	table.RefreshCachedLoads(time.Time{})
+	response, _ = table.LookupRoute(request)
+	if !response.CacheHit || response.Route.Metrics.Load != 0.5 {
+		t.Fatalf("refreshed route has load %v, want 0.5", response.Route.Metrics.Load)
+	}
+	if cached.Metrics.Load == 0.5 {
+		t.Error("refresh changed the route entry handed out before it")
+	}
+
+	// Once its path saturates it is no longer served from the cache
+	setPathUsage(9)
+	table.RefreshCachedLoads(time.Time{})
+	response, err = table.LookupRoute(request)
+	if err != nil {
+		t.Fatalf("lookup after saturation failed: %v", err)
+	}
+	if response.CacheHit {
+		t.Error("route through a saturated path was served from the cache")
+	}
+}
@@ -0,0 +1,171 @@
+// Package routing implements node capacity modeling and path admission
+package routing
+
+import (
+	"errors"
+	"math"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+// ErrNoCapacity is returned when every route to a destination crosses a node
+// or reserved edge without capacity left for another request
+var ErrNoCapacity = errors.New("no route with capacity")
+
+// NodeCapacity is what a node can serve. Zero fields are unlimited.
+type NodeCapacity struct {
+	// CPU cores
+	CPU float64
+
+	// Bandwidth in Mbps
+	Bandwidth float64
+
+	// Concurrent connections
+	Connections int
+}
+
+// NodeUsage is the share of its capacity a node reports in use
+type NodeUsage struct {
+	CPU         float64
+	Bandwidth   float64
+	Connections int
+}
+
+// limited reports whether any capacity is set
+func (nc NodeCapacity) limited() bool {
+	return nc.CPU > 0 || nc.Bandwidth > 0 || nc.Connections > 0
+}
+
+// SetNodeCapacity registers what a node can serve. Routes are then admitted
+// through it only while it has capacity for another request.
+func (lb *LoadBalancer) SetNodeCapacity(nodeID int64, capacity NodeCapacity) {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	nodeInfo := lb.nodeLoad(nodeID)
+	nodeInfo.Capacity = capacity
+	nodeInfo.CurrentLoad = nodeInfo.utilization()
+}
+
+// UpdateNodeUsage records the capacity a node reports in use
+func (lb *LoadBalancer) UpdateNodeUsage(nodeID int64, usage NodeUsage) {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	nodeInfo := lb.nodeLoad(nodeID)
+	nodeInfo.Usage = usage
+	if nodeInfo.Capacity.limited() {
+		nodeInfo.CurrentLoad = nodeInfo.utilization()
+	}
+}
+
+// AdmitsRoute reports whether every node on a route has capacity for
+// another request needing bandwidth Mbps, and every reserved edge that
+// bandwidth unreserved
+func (lb *LoadBalancer) AdmitsRoute(route *RouteEntry, bandwidth float64) bool {
+	lb.mutex.RLock()
+	defer lb.mutex.RUnlock()
+
+	return lb.admits(route.Path, bandwidth)
+}
+
+// AdmitRoutes returns the routes AdmitsRoute accepts, in order, counting
+// the rest as capacity rejections
+func (lb *LoadBalancer) AdmitRoutes(routes []*RouteEntry, bandwidth float64) []*RouteEntry {
+	lb.mutex.RLock()
+	defer lb.mutex.RUnlock()
+
+	admitted := make([]*RouteEntry, 0, len(routes))
+	for _, route := range routes {
+		if lb.admits(route.Path, bandwidth) {
+			admitted = append(admitted, route)
+		} else {
+			lb.stats.CapacityRejections.Add(1)
+		}
+	}
+	return admitted
+}
+
+// admits reports whether the bottleneck node on a path stays within its
+// capacity with one more request needing bandwidth Mbps, and its reserved
+// edges have that bandwidth unreserved. The caller holds the lock.
+func (lb *LoadBalancer) admits(path []*graph.NetworkNode, bandwidth float64) bool {
+	for _, node := range path {
+		if nodeInfo, exists := lb.nodeLoads[node.ID]; exists && !nodeInfo.admits(bandwidth) {
+			return false
+		}
+	}
+	return lb.reservations.admits(path, bandwidth)
+}
+
+// admits reports whether the node stays within its capacity with one more
+// request needing bandwidth Mbps
+func (nli *NodeLoadInfo) admits(bandwidth float64) bool {
+	capacity, usage := nli.Capacity, nli.Usage
+	if capacity.CPU > 0 && usage.CPU >= capacity.CPU {
+		return false
+	}
+	if capacity.Bandwidth > 0 && usage.Bandwidth+bandwidth > capacity.Bandwidth {
+		return false
+	}
+	if capacity.Connections > 0 && int64(usage.Connections)+nli.RoutedRequests >= int64(capacity.Connections) {
+		return false
+	}
+	return true
+}
+
+// NodeInFlight returns the requests in flight through a node
+func (lb *LoadBalancer) NodeInFlight(nodeID int64) int64 {
+	lb.mutex.RLock()
+	defer lb.mutex.RUnlock()
+
+	return lb.nodeRequests[nodeID]
+}
+
+// routeRequest counts a routed request starting, or ending if delta is
+// negative, at each node on the path, and against the capacity of those
+// with one. The caller holds the write lock.
+func (lb *LoadBalancer) routeRequest(nodeIDs []int64, delta int64) {
+	for _, nodeID := range nodeIDs {
+		if requests := lb.nodeRequests[nodeID] + delta; requests > 0 {
+			lb.nodeRequests[nodeID] = requests
+		} else {
+			delete(lb.nodeRequests, nodeID)
+		}
+
+		nodeInfo, exists := lb.nodeLoads[nodeID]
+		if !exists || !nodeInfo.Capacity.limited() {
+			continue
+		}
+		nodeInfo.RoutedRequests = max(nodeInfo.RoutedRequests+delta, 0)
+		nodeInfo.CurrentLoad = nodeInfo.utilization()
+	}
+}
+
+// nodeLoad returns a node's load, tracking it if it is new. The caller holds
+// the write lock.
+func (lb *LoadBalancer) nodeLoad(nodeID int64) *NodeLoadInfo {
+	nodeInfo, exists := lb.nodeLoads[nodeID]
+	if !exists {
+		nodeInfo = &NodeLoadInfo{NodeID: nodeID, IsHealthy: true}
+		lb.nodeLoads[nodeID] = nodeInfo
+	}
+	return nodeInfo
+}
+
+// utilization returns the node's most used capacity as a share of it,
+// counting routed requests as connections
+func (nli *NodeLoadInfo) utilization() float64 {
+	capacity, usage := nli.Capacity, nli.Usage
+	load := 0.0
+	if capacity.CPU > 0 {
+		load = math.Max(load, usage.CPU/capacity.CPU)
+	}
+	if capacity.Bandwidth > 0 {
+		load = math.Max(load, usage.Bandwidth/capacity.Bandwidth)
+	}
+	if capacity.Connections > 0 {
+		load = math.Max(load, float64(int64(usage.Connections)+nli.RoutedRequests)/float64(capacity.Connections))
+	}
+	return math.Min(load, 1.0)
+}
@@ -0,0 +1,53 @@
+// Package routing implements fingerprints identifying paths by their nodes
+package routing
+
+import "github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+
+// FNV-1a parameters for 64-bit hashes
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// PathFingerprint returns a 64-bit FNV-1a hash of the sequence of node IDs
+// on a path, or zero for an empty path. Paths visiting the same nodes in a
+// different order have different fingerprints.
+func PathFingerprint(path []*graph.NetworkNode) uint64 {
+	if len(path) == 0 {
+		return 0
+	}
+
+	hash := uint64(fnvOffset64)
+	for _, node := range path {
+		hash = fingerprintNode(hash, node.ID)
+	}
+	return hash
+}
+
+// NodeIDsFingerprint returns the fingerprint of the path through nodeIDs,
+// equal to PathFingerprint of the same nodes
+func NodeIDsFingerprint(nodeIDs []int64) uint64 {
+	if len(nodeIDs) == 0 {
+		return 0
+	}
+
+	hash := uint64(fnvOffset64)
+	for _, id := range nodeIDs {
+		hash = fingerprintNode(hash, id)
+	}
+	return hash
+}
+
+// fingerprintNode folds a node ID into a fingerprint, a byte at a time
+func fingerprintNode(hash uint64, id int64) uint64 {
+	for shift := 0; shift < 64; shift += 8 {
+		hash ^= (uint64(id) >> shift) & 0xff
+		hash *= fnvPrime64
+	}
+	return hash
+}
+
+// Fingerprint returns the fingerprint of the route's path
+func (re *RouteEntry) Fingerprint() uint64 {
+	return PathFingerprint(re.Path)
+}
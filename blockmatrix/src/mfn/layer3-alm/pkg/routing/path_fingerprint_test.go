@@ -0,0 +1,46 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+func nodePath(ids ...int64) []*graph.NetworkNode {
+	path := make([]*graph.NetworkNode, len(ids))
+	for i, id := range ids {
+		path[i] = &graph.NetworkNode{ID: id}
+	}
+	return path
+}
+
+func TestPathFingerprint(t *testing.T) {
+	// IDs beyond the Unicode range all mapped to the same path ID as runes
+	paths := [][]int64{
+		{1, 2, 3},
+		{3, 2, 1},
+		{1, 2},
+		{0x110000, 0x110001},
+		{0x110002, 0x110003},
+		{-1, 2},
+		{1<<32 + 1, 2},
+	}
+	seen := make(map[uint64][]int64)
+	for _, ids := range paths {
+		fingerprint := PathFingerprint(nodePath(ids...))
+		if other, exists := seen[fingerprint]; exists {
+			t.Errorf("paths %v and %v share fingerprint %x", other, ids, fingerprint)
+		}
+		seen[fingerprint] = ids
+	}
+
+	if PathFingerprint(nodePath(1, 2, 3)) != PathFingerprint(nodePath(1, 2, 3)) {
+		t.Error("fingerprint of the same path differs")
+	}
+	if PathFingerprint(nodePath(1, 2, 3)) != NodeIDsFingerprint([]int64{1, 2, 3}) {
+		t.Error("fingerprint of a path differs from the fingerprint of its node IDs")
+	}
+	if fingerprint := PathFingerprint(nil); fingerprint != 0 {
+		t.Errorf("empty path has fingerprint %x, want 0", fingerprint)
+	}
+}
@@ -3,8 +3,14 @@ package routing
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -18,7 +24,6 @@ import (
 type PerformanceBenchmark struct {
 	routingTable    *RoutingTable
 	testTopology    *TestTopology
-	baselineLatency time.Duration
 	targetImprovement float64 // 777% improvement = 7.77x faster = 1/7.77 latency
 	
 	// Test configuration
@@ -78,7 +83,6 @@ func NewPerformanceBenchmark(numNodes, numConnections int, concurrency int) *Per
 		numConnections:  numConnections,
 		testDuration:    30 * time.Second,
 		concurrency:     concurrency,
-		baselineLatency: 1390 * time.Microsecond, // HTTP baseline: 1.39ms
 		targetImprovement: 7.77, // 777% improvement
 	}
 }
@@ -129,7 +133,7 @@ func (pb *PerformanceBenchmark) setupTestTopology() error {
 	}
 	
 	// Create network graph
-	networkGraph := graph.NewNetworkGraph()
+	networkGraph := graph.NewNetworkGraph(pb.numNodes)
 	
 	// Generate realistic node distribution across regions
 	regions := []string{"us-east-1", "us-west-2", "eu-west-1", "ap-southeast-1", "ap-northeast-1"}
@@ -271,21 +275,36 @@ func (pb *PerformanceBenchmark) warmupSystem() error {
 	return nil
 }
 
-// runBaselineTest simulates traditional HTTP routing performance
+// runBaselineTest measures traditional HTTP routing: a table lookup behind
+// a real HTTP/1.1 round trip on loopback
 func (pb *PerformanceBenchmark) runBaselineTest() (*TestMetrics, error) {
 	metrics := &TestMetrics{
 		latencies: make([]time.Duration, 0, 10000),
 		startTime: time.Now(),
 	}
 	
-	// Simulate baseline HTTP routing (simple table lookup + network overhead)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		source, _ := strconv.ParseInt(r.URL.Query().Get("source"), 10, 64)
+		destination, _ := strconv.ParseInt(r.URL.Query().Get("destination"), 10, 64)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string][]int64{"path": {source, destination}})
+	}))
+	defer server.Close()
+	client := server.Client()
+	
 	requests := 5000
 	
 	for i := 0; i < requests; i++ {
+		source := int64(rand.Intn(pb.numNodes) + 1)
+		destination := int64(rand.Intn(pb.numNodes) + 1)
 		start := time.Now()
 		
-		// Simulate HTTP routing overhead
-		time.Sleep(pb.baselineLatency + time.Duration(rand.Intn(500))*time.Microsecond)
+		response, err := client.Get(fmt.Sprintf("%s/route?source=%d&destination=%d", server.URL, source, destination))
+		if err != nil {
+			return nil, fmt.Errorf("baseline request failed: %w", err)
+		}
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
 		
 		latency := time.Since(start)
 		metrics.latencies = append(metrics.latencies, latency)
@@ -369,7 +388,6 @@ func (pb *PerformanceBenchmark) runALMPerformanceTest() (*TestMetrics, error) {
 func (pb *PerformanceBenchmark) calculatePerformanceMetrics(baseline, alm *TestMetrics) *PerformanceTestResult {
 	// Calculate baseline metrics
 	baselineAvg := calculateAverageLatency(baseline.latencies)
-	baselineP50, baselineP90, baselineP95, baselineP99 := calculatePercentiles(baseline.latencies)
 	
 	// Calculate ALM metrics  
 	almAvg := calculateAverageLatency(alm.latencies)
@@ -578,4 +596,15 @@ func (pb *PerformanceBenchmark) calculateConsistencyScore(latencies []time.Durat
 func RunPerformanceTest(numNodes, connections, concurrency int) (*PerformanceTestResult, error) {
 	benchmark := NewPerformanceBenchmark(numNodes, connections, concurrency)
 	return benchmark.RunComprehensivePerformanceTest()
-}
\ No newline at end of file
+}
+// BenchmarkRoutingPerformance runs the full validation once per iteration
+func BenchmarkRoutingPerformance(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		result, err := RunPerformanceTest(100, 4, 8)
+		if err != nil {
+			b.Fatalf("performance test failed: %v", err)
+		}
+		b.ReportMetric(result.ImprovementFactor, "improvement")
+		b.ReportMetric(result.SuccessRate, "success%")
+	}
+}
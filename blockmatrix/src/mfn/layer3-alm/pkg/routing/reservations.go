@@ -0,0 +1,272 @@
+// Package routing implements bandwidth reservations on paths
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+var (
+	// ErrInsufficientBandwidth is returned when an edge of a path has too
+	// little unreserved bandwidth for a reservation
+	ErrInsufficientBandwidth = errors.New("insufficient bandwidth")
+
+	// ErrUnknownReservation is returned for a reservation that does not
+	// exist or has expired
+	ErrUnknownReservation = errors.New("unknown reservation")
+)
+
+// Reservation is bandwidth committed on every edge of a path until it
+// expires or is released
+type Reservation struct {
+	ID        string
+	Path      []int64
+	Mbps      float64
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// linkKey identifies a directed edge
+type linkKey struct {
+	from, to int64
+}
+
+// BandwidthReservations tracks the bandwidth committed on each edge, so
+// routes are admitted only where the capacity left after reservations
+// covers the throughput requested, guaranteeing reserved traffic its
+// bandwidth rather than scoring paths best-effort
+type BandwidthReservations struct {
+	networkGraph *graph.NetworkGraph
+
+	reservations map[string]*Reservation
+	committed    map[linkKey]float64
+	nextID       int64
+
+	// Earliest expiry among the reservations, so expiring them is a
+	// comparison until one is due
+	nextExpiry time.Time
+
+	now   func() time.Time
+	mutex sync.Mutex
+}
+
+// NewBandwidthReservations creates reservations on the edges of networkGraph
+func NewBandwidthReservations(networkGraph *graph.NetworkGraph) *BandwidthReservations {
+	return &BandwidthReservations{
+		networkGraph: networkGraph,
+		reservations: make(map[string]*Reservation),
+		committed:    make(map[linkKey]float64),
+		now:          time.Now,
+	}
+}
+
+// Reserve commits mbps on every edge of the path through nodeIDs for ttl.
+// It fails with ErrInsufficientBandwidth, reserving nothing, if any edge
+// has less than mbps of its bandwidth unreserved; edges without a known
+// bandwidth cannot be reserved.
+func (br *BandwidthReservations) Reserve(nodeIDs []int64, mbps float64, ttl time.Duration) (Reservation, error) {
+	switch {
+	case len(nodeIDs) < 2:
+		return Reservation{}, errors.New("a reservation path needs at least two nodes")
+	case mbps <= 0:
+		return Reservation{}, fmt.Errorf("reserved bandwidth must be positive, got %g", mbps)
+	case ttl <= 0:
+		return Reservation{}, fmt.Errorf("reservation ttl must be positive, got %s", ttl)
+	}
+
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	now := br.now()
+	br.expireLocked(now)
+
+	for i := 0; i < len(nodeIDs)-1; i++ {
+		from, to := nodeIDs[i], nodeIDs[i+1]
+		edge, exists := br.networkGraph.GetEdge(from, to)
+		if !exists {
+			return Reservation{}, fmt.Errorf("edge %d->%d not found", from, to)
+		}
+		if edge.Bandwidth <= 0 {
+			return Reservation{}, fmt.Errorf("edge %d->%d has no known bandwidth to reserve", from, to)
+		}
+		if residual := edge.Bandwidth - br.committed[linkKey{from, to}]; residual < mbps {
+			return Reservation{}, fmt.Errorf("%w on edge %d->%d: %g of %g unreserved, %g requested",
+				ErrInsufficientBandwidth, from, to, residual, edge.Bandwidth, mbps)
+		}
+	}
+
+	br.nextID++
+	reservation := &Reservation{
+		ID:        "reservation-" + strconv.FormatInt(br.nextID, 10),
+		Path:      append([]int64(nil), nodeIDs...),
+		Mbps:      mbps,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	br.reservations[reservation.ID] = reservation
+	br.commitLocked(reservation, mbps)
+	if br.nextExpiry.IsZero() || reservation.ExpiresAt.Before(br.nextExpiry) {
+		br.nextExpiry = reservation.ExpiresAt
+	}
+	return *reservation, nil
+}
+
+// Renew extends the reservation id to expire ttl from now
+func (br *BandwidthReservations) Renew(id string, ttl time.Duration) (Reservation, error) {
+	if ttl <= 0 {
+		return Reservation{}, fmt.Errorf("reservation ttl must be positive, got %s", ttl)
+	}
+
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	now := br.now()
+	br.expireLocked(now)
+
+	reservation, exists := br.reservations[id]
+	if !exists {
+		return Reservation{}, fmt.Errorf("%w %q", ErrUnknownReservation, id)
+	}
+	reservation.ExpiresAt = now.Add(ttl)
+	br.resetExpiryLocked()
+	return *reservation, nil
+}
+
+// Release frees the bandwidth of reservation id, reporting whether it was
+// held
+func (br *BandwidthReservations) Release(id string) bool {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	br.expireLocked(br.now())
+
+	reservation, exists := br.reservations[id]
+	if !exists {
+		return false
+	}
+	br.removeLocked(reservation)
+	br.resetExpiryLocked()
+	return true
+}
+
+// List returns the reservations held, oldest first
+func (br *BandwidthReservations) List() []Reservation {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	br.expireLocked(br.now())
+
+	reservations := make([]Reservation, 0, len(br.reservations))
+	for _, reservation := range br.reservations {
+		reservations = append(reservations, *reservation)
+	}
+	sort.Slice(reservations, func(i, j int) bool {
+		if !reservations[i].CreatedAt.Equal(reservations[j].CreatedAt) {
+			return reservations[i].CreatedAt.Before(reservations[j].CreatedAt)
+		}
+		return reservations[i].ID < reservations[j].ID
+	})
+	return reservations
+}
+
+// Committed returns the bandwidth reserved on the edge from one node to
+// another
+func (br *BandwidthReservations) Committed(from, to int64) float64 {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	br.expireLocked(br.now())
+	return br.committed[linkKey{from, to}]
+}
+
+// admits reports whether every reserved edge on path has mbps of its
+// bandwidth unreserved. A nil tracker admits every path.
+func (br *BandwidthReservations) admits(path []*graph.NetworkNode, mbps float64) bool {
+	if br == nil || mbps <= 0 {
+		return true
+	}
+
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	br.expireLocked(br.now())
+	if len(br.committed) == 0 {
+		return true
+	}
+	for i := 0; i < len(path)-1; i++ {
+		from, to := path[i].ID, path[i+1].ID
+		reserved := br.committed[linkKey{from, to}]
+		if reserved == 0 {
+			continue
+		}
+		if edge, exists := br.networkGraph.GetEdge(from, to); exists && edge.Bandwidth-reserved < mbps {
+			return false
+		}
+	}
+	return true
+}
+
+// expireLocked drops the reservations expired by now. The caller holds the
+// lock.
+func (br *BandwidthReservations) expireLocked(now time.Time) {
+	if br.nextExpiry.IsZero() || now.Before(br.nextExpiry) {
+		return
+	}
+	for _, reservation := range br.reservations {
+		if !now.Before(reservation.ExpiresAt) {
+			br.removeLocked(reservation)
+		}
+	}
+	br.resetExpiryLocked()
+}
+
+// resetExpiryLocked recomputes the earliest expiry. The caller holds the
+// lock.
+func (br *BandwidthReservations) resetExpiryLocked() {
+	br.nextExpiry = time.Time{}
+	for _, reservation := range br.reservations {
+		if br.nextExpiry.IsZero() || reservation.ExpiresAt.Before(br.nextExpiry) {
+			br.nextExpiry = reservation.ExpiresAt
+		}
+	}
+}
+
+// removeLocked drops a reservation and its commitments. The caller holds
+// the lock.
+func (br *BandwidthReservations) removeLocked(reservation *Reservation) {
+	delete(br.reservations, reservation.ID)
+	br.commitLocked(reservation, -reservation.Mbps)
+}
+
+// commitLocked adds mbps to the bandwidth committed on each edge of a
+// reservation, forgetting edges left with none. The caller holds the lock.
+func (br *BandwidthReservations) commitLocked(reservation *Reservation, mbps float64) {
+	for i := 0; i < len(reservation.Path)-1; i++ {
+		key := linkKey{reservation.Path[i], reservation.Path[i+1]}
+		committed := br.committed[key] + mbps
+		if committed <= 1e-9 {
+			delete(br.committed, key)
+			continue
+		}
+		br.committed[key] = committed
+	}
+}
+
+// ReserveBandwidth commits mbps on every edge of the path through nodeIDs
+// for ttl. Until the reservation expires or is released, routes are only
+// admitted for requests whose MinThroughput fits in the bandwidth left on
+// those edges.
+func (rt *RoutingTable) ReserveBandwidth(nodeIDs []int64, mbps float64, ttl time.Duration) (Reservation, error) {
+	return rt.reservations.Reserve(nodeIDs, mbps, ttl)
+}
+
+// Reservations returns the bandwidth reservations on the table's graph
+func (rt *RoutingTable) Reservations() *BandwidthReservations {
+	return rt.reservations
+}
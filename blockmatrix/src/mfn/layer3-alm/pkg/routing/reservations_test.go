@@ -0,0 +1,75 @@
+package routing
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBandwidthReservations(t *testing.T) {
+	table, requests := newLookupTable(t, FastLookup)
+	response, err := table.LookupRoute(requests[0])
+	if err != nil {
+		t.Fatalf("lookup failed: %v", err)
+	}
+	route := response.Route
+	path := make([]int64, len(route.Path))
+	for i, node := range route.Path {
+		path[i] = node.ID
+	}
+	bottleneck := route.Metrics.Throughput
+
+	reservations := table.Reservations()
+	now := time.Now()
+	reservations.now = func() time.Time { return now }
+
+	if _, err := table.ReserveBandwidth(path, bottleneck+1, time.Minute); !errors.Is(err, ErrInsufficientBandwidth) {
+		t.Fatalf("reservation above the bottleneck: got %v, want ErrInsufficientBandwidth", err)
+	}
+	reservation, err := table.ReserveBandwidth(path, bottleneck*0.75, time.Minute)
+	if err != nil {
+		t.Fatalf("ReserveBandwidth: %v", err)
+	}
+	if _, err := table.ReserveBandwidth(path, bottleneck*0.5, time.Minute); !errors.Is(err, ErrInsufficientBandwidth) {
+		t.Errorf("overcommitting reservation: got %v, want ErrInsufficientBandwidth", err)
+	}
+
+	// Requests needing more than the unreserved quarter are not admitted
+	if table.loadBalancer.RouteUsable(route, bottleneck*0.5) {
+		t.Error("route admitted for more than its unreserved bandwidth")
+	}
+	if !table.loadBalancer.RouteUsable(route, bottleneck*0.2) {
+		t.Error("route refused for less than its unreserved bandwidth")
+	}
+	if !table.loadBalancer.RouteUsable(route, 0) {
+		t.Error("route refused to a request without a throughput constraint")
+	}
+
+	// Renewed reservations outlive their first ttl; expired ones free their
+	// bandwidth
+	if _, err := reservations.Renew(reservation.ID, 2*time.Minute); err != nil {
+		t.Fatalf("Renew: %v", err)
+	}
+	now = now.Add(90 * time.Second)
+	if reservations.Committed(path[0], path[1]) == 0 {
+		t.Error("renewed reservation expired at its first ttl")
+	}
+	now = now.Add(time.Minute)
+	if committed := reservations.Committed(path[0], path[1]); committed != 0 || len(reservations.List()) != 0 {
+		t.Errorf("expired reservation still commits %g", committed)
+	}
+	if _, err := reservations.Renew(reservation.ID, time.Minute); !errors.Is(err, ErrUnknownReservation) {
+		t.Errorf("renewing an expired reservation: got %v", err)
+	}
+
+	reservation, err = table.ReserveBandwidth(path, bottleneck, time.Minute)
+	if err != nil {
+		t.Fatalf("ReserveBandwidth after expiry: %v", err)
+	}
+	if !reservations.Release(reservation.ID) || reservations.Release(reservation.ID) {
+		t.Error("Release did not report the reservation exactly once")
+	}
+	if !table.loadBalancer.RouteUsable(route, bottleneck) {
+		t.Error("released bandwidth not available")
+	}
+}
@@ -3,7 +3,13 @@ package routing
 
 import (
 	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	lru "github.com/hashicorp/golang-lru"
@@ -17,18 +23,48 @@ type RouteCache struct {
 	// Statistics
 	stats    *RouteCacheStats
 	
+	// Path invalidation tracking: InvalidateByPath advances generation and
+	// stamps the nodes with it, and a route stored before any of its nodes'
+	// stamps is dropped when next read. Records older than the TTL are
+	// expired, since every route they invalidate has expired too.
+	generation     uint64
+	nodeGeneration map[int64]nodeInvalidation
+	lastExpiry     time.Time
+	
+	// Constraint fingerprints cached by PutConstrained for each endpoint
+	// key, for GetCompatible; fingerprints of evicted routes are pruned
+	// when next looked up or once the index outgrows the cache
+	variants map[RouteKey][]uint64
+	
 	// Thread safety
 	mutex    sync.RWMutex
 }
 
-// RouteCacheStats tracks cache performance
+// RouteCacheStats tracks cache performance with atomic counters, so
+// recording never contends on a lock
 type RouteCacheStats struct {
-	Hits          int64
-	Misses        int64
-	Puts          int64
-	Invalidations int64
-	
-	mutex sync.Mutex
+	Hits          atomic.Int64
+	Misses        atomic.Int64
+	Puts          atomic.Int64
+	Invalidations atomic.Int64
+}
+
+// maxNodeInvalidations bounds the node invalidation records kept before
+// invalidated routes are swept out and the records dropped
+const maxNodeInvalidations = 4096
+
+// nodeInvalidation records when a node was last invalidated
+type nodeInvalidation struct {
+	generation uint64
+	at         time.Time
+}
+
+// cachedRoute is a route with the cache generation it was stored at and the
+// constraints it was discovered under
+type cachedRoute struct {
+	route       *RouteEntry
+	generation  uint64
+	constraints RouteConstraints
 }
 
 // NewRouteCache creates a new route cache
@@ -36,22 +72,26 @@ func NewRouteCache(size int, ttl time.Duration) *RouteCache {
 	cache, _ := lru.NewARC(size)
 	
 	return &RouteCache{
-		cache: cache,
-		ttl:   ttl,
-		stats: &RouteCacheStats{},
+		cache:          cache,
+		ttl:            ttl,
+		stats:          &RouteCacheStats{},
+		nodeGeneration: make(map[int64]nodeInvalidation),
+		lastExpiry:     time.Now(),
+		variants:       make(map[RouteKey][]uint64),
 	}
 }
 
 // Get retrieves a route from the cache if valid
-func (rc *RouteCache) Get(key string) *RouteEntry {
+func (rc *RouteCache) Get(key RouteKey) *RouteEntry {
 	rc.mutex.RLock()
 	defer rc.mutex.RUnlock()
 	
 	if value, ok := rc.cache.Get(key); ok {
-		route := value.(*RouteEntry)
+		cached := value.(*cachedRoute)
+		route := cached.route
 		
-		// Check if route has expired
-		if time.Since(route.CreatedAt) > rc.ttl {
+		// Check if route has expired or a node on it was invalidated
+		if rc.stale(cached) {
 			rc.cache.Remove(key)
 			rc.stats.recordInvalidation()
 			rc.stats.recordMiss()
@@ -71,43 +111,206 @@ func (rc *RouteCache) Get(key string) *RouteEntry {
 }
 
 // GetByKey retrieves a route by key without updating access stats
-func (rc *RouteCache) GetByKey(key string) *RouteEntry {
+func (rc *RouteCache) GetByKey(key RouteKey) *RouteEntry {
 	rc.mutex.RLock()
 	defer rc.mutex.RUnlock()
 	
 	if value, ok := rc.cache.Peek(key); ok {
-		route := value.(*RouteEntry)
+		cached := value.(*cachedRoute)
 		
-		// Check if route has expired
-		if time.Since(route.CreatedAt) > rc.ttl {
+		// Check if route has expired or a node on it was invalidated
+		if rc.stale(cached) {
 			return nil
 		}
 		
-		return route
+		return cached.route
 	}
 	
 	return nil
 }
 
 // Put stores a route in the cache
-func (rc *RouteCache) Put(key string, route *RouteEntry) {
+func (rc *RouteCache) Put(key RouteKey, route *RouteEntry) {
 	rc.mutex.Lock()
 	defer rc.mutex.Unlock()
 	
-	rc.cache.Add(key, route)
+	rc.cache.Add(key, &cachedRoute{route: route, generation: rc.generation})
 	rc.stats.recordPut()
 }
 
+// PutConstrained stores a route discovered under constraints, whose
+// fingerprint key carries, so GetCompatible can serve it to requests with
+// stricter constraints it meets
+func (rc *RouteCache) PutConstrained(key RouteKey, constraints RouteConstraints, route *RouteEntry) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	
+	rc.cache.Add(key, &cachedRoute{route: route, generation: rc.generation, constraints: constraints})
+	rc.stats.recordPut()
+	if key.Constraints == 0 {
+		return
+	}
+	
+	endpoints := key.Endpoints()
+	if !slices.Contains(rc.variants[endpoints], key.Constraints) {
+		rc.variants[endpoints] = append(rc.variants[endpoints], key.Constraints)
+	}
+	if len(rc.variants) > rc.cache.Len() {
+		for endpoints := range rc.variants {
+			rc.pruneVariants(endpoints)
+		}
+	}
+}
+
+// GetCompatible returns a route cached for the endpoints of key under
+// constraints that relax the given ones, and that usable accepts, or nil.
+// The route for key itself is not considered; hits are not counted, as
+// the miss on key already was.
+func (rc *RouteCache) GetCompatible(key RouteKey, constraints RouteConstraints, usable func(route *RouteEntry) bool) *RouteEntry {
+	if key.Constraints == 0 {
+		return nil
+	}
+	
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	
+	endpoints := key.Endpoints()
+	candidates := []RouteKey{endpoints}
+	for _, fingerprint := range rc.pruneVariants(endpoints) {
+		if fingerprint != key.Constraints {
+			candidate := endpoints
+			candidate.Constraints = fingerprint
+			candidates = append(candidates, candidate)
+		}
+	}
+	
+	for _, candidate := range candidates {
+		value, ok := rc.cache.Peek(candidate)
+		if !ok {
+			continue
+		}
+		cached := value.(*cachedRoute)
+		if rc.stale(cached) || !cached.constraints.Relaxes(constraints) || !usable(cached.route) {
+			continue
+		}
+		
+		rc.cache.Get(candidate)
+		cached.route.LastUsed = time.Now()
+		cached.route.UseCount++
+		return cached.route
+	}
+	return nil
+}
+
+// pruneVariants drops the fingerprints of endpoints no longer cached and
+// returns those left. The caller holds the write lock.
+func (rc *RouteCache) pruneVariants(endpoints RouteKey) []uint64 {
+	fingerprints := slices.DeleteFunc(rc.variants[endpoints], func(fingerprint uint64) bool {
+		key := endpoints
+		key.Constraints = fingerprint
+		return !rc.cache.Contains(key)
+	})
+	if len(fingerprints) == 0 {
+		delete(rc.variants, endpoints)
+		return nil
+	}
+	rc.variants[endpoints] = fingerprints
+	return fingerprints
+}
+
 // Invalidate removes a route from the cache
-func (rc *RouteCache) Invalidate(key string) {
+func (rc *RouteCache) Invalidate(key RouteKey) {
 	rc.mutex.Lock()
 	defer rc.mutex.Unlock()
 	
-	if rc.cache.Remove(key) {
+	if rc.cache.Contains(key) {
+		rc.cache.Remove(key)
 		rc.stats.recordInvalidation()
 	}
 }
 
+// UpdateByDestination replaces each unexpired route to a destination with a
+// copy changed by update, so lookups holding the old entry never see it
+// change, and returns the number updated
+func (rc *RouteCache) UpdateByDestination(destination int64, update func(route *RouteEntry)) int {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	
+	updated := 0
+	for _, keyInterface := range rc.cache.Keys() {
+		key := keyInterface.(RouteKey)
+		if key.Destination != destination {
+			continue
+		}
+		if value, ok := rc.cache.Peek(key); ok {
+			cached := value.(*cachedRoute)
+			if rc.stale(cached) {
+				continue
+			}
+			route := *cached.route
+			update(&route)
+			rc.cache.Add(key, &cachedRoute{route: &route, generation: cached.generation})
+			updated++
+		}
+	}
+	
+	return updated
+}
+
+// RefreshHot replaces each unexpired route used since a time with a copy
+// changed by refresh, so lookups holding the old entry never see it change.
+// Routes refresh reports should no longer be served are removed instead. It
+// returns the number of routes refreshed and removed.
+func (rc *RouteCache) RefreshHot(since time.Time, refresh func(route *RouteEntry) bool) (refreshed, removed int) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	
+	for _, keyInterface := range rc.cache.Keys() {
+		key := keyInterface.(RouteKey)
+		value, ok := rc.cache.Peek(key)
+		if !ok {
+			continue
+		}
+		cached := value.(*cachedRoute)
+		if rc.stale(cached) || cached.route.LastUsed.Before(since) {
+			continue
+		}
+		
+		route := *cached.route
+		if !refresh(&route) {
+			rc.cache.Remove(key)
+			removed++
+			continue
+		}
+		rc.cache.Add(key, &cachedRoute{route: &route, generation: cached.generation})
+		refreshed++
+	}
+	
+	rc.stats.recordInvalidations(int64(removed))
+	return refreshed, removed
+}
+
+// NextHops returns the distinct next hops of unexpired routes
+func (rc *RouteCache) NextHops() []int64 {
+	rc.mutex.RLock()
+	defer rc.mutex.RUnlock()
+	
+	seen := make(map[int64]bool)
+	hops := make([]int64, 0)
+	for _, keyInterface := range rc.cache.Keys() {
+		if value, ok := rc.cache.Peek(keyInterface); ok {
+			cached := value.(*cachedRoute)
+			if rc.stale(cached) || seen[cached.route.NextHop] {
+				continue
+			}
+			seen[cached.route.NextHop] = true
+			hops = append(hops, cached.route.NextHop)
+		}
+	}
+	
+	return hops
+}
+
 // InvalidateByDestination removes all routes to a destination
 func (rc *RouteCache) InvalidateByDestination(destination int64) int {
 	rc.mutex.Lock()
@@ -117,9 +320,9 @@ func (rc *RouteCache) InvalidateByDestination(destination int64) int {
 	removed := 0
 	
 	for _, keyInterface := range keys {
-		key := keyInterface.(string)
+		key := keyInterface.(RouteKey)
 		if value, ok := rc.cache.Peek(key); ok {
-			route := value.(*RouteEntry)
+			route := value.(*cachedRoute).route
 			if route.Destination == destination {
 				rc.cache.Remove(key)
 				removed++
@@ -131,30 +334,59 @@ func (rc *RouteCache) InvalidateByDestination(destination int64) int {
 	return removed
 }
 
-// InvalidateByPath removes all routes containing specific nodes
-func (rc *RouteCache) InvalidateByPath(nodeIDs []int64) int {
+// InvalidateByPath invalidates all routes containing specific nodes in time
+// proportional to the number of nodes. The routes are dropped, and counted
+// as invalidations, when next read or by CleanupExpired.
+func (rc *RouteCache) InvalidateByPath(nodeIDs []int64) {
 	rc.mutex.Lock()
 	defer rc.mutex.Unlock()
 	
-	keys := rc.cache.Keys()
-	removed := 0
+	now := time.Now()
+	rc.generation++
+	for _, nodeID := range nodeIDs {
+		rc.nodeGeneration[nodeID] = nodeInvalidation{generation: rc.generation, at: now}
+	}
 	
-	for _, keyInterface := range keys {
-		key := keyInterface.(string)
+	if now.Sub(rc.lastExpiry) >= rc.ttl {
+		for nodeID, invalidation := range rc.nodeGeneration {
+			if now.Sub(invalidation.at) > rc.ttl {
+				delete(rc.nodeGeneration, nodeID)
+			}
+		}
+		rc.lastExpiry = now
+	}
+	if len(rc.nodeGeneration) > maxNodeInvalidations {
+		rc.removeStale()
+	}
+}
+
+// InvalidateByChange removes the routes a topology change can affect in a
+// single pass over the cache
+func (rc *RouteCache) InvalidateByChange(change TopologyChange) int {
+	nodes := make(map[int64]bool, len(change.Nodes))
+	for _, nodeID := range change.Nodes {
+		nodes[nodeID] = true
+	}
+	links := make(map[Link]bool, len(change.Links))
+	for _, link := range change.Links {
+		links[link] = true
+	}
+	destinations := make(map[int64]bool, len(change.Destinations))
+	for _, destination := range change.Destinations {
+		destinations[destination] = true
+	}
+	
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	
+	removed := 0
+	for _, keyInterface := range rc.cache.Keys() {
+		key := keyInterface.(RouteKey)
 		if value, ok := rc.cache.Peek(key); ok {
-			route := value.(*RouteEntry)
-			
-			// Check if route contains any of the specified nodes
-			for _, routeNode := range route.Path {
-				for _, invalidNodeID := range nodeIDs {
-					if routeNode.ID == invalidNodeID {
-						rc.cache.Remove(key)
-						removed++
-						goto nextRoute
-					}
-				}
+			if routeAffected(value.(*cachedRoute).route, nodes, links, destinations) {
+				rc.cache.Remove(key)
+				removed++
 			}
-			nextRoute:
 		}
 	}
 	
@@ -162,6 +394,58 @@ func (rc *RouteCache) InvalidateByPath(nodeIDs []int64) int {
 	return removed
 }
 
+// InvalidateThrough removes share, from 0 to 1, of the routes through a
+// node, rounded up and least recently used first, so draining a node moves
+// its hottest traffic last. It returns how many were removed and how many
+// through the node remain; a zero share only counts them.
+func (rc *RouteCache) InvalidateThrough(nodeID int64, share float64) (removed, remaining int) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	
+	nodes := map[int64]bool{nodeID: true}
+	stale := 0
+	var through []interface{}
+	for _, keyInterface := range rc.cache.Keys() {
+		value, ok := rc.cache.Peek(keyInterface)
+		if !ok || !routeAffected(value.(*cachedRoute).route, nodes, nil, nil) {
+			continue
+		}
+		if rc.stale(value.(*cachedRoute)) {
+			rc.cache.Remove(keyInterface)
+			stale++
+			continue
+		}
+		through = append(through, keyInterface)
+	}
+	
+	removed = min(int(math.Ceil(max(share, 0)*float64(len(through)))), len(through))
+	for _, keyInterface := range through[:removed] {
+		rc.cache.Remove(keyInterface)
+	}
+	
+	rc.stats.recordInvalidations(int64(removed + stale))
+	return removed, len(through) - removed
+}
+
+// routeAffected reports whether a route goes to one of destinations, passes
+// through one of nodes or traverses one of links
+func routeAffected(route *RouteEntry, nodes map[int64]bool, links map[Link]bool, destinations map[int64]bool) bool {
+	if destinations[route.Destination] {
+		return true
+	}
+	
+	for i, node := range route.Path {
+		if nodes[node.ID] {
+			return true
+		}
+		if i > 0 && links[Link{From: route.Path[i-1].ID, To: node.ID}] {
+			return true
+		}
+	}
+	
+	return false
+}
+
 // Purge removes all entries from the cache
 func (rc *RouteCache) Purge() {
 	rc.mutex.Lock()
@@ -169,9 +453,99 @@ func (rc *RouteCache) Purge() {
 	
 	size := rc.cache.Len()
 	rc.cache.Purge()
+	clear(rc.nodeGeneration)
+	clear(rc.variants)
 	rc.stats.recordInvalidations(int64(size))
 }
 
+// Resize changes the cache capacity. When shrinking, the most recently used
+// routes are kept.
+func (rc *RouteCache) Resize(size int) error {
+	cache, err := lru.NewARC(size)
+	if err != nil {
+		return fmt.Errorf("invalid route cache size %d: %w", size, err)
+	}
+	
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	
+	routes := make(map[RouteKey]*cachedRoute, rc.cache.Len())
+	keys := make([]RouteKey, 0, rc.cache.Len())
+	for _, keyInterface := range rc.cache.Keys() {
+		key := keyInterface.(RouteKey)
+		if value, ok := rc.cache.Peek(key); ok {
+			routes[key] = value.(*cachedRoute)
+			keys = append(keys, key)
+		}
+	}
+	
+	// Add least recently used first so the new cache evicts those
+	sort.Slice(keys, func(i, j int) bool {
+		return routes[keys[i]].route.LastUsed.Before(routes[keys[j]].route.LastUsed)
+	})
+	for _, key := range keys {
+		cache.Add(key, routes[key])
+	}
+	
+	rc.cache = cache
+	return nil
+}
+
+// Export returns every unexpired route keyed by its cache key encoded with
+// RouteKey.String
+func (rc *RouteCache) Export() map[string]*RouteEntry {
+	rc.mutex.RLock()
+	defer rc.mutex.RUnlock()
+	
+	routes := make(map[string]*RouteEntry, rc.cache.Len())
+	for _, keyInterface := range rc.cache.Keys() {
+		key := keyInterface.(RouteKey)
+		if value, ok := rc.cache.Peek(key); ok {
+			if cached := value.(*cachedRoute); !rc.stale(cached) {
+				routes[key.String()] = cached.route
+			}
+		}
+	}
+	
+	return routes
+}
+
+// Import adds exported routes, skipping expired ones and those whose keys do
+// not parse, and returns the number added. Routes are added least recently
+// used first so recency is preserved.
+func (rc *RouteCache) Import(routes map[string]*RouteEntry) int {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	
+	parsed := make(map[RouteKey]*RouteEntry, len(routes))
+	keys := make([]RouteKey, 0, len(routes))
+	for encoded, route := range routes {
+		key, err := ParseRouteKey(encoded)
+		if err != nil || time.Since(route.CreatedAt) > rc.ttl {
+			continue
+		}
+		parsed[key] = route
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return parsed[keys[i]].LastUsed.Before(parsed[keys[j]].LastUsed)
+	})
+	
+	for _, key := range keys {
+		rc.cache.Add(key, &cachedRoute{route: parsed[key], generation: rc.generation})
+	}
+	
+	return len(keys)
+}
+
+// SetTTL changes how long cached routes stay valid
+func (rc *RouteCache) SetTTL(ttl time.Duration) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	
+	rc.ttl = ttl
+}
+
 // Size returns the current cache size
 func (rc *RouteCache) Size() int {
 	rc.mutex.RLock()
@@ -182,43 +556,42 @@ func (rc *RouteCache) Size() int {
 
 // GetStats returns cache statistics
 func (rc *RouteCache) GetStats() RouteCacheStatistics {
-	rc.stats.mutex.Lock()
-	defer rc.stats.mutex.Unlock()
-	
-	total := rc.stats.Hits + rc.stats.Misses
-	hitRate := 0.0
-	if total > 0 {
-		hitRate = float64(rc.stats.Hits) / float64(total) * 100.0
+	stats := RouteCacheStatistics{
+		Hits:          rc.stats.Hits.Load(),
+		Misses:        rc.stats.Misses.Load(),
+		Puts:          rc.stats.Puts.Load(),
+		Invalidations: rc.stats.Invalidations.Load(),
+		Size:          rc.Size(),
 	}
 	
-	return RouteCacheStatistics{
-		Hits:          rc.stats.Hits,
-		Misses:        rc.stats.Misses,
-		Puts:          rc.stats.Puts,
-		Invalidations: rc.stats.Invalidations,
-		HitRate:       hitRate,
-		Size:          rc.Size(),
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total) * 100.0
 	}
+	
+	return stats
 }
 
-// CleanupExpired removes expired entries from the cache
+// CleanupExpired removes expired entries, and those on paths invalidated by
+// InvalidateByPath, from the cache
 func (rc *RouteCache) CleanupExpired() int {
 	rc.mutex.Lock()
 	defer rc.mutex.Unlock()
 	
-	keys := rc.cache.Keys()
+	return rc.removeStale()
+}
+
+// removeStale removes every stale route, after which no invalidation record
+// can affect a cached or future route, and drops the records. The caller
+// holds the mutex.
+func (rc *RouteCache) removeStale() int {
 	removed := 0
-	
-	for _, keyInterface := range keys {
-		key := keyInterface.(string)
-		if value, ok := rc.cache.Peek(key); ok {
-			route := value.(*RouteEntry)
-			if time.Since(route.CreatedAt) > rc.ttl {
-				rc.cache.Remove(key)
-				removed++
-			}
+	for _, keyInterface := range rc.cache.Keys() {
+		if value, ok := rc.cache.Peek(keyInterface); ok && rc.stale(value.(*cachedRoute)) {
+			rc.cache.Remove(keyInterface)
+			removed++
 		}
 	}
+	clear(rc.nodeGeneration)
 	
 	rc.stats.recordInvalidations(int64(removed))
 	return removed
@@ -238,9 +611,13 @@ func (rc *RouteCache) GetMostUsedRoutes(limit int) []*RouteEntry {
 	routeUsages := make([]routeUsage, 0, len(keys))
 	
 	for _, keyInterface := range keys {
-		key := keyInterface.(string)
+		key := keyInterface.(RouteKey)
 		if value, ok := rc.cache.Peek(key); ok {
-			route := value.(*RouteEntry)
+			cached := value.(*cachedRoute)
+			if rc.stale(cached) {
+				continue
+			}
+			route := cached.route
 			routeUsages = append(routeUsages, routeUsage{
 				route: route,
 				usage: route.UseCount,
@@ -269,6 +646,108 @@ func (rc *RouteCache) GetMostUsedRoutes(limit int) []*RouteEntry {
 	return result
 }
 
+// stale reports whether a cached route has expired or passes through a node
+// invalidated since it was stored. The caller holds the mutex.
+func (rc *RouteCache) stale(cached *cachedRoute) bool {
+	if time.Since(cached.route.CreatedAt) > rc.ttl {
+		return true
+	}
+	
+	for _, node := range cached.route.Path {
+		if rc.nodeGeneration[node.ID].generation > cached.generation {
+			return true
+		}
+	}
+	
+	return false
+}
+
+// TopologyChange lists the graph elements touched by a topology update so
+// that only the cached routes it can affect are invalidated
+type TopologyChange struct {
+	// Routes passing through these nodes
+	Nodes []int64
+	
+	// Routes traversing these directed links
+	Links []Link
+	
+	// Routes to these destinations
+	Destinations []int64
+}
+
+// RouteKey identifies the cached route for a request. It is comparable, so
+// lookups build no key strings.
+type RouteKey struct {
+	Source      int64
+	Destination int64
+	ServiceType string
+	QoSClass    QoSClass
+	
+	// Fingerprint of the request's constraints, zero when unconstrained, so
+	// a route discovered under loose constraints is never served as is to
+	// a request with strict ones
+	Constraints uint64
+}
+
+// Endpoints returns the key without its constraints
+func (k RouteKey) Endpoints() RouteKey {
+	k.Constraints = 0
+	return k
+}
+
+// String encodes the key as exported routes are keyed:
+// source-destination-service-qos, followed by ~ and the constraint
+// fingerprint in hex when constrained
+func (k RouteKey) String() string {
+	if k.Constraints != 0 {
+		return fmt.Sprintf("%d-%d-%s-%d~%x", k.Source, k.Destination, k.ServiceType, int(k.QoSClass), k.Constraints)
+	}
+	return fmt.Sprintf("%d-%d-%s-%d", k.Source, k.Destination, k.ServiceType, int(k.QoSClass))
+}
+
+// ParseRouteKey decodes a key encoded by RouteKey.String
+func ParseRouteKey(encoded string) (RouteKey, error) {
+	parts := strings.SplitN(encoded, "-", 3)
+	last := strings.LastIndexByte(encoded, '-')
+	if len(parts) < 3 || last < len(parts[0])+len(parts[1])+2 {
+		return RouteKey{}, fmt.Errorf("invalid route key %q", encoded)
+	}
+	
+	source, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return RouteKey{}, fmt.Errorf("invalid route key %q: %w", encoded, err)
+	}
+	destination, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return RouteKey{}, fmt.Errorf("invalid route key %q: %w", encoded, err)
+	}
+	qosText, fingerprintText, constrained := strings.Cut(encoded[last+1:], "~")
+	qos, err := strconv.Atoi(qosText)
+	if err != nil {
+		return RouteKey{}, fmt.Errorf("invalid route key %q: %w", encoded, err)
+	}
+	var fingerprint uint64
+	if constrained {
+		if fingerprint, err = strconv.ParseUint(fingerprintText, 16, 64); err != nil || fingerprint == 0 {
+			return RouteKey{}, fmt.Errorf("invalid route key %q: bad constraint fingerprint", encoded)
+		}
+	}
+	
+	return RouteKey{
+		Source:      source,
+		Destination: destination,
+		ServiceType: encoded[len(parts[0])+len(parts[1])+2 : last],
+		QoSClass:    QoSClass(qos),
+		Constraints: fingerprint,
+	}, nil
+}
+
+// Link identifies a directed edge between two nodes
+type Link struct {
+	From int64
+	To   int64
+}
+
 // RouteCacheStatistics provides cache performance metrics
 type RouteCacheStatistics struct {
 	Hits          int64
@@ -282,33 +761,23 @@ type RouteCacheStatistics struct {
 // Statistics recording methods
 
 func (rcs *RouteCacheStats) recordHit() {
-	rcs.mutex.Lock()
-	defer rcs.mutex.Unlock()
-	rcs.Hits++
+	rcs.Hits.Add(1)
 }
 
 func (rcs *RouteCacheStats) recordMiss() {
-	rcs.mutex.Lock()
-	defer rcs.mutex.Unlock()
-	rcs.Misses++
+	rcs.Misses.Add(1)
 }
 
 func (rcs *RouteCacheStats) recordPut() {
-	rcs.mutex.Lock()
-	defer rcs.mutex.Unlock()
-	rcs.Puts++
+	rcs.Puts.Add(1)
 }
 
 func (rcs *RouteCacheStats) recordInvalidation() {
-	rcs.mutex.Lock()
-	defer rcs.mutex.Unlock()
-	rcs.Invalidations++
+	rcs.Invalidations.Add(1)
 }
 
 func (rcs *RouteCacheStats) recordInvalidations(count int64) {
-	rcs.mutex.Lock()
-	defer rcs.mutex.Unlock()
-	rcs.Invalidations += count
+	rcs.Invalidations.Add(count)
 }
 
 // String provides a string representation of cache statistics
@@ -0,0 +1,236 @@
+package routing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+func TestRouteKeyRoundTrip(t *testing.T) {
+	cases := []struct {
+		name string
+		key  RouteKey
+	}{
+		{"plain", RouteKey{Source: 1, Destination: 2, ServiceType: "api", QoSClass: LowLatency}},
+		{"dashed service", RouteKey{Source: 10, Destination: 20, ServiceType: "edge-cache-v2", QoSClass: CriticalMission}},
+		{"no service", RouteKey{Source: 3, Destination: 4}},
+		{"constrained", RouteKey{Source: 5, Destination: 6, ServiceType: "api-v2", QoSClass: LowLatency, Constraints: 0xfeed}},
+	}
+
+	for _, tc := range cases {
+		got, err := ParseRouteKey(tc.key.String())
+		if err != nil || got != tc.key {
+			t.Errorf("%s: %q parsed to %+v (%v)", tc.name, tc.key.String(), got, err)
+		}
+	}
+
+	for _, encoded := range []string{"", "1-2", "1-2-3", "a-2-api-0", "1-2-api-x", "1-2-api-0~", "1-2-api-0~zz", "1-2-api-0~0"} {
+		if _, err := ParseRouteKey(encoded); err == nil {
+			t.Errorf("ParseRouteKey accepted %q", encoded)
+		}
+	}
+}
+
+func TestRouteCacheExportImport(t *testing.T) {
+	rc := NewRouteCache(16, time.Minute)
+	key := RouteKey{Source: 1, Destination: 2, ServiceType: "api-gateway", QoSClass: HighThroughput}
+	rc.Put(key, &RouteEntry{Destination: 2, CreatedAt: time.Now()})
+
+	exported := rc.Export()
+	exported["not-a-key"] = &RouteEntry{Destination: 3, CreatedAt: time.Now()}
+
+	imported := NewRouteCache(16, time.Minute)
+	if added := imported.Import(exported); added != 1 {
+		t.Errorf("imported %d routes, want 1", added)
+	}
+	if route := imported.Get(key); route == nil || route.Destination != 2 {
+		t.Errorf("imported cache holds %+v for %+v", route, key)
+	}
+}
+
+func TestRouteCacheUpdateByDestination(t *testing.T) {
+	rc := NewRouteCache(16, time.Minute)
+	original := &RouteEntry{Destination: 2, Confidence: 1, CreatedAt: time.Now()}
+	rc.Put(RouteKey{Source: 1, Destination: 2}, original)
+	rc.Put(RouteKey{Source: 3, Destination: 2}, &RouteEntry{Destination: 2, Confidence: 1, CreatedAt: time.Now()})
+	rc.Put(RouteKey{Source: 1, Destination: 4}, &RouteEntry{Destination: 4, Confidence: 1, CreatedAt: time.Now()})
+
+	updated := rc.UpdateByDestination(2, func(route *RouteEntry) {
+		route.Confidence = 0.5
+	})
+	if updated != 2 {
+		t.Errorf("updated %d routes, want 2", updated)
+	}
+	if original.Confidence != 1 {
+		t.Error("update changed the entry in place")
+	}
+	if route := rc.GetByKey(RouteKey{Source: 1, Destination: 2}); route == nil || route.Confidence != 0.5 {
+		t.Errorf("route to 2 is %+v after update", route)
+	}
+	if route := rc.GetByKey(RouteKey{Source: 1, Destination: 4}); route == nil || route.Confidence != 1 {
+		t.Errorf("route to 4 is %+v after update", route)
+	}
+}
+
+func TestRouteCacheInvalidateByPath(t *testing.T) {
+	rc := NewRouteCache(16, time.Minute)
+	path := func(ids ...int64) []*graph.NetworkNode {
+		nodes := make([]*graph.NetworkNode, len(ids))
+		for i, id := range ids {
+			nodes[i] = &graph.NetworkNode{ID: id}
+		}
+		return nodes
+	}
+	through := RouteKey{Source: 1, Destination: 3}
+	around := RouteKey{Source: 1, Destination: 4}
+	rc.Put(through, &RouteEntry{Destination: 3, Path: path(1, 2, 3), CreatedAt: time.Now()})
+	rc.Put(around, &RouteEntry{Destination: 4, Path: path(1, 4), CreatedAt: time.Now()})
+
+	rc.InvalidateByPath([]int64{2})
+	if route := rc.GetByKey(through); route != nil {
+		t.Errorf("route through invalidated node 2 still cached: %+v", route)
+	}
+	if len(rc.Export()) != 1 || len(rc.GetMostUsedRoutes(10)) != 1 {
+		t.Error("invalidated route exported or listed")
+	}
+	if removed := rc.CleanupExpired(); removed != 1 {
+		t.Errorf("cleanup removed %d routes, want 1", removed)
+	}
+	if len(rc.nodeGeneration) != 0 {
+		t.Errorf("kept %d invalidation records after cleanup", len(rc.nodeGeneration))
+	}
+	if route := rc.Get(around); route == nil {
+		t.Error("route avoiding node 2 was invalidated")
+	}
+
+	// Routes stored after the invalidation stay valid
+	rc.Put(through, &RouteEntry{Destination: 3, Path: path(1, 2, 3), CreatedAt: time.Now()})
+	if route := rc.Get(through); route == nil {
+		t.Error("route stored after invalidating node 2 was dropped")
+	}
+}
+
+func TestRouteCacheInvalidateThrough(t *testing.T) {
+	rc := NewRouteCache(16, time.Minute)
+	path := func(ids ...int64) []*graph.NetworkNode {
+		nodes := make([]*graph.NetworkNode, len(ids))
+		for i, id := range ids {
+			nodes[i] = &graph.NetworkNode{ID: id}
+		}
+		return nodes
+	}
+	for destination := int64(3); destination <= 6; destination++ {
+		rc.Put(RouteKey{Source: 1, Destination: destination}, &RouteEntry{Destination: destination, Path: path(1, 2, destination), CreatedAt: time.Now()})
+	}
+	around := RouteKey{Source: 1, Destination: 7}
+	rc.Put(around, &RouteEntry{Destination: 7, Path: path(1, 7), CreatedAt: time.Now()})
+	hot := RouteKey{Source: 1, Destination: 6}
+	rc.Get(hot)
+
+	if removed, remaining := rc.InvalidateThrough(2, 0); removed != 0 || remaining != 4 {
+		t.Errorf("counting: removed %d, %d remaining; want 0 and 4", removed, remaining)
+	}
+	if removed, remaining := rc.InvalidateThrough(2, 0.4); removed != 2 || remaining != 2 {
+		t.Errorf("partial drain: removed %d, %d remaining; want 2 and 2", removed, remaining)
+	}
+	if route := rc.GetByKey(hot); route == nil {
+		t.Error("most recently used route drained before colder ones")
+	}
+	if removed, remaining := rc.InvalidateThrough(2, 1); removed != 2 || remaining != 0 {
+		t.Errorf("full drain: removed %d, %d remaining; want 2 and 0", removed, remaining)
+	}
+	if route := rc.GetByKey(around); route == nil {
+		t.Error("route avoiding node 2 was drained")
+	}
+}
+
+func TestRouteCacheGetCompatible(t *testing.T) {
+	rc := NewRouteCache(16, time.Minute)
+	usable := func(route *RouteEntry) bool { return true }
+	endpoints := RouteKey{Source: 1, Destination: 2}
+	constrainedKey := func(constraints RouteConstraints) RouteKey {
+		key := endpoints
+		key.Constraints = constraints.Fingerprint()
+		return key
+	}
+
+	threeHops := RouteConstraints{MaxHops: 3}
+	route := &RouteEntry{Destination: 2, CreatedAt: time.Now()}
+	rc.PutConstrained(constrainedKey(threeHops), threeHops, route)
+
+	// Served to stricter constraints, not looser ones or none
+	twoHops := RouteConstraints{MaxHops: 2}
+	if got := rc.GetCompatible(constrainedKey(twoHops), twoHops, usable); got != route {
+		t.Errorf("stricter constraints: got %+v, want the cached route", got)
+	}
+	fourHops := RouteConstraints{MaxHops: 4}
+	if got := rc.GetCompatible(constrainedKey(fourHops), fourHops, usable); got != nil {
+		t.Errorf("looser constraints served %+v", got)
+	}
+	if got := rc.GetCompatible(endpoints, RouteConstraints{}, usable); got != nil {
+		t.Errorf("unconstrained request served %+v", got)
+	}
+	if got := rc.GetCompatible(constrainedKey(twoHops), twoHops, func(*RouteEntry) bool { return false }); got != nil {
+		t.Errorf("unusable route served %+v", got)
+	}
+
+	// An unconstrained route relaxes every constraint; evicted variants
+	// are forgotten
+	unconstrained := &RouteEntry{Destination: 2, CreatedAt: time.Now()}
+	rc.Put(endpoints, unconstrained)
+	rc.Invalidate(constrainedKey(threeHops))
+	if got := rc.GetCompatible(constrainedKey(twoHops), twoHops, usable); got != unconstrained {
+		t.Errorf("after evicting the variant: got %+v, want the unconstrained route", got)
+	}
+	if len(rc.variants) != 0 {
+		t.Errorf("evicted variants still indexed: %v", rc.variants)
+	}
+}
+
+func TestLookupRouteKeysCacheByConstraints(t *testing.T) {
+	table := newRegionTable(t)
+	request := RoutingRequest{Source: 1, Destination: 3, Context: context.Background()}
+	if _, err := table.LookupRoute(request); err != nil {
+		t.Fatalf("LookupRoute: %v", err)
+	}
+
+	// The 5ms route cached for no constraints is neither served to nor
+	// dropped by a lookup bounded to 1ms
+	strict := request
+	strict.Constraints = RouteConstraints{MaxLatency: time.Millisecond}
+	if response, err := table.LookupRoute(strict); err == nil {
+		t.Fatalf("route over a 1ms bound returned: %+v", response.Route.Metrics)
+	}
+	if response, err := table.LookupRoute(request); err != nil || !response.CacheHit {
+		t.Fatalf("unconstrained route not cached after a strict lookup: %+v, %v", response, err)
+	}
+
+	// A bound the cached route meets reuses it
+	loose := request
+	loose.Constraints = RouteConstraints{MaxLatency: 50 * time.Millisecond}
+	if response, err := table.LookupRoute(loose); err != nil || !response.CacheHit {
+		t.Errorf("route meeting a 50ms bound not reused: %+v, %v", response, err)
+	}
+	if size := table.GetRouteCacheStats().Size; size != 1 {
+		t.Errorf("cache holds %d routes, want 1", size)
+	}
+}
+
+// BenchmarkRouteCacheGet measures cache hits, which build their key from
+// the request on every lookup
+func BenchmarkRouteCacheGet(b *testing.B) {
+	rt := &RoutingTable{}
+	rc := NewRouteCache(1024, time.Hour)
+	request := RoutingRequest{Source: 1, Destination: 2, ServiceType: "api", QoSClass: LowLatency}
+	rc.Put(rt.createCacheKey(request), &RouteEntry{Destination: 2, CreatedAt: time.Now()})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if rc.Get(rt.createCacheKey(request)) == nil {
+			b.Fatal("cache missed")
+		}
+	}
+}
@@ -4,44 +4,101 @@ package routing
 import (
 	"fmt"
 	"math"
+	"math/rand"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/histogram"
+)
+
+const (
+	// Lookup latencies above this are counted as this
+	maxTrackedLookupTime = time.Minute
+
+	// Lookup latency quantiles cover this much recent history by default,
+	// kept in a ring of this many slots rotated one at a time
+	defaultLookupLatencyWindow      = 5 * time.Minute
+	defaultLookupLatencyWindowSlots = 5
+	
+	// Lookup latencies a shard buffers before flushing them to the shared
+	// histograms, and the most shards kept
+	lookupShardBatch = 64
+	maxLookupShards  = 256
 )
 
-// RoutingMetrics tracks comprehensive performance metrics for the routing system
+// RoutingMetrics tracks comprehensive performance metrics for the routing system.
+// Lookups are recorded into a shard picked at random, so concurrent lookups
+// rarely touch the same counters: readers sum the shards, and latencies are
+// buffered per shard and flushed to the shared histograms in batches or when
+// read. The mutex only guards the invalidation reasons.
 type RoutingMetrics struct {
-	// Lookup statistics
-	TotalLookups       int64
-	SuccessfulLookups  int64
-	FailedLookups      int64
-	CacheHits          int64
-	CacheMisses        int64
-	
-	// Timing statistics
-	TotalLookupTime    time.Duration
-	MinLookupTime      time.Duration
-	MaxLookupTime      time.Duration
+	// Lookup statistics, by shard
+	shards             []lookupShard
+	shardMask          uint32
+	
+	// Timing statistics, in nanoseconds
+	minLookupTime      atomic.Int64
+	maxLookupTime      atomic.Int64
 	
 	// Route quality metrics
-	totalRouteUpdates  int64
-	successfulUpdates  int64
-	failedUpdates      int64
+	totalRouteUpdates  atomic.Int64
+	successfulUpdates  atomic.Int64
+	failedUpdates      atomic.Int64
 	
 	// Invalidation tracking
-	totalInvalidations int64
+	totalInvalidations atomic.Int64
 	invalidationReasons map[string]int64
 	
 	// Moving averages
-	lookupTimeEMA      *ExponentialMovingAverage
+	lookupTimeEMA      *atomicEMA
+	
+	// Latency distribution of every lookup since the last reset, exported
+	// as a cumulative Prometheus histogram
+	lookupLatencies    *histogram.Histogram
 	
-	// Historical data (last N lookups for percentile calculations)
-	recentLookupTimes  []time.Duration
-	maxHistorySize     int
+	// Latency distribution of recent lookups, for quantiles
+	recentLatencies    *histogram.Windowed
+	
+	// Latency SLOs per QoS class, over their own windows
+	slos               *SLOTracker
 	
 	// Thread safety
 	mutex              sync.RWMutex
 }
 
+// lookupShard holds the lookups recorded into one shard. Counters are atomic;
+// the mutex guards the latencies buffered for the shared histograms.
+type lookupShard struct {
+	total              atomic.Int64
+	successful         atomic.Int64
+	failed             atomic.Int64
+	cacheHits          atomic.Int64
+	cacheMisses        atomic.Int64
+	coalesced          atomic.Int64
+	lookupTime         atomic.Int64
+	
+	mutex              sync.Mutex
+	latencies          [lookupShardBatch]time.Duration
+	lookupFailed       [lookupShardBatch]bool
+	buffered           int
+	
+	// Keeps neighbouring shards' counters off each other's cache lines
+	_                  [64]byte
+}
+
+// lookupCounts is the sum of the shards' counters
+type lookupCounts struct {
+	total       int64
+	successful  int64
+	failed      int64
+	cacheHits   int64
+	cacheMisses int64
+	coalesced   int64
+	lookupTime  int64
+}
+
 // RoutingPerformanceReport provides detailed performance analysis
 type RoutingPerformanceReport struct {
 	// Overall statistics
@@ -55,6 +112,8 @@ type RoutingPerformanceReport struct {
 	P90Latency        time.Duration
 	P95Latency        time.Duration
 	P99Latency        time.Duration
+	P999Latency       time.Duration
+	P9999Latency      time.Duration
 	
 	// Quality metrics
 	RouteUpdateSuccessRate float64
@@ -63,86 +122,175 @@ type RoutingPerformanceReport struct {
 	// Performance trends
 	LookupTimeEMA         float64
 	
+	// Latency SLOs and their error budgets
+	SLOs                  []SLOStatus
+	
 	// Report metadata
 	GeneratedAt           time.Time
 	MeasurementPeriod     time.Duration
 }
 
-// NewRoutingMetrics creates a new routing metrics collector
-func NewRoutingMetrics() *RoutingMetrics {
-	return &RoutingMetrics{
-		MinLookupTime:       time.Duration(math.MaxInt64),
-		MaxLookupTime:       time.Duration(0),
+// NewRoutingMetrics creates a new routing metrics collector whose latency
+// quantiles cover latencyWindow of recent lookups, or five minutes if zero.
+// The window is a ring of slots histograms, five if zero, and drops the
+// oldest slot's lookups each window/slots.
+func NewRoutingMetrics(latencyWindow time.Duration, slots int) *RoutingMetrics {
+	if latencyWindow <= 0 {
+		latencyWindow = defaultLookupLatencyWindow
+	}
+	if slots <= 0 {
+		slots = defaultLookupLatencyWindowSlots
+	}
+	
+	shards := 1
+	for shards < 2*runtime.GOMAXPROCS(0) && shards < maxLookupShards {
+		shards <<= 1
+	}
+	
+	rm := &RoutingMetrics{
+		shards:              make([]lookupShard, shards),
+		shardMask:           uint32(shards - 1),
 		invalidationReasons: make(map[string]int64),
-		lookupTimeEMA:       NewExponentialMovingAverage(0.1),
-		recentLookupTimes:   make([]time.Duration, 0, 1000),
-		maxHistorySize:      1000,
+		lookupTimeEMA:       newAtomicEMA(0.1),
+		lookupLatencies:     histogram.New(maxTrackedLookupTime, 3),
+		recentLatencies:     histogram.NewWindowed(maxTrackedLookupTime, 3, latencyWindow, slots),
+		slos:                NewSLOTracker(DefaultLatencySLOs()),
 	}
+	rm.minLookupTime.Store(math.MaxInt64)
+	return rm
+}
+
+// shard picks the shard a lookup is recorded into
+func (rm *RoutingMetrics) shard() *lookupShard {
+	return &rm.shards[rand.Uint32()&rm.shardMask]
 }
 
 // RecordSuccessfulLookup records a successful route lookup
 func (rm *RoutingMetrics) RecordSuccessfulLookup(lookupTime time.Duration) {
-	rm.mutex.Lock()
-	defer rm.mutex.Unlock()
-	
-	rm.TotalLookups++
-	rm.SuccessfulLookups++
-	rm.TotalLookupTime += lookupTime
+	shard := rm.shard()
+	shard.total.Add(1)
+	shard.successful.Add(1)
+	shard.lookupTime.Add(int64(lookupTime))
+	rm.buffer(shard, lookupTime, false)
 	
 	// Update min/max
-	if lookupTime < rm.MinLookupTime {
-		rm.MinLookupTime = lookupTime
+	for current := rm.minLookupTime.Load(); int64(lookupTime) < current; current = rm.minLookupTime.Load() {
+		if rm.minLookupTime.CompareAndSwap(current, int64(lookupTime)) {
+			break
+		}
 	}
-	if lookupTime > rm.MaxLookupTime {
-		rm.MaxLookupTime = lookupTime
+	for current := rm.maxLookupTime.Load(); int64(lookupTime) > current; current = rm.maxLookupTime.Load() {
+		if rm.maxLookupTime.CompareAndSwap(current, int64(lookupTime)) {
+			break
+		}
 	}
-	
-	// Update moving average
-	rm.lookupTimeEMA.Update(float64(lookupTime.Nanoseconds()))
-	
-	// Add to recent history for percentile calculations
-	rm.addToHistory(lookupTime)
 }
 
 // RecordFailedLookup records a failed route lookup
 func (rm *RoutingMetrics) RecordFailedLookup(lookupTime time.Duration) {
-	rm.mutex.Lock()
-	defer rm.mutex.Unlock()
-	
-	rm.TotalLookups++
-	rm.FailedLookups++
-	rm.TotalLookupTime += lookupTime
-	
 	// Still update timing stats for failed lookups
-	rm.addToHistory(lookupTime)
+	shard := rm.shard()
+	shard.total.Add(1)
+	shard.failed.Add(1)
+	shard.lookupTime.Add(int64(lookupTime))
+	rm.buffer(shard, lookupTime, true)
+}
+
+// RecordClassLookup counts a lookup of a QoS class, served from the cache or
+// not, against the latency SLOs of the class
+func (rm *RoutingMetrics) RecordClassLookup(class QoSClass, lookupTime time.Duration) {
+	rm.slos.Record(class, lookupTime)
+}
+
+// SLOs returns the latency SLOs lookups are tracked against
+func (rm *RoutingMetrics) SLOs() *SLOTracker {
+	return rm.slos
+}
+
+// buffer adds a lookup latency to the shard, flushing the shard once its
+// buffer is full
+func (rm *RoutingMetrics) buffer(shard *lookupShard, lookupTime time.Duration, failed bool) {
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	
+	shard.latencies[shard.buffered] = lookupTime
+	shard.lookupFailed[shard.buffered] = failed
+	shard.buffered++
+	if shard.buffered == lookupShardBatch {
+		rm.flushShard(shard)
+	}
+}
+
+// flushShard records the shard's buffered latencies in the histograms and
+// folds the successful ones into the moving average. The caller holds the
+// shard mutex.
+func (rm *RoutingMetrics) flushShard(shard *lookupShard) {
+	latencies := shard.latencies[:shard.buffered]
+	rm.lookupLatencies.RecordBatch(latencies)
+	rm.recentLatencies.RecordBatch(latencies)
+	for i, lookupTime := range latencies {
+		if !shard.lookupFailed[i] {
+			rm.lookupTimeEMA.update(float64(lookupTime.Nanoseconds()))
+		}
+	}
+	shard.buffered = 0
+}
+
+// flush records every shard's buffered latencies, so histograms and the
+// moving average include all lookups recorded so far
+func (rm *RoutingMetrics) flush() {
+	for i := range rm.shards {
+		shard := &rm.shards[i]
+		shard.mutex.Lock()
+		rm.flushShard(shard)
+		shard.mutex.Unlock()
+	}
+}
+
+// counts sums the shards' counters
+func (rm *RoutingMetrics) counts() lookupCounts {
+	var counts lookupCounts
+	for i := range rm.shards {
+		shard := &rm.shards[i]
+		counts.total += shard.total.Load()
+		counts.successful += shard.successful.Load()
+		counts.failed += shard.failed.Load()
+		counts.cacheHits += shard.cacheHits.Load()
+		counts.cacheMisses += shard.cacheMisses.Load()
+		counts.coalesced += shard.coalesced.Load()
+		counts.lookupTime += shard.lookupTime.Load()
+	}
+	return counts
 }
 
 // RecordCacheHit records a cache hit
 func (rm *RoutingMetrics) RecordCacheHit() {
-	rm.mutex.Lock()
-	defer rm.mutex.Unlock()
-	
-	rm.CacheHits++
+	rm.shard().cacheHits.Add(1)
 }
 
 // RecordCacheMiss records a cache miss
 func (rm *RoutingMetrics) RecordCacheMiss() {
-	rm.mutex.Lock()
-	defer rm.mutex.Unlock()
-	
-	rm.CacheMisses++
+	rm.shard().cacheMisses.Add(1)
+}
+
+// RecordCoalescedLookup records a cache miss that shared a concurrent
+// lookup's discovery
+func (rm *RoutingMetrics) RecordCoalescedLookup() {
+	rm.shard().coalesced.Add(1)
+}
+
+// TotalLookups returns the number of lookups recorded
+func (rm *RoutingMetrics) TotalLookups() int64 {
+	return rm.counts().total
 }
 
 // RecordRouteUpdate records a route performance update
 func (rm *RoutingMetrics) RecordRouteUpdate(metrics RouteMetrics, success bool) {
-	rm.mutex.Lock()
-	defer rm.mutex.Unlock()
-	
-	rm.totalRouteUpdates++
+	rm.totalRouteUpdates.Add(1)
 	if success {
-		rm.successfulUpdates++
+		rm.successfulUpdates.Add(1)
 	} else {
-		rm.failedUpdates++
+		rm.failedUpdates.Add(1)
 	}
 }
 
@@ -151,110 +299,106 @@ func (rm *RoutingMetrics) RecordInvalidation(reason string) {
 	rm.mutex.Lock()
 	defer rm.mutex.Unlock()
 	
-	rm.totalInvalidations++
+	rm.totalInvalidations.Add(1)
 	rm.invalidationReasons[reason]++
 }
 
 // GetCacheHitRate returns the cache hit rate as a percentage
 func (rm *RoutingMetrics) GetCacheHitRate() float64 {
-	rm.mutex.RLock()
-	defer rm.mutex.RUnlock()
-	
-	total := rm.CacheHits + rm.CacheMisses
+	counts := rm.counts()
+	total := counts.cacheHits + counts.cacheMisses
 	if total == 0 {
 		return 0.0
 	}
 	
-	return float64(rm.CacheHits) / float64(total) * 100.0
+	return float64(counts.cacheHits) / float64(total) * 100.0
 }
 
 // GetSuccessRate returns the lookup success rate as a percentage
 func (rm *RoutingMetrics) GetSuccessRate() float64 {
-	rm.mutex.RLock()
-	defer rm.mutex.RUnlock()
-	
-	if rm.TotalLookups == 0 {
+	counts := rm.counts()
+	if counts.total == 0 {
 		return 0.0
 	}
 	
-	return float64(rm.SuccessfulLookups) / float64(rm.TotalLookups) * 100.0
+	return float64(counts.successful) / float64(counts.total) * 100.0
 }
 
 // GetAverageLatency returns the average lookup latency
 func (rm *RoutingMetrics) GetAverageLatency() time.Duration {
-	rm.mutex.RLock()
-	defer rm.mutex.RUnlock()
-	
-	if rm.TotalLookups == 0 {
+	counts := rm.counts()
+	if counts.total == 0 {
 		return 0
 	}
 	
-	return rm.TotalLookupTime / time.Duration(rm.TotalLookups)
+	return time.Duration(counts.lookupTime / counts.total)
 }
 
 // GetInvalidationRate returns the rate of route invalidations
 func (rm *RoutingMetrics) GetInvalidationRate() float64 {
-	rm.mutex.RLock()
-	defer rm.mutex.RUnlock()
-	
-	if rm.TotalLookups == 0 {
+	total := rm.TotalLookups()
+	if total == 0 {
 		return 0.0
 	}
 	
-	return float64(rm.totalInvalidations) / float64(rm.TotalLookups) * 100.0
+	return float64(rm.totalInvalidations.Load()) / float64(total) * 100.0
 }
 
-// CalculateLatencyPercentiles calculates latency percentiles from recent history
+// CalculateLatencyPercentiles returns latency percentiles of lookups in the
+// recent window
 func (rm *RoutingMetrics) CalculateLatencyPercentiles() (p50, p90, p95, p99 time.Duration) {
-	rm.mutex.RLock()
-	defer rm.mutex.RUnlock()
-	
-	if len(rm.recentLookupTimes) == 0 {
-		return 0, 0, 0, 0
-	}
-	
-	// Create a copy and sort it
-	times := make([]time.Duration, len(rm.recentLookupTimes))
-	copy(times, rm.recentLookupTimes)
-	
-	// Simple sorting (for production, use sort.Slice)
-	for i := 0; i < len(times)-1; i++ {
-		for j := 0; j < len(times)-i-1; j++ {
-			if times[j] > times[j+1] {
-				times[j], times[j+1] = times[j+1], times[j]
-			}
-		}
-	}
-	
-	// Calculate percentiles
-	n := len(times)
-	p50 = times[int(float64(n)*0.50)]
-	p90 = times[int(float64(n)*0.90)]
-	p95 = times[int(float64(n)*0.95)]
-	p99 = times[int(float64(n)*0.99)]
-	
-	return p50, p90, p95, p99
+	rm.flush()
+	quantiles := rm.recentLatencies.Snapshot().Quantiles(0.50, 0.90, 0.95, 0.99)
+	return quantiles[0], quantiles[1], quantiles[2], quantiles[3]
+}
+
+// LatencyWindow returns the span of recent lookups latency quantiles cover
+func (rm *RoutingMetrics) LatencyWindow() time.Duration {
+	return rm.recentLatencies.Window()
+}
+
+// LatencyQuantile returns the lookup latency below which q of recent
+// lookups fall
+func (rm *RoutingMetrics) LatencyQuantile(q float64) time.Duration {
+	rm.flush()
+	return rm.recentLatencies.Snapshot().Quantile(q)
+}
+
+// LatencyHistogram returns a copy of the lookup latency distribution since
+// the last reset
+func (rm *RoutingMetrics) LatencyHistogram() *histogram.Histogram {
+	rm.flush()
+	return rm.lookupLatencies.Copy()
+}
+
+// RecentLatencyHistogram returns the lookup latency distribution over the
+// recent window
+func (rm *RoutingMetrics) RecentLatencyHistogram() *histogram.Histogram {
+	rm.flush()
+	return rm.recentLatencies.Snapshot()
 }
 
 // GeneratePerformanceReport creates a comprehensive performance report
 func (rm *RoutingMetrics) GeneratePerformanceReport(measurementPeriod time.Duration) *RoutingPerformanceReport {
-	rm.mutex.RLock()
-	defer rm.mutex.RUnlock()
-	
-	p50, p90, p95, p99 := rm.CalculateLatencyPercentiles()
+	rm.flush()
+	recent := rm.recentLatencies.Snapshot()
+	quantiles := recent.Quantiles(0.50, 0.90, 0.95, 0.99, 0.999, 0.9999)
 	
 	return &RoutingPerformanceReport{
-		TotalLookups:           rm.TotalLookups,
+		TotalLookups:           rm.TotalLookups(),
 		SuccessRate:           rm.GetSuccessRate(),
 		CacheHitRate:          rm.GetCacheHitRate(),
 		AverageLatency:        rm.GetAverageLatency(),
-		P50Latency:            p50,
-		P90Latency:            p90,
-		P95Latency:            p95,
-		P99Latency:            p99,
+		P50Latency:            quantiles[0],
+		P90Latency:            quantiles[1],
+		P95Latency:            quantiles[2],
+		P99Latency:            quantiles[3],
+		P999Latency:           quantiles[4],
+		P9999Latency:          quantiles[5],
 		RouteUpdateSuccessRate: rm.getRouteUpdateSuccessRate(),
 		InvalidationRate:      rm.GetInvalidationRate(),
-		LookupTimeEMA:         rm.lookupTimeEMA.Value(),
+		LookupTimeEMA:         rm.lookupTimeEMA.value(),
+		SLOs:                  rm.slos.Status(),
 		GeneratedAt:           time.Now(),
 		MeasurementPeriod:     measurementPeriod,
 	}
@@ -274,65 +418,131 @@ func (rm *RoutingMetrics) GetInvalidationReasons() map[string]int64 {
 	return reasons
 }
 
-// Reset resets all metrics (useful for testing or periodic resets)
+// Reset resets all metrics (useful for testing or periodic resets). Lookups
+// recorded while it runs may be partly kept.
 func (rm *RoutingMetrics) Reset() {
 	rm.mutex.Lock()
 	defer rm.mutex.Unlock()
 	
-	rm.TotalLookups = 0
-	rm.SuccessfulLookups = 0
-	rm.FailedLookups = 0
-	rm.CacheHits = 0
-	rm.CacheMisses = 0
-	rm.TotalLookupTime = 0
-	rm.MinLookupTime = time.Duration(math.MaxInt64)
-	rm.MaxLookupTime = 0
-	rm.totalRouteUpdates = 0
-	rm.successfulUpdates = 0
-	rm.failedUpdates = 0
-	rm.totalInvalidations = 0
+	for i := range rm.shards {
+		shard := &rm.shards[i]
+		shard.mutex.Lock()
+		shard.total.Store(0)
+		shard.successful.Store(0)
+		shard.failed.Store(0)
+		shard.cacheHits.Store(0)
+		shard.cacheMisses.Store(0)
+		shard.coalesced.Store(0)
+		shard.lookupTime.Store(0)
+		shard.buffered = 0
+		shard.mutex.Unlock()
+	}
+	rm.minLookupTime.Store(math.MaxInt64)
+	rm.maxLookupTime.Store(0)
+	rm.totalRouteUpdates.Store(0)
+	rm.successfulUpdates.Store(0)
+	rm.failedUpdates.Store(0)
+	rm.totalInvalidations.Store(0)
 	rm.invalidationReasons = make(map[string]int64)
-	rm.lookupTimeEMA = NewExponentialMovingAverage(0.1)
-	rm.recentLookupTimes = rm.recentLookupTimes[:0]
+	rm.lookupTimeEMA.reset()
+	rm.lookupLatencies.Reset()
+	rm.recentLatencies.Reset()
+	rm.slos.reset()
 }
 
 // GetCurrentStats returns current statistics snapshot
 func (rm *RoutingMetrics) GetCurrentStats() RoutingStatSnapshot {
-	rm.mutex.RLock()
-	defer rm.mutex.RUnlock()
-	
-	return RoutingStatSnapshot{
-		TotalLookups:      rm.TotalLookups,
-		SuccessfulLookups: rm.SuccessfulLookups,
-		FailedLookups:     rm.FailedLookups,
-		CacheHits:         rm.CacheHits,
-		CacheMisses:       rm.CacheMisses,
-		CacheHitRate:      rm.GetCacheHitRate(),
-		SuccessRate:       rm.GetSuccessRate(),
-		AverageLatency:    rm.GetAverageLatency(),
-		MinLatency:        rm.MinLookupTime,
-		MaxLatency:        rm.MaxLookupTime,
-		InvalidationRate:  rm.GetInvalidationRate(),
+	counts := rm.counts()
+	snapshot := RoutingStatSnapshot{
+		TotalLookups:      counts.total,
+		SuccessfulLookups: counts.successful,
+		FailedLookups:     counts.failed,
+		CacheHits:         counts.cacheHits,
+		CacheMisses:       counts.cacheMisses,
+		CoalescedLookups:  counts.coalesced,
+		MinLatency:        rm.MinLookupTime(),
+		MaxLatency:        rm.MaxLookupTime(),
+		Invalidations:     rm.totalInvalidations.Load(),
 		Timestamp:         time.Now(),
 	}
+	
+	// Rates are derived from the loaded counters so they agree with each other
+	if cacheTotal := snapshot.CacheHits + snapshot.CacheMisses; cacheTotal > 0 {
+		snapshot.CacheHitRate = float64(snapshot.CacheHits) / float64(cacheTotal) * 100.0
+	}
+	if snapshot.TotalLookups > 0 {
+		snapshot.SuccessRate = float64(snapshot.SuccessfulLookups) / float64(snapshot.TotalLookups) * 100.0
+		snapshot.AverageLatency = time.Duration(counts.lookupTime / snapshot.TotalLookups)
+		snapshot.InvalidationRate = float64(snapshot.Invalidations) / float64(snapshot.TotalLookups) * 100.0
+	}
+	
+	return snapshot
 }
 
-// Helper methods
-
-func (rm *RoutingMetrics) addToHistory(duration time.Duration) {
-	if len(rm.recentLookupTimes) >= rm.maxHistorySize {
-		// Remove oldest entry (FIFO)
-		rm.recentLookupTimes = rm.recentLookupTimes[1:]
+// MinLookupTime returns the fastest successful lookup, or zero before any
+func (rm *RoutingMetrics) MinLookupTime() time.Duration {
+	if min := rm.minLookupTime.Load(); min != math.MaxInt64 {
+		return time.Duration(min)
 	}
-	rm.recentLookupTimes = append(rm.recentLookupTimes, duration)
+	return 0
 }
 
+// MaxLookupTime returns the slowest successful lookup
+func (rm *RoutingMetrics) MaxLookupTime() time.Duration {
+	return time.Duration(rm.maxLookupTime.Load())
+}
+
+// Helper methods
+
 func (rm *RoutingMetrics) getRouteUpdateSuccessRate() float64 {
-	if rm.totalRouteUpdates == 0 {
+	total := rm.totalRouteUpdates.Load()
+	if total == 0 {
 		return 0.0
 	}
 	
-	return float64(rm.successfulUpdates) / float64(rm.totalRouteUpdates) * 100.0
+	return float64(rm.successfulUpdates.Load()) / float64(total) * 100.0
+}
+
+// atomicEMA is an exponential moving average updated without locking
+type atomicEMA struct {
+	alpha float64
+	
+	// bits holds the average as float64 bits, NaN until the first value
+	bits  atomic.Uint64
+}
+
+// newAtomicEMA creates an average weighting each new value by alpha
+func newAtomicEMA(alpha float64) *atomicEMA {
+	ema := &atomicEMA{alpha: alpha}
+	ema.reset()
+	return ema
+}
+
+// update folds value into the average
+func (ema *atomicEMA) update(value float64) {
+	for {
+		old := ema.bits.Load()
+		next := value
+		if average := math.Float64frombits(old); !math.IsNaN(average) {
+			next = ema.alpha*value + (1.0-ema.alpha)*average
+		}
+		if ema.bits.CompareAndSwap(old, math.Float64bits(next)) {
+			return
+		}
+	}
+}
+
+// value returns the current average, or zero before the first value
+func (ema *atomicEMA) value() float64 {
+	if average := math.Float64frombits(ema.bits.Load()); !math.IsNaN(average) {
+		return average
+	}
+	return 0
+}
+
+// reset forgets every value
+func (ema *atomicEMA) reset() {
+	ema.bits.Store(math.Float64bits(math.NaN()))
 }
 
 // RoutingStatSnapshot provides a point-in-time snapshot of routing statistics
@@ -342,12 +552,14 @@ type RoutingStatSnapshot struct {
 	FailedLookups     int64
 	CacheHits         int64
 	CacheMisses       int64
+	CoalescedLookups  int64
 	CacheHitRate      float64
 	SuccessRate       float64
 	AverageLatency    time.Duration
 	MinLatency        time.Duration
 	MaxLatency        time.Duration
 	InvalidationRate  float64
+	Invalidations     int64
 	Timestamp         time.Time
 }
 
@@ -355,9 +567,6 @@ type RoutingStatSnapshot struct {
 
 // IsPerformingWell returns whether the routing system is performing within acceptable parameters
 func (rm *RoutingMetrics) IsPerformingWell() (bool, []string) {
-	rm.mutex.RLock()
-	defer rm.mutex.RUnlock()
-	
 	issues := make([]string, 0)
 	
 	// Check success rate
@@ -372,10 +581,16 @@ func (rm *RoutingMetrics) IsPerformingWell() (bool, []string) {
 		issues = append(issues, fmt.Sprintf("Low cache hit rate: %.2f%%", cacheHitRate))
 	}
 	
-	// Check average latency
-	avgLatency := rm.GetAverageLatency()
-	if avgLatency > 5*time.Millisecond {
-		issues = append(issues, fmt.Sprintf("High average latency: %v", avgLatency))
+	// Check latency SLOs
+	for _, status := range rm.slos.Status() {
+		switch {
+		case !status.Met:
+			issues = append(issues, fmt.Sprintf("Latency SLO %s missed: P%g %v over %v target, error budget exhausted",
+				status.SLO.Name, status.SLO.Quantile*100, status.Latency, status.SLO.Threshold))
+		case status.Alert != "":
+			issues = append(issues, fmt.Sprintf("Latency SLO %s burning error budget: %.1fx, %.1f%% left",
+				status.SLO.Name, status.BurnRate(status.Alert), status.BudgetRemaining*100))
+		}
 	}
 	
 	// Check invalidation rate
@@ -0,0 +1,94 @@
+package routing
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRoutingMetricsConcurrentRecord(t *testing.T) {
+	const goroutines, lookups = 100, 200
+
+	rm := NewRoutingMetrics(0, 0)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < lookups; i++ {
+				if i%4 == 0 {
+					rm.RecordFailedLookup(time.Millisecond)
+					rm.RecordCacheMiss()
+					continue
+				}
+				rm.RecordSuccessfulLookup(time.Duration(1+g) * time.Microsecond)
+				rm.RecordCacheHit()
+			}
+		}(g)
+	}
+	// Snapshots race the recorders
+	for i := 0; i < 10; i++ {
+		rm.GetCurrentStats()
+	}
+	wg.Wait()
+
+	stats := rm.GetCurrentStats()
+	if stats.TotalLookups != goroutines*lookups || stats.FailedLookups != goroutines*lookups/4 {
+		t.Errorf("counted %d lookups with %d failed, want %d with %d", stats.TotalLookups, stats.FailedLookups, goroutines*lookups, goroutines*lookups/4)
+	}
+	if stats.CacheHitRate != 75 || stats.SuccessRate != 75 {
+		t.Errorf("cache hit rate %.1f%% and success rate %.1f%%, want 75%%", stats.CacheHitRate, stats.SuccessRate)
+	}
+	if stats.MinLatency != time.Microsecond || stats.MaxLatency != goroutines*time.Microsecond {
+		t.Errorf("latency ranged %v to %v, want %v to %v", stats.MinLatency, stats.MaxLatency, time.Microsecond, goroutines*time.Microsecond)
+	}
+	if ema := rm.GeneratePerformanceReport(time.Second).LookupTimeEMA; ema < float64(time.Microsecond) || ema > float64(goroutines*time.Microsecond) {
+		t.Errorf("lookup time EMA %.0fns outside the recorded range", ema)
+	}
+
+	rm.Reset()
+	if stats := rm.GetCurrentStats(); stats.TotalLookups != 0 || stats.MinLatency != 0 || stats.MaxLatency != 0 {
+		t.Errorf("stats after Reset: %+v", stats)
+	}
+}
+
+func TestRoutingMetricsFlushBufferedLatencies(t *testing.T) {
+	rm := NewRoutingMetrics(0, 0)
+	rm.RecordSuccessfulLookup(2 * time.Millisecond)
+	rm.RecordFailedLookup(4 * time.Millisecond)
+
+	// Fewer lookups than a shard batch are still read
+	if count := rm.LatencyHistogram().Count(); count != 2 {
+		t.Errorf("latency histogram counted %d lookups, want 2", count)
+	}
+	if p99 := rm.LatencyQuantile(0.99); p99 < 4*time.Millisecond {
+		t.Errorf("recent p99 %v below the slowest lookup", p99)
+	}
+	if ema := rm.GeneratePerformanceReport(time.Second).LookupTimeEMA; ema != float64(2*time.Millisecond) {
+		t.Errorf("lookup time EMA %.0fns, want only the successful lookup", ema)
+	}
+}
+
+func TestRoutingMetricsLatencyWindow(t *testing.T) {
+	if window := NewRoutingMetrics(0, 0).LatencyWindow(); window != defaultLookupLatencyWindow {
+		t.Errorf("default latency window %v, want %v", window, defaultLookupLatencyWindow)
+	}
+	if window := NewRoutingMetrics(time.Minute, 12).LatencyWindow(); window != time.Minute {
+		t.Errorf("latency window %v in 12 slots, want %v", window, time.Minute)
+	}
+}
+
+// BenchmarkRecordLookupParallel measures recording lookups from many
+// goroutines at once, as the routing table does under load
+func BenchmarkRecordLookupParallel(b *testing.B) {
+	rm := NewRoutingMetrics(0, 0)
+
+	b.ReportAllocs()
+	b.SetParallelism(16)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			rm.RecordCacheMiss()
+			rm.RecordSuccessfulLookup(time.Millisecond)
+		}
+	})
+}
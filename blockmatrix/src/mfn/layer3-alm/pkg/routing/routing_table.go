@@ -5,13 +5,25 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
 	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/associative"
 	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/optimization"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/logging"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 )
 
+// tracer traces route lookups
+var tracer = tracing.NewTracer("routing")
+
+// logger logs routing decisions and failures
+var logger = logging.Named("routing")
+
 // RoutingTable implements an intelligent routing table with associative search
 type RoutingTable struct {
 	// Core components
@@ -22,6 +34,9 @@ type RoutingTable struct {
 	// Routing cache with intelligent invalidation
 	routeCache    *RouteCache
 	
+	// Coalesces concurrent discoveries for the same cache key
+	discoveries   singleflight.Group
+	
 	// Load balancing
 	loadBalancer  *LoadBalancer
 	
@@ -31,6 +46,19 @@ type RoutingTable struct {
 	// Configuration
 	config        *RoutingConfig
 	
+	// Brownout mode and the cache TTL it extends routes to
+	brownout      atomic.Bool
+	brownoutTTL   atomic.Int64
+	
+	// Constraints requests reference by name
+	templates     *ConstraintTemplates
+	
+	// Bandwidth committed on edges, which routes are admitted around
+	reservations  *BandwidthReservations
+	
+	// Failover regions for the destinations of failed regions
+	drPlans       *DRPlans
+	
 	// Thread safety
 	mutex         sync.RWMutex
 }
@@ -71,17 +99,32 @@ type RoutingRequest struct {
 	QoSClass    QoSClass
 	Constraints RouteConstraints
 	Context     context.Context
+	
+	// ConstraintTemplate names registered constraints the route must meet,
+	// tightened by any set in Constraints
+	ConstraintTemplate string
+	
+	// NoCache leaves the route out of the route cache, for requesters over
+	// their share of it; cached routes are still served
+	NoCache     bool
+	
+	// Disjoint asks for alternatives sharing no link, or no intermediate
+	// node, with the route or each other, for replicas that must not fail
+	// together. DisjointPaths counts the paths, the route included; zero
+	// means two.
+	Disjoint      graph.DisjointMode
+	DisjointPaths int
 }
 
 // RouteConstraints define hard limits for routing
 type RouteConstraints struct {
-	MaxLatency    time.Duration
-	MinThroughput float64
-	MinReliability float64
-	MaxCost       float64
-	MaxHops       int
-	AvoidNodes    []int64
-	PreferRegions []string
+	MaxLatency    time.Duration `json:"max_latency_ns,omitempty" yaml:"max_latency_ns,omitempty"`
+	MinThroughput float64       `json:"min_throughput,omitempty" yaml:"min_throughput,omitempty"`
+	MinReliability float64      `json:"min_reliability,omitempty" yaml:"min_reliability,omitempty"`
+	MaxCost       float64       `json:"max_cost,omitempty" yaml:"max_cost,omitempty"`
+	MaxHops       int           `json:"max_hops,omitempty" yaml:"max_hops,omitempty"`
+	AvoidNodes    []int64       `json:"avoid_nodes,omitempty" yaml:"avoid_nodes,omitempty"`
+	PreferRegions []string      `json:"prefer_regions,omitempty" yaml:"prefer_regions,omitempty"`
 }
 
 // QoSClass defines Quality of Service requirements
@@ -95,6 +138,24 @@ const (
 	CriticalMission
 )
 
+// String returns the snake_case name of the class
+func (q QoSClass) String() string {
+	switch q {
+	case BestEffort:
+		return "best_effort"
+	case LowLatency:
+		return "low_latency"
+	case HighThroughput:
+		return "high_throughput"
+	case HighReliability:
+		return "high_reliability"
+	case CriticalMission:
+		return "critical_mission"
+	default:
+		return fmt.Sprintf("qos_class_%d", int(q))
+	}
+}
+
 // RoutingResponse contains the routing decision
 type RoutingResponse struct {
 	Route          *RouteEntry
@@ -103,9 +164,28 @@ type RoutingResponse struct {
 	CacheHit       bool
 	Confidence     float64
 	
+	// Coalesced is set when the route came from a discovery run for a
+	// concurrent lookup of the same key
+	Coalesced      bool
+	
 	// Load balancing info
 	LoadBalanced   bool
 	SelectedReason string
+	
+	// Traffic split hints: the shares of traffic recommended for Route and
+	// for each of Alternatives, in order, summing to 1. Callers may split
+	// traffic proportionally instead of sending all of it over Route.
+	Weight             float64
+	AlternativeWeights []float64
+	
+	// Diversity is the lowest graph.PathDiversity between any two of Route
+	// and Alternatives: 1 when no two share a node or link, 0 without
+	// alternatives
+	Diversity          float64
+	
+	// Failover is set when the destination's region has failed and the
+	// route leads to a failover region under its disaster recovery plan
+	Failover           *DRFailover
 }
 
 // RoutingConfig configures the routing table
@@ -124,9 +204,26 @@ type RoutingConfig struct {
 	LoadBalanceThreshold float64
 	HealthCheckInterval  time.Duration
 	
+	// HealthCheckFailures is the consecutive failed probes after which a
+	// next hop is marked unhealthy and the routes through it dropped
+	HealthCheckFailures  int
+	
+	// PathRequestCapacity is the requests in flight that fully load a path
+	PathRequestCapacity  int
+	
+	// LoadRefreshInterval is how often the loads of routes served from the
+	// cache are refreshed from the load balancer
+	LoadRefreshInterval  time.Duration
+	
 	// Performance tuning
 	MaxConcurrentLookups int
 	StatisticsWindow     time.Duration
+	
+	// LatencyWindow is the span of recent lookups latency quantiles cover,
+	// kept as a ring of LatencyWindowSlots histograms. More slots retire
+	// old lookups in smaller steps at the cost of slower snapshots.
+	LatencyWindow        time.Duration
+	LatencyWindowSlots   int
 }
 
 type OptimizationLevel int
@@ -148,26 +245,87 @@ func NewRoutingTable(
 		config = DefaultRoutingConfig()
 	}
 	
+	reservations := NewBandwidthReservations(networkGraph)
+	loadBalancer := NewLoadBalancer(config.LoadBalanceThreshold, config.PathRequestCapacity)
+	loadBalancer.reservations = reservations
+	
 	return &RoutingTable{
 		networkGraph:  networkGraph,
 		searchEngine:  searchEngine,
 		optimizer:     optimizer,
 		routeCache:    NewRouteCache(config.CacheSize, config.CacheTTL),
-		loadBalancer:  NewLoadBalancer(config.LoadBalanceThreshold),
-		metrics:       NewRoutingMetrics(),
+		loadBalancer:  loadBalancer,
+		metrics:       NewRoutingMetrics(config.LatencyWindow, config.LatencyWindowSlots),
 		config:        config,
+		templates:     NewConstraintTemplates(),
+		reservations:  reservations,
+		drPlans:       NewDRPlans(),
 	}
 }
 
 // LookupRoute finds the optimal route for a destination
-func (rt *RoutingTable) LookupRoute(request RoutingRequest) (*RoutingResponse, error) {
+func (rt *RoutingTable) LookupRoute(request RoutingRequest) (response *RoutingResponse, err error) {
 	startTime := time.Now()
 	
+	ctx, span := tracer.Start(request.Context, "routing.LookupRoute",
+		attribute.Int64("alm.source_id", request.Source),
+		attribute.Int64("alm.destination_id", request.Destination),
+		attribute.String("alm.service_type", request.ServiceType),
+		attribute.Int("alm.qos_class", int(request.QoSClass)),
+	)
+	request.Context = ctx
+	defer func() {
+		if response != nil {
+			rt.metrics.RecordClassLookup(request.QoSClass, response.DecisionTime)
+			span.SetAttributes(
+				attribute.Bool("alm.cache_hit", response.CacheHit),
+				attribute.Bool("alm.coalesced", response.Coalesced),
+				attribute.Int("alm.alternatives", len(response.Alternatives)),
+				attribute.Int64("alm.decision_time_us", response.DecisionTime.Microseconds()),
+			)
+			if response.Failover != nil {
+				span.SetAttributes(attribute.String("alm.failover_region", response.Failover.Region))
+			}
+		}
+		if err != nil {
+			logger.Ctx(ctx).Debug("Route lookup failed",
+				zap.Int64("source", request.Source),
+				zap.Int64("destination", request.Destination),
+				zap.Error(err),
+			)
+		}
+		tracing.End(span, err)
+	}()
+	
 	// Validate request
 	if err := rt.validateRequest(request); err != nil {
 		return nil, fmt.Errorf("invalid routing request: %w", err)
 	}
 	
+	// Expand the named constraint template
+	if request.Constraints, err = rt.templates.Resolve(request.ConstraintTemplate, request.Constraints); err != nil {
+		return nil, fmt.Errorf("invalid routing request: %w", err)
+	}
+	
+	// Redirect destinations in failed regions under their DR plans
+	failover, err := rt.applyDRPlan(&request)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Landmark bounds rule out latency limits no path can meet without a
+	// search
+	if limit := request.Constraints.MaxLatency; limit > 0 && !rt.networkGraph.PathPossibleWithin(request.Source, request.Destination, limit) {
+		return nil, fmt.Errorf("%w: %v to destination %d", graph.ErrNoPathWithin, limit, request.Destination)
+	}
+	
+	// Disjoint paths are found together on every lookup; the cache holds
+	// single routes
+	if request.Disjoint != graph.NotDisjoint {
+		response, err = rt.lookupDisjoint(request, failover, startTime)
+		return response, err
+	}
+	
 	// Check cache first
 	cacheKey := rt.createCacheKey(request)
 	if cached := rt.routeCache.Get(cacheKey); cached != nil {
@@ -175,11 +333,13 @@ func (rt *RoutingTable) LookupRoute(request RoutingRequest) (*RoutingResponse, e
 		
 		// Verify route is still valid
 		if rt.isRouteValid(cached, request) {
-			response := &RoutingResponse{
+			response = &RoutingResponse{
 				Route:        cached,
 				DecisionTime: time.Since(startTime),
 				CacheHit:     true,
 				Confidence:   cached.Confidence,
+				Weight:       1,
+				Failover:     failover,
 			}
 			
 			cached.LastUsed = time.Now()
@@ -187,51 +347,149 @@ func (rt *RoutingTable) LookupRoute(request RoutingRequest) (*RoutingResponse, e
 			return response, nil
 		} else {
 			rt.routeCache.Invalidate(cacheKey)
+			logger.Ctx(ctx).Debug("Cached route no longer valid",
+				zap.Int64("destination", request.Destination),
+			)
 		}
 	}
 	
+	// A route cached under looser constraints that meets these is the one
+	// discovery would pick
+	if compatible := rt.routeCache.GetCompatible(cacheKey, request.Constraints, func(route *RouteEntry) bool {
+		return rt.isRouteValid(route, request)
+	}); compatible != nil {
+		rt.metrics.RecordCacheHit()
+		return &RoutingResponse{
+			Route:        compatible,
+			DecisionTime: time.Since(startTime),
+			CacheHit:     true,
+			Confidence:   compatible.Confidence,
+			Weight:       1,
+			Failover:     failover,
+		}, nil
+	}
+	
 	rt.metrics.RecordCacheMiss()
 	
-	// Perform route discovery based on optimization level
-	routes, err := rt.discoverRoutes(request)
+	// Perform route discovery, shared with concurrent misses on the same key
+	discovered, coalesced, err := rt.discoverShared(cacheKey, request)
 	if err != nil {
-		return nil, fmt.Errorf("route discovery failed: %w", err)
-	}
-	
-	if len(routes) == 0 {
-		return nil, fmt.Errorf("no valid routes found to destination %d", request.Destination)
+		return nil, err
 	}
-	
-	// Select best route using load balancing
-	selectedRoute, alternatives := rt.selectOptimalRoute(routes, request)
-	
-	// Cache the result
-	rt.routeCache.Put(cacheKey, selectedRoute)
+	selectedRoute, alternatives := discovered.route, discovered.alternatives
 	
 	// Update metrics
 	rt.metrics.RecordSuccessfulLookup(time.Since(startTime))
+	if coalesced {
+		rt.metrics.RecordCoalescedLookup()
+	}
 	
-	response := &RoutingResponse{
+	response = &RoutingResponse{
 		Route:          selectedRoute,
 		Alternatives:   alternatives,
 		DecisionTime:   time.Since(startTime),
 		CacheHit:       false,
 		Confidence:     selectedRoute.Confidence,
+		Coalesced:      coalesced,
 		LoadBalanced:   len(alternatives) > 0,
 		SelectedReason: rt.getSelectionReason(selectedRoute, alternatives),
+		Failover:       failover,
 	}
+	response.Weight, response.AlternativeWeights = rt.trafficWeights(selectedRoute, alternatives, request)
+	response.Diversity = routeDiversity(selectedRoute, alternatives)
 	
 	return response, nil
 }
 
+// discoveredRoute is the route selected by a discovery and its alternatives
+type discoveredRoute struct {
+	route        *RouteEntry
+	alternatives []*RouteEntry
+}
+
+// discoverShared discovers, selects and caches a route for a cache miss.
+// Concurrent misses on the same key wait for a single discovery, which is
+// detached from the cancellation of the lookup that started it, and report
+// themselves coalesced. A waiter whose constraints the shared route does not
+// meet runs its own discovery.
+func (rt *RoutingTable) discoverShared(cacheKey RouteKey, request RoutingRequest) (*discoveredRoute, bool, error) {
+	led := false
+	flight := rt.discoveries.DoChan(cacheKey.String(), func() (interface{}, error) {
+		led = true
+		detached := request
+		detached.Context = context.WithoutCancel(request.Context)
+		return rt.discoverAndCache(cacheKey, detached)
+	})
+	
+	select {
+	case <-request.Context.Done():
+		return nil, false, request.Context.Err()
+	case result := <-flight:
+		if result.Err != nil {
+			return nil, false, result.Err
+		}
+		discovered := result.Val.(*discoveredRoute)
+		if led {
+			return discovered, false, nil
+		}
+		if rt.meetsConstraints(discovered.route, request.Constraints) {
+			return discovered, true, nil
+		}
+		discovered, err := rt.discoverAndCache(cacheKey, request)
+		return discovered, false, err
+	}
+}
+
+// discoverAndCache discovers candidate routes, selects one using load
+// balancing and caches it
+func (rt *RoutingTable) discoverAndCache(cacheKey RouteKey, request RoutingRequest) (*discoveredRoute, error) {
+	// Perform route discovery based on optimization level
+	routes, err := rt.discoverRoutes(request)
+	if err != nil {
+		return nil, fmt.Errorf("route discovery failed: %w", err)
+	}
+	
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("no valid routes found to destination %d", request.Destination)
+	}
+	
+	// Drop routes whose bottleneck node or reserved edge has no capacity
+	// for the request, then those crossing unhealthy nodes while any
+	// healthy route remains
+	routes = rt.loadBalancer.AdmitRoutes(routes, request.Constraints.MinThroughput)
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("%w to destination %d: every route crosses a node or reserved edge at capacity", ErrNoCapacity, request.Destination)
+	}
+	routes = rt.loadBalancer.HealthyRoutes(routes)
+	
+	// Select best route using load balancing, noting failovers from the
+	// path previously selected
+	selectedRoute, alternatives := rt.selectOptimalRoute(routes, request)
+	rt.loadBalancer.RecordSelection(request.Source, request.Destination, selectedRoute, request.Constraints.MinThroughput)
+	
+	// Cache the result
+	if !request.NoCache {
+		rt.routeCache.PutConstrained(cacheKey, request.Constraints, selectedRoute)
+	}
+	
+	return &discoveredRoute{route: selectedRoute, alternatives: alternatives}, nil
+}
+
 // discoverRoutes finds candidate routes using different algorithms based on optimization level
 func (rt *RoutingTable) discoverRoutes(request RoutingRequest) ([]*RouteEntry, error) {
-	_, cancel := context.WithTimeout(request.Context, rt.config.SearchTimeout)
+	ctx, cancel := context.WithTimeout(request.Context, rt.config.SearchTimeout)
 	defer cancel()
 	
+	level := rt.optimizationLevel()
+	ctx, span := tracer.Start(ctx, "routing.discoverRoutes",
+		attribute.Int("alm.optimization_level", int(level)),
+	)
+	defer span.End()
+	request.Context = ctx
+	
 	var routes []*RouteEntry
 	
-	switch rt.config.OptimizationLevel {
+	switch level {
 	case FastLookup:
 		// Use simple graph search for speed
 		route, err := rt.fastGraphSearch(request)
@@ -255,7 +513,7 @@ func (rt *RoutingTable) discoverRoutes(request RoutingRequest) ([]*RouteEntry, e
 	case DeepOptimization:
 		// Use multi-objective optimization for best results
 		optReq := rt.createOptimizationRequest(request)
-		result, err := rt.optimizer.Optimize(optReq)
+		result, err := rt.optimizer.Optimize(*optReq)
 		if err == nil {
 			for _, solution := range result.ParetoSolutions {
 				route := rt.convertOptimizationSolution(solution, request)
@@ -272,6 +530,11 @@ func (rt *RoutingTable) discoverRoutes(request RoutingRequest) ([]*RouteEntry, e
 	// Filter routes by constraints
 	validRoutes := rt.filterRoutesByConstraints(routes, request.Constraints)
 	
+	span.SetAttributes(
+		attribute.Int("alm.candidate_routes", len(routes)),
+		attribute.Int("alm.valid_routes", len(validRoutes)),
+	)
+	
 	return validRoutes, nil
 }
 
@@ -312,19 +575,33 @@ func (rt *RoutingTable) selectOptimalRoute(routes []*RouteEntry, request Routing
 	return primaryRoute, alternatives
 }
 
+// trafficWeights splits traffic between a selected route and its
+// alternatives by the load headroom left on their paths
+func (rt *RoutingTable) trafficWeights(selected *RouteEntry, alternatives []*RouteEntry, request RoutingRequest) (float64, []float64) {
+	if len(alternatives) == 0 {
+		return 1, nil
+	}
+	
+	routes := make([]*RouteEntry, 0, len(alternatives)+1)
+	routes = append(routes, selected)
+	routes = append(routes, alternatives...)
+	weights := rt.loadBalancer.TrafficWeights(routes, request.Constraints.MinThroughput)
+	return weights[0], weights[1:]
+}
+
 // UpdateRouteMetrics updates metrics for a route based on actual performance
 func (rt *RoutingTable) UpdateRouteMetrics(destination int64, actualMetrics RouteMetrics, success bool) {
 	rt.mutex.Lock()
 	defer rt.mutex.Unlock()
 	
-	// Update route in cache if it exists
-	cacheKey := fmt.Sprintf("dest-%d", destination)
-	if route := rt.routeCache.GetByKey(cacheKey); route != nil {
+	// Update cached routes to the destination
+	rt.routeCache.UpdateByDestination(destination, func(route *RouteEntry) {
 		rt.updateRouteMetricsInternal(route, actualMetrics, success)
-	}
+		rt.loadBalancer.UpdatePathMetrics(route, actualMetrics, success)
+	})
 	
-	// Update associative search engine with feedback
-	if rt.searchEngine != nil {
+	// Update associative search engine with feedback, unless in brownout
+	if rt.searchEngine != nil && !rt.brownout.Load() {
 		reward := rt.calculateLearningReward(actualMetrics, success)
 		// Update associations based on performance
 		rt.updateAssociativeLearning(destination, actualMetrics, reward)
@@ -337,24 +614,61 @@ func (rt *RoutingTable) UpdateRouteMetrics(destination int64, actualMetrics Rout
 	rt.metrics.RecordRouteUpdate(actualMetrics, success)
 }
 
-// InvalidateRoute removes a route from the cache
+// InvalidateRoute removes the routes to a destination from the cache
 func (rt *RoutingTable) InvalidateRoute(destination int64, reason string) {
 	rt.mutex.Lock()
 	defer rt.mutex.Unlock()
 	
-	cacheKey := fmt.Sprintf("dest-%d", destination)
-	rt.routeCache.Invalidate(cacheKey)
+	rt.routeCache.InvalidateByDestination(destination)
 	
 	rt.metrics.RecordInvalidation(reason)
 }
 
+// InvalidateCache removes every cached route
+func (rt *RoutingTable) InvalidateCache() {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	
+	rt.routeCache.Purge()
+	rt.metrics.RecordInvalidation("purge")
+}
+
+// InvalidateTopology removes the cached routes affected by a topology change
+// and returns how many were removed
+func (rt *RoutingTable) InvalidateTopology(change TopologyChange) int {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	
+	removed := rt.routeCache.InvalidateByChange(change)
+	if removed > 0 {
+		rt.metrics.RecordInvalidation("topology_change")
+	}
+	
+	return removed
+}
+
+// DrainNode removes share, from 0 to 1, of the cached routes through a
+// node, least recently used first, returning how many were removed and how
+// many remain. A zero share only counts them.
+func (rt *RoutingTable) DrainNode(nodeID int64, share float64) (removed, remaining int) {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	
+	removed, remaining = rt.routeCache.InvalidateThrough(nodeID, share)
+	if removed > 0 {
+		rt.metrics.RecordInvalidation("node_drain")
+	}
+	
+	return removed, remaining
+}
+
 // GetRoutingStats returns current routing table statistics
 func (rt *RoutingTable) GetRoutingStats() RoutingStats {
 	rt.mutex.RLock()
 	defer rt.mutex.RUnlock()
 	
 	return RoutingStats{
-		TotalLookups:      rt.metrics.TotalLookups,
+		TotalLookups:      rt.metrics.TotalLookups(),
 		CacheHitRate:     rt.metrics.GetCacheHitRate(),
 		AverageLatency:   rt.metrics.GetAverageLatency(),
 		SuccessRate:      rt.metrics.GetSuccessRate(),
@@ -364,6 +678,121 @@ func (rt *RoutingTable) GetRoutingStats() RoutingStats {
 	}
 }
 
+// GetRoutingMetrics returns the metrics collector for route lookups
+func (rt *RoutingTable) GetRoutingMetrics() *RoutingMetrics {
+	return rt.metrics
+}
+
+// GetRouteCacheStats returns route cache statistics
+func (rt *RoutingTable) GetRouteCacheStats() RouteCacheStatistics {
+	return rt.routeCache.GetStats()
+}
+
+// GetLoadBalancerStats returns load balancer statistics
+func (rt *RoutingTable) GetLoadBalancerStats() LoadBalancerStatistics {
+	return rt.loadBalancer.GetLoadBalancerStats()
+}
+
+// Config returns a copy of the routing configuration
+func (rt *RoutingTable) Config() RoutingConfig {
+	rt.mutex.RLock()
+	defer rt.mutex.RUnlock()
+	
+	return *rt.config
+}
+
+// UpdateConfig applies a new routing configuration. The route cache is
+// resized when CacheSize changes; other settings apply to the next lookup.
+func (rt *RoutingTable) UpdateConfig(config RoutingConfig) error {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	
+	if config.CacheSize != rt.config.CacheSize {
+		if err := rt.routeCache.Resize(config.CacheSize); err != nil {
+			return err
+		}
+	}
+	if config.CacheTTL != rt.config.CacheTTL {
+		rt.routeCache.SetTTL(rt.effectiveCacheTTL(config.CacheTTL))
+	}
+	
+	rt.config = &config
+	return nil
+}
+
+// ResizeRouteCache changes the route cache capacity without changing the
+// configured CacheSize, for callers that shrink the cache under memory
+// pressure
+func (rt *RoutingTable) ResizeRouteCache(size int) error {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	
+	return rt.routeCache.Resize(size)
+}
+
+// GetCachedRoutes returns up to limit cached routes, most used first
+func (rt *RoutingTable) GetCachedRoutes(limit int) []*RouteEntry {
+	return rt.routeCache.GetMostUsedRoutes(limit)
+}
+
+// ExportRoutes returns the unexpired cached routes keyed by cache key
+func (rt *RoutingTable) ExportRoutes() map[string]*RouteEntry {
+	return rt.routeCache.Export()
+}
+
+// ImportRoutes warms the route cache with previously exported routes and
+// returns the number added
+func (rt *RoutingTable) ImportRoutes(routes map[string]*RouteEntry) int {
+	return rt.routeCache.Import(routes)
+}
+
+// NodeInFlight returns the requests in flight through a node
+func (rt *RoutingTable) NodeInFlight(nodeID int64) int64 {
+	return rt.loadBalancer.NodeInFlight(nodeID)
+}
+
+// BeginPathRequest counts a request in flight on the path through nodeIDs,
+// which carries up to throughput Mbps, towards the path's load. The returned
+// function ends the request, counting the bytes it carried.
+func (rt *RoutingTable) BeginPathRequest(nodeIDs []int64, throughput float64) (end func(bytes int64)) {
+	rt.loadBalancer.BeginPathRequest(nodeIDs, throughput)
+	return func(bytes int64) {
+		rt.loadBalancer.EndPathRequest(nodeIDs, bytes)
+	}
+}
+
+// FailoverEvents returns the channel failovers between paths are reported
+// on; it should have a single reader
+func (rt *RoutingTable) FailoverEvents() <-chan FailoverEvent {
+	return rt.loadBalancer.FailoverEvents()
+}
+
+// UpdateNodeHealth records a node's health. Cached routes through a node
+// that turns unhealthy are dropped, so the next lookup fails over.
+func (rt *RoutingTable) UpdateNodeHealth(nodeID int64, isHealthy bool, metrics NodeHealthMetrics) {
+	rt.loadBalancer.UpdateNodeHealth(nodeID, isHealthy, metrics)
+	if !isHealthy {
+		rt.InvalidateTopology(TopologyChange{Nodes: []int64{nodeID}})
+	}
+}
+
+// SetNodeCapacity registers what a node can serve; routes crossing a node
+// without capacity for another request are not selected
+func (rt *RoutingTable) SetNodeCapacity(nodeID int64, capacity NodeCapacity) {
+	rt.loadBalancer.SetNodeCapacity(nodeID, capacity)
+}
+
+// UpdateNodeUsage records the capacity a node reports in use
+func (rt *RoutingTable) UpdateNodeUsage(nodeID int64, usage NodeUsage) {
+	rt.loadBalancer.UpdateNodeUsage(nodeID, usage)
+}
+
+// UpdateNodeBackpressure feeds transport flow control pressure towards a node
+// into load-balanced path selection
+func (rt *RoutingTable) UpdateNodeBackpressure(nodeID int64, pressure float64) {
+	rt.loadBalancer.UpdateNodeBackpressure(nodeID, pressure)
+}
+
 // Helper methods
 
 func (rt *RoutingTable) validateRequest(request RoutingRequest) error {
@@ -375,22 +804,42 @@ func (rt *RoutingTable) validateRequest(request RoutingRequest) error {
 		return fmt.Errorf("invalid node IDs")
 	}
 	
+	if request.Disjoint < graph.NotDisjoint || request.Disjoint > graph.NodeDisjoint {
+		return fmt.Errorf("unknown disjoint mode %s", request.Disjoint)
+	}
+	
+	if request.DisjointPaths != 0 && (request.DisjointPaths < 2 || request.DisjointPaths > maxDisjointPaths) {
+		return fmt.Errorf("disjoint paths must be between 2 and %d, got %d", maxDisjointPaths, request.DisjointPaths)
+	}
+	
 	return nil
 }
 
-func (rt *RoutingTable) createCacheKey(request RoutingRequest) string {
-	return fmt.Sprintf("%d-%d-%s-%d", request.Source, request.Destination, 
-		request.ServiceType, int(request.QoSClass))
+// ConstraintTemplates returns the templates requests reference by name
+func (rt *RoutingTable) ConstraintTemplates() *ConstraintTemplates {
+	return rt.templates
+}
+
+func (rt *RoutingTable) createCacheKey(request RoutingRequest) RouteKey {
+	return RouteKey{
+		Source:      request.Source,
+		Destination: request.Destination,
+		ServiceType: request.ServiceType,
+		QoSClass:    request.QoSClass,
+		Constraints: request.Constraints.Fingerprint(),
+	}
 }
 
 func (rt *RoutingTable) isRouteValid(route *RouteEntry, request RoutingRequest) bool {
 	// Check if route is too old
-	if time.Since(route.CreatedAt) > rt.config.CacheTTL {
+	if time.Since(route.CreatedAt) > rt.effectiveCacheTTL(rt.config.CacheTTL) {
 		return false
 	}
 	
-	// Check if route meets current constraints
-	return rt.meetsConstraints(route, request.Constraints)
+	// Check if route meets current constraints and its nodes are healthy
+	// with capacity
+	return rt.meetsConstraints(route, request.Constraints) &&
+		rt.loadBalancer.RouteUsable(route, request.Constraints.MinThroughput)
 }
 
 func (rt *RoutingTable) meetsConstraints(route *RouteEntry, constraints RouteConstraints) bool {
@@ -449,9 +898,14 @@ func DefaultRoutingConfig() *RoutingConfig {
 		SearchTimeout:       1 * time.Second,
 		OptimizationLevel:   BalancedOptimization,
 		LoadBalanceThreshold: 0.8,
-		HealthCheckInterval: 30 * time.Second,
+		HealthCheckInterval: defaultHealthCheckInterval,
+		HealthCheckFailures: defaultHealthCheckFailures,
+		PathRequestCapacity: 100,
+		LoadRefreshInterval: defaultLoadRefreshInterval,
 		MaxConcurrentLookups: 100,
 		StatisticsWindow:    1 * time.Hour,
+		LatencyWindow:       5 * time.Minute,
+		LatencyWindowSlots:  5,
 	}
 }
 
@@ -462,20 +916,25 @@ func (rt *RoutingTable) fastGraphSearch(request RoutingRequest) (*RouteEntry, er
 		return nil, err
 	}
 	
+	return rt.pathRoute(path, request), nil
+}
+
+// pathRoute converts a graph path to a route entry
+func (rt *RoutingTable) pathRoute(path *graph.OptimalPath, request RoutingRequest) *RouteEntry {
 	// Calculate route metrics
 	metrics := rt.calculatePathMetrics(path)
 	
 	return &RouteEntry{
 		Destination:  request.Destination,
 		NextHop:     path.NodeIDs[1], // First hop after source
-		Path:        path.Nodes,
+		Path:        rt.pathNodes(path),
 		Metrics:     metrics,
 		QualityScore: rt.calculateQualityScore(metrics, request.QoSClass),
 		CreatedAt:   time.Now(),
 		LastUsed:    time.Now(),
 		UseCount:    0,
 		Confidence:  0.8, // High confidence for fast search
-	}, nil
+	}
 }
 
 // createSearchRequest converts routing request to search request
@@ -487,12 +946,13 @@ func (rt *RoutingTable) createSearchRequest(request RoutingRequest) *associative
 		QoSClass:    int(request.QoSClass),
 		MaxResults:  rt.config.MaxAlternatives,
 		Timeout:     rt.config.SearchTimeout,
+		MaxLatency:  request.Constraints.MaxLatency,
 	}
 }
 
 // convertSearchResult converts search result to route entry
 func (rt *RoutingTable) convertSearchResult(result *associative.SearchResult, request RoutingRequest) *RouteEntry {
-	if result == nil || len(result.BestPath.Nodes) == 0 {
+	if result == nil || result.BestPath == nil || len(result.BestPath.NodeIDs) < 2 {
 		return nil
 	}
 	
@@ -501,7 +961,7 @@ func (rt *RoutingTable) convertSearchResult(result *associative.SearchResult, re
 	return &RouteEntry{
 		Destination:  request.Destination,
 		NextHop:     result.BestPath.NodeIDs[1],
-		Path:        result.BestPath.Nodes,
+		Path:        rt.pathNodes(result.BestPath),
 		Metrics:     metrics,
 		QualityScore: rt.calculateQualityScore(metrics, request.QoSClass),
 		CreatedAt:   time.Now(),
@@ -556,6 +1016,7 @@ func (rt *RoutingTable) convertOptimizationSolution(solution *optimization.Routi
 		Reliability: solution.AvgReliability,
 		Cost:        solution.TotalCost,
 		HopCount:    solution.HopCount,
+		Load:        rt.loadBalancer.GetPathLoad(solution.Path),
 	}
 	
 	return &RouteEntry{
@@ -571,12 +1032,13 @@ func (rt *RoutingTable) convertOptimizationSolution(solution *optimization.Routi
 	}
 }
 
-// filterRoutesByConstraints filters routes that don't meet constraints
+// filterRoutesByConstraints filters routes that don't meet constraints,
+// dropping candidates that could not be converted to a route
 func (rt *RoutingTable) filterRoutesByConstraints(routes []*RouteEntry, constraints RouteConstraints) []*RouteEntry {
 	filtered := make([]*RouteEntry, 0, len(routes))
 	
 	for _, route := range routes {
-		if rt.meetsConstraints(route, constraints) {
+		if route != nil && rt.meetsConstraints(route, constraints) {
 			filtered = append(filtered, route)
 		}
 	}
@@ -586,16 +1048,52 @@ func (rt *RoutingTable) filterRoutesByConstraints(routes []*RouteEntry, constrai
 
 // calculatePathMetrics calculates metrics for a path
 func (rt *RoutingTable) calculatePathMetrics(path *graph.OptimalPath) RouteMetrics {
+	jitter, packetLoss := rt.pathEdgeQuality(path)
 	return RouteMetrics{
-		Latency:     time.Duration(path.TotalLatency) * time.Microsecond,
+		Latency:     path.TotalLatency,
 		Throughput:  path.MinThroughput,
 		Reliability: path.AvgReliability,
 		Cost:        path.TotalCost,
 		HopCount:    len(path.NodeIDs) - 1,
 		Load:        rt.calculatePathLoad(path),
-		Jitter:      time.Duration(path.TotalJitter) * time.Microsecond,
-		PacketLoss:  path.AvgPacketLoss,
+		Jitter:      jitter,
+		PacketLoss:  packetLoss,
+	}
+}
+
+// pathEdgeQuality sums the jitter and averages the packet loss of the edges
+// along path
+func (rt *RoutingTable) pathEdgeQuality(path *graph.OptimalPath) (time.Duration, float64) {
+	var (
+		jitter     time.Duration
+		packetLoss float64
+		edges      int
+	)
+	for i := 0; i+1 < len(path.NodeIDs); i++ {
+		edge, exists := rt.networkGraph.GetEdge(path.NodeIDs[i], path.NodeIDs[i+1])
+		if !exists {
+			continue
+		}
+		jitter += edge.Jitter
+		packetLoss += edge.PacketLoss
+		edges++
+	}
+	if edges > 0 {
+		packetLoss /= float64(edges)
+	}
+	return jitter, packetLoss
+}
+
+// pathNodes resolves the nodes along path, skipping any removed since it
+// was computed
+func (rt *RoutingTable) pathNodes(path *graph.OptimalPath) []*graph.NetworkNode {
+	nodes := make([]*graph.NetworkNode, 0, len(path.NodeIDs))
+	for _, id := range path.NodeIDs {
+		if node, exists := rt.networkGraph.GetNode(id); exists {
+			nodes = append(nodes, node)
+		}
 	}
+	return nodes
 }
 
 // calculateQualityScore calculates route quality score based on QoS class
@@ -614,10 +1112,9 @@ func (rt *RoutingTable) calculateQualityScore(metrics RouteMetrics, qosClass QoS
 	}
 }
 
-// calculatePathLoad calculates current load on a path
+// calculatePathLoad returns the load balancer's current load on a path
 func (rt *RoutingTable) calculatePathLoad(path *graph.OptimalPath) float64 {
-	// This would integrate with load balancer to get real load
-	return 0.5 // Placeholder
+	return rt.loadBalancer.GetPathLoad(rt.pathNodes(path))
 }
 
 // convertConstraints converts routing constraints to optimization constraints
@@ -0,0 +1,98 @@
+// Package routing implements micro-benchmarks of route lookups
+package routing
+
+import (
+	"context"
+	"testing"
+)
+
+// lookupLevels are the optimization levels lookup misses are measured at.
+// Deep optimization is measured by the optimizer's own benchmarks, as its
+// solutions carry no paths to route over.
+var lookupLevels = []struct {
+	name  string
+	level OptimizationLevel
+}{
+	{"fast", FastLookup},
+	{"balanced", BalancedOptimization},
+}
+
+// newLookupTable returns a routing table over a 100 node test topology at
+// level and the requests it can route
+func newLookupTable(tb testing.TB, level OptimizationLevel) (*RoutingTable, []RoutingRequest) {
+	tb.Helper()
+
+	pb := NewPerformanceBenchmark(100, 400, 1)
+	if err := pb.setupTestTopology(); err != nil {
+		tb.Fatalf("failed to set up topology: %v", err)
+	}
+	tb.Cleanup(pb.testTopology.graph.Close)
+	if err := pb.initializeRoutingTable(); err != nil {
+		tb.Fatalf("failed to initialize routing table: %v", err)
+	}
+	pb.routingTable.config.OptimizationLevel = level
+
+	var requests []RoutingRequest
+	for source := int64(1); source <= 100 && len(requests) < 64; source += 7 {
+		for destination := int64(100); destination > source && len(requests) < 64; destination -= 13 {
+			request := RoutingRequest{
+				Source:      source,
+				Destination: destination,
+				ServiceType: "api",
+				QoSClass:    BestEffort,
+				Constraints: RouteConstraints{MaxHops: 10},
+				Context:     context.Background(),
+			}
+			if _, err := pb.routingTable.LookupRoute(request); err == nil {
+				requests = append(requests, request)
+			}
+		}
+	}
+	if len(requests) == 0 {
+		tb.Fatal("no routable requests in test topology")
+	}
+	return pb.routingTable, requests
+}
+
+// BenchmarkLookupRouteCacheHit measures lookups answered from the route cache
+func BenchmarkLookupRouteCacheHit(b *testing.B) {
+	table, requests := newLookupTable(b, BalancedOptimization)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		response, err := table.LookupRoute(requests[i%len(requests)])
+		if err != nil {
+			b.Fatalf("lookup failed: %v", err)
+		}
+		if !response.CacheHit {
+			b.Fatal("lookup missed the cache")
+		}
+	}
+}
+
+// BenchmarkLookupRouteCacheMiss measures route discovery at each
+// optimization level, emptying the cache before every lookup
+func BenchmarkLookupRouteCacheMiss(b *testing.B) {
+	for _, tc := range lookupLevels {
+		b.Run(tc.name, func(b *testing.B) {
+			table, requests := newLookupTable(b, tc.level)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				table.InvalidateCache()
+				b.StartTimer()
+
+				response, err := table.LookupRoute(requests[i%len(requests)])
+				if err != nil {
+					b.Fatalf("lookup failed: %v", err)
+				}
+				if response.CacheHit {
+					b.Fatal("lookup hit the cache")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+func TestLookupRouteCoalescesDiscoveries(t *testing.T) {
+	const lookups = 50
+
+	table, requests := newLookupTable(t, FastLookup)
+	request := requests[0]
+	table.InvalidateCache()
+	table.metrics.Reset()
+	putsBefore := table.GetRouteCacheStats().Puts
+
+	// Hold a discovery in flight so every lookup joins it
+	key := table.createCacheKey(request)
+	release := make(chan struct{})
+	leader := table.discoveries.DoChan(key.String(), func() (interface{}, error) {
+		<-release
+		return table.discoverAndCache(key, request)
+	})
+
+	responses := make([]*RoutingResponse, lookups)
+	errs := make([]error, lookups)
+	var wg sync.WaitGroup
+	for i := 0; i < lookups; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i], errs[i] = table.LookupRoute(request)
+		}(i)
+	}
+	for table.metrics.GetCurrentStats().CacheMisses < lookups {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	result := <-leader
+	if result.Err != nil {
+		t.Fatalf("discovery failed: %v", result.Err)
+	}
+	route := result.Val.(*discoveredRoute).route
+	for i := range responses {
+		if errs[i] != nil {
+			t.Fatalf("lookup %d failed: %v", i, errs[i])
+		}
+		if !responses[i].Coalesced || responses[i].Route != route {
+			t.Errorf("lookup %d did not share the discovery: %+v", i, responses[i])
+		}
+	}
+	if coalesced := table.metrics.GetCurrentStats().CoalescedLookups; coalesced != lookups {
+		t.Errorf("recorded %d coalesced lookups, want %d", coalesced, lookups)
+	}
+	if puts := table.GetRouteCacheStats().Puts - putsBefore; puts != 1 {
+		t.Errorf("cached %d discoveries, want 1", puts)
+	}
+}
+
+func TestLookupRouteRejectsNodesAtCapacity(t *testing.T) {
+	table, requests := newLookupTable(t, FastLookup)
+	request := requests[0]
+
+	// Every route crosses the destination, so filling it leaves none
+	table.SetNodeCapacity(request.Destination, NodeCapacity{Connections: 1})
+	table.UpdateNodeUsage(request.Destination, NodeUsage{Connections: 1})
+	if _, err := table.LookupRoute(request); !errors.Is(err, ErrNoCapacity) {
+		t.Fatalf("lookup through a full destination returned %v, want ErrNoCapacity", err)
+	}
+
+	table.UpdateNodeUsage(request.Destination, NodeUsage{})
+	if _, err := table.LookupRoute(request); err != nil {
+		t.Fatalf("lookup after the destination freed up failed: %v", err)
+	}
+}
+
+func TestLookupRouteRulesOutUnmeetableLatency(t *testing.T) {
+	ng := graph.NewNetworkGraph(4)
+	t.Cleanup(ng.Close)
+	for id := int64(1); id <= 4; id++ {
+		if err := ng.AddNode(&graph.NetworkNode{ID: id, Reliability: 1}); err != nil {
+			t.Fatalf("AddNode: %v", err)
+		}
+	}
+	for _, edge := range []*graph.NetworkEdge{
+		{From: 1, To: 2, Weight: 10, Latency: 10 * time.Microsecond, Reliability: 1},
+		{From: 2, To: 4, Weight: 10, Latency: 10 * time.Microsecond, Reliability: 1},
+		{From: 1, To: 3, Weight: 15, Latency: 15 * time.Microsecond, Reliability: 1},
+		{From: 3, To: 4, Weight: 15, Latency: 15 * time.Microsecond, Reliability: 1},
+	} {
+		if err := ng.AddEdge(edge); err != nil {
+			t.Fatalf("AddEdge: %v", err)
+		}
+	}
+	ng.SetLandmarks(4)
+	config := DefaultRoutingConfig()
+	config.OptimizationLevel = FastLookup
+	table := NewRoutingTable(ng, nil, nil, config)
+
+	// The fastest path takes 20µs, so a 15µs limit fails before any search
+	request := RoutingRequest{Source: 1, Destination: 4, Context: context.Background()}
+	request.Constraints.MaxLatency = 15 * time.Microsecond
+	if _, err := table.LookupRoute(request); !errors.Is(err, graph.ErrNoPathWithin) {
+		t.Fatalf("lookup under 15µs returned %v, want ErrNoPathWithin", err)
+	}
+	if misses := table.metrics.GetCurrentStats().CacheMisses; misses != 0 {
+		t.Errorf("ruled out lookup searched %d times", misses)
+	}
+
+	request.Constraints.MaxLatency = 20 * time.Microsecond
+	if response, err := table.LookupRoute(request); err != nil || response.Route.NextHop != 2 {
+		t.Fatalf("lookup under 20µs: %+v, %v; want a route via 2", response, err)
+	}
+}
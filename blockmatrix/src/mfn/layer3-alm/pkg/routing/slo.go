@@ -0,0 +1,404 @@
+// Package routing implements latency SLOs with error budgets per QoS class
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/histogram"
+	"go.uber.org/zap"
+)
+
+const (
+	// SLO histograms resolve latencies to two significant figures, so the
+	// lookups over a threshold are counted to within 1% of it
+	sloSignificantFigures = 2
+
+	// The SLO window is a ring of this many slots, and each burn-rate
+	// window a ring of this many
+	sloWindowSlots  = 30
+	burnWindowSlots = 6
+
+	// minSLOWindow is the shortest SLO window; burn rates are measured over
+	// windows down to 1/8640 of it
+	minSLOWindow = time.Hour
+
+	// minAlertLookups is the fewest lookups in a short burn-rate window
+	// that can fire an alert, so one slow lookup on a quiet class does not
+	minAlertLookups = 10
+)
+
+// Severities of burn-rate alerts
+const (
+	SLOSeverityPage   = "page"
+	SLOSeverityTicket = "ticket"
+)
+
+// burnRatePolicy fires an alert when the error budget burns rate times
+// faster than it would last the SLO window, over both a long window and a
+// short one that lets the alert resolve soon after the burn stops. Windows
+// are the SLO window divided by long and short: over 30 days, a page when
+// an hour burns 2% of the budget and a ticket when six hours burn 5%.
+type burnRatePolicy struct {
+	severity    string
+	long, short time.Duration
+	rate        float64
+}
+
+var burnRatePolicies = []burnRatePolicy{
+	{severity: SLOSeverityPage, long: 720, short: 8640, rate: 14.4},
+	{severity: SLOSeverityTicket, long: 120, short: 1440, rate: 6},
+}
+
+// LatencySLO is a latency objective for the lookups of a QoS class, such as
+// low latency lookups within 1ms at P99 over 30 days. Its error budget is
+// the share of lookups allowed over Threshold, 1 - Quantile.
+type LatencySLO struct {
+	Name      string        `json:"name" yaml:"name"`
+	QoSClass  QoSClass      `json:"qos_class" yaml:"qos_class"`
+	Quantile  float64       `json:"quantile" yaml:"quantile"`
+	Threshold time.Duration `json:"threshold_ns" yaml:"threshold_ns"`
+	Window    time.Duration `json:"window_ns" yaml:"window_ns"`
+}
+
+// Validate checks the objective is satisfiable and its window long enough
+// to measure burn rates over
+func (slo LatencySLO) Validate() error {
+	switch {
+	case slo.Name == "":
+		return errors.New("latency SLO name is required")
+	case slo.QoSClass < BestEffort || slo.QoSClass > CriticalMission:
+		return fmt.Errorf("latency SLO %s: unknown QoS class %d", slo.Name, slo.QoSClass)
+	case !(slo.Quantile > 0 && slo.Quantile < 1):
+		return fmt.Errorf("latency SLO %s: quantile must be between 0 and 1, got %v", slo.Name, slo.Quantile)
+	case slo.Threshold <= 0 || slo.Threshold >= maxTrackedLookupTime:
+		return fmt.Errorf("latency SLO %s: threshold must be positive and below %v, got %v",
+			slo.Name, maxTrackedLookupTime, slo.Threshold)
+	case slo.Window < minSLOWindow:
+		return fmt.Errorf("latency SLO %s: window must be at least %v, got %v", slo.Name, minSLOWindow, slo.Window)
+	}
+	return nil
+}
+
+// ErrorBudget returns the share of lookups allowed over the threshold
+func (slo LatencySLO) ErrorBudget() float64 {
+	return 1 - slo.Quantile
+}
+
+// ValidateLatencySLOs checks every SLO and that their names are unique
+func ValidateLatencySLOs(slos []LatencySLO) error {
+	var problems []error
+	seen := make(map[string]bool, len(slos))
+	for _, slo := range slos {
+		if err := slo.Validate(); err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		if seen[slo.Name] {
+			problems = append(problems, fmt.Errorf("latency SLO %s defined twice", slo.Name))
+		}
+		seen[slo.Name] = true
+	}
+	return errors.Join(problems...)
+}
+
+// DefaultLatencySLOs returns P99 objectives over 30 days: 1ms for low
+// latency and critical mission lookups, and 5ms for best effort ones
+func DefaultLatencySLOs() []LatencySLO {
+	const month = 30 * 24 * time.Hour
+	return []LatencySLO{
+		{Name: "low-latency-p99", QoSClass: LowLatency, Quantile: 0.99, Threshold: time.Millisecond, Window: month},
+		{Name: "critical-mission-p99", QoSClass: CriticalMission, Quantile: 0.99, Threshold: time.Millisecond, Window: month},
+		{Name: "best-effort-p99", QoSClass: BestEffort, Quantile: 0.99, Threshold: 5 * time.Millisecond, Window: month},
+	}
+}
+
+// SLOBurnRate is how many times faster than the SLO window allows the error
+// budget burned over a window
+type SLOBurnRate struct {
+	Window time.Duration
+	Rate   float64
+}
+
+// SLOStatus reports an SLO over its window
+type SLOStatus struct {
+	SLO LatencySLO
+
+	// Lookups in the window, those over the threshold, and the latency at
+	// the objective's quantile
+	Lookups     int64
+	SlowLookups int64
+	Latency     time.Duration
+
+	// Share of the error budget left; negative once it is overspent and
+	// the objective missed
+	BudgetRemaining float64
+	Met             bool
+
+	// Burn rates over the long window of each alerting severity, pages
+	// first, and the severity whose burn rates are exceeded, if any
+	BurnRates []SLOBurnRate
+	Alert     string
+}
+
+// BurnRate returns the burn rate over the long window of severity
+func (status SLOStatus) BurnRate(severity string) float64 {
+	for i, policy := range burnRatePolicies {
+		if policy.severity == severity && i < len(status.BurnRates) {
+			return status.BurnRates[i].Rate
+		}
+	}
+	return 0
+}
+
+// SLOAlert reports a burn-rate alert firing, changing severity or resolving
+type SLOAlert struct {
+	SLO LatencySLO
+
+	// Severity firing, empty once resolved, and the one before
+	Severity string
+	Previous string
+
+	// Burn rate over the long window of the severity firing, or of the
+	// previous one when resolved
+	BurnRate        float64
+	BudgetRemaining float64
+	Time            time.Time
+}
+
+// sloTracker records the lookups of one SLO's class over the SLO window and
+// each burn-rate policy's long and short windows
+type sloTracker struct {
+	slo    LatencySLO
+	window *histogram.Windowed
+	burn   [][2]*histogram.Windowed
+
+	// Severity of the alert firing; guarded by SLOTracker.mutex
+	alert string
+}
+
+func newSLOTracker(slo LatencySLO) *sloTracker {
+	tracker := &sloTracker{
+		slo:    slo,
+		window: histogram.NewWindowed(maxTrackedLookupTime, sloSignificantFigures, slo.Window, sloWindowSlots),
+		burn:   make([][2]*histogram.Windowed, len(burnRatePolicies)),
+	}
+	for i, policy := range burnRatePolicies {
+		tracker.burn[i] = [2]*histogram.Windowed{
+			histogram.NewWindowed(maxTrackedLookupTime, sloSignificantFigures, slo.Window/policy.long, burnWindowSlots),
+			histogram.NewWindowed(maxTrackedLookupTime, sloSignificantFigures, slo.Window/policy.short, burnWindowSlots),
+		}
+	}
+	return tracker
+}
+
+func (st *sloTracker) record(latency time.Duration) {
+	st.window.Record(latency)
+	for _, windows := range st.burn {
+		windows[0].Record(latency)
+		windows[1].Record(latency)
+	}
+}
+
+// slowLookups counts the lookups in h and those over the threshold
+func (st *sloTracker) slowLookups(h *histogram.Histogram) (lookups, slow int64) {
+	lookups = h.Count()
+	if lookups == 0 {
+		return 0, 0
+	}
+	within := int64(h.CumulativeCounts([]time.Duration{st.slo.Threshold})[0])
+	return lookups, max(lookups-within, 0)
+}
+
+// burnRate returns how fast the lookups in h burn the error budget, and
+// how many there were
+func (st *sloTracker) burnRate(h *histogram.Histogram) (float64, int64) {
+	lookups, slow := st.slowLookups(h)
+	if lookups == 0 {
+		return 0, 0
+	}
+	return float64(slow) / float64(lookups) / st.slo.ErrorBudget(), lookups
+}
+
+// status measures the SLO and the severity its burn rates call for
+func (st *sloTracker) status() SLOStatus {
+	snapshot := st.window.Snapshot()
+	status := SLOStatus{
+		SLO:             st.slo,
+		Latency:         snapshot.Quantile(st.slo.Quantile),
+		BudgetRemaining: 1,
+		BurnRates:       make([]SLOBurnRate, 0, len(burnRatePolicies)),
+	}
+	status.Lookups, status.SlowLookups = st.slowLookups(snapshot)
+	if status.Lookups > 0 {
+		status.BudgetRemaining = 1 - float64(status.SlowLookups)/float64(status.Lookups)/st.slo.ErrorBudget()
+	}
+	status.Met = status.BudgetRemaining >= 0
+
+	for i, policy := range burnRatePolicies {
+		long, _ := st.burnRate(st.burn[i][0].Snapshot())
+		short, lookups := st.burnRate(st.burn[i][1].Snapshot())
+		status.BurnRates = append(status.BurnRates, SLOBurnRate{Window: st.burn[i][0].Window(), Rate: long})
+		if status.Alert == "" && long >= policy.rate && short >= policy.rate && lookups >= minAlertLookups {
+			status.Alert = policy.severity
+		}
+	}
+	return status
+}
+
+// SLOTracker tracks latency SLOs against the lookups of their QoS classes
+// and raises burn-rate alerts. Recording takes no lock; the mutex
+// serializes replacing the SLOs with evaluating their alerts.
+type SLOTracker struct {
+	trackers  atomic.Pointer[[]*sloTracker]
+	listeners []func(SLOAlert)
+	mutex     sync.Mutex
+}
+
+// NewSLOTracker creates a tracker for slos, which must be valid
+func NewSLOTracker(slos []LatencySLO) *SLOTracker {
+	st := &SLOTracker{}
+	trackers := make([]*sloTracker, 0, len(slos))
+	for _, slo := range slos {
+		trackers = append(trackers, newSLOTracker(slo))
+	}
+	st.trackers.Store(&trackers)
+	return st
+}
+
+// Replace tracks exactly slos, or leaves the tracker unchanged if any is
+// invalid. SLOs kept unchanged keep their history; redefined ones start
+// afresh but keep their alert, so the next evaluation resolves it.
+func (st *SLOTracker) Replace(slos []LatencySLO) error {
+	if err := ValidateLatencySLOs(slos); err != nil {
+		return err
+	}
+
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	existing := make(map[string]*sloTracker)
+	for _, tracker := range *st.trackers.Load() {
+		existing[tracker.slo.Name] = tracker
+	}
+	trackers := make([]*sloTracker, 0, len(slos))
+	for _, slo := range slos {
+		tracker, exists := existing[slo.Name]
+		if !exists || tracker.slo != slo {
+			redefined := newSLOTracker(slo)
+			if exists {
+				redefined.alert = tracker.alert
+			}
+			tracker = redefined
+		}
+		trackers = append(trackers, tracker)
+	}
+	st.trackers.Store(&trackers)
+	return nil
+}
+
+// reset forgets every lookup recorded and alert fired
+func (st *SLOTracker) reset() {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	previous := *st.trackers.Load()
+	trackers := make([]*sloTracker, 0, len(previous))
+	for _, tracker := range previous {
+		trackers = append(trackers, newSLOTracker(tracker.slo))
+	}
+	st.trackers.Store(&trackers)
+}
+
+// All returns the SLOs tracked
+func (st *SLOTracker) All() []LatencySLO {
+	trackers := *st.trackers.Load()
+	slos := make([]LatencySLO, 0, len(trackers))
+	for _, tracker := range trackers {
+		slos = append(slos, tracker.slo)
+	}
+	return slos
+}
+
+// Record counts a lookup of class against the SLOs of the class
+func (st *SLOTracker) Record(class QoSClass, latency time.Duration) {
+	for _, tracker := range *st.trackers.Load() {
+		if tracker.slo.QoSClass == class {
+			tracker.record(latency)
+		}
+	}
+}
+
+// Status reports every SLO, in the order they were defined
+func (st *SLOTracker) Status() []SLOStatus {
+	trackers := *st.trackers.Load()
+	statuses := make([]SLOStatus, 0, len(trackers))
+	for _, tracker := range trackers {
+		statuses = append(statuses, tracker.status())
+	}
+	return statuses
+}
+
+// OnAlert registers a callback invoked from Evaluate when a burn-rate alert
+// fires, changes severity or resolves
+func (st *SLOTracker) OnAlert(listener func(SLOAlert)) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	st.listeners = append(st.listeners, listener)
+}
+
+// Evaluate measures every SLO, notifies listeners of alerts that changed
+// since the last evaluation, and returns the statuses
+func (st *SLOTracker) Evaluate() []SLOStatus {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	now := time.Now()
+	trackers := *st.trackers.Load()
+	statuses := make([]SLOStatus, 0, len(trackers))
+	for _, tracker := range trackers {
+		status := tracker.status()
+		statuses = append(statuses, status)
+		if status.Alert == tracker.alert {
+			continue
+		}
+
+		alert := SLOAlert{
+			SLO:             tracker.slo,
+			Severity:        status.Alert,
+			Previous:        tracker.alert,
+			BudgetRemaining: status.BudgetRemaining,
+			Time:            now,
+		}
+		if status.Alert != "" {
+			alert.BurnRate = status.BurnRate(status.Alert)
+		} else {
+			alert.BurnRate = status.BurnRate(tracker.alert)
+		}
+		tracker.alert = status.Alert
+
+		logSLOAlert(alert)
+		for _, listener := range st.listeners {
+			listener(alert)
+		}
+	}
+	return statuses
+}
+
+func logSLOAlert(alert SLOAlert) {
+	fields := []zap.Field{
+		zap.String("slo", alert.SLO.Name),
+		zap.String("qos_class", alert.SLO.QoSClass.String()),
+		zap.Float64("burn_rate", alert.BurnRate),
+		zap.Float64("budget_remaining", alert.BudgetRemaining),
+	}
+	if alert.Severity == "" {
+		logger.L().Info("SLO burn-rate alert resolved", append(fields, zap.String("previous", alert.Previous))...)
+		return
+	}
+	logger.L().Warn("SLO burning error budget", append(fields, zap.String("severity", alert.Severity))...)
+}
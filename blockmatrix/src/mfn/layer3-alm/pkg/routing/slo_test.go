@@ -0,0 +1,108 @@
+package routing
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSLOTrackerBurnRateAlerts(t *testing.T) {
+	slo := LatencySLO{Name: "low-latency-p99", QoSClass: LowLatency, Quantile: 0.99, Threshold: time.Millisecond, Window: 30 * 24 * time.Hour}
+	tracker := NewSLOTracker([]LatencySLO{slo})
+	var alerts []SLOAlert
+	tracker.OnAlert(func(alert SLOAlert) { alerts = append(alerts, alert) })
+
+	for i := 0; i < 100; i++ {
+		tracker.Record(LowLatency, 200*time.Microsecond)
+		tracker.Record(BestEffort, 50*time.Millisecond)
+	}
+	status := tracker.Evaluate()[0]
+	if status.Lookups != 100 || status.SlowLookups != 0 || status.BudgetRemaining != 1 || !status.Met || status.Alert != "" {
+		t.Fatalf("status within the objective: %+v", status)
+	}
+
+	// A sixth of lookups over the threshold burns the budget 16.7 times
+	// faster than it lasts, enough to page
+	for i := 0; i < 20; i++ {
+		tracker.Record(LowLatency, 3*time.Millisecond)
+	}
+	status = tracker.Evaluate()[0]
+	if status.SlowLookups != 20 || status.Met || status.Alert != SLOSeverityPage {
+		t.Fatalf("status burning the budget: %+v", status)
+	}
+	if rate := status.BurnRate(SLOSeverityPage); rate < 16 || rate > 17 {
+		t.Errorf("page burn rate %.2f, want about 16.7", rate)
+	}
+	if len(alerts) != 1 || alerts[0].Severity != SLOSeverityPage || alerts[0].Previous != "" || alerts[0].BurnRate != status.BurnRate(SLOSeverityPage) {
+		t.Fatalf("alerts after the burn: %+v", alerts)
+	}
+	tracker.Evaluate()
+	if len(alerts) != 1 {
+		t.Errorf("alert repeated while still firing: %+v", alerts[1:])
+	}
+
+	// Keeping the SLO keeps its history; redefining it starts afresh and
+	// resolves its alert
+	if err := tracker.Replace([]LatencySLO{slo}); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if status := tracker.Status()[0]; status.Lookups != 120 {
+		t.Errorf("kept SLO lost its history: %+v", status)
+	}
+	slo.Threshold = 10 * time.Millisecond
+	if err := tracker.Replace([]LatencySLO{slo}); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	tracker.Evaluate()
+	if len(alerts) != 2 || alerts[1].Severity != "" || alerts[1].Previous != SLOSeverityPage {
+		t.Errorf("alerts after redefining the SLO: %+v", alerts)
+	}
+}
+
+func TestValidateLatencySLOs(t *testing.T) {
+	valid := LatencySLO{Name: "p99", QoSClass: LowLatency, Quantile: 0.99, Threshold: time.Millisecond, Window: 24 * time.Hour}
+	if err := ValidateLatencySLOs(append(DefaultLatencySLOs(), valid)); err != nil {
+		t.Fatalf("valid SLOs rejected: %v", err)
+	}
+
+	for name, mutate := range map[string]func(*LatencySLO){
+		"no name":         func(slo *LatencySLO) { slo.Name = "" },
+		"unknown class":   func(slo *LatencySLO) { slo.QoSClass = 9 },
+		"quantile of one": func(slo *LatencySLO) { slo.Quantile = 1 },
+		"no threshold":    func(slo *LatencySLO) { slo.Threshold = 0 },
+		"short window":    func(slo *LatencySLO) { slo.Window = time.Minute },
+	} {
+		slo := valid
+		mutate(&slo)
+		if err := ValidateLatencySLOs([]LatencySLO{slo}); err == nil {
+			t.Errorf("%s: accepted %+v", name, slo)
+		}
+	}
+	if err := ValidateLatencySLOs([]LatencySLO{valid, valid}); err == nil {
+		t.Error("duplicate SLO names accepted")
+	}
+}
+
+func TestIsPerformingWellReportsMissedSLOs(t *testing.T) {
+	rm := NewRoutingMetrics(0, 0)
+	for i := 0; i < 100; i++ {
+		rm.RecordSuccessfulLookup(100 * time.Microsecond)
+		rm.RecordCacheHit()
+		rm.RecordClassLookup(LowLatency, 100*time.Microsecond)
+	}
+	if ok, issues := rm.IsPerformingWell(); !ok {
+		t.Fatalf("issues within every SLO: %v", issues)
+	}
+
+	for i := 0; i < 5; i++ {
+		rm.RecordClassLookup(LowLatency, 2*time.Millisecond)
+	}
+	ok, issues := rm.IsPerformingWell()
+	if ok || len(issues) != 1 || !strings.Contains(issues[0], "low-latency-p99 missed") {
+		t.Errorf("issues with the low latency SLO missed: %v", issues)
+	}
+	report := rm.GeneratePerformanceReport(time.Minute)
+	if len(report.SLOs) != len(DefaultLatencySLOs()) || report.SLOs[0].Met {
+		t.Errorf("report SLOs: %+v", report.SLOs)
+	}
+}
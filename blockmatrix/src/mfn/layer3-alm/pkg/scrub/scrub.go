@@ -0,0 +1,205 @@
+// Package scrub redacts configured metadata keys and address patterns from
+// state leaving the coordinator: admin exports, logs, trace attributes and
+// debug dumps, so they can be shared with support without leaking internal
+// hostnames or customer labels.
+//
+// Redacted values are replaced by a keyed hash, "redacted-" and 8 hex
+// digits, so an export still shows which values were equal without
+// revealing them. The key is random per Scrubber, so hashes cannot be
+// compared across policies or processes.
+//
+// A nil *Scrubber is valid and leaves everything unchanged.
+package scrub
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RedactedPrefix starts every redacted value
+const RedactedPrefix = "redacted-"
+
+// builtinPatterns are address patterns that may be given by name
+var builtinPatterns = map[string]string{
+	"ipv4": `\b(?:\d{1,3}\.){3}\d{1,3}\b`,
+	"ipv6": `\[?\b(?:[0-9A-Fa-f]{1,4}:){2,7}[0-9A-Fa-f]{1,4}\b\]?`,
+}
+
+// Policy names what is redacted
+type Policy struct {
+	// MetadataKeys are service metadata, tag and log field keys whose
+	// values are redacted whole, matched case-insensitively. A key may be
+	// a pattern such as "customer.*", with path.Match syntax.
+	MetadataKeys []string
+
+	// AddressPatterns are regular expressions whose matches are redacted
+	// from addresses and free text, or "ipv4" and "ipv6" for any address
+	// of that family
+	AddressPatterns []string
+}
+
+// Empty reports whether the policy redacts nothing
+func (p Policy) Empty() bool {
+	return len(p.MetadataKeys) == 0 && len(p.AddressPatterns) == 0
+}
+
+// Validate checks every key and address pattern
+func (p Policy) Validate() error {
+	_, err := New(p)
+	return err
+}
+
+// Scrubber applies a policy
+type Scrubber struct {
+	keys     []string
+	patterns []*regexp.Regexp
+	hashKey  []byte
+}
+
+// New creates a scrubber for policy, or returns nil if the policy is empty
+func New(policy Policy) (*Scrubber, error) {
+	if policy.Empty() {
+		return nil, nil
+	}
+
+	s := &Scrubber{hashKey: make([]byte, 32)}
+	if _, err := rand.Read(s.hashKey); err != nil {
+		return nil, fmt.Errorf("failed to generate scrubbing key: %w", err)
+	}
+
+	var problems []error
+	for _, key := range policy.MetadataKeys {
+		key = strings.ToLower(key)
+		if _, err := path.Match(key, ""); err != nil || key == "" {
+			problems = append(problems, fmt.Errorf("invalid scrubbed metadata key %q", key))
+			continue
+		}
+		s.keys = append(s.keys, key)
+	}
+	for _, pattern := range policy.AddressPatterns {
+		expr := pattern
+		if builtin, ok := builtinPatterns[pattern]; ok {
+			expr = builtin
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("invalid scrubbed address pattern %q: %w", pattern, err))
+			continue
+		}
+		s.patterns = append(s.patterns, re)
+	}
+	if len(problems) > 0 {
+		return nil, errors.Join(problems...)
+	}
+	return s, nil
+}
+
+// RedactsKey reports whether values of the metadata key are redacted whole
+func (s *Scrubber) RedactsKey(key string) bool {
+	if s == nil {
+		return false
+	}
+	key = strings.ToLower(key)
+	for _, pattern := range s.keys {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// String redacts every address pattern match in text
+func (s *Scrubber) String(text string) string {
+	if s == nil {
+		return text
+	}
+	for _, re := range s.patterns {
+		text = re.ReplaceAllStringFunc(text, s.redact)
+	}
+	return text
+}
+
+// Field scrubs value stored under key: it is redacted whole if the key is,
+// otherwise address pattern matches are
+func (s *Scrubber) Field(key, value string) string {
+	if s.RedactsKey(key) {
+		return s.redact(value)
+	}
+	return s.String(value)
+}
+
+// Labels returns a scrubbed copy of labels, such as service tags
+func (s *Scrubber) Labels(labels map[string]string) map[string]string {
+	if s == nil || labels == nil {
+		return labels
+	}
+	scrubbed := make(map[string]string, len(labels))
+	for key, value := range labels {
+		scrubbed[key] = s.Field(key, value)
+	}
+	return scrubbed
+}
+
+// Metadata returns a scrubbed copy of metadata. Values of redacted keys are
+// replaced whole whatever their type; strings elsewhere, including inside
+// nested maps and lists, have address pattern matches redacted.
+func (s *Scrubber) Metadata(metadata map[string]interface{}) map[string]interface{} {
+	if s == nil || metadata == nil {
+		return metadata
+	}
+	scrubbed := make(map[string]interface{}, len(metadata))
+	for key, value := range metadata {
+		if s.RedactsKey(key) {
+			scrubbed[key] = s.redact(fmt.Sprint(value))
+			continue
+		}
+		scrubbed[key] = s.value(value)
+	}
+	return scrubbed
+}
+
+// Strings returns a copy of values with address pattern matches redacted
+func (s *Scrubber) Strings(values []string) []string {
+	if s == nil || values == nil {
+		return values
+	}
+	scrubbed := make([]string, len(values))
+	for i, value := range values {
+		scrubbed[i] = s.String(value)
+	}
+	return scrubbed
+}
+
+func (s *Scrubber) value(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return s.String(v)
+	case map[string]interface{}:
+		return s.Metadata(v)
+	case map[string]string:
+		return s.Labels(v)
+	case []string:
+		return s.Strings(v)
+	case []interface{}:
+		scrubbed := make([]interface{}, len(v))
+		for i, item := range v {
+			scrubbed[i] = s.value(item)
+		}
+		return scrubbed
+	}
+	return value
+}
+
+// redact replaces value with its keyed hash
+func (s *Scrubber) redact(value string) string {
+	mac := hmac.New(sha256.New, s.hashKey)
+	mac.Write([]byte(value))
+	return RedactedPrefix + hex.EncodeToString(mac.Sum(nil)[:4])
+}
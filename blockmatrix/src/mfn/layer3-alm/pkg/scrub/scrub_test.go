@@ -0,0 +1,83 @@
+package scrub
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScrubber(t *testing.T) {
+	s, err := New(Policy{
+		MetadataKeys:    []string{"customer.*", "Owner"},
+		AddressPatterns: []string{"ipv4", `[a-z0-9-]+\.corp\.internal`},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	text := s.String("dial 10.1.2.3:9000 via db-7.corp.internal failed")
+	if strings.Contains(text, "10.1.2.3") || strings.Contains(text, "corp.internal") {
+		t.Errorf("addresses left in %q", text)
+	}
+	if strings.Count(text, RedactedPrefix) != 2 || !strings.Contains(text, ":9000 via ") {
+		t.Errorf("unexpected scrubbed text %q", text)
+	}
+
+	// Equal values redact to equal hashes
+	if s.String("10.1.2.3") != s.String("10.1.2.3") || s.String("10.1.2.3") == s.String("10.1.2.4") {
+		t.Error("redaction does not preserve equality")
+	}
+
+	metadata := s.Metadata(map[string]interface{}{
+		"customer.name": "Acme Corp",
+		"OWNER":         42,
+		"zone":          "eu-1",
+		"peers":         []interface{}{"10.0.0.1", "gateway"},
+		"nested":        map[string]interface{}{"customer.id": "c-123"},
+	})
+	for _, key := range []string{"customer.name", "OWNER"} {
+		if value, _ := metadata[key].(string); !strings.HasPrefix(value, RedactedPrefix) {
+			t.Errorf("%s not redacted: %v", key, metadata[key])
+		}
+	}
+	if metadata["zone"] != "eu-1" {
+		t.Errorf("zone changed to %v", metadata["zone"])
+	}
+	if peers := metadata["peers"].([]interface{}); peers[1] != "gateway" || strings.Contains(peers[0].(string), "10.0.0.1") {
+		t.Errorf("peers scrubbed to %v", peers)
+	}
+	if nested := metadata["nested"].(map[string]interface{}); nested["customer.id"] == "c-123" {
+		t.Error("nested customer key not redacted")
+	}
+
+	if labels := s.Labels(map[string]string{"owner": "team-a", "tier": "gold"}); labels["owner"] == "team-a" || labels["tier"] != "gold" {
+		t.Errorf("labels scrubbed to %v", labels)
+	}
+}
+
+func TestNilScrubber(t *testing.T) {
+	s, err := New(Policy{})
+	if err != nil || s != nil {
+		t.Fatalf("New of an empty policy: %v, %v", s, err)
+	}
+
+	if got := s.String("10.1.2.3"); got != "10.1.2.3" {
+		t.Errorf("nil scrubber changed text to %q", got)
+	}
+	metadata := map[string]interface{}{"customer": "Acme"}
+	if got := s.Metadata(metadata); got["customer"] != "Acme" {
+		t.Errorf("nil scrubber changed metadata to %v", got)
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	policy := Policy{MetadataKeys: []string{"customer[", ""}, AddressPatterns: []string{"(unclosed"}}
+	err := policy.Validate()
+	if err == nil {
+		t.Fatal("invalid policy accepted")
+	}
+	for _, want := range []string{"customer[", "(unclosed"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package service implements the registry's background health and cleanup processes
+package service
+
+import (
+	"time"
+)
+
+// staleHealthChecks is how many health check intervals may pass without a
+// report before an instance's health is considered unknown
+const staleHealthChecks = 3
+
+// Close stops the registry's background processes and pending drain timers
+// and waits for them to exit. The registry remains usable for lookups.
+func (esr *EnhancedServiceRegistry) Close() {
+	esr.stopOnce.Do(func() {
+		close(esr.stop)
+	})
+	esr.background.Wait()
+
+	esr.mutex.Lock()
+	defer esr.mutex.Unlock()
+
+	for serviceID, timer := range esr.drainTimers {
+		timer.Stop()
+		delete(esr.drainTimers, serviceID)
+	}
+}
+
+// startHealthMonitoring marks instances whose health reports have stopped as
+// HealthUnknown so they rank below instances with fresh reports, checking
+// every interval
+func (esr *EnhancedServiceRegistry) startHealthMonitoring(interval time.Duration) {
+	defer esr.background.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-esr.stop:
+			return
+		case now := <-ticker.C:
+			esr.expireHealth(now)
+		}
+	}
+}
+
+// startCleanupProcess removes instances that have not reported health within
+// StaleServiceTimeout and affinity bindings unused for AffinityKeyTTL,
+// every interval
+func (esr *EnhancedServiceRegistry) startCleanupProcess(interval time.Duration) {
+	defer esr.background.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-esr.stop:
+			return
+		case now := <-ticker.C:
+			esr.removeStaleServices(now)
+			esr.affinities.Expire(now)
+		}
+	}
+}
+
+func (esr *EnhancedServiceRegistry) expireHealth(now time.Time) {
+	esr.mutex.Lock()
+	defer esr.mutex.Unlock()
+
+	for _, serviceID := range esr.healthMonitor.Overdue(now, staleHealthChecks) {
+		service, exists := esr.services[serviceID]
+		if !exists || service.HealthStatus == HealthUnknown {
+			continue
+		}
+		service.HealthStatus = HealthUnknown
+		esr.discoveryCache.InvalidateByServiceType(service.ServiceType)
+	}
+}
+
+func (esr *EnhancedServiceRegistry) removeStaleServices(now time.Time) {
+	esr.mutex.Lock()
+	defer esr.mutex.Unlock()
+
+	for serviceID, service := range esr.services {
+		// Draining instances are removed by their drain timer
+		if service.Lifecycle == LifecycleDraining {
+			continue
+		}
+		if now.Sub(lastSeen(service)) >= esr.config.StaleServiceTimeout {
+			esr.removeServiceLocked(serviceID)
+		}
+	}
+}
+
+// lastSeen returns when an instance last reported health, or registered if
+// it never has
+func lastSeen(service *ServiceInstance) time.Time {
+	if service.LastHealthCheck.After(service.RegisteredAt) {
+		return service.LastHealthCheck
+	}
+	return service.RegisteredAt
+}
+
+// PruneAffinities removes learned service affinities whose decayed weight
+// has fallen below threshold and returns how many were removed
+func (esr *EnhancedServiceRegistry) PruneAffinities(threshold float64) int {
+	return esr.serviceAffinity.PruneWeakAssociations(threshold)
+}
+
+// DiscoveryCacheLen returns the number of cached discovery results
+func (esr *EnhancedServiceRegistry) DiscoveryCacheLen() int {
+	return esr.discoveryCache.Len()
+}
+
+// ResizeDiscoveryCache changes how many discovery results are cached and
+// returns how many were evicted
+func (esr *EnhancedServiceRegistry) ResizeDiscoveryCache(size int) int {
+	return esr.discoveryCache.Resize(size)
+}
+
+// AffinitiesLen returns the number of learned service affinities
+func (esr *EnhancedServiceRegistry) AffinitiesLen() int {
+	return esr.serviceAffinity.Len()
+}
+
+// LimitAffinities bounds the learned service affinities, dropping the
+// weakest, and returns how many were removed
+func (esr *EnhancedServiceRegistry) LimitAffinities(capacity int) int {
+	return esr.serviceAffinity.SetCapacity(capacity)
+}
@@ -0,0 +1,285 @@
+// Package service implements hierarchical capability matching for service discovery
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Capability taxonomy
+//
+// Capabilities are dot-separated hierarchical names such as
+// "storage.object.s3" or "compute.gpu.cuda". A service advertises concrete
+// capabilities, optionally pinned to a version ("compute.gpu.cuda=12.2").
+// Queries match against them with patterns that may contain wildcards and a
+// version constraint:
+//
+//	storage.object.s3      exact capability (or any more specific child)
+//	storage.object         any object storage capability
+//	storage.*.s3           single-segment wildcard
+//	compute.**             any capability below compute
+//	compute.gpu.cuda>=12   version-qualified match
+
+const (
+	capabilitySeparator      = "."
+	capabilityWildcard       = "*"
+	capabilityDeepWildcard   = "**"
+	capabilityVersionPrefix  = "v"
+	capabilityVersionDivider = "."
+)
+
+// VersionOperator defines how a capability version constraint is evaluated
+type VersionOperator int
+
+const (
+	VersionAny VersionOperator = iota
+	VersionEqual
+	VersionNotEqual
+	VersionGreater
+	VersionGreaterEqual
+	VersionLess
+	VersionLessEqual
+)
+
+// versionOperators is ordered so that two-character operators are tried first
+var versionOperators = []struct {
+	token string
+	op    VersionOperator
+}{
+	{">=", VersionGreaterEqual},
+	{"<=", VersionLessEqual},
+	{"!=", VersionNotEqual},
+	{"==", VersionEqual},
+	{">", VersionGreater},
+	{"<", VersionLess},
+	{"=", VersionEqual},
+	{"@", VersionEqual},
+}
+
+// Capability is a parsed capability advertised by a service or requested by a query
+type Capability struct {
+	Segments []string
+	Operator VersionOperator
+	Version  []int
+	Raw      string
+}
+
+// ParseCapability parses a capability expression such as "compute.gpu.cuda>=12"
+func ParseCapability(expr string) (Capability, error) {
+	raw := strings.TrimSpace(expr)
+	if raw == "" {
+		return Capability{}, fmt.Errorf("empty capability")
+	}
+
+	name, operator, versionText := splitCapabilityVersion(raw)
+
+	segments := strings.Split(strings.ToLower(name), capabilitySeparator)
+	for i, segment := range segments {
+		segment = strings.TrimSpace(segment)
+		if segment == "" {
+			return Capability{}, fmt.Errorf("capability %q has an empty segment", raw)
+		}
+		if segment == capabilityDeepWildcard && i != len(segments)-1 {
+			return Capability{}, fmt.Errorf("capability %q: %q is only allowed as the last segment", raw, capabilityDeepWildcard)
+		}
+		segments[i] = segment
+	}
+
+	capability := Capability{
+		Segments: segments,
+		Operator: operator,
+		Raw:      raw,
+	}
+
+	if operator != VersionAny {
+		version, err := parseCapabilityVersion(versionText)
+		if err != nil {
+			return Capability{}, fmt.Errorf("capability %q: %w", raw, err)
+		}
+		capability.Version = version
+	}
+
+	return capability, nil
+}
+
+// IsWildcard reports whether the capability contains wildcard segments
+func (c Capability) IsWildcard() bool {
+	for _, segment := range c.Segments {
+		if segment == capabilityWildcard || segment == capabilityDeepWildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// Name returns the capability path without any version constraint
+func (c Capability) Name() string {
+	return strings.Join(c.Segments, capabilitySeparator)
+}
+
+// Matches reports whether the advertised capability satisfies the pattern.
+//
+// A pattern matches an advertised capability when every pattern segment
+// matches the corresponding advertised segment; advertised capabilities that
+// are more specific than the pattern still match ("storage.object" matches
+// "storage.object.s3"). If the pattern carries a version constraint the
+// advertised capability must declare a version that satisfies it.
+func (c Capability) Matches(advertised Capability) bool {
+	if !matchCapabilitySegments(c.Segments, advertised.Segments) {
+		return false
+	}
+
+	if c.Operator == VersionAny {
+		return true
+	}
+
+	if len(advertised.Version) == 0 {
+		return false
+	}
+
+	return compareCapabilityVersion(c.Operator, advertised.Version, c.Version)
+}
+
+// MatchCapability reports whether any of the advertised capabilities satisfies the pattern
+func MatchCapability(pattern string, advertised []string) (bool, error) {
+	parsedPattern, err := ParseCapability(pattern)
+	if err != nil {
+		return false, err
+	}
+
+	for _, capability := range advertised {
+		parsed, err := ParseCapability(capability)
+		if err != nil {
+			continue // Ignore malformed advertisements rather than failing the query
+		}
+		if parsed.IsWildcard() {
+			continue // Services must advertise concrete capabilities
+		}
+		if parsedPattern.Matches(parsed) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ValidateCapabilities checks that advertised capabilities are concrete and well formed
+func ValidateCapabilities(capabilities []string) error {
+	for _, capability := range capabilities {
+		parsed, err := ParseCapability(capability)
+		if err != nil {
+			return err
+		}
+		if parsed.IsWildcard() {
+			return fmt.Errorf("capability %q: wildcards are only allowed in queries", capability)
+		}
+		if parsed.Operator != VersionAny && parsed.Operator != VersionEqual {
+			return fmt.Errorf("capability %q: advertised versions must be exact", capability)
+		}
+	}
+	return nil
+}
+
+// hasCapability checks whether a service advertises a capability matching the query pattern
+func (esr *EnhancedServiceRegistry) hasCapability(service *ServiceInstance, capability string) bool {
+	matched, err := MatchCapability(capability, service.Capabilities)
+	if err != nil {
+		return false
+	}
+	return matched
+}
+
+// splitCapabilityVersion separates the capability name from its version constraint
+func splitCapabilityVersion(raw string) (string, VersionOperator, string) {
+	index := strings.IndexAny(raw, "<>=!@")
+	if index < 0 {
+		return raw, VersionAny, ""
+	}
+
+	name := strings.TrimSpace(raw[:index])
+	rest := raw[index:]
+
+	for _, candidate := range versionOperators {
+		if strings.HasPrefix(rest, candidate.token) {
+			return name, candidate.op, strings.TrimSpace(rest[len(candidate.token):])
+		}
+	}
+
+	return raw, VersionAny, ""
+}
+
+// parseCapabilityVersion parses dotted numeric versions such as "12", "v12.2" or "1.0.3"
+func parseCapabilityVersion(text string) ([]int, error) {
+	text = strings.TrimPrefix(strings.TrimSpace(text), capabilityVersionPrefix)
+	if text == "" {
+		return nil, fmt.Errorf("missing version")
+	}
+
+	parts := strings.Split(text, capabilityVersionDivider)
+	version := make([]int, len(parts))
+	for i, part := range parts {
+		value, err := strconv.Atoi(part)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid version component %q", part)
+		}
+		version[i] = value
+	}
+
+	return version, nil
+}
+
+// matchCapabilitySegments matches pattern segments against advertised segments
+func matchCapabilitySegments(pattern, advertised []string) bool {
+	for i, segment := range pattern {
+		if segment == capabilityDeepWildcard {
+			return true
+		}
+		if i >= len(advertised) {
+			return false
+		}
+		if segment != capabilityWildcard && segment != advertised[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// compareCapabilityVersion evaluates advertised <op> required.
+//
+// Only the components present in the required version are compared, so
+// "cuda=12" matches 12.0 and 12.4 while "cuda>12" requires 13 or later.
+func compareCapabilityVersion(operator VersionOperator, advertised, required []int) bool {
+	cmp := 0
+	for i, want := range required {
+		have := 0
+		if i < len(advertised) {
+			have = advertised[i]
+		}
+		if have != want {
+			if have < want {
+				cmp = -1
+			} else {
+				cmp = 1
+			}
+			break
+		}
+	}
+
+	switch operator {
+	case VersionEqual:
+		return cmp == 0
+	case VersionNotEqual:
+		return cmp != 0
+	case VersionGreater:
+		return cmp > 0
+	case VersionGreaterEqual:
+		return cmp >= 0
+	case VersionLess:
+		return cmp < 0
+	case VersionLessEqual:
+		return cmp <= 0
+	default:
+		return true
+	}
+}
@@ -0,0 +1,57 @@
+package service
+
+import "testing"
+
+func TestMatchCapability(t *testing.T) {
+	advertised := []string{"storage.object.s3", "compute.gpu.cuda=12.2", "network.ingress"}
+
+	cases := []struct {
+		pattern string
+		want    bool
+	}{
+		{"storage.object.s3", true},
+		{"storage.object", true},
+		{"storage.*.s3", true},
+		{"storage.*.gcs", false},
+		{"compute.**", true},
+		{"**", true},
+		{"compute.gpu.cuda>=12", true},
+		{"compute.gpu.cuda>=12.3", false},
+		{"compute.gpu.cuda=12", true},
+		{"compute.gpu.cuda<12", false},
+		{"compute.gpu.cuda!=11", true},
+		{"network.ingress>=1", false}, // unversioned advertisement cannot satisfy a version constraint
+		{"Storage.Object.S3", true},
+		{"storage.object.s3.multipart", false},
+	}
+
+	for _, tc := range cases {
+		got, err := MatchCapability(tc.pattern, advertised)
+		if err != nil {
+			t.Fatalf("MatchCapability(%q) returned error: %v", tc.pattern, err)
+		}
+		if got != tc.want {
+			t.Errorf("MatchCapability(%q) = %v, want %v", tc.pattern, got, tc.want)
+		}
+	}
+}
+
+func TestParseCapabilityErrors(t *testing.T) {
+	for _, expr := range []string{"", "storage..s3", "compute.**.gpu", "compute.gpu.cuda>=", "compute.gpu.cuda>=x"} {
+		if _, err := ParseCapability(expr); err == nil {
+			t.Errorf("ParseCapability(%q) expected error", expr)
+		}
+	}
+}
+
+func TestValidateCapabilities(t *testing.T) {
+	if err := ValidateCapabilities([]string{"storage.object.s3", "compute.gpu.cuda=12.2"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ValidateCapabilities([]string{"storage.*"}); err == nil {
+		t.Fatal("expected wildcard advertisement to be rejected")
+	}
+	if err := ValidateCapabilities([]string{"compute.gpu.cuda>=12"}); err == nil {
+		t.Fatal("expected ranged advertisement to be rejected")
+	}
+}
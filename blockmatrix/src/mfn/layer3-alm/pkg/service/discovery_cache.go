@@ -0,0 +1,150 @@
+// Package service implements caching of ranked discovery results
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// DiscoveryCache holds ranked discovery results by query so repeated queries
+// skip ranking until the result expires or its service type changes
+type DiscoveryCache struct {
+	entries *lru.Cache
+	ttl     time.Duration
+	mutex   sync.Mutex
+}
+
+// discoveryCacheEntry is a cached result and the service type it covers
+type discoveryCacheEntry struct {
+	result      *DiscoveryResult
+	serviceType string
+	expiresAt   time.Time
+}
+
+// NewDiscoveryCache creates a cache of up to size results, each kept for at
+// most ttl
+func NewDiscoveryCache(size int, ttl time.Duration) *DiscoveryCache {
+	if size <= 0 {
+		size = 1
+	}
+	entries, _ := lru.New(size)
+	return &DiscoveryCache{
+		entries: entries,
+		ttl:     ttl,
+	}
+}
+
+// Get returns the cached result for key, or nil if there is none or it has
+// expired
+func (dc *DiscoveryCache) Get(key string) *DiscoveryResult {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+
+	value, exists := dc.entries.Get(key)
+	if !exists {
+		return nil
+	}
+	entry := value.(*discoveryCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		dc.entries.Remove(key)
+		return nil
+	}
+	return entry.result
+}
+
+// Put caches result under key until the cache TTL or the result's own
+// expiry, whichever is sooner
+func (dc *DiscoveryCache) Put(key string, result *DiscoveryResult) {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+
+	expiresAt := time.Now().Add(dc.ttl)
+	if !result.ExpiresAt.IsZero() && result.ExpiresAt.Before(expiresAt) {
+		expiresAt = result.ExpiresAt
+	}
+	dc.entries.Add(key, &discoveryCacheEntry{
+		result:      result,
+		serviceType: cacheKeyServiceType(key),
+		expiresAt:   expiresAt,
+	})
+}
+
+// InvalidateByServiceType drops results that may include instances of
+// serviceType: those for the type and those of queries not naming one
+func (dc *DiscoveryCache) InvalidateByServiceType(serviceType string) int {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+
+	removed := 0
+	for _, key := range dc.entries.Keys() {
+		value, exists := dc.entries.Peek(key)
+		if !exists {
+			continue
+		}
+		entry := value.(*discoveryCacheEntry)
+		if entry.serviceType == serviceType || entry.serviceType == "" {
+			dc.entries.Remove(key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Purge drops every cached result
+func (dc *DiscoveryCache) Purge() {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+
+	dc.entries.Purge()
+}
+
+// Len returns the number of cached results
+func (dc *DiscoveryCache) Len() int {
+	return dc.entries.Len()
+}
+
+// Resize changes how many results are kept, evicting the least recently
+// used, and returns how many were evicted
+func (dc *DiscoveryCache) Resize(size int) int {
+	if size <= 0 {
+		size = 1
+	}
+
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+
+	return dc.entries.Resize(size)
+}
+
+// createCacheKey identifies a query by every field that affects its ranked
+// result. The affinity key is left out as it is resolved after caching. The
+// service type leads the key so invalidation can find it.
+func (esr *EnhancedServiceRegistry) createCacheKey(query ServiceQuery) string {
+	regions := append([]string(nil), query.PreferredRegions...)
+	sort.Strings(regions)
+
+	return fmt.Sprintf("%s|%s|src=%d|dist=%g|regions=%s|health=%g|rt=%d|rps=%g|degraded=%t|max=%d|sort=%d",
+		query.ServiceType,
+		querySignature(query),
+		query.SourceNodeID,
+		query.MaxDistance,
+		strings.Join(regions, ","),
+		query.MinHealthScore,
+		query.MaxResponseTime,
+		query.MinThroughput,
+		query.IncludeDegraded,
+		query.MaxResults,
+		query.SortBy,
+	)
+}
+
+// cacheKeyServiceType returns the service type a cache key leads with
+func cacheKeyServiceType(key string) string {
+	serviceType, _, _ := strings.Cut(key, "|")
+	return serviceType
+}
@@ -0,0 +1,422 @@
+// Package service implements discovery metrics and query-pattern analytics
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/associative"
+)
+
+// DiscoveryMetrics records registry activity and query patterns so operators can
+// spot misconfigured clients (queries that never match) and capacity gaps
+// (hot service types with few instances)
+type DiscoveryMetrics struct {
+	// Query statistics
+	totalQueries      int64
+	emptyQueries      int64
+	successfulQueries int64
+	cacheHits         int64
+	cacheMisses       int64
+	totalResults      int64
+
+	// Registration statistics
+	registrations       int64
+	registrationsByType map[string]int64
+
+	// Query latency history for percentile calculations
+	latencies      []time.Duration
+	latencyCursor  int
+	maxHistorySize int
+	totalLatency   time.Duration
+
+	// Query pattern analytics
+	queriesByType  map[string]int64
+	emptiesByQuery map[string]*EmptyQueryStats
+
+	startedAt time.Time
+
+	// Thread safety
+	mutex sync.RWMutex
+}
+
+// EmptyQueryStats tracks a query signature that returned zero results
+type EmptyQueryStats struct {
+	Signature string
+	Count     int64
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// ServiceTypeCount pairs a service type with its query and registration volume
+type ServiceTypeCount struct {
+	ServiceType   string
+	Queries       int64
+	Registrations int64
+}
+
+// DiscoveryStats is a point-in-time snapshot of discovery metrics
+type DiscoveryStats struct {
+	TotalQueries      int64
+	SuccessfulQueries int64
+	EmptyQueries      int64
+	EmptyRate         float64
+	CacheHits         int64
+	CacheMisses       int64
+	CacheHitRate      float64
+	AverageResults    float64
+	Registrations     int64
+
+	// Latency percentiles over the recent history window
+	AverageLatency time.Duration
+	P50Latency     time.Duration
+	P90Latency     time.Duration
+	P99Latency     time.Duration
+
+	// Query pattern analytics
+	HottestServiceTypes []ServiceTypeCount
+	TopEmptyQueries     []EmptyQueryStats
+
+	Uptime      time.Duration
+	GeneratedAt time.Time
+}
+
+const (
+	defaultDiscoveryHistorySize = 1024
+	defaultDiscoveryTopN        = 10
+	maxTrackedEmptyQueries      = 1000
+)
+
+// NewDiscoveryMetrics creates a new discovery metrics collector
+func NewDiscoveryMetrics() *DiscoveryMetrics {
+	return &DiscoveryMetrics{
+		registrationsByType: make(map[string]int64),
+		latencies:           make([]time.Duration, 0, defaultDiscoveryHistorySize),
+		maxHistorySize:      defaultDiscoveryHistorySize,
+		queriesByType:       make(map[string]int64),
+		emptiesByQuery:      make(map[string]*EmptyQueryStats),
+		startedAt:           time.Now(),
+	}
+}
+
+// RecordRegistration records a service registration
+func (dm *DiscoveryMetrics) RecordRegistration(service *ServiceInstance) {
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+
+	dm.registrations++
+	dm.registrationsByType[serviceTypeKey(service.ServiceType)]++
+}
+
+// RecordCacheHit records a discovery cache hit
+func (dm *DiscoveryMetrics) RecordCacheHit() {
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+
+	dm.cacheHits++
+}
+
+// RecordCacheMiss records a discovery cache miss
+func (dm *DiscoveryMetrics) RecordCacheMiss() {
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+
+	dm.cacheMisses++
+}
+
+// RecordQuery records a completed discovery query, whether served from cache or not
+func (dm *DiscoveryMetrics) RecordQuery(query ServiceQuery, latency time.Duration, resultCount int) {
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+
+	dm.totalQueries++
+	dm.totalResults += int64(resultCount)
+	dm.totalLatency += latency
+	dm.queriesByType[serviceTypeKey(query.ServiceType)]++
+	dm.addToHistory(latency)
+
+	if resultCount == 0 {
+		dm.recordEmpty(query)
+	}
+}
+
+// RecordSuccessfulDiscovery records a discovery that produced ranked results
+func (dm *DiscoveryMetrics) RecordSuccessfulDiscovery(result *DiscoveryResult) {
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+
+	if result != nil && len(result.Services) > 0 {
+		dm.successfulQueries++
+	}
+}
+
+// GetStats returns a snapshot of the current discovery metrics
+func (dm *DiscoveryMetrics) GetStats() DiscoveryStats {
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
+
+	p50, p90, p99 := dm.calculatePercentiles()
+
+	stats := DiscoveryStats{
+		TotalQueries:        dm.totalQueries,
+		SuccessfulQueries:   dm.successfulQueries,
+		EmptyQueries:        dm.emptyQueries,
+		CacheHits:           dm.cacheHits,
+		CacheMisses:         dm.cacheMisses,
+		Registrations:       dm.registrations,
+		P50Latency:          p50,
+		P90Latency:          p90,
+		P99Latency:          p99,
+		HottestServiceTypes: dm.hottestServiceTypes(defaultDiscoveryTopN),
+		TopEmptyQueries:     dm.topEmptyQueries(defaultDiscoveryTopN),
+		Uptime:              time.Since(dm.startedAt),
+		GeneratedAt:         time.Now(),
+	}
+
+	if dm.totalQueries > 0 {
+		stats.EmptyRate = float64(dm.emptyQueries) / float64(dm.totalQueries) * 100.0
+		stats.AverageResults = float64(dm.totalResults) / float64(dm.totalQueries)
+		stats.AverageLatency = dm.totalLatency / time.Duration(dm.totalQueries)
+	}
+
+	if lookups := dm.cacheHits + dm.cacheMisses; lookups > 0 {
+		stats.CacheHitRate = float64(dm.cacheHits) / float64(lookups) * 100.0
+	}
+
+	return stats
+}
+
+// HottestServiceTypes returns the most frequently queried service types
+func (dm *DiscoveryMetrics) HottestServiceTypes(limit int) []ServiceTypeCount {
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
+
+	return dm.hottestServiceTypes(limit)
+}
+
+// EmptyQueries returns the query signatures that most often returned no results
+func (dm *DiscoveryMetrics) EmptyQueries(limit int) []EmptyQueryStats {
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
+
+	return dm.topEmptyQueries(limit)
+}
+
+// Reset clears all collected metrics
+func (dm *DiscoveryMetrics) Reset() {
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+
+	dm.totalQueries = 0
+	dm.emptyQueries = 0
+	dm.successfulQueries = 0
+	dm.cacheHits = 0
+	dm.cacheMisses = 0
+	dm.totalResults = 0
+	dm.registrations = 0
+	dm.registrationsByType = make(map[string]int64)
+	dm.latencies = dm.latencies[:0]
+	dm.latencyCursor = 0
+	dm.totalLatency = 0
+	dm.queriesByType = make(map[string]int64)
+	dm.emptiesByQuery = make(map[string]*EmptyQueryStats)
+	dm.startedAt = time.Now()
+}
+
+// GetDiscoveryStats returns discovery metrics and query-pattern analytics for the registry
+func (esr *EnhancedServiceRegistry) GetDiscoveryStats() DiscoveryStats {
+	return esr.metrics.GetStats()
+}
+
+// GetRegistryStats returns registry inventory together with discovery metrics
+func (esr *EnhancedServiceRegistry) GetRegistryStats() RegistryStats {
+	esr.mutex.RLock()
+	stats := RegistryStats{
+		TotalServices:  len(esr.services),
+		TotalNodes:     len(esr.servicesByNode),
+		ServicesByType: make(map[string]int),
+		HealthCounts:   make(map[HealthStatus]int),
+	}
+	for _, service := range esr.services {
+		stats.ServicesByType[serviceTypeKey(service.ServiceType)]++
+		stats.HealthCounts[service.HealthStatus]++
+	}
+	esr.mutex.RUnlock()
+
+	stats.Discovery = esr.metrics.GetStats()
+
+	return stats
+}
+
+// GetAffinityStats returns statistics of the learned service affinity matrix
+func (esr *EnhancedServiceRegistry) GetAffinityStats() associative.AssociationMatrixStats {
+	return esr.serviceAffinity.GetMatrixStats()
+}
+
+// GetNodeAffinities returns up to limit of the strongest learned associations from a node
+func (esr *EnhancedServiceRegistry) GetNodeAffinities(nodeID int64, limit int) []associative.Association {
+	return esr.serviceAffinity.GetStrongestAssociations(nodeID, limit)
+}
+
+// RegistryStats provides registry inventory and discovery statistics
+type RegistryStats struct {
+	TotalServices  int
+	TotalNodes     int
+	ServicesByType map[string]int
+	HealthCounts   map[HealthStatus]int
+	Discovery      DiscoveryStats
+}
+
+// Helper methods
+
+func (dm *DiscoveryMetrics) addToHistory(latency time.Duration) {
+	if len(dm.latencies) < dm.maxHistorySize {
+		dm.latencies = append(dm.latencies, latency)
+		return
+	}
+
+	// Overwrite the oldest entry once the window is full
+	dm.latencies[dm.latencyCursor] = latency
+	dm.latencyCursor = (dm.latencyCursor + 1) % dm.maxHistorySize
+}
+
+func (dm *DiscoveryMetrics) calculatePercentiles() (p50, p90, p99 time.Duration) {
+	if len(dm.latencies) == 0 {
+		return 0, 0, 0
+	}
+
+	sorted := make([]time.Duration, len(dm.latencies))
+	copy(sorted, dm.latencies)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	n := len(sorted)
+	return sorted[percentileIndex(n, 0.50)], sorted[percentileIndex(n, 0.90)], sorted[percentileIndex(n, 0.99)]
+}
+
+func (dm *DiscoveryMetrics) recordEmpty(query ServiceQuery) {
+	dm.emptyQueries++
+
+	signature := querySignature(query)
+	now := time.Now()
+
+	if stats, exists := dm.emptiesByQuery[signature]; exists {
+		stats.Count++
+		stats.LastSeen = now
+		return
+	}
+
+	// Bound memory by evicting the least recently seen signature
+	if len(dm.emptiesByQuery) >= maxTrackedEmptyQueries {
+		var oldestKey string
+		var oldest time.Time
+		for key, stats := range dm.emptiesByQuery {
+			if oldestKey == "" || stats.LastSeen.Before(oldest) {
+				oldestKey = key
+				oldest = stats.LastSeen
+			}
+		}
+		delete(dm.emptiesByQuery, oldestKey)
+	}
+
+	dm.emptiesByQuery[signature] = &EmptyQueryStats{
+		Signature: signature,
+		Count:     1,
+		FirstSeen: now,
+		LastSeen:  now,
+	}
+}
+
+func (dm *DiscoveryMetrics) hottestServiceTypes(limit int) []ServiceTypeCount {
+	counts := make([]ServiceTypeCount, 0, len(dm.queriesByType))
+	for serviceType, queries := range dm.queriesByType {
+		counts = append(counts, ServiceTypeCount{
+			ServiceType:   serviceType,
+			Queries:       queries,
+			Registrations: dm.registrationsByType[serviceType],
+		})
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Queries == counts[j].Queries {
+			return counts[i].ServiceType < counts[j].ServiceType
+		}
+		return counts[i].Queries > counts[j].Queries
+	})
+
+	if limit > 0 && len(counts) > limit {
+		counts = counts[:limit]
+	}
+
+	return counts
+}
+
+func (dm *DiscoveryMetrics) topEmptyQueries(limit int) []EmptyQueryStats {
+	empties := make([]EmptyQueryStats, 0, len(dm.emptiesByQuery))
+	for _, stats := range dm.emptiesByQuery {
+		empties = append(empties, *stats)
+	}
+
+	sort.Slice(empties, func(i, j int) bool {
+		if empties[i].Count == empties[j].Count {
+			return empties[i].Signature < empties[j].Signature
+		}
+		return empties[i].Count > empties[j].Count
+	})
+
+	if limit > 0 && len(empties) > limit {
+		empties = empties[:limit]
+	}
+
+	return empties
+}
+
+// querySignature summarises the selective parts of a query for empty-result tracking
+func querySignature(query ServiceQuery) string {
+	parts := make([]string, 0, 4)
+	if query.ServiceName != "" {
+		parts = append(parts, "name="+query.ServiceName)
+	}
+	if query.ServiceType != "" {
+		parts = append(parts, "type="+query.ServiceType)
+	}
+	if query.Version != "" {
+		parts = append(parts, "version="+query.Version)
+	}
+	if len(query.Capabilities) > 0 {
+		capabilities := append([]string(nil), query.Capabilities...)
+		sort.Strings(capabilities)
+		parts = append(parts, "capabilities="+strings.Join(capabilities, ","))
+	}
+	if len(query.RequiredTags) > 0 {
+		tags := make([]string, 0, len(query.RequiredTags))
+		for key, value := range query.RequiredTags {
+			tags = append(tags, fmt.Sprintf("%s:%s", key, value))
+		}
+		sort.Strings(tags)
+		parts = append(parts, "tags="+strings.Join(tags, ","))
+	}
+	if len(parts) == 0 {
+		return "*"
+	}
+	return strings.Join(parts, " ")
+}
+
+func serviceTypeKey(serviceType string) string {
+	if serviceType == "" {
+		return "unspecified"
+	}
+	return serviceType
+}
+
+func percentileIndex(n int, percentile float64) int {
+	index := int(float64(n) * percentile)
+	if index >= n {
+		index = n - 1
+	}
+	return index
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDiscoveryMetricsStats(t *testing.T) {
+	dm := NewDiscoveryMetrics()
+
+	// Latencies of 1..100ms, every fourth query empty
+	for i := 1; i <= 100; i++ {
+		results := 2
+		if i%4 == 0 {
+			results = 0
+		}
+		dm.RecordQuery(ServiceQuery{ServiceType: "http"}, time.Duration(i)*time.Millisecond, results)
+	}
+	dm.RecordCacheHit()
+	dm.RecordCacheMiss()
+	dm.RecordCacheMiss()
+	dm.RecordCacheMiss()
+
+	stats := dm.GetStats()
+	if stats.TotalQueries != 100 || stats.EmptyQueries != 25 || stats.EmptyRate != 25 {
+		t.Errorf("%d queries, %d empty (%g%%), want 100 and 25 (25%%)", stats.TotalQueries, stats.EmptyQueries, stats.EmptyRate)
+	}
+	if stats.AverageResults != 1.5 || stats.CacheHitRate != 25 {
+		t.Errorf("average results %g and cache hit rate %g%%, want 1.5 and 25%%", stats.AverageResults, stats.CacheHitRate)
+	}
+	if stats.AverageLatency != 50500*time.Microsecond {
+		t.Errorf("average latency %v, want 50.5ms", stats.AverageLatency)
+	}
+	if stats.P50Latency != 51*time.Millisecond || stats.P90Latency != 91*time.Millisecond || stats.P99Latency != 100*time.Millisecond {
+		t.Errorf("percentiles %v/%v/%v, want 51ms/91ms/100ms", stats.P50Latency, stats.P90Latency, stats.P99Latency)
+	}
+
+	dm.Reset()
+	if stats := dm.GetStats(); stats.TotalQueries != 0 || stats.P99Latency != 0 || len(stats.TopEmptyQueries) != 0 {
+		t.Errorf("stats %+v after Reset", stats)
+	}
+}
+
+func TestDiscoveryMetricsHistoryWindow(t *testing.T) {
+	dm := NewDiscoveryMetrics()
+	dm.maxHistorySize = 3
+
+	// The slow first query falls out of the window; the average still counts it
+	for _, latency := range []time.Duration{time.Second, time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond} {
+		dm.RecordQuery(ServiceQuery{}, latency, 1)
+	}
+
+	stats := dm.GetStats()
+	if stats.P99Latency != 3*time.Millisecond {
+		t.Errorf("p99 %v, want 3ms once the 1s query left the window", stats.P99Latency)
+	}
+	if stats.AverageLatency != 1006*time.Millisecond/4 {
+		t.Errorf("average latency %v over all queries, want 251.5ms", stats.AverageLatency)
+	}
+}
+
+func TestDiscoveryMetricsQueryPatterns(t *testing.T) {
+	dm := NewDiscoveryMetrics()
+	dm.RecordRegistration(&ServiceInstance{ServiceType: "http"})
+	dm.RecordRegistration(&ServiceInstance{})
+
+	for i := 0; i < 3; i++ {
+		dm.RecordQuery(ServiceQuery{ServiceType: "grpc", ServiceName: "billing"}, time.Millisecond, 0)
+	}
+	dm.RecordQuery(ServiceQuery{ServiceType: "http"}, time.Millisecond, 1)
+	dm.RecordQuery(ServiceQuery{ServiceType: "http", Version: "v2"}, time.Millisecond, 0)
+	dm.RecordQuery(ServiceQuery{}, time.Millisecond, 1)
+
+	hottest := dm.HottestServiceTypes(2)
+	want := []ServiceTypeCount{{ServiceType: "grpc", Queries: 3}, {ServiceType: "http", Queries: 2, Registrations: 1}}
+	if len(hottest) != len(want) || hottest[0] != want[0] || hottest[1] != want[1] {
+		t.Errorf("HottestServiceTypes(2) = %+v, want %+v", hottest, want)
+	}
+	if all := dm.HottestServiceTypes(0); len(all) != 3 || all[2] != (ServiceTypeCount{ServiceType: "unspecified", Queries: 1, Registrations: 1}) {
+		t.Errorf("HottestServiceTypes(0) = %+v, want untyped queries counted as unspecified", all)
+	}
+
+	empties := dm.EmptyQueries(0)
+	if len(empties) != 2 || empties[0].Signature != "name=billing type=grpc" || empties[0].Count != 3 {
+		t.Fatalf("EmptyQueries = %+v, want billing first with 3", empties)
+	}
+	if empties[1].Signature != "type=http version=v2" || empties[0].FirstSeen.After(empties[0].LastSeen) {
+		t.Errorf("EmptyQueries = %+v", empties)
+	}
+}
+
+func TestDiscoveryMetricsEvictsStaleEmptyQueries(t *testing.T) {
+	dm := NewDiscoveryMetrics()
+	for i := 0; i < maxTrackedEmptyQueries; i++ {
+		dm.RecordQuery(ServiceQuery{ServiceName: fmt.Sprintf("svc-%d", i)}, time.Millisecond, 0)
+	}
+	dm.emptiesByQuery["name=svc-7"].LastSeen = time.Now().Add(-time.Hour)
+
+	dm.RecordQuery(ServiceQuery{ServiceName: "new"}, time.Millisecond, 0)
+
+	if len(dm.emptiesByQuery) != maxTrackedEmptyQueries {
+		t.Errorf("%d signatures tracked, want the bound of %d", len(dm.emptiesByQuery), maxTrackedEmptyQueries)
+	}
+	if _, exists := dm.emptiesByQuery["name=svc-7"]; exists {
+		t.Error("least recently seen signature kept")
+	}
+	if _, exists := dm.emptiesByQuery["name=new"]; !exists {
+		t.Error("new signature not tracked")
+	}
+}
+
+func TestQuerySignature(t *testing.T) {
+	cases := []struct {
+		query ServiceQuery
+		want  string
+	}{
+		{ServiceQuery{}, "*"},
+		{ServiceQuery{MaxResults: 5, SortBy: SortByLoad}, "*"},
+		{ServiceQuery{ServiceName: "api", Version: "v1"}, "name=api version=v1"},
+		{ServiceQuery{Capabilities: []string{"tls", "grpc"}}, "capabilities=grpc,tls"},
+		{ServiceQuery{RequiredTags: map[string]string{"zone": "b", "env": "prod"}}, "tags=env:prod,zone:b"},
+	}
+	for _, tc := range cases {
+		if got := querySignature(tc.query); got != tc.want {
+			t.Errorf("querySignature(%+v) = %q, want %q", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestRegistryRecordsDiscoveryStats(t *testing.T) {
+	registry := newTestRegistry(t, 2)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := registry.DiscoverServices(ServiceQuery{Context: ctx, ServiceName: "api"}); err != nil {
+			t.Fatalf("DiscoverServices(api): %v", err)
+		}
+	}
+	if _, err := registry.DiscoverServices(ServiceQuery{Context: ctx, ServiceName: "missing"}); err != nil {
+		t.Fatalf("DiscoverServices(missing): %v", err)
+	}
+
+	// The repeated query is a cache hit and still counts as successful
+	stats := registry.GetDiscoveryStats()
+	if stats.TotalQueries != 3 || stats.SuccessfulQueries != 2 || stats.EmptyQueries != 1 {
+		t.Errorf("%d queries, %d successful, %d empty; want 3, 2 and 1", stats.TotalQueries, stats.SuccessfulQueries, stats.EmptyQueries)
+	}
+	if stats.CacheHits != 1 || stats.Registrations != 2 {
+		t.Errorf("%d cache hits and %d registrations, want 1 and 2", stats.CacheHits, stats.Registrations)
+	}
+
+	registryStats := registry.GetRegistryStats()
+	if registryStats.TotalServices != 2 || registryStats.ServicesByType["http"] != 2 || registryStats.Discovery.TotalQueries != 3 {
+		t.Errorf("registry stats %+v", registryStats)
+	}
+}
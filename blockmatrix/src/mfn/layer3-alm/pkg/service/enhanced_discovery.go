@@ -4,15 +4,27 @@ package service
 import (
 	"context"
 	"fmt"
+	"math"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
 	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/associative"
 	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/routing"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/logging"
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
 )
 
+// tracer traces service discovery queries
+var tracer = tracing.NewTracer("service")
+
+// logger logs discovery failures and health transitions
+var logger = logging.Named("service")
+
 // EnhancedServiceRegistry implements intelligent service discovery
 type EnhancedServiceRegistry struct {
 	// Core service storage
@@ -22,8 +34,10 @@ type EnhancedServiceRegistry struct {
 	// Graph integration
 	networkGraph *graph.NetworkGraph
 	
-	// Associative learning for service affinity
+	// Associative learning for service affinity, paused while
+	// learningSuspended is set
 	serviceAffinity *associative.AssociationMatrix
+	learningSuspended atomic.Bool
 	
 	// Routing integration
 	routingTable *routing.RoutingTable
@@ -38,6 +52,18 @@ type EnhancedServiceRegistry struct {
 	// Metrics
 	metrics *DiscoveryMetrics
 	
+	// Membership churn for result freshness hints
+	churn *churnTracker
+	
+	// Lifecycle management
+	affinities  *affinityTable
+	drainTimers map[string]*time.Timer
+	
+	// Background process shutdown
+	stop       chan struct{}
+	stopOnce   sync.Once
+	background sync.WaitGroup
+	
 	// Thread safety
 	mutex sync.RWMutex
 }
@@ -65,6 +91,7 @@ type ServiceInstance struct {
 	ResponseTime   time.Duration
 	ThroughputRPS  float64
 	ErrorRate      float64
+	HealthVolatility float64 // EMA of absolute health score changes
 	
 	// Discovery metadata
 	RegisteredAt   time.Time
@@ -75,6 +102,10 @@ type ServiceInstance struct {
 	// Associative data
 	AffinityScore  float64
 	RelatedServices []string
+	
+	// Lifecycle
+	Lifecycle      LifecycleState
+	DrainDeadline  time.Time
 }
 
 // HealthStatus represents service health state
@@ -111,6 +142,10 @@ type ServiceQuery struct {
 	MaxResults       int
 	SortBy          SortCriteria
 	
+	// Sticky routing: queries sharing an affinity key keep resolving to the
+	// same instance, even while it drains
+	AffinityKey     string
+	
 	Context         context.Context
 }
 
@@ -135,6 +170,11 @@ type DiscoveryResult struct {
 	AverageHealth    float64
 	AverageLatency   time.Duration
 	GeographicSpread float64
+	
+	// Freshness hints for client-side caches
+	TTL             time.Duration
+	ExpiresAt       time.Time
+	FreshnessReason string
 }
 
 // RankedService represents a discovered service with ranking information
@@ -177,6 +217,15 @@ type RegistryConfig struct {
 	// Cleanup
 	StaleServiceTimeout    time.Duration
 	CleanupInterval        time.Duration
+	
+	// Result freshness hints
+	MinResultTTL           time.Duration
+	MaxResultTTL           time.Duration
+	
+	// Affinity key bindings expire after AffinityKeyTTL without use; the
+	// least recently used binding is evicted beyond MaxAffinityKeys
+	AffinityKeyTTL         time.Duration
+	MaxAffinityKeys        int
 }
 
 // NewEnhancedServiceRegistry creates a new enhanced service registry
@@ -199,11 +248,17 @@ func NewEnhancedServiceRegistry(
 		healthMonitor:   NewHealthMonitor(config.HealthCheckInterval),
 		config:         config,
 		metrics:        NewDiscoveryMetrics(),
+		churn:          newChurnTracker(churnWindow),
+		affinities:     newAffinityTable(config.MaxAffinityKeys, config.AffinityKeyTTL),
+		drainTimers:    make(map[string]*time.Timer),
+		stop:           make(chan struct{}),
 	}
 	
-	// Start background processes
-	go registry.startHealthMonitoring()
-	go registry.startCleanupProcess()
+	// Start background processes. Their intervals are fixed here because
+	// UpdateConfig replaces config while they run.
+	registry.background.Add(2)
+	go registry.startHealthMonitoring(config.HealthCheckInterval)
+	go registry.startCleanupProcess(config.CleanupInterval)
 	
 	return registry
 }
@@ -223,6 +278,8 @@ func (esr *EnhancedServiceRegistry) RegisterService(service *ServiceInstance) er
 	service.LastHealthCheck = time.Now()
 	service.HealthStatus = HealthHealthy
 	service.HealthScore = 1.0
+	service.Lifecycle = LifecycleActive
+	service.DrainDeadline = time.Time{}
 	
 	// Store service
 	esr.services[service.ID] = service
@@ -243,20 +300,80 @@ func (esr *EnhancedServiceRegistry) RegisterService(service *ServiceInstance) er
 	esr.healthMonitor.AddService(service)
 	
 	esr.metrics.RecordRegistration(service)
+	esr.churn.Record()
 	
 	return nil
 }
 
+// GetService returns a registered service instance by ID
+func (esr *EnhancedServiceRegistry) GetService(serviceID string) (*ServiceInstance, bool) {
+	esr.mutex.RLock()
+	defer esr.mutex.RUnlock()
+	
+	service, exists := esr.services[serviceID]
+	return service, exists
+}
+
+// Config returns a copy of the registry configuration
+func (esr *EnhancedServiceRegistry) Config() RegistryConfig {
+	esr.mutex.RLock()
+	defer esr.mutex.RUnlock()
+	
+	return *esr.config
+}
+
+// UpdateConfig applies a new registry configuration, such as ranking
+// weights and health thresholds, to subsequent discoveries. Cache size and
+// background intervals keep their values until restart.
+func (esr *EnhancedServiceRegistry) UpdateConfig(config RegistryConfig) {
+	esr.mutex.Lock()
+	defer esr.mutex.Unlock()
+	
+	config.CacheSize = esr.config.CacheSize
+	config.HealthCheckInterval = esr.config.HealthCheckInterval
+	config.CleanupInterval = esr.config.CleanupInterval
+	esr.config = &config
+}
+
 // DiscoverServices finds services matching the query criteria
-func (esr *EnhancedServiceRegistry) DiscoverServices(query ServiceQuery) (*DiscoveryResult, error) {
+func (esr *EnhancedServiceRegistry) DiscoverServices(query ServiceQuery) (result *DiscoveryResult, err error) {
 	startTime := time.Now()
 	
+	ctx, span := tracer.Start(query.Context, "service.DiscoverServices",
+		attribute.String("alm.service_name", query.ServiceName),
+		attribute.String("alm.service_type", query.ServiceType),
+		attribute.Int64("alm.source_node_id", query.SourceNodeID),
+	)
+	query.Context = ctx
+	defer func() {
+		if result != nil {
+			span.SetAttributes(
+				attribute.Bool("alm.cache_hit", result.CacheHit),
+				attribute.Int("alm.services_found", result.TotalFound),
+				attribute.Int("alm.services_returned", len(result.Services)),
+			)
+		}
+		if err != nil {
+			logger.Ctx(ctx).Debug("Service discovery failed",
+				zap.String("service_name", query.ServiceName),
+				zap.String("service_type", query.ServiceType),
+				zap.Error(err),
+			)
+		}
+		tracing.End(span, err)
+	}()
+	
 	// Check cache first
 	cacheKey := esr.createCacheKey(query)
-	if cached := esr.discoveryCache.Get(cacheKey); cached != nil {
+	if cached := esr.discoveryCache.Get(cacheKey); cached != nil && time.Now().Before(cached.ExpiresAt) {
 		esr.metrics.RecordCacheHit()
-		cached.CacheHit = true
-		return cached, nil
+		esr.metrics.RecordQuery(query, time.Since(startTime), len(cached.Services))
+		esr.metrics.RecordSuccessfulDiscovery(cached)
+		// The cached result is shared between queries, so hit details go on a copy
+		hit := *cached
+		hit.CacheHit = true
+		hit.TTL = time.Until(cached.ExpiresAt)
+		return esr.resolveAffinity(query, &hit), nil
 	}
 	
 	esr.metrics.RecordCacheMiss()
@@ -265,12 +382,15 @@ func (esr *EnhancedServiceRegistry) DiscoverServices(query ServiceQuery) (*Disco
 	candidates := esr.findCandidateServices(query)
 	
 	if len(candidates) == 0 {
-		return &DiscoveryResult{
+		esr.metrics.RecordQuery(query, time.Since(startTime), 0)
+		empty := &DiscoveryResult{
 			Services:   []*RankedService{},
 			TotalFound: 0,
 			QueryTime:  time.Since(startTime),
 			CacheHit:   false,
-		}, nil
+		}
+		esr.applyFreshness(empty)
+		return esr.resolveAffinity(query, empty), nil
 	}
 	
 	// Rank services using multi-criteria scoring
@@ -283,7 +403,7 @@ func (esr *EnhancedServiceRegistry) DiscoverServices(query ServiceQuery) (*Disco
 	}
 	
 	// Calculate result metrics
-	result := &DiscoveryResult{
+	result = &DiscoveryResult{
 		Services:         rankedServices,
 		TotalFound:       len(candidates),
 		QueryTime:        time.Since(startTime),
@@ -293,15 +413,20 @@ func (esr *EnhancedServiceRegistry) DiscoverServices(query ServiceQuery) (*Disco
 		GeographicSpread: esr.calculateGeographicSpread(rankedServices),
 	}
 	
-	// Cache the result
-	esr.discoveryCache.Put(cacheKey, result)
+	// Attach TTL hint so clients know how long the ranking stays trustworthy
+	esr.applyFreshness(result)
+	
+	// Cache a copy so callers cannot alter what later queries are served
+	cached := *result
+	esr.discoveryCache.Put(cacheKey, &cached)
 	
 	// Update affinity learning based on query patterns
 	esr.updateAffinityLearning(query, rankedServices)
 	
+	esr.metrics.RecordQuery(query, result.QueryTime, len(rankedServices))
 	esr.metrics.RecordSuccessfulDiscovery(result)
 	
-	return result, nil
+	return esr.resolveAffinity(query, result), nil
 }
 
 // findCandidateServices finds all services that match basic query criteria
@@ -320,8 +445,18 @@ func (esr *EnhancedServiceRegistry) findCandidateServices(query ServiceQuery) []
 	return candidates
 }
 
-// matchesBasicCriteria checks if a service matches basic query criteria
+// matchesBasicCriteria checks if a service is eligible for new discovery results
 func (esr *EnhancedServiceRegistry) matchesBasicCriteria(service *ServiceInstance, query ServiceQuery) bool {
+	// Draining and removed instances only resolve through affinity bindings
+	if service.Lifecycle != LifecycleActive {
+		return false
+	}
+	
+	return esr.matchesQueryCriteria(service, query)
+}
+
+// matchesQueryCriteria checks if a service matches the query filters regardless of lifecycle
+func (esr *EnhancedServiceRegistry) matchesQueryCriteria(service *ServiceInstance, query ServiceQuery) bool {
 	// Service name match
 	if query.ServiceName != "" && service.Name != query.ServiceName {
 		return false
@@ -520,13 +655,16 @@ func (esr *EnhancedServiceRegistry) UpdateServiceHealth(serviceID string, health
 	}
 	
 	// Update health metrics
+	updateHealthVolatility(service, health.Score)
 	service.HealthScore = health.Score
 	service.ResponseTime = health.ResponseTime
 	service.ThroughputRPS = health.ThroughputRPS
 	service.ErrorRate = health.ErrorRate
 	service.LastHealthCheck = time.Now()
+	esr.healthMonitor.RecordReport(serviceID, service.LastHealthCheck)
 	
 	// Update health status based on thresholds
+	previousStatus := service.HealthStatus
 	if health.Score >= esr.config.DegradedThreshold {
 		service.HealthStatus = HealthHealthy
 	} else if health.Score >= esr.config.UnhealthyThreshold {
@@ -535,6 +673,16 @@ func (esr *EnhancedServiceRegistry) UpdateServiceHealth(serviceID string, health
 		service.HealthStatus = HealthUnhealthy
 	}
 	
+	if service.HealthStatus != previousStatus {
+		logger.L().Info("Service health changed",
+			zap.String("service_id", serviceID),
+			zap.String("service_type", service.ServiceType),
+			zap.Int("from", int(previousStatus)),
+			zap.Int("to", int(service.HealthStatus)),
+			zap.Float64("score", health.Score),
+		)
+	}
+	
 	// Invalidate discovery cache for this service type
 	esr.discoveryCache.InvalidateByServiceType(service.ServiceType)
 	
@@ -568,10 +716,9 @@ func DefaultRegistryConfig() *RegistryConfig {
 		PerformanceWeight:    0.2,
 		StaleServiceTimeout:  10 * time.Minute,
 		CleanupInterval:      5 * time.Minute,
+		MinResultTTL:         1 * time.Second,
+		MaxResultTTL:         2 * time.Minute,
+		AffinityKeyTTL:       30 * time.Minute,
+		MaxAffinityKeys:      100000,
 	}
 }
-
-// Add missing import for math
-import (
-	"math"
-)
\ No newline at end of file
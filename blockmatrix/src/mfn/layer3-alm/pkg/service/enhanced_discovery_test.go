@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+func TestCachedResultsAreNotShared(t *testing.T) {
+	registry := newTestRegistry(t, 2)
+	query := ServiceQuery{Context: context.Background(), ServiceName: "api"}
+
+	first, err := registry.DiscoverServices(query)
+	if err != nil {
+		t.Fatalf("DiscoverServices: %v", err)
+	}
+	first.TotalFound = 99
+
+	second, err := registry.DiscoverServices(query)
+	if err != nil {
+		t.Fatalf("DiscoverServices: %v", err)
+	}
+	third, err := registry.DiscoverServices(query)
+	if err != nil {
+		t.Fatalf("DiscoverServices: %v", err)
+	}
+
+	if first.CacheHit {
+		t.Error("cache hit flag leaked into the original result")
+	}
+	if !second.CacheHit || !third.CacheHit {
+		t.Fatal("expected repeated queries to hit the cache")
+	}
+	if second == third {
+		t.Error("cache hits returned the same result value")
+	}
+	if second.TotalFound != 2 {
+		t.Errorf("caller mutation reached the cache: TotalFound = %d", second.TotalFound)
+	}
+}
+
+func TestUpdateConfigWhileBackgroundRuns(t *testing.T) {
+	config := DefaultRegistryConfig()
+	config.HealthCheckInterval = time.Millisecond
+	config.CleanupInterval = time.Millisecond
+	registry := NewEnhancedServiceRegistry(graph.NewNetworkGraph(10), nil, config)
+	t.Cleanup(registry.Close)
+
+	// Both background loops tick while the configuration is replaced
+	for i := 0; i < 20; i++ {
+		next := registry.Config()
+		next.HealthWeight = 0.3 + float64(i)/100
+		next.HealthCheckInterval = time.Hour
+		registry.UpdateConfig(next)
+		time.Sleep(time.Millisecond)
+	}
+
+	updated := registry.Config()
+	if updated.HealthWeight != 0.49 {
+		t.Errorf("health weight %g, want 0.49", updated.HealthWeight)
+	}
+	if updated.HealthCheckInterval != time.Millisecond {
+		t.Errorf("health check interval changed to %v without a restart", updated.HealthCheckInterval)
+	}
+}
@@ -0,0 +1,150 @@
+// Package service implements freshness hints for discovery results
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// Freshness tuning constants
+const (
+	// volatilitySmoothing is the EMA weight given to the latest health delta
+	volatilitySmoothing = 0.2
+
+	// volatilitySensitivity controls how quickly TTL shrinks as health flaps
+	volatilitySensitivity = 10.0
+
+	// churnWindow is the sliding window used to measure registry churn
+	churnWindow = time.Minute
+
+	// churnSensitivity is the churn rate (events per window) that halves the TTL
+	churnSensitivity = 10.0
+
+	// degradedTTLFactor shortens TTL when results include non-healthy services
+	degradedTTLFactor = 0.5
+)
+
+// churnTracker measures how often the registry membership changes
+type churnTracker struct {
+	events []time.Time
+	window time.Duration
+	mutex  sync.Mutex
+}
+
+func newChurnTracker(window time.Duration) *churnTracker {
+	return &churnTracker{
+		events: make([]time.Time, 0, 64),
+		window: window,
+	}
+}
+
+// Record records a membership change
+func (ct *churnTracker) Record() {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+
+	now := time.Now()
+	ct.trim(now)
+	ct.events = append(ct.events, now)
+}
+
+// Rate returns the number of membership changes within the window
+func (ct *churnTracker) Rate() float64 {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+
+	ct.trim(time.Now())
+	return float64(len(ct.events))
+}
+
+func (ct *churnTracker) trim(now time.Time) {
+	cutoff := now.Add(-ct.window)
+	expired := 0
+	for expired < len(ct.events) && ct.events[expired].Before(cutoff) {
+		expired++
+	}
+	if expired > 0 {
+		ct.events = append(ct.events[:0], ct.events[expired:]...)
+	}
+}
+
+// updateHealthVolatility folds the latest health score change into the service's volatility EMA
+func updateHealthVolatility(service *ServiceInstance, newScore float64) {
+	delta := newScore - service.HealthScore
+	if delta < 0 {
+		delta = -delta
+	}
+	service.HealthVolatility = service.HealthVolatility*(1-volatilitySmoothing) + delta*volatilitySmoothing
+}
+
+// calculateResultTTL derives how long a ranked result list remains trustworthy.
+//
+// The TTL starts from the configured maximum and is shortened by health
+// volatility of the returned services, by recent topology changes in the
+// network graph, and by registry churn. The result is clamped to
+// [MinResultTTL, MaxResultTTL].
+func (esr *EnhancedServiceRegistry) calculateResultTTL(services []*RankedService) (time.Duration, string) {
+	maxTTL := esr.config.MaxResultTTL
+	if maxTTL <= 0 {
+		maxTTL = esr.config.CacheTTL
+	}
+	minTTL := esr.config.MinResultTTL
+	if minTTL <= 0 || minTTL > maxTTL {
+		minTTL = maxTTL
+	}
+
+	factor := 1.0
+	reason := "stable"
+
+	// Health volatility of the returned services
+	maxVolatility := 0.0
+	degraded := false
+	for _, ranked := range services {
+		if ranked.Service.HealthVolatility > maxVolatility {
+			maxVolatility = ranked.Service.HealthVolatility
+		}
+		if ranked.Service.HealthStatus != HealthHealthy {
+			degraded = true
+		}
+	}
+	if volatilityFactor := 1.0 / (1.0 + volatilitySensitivity*maxVolatility); volatilityFactor < factor {
+		factor = volatilityFactor
+		reason = "health_volatility"
+	}
+	if degraded && degradedTTLFactor < factor {
+		factor = degradedTTLFactor
+		reason = "degraded_services"
+	}
+
+	// Topology churn: a graph change inside the TTL window means routes may still be settling
+	if esr.networkGraph != nil {
+		sinceUpdate := time.Since(esr.networkGraph.GetTopologyStats().LastUpdate)
+		if sinceUpdate < maxTTL {
+			if topologyFactor := float64(sinceUpdate) / float64(maxTTL); topologyFactor < factor {
+				factor = topologyFactor
+				reason = "topology_churn"
+			}
+		}
+	}
+
+	// Registry churn: frequent registrations make ranked lists go stale faster
+	if churnFactor := 1.0 / (1.0 + esr.churn.Rate()/churnSensitivity); churnFactor < factor {
+		factor = churnFactor
+		reason = "registry_churn"
+	}
+
+	ttl := time.Duration(float64(maxTTL) * factor)
+	if ttl < minTTL {
+		ttl = minTTL
+	}
+
+	return ttl, reason
+}
+
+// applyFreshness stamps a result with its TTL hint
+func (esr *EnhancedServiceRegistry) applyFreshness(result *DiscoveryResult) {
+	ttl, reason := esr.calculateResultTTL(result.Services)
+	result.TTL = ttl
+	result.ExpiresAt = time.Now().Add(ttl)
+	result.FreshnessReason = reason
+}
@@ -0,0 +1,75 @@
+// Package service implements tracking of the instances under health monitoring
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// HealthMonitor tracks which instances are expected to report health and
+// when each last did, so overdue instances can be found without scanning
+// the registry
+type HealthMonitor struct {
+	interval time.Duration
+	reported map[string]time.Time
+	mutex    sync.RWMutex
+}
+
+// NewHealthMonitor creates a monitor expecting a report every interval
+func NewHealthMonitor(interval time.Duration) *HealthMonitor {
+	return &HealthMonitor{
+		interval: interval,
+		reported: make(map[string]time.Time),
+	}
+}
+
+// AddService starts monitoring an instance, counting its last health check
+// or registration as its latest report
+func (hm *HealthMonitor) AddService(service *ServiceInstance) {
+	hm.mutex.Lock()
+	defer hm.mutex.Unlock()
+
+	hm.reported[service.ID] = lastSeen(service)
+}
+
+// RemoveService stops monitoring an instance
+func (hm *HealthMonitor) RemoveService(serviceID string) {
+	hm.mutex.Lock()
+	defer hm.mutex.Unlock()
+
+	delete(hm.reported, serviceID)
+}
+
+// RecordReport notes a health report from a monitored instance
+func (hm *HealthMonitor) RecordReport(serviceID string, at time.Time) {
+	hm.mutex.Lock()
+	defer hm.mutex.Unlock()
+
+	if _, monitored := hm.reported[serviceID]; monitored {
+		hm.reported[serviceID] = at
+	}
+}
+
+// Overdue returns the instances that have missed missedChecks consecutive
+// health check intervals as of now
+func (hm *HealthMonitor) Overdue(now time.Time, missedChecks int) []string {
+	hm.mutex.RLock()
+	defer hm.mutex.RUnlock()
+
+	staleAfter := time.Duration(missedChecks) * hm.interval
+	var overdue []string
+	for serviceID, reported := range hm.reported {
+		if now.Sub(reported) >= staleAfter {
+			overdue = append(overdue, serviceID)
+		}
+	}
+	return overdue
+}
+
+// Len returns the number of monitored instances
+func (hm *HealthMonitor) Len() int {
+	hm.mutex.RLock()
+	defer hm.mutex.RUnlock()
+
+	return len(hm.reported)
+}
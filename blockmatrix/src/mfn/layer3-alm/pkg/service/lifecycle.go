@@ -0,0 +1,360 @@
+// Package service implements instance lifecycle states for graceful removal
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// LifecycleState represents where a service instance is in its lifecycle
+type LifecycleState int
+
+const (
+	// LifecycleActive instances are eligible for all discovery results
+	LifecycleActive LifecycleState = iota
+	// LifecycleDraining instances are hidden from new discovery results but
+	// remain resolvable for existing affinity keys until their drain deadline
+	LifecycleDraining
+	// LifecycleRemoved instances have been unregistered
+	LifecycleRemoved
+)
+
+// String returns the lifecycle state name
+func (ls LifecycleState) String() string {
+	switch ls {
+	case LifecycleActive:
+		return "active"
+	case LifecycleDraining:
+		return "draining"
+	case LifecycleRemoved:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+// affinityTable binds client affinity keys to the service instance they were
+// routed to. Bindings unused for longer than the TTL expire, and the least
+// recently used binding is evicted once the table is full.
+type affinityTable struct {
+	bindings *lru.Cache
+	ttl      time.Duration
+	mutex    sync.Mutex
+}
+
+// affinityBinding records which instance an affinity key resolved to
+type affinityBinding struct {
+	ServiceID string
+	BoundAt   time.Time
+	LastUsed  time.Time
+}
+
+func newAffinityTable(maxKeys int, ttl time.Duration) *affinityTable {
+	if maxKeys <= 0 {
+		maxKeys = DefaultRegistryConfig().MaxAffinityKeys
+	}
+	bindings, _ := lru.New(maxKeys)
+
+	return &affinityTable{
+		bindings: bindings,
+		ttl:      ttl,
+	}
+}
+
+// Lookup returns the service ID bound to an affinity key. Expired bindings
+// are removed and not returned.
+func (at *affinityTable) Lookup(key string) (string, bool) {
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+
+	value, exists := at.bindings.Get(key)
+	if !exists {
+		return "", false
+	}
+
+	binding := value.(*affinityBinding)
+	if at.expired(binding, time.Now()) {
+		at.bindings.Remove(key)
+		return "", false
+	}
+	return binding.ServiceID, true
+}
+
+// Bind associates an affinity key with a service instance
+func (at *affinityTable) Bind(key, serviceID string) {
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+
+	now := time.Now()
+	if value, exists := at.bindings.Get(key); exists {
+		if binding := value.(*affinityBinding); binding.ServiceID == serviceID {
+			binding.LastUsed = now
+			return
+		}
+	}
+
+	at.bindings.Add(key, &affinityBinding{
+		ServiceID: serviceID,
+		BoundAt:   now,
+		LastUsed:  now,
+	})
+}
+
+// RemoveService drops all bindings that point at a service instance
+func (at *affinityTable) RemoveService(serviceID string) int {
+	return at.removeWhere(func(binding *affinityBinding) bool {
+		return binding.ServiceID == serviceID
+	})
+}
+
+// Expire drops bindings unused for longer than the TTL and returns how many
+// were removed
+func (at *affinityTable) Expire(now time.Time) int {
+	return at.removeWhere(func(binding *affinityBinding) bool {
+		return at.expired(binding, now)
+	})
+}
+
+// Len returns the number of bindings, including expired ones not yet removed
+func (at *affinityTable) Len() int {
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+	return at.bindings.Len()
+}
+
+func (at *affinityTable) removeWhere(match func(*affinityBinding) bool) int {
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+
+	removed := 0
+	for _, key := range at.bindings.Keys() {
+		value, exists := at.bindings.Peek(key)
+		if exists && match(value.(*affinityBinding)) {
+			at.bindings.Remove(key)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (at *affinityTable) expired(binding *affinityBinding, now time.Time) bool {
+	return at.ttl > 0 && now.Sub(binding.LastUsed) > at.ttl
+}
+
+// DrainService moves a service into the Draining state.
+//
+// Draining instances no longer appear in new discovery results, but queries
+// carrying an affinity key already bound to the instance keep resolving to it
+// until the deadline passes. At the deadline the instance is unregistered.
+func (esr *EnhancedServiceRegistry) DrainService(serviceID string, deadline time.Time) error {
+	esr.mutex.Lock()
+	defer esr.mutex.Unlock()
+
+	service, exists := esr.services[serviceID]
+	if !exists {
+		return fmt.Errorf("service %s not found", serviceID)
+	}
+
+	if service.Lifecycle == LifecycleDraining {
+		return fmt.Errorf("service %s is already draining", serviceID)
+	}
+
+	if !deadline.After(time.Now()) {
+		return fmt.Errorf("drain deadline for service %s must be in the future", serviceID)
+	}
+
+	service.Lifecycle = LifecycleDraining
+	service.DrainDeadline = deadline
+
+	// Schedule removal once the drain deadline expires
+	if timer, exists := esr.drainTimers[serviceID]; exists {
+		timer.Stop()
+	}
+	esr.drainTimers[serviceID] = time.AfterFunc(time.Until(deadline), func() {
+		esr.completeDrain(serviceID, deadline)
+	})
+
+	esr.discoveryCache.InvalidateByServiceType(service.ServiceType)
+	esr.churn.Record()
+
+	return nil
+}
+
+// CancelDrain returns a draining service to the Active state
+func (esr *EnhancedServiceRegistry) CancelDrain(serviceID string) error {
+	esr.mutex.Lock()
+	defer esr.mutex.Unlock()
+
+	service, exists := esr.services[serviceID]
+	if !exists {
+		return fmt.Errorf("service %s not found", serviceID)
+	}
+
+	if service.Lifecycle != LifecycleDraining {
+		return fmt.Errorf("service %s is not draining", serviceID)
+	}
+
+	if timer, exists := esr.drainTimers[serviceID]; exists {
+		timer.Stop()
+		delete(esr.drainTimers, serviceID)
+	}
+
+	service.Lifecycle = LifecycleActive
+	service.DrainDeadline = time.Time{}
+
+	esr.discoveryCache.InvalidateByServiceType(service.ServiceType)
+	esr.churn.Record()
+
+	return nil
+}
+
+// UnregisterService removes a service instance immediately
+func (esr *EnhancedServiceRegistry) UnregisterService(serviceID string) error {
+	esr.mutex.Lock()
+	defer esr.mutex.Unlock()
+
+	if _, exists := esr.services[serviceID]; !exists {
+		return fmt.Errorf("service %s not found", serviceID)
+	}
+
+	esr.removeServiceLocked(serviceID)
+	return nil
+}
+
+// completeDrain removes a drained service once its deadline has passed
+func (esr *EnhancedServiceRegistry) completeDrain(serviceID string, deadline time.Time) {
+	esr.mutex.Lock()
+	defer esr.mutex.Unlock()
+
+	service, exists := esr.services[serviceID]
+	if !exists || service.Lifecycle != LifecycleDraining || !service.DrainDeadline.Equal(deadline) {
+		return // Drain was cancelled or rescheduled
+	}
+
+	esr.removeServiceLocked(serviceID)
+}
+
+// removeServiceLocked removes a service from all indexes; callers must hold the write lock
+func (esr *EnhancedServiceRegistry) removeServiceLocked(serviceID string) {
+	service := esr.services[serviceID]
+	service.Lifecycle = LifecycleRemoved
+
+	delete(esr.services, serviceID)
+
+	nodeServices := esr.servicesByNode[service.NodeID]
+	for i, candidate := range nodeServices {
+		if candidate.ID == serviceID {
+			esr.servicesByNode[service.NodeID] = append(nodeServices[:i], nodeServices[i+1:]...)
+			break
+		}
+	}
+	if len(esr.servicesByNode[service.NodeID]) == 0 {
+		delete(esr.servicesByNode, service.NodeID)
+	}
+
+	if timer, exists := esr.drainTimers[serviceID]; exists {
+		timer.Stop()
+		delete(esr.drainTimers, serviceID)
+	}
+
+	esr.affinities.RemoveService(serviceID)
+	esr.healthMonitor.RemoveService(serviceID)
+	esr.discoveryCache.InvalidateByServiceType(service.ServiceType)
+	esr.churn.Record()
+}
+
+// resolveAffinity pins the instance bound to the query's affinity key at the
+// head of the result, including draining instances that are still before their
+// deadline. New affinity keys are bound to the top-ranked instance.
+func (esr *EnhancedServiceRegistry) resolveAffinity(query ServiceQuery, result *DiscoveryResult) *DiscoveryResult {
+	if query.AffinityKey == "" {
+		return result
+	}
+
+	if serviceID, bound := esr.affinities.Lookup(query.AffinityKey); bound {
+		if pinned := esr.pinnedService(serviceID, query); pinned != nil {
+			esr.affinities.Bind(query.AffinityKey, serviceID)
+			return withPinnedService(result, pinned, query.MaxResults)
+		}
+	}
+
+	// No usable binding: bind the key to the best current instance
+	if len(result.Services) > 0 {
+		esr.affinities.Bind(query.AffinityKey, result.Services[0].Service.ID)
+	}
+
+	return result
+}
+
+// pinnedService returns the bound instance if it may still serve the affinity key
+func (esr *EnhancedServiceRegistry) pinnedService(serviceID string, query ServiceQuery) *ServiceInstance {
+	esr.mutex.RLock()
+	defer esr.mutex.RUnlock()
+
+	service, exists := esr.services[serviceID]
+	if !exists {
+		return nil
+	}
+
+	if !esr.matchesQueryCriteria(service, query) {
+		return nil
+	}
+
+	switch service.Lifecycle {
+	case LifecycleActive:
+		return service
+	case LifecycleDraining:
+		if time.Now().Before(service.DrainDeadline) {
+			return service
+		}
+	}
+
+	return nil
+}
+
+// withPinnedService returns a copy of result with the pinned instance ranked
+// first, keeping at most maxResults services when maxResults is positive
+func withPinnedService(result *DiscoveryResult, pinned *ServiceInstance, maxResults int) *DiscoveryResult {
+	pinnedResult := *result
+	services := make([]*RankedService, 0, len(result.Services)+1)
+
+	var pinnedRank *RankedService
+	for _, ranked := range result.Services {
+		if ranked.Service.ID == pinned.ID {
+			pinnedRank = ranked
+			continue
+		}
+		services = append(services, ranked)
+	}
+
+	if pinnedRank == nil {
+		pinnedRank = &RankedService{
+			Service:       pinned,
+			HealthScore:   pinned.HealthScore,
+			ReasonForRank: "affinity_draining",
+		}
+		pinnedResult.TotalFound++
+	} else {
+		copied := *pinnedRank
+		copied.ReasonForRank = "affinity"
+		pinnedRank = &copied
+	}
+
+	services = append([]*RankedService{pinnedRank}, services...)
+	if maxResults > 0 && len(services) > maxResults {
+		services = services[:maxResults]
+	}
+	for i, ranked := range services {
+		if ranked.Rank != i+1 {
+			copied := *ranked
+			copied.Rank = i + 1
+			services[i] = &copied
+		}
+	}
+
+	pinnedResult.Services = services
+	return &pinnedResult
+}
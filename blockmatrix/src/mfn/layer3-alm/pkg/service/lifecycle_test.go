@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+func newTestRegistry(t *testing.T, instances int) *EnhancedServiceRegistry {
+	t.Helper()
+
+	networkGraph := graph.NewNetworkGraph(instances)
+	t.Cleanup(networkGraph.Close)
+
+	registry := NewEnhancedServiceRegistry(networkGraph, nil, nil)
+	t.Cleanup(registry.Close)
+
+	for i := 1; i <= instances; i++ {
+		err := registry.RegisterService(&ServiceInstance{
+			ID:          fmt.Sprintf("api-%d", i),
+			Name:        "api",
+			Version:     "v1",
+			NodeID:      int64(i),
+			Address:     "10.0.0.1",
+			Port:        8000 + i,
+			ServiceType: "http",
+		})
+		if err != nil {
+			t.Fatalf("RegisterService: %v", err)
+		}
+	}
+	return registry
+}
+
+func TestAffinityPinning(t *testing.T) {
+	cases := []struct {
+		name       string
+		query      ServiceQuery
+		wantPinned bool
+		wantLen    int
+	}{
+		{"matching query", ServiceQuery{ServiceName: "api"}, true, 3},
+		{"max results kept", ServiceQuery{ServiceName: "api", MaxResults: 1}, true, 1},
+		{"version mismatch", ServiceQuery{ServiceName: "api", Version: "v2"}, false, 0},
+	}
+
+	for _, tc := range cases {
+		registry := newTestRegistry(t, 3)
+
+		// Bind the key to an instance other than the top-ranked one
+		registry.affinities.Bind("session", "api-3")
+
+		tc.query.Context = context.Background()
+		tc.query.AffinityKey = "session"
+		result, err := registry.DiscoverServices(tc.query)
+		if err != nil {
+			t.Fatalf("%s: DiscoverServices: %v", tc.name, err)
+		}
+
+		if len(result.Services) != tc.wantLen {
+			t.Errorf("%s: got %d services, want %d", tc.name, len(result.Services), tc.wantLen)
+		}
+		pinned := len(result.Services) > 0 && result.Services[0].Service.ID == "api-3"
+		if pinned != tc.wantPinned {
+			t.Errorf("%s: pinned = %v, want %v", tc.name, pinned, tc.wantPinned)
+		}
+	}
+}
+
+func TestAffinityTableEviction(t *testing.T) {
+	table := newAffinityTable(2, time.Minute)
+	table.Bind("a", "api-1")
+	table.Bind("b", "api-2")
+	table.Lookup("a")
+	table.Bind("c", "api-3")
+
+	if _, found := table.Lookup("b"); found {
+		t.Error("least recently used binding was not evicted")
+	}
+	if _, found := table.Lookup("a"); !found {
+		t.Error("recently used binding was evicted")
+	}
+
+	if removed := table.Expire(time.Now().Add(2 * time.Minute)); removed != 2 {
+		t.Errorf("Expire removed %d bindings, want 2", removed)
+	}
+	if table.Len() != 0 {
+		t.Errorf("%d bindings left after expiry", table.Len())
+	}
+}
+
+func TestAffinityTableTTL(t *testing.T) {
+	table := newAffinityTable(10, time.Millisecond)
+	table.Bind("a", "api-1")
+	time.Sleep(5 * time.Millisecond)
+
+	if _, found := table.Lookup("a"); found {
+		t.Error("expired binding was returned")
+	}
+}
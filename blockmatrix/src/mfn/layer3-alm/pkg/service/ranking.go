@@ -0,0 +1,183 @@
+// Package service implements the scoring of discovered instances
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/graph"
+)
+
+// Scoring constants
+const (
+	// responseTimeScale is the response time that halves the performance score
+	responseTimeScale = 100 * time.Millisecond
+
+	// unknownHealthFactor discounts instances whose reports have stopped
+	unknownHealthFactor = 0.5
+
+	// registrationAffinity is the affinity reward for hosting a service type
+	registrationAffinity = 0.5
+)
+
+// validateService checks that an instance can be registered
+func (esr *EnhancedServiceRegistry) validateService(service *ServiceInstance) error {
+	if service == nil {
+		return errors.New("service is nil")
+	}
+	if service.ID == "" {
+		return errors.New("service ID is required")
+	}
+	if service.Name == "" {
+		return fmt.Errorf("service %s has no name", service.ID)
+	}
+	if service.Port < 0 || service.Port > math.MaxUint16 {
+		return fmt.Errorf("service %s has invalid port %d", service.ID, service.Port)
+	}
+	if existing, exists := esr.services[service.ID]; exists && existing != service {
+		return fmt.Errorf("service %s is already registered", service.ID)
+	}
+	return ValidateCapabilities(service.Capabilities)
+}
+
+// updateServiceAffinities teaches the affinity matrix that the instance's
+// node hosts its service type
+func (esr *EnhancedServiceRegistry) updateServiceAffinities(service *ServiceInstance) {
+	if service.ServiceType == "" {
+		return
+	}
+	esr.serviceAffinity.UpdateServiceAffinity(service.NodeID, service.ServiceType, registrationAffinity)
+}
+
+// updateAffinityLearning rewards the node of the top-ranked instance for the
+// queried service type, in proportion to its score, unless learning is
+// suspended
+func (esr *EnhancedServiceRegistry) updateAffinityLearning(query ServiceQuery, ranked []*RankedService) {
+	if query.ServiceType == "" || len(ranked) == 0 || esr.learningSuspended.Load() {
+		return
+	}
+	best := ranked[0]
+	esr.serviceAffinity.UpdateServiceAffinity(best.Service.NodeID, query.ServiceType, best.Score)
+}
+
+// SuspendLearning stops discovery queries from training service affinities
+// while suspend is set, as under overload; learned affinities still rank
+func (esr *EnhancedServiceRegistry) SuspendLearning(suspend bool) {
+	esr.learningSuspended.Store(suspend)
+}
+
+// calculateHealthScore is the reported health, discounted by the error rate
+// and for instances whose reports have stopped
+func (esr *EnhancedServiceRegistry) calculateHealthScore(service *ServiceInstance) float64 {
+	score := service.HealthScore * (1 - clampUnit(service.ErrorRate))
+	if service.HealthStatus == HealthUnknown {
+		score *= unknownHealthFactor
+	}
+	return clampUnit(score)
+}
+
+// calculatePerformanceScore decays with response time; instances that have
+// not reported one score neutrally
+func (esr *EnhancedServiceRegistry) calculatePerformanceScore(service *ServiceInstance) float64 {
+	if service.ResponseTime <= 0 {
+		return 0.5
+	}
+	return 1 / (1 + float64(service.ResponseTime)/float64(responseTimeScale))
+}
+
+// calculateLoadScore is the spare capacity of the instance's node
+func (esr *EnhancedServiceRegistry) calculateLoadScore(service *ServiceInstance) float64 {
+	node, exists := esr.networkGraph.GetNode(service.NodeID)
+	if !exists {
+		return 0.5
+	}
+	return 1 - clampUnit(node.LoadFactor)
+}
+
+// calculateDistance returns the great-circle distance in km between two
+// nodes, or 0 if either is unknown
+func (esr *EnhancedServiceRegistry) calculateDistance(from, to int64) float64 {
+	fromNode, fromExists := esr.networkGraph.GetNode(from)
+	toNode, toExists := esr.networkGraph.GetNode(to)
+	if !fromExists || !toExists {
+		return 0
+	}
+	return graph.HaversineDistance(fromNode.Latitude, fromNode.Longitude, toNode.Latitude, toNode.Longitude)
+}
+
+// calculateRouteLatency returns the latency of the shortest path from the
+// querying node to the instance's node, or 0 if there is none
+func (esr *EnhancedServiceRegistry) calculateRouteLatency(serviceNodeID, sourceNodeID int64) time.Duration {
+	if serviceNodeID == sourceNodeID {
+		return 0
+	}
+	path, err := esr.networkGraph.FindShortestPath(sourceNodeID, serviceNodeID)
+	if err != nil || path == nil {
+		return 0
+	}
+	return path.TotalLatency
+}
+
+// generateRankingReason names the factor contributing most to the score
+func (esr *EnhancedServiceRegistry) generateRankingReason(ranked *RankedService) string {
+	config := esr.config
+	contributions := []struct {
+		reason string
+		value  float64
+	}{
+		{"health", config.HealthWeight * ranked.HealthScore},
+		{"proximity", config.ProximityWeight * ranked.ProximityScore},
+		{"affinity", config.AffinityWeight * ranked.AffinityScore},
+		{"performance", config.PerformanceWeight * ranked.PerformanceScore},
+	}
+
+	best := contributions[0]
+	for _, contribution := range contributions[1:] {
+		if contribution.value > best.value {
+			best = contribution
+		}
+	}
+	return best.reason
+}
+
+// calculateAverageHealth returns the mean health score of ranked
+func (esr *EnhancedServiceRegistry) calculateAverageHealth(ranked []*RankedService) float64 {
+	if len(ranked) == 0 {
+		return 0
+	}
+	var total float64
+	for _, service := range ranked {
+		total += service.HealthScore
+	}
+	return total / float64(len(ranked))
+}
+
+// calculateAverageLatency returns the mean route latency of ranked
+func (esr *EnhancedServiceRegistry) calculateAverageLatency(ranked []*RankedService) time.Duration {
+	if len(ranked) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, service := range ranked {
+		total += service.RouteLatency
+	}
+	return total / time.Duration(len(ranked))
+}
+
+// calculateGeographicSpread returns the greatest distance in km between any
+// two ranked instances
+func (esr *EnhancedServiceRegistry) calculateGeographicSpread(ranked []*RankedService) float64 {
+	var spread float64
+	for i := range ranked {
+		for j := i + 1; j < len(ranked); j++ {
+			spread = math.Max(spread, esr.calculateDistance(ranked[i].Service.NodeID, ranked[j].Service.NodeID))
+		}
+	}
+	return spread
+}
+
+func clampUnit(value float64) float64 {
+	return math.Max(0, math.Min(1, value))
+}
@@ -0,0 +1,123 @@
+// Package service implements registry state snapshots for crash recovery
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/associative"
+)
+
+// RegistrySnapshot is the persistent state of a registry: its service
+// instances and the learned service affinity matrix
+type RegistrySnapshot struct {
+	Services   []*ServiceInstance
+	Affinities map[string]associative.AssociationExport
+}
+
+// Snapshot captures the registry state. Services are copies, but share tag
+// and metadata maps with the live instances and must not be modified.
+func (esr *EnhancedServiceRegistry) Snapshot() RegistrySnapshot {
+	return RegistrySnapshot{
+		Services:   esr.Instances(),
+		Affinities: esr.serviceAffinity.ExportAssociations(),
+	}
+}
+
+// Instances returns copies of all registered service instances. Copies share
+// tag and metadata maps with the live instances and must not be modified.
+func (esr *EnhancedServiceRegistry) Instances() []*ServiceInstance {
+	esr.mutex.RLock()
+	defer esr.mutex.RUnlock()
+
+	services := make([]*ServiceInstance, 0, len(esr.services))
+	for _, service := range esr.services {
+		instance := *service
+		services = append(services, &instance)
+	}
+	return services
+}
+
+// Restore loads services and affinities from a snapshot, keeping their
+// recorded health and lifecycle. Services already registered are left as
+// they are, and draining services whose deadline has passed are dropped.
+// It returns the number of services restored.
+func (esr *EnhancedServiceRegistry) Restore(snapshot RegistrySnapshot) (int, error) {
+	esr.mutex.Lock()
+	defer esr.mutex.Unlock()
+
+	return esr.restoreLocked(snapshot)
+}
+
+// Replace makes the registry mirror a snapshot taken from another registry,
+// such as a primary coordinator's: services missing from the snapshot are
+// removed, the rest take their recorded health and lifecycle, and learned
+// affinities are merged. It returns the number of services added.
+func (esr *EnhancedServiceRegistry) Replace(snapshot RegistrySnapshot) (int, error) {
+	incoming := make(map[string]*ServiceInstance, len(snapshot.Services))
+	for _, service := range snapshot.Services {
+		if service != nil {
+			incoming[service.ID] = service
+		}
+	}
+
+	esr.mutex.Lock()
+	defer esr.mutex.Unlock()
+
+	for serviceID, existing := range esr.services {
+		service, ok := incoming[serviceID]
+		if !ok || service.NodeID != existing.NodeID || service.Lifecycle != existing.Lifecycle ||
+			!service.DrainDeadline.Equal(existing.DrainDeadline) {
+			// Removed, moved or changing lifecycle: re-added by restoreLocked
+			// if still present, with its drain timer
+			esr.removeServiceLocked(serviceID)
+			continue
+		}
+
+		// Update in place so indexes and the health monitor keep pointing
+		// at the live instance
+		*existing = *service
+		esr.discoveryCache.InvalidateByServiceType(existing.ServiceType)
+	}
+
+	return esr.restoreLocked(snapshot)
+}
+
+// restoreLocked adds the snapshot's services that are not registered and
+// imports its affinities; callers must hold the write lock
+func (esr *EnhancedServiceRegistry) restoreLocked(snapshot RegistrySnapshot) (int, error) {
+	now := time.Now()
+	restored := 0
+	for _, service := range snapshot.Services {
+		if service == nil {
+			continue
+		}
+		if _, exists := esr.services[service.ID]; exists {
+			continue
+		}
+		if service.ID == "" || service.Name == "" {
+			return restored, fmt.Errorf("snapshot contains a service without an ID or name")
+		}
+
+		if service.Lifecycle == LifecycleDraining {
+			if !service.DrainDeadline.After(now) {
+				continue
+			}
+			serviceID, deadline := service.ID, service.DrainDeadline
+			esr.drainTimers[serviceID] = time.AfterFunc(deadline.Sub(now), func() {
+				esr.completeDrain(serviceID, deadline)
+			})
+		}
+
+		instance := *service
+		esr.services[service.ID] = &instance
+		esr.servicesByNode[service.NodeID] = append(esr.servicesByNode[service.NodeID], &instance)
+		esr.healthMonitor.AddService(&instance)
+		esr.discoveryCache.InvalidateByServiceType(service.ServiceType)
+		restored++
+	}
+
+	esr.serviceAffinity.ImportAssociations(snapshot.Affinities)
+
+	return restored, nil
+}
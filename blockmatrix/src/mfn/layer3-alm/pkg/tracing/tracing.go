@@ -0,0 +1,111 @@
+// Package tracing provides OpenTelemetry helpers shared by the Layer 3 ALM components
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/NeoTecDigital/hypermesh/layer3-alm/pkg/scrub"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationPrefix scopes tracer names to this module
+const instrumentationPrefix = "github.com/NeoTecDigital/hypermesh/layer3-alm/"
+
+// scrubber redacts span attributes and errors, installed with SetScrubber
+var scrubber atomic.Pointer[scrub.Scrubber]
+
+// SetScrubber installs s to scrub the string attributes spans are started
+// with and the errors End records. A nil s stops scrubbing. Attributes set
+// on a span after it starts are not scrubbed.
+func SetScrubber(s *scrub.Scrubber) {
+	scrubber.Store(s)
+}
+
+// Tracer starts spans for one component such as "routing" or "integration".
+//
+// The tracer is resolved through the global TracerProvider on every span, so
+// a provider installed after package initialization still takes effect.
+type Tracer struct {
+	name string
+}
+
+// NewTracer creates a tracer for the named component
+func NewTracer(component string) *Tracer {
+	return &Tracer{name: instrumentationPrefix + component}
+}
+
+// Start starts a span as a child of any span in ctx. A nil ctx is treated as
+// context.Background so callers can pass optional request contexts directly.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return otel.Tracer(t.name).Start(ctx, name, trace.WithAttributes(scrubAttributes(attrs)...))
+}
+
+// StartKind starts a span with an explicit span kind, used at transport boundaries
+func (t *Tracer) StartKind(ctx context.Context, name string, kind trace.SpanKind, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return otel.Tracer(t.name).Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(scrubAttributes(attrs)...))
+}
+
+// End records err on the span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		if s := scrubber.Load(); s != nil {
+			err = errors.New(s.String(err.Error()))
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// scrubAttributes returns attrs with string values scrubbed
+func scrubAttributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	s := scrubber.Load()
+	if s == nil {
+		return attrs
+	}
+
+	scrubbed := make([]attribute.KeyValue, len(attrs))
+	for i, attr := range attrs {
+		if attr.Value.Type() == attribute.STRING {
+			attr = attribute.String(string(attr.Key), s.Field(string(attr.Key), attr.Value.AsString()))
+		}
+		scrubbed[i] = attr
+	}
+	return scrubbed
+}
+
+// Inject writes the trace context of ctx into headers for propagation across
+// the wire. It returns a copy so the caller's headers are never mutated.
+func Inject(ctx context.Context, headers map[string]string) map[string]string {
+	carrier := make(propagation.MapCarrier, len(headers)+2)
+	for key, value := range headers {
+		carrier[key] = value
+	}
+	if ctx != nil {
+		otel.GetTextMapPropagator().Inject(ctx, carrier)
+	}
+	return carrier
+}
+
+// Extract returns a context carrying the remote trace context found in headers
+func Extract(ctx context.Context, headers map[string]string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if len(headers) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+}